@@ -75,6 +75,8 @@ func ChannelType2APIType(channelType int) (int, bool) {
 		apiType = constant.APITypeReplicate
 	case constant.ChannelTypeCodex:
 		apiType = constant.APITypeCodex
+	case constant.ChannelTypeBedrockConverse:
+		apiType = constant.APITypeBedrockConverse
 	}
 	if apiType == -1 {
 		return constant.APITypeOpenAI, false
@@ -179,10 +179,19 @@ var (
 	// Per-user search rate limit (applies after authentication, keyed by user ID)
 	SearchRateLimitNum            = 10
 	SearchRateLimitDuration int64 = 60
+
+	// Per-IP relay rate limit and concurrency cap (applies before token authentication)
+	RelayIPRateLimitEnable   bool
+	RelayIPRateLimitNum      int
+	RelayIPRateLimitDuration int64
+	RelayIPMaxConcurrency    int
 )
 
 var RateLimitKeyExpirationDuration = 20 * time.Minute
 
+// TrustedProxies 可信反向代理列表（CIDR 或 IP），为空时沿用 gin 默认行为信任所有代理的 X-Forwarded-For
+var TrustedProxies []string
+
 const (
 	UserStatusEnabled  = 1 // don't use 0, 0 is the default value!
 	UserStatusDisabled = 2 // also don't use 0
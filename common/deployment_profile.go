@@ -0,0 +1,65 @@
+package common
+
+import (
+	"os"
+	"sort"
+	"strings"
+)
+
+// DEPLOYMENT_PROFILE 选择一组预设的默认配置，显式设置的环境变量始终优先
+const (
+	DeploymentProfileDefault = ""
+	// DeploymentProfileEmbedded 单节点 SQLite 部署（家庭服务器等单个二进制运行的场景）：
+	// SQLite 使用 WAL 并由进程定时执行 PASSIVE checkpoint（便于 litestream 等工具接管 WAL 复制），
+	// 未配置 Redis 时以进程内缓存代替，合并写入并降低后台任务频率
+	DeploymentProfileEmbedded = "embedded"
+)
+
+var DeploymentProfile = DeploymentProfileDefault
+
+var embeddedProfileDefaults = map[string]string{
+	"MEMORY_CACHE_ENABLED":       "true",
+	"SYNC_FREQUENCY":             "600",
+	"BATCH_UPDATE_ENABLED":       "true",
+	"BATCH_UPDATE_INTERVAL":      "15",
+	"SQL_MAX_IDLE_CONNS":         "4",
+	"SQL_MAX_OPEN_CONNS":         "16",
+	"SQLITE_JOURNAL_MODE":        "WAL",
+	"SQLITE_SYNCHRONOUS":         "NORMAL",
+	"SQLITE_WAL_AUTOCHECKPOINT":  "0",
+	"SQLITE_CHECKPOINT_INTERVAL": "300",
+
+	"CHANNEL_UPSTREAM_MODEL_UPDATE_TASK_INTERVAL_MINUTES": "240",
+	"CHANNEL_RESPONSES_CAPABILITY_TASK_INTERVAL_MINUTES":  "240",
+}
+
+// applyDeploymentProfile 为未设置的环境变量填入所选部署模式的默认值，需在读取其他环境变量前调用
+func applyDeploymentProfile() {
+	DeploymentProfile = strings.ToLower(strings.TrimSpace(os.Getenv("DEPLOYMENT_PROFILE")))
+	switch DeploymentProfile {
+	case DeploymentProfileDefault:
+		return
+	case DeploymentProfileEmbedded:
+	default:
+		SysError("unknown DEPLOYMENT_PROFILE " + DeploymentProfile + ", using default profile")
+		DeploymentProfile = DeploymentProfileDefault
+		return
+	}
+
+	keys := make([]string, 0, len(embeddedProfileDefaults))
+	for key := range embeddedProfileDefaults {
+		if os.Getenv(key) == "" {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		_ = os.Setenv(key, embeddedProfileDefaults[key])
+	}
+	SysLog("deployment profile embedded applied, defaults: " + strings.Join(keys, ", "))
+}
+
+// IsEmbeddedProfile 是否以单节点 SQLite 的 embedded 模式运行
+func IsEmbeddedProfile() bool {
+	return DeploymentProfile == DeploymentProfileEmbedded
+}
@@ -0,0 +1,33 @@
+package common
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestApplyEmbeddedDeploymentProfile(t *testing.T) {
+	t.Cleanup(func() { DeploymentProfile = DeploymentProfileDefault })
+	for key := range embeddedProfileDefaults {
+		t.Setenv(key, "")
+	}
+	t.Setenv("DEPLOYMENT_PROFILE", " Embedded ")
+	t.Setenv("SYNC_FREQUENCY", "30")
+
+	applyDeploymentProfile()
+	require.True(t, IsEmbeddedProfile())
+	require.Equal(t, "30", os.Getenv("SYNC_FREQUENCY"), "explicit values win")
+	require.Equal(t, "true", os.Getenv("MEMORY_CACHE_ENABLED"))
+	require.Equal(t, "WAL", os.Getenv("SQLITE_JOURNAL_MODE"))
+}
+
+func TestApplyUnknownDeploymentProfile(t *testing.T) {
+	t.Cleanup(func() { DeploymentProfile = DeploymentProfileDefault })
+	t.Setenv("DEPLOYMENT_PROFILE", "cluster")
+	t.Setenv("SQLITE_JOURNAL_MODE", "")
+
+	applyDeploymentProfile()
+	require.False(t, IsEmbeddedProfile())
+	require.Empty(t, os.Getenv("SQLITE_JOURNAL_MODE"))
+}
@@ -0,0 +1,210 @@
+package common
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net"
+	"net/netip"
+	"os"
+	"sort"
+	"strings"
+	"sync/atomic"
+)
+
+// geoIPRange 表示一段 IP 区间及其所属国家，地址统一转换为 16 字节形式便于比较
+type geoIPRange struct {
+	start   [16]byte
+	end     [16]byte
+	country string
+}
+
+type geoIPDatabase struct {
+	ranges []geoIPRange
+}
+
+var geoIPDB atomic.Pointer[geoIPDatabase]
+
+// InitGeoIP 从 GEOIP_DB_PATH 加载 GeoIP 国家数据库。数据库因授权原因不随程序分发，需自行下载
+// DB-IP / IP2Location LITE 国家库；未配置时设置了国家限制的令牌请求全部被拒绝。
+// 显式配置的路径无法加载时返回错误，由调用方终止启动，避免国家限制在不知情时失效
+func InitGeoIP() error {
+	path := GetEnvOrDefaultString("GEOIP_DB_PATH", "")
+	if path == "" {
+		return nil
+	}
+	count, err := LoadGeoIPDatabase(path)
+	if err != nil {
+		return fmt.Errorf("failed to load GeoIP database from GEOIP_DB_PATH %s: %w", path, err)
+	}
+	if count == 0 {
+		return fmt.Errorf("GeoIP database %s contains no country ranges", path)
+	}
+	SysLog(fmt.Sprintf("GeoIP database loaded from %s, %d ranges", path, count))
+	return nil
+}
+
+// LoadGeoIPDatabase 加载 CSV 格式的国家数据库并替换当前数据库，返回区间数量。
+// 每行格式为 start,end,country[,...]，start/end 可以是 IP 文本（DB-IP 格式）
+// 或十进制整数（IP2Location 格式），文件名以 .gz 结尾时按 gzip 解压。
+func LoadGeoIPDatabase(path string) (int, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+
+	var reader io.Reader = file
+	if strings.HasSuffix(strings.ToLower(path), ".gz") {
+		gz, err := gzip.NewReader(file)
+		if err != nil {
+			return 0, err
+		}
+		defer gz.Close()
+		reader = gz
+	}
+	db, err := parseGeoIPDatabase(reader)
+	if err != nil {
+		return 0, err
+	}
+	geoIPDB.Store(db)
+	return len(db.ranges), nil
+}
+
+// LoadGeoIPDatabaseFromBytes 从内存数据加载国家数据库，格式同 LoadGeoIPDatabase（不支持 gzip）
+func LoadGeoIPDatabaseFromBytes(data []byte) (int, error) {
+	db, err := parseGeoIPDatabase(bytes.NewReader(data))
+	if err != nil {
+		return 0, err
+	}
+	geoIPDB.Store(db)
+	return len(db.ranges), nil
+}
+
+func parseGeoIPDatabase(reader io.Reader) (*geoIPDatabase, error) {
+	csvReader := csv.NewReader(reader)
+	csvReader.FieldsPerRecord = -1
+	csvReader.ReuseRecord = true
+	csvReader.Comment = '#'
+
+	db := &geoIPDatabase{}
+	line := 0
+	for {
+		record, err := csvReader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		line++
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		if len(record) < 3 {
+			return nil, fmt.Errorf("line %d: expected at least 3 fields", line)
+		}
+		country := strings.ToUpper(strings.TrimSpace(record[2]))
+		// "-" 与 "ZZ" 表示保留地址或未知国家，直接跳过
+		if country == "" || country == "-" || country == "ZZ" {
+			continue
+		}
+		start, ok := parseGeoIPAddr(record[0])
+		if !ok {
+			return nil, fmt.Errorf("line %d: invalid start address %q", line, record[0])
+		}
+		end, ok := parseGeoIPAddr(record[1])
+		if !ok {
+			return nil, fmt.Errorf("line %d: invalid end address %q", line, record[1])
+		}
+		if bytes.Compare(start[:], end[:]) > 0 {
+			return nil, fmt.Errorf("line %d: start address is greater than end address", line)
+		}
+		db.ranges = append(db.ranges, geoIPRange{start: start, end: end, country: country})
+	}
+	sort.Slice(db.ranges, func(i, j int) bool {
+		return bytes.Compare(db.ranges[i].start[:], db.ranges[j].start[:]) < 0
+	})
+	return db, nil
+}
+
+func parseGeoIPAddr(s string) ([16]byte, bool) {
+	s = strings.TrimSpace(s)
+	if addr, err := netip.ParseAddr(s); err == nil {
+		return addr.As16(), true
+	}
+	// IP2Location 使用十进制整数表示地址，IPv4 数值不超过 32 位
+	n, ok := new(big.Int).SetString(s, 10)
+	if !ok || n.Sign() < 0 || n.BitLen() > 128 {
+		return [16]byte{}, false
+	}
+	if n.BitLen() <= 32 {
+		var v4 [4]byte
+		n.FillBytes(v4[:])
+		return netip.AddrFrom4(v4).As16(), true
+	}
+	var v6 [16]byte
+	n.FillBytes(v6[:])
+	return v6, true
+}
+
+// GeoIPEnabled 返回是否已加载 GeoIP 数据库
+func GeoIPEnabled() bool {
+	db := geoIPDB.Load()
+	return db != nil && len(db.ranges) > 0
+}
+
+// LookupIPCountry 返回 IP 所属国家的 ISO 3166-1 alpha-2 代码，未知时返回空字符串
+func LookupIPCountry(ip net.IP) string {
+	db := geoIPDB.Load()
+	if db == nil || len(db.ranges) == 0 {
+		return ""
+	}
+	addr, ok := netip.AddrFromSlice(ip)
+	if !ok {
+		return ""
+	}
+	key := addr.As16()
+	// 找到最后一个起始地址不大于 key 的区间
+	idx := sort.Search(len(db.ranges), func(i int) bool {
+		return bytes.Compare(db.ranges[i].start[:], key[:]) > 0
+	}) - 1
+	if idx < 0 {
+		return ""
+	}
+	if bytes.Compare(key[:], db.ranges[idx].end[:]) > 0 {
+		return ""
+	}
+	return db.ranges[idx].country
+}
+
+// ParseCountryCodes 解析以逗号、空格或换行分隔的国家代码列表，统一转为大写并去重
+func ParseCountryCodes(s string) []string {
+	codes := make([]string, 0)
+	seen := make(map[string]struct{})
+	for _, field := range strings.FieldsFunc(s, func(r rune) bool {
+		return r == ',' || r == '\n' || r == '\r' || r == ' ' || r == '\t'
+	}) {
+		code := strings.ToUpper(field)
+		if _, ok := seen[code]; ok {
+			continue
+		}
+		seen[code] = struct{}{}
+		codes = append(codes, code)
+	}
+	return codes
+}
+
+// IsValidCountryCode 校验 ISO 3166-1 alpha-2 国家代码格式
+func IsValidCountryCode(code string) bool {
+	if len(code) != 2 {
+		return false
+	}
+	for _, r := range code {
+		if r < 'A' || r > 'Z' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,85 @@
+package common
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadGeoIPDatabase_LookupCountry(t *testing.T) {
+	t.Cleanup(func() { geoIPDB.Store(nil) })
+
+	data := `# DB-IP style
+1.0.0.0,1.0.0.255,AU
+8.8.8.0,8.8.8.255,us
+10.0.0.0,10.255.255.255,ZZ
+2001:4860::,2001:4860:ffff:ffff:ffff:ffff:ffff:ffff,US
+"16777472","16778239","CN","China"
+`
+	count, err := LoadGeoIPDatabaseFromBytes([]byte(data))
+	require.NoError(t, err)
+	assert.Equal(t, 4, count)
+	assert.True(t, GeoIPEnabled())
+
+	tests := []struct {
+		ip      string
+		country string
+	}{
+		{"1.0.0.1", "AU"},
+		{"1.0.1.5", "CN"},
+		{"8.8.8.8", "US"},
+		{"8.8.9.1", ""},
+		{"10.1.2.3", ""},
+		{"2001:4860:4860::8888", "US"},
+		{"2001:db8::1", ""},
+		{"0.0.0.1", ""},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.country, LookupIPCountry(net.ParseIP(tt.ip)), tt.ip)
+	}
+}
+
+func TestLoadGeoIPDatabase_InvalidRow(t *testing.T) {
+	t.Cleanup(func() { geoIPDB.Store(nil) })
+
+	_, err := LoadGeoIPDatabaseFromBytes([]byte("1.0.0.255,1.0.0.0,AU\n"))
+	assert.Error(t, err)
+	_, err = LoadGeoIPDatabaseFromBytes([]byte("not-an-ip,1.0.0.0,AU\n"))
+	assert.Error(t, err)
+	assert.False(t, GeoIPEnabled())
+}
+
+func TestInitGeoIP(t *testing.T) {
+	t.Cleanup(func() { geoIPDB.Store(nil) })
+
+	t.Setenv("GEOIP_DB_PATH", "")
+	require.NoError(t, InitGeoIP())
+	assert.False(t, GeoIPEnabled())
+
+	// an explicitly configured path that cannot be loaded fails startup
+	dir := t.TempDir()
+	t.Setenv("GEOIP_DB_PATH", filepath.Join(dir, "missing.csv"))
+	assert.Error(t, InitGeoIP())
+	empty := filepath.Join(dir, "empty.csv")
+	require.NoError(t, os.WriteFile(empty, []byte("# no ranges\n"), 0o644))
+	t.Setenv("GEOIP_DB_PATH", empty)
+	assert.Error(t, InitGeoIP())
+
+	valid := filepath.Join(dir, "country.csv")
+	require.NoError(t, os.WriteFile(valid, []byte("1.0.0.0,1.0.0.255,AU\n"), 0o644))
+	t.Setenv("GEOIP_DB_PATH", valid)
+	require.NoError(t, InitGeoIP())
+	assert.True(t, GeoIPEnabled())
+}
+
+func TestParseCountryCodes(t *testing.T) {
+	assert.Equal(t, []string{"US", "CN", "DE"}, ParseCountryCodes(" us, cn\nDE,us "))
+	assert.Empty(t, ParseCountryCodes(""))
+	assert.True(t, IsValidCountryCode("US"))
+	assert.False(t, IsValidCountryCode("USA"))
+	assert.False(t, IsValidCountryCode("u1"))
+}
@@ -19,17 +19,32 @@ var (
 	PrintVersion = flag.Bool("version", false, "print version and exit")
 	PrintHelp    = flag.Bool("help", false, "print help and exit")
 	LogDir       = flag.String("log-dir", "./logs", "specify the log directory")
+
+	MigrateCommand = flag.String("migrate", "", "run versioned database migrations and exit: status, up or down")
+	MigrateDryRun  = flag.Bool("migrate-dry-run", false, "print the migration SQL without executing it")
+	MigrateSteps   = flag.Int("migrate-steps", 1, "number of migrations to roll back with --migrate down")
 )
 
+// DoctorCommand 以 `newapi doctor` 运行时为 true：检查配置、数据库、Redis、渠道与系统设置后退出
+var DoctorCommand bool
+
+// RunningCommand 是否以一次性命令（--migrate、doctor）运行，此时启动流程不执行数据库迁移
+func RunningCommand() bool {
+	return *MigrateCommand != "" || DoctorCommand
+}
+
 func printHelp() {
 	fmt.Println("NewAPI(Based OneAPI) " + Version + " - The next-generation LLM gateway and AI asset management system supports multiple languages.")
 	fmt.Println("Original Project: OneAPI by JustSong - https://github.com/songquanpeng/one-api")
 	fmt.Println("Maintainer: QuantumNous - https://github.com/QuantumNous/new-api")
 	fmt.Println("Usage: newapi [--port <port>] [--log-dir <log directory>] [--version] [--help]")
+	fmt.Println("       newapi --migrate <status|up|down> [--migrate-dry-run] [--migrate-steps <n>]")
+	fmt.Println("       newapi [flags] doctor")
 }
 
 func InitEnv() {
 	flag.Parse()
+	DoctorCommand = flag.Arg(0) == "doctor"
 
 	envVersion := os.Getenv("VERSION")
 	if envVersion != "" {
@@ -41,6 +56,9 @@ func InitEnv() {
 		os.Exit(0)
 	}
 
+	// 部署模式的默认值需在读取其他环境变量前填入
+	applyDeploymentProfile()
+
 	if *PrintHelp {
 		printHelp()
 		os.Exit(0)
@@ -120,6 +138,22 @@ func InitEnv() {
 	CriticalRateLimitEnable = GetEnvOrDefaultBool("CRITICAL_RATE_LIMIT_ENABLE", true)
 	CriticalRateLimitNum = GetEnvOrDefault("CRITICAL_RATE_LIMIT", 20)
 	CriticalRateLimitDuration = int64(GetEnvOrDefault("CRITICAL_RATE_LIMIT_DURATION", 20*60))
+
+	RelayIPRateLimitEnable = GetEnvOrDefaultBool("RELAY_IP_RATE_LIMIT_ENABLE", false)
+	RelayIPRateLimitNum = GetEnvOrDefault("RELAY_IP_RATE_LIMIT", 600)
+	RelayIPRateLimitDuration = int64(GetEnvOrDefault("RELAY_IP_RATE_LIMIT_DURATION", 60))
+	RelayIPMaxConcurrency = GetEnvOrDefault("RELAY_IP_MAX_CONCURRENCY", 0)
+
+	if trustedProxies := strings.TrimSpace(os.Getenv("TRUSTED_PROXIES")); trustedProxies != "" {
+		// none 表示不信任任何代理，始终使用连接的远端地址
+		TrustedProxies = make([]string, 0)
+		for _, proxy := range strings.Split(trustedProxies, ",") {
+			proxy = strings.TrimSpace(proxy)
+			if proxy != "" && !strings.EqualFold(proxy, "none") {
+				TrustedProxies = append(TrustedProxies, proxy)
+			}
+		}
+	}
 	initConstantEnv()
 }
 
@@ -128,6 +162,20 @@ func initConstantEnv() {
 	constant.DifyDebug = GetEnvOrDefaultBool("DIFY_DEBUG", true)
 	constant.MaxFileDownloadMB = GetEnvOrDefault("MAX_FILE_DOWNLOAD_MB", 64)
 	constant.StreamScannerMaxBufferMB = GetEnvOrDefault("STREAM_SCANNER_MAX_BUFFER_MB", 64)
+	// 兼容层流式转换时单个工具调用参数的最大缓存大小（KB），超出后按 COMPAT_TOOL_ARGUMENTS_OVERFLOW 处理：truncate 截断并标记，disk 溢出到磁盘
+	constant.CompatToolArgumentsMaxKB = GetEnvOrDefault("COMPAT_TOOL_ARGUMENTS_MAX_KB", 1024)
+	constant.CompatToolArgumentsOverflow = GetEnvOrDefaultString("COMPAT_TOOL_ARGUMENTS_OVERFLOW", "truncate")
+	// 是否保留完整工具调用参数用于 done 事件，关闭后参数仅以 delta 形式透传
+	constant.CompatToolArgumentsRetain = GetEnvOrDefaultBool("COMPAT_TOOL_ARGUMENTS_RETAIN", true)
+	// fanout 广播请求（/v1/chat/completions?fanout=a,b）单次允许的最大目标模型数量
+	constant.FanoutMaxTargets = GetEnvOrDefault("FANOUT_MAX_TARGETS", 5)
+	// background=true 的 Responses 请求在每个节点上的工作协程数与排队上限
+	constant.ResponsesBackgroundWorkers = GetEnvOrDefault("RESPONSES_BACKGROUND_WORKERS", 8)
+	constant.ResponsesBackgroundQueueSize = GetEnvOrDefault("RESPONSES_BACKGROUND_QUEUE_SIZE", 256)
+	// 后台响应的最长执行时间（分钟），超时的任务被中止，排队或执行中超过该时间的响应（如节点重启丢失的任务）标记为失败
+	constant.ResponsesBackgroundTimeoutMinutes = GetEnvOrDefault("RESPONSES_BACKGROUND_TIMEOUT_MINUTES", 60)
+	// gRPC 管理接口的监听地址（如 :9090），为空时不启动
+	constant.GrpcManagementAddr = GetEnvOrDefaultString("GRPC_MANAGEMENT_ADDR", "")
 	// MaxRequestBodyMB 请求体最大大小（解压后），用于防止超大请求/zip bomb导致内存暴涨
 	constant.MaxRequestBodyMB = GetEnvOrDefault("MAX_REQUEST_BODY_MB", 128)
 	// ForceStreamOption 覆盖请求参数，强制返回usage信息
@@ -14,8 +14,8 @@ import (
 var rateLimitScript string
 
 type RedisLimiter struct {
-	client         *redis.Client
-	limitScriptSHA string
+	client redis.UniversalClient
+	script *redis.Script
 }
 
 var (
@@ -23,16 +23,16 @@ var (
 	once     sync.Once
 )
 
-func New(ctx context.Context, r *redis.Client) *RedisLimiter {
+func New(ctx context.Context, r redis.UniversalClient) *RedisLimiter {
 	once.Do(func() {
-		// 预加载脚本
-		limitSHA, err := r.ScriptLoad(ctx, rateLimitScript).Result()
-		if err != nil {
+		script := redis.NewScript(rateLimitScript)
+		// 预加载脚本；Cluster 模式下会加载到所有分片
+		if err := script.Load(ctx, r).Err(); err != nil {
 			common.SysLog(fmt.Sprintf("Failed to load rate limit script: %v", err))
 		}
 		instance = &RedisLimiter{
-			client:         r,
-			limitScriptSHA: limitSHA,
+			client: r,
+			script: script,
 		}
 	})
 
@@ -52,10 +52,10 @@ func (rl *RedisLimiter) Allow(ctx context.Context, key string, opts ...Option) (
 		opt(config)
 	}
 
-	// 执行限流
-	result, err := rl.client.EvalSha(
+	// 执行限流；Sentinel 主从切换或新分片上脚本缓存为空时自动回退为 EVAL
+	result, err := rl.script.Run(
 		ctx,
-		rl.limitScriptSHA,
+		rl.client,
 		[]string{key},
 		config.Requested,
 		config.Rate,
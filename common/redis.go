@@ -7,37 +7,67 @@ import (
 	"os"
 	"reflect"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/go-redis/redis/v8"
 	"gorm.io/gorm"
 )
 
-var RDB *redis.Client
+// RDB 为单机、Sentinel 或 Cluster 模式下的 Redis 客户端
+var RDB redis.UniversalClient
 var RedisEnabled = true
 
+// RedisKeyPrefix 为所有 Redis 键追加的前缀，多个实例共用同一个 Redis 时用于隔离
+var RedisKeyPrefix = ""
+
+const (
+	RedisModeStandalone = "standalone"
+	RedisModeSentinel   = "sentinel"
+	RedisModeCluster    = "cluster"
+)
+
 func RedisKeyCacheSeconds() int {
 	return SyncFrequency
 }
 
+// RedisKey 为键加上 REDIS_KEY_PREFIX 前缀，所有直接访问 Redis 的键都需经过此函数
+func RedisKey(key string) string {
+	if RedisKeyPrefix == "" || strings.HasPrefix(key, RedisKeyPrefix) {
+		return key
+	}
+	return RedisKeyPrefix + key
+}
+
+// redisConfigured 判断是否配置了任意一种 Redis 部署方式
+func redisConfigured() bool {
+	return os.Getenv("REDIS_CONN_STRING") != "" ||
+		os.Getenv("REDIS_SENTINEL_ADDRS") != "" ||
+		os.Getenv("REDIS_CLUSTER_ADDRS") != ""
+}
+
 // InitRedisClient This function is called after init()
 func InitRedisClient() (err error) {
-	if os.Getenv("REDIS_CONN_STRING") == "" {
+	if !redisConfigured() {
 		RedisEnabled = false
 		SysLog("REDIS_CONN_STRING not set, Redis is not enabled")
 		return nil
 	}
+	if IsEmbeddedProfile() {
+		// embedded 模式默认使用进程内缓存，显式配置的 Redis 与其他环境变量一样优先于部署模式的默认值
+		SysLog("deployment profile embedded, using the explicitly configured Redis")
+	}
 	if os.Getenv("SYNC_FREQUENCY") == "" {
 		SysLog("SYNC_FREQUENCY not set, use default value 60")
 		SyncFrequency = 60
 	}
-	SysLog("Redis is enabled")
-	opt, err := redis.ParseURL(os.Getenv("REDIS_CONN_STRING"))
+	RedisKeyPrefix = normalizeRedisKeyPrefix(os.Getenv("REDIS_KEY_PREFIX"))
+	client, mode, err := newRedisClient()
 	if err != nil {
-		FatalLog("failed to parse Redis connection string: " + err.Error())
+		FatalLog("failed to init Redis client: " + err.Error())
 	}
-	opt.PoolSize = GetEnvOrDefault("REDIS_POOL_SIZE", 10)
-	RDB = redis.NewClient(opt)
+	RDB = client
+	SysLog("Redis is enabled, mode: " + mode)
 
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
@@ -46,13 +76,115 @@ func InitRedisClient() (err error) {
 	if err != nil {
 		FatalLog("Redis ping test failed: " + err.Error())
 	}
-	if DebugEnabled {
-		SysLog(fmt.Sprintf("Redis connected to %s", opt.Addr))
-		SysLog(fmt.Sprintf("Redis database: %d", opt.DB))
+	if DebugEnabled && RedisKeyPrefix != "" {
+		SysLog(fmt.Sprintf("Redis key prefix: %s", RedisKeyPrefix))
 	}
 	return err
 }
 
+// CheckRedisConnection 按当前配置连接并 ping Redis 后关闭连接，不修改全局客户端，供 doctor 命令使用；
+// 未配置 Redis 时返回空的 mode
+func CheckRedisConnection() (mode string, err error) {
+	if !redisConfigured() {
+		return "", nil
+	}
+	client, mode, err := newRedisClient()
+	if err != nil {
+		return mode, err
+	}
+	defer client.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	_, err = client.Ping(ctx).Result()
+	return mode, err
+}
+
+// newRedisClient 根据环境变量创建客户端：
+// REDIS_CLUSTER_ADDRS 启用 Cluster，REDIS_SENTINEL_ADDRS + REDIS_SENTINEL_MASTER 启用 Sentinel，
+// 否则使用 REDIS_CONN_STRING 单机连接。前两种模式下 REDIS_CONN_STRING 可选，仅用于提供账号、密码、库号与 TLS 配置。
+func newRedisClient() (redis.UniversalClient, string, error) {
+	base := &redis.Options{}
+	if connString := os.Getenv("REDIS_CONN_STRING"); connString != "" {
+		opt, err := redis.ParseURL(connString)
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to parse Redis connection string: %w", err)
+		}
+		base = opt
+	}
+	poolSize := GetEnvOrDefault("REDIS_POOL_SIZE", 10)
+
+	if addrs := splitRedisAddrs(os.Getenv("REDIS_CLUSTER_ADDRS")); len(addrs) > 0 {
+		if base.DB != 0 {
+			return nil, "", errors.New("Redis Cluster only supports database 0")
+		}
+		if DebugEnabled {
+			SysLog(fmt.Sprintf("Redis cluster nodes: %s", strings.Join(addrs, ",")))
+		}
+		return redis.NewClusterClient(&redis.ClusterOptions{
+			Addrs:        addrs,
+			Username:     base.Username,
+			Password:     base.Password,
+			TLSConfig:    base.TLSConfig,
+			DialTimeout:  base.DialTimeout,
+			ReadTimeout:  base.ReadTimeout,
+			WriteTimeout: base.WriteTimeout,
+			PoolSize:     poolSize,
+		}), RedisModeCluster, nil
+	}
+
+	if addrs := splitRedisAddrs(os.Getenv("REDIS_SENTINEL_ADDRS")); len(addrs) > 0 {
+		masterName := strings.TrimSpace(os.Getenv("REDIS_SENTINEL_MASTER"))
+		if masterName == "" {
+			return nil, "", errors.New("REDIS_SENTINEL_MASTER is required when REDIS_SENTINEL_ADDRS is set")
+		}
+		if DebugEnabled {
+			SysLog(fmt.Sprintf("Redis sentinel nodes: %s, master: %s, database: %d", strings.Join(addrs, ","), masterName, base.DB))
+		}
+		return redis.NewFailoverClient(&redis.FailoverOptions{
+			MasterName:       masterName,
+			SentinelAddrs:    addrs,
+			SentinelUsername: os.Getenv("REDIS_SENTINEL_USERNAME"),
+			SentinelPassword: os.Getenv("REDIS_SENTINEL_PASSWORD"),
+			Username:         base.Username,
+			Password:         base.Password,
+			DB:               base.DB,
+			TLSConfig:        base.TLSConfig,
+			DialTimeout:      base.DialTimeout,
+			ReadTimeout:      base.ReadTimeout,
+			WriteTimeout:     base.WriteTimeout,
+			PoolSize:         poolSize,
+		}), RedisModeSentinel, nil
+	}
+
+	base.PoolSize = poolSize
+	if DebugEnabled {
+		SysLog(fmt.Sprintf("Redis connected to %s", base.Addr))
+		SysLog(fmt.Sprintf("Redis database: %d", base.DB))
+	}
+	return redis.NewClient(base), RedisModeStandalone, nil
+}
+
+// splitRedisAddrs 解析逗号分隔的 host:port 列表
+func splitRedisAddrs(value string) []string {
+	addrs := make([]string, 0)
+	for _, addr := range strings.Split(value, ",") {
+		addr = strings.TrimSpace(addr)
+		if addr != "" {
+			addrs = append(addrs, addr)
+		}
+	}
+	return addrs
+}
+
+// normalizeRedisKeyPrefix 保证非空前缀以 ':' 结尾
+func normalizeRedisKeyPrefix(prefix string) string {
+	prefix = strings.TrimSpace(prefix)
+	if prefix == "" || strings.HasSuffix(prefix, ":") {
+		return prefix
+	}
+	return prefix + ":"
+}
+
 func ParseRedisOption() *redis.Options {
 	opt, err := redis.ParseURL(os.Getenv("REDIS_CONN_STRING"))
 	if err != nil {
@@ -62,6 +194,7 @@ func ParseRedisOption() *redis.Options {
 }
 
 func RedisSet(key string, value string, expiration time.Duration) error {
+	key = RedisKey(key)
 	if DebugEnabled {
 		SysLog(fmt.Sprintf("Redis SET: key=%s, value=%s, expiration=%v", key, value, expiration))
 	}
@@ -70,6 +203,7 @@ func RedisSet(key string, value string, expiration time.Duration) error {
 }
 
 func RedisGet(key string) (string, error) {
+	key = RedisKey(key)
 	if DebugEnabled {
 		SysLog(fmt.Sprintf("Redis GET: key=%s", key))
 	}
@@ -89,6 +223,7 @@ func RedisGet(key string) (string, error) {
 //}
 
 func RedisDel(key string) error {
+	key = RedisKey(key)
 	if DebugEnabled {
 		SysLog(fmt.Sprintf("Redis DEL: key=%s", key))
 	}
@@ -97,6 +232,7 @@ func RedisDel(key string) error {
 }
 
 func RedisDelKey(key string) error {
+	key = RedisKey(key)
 	if DebugEnabled {
 		SysLog(fmt.Sprintf("Redis DEL Key: key=%s", key))
 	}
@@ -105,6 +241,7 @@ func RedisDelKey(key string) error {
 }
 
 func RedisHSetObj(key string, obj interface{}, expiration time.Duration) error {
+	key = RedisKey(key)
 	if DebugEnabled {
 		SysLog(fmt.Sprintf("Redis HSET: key=%s, obj=%+v, expiration=%v", key, obj, expiration))
 	}
@@ -159,6 +296,7 @@ func RedisHSetObj(key string, obj interface{}, expiration time.Duration) error {
 }
 
 func RedisHGetObj(key string, obj interface{}) error {
+	key = RedisKey(key)
 	if DebugEnabled {
 		SysLog(fmt.Sprintf("Redis HGETALL: key=%s", key))
 	}
@@ -240,6 +378,7 @@ func RedisHGetObj(key string, obj interface{}) error {
 
 // RedisIncr Add this function to handle atomic increments
 func RedisIncr(key string, delta int64) error {
+	key = RedisKey(key)
 	if DebugEnabled {
 		SysLog(fmt.Sprintf("Redis INCR: key=%s, delta=%d", key, delta))
 	}
@@ -273,6 +412,7 @@ func RedisIncr(key string, delta int64) error {
 }
 
 func RedisHIncrBy(key, field string, delta int64) error {
+	key = RedisKey(key)
 	if DebugEnabled {
 		SysLog(fmt.Sprintf("Redis HINCRBY: key=%s, field=%s, delta=%d", key, field, delta))
 	}
@@ -300,6 +440,7 @@ func RedisHIncrBy(key, field string, delta int64) error {
 }
 
 func RedisHSetField(key, field string, value interface{}) error {
+	key = RedisKey(key)
 	if DebugEnabled {
 		SysLog(fmt.Sprintf("Redis HSET field: key=%s, field=%s, value=%v", key, field, value))
 	}
@@ -0,0 +1,51 @@
+package constant
+
+// ApiVersion 固定网关对下游输出的兼容格式，取值为格式变更的发布日期
+type ApiVersion string
+
+const (
+	ApiVersionHeader = "X-New-Api-Version"
+
+	// ApiVersion20250101 Responses 兼容流使用 response.reasoning.* 事件，转换出的 Chat 请求使用 max_tokens
+	ApiVersion20250101 ApiVersion = "2025-01-01"
+	// ApiVersion20250601 Responses 兼容流使用 response.reasoning_text.* 事件，转换出的 Chat 请求使用 max_completion_tokens
+	ApiVersion20250601 ApiVersion = "2025-06-01"
+
+	ApiVersionDefault = ApiVersion20250101
+	ApiVersionLatest  = ApiVersion20250601
+)
+
+// ResolveApiVersion 解析请求头或令牌中的版本，空值返回默认版本，latest 返回最新版本
+func ResolveApiVersion(version string) (ApiVersion, bool) {
+	switch ApiVersion(version) {
+	case "":
+		return ApiVersionDefault, true
+	case "latest":
+		return ApiVersionLatest, true
+	case ApiVersion20250101, ApiVersion20250601:
+		return ApiVersion(version), true
+	}
+	return "", false
+}
+
+func IsValidApiVersion(version string) bool {
+	_, ok := ResolveApiVersion(version)
+	return ok
+}
+
+func (v ApiVersion) atLeast(target ApiVersion) bool {
+	if v == "" {
+		v = ApiVersionDefault
+	}
+	return v >= target
+}
+
+// ReasoningTextEvents 推理内容是否使用 response.reasoning_text.* 事件与 reasoning_text 内容类型
+func (v ApiVersion) ReasoningTextEvents() bool {
+	return v.atLeast(ApiVersion20250601)
+}
+
+// UseMaxCompletionTokens 转发给 OpenAI 兼容上游的 Chat Completions 请求是否使用 max_completion_tokens
+func (v ApiVersion) UseMaxCompletionTokens() bool {
+	return v.atLeast(ApiVersion20250601)
+}
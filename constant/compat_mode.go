@@ -0,0 +1,16 @@
+package constant
+
+type CompatMode string
+
+const (
+	CompatModeLenient CompatMode = "lenient" // 宽松：忽略无法转换的特性并返回警告
+	CompatModeStrict  CompatMode = "strict"  // 严格：拒绝包含无法转换特性的请求
+)
+
+func IsValidCompatMode(mode string) bool {
+	switch CompatMode(mode) {
+	case "", CompatModeLenient, CompatModeStrict:
+		return true
+	}
+	return false
+}
@@ -19,6 +19,18 @@ const (
 	ContextKeyTokenModelLimitEnabled ContextKey = "token_model_limit_enabled"
 	ContextKeyTokenModelLimit        ContextKey = "token_model_limit"
 	ContextKeyTokenCrossGroupRetry   ContextKey = "token_cross_group_retry"
+	ContextKeyTokenCompatMode        ContextKey = "token_compat_mode"
+	ContextKeyTokenContextOverflow   ContextKey = "token_context_overflow"
+	ContextKeyTokenDedupWindow       ContextKey = "token_dedup_window"
+	ContextKeyTokenDedupExemptStream ContextKey = "token_dedup_exempt_stream"
+	ContextKeyTokenBatchMode         ContextKey = "token_batch_mode"
+	ContextKeyTokenChannelLabels     ContextKey = "token_channel_labels"
+	ContextKeyTokenClientProfile     ContextKey = "token_client_profile"
+	ContextKeyClientApiVersion       ContextKey = "client_api_version"
+	ContextKeyTokenUsageWebhook      ContextKey = "token_usage_webhook"
+	ContextKeyTokenUsageWebhookKey   ContextKey = "token_usage_webhook_secret"
+	ContextKeyTokenRequestQuota      ContextKey = "token_request_quota"
+	ContextKeyTokenRequestPeriod     ContextKey = "token_request_period"
 
 	/* channel related keys */
 	ContextKeyChannelId                ContextKey = "channel_id"
@@ -52,10 +64,28 @@ const (
 	ContextKeyUsingGroup  ContextKey = "group"
 	ContextKeyUserName    ContextKey = "username"
 
+	ContextKeyUserTenantId ContextKey = "user_tenant_id"
+
+	/* tenant resolved from the request hostname or path prefix */
+	ContextKeyTenant   ContextKey = "tenant"
+	ContextKeyTenantId ContextKey = "tenant_id"
+
 	ContextKeyLocalCountTokens ContextKey = "local_count_tokens"
 
 	ContextKeySystemPromptOverride ContextKey = "system_prompt_override"
 
+	// ContextKeyModelRouterDecision stores the *service.ModelRouterDecision of a virtual router model request
+	ContextKeyModelRouterDecision ContextKey = "model_router_decision"
+
+	// ContextKeyModelDeprecation stores the *service.ModelDeprecationNotice of a request to a deprecated model
+	ContextKeyModelDeprecation ContextKey = "model_deprecation"
+
+	// ContextKeyResponsesBackgroundId stores the gateway response ID of a background Responses request being executed
+	ContextKeyResponsesBackgroundId ContextKey = "responses_background_id"
+
+	// ContextKeyConversationSummarized stores how many messages were replaced by a conversation summary
+	ContextKeyConversationSummarized ContextKey = "conversation_summarized"
+
 	// ContextKeyFileSourcesToCleanup stores file sources that need cleanup when request ends
 	ContextKeyFileSourcesToCleanup ContextKey = "file_sources_to_cleanup"
 
@@ -65,4 +95,13 @@ const (
 
 	// ContextKeyLanguage stores the user's language preference for i18n
 	ContextKeyLanguage ContextKey = "language"
+
+	// ContextKeyAudioDuration stores the audio duration in seconds reported by a transcription upstream or measured from synthesized speech
+	ContextKeyAudioDuration ContextKey = "audio_duration"
+
+	// ContextKeyRerankSearchUnits stores the search units billed by a rerank upstream such as Cohere
+	ContextKeyRerankSearchUnits ContextKey = "rerank_search_units"
+
+	// ContextKeyCompletionTokens stores the completion tokens settled for the request, reported in the stream integrity trailer
+	ContextKeyCompletionTokens ContextKey = "completion_tokens"
 )
@@ -0,0 +1,17 @@
+package constant
+
+type ContextOverflowStrategy string
+
+const (
+	ContextOverflowReject    ContextOverflowStrategy = "reject"    // 拒绝：返回超出的 token 数
+	ContextOverflowTruncate  ContextOverflowStrategy = "truncate"  // 截断：丢弃最早的对话消息
+	ContextOverflowSummarize ContextOverflowStrategy = "summarize" // 摘要：用低价模型总结较早的对话消息
+)
+
+func IsValidContextOverflowStrategy(strategy string) bool {
+	switch ContextOverflowStrategy(strategy) {
+	case "", ContextOverflowReject, ContextOverflowTruncate, ContextOverflowSummarize:
+		return true
+	}
+	return false
+}
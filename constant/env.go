@@ -4,6 +4,14 @@ var StreamingTimeout int
 var DifyDebug bool
 var MaxFileDownloadMB int
 var StreamScannerMaxBufferMB int
+var CompatToolArgumentsMaxKB int
+var CompatToolArgumentsOverflow string
+var CompatToolArgumentsRetain bool
+var FanoutMaxTargets int
+var ResponsesBackgroundWorkers int
+var ResponsesBackgroundQueueSize int
+var ResponsesBackgroundTimeoutMinutes int
+var GrpcManagementAddr string
 var ForceStreamOption bool
 var CountToken bool
 var GetMediaToken bool
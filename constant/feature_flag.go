@@ -0,0 +1,8 @@
+package constant
+
+// 已接入灰度开关的功能，开关需在后台创建并启用后才会生效
+const (
+	FeatureFlagStreamJSONGuard   = "stream_json_guard"  // 流式 JSON 模式输出校验，与全局设置任一开启即生效
+	FeatureFlagReasoningCoalesce = "reasoning_coalesce" // 流式思考内容合并发送，与全局设置任一开启即生效
+	FeatureFlagStreamIntegrity   = "stream_integrity"   // 流式响应末尾追加完整性校验注释，与全局设置任一开启即生效
+)
@@ -5,6 +5,8 @@ type TaskPlatform string
 const (
 	TaskPlatformSuno       TaskPlatform = "suno"
 	TaskPlatformMidjourney              = "mj"
+	TaskPlatformBatch                   = "batch"     // 转入上游 Batch API 的 Chat Completions 请求
+	TaskPlatformBatchApi                = "batch_api" // 通过 /v1/batches 提交的 Batch
 )
 
 const (
@@ -16,6 +18,10 @@ const (
 	TaskActionFirstTailGenerate = "firstTailGenerate"
 	TaskActionReferenceGenerate = "referenceGenerate"
 	TaskActionRemix             = "remixGenerate"
+
+	TaskActionBatchChatCompletions = "batchChatCompletions"
+	TaskActionBatchUpstream        = "batchUpstream" // 转发到渠道的上游 Batch API
+	TaskActionBatchEmulated        = "batchEmulated" // 渠道不支持 Batch，由网关逐行按普通请求执行
 )
 
 var SunoModel2Action = map[string]string{
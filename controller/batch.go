@@ -0,0 +1,305 @@
+package controller
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/constant"
+	"github.com/QuantumNous/new-api/dto"
+	"github.com/QuantumNous/new-api/logger"
+	"github.com/QuantumNous/new-api/middleware"
+	"github.com/QuantumNous/new-api/model"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	"github.com/QuantumNous/new-api/relay/helper"
+	"github.com/QuantumNous/new-api/service"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+	"github.com/QuantumNous/new-api/types"
+
+	"github.com/bytedance/gopkg/util/gopool"
+	"github.com/gin-gonic/gin"
+)
+
+// batchCompletionWindow Batch 唯一支持的完成时间窗口
+const batchCompletionWindow = "24h"
+
+// CreateBatch 创建 Batch：校验输入文件后按其中的模型选择渠道，OpenAI 渠道提交到上游 Batch API，
+// 其他渠道由网关逐行按普通请求执行（模拟模式）
+func CreateBatch(c *gin.Context) {
+	setting := operation_setting.GetBatchApiSetting()
+	if !setting.Enabled {
+		openAIErrorResponse(c, http.StatusNotImplemented, openAIErrorTypeInvalidRequest, "batch_disabled", "batch api is disabled")
+		return
+	}
+	var request dto.OpenAIBatchCreateRequest
+	if err := common.UnmarshalBodyReusable(c, &request); err != nil {
+		openAIErrorResponse(c, http.StatusBadRequest, openAIErrorTypeInvalidRequest, "invalid_request", err.Error())
+		return
+	}
+	format, ok := service.BatchApiEndpoints[request.Endpoint]
+	if !ok {
+		openAIErrorResponse(c, http.StatusBadRequest, openAIErrorTypeInvalidRequest, "invalid_request", fmt.Sprintf("unsupported endpoint: %s", request.Endpoint))
+		return
+	}
+	// Batch 中的请求按实际执行的接口类别受分组限制
+	if message, ok := middleware.CheckGroupEndpointAccess(c, request.Endpoint); !ok {
+		openAIErrorResponse(c, http.StatusForbidden, openAIErrorTypePermission, string(types.ErrorCodeAccessDenied), message)
+		return
+	}
+	if request.CompletionWindow == "" {
+		request.CompletionWindow = batchCompletionWindow
+	}
+	if request.CompletionWindow != batchCompletionWindow {
+		openAIErrorResponse(c, http.StatusBadRequest, openAIErrorTypeInvalidRequest, "invalid_request", "completion_window must be "+batchCompletionWindow)
+		return
+	}
+	userId := c.GetInt("id")
+	file, content, err := service.ReadGatewayFile(request.InputFileId, userId)
+	if err != nil {
+		openAILookupError(c, err, "input file")
+		return
+	}
+	if file.Purpose != service.BatchInputFilePurpose {
+		openAIErrorResponse(c, http.StatusBadRequest, openAIErrorTypeInvalidRequest, "invalid_request", "input file purpose must be "+service.BatchInputFilePurpose)
+		return
+	}
+	lines, modelName, err := service.ParseBatchInput(content, request.Endpoint, setting.MaxRequests)
+	if err != nil {
+		openAIErrorResponse(c, http.StatusBadRequest, openAIErrorTypeInvalidRequest, "invalid_request", err.Error())
+		return
+	}
+
+	// 按输入文件中的模型走一遍渠道分发，检查令牌与分组是否可用该模型并确定执行方式
+	modelBody, err := common.Marshal(map[string]string{"model": modelName})
+	if err != nil {
+		openAIErrorResponse(c, http.StatusInternalServerError, openAIErrorTypeServer, "internal_error", err.Error())
+		return
+	}
+	probeWriter := &backgroundWriter{header: make(http.Header)}
+	probe := newBatchContext(c, c.Request.Context(), probeWriter, request.Endpoint, modelBody)
+	defer common.CleanupBodyStorage(probe)
+	middleware.Distribute()(probe)
+	if probe.IsAborted() {
+		c.Data(probeWriter.status, "application/json", probeWriter.body.Bytes())
+		return
+	}
+	info := relaycommon.GenRelayInfoOpenAI(probe, &dto.GeneralOpenAIRequest{Model: modelName})
+	info.InitChannelMeta(probe)
+
+	now := time.Now().Unix()
+	batch := &dto.OpenAIBatch{
+		Object:           "batch",
+		Endpoint:         request.Endpoint,
+		InputFileId:      request.InputFileId,
+		CompletionWindow: request.CompletionWindow,
+		Status:           dto.OpenAIBatchStatusValidating,
+		CreatedAt:        now,
+		ExpiresAt:        now + int64(24*time.Hour/time.Second),
+		RequestCounts:    dto.OpenAIBatchRequestCounts{Total: len(lines)},
+		Metadata:         request.Metadata,
+	}
+	info.TaskRelayInfo = &relaycommon.TaskRelayInfo{PublicTaskID: "batch_" + common.GetRandomString(24)}
+
+	if service.BatchUsesUpstream(info.ChannelType) {
+		createUpstreamBatch(c, probe, info, lines, request, batch)
+		return
+	}
+	if !setting.EmulationEnabled {
+		openAIErrorResponse(c, http.StatusBadRequest, openAIErrorTypeInvalidRequest, "unsupported_channel", fmt.Sprintf("the channel serving model %s does not support the batch api", modelName))
+		return
+	}
+
+	info.TaskRelayInfo.Action = constant.TaskActionBatchEmulated
+	task := model.InitTask(constant.TaskPlatformBatchApi, info)
+	task.Action = constant.TaskActionBatchEmulated
+	task.Status = model.TaskStatusQueued
+	task.PrivateData.TokenId = info.TokenId
+	task.SetData(batch)
+	if err = task.Insert(); err != nil {
+		openAIErrorResponse(c, http.StatusInternalServerError, openAIErrorTypeServer, "internal_error", err.Error())
+		return
+	}
+
+	// Batch 不随创建请求结束而取消，逐行请求复用创建请求的鉴权信息
+	ctx := context.WithoutCancel(c.Request.Context())
+	template := newBatchContext(c, ctx, &backgroundWriter{header: make(http.Header)}, request.Endpoint, nil)
+	gopool.Go(func() {
+		service.RunEmulatedBatch(ctx, task, lines, setting.EmulationConcurrency, func(ctx context.Context, index int, line *dto.OpenAIBatchInputLine) (int, []byte) {
+			return executeBatchLine(template, ctx, task.TaskID, index, format, line)
+		})
+	})
+	c.JSON(http.StatusOK, task.ToOpenAIBatch())
+}
+
+// createUpstreamBatch 将改写模型后的输入文件提交到渠道的上游 Batch API，
+// 不预扣费，上游 Batch 结束后按输出文件中的 usage 与 Batch 倍率结算
+func createUpstreamBatch(c *gin.Context, probe *gin.Context, info *relaycommon.RelayInfo, lines []*dto.OpenAIBatchInputLine, request dto.OpenAIBatchCreateRequest, batch *dto.OpenAIBatch) {
+	if err := helper.ModelMappedHelper(probe, info, nil); err != nil {
+		openAIErrorResponse(c, http.StatusBadRequest, openAIErrorTypeInvalidRequest, "model_mapping_error", err.Error())
+		return
+	}
+	if _, err := helper.ModelPriceHelper(probe, info, 0, &types.TokenCountMeta{}); err != nil {
+		openAIErrorResponse(c, http.StatusBadRequest, openAIErrorTypeInvalidRequest, "model_price_error", err.Error())
+		return
+	}
+	billingRatio := operation_setting.GetBatchRoutingSetting().BillingRatio
+	if billingRatio <= 0 {
+		billingRatio = 1
+	}
+	info.PriceData.AddOtherRatio("batch", billingRatio)
+
+	input, err := service.RewriteBatchInputModel(lines, info.UpstreamModelName)
+	if err != nil {
+		openAIErrorResponse(c, http.StatusInternalServerError, openAIErrorTypeServer, "internal_error", err.Error())
+		return
+	}
+	channel, err := model.CacheGetChannel(info.ChannelId)
+	if err != nil {
+		openAIErrorResponse(c, http.StatusInternalServerError, openAIErrorTypeServer, "internal_error", err.Error())
+		return
+	}
+	upstreamBatch, err := service.CreateUpstreamBatch(c.Request.Context(), channel, info.ApiKey, input, request)
+	if err != nil {
+		openAIErrorResponse(c, http.StatusBadGateway, openAIErrorTypeUpstream, "upstream_error", err.Error())
+		return
+	}
+	if upstreamBatch.Status != "" {
+		batch.Status = upstreamBatch.Status
+	}
+	if upstreamBatch.ExpiresAt > 0 {
+		batch.ExpiresAt = upstreamBatch.ExpiresAt
+	}
+
+	info.TaskRelayInfo.Action = constant.TaskActionBatchUpstream
+	task := model.InitTask(constant.TaskPlatformBatchApi, info)
+	task.Action = constant.TaskActionBatchUpstream
+	task.Status = model.TaskStatusInProgress
+	task.PrivateData.UpstreamTaskID = upstreamBatch.ID
+	task.PrivateData.Key = info.ApiKey
+	task.PrivateData.TokenId = info.TokenId
+	task.PrivateData.BillingSource = service.BillingSourceWallet
+	task.PrivateData.BillingContext = &model.TaskBillingContext{
+		ModelPrice:      info.PriceData.ModelPrice,
+		GroupRatio:      info.PriceData.GroupRatioInfo.GroupRatio,
+		ModelRatio:      info.PriceData.ModelRatio,
+		CompletionRatio: info.PriceData.CompletionRatio,
+		CacheRatio:      info.PriceData.CacheRatio,
+		OtherRatios:     info.PriceData.OtherRatios,
+		OriginModelName: info.OriginModelName,
+		PerCallBilling:  info.PriceData.UsePrice,
+	}
+	task.SetData(batch)
+	if err = task.Insert(); err != nil {
+		openAIErrorResponse(c, http.StatusInternalServerError, openAIErrorTypeServer, "internal_error", err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, task.ToOpenAIBatch())
+}
+
+// executeBatchLine 按普通请求执行模拟 Batch 的一行：重新选择渠道、计费并记录日志
+func executeBatchLine(template *gin.Context, ctx context.Context, batchId string, index int, format types.RelayFormat, line *dto.OpenAIBatchInputLine) (statusCode int, body []byte) {
+	w := &backgroundWriter{header: make(http.Header)}
+	sub := newBatchContext(template, ctx, w, line.Url, line.Body)
+	sub.Set(common.RequestIdKey, fmt.Sprintf("%s-%d", batchId, index))
+	common.SetContextKey(sub, constant.ContextKeyRequestStartTime, time.Now())
+	defer common.CleanupBodyStorage(sub)
+	defer func() {
+		if r := recover(); r != nil {
+			logger.LogError(sub, fmt.Sprintf("batch %s request %d panic: %v", batchId, index, r))
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+		if w.status == 0 {
+			w.WriteHeader(http.StatusBadGateway)
+		}
+		statusCode, body = w.status, w.body.Bytes()
+	}()
+	middleware.Distribute()(sub)
+	if !sub.IsAborted() {
+		Relay(sub, format)
+	}
+	return
+}
+
+// newBatchContext builds the gin context a batch request runs in: the request is cloned
+// as a POST to the batch endpoint with the given body, and the auth context is copied
+// from c.
+func newBatchContext(c *gin.Context, ctx context.Context, w http.ResponseWriter, endpoint string, body []byte) *gin.Context {
+	sub, _ := gin.CreateTestContext(w)
+	req := c.Request.Clone(ctx)
+	req.Method = http.MethodPost
+	req.URL.Path = endpoint
+	req.URL.RawPath = ""
+	req.URL.RawQuery = ""
+	req.Header.Set("Content-Type", "application/json")
+	req.Body = io.NopCloser(bytes.NewReader(body))
+	req.ContentLength = int64(len(body))
+	sub.Request = req
+	for key, value := range c.Keys {
+		switch key {
+		case common.KeyBodyStorage, common.KeyRequestBody, "event_stream_headers_set", "use_channel":
+			continue
+		}
+		sub.Set(key, value)
+	}
+	return sub
+}
+
+func getUserBatchTask(c *gin.Context) (*model.Task, bool) {
+	task, exist, err := model.GetByTaskId(c.GetInt("id"), c.Param("id"))
+	if err != nil {
+		openAIErrorResponse(c, http.StatusInternalServerError, openAIErrorTypeServer, "internal_error", err.Error())
+		return nil, false
+	}
+	if !exist || task.Platform != constant.TaskPlatformBatchApi {
+		openAIErrorResponse(c, http.StatusNotFound, openAIErrorTypeInvalidRequest, "not_found", "batch not found")
+		return nil, false
+	}
+	return task, true
+}
+
+func GetBatch(c *gin.Context) {
+	task, ok := getUserBatchTask(c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, task.ToOpenAIBatch())
+}
+
+func ListBatches(c *gin.Context) {
+	limit := listLimit(c)
+	tasks, err := model.GetUserBatchTasks(c.GetInt("id"), c.Query("after"), limit+1)
+	if err != nil {
+		openAILookupError(c, err, "batch")
+		return
+	}
+	response := dto.ListResponse[*dto.OpenAIBatch]{Object: "list", Data: make([]*dto.OpenAIBatch, 0, len(tasks))}
+	if len(tasks) > limit {
+		tasks = tasks[:limit]
+		response.HasMore = true
+	}
+	for _, task := range tasks {
+		response.Data = append(response.Data, task.ToOpenAIBatch())
+	}
+	if len(response.Data) > 0 {
+		response.FirstId = response.Data[0].ID
+		response.LastId = response.Data[len(response.Data)-1].ID
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+func CancelBatch(c *gin.Context) {
+	task, ok := getUserBatchTask(c)
+	if !ok {
+		return
+	}
+	batch, err := service.CancelBatchTask(c.Request.Context(), task)
+	if err != nil {
+		openAIErrorResponse(c, http.StatusBadRequest, openAIErrorTypeInvalidRequest, "invalid_request", err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, batch)
+}
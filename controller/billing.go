@@ -24,6 +24,12 @@ func GetSubscription(c *gin.Context) {
 		userId := c.GetInt("id")
 		remainQuota, err = model.GetUserQuota(userId, false)
 		usedQuota, err = model.GetUserUsedQuota(userId)
+		// 后付费授信额度计入可用额度上限
+		if err == nil {
+			var allowance int
+			allowance, err = model.GetQuotaPoolPostpaidAllowance(userId)
+			remainQuota += allowance
+		}
 	}
 	if expiredTime <= 0 {
 		expiredTime = 0
@@ -0,0 +1,123 @@
+package controller
+
+import (
+	"net/http"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/i18n"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/service"
+	"github.com/QuantumNous/new-api/setting/config"
+	"github.com/QuantumNous/new-api/setting/system_setting"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetBranding returns the resolved branding of the current site (platform or tenant).
+func GetBranding(c *gin.Context) {
+	branding, err := service.ResolveBranding(model.GetContextTenant(c), nil)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	// email templates are only visible to admins
+	branding.EmailTemplates = nil
+	common.ApiSuccess(c, branding)
+}
+
+// GetBrandingSettings returns the global branding settings for editing (root only).
+func GetBrandingSettings(c *gin.Context) {
+	common.ApiSuccess(c, system_setting.GetBrandingSettings())
+}
+
+// UpdateBrandingSettings saves the global branding settings as a whole.
+func UpdateBrandingSettings(c *gin.Context) {
+	var settings system_setting.BrandingSettings
+	if err := common.DecodeJson(c.Request.Body, &settings); err != nil {
+		common.ApiErrorI18n(c, i18n.MsgInvalidParams)
+		return
+	}
+	if settings.FooterLinks == nil {
+		settings.FooterLinks = []system_setting.BrandingFooterLink{}
+	}
+	if settings.EmailTemplates == nil {
+		settings.EmailTemplates = map[string]system_setting.BrandingEmailTemplate{}
+	}
+	values, err := config.ConfigToMap(&settings)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	for key, value := range values {
+		if err = model.UpdateOption("branding."+key, value); err != nil {
+			common.ApiError(c, err)
+			return
+		}
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+	})
+}
+
+type BrandingPreviewRequest struct {
+	TenantId int                  `json:"tenant_id"`
+	Branding service.SiteBranding `json:"branding"`
+}
+
+type BrandingEmailPreview struct {
+	Subject string `json:"subject"`
+	Body    string `json:"body"`
+}
+
+// PreviewBranding resolves unsaved branding values on top of the saved ones and renders
+// the email templates with sample data, so the console can show a live preview.
+func PreviewBranding(c *gin.Context) {
+	var req BrandingPreviewRequest
+	if err := common.DecodeJson(c.Request.Body, &req); err != nil {
+		common.ApiErrorI18n(c, i18n.MsgInvalidParams)
+		return
+	}
+	var tenant *model.Tenant
+	if req.TenantId != 0 {
+		tenant = model.GetCachedTenant(req.TenantId)
+		if tenant == nil {
+			common.ApiErrorI18n(c, i18n.MsgTenantNotFound)
+			return
+		}
+	}
+	branding, err := service.ResolveBranding(tenant, &req.Branding)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	sampleVars := map[string]string{
+		"code":         "123456",
+		"link":         system_setting.ServerAddress + "/user/reset?email=user@example.com&token=preview",
+		"title":        "您的额度即将用尽",
+		"content":      "您的额度即将用尽，当前剩余额度为 $1.00",
+		"username":     "user",
+		"month":        "2026-01",
+		"requests":     "1024",
+		"quota":        "$12.34",
+		"remain_quota": "$87.66",
+		"period":       "2026-W01",
+		"report":       "<p>用量报告示例</p>",
+	}
+	emails := make(map[string]BrandingEmailPreview)
+	for _, kind := range []string{
+		system_setting.EmailTemplateVerification,
+		system_setting.EmailTemplatePasswordReset,
+		system_setting.EmailTemplateBudgetAlert,
+		system_setting.EmailTemplateUsageSummary,
+		system_setting.EmailTemplateUsageReport,
+		system_setting.EmailTemplateNotification,
+	} {
+		subject, body := service.RenderBrandingEmail(branding, kind, sampleVars)
+		emails[kind] = BrandingEmailPreview{Subject: subject, Body: body}
+	}
+	common.ApiSuccess(c, gin.H{
+		"branding": branding,
+		"emails":   emails,
+	})
+}
@@ -16,6 +16,7 @@ import (
 	relaychannel "github.com/QuantumNous/new-api/relay/channel"
 	"github.com/QuantumNous/new-api/relay/channel/gemini"
 	"github.com/QuantumNous/new-api/relay/channel/ollama"
+	"github.com/QuantumNous/new-api/relay/channel/vertex"
 	"github.com/QuantumNous/new-api/service"
 
 	"github.com/gin-gonic/gin"
@@ -66,6 +67,37 @@ func clearChannelInfo(channel *model.Channel) {
 		channel.ChannelInfo.MultiKeyDisabledReason = nil
 		channel.ChannelInfo.MultiKeyDisabledTime = nil
 	}
+	maskChannelSecrets(channel)
+}
+
+// maskChannelSecrets 渠道列表与详情不返回上游请求签名的密钥与 TLS 私钥，更新时由 restoreChannelSecrets 恢复原值
+func maskChannelSecrets(channel *model.Channel) {
+	if channel.OtherSettings == "" {
+		return
+	}
+	settings := dto.ChannelOtherSettings{}
+	if err := common.UnmarshalJsonStr(channel.OtherSettings, &settings); err != nil || settings.RequestSigning == nil {
+		return
+	}
+	settings.RequestSigning.MaskSecrets()
+	channel.SetOtherSettings(settings)
+}
+
+// restoreChannelSecrets 将更新请求中仍为占位值的密钥恢复为 origin 中保存的原值
+func restoreChannelSecrets(channel *model.Channel, origin *model.Channel) error {
+	if !strings.Contains(channel.OtherSettings, dto.SecretMask) {
+		return nil
+	}
+	settings := dto.ChannelOtherSettings{}
+	if err := common.UnmarshalJsonStr(channel.OtherSettings, &settings); err != nil {
+		return fmt.Errorf("渠道其他设置格式错误：%s", err.Error())
+	}
+	if settings.RequestSigning == nil {
+		return nil
+	}
+	settings.RequestSigning.RestoreMaskedSecrets(origin.GetOtherSettings().RequestSigning)
+	channel.SetOtherSettings(settings)
+	return nil
 }
 
 func GetAllChannels(c *gin.Context) {
@@ -84,6 +116,11 @@ func GetAllChannels(c *gin.Context) {
 			typeFilter = t
 		}
 	}
+	labelSelector, err := model.ParseChannelLabelSelector(c.Query("labels"))
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{"success": false, "message": "标签筛选条件错误：" + err.Error()})
+		return
+	}
 
 	var total int64
 
@@ -113,6 +150,9 @@ func GetAllChannels(c *gin.Context) {
 				if typeFilter >= 0 && ch.Type != typeFilter {
 					continue
 				}
+				if !ch.MatchesLabelSelector(labelSelector) {
+					continue
+				}
 				filtered = append(filtered, ch)
 			}
 			channelData = append(channelData, filtered...)
@@ -128,6 +168,9 @@ func GetAllChannels(c *gin.Context) {
 		} else if statusFilter == 0 {
 			baseQuery = baseQuery.Where("status != ?", common.ChannelStatusEnabled)
 		}
+		if len(labelSelector) > 0 {
+			baseQuery = baseQuery.Scopes(model.ChannelLabelScope(labelSelector))
+		}
 
 		baseQuery.Count(&total)
 
@@ -253,6 +296,11 @@ func SearchChannels(c *gin.Context) {
 	statusFilter := parseStatusFilter(statusParam)
 	idSort, _ := strconv.ParseBool(c.Query("id_sort"))
 	enableTagMode, _ := strconv.ParseBool(c.Query("tag_mode"))
+	labelSelector, err := model.ParseChannelLabelSelector(c.Query("labels"))
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{"success": false, "message": "标签筛选条件错误：" + err.Error()})
+		return
+	}
 	channelData := make([]*model.Channel, 0)
 	if enableTagMode {
 		tags, err := model.SearchTags(keyword, group, modelKeyword, idSort)
@@ -283,7 +331,7 @@ func SearchChannels(c *gin.Context) {
 		channelData = channels
 	}
 
-	if statusFilter == common.ChannelStatusEnabled || statusFilter == 0 {
+	if statusFilter == common.ChannelStatusEnabled || statusFilter == 0 || len(labelSelector) > 0 {
 		filtered := make([]*model.Channel, 0, len(channelData))
 		for _, ch := range channelData {
 			if statusFilter == common.ChannelStatusEnabled && ch.Status != common.ChannelStatusEnabled {
@@ -292,6 +340,9 @@ func SearchChannels(c *gin.Context) {
 			if statusFilter == 0 && ch.Status == common.ChannelStatusEnabled {
 				continue
 			}
+			if !ch.MatchesLabelSelector(labelSelector) {
+				continue
+			}
 			filtered = append(filtered, ch)
 		}
 		channelData = filtered
@@ -438,6 +489,61 @@ func validateChannel(channel *model.Channel, isAdd bool) error {
 	if err := channel.ValidateSettings(); err != nil {
 		return fmt.Errorf("渠道额外设置[channel setting] 格式错误：%s", err.Error())
 	}
+	if channel != nil && channel.BaseURL != nil && service.IsUnixSocketBaseURL(*channel.BaseURL) {
+		if err := service.ValidateUnixSocketBaseURL(*channel.BaseURL); err != nil {
+			return fmt.Errorf("渠道地址格式错误：%s", err.Error())
+		}
+	}
+
+	if channel != nil && channel.OtherSettings != "" {
+		otherSettings := dto.ChannelOtherSettings{}
+		if err := common.UnmarshalJsonStr(channel.OtherSettings, &otherSettings); err != nil {
+			return fmt.Errorf("渠道其他设置格式错误：%s", err.Error())
+		}
+		if err := relaychannel.ValidateRequestSigning(otherSettings.RequestSigning); err != nil {
+			return fmt.Errorf("上游请求签名设置错误：%s", err.Error())
+		}
+		if err := relaychannel.ValidateUpstreamTLS(otherSettings.UpstreamTLS); err != nil {
+			return fmt.Errorf("上游 TLS 设置错误：%s", err.Error())
+		}
+		if err := relaychannel.ValidateUpstreamDialer(otherSettings.UpstreamDialer); err != nil {
+			return fmt.Errorf("上游拨号设置错误：%s", err.Error())
+		}
+		if err := relaychannel.ValidateUpstreamPathTemplates(otherSettings.UpstreamPathTemplates); err != nil {
+			return fmt.Errorf("上游路径模板设置错误：%s", err.Error())
+		}
+		if err := relaychannel.ValidateStreamPrelude(otherSettings.StreamPrelude); err != nil {
+			return fmt.Errorf("流式缓冲设置错误：%s", err.Error())
+		}
+		if err := validateChannelPrewarm(otherSettings.Prewarm); err != nil {
+			return fmt.Errorf("预热设置错误：%s", err.Error())
+		}
+		if _, err := ollama.ParseKeepAlive(otherSettings.OllamaKeepAlive); err != nil {
+			return fmt.Errorf("Ollama keep_alive 设置错误：%s", err.Error())
+		}
+		if otherSettings.OllamaNumCtx < 0 {
+			return fmt.Errorf("Ollama num_ctx 不能为负数：%d", otherSettings.OllamaNumCtx)
+		}
+		if otherSettings.ForceServiceTier != "" && otherSettings.GetForceServiceTier() == "" {
+			return fmt.Errorf("强制 service_tier 只能为 default、flex 或 priority：%s", otherSettings.ForceServiceTier)
+		}
+	}
+
+	if channel != nil && channel.Labels != nil {
+		labels, err := model.NormalizeChannelLabels(*channel.Labels)
+		if err != nil {
+			return fmt.Errorf("渠道标签格式错误：%s", err.Error())
+		}
+		channel.Labels = &labels
+	}
+
+	if channel != nil && channel.AllowedEndpoints != nil {
+		endpoints, err := model.NormalizeChannelAllowedEndpoints(*channel.AllowedEndpoints)
+		if err != nil {
+			return fmt.Errorf("渠道端点类型设置错误：%s", err.Error())
+		}
+		channel.AllowedEndpoints = &endpoints
+	}
 
 	// 如果是添加操作，检查 channel 和 key 是否为空
 	if isAdd {
@@ -467,6 +573,18 @@ func validateChannel(channel *model.Channel, isAdd bool) error {
 		if regionMap["default"] == nil {
 			return fmt.Errorf("部署地区必须包含default字段")
 		}
+		for modelName, region := range regionMap {
+			if r, ok := region.(string); !ok || strings.TrimSpace(r) == "" {
+				return fmt.Errorf("模型 %s 的部署地区必须是非空字符串", modelName)
+			}
+		}
+
+		// 服务账号模式下校验密钥：需要包含 project_id、client_email 和可解析的 private_key
+		if channel.GetOtherSettings().VertexKeyType != dto.VertexKeyTypeAPIKey && strings.TrimSpace(channel.Key) != "" {
+			if err := vertex.ValidateCredentialKeys(channel.Key); err != nil {
+				return fmt.Errorf("Vertex AI 服务账号密钥无效：%w", err)
+			}
+		}
 	}
 
 	// Codex OAuth key validation (optional, only when JSON object is provided)
@@ -847,17 +965,26 @@ func UpdateChannel(c *gin.Context) {
 		return
 	}
 
-	// 使用统一的校验函数
-	if err := validateChannel(&channel.Channel, false); err != nil {
+	// Preserve existing ChannelInfo to ensure multi-key channels keep correct state even if the client does not send ChannelInfo in the request.
+	originChannel, err := model.GetChannelById(channel.Id, true)
+	if err != nil {
 		c.JSON(http.StatusOK, gin.H{
 			"success": false,
 			"message": err.Error(),
 		})
 		return
 	}
-	// Preserve existing ChannelInfo to ensure multi-key channels keep correct state even if the client does not send ChannelInfo in the request.
-	originChannel, err := model.GetChannelById(channel.Id, true)
-	if err != nil {
+	// 读取时被替换为占位值的密钥保留原值，需在校验前恢复
+	if err := restoreChannelSecrets(&channel.Channel, originChannel); err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+
+	// 使用统一的校验函数
+	if err := validateChannel(&channel.Channel, false); err != nil {
 		c.JSON(http.StatusOK, gin.H{
 			"success": false,
 			"message": err.Error(),
@@ -0,0 +1,40 @@
+package controller
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/model"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetChannelLabels 返回渠道标签的维度以及每个取值对应的渠道数量，供筛选和路由约束选择
+func GetChannelLabels(c *gin.Context) {
+	values, err := model.GetChannelLabelValues()
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, gin.H{
+		"keys":   model.ChannelLabelKeys,
+		"values": values,
+	})
+}
+
+// GetChannelLabelUsage 按标签维度汇总渠道用量，key 为 region、vendor、tier 或 compliance
+func GetChannelLabelUsage(c *gin.Context) {
+	key := c.Query("key")
+	startTimestamp, _ := strconv.ParseInt(c.Query("start_timestamp"), 10, 64)
+	endTimestamp, _ := strconv.ParseInt(c.Query("end_timestamp"), 10, 64)
+	usage, err := model.GetChannelLabelUsage(key, startTimestamp, endTimestamp)
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	common.ApiSuccess(c, usage)
+}
@@ -0,0 +1,161 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/constant"
+	"github.com/QuantumNous/new-api/dto"
+	"github.com/QuantumNous/new-api/model"
+	relaychannel "github.com/QuantumNous/new-api/relay/channel"
+)
+
+const (
+	channelPrewarmInterval           = 30 * time.Second
+	channelPrewarmRequestTimeout     = 10 * time.Second
+	channelPrewarmMaxConnections     = 8
+	channelPrewarmMinPingIntervalSec = 60
+)
+
+var (
+	channelPrewarmTaskOnce    sync.Once
+	channelPrewarmTaskRunning atomic.Bool
+	channelPrewarmPingLock    sync.Mutex
+	channelPrewarmLastPing    = make(map[int]int64)
+)
+
+// channelPrewarmConnectionLimit 返回可预热的最大连接数：超过连接池每主机空闲上限的连接会在预热后被直接关闭
+func channelPrewarmConnectionLimit() int {
+	idleLimit := common.RelayMaxIdleConnsPerHost
+	if idleLimit <= 0 {
+		idleLimit = http.DefaultMaxIdleConnsPerHost
+	}
+	return min(channelPrewarmMaxConnections, idleLimit)
+}
+
+// validateChannelPrewarm 校验渠道预热设置，nil 表示未启用
+func validateChannelPrewarm(prewarm *dto.ChannelPrewarm) error {
+	if prewarm == nil {
+		return nil
+	}
+	if limit := channelPrewarmConnectionLimit(); prewarm.Connections < 0 || prewarm.Connections > limit {
+		return fmt.Errorf("预热连接数必须在 0 到 %d 之间", limit)
+	}
+	if prewarm.PingIntervalSeconds < 0 || (prewarm.PingIntervalSeconds > 0 && prewarm.PingIntervalSeconds < channelPrewarmMinPingIntervalSec) {
+		return fmt.Errorf("就绪探测间隔为 0（不探测）或不小于 %d 秒", channelPrewarmMinPingIntervalSec)
+	}
+	return nil
+}
+
+// warmChannelConnections 通过与转发相同的客户端并发发送 HEAD 请求，使连接池中保持已完成 TLS 握手的空闲连接。
+// 上游返回任何状态码都说明连接可用
+func warmChannelConnections(channel *model.Channel, connections int) error {
+	baseURL := channel.GetBaseURL()
+	if baseURL == "" {
+		baseURL = constant.ChannelBaseURLs[channel.Type]
+	}
+	if baseURL == "" {
+		return errors.New("channel has no base url")
+	}
+	client, err := relaychannel.GetChannelHttpClient(channel.GetOtherSettings(), baseURL, channel.GetSetting().Proxy)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), channelPrewarmRequestTimeout)
+	defer cancel()
+
+	var wg sync.WaitGroup
+	errs := make([]error, connections)
+	for i := 0; i < connections; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			req, err := http.NewRequestWithContext(ctx, http.MethodHead, strings.TrimRight(baseURL, "/"), nil)
+			if err != nil {
+				errs[i] = err
+				return
+			}
+			resp, err := client.Do(req)
+			if err != nil {
+				errs[i] = err
+				return
+			}
+			// 读完响应体才能将连接放回连接池
+			_, _ = io.Copy(io.Discard, resp.Body)
+			_ = resp.Body.Close()
+		}(i)
+	}
+	wg.Wait()
+	return errors.Join(errs...)
+}
+
+// channelPrewarmPingDue 判断渠道是否到了就绪探测时间，到期时记录本次探测时间
+func channelPrewarmPingDue(channelId int, intervalSeconds int, now int64) bool {
+	channelPrewarmPingLock.Lock()
+	defer channelPrewarmPingLock.Unlock()
+	if now-channelPrewarmLastPing[channelId] < int64(intervalSeconds) {
+		return false
+	}
+	channelPrewarmLastPing[channelId] = now
+	return true
+}
+
+func runChannelPrewarmTaskOnce() {
+	if !channelPrewarmTaskRunning.CompareAndSwap(false, true) {
+		return
+	}
+	defer channelPrewarmTaskRunning.Store(false)
+
+	var channels []*model.Channel
+	if err := model.DB.
+		Where("status = ? AND settings LIKE ?", common.ChannelStatusEnabled, "%\"prewarm\"%").
+		Find(&channels).Error; err != nil {
+		common.SysLog(fmt.Sprintf("channel prewarm task failed to load channels: %v", err))
+		return
+	}
+	now := common.GetTimestamp()
+	for _, channel := range channels {
+		prewarm := channel.GetOtherSettings().Prewarm
+		if prewarm == nil {
+			continue
+		}
+		if prewarm.Connections > 0 {
+			if err := warmChannelConnections(channel, min(prewarm.Connections, channelPrewarmConnectionLimit())); err != nil && common.DebugEnabled {
+				common.SysLog(fmt.Sprintf("channel prewarm connections failed: channel_id=%d, name=%s, error=%v", channel.Id, channel.Name, err))
+			}
+		}
+		// 就绪探测会产生真实请求与消费日志，只在主节点执行
+		if prewarm.PingIntervalSeconds > 0 && common.IsMasterNode &&
+			channelPrewarmPingDue(channel.Id, max(prewarm.PingIntervalSeconds, channelPrewarmMinPingIntervalSec), now) {
+			pingModel := prewarm.PingModel
+			go func() {
+				result := testChannel(channel, pingModel, "", false)
+				if result.localErr != nil {
+					common.SysLog(fmt.Sprintf("channel prewarm ping failed: channel_id=%d, name=%s, error=%v", channel.Id, channel.Name, result.localErr))
+				}
+			}()
+		}
+	}
+}
+
+// StartChannelPrewarmTask 定期为配置了预热的渠道保持空闲连接并发送就绪探测。
+// 连接池属于各个节点，因此所有节点都会预热连接
+func StartChannelPrewarmTask() {
+	channelPrewarmTaskOnce.Do(func() {
+		go func() {
+			ticker := time.NewTicker(channelPrewarmInterval)
+			defer ticker.Stop()
+			for range ticker.C {
+				runChannelPrewarmTaskOnce()
+			}
+		}()
+	})
+}
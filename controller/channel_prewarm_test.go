@@ -0,0 +1,40 @@
+package controller
+
+import (
+	"testing"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/dto"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateChannelPrewarm(t *testing.T) {
+	assert.NoError(t, validateChannelPrewarm(nil))
+	assert.NoError(t, validateChannelPrewarm(&dto.ChannelPrewarm{Connections: 2}))
+	assert.NoError(t, validateChannelPrewarm(&dto.ChannelPrewarm{PingIntervalSeconds: 300}))
+	assert.Error(t, validateChannelPrewarm(&dto.ChannelPrewarm{Connections: 9}))
+	assert.Error(t, validateChannelPrewarm(&dto.ChannelPrewarm{Connections: -1}))
+	assert.Error(t, validateChannelPrewarm(&dto.ChannelPrewarm{PingIntervalSeconds: 30}))
+}
+
+func TestValidateChannelPrewarmClampsToIdleConnLimit(t *testing.T) {
+	original := common.RelayMaxIdleConnsPerHost
+	defer func() { common.RelayMaxIdleConnsPerHost = original }()
+
+	common.RelayMaxIdleConnsPerHost = 4
+	assert.NoError(t, validateChannelPrewarm(&dto.ChannelPrewarm{Connections: 4}))
+	assert.Error(t, validateChannelPrewarm(&dto.ChannelPrewarm{Connections: 5}))
+
+	common.RelayMaxIdleConnsPerHost = 100
+	assert.NoError(t, validateChannelPrewarm(&dto.ChannelPrewarm{Connections: 8}))
+	assert.Error(t, validateChannelPrewarm(&dto.ChannelPrewarm{Connections: 9}))
+}
+
+func TestChannelPrewarmPingDue(t *testing.T) {
+	const channelId = -1
+	defer delete(channelPrewarmLastPing, channelId)
+
+	assert.True(t, channelPrewarmPingDue(channelId, 60, 1000))
+	assert.False(t, channelPrewarmPingDue(channelId, 60, 1059))
+	assert.True(t, channelPrewarmPingDue(channelId, 60, 1060))
+}
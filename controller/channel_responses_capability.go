@@ -0,0 +1,208 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/constant"
+	"github.com/QuantumNous/new-api/dto"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/service"
+	"github.com/QuantumNous/new-api/setting/model_setting"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	channelResponsesCapabilityTaskDefaultIntervalMinutes = 30
+	channelResponsesCapabilityTaskBatchSize              = 100
+)
+
+var (
+	channelResponsesCapabilityTaskOnce    sync.Once
+	channelResponsesCapabilityTaskRunning atomic.Bool
+)
+
+type detectChannelResponsesCapabilityRequest struct {
+	ID int `json:"id"`
+}
+
+// supportsResponsesCapabilityProbe reports whether the channel speaks the plain OpenAI
+// protocol, where /v1/responses may or may not be served by the upstream.
+func supportsResponsesCapabilityProbe(channel *model.Channel) bool {
+	if channel.Type == constant.ChannelTypeAzure || channel.Type == constant.ChannelTypeOpenAIResponses {
+		return false
+	}
+	apiType, _ := common.ChannelType2APIType(channel.Type)
+	return apiType == constant.APITypeOpenAI
+}
+
+func probeChannelResponsesModel(channel *model.Channel) string {
+	if channel.TestModel != nil && strings.TrimSpace(*channel.TestModel) != "" {
+		return strings.TrimSpace(*channel.TestModel)
+	}
+	models := channel.GetModels()
+	if len(models) == 0 {
+		return ""
+	}
+	return strings.TrimSpace(models[0])
+}
+
+// detectAndPersistChannelResponsesCapability probes the channel and stores the result in
+// its settings.
+func detectAndPersistChannelResponsesCapability(channel *model.Channel) (*dto.ResponsesCapability, error) {
+	if !supportsResponsesCapabilityProbe(channel) {
+		return nil, errors.New("channel type does not support responses capability detection")
+	}
+	probeModel := probeChannelResponsesModel(channel)
+	if probeModel == "" {
+		return nil, errors.New("channel has no model to probe with")
+	}
+	baseURL := channel.GetBaseURL()
+	if baseURL == "" {
+		baseURL = constant.ChannelBaseURLs[channel.Type]
+	}
+	key, _, apiErr := channel.GetNextEnabledKey()
+	if apiErr != nil {
+		return nil, apiErr
+	}
+	client, err := service.GetHttpClientForBaseURL(baseURL, channel.GetSetting().Proxy)
+	if err != nil {
+		return nil, err
+	}
+	capability, err := service.ProbeResponsesCapability(client, baseURL, strings.TrimSpace(key), probeModel)
+	if err != nil {
+		return nil, err
+	}
+	settings := channel.GetOtherSettings()
+	settings.ResponsesCapability = capability
+	channel.SetOtherSettings(settings)
+	err = model.DB.Model(&model.Channel{}).Where("id = ?", channel.Id).Update("settings", channel.OtherSettings).Error
+	return capability, err
+}
+
+func isChannelResponsesCapabilityStale(channel *model.Channel, now int64) bool {
+	capability := channel.GetOtherSettings().ResponsesCapability
+	if capability == nil {
+		return true
+	}
+	recheckHours := model_setting.GetGlobalSettings().ResponsesCapabilityRecheckHours
+	if recheckHours <= 0 {
+		return false
+	}
+	return now-capability.CheckedAt >= int64(recheckHours)*3600
+}
+
+func runChannelResponsesCapabilityTaskOnce() {
+	if !model_setting.GetGlobalSettings().ResponsesCapabilityAutoDetect {
+		return
+	}
+	if !channelResponsesCapabilityTaskRunning.CompareAndSwap(false, true) {
+		return
+	}
+	defer channelResponsesCapabilityTaskRunning.Store(false)
+
+	now := common.GetTimestamp()
+	detected := 0
+	failed := 0
+	lastID := 0
+	for {
+		var channels []*model.Channel
+		query := model.DB.
+			Select("id", "name", "type", "key", "status", "base_url", "models", "test_model", "settings", "setting", "channel_info").
+			Where("status = ?", common.ChannelStatusEnabled).
+			Order("id asc").
+			Limit(channelResponsesCapabilityTaskBatchSize)
+		if lastID > 0 {
+			query = query.Where("id > ?", lastID)
+		}
+		if err := query.Find(&channels).Error; err != nil {
+			common.SysLog(fmt.Sprintf("responses capability task failed to load channels: %v", err))
+			return
+		}
+		for _, channel := range channels {
+			lastID = channel.Id
+			if !supportsResponsesCapabilityProbe(channel) || !isChannelResponsesCapabilityStale(channel, now) {
+				continue
+			}
+			if _, err := detectAndPersistChannelResponsesCapability(channel); err != nil {
+				failed++
+				common.SysLog(fmt.Sprintf("responses capability detection failed: channel_id=%d, name=%s, error=%v", channel.Id, channel.Name, err))
+				continue
+			}
+			detected++
+		}
+		if len(channels) < channelResponsesCapabilityTaskBatchSize {
+			break
+		}
+	}
+	if detected > 0 {
+		refreshChannelRuntimeCache()
+	}
+	if detected > 0 || failed > 0 {
+		common.SysLog(fmt.Sprintf("responses capability task done: detected=%d, failed=%d", detected, failed))
+	}
+}
+
+// StartChannelResponsesCapabilityTask periodically probes OpenAI compatible channels whose
+// /v1/responses capability is unknown or stale.
+func StartChannelResponsesCapabilityTask() {
+	channelResponsesCapabilityTaskOnce.Do(func() {
+		if !common.IsMasterNode {
+			return
+		}
+		intervalMinutes := common.GetEnvOrDefault(
+			"CHANNEL_RESPONSES_CAPABILITY_TASK_INTERVAL_MINUTES",
+			channelResponsesCapabilityTaskDefaultIntervalMinutes,
+		)
+		if intervalMinutes < 1 {
+			intervalMinutes = channelResponsesCapabilityTaskDefaultIntervalMinutes
+		}
+		interval := time.Duration(intervalMinutes) * time.Minute
+
+		go func() {
+			common.SysLog(fmt.Sprintf("responses capability task started: interval=%s", interval))
+			runChannelResponsesCapabilityTaskOnce()
+			ticker := time.NewTicker(interval)
+			defer ticker.Stop()
+			for range ticker.C {
+				runChannelResponsesCapabilityTaskOnce()
+			}
+		}()
+	})
+}
+
+// DetectChannelResponsesCapability probes a single channel right away.
+func DetectChannelResponsesCapability(c *gin.Context) {
+	var req detectChannelResponsesCapabilityRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	if req.ID <= 0 {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "invalid channel id",
+		})
+		return
+	}
+
+	channel, err := model.GetChannelById(req.ID, true)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	capability, err := detectAndPersistChannelResponsesCapability(channel)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	refreshChannelRuntimeCache()
+	common.ApiSuccess(c, capability)
+}
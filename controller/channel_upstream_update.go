@@ -242,14 +242,16 @@ func fetchChannelUpstreamModelIDs(channel *model.Channel) ([]string, error) {
 	}
 
 	if channel.Type == constant.ChannelTypeOllama {
-		key := strings.TrimSpace(strings.Split(channel.Key, "\n")[0])
-		models, err := ollama.FetchOllamaModels(baseURL, key)
-		if err != nil {
+		ids, err := fetchOllamaTagModelIDs(channel, baseURL)
+		if err == nil {
+			return ids, nil
+		}
+		// 只开放 OpenAI 兼容接口的 Ollama 部署（如经反向代理）回退到 /v1/models
+		ids, fallbackErr := fetchOpenAICompatibleModelIDs(channel, fmt.Sprintf("%s/v1/models", baseURL))
+		if fallbackErr != nil {
 			return nil, err
 		}
-		return normalizeModelNames(lo.Map(models, func(item ollama.OllamaModel, _ int) string {
-			return item.Name
-		})), nil
+		return ids, nil
 	}
 
 	if channel.Type == constant.ChannelTypeGemini {
@@ -291,6 +293,30 @@ func fetchChannelUpstreamModelIDs(channel *model.Channel) ([]string, error) {
 		url = fmt.Sprintf("%s/v1/models", baseURL)
 	}
 
+	ids, err := fetchOpenAICompatibleModelIDs(channel, url)
+	if err != nil && channel.Type == constant.ChannelTypeSelfHosted {
+		// 自托管渠道可能是未开放 /v1/models 的 Ollama，尝试 /api/tags
+		if tagIDs, tagErr := fetchOllamaTagModelIDs(channel, baseURL); tagErr == nil {
+			return tagIDs, nil
+		}
+	}
+	return ids, err
+}
+
+// fetchOllamaTagModelIDs 通过 Ollama 的 /api/tags 获取本地已拉取的模型
+func fetchOllamaTagModelIDs(channel *model.Channel, baseURL string) ([]string, error) {
+	key := strings.TrimSpace(strings.Split(channel.Key, "\n")[0])
+	models, err := ollama.FetchOllamaModels(baseURL, key)
+	if err != nil {
+		return nil, err
+	}
+	return normalizeModelNames(lo.Map(models, func(item ollama.OllamaModel, _ int) string {
+		return item.Name
+	})), nil
+}
+
+// fetchOpenAICompatibleModelIDs 通过 OpenAI 兼容的模型列表接口获取模型
+func fetchOpenAICompatibleModelIDs(channel *model.Channel, url string) ([]string, error) {
 	key, _, apiErr := channel.GetNextEnabledKey()
 	if apiErr != nil {
 		return nil, fmt.Errorf("获取渠道密钥失败: %w", apiErr)
@@ -338,13 +364,13 @@ func checkAndPersistChannelUpstreamModelUpdates(
 	settings *dto.ChannelOtherSettings,
 	force bool,
 	allowAutoApply bool,
-) (modelsChanged bool, autoAdded int, err error) {
+) (modelsChanged bool, autoAdded int, autoRemoved int, err error) {
 	now := common.GetTimestamp()
 	if !force {
 		minInterval := getUpstreamModelUpdateMinCheckIntervalSeconds()
 		if settings.UpstreamModelUpdateLastCheckTime > 0 &&
 			now-settings.UpstreamModelUpdateLastCheckTime < minInterval {
-			return false, 0, nil
+			return false, 0, 0, nil
 		}
 	}
 
@@ -352,12 +378,13 @@ func checkAndPersistChannelUpstreamModelUpdates(
 	settings.UpstreamModelUpdateLastCheckTime = now
 	if fetchErr != nil {
 		if err = updateChannelUpstreamModelSettings(channel, *settings, false); err != nil {
-			return false, 0, err
+			return false, 0, 0, err
 		}
-		return false, 0, fetchErr
+		return false, 0, 0, fetchErr
 	}
 
-	if allowAutoApply && settings.UpstreamModelUpdateAutoSyncEnabled && len(pendingAddModels) > 0 {
+	autoDiscovery := allowAutoApply && settings.ModelAutoDiscovery
+	if allowAutoApply && (settings.UpstreamModelUpdateAutoSyncEnabled || autoDiscovery) && len(pendingAddModels) > 0 {
 		originModels := normalizeModelNames(channel.GetModels())
 		mergedModels := mergeModelNames(originModels, pendingAddModels)
 		if len(mergedModels) > len(originModels) {
@@ -369,17 +396,41 @@ func checkAndPersistChannelUpstreamModelUpdates(
 	} else {
 		settings.UpstreamModelUpdateLastDetectedModels = pendingAddModels
 	}
+	if autoDiscovery && len(pendingRemoveModels) > 0 {
+		remainingModels := removeDiscoveredModels(channel.GetModels(), pendingRemoveModels)
+		if len(remainingModels) > 0 {
+			autoRemoved = len(normalizeModelNames(channel.GetModels())) - len(remainingModels)
+			channel.Models = strings.Join(remainingModels, ",")
+			modelsChanged = true
+			pendingRemoveModels = []string{}
+		}
+	}
 	settings.UpstreamModelUpdateLastRemovedModels = pendingRemoveModels
 
 	if err = updateChannelUpstreamModelSettings(channel, *settings, modelsChanged); err != nil {
-		return false, autoAdded, err
+		return false, autoAdded, autoRemoved, err
 	}
 	if modelsChanged {
 		if err = channel.UpdateAbilities(nil); err != nil {
-			return true, autoAdded, err
+			return true, autoAdded, autoRemoved, err
 		}
 	}
-	return modelsChanged, autoAdded, nil
+	return modelsChanged, autoAdded, autoRemoved, nil
+}
+
+// removeDiscoveredModels 返回自动发现下线模型后剩余的渠道模型。上游暂时返回空列表等情况下
+// 全部模型都会被判定为下线，此时返回空，保留原有模型而不是清空渠道
+func removeDiscoveredModels(localModels []string, removedModels []string) []string {
+	remainingModels := subtractModelNames(localModels, removedModels)
+	if len(remainingModels) == 0 {
+		return nil
+	}
+	return remainingModels
+}
+
+// upstreamModelUpdateEnabled 渠道是否参与上游模型巡检
+func upstreamModelUpdateEnabled(settings dto.ChannelOtherSettings) bool {
+	return settings.UpstreamModelUpdateCheckEnabled || settings.ModelAutoDiscovery
 }
 
 func refreshChannelRuntimeCache() {
@@ -540,12 +591,12 @@ func runChannelUpstreamModelUpdateTaskOnce() {
 			}
 
 			settings := channel.GetOtherSettings()
-			if !settings.UpstreamModelUpdateCheckEnabled {
+			if !upstreamModelUpdateEnabled(settings) {
 				continue
 			}
 
 			checkedChannels++
-			modelsChanged, autoAdded, err := checkAndPersistChannelUpstreamModelUpdates(channel, &settings, false, true)
+			modelsChanged, autoAdded, autoRemoved, err := checkAndPersistChannelUpstreamModelUpdates(channel, &settings, false, true)
 			if err != nil {
 				failedChannels++
 				failedChannelIDs = append(failedChannelIDs, channel.Id)
@@ -555,7 +606,7 @@ func runChannelUpstreamModelUpdateTaskOnce() {
 			currentAddModels := normalizeModelNames(settings.UpstreamModelUpdateLastDetectedModels)
 			currentRemoveModels := normalizeModelNames(settings.UpstreamModelUpdateLastRemovedModels)
 			currentAddCount := len(currentAddModels) + autoAdded
-			currentRemoveCount := len(currentRemoveModels)
+			currentRemoveCount := len(currentRemoveModels) + autoRemoved
 			detectedAddModels += currentAddCount
 			detectedRemoveModels += currentRemoveCount
 			if currentAddCount > 0 || currentRemoveCount > 0 {
@@ -730,7 +781,7 @@ func DetectChannelUpstreamModelUpdates(c *gin.Context) {
 	}
 
 	settings := channel.GetOtherSettings()
-	modelsChanged, autoAdded, err := checkAndPersistChannelUpstreamModelUpdates(channel, &settings, true, false)
+	modelsChanged, autoAdded, _, err := checkAndPersistChannelUpstreamModelUpdates(channel, &settings, true, false)
 	if err != nil {
 		common.ApiError(c, err)
 		return
@@ -845,7 +896,7 @@ func ApplyAllChannelUpstreamModelUpdates(c *gin.Context) {
 			}
 
 			settings := channel.GetOtherSettings()
-			if !settings.UpstreamModelUpdateCheckEnabled {
+			if !upstreamModelUpdateEnabled(settings) {
 				continue
 			}
 
@@ -925,11 +976,11 @@ func DetectAllChannelUpstreamModelUpdates(c *gin.Context) {
 				continue
 			}
 			settings := channel.GetOtherSettings()
-			if !settings.UpstreamModelUpdateCheckEnabled {
+			if !upstreamModelUpdateEnabled(settings) {
 				continue
 			}
 
-			modelsChanged, autoAdded, err := checkAndPersistChannelUpstreamModelUpdates(channel, &settings, true, false)
+			modelsChanged, autoAdded, _, err := checkAndPersistChannelUpstreamModelUpdates(channel, &settings, true, false)
 			if err != nil {
 				failed = append(failed, channel.Id)
 				continue
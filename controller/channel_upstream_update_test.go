@@ -111,6 +111,24 @@ func TestCollectPendingUpstreamModelChangesFromModels_WithModelMapping(t *testin
 	require.Equal(t, []string{"stale-model"}, pendingRemoveModels)
 }
 
+func TestRemoveDiscoveredModels(t *testing.T) {
+	require.Equal(t, []string{"llama3.1:8b"}, removeDiscoveredModels(
+		[]string{"llama3.1:8b", "qwen2.5:7b"},
+		[]string{"qwen2.5:7b"},
+	))
+	// an upstream that temporarily lists no models must not empty the channel
+	require.Nil(t, removeDiscoveredModels(
+		[]string{"llama3.1:8b", "qwen2.5:7b"},
+		[]string{"llama3.1:8b", "qwen2.5:7b"},
+	))
+}
+
+func TestUpstreamModelUpdateEnabled(t *testing.T) {
+	require.False(t, upstreamModelUpdateEnabled(dto.ChannelOtherSettings{}))
+	require.True(t, upstreamModelUpdateEnabled(dto.ChannelOtherSettings{UpstreamModelUpdateCheckEnabled: true}))
+	require.True(t, upstreamModelUpdateEnabled(dto.ChannelOtherSettings{ModelAutoDiscovery: true}))
+}
+
 func TestBuildUpstreamModelUpdateTaskNotificationContent_OmitOverflowDetails(t *testing.T) {
 	channelSummaries := make([]upstreamModelUpdateChannelSummary, 0, 12)
 	for i := 0; i < 12; i++ {
@@ -0,0 +1,102 @@
+package controller
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+type createDatasetExportRequest struct {
+	Format      string `json:"format"`
+	Destination string `json:"destination"`
+	RedactPII   *bool  `json:"redact_pii"` // 默认开启
+	model.DatasetResponseFilter
+}
+
+// CreateDatasetExport 创建训练数据集导出任务，任务在后台执行，通过 GetDatasetExport 查看进度与结果
+func CreateDatasetExport(c *gin.Context) {
+	var req createDatasetExportRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		common.ApiErrorMsg(c, "无效的参数")
+		return
+	}
+	if req.Format == "" {
+		req.Format = model.DatasetExportFormatSFT
+	}
+	if req.Destination == "" {
+		req.Destination = model.DatasetExportDestinationFile
+	}
+	redactPII := req.RedactPII == nil || *req.RedactPII
+	export, err := service.StartDatasetExport(c.GetInt("id"), req.Format, req.Destination, req.DatasetResponseFilter, redactPII)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	model.RecordLog(c.GetInt("id"), model.LogTypeManage, fmt.Sprintf("创建训练数据集导出任务 #%d（%s，%s）", export.Id, export.Format, export.Destination))
+	common.ApiSuccess(c, export)
+}
+
+func GetDatasetExports(c *gin.Context) {
+	pageInfo := common.GetPageQuery(c)
+	exports, total, err := model.GetDatasetExports(pageInfo.GetStartIdx(), pageInfo.GetPageSize())
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	pageInfo.SetTotal(int(total))
+	pageInfo.SetItems(exports)
+	common.ApiSuccess(c, pageInfo)
+}
+
+func getDatasetExportParam(c *gin.Context) *model.DatasetExport {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		common.ApiErrorMsg(c, "invalid id")
+		return nil
+	}
+	export, err := model.GetDatasetExport(id)
+	if err != nil {
+		common.ApiError(c, err)
+		return nil
+	}
+	if export == nil {
+		common.ApiErrorMsg(c, "导出任务不存在")
+		return nil
+	}
+	return export
+}
+
+func GetDatasetExport(c *gin.Context) {
+	export := getDatasetExportParam(c)
+	if export == nil {
+		return
+	}
+	common.ApiSuccess(c, export)
+}
+
+// DownloadDatasetExport 下载保存为网关文件的数据集
+func DownloadDatasetExport(c *gin.Context) {
+	export := getDatasetExportParam(c)
+	if export == nil {
+		return
+	}
+	if export.FileId == "" {
+		common.ApiErrorMsg(c, "该任务没有可下载的文件")
+		return
+	}
+	file, reader, err := service.OpenGatewayFile(export.FileId, export.CreatedBy)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	defer reader.Close()
+	c.DataFromReader(http.StatusOK, file.Bytes, "application/jsonl", reader, map[string]string{
+		"Content-Disposition": fmt.Sprintf(`attachment; filename="%s"`, file.Filename),
+	})
+}
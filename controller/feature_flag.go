@@ -0,0 +1,80 @@
+package controller
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/i18n"
+	"github.com/QuantumNous/new-api/model"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetFeatureFlags returns all feature flags (root only)
+func GetFeatureFlags(c *gin.Context) {
+	flags, err := model.GetAllFeatureFlags()
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, flags)
+}
+
+// CreateFeatureFlag creates a new feature flag
+func CreateFeatureFlag(c *gin.Context) {
+	var flag model.FeatureFlag
+	if err := common.DecodeJson(c.Request.Body, &flag); err != nil {
+		common.ApiErrorI18n(c, i18n.MsgInvalidParams)
+		return
+	}
+	flag.Id = 0
+	if model.IsFeatureFlagNameTaken(flag.Name, 0) {
+		common.ApiErrorI18n(c, i18n.MsgFeatureFlagNameExists)
+		return
+	}
+	if err := model.CreateFeatureFlag(&flag); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, flag)
+}
+
+// UpdateFeatureFlag updates an existing feature flag, e.g. to raise its rollout percentage
+func UpdateFeatureFlag(c *gin.Context) {
+	var flag model.FeatureFlag
+	if err := common.DecodeJson(c.Request.Body, &flag); err != nil || flag.Id == 0 {
+		common.ApiErrorI18n(c, i18n.MsgInvalidParams)
+		return
+	}
+	if _, err := model.GetFeatureFlagById(flag.Id); err != nil {
+		common.ApiErrorI18n(c, i18n.MsgFeatureFlagNotFound)
+		return
+	}
+	if model.IsFeatureFlagNameTaken(flag.Name, flag.Id) {
+		common.ApiErrorI18n(c, i18n.MsgFeatureFlagNameExists)
+		return
+	}
+	if err := model.UpdateFeatureFlag(&flag); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, flag)
+}
+
+// DeleteFeatureFlag deletes a feature flag; gated features fall back to off
+func DeleteFeatureFlag(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		common.ApiErrorI18n(c, i18n.MsgInvalidParams)
+		return
+	}
+	if err = model.DeleteFeatureFlag(id); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+	})
+}
@@ -0,0 +1,107 @@
+package controller
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/model"
+
+	"github.com/gin-gonic/gin"
+)
+
+const maxFeedbackCommentLength = 2000
+
+type feedbackRequest struct {
+	RequestId string `json:"request_id"`
+	Rating    int    `json:"rating"` // 1 好评、-1 差评
+	Comment   string `json:"comment"`
+}
+
+// CreateFeedback 为当前用户的一次请求提交评价，请求 ID 取自响应头 X-Oneapi-Request-Id；
+// 重复提交时覆盖之前的评价
+func CreateFeedback(c *gin.Context) {
+	var req feedbackRequest
+	if err := common.UnmarshalBodyReusable(c, &req); err != nil {
+		openAIErrorResponse(c, http.StatusBadRequest, openAIErrorTypeInvalidRequest, "invalid_request", err.Error())
+		return
+	}
+	req.RequestId = strings.TrimSpace(req.RequestId)
+	if req.RequestId == "" {
+		openAIErrorResponse(c, http.StatusBadRequest, openAIErrorTypeInvalidRequest, "invalid_request", "request_id is required")
+		return
+	}
+	if req.Rating != model.FeedbackRatingPositive && req.Rating != model.FeedbackRatingNegative {
+		openAIErrorResponse(c, http.StatusBadRequest, openAIErrorTypeInvalidRequest, "invalid_request", "rating must be 1 or -1")
+		return
+	}
+	if utf8.RuneCountInString(req.Comment) > maxFeedbackCommentLength {
+		openAIErrorResponse(c, http.StatusBadRequest, openAIErrorTypeInvalidRequest, "invalid_request", "comment must be at most 2000 characters")
+		return
+	}
+	userId := c.GetInt("id")
+	log, err := model.GetConsumeLogByRequestId(userId, req.RequestId)
+	if err != nil {
+		openAIErrorResponse(c, http.StatusInternalServerError, openAIErrorTypeServer, "internal_error", err.Error())
+		return
+	}
+	if log == nil {
+		openAIErrorResponse(c, http.StatusNotFound, openAIErrorTypeInvalidRequest, "not_found", "No request found with id '"+req.RequestId+"'.")
+		return
+	}
+	feedback := &model.RequestFeedback{
+		UserId:    userId,
+		RequestId: req.RequestId,
+		TokenId:   c.GetInt("token_id"),
+		ModelName: log.ModelName,
+		ChannelId: log.ChannelId,
+		Rating:    req.Rating,
+		Comment:   strings.TrimSpace(req.Comment),
+	}
+	if err := model.UpsertRequestFeedback(feedback); err != nil {
+		openAIErrorResponse(c, http.StatusInternalServerError, openAIErrorTypeServer, "internal_error", err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"id":         feedback.Id,
+		"object":     "feedback",
+		"request_id": feedback.RequestId,
+		"model":      feedback.ModelName,
+		"rating":     feedback.Rating,
+		"comment":    feedback.Comment,
+		"created_at": feedback.CreatedAt,
+		"updated_at": feedback.UpdatedAt,
+	})
+}
+
+// GetFeedbacks 管理员分页查询用户评价，可按模型、渠道、评分与时间筛选
+func GetFeedbacks(c *gin.Context) {
+	pageInfo := common.GetPageQuery(c)
+	channel, _ := strconv.Atoi(c.Query("channel"))
+	rating, _ := strconv.Atoi(c.Query("rating"))
+	startTimestamp, _ := strconv.ParseInt(c.Query("start_timestamp"), 10, 64)
+	endTimestamp, _ := strconv.ParseInt(c.Query("end_timestamp"), 10, 64)
+	feedbacks, total, err := model.GetRequestFeedbacks(c.Query("model_name"), channel, rating, startTimestamp, endTimestamp, pageInfo.GetStartIdx(), pageInfo.GetPageSize())
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	pageInfo.SetTotal(int(total))
+	pageInfo.SetItems(feedbacks)
+	common.ApiSuccess(c, pageInfo)
+}
+
+// GetFeedbackStats 按模型与渠道汇总用户评价，用于对比各上游的质量
+func GetFeedbackStats(c *gin.Context) {
+	channel, _ := strconv.Atoi(c.Query("channel"))
+	startTimestamp, _ := strconv.ParseInt(c.Query("start_timestamp"), 10, 64)
+	endTimestamp, _ := strconv.ParseInt(c.Query("end_timestamp"), 10, 64)
+	stats, err := model.GetRequestFeedbackStats(c.Query("model_name"), channel, startTimestamp, endTimestamp)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, stats)
+}
@@ -6,6 +6,7 @@ import (
 
 	"github.com/QuantumNous/new-api/common"
 	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/service"
 
 	"github.com/gin-gonic/gin"
 )
@@ -169,3 +170,22 @@ func DeleteHistoryLogs(c *gin.Context) {
 	})
 	return
 }
+
+// GetRequestCapture 按请求 ID 返回捕获的入站、各级转换、上游请求与上游响应载荷及相邻阶段的差异
+func GetRequestCapture(c *gin.Context) {
+	requestId := c.Param("request_id")
+	if requestId == "" {
+		common.ApiErrorMsg(c, "request id is required")
+		return
+	}
+	detail, err := service.GetRequestCaptureDetail(requestId)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	if detail == nil {
+		common.ApiErrorMsg(c, "no capture found for this request id")
+		return
+	}
+	common.ApiSuccess(c, detail)
+}
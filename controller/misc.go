@@ -11,6 +11,7 @@ import (
 	"github.com/QuantumNous/new-api/middleware"
 	"github.com/QuantumNous/new-api/model"
 	"github.com/QuantumNous/new-api/oauth"
+	"github.com/QuantumNous/new-api/service"
 	"github.com/QuantumNous/new-api/setting"
 	"github.com/QuantumNous/new-api/setting/console_setting"
 	"github.com/QuantumNous/new-api/setting/operation_setting"
@@ -119,6 +120,22 @@ func GetStatus(c *gin.Context) {
 		"_qn":                         "new-api",
 	}
 
+	// 品牌信息：全局白标配置叠加租户覆盖
+	tenant := model.GetContextTenant(c)
+	if branding, err := service.ResolveBranding(tenant, nil); err == nil {
+		data["system_name"] = branding.SystemName
+		data["logo"] = branding.Logo
+		data["footer_html"] = branding.Footer
+		data["docs_link"] = branding.DocsURL
+		branding.EmailTemplates = nil
+		data["branding"] = branding
+	} else {
+		common.SysError("failed to resolve branding: " + err.Error())
+	}
+	if tenant != nil {
+		data["tenant"] = tenant.Slug
+	}
+
 	// 根据启用状态注入可选内容
 	if cs.ApiInfoEnabled {
 		data["api_info"] = console_setting.GetApiInfo()
@@ -283,11 +300,12 @@ func SendEmailVerification(c *gin.Context) {
 	}
 	code := common.GenerateVerificationCode(6)
 	common.RegisterVerificationCodeWithKey(email, code, common.EmailVerificationPurpose)
-	subject := fmt.Sprintf("%s邮箱验证邮件", common.SystemName)
-	content := fmt.Sprintf("<p>您好，你正在进行%s邮箱验证。</p>"+
-		"<p>您的验证码为: <strong>%s</strong></p>"+
-		"<p>验证码 %d 分钟内有效，如果不是本人操作，请忽略。</p>", common.SystemName, code, common.VerificationValidMinutes)
-	err := common.SendEmail(subject, email, content)
+	branding, err := service.ResolveBranding(model.GetContextTenant(c), nil)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	err = service.SendTemplateEmail(c, branding, system_setting.EmailTemplateVerification, email, map[string]string{"code": code})
 	if err != nil {
 		common.ApiError(c, err)
 		return
@@ -318,12 +336,12 @@ func SendPasswordResetEmail(c *gin.Context) {
 	code := common.GenerateVerificationCode(0)
 	common.RegisterVerificationCodeWithKey(email, code, common.PasswordResetPurpose)
 	link := fmt.Sprintf("%s/user/reset?email=%s&token=%s", system_setting.ServerAddress, email, code)
-	subject := fmt.Sprintf("%s密码重置", common.SystemName)
-	content := fmt.Sprintf("<p>您好，你正在进行%s密码重置。</p>"+
-		"<p>点击 <a href='%s'>此处</a> 进行密码重置。</p>"+
-		"<p>如果链接无法点击，请尝试点击下面的链接或将其复制到浏览器中打开：<br> %s </p>"+
-		"<p>重置链接 %d 分钟内有效，如果不是本人操作，请忽略。</p>", common.SystemName, link, link, common.VerificationValidMinutes)
-	err := common.SendEmail(subject, email, content)
+	branding, err := service.ResolveBranding(model.GetContextTenant(c), nil)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	err = service.SendTemplateEmail(c, branding, system_setting.EmailTemplatePasswordReset, email, map[string]string{"link": link})
 	if err != nil {
 		common.ApiError(c, err)
 		return
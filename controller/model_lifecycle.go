@@ -0,0 +1,26 @@
+package controller
+
+import (
+	"strconv"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/model"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetModelDeprecationUsages 返回最近 days 天（默认 30 天）内仍在请求弃用模型的令牌，可按 model 筛选，
+// 用于在模型下线前通知相关用户迁移
+func GetModelDeprecationUsages(c *gin.Context) {
+	days, _ := strconv.Atoi(c.Query("days"))
+	if days <= 0 {
+		days = 30
+	}
+	since := common.GetTimestamp() - int64(days)*24*3600
+	usages, err := model.GetModelDeprecationUsages(c.Query("model"), since)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, usages)
+}
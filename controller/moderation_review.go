@@ -0,0 +1,61 @@
+package controller
+
+import (
+	"strconv"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/i18n"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetModerationReviews 分页返回审核队列中的记录，可按 status（pending / approved / flagged）筛选
+func GetModerationReviews(c *gin.Context) {
+	pageInfo := common.GetPageQuery(c)
+	reviews, total, err := model.GetModerationReviews(c.Query("status"), pageInfo.GetStartIdx(), pageInfo.GetPageSize())
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	pageInfo.SetTotal(int(total))
+	pageInfo.SetItems(reviews)
+	common.ApiSuccess(c, pageInfo)
+}
+
+func GetModerationReview(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		common.ApiErrorI18n(c, i18n.MsgInvalidParams)
+		return
+	}
+	review, err := model.GetModerationReviewById(id)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, review)
+}
+
+// ReviewModerationReview 审核一条记录：action 为 approve（通过）或 flag_user（标记用户），结论计入用户信任分
+func ReviewModerationReview(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		common.ApiErrorI18n(c, i18n.MsgInvalidParams)
+		return
+	}
+	var req struct {
+		Action string `json:"action"`
+	}
+	if err := common.DecodeJson(c.Request.Body, &req); err != nil {
+		common.ApiErrorI18n(c, i18n.MsgInvalidParams)
+		return
+	}
+	review, err := service.ReviewModeration(id, req.Action, c.GetInt("id"))
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, review)
+}
@@ -259,6 +259,7 @@ func findOrCreateOAuthUser(c *gin.Context, provider oauth.Provider, oauthUser *o
 	}
 	user.Role = common.RoleCommonUser
 	user.Status = common.UserStatusEnabled
+	user.AssignTenant(model.GetContextTenant(c))
 
 	// Handle affiliate code
 	affCode := session.Get("aff")
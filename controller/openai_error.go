@@ -0,0 +1,39 @@
+package controller
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/QuantumNous/new-api/types"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// 网关本地实现的 OpenAI 兼容接口（文件、向量库、Batch、临时令牌等）以 OpenAI 的错误格式响应
+const (
+	openAIErrorTypeInvalidRequest    = "invalid_request_error"
+	openAIErrorTypePermission        = "permission_error"
+	openAIErrorTypeInsufficientQuota = "insufficient_quota"
+	openAIErrorTypeServer            = "server_error"
+	openAIErrorTypeUpstream          = string(types.ErrorTypeUpstreamError)
+)
+
+func openAIErrorResponse(c *gin.Context, statusCode int, errType string, code string, message string) {
+	c.JSON(statusCode, gin.H{
+		"error": types.OpenAIError{
+			Message: message,
+			Type:    errType,
+			Code:    code,
+		},
+	})
+}
+
+// openAILookupError 将查询错误转换为 404 或 500 响应
+func openAILookupError(c *gin.Context, err error, what string) {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		openAIErrorResponse(c, http.StatusNotFound, openAIErrorTypeInvalidRequest, "not_found", what+" not found")
+		return
+	}
+	openAIErrorResponse(c, http.StatusInternalServerError, openAIErrorTypeServer, "internal_error", err.Error())
+}
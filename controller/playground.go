@@ -50,7 +50,9 @@ func Playground(c *gin.Context) {
 		Name:   fmt.Sprintf("playground-%s", relayInfo.UsingGroup),
 		Group:  relayInfo.UsingGroup,
 	}
-	_ = middleware.SetupContextForToken(c, tempToken)
+	if err := middleware.SetupContextForToken(c, tempToken); err != nil {
+		return
+	}
 
 	Relay(c, types.RelayFormatOpenAI)
 }
@@ -0,0 +1,99 @@
+package controller
+
+import (
+	"encoding/json"
+	"errors"
+	"strconv"
+	"strings"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/i18n"
+	"github.com/QuantumNous/new-api/model"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetPromptTemplates 分页返回每个提示词模板的最新版本
+func GetPromptTemplates(c *gin.Context) {
+	pageInfo := common.GetPageQuery(c)
+	templates, total, err := model.GetPromptTemplates(pageInfo.GetStartIdx(), pageInfo.GetPageSize())
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	pageInfo.SetTotal(int(total))
+	pageInfo.SetItems(templates)
+	common.ApiSuccess(c, pageInfo)
+}
+
+// GetPromptTemplateVersions 返回提示词模板的全部版本
+func GetPromptTemplateVersions(c *gin.Context) {
+	templates, err := model.GetPromptTemplateVersions(c.Param("prompt_id"))
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	if len(templates) == 0 {
+		common.ApiError(c, model.ErrPromptTemplateNotFound)
+		return
+	}
+	common.ApiSuccess(c, templates)
+}
+
+// CreatePromptTemplate 保存提示词模板的新版本，prompt_id 为空时创建新模板。
+// input 为 Responses input 数组，instructions 与 input 至少填写一项
+func CreatePromptTemplate(c *gin.Context) {
+	var req struct {
+		PromptId     string          `json:"prompt_id"`
+		Description  string          `json:"description"`
+		Instructions string          `json:"instructions"`
+		Input        json.RawMessage `json:"input"`
+	}
+	if err := common.DecodeJson(c.Request.Body, &req); err != nil {
+		common.ApiErrorI18n(c, i18n.MsgInvalidParams)
+		return
+	}
+	hasInput := len(req.Input) > 0 && string(req.Input) != "null"
+	if hasInput && common.GetJsonType(req.Input) != "array" {
+		common.ApiError(c, errors.New("input must be an array of Responses input items"))
+		return
+	}
+	if strings.TrimSpace(req.Instructions) == "" && !hasInput {
+		common.ApiError(c, errors.New("instructions or input is required"))
+		return
+	}
+	template := &model.PromptTemplate{
+		PromptId:     strings.TrimSpace(req.PromptId),
+		Description:  req.Description,
+		Instructions: req.Instructions,
+		CreatedBy:    c.GetInt("id"),
+	}
+	if template.PromptId == "" {
+		template.PromptId = "pmpt_" + common.GetUUID()
+	}
+	if hasInput {
+		template.Input = string(req.Input)
+	}
+	if err := model.CreatePromptTemplate(template); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, template)
+}
+
+// DeletePromptTemplate 删除提示词模板，指定 version 时只删除该版本
+func DeletePromptTemplate(c *gin.Context) {
+	version := 0
+	if v := c.Query("version"); v != "" {
+		var err error
+		if version, err = strconv.Atoi(v); err != nil || version <= 0 {
+			common.ApiErrorI18n(c, i18n.MsgInvalidParams)
+			return
+		}
+	}
+	if err := model.DeletePromptTemplate(c.Param("prompt_id"), version); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, nil)
+}
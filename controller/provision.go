@@ -0,0 +1,159 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/constant"
+	"github.com/QuantumNous/new-api/i18n"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/setting/ratio_setting"
+
+	"github.com/gin-gonic/gin"
+)
+
+// provisionRequest 开通账户的请求：用户信息及其第一个令牌的设置
+type provisionRequest struct {
+	Username    string `json:"username"`
+	Password    string `json:"password"` // 为空时生成随机密码并在响应中返回
+	DisplayName string `json:"display_name"`
+	Email       string `json:"email"`
+	Remark      string `json:"remark"`
+	Group       string `json:"group"`
+	Quota       *int   `json:"quota"` // 用户初始额度，为空时使用新用户默认额度
+	Token       struct {
+		Name           string   `json:"name"`
+		RemainQuota    int      `json:"remain_quota"`
+		UnlimitedQuota bool     `json:"unlimited_quota"`
+		ExpiredTime    int64    `json:"expired_time"` // 过期时间戳，0 或 -1 表示永不过期
+		Models         []string `json:"models"`       // 可用模型，为空时不限制
+		Group          string   `json:"group"`
+	} `json:"token"`
+}
+
+// ProvisionUser 一次调用创建用户及其令牌并返回令牌密钥，供 SaaS 平台在客户注册时为其开通网关密钥
+func ProvisionUser(c *gin.Context) {
+	var req provisionRequest
+	if err := common.DecodeJson(c.Request.Body, &req); err != nil {
+		common.ApiErrorI18n(c, i18n.MsgInvalidParams)
+		return
+	}
+	req.Username = strings.TrimSpace(req.Username)
+	if req.Username == "" {
+		common.ApiErrorI18n(c, i18n.MsgInvalidParams)
+		return
+	}
+	generatedPassword := ""
+	if req.Password == "" {
+		generatedPassword = common.GetRandomString(16)
+		req.Password = generatedPassword
+	}
+	if req.DisplayName == "" {
+		req.DisplayName = req.Username
+	}
+	if err := validateProvisionRequest(&req); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	if exist, err := model.CheckUserExistOrDeleted(req.Username, req.Email); err != nil {
+		common.ApiError(c, err)
+		return
+	} else if exist {
+		common.ApiErrorI18n(c, i18n.MsgUserExists)
+		return
+	}
+
+	user := &model.User{
+		Username:    req.Username,
+		Password:    req.Password,
+		DisplayName: req.DisplayName,
+		Email:       req.Email,
+		Remark:      req.Remark,
+		Group:       req.Group,
+		Role:        common.RoleCommonUser,
+	}
+	if err := common.Validate.Struct(user); err != nil {
+		common.ApiErrorI18n(c, i18n.MsgUserInputInvalid, map[string]any{"Error": err.Error()})
+		return
+	}
+	if tenant := model.GetCachedTenant(common.GetContextKeyInt(c, constant.ContextKeyUserTenantId)); tenant != nil {
+		user.AssignTenant(tenant)
+	} else {
+		user.AssignTenant(model.GetContextTenant(c))
+	}
+
+	key, err := common.GenerateKey()
+	if err != nil {
+		common.ApiErrorI18n(c, i18n.MsgTokenGenerateFailed)
+		common.SysLog("failed to generate token key: " + err.Error())
+		return
+	}
+	now := common.GetTimestamp()
+	token := &model.Token{
+		Name:               req.Token.Name,
+		Key:                key,
+		CreatedTime:        now,
+		AccessedTime:       now,
+		ExpiredTime:        req.Token.ExpiredTime,
+		RemainQuota:        req.Token.RemainQuota,
+		UnlimitedQuota:     req.Token.UnlimitedQuota,
+		ModelLimitsEnabled: len(req.Token.Models) > 0,
+		ModelLimits:        strings.Join(req.Token.Models, ","),
+		Group:              req.Token.Group,
+	}
+	if err := model.ProvisionUserWithToken(user, req.Quota, token); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, gin.H{
+		"user_id":  user.Id,
+		"username": user.Username,
+		"password": generatedPassword,
+		"group":    user.Group,
+		"quota":    user.Quota,
+		"token_id": token.Id,
+		"key":      "sk-" + token.Key,
+	})
+}
+
+// validateProvisionRequest 校验并补全开通请求的分组、额度与令牌设置
+func validateProvisionRequest(req *provisionRequest) error {
+	if req.Group == "" {
+		req.Group = "default"
+	}
+	if !ratio_setting.ContainsGroupRatio(req.Group) {
+		return fmt.Errorf("group %s does not exist", req.Group)
+	}
+	if req.Token.Group != "" && req.Token.Group != "auto" && !ratio_setting.ContainsGroupRatio(req.Token.Group) {
+		return fmt.Errorf("token group %s does not exist", req.Token.Group)
+	}
+	maxQuotaValue := int(1000000000 * common.QuotaPerUnit)
+	if req.Quota != nil && (*req.Quota < 0 || *req.Quota > maxQuotaValue) {
+		return fmt.Errorf("quota must be between 0 and %d", maxQuotaValue)
+	}
+	if !req.Token.UnlimitedQuota && (req.Token.RemainQuota < 0 || req.Token.RemainQuota > maxQuotaValue) {
+		return fmt.Errorf("token remain_quota must be between 0 and %d", maxQuotaValue)
+	}
+	if req.Token.Name == "" {
+		req.Token.Name = "default"
+	}
+	if len(req.Token.Name) > 50 {
+		return errors.New("token name is too long")
+	}
+	if req.Token.ExpiredTime == 0 {
+		req.Token.ExpiredTime = -1
+	}
+	if req.Token.ExpiredTime != -1 && req.Token.ExpiredTime <= common.GetTimestamp() {
+		return errors.New("token expired_time must be in the future")
+	}
+	models := make([]string, 0, len(req.Token.Models))
+	for _, m := range req.Token.Models {
+		if m = strings.TrimSpace(m); m != "" {
+			models = append(models, m)
+		}
+	}
+	req.Token.Models = models
+	return nil
+}
@@ -0,0 +1,49 @@
+package controller
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/i18n"
+	"github.com/QuantumNous/new-api/service"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+
+	"github.com/gin-gonic/gin"
+)
+
+var (
+	publicStatsCache     *service.PublicStats
+	publicStatsCacheLock sync.Mutex
+)
+
+// GetPublicStats 公开的用量统计：各模型的请求量与延迟分布，已做 k 匿名与差分隐私加噪。
+// 每个统计窗口只发布一次结果并保存在数据库中，所有节点返回同一份数据，反复请求不会得到新的噪声样本
+func GetPublicStats(c *gin.Context) {
+	setting := operation_setting.GetPublicStatsSetting()
+	if !setting.Enabled {
+		c.JSON(http.StatusNotFound, gin.H{
+			"success": false,
+			"message": i18n.T(c, i18n.MsgFeatureDisabled),
+		})
+		return
+	}
+
+	windowDays := setting.WindowDays
+	if windowDays <= 0 {
+		windowDays = 30
+	}
+	windowStart, _ := service.PublicStatsWindow(windowDays, time.Now())
+	publicStatsCacheLock.Lock()
+	defer publicStatsCacheLock.Unlock()
+	if publicStatsCache == nil || publicStatsCache.WindowStart != windowStart || publicStatsCache.WindowDays != windowDays {
+		stats, err := service.GetPublicStats(setting)
+		if err != nil {
+			common.ApiError(c, err)
+			return
+		}
+		publicStatsCache = stats
+	}
+	common.ApiSuccess(c, publicStatsCache)
+}
@@ -0,0 +1,83 @@
+package controller
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/logger"
+	"github.com/QuantumNous/new-api/model"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetSelfQuotaPools 返回当前用户的额度构成（赠送、预付、后付费授信）与生效中的额度池
+func GetSelfQuotaPools(c *gin.Context) {
+	summary, err := model.GetUserQuotaPoolSummary(c.GetInt("id"))
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, summary)
+}
+
+// getManagedUser 解析路径中的用户 ID，并检查当前管理员是否有权管理该用户
+func getManagedUser(c *gin.Context) (*model.User, bool) {
+	userId, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		common.ApiErrorMsg(c, "invalid user id")
+		return nil, false
+	}
+	user, err := model.GetUserById(userId, false)
+	if err != nil {
+		common.ApiError(c, err)
+		return nil, false
+	}
+	myRole := c.GetInt("role")
+	if myRole <= user.Role && myRole != common.RoleRootUser {
+		common.ApiErrorMsg(c, "no permission")
+		return nil, false
+	}
+	return user, true
+}
+
+// GetUserQuotaPools 管理员查看用户的额度构成
+func GetUserQuotaPools(c *gin.Context) {
+	user, ok := getManagedUser(c)
+	if !ok {
+		return
+	}
+	summary, err := model.GetUserQuotaPoolSummary(user.Id)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, summary)
+}
+
+type grantQuotaPoolRequest struct {
+	Kind      string `json:"kind"`
+	Amount    int    `json:"amount"`
+	ExpiresAt int64  `json:"expires_at"` // 0 表示永不过期
+	Remark    string `json:"remark"`
+}
+
+// GrantUserQuotaPool 管理员为用户发放赠送额度、预付额度或后付费授信
+func GrantUserQuotaPool(c *gin.Context) {
+	user, ok := getManagedUser(c)
+	if !ok {
+		return
+	}
+	var req grantQuotaPoolRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		common.ApiErrorMsg(c, "无效的参数")
+		return
+	}
+	pool, err := model.GrantQuotaPool(user.Id, req.Kind, req.Amount, req.ExpiresAt, req.Remark)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	model.RecordLog(user.Id, model.LogTypeManage, fmt.Sprintf("管理员发放额度池 #%d（%s）%s", pool.Id, pool.Kind, logger.LogQuota(pool.Total)))
+	common.ApiSuccess(c, pool)
+}
@@ -0,0 +1,50 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/constant"
+	"github.com/QuantumNous/new-api/logger"
+	"github.com/QuantumNous/new-api/relay"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	"github.com/QuantumNous/new-api/relay/helper"
+	"github.com/QuantumNous/new-api/service"
+	"github.com/QuantumNous/new-api/types"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RelayRealtimeTranscription 实时语音转写 WebSocket 入口：客户端发送 pcm16 二进制帧，服务端返回中间与最终转写结果
+func RelayRealtimeTranscription(c *gin.Context) {
+	ws, err := upgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		return
+	}
+	defer ws.Close()
+
+	var newAPIError *types.NewAPIError
+	defer func() {
+		if newAPIError != nil {
+			logger.LogError(c, fmt.Sprintf("realtime transcription error: %s", newAPIError.Error()))
+			newAPIError.SetMessage(common.MessageWithRequestId(newAPIError.Error(), c.GetString(common.RequestIdKey)))
+			helper.WssError(c, ws, newAPIError.ToOpenAIError())
+		}
+	}()
+
+	userId := c.GetInt("id")
+	if !service.AcquireTranscriptionSession(userId) {
+		newAPIError = types.NewErrorWithStatusCode(errors.New("too many concurrent realtime transcription sessions"), types.ErrorCodeRateLimitExceeded, http.StatusTooManyRequests, types.ErrOptionWithSkipRetry())
+		return
+	}
+	defer service.ReleaseTranscriptionSession(userId)
+
+	relayInfo := relaycommon.GenRelayInfoWs(c, ws)
+	newAPIError = relay.RealtimeTranscriptionHelper(c, relayInfo)
+	if newAPIError != nil && !types.IsSkipRetryError(newAPIError) {
+		channelId := common.GetContextKeyInt(c, constant.ContextKeyChannelId)
+		processChannelError(c, *types.NewChannelError(channelId, relayInfo.ChannelType, common.GetContextKeyString(c, constant.ContextKeyChannelName), relayInfo.ChannelIsMultiKey, common.GetContextKeyString(c, constant.ContextKeyChannelKey), common.GetContextKeyBool(c, constant.ContextKeyChannelAutoBan)), newAPIError)
+	}
+}
@@ -12,6 +12,7 @@ import (
 	"github.com/QuantumNous/new-api/common"
 	"github.com/QuantumNous/new-api/constant"
 	"github.com/QuantumNous/new-api/dto"
+	"github.com/QuantumNous/new-api/i18n"
 	"github.com/QuantumNous/new-api/logger"
 	"github.com/QuantumNous/new-api/middleware"
 	"github.com/QuantumNous/new-api/model"
@@ -67,12 +68,14 @@ func geminiRelayHandler(c *gin.Context, info *relaycommon.RelayInfo) *types.NewA
 func Relay(c *gin.Context, relayFormat types.RelayFormat) {
 
 	requestId := c.GetString(common.RequestIdKey)
+	service.SetProvenanceHeader(c)
 	//group := common.GetContextKeyString(c, constant.ContextKeyUsingGroup)
 	//originalModel := common.GetContextKeyString(c, constant.ContextKeyOriginalModel)
 
 	var (
 		newAPIError *types.NewAPIError
 		ws          *websocket.Conn
+		finishDedup func()
 	)
 
 	if relayFormat == types.RelayFormatOpenAIRealtime {
@@ -85,6 +88,13 @@ func Relay(c *gin.Context, relayFormat types.RelayFormat) {
 		defer ws.Close()
 	}
 
+	// 在错误响应写出之后再结束去重记录
+	defer func() {
+		if finishDedup != nil {
+			finishDedup()
+		}
+	}()
+
 	defer func() {
 		if newAPIError != nil {
 			logger.LogError(c, fmt.Sprintf("relay error: %s", newAPIError.Error()))
@@ -122,11 +132,41 @@ func Relay(c *gin.Context, relayFormat types.RelayFormat) {
 		return
 	}
 
+	var served bool
+	if finishDedup, served = service.BeginRequestDedup(c, relayInfo); served {
+		return
+	}
+
+	if finishIntegrity := service.BeginStreamIntegrity(c, relayInfo); finishIntegrity != nil {
+		defer func() {
+			finishIntegrity(newAPIError == nil)
+		}()
+	}
+
+	if relayFormat != types.RelayFormatOpenAIRealtime && service.StartRequestCapture(c, relayInfo) {
+		defer func() {
+			service.SaveRequestCapture(c, relayInfo, newAPIError)
+		}()
+	}
+
+	newAPIError = service.ApplyGroupSystemPromptPolicy(c, relayInfo, request)
+	if newAPIError != nil {
+		return
+	}
+
+	newAPIError = service.ApplyResponsesPromptTemplate(c, request)
+	if newAPIError != nil {
+		return
+	}
+
+	service.ApplyConversationSummary(c, relayInfo, request)
+
 	needSensitiveCheck := setting.ShouldCheckPromptSensitive()
+	needReviewCheck := service.ModerationReviewEnabled()
 	needCountToken := constant.CountToken
-	// Avoid building huge CombineText (strings.Join) when token counting and sensitive check are both disabled.
+	// Avoid building huge CombineText (strings.Join) when token counting and sensitive checks are all disabled.
 	var meta *types.TokenCountMeta
-	if needSensitiveCheck || needCountToken {
+	if needSensitiveCheck || needReviewCheck || needCountToken {
 		meta = request.GetTokenCountMeta()
 	} else {
 		meta = fastTokenCountMetaForPricing(request)
@@ -140,6 +180,15 @@ func Relay(c *gin.Context, relayFormat types.RelayFormat) {
 			return
 		}
 	}
+	if needReviewCheck && meta != nil {
+		newAPIError = service.CheckModerationReview(c, relayInfo, meta.CombineText)
+		if newAPIError != nil {
+			return
+		}
+		if len(relayInfo.ModerationFlagWords) > 0 {
+			defer service.SaveModerationReview(c, relayInfo)
+		}
+	}
 
 	tokens, err := service.EstimateRequestToken(c, meta, relayInfo)
 	if err != nil {
@@ -147,6 +196,11 @@ func Relay(c *gin.Context, relayFormat types.RelayFormat) {
 		return
 	}
 
+	tokens, newAPIError = service.EnforceContextWindow(c, relayInfo, request, tokens)
+	if newAPIError != nil {
+		return
+	}
+
 	relayInfo.SetEstimatePromptTokens(tokens)
 
 	priceData, err := helper.ModelPriceHelper(c, relayInfo, tokens, meta)
@@ -177,6 +231,11 @@ func Relay(c *gin.Context, relayFormat types.RelayFormat) {
 		}
 	}()
 
+	if service.ShouldRouteToBatch(c, relayInfo) {
+		newAPIError = relayBatchSubmit(c, relayInfo)
+		return
+	}
+
 	retryParam := &service.RetryParam{
 		Ctx:        c,
 		TokenGroup: relayInfo.TokenGroup,
@@ -208,16 +267,7 @@ func Relay(c *gin.Context, relayFormat types.RelayFormat) {
 		}
 		c.Request.Body = io.NopCloser(bodyStorage)
 
-		switch relayFormat {
-		case types.RelayFormatOpenAIRealtime:
-			newAPIError = relay.WssHelper(c, relayInfo)
-		case types.RelayFormatClaude:
-			newAPIError = relay.ClaudeHelper(c, relayInfo)
-		case types.RelayFormatGemini:
-			newAPIError = geminiRelayHandler(c, relayInfo)
-		default:
-			newAPIError = relayHandler(c, relayInfo)
-		}
+		newAPIError = relayChannelRequest(c, relayFormat, relayInfo, channel.Id)
 
 		if newAPIError == nil {
 			relayInfo.LastError = nil
@@ -241,6 +291,39 @@ func Relay(c *gin.Context, relayFormat types.RelayFormat) {
 	}
 }
 
+// relayChannelRequest 按请求格式转发到已选渠道，处理期间计入渠道的在途请求，处理器 panic 时也会结束计数
+func relayChannelRequest(c *gin.Context, relayFormat types.RelayFormat, relayInfo *relaycommon.RelayInfo, channelId int) (newAPIError *types.NewAPIError) {
+	finishChannelRequest := model.BeginChannelRequest(channelId)
+	defer func() {
+		finishChannelRequest(isChannelFailure(newAPIError))
+	}()
+	switch relayFormat {
+	case types.RelayFormatOpenAIRealtime:
+		return relay.WssHelper(c, relayInfo)
+	case types.RelayFormatClaude:
+		return relay.ClaudeHelper(c, relayInfo)
+	case types.RelayFormatGemini:
+		return geminiRelayHandler(c, relayInfo)
+	default:
+		return relayHandler(c, relayInfo)
+	}
+}
+
+// relayBatchSubmit 将请求排入上游 Batch 队列并立即返回 202，结果通过 /v1/batch_requests/:id 轮询获取
+func relayBatchSubmit(c *gin.Context, relayInfo *relaycommon.RelayInfo) *types.NewAPIError {
+	addUsedChannel(c, c.GetInt("channel_id"))
+	body, newAPIError := relay.BuildBatchRequestBody(c, relayInfo)
+	if newAPIError != nil {
+		return newAPIError
+	}
+	task, newAPIError := service.SubmitBatchRequest(c, relayInfo, body)
+	if newAPIError != nil {
+		return newAPIError
+	}
+	c.JSON(http.StatusAccepted, task.ToBatchRequest())
+	return nil
+}
+
 var upgrader = websocket.Upgrader{
 	Subprotocols: []string{"realtime"}, // WS 握手支持的协议，如果有使用 Sec-WebSocket-Protocol，则必须在此声明对应的 Protocol TODO add other protocol
 	CheckOrigin: func(r *http.Request) bool {
@@ -302,10 +385,10 @@ func getChannel(c *gin.Context, info *relaycommon.RelayInfo, retryParam *service
 	info.PriceData.GroupRatioInfo = helper.HandleGroupRatio(c, info)
 
 	if err != nil {
-		return nil, types.NewError(fmt.Errorf("获取分组 %s 下模型 %s 的可用渠道失败（retry）: %s", selectGroup, info.OriginModelName, err.Error()), types.ErrorCodeGetChannelFailed, types.ErrOptionWithSkipRetry())
+		return nil, types.NewError(errors.New(i18n.T(c, i18n.MsgRelayGetChannelFailed, map[string]any{"Group": selectGroup, "Model": info.OriginModelName, "Error": err.Error()})), types.ErrorCodeGetChannelFailed, types.ErrOptionWithSkipRetry())
 	}
 	if channel == nil {
-		return nil, types.NewError(fmt.Errorf("分组 %s 下模型 %s 的可用渠道不存在（retry）", selectGroup, info.OriginModelName), types.ErrorCodeGetChannelFailed, types.ErrOptionWithSkipRetry())
+		return nil, types.NewError(errors.New(i18n.T(c, i18n.MsgRelayNoAvailableChannel, map[string]any{"Group": selectGroup, "Model": info.OriginModelName})), types.ErrorCodeGetChannelFailed, types.ErrOptionWithSkipRetry())
 	}
 
 	newAPIError := middleware.SetupContextForSelectedChannel(c, channel, info.OriginModelName)
@@ -347,6 +430,17 @@ func shouldRetry(c *gin.Context, openaiErr *types.NewAPIError, retryTimes int) b
 	return operation_setting.ShouldRetryByStatusCode(code)
 }
 
+// isChannelFailure 判断错误是否由渠道导致（渠道错误、上游 5xx 或 429），计入渠道的近期错误率
+func isChannelFailure(err *types.NewAPIError) bool {
+	if err == nil {
+		return false
+	}
+	if types.IsChannelError(err) {
+		return true
+	}
+	return err.StatusCode >= http.StatusInternalServerError || err.StatusCode == http.StatusTooManyRequests
+}
+
 func processChannelError(c *gin.Context, channelError types.ChannelError, err *types.NewAPIError) {
 	logger.LogError(c, fmt.Sprintf("channel error (channel #%d, status code: %d): %s", channelError.ChannelId, err.StatusCode, err.Error()))
 	// 不要使用context获取渠道信息，异步处理时可能会出现渠道信息不一致的情况
@@ -0,0 +1,296 @@
+package controller
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/constant"
+	"github.com/QuantumNous/new-api/logger"
+	"github.com/QuantumNous/new-api/middleware"
+	"github.com/QuantumNous/new-api/relay/helper"
+	"github.com/QuantumNous/new-api/types"
+
+	"github.com/gin-gonic/gin"
+)
+
+// fanoutResult is the per-target entry of a non-stream fanout response.
+type fanoutResult struct {
+	Index      int             `json:"index"`
+	Model      string          `json:"model"`
+	StatusCode int             `json:"status_code"`
+	Response   json.RawMessage `json:"response,omitempty"`
+	Error      json.RawMessage `json:"error,omitempty"`
+}
+
+// RelayFanout sends the same chat completion request to every model listed in the
+// fanout query parameter in parallel. Each target goes through the normal channel
+// selection and relay pipeline, so it is billed and logged as an individual request.
+//
+// Non-stream requests return all results at once; stream requests multiplex the
+// upstream chunks into one SSE stream, each event labelled with fanout_index and model.
+func RelayFanout(c *gin.Context) {
+	models, err := parseFanoutModels(c.Query("fanout"))
+	if err != nil {
+		fanoutError(c, err)
+		return
+	}
+
+	storage, err := common.GetBodyStorage(c)
+	if err != nil {
+		fanoutError(c, err)
+		return
+	}
+	body, err := storage.Bytes()
+	if err != nil {
+		fanoutError(c, err)
+		return
+	}
+	var request map[string]json.RawMessage
+	if err = common.Unmarshal(body, &request); err != nil {
+		fanoutError(c, fmt.Errorf("invalid request body: %w", err))
+		return
+	}
+	var stream bool
+	if raw, ok := request["stream"]; ok {
+		_ = common.Unmarshal(raw, &stream)
+	}
+
+	var parentLock sync.Mutex
+	writers := make([]*fanoutWriter, len(models))
+	subs := make([]*gin.Context, len(models))
+	for i, modelName := range models {
+		request["model"], _ = common.Marshal(modelName)
+		subBody, err := common.Marshal(request)
+		if err != nil {
+			fanoutError(c, err)
+			return
+		}
+		writers[i] = newFanoutWriter(c, &parentLock, i, modelName, stream)
+		subs[i] = newFanoutContext(c, writers[i], i, subBody)
+	}
+
+	if stream {
+		helper.SetEventStreamHeaders(c)
+		c.Status(http.StatusOK)
+	}
+
+	var wg sync.WaitGroup
+	for i := range subs {
+		wg.Add(1)
+		go func(sub *gin.Context, w *fanoutWriter) {
+			defer wg.Done()
+			defer func() {
+				if r := recover(); r != nil {
+					logger.LogError(c, fmt.Sprintf("fanout target %s panic: %v", w.model, r))
+					w.WriteHeader(http.StatusInternalServerError)
+				}
+				if w.status == 0 {
+					w.WriteHeader(http.StatusBadGateway)
+				}
+				common.CleanupBodyStorage(sub)
+				w.finish()
+			}()
+			middleware.Distribute()(sub)
+			if !sub.IsAborted() {
+				Relay(sub, types.RelayFormatOpenAI)
+			}
+		}(subs[i], writers[i])
+	}
+	wg.Wait()
+
+	if stream {
+		c.Render(-1, common.CustomEvent{Data: "data: [DONE]"})
+		_ = helper.FlushWriter(c)
+		return
+	}
+	results := make([]fanoutResult, len(writers))
+	for i, w := range writers {
+		results[i] = w.result()
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"object":  "chat.completion.fanout",
+		"results": results,
+	})
+}
+
+func parseFanoutModels(value string) ([]string, error) {
+	models := make([]string, 0)
+	seen := make(map[string]bool)
+	for _, name := range strings.Split(value, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" || seen[name] {
+			continue
+		}
+		seen[name] = true
+		models = append(models, name)
+	}
+	if len(models) == 0 {
+		return nil, errors.New("fanout requires at least one model")
+	}
+	if constant.FanoutMaxTargets > 0 && len(models) > constant.FanoutMaxTargets {
+		return nil, fmt.Errorf("fanout supports at most %d models", constant.FanoutMaxTargets)
+	}
+	return models, nil
+}
+
+func fanoutError(c *gin.Context, err error) {
+	newAPIError := types.NewErrorWithStatusCode(err, types.ErrorCodeInvalidRequest, http.StatusBadRequest)
+	newAPIError.SetMessage(common.MessageWithRequestId(newAPIError.Error(), c.GetString(common.RequestIdKey)))
+	c.JSON(newAPIError.StatusCode, gin.H{
+		"error": newAPIError.ToOpenAIError(),
+	})
+}
+
+// newFanoutContext builds an independent gin context for one target: the request is
+// cloned without the fanout parameter and with the rewritten body, and the auth
+// context (user, token, group...) is copied from the parent.
+func newFanoutContext(c *gin.Context, w *fanoutWriter, index int, body []byte) *gin.Context {
+	sub, _ := gin.CreateTestContext(w)
+	req := c.Request.Clone(c.Request.Context())
+	query := req.URL.Query()
+	query.Del("fanout")
+	req.URL.RawQuery = query.Encode()
+	req.Body = io.NopCloser(bytes.NewReader(body))
+	req.ContentLength = int64(len(body))
+	sub.Request = req
+	for key, value := range c.Keys {
+		switch key {
+		case common.KeyBodyStorage, common.KeyRequestBody, "event_stream_headers_set", "use_channel":
+			continue
+		}
+		sub.Set(key, value)
+	}
+	sub.Set(common.RequestIdKey, fmt.Sprintf("%s-%d", c.GetString(common.RequestIdKey), index))
+	return sub
+}
+
+// fanoutWriter captures the response of one fanout target. In stream mode every
+// complete "data:" line is re-emitted on the parent writer wrapped in an envelope.
+type fanoutWriter struct {
+	parent *gin.Context
+	lock   *sync.Mutex // serializes writes to the parent
+	index  int
+	model  string
+	stream bool
+
+	header  http.Header
+	status  int
+	body    bytes.Buffer
+	pending []byte
+}
+
+func newFanoutWriter(parent *gin.Context, lock *sync.Mutex, index int, model string, stream bool) *fanoutWriter {
+	return &fanoutWriter{
+		parent: parent,
+		lock:   lock,
+		index:  index,
+		model:  model,
+		stream: stream,
+		header: make(http.Header),
+	}
+}
+
+func (w *fanoutWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *fanoutWriter) WriteHeader(statusCode int) {
+	if w.status == 0 {
+		w.status = statusCode
+	}
+}
+
+func (w *fanoutWriter) Write(data []byte) (int, error) {
+	w.WriteHeader(http.StatusOK)
+	if !w.stream || w.status >= http.StatusBadRequest {
+		return w.body.Write(data)
+	}
+	w.pending = append(w.pending, data...)
+	for {
+		i := bytes.IndexByte(w.pending, '\n')
+		if i < 0 {
+			break
+		}
+		line := bytes.TrimRight(w.pending[:i], "\r")
+		w.pending = w.pending[i+1:]
+		payload, ok := bytes.CutPrefix(line, []byte("data:"))
+		if !ok {
+			continue
+		}
+		payload = bytes.TrimSpace(payload)
+		if len(payload) == 0 || string(payload) == "[DONE]" {
+			continue
+		}
+		w.emit("chunk", payload)
+	}
+	return len(data), nil
+}
+
+func (w *fanoutWriter) Flush() {
+	// stream chunks are flushed on the parent writer by emit
+}
+
+func (w *fanoutWriter) emit(field string, payload json.RawMessage) {
+	event := map[string]any{
+		"fanout_index": w.index,
+		"model":        w.model,
+	}
+	if payload != nil {
+		event[field] = payload
+	} else {
+		event[field] = true
+	}
+	data, err := common.Marshal(event)
+	if err != nil {
+		return
+	}
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	w.parent.Render(-1, common.CustomEvent{Data: "data: " + string(data)})
+	_ = helper.FlushWriter(w.parent)
+}
+
+// finish emits the error (if any) and the done marker of a stream target.
+func (w *fanoutWriter) finish() {
+	if !w.stream {
+		return
+	}
+	if w.status >= http.StatusBadRequest {
+		w.emit("error", w.errorBody())
+	}
+	w.emit("done", nil)
+}
+
+func (w *fanoutWriter) errorBody() json.RawMessage {
+	var errResp struct {
+		Error json.RawMessage `json:"error"`
+	}
+	if err := common.Unmarshal(w.body.Bytes(), &errResp); err == nil && len(errResp.Error) > 0 {
+		return errResp.Error
+	}
+	raw, _ := common.Marshal(w.body.String())
+	return raw
+}
+
+func (w *fanoutWriter) result() fanoutResult {
+	result := fanoutResult{
+		Index:      w.index,
+		Model:      w.model,
+		StatusCode: w.status,
+	}
+	if w.status >= http.StatusBadRequest {
+		result.Error = w.errorBody()
+	} else if common.ValidJson(w.body.Bytes()) {
+		result.Response = w.body.Bytes()
+	} else {
+		result.Response, _ = common.Marshal(w.body.String())
+	}
+	return result
+}
@@ -0,0 +1,57 @@
+package controller
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/QuantumNous/new-api/constant"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseFanoutModels(t *testing.T) {
+	origin := constant.FanoutMaxTargets
+	constant.FanoutMaxTargets = 2
+	t.Cleanup(func() { constant.FanoutMaxTargets = origin })
+
+	models, err := parseFanoutModels(" a, b ,a,")
+	require.NoError(t, err)
+	require.Equal(t, []string{"a", "b"}, models)
+
+	_, err = parseFanoutModels(",")
+	require.Error(t, err)
+	_, err = parseFanoutModels("a,b,c")
+	require.Error(t, err)
+}
+
+func TestFanoutWriterStreamEnvelope(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	recorder := httptest.NewRecorder()
+	parent, _ := gin.CreateTestContext(recorder)
+	parent.Request = httptest.NewRequest(http.MethodPost, "/v1/chat/completions?fanout=m", nil)
+
+	var lock sync.Mutex
+	w := newFanoutWriter(parent, &lock, 1, "m", true)
+	_, _ = w.Write([]byte("data: {\"id\":\"x\"}\n\ndata: [DO"))
+	_, _ = w.Write([]byte("NE]\n\n"))
+	w.finish()
+
+	out := recorder.Body.String()
+	require.Contains(t, out, `data: {"chunk":{"id":"x"},"fanout_index":1,"model":"m"}`)
+	require.Contains(t, out, `data: {"done":true,"fanout_index":1,"model":"m"}`)
+	require.Equal(t, 2, strings.Count(out, "data: "))
+}
+
+func TestFanoutWriterErrorResult(t *testing.T) {
+	w := newFanoutWriter(nil, nil, 0, "m", false)
+	w.WriteHeader(http.StatusServiceUnavailable)
+	_, _ = w.Write([]byte(`{"error":{"message":"no channel"}}`))
+
+	result := w.result()
+	require.Equal(t, http.StatusServiceUnavailable, result.StatusCode)
+	require.JSONEq(t, `{"message":"no channel"}`, string(result.Error))
+	require.Nil(t, result.Response)
+}
@@ -0,0 +1,186 @@
+package controller
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/constant"
+	"github.com/QuantumNous/new-api/i18n"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/service"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// resolveReportTarget fills the default target and checks the caller may read the report.
+// Users read their own report; admins read users and the tenant they manage; root reads
+// any tenant, with tenant 0 meaning the whole platform.
+func resolveReportTarget(c *gin.Context, scope string, targetId int) (int, bool) {
+	role := c.GetInt("role")
+	adminTenantId := common.GetContextKeyInt(c, constant.ContextKeyUserTenantId)
+	switch scope {
+	case model.ReportScopeUser:
+		if targetId == 0 || targetId == c.GetInt("id") {
+			return c.GetInt("id"), true
+		}
+		if role < common.RoleAdminUser {
+			break
+		}
+		user, err := model.GetUserById(targetId, false)
+		if err != nil {
+			common.ApiErrorI18n(c, i18n.MsgUserNotExists)
+			return 0, false
+		}
+		if adminTenantId == 0 || user.TenantId == adminTenantId {
+			return targetId, true
+		}
+	case model.ReportScopeTenant:
+		if role < common.RoleAdminUser {
+			break
+		}
+		if adminTenantId != 0 {
+			targetId = adminTenantId
+		}
+		if targetId == 0 && role != common.RoleRootUser {
+			break
+		}
+		return targetId, true
+	default:
+		common.ApiErrorI18n(c, i18n.MsgInvalidParams)
+		return 0, false
+	}
+	common.ApiErrorI18n(c, i18n.MsgReportNoPermission)
+	return 0, false
+}
+
+// GetUsageReport returns the usage report of the last complete week or month, or of the
+// given time range.
+func GetUsageReport(c *gin.Context) {
+	scope := c.DefaultQuery("scope", model.ReportScopeUser)
+	targetId, _ := strconv.Atoi(c.Query("target_id"))
+	targetId, ok := resolveReportTarget(c, scope, targetId)
+	if !ok {
+		return
+	}
+	frequency := c.DefaultQuery("frequency", model.ReportFrequencyMonthly)
+	period, start, end := service.UsageReportPeriod(frequency, time.Now())
+	startTimestamp, _ := strconv.ParseInt(c.Query("start_timestamp"), 10, 64)
+	endTimestamp, _ := strconv.ParseInt(c.Query("end_timestamp"), 10, 64)
+	if startTimestamp > 0 && endTimestamp > startTimestamp {
+		period, start, end = "custom", startTimestamp, endTimestamp
+	}
+	report, err := service.BuildUsageReport(scope, targetId, period, start, end)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, report)
+}
+
+// GetReportSubscriptions returns the report subscriptions created by the current user.
+func GetReportSubscriptions(c *gin.Context) {
+	subs, err := model.GetReportSubscriptionsByUserId(c.GetInt("id"))
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, subs)
+}
+
+func CreateReportSubscription(c *gin.Context) {
+	var sub model.ReportSubscription
+	if err := common.DecodeJson(c.Request.Body, &sub); err != nil {
+		common.ApiErrorI18n(c, i18n.MsgInvalidParams)
+		return
+	}
+	targetId, ok := resolveReportTarget(c, sub.Scope, sub.TargetId)
+	if !ok {
+		return
+	}
+	sub.Id = 0
+	sub.UserId = c.GetInt("id")
+	sub.TargetId = targetId
+	if err := model.CreateReportSubscription(&sub); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, sub)
+}
+
+func UpdateReportSubscription(c *gin.Context) {
+	var sub model.ReportSubscription
+	if err := common.DecodeJson(c.Request.Body, &sub); err != nil || sub.Id == 0 {
+		common.ApiErrorI18n(c, i18n.MsgInvalidParams)
+		return
+	}
+	if _, err := model.GetReportSubscriptionById(sub.Id, c.GetInt("id")); err != nil {
+		common.ApiErrorI18n(c, i18n.MsgReportSubscriptionNotFound)
+		return
+	}
+	targetId, ok := resolveReportTarget(c, sub.Scope, sub.TargetId)
+	if !ok {
+		return
+	}
+	sub.TargetId = targetId
+	if err := model.UpdateReportSubscription(&sub); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, sub)
+}
+
+func DeleteReportSubscription(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		common.ApiErrorI18n(c, i18n.MsgInvalidParams)
+		return
+	}
+	if err = model.DeleteReportSubscription(id, c.GetInt("id")); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			common.ApiErrorI18n(c, i18n.MsgReportSubscriptionNotFound)
+			return
+		}
+		common.ApiError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+	})
+}
+
+// SendReportSubscription delivers the report of the last complete period right away,
+// without affecting the schedule, so recipients can be verified.
+func SendReportSubscription(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		common.ApiErrorI18n(c, i18n.MsgInvalidParams)
+		return
+	}
+	sub, err := model.GetReportSubscriptionById(id, c.GetInt("id"))
+	if err != nil {
+		common.ApiErrorI18n(c, i18n.MsgReportSubscriptionNotFound)
+		return
+	}
+	if _, ok := resolveReportTarget(c, sub.Scope, sub.TargetId); !ok {
+		return
+	}
+	period, start, end := service.UsageReportPeriod(sub.Frequency, time.Now())
+	report, err := service.BuildUsageReport(sub.Scope, sub.TargetId, period, start, end)
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	if err = service.DeliverUsageReport(c, sub, report); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+	})
+}
@@ -0,0 +1,251 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/constant"
+	"github.com/QuantumNous/new-api/i18n"
+	"github.com/QuantumNous/new-api/middleware"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/relay"
+	"github.com/QuantumNous/new-api/service"
+	"github.com/QuantumNous/new-api/setting/ratio_setting"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 已存储的 Responses API 响应：兼容层保存的响应与网关后台执行的响应由网关直接返回、删除，
+// 原生渠道保存在上游的响应转发到创建它的渠道。只能访问令牌所属用户的响应，且令牌须允许使用该响应的模型
+
+// GetResponse 获取已存储的响应
+func GetResponse(c *gin.Context) {
+	stored := lookupStoredResponse(c)
+	if stored == nil {
+		return
+	}
+	if stored.Native {
+		forwardStoredResponse(c, stored, "")
+		return
+	}
+	c.Data(http.StatusOK, "application/json", []byte(stored.Response))
+}
+
+// DeleteResponse 删除已存储的响应
+func DeleteResponse(c *gin.Context) {
+	stored := lookupStoredResponse(c)
+	if stored == nil {
+		return
+	}
+	if stored.Native {
+		if !forwardStoredResponse(c, stored, "") {
+			return
+		}
+		// 上游已删除，本地记录的删除失败不影响返回结果
+		if err := service.DeleteStoredResponse(stored.UserId, stored.ResponseId); err != nil {
+			common.SysError("delete stored response failed: " + err.Error())
+		}
+		return
+	}
+	if err := service.DeleteStoredResponse(stored.UserId, stored.ResponseId); err != nil {
+		openAILookupError(c, err, "response")
+		return
+	}
+	if stored.Background {
+		service.StopResponsesBackgroundJob(stored.ResponseId)
+	}
+	c.JSON(http.StatusOK, gin.H{"id": stored.ResponseId, "object": "response", "deleted": true})
+}
+
+// CancelResponse 取消后台运行的响应：网关后台执行的响应由网关取消，原生渠道的响应转发到上游；
+// 兼容层同步返回的响应已经完成，无法取消
+func CancelResponse(c *gin.Context) {
+	stored := lookupStoredResponse(c)
+	if stored == nil {
+		return
+	}
+	if stored.Background {
+		response, err := service.CancelBackgroundResponse(stored)
+		if err != nil {
+			openAIErrorResponse(c, http.StatusInternalServerError, openAIErrorTypeServer, "internal_error", err.Error())
+			return
+		}
+		c.Data(http.StatusOK, "application/json", []byte(response))
+		return
+	}
+	if !stored.Native {
+		openAIErrorResponse(c, http.StatusBadRequest, openAIErrorTypeInvalidRequest, "invalid_request", "Only responses created with background mode can be cancelled.")
+		return
+	}
+	forwardStoredResponse(c, stored, "/cancel")
+}
+
+type responseFeedbackRequest struct {
+	Rating *int     `json:"rating"` // 1 好评、-1 差评、0 取消评价
+	Tags   []string `json:"tags"`   // 为 null 时保留原有标签
+}
+
+// UpdateResponseFeedback 评价兼容层保存的响应并设置标签，导出训练数据集时据此筛选与配对
+func UpdateResponseFeedback(c *gin.Context) {
+	var req responseFeedbackRequest
+	if err := common.UnmarshalBodyReusable(c, &req); err != nil {
+		openAIErrorResponse(c, http.StatusBadRequest, openAIErrorTypeInvalidRequest, "invalid_request", err.Error())
+		return
+	}
+	if req.Rating != nil && (*req.Rating < -1 || *req.Rating > 1) {
+		openAIErrorResponse(c, http.StatusBadRequest, openAIErrorTypeInvalidRequest, "invalid_request", "rating must be 1, 0 or -1")
+		return
+	}
+	stored := lookupStoredResponse(c)
+	if stored == nil {
+		return
+	}
+	if stored.Native {
+		openAIErrorResponse(c, http.StatusBadRequest, openAIErrorTypeInvalidRequest, "invalid_request", "Only responses stored by the gateway accept feedback.")
+		return
+	}
+	if req.Rating != nil {
+		stored.Rating = *req.Rating
+	}
+	if req.Tags != nil {
+		stored.Tags = model.JoinStoredResponseTags(req.Tags)
+	}
+	if err := service.UpdateStoredResponseFeedback(stored); err != nil {
+		openAIErrorResponse(c, http.StatusInternalServerError, openAIErrorTypeServer, "internal_error", err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"id":     stored.ResponseId,
+		"object": "response.feedback",
+		"rating": stored.Rating,
+		"tags":   model.StoredResponseTagList(stored.Tags),
+	})
+}
+
+// ExportResponse 导出已存储响应所在的整条对话链，format 为 messages（默认）或 markdown
+func ExportResponse(c *gin.Context) {
+	format := c.DefaultQuery("format", service.ResponsesExportFormatMessages)
+	if !isResponsesExportFormat(format) {
+		openAIErrorResponse(c, http.StatusBadRequest, openAIErrorTypeInvalidRequest, "invalid_request", responsesExportFormatError)
+		return
+	}
+	stored := lookupStoredResponse(c)
+	if stored == nil {
+		return
+	}
+	export, err := service.ExportResponsesConversation(stored.UserId, stored.ResponseId)
+	if err != nil {
+		if errors.Is(err, service.ErrPreviousResponseNotFound) {
+			openAIErrorResponse(c, http.StatusBadRequest, openAIErrorTypeInvalidRequest, "invalid_request", "Only responses stored by the gateway can be exported: "+err.Error())
+			return
+		}
+		openAIErrorResponse(c, http.StatusInternalServerError, openAIErrorTypeServer, "internal_error", err.Error())
+		return
+	}
+	if format == service.ResponsesExportFormatMarkdown {
+		writeResponsesExportMarkdown(c, export)
+		return
+	}
+	c.JSON(http.StatusOK, export)
+}
+
+// AdminExportResponse 管理员导出指定用户已存储响应所在的对话链，用于排查问题
+func AdminExportResponse(c *gin.Context) {
+	format := c.DefaultQuery("format", service.ResponsesExportFormatMessages)
+	if !isResponsesExportFormat(format) {
+		common.ApiErrorMsg(c, responsesExportFormatError)
+		return
+	}
+	userId, _ := strconv.Atoi(c.Query("user_id"))
+	if userId <= 0 {
+		common.ApiErrorMsg(c, "user_id is required")
+		return
+	}
+	export, err := service.ExportResponsesConversation(userId, c.Param("id"))
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	model.RecordLog(userId, model.LogTypeManage, fmt.Sprintf("管理员(ID:%d)导出了用户响应 %s 所在的对话", c.GetInt("id"), export.ResponseId))
+	if format == service.ResponsesExportFormatMarkdown {
+		writeResponsesExportMarkdown(c, export)
+		return
+	}
+	common.ApiSuccess(c, export)
+}
+
+var responsesExportFormatError = fmt.Sprintf("format must be %s or %s", service.ResponsesExportFormatMessages, service.ResponsesExportFormatMarkdown)
+
+func isResponsesExportFormat(format string) bool {
+	return format == service.ResponsesExportFormatMessages || format == service.ResponsesExportFormatMarkdown
+}
+
+func writeResponsesExportMarkdown(c *gin.Context, export *service.ResponsesConversationExport) {
+	c.Data(http.StatusOK, "text/markdown; charset=utf-8", []byte(service.RenderResponsesConversationMarkdown(export)))
+}
+
+// lookupStoredResponse 查询请求用户的已存储响应，不存在或令牌无权访问时写入错误响应并返回 nil
+func lookupStoredResponse(c *gin.Context) *model.StoredResponse {
+	responseId := c.Param("id")
+	stored, err := service.FindStoredResponse(c.GetInt("id"), responseId)
+	if err != nil {
+		openAIErrorResponse(c, http.StatusInternalServerError, openAIErrorTypeServer, "internal_error", err.Error())
+		return nil
+	}
+	if stored == nil {
+		openAIErrorResponse(c, http.StatusNotFound, openAIErrorTypeInvalidRequest, "not_found", fmt.Sprintf("Response with id '%s' not found.", responseId))
+		return nil
+	}
+	if common.GetContextKeyBool(c, constant.ContextKeyTokenModelLimitEnabled) {
+		s, _ := common.GetContextKey(c, constant.ContextKeyTokenModelLimit)
+		tokenModelLimit, _ := s.(map[string]bool)
+		if !tokenModelLimit[ratio_setting.FormatMatchingModelName(stored.ModelName)] {
+			openAIErrorResponse(c, http.StatusForbidden, openAIErrorTypePermission, "model_not_allowed", i18n.T(c, i18n.MsgDistributorTokenModelForbidden, map[string]any{"Model": stored.ModelName}))
+			return nil
+		}
+	}
+	return stored
+}
+
+// forwardStoredResponse 以创建响应时的密钥将请求转发到保存响应的原生渠道并原样返回上游响应，上游返回成功时返回 true
+func forwardStoredResponse(c *gin.Context, stored *model.StoredResponse, suffix string) bool {
+	channel, err := model.CacheGetChannel(stored.ChannelId)
+	if err != nil {
+		openAIErrorResponse(c, http.StatusNotFound, openAIErrorTypeInvalidRequest, "not_found", fmt.Sprintf("Response with id '%s' not found: the channel that stored it no longer exists.", stored.ResponseId))
+		return false
+	}
+	if newAPIError := middleware.SetupContextForSelectedChannel(c, channel, stored.ModelName); newAPIError != nil {
+		openAIErrorResponse(c, http.StatusServiceUnavailable, openAIErrorTypeUpstream, "channel_unavailable", newAPIError.Error())
+		return false
+	}
+	// 上游响应只能由创建它的账号访问，多密钥渠道须使用创建时的密钥而不是轮询下一个
+	if channel.ChannelInfo.IsMultiKey {
+		keys := channel.GetKeys()
+		if stored.KeyIndex < 0 || stored.KeyIndex >= len(keys) {
+			openAIErrorResponse(c, http.StatusNotFound, openAIErrorTypeInvalidRequest, "not_found", fmt.Sprintf("Response with id '%s' not found: the channel key that stored it no longer exists.", stored.ResponseId))
+			return false
+		}
+		common.SetContextKey(c, constant.ContextKeyChannelKey, keys[stored.KeyIndex])
+		common.SetContextKey(c, constant.ContextKeyChannelMultiKeyIndex, stored.KeyIndex)
+	}
+	resp, err := relay.StoredResponseRequest(c, "/"+stored.ResponseId+suffix)
+	if err != nil {
+		openAIErrorResponse(c, http.StatusBadGateway, openAIErrorTypeUpstream, "upstream_error", common.MaskSensitiveInfo(err.Error()))
+		return false
+	}
+	defer service.CloseResponseBodyGracefully(resp)
+	for _, header := range []string{"Content-Type", "Cache-Control"} {
+		if value := resp.Header.Get(header); value != "" {
+			c.Header(header, value)
+		}
+	}
+	c.Status(resp.StatusCode)
+	if _, err = io.Copy(c.Writer, resp.Body); err != nil {
+		common.SysError("copy stored response failed: " + err.Error())
+	}
+	return resp.StatusCode >= 200 && resp.StatusCode < 300
+}
@@ -0,0 +1,202 @@
+package controller
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/constant"
+	"github.com/QuantumNous/new-api/logger"
+	"github.com/QuantumNous/new-api/middleware"
+	"github.com/QuantumNous/new-api/service"
+	"github.com/QuantumNous/new-api/types"
+
+	"github.com/gin-gonic/gin"
+)
+
+// IsBackgroundResponsesRequest 判断 /v1/responses 请求是否要求后台执行（background=true）
+func IsBackgroundResponsesRequest(c *gin.Context) bool {
+	storage, err := common.GetBodyStorage(c)
+	if err != nil {
+		return false
+	}
+	body, err := storage.Bytes()
+	if err != nil {
+		return false
+	}
+	var request struct {
+		Background bool `json:"background"`
+	}
+	return common.Unmarshal(body, &request) == nil && request.Background
+}
+
+// RelayResponsesBackground 接受 background=true 的 Responses 请求：立即返回 queued 状态的响应对象，
+// 请求去掉 background 后在工作池中按普通请求执行（重新选择渠道、计费、记录日志），结果写回存储供轮询。
+// 后台请求不支持流式返回，且须允许存储（store 不为 false）
+func RelayResponsesBackground(c *gin.Context) {
+	storage, err := common.GetBodyStorage(c)
+	if err != nil {
+		responsesBackgroundError(c, http.StatusBadRequest, types.ErrorCodeReadRequestBodyFailed, err)
+		return
+	}
+	body, err := storage.Bytes()
+	if err != nil {
+		responsesBackgroundError(c, http.StatusBadRequest, types.ErrorCodeReadRequestBodyFailed, err)
+		return
+	}
+	var request map[string]json.RawMessage
+	if err = common.Unmarshal(body, &request); err != nil {
+		responsesBackgroundError(c, http.StatusBadRequest, types.ErrorCodeInvalidRequest, fmt.Errorf("invalid request body: %w", err))
+		return
+	}
+	var stream bool
+	var store *bool
+	var modelName string
+	_ = common.Unmarshal(request["stream"], &stream)
+	_ = common.Unmarshal(request["store"], &store)
+	_ = common.Unmarshal(request["model"], &modelName)
+	if stream {
+		responsesBackgroundError(c, http.StatusBadRequest, types.ErrorCodeUnsupportedFeature, errors.New("stream is not supported in background mode"))
+		return
+	}
+	if store != nil && !*store {
+		responsesBackgroundError(c, http.StatusBadRequest, types.ErrorCodeInvalidRequest, errors.New("background mode requires store=true"))
+		return
+	}
+	delete(request, "background")
+	delete(request, "stream")
+	jobBody, err := common.Marshal(request)
+	if err != nil {
+		responsesBackgroundError(c, http.StatusInternalServerError, types.ErrorCodeJsonMarshalFailed, err)
+		return
+	}
+
+	responseId := fmt.Sprintf("resp_%s", common.GetUUID())
+	queued, err := common.Marshal(map[string]any{
+		"id":         responseId,
+		"object":     "response",
+		"created_at": common.GetTimestamp(),
+		"status":     service.ResponsesStatusQueued,
+		"background": true,
+		"model":      modelName,
+		"output":     []any{},
+		"error":      nil,
+		"usage":      nil,
+	})
+	if err != nil {
+		responsesBackgroundError(c, http.StatusInternalServerError, types.ErrorCodeJsonMarshalFailed, err)
+		return
+	}
+	userId := c.GetInt("id")
+	if err = service.CreateBackgroundResponse(userId, modelName, responseId, queued); err != nil {
+		responsesBackgroundError(c, http.StatusBadRequest, types.ErrorCodeUnsupportedFeature, err)
+		return
+	}
+
+	// 任务不随客户端请求结束而取消，只能通过 cancel、delete 中止，超过最长执行时间后中止
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(c.Request.Context()), service.ResponsesBackgroundTimeout())
+	w := &backgroundWriter{header: make(http.Header)}
+	sub := newBackgroundContext(c, ctx, w, responseId, jobBody)
+	err = service.SubmitResponsesBackgroundJob(func() {
+		runBackgroundResponse(sub, w, cancel, userId, responseId)
+	})
+	if err != nil {
+		cancel()
+		if err := service.DeleteStoredResponse(userId, responseId); err != nil {
+			common.SysError("delete background response failed: " + err.Error())
+		}
+		responsesBackgroundError(c, http.StatusTooManyRequests, types.ErrorCodeRateLimitExceeded, err)
+		return
+	}
+	c.Data(http.StatusOK, "application/json", queued)
+}
+
+// runBackgroundResponse 在工作池中执行后台请求并写回结果，请求在排队期间被取消或删除时不再执行
+func runBackgroundResponse(sub *gin.Context, w *backgroundWriter, cancel context.CancelFunc, userId int, responseId string) {
+	defer cancel()
+	defer common.CleanupBodyStorage(sub)
+	started, err := service.StartBackgroundResponse(userId, responseId)
+	if err != nil {
+		logger.LogError(sub, "start background response failed: "+err.Error())
+	}
+	if !started {
+		return
+	}
+	done := service.RegisterResponsesBackgroundCancel(responseId, cancel)
+	defer done()
+	func() {
+		defer func() {
+			if r := recover(); r != nil {
+				logger.LogError(sub, fmt.Sprintf("background response %s panic: %v", responseId, r))
+				w.WriteHeader(http.StatusInternalServerError)
+			}
+		}()
+		middleware.Distribute()(sub)
+		if !sub.IsAborted() {
+			Relay(sub, types.RelayFormatOpenAIResponses)
+		}
+	}()
+	if w.status == 0 {
+		w.WriteHeader(http.StatusBadGateway)
+	}
+	if err := service.FinishBackgroundResponse(userId, responseId, w.status, w.body.Bytes()); err != nil {
+		logger.LogError(sub, "save background response failed: "+err.Error())
+	}
+}
+
+func responsesBackgroundError(c *gin.Context, statusCode int, code types.ErrorCode, err error) {
+	newAPIError := types.NewErrorWithStatusCode(err, code, statusCode)
+	newAPIError.SetMessage(common.MessageWithRequestId(newAPIError.Error(), c.GetString(common.RequestIdKey)))
+	c.JSON(newAPIError.StatusCode, gin.H{
+		"error": newAPIError.ToOpenAIError(),
+	})
+}
+
+// newBackgroundContext builds the gin context a background request runs in: the request
+// is cloned with the job body and the detached context, and the auth context is copied
+// from the original request, which is recycled once the queued response has been returned.
+func newBackgroundContext(c *gin.Context, ctx context.Context, w http.ResponseWriter, responseId string, body []byte) *gin.Context {
+	sub, _ := gin.CreateTestContext(w)
+	req := c.Request.Clone(ctx)
+	req.Body = io.NopCloser(bytes.NewReader(body))
+	req.ContentLength = int64(len(body))
+	sub.Request = req
+	for key, value := range c.Keys {
+		switch key {
+		case common.KeyBodyStorage, common.KeyRequestBody, "event_stream_headers_set", "use_channel":
+			continue
+		}
+		sub.Set(key, value)
+	}
+	common.SetContextKey(sub, constant.ContextKeyResponsesBackgroundId, responseId)
+	return sub
+}
+
+// backgroundWriter captures the response of a background request.
+type backgroundWriter struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func (w *backgroundWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *backgroundWriter) WriteHeader(statusCode int) {
+	if w.status == 0 {
+		w.status = statusCode
+	}
+}
+
+func (w *backgroundWriter) Write(data []byte) (int, error) {
+	w.WriteHeader(http.StatusOK)
+	return w.body.Write(data)
+}
+
+func (w *backgroundWriter) Flush() {}
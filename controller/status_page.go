@@ -0,0 +1,132 @@
+package controller
+
+import (
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/constant"
+	"github.com/QuantumNous/new-api/i18n"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	ModelStatusOperational = "operational"
+	ModelStatusDegraded    = "degraded"
+	ModelStatusDown        = "down"
+
+	modelStatusCacheTTL = time.Minute
+)
+
+type ModelStatus struct {
+	Model     string   `json:"model"`
+	Status    string   `json:"status"`
+	Requests  int64    `json:"requests"`
+	Errors    int64    `json:"errors"`
+	ErrorRate *float64 `json:"error_rate"`
+}
+
+type ModelStatusPage struct {
+	UpdatedAt     int64         `json:"updated_at"`
+	WindowMinutes int           `json:"window_minutes"`
+	Models        []ModelStatus `json:"models"`
+}
+
+var (
+	modelStatusCache     *ModelStatusPage
+	modelStatusCacheTime time.Time
+	modelStatusCacheLock sync.Mutex
+)
+
+// GetModelStatusPage is the public status endpoint: per-model availability derived from
+// channel health and error rates from recent logs. Channel identities are never exposed.
+func GetModelStatusPage(c *gin.Context) {
+	setting := operation_setting.GetMonitorSetting()
+	if !setting.PublicStatusEnabled {
+		c.JSON(http.StatusNotFound, gin.H{
+			"success": false,
+			"message": i18n.T(c, i18n.MsgFeatureDisabled),
+		})
+		return
+	}
+
+	modelStatusCacheLock.Lock()
+	defer modelStatusCacheLock.Unlock()
+	if modelStatusCache == nil || time.Since(modelStatusCacheTime) > modelStatusCacheTTL {
+		page, err := buildModelStatusPage(setting)
+		if err != nil {
+			common.ApiError(c, err)
+			return
+		}
+		modelStatusCache = page
+		modelStatusCacheTime = time.Now()
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+		"data":    modelStatusCache,
+	})
+}
+
+func buildModelStatusPage(setting *operation_setting.MonitorSetting) (*ModelStatusPage, error) {
+	windowMinutes := setting.PublicStatusWindowMinutes
+	if windowMinutes <= 0 {
+		windowMinutes = 60
+	}
+	availability, err := model.GetModelChannelAvailability()
+	if err != nil {
+		return nil, err
+	}
+	since := time.Now().Add(-time.Duration(windowMinutes) * time.Minute).Unix()
+	counts, err := model.GetModelLogCountsSince(since)
+	if err != nil {
+		return nil, err
+	}
+
+	successes := make(map[string]int64)
+	failures := make(map[string]int64)
+	for _, count := range counts {
+		if count.Type == model.LogTypeError {
+			failures[count.ModelName] += count.Count
+		} else {
+			successes[count.ModelName] += count.Count
+		}
+	}
+
+	// error rates are only meaningful when both consume and error logs are recorded
+	rateKnown := common.LogConsumeEnabled && constant.ErrorLogEnabled
+	models := make([]ModelStatus, 0, len(availability))
+	for _, item := range availability {
+		status := ModelStatus{
+			Model:    item.Model,
+			Status:   ModelStatusOperational,
+			Requests: successes[item.Model] + failures[item.Model],
+			Errors:   failures[item.Model],
+		}
+		if rateKnown && status.Requests > 0 {
+			rate := float64(status.Errors) / float64(status.Requests)
+			status.ErrorRate = &rate
+			if setting.PublicStatusDegradedRate > 0 && rate >= setting.PublicStatusDegradedRate {
+				status.Status = ModelStatusDegraded
+			}
+		}
+		if item.EnabledCount == 0 {
+			status.Status = ModelStatusDown
+		}
+		models = append(models, status)
+	}
+	sort.Slice(models, func(i, j int) bool {
+		return models[i].Model < models[j].Model
+	})
+
+	return &ModelStatusPage{
+		UpdatedAt:     time.Now().Unix(),
+		WindowMinutes: windowMinutes,
+		Models:        models,
+	}, nil
+}
@@ -0,0 +1,59 @@
+package controller
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/constant"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+	"github.com/glebarez/sqlite"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func TestBuildModelStatusPage(t *testing.T) {
+	common.UsingSQLite = true
+	dsn := fmt.Sprintf("file:%s?mode=memory&cache=shared", strings.ReplaceAll(t.Name(), "/", "_"))
+	db, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{})
+	require.NoError(t, err)
+	model.DB = db
+	model.LOG_DB = db
+	require.NoError(t, db.AutoMigrate(&model.Ability{}, &model.Log{}))
+
+	originConsume, originError := common.LogConsumeEnabled, constant.ErrorLogEnabled
+	common.LogConsumeEnabled, constant.ErrorLogEnabled = true, true
+	t.Cleanup(func() { common.LogConsumeEnabled, constant.ErrorLogEnabled = originConsume, originError })
+
+	require.NoError(t, db.Create(&[]model.Ability{
+		{Group: "default", Model: "gpt-ok", ChannelId: 1, Enabled: true},
+		{Group: "vip", Model: "gpt-ok", ChannelId: 1, Enabled: true},
+		{Group: "default", Model: "gpt-bad", ChannelId: 2, Enabled: true},
+		{Group: "default", Model: "gpt-down", ChannelId: 3, Enabled: false},
+	}).Error)
+	now := time.Now().Unix()
+	require.NoError(t, db.Create(&[]model.Log{
+		{ModelName: "gpt-ok", Type: model.LogTypeConsume, CreatedAt: now},
+		{ModelName: "gpt-bad", Type: model.LogTypeConsume, CreatedAt: now},
+		{ModelName: "gpt-bad", Type: model.LogTypeError, CreatedAt: now},
+		{ModelName: "gpt-bad", Type: model.LogTypeError, CreatedAt: now - 7200},
+	}).Error)
+
+	page, err := buildModelStatusPage(&operation_setting.MonitorSetting{PublicStatusWindowMinutes: 60, PublicStatusDegradedRate: 0.2})
+	require.NoError(t, err)
+	require.Len(t, page.Models, 3)
+
+	statuses := make(map[string]ModelStatus)
+	for _, status := range page.Models {
+		statuses[status.Model] = status
+	}
+	require.Equal(t, ModelStatusOperational, statuses["gpt-ok"].Status)
+	require.Equal(t, ModelStatusDegraded, statuses["gpt-bad"].Status)
+	require.EqualValues(t, 2, statuses["gpt-bad"].Requests)
+	require.InDelta(t, 0.5, *statuses["gpt-bad"].ErrorRate, 1e-9)
+	require.Equal(t, ModelStatusDown, statuses["gpt-down"].Status)
+	require.Nil(t, statuses["gpt-down"].ErrorRate)
+}
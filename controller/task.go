@@ -1,6 +1,7 @@
 package controller
 
 import (
+	"net/http"
 	"strconv"
 
 	"github.com/QuantumNous/new-api/common"
@@ -66,6 +67,20 @@ func GetUserTask(c *gin.Context) {
 	common.ApiSuccess(c, pageInfo)
 }
 
+// GetBatchRequest 查询转入上游 Batch API 的请求状态与结果
+func GetBatchRequest(c *gin.Context) {
+	task, exist, err := model.GetByTaskId(c.GetInt("id"), c.Param("id"))
+	if err != nil {
+		openAIErrorResponse(c, http.StatusInternalServerError, openAIErrorTypeServer, "internal_error", err.Error())
+		return
+	}
+	if !exist || task.Platform != constant.TaskPlatformBatch {
+		openAIErrorResponse(c, http.StatusNotFound, openAIErrorTypeInvalidRequest, "not_found", "batch request not found")
+		return
+	}
+	c.JSON(http.StatusOK, task.ToBatchRequest())
+}
+
 func tasksToDto(tasks []*model.Task, fillUser bool) []*dto.TaskDto {
 	var userIdMap map[int]*model.UserBase
 	if fillUser {
@@ -0,0 +1,111 @@
+package controller
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/i18n"
+	"github.com/QuantumNous/new-api/model"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetTenants returns all tenants (root only)
+func GetTenants(c *gin.Context) {
+	tenants, err := model.GetAllTenants()
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, tenants)
+}
+
+// GetTenant returns a single tenant by ID
+func GetTenant(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		common.ApiErrorI18n(c, i18n.MsgInvalidParams)
+		return
+	}
+	tenant, err := model.GetTenantById(id)
+	if err != nil {
+		common.ApiErrorI18n(c, i18n.MsgTenantNotFound)
+		return
+	}
+	common.ApiSuccess(c, tenant)
+}
+
+// CreateTenant creates a new tenant
+func CreateTenant(c *gin.Context) {
+	var tenant model.Tenant
+	if err := common.DecodeJson(c.Request.Body, &tenant); err != nil {
+		common.ApiErrorI18n(c, i18n.MsgInvalidParams)
+		return
+	}
+	tenant.Id = 0
+	if !checkTenantSelectors(c, &tenant) {
+		return
+	}
+	if err := model.CreateTenant(&tenant); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, tenant)
+}
+
+// UpdateTenant updates an existing tenant
+func UpdateTenant(c *gin.Context) {
+	var tenant model.Tenant
+	if err := common.DecodeJson(c.Request.Body, &tenant); err != nil || tenant.Id == 0 {
+		common.ApiErrorI18n(c, i18n.MsgInvalidParams)
+		return
+	}
+	if _, err := model.GetTenantById(tenant.Id); err != nil {
+		common.ApiErrorI18n(c, i18n.MsgTenantNotFound)
+		return
+	}
+	if !checkTenantSelectors(c, &tenant) {
+		return
+	}
+	if err := model.UpdateTenant(&tenant); err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	common.ApiSuccess(c, tenant)
+}
+
+// DeleteTenant deletes a tenant without users
+func DeleteTenant(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		common.ApiErrorI18n(c, i18n.MsgInvalidParams)
+		return
+	}
+	if err = model.DeleteTenant(id); err != nil {
+		if errors.Is(err, model.ErrTenantHasUsers) {
+			common.ApiErrorI18n(c, i18n.MsgTenantHasUsers)
+			return
+		}
+		common.ApiError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "",
+	})
+}
+
+// checkTenantSelectors makes sure the slug and hostname select only this tenant.
+func checkTenantSelectors(c *gin.Context, tenant *model.Tenant) bool {
+	if model.IsTenantSlugTaken(tenant.Slug, tenant.Id) {
+		common.ApiErrorI18n(c, i18n.MsgTenantSlugExists)
+		return false
+	}
+	if model.IsTenantHostnameTaken(tenant.Hostname, tenant.Id) {
+		common.ApiErrorI18n(c, i18n.MsgTenantHostnameExists)
+		return false
+	}
+	return true
+}
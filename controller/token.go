@@ -3,10 +3,12 @@ package controller
 import (
 	"fmt"
 	"net/http"
+	"net/url"
 	"strconv"
 	"strings"
 
 	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/constant"
 	"github.com/QuantumNous/new-api/i18n"
 	"github.com/QuantumNous/new-api/model"
 	"github.com/QuantumNous/new-api/setting/operation_setting"
@@ -14,6 +16,9 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
+// 令牌相同请求去重窗口的上限（秒）
+const maxTokenDedupWindow = 300
+
 func buildMaskedTokenResponse(token *model.Token) *model.Token {
 	if token == nil {
 		return nil
@@ -77,6 +82,24 @@ func GetToken(c *gin.Context) {
 	common.ApiSuccess(c, buildMaskedTokenResponse(token))
 }
 
+// GetTokenQuotaPeriods 返回令牌过去额度周期的用量记录
+func GetTokenQuotaPeriods(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	pageInfo := common.GetPageQuery(c)
+	records, total, err := model.GetTokenQuotaPeriodRecords(id, c.GetInt("id"), pageInfo.GetStartIdx(), pageInfo.GetPageSize())
+	if err != nil {
+		common.ApiError(c, err)
+		return
+	}
+	pageInfo.SetTotal(int(total))
+	pageInfo.SetItems(records)
+	common.ApiSuccess(c, pageInfo)
+}
+
 func GetTokenKey(c *gin.Context) {
 	id, err := strconv.Atoi(c.Param("id"))
 	userId := c.GetInt("id")
@@ -136,6 +159,10 @@ func GetTokenUsage(c *gin.Context) {
 	tokenKey := parts[1]
 
 	token, err := model.GetTokenByKey(strings.TrimPrefix(tokenKey, "sk-"), false)
+	if err == nil && token.IsRequestQuotaMode() {
+		// 请求计数只在数据库中更新，缓存中的值可能已过时
+		token, err = model.GetTokenByKey(token.Key, true)
+	}
 	if err != nil {
 		common.SysError("failed to get token by key: " + err.Error())
 		common.ApiErrorI18n(c, i18n.MsgTokenGetInfoFailed)
@@ -147,23 +174,70 @@ func GetTokenUsage(c *gin.Context) {
 		expiredAt = 0
 	}
 
+	data := gin.H{
+		"object":               "token_usage",
+		"name":                 token.Name,
+		"total_granted":        token.RemainQuota + token.UsedQuota,
+		"total_used":           token.UsedQuota,
+		"total_available":      token.RemainQuota,
+		"unlimited_quota":      token.UnlimitedQuota,
+		"model_limits":         token.GetModelLimitsMap(),
+		"model_limits_enabled": token.ModelLimitsEnabled,
+		"expires_at":           expiredAt,
+		"quota_mode":           token.QuotaMode,
+	}
+	if token.IsRequestQuotaMode() {
+		data["request_quota"] = token.RequestQuota
+		data["request_quota_period"] = token.RequestQuotaPeriod
+		data["request_available"] = token.RemainTokenRequests()
+	}
 	c.JSON(http.StatusOK, gin.H{
 		"code":    true,
 		"message": "ok",
-		"data": gin.H{
-			"object":               "token_usage",
-			"name":                 token.Name,
-			"total_granted":        token.RemainQuota + token.UsedQuota,
-			"total_used":           token.UsedQuota,
-			"total_available":      token.RemainQuota,
-			"unlimited_quota":      token.UnlimitedQuota,
-			"model_limits":         token.GetModelLimitsMap(),
-			"model_limits_enabled": token.ModelLimitsEnabled,
-			"expires_at":           expiredAt,
-		},
+		"data":    data,
 	})
 }
 
+// normalizeTokenCountries 校验并规范化令牌的国家允许/禁止列表，校验失败时已写入响应
+func normalizeTokenCountries(c *gin.Context, token *model.Token) bool {
+	allow, deny := token.GetCountryLimits()
+	for _, code := range append(allow, deny...) {
+		if !common.IsValidCountryCode(code) {
+			common.ApiErrorI18n(c, i18n.MsgTokenCountryInvalid, map[string]any{"Code": code})
+			return false
+		}
+	}
+	token.AllowCountries = strings.Join(allow, ",")
+	token.DenyCountries = strings.Join(deny, ",")
+	return true
+}
+
+// normalizeTokenChannelLabels 校验并规范化令牌的渠道标签约束，校验失败时已写入响应
+func normalizeTokenChannelLabels(c *gin.Context, token *model.Token) bool {
+	labels, err := model.NormalizeChannelLabelSelector(token.ChannelLabels)
+	if err != nil {
+		common.ApiErrorI18n(c, i18n.MsgTokenChannelLabelsInvalid, map[string]any{"Error": err.Error()})
+		return false
+	}
+	token.ChannelLabels = labels
+	return true
+}
+
+// validateTokenUsageWebhook 校验令牌用量推送地址，校验失败时已写入响应
+func validateTokenUsageWebhook(c *gin.Context, token *model.Token) bool {
+	token.UsageWebhookUrl = strings.TrimSpace(token.UsageWebhookUrl)
+	if token.UsageWebhookUrl == "" {
+		token.UsageWebhookSecret = ""
+		return true
+	}
+	parsed, err := url.ParseRequestURI(token.UsageWebhookUrl)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || len(token.UsageWebhookUrl) > 512 || len(token.UsageWebhookSecret) > 128 {
+		common.ApiErrorI18n(c, i18n.MsgSettingWebhookInvalid)
+		return false
+	}
+	return true
+}
+
 func AddToken(c *gin.Context) {
 	token := model.Token{}
 	err := c.ShouldBindJSON(&token)
@@ -175,6 +249,43 @@ func AddToken(c *gin.Context) {
 		common.ApiErrorI18n(c, i18n.MsgTokenNameTooLong)
 		return
 	}
+	if !constant.IsValidCompatMode(token.CompatMode) {
+		common.ApiErrorI18n(c, i18n.MsgTokenCompatModeInvalid)
+		return
+	}
+	if !constant.IsValidContextOverflowStrategy(token.ContextOverflow) {
+		common.ApiErrorI18n(c, i18n.MsgTokenContextOverflowInvalid)
+		return
+	}
+	if !constant.IsValidApiVersion(token.ApiVersion) {
+		common.ApiErrorI18n(c, i18n.MsgTokenApiVersionInvalid)
+		return
+	}
+	if !operation_setting.IsValidClientProfile(token.ClientProfile) {
+		common.ApiErrorI18n(c, i18n.MsgTokenClientProfileInvalid, map[string]any{"Profiles": strings.Join(operation_setting.GetClientProfileNames(), ", ")})
+		return
+	}
+	if token.DedupWindow < 0 || token.DedupWindow > maxTokenDedupWindow {
+		common.ApiErrorI18n(c, i18n.MsgTokenDedupWindowInvalid, map[string]any{"Max": maxTokenDedupWindow})
+		return
+	}
+	if !model.IsValidTokenRequestQuota(token.QuotaMode, token.RequestQuota, token.RequestQuotaPeriod) {
+		common.ApiErrorI18n(c, i18n.MsgTokenRequestQuotaInvalid)
+		return
+	}
+	if !model.IsValidTokenQuotaResetPeriod(token.QuotaResetPeriod) || token.QuotaPeriodAnchor < 0 {
+		common.ApiErrorI18n(c, i18n.MsgTokenQuotaPeriodInvalid)
+		return
+	}
+	if !normalizeTokenCountries(c, &token) {
+		return
+	}
+	if !normalizeTokenChannelLabels(c, &token) {
+		return
+	}
+	if !validateTokenUsageWebhook(c, &token) {
+		return
+	}
 	// 非无限额度时，检查额度值是否超出有效范围
 	if !token.UnlimitedQuota {
 		if token.RemainQuota < 0 {
@@ -219,9 +330,25 @@ func AddToken(c *gin.Context) {
 		ModelLimitsEnabled: token.ModelLimitsEnabled,
 		ModelLimits:        token.ModelLimits,
 		AllowIps:           token.AllowIps,
+		AllowCountries:     token.AllowCountries,
+		DenyCountries:      token.DenyCountries,
+		UsageWebhookUrl:    token.UsageWebhookUrl,
+		UsageWebhookSecret: token.UsageWebhookSecret,
 		Group:              token.Group,
 		CrossGroupRetry:    token.CrossGroupRetry,
-	}
+		CompatMode:         token.CompatMode,
+		ContextOverflow:    token.ContextOverflow,
+		DedupWindow:        token.DedupWindow,
+		DedupExemptStream:  token.DedupExemptStream,
+		BatchMode:          token.BatchMode,
+		ChannelLabels:      token.ChannelLabels,
+		ApiVersion:         token.ApiVersion,
+		ClientProfile:      token.ClientProfile,
+		QuotaMode:          token.QuotaMode,
+		RequestQuota:       token.RequestQuota,
+		RequestQuotaPeriod: token.RequestQuotaPeriod,
+	}
+	cleanToken.SetQuotaResetPeriod(token.QuotaResetPeriod, token.QuotaPeriodAnchor)
 	err = cleanToken.Insert()
 	if err != nil {
 		common.ApiError(c, err)
@@ -260,6 +387,43 @@ func UpdateToken(c *gin.Context) {
 		common.ApiErrorI18n(c, i18n.MsgTokenNameTooLong)
 		return
 	}
+	if !constant.IsValidCompatMode(token.CompatMode) {
+		common.ApiErrorI18n(c, i18n.MsgTokenCompatModeInvalid)
+		return
+	}
+	if !constant.IsValidContextOverflowStrategy(token.ContextOverflow) {
+		common.ApiErrorI18n(c, i18n.MsgTokenContextOverflowInvalid)
+		return
+	}
+	if !constant.IsValidApiVersion(token.ApiVersion) {
+		common.ApiErrorI18n(c, i18n.MsgTokenApiVersionInvalid)
+		return
+	}
+	if !operation_setting.IsValidClientProfile(token.ClientProfile) {
+		common.ApiErrorI18n(c, i18n.MsgTokenClientProfileInvalid, map[string]any{"Profiles": strings.Join(operation_setting.GetClientProfileNames(), ", ")})
+		return
+	}
+	if token.DedupWindow < 0 || token.DedupWindow > maxTokenDedupWindow {
+		common.ApiErrorI18n(c, i18n.MsgTokenDedupWindowInvalid, map[string]any{"Max": maxTokenDedupWindow})
+		return
+	}
+	if !model.IsValidTokenRequestQuota(token.QuotaMode, token.RequestQuota, token.RequestQuotaPeriod) {
+		common.ApiErrorI18n(c, i18n.MsgTokenRequestQuotaInvalid)
+		return
+	}
+	if !model.IsValidTokenQuotaResetPeriod(token.QuotaResetPeriod) || token.QuotaPeriodAnchor < 0 {
+		common.ApiErrorI18n(c, i18n.MsgTokenQuotaPeriodInvalid)
+		return
+	}
+	if !normalizeTokenCountries(c, &token) {
+		return
+	}
+	if !normalizeTokenChannelLabels(c, &token) {
+		return
+	}
+	if !validateTokenUsageWebhook(c, &token) {
+		return
+	}
 	if !token.UnlimitedQuota {
 		if token.RemainQuota < 0 {
 			common.ApiErrorI18n(c, i18n.MsgTokenQuotaNegative)
@@ -276,6 +440,10 @@ func UpdateToken(c *gin.Context) {
 		common.ApiError(c, err)
 		return
 	}
+	if cleanToken.IsTemporary() && statusOnly == "" {
+		common.ApiErrorMsg(c, "临时令牌只能启用、禁用或删除")
+		return
+	}
 	if token.Status == common.TokenStatusEnabled {
 		if cleanToken.Status == common.TokenStatusExpired && cleanToken.ExpiredTime <= common.GetTimestamp() && cleanToken.ExpiredTime != -1 {
 			common.ApiErrorI18n(c, i18n.MsgTokenExpiredCannotEnable)
@@ -297,8 +465,38 @@ func UpdateToken(c *gin.Context) {
 		cleanToken.ModelLimitsEnabled = token.ModelLimitsEnabled
 		cleanToken.ModelLimits = token.ModelLimits
 		cleanToken.AllowIps = token.AllowIps
+		cleanToken.AllowCountries = token.AllowCountries
+		cleanToken.DenyCountries = token.DenyCountries
+		cleanToken.UsageWebhookUrl = token.UsageWebhookUrl
+		cleanToken.UsageWebhookSecret = token.UsageWebhookSecret
 		cleanToken.Group = token.Group
 		cleanToken.CrossGroupRetry = token.CrossGroupRetry
+		cleanToken.CompatMode = token.CompatMode
+		cleanToken.ContextOverflow = token.ContextOverflow
+		cleanToken.DedupWindow = token.DedupWindow
+		cleanToken.DedupExemptStream = token.DedupExemptStream
+		cleanToken.BatchMode = token.BatchMode
+		cleanToken.ChannelLabels = token.ChannelLabels
+		cleanToken.ApiVersion = token.ApiVersion
+		cleanToken.ClientProfile = token.ClientProfile
+		cleanToken.QuotaMode = token.QuotaMode
+		cleanToken.RequestQuota = token.RequestQuota
+		cleanToken.RequestQuotaPeriod = token.RequestQuotaPeriod
+		if token.QuotaResetPeriod != cleanToken.QuotaResetPeriod || token.QuotaPeriodAnchor != cleanToken.QuotaPeriodAnchor {
+			// 临时令牌的额度从父令牌预留，切换额度周期会让预留额度在重置与回收时重复计入
+			if token.QuotaResetPeriod != cleanToken.QuotaResetPeriod {
+				hasTemporary, err := model.HasTemporaryTokens(cleanToken.Id)
+				if err != nil {
+					common.ApiError(c, err)
+					return
+				}
+				if hasTemporary {
+					common.ApiErrorMsg(c, "令牌存在未回收的临时令牌，不能修改额度重置周期")
+					return
+				}
+			}
+			cleanToken.SetQuotaResetPeriod(token.QuotaResetPeriod, token.QuotaPeriodAnchor)
+		}
 	}
 	err = cleanToken.Update()
 	if err != nil {
@@ -0,0 +1,90 @@
+package controller
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/dto"
+	"github.com/QuantumNous/new-api/service"
+	"github.com/QuantumNous/new-api/types"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CountTokens POST /v1/token/count 估算 Chat Completions（messages）或 Responses（input）请求的提示词 token 数，
+// 请求不会转发到上游，也不计费
+func CountTokens(c *gin.Context) {
+	var probe struct {
+		Model    string          `json:"model"`
+		Messages json.RawMessage `json:"messages"`
+		Input    json.RawMessage `json:"input"`
+	}
+	if err := common.UnmarshalBodyReusable(c, &probe); err != nil {
+		openAIErrorResponse(c, http.StatusBadRequest, openAIErrorTypeInvalidRequest, "invalid_request", "invalid request body: "+err.Error())
+		return
+	}
+	if probe.Model == "" {
+		openAIErrorResponse(c, http.StatusBadRequest, openAIErrorTypeInvalidRequest, "invalid_request", "model is required")
+		return
+	}
+
+	var resp *dto.TokenCountResponse
+	var err error
+	switch {
+	case len(probe.Messages) > 0:
+		var request dto.GeneralOpenAIRequest
+		if err = common.UnmarshalBodyReusable(c, &request); err == nil {
+			resp, err = service.CountChatRequestTokens(c, &request)
+		}
+	case len(probe.Input) > 0:
+		var request dto.OpenAIResponsesRequest
+		if err = common.UnmarshalBodyReusable(c, &request); err == nil {
+			resp, err = service.CountResponsesRequestTokens(c, &request)
+		}
+	default:
+		openAIErrorResponse(c, http.StatusBadRequest, openAIErrorTypeInvalidRequest, "invalid_request", "messages or input is required")
+		return
+	}
+	if err != nil {
+		openAIErrorResponse(c, http.StatusBadRequest, openAIErrorTypeInvalidRequest, "invalid_request", err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// CountClaudeMessageTokens POST /v1/messages/count_tokens 以 Anthropic 格式估算 Messages 请求的输入 token 数，
+// 供 Anthropic SDK 的 messages.count_tokens 使用，请求不会转发到上游，也不计费
+func CountClaudeMessageTokens(c *gin.Context) {
+	var request dto.ClaudeRequest
+	if err := common.UnmarshalBodyReusable(c, &request); err != nil {
+		claudeCountTokensError(c, "invalid request body: "+err.Error())
+		return
+	}
+	if request.Model == "" {
+		claudeCountTokensError(c, "model: field required")
+		return
+	}
+	if len(request.Messages) == 0 {
+		claudeCountTokensError(c, "messages: field required")
+		return
+	}
+	tokens, err := service.CountClaudeRequestTokens(c, &request)
+	if err != nil {
+		claudeCountTokensError(c, err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"input_tokens": tokens,
+	})
+}
+
+func claudeCountTokensError(c *gin.Context, message string) {
+	c.JSON(http.StatusBadRequest, gin.H{
+		"type": "error",
+		"error": types.ClaudeError{
+			Type:    "invalid_request_error",
+			Message: message,
+		},
+	})
+}
@@ -0,0 +1,205 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/dto"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 临时令牌：服务端用自己的令牌签发短期、限模型、限额度的临时令牌，交给浏览器或移动端直接调用网关，
+// 避免在客户端暴露长期令牌。临时令牌不能再签发临时令牌
+
+const (
+	realtimeClientSecretDefaultTTL = 600
+	realtimeClientSecretMinTTL     = 10
+	realtimeClientSecretMaxTTL     = 7200
+)
+
+// temporaryTokenSpec 待签发的临时令牌，ttl 与 quota 为 0 时使用默认值
+type temporaryTokenSpec struct {
+	name   string
+	ttl    int64
+	models []string
+	quota  *int
+}
+
+// CreateTemporaryToken 由当前令牌签发临时令牌
+func CreateTemporaryToken(c *gin.Context) {
+	var request dto.TemporaryTokenRequest
+	if err := common.UnmarshalBodyReusable(c, &request); err != nil {
+		openAIErrorResponse(c, http.StatusBadRequest, openAIErrorTypeInvalidRequest, "invalid_request", err.Error())
+		return
+	}
+	name := request.Name
+	if name == "" {
+		name = "temporary"
+	}
+	token, ok := mintTemporaryToken(c, temporaryTokenSpec{name: name, ttl: request.ExpiresIn, models: request.Models, quota: request.Quota})
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, dto.TemporaryTokenResponse{
+		Object:    "temporary_token",
+		Id:        token.Id,
+		Key:       "sk-" + token.Key,
+		Name:      token.Name,
+		ParentId:  token.ParentId,
+		Models:    token.GetModelLimits(),
+		Quota:     token.RemainQuota,
+		CreatedAt: token.CreatedTime,
+		ExpiresAt: token.ExpiredTime,
+	})
+}
+
+// CreateRealtimeClientSecret 签发 Realtime 临时密钥，与 OpenAI 的 /v1/realtime/client_secrets 兼容：
+// 返回的 ek_ 密钥是一个临时令牌，可在 WebSocket 子协议 openai-insecure-api-key.<key> 或 Authorization 中使用，
+// session 指定 model 时只能使用该模型
+func CreateRealtimeClientSecret(c *gin.Context) {
+	var request dto.RealtimeClientSecretRequest
+	if err := common.UnmarshalBodyReusable(c, &request); err != nil {
+		openAIErrorResponse(c, http.StatusBadRequest, openAIErrorTypeInvalidRequest, "invalid_request", err.Error())
+		return
+	}
+	ttl := int64(realtimeClientSecretDefaultTTL)
+	if request.ExpiresAfter != nil {
+		if request.ExpiresAfter.Anchor != "" && request.ExpiresAfter.Anchor != "created_at" {
+			openAIErrorResponse(c, http.StatusBadRequest, openAIErrorTypeInvalidRequest, "invalid_request", "expires_after.anchor must be created_at")
+			return
+		}
+		if request.ExpiresAfter.Seconds != 0 {
+			ttl = request.ExpiresAfter.Seconds
+		}
+	}
+	if ttl < realtimeClientSecretMinTTL || ttl > realtimeClientSecretMaxTTL {
+		openAIErrorResponse(c, http.StatusBadRequest, openAIErrorTypeInvalidRequest, "invalid_request",
+			fmt.Sprintf("expires_after.seconds must be between %d and %d", realtimeClientSecretMinTTL, realtimeClientSecretMaxTTL))
+		return
+	}
+	session := request.Session
+	if session == nil {
+		session = map[string]any{}
+	}
+	if _, ok := session["type"]; !ok {
+		session["type"] = "realtime"
+	}
+	var models []string
+	if modelName, ok := session["model"].(string); ok && modelName != "" {
+		models = []string{modelName}
+	}
+	token, ok := mintTemporaryToken(c, temporaryTokenSpec{name: "realtime", ttl: ttl, models: models, quota: request.Quota})
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, dto.RealtimeClientSecretResponse{
+		Value:     model.RealtimeClientSecretPrefix + token.Key,
+		ExpiresAt: token.ExpiredTime,
+		Session:   session,
+	})
+}
+
+// mintTemporaryToken 校验并签发当前令牌的临时令牌，失败时已写入错误响应
+func mintTemporaryToken(c *gin.Context, spec temporaryTokenSpec) (*model.Token, bool) {
+	setting := operation_setting.GetTokenSetting()
+	parent, err := model.GetTokenByIds(c.GetInt("token_id"), c.GetInt("id"))
+	if err != nil {
+		openAIErrorResponse(c, http.StatusInternalServerError, openAIErrorTypeServer, "internal_error", err.Error())
+		return nil, false
+	}
+	if parent.IsTemporary() {
+		openAIErrorResponse(c, http.StatusForbidden, openAIErrorTypePermission, "permission_denied", "temporary tokens cannot mint temporary tokens")
+		return nil, false
+	}
+	if len(spec.name) > 50 {
+		openAIErrorResponse(c, http.StatusBadRequest, openAIErrorTypeInvalidRequest, "invalid_request", "name must be at most 50 characters")
+		return nil, false
+	}
+
+	ttl := spec.ttl
+	if ttl == 0 {
+		ttl = int64(setting.TemporaryTokenDefaultTTL)
+	}
+	if ttl <= 0 || ttl > int64(setting.TemporaryTokenMaxTTL) {
+		openAIErrorResponse(c, http.StatusBadRequest, openAIErrorTypeInvalidRequest, "invalid_request", fmt.Sprintf("expires_in must be between 1 and %d", setting.TemporaryTokenMaxTTL))
+		return nil, false
+	}
+	now := common.GetTimestamp()
+	expiredTime := now + ttl
+	// 不晚于父令牌的过期时间
+	if parent.ExpiredTime != -1 && parent.ExpiredTime < expiredTime {
+		expiredTime = parent.ExpiredTime
+	}
+
+	quota := setting.TemporaryTokenDefaultQuota
+	if spec.quota != nil {
+		quota = *spec.quota
+	}
+	if quota <= 0 || quota > int(1000000000*common.QuotaPerUnit) {
+		openAIErrorResponse(c, http.StatusBadRequest, openAIErrorTypeInvalidRequest, "invalid_request", "quota must be a positive number")
+		return nil, false
+	}
+
+	modelLimitsEnabled := parent.ModelLimitsEnabled
+	modelLimits := parent.ModelLimits
+	if len(spec.models) > 0 {
+		if parent.ModelLimitsEnabled {
+			allowed := parent.GetModelLimitsMap()
+			for _, modelName := range spec.models {
+				if !allowed[modelName] {
+					openAIErrorResponse(c, http.StatusForbidden, openAIErrorTypePermission, "permission_denied", fmt.Sprintf("model %s is not allowed for this token", modelName))
+					return nil, false
+				}
+			}
+		}
+		modelLimitsEnabled = true
+		modelLimits = strings.Join(spec.models, ",")
+	}
+
+	key, err := common.GenerateKey()
+	if err != nil {
+		common.SysLog("failed to generate token key: " + err.Error())
+		openAIErrorResponse(c, http.StatusInternalServerError, openAIErrorTypeServer, "internal_error", "failed to generate token key")
+		return nil, false
+	}
+	// 继承父令牌的分组与路由设置；IP 限制不继承，临时令牌本就用于在其他设备上使用
+	token := &model.Token{
+		Name:               spec.name,
+		Key:                key,
+		Status:             common.TokenStatusEnabled,
+		CreatedTime:        now,
+		AccessedTime:       now,
+		ExpiredTime:        expiredTime,
+		RemainQuota:        quota,
+		ModelLimitsEnabled: modelLimitsEnabled,
+		ModelLimits:        modelLimits,
+		AllowCountries:     parent.AllowCountries,
+		DenyCountries:      parent.DenyCountries,
+		Group:              parent.Group,
+		CrossGroupRetry:    parent.CrossGroupRetry,
+		CompatMode:         parent.CompatMode,
+		ContextOverflow:    parent.ContextOverflow,
+		ChannelLabels:      parent.ChannelLabels,
+		ApiVersion:         parent.ApiVersion,
+		ClientProfile:      parent.ClientProfile,
+		UsageWebhookUrl:    parent.UsageWebhookUrl,
+		UsageWebhookSecret: parent.UsageWebhookSecret,
+	}
+	if err = model.CreateTemporaryToken(parent, token); err != nil {
+		if errors.Is(err, model.ErrTemporaryTokenQuotaExceeded) {
+			openAIErrorResponse(c, http.StatusForbidden, openAIErrorTypeInsufficientQuota, "insufficient_quota", err.Error())
+		} else if errors.Is(err, model.ErrTemporaryTokenRequestModeParent) || errors.Is(err, model.ErrTemporaryTokenPeriodicParent) {
+			openAIErrorResponse(c, http.StatusForbidden, openAIErrorTypePermission, "permission_denied", err.Error())
+		} else {
+			openAIErrorResponse(c, http.StatusInternalServerError, openAIErrorTypeServer, "internal_error", err.Error())
+		}
+		return nil, false
+	}
+	return token, true
+}
@@ -304,6 +304,9 @@ func EpayNotify(c *gin.Context) {
 				log.Printf("易支付回调更新用户失败: %v", topUp)
 				return
 			}
+			if err := model.RecordQuotaPool(nil, topUp.UserId, model.QuotaPoolKindPrepaid, quotaToAdd, "topup"); err != nil {
+				log.Printf("易支付回调记录额度池失败: %v", err)
+			}
 			log.Printf("易支付回调更新用户成功 %v", topUp)
 			model.RecordLog(topUp.UserId, model.LogTypeTopup, fmt.Sprintf("使用在线充值成功，充值金额: %v，支付金额：%f", logger.LogQuota(quotaToAdd), topUp.Money))
 		}
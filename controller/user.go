@@ -86,12 +86,17 @@ func Login(c *gin.Context) {
 
 // setup session & cookies and then return user info
 func setupLogin(user *model.User, c *gin.Context) {
+	if !model.CanAccessTenant(user.TenantId, user.Role, common.GetContextKeyInt(c, constant.ContextKeyTenantId)) {
+		common.ApiErrorI18n(c, i18n.MsgTenantUserMismatch)
+		return
+	}
 	session := sessions.Default(c)
 	session.Set("id", user.Id)
 	session.Set("username", user.Username)
 	session.Set("role", user.Role)
 	session.Set("status", user.Status)
 	session.Set("group", user.Group)
+	session.Set("tenant_id", user.TenantId)
 	err := session.Save()
 	if err != nil {
 		common.ApiErrorI18n(c, i18n.MsgUserSessionSaveFailed)
@@ -176,6 +181,7 @@ func Register(c *gin.Context) {
 		InviterId:   inviterId,
 		Role:        common.RoleCommonUser, // 明确设置角色为普通用户
 	}
+	cleanUser.AssignTenant(model.GetContextTenant(c))
 	if common.EmailVerificationEnabled {
 		cleanUser.Email = user.Email
 	}
@@ -228,7 +234,7 @@ func Register(c *gin.Context) {
 
 func GetAllUsers(c *gin.Context) {
 	pageInfo := common.GetPageQuery(c)
-	users, total, err := model.GetAllUsers(pageInfo)
+	users, total, err := model.GetAllUsers(pageInfo, common.GetContextKeyInt(c, constant.ContextKeyUserTenantId))
 	if err != nil {
 		common.ApiError(c, err)
 		return
@@ -245,7 +251,7 @@ func SearchUsers(c *gin.Context) {
 	keyword := c.Query("keyword")
 	group := c.Query("group")
 	pageInfo := common.GetPageQuery(c)
-	users, total, err := model.SearchUsers(keyword, group, pageInfo.GetStartIdx(), pageInfo.GetPageSize())
+	users, total, err := model.SearchUsers(keyword, group, common.GetContextKeyInt(c, constant.ContextKeyUserTenantId), pageInfo.GetStartIdx(), pageInfo.GetPageSize())
 	if err != nil {
 		common.ApiError(c, err)
 		return
@@ -268,6 +274,9 @@ func GetUser(c *gin.Context) {
 		common.ApiError(c, err)
 		return
 	}
+	if !checkTenantUserScope(c, user) {
+		return
+	}
 	myRole := c.GetInt("role")
 	if myRole <= user.Role && myRole != common.RoleRootUser {
 		common.ApiErrorI18n(c, i18n.MsgUserNoPermissionSameLevel)
@@ -281,6 +290,16 @@ func GetUser(c *gin.Context) {
 	return
 }
 
+// checkTenantUserScope rejects tenant admins acting on users outside their tenant.
+func checkTenantUserScope(c *gin.Context, user *model.User) bool {
+	adminTenantId := common.GetContextKeyInt(c, constant.ContextKeyUserTenantId)
+	if adminTenantId != 0 && user.TenantId != adminTenantId {
+		common.ApiErrorI18n(c, i18n.MsgTenantNoPermission)
+		return false
+	}
+	return true
+}
+
 func GenerateAccessToken(c *gin.Context) {
 	id := c.GetInt("id")
 	user, err := model.GetUserById(id, true)
@@ -555,6 +574,13 @@ func UpdateUser(c *gin.Context) {
 		common.ApiError(c, err)
 		return
 	}
+	if !checkTenantUserScope(c, originUser) {
+		return
+	}
+	if tenant := model.GetCachedTenant(common.GetContextKeyInt(c, constant.ContextKeyUserTenantId)); tenant != nil && !tenant.OwnsGroup(updatedUser.Group) {
+		common.ApiErrorI18n(c, i18n.MsgTenantGroupForbidden, map[string]any{"Group": updatedUser.Group})
+		return
+	}
 	myRole := c.GetInt("role")
 	if myRole <= originUser.Role && myRole != common.RoleRootUser {
 		common.ApiErrorI18n(c, i18n.MsgUserNoPermissionHigherLevel)
@@ -763,6 +789,9 @@ func DeleteUser(c *gin.Context) {
 		common.ApiError(c, err)
 		return
 	}
+	if !checkTenantUserScope(c, originUser) {
+		return
+	}
 	myRole := c.GetInt("role")
 	if myRole <= originUser.Role {
 		common.ApiErrorI18n(c, i18n.MsgUserNoPermissionHigherLevel)
@@ -826,6 +855,12 @@ func CreateUser(c *gin.Context) {
 		DisplayName: user.DisplayName,
 		Role:        user.Role, // 保持管理员设置的角色
 	}
+	// 租户管理员创建的用户归属其租户，平台管理员按当前站点归属
+	if tenant := model.GetCachedTenant(common.GetContextKeyInt(c, constant.ContextKeyUserTenantId)); tenant != nil {
+		cleanUser.AssignTenant(tenant)
+	} else {
+		cleanUser.AssignTenant(model.GetContextTenant(c))
+	}
 	if err := cleanUser.Insert(0); err != nil {
 		common.ApiError(c, err)
 		return
@@ -861,6 +896,9 @@ func ManageUser(c *gin.Context) {
 		common.ApiErrorI18n(c, i18n.MsgUserNotExists)
 		return
 	}
+	if !checkTenantUserScope(c, &user) {
+		return
+	}
 	myRole := c.GetInt("role")
 	if myRole <= user.Role && myRole != common.RoleRootUser {
 		common.ApiErrorI18n(c, i18n.MsgUserNoPermissionHigherLevel)
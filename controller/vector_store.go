@@ -0,0 +1,499 @@
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/dto"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/pkg/vectorstore"
+	"github.com/QuantumNous/new-api/service"
+	"github.com/QuantumNous/new-api/types"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// maxUploadFileSize 通过 /v1/files 上传的单个文件大小上限
+const maxUploadFileSize = 32 << 20
+
+// maxBatchUploadFileSize Batch 输入文件（purpose=batch）的大小上限，与 OpenAI 一致
+const maxBatchUploadFileSize = 200 << 20
+
+func requireVectorStore(c *gin.Context) bool {
+	if !service.VectorStoreEnabled() {
+		openAIErrorResponse(c, http.StatusNotImplemented, openAIErrorTypeInvalidRequest, "vector_store_disabled", service.ErrVectorStoreDisabled.Error())
+		return false
+	}
+	return true
+}
+
+func listLimit(c *gin.Context) int {
+	limit, _ := strconv.Atoi(c.Query("limit"))
+	if limit <= 0 || limit > 100 {
+		limit = 20
+	}
+	return limit
+}
+
+func toFileObject(file *model.File) dto.FileObject {
+	return dto.FileObject{
+		Id:        file.Id,
+		Object:    "file",
+		Bytes:     file.Bytes,
+		CreatedAt: file.CreatedAt,
+		Filename:  file.Filename,
+		Purpose:   file.Purpose,
+	}
+}
+
+func UploadFile(c *gin.Context) {
+	purpose := c.PostForm("purpose")
+	if purpose == "" {
+		openAIErrorResponse(c, http.StatusBadRequest, openAIErrorTypeInvalidRequest, "invalid_request", "purpose is required")
+		return
+	}
+	header, err := c.FormFile("file")
+	if err != nil {
+		openAIErrorResponse(c, http.StatusBadRequest, openAIErrorTypeInvalidRequest, "invalid_request", "file is required")
+		return
+	}
+	limit := int64(maxUploadFileSize)
+	if purpose == service.BatchInputFilePurpose {
+		limit = maxBatchUploadFileSize
+	}
+	tooLarge := fmt.Sprintf("file exceeds the maximum size of %dMB", limit>>20)
+	if header.Size > limit {
+		openAIErrorResponse(c, http.StatusRequestEntityTooLarge, openAIErrorTypeInvalidRequest, "file_too_large", tooLarge)
+		return
+	}
+	reader, err := header.Open()
+	if err != nil {
+		openAIErrorResponse(c, http.StatusBadRequest, openAIErrorTypeInvalidRequest, "invalid_request", err.Error())
+		return
+	}
+	defer reader.Close()
+	file := &model.File{
+		UserId:   c.GetInt("id"),
+		Filename: header.Filename,
+		Purpose:  purpose,
+	}
+	if err = service.SaveGatewayFile(file, reader, limit); err != nil {
+		if errors.Is(err, service.ErrFileTooLarge) {
+			openAIErrorResponse(c, http.StatusRequestEntityTooLarge, openAIErrorTypeInvalidRequest, "file_too_large", tooLarge)
+			return
+		}
+		openAIErrorResponse(c, http.StatusInternalServerError, openAIErrorTypeServer, "internal_error", err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, toFileObject(file))
+}
+
+func ListFiles(c *gin.Context) {
+	files, err := model.GetUserFiles(c.GetInt("id"), c.Query("purpose"), listLimit(c))
+	if err != nil {
+		openAIErrorResponse(c, http.StatusInternalServerError, openAIErrorTypeServer, "internal_error", err.Error())
+		return
+	}
+	data := make([]dto.FileObject, 0, len(files))
+	for _, file := range files {
+		data = append(data, toFileObject(file))
+	}
+	c.JSON(http.StatusOK, dto.ListResponse[dto.FileObject]{Object: "list", Data: data})
+}
+
+func GetFile(c *gin.Context) {
+	file, err := model.GetFileById(c.Param("id"), c.GetInt("id"), false)
+	if err != nil {
+		openAILookupError(c, err, "file")
+		return
+	}
+	c.JSON(http.StatusOK, toFileObject(file))
+}
+
+func GetFileContent(c *gin.Context) {
+	file, reader, err := service.OpenGatewayFile(c.Param("id"), c.GetInt("id"))
+	if err != nil {
+		openAILookupError(c, err, "file")
+		return
+	}
+	defer reader.Close()
+	c.DataFromReader(http.StatusOK, file.Bytes, "application/octet-stream", reader, nil)
+}
+
+func DeleteFile(c *gin.Context) {
+	userId := c.GetInt("id")
+	fileId := c.Param("id")
+	vsFiles, err := model.GetVectorStoreFilesByFileId(fileId, userId)
+	if err != nil {
+		openAIErrorResponse(c, http.StatusInternalServerError, openAIErrorTypeServer, "internal_error", err.Error())
+		return
+	}
+	if err = service.DeleteGatewayFile(fileId, userId); err != nil {
+		openAILookupError(c, err, "file")
+		return
+	}
+	// 同时从引用该文件的向量库中移除
+	for _, vsFile := range vsFiles {
+		_ = service.DeleteVectorStoreFileChunks(c.Request.Context(), vsFile.VectorStoreId, vsFile.FileId)
+		_ = model.DeleteVectorStoreFile(vsFile)
+	}
+	c.JSON(http.StatusOK, gin.H{"id": fileId, "object": "file", "deleted": true})
+}
+
+func toVectorStoreObject(store *model.VectorStore) dto.VectorStoreObject {
+	counts, _ := model.GetVectorStoreFileCounts(store.Id)
+	object := dto.VectorStoreObject{
+		Id:           store.Id,
+		Object:       "vector_store",
+		CreatedAt:    store.CreatedAt,
+		Name:         store.Name,
+		UsageBytes:   store.UsageBytes,
+		FileCounts:   counts,
+		Status:       model.VectorStoreStatusCompleted,
+		LastActiveAt: store.LastActiveAt,
+		Metadata:     map[string]string{},
+	}
+	if counts.InProgress > 0 {
+		object.Status = model.VectorStoreStatusInProgress
+	}
+	if store.ExpiresDays > 0 {
+		expiresAt := store.LastActiveAt + int64(store.ExpiresDays)*86400
+		object.ExpiresAfter = &dto.VectorStoreExpiresAfter{Anchor: "last_active_at", Days: store.ExpiresDays}
+		object.ExpiresAt = &expiresAt
+		if expiresAt < common.GetTimestamp() {
+			object.Status = model.VectorStoreStatusExpired
+		}
+	}
+	if store.Metadata != "" {
+		_ = common.UnmarshalJsonStr(store.Metadata, &object.Metadata)
+	}
+	return object
+}
+
+func toVectorStoreFileObject(vsFile *model.VectorStoreFile) dto.VectorStoreFileObject {
+	object := dto.VectorStoreFileObject{
+		Id:            vsFile.FileId,
+		Object:        "vector_store.file",
+		UsageBytes:    vsFile.UsageBytes,
+		CreatedAt:     vsFile.CreatedAt,
+		VectorStoreId: vsFile.VectorStoreId,
+		Status:        vsFile.Status,
+		ChunkingStrategy: dto.ChunkingStrategy{
+			Type: "static",
+			Static: &dto.StaticChunkingStrategy{
+				MaxChunkSizeTokens: vsFile.MaxChunkSize,
+				ChunkOverlapTokens: vsFile.ChunkOverlap,
+			},
+		},
+		Attributes: map[string]any{},
+	}
+	if vsFile.LastError != "" {
+		object.LastError = &dto.VectorStoreFileError{Code: "server_error", Message: vsFile.LastError}
+	}
+	if vsFile.Attributes != "" {
+		_ = common.UnmarshalJsonStr(vsFile.Attributes, &object.Attributes)
+	}
+	return object
+}
+
+// resolveChunkingStrategy 校验分块参数，未指定时使用默认值
+func resolveChunkingStrategy(strategy *dto.ChunkingStrategy) (int, int, error) {
+	if strategy == nil || strategy.Type == "" || strategy.Type == "auto" {
+		return vectorstore.DefaultMaxChunkSizeTokens, vectorstore.DefaultChunkOverlapTokens, nil
+	}
+	if strategy.Type != "static" || strategy.Static == nil {
+		return 0, 0, errors.New("invalid chunking_strategy")
+	}
+	maxSize := strategy.Static.MaxChunkSizeTokens
+	overlap := strategy.Static.ChunkOverlapTokens
+	if maxSize < 100 || maxSize > 4096 {
+		return 0, 0, errors.New("max_chunk_size_tokens must be between 100 and 4096")
+	}
+	if overlap < 0 || overlap > maxSize/2 {
+		return 0, 0, errors.New("chunk_overlap_tokens must not exceed half of max_chunk_size_tokens")
+	}
+	return maxSize, overlap, nil
+}
+
+// attachVectorStoreFile 将已上传的文件加入向量库，预扣计算向量的额度并开始异步导入
+func attachVectorStoreFile(c *gin.Context, userId int, vectorStoreId string, fileId string, strategy *dto.ChunkingStrategy, attributes map[string]any) (*model.VectorStoreFile, error) {
+	maxSize, overlap, err := resolveChunkingStrategy(strategy)
+	if err != nil {
+		return nil, err
+	}
+	file, err := model.GetFileById(fileId, userId, false)
+	if err != nil {
+		return nil, err
+	}
+	if err = service.CheckVectorStoreUserLimits(userId, file.Bytes); err != nil {
+		return nil, err
+	}
+	vsFile := &model.VectorStoreFile{
+		VectorStoreId: vectorStoreId,
+		FileId:        fileId,
+		UserId:        userId,
+		MaxChunkSize:  maxSize,
+		ChunkOverlap:  overlap,
+	}
+	if len(attributes) > 0 {
+		data, err := common.Marshal(attributes)
+		if err != nil {
+			return nil, err
+		}
+		vsFile.Attributes = string(data)
+	}
+	info, apiErr := service.PreConsumeVectorStoreIngestion(c, file.Bytes)
+	if apiErr != nil {
+		return nil, apiErr
+	}
+	if err = model.CreateVectorStoreFile(vsFile); err != nil {
+		info.Billing.Refund(c)
+		return nil, err
+	}
+	service.StartVectorStoreFileIngestion(c, info, vsFile)
+	return vsFile, nil
+}
+
+// vectorStoreAttachError 将加入文件失败的错误转换为对应的响应
+func vectorStoreAttachError(c *gin.Context, err error, message string) {
+	var apiErr *types.NewAPIError
+	if errors.As(err, &apiErr) {
+		errType := openAIErrorTypeServer
+		switch apiErr.GetErrorCode() {
+		case types.ErrorCodeInsufficientUserQuota, types.ErrorCodePreConsumeTokenQuotaFailed:
+			errType = openAIErrorTypeInsufficientQuota
+		}
+		openAIErrorResponse(c, apiErr.StatusCode, errType, string(apiErr.GetErrorCode()), message)
+		return
+	}
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		openAILookupError(c, err, "file")
+	case errors.Is(err, service.ErrVectorStoreLimitExceeded):
+		openAIErrorResponse(c, http.StatusBadRequest, openAIErrorTypeInvalidRequest, "limit_exceeded", message)
+	default:
+		openAIErrorResponse(c, http.StatusBadRequest, openAIErrorTypeInvalidRequest, "invalid_request", message)
+	}
+}
+
+func CreateVectorStore(c *gin.Context) {
+	if !requireVectorStore(c) {
+		return
+	}
+	var req dto.VectorStoreCreateRequest
+	if err := common.DecodeJson(c.Request.Body, &req); err != nil {
+		openAIErrorResponse(c, http.StatusBadRequest, openAIErrorTypeInvalidRequest, "invalid_request", err.Error())
+		return
+	}
+	if _, _, err := resolveChunkingStrategy(req.ChunkingStrategy); err != nil {
+		openAIErrorResponse(c, http.StatusBadRequest, openAIErrorTypeInvalidRequest, "invalid_request", err.Error())
+		return
+	}
+	userId := c.GetInt("id")
+	store := &model.VectorStore{UserId: userId, Name: req.Name}
+	if req.ExpiresAfter != nil {
+		store.ExpiresDays = req.ExpiresAfter.Days
+	}
+	if len(req.Metadata) > 0 {
+		data, _ := common.Marshal(req.Metadata)
+		store.Metadata = string(data)
+	}
+	if err := model.CreateVectorStore(store); err != nil {
+		openAIErrorResponse(c, http.StatusInternalServerError, openAIErrorTypeServer, "internal_error", err.Error())
+		return
+	}
+	for _, fileId := range req.FileIds {
+		if _, err := attachVectorStoreFile(c, userId, store.Id, fileId, req.ChunkingStrategy, nil); err != nil {
+			vectorStoreAttachError(c, err, "failed to add file "+fileId+": "+err.Error())
+			return
+		}
+	}
+	c.JSON(http.StatusOK, toVectorStoreObject(store))
+}
+
+func ListVectorStores(c *gin.Context) {
+	limit := listLimit(c)
+	stores, err := model.GetUserVectorStores(c.GetInt("id"), limit+1, c.Query("after"))
+	if err != nil {
+		openAIErrorResponse(c, http.StatusInternalServerError, openAIErrorTypeServer, "internal_error", err.Error())
+		return
+	}
+	response := dto.ListResponse[dto.VectorStoreObject]{Object: "list", Data: make([]dto.VectorStoreObject, 0, len(stores))}
+	if len(stores) > limit {
+		stores = stores[:limit]
+		response.HasMore = true
+	}
+	for _, store := range stores {
+		response.Data = append(response.Data, toVectorStoreObject(store))
+	}
+	if len(stores) > 0 {
+		response.FirstId = stores[0].Id
+		response.LastId = stores[len(stores)-1].Id
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+func GetVectorStore(c *gin.Context) {
+	store, err := model.GetVectorStoreById(c.Param("id"), c.GetInt("id"))
+	if err != nil {
+		openAILookupError(c, err, "vector store")
+		return
+	}
+	c.JSON(http.StatusOK, toVectorStoreObject(store))
+}
+
+func UpdateVectorStore(c *gin.Context) {
+	store, err := model.GetVectorStoreById(c.Param("id"), c.GetInt("id"))
+	if err != nil {
+		openAILookupError(c, err, "vector store")
+		return
+	}
+	var req dto.VectorStoreUpdateRequest
+	if err = common.DecodeJson(c.Request.Body, &req); err != nil {
+		openAIErrorResponse(c, http.StatusBadRequest, openAIErrorTypeInvalidRequest, "invalid_request", err.Error())
+		return
+	}
+	if req.Name != nil {
+		store.Name = *req.Name
+	}
+	if req.ExpiresAfter != nil {
+		store.ExpiresDays = req.ExpiresAfter.Days
+	}
+	if req.Metadata != nil {
+		data, _ := common.Marshal(req.Metadata)
+		store.Metadata = string(data)
+	}
+	if err = model.UpdateVectorStore(store); err != nil {
+		openAIErrorResponse(c, http.StatusInternalServerError, openAIErrorTypeServer, "internal_error", err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, toVectorStoreObject(store))
+}
+
+func DeleteVectorStore(c *gin.Context) {
+	id := c.Param("id")
+	if err := model.DeleteVectorStore(id, c.GetInt("id")); err != nil {
+		openAILookupError(c, err, "vector store")
+		return
+	}
+	if err := service.DeleteVectorStoreChunks(c.Request.Context(), id); err != nil {
+		common.SysError("failed to delete vector store chunks: " + err.Error())
+	}
+	c.JSON(http.StatusOK, gin.H{"id": id, "object": "vector_store.deleted", "deleted": true})
+}
+
+func CreateVectorStoreFile(c *gin.Context) {
+	if !requireVectorStore(c) {
+		return
+	}
+	userId := c.GetInt("id")
+	store, err := model.GetVectorStoreById(c.Param("id"), userId)
+	if err != nil {
+		openAILookupError(c, err, "vector store")
+		return
+	}
+	var req dto.VectorStoreFileCreateRequest
+	if err = common.DecodeJson(c.Request.Body, &req); err != nil || req.FileId == "" {
+		openAIErrorResponse(c, http.StatusBadRequest, openAIErrorTypeInvalidRequest, "invalid_request", "file_id is required")
+		return
+	}
+	vsFile, err := attachVectorStoreFile(c, userId, store.Id, req.FileId, req.ChunkingStrategy, req.Attributes)
+	if err != nil {
+		vectorStoreAttachError(c, err, err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, toVectorStoreFileObject(vsFile))
+}
+
+func ListVectorStoreFiles(c *gin.Context) {
+	store, err := model.GetVectorStoreById(c.Param("id"), c.GetInt("id"))
+	if err != nil {
+		openAILookupError(c, err, "vector store")
+		return
+	}
+	files, err := model.GetVectorStoreFiles(store.Id, c.Query("filter"), listLimit(c))
+	if err != nil {
+		openAIErrorResponse(c, http.StatusInternalServerError, openAIErrorTypeServer, "internal_error", err.Error())
+		return
+	}
+	data := make([]dto.VectorStoreFileObject, 0, len(files))
+	for _, file := range files {
+		data = append(data, toVectorStoreFileObject(file))
+	}
+	c.JSON(http.StatusOK, dto.ListResponse[dto.VectorStoreFileObject]{Object: "list", Data: data})
+}
+
+func GetVectorStoreFile(c *gin.Context) {
+	vsFile, err := model.GetVectorStoreFile(c.Param("id"), c.Param("file_id"), c.GetInt("id"))
+	if err != nil {
+		openAILookupError(c, err, "vector store file")
+		return
+	}
+	c.JSON(http.StatusOK, toVectorStoreFileObject(vsFile))
+}
+
+func DeleteVectorStoreFile(c *gin.Context) {
+	vsFile, err := model.GetVectorStoreFile(c.Param("id"), c.Param("file_id"), c.GetInt("id"))
+	if err != nil {
+		openAILookupError(c, err, "vector store file")
+		return
+	}
+	if err = service.DeleteVectorStoreFileChunks(c.Request.Context(), vsFile.VectorStoreId, vsFile.FileId); err != nil {
+		openAIErrorResponse(c, http.StatusInternalServerError, openAIErrorTypeServer, "internal_error", err.Error())
+		return
+	}
+	if err = model.DeleteVectorStoreFile(vsFile); err != nil {
+		openAIErrorResponse(c, http.StatusInternalServerError, openAIErrorTypeServer, "internal_error", err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"id": vsFile.FileId, "object": "vector_store.file.deleted", "deleted": true})
+}
+
+// parseSearchQuery 支持字符串或字符串数组形式的 query
+func parseSearchQuery(raw []byte) string {
+	if common.GetJsonType(raw) == "array" {
+		var queries []string
+		if err := common.Unmarshal(raw, &queries); err == nil {
+			return strings.Join(queries, "\n")
+		}
+		return ""
+	}
+	var query string
+	_ = common.Unmarshal(raw, &query)
+	return query
+}
+
+func SearchVectorStore(c *gin.Context) {
+	if !requireVectorStore(c) {
+		return
+	}
+	var req dto.VectorStoreSearchRequest
+	if err := common.DecodeJson(c.Request.Body, &req); err != nil {
+		openAIErrorResponse(c, http.StatusBadRequest, openAIErrorTypeInvalidRequest, "invalid_request", err.Error())
+		return
+	}
+	query := parseSearchQuery(req.Query)
+	options := service.FileSearchOptions{
+		VectorStoreIds: []string{c.Param("id")},
+		Query:          query,
+		MaxResults:     req.MaxNumResults,
+	}
+	if req.RankingOptions != nil {
+		options.ScoreThreshold = req.RankingOptions.ScoreThreshold
+	}
+	results, err := service.FileSearch(c.Request.Context(), c.GetInt("id"), options)
+	if err != nil {
+		openAIErrorResponse(c, http.StatusBadRequest, openAIErrorTypeInvalidRequest, "invalid_request", err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, dto.VectorStoreSearchResponse{
+		Object:      "vector_store.search_results.page",
+		SearchQuery: query,
+		Data:        results,
+	})
+}
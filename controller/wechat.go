@@ -94,6 +94,7 @@ func WeChatAuth(c *gin.Context) {
 			user.DisplayName = "WeChat User"
 			user.Role = common.RoleCommonUser
 			user.Status = common.UserStatusEnabled
+			user.AssignTenant(model.GetContextTenant(c))
 
 			if err := user.Insert(0); err != nil {
 				c.JSON(http.StatusOK, gin.H{
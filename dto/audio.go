@@ -18,6 +18,9 @@ type AudioRequest struct {
 	Speed          *float64        `json:"speed,omitempty"`
 	StreamFormat   string          `json:"stream_format,omitempty"`
 	Metadata       json.RawMessage `json:"metadata,omitempty"`
+	// 以下为转写请求的表单字段
+	Language string    `json:"language,omitempty"`
+	Stream   BoolValue `json:"stream,omitempty"`
 }
 
 func (r *AudioRequest) GetTokenCountMeta() *types.TokenCountMeta {
@@ -32,7 +35,7 @@ func (r *AudioRequest) GetTokenCountMeta() *types.TokenCountMeta {
 }
 
 func (r *AudioRequest) IsStream(c *gin.Context) bool {
-	return r.StreamFormat == "sse"
+	return r.StreamFormat == "sse" || bool(r.Stream)
 }
 
 func (r *AudioRequest) SetModelName(modelName string) {
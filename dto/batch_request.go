@@ -0,0 +1,110 @@
+package dto
+
+import (
+	"encoding/json"
+
+	"github.com/QuantumNous/new-api/types"
+)
+
+const (
+	BatchRequestStatusQueued     = "queued"
+	BatchRequestStatusInProgress = "in_progress"
+	BatchRequestStatusCompleted  = "completed"
+	BatchRequestStatusFailed     = "failed"
+)
+
+// BatchRequest 转入上游 Batch API 的单个请求，提交时立即返回，完成后可轮询获取结果
+type BatchRequest struct {
+	ID          string             `json:"id"`
+	Object      string             `json:"object"`
+	Model       string             `json:"model"`
+	Status      string             `json:"status"` // BatchRequestStatus* 常量
+	CreatedAt   int64              `json:"created_at"`
+	CompletedAt int64              `json:"completed_at,omitempty"`
+	Response    json.RawMessage    `json:"response,omitempty"` // 完成后为 Chat Completions 响应
+	Error       *types.OpenAIError `json:"error,omitempty"`
+}
+
+// OpenAIBatchCreateRequest POST /v1/batches
+type OpenAIBatchCreateRequest struct {
+	InputFileId      string            `json:"input_file_id"`
+	Endpoint         string            `json:"endpoint"`
+	CompletionWindow string            `json:"completion_window"`
+	Metadata         map[string]string `json:"metadata,omitempty"`
+}
+
+type OpenAIBatchRequestCounts struct {
+	Total     int `json:"total"`
+	Completed int `json:"completed"`
+	Failed    int `json:"failed"`
+}
+
+type OpenAIBatchErrors struct {
+	Data []types.OpenAIError `json:"data"`
+}
+
+// OpenAI Batch 对象的状态
+const (
+	OpenAIBatchStatusValidating = "validating"
+	OpenAIBatchStatusFailed     = "failed"
+	OpenAIBatchStatusInProgress = "in_progress"
+	OpenAIBatchStatusFinalizing = "finalizing"
+	OpenAIBatchStatusCompleted  = "completed"
+	OpenAIBatchStatusExpired    = "expired"
+	OpenAIBatchStatusCancelling = "cancelling"
+	OpenAIBatchStatusCancelled  = "cancelled"
+)
+
+// OpenAIBatch Batch 对象，既用于解析上游返回，也作为 /v1/batches 的响应
+type OpenAIBatch struct {
+	ID               string                   `json:"id"`
+	Object           string                   `json:"object,omitempty"`
+	Endpoint         string                   `json:"endpoint,omitempty"`
+	Errors           *OpenAIBatchErrors       `json:"errors,omitempty"`
+	InputFileId      string                   `json:"input_file_id,omitempty"`
+	CompletionWindow string                   `json:"completion_window,omitempty"`
+	Status           string                   `json:"status"`
+	OutputFileId     string                   `json:"output_file_id,omitempty"`
+	ErrorFileId      string                   `json:"error_file_id,omitempty"`
+	CreatedAt        int64                    `json:"created_at,omitempty"`
+	InProgressAt     int64                    `json:"in_progress_at,omitempty"`
+	ExpiresAt        int64                    `json:"expires_at,omitempty"`
+	CompletedAt      int64                    `json:"completed_at,omitempty"`
+	FailedAt         int64                    `json:"failed_at,omitempty"`
+	ExpiredAt        int64                    `json:"expired_at,omitempty"`
+	CancellingAt     int64                    `json:"cancelling_at,omitempty"`
+	CancelledAt      int64                    `json:"cancelled_at,omitempty"`
+	RequestCounts    OpenAIBatchRequestCounts `json:"request_counts"`
+	Metadata         map[string]string        `json:"metadata,omitempty"`
+}
+
+// IsTerminal 判断 Batch 是否已结束
+func (b *OpenAIBatch) IsTerminal() bool {
+	switch b.Status {
+	case OpenAIBatchStatusCompleted, OpenAIBatchStatusFailed, OpenAIBatchStatusExpired, OpenAIBatchStatusCancelled:
+		return true
+	}
+	return false
+}
+
+// OpenAIBatchInputLine Batch 输入文件中的一行
+type OpenAIBatchInputLine struct {
+	CustomId string          `json:"custom_id"`
+	Method   string          `json:"method"`
+	Url      string          `json:"url"`
+	Body     json.RawMessage `json:"body"`
+}
+
+type OpenAIBatchOutputResponse struct {
+	StatusCode int             `json:"status_code"`
+	RequestId  string          `json:"request_id"`
+	Body       json.RawMessage `json:"body"`
+}
+
+// OpenAIBatchOutputLine Batch 输出文件或错误文件中的一行
+type OpenAIBatchOutputLine struct {
+	ID       string                     `json:"id"`
+	CustomId string                     `json:"custom_id"`
+	Response *OpenAIBatchOutputResponse `json:"response"`
+	Error    *types.OpenAIError         `json:"error"`
+}
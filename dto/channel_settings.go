@@ -1,5 +1,7 @@
 package dto
 
+import "slices"
+
 type ChannelSettings struct {
 	ForceFormat            bool   `json:"force_format,omitempty"`
 	ThinkingToContent      bool   `json:"thinking_to_content,omitempty"`
@@ -24,22 +26,164 @@ const (
 )
 
 type ChannelOtherSettings struct {
-	AzureResponsesVersion                 string        `json:"azure_responses_version,omitempty"`
-	VertexKeyType                         VertexKeyType `json:"vertex_key_type,omitempty"` // "json" or "api_key"
-	OpenRouterEnterprise                  *bool         `json:"openrouter_enterprise,omitempty"`
-	ClaudeBetaQuery                       bool          `json:"claude_beta_query,omitempty"`         // Claude 渠道是否强制追加 ?beta=true
-	AllowServiceTier                      bool          `json:"allow_service_tier,omitempty"`        // 是否允许 service_tier 透传（默认过滤以避免额外计费）
-	AllowInferenceGeo                     bool          `json:"allow_inference_geo,omitempty"`       // 是否允许 inference_geo 透传（仅 Claude，默认过滤以满足数据驻留合规
-	AllowSafetyIdentifier                 bool          `json:"allow_safety_identifier,omitempty"`   // 是否允许 safety_identifier 透传（默认过滤以保护用户隐私）
-	DisableStore                          bool          `json:"disable_store,omitempty"`             // 是否禁用 store 透传（默认允许透传，禁用后可能导致 Codex 无法使用）
-	AllowIncludeObfuscation               bool          `json:"allow_include_obfuscation,omitempty"` // 是否允许 stream_options.include_obfuscation 透传（默认过滤以避免关闭流混淆保护）
-	AwsKeyType                            AwsKeyType    `json:"aws_key_type,omitempty"`
-	UpstreamModelUpdateCheckEnabled       bool          `json:"upstream_model_update_check_enabled,omitempty"`        // 是否检测上游模型更新
-	UpstreamModelUpdateAutoSyncEnabled    bool          `json:"upstream_model_update_auto_sync_enabled,omitempty"`    // 是否自动同步上游模型更新
-	UpstreamModelUpdateLastCheckTime      int64         `json:"upstream_model_update_last_check_time,omitempty"`      // 上次检测时间
-	UpstreamModelUpdateLastDetectedModels []string      `json:"upstream_model_update_last_detected_models,omitempty"` // 上次检测到的可加入模型
-	UpstreamModelUpdateLastRemovedModels  []string      `json:"upstream_model_update_last_removed_models,omitempty"`  // 上次检测到的可删除模型
-	UpstreamModelUpdateIgnoredModels      []string      `json:"upstream_model_update_ignored_models,omitempty"`       // 手动忽略的模型
+	AzureResponsesVersion                 string               `json:"azure_responses_version,omitempty"`
+	VertexKeyType                         VertexKeyType        `json:"vertex_key_type,omitempty"` // "json" or "api_key"
+	OpenRouterEnterprise                  *bool                `json:"openrouter_enterprise,omitempty"`
+	ClaudeBetaQuery                       bool                 `json:"claude_beta_query,omitempty"`         // Claude 渠道是否强制追加 ?beta=true
+	AllowServiceTier                      bool                 `json:"allow_service_tier,omitempty"`        // 是否允许 service_tier 透传（默认过滤以避免额外计费）
+	ForceServiceTier                      string               `json:"force_service_tier,omitempty"`        // 强制发往上游的 service_tier（flex / priority / default），优先于请求中的值
+	AllowInferenceGeo                     bool                 `json:"allow_inference_geo,omitempty"`       // 是否允许 inference_geo 透传（仅 Claude，默认过滤以满足数据驻留合规
+	AllowSafetyIdentifier                 bool                 `json:"allow_safety_identifier,omitempty"`   // 是否允许 safety_identifier 透传（默认过滤以保护用户隐私）
+	DisableStore                          bool                 `json:"disable_store,omitempty"`             // 是否禁用 store 透传（默认允许透传，禁用后可能导致 Codex 无法使用）
+	AllowIncludeObfuscation               bool                 `json:"allow_include_obfuscation,omitempty"` // 是否允许 stream_options.include_obfuscation 透传（默认过滤以避免关闭流混淆保护）
+	CompatMode                            string               `json:"compat_mode,omitempty"`               // 协议转换兼容模式：strict 拒绝无法转换的特性，lenient（默认）降级并返回警告
+	AwsKeyType                            AwsKeyType           `json:"aws_key_type,omitempty"`
+	UpstreamModelUpdateCheckEnabled       bool                 `json:"upstream_model_update_check_enabled,omitempty"`        // 是否检测上游模型更新
+	UpstreamModelUpdateAutoSyncEnabled    bool                 `json:"upstream_model_update_auto_sync_enabled,omitempty"`    // 是否自动同步上游模型更新
+	UpstreamModelUpdateLastCheckTime      int64                `json:"upstream_model_update_last_check_time,omitempty"`      // 上次检测时间
+	UpstreamModelUpdateLastDetectedModels []string             `json:"upstream_model_update_last_detected_models,omitempty"` // 上次检测到的可加入模型
+	UpstreamModelUpdateLastRemovedModels  []string             `json:"upstream_model_update_last_removed_models,omitempty"`  // 上次检测到的可删除模型
+	UpstreamModelUpdateIgnoredModels      []string             `json:"upstream_model_update_ignored_models,omitempty"`       // 手动忽略的模型
+	ResponsesCapability                   *ResponsesCapability `json:"responses_capability,omitempty"`                       // 自动探测到的上游 Responses API 支持情况
+	RequestSigning                        *RequestSigning      `json:"request_signing,omitempty"`                            // 上游请求签名与双向 TLS 设置，用于企业内部网关
+	UpstreamTLS                           *UpstreamTLS         `json:"upstream_tls,omitempty"`                               // 上游 HTTPS 证书校验设置，用于私有 PKI 的自建后端
+	UpstreamDialer                        *UpstreamDialer      `json:"upstream_dialer,omitempty"`                            // 上游连接的 IP 协议偏好与静态解析，用于绕开损坏的 IPv6 线路
+	RealtimeTranscriptionProtocol         string               `json:"realtime_transcription_protocol,omitempty"`            // 转写上游协议：openai（默认）或 deepgram，实时转写与文件转写共用
+	SpeechProtocol                        string               `json:"speech_protocol,omitempty"`                            // 语音合成上游协议：openai（默认）或 elevenlabs
+	RerankProtocol                        string               `json:"rerank_protocol,omitempty"`                            // 重排序上游协议：jina（默认，Jina / OpenAI 兼容格式）或 voyage
+	EmbeddingDimensionsEmulation          bool                 `json:"embedding_dimensions_emulation,omitempty"`             // 上游不支持 dimensions 时由网关截断向量并重新归一化
+	ResponsesReasoningItems               *bool                `json:"responses_reasoning_items,omitempty"`                  // Chat 转换为 Responses 时思考内容是否输出为独立的 reasoning 输出项，未设置时使用全局设置
+	UpstreamPathTemplates                 map[string]string    `json:"upstream_path_templates,omitempty"`                    // 按端点类型（chat_completions、responses、embeddings 等）覆盖上游请求路径的模板，用于路径不标准的 OpenAI 兼容上游
+	Prewarm                               *ChannelPrewarm      `json:"prewarm,omitempty"`                                    // 预热连接与就绪探测，避免首个请求承担建连或冷启动延迟
+	StreamPrelude                         *StreamPrelude       `json:"stream_prelude,omitempty"`                             // 流式响应开头的缓冲窗口，窗口内上游失败时可透明重试其他渠道，未设置时使用默认窗口
+	ModelAutoDiscovery                    bool                 `json:"model_auto_discovery,omitempty"`                       // 定期拉取上游模型列表并同步渠道模型（新增与下线），适用于 Ollama 等自托管服务
+	OllamaKeepAlive                       string               `json:"ollama_keep_alive,omitempty"`                          // Ollama 模型在内存中的保留时长（如 5m、1h、-1），请求未指定时使用
+	OllamaNumCtx                          int                  `json:"ollama_num_ctx,omitempty"`                             // Ollama 上下文窗口大小（options.num_ctx），请求未指定时使用
+}
+
+type RequestSigningType string
+
+const (
+	RequestSigningTypeNone     RequestSigningType = ""
+	RequestSigningTypeAwsSigV4 RequestSigningType = "aws_sigv4"
+	RequestSigningTypeHmac     RequestSigningType = "hmac"
+)
+
+// RequestSigning 上游请求签名设置，签名在 Header Override 之后、发送之前计算
+type RequestSigning struct {
+	Type RequestSigningType `json:"type,omitempty"`
+
+	// AWS SigV4，适用于 API Gateway 等通用端点
+	AwsRegion          string `json:"aws_region,omitempty"`
+	AwsService         string `json:"aws_service,omitempty"` // 默认 execute-api
+	AwsAccessKeyId     string `json:"aws_access_key_id,omitempty"`
+	AwsSecretAccessKey string `json:"aws_secret_access_key,omitempty"`
+	AwsSessionToken    string `json:"aws_session_token,omitempty"`
+
+	// HMAC 头签名
+	HmacSecret          string `json:"hmac_secret,omitempty"`
+	HmacKeyId           string `json:"hmac_key_id,omitempty"`
+	HmacAlgorithm       string `json:"hmac_algorithm,omitempty"`        // sha256（默认）或 sha512
+	HmacEncoding        string `json:"hmac_encoding,omitempty"`         // hex（默认）或 base64
+	HmacStringToSign    string `json:"hmac_string_to_sign,omitempty"`   // 待签名字符串模板，支持 {method} {path} {query} {timestamp} {nonce} {body_sha256} {header:Name}
+	HmacSignatureHeader string `json:"hmac_signature_header,omitempty"` // 默认 X-Signature
+	HmacKeyIdHeader     string `json:"hmac_key_id_header,omitempty"`    // 默认 X-Key-Id
+	HmacTimestampHeader string `json:"hmac_timestamp_header,omitempty"` // 默认 X-Timestamp
+	HmacNonceHeader     string `json:"hmac_nonce_header,omitempty"`     // 为空时不发送 nonce
+
+	// 双向 TLS 客户端证书（PEM），可与上述签名方式同时使用
+	TlsClientCert string `json:"tls_client_cert,omitempty"`
+	TlsClientKey  string `json:"tls_client_key,omitempty"`
+	TlsCaCert     string `json:"tls_ca_cert,omitempty"` // 自定义上游 CA，为空时使用系统证书
+}
+
+// SecretMask 渠道设置中的密钥返回给管理后台时使用的占位值，更新时仍为占位值的字段保留原值
+const SecretMask = "********"
+
+func (s *RequestSigning) secretFields() []*string {
+	return []*string{&s.AwsSecretAccessKey, &s.AwsSessionToken, &s.HmacSecret, &s.TlsClientKey}
+}
+
+// MaskSecrets 将签名密钥与 TLS 私钥替换为占位值
+func (s *RequestSigning) MaskSecrets() {
+	if s == nil {
+		return
+	}
+	for _, field := range s.secretFields() {
+		if *field != "" {
+			*field = SecretMask
+		}
+	}
+}
+
+// RestoreMaskedSecrets 将仍为占位值的密钥恢复为 origin 中的原值，origin 为 nil 时清空
+func (s *RequestSigning) RestoreMaskedSecrets(origin *RequestSigning) {
+	if s == nil {
+		return
+	}
+	var originFields []*string
+	if origin != nil {
+		originFields = origin.secretFields()
+	}
+	for i, field := range s.secretFields() {
+		if *field != SecretMask {
+			continue
+		}
+		*field = ""
+		if originFields != nil {
+			*field = *originFields[i]
+		}
+	}
+}
+
+// UpstreamTLS 渠道级上游证书校验，替代全局的跳过校验开关
+type UpstreamTLS struct {
+	CaCert             string   `json:"ca_cert,omitempty"`              // 额外信任的 CA 证书（PEM，可包含多个）
+	DisableSystemRoots bool     `json:"disable_system_roots,omitempty"` // 不信任系统根证书，仅信任 ca_cert
+	PinnedSha256       []string `json:"pinned_sha256,omitempty"`        // 固定的证书公钥（SPKI）SHA-256 指纹，hex 或 base64，证书链中任一证书匹配即通过
+}
+
+// UpstreamDialer 渠道级拨号设置，配置 SOCKS 代理时由代理负责连接，不生效
+type UpstreamDialer struct {
+	IPPreference    string            `json:"ip_preference,omitempty"`     // ipv4 / ipv6 仅连接该协议，prefer_ipv4 / prefer_ipv6 优先连接该协议，为空时使用系统默认
+	FallbackDelayMs int               `json:"fallback_delay_ms,omitempty"` // happy eyeballs 回退延迟（毫秒），0 使用默认 300ms，负数关闭并行回退
+	Hosts           map[string]string `json:"hosts,omitempty"`             // 静态解析，主机名 -> IP，TLS 仍按原主机名校验证书
+}
+
+// ChannelPrewarm 渠道预热设置：每个节点保持少量已建立 TLS 的空闲连接，
+// 并可由主节点定期发送极小的测试请求，使缩容到零的 serverless 后端（如 vLLM）保持就绪
+type ChannelPrewarm struct {
+	Connections         int    `json:"connections,omitempty"`           // 保持的预热连接数，0 不预热，最大 8；HTTP/2 上游多路复用，通常只需 1
+	PingIntervalSeconds int    `json:"ping_interval_seconds,omitempty"` // 就绪探测间隔（秒），0 不探测，最小 60
+	PingModel           string `json:"ping_model,omitempty"`            // 就绪探测使用的模型，为空时与渠道测试相同
+}
+
+// StreamPrelude 流式请求在转发给客户端前先缓冲上游响应的开头（收到首个内容 token 或达到字节上限为止），
+// 窗口内上游报错、断开或返回空流时请求尚未写出任何数据，可以换渠道重试
+type StreamPrelude struct {
+	MaxBytes  int `json:"max_bytes"`             // 缓冲字节上限，0 表示不缓冲
+	MaxWaitMs int `json:"max_wait_ms,omitempty"` // 最长等待时间（毫秒），超时后不再缓冲，为 0 时使用默认值
+}
+
+// ResponsesCapability 上游 /v1/responses 的探测结果，未探测时为 nil
+type ResponsesCapability struct {
+	Native     bool  `json:"native"`     // 上游是否原生支持 /v1/responses
+	Background bool  `json:"background"` // 是否接受 background
+	Store      bool  `json:"store"`      // 是否接受 store
+	WebSearch  bool  `json:"web_search"` // 是否支持内置 web_search 工具
+	CheckedAt  int64 `json:"checked_at"` // 探测时间
+}
+
+// ForceableServiceTiers 渠道可以强制发往上游的 service_tier
+var ForceableServiceTiers = []string{"default", "flex", "priority"}
+
+// GetForceServiceTier 返回渠道强制的 service_tier。保存渠道时已校验取值，使用时再次校验，
+// 历史数据或直接写库产生的非法值按未设置处理，不会原样发往上游或参与计费
+func (s *ChannelOtherSettings) GetForceServiceTier() string {
+	if s == nil || !slices.Contains(ForceableServiceTiers, s.ForceServiceTier) {
+		return ""
+	}
+	return s.ForceServiceTier
 }
 
 func (s *ChannelOtherSettings) IsOpenRouterEnterprise() bool {
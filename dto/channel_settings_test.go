@@ -0,0 +1,27 @@
+package dto
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRequestSigningMaskAndRestoreSecrets(t *testing.T) {
+	origin := &RequestSigning{Type: RequestSigningTypeHmac, HmacSecret: "secret", HmacKeyId: "key-1", TlsClientKey: "private key"}
+	masked := *origin
+	masked.MaskSecrets()
+	require.Equal(t, SecretMask, masked.HmacSecret)
+	require.Equal(t, SecretMask, masked.TlsClientKey)
+	require.Equal(t, "", masked.AwsSecretAccessKey)
+	require.Equal(t, "key-1", masked.HmacKeyId)
+
+	// 未修改的占位值恢复为原值，修改过的密钥保留新值
+	masked.TlsClientKey = "new private key"
+	masked.RestoreMaskedSecrets(origin)
+	require.Equal(t, "secret", masked.HmacSecret)
+	require.Equal(t, "new private key", masked.TlsClientKey)
+
+	masked.HmacSecret = SecretMask
+	masked.RestoreMaskedSecrets(nil)
+	require.Equal(t, "", masked.HmacSecret)
+}
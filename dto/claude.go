@@ -175,6 +175,14 @@ type ClaudeWebSearchTool struct {
 	UserLocation *ClaudeWebSearchUserLocation `json:"user_location,omitempty"`
 }
 
+type ClaudeComputerTool struct {
+	Type            string `json:"type"`
+	Name            string `json:"name"`
+	DisplayWidthPx  int    `json:"display_width_px"`
+	DisplayHeightPx int    `json:"display_height_px"`
+	DisplayNumber   *int   `json:"display_number,omitempty"`
+}
+
 type ClaudeWebSearchUserLocation struct {
 	Type     string `json:"type"`
 	Timezone string `json:"timezone,omitempty"`
@@ -271,6 +271,14 @@ type ToolCallRequest struct {
 	Type     string          `json:"type"`
 	Function FunctionRequest `json:"function,omitempty"`
 	Custom   json.RawMessage `json:"custom,omitempty"`
+	// ComputerUsePreview 计算机操作工具的屏幕参数，仅 type 为 computer_use_preview 时存在
+	ComputerUsePreview *ComputerUseTool `json:"computer_use_preview,omitempty"`
+}
+
+type ComputerUseTool struct {
+	DisplayWidth  int    `json:"display_width"`
+	DisplayHeight int    `json:"display_height"`
+	Environment   string `json:"environment,omitempty"`
 }
 
 type FunctionRequest struct {
@@ -321,9 +329,12 @@ type Message struct {
 	Prefix           *bool           `json:"prefix,omitempty"`
 	ReasoningContent string          `json:"reasoning_content,omitempty"`
 	Reasoning        string          `json:"reasoning,omitempty"`
+	Refusal          string          `json:"refusal,omitempty"`
 	ToolCalls        json.RawMessage `json:"tool_calls,omitempty"`
 	ToolCallId       string          `json:"tool_call_id,omitempty"`
-	parsedContent    []MediaContent
+	// Annotations 响应消息中的引用标注（例如联网搜索的 url_citation）
+	Annotations   []any `json:"annotations,omitempty"`
+	parsedContent []MediaContent
 	//parsedStringContent *string
 }
 
@@ -557,10 +568,14 @@ func (m *Message) ParseContent() []MediaContent {
 		switch contentType {
 		case ContentTypeText:
 			if text, ok := contentItem["text"].(string); ok {
-				contentList = append(contentList, MediaContent{
+				mediaContent := MediaContent{
 					Type: ContentTypeText,
 					Text: text,
-				})
+				}
+				if cacheControl, ok := contentItem["cache_control"]; ok && cacheControl != nil {
+					mediaContent.CacheControl, _ = common.Marshal(cacheControl)
+				}
+				contentList = append(contentList, mediaContent)
 			}
 
 		case ContentTypeImageURL:
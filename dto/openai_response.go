@@ -33,6 +33,7 @@ type TextResponse struct {
 type OpenAITextResponseChoice struct {
 	Index        int `json:"index"`
 	Message      `json:"message"`
+	Logprobs     *any   `json:"logprobs,omitempty"`
 	FinishReason string `json:"finish_reason"`
 }
 
@@ -43,7 +44,19 @@ type OpenAITextResponse struct {
 	Created any                        `json:"created"`
 	Choices []OpenAITextResponseChoice `json:"choices"`
 	Error   any                        `json:"error,omitempty"`
-	Usage   `json:"usage"`
+	// ServiceTier 上游实际提供的服务层级
+	ServiceTier string `json:"service_tier,omitempty"`
+	// Citations 与 SearchResults 为联网搜索引用的来源（Perplexity），正文以 [1]、[2] 标注
+	Citations     []string           `json:"citations,omitempty"`
+	SearchResults []ChatSearchResult `json:"search_results,omitempty"`
+	Usage         `json:"usage"`
+}
+
+// ChatSearchResult 联网搜索引用的来源
+type ChatSearchResult struct {
+	Title string `json:"title"`
+	Url   string `json:"url"`
+	Date  string `json:"date,omitempty"`
 }
 
 // GetOpenAIError 从动态错误类型中提取OpenAIError结构
@@ -90,6 +103,7 @@ type ChatCompletionsStreamResponseChoiceDelta struct {
 	Reasoning        *string            `json:"reasoning,omitempty"`
 	Role             string             `json:"role,omitempty"`
 	ToolCalls        []ToolCallResponse `json:"tool_calls,omitempty"`
+	Annotations      []any              `json:"annotations,omitempty"`
 }
 
 func (c *ChatCompletionsStreamResponseChoiceDelta) SetContentString(s string) {
@@ -145,6 +159,9 @@ type ChatCompletionsStreamResponse struct {
 	Model             string                                `json:"model"`
 	SystemFingerprint *string                               `json:"system_fingerprint"`
 	Choices           []ChatCompletionsStreamResponseChoice `json:"choices"`
+	ServiceTier       string                                `json:"service_tier,omitempty"`
+	Citations         []string                              `json:"citations,omitempty"`
+	SearchResults     []ChatSearchResult                    `json:"search_results,omitempty"`
 	Usage             *Usage                                `json:"usage"`
 }
 
@@ -230,6 +247,8 @@ type Usage struct {
 	InputTokens            int                `json:"input_tokens"`
 	OutputTokens           int                `json:"output_tokens"`
 	InputTokensDetails     *InputTokenDetails `json:"input_tokens_details"`
+	// Responses API output details, only set on usage in the Responses format
+	OutputTokensDetails *OutputTokenDetails `json:"output_tokens_details,omitempty"`
 
 	// claude cache 1h
 	ClaudeCacheCreation5mTokens int `json:"claude_cache_creation_5_m_tokens"`
@@ -279,10 +298,12 @@ type OpenAIResponsesResponse struct {
 	Reasoning          *Reasoning         `json:"reasoning"`
 	Store              bool               `json:"store"`
 	Temperature        float64            `json:"temperature"`
+	Text               json.RawMessage    `json:"text,omitempty"`
 	ToolChoice         json.RawMessage    `json:"tool_choice"`
 	Tools              []map[string]any   `json:"tools"`
 	TopP               float64            `json:"top_p"`
 	Truncation         json.RawMessage    `json:"truncation"`
+	ServiceTier        string             `json:"service_tier,omitempty"`
 	Usage              *Usage             `json:"usage"`
 	User               json.RawMessage    `json:"user"`
 	Metadata           json.RawMessage    `json:"metadata"`
@@ -344,12 +365,33 @@ type ResponsesOutput struct {
 	CallId    string                   `json:"call_id,omitempty"`
 	Name      string                   `json:"name,omitempty"`
 	Arguments string                   `json:"arguments,omitempty"`
+	// computer_call
+	Action              json.RawMessage `json:"action,omitempty"`
+	PendingSafetyChecks []any           `json:"pending_safety_checks,omitempty"`
+	// file_search_call
+	Queries []string                    `json:"queries,omitempty"`
+	Results []ResponsesFileSearchResult `json:"results,omitempty"`
+	// reasoning
+	Summary []ResponsesReasoningSummaryPart `json:"summary,omitempty"`
+	// image_generation_call
+	Result        string `json:"result,omitempty"` // base64 encoded image
+	OutputFormat  string `json:"output_format,omitempty"`
+	RevisedPrompt string `json:"revised_prompt,omitempty"`
+}
+
+type ResponsesFileSearchResult struct {
+	FileId     string         `json:"file_id"`
+	Filename   string         `json:"filename"`
+	Score      float64        `json:"score"`
+	Text       string         `json:"text"`
+	Attributes map[string]any `json:"attributes"`
 }
 
 type ResponsesOutputContent struct {
 	Type        string        `json:"type"`
 	Text        string        `json:"text"`
 	Annotations []interface{} `json:"annotations"`
+	Logprobs    []any         `json:"logprobs,omitempty"`
 }
 
 type ResponsesReasoningSummaryPart struct {
@@ -384,6 +426,8 @@ type ResponsesStreamResponse struct {
 	SummaryIndex *int                           `json:"summary_index,omitempty"`
 	ItemID       string                         `json:"item_id,omitempty"`
 	Part         *ResponsesReasoningSummaryPart `json:"part,omitempty"`
+	// - response.output_text.delta (when include lists message.output_text.logprobs)
+	Logprobs []any `json:"logprobs,omitempty"`
 }
 
 // GetOpenAIError 从动态错误类型中提取OpenAIError结构
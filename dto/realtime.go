@@ -19,6 +19,9 @@ const (
 	RealtimeEventResponseFunctionCallArgumentsDelta = "response.function_call_arguments.delta"
 	RealtimeEventResponseFunctionCallArgumentsDone  = "response.function_call_arguments.done"
 	RealtimeEventConversationItemCreated            = "conversation.item.created"
+	// GA 版本 Realtime API 将音频输出事件重命名为 output_audio
+	RealtimeEventResponseOutputAudioDelta           = "response.output_audio.delta"
+	RealtimeEventResponseOutputAudioTranscriptDelta = "response.output_audio_transcript.delta"
 )
 
 type RealtimeEvent struct {
@@ -38,11 +41,39 @@ type RealtimeResponse struct {
 }
 
 type RealtimeUsage struct {
-	TotalTokens        int                `json:"total_tokens"`
-	InputTokens        int                `json:"input_tokens"`
-	OutputTokens       int                `json:"output_tokens"`
-	InputTokenDetails  InputTokenDetails  `json:"input_token_details"`
-	OutputTokenDetails OutputTokenDetails `json:"output_token_details"`
+	TotalTokens        int                       `json:"total_tokens"`
+	InputTokens        int                       `json:"input_tokens"`
+	OutputTokens       int                       `json:"output_tokens"`
+	InputTokenDetails  RealtimeInputTokenDetails `json:"input_token_details"`
+	OutputTokenDetails OutputTokenDetails        `json:"output_token_details"`
+}
+
+// Add 累加另一段用量，包括缓存命中的文本/音频拆分
+func (u *RealtimeUsage) Add(other *RealtimeUsage) {
+	if other == nil {
+		return
+	}
+	u.TotalTokens += other.TotalTokens
+	u.InputTokens += other.InputTokens
+	u.OutputTokens += other.OutputTokens
+	u.InputTokenDetails.CachedTokens += other.InputTokenDetails.CachedTokens
+	u.InputTokenDetails.TextTokens += other.InputTokenDetails.TextTokens
+	u.InputTokenDetails.AudioTokens += other.InputTokenDetails.AudioTokens
+	u.InputTokenDetails.CachedTokensDetails.TextTokens += other.InputTokenDetails.CachedTokensDetails.TextTokens
+	u.InputTokenDetails.CachedTokensDetails.AudioTokens += other.InputTokenDetails.CachedTokensDetails.AudioTokens
+	u.OutputTokenDetails.TextTokens += other.OutputTokenDetails.TextTokens
+	u.OutputTokenDetails.AudioTokens += other.OutputTokenDetails.AudioTokens
+}
+
+// RealtimeInputTokenDetails 在通用输入明细之上补充缓存命中 token 的文本/音频拆分
+type RealtimeInputTokenDetails struct {
+	InputTokenDetails
+	CachedTokensDetails RealtimeCachedTokensDetails `json:"cached_tokens_details"`
+}
+
+type RealtimeCachedTokensDetails struct {
+	TextTokens  int `json:"text_tokens"`
+	AudioTokens int `json:"audio_tokens"`
 }
 
 type RealtimeSession struct {
@@ -54,11 +85,42 @@ type RealtimeSession struct {
 	InputAudioTranscription InputAudioTranscription `json:"input_audio_transcription"`
 	TurnDetection           interface{}             `json:"turn_detection"`
 	Tools                   []RealTimeTool          `json:"tools"`
-	ToolChoice              string                  `json:"tool_choice"`
+	ToolChoice              any                     `json:"tool_choice"`
 	Temperature             float64                 `json:"temperature"`
+	Audio                   *RealtimeSessionAudio   `json:"audio,omitempty"`
 	//MaxResponseOutputTokens int                     `json:"max_response_output_tokens"`
 }
 
+// RealtimeSessionAudio GA 版本的会话音频配置，取代 beta 版本的 input_audio_format/output_audio_format
+type RealtimeSessionAudio struct {
+	Input  *RealtimeAudioConfig `json:"input,omitempty"`
+	Output *RealtimeAudioConfig `json:"output,omitempty"`
+}
+
+type RealtimeAudioConfig struct {
+	Format *RealtimeAudioFormat `json:"format,omitempty"`
+}
+
+type RealtimeAudioFormat struct {
+	Type string `json:"type"`
+	Rate int    `json:"rate,omitempty"`
+}
+
+// AudioFormats 返回会话声明的输入/输出音频格式，兼容 beta 与 GA 两种会话结构，未声明时返回空字符串
+func (s *RealtimeSession) AudioFormats() (input string, output string) {
+	input, output = s.InputAudioFormat, s.OutputAudioFormat
+	if s.Audio == nil {
+		return input, output
+	}
+	if s.Audio.Input != nil && s.Audio.Input.Format != nil && s.Audio.Input.Format.Type != "" {
+		input = s.Audio.Input.Format.Type
+	}
+	if s.Audio.Output != nil && s.Audio.Output.Format != nil && s.Audio.Output.Format.Type != "" {
+		output = s.Audio.Output.Format.Type
+	}
+	return input, output
+}
+
 type InputAudioTranscription struct {
 	Model string `json:"model"`
 }
@@ -0,0 +1,53 @@
+package dto
+
+import (
+	"testing"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRealtimeUsageCachedDetails(t *testing.T) {
+	raw := []byte(`{
+		"type":"response.done",
+		"response":{"usage":{
+			"total_tokens":300,"input_tokens":200,"output_tokens":100,
+			"input_token_details":{"cached_tokens":120,"text_tokens":80,"audio_tokens":120,
+				"cached_tokens_details":{"text_tokens":40,"audio_tokens":80}},
+			"output_token_details":{"text_tokens":20,"audio_tokens":80}
+		}}
+	}`)
+
+	var event RealtimeEvent
+	require.NoError(t, common.Unmarshal(raw, &event))
+	require.NotNil(t, event.Response.Usage)
+
+	total := &RealtimeUsage{}
+	total.Add(event.Response.Usage)
+	total.Add(event.Response.Usage)
+	assert.Equal(t, 600, total.TotalTokens)
+	assert.Equal(t, 240, total.InputTokenDetails.CachedTokens)
+	assert.Equal(t, 160, total.InputTokenDetails.TextTokens)
+	assert.Equal(t, 80, total.InputTokenDetails.CachedTokensDetails.TextTokens)
+	assert.Equal(t, 160, total.InputTokenDetails.CachedTokensDetails.AudioTokens)
+	assert.Equal(t, 160, total.OutputTokenDetails.AudioTokens)
+}
+
+func TestRealtimeSessionAudioFormats(t *testing.T) {
+	var beta RealtimeSession
+	require.NoError(t, common.Unmarshal([]byte(`{"input_audio_format":"g711_ulaw","output_audio_format":"pcm16","tool_choice":"auto"}`), &beta))
+	input, output := beta.AudioFormats()
+	assert.Equal(t, "g711_ulaw", input)
+	assert.Equal(t, "pcm16", output)
+
+	var ga RealtimeSession
+	require.NoError(t, common.Unmarshal([]byte(`{
+		"type":"realtime",
+		"audio":{"input":{"format":{"type":"audio/pcmu"}},"output":{"format":{"type":"audio/pcm","rate":24000}}},
+		"tool_choice":{"type":"function","name":"lookup"}
+	}`), &ga))
+	input, output = ga.AudioFormats()
+	assert.Equal(t, "audio/pcmu", input)
+	assert.Equal(t, "audio/pcm", output)
+}
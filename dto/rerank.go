@@ -13,11 +13,21 @@ type RerankRequest struct {
 	Query           string `json:"query"`
 	Model           string `json:"model"`
 	TopN            *int   `json:"top_n,omitempty"`
+	TopK            *int   `json:"top_k,omitempty"` // Voyage 风格的 top_n，转发前统一为 top_n
 	ReturnDocuments *bool  `json:"return_documents,omitempty"`
+	Truncation      *bool  `json:"truncation,omitempty"` // 仅 Voyage 支持，其他上游忽略
 	MaxChunkPerDoc  *int   `json:"max_chunk_per_doc,omitempty"`
 	OverLapTokens   *int   `json:"overlap_tokens,omitempty"`
 }
 
+// NormalizeTopN 将 top_k 合并到 top_n，两者都传时以 top_n 为准
+func (r *RerankRequest) NormalizeTopN() {
+	if r.TopN == nil {
+		r.TopN = r.TopK
+	}
+	r.TopK = nil
+}
+
 func (r *RerankRequest) IsStream(c *gin.Context) bool {
 	return false
 }
@@ -0,0 +1,39 @@
+package dto
+
+// TemporaryTokenRequest POST /v1/tokens/temporary 由当前令牌签发临时令牌
+type TemporaryTokenRequest struct {
+	Name      string   `json:"name,omitempty"`
+	ExpiresIn int64    `json:"expires_in,omitempty"` // 有效期（秒），留空时使用默认有效期
+	Models    []string `json:"models,omitempty"`     // 允许使用的模型，须为父令牌可用模型的子集，留空时继承父令牌的模型限制
+	Quota     *int     `json:"quota,omitempty"`      // 可消耗的额度上限，从父令牌中预留，留空时使用默认额度
+}
+
+type TemporaryTokenResponse struct {
+	Object    string   `json:"object"`
+	Id        int      `json:"id"`
+	Key       string   `json:"key"`
+	Name      string   `json:"name"`
+	ParentId  int      `json:"parent_id"`
+	Models    []string `json:"models"`
+	Quota     int      `json:"quota"`
+	CreatedAt int64    `json:"created_at"`
+	ExpiresAt int64    `json:"expires_at"`
+}
+
+// RealtimeClientSecretRequest POST /v1/realtime/client_secrets，与 OpenAI 兼容，quota 为网关的扩展字段
+type RealtimeClientSecretRequest struct {
+	ExpiresAfter *RealtimeClientSecretExpiresAfter `json:"expires_after,omitempty"`
+	Session      map[string]any                    `json:"session,omitempty"`
+	Quota        *int                              `json:"quota,omitempty"`
+}
+
+type RealtimeClientSecretExpiresAfter struct {
+	Anchor  string `json:"anchor,omitempty"`
+	Seconds int64  `json:"seconds,omitempty"`
+}
+
+type RealtimeClientSecretResponse struct {
+	Value     string         `json:"value"`
+	ExpiresAt int64          `json:"expires_at"`
+	Session   map[string]any `json:"session"`
+}
@@ -0,0 +1,18 @@
+package dto
+
+// TokenCountResponse POST /v1/token/count 的响应：网关对请求提示词 token 数的估算
+type TokenCountResponse struct {
+	Object      string              `json:"object"` // 固定为 token_count
+	Model       string              `json:"model"`
+	Tokenizer   string              `json:"tokenizer"` // tiktoken，或按厂商估算的 estimate:<厂商>
+	InputTokens int                 `json:"input_tokens"`
+	Messages    []TokenCountMessage `json:"messages"`
+}
+
+// TokenCountMessage 单条消息（Responses 请求为 input 中的单个条目）的 token 数
+type TokenCountMessage struct {
+	Index  int    `json:"index"`
+	Type   string `json:"type,omitempty"`
+	Role   string `json:"role,omitempty"`
+	Tokens int    `json:"tokens"`
+}
@@ -0,0 +1,129 @@
+package dto
+
+import "encoding/json"
+
+type FileObject struct {
+	Id        string `json:"id"`
+	Object    string `json:"object"`
+	Bytes     int64  `json:"bytes"`
+	CreatedAt int64  `json:"created_at"`
+	Filename  string `json:"filename"`
+	Purpose   string `json:"purpose"`
+}
+
+type ChunkingStrategy struct {
+	Type   string                  `json:"type"`
+	Static *StaticChunkingStrategy `json:"static,omitempty"`
+}
+
+type StaticChunkingStrategy struct {
+	MaxChunkSizeTokens int `json:"max_chunk_size_tokens"`
+	ChunkOverlapTokens int `json:"chunk_overlap_tokens"`
+}
+
+type VectorStoreExpiresAfter struct {
+	Anchor string `json:"anchor"`
+	Days   int    `json:"days"`
+}
+
+type VectorStoreCreateRequest struct {
+	Name             string                   `json:"name,omitempty"`
+	FileIds          []string                 `json:"file_ids,omitempty"`
+	ExpiresAfter     *VectorStoreExpiresAfter `json:"expires_after,omitempty"`
+	ChunkingStrategy *ChunkingStrategy        `json:"chunking_strategy,omitempty"`
+	Metadata         map[string]string        `json:"metadata,omitempty"`
+}
+
+type VectorStoreUpdateRequest struct {
+	Name         *string                  `json:"name,omitempty"`
+	ExpiresAfter *VectorStoreExpiresAfter `json:"expires_after,omitempty"`
+	Metadata     map[string]string        `json:"metadata,omitempty"`
+}
+
+type VectorStoreFileCreateRequest struct {
+	FileId           string            `json:"file_id"`
+	ChunkingStrategy *ChunkingStrategy `json:"chunking_strategy,omitempty"`
+	Attributes       map[string]any    `json:"attributes,omitempty"`
+}
+
+type VectorStoreFileCounts struct {
+	InProgress int `json:"in_progress"`
+	Completed  int `json:"completed"`
+	Failed     int `json:"failed"`
+	Cancelled  int `json:"cancelled"`
+	Total      int `json:"total"`
+}
+
+type VectorStoreObject struct {
+	Id           string                   `json:"id"`
+	Object       string                   `json:"object"`
+	CreatedAt    int64                    `json:"created_at"`
+	Name         string                   `json:"name"`
+	UsageBytes   int64                    `json:"usage_bytes"`
+	FileCounts   VectorStoreFileCounts    `json:"file_counts"`
+	Status       string                   `json:"status"`
+	ExpiresAfter *VectorStoreExpiresAfter `json:"expires_after"`
+	ExpiresAt    *int64                   `json:"expires_at"`
+	LastActiveAt int64                    `json:"last_active_at"`
+	Metadata     map[string]string        `json:"metadata"`
+}
+
+type VectorStoreFileError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+type VectorStoreFileObject struct {
+	Id               string                `json:"id"`
+	Object           string                `json:"object"`
+	UsageBytes       int64                 `json:"usage_bytes"`
+	CreatedAt        int64                 `json:"created_at"`
+	VectorStoreId    string                `json:"vector_store_id"`
+	Status           string                `json:"status"`
+	LastError        *VectorStoreFileError `json:"last_error"`
+	ChunkingStrategy ChunkingStrategy      `json:"chunking_strategy"`
+	Attributes       map[string]any        `json:"attributes"`
+}
+
+type VectorStoreRankingOptions struct {
+	Ranker         string  `json:"ranker,omitempty"`
+	ScoreThreshold float64 `json:"score_threshold,omitempty"`
+}
+
+type VectorStoreSearchRequest struct {
+	Query          json.RawMessage            `json:"query"` // string 或 string 数组
+	MaxNumResults  int                        `json:"max_num_results,omitempty"`
+	RankingOptions *VectorStoreRankingOptions `json:"ranking_options,omitempty"`
+	RewriteQuery   bool                       `json:"rewrite_query,omitempty"`
+}
+
+type VectorStoreSearchContent struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+}
+
+type VectorStoreSearchResult struct {
+	FileId        string                     `json:"file_id"`
+	Filename      string                     `json:"filename"`
+	Score         float64                    `json:"score"`
+	Attributes    map[string]any             `json:"attributes"`
+	Content       []VectorStoreSearchContent `json:"content"`
+	VectorStoreId string                     `json:"-"`
+}
+
+type VectorStoreSearchResponse struct {
+	Object      string                    `json:"object"`
+	SearchQuery string                    `json:"search_query"`
+	Data        []VectorStoreSearchResult `json:"data"`
+	HasMore     bool                      `json:"has_more"`
+	NextPage    *string                   `json:"next_page"`
+}
+
+// ListResponse OpenAI 风格的分页列表
+type ListResponse[T any] struct {
+	Object  string `json:"object"`
+	Data    []T    `json:"data"`
+	FirstId string `json:"first_id,omitempty"`
+	LastId  string `json:"last_id,omitempty"`
+	HasMore bool   `json:"has_more"`
+}
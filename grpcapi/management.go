@@ -0,0 +1,309 @@
+package grpcapi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/model"
+	managementv1 "github.com/QuantumNous/new-api/proto/management/v1"
+	"github.com/QuantumNous/new-api/service"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"gorm.io/gorm"
+)
+
+const maxManagementPageSize = 100
+
+// managementServer 实现 ManagementService，行为与管理后台对应的 HTTP 接口一致
+type managementServer struct {
+	managementv1.UnimplementedManagementServiceServer
+}
+
+func (s *managementServer) ListChannels(ctx context.Context, req *managementv1.ListChannelsRequest) (*managementv1.ListChannelsResponse, error) {
+	startIdx, pageSize := pageRange(req.GetPage(), req.GetPageSize())
+	channels, err := model.GetAllChannels(startIdx, pageSize, false, false)
+	if err != nil {
+		return nil, internalError(err)
+	}
+	total, err := model.CountAllChannels()
+	if err != nil {
+		return nil, internalError(err)
+	}
+	resp := &managementv1.ListChannelsResponse{
+		Channels: make([]*managementv1.Channel, 0, len(channels)),
+		Total:    total,
+	}
+	for _, channel := range channels {
+		resp.Channels = append(resp.Channels, toChannelMessage(channel))
+	}
+	return resp, nil
+}
+
+func (s *managementServer) GetChannel(ctx context.Context, req *managementv1.GetChannelRequest) (*managementv1.Channel, error) {
+	channel, err := getChannel(req.GetId())
+	if err != nil {
+		return nil, err
+	}
+	return toChannelMessage(channel), nil
+}
+
+func (s *managementServer) UpdateChannelStatus(ctx context.Context, req *managementv1.UpdateChannelStatusRequest) (*managementv1.Channel, error) {
+	channel, err := getChannel(req.GetId())
+	if err != nil {
+		return nil, err
+	}
+	admin := adminFromContext(ctx)
+	switch req.GetStatus() {
+	case common.ChannelStatusEnabled:
+		service.EnableChannel(channel.Id, "", channel.Name)
+		model.RecordLog(admin.Id, model.LogTypeManage, fmt.Sprintf("通过 gRPC 管理接口启用渠道 #%d %s", channel.Id, channel.Name))
+	case common.ChannelStatusManuallyDisabled:
+		model.UpdateChannelStatus(channel.Id, "", common.ChannelStatusManuallyDisabled, "通过 gRPC 管理接口手动禁用")
+		model.RecordLog(admin.Id, model.LogTypeManage, fmt.Sprintf("通过 gRPC 管理接口禁用渠道 #%d %s", channel.Id, channel.Name))
+	default:
+		return nil, status.Error(codes.InvalidArgument, "status must be 1 (enabled) or 2 (manually disabled)")
+	}
+	return s.GetChannel(ctx, &managementv1.GetChannelRequest{Id: req.GetId()})
+}
+
+func (s *managementServer) ListTokens(ctx context.Context, req *managementv1.ListTokensRequest) (*managementv1.ListTokensResponse, error) {
+	userId := int(req.GetUserId())
+	if userId <= 0 {
+		return nil, status.Error(codes.InvalidArgument, "user_id is required")
+	}
+	if _, err := authorizeTokenOwner(ctx, userId); err != nil {
+		return nil, err
+	}
+	startIdx, pageSize := pageRange(req.GetPage(), req.GetPageSize())
+	tokens, err := model.GetAllUserTokens(userId, startIdx, pageSize)
+	if err != nil {
+		return nil, internalError(err)
+	}
+	total, err := model.CountUserTokens(userId)
+	if err != nil {
+		return nil, internalError(err)
+	}
+	resp := &managementv1.ListTokensResponse{
+		Tokens: make([]*managementv1.Token, 0, len(tokens)),
+		Total:  total,
+	}
+	for _, token := range tokens {
+		resp.Tokens = append(resp.Tokens, toTokenMessage(token))
+	}
+	return resp, nil
+}
+
+func (s *managementServer) CreateToken(ctx context.Context, req *managementv1.CreateTokenRequest) (*managementv1.CreateTokenResponse, error) {
+	userId := int(req.GetUserId())
+	if userId <= 0 {
+		return nil, status.Error(codes.InvalidArgument, "user_id is required")
+	}
+	owner, err := authorizeTokenOwner(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+	if len(req.GetName()) > 50 {
+		return nil, status.Error(codes.InvalidArgument, "name must not exceed 50 characters")
+	}
+	// 与令牌鉴权一致，分组需对令牌所属用户可用
+	if err := service.CheckTokenGroup(owner.Group, req.GetGroup()); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	if tenant := model.GetCachedTenant(owner.TenantId); tenant != nil && req.GetGroup() != "" && !tenant.OwnsGroup(req.GetGroup()) {
+		return nil, status.Errorf(codes.InvalidArgument, "group %s does not belong to the user's tenant", req.GetGroup())
+	}
+	maxQuotaValue := int64(1000000000 * common.QuotaPerUnit)
+	if !req.GetUnlimitedQuota() && (req.GetRemainQuota() < 0 || req.GetRemainQuota() > maxQuotaValue) {
+		return nil, status.Errorf(codes.InvalidArgument, "remain_quota must be between 0 and %d", maxQuotaValue)
+	}
+	count, err := model.CountUserTokens(userId)
+	if err != nil {
+		return nil, internalError(err)
+	}
+	if maxTokens := operation_setting.GetMaxUserTokens(); int(count) >= maxTokens {
+		return nil, status.Errorf(codes.FailedPrecondition, "user has reached the maximum number of tokens (%d)", maxTokens)
+	}
+	key, err := common.GenerateKey()
+	if err != nil {
+		return nil, internalError(err)
+	}
+	expiredTime := req.GetExpiredTime()
+	if expiredTime == 0 {
+		expiredTime = -1
+	}
+	token := &model.Token{
+		UserId:             userId,
+		Name:               req.GetName(),
+		Key:                key,
+		CreatedTime:        common.GetTimestamp(),
+		AccessedTime:       common.GetTimestamp(),
+		ExpiredTime:        expiredTime,
+		RemainQuota:        int(req.GetRemainQuota()),
+		UnlimitedQuota:     req.GetUnlimitedQuota(),
+		ModelLimitsEnabled: len(req.GetModelLimits()) > 0,
+		ModelLimits:        strings.Join(req.GetModelLimits(), ","),
+		Group:              req.GetGroup(),
+	}
+	if err := token.Insert(); err != nil {
+		return nil, internalError(err)
+	}
+	model.RecordLog(adminFromContext(ctx).Id, model.LogTypeManage, fmt.Sprintf("通过 gRPC 管理接口为用户 #%d 创建令牌 #%d %s", userId, token.Id, token.Name))
+	return &managementv1.CreateTokenResponse{
+		Token: toTokenMessage(token),
+		Key:   "sk-" + key,
+	}, nil
+}
+
+func (s *managementServer) UpdateTokenStatus(ctx context.Context, req *managementv1.UpdateTokenStatusRequest) (*managementv1.Token, error) {
+	if req.GetId() <= 0 || req.GetUserId() <= 0 {
+		return nil, status.Error(codes.InvalidArgument, "id and user_id are required")
+	}
+	if _, err := authorizeTokenOwner(ctx, int(req.GetUserId())); err != nil {
+		return nil, err
+	}
+	token, err := model.GetTokenByIds(int(req.GetId()), int(req.GetUserId()))
+	if err != nil {
+		return nil, notFoundOrInternal(err, "token not found")
+	}
+	switch req.GetStatus() {
+	case common.TokenStatusEnabled:
+		// 与 HTTP 接口一致：已过期或额度用尽的令牌需先调整过期时间或额度
+		if token.Status == common.TokenStatusExpired && token.ExpiredTime <= common.GetTimestamp() && token.ExpiredTime != -1 {
+			return nil, status.Error(codes.FailedPrecondition, "token has expired")
+		}
+		if token.Status == common.TokenStatusExhausted && token.RemainQuota <= 0 && !token.UnlimitedQuota {
+			return nil, status.Error(codes.FailedPrecondition, "token quota is exhausted")
+		}
+	case common.TokenStatusDisabled:
+	default:
+		return nil, status.Error(codes.InvalidArgument, "status must be 1 (enabled) or 2 (disabled)")
+	}
+	token.Status = int(req.GetStatus())
+	if err := token.SelectUpdate(); err != nil {
+		return nil, internalError(err)
+	}
+	action := "启用"
+	if token.Status == common.TokenStatusDisabled {
+		action = "禁用"
+	}
+	model.RecordLog(adminFromContext(ctx).Id, model.LogTypeManage, fmt.Sprintf("通过 gRPC 管理接口%s用户 #%d 的令牌 #%d %s", action, token.UserId, token.Id, token.Name))
+	return toTokenMessage(token), nil
+}
+
+func (s *managementServer) DeleteToken(ctx context.Context, req *managementv1.DeleteTokenRequest) (*managementv1.DeleteTokenResponse, error) {
+	if req.GetId() <= 0 || req.GetUserId() <= 0 {
+		return nil, status.Error(codes.InvalidArgument, "id and user_id are required")
+	}
+	if _, err := authorizeTokenOwner(ctx, int(req.GetUserId())); err != nil {
+		return nil, err
+	}
+	if err := model.DeleteTokenById(int(req.GetId()), int(req.GetUserId())); err != nil {
+		return nil, notFoundOrInternal(err, "token not found")
+	}
+	model.RecordLog(adminFromContext(ctx).Id, model.LogTypeManage, fmt.Sprintf("通过 gRPC 管理接口删除用户 #%d 的令牌 #%d", req.GetUserId(), req.GetId()))
+	return &managementv1.DeleteTokenResponse{}, nil
+}
+
+func (s *managementServer) GetUsage(ctx context.Context, req *managementv1.GetUsageRequest) (*managementv1.GetUsageResponse, error) {
+	stat, err := model.SumUsedQuota(model.LogTypeConsume, req.GetStartTimestamp(), req.GetEndTimestamp(), req.GetModelName(),
+		req.GetUsername(), req.GetTokenName(), int(req.GetChannelId()), req.GetGroup())
+	if err != nil {
+		return nil, internalError(err)
+	}
+	return &managementv1.GetUsageResponse{
+		Quota: int64(stat.Quota),
+		Rpm:   int64(stat.Rpm),
+		Tpm:   int64(stat.Tpm),
+	}, nil
+}
+
+func getChannel(id int64) (*model.Channel, error) {
+	channel, err := model.GetChannelById(int(id), false)
+	if err != nil {
+		return nil, notFoundOrInternal(err, "channel not found")
+	}
+	return channel, nil
+}
+
+// authorizeTokenOwner 返回令牌所属的用户，与 HTTP 用户管理接口一致，管理员只能管理角色低于自己的用户，超级管理员不受限制
+func authorizeTokenOwner(ctx context.Context, userId int) (*model.User, error) {
+	owner, err := model.GetUserById(userId, false)
+	if err != nil {
+		return nil, notFoundOrInternal(err, "user not found")
+	}
+	if !canManageUser(adminFromContext(ctx).Role, owner.Role) {
+		return nil, status.Error(codes.PermissionDenied, "no permission to manage tokens of a user with the same or higher role")
+	}
+	return owner, nil
+}
+
+func canManageUser(adminRole int, targetRole int) bool {
+	return adminRole > targetRole || adminRole == common.RoleRootUser
+}
+
+// pageRange 将从 1 开始的页码与页大小转换为偏移量，页大小默认 ItemsPerPage，最大 100
+func pageRange(page int32, pageSize int32) (int, int) {
+	size := int(pageSize)
+	if size <= 0 {
+		size = common.ItemsPerPage
+	}
+	size = min(size, maxManagementPageSize)
+	return (max(int(page), 1) - 1) * size, size
+}
+
+// notFoundOrInternal 将记录不存在转换为 NotFound，其他错误为 Internal
+func notFoundOrInternal(err error, message string) error {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return status.Error(codes.NotFound, message)
+	}
+	return internalError(err)
+}
+
+func internalError(err error) error {
+	common.SysError("gRPC management API error: " + err.Error())
+	return status.Error(codes.Internal, err.Error())
+}
+
+func toChannelMessage(channel *model.Channel) *managementv1.Channel {
+	return &managementv1.Channel{
+		Id:           int64(channel.Id),
+		Type:         int32(channel.Type),
+		Name:         channel.Name,
+		Status:       int32(channel.Status),
+		Group:        channel.Group,
+		Models:       channel.GetModels(),
+		BaseUrl:      channel.GetBaseURL(),
+		Priority:     channel.GetPriority(),
+		Weight:       uint32(channel.GetWeight()),
+		Tag:          channel.GetTag(),
+		UsedQuota:    channel.UsedQuota,
+		Balance:      channel.Balance,
+		ResponseTime: int64(channel.ResponseTime),
+		CreatedTime:  channel.CreatedTime,
+		TestTime:     channel.TestTime,
+	}
+}
+
+func toTokenMessage(token *model.Token) *managementv1.Token {
+	return &managementv1.Token{
+		Id:                 int64(token.Id),
+		UserId:             int64(token.UserId),
+		Name:               token.Name,
+		Key:                token.GetMaskedKey(),
+		Status:             int32(token.Status),
+		RemainQuota:        int64(token.RemainQuota),
+		UsedQuota:          int64(token.UsedQuota),
+		UnlimitedQuota:     token.UnlimitedQuota,
+		ExpiredTime:        token.ExpiredTime,
+		Group:              token.Group,
+		ModelLimitsEnabled: token.ModelLimitsEnabled,
+		ModelLimits:        token.GetModelLimits(),
+		CreatedTime:        token.CreatedTime,
+		AccessedTime:       token.AccessedTime,
+	}
+}
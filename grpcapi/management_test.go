@@ -0,0 +1,42 @@
+package grpcapi
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"gorm.io/gorm"
+)
+
+func TestPageRange(t *testing.T) {
+	start, size := pageRange(0, 0)
+	assert.Equal(t, 0, start)
+	assert.Equal(t, common.ItemsPerPage, size)
+
+	start, size = pageRange(3, 20)
+	assert.Equal(t, 40, start)
+	assert.Equal(t, 20, size)
+
+	start, size = pageRange(2, 1000)
+	assert.Equal(t, maxManagementPageSize, start)
+	assert.Equal(t, maxManagementPageSize, size)
+}
+
+func TestNotFoundOrInternal(t *testing.T) {
+	err := notFoundOrInternal(gorm.ErrRecordNotFound, "token not found")
+	assert.Equal(t, codes.NotFound, status.Code(err))
+	assert.Equal(t, "token not found", status.Convert(err).Message())
+
+	err = notFoundOrInternal(errors.New("connection refused"), "token not found")
+	assert.Equal(t, codes.Internal, status.Code(err))
+}
+
+func TestCanManageUser(t *testing.T) {
+	assert.True(t, canManageUser(common.RoleAdminUser, common.RoleCommonUser))
+	assert.False(t, canManageUser(common.RoleAdminUser, common.RoleAdminUser))
+	assert.False(t, canManageUser(common.RoleAdminUser, common.RoleRootUser))
+	assert.True(t, canManageUser(common.RoleRootUser, common.RoleRootUser))
+}
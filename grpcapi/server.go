@@ -0,0 +1,74 @@
+package grpcapi
+
+import (
+	"context"
+	"net"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/constant"
+	"github.com/QuantumNous/new-api/model"
+	managementv1 "github.com/QuantumNous/new-api/proto/management/v1"
+
+	"github.com/bytedance/gopkg/util/gopool"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/reflection"
+	"google.golang.org/grpc/status"
+)
+
+type adminContextKey struct{}
+
+// StartManagementServer 在 GRPC_MANAGEMENT_ADDR 上启动 gRPC 管理接口（proto/management/v1），未配置时不启动。
+// 接口开启了 server reflection，可直接用 grpcurl 等工具调用；传输层不加密，需要时由服务网格提供 mTLS
+func StartManagementServer() {
+	addr := constant.GrpcManagementAddr
+	if addr == "" {
+		return
+	}
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		common.FatalLog("failed to listen on gRPC management address: " + err.Error())
+	}
+	server := grpc.NewServer(grpc.UnaryInterceptor(adminAuthInterceptor))
+	managementv1.RegisterManagementServiceServer(server, &managementServer{})
+	reflection.Register(server)
+	gopool.Go(func() {
+		if err := server.Serve(listener); err != nil {
+			common.SysError("gRPC management server stopped: " + err.Error())
+		}
+	})
+	common.SysLog("gRPC management API listening on " + addr)
+}
+
+// adminAuthInterceptor 校验 metadata 中的系统访问令牌，只允许已启用的管理员调用，reflection 接口不需要认证
+func adminAuthInterceptor(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	if _, ok := info.Server.(*managementServer); !ok {
+		return handler(ctx, req)
+	}
+	md, _ := metadata.FromIncomingContext(ctx)
+	var accessToken string
+	if values := md.Get("authorization"); len(values) > 0 {
+		accessToken = values[0]
+	}
+	if accessToken == "" {
+		return nil, status.Error(codes.Unauthenticated, "missing access token in authorization metadata")
+	}
+	user := model.ValidateAccessToken(accessToken)
+	if user == nil {
+		return nil, status.Error(codes.Unauthenticated, "invalid access token")
+	}
+	if user.Status != common.UserStatusEnabled {
+		return nil, status.Error(codes.PermissionDenied, "user is disabled")
+	}
+	if user.Role < common.RoleAdminUser {
+		return nil, status.Error(codes.PermissionDenied, "admin access required")
+	}
+	return handler(context.WithValue(ctx, adminContextKey{}, user), req)
+}
+
+// adminFromContext 返回通过认证的管理员
+func adminFromContext(ctx context.Context) *model.User {
+	user, _ := ctx.Value(adminContextKey{}).(*model.User)
+	return user
+}
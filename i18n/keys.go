@@ -29,19 +29,30 @@ const (
 
 // Token related messages
 const (
-	MsgTokenNameTooLong          = "token.name_too_long"
-	MsgTokenQuotaNegative        = "token.quota_negative"
-	MsgTokenQuotaExceedMax       = "token.quota_exceed_max"
-	MsgTokenGenerateFailed       = "token.generate_failed"
-	MsgTokenGetInfoFailed        = "token.get_info_failed"
-	MsgTokenExpiredCannotEnable  = "token.expired_cannot_enable"
-	MsgTokenExhaustedCannotEable = "token.exhausted_cannot_enable"
-	MsgTokenInvalid              = "token.invalid"
-	MsgTokenNotProvided          = "token.not_provided"
-	MsgTokenExpired              = "token.expired"
-	MsgTokenExhausted            = "token.exhausted"
-	MsgTokenStatusUnavailable    = "token.status_unavailable"
-	MsgTokenDbError              = "token.db_error"
+	MsgTokenNameTooLong            = "token.name_too_long"
+	MsgTokenQuotaNegative          = "token.quota_negative"
+	MsgTokenQuotaExceedMax         = "token.quota_exceed_max"
+	MsgTokenGenerateFailed         = "token.generate_failed"
+	MsgTokenGetInfoFailed          = "token.get_info_failed"
+	MsgTokenExpiredCannotEnable    = "token.expired_cannot_enable"
+	MsgTokenExhaustedCannotEable   = "token.exhausted_cannot_enable"
+	MsgTokenInvalid                = "token.invalid"
+	MsgTokenNotProvided            = "token.not_provided"
+	MsgTokenExpired                = "token.expired"
+	MsgTokenExhausted              = "token.exhausted"
+	MsgTokenStatusUnavailable      = "token.status_unavailable"
+	MsgTokenDbError                = "token.db_error"
+	MsgTokenCompatModeInvalid      = "token.compat_mode_invalid"
+	MsgTokenContextOverflowInvalid = "token.context_overflow_invalid"
+	MsgTokenApiVersionInvalid      = "token.api_version_invalid"
+	MsgTokenClientProfileInvalid   = "token.client_profile_invalid"
+	MsgTokenDedupWindowInvalid     = "token.dedup_window_invalid"
+	MsgTokenRequestQuotaInvalid    = "token.request_quota_invalid"
+	MsgTokenQuotaPeriodInvalid     = "token.quota_reset_period_invalid"
+	MsgTokenCountryInvalid         = "token.country_invalid"
+	MsgTokenChannelLabelsInvalid   = "token.channel_labels_invalid"
+	MsgTokenCountryDenied          = "token.country_denied"
+	MsgTokenGeoIPUnavailable       = "token.geoip_unavailable"
 )
 
 // Redemption related messages
@@ -109,6 +120,12 @@ const (
 	MsgQuotaInsufficient    = "quota.insufficient"
 	MsgQuotaWarningInvalid  = "quota.warning_invalid"
 	MsgQuotaThresholdGtZero = "quota.threshold_gt_zero"
+
+	MsgQuotaUserInsufficient         = "quota.user_insufficient"
+	MsgQuotaUserPreConsumeFailed     = "quota.user_pre_consume_failed"
+	MsgQuotaTokenInsufficient        = "quota.token_insufficient"
+	MsgQuotaTokenRequestsExhausted   = "quota.token_requests_exhausted"
+	MsgQuotaSubscriptionInsufficient = "quota.subscription_insufficient"
 )
 
 // Subscription related messages
@@ -218,6 +235,9 @@ const (
 const (
 	MsgRateLimitReached      = "rate_limit.reached"
 	MsgRateLimitTotalReached = "rate_limit.total_reached"
+	MsgRateLimitCheckFailed  = "rate_limit.check_failed"
+	MsgRateLimitIPReached    = "rate_limit.ip_reached"
+	MsgRateLimitIPConcurrent = "rate_limit.ip_concurrent"
 )
 
 // Setting related messages
@@ -302,6 +322,16 @@ const (
 	MsgDistributorNoAvailableChannel  = "distributor.no_available_channel"
 	MsgDistributorInvalidMidjourney   = "distributor.invalid_midjourney_request"
 	MsgDistributorInvalidParseModel   = "distributor.invalid_request_parse_model"
+	MsgDistributorModelRouterFailed   = "distributor.model_router_failed"
+	MsgGroupEndpointForbidden         = "distributor.group_endpoint_forbidden"
+	MsgDistributorModelSunset         = "distributor.model_sunset"
+	MsgDistributorModelSunsetReplace  = "distributor.model_sunset_replacement"
+)
+
+// Relay related messages
+const (
+	MsgRelayGetChannelFailed   = "relay.get_channel_failed"
+	MsgRelayNoAvailableChannel = "relay.no_available_channel"
 )
 
 // Custom OAuth provider related messages
@@ -314,3 +344,27 @@ const (
 	MsgCustomOAuthBindingNotFound   = "custom_oauth.binding_not_found"
 	MsgCustomOAuthProviderIdInvalid = "custom_oauth.provider_id_field_invalid"
 )
+
+// Tenant related messages
+const (
+	MsgTenantNotFound       = "tenant.not_found"
+	MsgTenantDisabled       = "tenant.disabled"
+	MsgTenantSlugExists     = "tenant.slug_exists"
+	MsgTenantHostnameExists = "tenant.hostname_exists"
+	MsgTenantHasUsers       = "tenant.has_users"
+	MsgTenantUserMismatch   = "tenant.user_mismatch"
+	MsgTenantNoPermission   = "tenant.no_permission"
+	MsgTenantGroupForbidden = "tenant.group_forbidden"
+)
+
+// Feature flag related messages
+const (
+	MsgFeatureFlagNotFound   = "feature_flag.not_found"
+	MsgFeatureFlagNameExists = "feature_flag.name_exists"
+)
+
+// Usage report related messages
+const (
+	MsgReportSubscriptionNotFound = "report.subscription_not_found"
+	MsgReportNoPermission         = "report.no_permission"
+)
@@ -14,6 +14,7 @@ import (
 	"github.com/QuantumNous/new-api/common"
 	"github.com/QuantumNous/new-api/constant"
 	"github.com/QuantumNous/new-api/controller"
+	"github.com/QuantumNous/new-api/grpcapi"
 	"github.com/QuantumNous/new-api/i18n"
 	"github.com/QuantumNous/new-api/logger"
 	"github.com/QuantumNous/new-api/middleware"
@@ -96,6 +97,12 @@ func main() {
 	// 数据看板
 	go model.UpdateQuotaData()
 
+	// 日志表按月分区时预建后续月份的分区
+	model.StartLogPartitionMaintainer()
+
+	// SQLite 定时 checkpoint（embedded 部署模式默认开启）
+	model.StartSQLiteCheckpointTask()
+
 	if os.Getenv("CHANNEL_UPDATE_FREQUENCY") != "" {
 		frequency, err := strconv.Atoi(os.Getenv("CHANNEL_UPDATE_FREQUENCY"))
 		if err != nil {
@@ -112,6 +119,36 @@ func main() {
 	// Subscription quota reset task (daily/weekly/monthly/custom)
 	service.StartSubscriptionQuotaResetTask()
 
+	// Token used-quota reset task (weekly/monthly billing periods)
+	service.StartTokenQuotaResetTask()
+
+	// Expire bonus / prepaid quota pools and deduct their remaining quota
+	service.StartQuotaPoolExpireTask()
+
+	// Channel feedback scores used by feedback-aware channel selection (idle until enabled)
+	service.StartFeedbackScoreRefreshTask()
+
+	// Monthly usage summary emails (idle until enabled)
+	service.StartUsageSummaryEmailTask()
+
+	// Weekly / monthly usage reports of subscriptions
+	service.StartUsageReportTask()
+
+	// Fail background responses that are stuck queued or in progress
+	service.StartResponsesBackgroundReapTask()
+
+	// Telegram admin companion bot (idle until enabled)
+	service.StartTelegramBot()
+
+	// Vector store backend for /v1/vector_stores and file_search (idle until configured)
+	service.InitVectorStore()
+
+	// gRPC management API for channels, tokens and usage (idle until GRPC_MANAGEMENT_ADDR is set)
+	grpcapi.StartManagementServer()
+
+	// Push per-request usage to token usage webhooks (breaks model -> service import cycle)
+	model.OnConsumeLog = service.NotifyTokenUsageWebhook
+
 	// Wire task polling adaptor factory (breaks service -> relay import cycle)
 	service.GetTaskAdaptorFunc = func(platform constant.TaskPlatform) service.TaskPollingAdaptor {
 		a := relay.GetTaskAdaptor(platform)
@@ -124,6 +161,12 @@ func main() {
 	// Channel upstream model update check task
 	controller.StartChannelUpstreamModelUpdateTask()
 
+	// Channel /v1/responses capability detection task
+	controller.StartChannelResponsesCapabilityTask()
+
+	// Keep warm upstream connections and readiness pings for channels with prewarm settings
+	controller.StartChannelPrewarmTask()
+
 	if common.IsMasterNode && constant.UpdateTask {
 		gopool.Go(func() {
 			controller.UpdateMidjourneyTaskBulk()
@@ -153,6 +196,11 @@ func main() {
 
 	// Initialize HTTP server
 	server := gin.New()
+	if common.TrustedProxies != nil {
+		if err := server.SetTrustedProxies(common.TrustedProxies); err != nil {
+			common.FatalLog("failed to set trusted proxies: " + err.Error())
+		}
+	}
 	server.Use(gin.CustomRecovery(func(c *gin.Context, err any) {
 		common.SysLog(fmt.Sprintf("panic detected: %v", err))
 		c.JSON(http.StatusInternalServerError, gin.H{
@@ -167,6 +215,7 @@ func main() {
 	server.Use(middleware.RequestId())
 	server.Use(middleware.PoweredBy())
 	server.Use(middleware.I18n())
+	server.Use(middleware.TenantResolve())
 	middleware.SetUpLogger(server)
 	// Initialize session store
 	store := cookie.NewStore([]byte(common.SessionSecret))
@@ -192,7 +241,8 @@ func main() {
 	// Log startup success message
 	common.LogStartupSuccess(startTime, port)
 
-	err = server.Run(":" + port)
+	// 租户路径前缀 /t/{slug} 需在路由匹配前剥离
+	err = http.ListenAndServe(":"+port, middleware.TenantPathHandler(server))
 	if err != nil {
 		common.FatalLog("failed to start HTTP server: " + err.Error())
 	}
@@ -239,6 +289,30 @@ func InjectGoogleAnalytics() {
 	indexPage = bytes.ReplaceAll(indexPage, []byte("<!--Google Analytics-->\n"), []byte(analyticsInject))
 }
 
+func runMigrateCommand() {
+	if err := model.InitDB(); err != nil {
+		common.FatalLog("failed to initialize database: " + err.Error())
+	}
+	if err := model.InitLogDB(); err != nil {
+		common.FatalLog("failed to initialize log database: " + err.Error())
+	}
+	err := model.RunMigrateCommand(*common.MigrateCommand, *common.MigrateDryRun, *common.MigrateSteps, os.Stdout)
+	_ = model.CloseDB()
+	if err != nil {
+		common.FatalLog("migration failed: " + err.Error())
+	}
+	os.Exit(0)
+}
+
+func runDoctorCommand() {
+	ok := service.RunDoctor(os.Stdout)
+	_ = model.CloseDB()
+	if !ok {
+		os.Exit(1)
+	}
+	os.Exit(0)
+}
+
 func InitResources() error {
 	// Initialize resources here if needed
 	// This is a placeholder function for future resource initialization
@@ -261,6 +335,21 @@ func InitResources() error {
 
 	service.InitTokenEncoders()
 
+	// 加载 GeoIP 国家数据库，用于令牌国家访问限制；doctor 命令自行报告加载失败
+	if err := common.InitGeoIP(); err != nil && !common.DoctorCommand {
+		common.FatalLog(err.Error())
+	}
+
+	// doctor 命令只执行检查，完成后退出
+	if common.DoctorCommand {
+		runDoctorCommand()
+	}
+
+	// --migrate 命令只执行数据库迁移，完成后退出
+	if *common.MigrateCommand != "" {
+		runMigrateCommand()
+	}
+
 	// Initialize SQL Database
 	err = model.InitDB()
 	if err != nil {
@@ -270,6 +359,12 @@ func InitResources() error {
 
 	model.CheckSetup()
 
+	if !common.GeoIPEnabled() {
+		if count, err := model.CountTokensWithCountryLimits(); err == nil && count > 0 {
+			common.SysError(fmt.Sprintf("%d tokens have country restrictions but GEOIP_DB_PATH is not set, their requests are rejected", count))
+		}
+	}
+
 	// Initialize options, should after model.InitDB()
 	model.InitOptionMap()
 
@@ -285,6 +380,9 @@ func InitResources() error {
 		return err
 	}
 
+	// 日志查询、统计等读请求使用的只读副本
+	model.InitReadDB()
+
 	// Initialize Redis
 	err = common.InitRedisClient()
 	if err != nil {
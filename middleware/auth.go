@@ -9,10 +9,11 @@ import (
 
 	"github.com/QuantumNous/new-api/common"
 	"github.com/QuantumNous/new-api/constant"
+	"github.com/QuantumNous/new-api/i18n"
 	"github.com/QuantumNous/new-api/logger"
 	"github.com/QuantumNous/new-api/model"
 	"github.com/QuantumNous/new-api/service"
-	"github.com/QuantumNous/new-api/setting/ratio_setting"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
 	"github.com/QuantumNous/new-api/types"
 
 	"github.com/gin-contrib/sessions"
@@ -30,12 +31,13 @@ func validUserInfo(username string, role int) bool {
 	return true
 }
 
-func authHelper(c *gin.Context, minRole int) {
+func authHelper(c *gin.Context, minRole int, tenantAdminAllowed bool) {
 	session := sessions.Default(c)
 	username := session.Get("username")
 	role := session.Get("role")
 	id := session.Get("id")
 	status := session.Get("status")
+	userTenantId, _ := session.Get("tenant_id").(int)
 	useAccessToken := false
 	if username == nil {
 		// Check access token
@@ -63,6 +65,7 @@ func authHelper(c *gin.Context, minRole int) {
 			role = user.Role
 			id = user.Id
 			status = user.Status
+			userTenantId = user.TenantId
 			useAccessToken = true
 		} else {
 			c.JSON(http.StatusOK, gin.H{
@@ -125,6 +128,23 @@ func authHelper(c *gin.Context, minRole int) {
 		c.Abort()
 		return
 	}
+	if !model.CanAccessTenant(userTenantId, role.(int), common.GetContextKeyInt(c, constant.ContextKeyTenantId)) {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"success": false,
+			"message": "无权进行此操作，用户不属于当前站点",
+		})
+		c.Abort()
+		return
+	}
+	// 租户管理员只能访问显式开放给租户管理员的接口
+	if minRole >= common.RoleAdminUser && role.(int) < common.RoleRootUser && userTenantId != 0 && !tenantAdminAllowed {
+		c.JSON(http.StatusOK, gin.H{
+			"success": false,
+			"message": "无权进行此操作，权限不足",
+		})
+		c.Abort()
+		return
+	}
 	// 防止不同newapi版本冲突，导致数据不通用
 	c.Header("Auth-Version", "864b7076dbcd0a3c01b5520316720ebf")
 	c.Set("username", username)
@@ -133,6 +153,7 @@ func authHelper(c *gin.Context, minRole int) {
 	c.Set("group", session.Get("group"))
 	c.Set("user_group", session.Get("group"))
 	c.Set("use_access_token", useAccessToken)
+	common.SetContextKey(c, constant.ContextKeyUserTenantId, userTenantId)
 
 	c.Next()
 }
@@ -150,19 +171,27 @@ func TryUserAuth() func(c *gin.Context) {
 
 func UserAuth() func(c *gin.Context) {
 	return func(c *gin.Context) {
-		authHelper(c, common.RoleCommonUser)
+		authHelper(c, common.RoleCommonUser, false)
 	}
 }
 
 func AdminAuth() func(c *gin.Context) {
 	return func(c *gin.Context) {
-		authHelper(c, common.RoleAdminUser)
+		authHelper(c, common.RoleAdminUser, false)
+	}
+}
+
+// TenantAdminAuth is AdminAuth that also admits tenant admins; handlers must scope
+// their data to the admin's tenant.
+func TenantAdminAuth() func(c *gin.Context) {
+	return func(c *gin.Context) {
+		authHelper(c, common.RoleAdminUser, true)
 	}
 }
 
 func RootAuth() func(c *gin.Context) {
 	return func(c *gin.Context) {
-		authHelper(c, common.RoleRootUser)
+		authHelper(c, common.RoleRootUser, false)
 	}
 }
 
@@ -298,6 +327,7 @@ func TokenAuth() func(c *gin.Context) {
 			key = parts[0]
 		} else {
 			key = strings.TrimPrefix(key, "sk-")
+			key = strings.TrimPrefix(key, model.RealtimeClientSecretPrefix)
 			parts = strings.Split(key, "-")
 			key = parts[0]
 		}
@@ -329,6 +359,10 @@ func TokenAuth() func(c *gin.Context) {
 			logger.LogDebug(c, "Client IP %s passed the token IP restrictions check", clientIp)
 		}
 
+		if ip := net.ParseIP(c.ClientIP()); ip != nil && !checkTokenCountry(c, token, ip) {
+			return
+		}
+
 		userCache, err := model.GetUserCache(token.UserId)
 		if err != nil {
 			abortWithOpenAiMessage(c, http.StatusInternalServerError, err.Error())
@@ -340,23 +374,26 @@ func TokenAuth() func(c *gin.Context) {
 			return
 		}
 
+		// 租户站点只接受本租户用户的令牌，平台站点接受所有令牌
+		if requestTenantId := common.GetContextKeyInt(c, constant.ContextKeyTenantId); requestTenantId != 0 && userCache.TenantId != requestTenantId {
+			abortWithOpenAiMessage(c, http.StatusUnauthorized, i18n.T(c, i18n.MsgTenantUserMismatch))
+			return
+		}
+
 		userCache.WriteContext(c)
 
 		userGroup := userCache.Group
 		tokenGroup := token.Group
+		if tenant := model.GetCachedTenant(userCache.TenantId); tenant != nil && tokenGroup != "" && !tenant.OwnsGroup(tokenGroup) {
+			abortWithOpenAiMessage(c, http.StatusForbidden, i18n.T(c, i18n.MsgTenantGroupForbidden, map[string]any{"Group": tokenGroup}))
+			return
+		}
 		if tokenGroup != "" {
-			// check common.UserUsableGroups[userGroup]
-			if _, ok := service.GetUserUsableGroups(userGroup)[tokenGroup]; !ok {
-				abortWithOpenAiMessage(c, http.StatusForbidden, fmt.Sprintf("无权访问 %s 分组", tokenGroup))
+			// check common.UserUsableGroups[userGroup] and common.GroupRatio
+			if err := service.CheckTokenGroup(userGroup, tokenGroup); err != nil {
+				abortWithOpenAiMessage(c, http.StatusForbidden, err.Error())
 				return
 			}
-			// check group in common.GroupRatio
-			if !ratio_setting.ContainsGroupRatio(tokenGroup) {
-				if tokenGroup != "auto" {
-					abortWithOpenAiMessage(c, http.StatusForbidden, fmt.Sprintf("分组 %s 已被弃用", tokenGroup))
-					return
-				}
-			}
 			userGroup = tokenGroup
 		}
 		common.SetContextKey(c, constant.ContextKeyUsingGroup, userGroup)
@@ -377,10 +414,15 @@ func SetupContextForToken(c *gin.Context, token *model.Token, parts ...string) e
 	c.Set("token_id", token.Id)
 	c.Set("token_key", token.Key)
 	c.Set("token_name", token.Name)
-	c.Set("token_unlimited_quota", token.UnlimitedQuota)
-	if !token.UnlimitedQuota {
+	// 按次模式的令牌不限制额度，只限制请求次数
+	c.Set("token_unlimited_quota", token.UnlimitedQuota || token.IsRequestQuotaMode())
+	if !token.UnlimitedQuota && !token.IsRequestQuotaMode() {
 		c.Set("token_quota", token.RemainQuota)
 	}
+	if token.IsRequestQuotaMode() {
+		common.SetContextKey(c, constant.ContextKeyTokenRequestQuota, token.RequestQuota)
+		common.SetContextKey(c, constant.ContextKeyTokenRequestPeriod, token.RequestQuotaPeriod)
+	}
 	if token.ModelLimitsEnabled {
 		c.Set("token_model_limit_enabled", true)
 		c.Set("token_model_limit", token.GetModelLimitsMap())
@@ -389,6 +431,34 @@ func SetupContextForToken(c *gin.Context, token *model.Token, parts ...string) e
 	}
 	common.SetContextKey(c, constant.ContextKeyTokenGroup, token.Group)
 	common.SetContextKey(c, constant.ContextKeyTokenCrossGroupRetry, token.CrossGroupRetry)
+	common.SetContextKey(c, constant.ContextKeyTokenCompatMode, token.CompatMode)
+	common.SetContextKey(c, constant.ContextKeyTokenContextOverflow, token.ContextOverflow)
+	common.SetContextKey(c, constant.ContextKeyTokenDedupWindow, token.DedupWindow)
+	common.SetContextKey(c, constant.ContextKeyTokenDedupExemptStream, token.DedupExemptStream)
+	common.SetContextKey(c, constant.ContextKeyTokenBatchMode, token.BatchMode)
+	common.SetContextKey(c, constant.ContextKeyTokenChannelLabels, token.ChannelLabels)
+	common.SetContextKey(c, constant.ContextKeyTokenClientProfile, token.ClientProfile)
+	// 请求头指定的版本优先于令牌设置，令牌未设置时使用客户端兼容配置的版本
+	requestedVersion := c.GetHeader(constant.ApiVersionHeader)
+	if requestedVersion == "" {
+		requestedVersion = token.ApiVersion
+	}
+	if requestedVersion == "" {
+		if profile := operation_setting.GetClientProfile(token.ClientProfile); profile != nil {
+			requestedVersion = profile.ApiVersion
+		}
+	}
+	apiVersion, ok := constant.ResolveApiVersion(requestedVersion)
+	if !ok {
+		abortWithOpenAiMessage(c, http.StatusBadRequest, fmt.Sprintf("不支持的 API 版本 %s", requestedVersion), types.ErrorCodeInvalidRequest)
+		return fmt.Errorf("unsupported api version %s", requestedVersion)
+	}
+	common.SetContextKey(c, constant.ContextKeyClientApiVersion, string(apiVersion))
+	c.Header(constant.ApiVersionHeader, string(apiVersion))
+	if token.UsageWebhookUrl != "" {
+		common.SetContextKey(c, constant.ContextKeyTokenUsageWebhook, token.UsageWebhookUrl)
+		common.SetContextKey(c, constant.ContextKeyTokenUsageWebhookKey, token.UsageWebhookSecret)
+	}
 	if len(parts) > 1 {
 		if model.IsAdmin(token.UserId) {
 			c.Set("specific_channel_id", parts[1])
@@ -16,6 +16,7 @@ import (
 	"github.com/QuantumNous/new-api/model"
 	relayconstant "github.com/QuantumNous/new-api/relay/constant"
 	"github.com/QuantumNous/new-api/service"
+	"github.com/QuantumNous/new-api/setting/model_setting"
 	"github.com/QuantumNous/new-api/setting/ratio_setting"
 	"github.com/QuantumNous/new-api/types"
 
@@ -27,8 +28,18 @@ type ModelRequest struct {
 	Group string `json:"group,omitempty"`
 }
 
+// IsFanoutRequest reports whether the request asks to broadcast a chat completion to
+// several models; channels are then selected per target by the fanout controller.
+func IsFanoutRequest(c *gin.Context) bool {
+	return c.Request.URL.Path == "/v1/chat/completions" && c.Query("fanout") != ""
+}
+
 func Distribute() func(c *gin.Context) {
 	return func(c *gin.Context) {
+		if IsFanoutRequest(c) {
+			c.Next()
+			return
+		}
 		var channel *model.Channel
 		channelId, ok := common.GetContextKey(c, constant.ContextKeyTokenSpecificChannelId)
 		modelRequest, shouldSelectChannel, err := getModelRequest(c)
@@ -36,6 +47,20 @@ func Distribute() func(c *gin.Context) {
 			abortWithOpenAiMessage(c, http.StatusBadRequest, i18n.T(c, i18n.MsgDistributorInvalidRequest, map[string]any{"Error": err.Error()}))
 			return
 		}
+		if model_setting.IsModelRouterModel(modelRequest.Model) {
+			decision, err := service.RouteVirtualModel(c, modelRequest.Model)
+			if err != nil {
+				abortWithOpenAiMessage(c, http.StatusBadRequest, i18n.T(c, i18n.MsgDistributorModelRouterFailed, map[string]any{"Model": modelRequest.Model, "Error": err.Error()}))
+				return
+			}
+			modelRequest.Model = decision.TargetModel
+		}
+		modelName, statusCode, message := service.ApplyModelLifecycle(c, modelRequest.Model)
+		if statusCode != 0 {
+			abortWithOpenAiMessage(c, statusCode, message)
+			return
+		}
+		modelRequest.Model = modelName
 		if ok {
 			id, err := strconv.Atoi(channelId.(string))
 			if err != nil {
@@ -101,7 +126,7 @@ func Distribute() func(c *gin.Context) {
 
 				if preferredChannelID, found := service.GetPreferredChannelByAffinity(c, modelRequest.Model, usingGroup); found {
 					preferred, err := model.CacheGetChannel(preferredChannelID)
-					if err == nil && preferred != nil && preferred.Status == common.ChannelStatusEnabled {
+					if err == nil && preferred != nil && preferred.Status == common.ChannelStatusEnabled && preferred.MatchesLabelSelector(service.GetTokenChannelLabelSelector(c)) && preferred.ServesEndpoint(service.GetRequestChannelEndpoint(c)) {
 						if usingGroup == "auto" {
 							userGroup := common.GetContextKeyString(c, constant.ContextKeyUserGroup)
 							autoGroups := service.GetUserAutoGroup(userGroup)
@@ -275,6 +300,9 @@ func getModelRequest(c *gin.Context) (*ModelRequest, bool, error) {
 	if strings.HasPrefix(c.Request.URL.Path, "/v1/realtime") {
 		//wss://api.openai.com/v1/realtime?model=gpt-4o-realtime-preview-2024-10-01
 		modelRequest.Model = c.Query("model")
+		if strings.HasPrefix(c.Request.URL.Path, "/v1/realtime/transcriptions") {
+			modelRequest.Model = common.GetStringIfEmpty(modelRequest.Model, "gpt-4o-transcribe")
+		}
 	}
 	if strings.HasPrefix(c.Request.URL.Path, "/v1/moderations") {
 		if modelRequest.Model == "" {
@@ -353,6 +381,7 @@ func SetupContextForSelectedChannel(c *gin.Context, channel *model.Channel, mode
 	if mergedParam, applied := service.ApplyChannelAffinityOverrideTemplate(c, paramOverride); applied {
 		paramOverride = mergedParam
 	}
+	paramOverride = service.ApplyClientProfileOverride(c, paramOverride)
 	common.SetContextKey(c, constant.ContextKeyChannelParamOverride, paramOverride)
 	common.SetContextKey(c, constant.ContextKeyChannelHeaderOverride, headerOverride)
 	if nil != channel.OpenAIOrganization && *channel.OpenAIOrganization != "" {
@@ -0,0 +1,80 @@
+package middleware
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/constant"
+	"github.com/QuantumNous/new-api/i18n"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+	"github.com/QuantumNous/new-api/types"
+
+	"github.com/gin-gonic/gin"
+)
+
+// endpointClassRules 请求路径前缀到接口类别的映射，按顺序匹配
+var endpointClassRules = []struct {
+	prefix        string
+	endpointClass string
+}{
+	{"/v1/chat/completions", operation_setting.EndpointClassChat},
+	{"/v1/completions", operation_setting.EndpointClassChat},
+	{"/v1/responses", operation_setting.EndpointClassResponses},
+	{"/v1/messages", operation_setting.EndpointClassMessages},
+	{"/v1beta/models/", operation_setting.EndpointClassGemini},
+	{"/v1/models/", operation_setting.EndpointClassGemini},
+	{"/v1/embeddings", operation_setting.EndpointClassEmbeddings},
+	{"/v1/engines/", operation_setting.EndpointClassEmbeddings},
+	{"/v1/images/", operation_setting.EndpointClassImages},
+	{"/v1/edits", operation_setting.EndpointClassImages},
+	{"/v1/audio/", operation_setting.EndpointClassAudio},
+	{"/v1/realtime", operation_setting.EndpointClassRealtime},
+	{"/v1/rerank", operation_setting.EndpointClassRerank},
+	{"/v1/moderations", operation_setting.EndpointClassModerations},
+	{"/v1/video", operation_setting.EndpointClassVideo},
+	{"/kling/", operation_setting.EndpointClassVideo},
+	{"/jimeng", operation_setting.EndpointClassVideo},
+	{"/suno/", operation_setting.EndpointClassSuno},
+	{"/v1/files", operation_setting.EndpointClassFiles},
+	{"/v1/vector_stores", operation_setting.EndpointClassFiles},
+}
+
+// endpointClassOfPath 返回请求路径所属的接口类别，未归类的路径返回空字符串
+func endpointClassOfPath(path string) string {
+	// /mj 与 /:mode/mj
+	if strings.Contains(path, "/mj/") {
+		return operation_setting.EndpointClassMidjourney
+	}
+	for _, rule := range endpointClassRules {
+		if strings.HasPrefix(path, rule.prefix) {
+			return rule.endpointClass
+		}
+	}
+	return ""
+}
+
+// CheckGroupEndpointAccess 检查请求的分组能否使用 path 所属的接口类别，不允许时返回拒绝原因。
+// 令牌未指定分组或为 auto 时使用用户分组；Batch 等代为执行其他接口的请求按实际执行的接口路径检查
+func CheckGroupEndpointAccess(c *gin.Context, path string) (string, bool) {
+	endpointClass := endpointClassOfPath(path)
+	group := common.GetContextKeyString(c, constant.ContextKeyTokenGroup)
+	if group == "" || group == "auto" {
+		group = common.GetContextKeyString(c, constant.ContextKeyUserGroup)
+	}
+	if !operation_setting.IsEndpointAllowedForGroup(group, endpointClass) {
+		return i18n.T(c, i18n.MsgGroupEndpointForbidden, map[string]any{"Group": group, "Endpoint": endpointClass}), false
+	}
+	return "", true
+}
+
+// GroupEndpointAccess 拒绝分组无权使用的接口类别，需放在 TokenAuth 之后
+func GroupEndpointAccess() func(c *gin.Context) {
+	return func(c *gin.Context) {
+		if message, ok := CheckGroupEndpointAccess(c, c.Request.URL.Path); !ok {
+			abortWithOpenAiMessage(c, http.StatusForbidden, message, types.ErrorCodeAccessDenied)
+			return
+		}
+		c.Next()
+	}
+}
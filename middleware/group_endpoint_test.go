@@ -0,0 +1,80 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/constant"
+	"github.com/QuantumNous/new-api/i18n"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEndpointClassOfPath(t *testing.T) {
+	cases := map[string]string{
+		"/v1/chat/completions":                      operation_setting.EndpointClassChat,
+		"/v1/responses/resp_1/cancel":               operation_setting.EndpointClassResponses,
+		"/v1beta/models/gemini-2.5:generateContent": operation_setting.EndpointClassGemini,
+		"/v1/images/generations":                    operation_setting.EndpointClassImages,
+		"/v1/audio/speech":                          operation_setting.EndpointClassAudio,
+		"/v1/realtime":                              operation_setting.EndpointClassRealtime,
+		"/v1/videos/video_1/remix":                  operation_setting.EndpointClassVideo,
+		"/fast/mj/submit/imagine":                   operation_setting.EndpointClassMidjourney,
+		"/v1/vector_stores/vs_1/search":             operation_setting.EndpointClassFiles,
+		"/v1/token/count":                           "",
+	}
+	for path, endpointClass := range cases {
+		assert.Equal(t, endpointClass, endpointClassOfPath(path), path)
+	}
+}
+
+func TestGroupEndpointAccess(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	require.NoError(t, i18n.Init())
+	setting := operation_setting.GetGroupEndpointSetting()
+	origin := *setting
+	t.Cleanup(func() { *setting = origin })
+	*setting = operation_setting.GroupEndpointSetting{
+		Enabled:             true,
+		DisabledEndpoints:   map[string][]string{"free": {operation_setting.EndpointClassImages, operation_setting.EndpointClassAudio}},
+		RestrictedEndpoints: map[string][]string{operation_setting.EndpointClassRealtime: {"enterprise"}},
+	}
+
+	serve := func(path string, tokenGroup string, userGroup string) *httptest.ResponseRecorder {
+		router := gin.New()
+		router.Use(func(c *gin.Context) {
+			common.SetContextKey(c, constant.ContextKeyTokenGroup, tokenGroup)
+			common.SetContextKey(c, constant.ContextKeyUserGroup, userGroup)
+		}, GroupEndpointAccess())
+		router.Any("/*path", func(c *gin.Context) { c.Status(http.StatusOK) })
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, path, nil))
+		return w
+	}
+
+	blocked := serve("/v1/images/generations", "", "free")
+	assert.Equal(t, http.StatusForbidden, blocked.Code)
+	assert.Contains(t, blocked.Body.String(), "access_denied")
+	assert.Equal(t, http.StatusOK, serve("/v1/chat/completions", "", "free").Code)
+	assert.Equal(t, http.StatusOK, serve("/v1/images/generations", "vip", "free").Code)
+	assert.Equal(t, http.StatusForbidden, serve("/v1/audio/speech", "auto", "free").Code)
+
+	assert.Equal(t, http.StatusForbidden, serve("/v1/realtime", "", "vip").Code)
+	assert.Equal(t, http.StatusOK, serve("/v1/realtime", "", "enterprise").Code)
+
+	// Batch 按其执行的接口路径检查
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	common.SetContextKey(c, constant.ContextKeyUserGroup, "free")
+	setting.DisabledEndpoints["free"] = append(setting.DisabledEndpoints["free"], operation_setting.EndpointClassChat)
+	_, ok := CheckGroupEndpointAccess(c, "/v1/chat/completions")
+	assert.False(t, ok)
+	_, ok = CheckGroupEndpointAccess(c, "/v1/embeddings")
+	assert.True(t, ok)
+
+	setting.Enabled = false
+	assert.Equal(t, http.StatusOK, serve("/v1/images/generations", "", "free").Code)
+}
@@ -0,0 +1,82 @@
+package middleware
+
+import (
+	"net/http"
+	"sync"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/i18n"
+	"github.com/QuantumNous/new-api/types"
+
+	"github.com/gin-gonic/gin"
+)
+
+const RelayIPRateLimitMark = "RIP"
+
+// ipConcurrencyLimiter 记录本节点每个来源 IP 正在处理中的请求数
+type ipConcurrencyLimiter struct {
+	mu       sync.Mutex
+	inflight map[string]int
+}
+
+var relayIPConcurrency = &ipConcurrencyLimiter{inflight: make(map[string]int)}
+
+func (l *ipConcurrencyLimiter) acquire(ip string, max int) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.inflight[ip] >= max {
+		return false
+	}
+	l.inflight[ip]++
+	return true
+}
+
+func (l *ipConcurrencyLimiter) release(ip string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.inflight[ip] <= 1 {
+		delete(l.inflight, ip)
+		return
+	}
+	l.inflight[ip]--
+}
+
+// RelayIPLimit 在令牌鉴权之前按来源 IP 限制请求频率和并发数，
+// 撞库、爬取等流量在这里被拦截，不会每次都查询数据库。
+// 来源 IP 取自 c.ClientIP()，部署在反向代理之后时需配置 TRUSTED_PROXIES 防止伪造 X-Forwarded-For。
+func RelayIPLimit() gin.HandlerFunc {
+	maxConcurrency := common.RelayIPMaxConcurrency
+	if !common.RelayIPRateLimitEnable && maxConcurrency <= 0 {
+		return defNext
+	}
+	var rateLimit func(c *gin.Context)
+	if common.RelayIPRateLimitEnable {
+		rateLimit = rateLimitFactory(common.RelayIPRateLimitNum, common.RelayIPRateLimitDuration, RelayIPRateLimitMark)
+	}
+	return func(c *gin.Context) {
+		if rateLimit != nil {
+			rateLimit(c)
+			if c.IsAborted() {
+				// rateLimitFactory 只设置状态码，这里补充 OpenAI 格式的错误信息
+				if c.Writer.Status() == http.StatusTooManyRequests && !c.Writer.Written() {
+					abortWithOpenAiMessage(c, http.StatusTooManyRequests, i18n.T(c, i18n.MsgRateLimitIPReached, map[string]any{
+						"Max":     common.RelayIPRateLimitNum,
+						"Seconds": common.RelayIPRateLimitDuration,
+					}), types.ErrorCodeRateLimitExceeded)
+				}
+				return
+			}
+		}
+		if maxConcurrency > 0 {
+			clientIp := c.ClientIP()
+			if !relayIPConcurrency.acquire(clientIp, maxConcurrency) {
+				abortWithOpenAiMessage(c, http.StatusTooManyRequests, i18n.T(c, i18n.MsgRateLimitIPConcurrent, map[string]any{
+					"Max": maxConcurrency,
+				}), types.ErrorCodeRateLimitExceeded)
+				return
+			}
+			defer relayIPConcurrency.release(clientIp)
+		}
+		c.Next()
+	}
+}
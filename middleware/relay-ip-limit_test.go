@@ -0,0 +1,60 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/i18n"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRelayIPLimit_Concurrency(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	require.NoError(t, i18n.Init())
+	originEnable, originMax := common.RelayIPRateLimitEnable, common.RelayIPMaxConcurrency
+	t.Cleanup(func() {
+		common.RelayIPRateLimitEnable, common.RelayIPMaxConcurrency = originEnable, originMax
+	})
+	common.RelayIPRateLimitEnable = false
+	common.RelayIPMaxConcurrency = 1
+
+	entered := make(chan struct{})
+	unblock := make(chan struct{})
+	router := gin.New()
+	router.Use(RelayIPLimit())
+	router.GET("/slow", func(c *gin.Context) {
+		close(entered)
+		<-unblock
+		c.Status(http.StatusOK)
+	})
+	router.GET("/fast", func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	serve := func(path string, remoteAddr string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.RemoteAddr = remoteAddr
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	done := make(chan int)
+	go func() {
+		done <- serve("/slow", "192.0.2.1:1000").Code
+	}()
+	<-entered
+
+	blocked := serve("/fast", "192.0.2.1:1001")
+	assert.Equal(t, http.StatusTooManyRequests, blocked.Code)
+	assert.Contains(t, blocked.Body.String(), "rate_limit_exceeded")
+	assert.Equal(t, http.StatusOK, serve("/fast", "192.0.2.2:1000").Code)
+
+	close(unblock)
+	require.Equal(t, http.StatusOK, <-done)
+	assert.Equal(t, http.StatusOK, serve("/fast", "192.0.2.1:1002").Code)
+}
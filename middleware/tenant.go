@@ -0,0 +1,68 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/constant"
+	"github.com/QuantumNous/new-api/i18n"
+	"github.com/QuantumNous/new-api/model"
+
+	"github.com/gin-gonic/gin"
+)
+
+const tenantPathPrefix = "/t/"
+
+type tenantPathContextKey struct{}
+
+// TenantPathHandler selects a tenant by the /t/{slug} path prefix. The prefix is
+// stripped before routing so every route works unchanged under it; the selected
+// tenant is handed to TenantResolve through the request context.
+func TenantPathHandler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.URL.Path, tenantPathPrefix) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		rest := strings.TrimPrefix(r.URL.Path, tenantPathPrefix)
+		slug, path, _ := strings.Cut(rest, "/")
+		tenant := model.GetTenantBySlug(slug)
+		if tenant == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+		r = r.WithContext(context.WithValue(r.Context(), tenantPathContextKey{}, tenant))
+		r.URL.Path = "/" + path
+		r.URL.RawPath = ""
+		next.ServeHTTP(w, r)
+	})
+}
+
+// TenantResolve stores the tenant selected by path prefix or hostname in the context.
+// Requests matching no tenant are served as the platform (tenant id 0).
+func TenantResolve() func(c *gin.Context) {
+	return func(c *gin.Context) {
+		tenant, _ := c.Request.Context().Value(tenantPathContextKey{}).(*model.Tenant)
+		if tenant == nil {
+			tenant = model.GetTenantByHost(c.Request.Host)
+		}
+		if tenant == nil {
+			common.SetContextKey(c, constant.ContextKeyTenantId, 0)
+			c.Next()
+			return
+		}
+		if tenant.Status != model.TenantStatusEnabled {
+			c.JSON(http.StatusForbidden, gin.H{
+				"success": false,
+				"message": i18n.T(c, i18n.MsgTenantDisabled),
+			})
+			c.Abort()
+			return
+		}
+		common.SetContextKey(c, constant.ContextKeyTenant, tenant)
+		common.SetContextKey(c, constant.ContextKeyTenantId, tenant.Id)
+		c.Next()
+	}
+}
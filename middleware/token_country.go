@@ -0,0 +1,86 @@
+package middleware
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/i18n"
+	"github.com/QuantumNous/new-api/logger"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/types"
+
+	"github.com/bytedance/gopkg/util/gopool"
+	"github.com/gin-gonic/gin"
+)
+
+// 同一令牌同一来源 IP 的拒绝审计日志最短记录间隔，避免被扫描时刷爆日志表
+const tokenCountryAuditInterval = time.Minute
+
+var (
+	tokenCountryAuditLast  sync.Map // "tokenId:ip" -> time.Time
+	tokenCountryAuditCount atomic.Int64
+)
+
+// checkTokenCountry 按令牌的国家允许/禁止列表校验客户端来源，拒绝时中止请求并返回 false
+func checkTokenCountry(c *gin.Context, token *model.Token, ip net.IP) bool {
+	allow, deny := token.GetCountryLimits()
+	if len(allow) == 0 && len(deny) == 0 {
+		return true
+	}
+	clientIp := ip.String()
+	// 未加载数据库时无法判断来源，按拒绝处理，避免区域限制失效
+	if !common.GeoIPEnabled() {
+		auditTokenCountryDenied(token, clientIp, "", "GeoIP 数据库未加载")
+		abortWithOpenAiMessage(c, http.StatusForbidden, i18n.T(c, i18n.MsgTokenGeoIPUnavailable), types.ErrorCodeAccessDenied)
+		return false
+	}
+	country := common.LookupIPCountry(ip)
+	reason := ""
+	if len(allow) > 0 && !slices.Contains(allow, country) {
+		reason = "不在允许列表中"
+	} else if country != "" && slices.Contains(deny, country) {
+		reason = "在禁止列表中"
+	}
+	if reason == "" {
+		logger.LogDebug(c, "Client IP %s (%s) passed the token country restrictions check", clientIp, country)
+		return true
+	}
+	auditTokenCountryDenied(token, clientIp, country, reason)
+	displayCountry := country
+	if displayCountry == "" {
+		displayCountry = "unknown"
+	}
+	abortWithOpenAiMessage(c, http.StatusForbidden, i18n.T(c, i18n.MsgTokenCountryDenied, map[string]any{"Country": displayCountry}), types.ErrorCodeAccessDenied)
+	return false
+}
+
+func auditTokenCountryDenied(token *model.Token, clientIp string, country string, reason string) {
+	key := fmt.Sprintf("%d:%s", token.Id, clientIp)
+	now := time.Now()
+	if last, ok := tokenCountryAuditLast.Load(key); ok && now.Sub(last.(time.Time)) < tokenCountryAuditInterval {
+		return
+	}
+	tokenCountryAuditLast.Store(key, now)
+	// 定期清理过期记录，防止大量不同来源 IP 导致内存持续增长
+	if tokenCountryAuditCount.Add(1)%1024 == 0 {
+		tokenCountryAuditLast.Range(func(k, v any) bool {
+			if now.Sub(v.(time.Time)) >= tokenCountryAuditInterval {
+				tokenCountryAuditLast.Delete(k)
+			}
+			return true
+		})
+	}
+	if country == "" {
+		country = "未知"
+	}
+	content := fmt.Sprintf("令牌 %s (ID: %d) 拒绝来自 %s 的请求，国家/地区 %s %s", token.Name, token.Id, clientIp, country, reason)
+	gopool.Go(func() {
+		model.RecordLog(token.UserId, model.LogTypeSystem, content)
+	})
+}
@@ -58,12 +58,13 @@ func GetAllEnableAbilities() []Ability {
 	return abilities
 }
 
-func getPriority(group string, model string, retry int) (int, error) {
+func getPriority(group string, model string, retry int, selector ChannelLabelSelector, endpoint string) (int, error) {
 
 	var priorities []int
 	err := DB.Model(&Ability{}).
 		Select("DISTINCT(priority)").
 		Where(commonGroupCol+" = ? and model = ? and enabled = ?", group, model, true).
+		Scopes(abilityLabelScope(selector), abilityEndpointScope(endpoint)).
 		Order("priority DESC").              // 按优先级降序排序
 		Pluck("priority", &priorities).Error // Pluck用于将查询的结果直接扫描到一个切片中
 
@@ -88,11 +89,11 @@ func getPriority(group string, model string, retry int) (int, error) {
 	return priorityToUse, nil
 }
 
-func getChannelQuery(group string, model string, retry int) (*gorm.DB, error) {
-	maxPrioritySubQuery := DB.Model(&Ability{}).Select("MAX(priority)").Where(commonGroupCol+" = ? and model = ? and enabled = ?", group, model, true)
+func getChannelQuery(group string, model string, retry int, selector ChannelLabelSelector, endpoint string) (*gorm.DB, error) {
+	maxPrioritySubQuery := DB.Model(&Ability{}).Select("MAX(priority)").Where(commonGroupCol+" = ? and model = ? and enabled = ?", group, model, true).Scopes(abilityLabelScope(selector), abilityEndpointScope(endpoint))
 	channelQuery := DB.Where(commonGroupCol+" = ? and model = ? and enabled = ? and priority = (?)", group, model, true, maxPrioritySubQuery)
 	if retry != 0 {
-		priority, err := getPriority(group, model, retry)
+		priority, err := getPriority(group, model, retry, selector, endpoint)
 		if err != nil {
 			return nil, err
 		} else {
@@ -100,14 +101,15 @@ func getChannelQuery(group string, model string, retry int) (*gorm.DB, error) {
 		}
 	}
 
-	return channelQuery, nil
+	return channelQuery.Scopes(abilityLabelScope(selector), abilityEndpointScope(endpoint)), nil
 }
 
-func GetChannel(group string, model string, retry int) (*Channel, error) {
+// GetChannel 不使用内存缓存时从数据库选择渠道，selector 为空时不限制渠道标签，endpoint 为空时不限制端点类型
+func GetChannel(group string, model string, retry int, selector ChannelLabelSelector, endpoint string) (*Channel, error) {
 	var abilities []Ability
 
 	var err error = nil
-	channelQuery, err := getChannelQuery(group, model, retry)
+	channelQuery, err := getChannelQuery(group, model, retry, selector, endpoint)
 	if err != nil {
 		return nil, err
 	}
@@ -339,3 +341,19 @@ func FixAbility() (int, int, error) {
 	InitChannelCache()
 	return successCount, failCount, nil
 }
+
+// ModelChannelAvailability counts the channels serving a model, without identifying them.
+type ModelChannelAvailability struct {
+	Model        string `json:"model"`
+	TotalCount   int    `json:"total_count"`
+	EnabledCount int    `json:"enabled_count"`
+}
+
+func GetModelChannelAvailability() ([]ModelChannelAvailability, error) {
+	var availability []ModelChannelAvailability
+	err := DB.Table("abilities").
+		Select("model, count(distinct channel_id) as total_count, count(distinct case when enabled = ? then channel_id end) as enabled_count", true).
+		Group("model").
+		Scan(&availability).Error
+	return availability, err
+}
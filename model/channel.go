@@ -44,7 +44,9 @@ type Channel struct {
 	AutoBan           *int    `json:"auto_ban" gorm:"default:1"`
 	OtherInfo         string  `json:"other_info"`
 	Tag               *string `json:"tag" gorm:"index"`
-	Setting           *string `json:"setting" gorm:"type:text"` // 渠道额外设置
+	Labels            *string `json:"labels" gorm:"type:varchar(1024);default:''"`           // 结构化标签，逗号分隔的 key:value，如 region:eu,tier:premium
+	AllowedEndpoints  *string `json:"allowed_endpoints" gorm:"type:varchar(255);default:''"` // 可服务的端点类型，逗号分隔，如 chat,responses，为空时不限制
+	Setting           *string `json:"setting" gorm:"type:text"`                              // 渠道额外设置
 	ParamOverride     *string `json:"param_override" gorm:"type:text"`
 	HeaderOverride    *string `json:"header_override" gorm:"type:text"`
 	Remark            *string `json:"remark" gorm:"type:varchar(255)" validate:"max=255"`
@@ -1006,3 +1008,27 @@ func CountChannelsGroupByType() (map[int64]int64, error) {
 	}
 	return counts, nil
 }
+
+type ChannelStatusCount struct {
+	Status int   `json:"status"`
+	Count  int64 `json:"count"`
+}
+
+// GetChannelStatusCounts returns the number of channels per status.
+func GetChannelStatusCounts() ([]ChannelStatusCount, error) {
+	var counts []ChannelStatusCount
+	err := DB.Model(&Channel{}).Select("status, count(*) as count").Group("status").Scan(&counts).Error
+	return counts, err
+}
+
+// GetDisabledChannels returns manually and automatically disabled channels, newest first.
+func GetDisabledChannels(limit int) ([]*Channel, error) {
+	var channels []*Channel
+	err := DB.Model(&Channel{}).
+		Select("id, name, status, other_info").
+		Where("status <> ?", common.ChannelStatusEnabled).
+		Order("id desc").
+		Limit(limit).
+		Find(&channels).Error
+	return channels, err
+}
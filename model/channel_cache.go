@@ -11,6 +11,7 @@ import (
 
 	"github.com/QuantumNous/new-api/common"
 	"github.com/QuantumNous/new-api/constant"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
 	"github.com/QuantumNous/new-api/setting/ratio_setting"
 )
 
@@ -93,10 +94,12 @@ func SyncChannelCache(frequency int) {
 	}
 }
 
-func GetRandomSatisfiedChannel(group string, model string, retry int) (*Channel, error) {
+// GetRandomSatisfiedChannel 按优先级和权重选择分组下支持该模型的渠道，selector 非空时只在满足标签约束的渠道中选择，
+// endpoint 非空时只在服务该端点类型的渠道中选择
+func GetRandomSatisfiedChannel(group string, model string, retry int, selector ChannelLabelSelector, endpoint string) (*Channel, error) {
 	// if memory cache is disabled, get channel directly from database
 	if !common.MemoryCacheEnabled {
-		return GetChannel(group, model, retry)
+		return GetChannel(group, model, retry, selector, endpoint)
 	}
 
 	channelSyncLock.RLock()
@@ -111,6 +114,10 @@ func GetRandomSatisfiedChannel(group string, model string, retry int) (*Channel,
 		channels = group2model2channels[group][normalizedModel]
 	}
 
+	if len(selector) > 0 || endpoint != "" {
+		channels = filterSatisfiedChannels(channels, selector, endpoint)
+	}
+
 	if len(channels) == 0 {
 		return nil, nil
 	}
@@ -173,21 +180,70 @@ func GetRandomSatisfiedChannel(group string, model string, retry int) (*Channel,
 		smoothingFactor = 100
 	}
 
+	// 开启评价路由时按渠道在该模型上的用户评价调整权重
+	feedbackStrength := 0.0
+	if feedbackRouting := operation_setting.GetFeedbackRoutingSetting(); feedbackRouting.Enabled {
+		feedbackStrength = feedbackRouting.Strength
+	}
+	effectiveWeight := func(channel *Channel) int {
+		return channelFeedbackWeight(channel.GetWeight()*smoothingFactor+smoothingAdjustment, channel.Id, model, feedbackStrength)
+	}
+
 	// Calculate the total weight of all channels up to endIdx
-	totalWeight := sumWeight * smoothingFactor
+	totalWeight := 0
+	for _, channel := range targetChannels {
+		totalWeight += effectiveWeight(channel)
+	}
+
+	channel := pickWeightedChannel(targetChannels, totalWeight, effectiveWeight, nil)
+	if channel == nil {
+		// return null if no channel is not found
+		return nil, errors.New("channel not found")
+	}
 
+	// power of two choices: draw a second channel by weight and keep the less loaded one
+	loadBalance := operation_setting.GetChannelLoadBalanceSetting()
+	if loadBalance.Enabled && len(targetChannels) > 1 && totalWeight > effectiveWeight(channel) {
+		if other := pickWeightedChannel(targetChannels, totalWeight-effectiveWeight(channel), effectiveWeight, channel); other != nil {
+			channel = lessLoadedChannel(channel, effectiveWeight(channel), other, effectiveWeight(other), loadBalance.ErrorPenalty)
+		}
+	}
+	return channel, nil
+}
+
+// filterSatisfiedChannels 返回满足标签约束且服务该端点类型的渠道 ID，调用方需持有 channelSyncLock
+func filterSatisfiedChannels(channelIds []int, selector ChannelLabelSelector, endpoint string) []int {
+	filtered := make([]int, 0, len(channelIds))
+	for _, channelId := range channelIds {
+		channel, ok := channelsIDM[channelId]
+		// 缓存中不存在的渠道保留，由后续的一致性检查报错
+		if !ok || (channel.MatchesLabelSelector(selector) && channel.ServesEndpoint(endpoint)) {
+			filtered = append(filtered, channelId)
+		}
+	}
+	return filtered
+}
+
+// pickWeightedChannel draws a channel with probability proportional to its effective weight,
+// skipping exclude; totalWeight is the sum of the effective weights of the candidates.
+func pickWeightedChannel(channels []*Channel, totalWeight int, effectiveWeight func(*Channel) int, exclude *Channel) *Channel {
+	if totalWeight <= 0 {
+		return nil
+	}
 	// Generate a random value in the range [0, totalWeight)
 	randomWeight := rand.Intn(totalWeight)
 
 	// Find a channel based on its weight
-	for _, channel := range targetChannels {
-		randomWeight -= channel.GetWeight()*smoothingFactor + smoothingAdjustment
+	for _, channel := range channels {
+		if channel == exclude {
+			continue
+		}
+		randomWeight -= effectiveWeight(channel)
 		if randomWeight < 0 {
-			return channel, nil
+			return channel
 		}
 	}
-	// return null if no channel is not found
-	return nil, errors.New("channel not found")
+	return nil
 }
 
 func CacheGetChannel(id int) (*Channel, error) {
@@ -0,0 +1,93 @@
+package model
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/QuantumNous/new-api/common"
+
+	"gorm.io/gorm"
+)
+
+// 渠道可服务的端点类型：渠道声明后只会被分配到对应类型的请求，未声明时服务所有端点。
+// 避免把 embeddings 等请求发给只支持对话的上游，再因其 404 在整个优先级内反复重试
+const (
+	ChannelEndpointChat       = "chat"
+	ChannelEndpointResponses  = "responses"
+	ChannelEndpointEmbeddings = "embeddings"
+	ChannelEndpointImages     = "images"
+	ChannelEndpointAudio      = "audio"
+	ChannelEndpointRerank     = "rerank"
+)
+
+// ChannelEndpoints 端点类型，顺序即规范化后的排列顺序
+var ChannelEndpoints = []string{
+	ChannelEndpointChat,
+	ChannelEndpointResponses,
+	ChannelEndpointEmbeddings,
+	ChannelEndpointImages,
+	ChannelEndpointAudio,
+	ChannelEndpointRerank,
+}
+
+// NormalizeChannelAllowedEndpoints 校验并规范化逗号分隔的端点类型列表，去重并按固定顺序排列
+func NormalizeChannelAllowedEndpoints(raw string) (string, error) {
+	allowed := make(map[string]bool)
+	for _, item := range strings.Split(raw, ",") {
+		item = strings.ToLower(strings.TrimSpace(item))
+		if item == "" {
+			continue
+		}
+		if !common.StringsContains(ChannelEndpoints, item) {
+			return "", fmt.Errorf("不支持的端点类型 %s，可用类型：%s", item, strings.Join(ChannelEndpoints, "、"))
+		}
+		allowed[item] = true
+	}
+	endpoints := make([]string, 0, len(allowed))
+	for _, endpoint := range ChannelEndpoints {
+		if allowed[endpoint] {
+			endpoints = append(endpoints, endpoint)
+		}
+	}
+	return strings.Join(endpoints, ","), nil
+}
+
+func (channel *Channel) GetAllowedEndpoints() string {
+	if channel.AllowedEndpoints == nil {
+		return ""
+	}
+	return *channel.AllowedEndpoints
+}
+
+// ServesEndpoint 判断渠道是否服务该类型的请求，渠道未声明端点类型或请求不属于任何类型时不做限制
+func (channel *Channel) ServesEndpoint(endpoint string) bool {
+	allowed := channel.GetAllowedEndpoints()
+	if endpoint == "" || allowed == "" {
+		return true
+	}
+	return strings.Contains(","+allowed+",", ","+endpoint+",")
+}
+
+// ChannelEndpointScope 在 channels 表的查询上追加端点类型约束
+func ChannelEndpointScope(endpoint string) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		if endpoint == "" {
+			return db
+		}
+		endpointCondition := `(',' || allowed_endpoints || ',') LIKE ?`
+		if common.UsingMySQL {
+			endpointCondition = `CONCAT(',', allowed_endpoints, ',') LIKE ?`
+		}
+		return db.Where("(allowed_endpoints IS NULL OR allowed_endpoints = '' OR "+endpointCondition+")", "%,"+endpoint+",%")
+	}
+}
+
+// abilityEndpointScope 在 abilities 表的查询上追加端点类型约束，请求不属于任何类型时不做任何处理
+func abilityEndpointScope(endpoint string) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		if endpoint == "" {
+			return db
+		}
+		return db.Where("channel_id IN (?)", DB.Model(&Channel{}).Select("id").Scopes(ChannelEndpointScope(endpoint)))
+	}
+}
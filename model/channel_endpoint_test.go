@@ -0,0 +1,50 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNormalizeChannelAllowedEndpoints(t *testing.T) {
+	normalized, err := NormalizeChannelAllowedEndpoints(" Rerank, chat,embeddings,chat ")
+	require.NoError(t, err)
+	assert.Equal(t, "chat,embeddings,rerank", normalized)
+
+	normalized, err = NormalizeChannelAllowedEndpoints("")
+	require.NoError(t, err)
+	assert.Equal(t, "", normalized)
+
+	_, err = NormalizeChannelAllowedEndpoints("chat,video")
+	assert.Error(t, err)
+}
+
+func TestChannelServesEndpoint(t *testing.T) {
+	chatOnly := &Channel{AllowedEndpoints: common.GetPointer("chat,responses")}
+	assert.True(t, chatOnly.ServesEndpoint(ChannelEndpointChat))
+	assert.False(t, chatOnly.ServesEndpoint(ChannelEndpointEmbeddings))
+	// 不属于任何类型的请求不受限制
+	assert.True(t, chatOnly.ServesEndpoint(""))
+	assert.True(t, (&Channel{}).ServesEndpoint(ChannelEndpointEmbeddings))
+}
+
+func TestChannelEndpointScope(t *testing.T) {
+	truncateTables(t)
+
+	channels := []*Channel{
+		{Id: 1, Name: "chat-only", Key: "k1", AllowedEndpoints: common.GetPointer("chat")},
+		{Id: 2, Name: "embeddings", Key: "k2", AllowedEndpoints: common.GetPointer("chat,embeddings")},
+		{Id: 3, Name: "any", Key: "k3", AllowedEndpoints: common.GetPointer("")},
+	}
+	require.NoError(t, DB.Create(&channels).Error)
+
+	var ids []int
+	require.NoError(t, DB.Model(&Channel{}).Scopes(ChannelEndpointScope(ChannelEndpointEmbeddings)).Order("id").Pluck("id", &ids).Error)
+	assert.Equal(t, []int{2, 3}, ids)
+
+	ids = nil
+	require.NoError(t, DB.Model(&Channel{}).Scopes(ChannelEndpointScope("")).Order("id").Pluck("id", &ids).Error)
+	assert.Equal(t, []int{1, 2, 3}, ids)
+}
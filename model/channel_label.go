@@ -0,0 +1,323 @@
+package model
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/QuantumNous/new-api/common"
+
+	"gorm.io/gorm"
+)
+
+// 渠道标签：用固定维度（区域、供应商、层级、合规）的 key:value 描述渠道，多个标签以逗号分隔，
+// 例如 region:eu-west,vendor:openai,tier:premium,compliance:gdpr,compliance:hipaa。
+// region、vendor、tier 每个渠道只能有一个值，compliance 可以有多个值。
+// 标签用于管理后台筛选、令牌的路由约束以及按标签维度的用量统计
+const (
+	ChannelLabelRegion     = "region"
+	ChannelLabelVendor     = "vendor"
+	ChannelLabelTier       = "tier"
+	ChannelLabelCompliance = "compliance"
+)
+
+// ChannelLabelKeys 标签维度，顺序即规范化后的排列顺序
+var ChannelLabelKeys = []string{ChannelLabelRegion, ChannelLabelVendor, ChannelLabelTier, ChannelLabelCompliance}
+
+var channelLabelValueRegex = regexp.MustCompile(`^[a-z0-9][a-z0-9._-]{0,63}$`)
+
+const maxChannelLabelsLength = 1024
+
+// ChannelLabels 渠道标签，维度到取值列表
+type ChannelLabels map[string][]string
+
+// ChannelLabelSelector 标签约束：不同维度之间为“且”，region、vendor、tier 的多个取值为“或”，
+// compliance 的多个取值要求全部具备
+type ChannelLabelSelector map[string][]string
+
+func isMultiValueChannelLabel(key string) bool {
+	return key == ChannelLabelCompliance
+}
+
+func channelLabelKeyIndex(key string) int {
+	for i, k := range ChannelLabelKeys {
+		if k == key {
+			return i
+		}
+	}
+	return -1
+}
+
+// parseChannelLabelPairs 解析 key:value 列表，key 与 value 统一转为小写并去重
+func parseChannelLabelPairs(raw string) (map[string][]string, error) {
+	pairs := make(map[string][]string)
+	for _, item := range strings.Split(raw, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		key, value, ok := strings.Cut(item, ":")
+		key = strings.ToLower(strings.TrimSpace(key))
+		value = strings.ToLower(strings.TrimSpace(value))
+		if !ok || value == "" {
+			return nil, fmt.Errorf("标签 %s 格式错误，应为 key:value", item)
+		}
+		if channelLabelKeyIndex(key) < 0 {
+			return nil, fmt.Errorf("不支持的标签维度 %s，可用维度：%s", key, strings.Join(ChannelLabelKeys, "、"))
+		}
+		if !channelLabelValueRegex.MatchString(value) {
+			return nil, fmt.Errorf("标签 %s 的取值 %s 无效，只能包含小写字母、数字、点、下划线和连字符", key, value)
+		}
+		if !common.StringsContains(pairs[key], value) {
+			pairs[key] = append(pairs[key], value)
+		}
+	}
+	for _, values := range pairs {
+		sort.Strings(values)
+	}
+	return pairs, nil
+}
+
+// ParseChannelLabels 解析渠道标签，region、vendor、tier 出现多个取值时返回错误
+func ParseChannelLabels(raw string) (ChannelLabels, error) {
+	pairs, err := parseChannelLabelPairs(raw)
+	if err != nil {
+		return nil, err
+	}
+	for key, values := range pairs {
+		if len(values) > 1 && !isMultiValueChannelLabel(key) {
+			return nil, fmt.Errorf("标签维度 %s 只能有一个取值", key)
+		}
+	}
+	return ChannelLabels(pairs), nil
+}
+
+// ParseChannelLabelSelector 解析标签约束，语法与渠道标签相同，为空时返回 nil（不约束）
+func ParseChannelLabelSelector(raw string) (ChannelLabelSelector, error) {
+	pairs, err := parseChannelLabelPairs(raw)
+	if err != nil || len(pairs) == 0 {
+		return nil, err
+	}
+	return ChannelLabelSelector(pairs), nil
+}
+
+// String 按维度顺序输出规范化的标签字符串
+func (labels ChannelLabels) String() string {
+	return formatChannelLabelPairs(labels)
+}
+
+func (selector ChannelLabelSelector) String() string {
+	return formatChannelLabelPairs(selector)
+}
+
+func formatChannelLabelPairs(pairs map[string][]string) string {
+	items := make([]string, 0)
+	for _, key := range ChannelLabelKeys {
+		for _, value := range pairs[key] {
+			items = append(items, key+":"+value)
+		}
+	}
+	return strings.Join(items, ",")
+}
+
+// NormalizeChannelLabels 校验并规范化渠道标签字符串
+func NormalizeChannelLabels(raw string) (string, error) {
+	labels, err := ParseChannelLabels(raw)
+	if err != nil {
+		return "", err
+	}
+	normalized := labels.String()
+	if len(normalized) > maxChannelLabelsLength {
+		return "", fmt.Errorf("标签总长度不能超过 %d 个字符", maxChannelLabelsLength)
+	}
+	return normalized, nil
+}
+
+// NormalizeChannelLabelSelector 校验并规范化标签约束字符串
+func NormalizeChannelLabelSelector(raw string) (string, error) {
+	selector, err := ParseChannelLabelSelector(raw)
+	if err != nil {
+		return "", err
+	}
+	normalized := selector.String()
+	if len(normalized) > maxChannelLabelsLength {
+		return "", fmt.Errorf("标签约束总长度不能超过 %d 个字符", maxChannelLabelsLength)
+	}
+	return normalized, nil
+}
+
+func (channel *Channel) GetLabels() string {
+	if channel.Labels == nil {
+		return ""
+	}
+	return *channel.Labels
+}
+
+// GetLabelValues 返回渠道在某个维度上的取值，标签已在保存时规范化，解析失败时视为没有标签
+func (channel *Channel) GetLabelValues(key string) []string {
+	labels, err := ParseChannelLabels(channel.GetLabels())
+	if err != nil {
+		return nil
+	}
+	return labels[key]
+}
+
+// MatchesLabelSelector 判断渠道是否满足标签约束，约束为空时总是满足
+func (channel *Channel) MatchesLabelSelector(selector ChannelLabelSelector) bool {
+	if len(selector) == 0 {
+		return true
+	}
+	labels := "," + channel.GetLabels() + ","
+	for key, values := range selector {
+		matched := 0
+		for _, value := range values {
+			if strings.Contains(labels, ","+key+":"+value+",") {
+				matched++
+			}
+		}
+		if matched == 0 || (isMultiValueChannelLabel(key) && matched < len(values)) {
+			return false
+		}
+	}
+	return true
+}
+
+// ChannelLabelScope 在 channels 表的查询上追加标签约束
+func ChannelLabelScope(selector ChannelLabelSelector) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		labelCondition := `(',' || labels || ',') LIKE ?`
+		if common.UsingMySQL {
+			labelCondition = `CONCAT(',', labels, ',') LIKE ?`
+		}
+		for _, key := range ChannelLabelKeys {
+			values := selector[key]
+			if len(values) == 0 {
+				continue
+			}
+			if isMultiValueChannelLabel(key) {
+				for _, value := range values {
+					db = db.Where(labelCondition, "%,"+key+":"+value+",%")
+				}
+				continue
+			}
+			conditions := make([]string, 0, len(values))
+			args := make([]interface{}, 0, len(values))
+			for _, value := range values {
+				conditions = append(conditions, labelCondition)
+				args = append(args, "%,"+key+":"+value+",%")
+			}
+			db = db.Where("("+strings.Join(conditions, " OR ")+")", args...)
+		}
+		return db
+	}
+}
+
+// abilityLabelScope 在 abilities 表的查询上追加标签约束，约束为空时不做任何处理
+func abilityLabelScope(selector ChannelLabelSelector) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		if len(selector) == 0 {
+			return db
+		}
+		return db.Where("channel_id IN (?)", DB.Model(&Channel{}).Select("id").Scopes(ChannelLabelScope(selector)))
+	}
+}
+
+// GetChannelLabelValues 统计每个维度下各取值对应的渠道数量
+func GetChannelLabelValues() (map[string]map[string]int, error) {
+	var rows []string
+	if err := DB.Model(&Channel{}).Where("labels <> ''").Pluck("labels", &rows).Error; err != nil {
+		return nil, err
+	}
+	values := make(map[string]map[string]int, len(ChannelLabelKeys))
+	for _, key := range ChannelLabelKeys {
+		values[key] = make(map[string]int)
+	}
+	for _, row := range rows {
+		labels, err := ParseChannelLabels(row)
+		if err != nil {
+			continue
+		}
+		for key, labelValues := range labels {
+			for _, value := range labelValues {
+				values[key][value]++
+			}
+		}
+	}
+	return values, nil
+}
+
+// ChannelLabelUsage 某个标签取值下渠道的用量，Value 为空表示没有该维度标签的渠道
+type ChannelLabelUsage struct {
+	Value    string `json:"value"`
+	Quota    int64  `json:"quota"`
+	Requests int64  `json:"requests"`
+	Tokens   int64  `json:"tokens"`
+	Channels int    `json:"channels"`
+}
+
+type channelLogUsage struct {
+	ChannelId int
+	Quota     int64
+	Requests  int64
+	Tokens    int64
+}
+
+// GetChannelLabelUsage 按标签维度汇总时间范围内的消费日志，用量按日志中的渠道归属到渠道当前的标签。
+// compliance 有多个取值的渠道会计入每个取值
+func GetChannelLabelUsage(key string, startTimestamp int64, endTimestamp int64) ([]ChannelLabelUsage, error) {
+	if channelLabelKeyIndex(key) < 0 {
+		return nil, fmt.Errorf("不支持的标签维度 %s", key)
+	}
+	var logUsage []channelLogUsage
+	query := LogReadDB().Table("logs").
+		Select("channel_id, sum(quota) as quota, count(*) as requests, sum(prompt_tokens) + sum(completion_tokens) as tokens").
+		Where("type = ?", LogTypeConsume)
+	if startTimestamp > 0 {
+		query = query.Where("created_at >= ?", startTimestamp)
+	}
+	if endTimestamp > 0 {
+		query = query.Where("created_at < ?", endTimestamp)
+	}
+	if err := query.Group("channel_id").Scan(&logUsage).Error; err != nil {
+		return nil, err
+	}
+	var channels []*Channel
+	if err := DB.Model(&Channel{}).Select("id", "labels").Find(&channels).Error; err != nil {
+		return nil, err
+	}
+	channelValues := make(map[int][]string, len(channels))
+	for _, channel := range channels {
+		channelValues[channel.Id] = channel.GetLabelValues(key)
+	}
+
+	usageByValue := make(map[string]*ChannelLabelUsage)
+	for _, row := range logUsage {
+		values := channelValues[row.ChannelId]
+		if len(values) == 0 {
+			values = []string{""}
+		}
+		for _, value := range values {
+			usage, ok := usageByValue[value]
+			if !ok {
+				usage = &ChannelLabelUsage{Value: value}
+				usageByValue[value] = usage
+			}
+			usage.Quota += row.Quota
+			usage.Requests += row.Requests
+			usage.Tokens += row.Tokens
+			usage.Channels++
+		}
+	}
+	result := make([]ChannelLabelUsage, 0, len(usageByValue))
+	for _, usage := range usageByValue {
+		result = append(result, *usage)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Quota != result[j].Quota {
+			return result[i].Quota > result[j].Quota
+		}
+		return result[i].Value < result[j].Value
+	})
+	return result, nil
+}
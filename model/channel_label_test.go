@@ -0,0 +1,98 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNormalizeChannelLabels(t *testing.T) {
+	labels, err := NormalizeChannelLabels(" Compliance:HIPAA, tier:premium,region:eu-west ,compliance:gdpr,compliance:hipaa")
+	require.NoError(t, err)
+	assert.Equal(t, "region:eu-west,tier:premium,compliance:gdpr,compliance:hipaa", labels)
+
+	labels, err = NormalizeChannelLabels("")
+	require.NoError(t, err)
+	assert.Equal(t, "", labels)
+
+	_, err = NormalizeChannelLabels("region:eu,region:us")
+	assert.Error(t, err)
+	_, err = NormalizeChannelLabels("owner:alice")
+	assert.Error(t, err)
+	_, err = NormalizeChannelLabels("region")
+	assert.Error(t, err)
+	_, err = NormalizeChannelLabels("region:eu west")
+	assert.Error(t, err)
+}
+
+func TestChannelMatchesLabelSelector(t *testing.T) {
+	channel := &Channel{Labels: common.GetPointer("region:eu-west,vendor:openai,compliance:gdpr,compliance:hipaa")}
+
+	cases := []struct {
+		selector string
+		matched  bool
+	}{
+		{"", true},
+		{"region:eu-west", true},
+		{"region:us-east,region:eu-west", true},
+		{"region:us-east", false},
+		{"region:eu-west,tier:premium", false},
+		{"compliance:gdpr,compliance:hipaa", true},
+		{"compliance:gdpr,compliance:soc2", false},
+		{"vendor:open", false},
+	}
+	for _, tc := range cases {
+		selector, err := ParseChannelLabelSelector(tc.selector)
+		require.NoError(t, err)
+		assert.Equal(t, tc.matched, channel.MatchesLabelSelector(selector), tc.selector)
+	}
+	assert.True(t, (&Channel{}).MatchesLabelSelector(nil))
+}
+
+func TestChannelLabelScopeAndUsage(t *testing.T) {
+	truncateTables(t)
+
+	channels := []*Channel{
+		{Id: 1, Name: "eu-gdpr", Key: "k1", Labels: common.GetPointer("region:eu-west,compliance:gdpr")},
+		{Id: 2, Name: "us", Key: "k2", Labels: common.GetPointer("region:us-east")},
+		{Id: 3, Name: "plain", Key: "k3", Labels: common.GetPointer("")},
+	}
+	require.NoError(t, DB.Create(&channels).Error)
+
+	selector, err := ParseChannelLabelSelector("region:eu-west,region:us-east")
+	require.NoError(t, err)
+	var ids []int
+	require.NoError(t, DB.Model(&Channel{}).Scopes(ChannelLabelScope(selector)).Order("id").Pluck("id", &ids).Error)
+	assert.Equal(t, []int{1, 2}, ids)
+
+	selector, err = ParseChannelLabelSelector("region:eu-west,compliance:gdpr")
+	require.NoError(t, err)
+	ids = nil
+	require.NoError(t, DB.Model(&Channel{}).Scopes(ChannelLabelScope(selector)).Pluck("id", &ids).Error)
+	assert.Equal(t, []int{1}, ids)
+
+	logs := []*Log{
+		{Type: LogTypeConsume, ChannelId: 1, Quota: 100, PromptTokens: 10, CompletionTokens: 5, CreatedAt: 1000},
+		{Type: LogTypeConsume, ChannelId: 2, Quota: 300, PromptTokens: 20, CompletionTokens: 5, CreatedAt: 1000},
+		{Type: LogTypeConsume, ChannelId: 3, Quota: 50, CreatedAt: 1000},
+		{Type: LogTypeError, ChannelId: 1, CreatedAt: 1000},
+	}
+	require.NoError(t, LOG_DB.Create(&logs).Error)
+
+	usage, err := GetChannelLabelUsage(ChannelLabelRegion, 0, 0)
+	require.NoError(t, err)
+	require.Len(t, usage, 3)
+	assert.Equal(t, ChannelLabelUsage{Value: "us-east", Quota: 300, Requests: 1, Tokens: 25, Channels: 1}, usage[0])
+	assert.Equal(t, ChannelLabelUsage{Value: "eu-west", Quota: 100, Requests: 1, Tokens: 15, Channels: 1}, usage[1])
+	assert.Equal(t, "", usage[2].Value)
+
+	values, err := GetChannelLabelValues()
+	require.NoError(t, err)
+	assert.Equal(t, map[string]int{"eu-west": 1, "us-east": 1}, values[ChannelLabelRegion])
+	assert.Equal(t, map[string]int{"gdpr": 1}, values[ChannelLabelCompliance])
+
+	_, err = GetChannelLabelUsage("owner", 0, 0)
+	assert.Error(t, err)
+}
@@ -0,0 +1,90 @@
+package model
+
+import (
+	"math"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+)
+
+// 渠道负载统计：记录本节点上每个渠道进行中的请求数与近期错误率，供负载感知的渠道选择使用。
+// 统计只在内存中，多节点部署时每个节点各自统计
+const channelErrorRateWeight = 0.9 // 每个请求结束时旧错误率保留的比例
+
+type channelLoad struct {
+	inflight atomic.Int64
+
+	mu        sync.Mutex
+	errorRate float64
+	updatedAt time.Time
+}
+
+var channelLoads sync.Map // 渠道 ID -> *channelLoad
+
+func getChannelLoad(channelId int) *channelLoad {
+	if load, ok := channelLoads.Load(channelId); ok {
+		return load.(*channelLoad)
+	}
+	load, _ := channelLoads.LoadOrStore(channelId, &channelLoad{})
+	return load.(*channelLoad)
+}
+
+// BeginChannelRequest 记录渠道开始处理一个请求，返回的函数在请求结束时调用，failed 表示渠道处理失败
+func BeginChannelRequest(channelId int) func(failed bool) {
+	load := getChannelLoad(channelId)
+	load.inflight.Add(1)
+	var once sync.Once
+	return func(failed bool) {
+		once.Do(func() {
+			load.inflight.Add(-1)
+			load.recordResult(failed, time.Now())
+		})
+	}
+}
+
+func (l *channelLoad) recordResult(failed bool, now time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	rate := l.decayedErrorRate(now) * channelErrorRateWeight
+	if failed {
+		rate += 1 - channelErrorRateWeight
+	}
+	l.errorRate = rate
+	l.updatedAt = now
+}
+
+// decayedErrorRate 返回按半衰期衰减到 now 的错误率，调用方须持有 mu
+func (l *channelLoad) decayedErrorRate(now time.Time) float64 {
+	halfLife := channelLoadBalanceHalfLife()
+	if l.errorRate == 0 || l.updatedAt.IsZero() || halfLife <= 0 {
+		return l.errorRate
+	}
+	elapsed := now.Sub(l.updatedAt)
+	if elapsed <= 0 {
+		return l.errorRate
+	}
+	return l.errorRate * math.Pow(0.5, elapsed.Seconds()/halfLife.Seconds())
+}
+
+// score 返回渠道的负载分数，越低越优先：(进行中请求数 + 1) * (1 + 错误率惩罚) / 有效权重
+func (l *channelLoad) score(weight int, errorPenalty float64, now time.Time) float64 {
+	l.mu.Lock()
+	errorRate := l.decayedErrorRate(now)
+	l.mu.Unlock()
+	return float64(l.inflight.Load()+1) * (1 + errorPenalty*errorRate) / float64(max(weight, 1))
+}
+
+func channelLoadBalanceHalfLife() time.Duration {
+	return time.Duration(operation_setting.GetChannelLoadBalanceSetting().ErrorHalfLifeSeconds) * time.Second
+}
+
+// lessLoadedChannel 在两个候选渠道中返回负载分数更低的一个，分数相同时返回 a
+func lessLoadedChannel(a *Channel, aWeight int, b *Channel, bWeight int, errorPenalty float64) *Channel {
+	now := time.Now()
+	if getChannelLoad(b.Id).score(bWeight, errorPenalty, now) < getChannelLoad(a.Id).score(aWeight, errorPenalty, now) {
+		return b
+	}
+	return a
+}
@@ -0,0 +1,43 @@
+package model
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLessLoadedChannelPrefersIdleAndHealthyChannels(t *testing.T) {
+	busy, idle := &Channel{Id: 90001}, &Channel{Id: 90002}
+	t.Cleanup(func() {
+		channelLoads.Delete(busy.Id)
+		channelLoads.Delete(idle.Id)
+	})
+
+	finish := BeginChannelRequest(busy.Id)
+	require.Same(t, idle, lessLoadedChannel(busy, 10, idle, 10, 4))
+	// 权重更高的渠道可以承担更多进行中的请求
+	require.Same(t, busy, lessLoadedChannel(busy, 30, idle, 10, 4))
+	finish(false)
+	finish(false)
+	require.Equal(t, int64(0), getChannelLoad(busy.Id).inflight.Load())
+
+	for i := 0; i < 5; i++ {
+		BeginChannelRequest(busy.Id)(true)
+	}
+	require.Same(t, idle, lessLoadedChannel(busy, 10, idle, 10, 4))
+	require.Same(t, busy, lessLoadedChannel(busy, 10, idle, 10, 0))
+}
+
+func TestChannelErrorRateDecays(t *testing.T) {
+	load := &channelLoad{}
+	now := time.Now()
+	load.recordResult(true, now)
+	require.InDelta(t, 1-channelErrorRateWeight, load.errorRate, 1e-9)
+
+	halfLife := channelLoadBalanceHalfLife()
+	load.mu.Lock()
+	decayed := load.decayedErrorRate(now.Add(halfLife))
+	load.mu.Unlock()
+	require.InDelta(t, (1-channelErrorRateWeight)/2, decayed, 1e-9)
+}
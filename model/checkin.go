@@ -105,6 +105,9 @@ func userCheckinWithTransaction(checkin *Checkin, userId int, quotaAwarded int)
 			Update("quota", gorm.Expr("quota + ?", quotaAwarded)).Error; err != nil {
 			return errors.New("签到失败：更新额度出错")
 		}
+		if err := RecordQuotaPool(tx, userId, QuotaPoolKindBonus, quotaAwarded, "checkin"); err != nil {
+			return errors.New("签到失败：记录额度池出错")
+		}
 
 		return nil
 	})
@@ -136,6 +139,9 @@ func userCheckinWithoutTransaction(checkin *Checkin, userId int, quotaAwarded in
 		DB.Delete(checkin)
 		return nil, errors.New("签到失败：更新额度出错")
 	}
+	if err := RecordQuotaPool(nil, userId, QuotaPoolKindBonus, quotaAwarded, "checkin"); err != nil {
+		common.SysLog("failed to record checkin quota pool: " + err.Error())
+	}
 
 	return checkin, nil
 }
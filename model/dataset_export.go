@@ -0,0 +1,86 @@
+package model
+
+import (
+	"errors"
+
+	"gorm.io/gorm"
+)
+
+// 训练数据集导出任务：按条件筛选兼容层保存的对话，生成 OpenAI 微调（sft）或偏好（preference）格式的 JSONL，
+// 保存为网关文件（可经 /v1/files 下载）或上传到 S3
+const (
+	DatasetExportFormatSFT        = "sft"
+	DatasetExportFormatPreference = "preference"
+
+	DatasetExportDestinationFile = "file"
+	DatasetExportDestinationS3   = "s3"
+
+	DatasetExportStatusPending   = "pending"
+	DatasetExportStatusRunning   = "running"
+	DatasetExportStatusSucceeded = "succeeded"
+	DatasetExportStatusFailed    = "failed"
+)
+
+// DatasetExport 一次数据集导出任务及其结果统计
+type DatasetExport struct {
+	Id          int    `json:"id"`
+	CreatedBy   int    `json:"created_by" gorm:"index"`
+	Format      string `json:"format" gorm:"type:varchar(16)"`
+	Destination string `json:"destination" gorm:"type:varchar(16)"`
+	Filter      string `json:"filter" gorm:"type:text"` // DatasetResponseFilter 的 JSON
+	RedactPII   bool   `json:"redact_pii"`
+	Status      string `json:"status" gorm:"type:varchar(16);index"`
+	Scanned     int    `json:"scanned"`    // 满足条件的对话数
+	Records     int    `json:"records"`    // 写入的 JSONL 行数
+	Skipped     int    `json:"skipped"`    // 无法转换为训练样本的对话数
+	Redactions  string `json:"redactions"` // 各类敏感信息的替换次数，JSON 对象
+	Bytes       int64  `json:"bytes"`
+	Truncated   bool   `json:"truncated"` // 达到文件大小上限，后续样本未写入
+	FileId      string `json:"file_id" gorm:"type:varchar(64)"`
+	Location    string `json:"location" gorm:"type:varchar(512)"`
+	Error       string `json:"error" gorm:"type:text"`
+	CreatedAt   int64  `json:"created_at" gorm:"bigint"`
+	CompletedAt int64  `json:"completed_at" gorm:"bigint"`
+}
+
+func IsValidDatasetExportFormat(format string) bool {
+	return format == DatasetExportFormatSFT || format == DatasetExportFormatPreference
+}
+
+func IsValidDatasetExportDestination(destination string) bool {
+	return destination == DatasetExportDestinationFile || destination == DatasetExportDestinationS3
+}
+
+func CreateDatasetExport(export *DatasetExport) error {
+	return DB.Create(export).Error
+}
+
+// UpdateDatasetExport 写回任务的状态与结果
+func UpdateDatasetExport(export *DatasetExport) error {
+	return DB.Model(export).Select("status", "scanned", "records", "skipped", "redactions", "bytes", "truncated",
+		"file_id", "location", "error", "completed_at").Updates(export).Error
+}
+
+// GetDatasetExport 获取导出任务，不存在时返回 nil
+func GetDatasetExport(id int) (*DatasetExport, error) {
+	var export DatasetExport
+	err := DB.First(&export, id).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &export, nil
+}
+
+// GetDatasetExports 按创建时间倒序分页返回导出任务
+func GetDatasetExports(startIdx int, num int) ([]*DatasetExport, int64, error) {
+	var exports []*DatasetExport
+	var total int64
+	if err := DB.Model(&DatasetExport{}).Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	err := DB.Order("id desc").Offset(startIdx).Limit(num).Find(&exports).Error
+	return exports, total, err
+}
@@ -0,0 +1,72 @@
+package model
+
+import (
+	"fmt"
+
+	"github.com/QuantumNous/new-api/common"
+	"gorm.io/gorm"
+)
+
+// SchemaIssues 返回数据库结构与当前版本的差异：AutoMigrate 尚未创建的表与列、待执行的版本化迁移，供 doctor 命令使用
+func SchemaIssues() ([]string, error) {
+	issues := make([]string, 0)
+	for _, m := range mainDBModels {
+		missing, err := missingColumns(DB, m.model)
+		if err != nil {
+			return nil, fmt.Errorf("failed to inspect table of %s: %w", m.name, err)
+		}
+		issues = append(issues, missing...)
+	}
+	for _, logModel := range []interface{}{&Log{}, &RequestCapture{}, &RequestFeedback{}} {
+		missing, err := missingColumns(LOG_DB, logModel)
+		if err != nil {
+			return nil, err
+		}
+		issues = append(issues, missing...)
+	}
+	for _, target := range migrationTargets() {
+		statuses, err := migrationStatus(migrations, target)
+		if err != nil {
+			return nil, err
+		}
+		for _, status := range statuses {
+			if !status.Applied && status.Skipped == "" {
+				issues = append(issues, fmt.Sprintf("migration %s (%s) is pending on the %s database", status.Version, status.Name, target))
+			}
+		}
+	}
+	return issues, nil
+}
+
+func missingColumns(db *gorm.DB, value interface{}) ([]string, error) {
+	stmt := &gorm.Statement{DB: db}
+	if err := stmt.Parse(value); err != nil {
+		return nil, err
+	}
+	table := stmt.Schema.Table
+	migrator := db.Migrator()
+	if !migrator.HasTable(table) {
+		return []string{fmt.Sprintf("table %s is missing", table)}, nil
+	}
+	missing := make([]string, 0)
+	for _, field := range stmt.Schema.Fields {
+		if field.DBName == "" || field.IgnoreMigration {
+			continue
+		}
+		if !migrator.HasColumn(value, field.DBName) {
+			missing = append(missing, fmt.Sprintf("column %s.%s is missing", table, field.DBName))
+		}
+	}
+	return missing, nil
+}
+
+// DatabaseVersion 返回 target（MigrationTargetMain / MigrationTargetLog）数据库的类型与服务端版本
+func DatabaseVersion(target string) (dialect string, version string, err error) {
+	dialect = migrationDialect(target)
+	query := "SELECT version()"
+	if dialect == common.DatabaseTypeSQLite {
+		query = "SELECT sqlite_version()"
+	}
+	err = migrationDB(target).Raw(query).Scan(&version).Error
+	return dialect, version, err
+}
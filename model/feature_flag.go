@@ -0,0 +1,278 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/types"
+)
+
+const featureFlagCacheTTL = time.Minute
+
+// FeatureFlag gates a new behavior for part of the traffic so it can be rolled out
+// gradually. An enabled flag is on for the listed groups and tokens, and for
+// Percentage percent of the remaining tokens (users for requests without a token).
+// When Endpoints is set, the flag is off for requests of any other endpoint, identified
+// by the relay format of the client request (openai, openai_responses, claude, ...).
+// Bucketing is stable: the same token stays in or out as long as the percentage does
+// not shrink below its bucket.
+type FeatureFlag struct {
+	Id          int    `json:"id"`
+	Name        string `json:"name" gorm:"type:varchar(64);uniqueIndex;not null"`
+	Description string `json:"description" gorm:"type:varchar(255)"`
+	Enabled     bool   `json:"enabled"`
+	Percentage  int    `json:"percentage" gorm:"default:0"`                      // 0-100
+	Groups      string `json:"groups" gorm:"type:text;column:flag_groups"`       // comma separated groups always enabled
+	TokenIds    string `json:"token_ids" gorm:"type:text;column:flag_tokens"`    // comma separated token ids always enabled
+	Endpoints   string `json:"endpoints" gorm:"type:text;column:flag_endpoints"` // comma separated relay formats the flag is limited to, empty for all
+	CreatedTime int64  `json:"created_time" gorm:"bigint"`
+	UpdatedTime int64  `json:"updated_time" gorm:"bigint"`
+
+	groupSet    map[string]struct{}
+	tokenSet    map[int]struct{}
+	endpointSet map[string]struct{}
+}
+
+// featureFlagEndpoints are the relay formats a flag can be limited to.
+var featureFlagEndpoints = []types.RelayFormat{
+	types.RelayFormatOpenAI,
+	types.RelayFormatClaude,
+	types.RelayFormatGemini,
+	types.RelayFormatOpenAIResponses,
+	types.RelayFormatOpenAIResponsesCompaction,
+	types.RelayFormatOpenAIAudio,
+	types.RelayFormatOpenAIImage,
+	types.RelayFormatOpenAIRealtime,
+	types.RelayFormatRerank,
+	types.RelayFormatEmbedding,
+}
+
+// IsEnabledFor reports whether the flag is on for a request of the endpoint, group,
+// token and user.
+func (f *FeatureFlag) IsEnabledFor(endpoint types.RelayFormat, group string, tokenId int, userId int) bool {
+	if f == nil || !f.Enabled {
+		return false
+	}
+	if f.groupSet == nil || f.tokenSet == nil || f.endpointSet == nil {
+		f.buildSets()
+	}
+	if _, ok := f.endpointSet[string(endpoint)]; len(f.endpointSet) > 0 && !ok {
+		return false
+	}
+	if _, ok := f.groupSet[group]; ok && group != "" {
+		return true
+	}
+	if _, ok := f.tokenSet[tokenId]; ok && tokenId > 0 {
+		return true
+	}
+	if f.Percentage >= 100 {
+		return true
+	}
+	if f.Percentage <= 0 {
+		return false
+	}
+	subject := "u" + strconv.Itoa(userId)
+	if tokenId > 0 {
+		subject = "t" + strconv.Itoa(tokenId)
+	}
+	return featureFlagBucket(f.Name, subject) < f.Percentage
+}
+
+func (f *FeatureFlag) buildSets() {
+	f.groupSet = make(map[string]struct{})
+	for _, group := range splitFeatureFlagList(f.Groups) {
+		f.groupSet[group] = struct{}{}
+	}
+	f.tokenSet = make(map[int]struct{})
+	for _, item := range splitFeatureFlagList(f.TokenIds) {
+		if id, err := strconv.Atoi(item); err == nil {
+			f.tokenSet[id] = struct{}{}
+		}
+	}
+	f.endpointSet = make(map[string]struct{})
+	for _, endpoint := range splitFeatureFlagList(f.Endpoints) {
+		f.endpointSet[endpoint] = struct{}{}
+	}
+}
+
+// featureFlagBucket maps the subject to a bucket in [0, 100); the flag name is mixed in
+// so different flags do not select the same slice of traffic.
+func featureFlagBucket(name string, subject string) int {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(name + ":" + subject))
+	return int(h.Sum32() % 100)
+}
+
+func splitFeatureFlagList(value string) []string {
+	items := make([]string, 0)
+	for _, item := range strings.Split(value, ",") {
+		item = strings.TrimSpace(item)
+		if item != "" && !common.StringsContains(items, item) {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+func normalizeFeatureFlag(flag *FeatureFlag) {
+	flag.Name = strings.ToLower(strings.TrimSpace(flag.Name))
+	flag.Description = strings.TrimSpace(flag.Description)
+	flag.Groups = strings.Join(splitFeatureFlagList(flag.Groups), ",")
+	flag.TokenIds = strings.Join(splitFeatureFlagList(flag.TokenIds), ",")
+	flag.Endpoints = strings.Join(splitFeatureFlagList(strings.ToLower(flag.Endpoints)), ",")
+	flag.groupSet, flag.tokenSet, flag.endpointSet = nil, nil, nil
+}
+
+func validateFeatureFlag(flag *FeatureFlag) error {
+	if flag.Name == "" {
+		return errors.New("feature flag name is required")
+	}
+	for _, r := range flag.Name {
+		if !(r >= 'a' && r <= 'z' || r >= '0' && r <= '9' || r == '_' || r == '.' || r == '-') {
+			return errors.New("feature flag name may only contain lowercase letters, digits, '_', '.' and '-'")
+		}
+	}
+	if flag.Percentage < 0 || flag.Percentage > 100 {
+		return errors.New("feature flag percentage must be between 0 and 100")
+	}
+	for _, item := range splitFeatureFlagList(flag.TokenIds) {
+		if id, err := strconv.Atoi(item); err != nil || id <= 0 {
+			return errors.New("feature flag token ids must be positive integers")
+		}
+	}
+	for _, endpoint := range splitFeatureFlagList(flag.Endpoints) {
+		if !slices.Contains(featureFlagEndpoints, types.RelayFormat(endpoint)) {
+			return fmt.Errorf("unknown feature flag endpoint %q", endpoint)
+		}
+	}
+	return nil
+}
+
+func GetAllFeatureFlags() ([]*FeatureFlag, error) {
+	var flags []*FeatureFlag
+	err := DB.Order("name asc").Find(&flags).Error
+	return flags, err
+}
+
+func GetFeatureFlagById(id int) (*FeatureFlag, error) {
+	var flag FeatureFlag
+	if err := DB.First(&flag, id).Error; err != nil {
+		return nil, err
+	}
+	return &flag, nil
+}
+
+// IsFeatureFlagNameTaken reports whether the name is used by another flag.
+func IsFeatureFlagNameTaken(name string, excludeId int) bool {
+	var count int64
+	query := DB.Model(&FeatureFlag{}).Where("name = ?", strings.ToLower(strings.TrimSpace(name)))
+	if excludeId > 0 {
+		query = query.Where("id != ?", excludeId)
+	}
+	if err := query.Count(&count).Error; err != nil {
+		return true
+	}
+	return count > 0
+}
+
+func CreateFeatureFlag(flag *FeatureFlag) error {
+	normalizeFeatureFlag(flag)
+	if err := validateFeatureFlag(flag); err != nil {
+		return err
+	}
+	flag.CreatedTime = common.GetTimestamp()
+	flag.UpdatedTime = flag.CreatedTime
+	if err := DB.Create(flag).Error; err != nil {
+		return err
+	}
+	InvalidateFeatureFlagCache()
+	return nil
+}
+
+func UpdateFeatureFlag(flag *FeatureFlag) error {
+	normalizeFeatureFlag(flag)
+	if err := validateFeatureFlag(flag); err != nil {
+		return err
+	}
+	flag.UpdatedTime = common.GetTimestamp()
+	if err := DB.Model(&FeatureFlag{}).Where("id = ?", flag.Id).Select(
+		"name", "description", "enabled", "percentage", "flag_groups", "flag_tokens", "flag_endpoints", "updated_time",
+	).Updates(flag).Error; err != nil {
+		return err
+	}
+	InvalidateFeatureFlagCache()
+	return nil
+}
+
+func DeleteFeatureFlag(id int) error {
+	if err := DB.Delete(&FeatureFlag{}, id).Error; err != nil {
+		return err
+	}
+	InvalidateFeatureFlagCache()
+	return nil
+}
+
+// flags are checked on the relay hot path, so they are served from an in-memory snapshot
+// that is reloaded on writes and at most featureFlagCacheTTL old on other nodes.
+var (
+	featureFlagCacheLock     sync.RWMutex
+	featureFlagCacheLoadedAt time.Time
+	featureFlagCacheByName   map[string]*FeatureFlag
+)
+
+func InvalidateFeatureFlagCache() {
+	featureFlagCacheLock.Lock()
+	featureFlagCacheLoadedAt = time.Time{}
+	featureFlagCacheLock.Unlock()
+}
+
+func ensureFeatureFlagCache() {
+	featureFlagCacheLock.RLock()
+	fresh := !featureFlagCacheLoadedAt.IsZero() && time.Since(featureFlagCacheLoadedAt) < featureFlagCacheTTL
+	featureFlagCacheLock.RUnlock()
+	if fresh || DB == nil {
+		return
+	}
+
+	featureFlagCacheLock.Lock()
+	defer featureFlagCacheLock.Unlock()
+	if !featureFlagCacheLoadedAt.IsZero() && time.Since(featureFlagCacheLoadedAt) < featureFlagCacheTTL {
+		return
+	}
+	flags, err := GetAllFeatureFlags()
+	if err != nil {
+		common.SysError("failed to load feature flags: " + err.Error())
+		// keep serving the previous snapshot and retry on the next TTL
+		featureFlagCacheLoadedAt = time.Now()
+		return
+	}
+	byName := make(map[string]*FeatureFlag, len(flags))
+	for _, flag := range flags {
+		// sets are built before publishing so readers never mutate shared flags
+		flag.buildSets()
+		byName[flag.Name] = flag
+	}
+	featureFlagCacheByName = byName
+	featureFlagCacheLoadedAt = time.Now()
+}
+
+// GetCachedFeatureFlag returns the flag by name, nil if it does not exist.
+func GetCachedFeatureFlag(name string) *FeatureFlag {
+	ensureFeatureFlagCache()
+	featureFlagCacheLock.RLock()
+	defer featureFlagCacheLock.RUnlock()
+	return featureFlagCacheByName[name]
+}
+
+// IsFeatureFlagEnabled reports whether the named flag is on for the request; unknown
+// flags are off.
+func IsFeatureFlagEnabled(name string, endpoint types.RelayFormat, group string, tokenId int, userId int) bool {
+	return GetCachedFeatureFlag(name).IsEnabledFor(endpoint, group, tokenId, userId)
+}
@@ -0,0 +1,77 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/QuantumNous/new-api/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFeatureFlagRollout(t *testing.T) {
+	flag := &FeatureFlag{Name: "new_adapter", Enabled: true, Percentage: 5, Groups: "beta", TokenIds: "42"}
+	assert.True(t, flag.IsEnabledFor(types.RelayFormatOpenAI, "beta", 1, 1))
+	assert.True(t, flag.IsEnabledFor(types.RelayFormatOpenAI, "default", 42, 1))
+
+	rollout := &FeatureFlag{Name: "new_adapter", Enabled: true, Percentage: 5}
+	enabled := 0
+	for tokenId := 1; tokenId <= 10000; tokenId++ {
+		if rollout.IsEnabledFor(types.RelayFormatOpenAI, "default", tokenId, 0) {
+			enabled++
+		}
+	}
+	assert.InDelta(t, 500, enabled, 150)
+	// bucketing is stable and widening the rollout keeps already enabled tokens
+	wider := &FeatureFlag{Name: "new_adapter", Enabled: true, Percentage: 50}
+	for tokenId := 1; tokenId <= 1000; tokenId++ {
+		if rollout.IsEnabledFor(types.RelayFormatOpenAI, "default", tokenId, 0) {
+			assert.True(t, wider.IsEnabledFor(types.RelayFormatOpenAI, "default", tokenId, 0))
+		}
+	}
+
+	flag.Enabled = false
+	assert.False(t, flag.IsEnabledFor(types.RelayFormatOpenAI, "beta", 42, 1))
+	assert.False(t, (*FeatureFlag)(nil).IsEnabledFor(types.RelayFormatOpenAI, "beta", 42, 1))
+	assert.True(t, (&FeatureFlag{Name: "all", Enabled: true, Percentage: 100}).IsEnabledFor(types.RelayFormatOpenAI, "", 0, 0))
+
+	// endpoint scoping limits the listed groups and tokens as well
+	scoped := &FeatureFlag{Name: "new_adapter", Enabled: true, Percentage: 100, Groups: "beta", Endpoints: "openai_responses"}
+	assert.True(t, scoped.IsEnabledFor(types.RelayFormatOpenAIResponses, "default", 1, 1))
+	assert.False(t, scoped.IsEnabledFor(types.RelayFormatOpenAI, "beta", 1, 1))
+}
+
+func TestFeatureFlagCache(t *testing.T) {
+	t.Cleanup(func() {
+		DB.Exec("DELETE FROM feature_flags")
+		InvalidateFeatureFlagCache()
+	})
+
+	flag := &FeatureFlag{Name: " Stream_Guard ", Enabled: true, Groups: "beta, beta,vip", TokenIds: "7"}
+	require.NoError(t, CreateFeatureFlag(flag))
+	assert.Equal(t, "stream_guard", flag.Name)
+	assert.Equal(t, "beta,vip", flag.Groups)
+	assert.True(t, IsFeatureFlagNameTaken("stream_guard", 0))
+	assert.False(t, IsFeatureFlagNameTaken("stream_guard", flag.Id))
+
+	assert.True(t, IsFeatureFlagEnabled("stream_guard", types.RelayFormatOpenAI, "vip", 0, 1))
+	assert.False(t, IsFeatureFlagEnabled("stream_guard", types.RelayFormatOpenAI, "default", 1, 1))
+	assert.False(t, IsFeatureFlagEnabled("unknown", types.RelayFormatOpenAI, "vip", 7, 1))
+
+	flag.Percentage = 100
+	require.NoError(t, UpdateFeatureFlag(flag))
+	assert.True(t, IsFeatureFlagEnabled("stream_guard", types.RelayFormatOpenAI, "default", 1, 1))
+
+	require.NoError(t, DeleteFeatureFlag(flag.Id))
+	assert.False(t, IsFeatureFlagEnabled("stream_guard", types.RelayFormatOpenAI, "vip", 7, 1))
+
+	assert.Error(t, CreateFeatureFlag(&FeatureFlag{Name: "bad name"}))
+	assert.Error(t, CreateFeatureFlag(&FeatureFlag{Name: "pct", Percentage: 101}))
+	assert.Error(t, CreateFeatureFlag(&FeatureFlag{Name: "tokens", TokenIds: "abc"}))
+	assert.Error(t, CreateFeatureFlag(&FeatureFlag{Name: "endpoints", Endpoints: "chat"}))
+
+	endpoints := &FeatureFlag{Name: "scoped", Enabled: true, Percentage: 100, Endpoints: " Claude, openai_responses,claude"}
+	require.NoError(t, CreateFeatureFlag(endpoints))
+	assert.Equal(t, "claude,openai_responses", endpoints.Endpoints)
+	assert.True(t, IsFeatureFlagEnabled("scoped", types.RelayFormatClaude, "default", 1, 1))
+	assert.False(t, IsFeatureFlagEnabled("scoped", types.RelayFormatOpenAI, "default", 1, 1))
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/QuantumNous/new-api/common"
@@ -148,7 +149,13 @@ type RecordConsumeLogParams struct {
 	Other            map[string]interface{} `json:"other"`
 }
 
+// OnConsumeLog 在每次记录消费日志时调用（不受 LogConsumeEnabled 影响），由 main 注入，用于令牌用量推送等
+var OnConsumeLog func(c *gin.Context, userId int, params RecordConsumeLogParams)
+
 func RecordConsumeLog(c *gin.Context, userId int, params RecordConsumeLogParams) {
+	if OnConsumeLog != nil {
+		OnConsumeLog(c, userId, params)
+	}
 	if !common.LogConsumeEnabled {
 		return
 	}
@@ -245,9 +252,9 @@ func RecordTaskBillingLog(params RecordTaskBillingLogParams) {
 func GetAllLogs(logType int, startTimestamp int64, endTimestamp int64, modelName string, username string, tokenName string, startIdx int, num int, channel int, group string, requestId string) (logs []*Log, total int64, err error) {
 	var tx *gorm.DB
 	if logType == LogTypeUnknown {
-		tx = LOG_DB
+		tx = LogReadDB()
 	} else {
-		tx = LOG_DB.Where("logs.type = ?", logType)
+		tx = LogReadDB().Where("logs.type = ?", logType)
 	}
 
 	if modelName != "" {
@@ -310,7 +317,7 @@ func GetAllLogs(logType int, startTimestamp int64, endTimestamp int64, modelName
 			}
 		} else {
 			// Bulk query channels from DB
-			if err = DB.Table("channels").Select("id, name").Where("id IN ?", channelIds.Items()).Find(&channels).Error; err != nil {
+			if err = ReadDB().Table("channels").Select("id, name").Where("id IN ?", channelIds.Items()).Find(&channels).Error; err != nil {
 				return logs, total, err
 			}
 		}
@@ -331,9 +338,9 @@ const logSearchCountLimit = 10000
 func GetUserLogs(userId int, logType int, startTimestamp int64, endTimestamp int64, modelName string, tokenName string, startIdx int, num int, group string, requestId string) (logs []*Log, total int64, err error) {
 	var tx *gorm.DB
 	if logType == LogTypeUnknown {
-		tx = LOG_DB.Where("logs.user_id = ?", userId)
+		tx = LogReadDB().Where("logs.user_id = ?", userId)
 	} else {
-		tx = LOG_DB.Where("logs.user_id = ? and logs.type = ?", userId, logType)
+		tx = LogReadDB().Where("logs.user_id = ? and logs.type = ?", userId, logType)
 	}
 
 	if modelName != "" {
@@ -380,10 +387,10 @@ type Stat struct {
 }
 
 func SumUsedQuota(logType int, startTimestamp int64, endTimestamp int64, modelName string, username string, tokenName string, channel int, group string) (stat Stat, err error) {
-	tx := LOG_DB.Table("logs").Select("sum(quota) quota")
+	tx := LogReadDB().Table("logs").Select("sum(quota) quota")
 
 	// 为rpm和tpm创建单独的查询
-	rpmTpmQuery := LOG_DB.Table("logs").Select("count(*) rpm, sum(prompt_tokens) + sum(completion_tokens) tpm")
+	rpmTpmQuery := LogReadDB().Table("logs").Select("count(*) rpm, sum(prompt_tokens) + sum(completion_tokens) tpm")
 
 	if username != "" {
 		tx = tx.Where("username = ?", username)
@@ -436,7 +443,7 @@ func SumUsedQuota(logType int, startTimestamp int64, endTimestamp int64, modelNa
 }
 
 func SumUsedToken(logType int, startTimestamp int64, endTimestamp int64, modelName string, username string, tokenName string) (token int) {
-	tx := LOG_DB.Table("logs").Select("ifnull(sum(prompt_tokens),0) + ifnull(sum(completion_tokens),0)")
+	tx := LogReadDB().Table("logs").Select("ifnull(sum(prompt_tokens),0) + ifnull(sum(completion_tokens),0)")
 	if username != "" {
 		tx = tx.Where("username = ?", username)
 	}
@@ -478,3 +485,69 @@ func DeleteOldLog(ctx context.Context, targetTimestamp int64, limit int) (int64,
 
 	return total, nil
 }
+
+// ModelLogCount is the number of logs of one type recorded for a model.
+type ModelLogCount struct {
+	ModelName string `json:"model_name"`
+	Type      int    `json:"type"`
+	Count     int64  `json:"count"`
+}
+
+// GetModelLogCountsSince counts consume and error logs per model created since the given timestamp.
+func GetModelLogCountsSince(startTimestamp int64) ([]ModelLogCount, error) {
+	var counts []ModelLogCount
+	err := LogReadDB().Table("logs").
+		Select("model_name, type, count(*) as count").
+		Where("created_at >= ? and type in ?", startTimestamp, []int{LogTypeConsume, LogTypeError}).
+		Group("model_name, type").
+		Scan(&counts).Error
+	return counts, err
+}
+
+// PublicStatsLatencyBounds are the upper bounds (in seconds) of the latency buckets used by
+// the public usage stats; requests slower than the last bound fall into an extra bucket.
+var PublicStatsLatencyBounds = []int{1, 2, 5, 10, 30, 60}
+
+// ModelUserLatencyCount is the number of consume logs of one user on one model in one latency bucket.
+type ModelUserLatencyCount struct {
+	ModelName string `json:"model_name"`
+	UserId    int    `json:"user_id"`
+	Bucket    int    `json:"bucket"`
+	Count     int64  `json:"count"`
+}
+
+// GetModelUserLatencyCounts counts consume logs per model, user and latency bucket created in
+// [startTimestamp, endTimestamp). Buckets index into PublicStatsLatencyBounds.
+func GetModelUserLatencyCounts(startTimestamp int64, endTimestamp int64) ([]ModelUserLatencyCount, error) {
+	var bucketExpr strings.Builder
+	bucketExpr.WriteString("CASE")
+	for i, bound := range PublicStatsLatencyBounds {
+		bucketExpr.WriteString(fmt.Sprintf(" WHEN use_time < %d THEN %d", bound, i))
+	}
+	bucketExpr.WriteString(fmt.Sprintf(" ELSE %d END", len(PublicStatsLatencyBounds)))
+
+	var counts []ModelUserLatencyCount
+	err := LogReadDB().Table("logs").
+		Select("model_name, user_id, "+bucketExpr.String()+" as bucket, count(*) as count").
+		Where("created_at >= ? and created_at < ? and type = ?", startTimestamp, endTimestamp, LogTypeConsume).
+		Group("model_name, user_id, bucket").
+		Scan(&counts).Error
+	return counts, err
+}
+
+type UserUsageSummary struct {
+	UserId   int   `json:"user_id"`
+	Quota    int64 `json:"quota"`
+	Requests int64 `json:"requests"`
+}
+
+// GetUserUsageSummaries sums the consume logs of every user in [startTimestamp, endTimestamp).
+func GetUserUsageSummaries(startTimestamp int64, endTimestamp int64) ([]UserUsageSummary, error) {
+	var summaries []UserUsageSummary
+	err := LogReadDB().Table("logs").
+		Select("user_id, sum(quota) as quota, count(*) as requests").
+		Where("type = ? and created_at >= ? and created_at < ?", LogTypeConsume, startTimestamp, endTimestamp).
+		Group("user_id").
+		Scan(&summaries).Error
+	return summaries, err
+}
@@ -143,7 +143,7 @@ func chooseDB(envName string, isLog bool) (*gorm.DB, error) {
 			} else {
 				common.LogSqlType = common.DatabaseTypeSQLite
 			}
-			return gorm.Open(sqlite.Open(common.SQLitePath), &gorm.Config{
+			return gorm.Open(sqlite.Open(sqliteDSN(common.SQLitePath)), &gorm.Config{
 				PrepareStmt: true, // precompile SQL
 			})
 		}
@@ -169,7 +169,7 @@ func chooseDB(envName string, isLog bool) (*gorm.DB, error) {
 	// Use SQLite
 	common.SysLog("SQL_DSN not set, using SQLite as database")
 	common.UsingSQLite = true
-	return gorm.Open(sqlite.Open(common.SQLitePath), &gorm.Config{
+	return gorm.Open(sqlite.Open(sqliteDSN(common.SQLitePath)), &gorm.Config{
 		PrepareStmt: true, // precompile SQL
 	})
 }
@@ -201,11 +201,15 @@ func InitDB() (err error) {
 		if common.UsingMySQL {
 			//_, _ = sqlDB.Exec("ALTER TABLE channels MODIFY model_mapping TEXT;") // TODO: delete this line when most users have upgraded
 		}
+		// --migrate 命令自行决定是否迁移，doctor 命令只检查不迁移
+		if common.RunningCommand() {
+			return nil
+		}
 		common.SysLog("database migration started")
-		err = migrateDB()
-		return err
-	} else {
-		common.FatalLog(err)
+		if err = migrateDB(); err != nil {
+			return err
+		}
+		return runStartupMigrations(MigrationTargetMain)
 	}
 	return err
 }
@@ -213,7 +217,10 @@ func InitDB() (err error) {
 func InitLogDB() (err error) {
 	if os.Getenv("LOG_SQL_DSN") == "" {
 		LOG_DB = DB
-		return
+		if !common.IsMasterNode || common.RunningCommand() {
+			return nil
+		}
+		return runStartupMigrations(MigrationTargetLog)
 	}
 	db, err := chooseDB("LOG_SQL_DSN", true)
 	if err == nil {
@@ -235,14 +242,14 @@ func InitLogDB() (err error) {
 		sqlDB.SetMaxOpenConns(common.GetEnvOrDefault("SQL_MAX_OPEN_CONNS", 1000))
 		sqlDB.SetConnMaxLifetime(time.Second * time.Duration(common.GetEnvOrDefault("SQL_MAX_LIFETIME", 60)))
 
-		if !common.IsMasterNode {
+		if !common.IsMasterNode || common.RunningCommand() {
 			return nil
 		}
 		common.SysLog("database migration started")
-		err = migrateLOGDB()
-		return err
-	} else {
-		common.FatalLog(err)
+		if err = migrateLOGDB(); err != nil {
+			return err
+		}
+		return runStartupMigrations(MigrationTargetLog)
 	}
 	return err
 }
@@ -280,6 +287,20 @@ func migrateDB() error {
 		&SubscriptionPreConsumeRecord{},
 		&CustomOAuthProvider{},
 		&UserOAuthBinding{},
+		&Tenant{},
+		&ReportSubscription{},
+		&File{},
+		&VectorStore{},
+		&VectorStoreFile{},
+		&FeatureFlag{},
+		&StoredResponse{},
+		&ModerationReview{},
+		&ModelDeprecationUsage{},
+		&PromptTemplate{},
+		&TokenQuotaPeriodRecord{},
+		&QuotaPool{},
+		&DatasetExport{},
+		&PublicStatsRelease{},
 	)
 	if err != nil {
 		return err
@@ -296,39 +317,56 @@ func migrateDB() error {
 	return nil
 }
 
+// mainDBModels 为主库中由 AutoMigrate 维护的表，doctor 命令据此检查表结构
+var mainDBModels = []struct {
+	model interface{}
+	name  string
+}{
+	{&Channel{}, "Channel"},
+	{&Token{}, "Token"},
+	{&User{}, "User"},
+	{&PasskeyCredential{}, "PasskeyCredential"},
+	{&Option{}, "Option"},
+	{&Redemption{}, "Redemption"},
+	{&Ability{}, "Ability"},
+	{&Log{}, "Log"},
+	{&Midjourney{}, "Midjourney"},
+	{&TopUp{}, "TopUp"},
+	{&QuotaData{}, "QuotaData"},
+	{&Task{}, "Task"},
+	{&Model{}, "Model"},
+	{&Vendor{}, "Vendor"},
+	{&PrefillGroup{}, "PrefillGroup"},
+	{&Setup{}, "Setup"},
+	{&TwoFA{}, "TwoFA"},
+	{&TwoFABackupCode{}, "TwoFABackupCode"},
+	{&Checkin{}, "Checkin"},
+	{&SubscriptionOrder{}, "SubscriptionOrder"},
+	{&UserSubscription{}, "UserSubscription"},
+	{&SubscriptionPreConsumeRecord{}, "SubscriptionPreConsumeRecord"},
+	{&CustomOAuthProvider{}, "CustomOAuthProvider"},
+	{&UserOAuthBinding{}, "UserOAuthBinding"},
+	{&Tenant{}, "Tenant"},
+	{&ReportSubscription{}, "ReportSubscription"},
+	{&File{}, "File"},
+	{&VectorStore{}, "VectorStore"},
+	{&VectorStoreFile{}, "VectorStoreFile"},
+	{&FeatureFlag{}, "FeatureFlag"},
+	{&StoredResponse{}, "StoredResponse"},
+	{&ModerationReview{}, "ModerationReview"},
+	{&ModelDeprecationUsage{}, "ModelDeprecationUsage"},
+	{&PromptTemplate{}, "PromptTemplate"},
+	{&TokenQuotaPeriodRecord{}, "TokenQuotaPeriodRecord"},
+	{&QuotaPool{}, "QuotaPool"},
+	{&DatasetExport{}, "DatasetExport"},
+	{&PublicStatsRelease{}, "PublicStatsRelease"},
+}
+
 func migrateDBFast() error {
 
 	var wg sync.WaitGroup
 
-	migrations := []struct {
-		model interface{}
-		name  string
-	}{
-		{&Channel{}, "Channel"},
-		{&Token{}, "Token"},
-		{&User{}, "User"},
-		{&PasskeyCredential{}, "PasskeyCredential"},
-		{&Option{}, "Option"},
-		{&Redemption{}, "Redemption"},
-		{&Ability{}, "Ability"},
-		{&Log{}, "Log"},
-		{&Midjourney{}, "Midjourney"},
-		{&TopUp{}, "TopUp"},
-		{&QuotaData{}, "QuotaData"},
-		{&Task{}, "Task"},
-		{&Model{}, "Model"},
-		{&Vendor{}, "Vendor"},
-		{&PrefillGroup{}, "PrefillGroup"},
-		{&Setup{}, "Setup"},
-		{&TwoFA{}, "TwoFA"},
-		{&TwoFABackupCode{}, "TwoFABackupCode"},
-		{&Checkin{}, "Checkin"},
-		{&SubscriptionOrder{}, "SubscriptionOrder"},
-		{&UserSubscription{}, "UserSubscription"},
-		{&SubscriptionPreConsumeRecord{}, "SubscriptionPreConsumeRecord"},
-		{&CustomOAuthProvider{}, "CustomOAuthProvider"},
-		{&UserOAuthBinding{}, "UserOAuthBinding"},
-	}
+	migrations := mainDBModels
 	// 动态计算migration数量，确保errChan缓冲区足够大
 	errChan := make(chan error, len(migrations))
 
@@ -367,7 +405,7 @@ func migrateDBFast() error {
 
 func migrateLOGDB() error {
 	var err error
-	if err = LOG_DB.AutoMigrate(&Log{}); err != nil {
+	if err = LOG_DB.AutoMigrate(&Log{}, &RequestCapture{}, &RequestFeedback{}); err != nil {
 		return err
 	}
 	return nil
@@ -572,6 +610,9 @@ func closeDB(db *gorm.DB) error {
 }
 
 func CloseDB() error {
+	if err := closeReadReplicas(); err != nil {
+		return err
+	}
 	if LOG_DB != DB {
 		err := closeDB(LOG_DB)
 		if err != nil {
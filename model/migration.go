@@ -0,0 +1,344 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/QuantumNous/new-api/common"
+	"gorm.io/gorm"
+)
+
+// 版本化迁移在 AutoMigrate 之后执行，用于 AutoMigrate 无法表达或不宜在启动时隐式执行的结构变更
+// （大表索引、分区等）。每个数据库用 schema_migrations 表记录已执行的版本。
+// MIGRATION_MODE=manual 时启动不执行待处理的迁移，只打印提示，由运维通过 --migrate up 执行；
+// Manual 的迁移始终如此。
+
+const (
+	MigrationTargetMain = "main" // 主库
+	MigrationTargetLog  = "log"  // 日志库（未配置 LOG_SQL_DSN 时与主库相同）
+
+	MigrationCommandStatus = "status"
+	MigrationCommandUp     = "up"
+	MigrationCommandDown   = "down"
+)
+
+type SchemaMigration struct {
+	Version   string `json:"version" gorm:"primaryKey;type:varchar(64)"`
+	Name      string `json:"name" gorm:"type:varchar(255)"`
+	AppliedAt int64  `json:"applied_at" gorm:"bigint"`
+}
+
+// Migration 描述一个版本化迁移，Up/Down 返回待执行的 SQL，dry-run 时仅打印不执行
+type Migration struct {
+	Version  string
+	Name     string
+	Target   string
+	Dialects []string // 适用的数据库类型，为空表示全部
+	// NoTransaction 的迁移逐条执行，用于 CREATE INDEX CONCURRENTLY 等不能在事务中执行的语句
+	NoTransaction bool
+	// Manual 的迁移不在启动时执行，只能通过 --migrate up 执行，用于大表上耗时较长的迁移
+	Manual bool
+	// Enabled 返回 false 时迁移保持待处理状态，用于需要显式开启的迁移
+	Enabled    func() bool
+	DisabledBy string // Enabled 返回 false 时的提示
+	Up         func(db *gorm.DB) ([]string, error)
+	Down       func(db *gorm.DB) ([]string, error)
+}
+
+func (m *Migration) supports(dialect string) bool {
+	return len(m.Dialects) == 0 || common.StringsContains(m.Dialects, dialect)
+}
+
+func (m *Migration) enabled() bool {
+	return m.Enabled == nil || m.Enabled()
+}
+
+// MigrationStatus 为迁移在当前数据库上的状态
+type MigrationStatus struct {
+	Version   string `json:"version"`
+	Name      string `json:"name"`
+	Target    string `json:"target"`
+	Applied   bool   `json:"applied"`
+	AppliedAt int64  `json:"applied_at"`
+	Skipped   string `json:"skipped,omitempty"`
+}
+
+// MigrationPlan 为一次 up/down 中某个迁移要执行的 SQL
+type MigrationPlan struct {
+	Migration  *Migration
+	Statements []string
+}
+
+// migrations 按版本号升序排列，版本号一经发布不可修改
+var migrations = []*Migration{
+	logPostgresIndexesMigration,
+	logPartitionByMonthMigration,
+}
+
+func migrationDB(target string) *gorm.DB {
+	if target == MigrationTargetLog && LOG_DB != nil {
+		return LOG_DB
+	}
+	return DB
+}
+
+func migrationDialect(target string) string {
+	if target == MigrationTargetLog && LOG_DB != nil && LOG_DB != DB {
+		return common.LogSqlType
+	}
+	switch {
+	case common.UsingPostgreSQL:
+		return common.DatabaseTypePostgreSQL
+	case common.UsingMySQL:
+		return common.DatabaseTypeMySQL
+	default:
+		return common.DatabaseTypeSQLite
+	}
+}
+
+func appliedMigrations(db *gorm.DB) (map[string]SchemaMigration, error) {
+	applied := make(map[string]SchemaMigration)
+	if !db.Migrator().HasTable(&SchemaMigration{}) {
+		return applied, nil
+	}
+	var records []SchemaMigration
+	if err := db.Find(&records).Error; err != nil {
+		return nil, err
+	}
+	for _, record := range records {
+		applied[record.Version] = record
+	}
+	return applied, nil
+}
+
+// migrationStatus 返回 list 中属于 target 的迁移状态
+func migrationStatus(list []*Migration, target string) ([]MigrationStatus, error) {
+	db := migrationDB(target)
+	dialect := migrationDialect(target)
+	applied, err := appliedMigrations(db)
+	if err != nil {
+		return nil, err
+	}
+	statuses := make([]MigrationStatus, 0, len(list))
+	for _, m := range list {
+		if m.Target != target {
+			continue
+		}
+		status := MigrationStatus{Version: m.Version, Name: m.Name, Target: m.Target}
+		if record, ok := applied[m.Version]; ok {
+			status.Applied = true
+			status.AppliedAt = record.AppliedAt
+		} else if !m.supports(dialect) {
+			status.Skipped = "not supported on " + dialect
+		} else if !m.enabled() {
+			status.Skipped = m.DisabledBy
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses, nil
+}
+
+// applyMigrations 执行 target 上所有待处理的迁移，dryRun 时只返回计划
+func applyMigrations(list []*Migration, target string, dryRun bool) ([]MigrationPlan, error) {
+	db := migrationDB(target)
+	dialect := migrationDialect(target)
+	applied, err := appliedMigrations(db)
+	if err != nil {
+		return nil, err
+	}
+	if !dryRun {
+		if err := db.AutoMigrate(&SchemaMigration{}); err != nil {
+			return nil, err
+		}
+	}
+
+	plans := make([]MigrationPlan, 0)
+	for _, m := range list {
+		if m.Target != target || !m.supports(dialect) || !m.enabled() {
+			continue
+		}
+		if _, ok := applied[m.Version]; ok {
+			continue
+		}
+		statements, err := m.Up(db)
+		if err != nil {
+			return plans, fmt.Errorf("migration %s: %w", m.Version, err)
+		}
+		plans = append(plans, MigrationPlan{Migration: m, Statements: statements})
+		if dryRun {
+			continue
+		}
+		record := &SchemaMigration{Version: m.Version, Name: m.Name, AppliedAt: common.GetTimestamp()}
+		if err := execMigration(db, m, statements, func(tx *gorm.DB) error {
+			return tx.Create(record).Error
+		}); err != nil {
+			return plans, fmt.Errorf("migration %s: %w", m.Version, err)
+		}
+		common.SysLog(fmt.Sprintf("migration %s (%s) applied", m.Version, m.Name))
+	}
+	return plans, nil
+}
+
+// rollbackMigrations 按版本倒序回滚所有数据库上最近执行的 steps 个迁移
+func rollbackMigrations(list []*Migration, steps int, dryRun bool) ([]MigrationPlan, error) {
+	candidates := make([]*Migration, 0)
+	for _, target := range migrationTargets() {
+		applied, err := appliedMigrations(migrationDB(target))
+		if err != nil {
+			return nil, err
+		}
+		for _, m := range list {
+			if _, ok := applied[m.Version]; ok && m.Target == target {
+				candidates = append(candidates, m)
+			}
+		}
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].Version > candidates[j].Version
+	})
+
+	plans := make([]MigrationPlan, 0)
+	for _, m := range candidates {
+		if len(plans) >= steps {
+			break
+		}
+		if m.Down == nil {
+			return plans, fmt.Errorf("migration %s cannot be rolled back", m.Version)
+		}
+		db := migrationDB(m.Target)
+		statements, err := m.Down(db)
+		if err != nil {
+			return plans, fmt.Errorf("migration %s: %w", m.Version, err)
+		}
+		plans = append(plans, MigrationPlan{Migration: m, Statements: statements})
+		if dryRun {
+			continue
+		}
+		if err := execMigration(db, m, statements, func(tx *gorm.DB) error {
+			return tx.Where("version = ?", m.Version).Delete(&SchemaMigration{}).Error
+		}); err != nil {
+			return plans, fmt.Errorf("rollback %s: %w", m.Version, err)
+		}
+		common.SysLog(fmt.Sprintf("migration %s (%s) rolled back", m.Version, m.Name))
+	}
+	return plans, nil
+}
+
+// execMigration 执行迁移语句并更新版本记录；NoTransaction 的迁移在全部语句成功后才写入记录
+func execMigration(db *gorm.DB, m *Migration, statements []string, record func(tx *gorm.DB) error) error {
+	if m.NoTransaction {
+		for _, statement := range statements {
+			if err := db.Exec(statement).Error; err != nil {
+				return err
+			}
+		}
+		return record(db)
+	}
+	return db.Transaction(func(tx *gorm.DB) error {
+		for _, statement := range statements {
+			if err := tx.Exec(statement).Error; err != nil {
+				return err
+			}
+		}
+		return record(tx)
+	})
+}
+
+func migrationTargets() []string {
+	return []string{MigrationTargetMain, MigrationTargetLog}
+}
+
+// runStartupMigrations 在启动时执行 target 上待处理的迁移，Manual 的迁移与 MIGRATION_MODE=manual 时仅提示
+func runStartupMigrations(target string) error {
+	return startupMigrations(migrations, target, os.Getenv("MIGRATION_MODE") == "manual")
+}
+
+func startupMigrations(list []*Migration, target string, manualMode bool) error {
+	if !manualMode {
+		automatic := make([]*Migration, 0, len(list))
+		for _, m := range list {
+			if !m.Manual {
+				automatic = append(automatic, m)
+			}
+		}
+		if _, err := applyMigrations(automatic, target, false); err != nil {
+			return err
+		}
+	}
+	plans, err := applyMigrations(list, target, true)
+	if err != nil {
+		return err
+	}
+	for _, plan := range plans {
+		common.SysLog(fmt.Sprintf("pending migration %s (%s) on %s database, run with --migrate up to apply", plan.Migration.Version, plan.Migration.Name, target))
+	}
+	return nil
+}
+
+// RunMigrateCommand 执行 --migrate 命令：status 列出迁移状态，up 执行待处理的迁移，down 回滚最近 steps 个迁移
+func RunMigrateCommand(command string, dryRun bool, steps int, w io.Writer) error {
+	switch command {
+	case MigrationCommandStatus:
+		for _, target := range migrationTargets() {
+			statuses, err := migrationStatus(migrations, target)
+			if err != nil {
+				return err
+			}
+			for _, status := range statuses {
+				state := "pending"
+				if status.Applied {
+					state = "applied"
+				} else if status.Skipped != "" {
+					state = "skipped: " + status.Skipped
+				}
+				_, _ = fmt.Fprintf(w, "[%s] %s %s (%s)\n", target, status.Version, status.Name, state)
+			}
+		}
+		return nil
+	case MigrationCommandUp:
+		if !dryRun {
+			// 版本化迁移基于 AutoMigrate 建立的表结构
+			if err := migrateDB(); err != nil {
+				return err
+			}
+			if LOG_DB != DB {
+				if err := migrateLOGDB(); err != nil {
+					return err
+				}
+			}
+		}
+		for _, target := range migrationTargets() {
+			plans, err := applyMigrations(migrations, target, dryRun)
+			printMigrationPlans(w, plans, dryRun)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	case MigrationCommandDown:
+		if steps <= 0 {
+			return errors.New("--migrate-steps must be positive")
+		}
+		plans, err := rollbackMigrations(migrations, steps, dryRun)
+		printMigrationPlans(w, plans, dryRun)
+		return err
+	}
+	return fmt.Errorf("unknown migrate command %q, expected status, up or down", command)
+}
+
+func printMigrationPlans(w io.Writer, plans []MigrationPlan, dryRun bool) {
+	for _, plan := range plans {
+		prefix := ""
+		if dryRun {
+			prefix = "-- dry run\n"
+		}
+		_, _ = fmt.Fprintf(w, "%s-- [%s] %s %s\n", prefix, plan.Migration.Target, plan.Migration.Version, plan.Migration.Name)
+		for _, statement := range plan.Statements {
+			_, _ = fmt.Fprintln(w, strings.TrimSpace(statement)+";")
+		}
+	}
+}
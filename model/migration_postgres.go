@@ -0,0 +1,238 @@
+package model
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"gorm.io/gorm"
+)
+
+// PostgreSQL 专用的日志表优化：部分索引、BRIN 索引与按月分区
+
+const (
+	logLegacyPartition     = "logs_legacy"
+	logDefaultPartition    = "logs_default"
+	logPartitionBoundCheck = "logs_partition_bound"
+	// 预先创建的月分区数量（含当月）
+	logPartitionMonthsAhead = 3
+)
+
+// logPostgresIndexes 为索引名与 ON logs 之后的定义
+var logPostgresIndexes = []struct {
+	name       string
+	definition string
+}{
+	// created_at 随 id 单调递增，BRIN 索引体积极小，适合按时间范围扫描与清理
+	{"idx_logs_created_at_brin", "USING brin (created_at)"},
+	// 错误日志占比很小，部分索引只覆盖 type = 5 的行
+	{"idx_logs_error_created_at", fmt.Sprintf("(created_at) WHERE type = %d", LogTypeError)},
+	// 用户消费日志按 id 倒序分页
+	{"idx_logs_consume_user_id", fmt.Sprintf("(user_id, id) WHERE type = %d", LogTypeConsume)},
+}
+
+// 索引在大表上使用 CONCURRENTLY 创建，不阻塞日志写入，但耗时较长，因此不在启动时执行，须通过 --migrate up 执行。
+// CONCURRENTLY 创建中断（超时、进程退出、唯一性冲突）会留下 indisvalid = false 的无效索引，
+// IF NOT EXISTS 会跳过它，所以重新执行时先删除同名的无效索引再重建
+var logPostgresIndexesMigration = &Migration{
+	Version:       "20261016000001",
+	Name:          "logs_partial_and_brin_indexes",
+	Target:        MigrationTargetLog,
+	Dialects:      []string{common.DatabaseTypePostgreSQL},
+	NoTransaction: true,
+	Manual:        true,
+	Up: func(db *gorm.DB) ([]string, error) {
+		names := make([]string, 0, len(logPostgresIndexes))
+		for _, index := range logPostgresIndexes {
+			names = append(names, index.name)
+		}
+		invalid, err := postgresInvalidIndexes(db, names)
+		if err != nil {
+			return nil, err
+		}
+		statements := make([]string, 0, len(logPostgresIndexes))
+		for _, index := range logPostgresIndexes {
+			if invalid[index.name] {
+				statements = append(statements, "DROP INDEX CONCURRENTLY IF EXISTS "+index.name)
+			}
+			statements = append(statements, fmt.Sprintf("CREATE INDEX CONCURRENTLY IF NOT EXISTS %s ON logs %s", index.name, index.definition))
+		}
+		return statements, nil
+	},
+	Down: func(db *gorm.DB) ([]string, error) {
+		return []string{
+			"DROP INDEX CONCURRENTLY IF EXISTS idx_logs_consume_user_id",
+			"DROP INDEX CONCURRENTLY IF EXISTS idx_logs_error_created_at",
+			"DROP INDEX CONCURRENTLY IF EXISTS idx_logs_created_at_brin",
+		}, nil
+	},
+}
+
+// 将 logs 转为按 created_at 月度范围分区的表。原表整体挂载为 logs_legacy 分区，不复制数据；
+// 挂载前先以 NOT VALID + VALIDATE 方式添加边界约束，使挂载时无需在排他锁下全表扫描。
+// 回滚会把分区表中的数据复制回原表，大表上需预留足够时间。
+var logPartitionByMonthMigration = &Migration{
+	Version:       "20261016000002",
+	Name:          "logs_partition_by_month",
+	Target:        MigrationTargetLog,
+	Dialects:      []string{common.DatabaseTypePostgreSQL},
+	NoTransaction: true,
+	Enabled: func() bool {
+		return os.Getenv("LOG_PARTITION_BY_MONTH") == "true"
+	},
+	DisabledBy: "set LOG_PARTITION_BY_MONTH=true to enable",
+	Up: func(db *gorm.DB) ([]string, error) {
+		// 原表承接到两个月后的月初为止，为迁移执行期间的写入留出余量
+		bound := logPartitionMonthStart(time.Now(), 2)
+		statements := []string{
+			fmt.Sprintf("ALTER TABLE logs DROP CONSTRAINT IF EXISTS %s", logPartitionBoundCheck),
+			fmt.Sprintf("ALTER TABLE logs ADD CONSTRAINT %s CHECK (created_at IS NOT NULL AND created_at < %d) NOT VALID", logPartitionBoundCheck, bound.Unix()),
+			fmt.Sprintf("ALTER TABLE logs VALIDATE CONSTRAINT %s", logPartitionBoundCheck),
+			fmt.Sprintf(`DO $$
+DECLARE
+	r record;
+BEGIN
+	ALTER TABLE logs RENAME TO %[1]s;
+	CREATE TABLE logs (LIKE %[1]s INCLUDING DEFAULTS) PARTITION BY RANGE (created_at);
+	-- 在分区表上以原名重建索引，原表的索引改名后在挂载时自动关联
+	FOR r IN
+		SELECT i.relname AS name, pg_get_indexdef(i.oid) AS def
+		FROM pg_index x JOIN pg_class i ON i.oid = x.indexrelid
+		WHERE x.indrelid = '%[1]s'::regclass AND NOT x.indisprimary
+	LOOP
+		EXECUTE format('ALTER INDEX %%I RENAME TO %%I', r.name, left(r.name, 56) || '_legacy');
+		EXECUTE regexp_replace(r.def, ' ON \S+ ', ' ON logs ');
+	END LOOP;
+	CREATE SEQUENCE IF NOT EXISTS logs_partitioned_id_seq;
+	PERFORM setval('logs_partitioned_id_seq', COALESCE((SELECT MAX(id) FROM %[1]s), 0) + 1, false);
+	ALTER TABLE logs ALTER COLUMN id SET DEFAULT nextval('logs_partitioned_id_seq');
+	ALTER SEQUENCE logs_partitioned_id_seq OWNED BY logs.id;
+	ALTER TABLE logs ATTACH PARTITION %[1]s FOR VALUES FROM (MINVALUE) TO (%[2]d);
+	ALTER TABLE %[1]s DROP CONSTRAINT IF EXISTS %[3]s;
+	CREATE TABLE %[4]s PARTITION OF logs DEFAULT;
+END $$`, logLegacyPartition, bound.Unix(), logPartitionBoundCheck, logDefaultPartition),
+		}
+		return append(statements, logMonthPartitionStatements(bound, logPartitionMonthsAhead)...), nil
+	},
+	Down: func(db *gorm.DB) ([]string, error) {
+		return []string{fmt.Sprintf(`DO $$
+DECLARE
+	r record;
+BEGIN
+	ALTER TABLE logs DETACH PARTITION %[1]s;
+	INSERT INTO %[1]s SELECT * FROM logs;
+	DROP TABLE logs;
+	FOR r IN
+		SELECT i.relname AS name
+		FROM pg_index x JOIN pg_class i ON i.oid = x.indexrelid
+		WHERE x.indrelid = '%[1]s'::regclass AND i.relname LIKE '%%\_legacy'
+	LOOP
+		EXECUTE format('ALTER INDEX %%I RENAME TO %%I', r.name, left(r.name, length(r.name) - 7));
+	END LOOP;
+	ALTER TABLE %[1]s RENAME TO logs;
+	PERFORM setval(pg_get_serial_sequence('logs', 'id'), COALESCE((SELECT MAX(id) FROM logs), 0) + 1, false);
+END $$`, logLegacyPartition)}, nil
+	},
+}
+
+// postgresInvalidIndexes 返回 names 中在当前 schema 下存在但无效（indisvalid = false）的索引
+func postgresInvalidIndexes(db *gorm.DB, names []string) (map[string]bool, error) {
+	var invalid []string
+	err := db.Raw(`SELECT i.relname FROM pg_index x JOIN pg_class i ON i.oid = x.indexrelid
+		WHERE NOT x.indisvalid AND i.relnamespace = current_schema()::regnamespace AND i.relname IN ?`, names).Scan(&invalid).Error
+	if err != nil {
+		return nil, err
+	}
+	result := make(map[string]bool, len(invalid))
+	for _, name := range invalid {
+		result[name] = true
+	}
+	return result, nil
+}
+
+// logPartitionMonthStart 返回 t 所在月份往后 offset 个月的月初（UTC）
+func logPartitionMonthStart(t time.Time, offset int) time.Time {
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month()+time.Month(offset), 1, 0, 0, 0, 0, time.UTC)
+}
+
+func logPartitionName(monthStart time.Time) string {
+	return fmt.Sprintf("logs_p%04d%02d", monthStart.Year(), int(monthStart.Month()))
+}
+
+// logMonthPartitionStatements 返回从 from 所在月份开始 months 个月分区的建表语句
+func logMonthPartitionStatements(from time.Time, months int) []string {
+	statements := make([]string, 0, months)
+	for i := 0; i < months; i++ {
+		start := logPartitionMonthStart(from, i)
+		end := logPartitionMonthStart(from, i+1)
+		statements = append(statements, fmt.Sprintf(
+			"CREATE TABLE IF NOT EXISTS %s PARTITION OF logs FOR VALUES FROM (%d) TO (%d)",
+			logPartitionName(start), start.Unix(), end.Unix(),
+		))
+	}
+	return statements
+}
+
+var logPartitionUpperBoundRegex = regexp.MustCompile(`TO \('?(-?\d+)'?\)`)
+
+// parseLogPartitionUpperBound 从 pg_get_expr(relpartbound) 的结果中解析范围分区的上界
+func parseLogPartitionUpperBound(expr string) (int64, bool) {
+	match := logPartitionUpperBoundRegex.FindStringSubmatch(expr)
+	if match == nil {
+		return 0, false
+	}
+	bound, err := strconv.ParseInt(match[1], 10, 64)
+	return bound, err == nil
+}
+
+// EnsureLogPartitions 在 logs 已按月分区时预先创建当月及之后的分区，避免新数据落入默认分区
+func EnsureLogPartitions() error {
+	if migrationDialect(MigrationTargetLog) != common.DatabaseTypePostgreSQL {
+		return nil
+	}
+	db := migrationDB(MigrationTargetLog)
+	var partitioned int64
+	if err := db.Raw(`SELECT COUNT(*) FROM pg_partitioned_table p JOIN pg_class c ON c.oid = p.partrelid
+		WHERE c.relname = 'logs' AND c.relnamespace = current_schema()::regnamespace`).Scan(&partitioned).Error; err != nil {
+		return err
+	}
+	if partitioned == 0 {
+		return nil
+	}
+
+	// 原表分区覆盖的月份不能再建分区
+	from := logPartitionMonthStart(time.Now(), 0)
+	var legacyBound string
+	if err := db.Raw(`SELECT COALESCE(pg_get_expr(c.relpartbound, c.oid), '') FROM pg_class c
+		WHERE c.relname = ? AND c.relnamespace = current_schema()::regnamespace AND c.relispartition`, logLegacyPartition).Scan(&legacyBound).Error; err != nil {
+		return err
+	}
+	if bound, ok := parseLogPartitionUpperBound(legacyBound); ok && bound > from.Unix() {
+		from = time.Unix(bound, 0)
+	}
+	for _, statement := range logMonthPartitionStatements(from, logPartitionMonthsAhead) {
+		if err := db.Exec(statement).Error; err != nil {
+			return fmt.Errorf("failed to create log partition: %w", err)
+		}
+	}
+	return nil
+}
+
+// StartLogPartitionMaintainer 每天检查一次日志分区
+func StartLogPartitionMaintainer() {
+	if !common.IsMasterNode {
+		return
+	}
+	go func() {
+		for {
+			if err := EnsureLogPartitions(); err != nil {
+				common.SysError("failed to maintain log partitions: " + err.Error())
+			}
+			time.Sleep(24 * time.Hour)
+		}
+	}()
+}
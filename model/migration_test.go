@@ -0,0 +1,113 @@
+package model
+
+import (
+	"testing"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func TestVersionedMigrations(t *testing.T) {
+	t.Cleanup(func() {
+		DB.Exec("DROP TABLE IF EXISTS migration_probes")
+		DB.Exec("DELETE FROM schema_migrations")
+	})
+	statements := func(sql ...string) func(*gorm.DB) ([]string, error) {
+		return func(*gorm.DB) ([]string, error) { return sql, nil }
+	}
+	list := []*Migration{
+		{
+			Version: "0001", Name: "create_probe", Target: MigrationTargetMain,
+			Up:   statements("CREATE TABLE migration_probes (id integer)"),
+			Down: statements("DROP TABLE migration_probes"),
+		},
+		{
+			Version: "0002", Name: "postgres_only", Target: MigrationTargetMain,
+			Dialects: []string{common.DatabaseTypePostgreSQL},
+			Up:       statements("SELECT pg_backend_pid()"),
+		},
+		{
+			Version: "0003", Name: "opt_in", Target: MigrationTargetLog,
+			Enabled: func() bool { return false }, DisabledBy: "disabled",
+			Up: statements("SELECT 1"),
+		},
+	}
+
+	plans, err := applyMigrations(list, MigrationTargetMain, true)
+	require.NoError(t, err)
+	require.Len(t, plans, 1)
+	require.False(t, DB.Migrator().HasTable("migration_probes"), "dry run must not execute")
+
+	plans, err = applyMigrations(list, MigrationTargetMain, false)
+	require.NoError(t, err)
+	require.Len(t, plans, 1)
+	require.True(t, DB.Migrator().HasTable("migration_probes"))
+
+	plans, err = applyMigrations(list, MigrationTargetMain, false)
+	require.NoError(t, err)
+	require.Empty(t, plans)
+
+	statuses, err := migrationStatus(list, MigrationTargetMain)
+	require.NoError(t, err)
+	require.Len(t, statuses, 2)
+	require.True(t, statuses[0].Applied)
+	require.Equal(t, "not supported on sqlite", statuses[1].Skipped)
+	statuses, err = migrationStatus(list, MigrationTargetLog)
+	require.NoError(t, err)
+	require.Equal(t, "disabled", statuses[0].Skipped)
+
+	plans, err = rollbackMigrations(list, 5, false)
+	require.NoError(t, err)
+	require.Len(t, plans, 1)
+	require.False(t, DB.Migrator().HasTable("migration_probes"))
+	statuses, err = migrationStatus(list, MigrationTargetMain)
+	require.NoError(t, err)
+	require.False(t, statuses[0].Applied)
+}
+
+func TestLogMonthPartitions(t *testing.T) {
+	start := logPartitionMonthStart(time.Date(2026, 11, 20, 23, 0, 0, 0, time.FixedZone("UTC-8", -8*3600)), 1)
+	require.Equal(t, time.Date(2027, 1, 1, 0, 0, 0, 0, time.UTC), start)
+	require.Equal(t, "logs_p202701", logPartitionName(start))
+
+	statements := logMonthPartitionStatements(start, 2)
+	require.Equal(t, []string{
+		"CREATE TABLE IF NOT EXISTS logs_p202701 PARTITION OF logs FOR VALUES FROM (1798761600) TO (1801440000)",
+		"CREATE TABLE IF NOT EXISTS logs_p202702 PARTITION OF logs FOR VALUES FROM (1801440000) TO (1803859200)",
+	}, statements)
+
+	bound, ok := parseLogPartitionUpperBound("FOR VALUES FROM (MINVALUE) TO ('1798761600')")
+	require.True(t, ok)
+	require.EqualValues(t, 1798761600, bound)
+	_, ok = parseLogPartitionUpperBound("DEFAULT")
+	require.False(t, ok)
+}
+
+func TestStartupMigrationsSkipManual(t *testing.T) {
+	t.Cleanup(func() {
+		DB.Exec("DROP TABLE IF EXISTS migration_probes")
+		DB.Exec("DROP TABLE IF EXISTS migration_manual_probes")
+		DB.Exec("DELETE FROM schema_migrations")
+	})
+	statements := func(sql ...string) func(*gorm.DB) ([]string, error) {
+		return func(*gorm.DB) ([]string, error) { return sql, nil }
+	}
+	list := []*Migration{
+		{Version: "0001", Name: "automatic", Target: MigrationTargetMain, Up: statements("CREATE TABLE migration_probes (id integer)")},
+		{Version: "0002", Name: "manual", Target: MigrationTargetMain, Manual: true, Up: statements("CREATE TABLE migration_manual_probes (id integer)")},
+	}
+
+	require.NoError(t, startupMigrations(list, MigrationTargetMain, true))
+	require.False(t, DB.Migrator().HasTable("migration_probes"))
+
+	require.NoError(t, startupMigrations(list, MigrationTargetMain, false))
+	require.True(t, DB.Migrator().HasTable("migration_probes"))
+	require.False(t, DB.Migrator().HasTable("migration_manual_probes"))
+
+	plans, err := applyMigrations(list, MigrationTargetMain, false)
+	require.NoError(t, err)
+	require.Len(t, plans, 1)
+	require.True(t, DB.Migrator().HasTable("migration_manual_probes"))
+}
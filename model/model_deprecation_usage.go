@@ -0,0 +1,57 @@
+package model
+
+import (
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ModelDeprecationUsage 令牌请求弃用模型的统计，供管理员在模型下线前通知仍在使用的用户
+type ModelDeprecationUsage struct {
+	Id         int    `json:"id"`
+	TokenId    int    `json:"token_id" gorm:"uniqueIndex:idx_deprecation_usage_token_model"`
+	ModelName  string `json:"model_name" gorm:"type:varchar(128);uniqueIndex:idx_deprecation_usage_token_model"`
+	TokenName  string `json:"token_name" gorm:"type:varchar(255)"`
+	UserId     int    `json:"user_id" gorm:"index"`
+	Requests   int64  `json:"requests" gorm:"type:bigint;default:0"`
+	LastUsedAt int64  `json:"last_used_at" gorm:"type:bigint;index"`
+}
+
+// RecordModelDeprecationUsage 记录令牌对弃用模型的一次请求
+func RecordModelDeprecationUsage(tokenId int, tokenName string, userId int, modelName string, now int64) error {
+	increase := func() (int64, error) {
+		result := DB.Model(&ModelDeprecationUsage{}).Where("token_id = ? AND model_name = ?", tokenId, modelName).Updates(map[string]interface{}{
+			"requests":     gorm.Expr("requests + 1"),
+			"token_name":   tokenName,
+			"last_used_at": now,
+		})
+		return result.RowsAffected, result.Error
+	}
+	if updated, err := increase(); err != nil || updated > 0 {
+		return err
+	}
+	result := DB.Clauses(clause.OnConflict{DoNothing: true}).Create(&ModelDeprecationUsage{
+		TokenId:    tokenId,
+		ModelName:  modelName,
+		TokenName:  tokenName,
+		UserId:     userId,
+		Requests:   1,
+		LastUsedAt: now,
+	})
+	if result.Error != nil || result.RowsAffected > 0 {
+		return result.Error
+	}
+	// 并发请求已先插入记录
+	_, err := increase()
+	return err
+}
+
+// GetModelDeprecationUsages 返回自 since 起仍请求弃用模型的令牌，modelName 为空时返回全部弃用模型
+func GetModelDeprecationUsages(modelName string, since int64) ([]*ModelDeprecationUsage, error) {
+	var usages []*ModelDeprecationUsage
+	query := DB.Where("last_used_at >= ?", since)
+	if modelName != "" {
+		query = query.Where("model_name = ?", modelName)
+	}
+	err := query.Order("last_used_at desc").Find(&usages).Error
+	return usages, err
+}
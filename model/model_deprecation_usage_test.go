@@ -0,0 +1,29 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecordModelDeprecationUsage(t *testing.T) {
+	t.Cleanup(func() {
+		DB.Exec("DELETE FROM model_deprecation_usages")
+	})
+	require.NoError(t, RecordModelDeprecationUsage(1, "legacy", 7, "gpt-old", 100))
+	require.NoError(t, RecordModelDeprecationUsage(1, "legacy", 7, "gpt-old", 200))
+	require.NoError(t, RecordModelDeprecationUsage(2, "other", 8, "gpt-old", 50))
+	require.NoError(t, RecordModelDeprecationUsage(1, "legacy", 7, "gpt-older", 300))
+
+	usages, err := GetModelDeprecationUsages("gpt-old", 0)
+	require.NoError(t, err)
+	require.Len(t, usages, 2)
+	require.Equal(t, 1, usages[0].TokenId)
+	require.Equal(t, int64(2), usages[0].Requests)
+	require.Equal(t, int64(200), usages[0].LastUsedAt)
+
+	usages, err = GetModelDeprecationUsages("", 150)
+	require.NoError(t, err)
+	require.Len(t, usages, 2)
+	require.Equal(t, "gpt-older", usages[0].ModelName)
+}
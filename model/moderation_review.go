@@ -0,0 +1,105 @@
+package model
+
+import (
+	"errors"
+
+	"github.com/QuantumNous/new-api/common"
+	"gorm.io/gorm"
+)
+
+// 审核记录状态
+const (
+	ModerationReviewPending  = "pending"
+	ModerationReviewApproved = "approved"
+	ModerationReviewFlagged  = "flagged"
+)
+
+// 用户信任分的默认值与上限
+const (
+	DefaultTrustScore = 100
+	MaxTrustScore     = 100
+)
+
+var ErrModerationReviewNotPending = errors.New("moderation review is not pending")
+
+// ModerationReview 人工审核队列中的一条记录：命中待审词而被放行的请求及其上游响应
+type ModerationReview struct {
+	Id         int    `json:"id"`
+	RequestId  string `json:"request_id" gorm:"type:varchar(64);index"`
+	UserId     int    `json:"user_id" gorm:"index"`
+	TokenId    int    `json:"token_id"`
+	ChannelId  int    `json:"channel_id"`
+	ModelName  string `json:"model_name" gorm:"type:varchar(255)"`
+	Words      string `json:"words" gorm:"type:varchar(1024)"` // 命中的待审词，逗号分隔
+	Request    string `json:"request"`
+	Response   string `json:"response"`
+	Status     string `json:"status" gorm:"type:varchar(16);index"`
+	ReviewerId int    `json:"reviewer_id"`
+	CreatedAt  int64  `json:"created_at" gorm:"bigint;index"`
+	ReviewedAt int64  `json:"reviewed_at" gorm:"bigint"`
+}
+
+func CreateModerationReview(review *ModerationReview) error {
+	return DB.Create(review).Error
+}
+
+// GetModerationReviews 按状态分页获取审核记录，status 为空时返回全部
+func GetModerationReviews(status string, startIdx int, num int) (reviews []*ModerationReview, total int64, err error) {
+	query := DB.Model(&ModerationReview{})
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if err = query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	err = query.Order("id desc").Limit(num).Offset(startIdx).Find(&reviews).Error
+	return reviews, total, err
+}
+
+func GetModerationReviewById(id int) (*ModerationReview, error) {
+	var review ModerationReview
+	if err := DB.First(&review, id).Error; err != nil {
+		return nil, err
+	}
+	return &review, nil
+}
+
+// ReviewModerationReview 将待审记录标记为 status 并按 trustDelta 调整提交者的信任分，
+// 记录已被审核时返回 ErrModerationReviewNotPending
+func ReviewModerationReview(review *ModerationReview, status string, reviewerId int, trustDelta int) error {
+	return DB.Transaction(func(tx *gorm.DB) error {
+		now := common.GetTimestamp()
+		result := tx.Model(&ModerationReview{}).Where("id = ? AND status = ?", review.Id, ModerationReviewPending).Updates(map[string]interface{}{
+			"status":      status,
+			"reviewer_id": reviewerId,
+			"reviewed_at": now,
+		})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrModerationReviewNotPending
+		}
+		review.Status = status
+		review.ReviewerId = reviewerId
+		review.ReviewedAt = now
+		if trustDelta == 0 {
+			return nil
+		}
+		return adjustUserTrustScore(tx, review.UserId, trustDelta)
+	})
+}
+
+// adjustUserTrustScore 调整用户信任分，结果限制在 0 到 MaxTrustScore 之间
+func adjustUserTrustScore(tx *gorm.DB, userId int, delta int) error {
+	return tx.Model(&User{}).Where("id = ?", userId).Update("trust_score",
+		gorm.Expr("CASE WHEN trust_score + ? > ? THEN ? WHEN trust_score + ? < 0 THEN 0 ELSE trust_score + ? END",
+			delta, MaxTrustScore, MaxTrustScore, delta, delta)).Error
+}
+
+// GetUserTrustScore 获取用户信任分
+func GetUserTrustScore(userId int) (int, error) {
+	var trustScore int
+	err := DB.Model(&User{}).Where("id = ?", userId).Select("trust_score").Find(&trustScore).Error
+	return trustScore, err
+}
@@ -0,0 +1,46 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestReviewModerationReviewAdjustsTrustScore(t *testing.T) {
+	t.Cleanup(func() {
+		DB.Exec("DELETE FROM moderation_reviews")
+		DB.Exec("DELETE FROM users")
+	})
+	user := &User{Username: "reviewed", Password: "password123", AffCode: "review-aff"}
+	require.NoError(t, DB.Create(user).Error)
+	trustScore, err := GetUserTrustScore(user.Id)
+	require.NoError(t, err)
+	require.Equal(t, DefaultTrustScore, trustScore)
+
+	newReview := func() *ModerationReview {
+		review := &ModerationReview{UserId: user.Id, Words: "flagged", Status: ModerationReviewPending}
+		require.NoError(t, CreateModerationReview(review))
+		return review
+	}
+
+	// 信任分不超过上限
+	review := newReview()
+	require.NoError(t, ReviewModerationReview(review, ModerationReviewApproved, 1, 5))
+	require.ErrorIs(t, ReviewModerationReview(review, ModerationReviewFlagged, 1, -20), ErrModerationReviewNotPending)
+	trustScore, _ = GetUserTrustScore(user.Id)
+	require.Equal(t, MaxTrustScore, trustScore)
+
+	require.NoError(t, ReviewModerationReview(newReview(), ModerationReviewFlagged, 1, -70))
+	trustScore, _ = GetUserTrustScore(user.Id)
+	require.Equal(t, 30, trustScore)
+
+	// 信任分不低于 0
+	require.NoError(t, ReviewModerationReview(newReview(), ModerationReviewFlagged, 1, -70))
+	trustScore, _ = GetUserTrustScore(user.Id)
+	require.Equal(t, 0, trustScore)
+
+	reviews, total, err := GetModerationReviews(ModerationReviewFlagged, 0, 10)
+	require.NoError(t, err)
+	require.EqualValues(t, 2, total)
+	require.Len(t, reviews, 2)
+}
@@ -0,0 +1,91 @@
+package model
+
+import (
+	"errors"
+
+	"github.com/QuantumNous/new-api/common"
+	"gorm.io/gorm"
+)
+
+// PromptTemplate Responses 请求 prompt 字段引用的提示词模板的一个版本。
+// 同一 PromptId 每次保存生成新版本，请求未指定版本时使用最新版本。
+// Instructions 与 Input 中的 {{变量名}} 在请求时替换为 prompt.variables 中的值
+type PromptTemplate struct {
+	Id           int    `json:"id"`
+	PromptId     string `json:"prompt_id" gorm:"type:varchar(128);uniqueIndex:idx_prompt_template_version"`
+	Version      int    `json:"version" gorm:"uniqueIndex:idx_prompt_template_version"`
+	Description  string `json:"description" gorm:"type:varchar(255)"`
+	Instructions string `json:"instructions" gorm:"type:text"`
+	Input        string `json:"input" gorm:"type:text"` // Responses input 数组（JSON），追加在请求 input 之前
+	CreatedBy    int    `json:"created_by"`
+	CreatedTime  int64  `json:"created_time" gorm:"bigint"`
+}
+
+var ErrPromptTemplateNotFound = errors.New("prompt template not found")
+
+// CreatePromptTemplate 保存模板的新版本，版本号为当前最新版本加一
+func CreatePromptTemplate(template *PromptTemplate) error {
+	return DB.Transaction(func(tx *gorm.DB) error {
+		var latest int
+		if err := tx.Model(&PromptTemplate{}).Where("prompt_id = ?", template.PromptId).
+			Select("COALESCE(MAX(version), 0)").Scan(&latest).Error; err != nil {
+			return err
+		}
+		template.Id = 0
+		template.Version = latest + 1
+		template.CreatedTime = common.GetTimestamp()
+		return tx.Create(template).Error
+	})
+}
+
+// GetPromptTemplate 返回模板的指定版本，version 为 0 时返回最新版本，不存在时返回 ErrPromptTemplateNotFound
+func GetPromptTemplate(promptId string, version int) (*PromptTemplate, error) {
+	var template PromptTemplate
+	query := DB.Where("prompt_id = ?", promptId)
+	if version > 0 {
+		query = query.Where("version = ?", version)
+	}
+	err := query.Order("version desc").First(&template).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrPromptTemplateNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &template, nil
+}
+
+// GetPromptTemplates 分页返回每个模板的最新版本
+func GetPromptTemplates(startIdx int, num int) (templates []*PromptTemplate, total int64, err error) {
+	latest := DB.Model(&PromptTemplate{}).Select("prompt_id, MAX(version) AS version").Group("prompt_id")
+	query := DB.Model(&PromptTemplate{}).
+		Joins("JOIN (?) AS latest ON latest.prompt_id = prompt_templates.prompt_id AND latest.version = prompt_templates.version", latest)
+	if err = query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	err = query.Order("prompt_templates.prompt_id").Limit(num).Offset(startIdx).Find(&templates).Error
+	return templates, total, err
+}
+
+// GetPromptTemplateVersions 返回模板的全部版本，新版本在前
+func GetPromptTemplateVersions(promptId string) ([]*PromptTemplate, error) {
+	var templates []*PromptTemplate
+	err := DB.Where("prompt_id = ?", promptId).Order("version desc").Find(&templates).Error
+	return templates, err
+}
+
+// DeletePromptTemplate 删除模板的指定版本，version 为 0 时删除全部版本
+func DeletePromptTemplate(promptId string, version int) error {
+	query := DB.Where("prompt_id = ?", promptId)
+	if version > 0 {
+		query = query.Where("version = ?", version)
+	}
+	result := query.Delete(&PromptTemplate{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrPromptTemplateNotFound
+	}
+	return nil
+}
@@ -0,0 +1,34 @@
+package model
+
+import (
+	"fmt"
+
+	"github.com/QuantumNous/new-api/logger"
+	"gorm.io/gorm"
+)
+
+// ProvisionUserWithToken 在一个事务中创建用户及其令牌，供 SaaS 平台在客户注册时开通网关密钥。
+// quota 不为空时以其作为用户初始额度，否则使用新用户默认额度
+func ProvisionUserWithToken(user *User, quota *int, token *Token) error {
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		if err := user.InsertWithTx(tx, 0); err != nil {
+			return err
+		}
+		if quota != nil {
+			if err := tx.Model(&User{}).Where("id = ?", user.Id).Update("quota", *quota).Error; err != nil {
+				return err
+			}
+			user.Quota = *quota
+		}
+		token.UserId = user.Id
+		return tx.Create(token).Error
+	})
+	if err != nil {
+		return err
+	}
+	user.initDefaultSidebarConfig()
+	if user.Quota > 0 {
+		RecordLog(user.Id, LogTypeSystem, fmt.Sprintf("管理员开通账户，初始额度 %s", logger.LogQuota(user.Quota)))
+	}
+	return nil
+}
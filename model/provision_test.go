@@ -0,0 +1,33 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestProvisionUserWithToken(t *testing.T) {
+	truncateTables(t)
+	quota := 5000
+	user := &User{Username: "customer1", Password: "password123", DisplayName: "customer1", Group: "vip"}
+	token := &Token{Name: "default", Key: "provisionedkey0000000000000000000000000000000000", ExpiredTime: -1, RemainQuota: 1000, ModelLimitsEnabled: true, ModelLimits: "gpt-4o,gpt-4o-mini"}
+	require.NoError(t, ProvisionUserWithToken(user, &quota, token))
+	require.NotZero(t, user.Id)
+	require.Equal(t, user.Id, token.UserId)
+
+	stored, err := GetUserById(user.Id, false)
+	require.NoError(t, err)
+	require.Equal(t, quota, stored.Quota)
+	require.Equal(t, "vip", stored.Group)
+
+	storedToken, err := GetTokenByIds(token.Id, user.Id)
+	require.NoError(t, err)
+	require.Equal(t, map[string]bool{"gpt-4o": true, "gpt-4o-mini": true}, storedToken.GetModelLimitsMap())
+
+	// 用户名重复时令牌也不会创建
+	duplicate := &User{Username: "customer1", Password: "password123"}
+	require.Error(t, ProvisionUserWithToken(duplicate, nil, &Token{Name: "default", Key: "provisionedkey1111111111111111111111111111111111"}))
+	var count int64
+	require.NoError(t, DB.Model(&Token{}).Count(&count).Error)
+	require.Equal(t, int64(1), count)
+}
@@ -0,0 +1,50 @@
+package model
+
+import (
+	"errors"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// PublicStatsRelease 公开用量统计的一次发布。每个统计窗口只加噪一次并保存在数据库中，所有节点读取同一份结果，
+// 反复请求或多个节点各自生成都不会得到新的噪声样本
+type PublicStatsRelease struct {
+	Id          int    `json:"id"`
+	WindowStart int64  `json:"window_start" gorm:"type:bigint;uniqueIndex"`
+	WindowEnd   int64  `json:"window_end" gorm:"type:bigint"`
+	Data        string `json:"data" gorm:"type:text"`
+	CreatedAt   int64  `json:"created_at" gorm:"type:bigint"`
+}
+
+// GetPublicStatsRelease 返回统计窗口的发布结果，尚未发布时返回 nil
+func GetPublicStatsRelease(windowStart int64) (*PublicStatsRelease, error) {
+	var release PublicStatsRelease
+	err := DB.Where("window_start = ?", windowStart).First(&release).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &release, nil
+}
+
+// SavePublicStatsRelease 保存统计窗口的发布结果并返回最终生效的一份，其他节点已先发布时丢弃本次结果
+func SavePublicStatsRelease(release *PublicStatsRelease) (*PublicStatsRelease, error) {
+	result := DB.Clauses(clause.OnConflict{DoNothing: true}).Create(release)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected > 0 {
+		return release, nil
+	}
+	existing, err := GetPublicStatsRelease(release.WindowStart)
+	if err != nil {
+		return nil, err
+	}
+	if existing == nil {
+		return nil, errors.New("public stats release not found after conflict")
+	}
+	return existing, nil
+}
@@ -0,0 +1,413 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/logger"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// 额度池：用户额度按来源分为赠送（签到、邀请奖励、活动）、预付（充值、兑换码）与后付费授信三类，
+// 消费时先用赠送额度，再用预付额度，余额用尽后在授信额度内透支。
+// users.quota 仍是可用余额，额度池只记录余额的构成：未记入额度池的余额视为永不过期的预付额度；
+// 后付费授信不计入余额，余额为负时的欠款即为已用授信。额度池到期后剩余额度从余额中扣除
+const (
+	QuotaPoolKindBonus    = "bonus"
+	QuotaPoolKindPrepaid  = "prepaid"
+	QuotaPoolKindPostpaid = "postpaid"
+)
+
+const (
+	QuotaPoolStatusActive  = 1
+	QuotaPoolStatusExpired = 2
+)
+
+// QuotaPool 用户的一笔额度
+type QuotaPool struct {
+	Id        int    `json:"id"`
+	UserId    int    `json:"user_id" gorm:"index"`
+	Kind      string `json:"kind" gorm:"type:varchar(16);index"`
+	Total     int    `json:"total"`
+	Remaining int    `json:"remaining"`
+	ExpiresAt int64  `json:"expires_at" gorm:"type:bigint;index"` // 0 表示永不过期
+	Status    int    `json:"status" gorm:"default:1;index"`
+	Source    string `json:"source" gorm:"type:varchar(32)"` // topup、redemption、checkin、invitation、admin 等
+	Remark    string `json:"remark" gorm:"type:varchar(255)"`
+	CreatedAt int64  `json:"created_at" gorm:"type:bigint"`
+	UpdatedAt int64  `json:"updated_at" gorm:"type:bigint"`
+}
+
+// QuotaPoolDeduction 一次消费从某个额度池扣除的额度，用于退款时原路退回
+type QuotaPoolDeduction struct {
+	PoolId int
+	Amount int
+}
+
+// QuotaPoolSummary 用户额度的构成
+type QuotaPoolSummary struct {
+	Quota         int          `json:"quota"`          // 账户余额，透支时为负
+	Bonus         int          `json:"bonus"`          // 剩余赠送额度
+	Prepaid       int          `json:"prepaid"`        // 剩余预付额度，含未记入额度池的余额
+	PostpaidLimit int          `json:"postpaid_limit"` // 后付费授信额度
+	PostpaidUsed  int          `json:"postpaid_used"`  // 已用授信额度
+	Available     int          `json:"available"`      // 可用额度合计
+	Pools         []*QuotaPool `json:"pools"`          // 生效中的额度池，按消费顺序排列
+}
+
+// IsValidQuotaPoolKind 检查额度池类型是否有效
+func IsValidQuotaPoolKind(kind string) bool {
+	switch kind {
+	case QuotaPoolKindBonus, QuotaPoolKindPrepaid, QuotaPoolKindPostpaid:
+		return true
+	default:
+		return false
+	}
+}
+
+func quotaPoolPriority(kind string) int {
+	switch kind {
+	case QuotaPoolKindBonus:
+		return 0
+	case QuotaPoolKindPrepaid:
+		return 1
+	default:
+		return 2
+	}
+}
+
+// sortQuotaPools 按消费顺序排列额度池：先按类型（赠送、预付、授信），同类型先到期的在前，永不过期的最后
+func sortQuotaPools(pools []*QuotaPool) {
+	sort.SliceStable(pools, func(i, j int) bool {
+		a, b := pools[i], pools[j]
+		if pa, pb := quotaPoolPriority(a.Kind), quotaPoolPriority(b.Kind); pa != pb {
+			return pa < pb
+		}
+		if a.ExpiresAt != b.ExpiresAt {
+			if a.ExpiresAt == 0 || b.ExpiresAt == 0 {
+				return b.ExpiresAt == 0
+			}
+			return a.ExpiresAt < b.ExpiresAt
+		}
+		return a.Id < b.Id
+	})
+}
+
+// QuotaPoolExpiresAt 根据额度池设置计算新额度的到期时间，0 表示永不过期
+func QuotaPoolExpiresAt(kind string, now int64) int64 {
+	setting := operation_setting.GetQuotaPoolSetting()
+	days := 0
+	switch kind {
+	case QuotaPoolKindBonus:
+		days = setting.BonusExpireDays
+	case QuotaPoolKindPrepaid:
+		days = setting.PrepaidExpireDays
+	}
+	if days <= 0 {
+		return 0
+	}
+	return now + int64(days)*24*3600
+}
+
+// RecordQuotaPool 为已计入余额的额度建立额度池，到期时间取自额度池设置。
+// 永不过期的预付额度与未记入额度池的余额等价，不建立额度池。tx 为 nil 时使用 DB
+func RecordQuotaPool(tx *gorm.DB, userId int, kind string, amount int, source string) error {
+	if amount <= 0 || !IsValidQuotaPoolKind(kind) || kind == QuotaPoolKindPostpaid {
+		return nil
+	}
+	now := common.GetTimestamp()
+	expiresAt := QuotaPoolExpiresAt(kind, now)
+	if kind == QuotaPoolKindPrepaid && expiresAt == 0 {
+		return nil
+	}
+	if tx == nil {
+		tx = DB
+	}
+	return tx.Create(&QuotaPool{
+		UserId:    userId,
+		Kind:      kind,
+		Total:     amount,
+		Remaining: amount,
+		ExpiresAt: expiresAt,
+		Status:    QuotaPoolStatusActive,
+		Source:    source,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}).Error
+}
+
+// GrantQuotaPool 管理员为用户发放额度池。赠送与预付额度同时计入余额，后付费授信只记录授信额度
+func GrantQuotaPool(userId int, kind string, amount int, expiresAt int64, remark string) (*QuotaPool, error) {
+	if !IsValidQuotaPoolKind(kind) {
+		return nil, errors.New("无效的额度池类型")
+	}
+	if amount <= 0 {
+		return nil, errors.New("额度必须大于 0")
+	}
+	now := common.GetTimestamp()
+	if expiresAt != 0 && expiresAt <= now {
+		return nil, errors.New("到期时间必须晚于当前时间")
+	}
+	pool := &QuotaPool{
+		UserId:    userId,
+		Kind:      kind,
+		Total:     amount,
+		Remaining: amount,
+		ExpiresAt: expiresAt,
+		Status:    QuotaPoolStatusActive,
+		Source:    "admin",
+		Remark:    remark,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(pool).Error; err != nil {
+			return err
+		}
+		if kind == QuotaPoolKindPostpaid {
+			return nil
+		}
+		return tx.Model(&User{}).Where("id = ?", userId).Update("quota", gorm.Expr("quota + ?", amount)).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	if kind != QuotaPoolKindPostpaid {
+		if err := cacheIncrUserQuota(userId, int64(amount)); err != nil {
+			common.SysLog("failed to increase user quota cache: " + err.Error())
+		}
+	}
+	return pool, nil
+}
+
+// GetUserQuotaPools 返回用户生效中的额度池，按消费顺序排列
+func GetUserQuotaPools(userId int) ([]*QuotaPool, error) {
+	var pools []*QuotaPool
+	now := common.GetTimestamp()
+	err := DB.Where("user_id = ? AND status = ? AND (expires_at = 0 OR expires_at > ?)", userId, QuotaPoolStatusActive, now).
+		Find(&pools).Error
+	if err != nil {
+		return nil, err
+	}
+	sortQuotaPools(pools)
+	return pools, nil
+}
+
+// ConsumeQuotaPools 按消费顺序从用户的赠送与预付额度池扣除 amount，返回各额度池的扣除记录。
+// 额度池不足的部分由未记入额度池的余额或后付费授信承担。由 DecreaseUserQuota 统一调用，所有扣费路径都会同步额度池
+func ConsumeQuotaPools(userId int, amount int) ([]QuotaPoolDeduction, error) {
+	if amount <= 0 {
+		return nil, nil
+	}
+	var deductions []QuotaPoolDeduction
+	now := common.GetTimestamp()
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		var pools []*QuotaPool
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("user_id = ? AND status = ? AND kind IN ? AND remaining > 0 AND (expires_at = 0 OR expires_at > ?)",
+				userId, QuotaPoolStatusActive, []string{QuotaPoolKindBonus, QuotaPoolKindPrepaid}, now).
+			Find(&pools).Error; err != nil {
+			return err
+		}
+		sortQuotaPools(pools)
+		left := amount
+		for _, pool := range pools {
+			if left <= 0 {
+				break
+			}
+			take, err := takeFromQuotaPool(tx, pool.Id, pool.Remaining, left, now)
+			if err != nil {
+				return err
+			}
+			if take == 0 {
+				continue
+			}
+			deductions = append(deductions, QuotaPoolDeduction{PoolId: pool.Id, Amount: take})
+			left -= take
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return deductions, nil
+}
+
+// takeFromQuotaPool 从生效中的额度池扣除至多 want，返回实际扣除的数量。
+// 扣除以剩余额度为条件，并发请求先扣走一部分时按重新读取的剩余额度重试，剩余额度不会被扣成负数
+func takeFromQuotaPool(tx *gorm.DB, poolId int, remaining int, want int, now int64) (int, error) {
+	for attempt := 0; attempt < 5 && remaining > 0; attempt++ {
+		take := min(remaining, want)
+		res := tx.Model(&QuotaPool{}).
+			Where("id = ? AND status = ? AND remaining >= ?", poolId, QuotaPoolStatusActive, take).
+			Updates(map[string]interface{}{
+				"remaining":  gorm.Expr("remaining - ?", take),
+				"updated_at": now,
+			})
+		if res.Error != nil {
+			return 0, res.Error
+		}
+		if res.RowsAffected > 0 {
+			return take, nil
+		}
+		var pool QuotaPool
+		if err := tx.Select("remaining", "status").Where("id = ?", poolId).First(&pool).Error; err != nil {
+			return 0, err
+		}
+		if pool.Status != QuotaPoolStatusActive {
+			return 0, nil
+		}
+		remaining = pool.Remaining
+	}
+	return 0, nil
+}
+
+// RestoreQuotaPools 按扣除的逆序向额度池退回至多 amount，返回尚未退回的扣除记录。
+// 已过期的额度池不再退回，退款以未记入额度池的余额形式保留
+func RestoreQuotaPools(deductions []QuotaPoolDeduction, amount int) ([]QuotaPoolDeduction, error) {
+	now := common.GetTimestamp()
+	for amount > 0 && len(deductions) > 0 {
+		last := &deductions[len(deductions)-1]
+		give := min(last.Amount, amount)
+		if err := DB.Model(&QuotaPool{}).Where("id = ? AND status = ?", last.PoolId, QuotaPoolStatusActive).Updates(map[string]interface{}{
+			"remaining":  gorm.Expr("remaining + ?", give),
+			"updated_at": now,
+		}).Error; err != nil {
+			return deductions, err
+		}
+		last.Amount -= give
+		amount -= give
+		if last.Amount == 0 {
+			deductions = deductions[:len(deductions)-1]
+		}
+	}
+	return deductions, nil
+}
+
+// GetQuotaPoolPostpaidAllowance 返回用户生效中的后付费授信额度合计
+func GetQuotaPoolPostpaidAllowance(userId int) (int, error) {
+	var total int64
+	now := common.GetTimestamp()
+	err := DB.Model(&QuotaPool{}).
+		Where("user_id = ? AND kind = ? AND status = ? AND (expires_at = 0 OR expires_at > ?)",
+			userId, QuotaPoolKindPostpaid, QuotaPoolStatusActive, now).
+		Select("COALESCE(SUM(total), 0)").Scan(&total).Error
+	return int(total), err
+}
+
+// GetUserAvailableQuota 返回用户余额与可用额度，余额不足 need 或已透支时可用额度计入后付费授信
+func GetUserAvailableQuota(userId int, need int) (quota int, available int, err error) {
+	quota, err = GetUserQuota(userId, false)
+	if err != nil {
+		return 0, 0, err
+	}
+	available = quota
+	if quota < need || quota <= 0 {
+		allowance, err := GetQuotaPoolPostpaidAllowance(userId)
+		if err != nil {
+			return quota, 0, err
+		}
+		available += allowance
+	}
+	return quota, available, nil
+}
+
+// GetUserQuotaPoolSummary 返回用户额度的构成。额度池与余额分别更新，二者不一致时以余额为准
+func GetUserQuotaPoolSummary(userId int) (*QuotaPoolSummary, error) {
+	quota, err := GetUserQuota(userId, true)
+	if err != nil {
+		return nil, err
+	}
+	pools, err := GetUserQuotaPools(userId)
+	if err != nil {
+		return nil, err
+	}
+	return buildQuotaPoolSummary(quota, pools), nil
+}
+
+func buildQuotaPoolSummary(quota int, pools []*QuotaPool) *QuotaPoolSummary {
+	summary := &QuotaPoolSummary{Quota: quota, Pools: pools}
+	bonus := 0
+	for _, pool := range pools {
+		switch pool.Kind {
+		case QuotaPoolKindBonus:
+			bonus += pool.Remaining
+		case QuotaPoolKindPostpaid:
+			summary.PostpaidLimit += pool.Total
+		}
+	}
+	balance := max(quota, 0)
+	summary.Bonus = min(bonus, balance)
+	summary.Prepaid = balance - summary.Bonus
+	summary.PostpaidUsed = max(-quota, 0)
+	summary.Available = balance + max(summary.PostpaidLimit-summary.PostpaidUsed, 0)
+	return summary
+}
+
+// ExpireQuotaPools 将到期的额度池标记为过期，并从余额中扣除赠送与预付额度池的剩余额度，返回处理的额度池数量
+func ExpireQuotaPools(limit int) (int, error) {
+	if limit <= 0 {
+		limit = 200
+	}
+	now := common.GetTimestamp()
+	var pools []QuotaPool
+	if err := DB.Where("status = ? AND expires_at > 0 AND expires_at <= ?", QuotaPoolStatusActive, now).
+		Order("expires_at asc").
+		Limit(limit).
+		Find(&pools).Error; err != nil {
+		return 0, err
+	}
+	expired := 0
+	for _, pool := range pools {
+		deducted := 0
+		result := 0
+		err := DB.Transaction(func(tx *gorm.DB) error {
+			res := tx.Model(&QuotaPool{}).Where("id = ? AND status = ?", pool.Id, QuotaPoolStatusActive).Updates(map[string]interface{}{
+				"status":     QuotaPoolStatusExpired,
+				"updated_at": now,
+			})
+			if res.Error != nil {
+				return res.Error
+			}
+			result = int(res.RowsAffected)
+			if result == 0 || pool.Kind == QuotaPoolKindPostpaid {
+				return nil
+			}
+			// 标记过期后扣除不再作用于该额度池，重新读取的剩余额度不包含期间已被消耗的部分
+			var remaining int
+			if err := tx.Model(&QuotaPool{}).Where("id = ?", pool.Id).Select("remaining").Find(&remaining).Error; err != nil {
+				return err
+			}
+			if remaining <= 0 {
+				return nil
+			}
+			var quota int
+			if err := tx.Model(&User{}).Where("id = ?", pool.UserId).Select("quota").Find(&quota).Error; err != nil {
+				return err
+			}
+			deducted = min(remaining, max(quota, 0))
+			if deducted == 0 {
+				return nil
+			}
+			return tx.Model(&User{}).Where("id = ?", pool.UserId).Update("quota", gorm.Expr("quota - ?", deducted)).Error
+		})
+		if err != nil {
+			return expired, err
+		}
+		if result == 0 {
+			continue
+		}
+		expired++
+		if deducted > 0 {
+			if err := cacheDecrUserQuota(pool.UserId, int64(deducted)); err != nil {
+				common.SysLog("failed to decrease user quota cache: " + err.Error())
+			}
+			RecordLog(pool.UserId, LogTypeSystem, fmt.Sprintf("额度池 #%d 已过期，扣除剩余额度 %s", pool.Id, logger.LogQuota(deducted)))
+		}
+	}
+	return expired, nil
+}
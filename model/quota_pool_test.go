@@ -0,0 +1,151 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSortQuotaPools(t *testing.T) {
+	pools := []*QuotaPool{
+		{Id: 1, Kind: QuotaPoolKindPrepaid, ExpiresAt: 0},
+		{Id: 2, Kind: QuotaPoolKindPostpaid},
+		{Id: 3, Kind: QuotaPoolKindBonus, ExpiresAt: 0},
+		{Id: 4, Kind: QuotaPoolKindPrepaid, ExpiresAt: 2000},
+		{Id: 5, Kind: QuotaPoolKindBonus, ExpiresAt: 3000},
+		{Id: 6, Kind: QuotaPoolKindBonus, ExpiresAt: 1000},
+	}
+	sortQuotaPools(pools)
+	ids := make([]int, 0, len(pools))
+	for _, pool := range pools {
+		ids = append(ids, pool.Id)
+	}
+	assert.Equal(t, []int{6, 5, 3, 4, 1, 2}, ids)
+}
+
+func TestBuildQuotaPoolSummary(t *testing.T) {
+	pools := []*QuotaPool{
+		{Kind: QuotaPoolKindBonus, Total: 100, Remaining: 80},
+		{Kind: QuotaPoolKindPostpaid, Total: 500, Remaining: 500},
+	}
+	summary := buildQuotaPoolSummary(300, pools)
+	assert.Equal(t, 80, summary.Bonus)
+	assert.Equal(t, 220, summary.Prepaid)
+	assert.Equal(t, 0, summary.PostpaidUsed)
+	assert.Equal(t, 800, summary.Available)
+
+	// 透支时余额全部来自授信，赠送额度按余额截断
+	summary = buildQuotaPoolSummary(-200, pools)
+	assert.Equal(t, 0, summary.Bonus)
+	assert.Equal(t, 0, summary.Prepaid)
+	assert.Equal(t, 200, summary.PostpaidUsed)
+	assert.Equal(t, 300, summary.Available)
+}
+
+func TestQuotaPoolConsumeRestoreAndExpire(t *testing.T) {
+	truncateTables(t)
+	t.Cleanup(func() {
+		DB.Exec("DELETE FROM quota_pools")
+	})
+	setting := operation_setting.GetQuotaPoolSetting()
+	original := *setting
+	t.Cleanup(func() { *setting = original })
+	setting.BonusExpireDays = 7
+	setting.PrepaidExpireDays = 0
+
+	require.NoError(t, DB.Create(&User{Id: 41, Username: "pool", AffCode: "pool1", Quota: 1000}).Error)
+	// 永不过期的预付额度与未记入额度池的余额等价，不建立额度池
+	require.NoError(t, RecordQuotaPool(nil, 41, QuotaPoolKindPrepaid, 600, "topup"))
+	require.NoError(t, RecordQuotaPool(nil, 41, QuotaPoolKindBonus, 400, "checkin"))
+	pools, err := GetUserQuotaPools(41)
+	require.NoError(t, err)
+	require.Len(t, pools, 1)
+	assert.Equal(t, QuotaPoolKindBonus, pools[0].Kind)
+	assert.Greater(t, pools[0].ExpiresAt, common.GetTimestamp())
+
+	_, err = GrantQuotaPool(41, QuotaPoolKindPostpaid, 300, 0, "net 30")
+	require.NoError(t, err)
+	allowance, err := GetQuotaPoolPostpaidAllowance(41)
+	require.NoError(t, err)
+	assert.Equal(t, 300, allowance)
+
+	// 赠送额度先于余额中的预付额度消费
+	deductions, err := ConsumeQuotaPools(41, 250)
+	require.NoError(t, err)
+	assert.Equal(t, []QuotaPoolDeduction{{PoolId: pools[0].Id, Amount: 250}}, deductions)
+	deductions, err = ConsumeQuotaPools(41, 250)
+	require.NoError(t, err)
+	require.Len(t, deductions, 1)
+	assert.Equal(t, 150, deductions[0].Amount)
+
+	rest, err := RestoreQuotaPools(deductions, 100)
+	require.NoError(t, err)
+	assert.Equal(t, []QuotaPoolDeduction{{PoolId: pools[0].Id, Amount: 50}}, rest)
+	var bonus QuotaPool
+	require.NoError(t, DB.First(&bonus, pools[0].Id).Error)
+	assert.Equal(t, 100, bonus.Remaining)
+
+	// 到期后剩余的赠送额度从余额中扣除
+	require.NoError(t, DB.Model(&QuotaPool{}).Where("id = ?", bonus.Id).Update("expires_at", common.GetTimestamp()-1).Error)
+	n, err := ExpireQuotaPools(10)
+	require.NoError(t, err)
+	assert.Equal(t, 1, n)
+	quota, err := GetUserQuota(41, true)
+	require.NoError(t, err)
+	assert.Equal(t, 900, quota)
+
+	summary, err := GetUserQuotaPoolSummary(41)
+	require.NoError(t, err)
+	assert.Equal(t, 0, summary.Bonus)
+	assert.Equal(t, 900, summary.Prepaid)
+	assert.Equal(t, 300, summary.PostpaidLimit)
+	assert.Equal(t, 1200, summary.Available)
+}
+
+func TestTakeFromQuotaPoolUsesCurrentRemaining(t *testing.T) {
+	t.Cleanup(func() {
+		DB.Exec("DELETE FROM quota_pools")
+	})
+	now := common.GetTimestamp()
+	pool := &QuotaPool{UserId: 42, Kind: QuotaPoolKindBonus, Total: 500, Remaining: 100, Status: QuotaPoolStatusActive, CreatedAt: now}
+	require.NoError(t, DB.Create(pool).Error)
+
+	// 快照中的剩余额度已被并发请求扣走一部分，只扣除实际剩余的额度
+	take, err := takeFromQuotaPool(DB, pool.Id, 500, 300, now)
+	require.NoError(t, err)
+	assert.Equal(t, 100, take)
+	take, err = takeFromQuotaPool(DB, pool.Id, 100, 300, now)
+	require.NoError(t, err)
+	assert.Equal(t, 0, take)
+
+	var stored QuotaPool
+	require.NoError(t, DB.First(&stored, pool.Id).Error)
+	assert.Equal(t, 0, stored.Remaining)
+}
+
+func TestDecreaseUserQuotaConsumesQuotaPools(t *testing.T) {
+	truncateTables(t)
+	t.Cleanup(func() {
+		DB.Exec("DELETE FROM quota_pools")
+	})
+	require.NoError(t, DB.Create(&User{Id: 43, Username: "pool-debit", AffCode: "pool3", Quota: 500}).Error)
+	pool, err := GrantQuotaPool(43, QuotaPoolKindBonus, 200, common.GetTimestamp()+3600, "event")
+	require.NoError(t, err)
+
+	// 不经过计费会话的扣费（如 MJ、任务、违规扣费）同样先消耗赠送额度
+	require.NoError(t, DecreaseUserQuota(43, 300))
+	var stored QuotaPool
+	require.NoError(t, DB.First(&stored, pool.Id).Error)
+	assert.Equal(t, 0, stored.Remaining)
+
+	// 已消耗的赠送额度到期时不再从余额中扣除
+	require.NoError(t, DB.Model(&QuotaPool{}).Where("id = ?", pool.Id).Update("expires_at", common.GetTimestamp()-1).Error)
+	_, err = ExpireQuotaPools(10)
+	require.NoError(t, err)
+	quota, err := GetUserQuota(43, true)
+	require.NoError(t, err)
+	assert.Equal(t, 400, quota)
+}
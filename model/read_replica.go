@@ -0,0 +1,188 @@
+package model
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"gorm.io/driver/mysql"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+// 只读副本：SQL_READ_DSN、LOG_SQL_READ_DSN 分别为主库、日志库的只读副本（仅支持 MySQL 与 PostgreSQL，
+// 类型须与对应的数据库一致）。日志查询、统计、数据看板等读多的接口通过 ReadDB / LogReadDB 读取副本，
+// 写入仍走主库。副本每 SQL_READ_CHECK_INTERVAL 秒检测一次，不可用时自动回退到主库，恢复后重新启用。
+// 未设置 LOG_SQL_DSN 时日志表位于主库，日志查询同样使用 SQL_READ_DSN。
+
+type readReplica struct {
+	name    string
+	db      *gorm.DB
+	healthy atomic.Bool
+}
+
+var (
+	mainReadReplica *readReplica
+	logReadReplica  *readReplica
+)
+
+// ReadDB 返回主库的只读副本，未配置或副本不可用时返回主库
+func ReadDB() *gorm.DB {
+	return mainReadReplica.get(DB)
+}
+
+// LogReadDB 返回日志库的只读副本，未配置或副本不可用时返回日志库
+func LogReadDB() *gorm.DB {
+	return logReadReplica.get(LOG_DB)
+}
+
+func (r *readReplica) get(primary *gorm.DB) *gorm.DB {
+	if r == nil || !r.healthy.Load() {
+		return primary
+	}
+	return r.db
+}
+
+// InitReadDB 连接配置的只读副本，需在 InitDB、InitLogDB 之后调用；副本连接失败不影响启动
+func InitReadDB() {
+	mainReadReplica = openReadReplica("SQL_READ_DSN", mainDatabaseType())
+	if os.Getenv("LOG_SQL_DSN") == "" {
+		logReadReplica = mainReadReplica
+	} else {
+		logReadReplica = openReadReplica("LOG_SQL_READ_DSN", common.LogSqlType)
+	}
+	if mainReadReplica == nil && logReadReplica == nil {
+		return
+	}
+	interval := common.GetEnvOrDefault("SQL_READ_CHECK_INTERVAL", 10)
+	if interval <= 0 {
+		interval = 10
+	}
+	go func() {
+		ticker := time.NewTicker(time.Duration(interval) * time.Second)
+		defer ticker.Stop()
+		for range ticker.C {
+			mainReadReplica.check()
+			if logReadReplica != mainReadReplica {
+				logReadReplica.check()
+			}
+		}
+	}()
+}
+
+func mainDatabaseType() string {
+	switch {
+	case common.UsingPostgreSQL:
+		return common.DatabaseTypePostgreSQL
+	case common.UsingMySQL:
+		return common.DatabaseTypeMySQL
+	default:
+		return common.DatabaseTypeSQLite
+	}
+}
+
+// dsnDatabaseType 按 chooseDB 的规则判断连接串对应的数据库类型
+func dsnDatabaseType(dsn string) string {
+	switch {
+	case strings.HasPrefix(dsn, "postgres://") || strings.HasPrefix(dsn, "postgresql://"):
+		return common.DatabaseTypePostgreSQL
+	case strings.HasPrefix(dsn, "local"):
+		return common.DatabaseTypeSQLite
+	default:
+		return common.DatabaseTypeMySQL
+	}
+}
+
+func openReadReplica(envName string, primaryType string) *readReplica {
+	dsn := os.Getenv(envName)
+	if dsn == "" {
+		return nil
+	}
+	dsnType := dsnDatabaseType(dsn)
+	if dsnType == common.DatabaseTypeSQLite || dsnType != primaryType {
+		common.SysError(fmt.Sprintf("%s ignored: read replica must be MySQL or PostgreSQL matching the primary database (%s)", envName, primaryType))
+		return nil
+	}
+
+	var dialector gorm.Dialector
+	if dsnType == common.DatabaseTypePostgreSQL {
+		dialector = postgres.New(postgres.Config{
+			DSN:                  dsn,
+			PreferSimpleProtocol: true,
+		})
+	} else {
+		if !strings.Contains(dsn, "parseTime") {
+			if strings.Contains(dsn, "?") {
+				dsn += "&parseTime=true"
+			} else {
+				dsn += "?parseTime=true"
+			}
+		}
+		dialector = mysql.Open(dsn)
+	}
+	db, err := gorm.Open(dialector, &gorm.Config{
+		PrepareStmt: true,
+	})
+	if err != nil {
+		common.SysError(fmt.Sprintf("failed to open %s, using the primary database: %s", envName, err.Error()))
+		return nil
+	}
+	if common.DebugEnabled {
+		db = db.Debug()
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		common.SysError(fmt.Sprintf("failed to open %s, using the primary database: %s", envName, err.Error()))
+		return nil
+	}
+	sqlDB.SetMaxIdleConns(common.GetEnvOrDefault("SQL_MAX_IDLE_CONNS", 100))
+	sqlDB.SetMaxOpenConns(common.GetEnvOrDefault("SQL_MAX_OPEN_CONNS", 1000))
+	sqlDB.SetConnMaxLifetime(time.Second * time.Duration(common.GetEnvOrDefault("SQL_MAX_LIFETIME", 60)))
+
+	replica := &readReplica{name: envName, db: db}
+	replica.healthy.Store(true)
+	replica.check()
+	common.SysLog(fmt.Sprintf("read replica %s enabled", envName))
+	return replica
+}
+
+// check 检测副本连接，状态变化时记录日志
+func (r *readReplica) check() {
+	if r == nil {
+		return
+	}
+	sqlDB, err := r.db.DB()
+	if err == nil {
+		err = sqlDB.Ping()
+	}
+	healthy := err == nil
+	if r.healthy.Swap(healthy) == healthy {
+		return
+	}
+	if healthy {
+		common.SysLog(fmt.Sprintf("read replica %s is available", r.name))
+	} else {
+		common.SysError(fmt.Sprintf("read replica %s is unavailable, falling back to the primary database: %s", r.name, err.Error()))
+	}
+}
+
+func closeReadReplicas() error {
+	if err := mainReadReplica.close(); err != nil {
+		return err
+	}
+	if logReadReplica != mainReadReplica {
+		return logReadReplica.close()
+	}
+	return nil
+}
+
+func (r *readReplica) close() error {
+	if r == nil {
+		return nil
+	}
+	r.healthy.Store(false)
+	return closeDB(r.db)
+}
@@ -144,6 +144,9 @@ func Redeem(key string, userId int) (quota int, err error) {
 		if err != nil {
 			return err
 		}
+		if err = RecordQuotaPool(tx, userId, QuotaPoolKindPrepaid, redemption.Quota, "redemption"); err != nil {
+			return err
+		}
 		redemption.RedeemedTime = common.GetTimestamp()
 		redemption.Status = common.RedemptionCodeStatusUsed
 		redemption.UsedUserId = userId
@@ -0,0 +1,44 @@
+package model
+
+import (
+	"errors"
+
+	"gorm.io/gorm"
+)
+
+// RequestCapture 一次请求的载荷捕获记录，Stages 为各阶段载荷的 JSON 数组
+type RequestCapture struct {
+	Id           int    `json:"id"`
+	RequestId    string `json:"request_id" gorm:"type:varchar(64);index"`
+	UserId       int    `json:"user_id" gorm:"index"`
+	ChannelId    int    `json:"channel_id"`
+	ModelName    string `json:"model_name" gorm:"type:varchar(255)"`
+	Path         string `json:"path" gorm:"type:varchar(255)"`
+	StatusCode   int    `json:"status_code"`
+	ErrorMessage string `json:"error_message" gorm:"type:text"`
+	Stages       string `json:"-"`
+	CreatedAt    int64  `json:"created_at" gorm:"bigint;index"`
+}
+
+func CreateRequestCapture(capture *RequestCapture) error {
+	return LOG_DB.Create(capture).Error
+}
+
+// GetRequestCaptureByRequestId 按请求 ID 获取捕获记录，不存在时返回 nil
+func GetRequestCaptureByRequestId(requestId string) (*RequestCapture, error) {
+	var capture RequestCapture
+	err := LOG_DB.Where("request_id = ?", requestId).Order("id desc").First(&capture).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &capture, nil
+}
+
+// DeleteRequestCapturesBefore 删除早于指定时间戳的捕获记录
+func DeleteRequestCapturesBefore(targetTimestamp int64) (int64, error) {
+	result := LOG_DB.Where("created_at < ?", targetTimestamp).Delete(&RequestCapture{})
+	return result.RowsAffected, result.Error
+}
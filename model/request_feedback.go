@@ -0,0 +1,224 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"sync/atomic"
+
+	"github.com/QuantumNous/new-api/common"
+
+	"gorm.io/gorm"
+)
+
+const (
+	FeedbackRatingPositive = 1
+	FeedbackRatingNegative = -1
+)
+
+// RequestFeedback 用户对单次请求的评价（1 好评、-1 差评）与评论，与消费日志一同存储在日志库，
+// 模型与渠道取自该请求的消费日志，供按模型/渠道对比上游质量与渠道选择使用
+type RequestFeedback struct {
+	Id        int    `json:"id"`
+	UserId    int    `json:"user_id" gorm:"uniqueIndex:idx_feedback_user_request,priority:1"`
+	RequestId string `json:"request_id" gorm:"type:varchar(64);uniqueIndex:idx_feedback_user_request,priority:2"`
+	TokenId   int    `json:"token_id" gorm:"default:0"`
+	ModelName string `json:"model_name" gorm:"type:varchar(255);index:idx_feedback_model_channel,priority:1;default:''"`
+	ChannelId int    `json:"channel_id" gorm:"index:idx_feedback_model_channel,priority:2"`
+	Rating    int    `json:"rating"`
+	Comment   string `json:"comment" gorm:"type:text"`
+	CreatedAt int64  `json:"created_at" gorm:"bigint;index"`
+	UpdatedAt int64  `json:"updated_at" gorm:"bigint"`
+}
+
+// RequestFeedbackStat 某个模型在某个渠道上的评价汇总，Score 为 (好评数 - 差评数) / 总数，取值 [-1, 1]
+type RequestFeedbackStat struct {
+	ModelName   string  `json:"model_name"`
+	ChannelId   int     `json:"channel_id"`
+	ChannelName string  `json:"channel_name" gorm:"-"`
+	Total       int64   `json:"total"`
+	Positive    int64   `json:"positive"`
+	Negative    int64   `json:"negative"`
+	Score       float64 `json:"score" gorm:"-"`
+}
+
+// GetConsumeLogByRequestId 返回用户指定请求 ID 的消费日志，不存在时返回 nil
+func GetConsumeLogByRequestId(userId int, requestId string) (*Log, error) {
+	var log Log
+	err := LOG_DB.Where("user_id = ? AND request_id = ? AND type = ?", userId, requestId, LogTypeConsume).
+		Order("id desc").First(&log).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &log, nil
+}
+
+// UpsertRequestFeedback 保存评价，同一用户对同一请求重复评价时覆盖评分与评论
+func UpsertRequestFeedback(feedback *RequestFeedback) error {
+	now := common.GetTimestamp()
+	var existing RequestFeedback
+	err := LOG_DB.Where("user_id = ? AND request_id = ?", feedback.UserId, feedback.RequestId).First(&existing).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		feedback.CreatedAt = now
+		feedback.UpdatedAt = now
+		return LOG_DB.Create(feedback).Error
+	}
+	if err != nil {
+		return err
+	}
+	feedback.Id = existing.Id
+	feedback.CreatedAt = existing.CreatedAt
+	feedback.UpdatedAt = now
+	return LOG_DB.Model(&existing).Updates(map[string]interface{}{
+		"rating":     feedback.Rating,
+		"comment":    feedback.Comment,
+		"updated_at": now,
+	}).Error
+}
+
+func requestFeedbackQuery(modelName string, channelId int, startTimestamp int64, endTimestamp int64) *gorm.DB {
+	tx := LogReadDB().Model(&RequestFeedback{})
+	if modelName != "" {
+		tx = tx.Where("model_name = ?", modelName)
+	}
+	if channelId != 0 {
+		tx = tx.Where("channel_id = ?", channelId)
+	}
+	if startTimestamp != 0 {
+		tx = tx.Where("created_at >= ?", startTimestamp)
+	}
+	if endTimestamp != 0 {
+		tx = tx.Where("created_at <= ?", endTimestamp)
+	}
+	return tx
+}
+
+// GetRequestFeedbacks 分页查询评价，rating 为 0 时不按评分筛选
+func GetRequestFeedbacks(modelName string, channelId int, rating int, startTimestamp int64, endTimestamp int64, startIdx int, num int) ([]*RequestFeedback, int64, error) {
+	tx := requestFeedbackQuery(modelName, channelId, startTimestamp, endTimestamp)
+	if rating != 0 {
+		tx = tx.Where("rating = ?", rating)
+	}
+	var total int64
+	if err := tx.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	var feedbacks []*RequestFeedback
+	if err := tx.Order("id desc").Limit(num).Offset(startIdx).Find(&feedbacks).Error; err != nil {
+		return nil, 0, err
+	}
+	return feedbacks, total, nil
+}
+
+// GetRequestFeedbackStats 按模型与渠道汇总评价，按评价数从多到少排列
+func GetRequestFeedbackStats(modelName string, channelId int, startTimestamp int64, endTimestamp int64) ([]*RequestFeedbackStat, error) {
+	var stats []*RequestFeedbackStat
+	err := requestFeedbackQuery(modelName, channelId, startTimestamp, endTimestamp).
+		Select("model_name, channel_id, count(*) as total, " +
+			"sum(case when rating > 0 then 1 else 0 end) as positive, " +
+			"sum(case when rating < 0 then 1 else 0 end) as negative").
+		Group("model_name, channel_id").
+		Order("total desc").
+		Scan(&stats).Error
+	if err != nil {
+		return nil, err
+	}
+	channelIds := make([]int, 0, len(stats))
+	for _, stat := range stats {
+		if stat.Total > 0 {
+			stat.Score = float64(stat.Positive-stat.Negative) / float64(stat.Total)
+		}
+		if stat.ChannelId != 0 {
+			channelIds = append(channelIds, stat.ChannelId)
+		}
+	}
+	if len(channelIds) > 0 {
+		var channels []struct {
+			Id   int
+			Name string
+		}
+		if err := DB.Model(&Channel{}).Select("id, name").Where("id IN ?", channelIds).Find(&channels).Error; err != nil {
+			return nil, err
+		}
+		names := make(map[int]string, len(channels))
+		for _, channel := range channels {
+			names[channel.Id] = channel.Name
+		}
+		for _, stat := range stats {
+			stat.ChannelName = names[stat.ChannelId]
+		}
+	}
+	return stats, nil
+}
+
+// 渠道评价分数缓存：定期从日志库汇总近期评价，渠道选择时按分数调整同一优先级内渠道的权重
+var channelFeedbackScores atomic.Pointer[map[string]float64]
+
+func channelFeedbackKey(channelId int, modelName string) string {
+	return fmt.Sprintf("%d:%s", channelId, modelName)
+}
+
+// RefreshChannelFeedbackScores 汇总 since 之后的评价并更新缓存。每个用户对同一渠道与模型的评价先平均为一票，
+// 分数为各用户平均分的均值，避免单个用户的大量评价左右渠道选择；评价用户少于 minSamples 的渠道不参与调整
+func RefreshChannelFeedbackScores(since int64, minSamples int) error {
+	var rows []struct {
+		ModelName string
+		ChannelId int
+		Total     int64
+		RatingSum int64
+	}
+	err := requestFeedbackQuery("", 0, since, 0).
+		Select("model_name, channel_id, user_id, count(*) as total, sum(rating) as rating_sum").
+		Where("channel_id <> 0").
+		Group("model_name, channel_id, user_id").
+		Scan(&rows).Error
+	if err != nil {
+		return err
+	}
+	type userVotes struct {
+		users int
+		sum   float64
+	}
+	votes := make(map[string]*userVotes)
+	for _, row := range rows {
+		if row.Total == 0 {
+			continue
+		}
+		key := channelFeedbackKey(row.ChannelId, row.ModelName)
+		v := votes[key]
+		if v == nil {
+			v = &userVotes{}
+			votes[key] = v
+		}
+		v.users++
+		v.sum += float64(row.RatingSum) / float64(row.Total)
+	}
+	scores := make(map[string]float64, len(votes))
+	for key, v := range votes {
+		if v.users < minSamples {
+			continue
+		}
+		scores[key] = v.sum / float64(v.users)
+	}
+	channelFeedbackScores.Store(&scores)
+	return nil
+}
+
+// channelFeedbackWeight 按评价分数调整渠道的有效权重：权重乘以 1 + strength * 分数，至少保留原权重的 10%
+func channelFeedbackWeight(weight int, channelId int, modelName string, strength float64) int {
+	if strength <= 0 || weight <= 0 {
+		return weight
+	}
+	scores := channelFeedbackScores.Load()
+	if scores == nil {
+		return weight
+	}
+	score, ok := (*scores)[channelFeedbackKey(channelId, modelName)]
+	if !ok {
+		return weight
+	}
+	factor := max(1+strength*score, 0.1)
+	return max(int(float64(weight)*factor+0.5), 1)
+}
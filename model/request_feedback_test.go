@@ -0,0 +1,62 @@
+package model
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRequestFeedbackUpsertAndStats(t *testing.T) {
+	truncateTables(t)
+	t.Cleanup(func() {
+		DB.Exec("DELETE FROM request_feedbacks")
+		channelFeedbackScores.Store(nil)
+	})
+
+	require.NoError(t, DB.Create(&Log{UserId: 51, Type: LogTypeConsume, ModelName: "gpt-4o", ChannelId: 7, RequestId: "req-1"}).Error)
+	log, err := GetConsumeLogByRequestId(51, "req-1")
+	require.NoError(t, err)
+	require.NotNil(t, log)
+	assert.Equal(t, 7, log.ChannelId)
+
+	// 其他用户的请求不可评价
+	log, err = GetConsumeLogByRequestId(52, "req-1")
+	require.NoError(t, err)
+	assert.Nil(t, log)
+
+	first := &RequestFeedback{UserId: 51, RequestId: "req-1", ModelName: "gpt-4o", ChannelId: 7, Rating: FeedbackRatingNegative}
+	require.NoError(t, UpsertRequestFeedback(first))
+	// 重复评价覆盖之前的结果
+	second := &RequestFeedback{UserId: 51, RequestId: "req-1", ModelName: "gpt-4o", ChannelId: 7, Rating: FeedbackRatingPositive, Comment: "good"}
+	require.NoError(t, UpsertRequestFeedback(second))
+	assert.Equal(t, first.Id, second.Id)
+	require.NoError(t, UpsertRequestFeedback(&RequestFeedback{UserId: 52, RequestId: "req-2", ModelName: "gpt-4o", ChannelId: 7, Rating: FeedbackRatingPositive}))
+
+	feedbacks, total, err := GetRequestFeedbacks("gpt-4o", 0, FeedbackRatingPositive, 0, 0, 0, 10)
+	require.NoError(t, err)
+	assert.EqualValues(t, 2, total)
+	require.Len(t, feedbacks, 2)
+
+	stats, err := GetRequestFeedbackStats("", 0, 0, 0)
+	require.NoError(t, err)
+	require.Len(t, stats, 1)
+	assert.EqualValues(t, 2, stats[0].Total)
+	assert.EqualValues(t, 2, stats[0].Positive)
+	assert.Equal(t, 1.0, stats[0].Score)
+
+	require.NoError(t, RefreshChannelFeedbackScores(0, 3))
+	assert.Equal(t, 10, channelFeedbackWeight(10, 7, "gpt-4o", 0.5))
+	require.NoError(t, RefreshChannelFeedbackScores(0, 2))
+	assert.Equal(t, 15, channelFeedbackWeight(10, 7, "gpt-4o", 0.5))
+	assert.Equal(t, 10, channelFeedbackWeight(10, 8, "gpt-4o", 0.5))
+
+	// 单个用户的大量差评只计为一票
+	for i := 0; i < 20; i++ {
+		require.NoError(t, UpsertRequestFeedback(&RequestFeedback{UserId: 53, RequestId: fmt.Sprintf("req-spam-%d", i), ModelName: "gpt-4o", ChannelId: 7, Rating: FeedbackRatingNegative}))
+	}
+	require.NoError(t, RefreshChannelFeedbackScores(0, 3))
+	// (1 + 1 - 1) / 3 个用户
+	assert.Equal(t, 12, channelFeedbackWeight(10, 7, "gpt-4o", 0.5))
+}
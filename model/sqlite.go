@@ -0,0 +1,75 @@
+package model
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"gorm.io/gorm"
+)
+
+// SQLite 连接参数：SQLITE_JOURNAL_MODE、SQLITE_SYNCHRONOUS、SQLITE_WAL_AUTOCHECKPOINT 以 _pragma 形式
+// 追加到连接串，对连接池中的每个连接生效；未设置时保持驱动默认值。
+// SQLITE_WAL_AUTOCHECKPOINT=0 关闭自动 checkpoint，由 SQLITE_CHECKPOINT_INTERVAL 定时执行 PASSIVE checkpoint，
+// PASSIVE 不会等待读事务，litestream 等复制工具持有读锁时不会被打断。
+
+// sqliteDSN 返回追加了 pragma 参数的 SQLite 连接串，path 中已设置的 pragma 不会被覆盖
+func sqliteDSN(path string) string {
+	pragmas := make([]string, 0, 4)
+	addPragma := func(name string, value string) {
+		value = strings.TrimSpace(value)
+		if value == "" || strings.Contains(path, name+"(") {
+			return
+		}
+		pragmas = append(pragmas, fmt.Sprintf("_pragma=%s(%s)", name, value))
+	}
+	addPragma("journal_mode", os.Getenv("SQLITE_JOURNAL_MODE"))
+	addPragma("synchronous", os.Getenv("SQLITE_SYNCHRONOUS"))
+	addPragma("wal_autocheckpoint", os.Getenv("SQLITE_WAL_AUTOCHECKPOINT"))
+	if len(pragmas) == 0 {
+		return path
+	}
+	// WAL 模式下写入仍是串行的，等待锁而不是立即返回 SQLITE_BUSY
+	addPragma("busy_timeout", "30000")
+	separator := "?"
+	if strings.Contains(path, "?") {
+		separator = "&"
+	}
+	return path + separator + strings.Join(pragmas, "&")
+}
+
+// StartSQLiteCheckpointTask 按 SQLITE_CHECKPOINT_INTERVAL（秒）定时对 SQLite 执行 PASSIVE checkpoint，0 表示不执行
+func StartSQLiteCheckpointTask() {
+	interval := common.GetEnvOrDefault("SQLITE_CHECKPOINT_INTERVAL", 0)
+	if interval <= 0 {
+		return
+	}
+	dbs := make([]*gorm.DB, 0, 2)
+	if common.UsingSQLite {
+		dbs = append(dbs, DB)
+	}
+	if LOG_DB != nil && LOG_DB != DB && common.LogSqlType == common.DatabaseTypeSQLite {
+		dbs = append(dbs, LOG_DB)
+	}
+	if len(dbs) == 0 {
+		return
+	}
+	go func() {
+		common.SysLog(fmt.Sprintf("sqlite checkpoint task started: interval=%ds", interval))
+		ticker := time.NewTicker(time.Duration(interval) * time.Second)
+		defer ticker.Stop()
+		for range ticker.C {
+			for _, db := range dbs {
+				sqliteCheckpoint(db)
+			}
+		}
+	}()
+}
+
+func sqliteCheckpoint(db *gorm.DB) {
+	if err := db.Exec("PRAGMA wal_checkpoint(PASSIVE)").Error; err != nil {
+		common.SysError("failed to checkpoint sqlite database: " + err.Error())
+	}
+}
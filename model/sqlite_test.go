@@ -0,0 +1,23 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSQLiteDSN(t *testing.T) {
+	t.Setenv("SQLITE_JOURNAL_MODE", "")
+	t.Setenv("SQLITE_SYNCHRONOUS", "")
+	t.Setenv("SQLITE_WAL_AUTOCHECKPOINT", "")
+	require.Equal(t, "one-api.db", sqliteDSN("one-api.db"))
+
+	t.Setenv("SQLITE_JOURNAL_MODE", "WAL")
+	t.Setenv("SQLITE_WAL_AUTOCHECKPOINT", "0")
+	require.Equal(t,
+		"one-api.db?_busy_timeout=30000&_pragma=journal_mode(WAL)&_pragma=wal_autocheckpoint(0)&_pragma=busy_timeout(30000)",
+		sqliteDSN("one-api.db?_busy_timeout=30000"))
+	require.Equal(t,
+		"data.db?_pragma=journal_mode(DELETE)&_pragma=wal_autocheckpoint(0)&_pragma=busy_timeout(30000)",
+		sqliteDSN("data.db?_pragma=journal_mode(DELETE)"))
+}
@@ -0,0 +1,200 @@
+package model
+
+import (
+	"errors"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// StoredResponse 以 store=true 返回的 Responses API 响应。
+// 经兼容层返回的响应由网关保存：Messages 为本轮新增的 Chat Completions 格式消息（不含 instructions）的 JSON，
+// 续接的上一个响应之前的 MessagesOffset 条消息保存在链上较早的响应中，沿 previous_response_id 拼接即为完整对话，
+// MessagesOffset 为 0 时 Messages 即完整对话；同一对话链的响应共享 ConversationId（链上第一个响应的 ID），
+// 读取时一次查询整条链，新增响应时整条链一起续期。Response 为返回给客户端的响应对象；
+// 原生渠道的响应保存在上游（Native 为 true），仅记录所属渠道与创建时使用的多密钥序号 KeyIndex，
+// 查询、删除、取消时以同一密钥转发到该渠道；
+// background=true 的请求（Background 为 true）由网关在后台执行，Response 随执行进度更新，
+// Status 与 Response 中的 status 一致，写回时以其为条件，避免并发的取消与任务结果相互覆盖；
+// PreviousResponseId 为请求续接的上一个响应，用于导出整条对话链；
+// Rating（1 好评、-1 差评、0 未评价）与 Tags 为用户对响应的反馈，导出训练数据集时据此筛选。
+// Messages 与 Response 不指定列类型：MySQL 为 longtext，PostgreSQL 与 SQLite 为 text，避免 MySQL text 的 64KB 上限
+type StoredResponse struct {
+	Id                 int    `json:"id"`
+	ResponseId         string `json:"response_id" gorm:"type:varchar(64);uniqueIndex"`
+	PreviousResponseId string `json:"previous_response_id" gorm:"type:varchar(64)"`
+	UserId             int    `json:"user_id" gorm:"index"`
+	ChannelId          int    `json:"channel_id"`
+	KeyIndex           int    `json:"key_index" gorm:"default:0"`
+	ModelName          string `json:"model_name" gorm:"type:varchar(255)"`
+	Native             bool   `json:"native"`
+	Background         bool   `json:"background"`
+	Status             string `json:"status" gorm:"type:varchar(32);index"`
+	ConversationId     string `json:"conversation_id" gorm:"type:varchar(64);index"`
+	MessagesOffset     int    `json:"messages_offset" gorm:"default:0"`
+	Messages           string `json:"messages"`
+	Response           string `json:"response"`
+	Rating             int    `json:"rating" gorm:"default:0"`
+	Tags               string `json:"tags" gorm:"type:varchar(255)"` // 逗号分隔
+	CreatedAt          int64  `json:"created_at" gorm:"bigint"`
+	ExpiresAt          int64  `json:"expires_at" gorm:"bigint;index"`
+}
+
+func CreateStoredResponse(response *StoredResponse) error {
+	return DB.Create(response).Error
+}
+
+// GetStoredResponse 获取用户未过期的已存储响应，不存在时返回 nil
+func GetStoredResponse(responseId string, userId int, now int64) (*StoredResponse, error) {
+	var response StoredResponse
+	err := DB.Where("response_id = ? AND user_id = ? AND expires_at > ?", responseId, userId, now).First(&response).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &response, nil
+}
+
+// UpdateStoredResponse 更新已存储响应的对话、续接的上一个响应与响应对象
+func UpdateStoredResponse(response *StoredResponse) error {
+	return DB.Model(response).Select("messages", "messages_offset", "conversation_id", "previous_response_id", "response").Updates(response).Error
+}
+
+// UpdateStoredResponseIfStatus 仅在记录的状态仍为 status 时写回对话、响应对象与状态，返回是否写入
+func UpdateStoredResponseIfStatus(response *StoredResponse, status string) (bool, error) {
+	result := DB.Model(&StoredResponse{}).Where("id = ? AND status = ?", response.Id, status).
+		Select("messages", "messages_offset", "conversation_id", "previous_response_id", "response", "status").
+		Updates(response)
+	return result.RowsAffected > 0, result.Error
+}
+
+// GetStaleBackgroundResponses 返回创建时间早于 before 且状态仍为 statuses 之一的后台响应
+func GetStaleBackgroundResponses(statuses []string, before int64, limit int) ([]*StoredResponse, error) {
+	var responses []*StoredResponse
+	err := DB.Where("background = ? AND status IN ? AND created_at < ?", true, statuses, before).
+		Order("id asc").Limit(limit).Find(&responses).Error
+	return responses, err
+}
+
+// GetStoredConversation 返回用户同一对话链上 ID 不大于 maxId（即不晚于该响应创建）的未过期存储响应，
+// 按 ID 倒序至多返回 limit 条
+func GetStoredConversation(conversationId string, userId int, maxId int, now int64, limit int) ([]*StoredResponse, error) {
+	var responses []*StoredResponse
+	err := DB.Where("conversation_id = ? AND user_id = ? AND id <= ? AND expires_at > ?", conversationId, userId, maxId, now).
+		Order("id desc").Limit(limit).Find(&responses).Error
+	return responses, err
+}
+
+// ExtendStoredConversation 将对话链上较早过期的响应续期到 expiresAt，使链上较新的响应始终能还原完整对话；
+// 早期保存的响应没有 conversation_id，以其 response_id 作为对话链 ID 匹配
+func ExtendStoredConversation(conversationId string, userId int, expiresAt int64) error {
+	return DB.Model(&StoredResponse{}).
+		Where("(conversation_id = ? OR response_id = ?) AND user_id = ? AND expires_at < ?", conversationId, conversationId, userId, expiresAt).
+		Update("expires_at", expiresAt).Error
+}
+
+// DeleteStoredResponse 删除用户的已存储响应，不存在时返回 gorm.ErrRecordNotFound
+func DeleteStoredResponse(responseId string, userId int) error {
+	result := DB.Where("response_id = ? AND user_id = ?", responseId, userId).Delete(&StoredResponse{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// DeleteExpiredStoredResponses 删除已过期的存储响应
+func DeleteExpiredStoredResponses(now int64) (int64, error) {
+	result := DB.Where("expires_at <= ?", now).Delete(&StoredResponse{})
+	return result.RowsAffected, result.Error
+}
+
+// StoredResponseTagList 返回逗号分隔的标签列表
+func StoredResponseTagList(tags string) []string {
+	list := make([]string, 0)
+	for _, tag := range strings.Split(tags, ",") {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			list = append(list, tag)
+		}
+	}
+	return list
+}
+
+// JoinStoredResponseTags 去除空白与重复后合并标签，超出字段长度的标签被丢弃
+func JoinStoredResponseTags(tags []string) string {
+	seen := make(map[string]bool)
+	joined := ""
+	for _, tag := range tags {
+		tag = strings.ReplaceAll(strings.TrimSpace(tag), ",", "")
+		if tag == "" || seen[tag] {
+			continue
+		}
+		next := tag
+		if joined != "" {
+			next = joined + "," + tag
+		}
+		if len(next) > 255 {
+			break
+		}
+		seen[tag] = true
+		joined = next
+	}
+	return joined
+}
+
+// UpdateStoredResponseFeedback 更新存储响应的评价与标签
+func UpdateStoredResponseFeedback(response *StoredResponse) error {
+	return DB.Model(response).Select("rating", "tags").Updates(response).Error
+}
+
+// DatasetResponseFilter 导出训练数据集时筛选存储响应的条件，零值表示不限
+type DatasetResponseFilter struct {
+	Models         []string `json:"models,omitempty"`
+	Tags           []string `json:"tags,omitempty"` // 带有任一标签即可
+	Rating         *int     `json:"rating,omitempty"`
+	UserId         int      `json:"user_id,omitempty"`
+	StartTimestamp int64    `json:"start_timestamp,omitempty"`
+	EndTimestamp   int64    `json:"end_timestamp,omitempty"`
+}
+
+// Match 判断存储响应是否满足标签条件，其余条件由查询处理
+func (f *DatasetResponseFilter) Match(response *StoredResponse) bool {
+	if len(f.Tags) == 0 {
+		return true
+	}
+	for _, tag := range StoredResponseTagList(response.Tags) {
+		for _, want := range f.Tags {
+			if tag == want {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// GetDatasetStoredResponses 按 ID 顺序返回 afterId 之后经兼容层保存且未过期的存储响应，标签条件由调用方用 Match 过滤
+func GetDatasetStoredResponses(filter *DatasetResponseFilter, afterId int, limit int, now int64) ([]*StoredResponse, error) {
+	query := DB.Where("id > ? AND native = ? AND messages <> '' AND expires_at > ?", afterId, false, now)
+	if len(filter.Models) > 0 {
+		query = query.Where("model_name IN ?", filter.Models)
+	}
+	if filter.Rating != nil {
+		query = query.Where("rating = ?", *filter.Rating)
+	}
+	if filter.UserId > 0 {
+		query = query.Where("user_id = ?", filter.UserId)
+	}
+	if filter.StartTimestamp > 0 {
+		query = query.Where("created_at >= ?", filter.StartTimestamp)
+	}
+	if filter.EndTimestamp > 0 {
+		query = query.Where("created_at < ?", filter.EndTimestamp)
+	}
+	var responses []*StoredResponse
+	err := query.Order("id asc").Limit(limit).Find(&responses).Error
+	return responses, err
+}
@@ -0,0 +1,98 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func TestStoredResponseOwnershipAndExpiry(t *testing.T) {
+	t.Cleanup(func() { DB.Exec("DELETE FROM stored_responses") })
+
+	require.NoError(t, CreateStoredResponse(&StoredResponse{ResponseId: "resp_a", UserId: 1, Response: `{"id":"resp_a"}`, CreatedAt: 100, ExpiresAt: 200}))
+	require.NoError(t, CreateStoredResponse(&StoredResponse{ResponseId: "resp_b", UserId: 1, ChannelId: 3, Native: true, CreatedAt: 100, ExpiresAt: 120}))
+
+	stored, err := GetStoredResponse("resp_a", 1, 150)
+	require.NoError(t, err)
+	require.NotNil(t, stored)
+	require.Equal(t, `{"id":"resp_a"}`, stored.Response)
+
+	stored, err = GetStoredResponse("resp_a", 2, 150)
+	require.NoError(t, err)
+	require.Nil(t, stored)
+	stored, err = GetStoredResponse("resp_b", 1, 150)
+	require.NoError(t, err)
+	require.Nil(t, stored)
+
+	require.ErrorIs(t, DeleteStoredResponse("resp_a", 2), gorm.ErrRecordNotFound)
+	require.NoError(t, DeleteStoredResponse("resp_a", 1))
+	require.ErrorIs(t, DeleteStoredResponse("resp_a", 1), gorm.ErrRecordNotFound)
+
+	deleted, err := DeleteExpiredStoredResponses(150)
+	require.NoError(t, err)
+	require.EqualValues(t, 1, deleted)
+}
+
+func TestStoredResponseTags(t *testing.T) {
+	require.Equal(t, "math,chat,ab", JoinStoredResponseTags([]string{" math ", "", "chat", "math", "a,b"}))
+	require.Equal(t, []string{"math", "chat"}, StoredResponseTagList("math, chat,,"))
+}
+
+func TestGetDatasetStoredResponses(t *testing.T) {
+	t.Cleanup(func() { DB.Exec("DELETE FROM stored_responses") })
+
+	require.NoError(t, CreateStoredResponse(&StoredResponse{ResponseId: "resp_1", UserId: 1, ModelName: "gpt-4o", Messages: "[]", Rating: 1, Tags: "math", CreatedAt: 100, ExpiresAt: 500}))
+	require.NoError(t, CreateStoredResponse(&StoredResponse{ResponseId: "resp_2", UserId: 1, ModelName: "gpt-4o", Messages: "[]", Rating: -1, CreatedAt: 200, ExpiresAt: 500}))
+	require.NoError(t, CreateStoredResponse(&StoredResponse{ResponseId: "resp_3", UserId: 2, ModelName: "claude", Messages: "[]", CreatedAt: 300, ExpiresAt: 500}))
+	require.NoError(t, CreateStoredResponse(&StoredResponse{ResponseId: "resp_4", UserId: 1, ModelName: "gpt-4o", Native: true, CreatedAt: 300, ExpiresAt: 500}))
+
+	all, err := GetDatasetStoredResponses(&DatasetResponseFilter{}, 0, 10, 400)
+	require.NoError(t, err)
+	require.Len(t, all, 3)
+
+	positive := 1
+	rated, err := GetDatasetStoredResponses(&DatasetResponseFilter{Models: []string{"gpt-4o"}, Rating: &positive}, 0, 10, 400)
+	require.NoError(t, err)
+	require.Len(t, rated, 1)
+	require.True(t, (&DatasetResponseFilter{Tags: []string{"math"}}).Match(rated[0]))
+	require.False(t, (&DatasetResponseFilter{Tags: []string{"chat"}}).Match(rated[0]))
+
+	ranged, err := GetDatasetStoredResponses(&DatasetResponseFilter{StartTimestamp: 150, EndTimestamp: 300}, 0, 10, 400)
+	require.NoError(t, err)
+	require.Len(t, ranged, 1)
+	require.Equal(t, "resp_2", ranged[0].ResponseId)
+
+	page, err := GetDatasetStoredResponses(&DatasetResponseFilter{}, all[0].Id, 10, 400)
+	require.NoError(t, err)
+	require.Len(t, page, 2)
+}
+
+func TestStoredConversationExpiry(t *testing.T) {
+	t.Cleanup(func() { DB.Exec("DELETE FROM stored_responses") })
+
+	// resp_root 为早期保存的响应，没有 conversation_id
+	require.NoError(t, CreateStoredResponse(&StoredResponse{ResponseId: "resp_root", UserId: 1, Messages: "[]", CreatedAt: 100, ExpiresAt: 200}))
+	require.NoError(t, CreateStoredResponse(&StoredResponse{ResponseId: "resp_next", PreviousResponseId: "resp_root", ConversationId: "resp_root", MessagesOffset: 1, UserId: 1, Messages: "[]", CreatedAt: 150, ExpiresAt: 250}))
+	require.NoError(t, CreateStoredResponse(&StoredResponse{ResponseId: "resp_other", ConversationId: "resp_root", UserId: 2, Messages: "[]", CreatedAt: 150, ExpiresAt: 250}))
+
+	require.NoError(t, ExtendStoredConversation("resp_root", 1, 300))
+	root, err := GetStoredResponse("resp_root", 1, 260)
+	require.NoError(t, err)
+	require.NotNil(t, root)
+	require.EqualValues(t, 300, root.ExpiresAt)
+
+	next, err := GetStoredResponse("resp_next", 1, 260)
+	require.NoError(t, err)
+	conversation, err := GetStoredConversation("resp_root", 1, next.Id, 260, 10)
+	require.NoError(t, err)
+	require.Len(t, conversation, 1)
+	require.Equal(t, "resp_next", conversation[0].ResponseId)
+	conversation, err = GetStoredConversation("resp_root", 1, next.Id-1, 260, 10)
+	require.NoError(t, err)
+	require.Empty(t, conversation)
+
+	other, err := GetStoredResponse("resp_other", 2, 100)
+	require.NoError(t, err)
+	require.EqualValues(t, 250, other.ExpiresAt)
+}
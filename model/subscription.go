@@ -86,7 +86,7 @@ func getSubscriptionPlanCache() *cachex.HybridCache[SubscriptionPlan] {
 	subscriptionPlanCacheOnce.Do(func() {
 		ttl := subscriptionPlanCacheTTL()
 		subscriptionPlanCache = cachex.NewHybridCache[SubscriptionPlan](cachex.HybridCacheConfig[SubscriptionPlan]{
-			Namespace: cachex.Namespace(subscriptionPlanCacheNamespace),
+			Namespace: cachex.Namespace(common.RedisKey(subscriptionPlanCacheNamespace)),
 			Redis:     common.RDB,
 			RedisEnabled: func() bool {
 				return common.RedisEnabled && common.RDB != nil
@@ -107,7 +107,7 @@ func getSubscriptionPlanInfoCache() *cachex.HybridCache[SubscriptionPlanInfo] {
 	subscriptionPlanInfoCacheOnce.Do(func() {
 		ttl := subscriptionPlanInfoCacheTTL()
 		subscriptionPlanInfoCache = cachex.NewHybridCache[SubscriptionPlanInfo](cachex.HybridCacheConfig[SubscriptionPlanInfo]{
-			Namespace: cachex.Namespace(subscriptionPlanInfoCacheNamespace),
+			Namespace: cachex.Namespace(common.RedisKey(subscriptionPlanInfoCacheNamespace)),
 			Redis:     common.RDB,
 			RedisEnabled: func() bool {
 				return common.RedisEnabled && common.RDB != nil
@@ -10,6 +10,7 @@ import (
 	"github.com/QuantumNous/new-api/constant"
 	"github.com/QuantumNous/new-api/dto"
 	commonRelay "github.com/QuantumNous/new-api/relay/common"
+	"github.com/QuantumNous/new-api/types"
 )
 
 type TaskStatus string
@@ -31,6 +32,19 @@ func (t TaskStatus) ToVideoStatus() string {
 	return status
 }
 
+func (t TaskStatus) ToBatchRequestStatus() string {
+	switch t {
+	case TaskStatusSubmitted, TaskStatusInProgress:
+		return dto.BatchRequestStatusInProgress
+	case TaskStatusSuccess:
+		return dto.BatchRequestStatusCompleted
+	case TaskStatusFailure:
+		return dto.BatchRequestStatusFailed
+	default:
+		return dto.BatchRequestStatusQueued
+	}
+}
+
 const (
 	TaskStatusNotStart   TaskStatus = "NOT_START"
 	TaskStatusSubmitted             = "SUBMITTED"
@@ -112,6 +126,8 @@ type TaskBillingContext struct {
 	ModelPrice      float64            `json:"model_price,omitempty"`       // 模型单价
 	GroupRatio      float64            `json:"group_ratio,omitempty"`       // 分组倍率
 	ModelRatio      float64            `json:"model_ratio,omitempty"`       // 模型倍率
+	CompletionRatio float64            `json:"completion_ratio,omitempty"`  // 补全倍率（按 usage 结算时使用）
+	CacheRatio      float64            `json:"cache_ratio,omitempty"`       // 缓存倍率（按 usage 结算时使用）
 	OtherRatios     map[string]float64 `json:"other_ratios,omitempty"`      // 附加倍率（时长、分辨率等）
 	OriginModelName string             `json:"origin_model_name,omitempty"` // 模型名称，必须为OriginModelName
 	PerCallBilling  bool               `json:"per_call_billing,omitempty"`  // 按次计费：跳过轮询阶段的差额结算
@@ -291,8 +307,10 @@ func TaskGetAllTasks(startIdx int, num int, queryParams SyncTaskQueryParams) []*
 
 func GetTimedOutUnfinishedTasks(cutoffUnix int64, limit int) []*Task {
 	var tasks []*Task
+	// /v1/batches 的 Batch 按 expires_at 由自身的轮询结束，不参与任务超时
 	err := DB.Where("progress != ?", "100%").
 		Where("status NOT IN ?", []string{TaskStatusFailure, TaskStatusSuccess}).
+		Where("platform != ?", constant.TaskPlatformBatchApi).
 		Where("submit_time < ?", cutoffUnix).
 		Order("submit_time").
 		Limit(limit).
@@ -506,3 +524,57 @@ func (t *Task) ToOpenAIVideo() *dto.OpenAIVideo {
 	openAIVideo.SetMetadata("url", t.GetResultURL())
 	return openAIVideo
 }
+
+// ToBatchRequest 转换为 Batch 请求对象。
+// Batch 任务的 Data 在完成前保存上游请求体，成功后替换为上游响应体。
+func (t *Task) ToBatchRequest() *dto.BatchRequest {
+	batchRequest := &dto.BatchRequest{
+		ID:        t.TaskID,
+		Object:    "batch_request",
+		Model:     t.Properties.OriginModelName,
+		Status:    t.Status.ToBatchRequestStatus(),
+		CreatedAt: t.CreatedAt,
+	}
+	switch t.Status {
+	case TaskStatusSuccess:
+		batchRequest.CompletedAt = t.FinishTime
+		batchRequest.Response = t.Data
+	case TaskStatusFailure:
+		batchRequest.CompletedAt = t.FinishTime
+		batchRequest.Error = &types.OpenAIError{
+			Message: t.FailReason,
+			Type:    "batch_error",
+		}
+	}
+	return batchRequest
+}
+
+// ToOpenAIBatch 将 /v1/batches 提交的 Batch 任务转换为 Batch 对象；
+// 任务被超时清理等方式结束而 Batch 状态未同步时按失败返回
+func (t *Task) ToOpenAIBatch() *dto.OpenAIBatch {
+	var batch dto.OpenAIBatch
+	_ = common.Unmarshal(t.Data, &batch)
+	batch.ID = t.TaskID
+	batch.Object = "batch"
+	if t.Status == TaskStatusFailure && !batch.IsTerminal() {
+		batch.Status = dto.OpenAIBatchStatusFailed
+		batch.FailedAt = t.FinishTime
+		batch.Errors = &dto.OpenAIBatchErrors{Data: []types.OpenAIError{{Message: t.FailReason, Code: "batch_failed"}}}
+	}
+	return &batch
+}
+
+// GetUserBatchTasks 按创建时间倒序分页查询用户通过 /v1/batches 提交的 Batch，after 为上一页最后一个 Batch 的 ID
+func GetUserBatchTasks(userId int, after string, limit int) ([]*Task, error) {
+	query := DB.Where("user_id = ? and platform = ?", userId, constant.TaskPlatformBatchApi)
+	if after != "" {
+		var cursor Task
+		if err := DB.Select("id").Where("user_id = ? and task_id = ?", userId, after).First(&cursor).Error; err != nil {
+			return nil, err
+		}
+		query = query.Where("id < ?", cursor.ID)
+	}
+	var tasks []*Task
+	err := query.Order("id desc").Limit(limit).Find(&tasks).Error
+	return tasks, err
+}
@@ -33,7 +33,7 @@ func TestMain(m *testing.M) {
 	}
 	sqlDB.SetMaxOpenConns(1)
 
-	if err := db.AutoMigrate(&Task{}, &User{}, &Token{}, &Log{}, &Channel{}); err != nil {
+	if err := db.AutoMigrate(&Task{}, &User{}, &Token{}, &Log{}, &Channel{}, &Tenant{}, &ReportSubscription{}, &FeatureFlag{}, &StoredResponse{}, &ModerationReview{}, &ModelDeprecationUsage{}, &PromptTemplate{}, &TokenQuotaPeriodRecord{}, &QuotaPool{}, &RequestFeedback{}); err != nil {
 		panic("failed to migrate: " + err.Error())
 	}
 
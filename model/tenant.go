@@ -0,0 +1,319 @@
+package model
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/constant"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+const (
+	TenantStatusEnabled  = 1
+	TenantStatusDisabled = 2
+
+	tenantCacheTTL = time.Minute
+)
+
+// Tenant is an isolated namespace hosted by the same deployment, selected by hostname
+// or by the /t/{slug} path prefix. Users belong to exactly one tenant (0 = the platform
+// itself). Channels and pricing are isolated through groups: a tenant owns a set of
+// groups, its users can only be placed in and use those groups, and channels and group
+// ratios are assigned per group as usual.
+type Tenant struct {
+	Id           int       `json:"id"`
+	Name         string    `json:"name" gorm:"type:varchar(64);not null"`
+	Slug         string    `json:"slug" gorm:"type:varchar(64);uniqueIndex;not null"`
+	Hostname     string    `json:"hostname" gorm:"type:varchar(255);index"`
+	Status       int       `json:"status" gorm:"type:int;default:1"`
+	Groups       string    `json:"groups" gorm:"type:text;column:tenant_groups"` // comma separated group names owned by the tenant
+	DefaultGroup string    `json:"default_group" gorm:"type:varchar(64)"`
+	SystemName   string    `json:"system_name" gorm:"type:varchar(128)"`
+	Logo         string    `json:"logo" gorm:"type:varchar(512)"`
+	Footer       string    `json:"footer" gorm:"type:text"`
+	Branding     JSONValue `json:"branding" gorm:"type:json"` // overrides of the global branding settings
+	CreatedTime  int64     `json:"created_time" gorm:"bigint"`
+}
+
+// GetGroups returns the groups owned by the tenant, the default group first.
+func (t *Tenant) GetGroups() []string {
+	groups := make([]string, 0)
+	if t.DefaultGroup != "" {
+		groups = append(groups, t.DefaultGroup)
+	}
+	for _, group := range strings.Split(t.Groups, ",") {
+		group = strings.TrimSpace(group)
+		if group != "" && !common.StringsContains(groups, group) {
+			groups = append(groups, group)
+		}
+	}
+	return groups
+}
+
+// OwnsGroup reports whether the group belongs to the tenant.
+func (t *Tenant) OwnsGroup(group string) bool {
+	return common.StringsContains(t.GetGroups(), group)
+}
+
+func normalizeTenant(tenant *Tenant) {
+	tenant.Name = strings.TrimSpace(tenant.Name)
+	tenant.Slug = strings.ToLower(strings.TrimSpace(tenant.Slug))
+	tenant.Hostname = normalizeTenantHost(tenant.Hostname)
+	tenant.DefaultGroup = strings.TrimSpace(tenant.DefaultGroup)
+	tenant.Groups = strings.Join(tenant.GetGroups(), ",")
+	if tenant.Status == 0 {
+		tenant.Status = TenantStatusEnabled
+	}
+}
+
+// normalizeTenantHost lowercases the host and strips the port.
+func normalizeTenantHost(host string) string {
+	host = strings.ToLower(strings.TrimSpace(host))
+	if i := strings.LastIndex(host, ":"); i >= 0 && !strings.Contains(host[i:], "]") {
+		host = host[:i]
+	}
+	return host
+}
+
+func validateTenant(tenant *Tenant) error {
+	if tenant.Name == "" {
+		return errors.New("tenant name is required")
+	}
+	if tenant.Slug == "" {
+		return errors.New("tenant slug is required")
+	}
+	for _, r := range tenant.Slug {
+		if !(r >= 'a' && r <= 'z' || r >= '0' && r <= '9' || r == '-' || r == '_') {
+			return errors.New("tenant slug may only contain lowercase letters, digits, '-' and '_'")
+		}
+	}
+	if tenant.DefaultGroup == "" {
+		return errors.New("tenant default group is required")
+	}
+	if len(tenant.Branding) > 0 {
+		branding := json.RawMessage(tenant.Branding)
+		if !common.ValidJson(branding) {
+			return errors.New("tenant branding must be a JSON object")
+		}
+		// null means no overrides; arrays, strings and numbers cannot override branding settings
+		if jsonType := common.GetJsonType(branding); jsonType != "object" && jsonType != "null" {
+			return errors.New("tenant branding must be a JSON object")
+		}
+	}
+	return nil
+}
+
+func GetAllTenants() ([]*Tenant, error) {
+	var tenants []*Tenant
+	err := DB.Order("id asc").Find(&tenants).Error
+	return tenants, err
+}
+
+func GetTenantById(id int) (*Tenant, error) {
+	var tenant Tenant
+	if err := DB.First(&tenant, id).Error; err != nil {
+		return nil, err
+	}
+	return &tenant, nil
+}
+
+func CreateTenant(tenant *Tenant) error {
+	normalizeTenant(tenant)
+	if err := validateTenant(tenant); err != nil {
+		return err
+	}
+	tenant.CreatedTime = common.GetTimestamp()
+	if err := DB.Create(tenant).Error; err != nil {
+		return err
+	}
+	InvalidateTenantCache()
+	return nil
+}
+
+func UpdateTenant(tenant *Tenant) error {
+	normalizeTenant(tenant)
+	if err := validateTenant(tenant); err != nil {
+		return err
+	}
+	if err := DB.Model(&Tenant{}).Where("id = ?", tenant.Id).Select(
+		"name", "slug", "hostname", "status", "tenant_groups", "default_group", "system_name", "logo", "footer", "branding",
+	).Updates(tenant).Error; err != nil {
+		return err
+	}
+	InvalidateTenantCache()
+	return nil
+}
+
+// DeleteTenant deletes a tenant that no longer has users.
+func DeleteTenant(id int) error {
+	if CountTenantUsers(id) > 0 {
+		return ErrTenantHasUsers
+	}
+	if err := DB.Delete(&Tenant{}, id).Error; err != nil {
+		return err
+	}
+	InvalidateTenantCache()
+	return nil
+}
+
+var ErrTenantHasUsers = errors.New("tenant still has users")
+
+// CountTenantUsers counts users of the tenant, including soft deleted ones.
+func CountTenantUsers(tenantId int) int64 {
+	var count int64
+	DB.Unscoped().Model(&User{}).Where("tenant_id = ?", tenantId).Count(&count)
+	return count
+}
+
+// IsTenantSlugTaken reports whether the slug is used by another tenant.
+func IsTenantSlugTaken(slug string, excludeId int) bool {
+	return isTenantFieldTaken("slug", strings.ToLower(strings.TrimSpace(slug)), excludeId)
+}
+
+// IsTenantHostnameTaken reports whether the hostname is used by another tenant.
+func IsTenantHostnameTaken(hostname string, excludeId int) bool {
+	hostname = normalizeTenantHost(hostname)
+	if hostname == "" {
+		return false
+	}
+	return isTenantFieldTaken("hostname", hostname, excludeId)
+}
+
+func isTenantFieldTaken(column string, value string, excludeId int) bool {
+	var count int64
+	query := DB.Model(&Tenant{}).Where(column+" = ?", value)
+	if excludeId > 0 {
+		query = query.Where("id != ?", excludeId)
+	}
+	if err := query.Count(&count).Error; err != nil {
+		// fail closed to avoid two tenants sharing a selector
+		return true
+	}
+	return count > 0
+}
+
+// AssignTenant places a new user into the tenant and its default group; a nil
+// tenant keeps the user on the platform.
+func (user *User) AssignTenant(tenant *Tenant) {
+	if tenant == nil {
+		return
+	}
+	user.TenantId = tenant.Id
+	user.Group = tenant.DefaultGroup
+}
+
+// CanAccessTenant reports whether a user of userTenantId may sign in to the site
+// resolved to tenantId. Root users manage the whole deployment.
+func CanAccessTenant(userTenantId int, role int, tenantId int) bool {
+	return role == common.RoleRootUser || userTenantId == tenantId
+}
+
+// TenantScope limits a user query to the tenant; platform scope (0) sees every tenant.
+func TenantScope(tenantId int) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		if tenantId <= 0 {
+			return db
+		}
+		return db.Where("tenant_id = ?", tenantId)
+	}
+}
+
+// tenant lookups run on every request, so they are served from an in-memory snapshot
+// that is reloaded on writes and at most tenantCacheTTL old on other nodes.
+var (
+	tenantCacheLock     sync.RWMutex
+	tenantCacheLoadedAt time.Time
+	tenantCacheById     map[int]*Tenant
+	tenantCacheByHost   map[string]*Tenant
+	tenantCacheBySlug   map[string]*Tenant
+)
+
+func InvalidateTenantCache() {
+	tenantCacheLock.Lock()
+	tenantCacheLoadedAt = time.Time{}
+	tenantCacheLock.Unlock()
+}
+
+func ensureTenantCache() {
+	tenantCacheLock.RLock()
+	fresh := !tenantCacheLoadedAt.IsZero() && time.Since(tenantCacheLoadedAt) < tenantCacheTTL
+	tenantCacheLock.RUnlock()
+	if fresh || DB == nil {
+		return
+	}
+
+	tenantCacheLock.Lock()
+	defer tenantCacheLock.Unlock()
+	if !tenantCacheLoadedAt.IsZero() && time.Since(tenantCacheLoadedAt) < tenantCacheTTL {
+		return
+	}
+	tenants, err := GetAllTenants()
+	if err != nil {
+		common.SysError("failed to load tenants: " + err.Error())
+		// keep serving the previous snapshot and retry on the next TTL
+		tenantCacheLoadedAt = time.Now()
+		return
+	}
+	byId := make(map[int]*Tenant, len(tenants))
+	byHost := make(map[string]*Tenant, len(tenants))
+	bySlug := make(map[string]*Tenant, len(tenants))
+	for _, tenant := range tenants {
+		byId[tenant.Id] = tenant
+		bySlug[tenant.Slug] = tenant
+		if tenant.Hostname != "" {
+			byHost[tenant.Hostname] = tenant
+		}
+	}
+	tenantCacheById, tenantCacheByHost, tenantCacheBySlug = byId, byHost, bySlug
+	tenantCacheLoadedAt = time.Now()
+}
+
+// GetCachedTenant returns the tenant by id, nil if it does not exist.
+func GetCachedTenant(id int) *Tenant {
+	if id <= 0 {
+		return nil
+	}
+	ensureTenantCache()
+	tenantCacheLock.RLock()
+	defer tenantCacheLock.RUnlock()
+	return tenantCacheById[id]
+}
+
+// GetTenantByHost returns the tenant bound to the request host, nil if none.
+func GetTenantByHost(host string) *Tenant {
+	host = normalizeTenantHost(host)
+	if host == "" {
+		return nil
+	}
+	ensureTenantCache()
+	tenantCacheLock.RLock()
+	defer tenantCacheLock.RUnlock()
+	return tenantCacheByHost[host]
+}
+
+// GetTenantBySlug returns the tenant selected by the /t/{slug} path prefix, nil if none.
+func GetTenantBySlug(slug string) *Tenant {
+	if slug == "" {
+		return nil
+	}
+	ensureTenantCache()
+	tenantCacheLock.RLock()
+	defer tenantCacheLock.RUnlock()
+	return tenantCacheBySlug[strings.ToLower(slug)]
+}
+
+// GetContextTenant returns the tenant the request was resolved to, nil for the platform.
+func GetContextTenant(c *gin.Context) *Tenant {
+	value, ok := common.GetContextKey(c, constant.ContextKeyTenant)
+	if !ok {
+		return nil
+	}
+	tenant, _ := value.(*Tenant)
+	return tenant
+}
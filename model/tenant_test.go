@@ -0,0 +1,68 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTenantLookup(t *testing.T) {
+	t.Cleanup(func() {
+		DB.Exec("DELETE FROM tenants")
+		InvalidateTenantCache()
+	})
+
+	tenant := &Tenant{Name: "Acme", Slug: " Acme ", Hostname: "API.Acme.com:443", DefaultGroup: "acme", Groups: "acme-vip, acme"}
+	require.NoError(t, CreateTenant(tenant))
+	assert.Equal(t, "acme", tenant.Slug)
+	assert.Equal(t, "api.acme.com", tenant.Hostname)
+	assert.Equal(t, []string{"acme", "acme-vip"}, tenant.GetGroups())
+	assert.True(t, tenant.OwnsGroup("acme-vip"))
+	assert.False(t, tenant.OwnsGroup("default"))
+
+	require.NotNil(t, GetTenantByHost("api.acme.com:8080"))
+	require.NotNil(t, GetTenantBySlug("acme"))
+	assert.Nil(t, GetTenantByHost("api.example.com"))
+	assert.True(t, IsTenantSlugTaken("acme", 0))
+	assert.False(t, IsTenantSlugTaken("acme", tenant.Id))
+	assert.True(t, IsTenantHostnameTaken("api.acme.com", 0))
+
+	tenant.Hostname = "console.acme.com"
+	require.NoError(t, UpdateTenant(tenant))
+	assert.Nil(t, GetTenantByHost("api.acme.com"))
+	assert.Equal(t, tenant.Id, GetTenantByHost("console.acme.com").Id)
+
+	assert.Error(t, CreateTenant(&Tenant{Name: "Bad", Slug: "bad/slug", DefaultGroup: "x"}))
+	assert.Error(t, CreateTenant(&Tenant{Name: "Bad", Slug: "bad-branding", DefaultGroup: "x", Branding: JSONValue(`["x"]`)}))
+	assert.Error(t, CreateTenant(&Tenant{Name: "Bad", Slug: "bad-branding", DefaultGroup: "x", Branding: JSONValue(`"x"`)}))
+	require.NoError(t, CreateTenant(&Tenant{Name: "Branded", Slug: "branded", DefaultGroup: "x", Branding: JSONValue(`{"system_name":"Branded"}`)}))
+}
+
+func TestUserTenantScope(t *testing.T) {
+	truncateTables(t)
+
+	require.NoError(t, DB.Create(&User{Username: "platform_bob", Password: "x", AffCode: "a1"}).Error)
+	require.NoError(t, DB.Create(&User{Username: "tenant_bob", Password: "x", AffCode: "a2", TenantId: 7}).Error)
+	require.NoError(t, DB.Create(&User{Username: "tenant_amy", Password: "x", AffCode: "a3", TenantId: 7}).Error)
+
+	users, total, err := SearchUsers("bob", "", 7, 0, 10)
+	require.NoError(t, err)
+	assert.EqualValues(t, 1, total)
+	require.Len(t, users, 1)
+	assert.Equal(t, "tenant_bob", users[0].Username)
+
+	_, total, err = SearchUsers("bob", "", 0, 0, 10)
+	require.NoError(t, err)
+	assert.EqualValues(t, 2, total)
+
+	pageInfo := &common.PageInfo{Page: 1, PageSize: 10}
+	_, total, err = GetAllUsers(pageInfo, 7)
+	require.NoError(t, err)
+	assert.EqualValues(t, 2, total)
+
+	assert.True(t, CanAccessTenant(0, common.RoleRootUser, 7))
+	assert.False(t, CanAccessTenant(0, common.RoleAdminUser, 7))
+	assert.True(t, CanAccessTenant(7, common.RoleCommonUser, 7))
+}
@@ -25,9 +25,31 @@ type Token struct {
 	ModelLimitsEnabled bool           `json:"model_limits_enabled"`
 	ModelLimits        string         `json:"model_limits" gorm:"type:text"`
 	AllowIps           *string        `json:"allow_ips" gorm:"default:''"`
-	UsedQuota          int            `json:"used_quota" gorm:"default:0"` // used quota
+	AllowCountries     string         `json:"allow_countries" gorm:"type:varchar(1024);default:''"` // 允许访问的国家代码，逗号分隔
+	DenyCountries      string         `json:"deny_countries" gorm:"type:varchar(1024);default:''"`  // 禁止访问的国家代码，逗号分隔
+	UsedQuota          int            `json:"used_quota" gorm:"default:0"`                          // used quota
 	Group              string         `json:"group" gorm:"default:''"`
-	CrossGroupRetry    bool           `json:"cross_group_retry"` // 跨分组重试，仅auto分组有效
+	CrossGroupRetry    bool           `json:"cross_group_retry"`                                        // 跨分组重试，仅auto分组有效
+	CompatMode         string         `json:"compat_mode" gorm:"type:varchar(16);default:''"`           // 协议转换兼容模式，为空时使用渠道设置
+	ContextOverflow    string         `json:"context_overflow" gorm:"type:varchar(16);default:''"`      // 超出模型上下文窗口时的处理策略，为空时使用全局设置
+	DedupWindow        int            `json:"dedup_window" gorm:"default:0"`                            // 相同请求去重窗口（秒），0 表示关闭
+	DedupExemptStream  bool           `json:"dedup_exempt_stream"`                                      // 流式请求不参与去重
+	BatchMode          bool           `json:"batch_mode"`                                               // 延迟不敏感，非流式请求转入上游 Batch API
+	ChannelLabels      string         `json:"channel_labels" gorm:"type:varchar(1024);default:''"`      // 渠道标签约束，只路由到满足约束的渠道，如 region:eu,compliance:gdpr
+	ApiVersion         string         `json:"api_version" gorm:"type:varchar(16);default:''"`           // 固定下游兼容格式版本，为空时使用默认版本，可被请求头覆盖
+	ClientProfile      string         `json:"client_profile" gorm:"type:varchar(32);default:''"`        // 客户端兼容配置，如 claude-code、codex、cursor，为空时不使用
+	UsageWebhookUrl    string         `json:"usage_webhook_url" gorm:"type:varchar(512);default:''"`    // 每次请求完成后推送用量的地址
+	UsageWebhookSecret string         `json:"usage_webhook_secret" gorm:"type:varchar(128);default:''"` // 用量推送签名密钥
+	QuotaMode          string         `json:"quota_mode" gorm:"type:varchar(16);default:''"`            // 额度模式，为空时按额度计费，requests 按请求次数限制
+	RequestQuota       int            `json:"request_quota" gorm:"default:0"`                           // 按次模式每个周期允许的请求数
+	RequestQuotaPeriod string         `json:"request_quota_period" gorm:"type:varchar(16);default:''"`  // 按次模式的重置周期：day、month，为空时不重置
+	RequestUsed        int            `json:"request_used" gorm:"default:0"`                            // 当前周期已使用的请求数
+	RequestPeriodStart int64          `json:"request_period_start" gorm:"bigint;default:0"`             // 当前周期的开始时间
+	QuotaResetPeriod   string         `json:"quota_reset_period" gorm:"type:varchar(16);default:''"`    // 已用额度的重置周期：week、month，为空时不重置
+	QuotaPeriodAnchor  int64          `json:"quota_period_anchor" gorm:"bigint;default:0"`              // 额度周期的锚定时间
+	QuotaPeriodStart   int64          `json:"quota_period_start" gorm:"bigint;default:0"`               // 当前额度周期的开始时间
+	NextQuotaResetTime int64          `json:"next_quota_reset_time" gorm:"bigint;default:0;index"`      // 下次重置已用额度的时间，0 表示不重置
+	ParentId           int            `json:"parent_id" gorm:"default:0;index"`                         // 临时令牌的父令牌 ID，0 表示普通令牌
 	DeletedAt          gorm.DeletedAt `gorm:"index"`
 }
 
@@ -78,6 +100,11 @@ func (token *Token) GetIpLimits() []string {
 	return ipLimits
 }
 
+// GetCountryLimits 返回令牌的国家允许列表和禁止列表
+func (token *Token) GetCountryLimits() (allow []string, deny []string) {
+	return common.ParseCountryCodes(token.AllowCountries), common.ParseCountryCodes(token.DenyCountries)
+}
+
 func GetAllUserTokens(userId int, startIdx int, num int) ([]*Token, error) {
 	var tokens []*Token
 	var err error
@@ -211,7 +238,7 @@ func ValidateUserToken(key string) (token *Token, err error) {
 			}
 			return token, errors.New("该令牌已过期")
 		}
-		if !token.UnlimitedQuota && token.RemainQuota <= 0 {
+		if !token.UnlimitedQuota && !token.IsRequestQuotaMode() && token.RemainQuota <= 0 {
 			if !common.RedisEnabled {
 				// in this case, we can make sure the token is exhausted
 				token.Status = common.TokenStatusExhausted
@@ -224,6 +251,11 @@ func ValidateUserToken(key string) (token *Token, err error) {
 			keySuffix := key[len(key)-3:]
 			return token, fmt.Errorf("[sk-%s***%s] 该令牌额度已用尽 !token.UnlimitedQuota && token.RemainQuota = %d", keyPrefix, keySuffix, token.RemainQuota)
 		}
+		if token.IsTemporary() {
+			if err := validateTemporaryTokenParent(token); err != nil {
+				return token, err
+			}
+		}
 		return token, nil
 	}
 	common.SysLog("ValidateUserToken: failed to get token: " + err.Error())
@@ -304,7 +336,9 @@ func (token *Token) Update() (err error) {
 		}
 	}()
 	err = DB.Model(token).Select("name", "status", "expired_time", "remain_quota", "unlimited_quota",
-		"model_limits_enabled", "model_limits", "allow_ips", "group", "cross_group_retry").Updates(token).Error
+		"model_limits_enabled", "model_limits", "allow_ips", "allow_countries", "deny_countries", "group", "cross_group_retry", "compat_mode", "context_overflow", "dedup_window", "dedup_exempt_stream", "batch_mode", "channel_labels", "api_version", "client_profile", "usage_webhook_url", "usage_webhook_secret",
+		"quota_mode", "request_quota", "request_quota_period",
+		"quota_reset_period", "quota_period_anchor", "quota_period_start", "next_quota_reset_time").Updates(token).Error
 	return err
 }
 
@@ -378,6 +412,9 @@ func DeleteTokenById(id int, userId int) (err error) {
 	if err != nil {
 		return err
 	}
+	if token.IsTemporary() {
+		return deleteTemporaryToken(&token)
+	}
 	return token.Delete()
 }
 
@@ -448,6 +485,13 @@ func CountUserTokens(userId int) (int64, error) {
 	return total, err
 }
 
+// CountTokensWithCountryLimits 统计设置了国家允许或禁止列表的令牌数量，用于启动时检查 GeoIP 数据库是否配置
+func CountTokensWithCountryLimits() (int64, error) {
+	var total int64
+	err := DB.Model(&Token{}).Where("allow_countries <> '' OR deny_countries <> ''").Count(&total).Error
+	return total, err
+}
+
 // BatchDeleteTokens 删除指定用户的一组令牌，返回成功删除数量
 func BatchDeleteTokens(ids []int, userId int) (int, error) {
 	if len(ids) == 0 {
@@ -467,6 +511,12 @@ func BatchDeleteTokens(ids []int, userId int) (int, error) {
 		return 0, err
 	}
 
+	parentIds, err := refundTemporaryTokens(tx, tokens)
+	if err != nil {
+		tx.Rollback()
+		return 0, err
+	}
+
 	if err := tx.Commit().Error; err != nil {
 		return 0, err
 	}
@@ -478,6 +528,7 @@ func BatchDeleteTokens(ids []int, userId int) (int, error) {
 			}
 		})
 	}
+	invalidateTokenCacheByIds(parentIds)
 
 	return len(tokens), nil
 }
@@ -0,0 +1,172 @@
+package model
+
+import (
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// 令牌额度周期：设置 QuotaResetPeriod 后，令牌的已用额度按周期（week、month）自动重置，
+// 周期边界由锚定时间 QuotaPeriodAnchor 决定：每周为锚定时间起每隔 7 天，每月为每月与锚定时间同一日的同一时刻
+// （当月没有该日时取当月最后一天）。重置时已用额度退回剩余额度，即每个周期开始时恢复上一周期开始时的剩余额度，
+// 过去周期的用量记录在 TokenQuotaPeriodRecord 中
+const (
+	TokenQuotaResetWeek  = "week"
+	TokenQuotaResetMonth = "month"
+)
+
+const tokenQuotaWeekSeconds = 7 * 24 * 3600
+
+// TokenQuotaPeriodRecord 令牌过去一个额度周期的用量
+type TokenQuotaPeriodRecord struct {
+	Id          int   `json:"id"`
+	TokenId     int   `json:"token_id" gorm:"index"`
+	UserId      int   `json:"user_id" gorm:"index"`
+	PeriodStart int64 `json:"period_start" gorm:"type:bigint"`
+	PeriodEnd   int64 `json:"period_end" gorm:"type:bigint"`
+	UsedQuota   int   `json:"used_quota"`
+	CreatedAt   int64 `json:"created_at" gorm:"type:bigint"`
+}
+
+// IsValidTokenQuotaResetPeriod 检查额度重置周期是否有效，为空表示不重置
+func IsValidTokenQuotaResetPeriod(period string) bool {
+	switch period {
+	case "", TokenQuotaResetWeek, TokenQuotaResetMonth:
+		return true
+	default:
+		return false
+	}
+}
+
+// SetQuotaResetPeriod 设置令牌的额度重置周期并计算当前周期，anchor 不大于 0 时以当前时间为锚定时间
+func (token *Token) SetQuotaResetPeriod(period string, anchor int64) {
+	if period == "" {
+		token.QuotaResetPeriod = ""
+		token.QuotaPeriodAnchor = 0
+		token.QuotaPeriodStart = 0
+		token.NextQuotaResetTime = 0
+		return
+	}
+	now := common.GetTimestamp()
+	if anchor <= 0 {
+		anchor = now
+	}
+	token.QuotaResetPeriod = period
+	token.QuotaPeriodAnchor = anchor
+	token.QuotaPeriodStart, token.NextQuotaResetTime = TokenQuotaPeriodBounds(period, anchor, time.Unix(now, 0))
+}
+
+// TokenQuotaPeriodBounds 返回 now 所在周期的开始时间和结束时间（下次重置时间），月周期按服务器时区计算
+func TokenQuotaPeriodBounds(period string, anchor int64, now time.Time) (int64, int64) {
+	switch period {
+	case TokenQuotaResetWeek:
+		diff := now.Unix() - anchor
+		weeks := diff / tokenQuotaWeekSeconds
+		if diff%tokenQuotaWeekSeconds < 0 {
+			weeks--
+		}
+		start := anchor + weeks*tokenQuotaWeekSeconds
+		return start, start + tokenQuotaWeekSeconds
+	case TokenQuotaResetMonth:
+		anchorTime := time.Unix(anchor, 0).In(now.Location())
+		months := (now.Year()-anchorTime.Year())*12 + int(now.Month()) - int(anchorTime.Month())
+		start := addMonthsClamped(anchorTime, months)
+		if start.After(now) {
+			months--
+			start = addMonthsClamped(anchorTime, months)
+		}
+		return start.Unix(), addMonthsClamped(anchorTime, months+1).Unix()
+	default:
+		return 0, 0
+	}
+}
+
+// addMonthsClamped 返回 t 之后 months 个月的同一日同一时刻，目标月份没有该日时取当月最后一天
+func addMonthsClamped(t time.Time, months int) time.Time {
+	first := time.Date(t.Year(), t.Month()+time.Month(months), 1, t.Hour(), t.Minute(), t.Second(), 0, t.Location())
+	lastDay := time.Date(first.Year(), first.Month()+1, 0, 0, 0, 0, 0, t.Location()).Day()
+	return first.AddDate(0, 0, min(t.Day(), lastDay)-1)
+}
+
+// ResetDueTokenQuotas 重置已到下次重置时间的令牌：记录上一周期的用量，已用额度退回剩余额度并清零。
+// 任务停止期间错过的多个周期合并为一条记录，新周期按当前时间计算
+func ResetDueTokenQuotas(limit int) (int, error) {
+	if limit <= 0 {
+		limit = 200
+	}
+	now := common.GetTimestamp()
+	var tokens []Token
+	if err := DB.Where("next_quota_reset_time > 0 AND next_quota_reset_time <= ?", now).
+		Order("next_quota_reset_time asc").
+		Limit(limit).
+		Find(&tokens).Error; err != nil {
+		return 0, err
+	}
+	resetCount := 0
+	for _, token := range tokens {
+		tokenId := token.Id
+		var resetKey string
+		err := DB.Transaction(func(tx *gorm.DB) error {
+			var locked Token
+			if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+				Where("id = ? AND next_quota_reset_time > 0 AND next_quota_reset_time <= ?", tokenId, now).
+				First(&locked).Error; err != nil {
+				return nil
+			}
+			record := TokenQuotaPeriodRecord{
+				TokenId:     locked.Id,
+				UserId:      locked.UserId,
+				PeriodStart: locked.QuotaPeriodStart,
+				PeriodEnd:   locked.NextQuotaResetTime,
+				UsedQuota:   locked.UsedQuota,
+				CreatedAt:   now,
+			}
+			if err := tx.Create(&record).Error; err != nil {
+				return err
+			}
+			start, next := TokenQuotaPeriodBounds(locked.QuotaResetPeriod, locked.QuotaPeriodAnchor, time.Unix(now, 0))
+			// 按写入时的行计算，读取之后发生的消耗不会被覆盖；gorm 按字段名排序生成 SET，MySQL 中 remain_quota 先于 used_quota 赋值
+			updates := map[string]interface{}{
+				"remain_quota":          gorm.Expr("remain_quota + used_quota"),
+				"used_quota":            0,
+				"quota_period_start":    start,
+				"next_quota_reset_time": next,
+			}
+			if locked.Status == common.TokenStatusExhausted {
+				updates["status"] = common.TokenStatusEnabled
+			}
+			if err := tx.Model(&Token{}).Where("id = ?", locked.Id).Updates(updates).Error; err != nil {
+				return err
+			}
+			resetKey = locked.Key
+			return nil
+		})
+		if err != nil {
+			return resetCount, err
+		}
+		if resetKey == "" {
+			continue
+		}
+		resetCount++
+		if common.RedisEnabled {
+			if err := cacheDeleteToken(resetKey); err != nil {
+				common.SysLog("failed to delete token cache: " + err.Error())
+			}
+		}
+	}
+	return resetCount, nil
+}
+
+// GetTokenQuotaPeriodRecords 按时间倒序返回令牌过去周期的用量记录
+func GetTokenQuotaPeriodRecords(tokenId int, userId int, startIdx int, num int) ([]*TokenQuotaPeriodRecord, int64, error) {
+	var records []*TokenQuotaPeriodRecord
+	var total int64
+	query := DB.Model(&TokenQuotaPeriodRecord{}).Where("token_id = ? AND user_id = ?", tokenId, userId)
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	err := query.Order("id desc").Offset(startIdx).Limit(num).Find(&records).Error
+	return records, total, err
+}
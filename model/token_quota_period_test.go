@@ -0,0 +1,71 @@
+package model
+
+import (
+	"testing"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTokenQuotaPeriodBounds(t *testing.T) {
+	anchor := time.Date(2026, 1, 31, 8, 0, 0, 0, time.Local)
+
+	// 没有 31 日的月份取当月最后一天
+	start, end := TokenQuotaPeriodBounds(TokenQuotaResetMonth, anchor.Unix(), time.Date(2026, 3, 10, 0, 0, 0, 0, time.Local))
+	require.Equal(t, time.Date(2026, 2, 28, 8, 0, 0, 0, time.Local).Unix(), start)
+	require.Equal(t, time.Date(2026, 3, 31, 8, 0, 0, 0, time.Local).Unix(), end)
+
+	start, end = TokenQuotaPeriodBounds(TokenQuotaResetMonth, anchor.Unix(), time.Date(2026, 3, 31, 8, 0, 0, 0, time.Local))
+	require.Equal(t, time.Date(2026, 3, 31, 8, 0, 0, 0, time.Local).Unix(), start)
+	require.Equal(t, time.Date(2026, 4, 30, 8, 0, 0, 0, time.Local).Unix(), end)
+
+	week := int64(7 * 24 * 3600)
+	start, end = TokenQuotaPeriodBounds(TokenQuotaResetWeek, anchor.Unix(), anchor.Add(10*24*time.Hour))
+	require.Equal(t, anchor.Unix()+week, start)
+	require.Equal(t, anchor.Unix()+2*week, end)
+
+	// 锚定时间在未来时，当前周期在锚定时间之前
+	start, end = TokenQuotaPeriodBounds(TokenQuotaResetWeek, anchor.Unix(), anchor.Add(-time.Hour))
+	require.Equal(t, anchor.Unix()-week, start)
+	require.Equal(t, anchor.Unix(), end)
+}
+
+func TestResetDueTokenQuotas(t *testing.T) {
+	truncateTables(t)
+	t.Cleanup(func() {
+		DB.Exec("DELETE FROM token_quota_period_records")
+	})
+	now := common.GetTimestamp()
+	due := &Token{UserId: 1, Name: "due", Key: "quotaperiodkey00000000000000000000000000000000000", Status: common.TokenStatusExhausted, RemainQuota: 0, UsedQuota: 500}
+	due.SetQuotaResetPeriod(TokenQuotaResetWeek, now-8*24*3600)
+	require.Equal(t, now-24*3600, due.QuotaPeriodStart)
+	due.QuotaPeriodStart, due.NextQuotaResetTime = now-8*24*3600, now-24*3600
+	require.NoError(t, DB.Create(due).Error)
+	notDue := &Token{UserId: 1, Name: "not due", Key: "quotaperiodkey11111111111111111111111111111111111", Status: common.TokenStatusEnabled, RemainQuota: 100, UsedQuota: 50}
+	notDue.SetQuotaResetPeriod(TokenQuotaResetMonth, 0)
+	require.NoError(t, DB.Create(notDue).Error)
+
+	count, err := ResetDueTokenQuotas(10)
+	require.NoError(t, err)
+	require.Equal(t, 1, count)
+
+	stored, err := GetTokenById(due.Id)
+	require.NoError(t, err)
+	require.Equal(t, 0, stored.UsedQuota)
+	require.Equal(t, 500, stored.RemainQuota)
+	require.Equal(t, common.TokenStatusEnabled, stored.Status)
+	require.Equal(t, now-24*3600, stored.QuotaPeriodStart)
+	require.Equal(t, now+6*24*3600, stored.NextQuotaResetTime)
+
+	records, total, err := GetTokenQuotaPeriodRecords(due.Id, 1, 0, 10)
+	require.NoError(t, err)
+	require.Equal(t, int64(1), total)
+	require.Equal(t, 500, records[0].UsedQuota)
+	require.Equal(t, now-8*24*3600, records[0].PeriodStart)
+	require.Equal(t, now-24*3600, records[0].PeriodEnd)
+
+	stored, err = GetTokenById(notDue.Id)
+	require.NoError(t, err)
+	require.Equal(t, 50, stored.UsedQuota)
+}
@@ -0,0 +1,99 @@
+package model
+
+import (
+	"errors"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"gorm.io/gorm"
+)
+
+// 按次计费的令牌：令牌不再按额度扣减（视为无限额度，消耗仍计入用户余额或订阅），
+// 每个周期只允许 RequestQuota 次请求，适用于按月固定价格转售的套餐。
+// 计数直接在数据库中以条件更新原子地增减，多节点部署时同样准确
+const (
+	TokenQuotaModeRequests = "requests"
+
+	TokenRequestPeriodDay   = "day"
+	TokenRequestPeriodMonth = "month"
+)
+
+var ErrTokenRequestQuotaExhausted = errors.New("token request quota exhausted")
+
+// IsValidTokenRequestQuota 检查按次模式的设置是否有效
+func IsValidTokenRequestQuota(mode string, quota int, period string) bool {
+	switch mode {
+	case "":
+		return true
+	case TokenQuotaModeRequests:
+		return quota >= 0 && (period == "" || period == TokenRequestPeriodDay || period == TokenRequestPeriodMonth)
+	default:
+		return false
+	}
+}
+
+func (token *Token) IsRequestQuotaMode() bool {
+	return token.QuotaMode == TokenQuotaModeRequests
+}
+
+// TokenRequestPeriodStart 返回 now 所在周期的开始时间（服务器时区），不重置时为 0
+func TokenRequestPeriodStart(period string, now time.Time) int64 {
+	year, month, day := now.Date()
+	switch period {
+	case TokenRequestPeriodDay:
+		return time.Date(year, month, day, 0, 0, 0, 0, now.Location()).Unix()
+	case TokenRequestPeriodMonth:
+		return time.Date(year, month, 1, 0, 0, 0, 0, now.Location()).Unix()
+	default:
+		return 0
+	}
+}
+
+// ConsumeTokenRequest 消耗令牌当前周期的一次请求额度，已用尽时返回 ErrTokenRequestQuotaExhausted。
+// 返回计入的周期开始时间，请求失败时据此退还
+func ConsumeTokenRequest(tokenId int, quota int, period string) (int64, error) {
+	now := time.Now()
+	periodStart := TokenRequestPeriodStart(period, now)
+	if quota <= 0 {
+		return periodStart, ErrTokenRequestQuotaExhausted
+	}
+	if periodStart > 0 {
+		// 进入新周期时重置计数，并计入本次请求
+		result := DB.Model(&Token{}).Where("id = ? AND request_period_start < ?", tokenId, periodStart).Updates(map[string]interface{}{
+			"request_used":         1,
+			"request_period_start": periodStart,
+			"accessed_time":        now.Unix(),
+		})
+		if result.Error != nil {
+			return periodStart, result.Error
+		}
+		if result.RowsAffected > 0 {
+			return periodStart, nil
+		}
+	}
+	result := DB.Model(&Token{}).Where("id = ? AND request_period_start >= ? AND request_used < ?", tokenId, periodStart, quota).Updates(map[string]interface{}{
+		"request_used":  gorm.Expr("request_used + 1"),
+		"accessed_time": now.Unix(),
+	})
+	if result.Error != nil {
+		return periodStart, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return periodStart, ErrTokenRequestQuotaExhausted
+	}
+	return periodStart, nil
+}
+
+// RefundTokenRequest 退还 ConsumeTokenRequest 计入的一次请求，周期已经重置时不做任何事
+func RefundTokenRequest(tokenId int, periodStart int64) error {
+	return DB.Model(&Token{}).Where("id = ? AND request_period_start = ? AND request_used > 0", tokenId, periodStart).
+		Update("request_used", gorm.Expr("request_used - 1")).Error
+}
+
+// RemainTokenRequests 返回令牌当前周期剩余的请求数
+func (token *Token) RemainTokenRequests() int {
+	if token.RequestPeriodStart < TokenRequestPeriodStart(token.RequestQuotaPeriod, time.Unix(common.GetTimestamp(), 0)) {
+		return token.RequestQuota
+	}
+	return max(token.RequestQuota-token.RequestUsed, 0)
+}
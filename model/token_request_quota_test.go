@@ -0,0 +1,50 @@
+package model
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTokenRequestPeriodStart(t *testing.T) {
+	now := time.Date(2026, 3, 15, 10, 30, 0, 0, time.UTC)
+	require.Equal(t, time.Date(2026, 3, 15, 0, 0, 0, 0, time.UTC).Unix(), TokenRequestPeriodStart(TokenRequestPeriodDay, now))
+	require.Equal(t, time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC).Unix(), TokenRequestPeriodStart(TokenRequestPeriodMonth, now))
+	require.Zero(t, TokenRequestPeriodStart("", now))
+}
+
+func TestConsumeTokenRequest(t *testing.T) {
+	t.Cleanup(func() { DB.Exec("DELETE FROM tokens") })
+
+	token := &Token{UserId: 1, Key: "request-quota-token", Name: "requests", QuotaMode: TokenQuotaModeRequests, RequestQuota: 2}
+	require.NoError(t, DB.Create(token).Error)
+
+	period, err := ConsumeTokenRequest(token.Id, 2, "")
+	require.NoError(t, err)
+	_, err = ConsumeTokenRequest(token.Id, 2, "")
+	require.NoError(t, err)
+	_, err = ConsumeTokenRequest(token.Id, 2, "")
+	require.ErrorIs(t, err, ErrTokenRequestQuotaExhausted)
+
+	require.NoError(t, RefundTokenRequest(token.Id, period))
+	_, err = ConsumeTokenRequest(token.Id, 2, "")
+	require.NoError(t, err)
+
+	// 进入新周期时计数重置
+	require.NoError(t, DB.Model(token).Update("request_period_start", 1).Error)
+	period, err = ConsumeTokenRequest(token.Id, 2, TokenRequestPeriodDay)
+	require.NoError(t, err)
+	require.NoError(t, DB.First(token, token.Id).Error)
+	require.Equal(t, 1, token.RequestUsed)
+	require.Equal(t, period, token.RequestPeriodStart)
+	require.Equal(t, 1, token.RemainTokenRequests())
+
+	// 周期已经变化时不退还
+	require.NoError(t, RefundTokenRequest(token.Id, period-1))
+	require.NoError(t, DB.First(token, token.Id).Error)
+	require.Equal(t, 1, token.RequestUsed)
+
+	_, err = ConsumeTokenRequest(token.Id, 0, "")
+	require.ErrorIs(t, err, ErrTokenRequestQuotaExhausted)
+}
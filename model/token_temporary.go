@@ -0,0 +1,203 @@
+package model
+
+import (
+	"errors"
+
+	"github.com/QuantumNous/new-api/common"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// 临时令牌：由父令牌签发的短期子令牌，交给浏览器、移动端等不可信的客户端使用。
+// 临时令牌属于父令牌的用户，额度在签发时从父令牌中预留，删除或到期回收时未用完的部分退回父令牌；
+// 父令牌停用、过期或删除后临时令牌随之失效
+
+// RealtimeClientSecretPrefix Realtime 临时密钥（client secret）的前缀，与 OpenAI 的 ek_ 密钥一致，鉴权时与 sk- 一样去掉
+const RealtimeClientSecretPrefix = "ek_"
+
+// temporaryTokenReleaseGrace 临时令牌到期后延迟回收的时间（秒），留给进行中的请求完成结算
+const temporaryTokenReleaseGrace = 10 * 60
+
+var (
+	ErrTemporaryTokenQuotaExceeded     = errors.New("父令牌剩余额度不足")
+	ErrTemporaryTokenRequestModeParent = errors.New("按次计费的令牌不能签发临时令牌")
+	ErrTemporaryTokenPeriodicParent    = errors.New("设置了额度重置周期的令牌不能签发临时令牌")
+)
+
+func (token *Token) IsTemporary() bool {
+	return token.ParentId != 0
+}
+
+// reservesQuota 父令牌是否按额度限制，只有此时签发临时令牌才从父令牌中预留额度并在回收时退回
+func (token *Token) reservesQuota() bool {
+	return !token.UnlimitedQuota && !token.IsRequestQuotaMode()
+}
+
+// CreateTemporaryToken 创建 parent 的临时令牌，父令牌按额度限制时从中预留临时令牌的额度。
+// 按次计费的父令牌限制的是请求次数，临时令牌无法共享其周期计数，因此不能签发；
+// 预留的额度计入父令牌的已用额度，周期重置会将其并回剩余额度，回收时再退回会重复计入，因此设置了额度重置周期的父令牌也不能签发
+func CreateTemporaryToken(parent *Token, token *Token) error {
+	token.ParentId = parent.Id
+	token.UserId = parent.UserId
+	var parentKey string
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		var locked Token
+		if err := tx.Where("id = ?", parent.Id).First(&locked).Error; err != nil {
+			return err
+		}
+		if locked.IsRequestQuotaMode() {
+			return ErrTemporaryTokenRequestModeParent
+		}
+		if locked.QuotaResetPeriod != "" {
+			return ErrTemporaryTokenPeriodicParent
+		}
+		if locked.reservesQuota() {
+			// 条件更新，并发签发或消耗时剩余额度不会被扣成负数
+			result := tx.Model(&Token{}).
+				Where("id = ? AND unlimited_quota = ? AND quota_reset_period = ? AND remain_quota >= ?", locked.Id, false, "", token.RemainQuota).
+				Updates(map[string]interface{}{
+					"remain_quota": gorm.Expr("remain_quota - ?", token.RemainQuota),
+					"used_quota":   gorm.Expr("used_quota + ?", token.RemainQuota),
+				})
+			if result.Error != nil {
+				return result.Error
+			}
+			if result.RowsAffected == 0 {
+				return ErrTemporaryTokenQuotaExceeded
+			}
+		}
+		parentKey = locked.Key
+		return tx.Create(token).Error
+	})
+	if err != nil {
+		return err
+	}
+	invalidateTokenCache(parentKey)
+	return nil
+}
+
+// HasTemporaryTokens 判断令牌是否有未回收的临时令牌
+func HasTemporaryTokens(parentId int) (bool, error) {
+	var count int64
+	err := DB.Model(&Token{}).Where("parent_id = ?", parentId).Count(&count).Error
+	return count > 0, err
+}
+
+// validateTemporaryTokenParent 检查临时令牌的父令牌是否仍然可用，父令牌额度用尽不影响已预留的额度
+func validateTemporaryTokenParent(token *Token) error {
+	var parent Token
+	err := DB.Select("id", "status", "expired_time").Where("id = ?", token.ParentId).First(&parent).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return errors.New("临时令牌的父令牌已删除")
+	}
+	if err != nil {
+		return errors.New("无效的令牌，数据库查询出错，请联系管理员")
+	}
+	if parent.Status != common.TokenStatusEnabled && parent.Status != common.TokenStatusExhausted {
+		return errors.New("临时令牌的父令牌不可用")
+	}
+	if parent.ExpiredTime != -1 && parent.ExpiredTime < common.GetTimestamp() {
+		return errors.New("临时令牌的父令牌已过期")
+	}
+	return nil
+}
+
+// refundTemporaryTokens 将临时令牌未用完的额度退回各自按额度限制的父令牌，返回被退回额度的父令牌 ID
+func refundTemporaryTokens(tx *gorm.DB, tokens []Token) ([]int, error) {
+	var parentIds []int
+	for _, token := range tokens {
+		if token.ParentId == 0 || token.RemainQuota <= 0 {
+			continue
+		}
+		// 与签发时的预留条件一致，不限额度、按次计费或设置了额度重置周期的父令牌不退回
+		result := tx.Model(&Token{}).
+			Where("id = ? AND unlimited_quota = ? AND quota_mode <> ? AND quota_reset_period = ?", token.ParentId, false, TokenQuotaModeRequests, "").
+			Updates(map[string]interface{}{
+				"remain_quota": gorm.Expr("remain_quota + ?", token.RemainQuota),
+				"used_quota":   gorm.Expr("used_quota - ?", token.RemainQuota),
+			})
+		if result.Error != nil {
+			return nil, result.Error
+		}
+		if result.RowsAffected == 0 {
+			continue
+		}
+		if err := tx.Model(&Token{}).Where("id = ? AND status = ?", token.ParentId, common.TokenStatusExhausted).
+			Update("status", common.TokenStatusEnabled).Error; err != nil {
+			return nil, err
+		}
+		parentIds = append(parentIds, token.ParentId)
+	}
+	return parentIds, nil
+}
+
+// deleteTemporaryToken 删除临时令牌并退回未用完的额度
+func deleteTemporaryToken(token *Token) error {
+	var parentIds []int
+	err := DB.Transaction(func(tx *gorm.DB) error {
+		var locked Token
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", token.Id).First(&locked).Error; err != nil {
+			return err
+		}
+		if err := tx.Delete(&locked).Error; err != nil {
+			return err
+		}
+		var err error
+		parentIds, err = refundTemporaryTokens(tx, []Token{locked})
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	invalidateTokenCache(token.Key)
+	invalidateTokenCacheByIds(parentIds)
+	return nil
+}
+
+// ReleaseExpiredTemporaryTokens 删除到期超过回收延迟的临时令牌并退回未用完的额度，返回处理的数量
+func ReleaseExpiredTemporaryTokens(limit int) (int, error) {
+	if limit <= 0 {
+		limit = 200
+	}
+	var tokens []Token
+	if err := DB.Where("parent_id > 0 AND expired_time <= ?", common.GetTimestamp()-temporaryTokenReleaseGrace).
+		Order("expired_time asc").
+		Limit(limit).
+		Find(&tokens).Error; err != nil {
+		return 0, err
+	}
+	released := 0
+	for i := range tokens {
+		if err := deleteTemporaryToken(&tokens[i]); err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				continue
+			}
+			return released, err
+		}
+		released++
+	}
+	return released, nil
+}
+
+func invalidateTokenCache(key string) {
+	if !common.RedisEnabled || key == "" {
+		return
+	}
+	if err := cacheDeleteToken(key); err != nil {
+		common.SysLog("failed to delete token cache: " + err.Error())
+	}
+}
+
+func invalidateTokenCacheByIds(ids []int) {
+	if !common.RedisEnabled || len(ids) == 0 {
+		return
+	}
+	var tokens []Token
+	if err := DB.Where("id IN ?", ids).Find(&tokens).Error; err != nil {
+		common.SysLog("failed to delete token cache: " + err.Error())
+		return
+	}
+	for _, token := range tokens {
+		invalidateTokenCache(token.Key)
+	}
+}
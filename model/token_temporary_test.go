@@ -0,0 +1,102 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func TestTemporaryTokenReservesAndRefundsQuota(t *testing.T) {
+	truncateTables(t)
+	now := common.GetTimestamp()
+	parent := &Token{UserId: 1, Name: "parent", Key: "temporaryparent000000000000000000000000000000000", Status: common.TokenStatusEnabled, ExpiredTime: -1, RemainQuota: 1000}
+	require.NoError(t, DB.Create(parent).Error)
+
+	child := &Token{Name: "temporary", Key: "temporarychild0000000000000000000000000000000000", Status: common.TokenStatusEnabled, ExpiredTime: now + 600, RemainQuota: 300}
+	require.NoError(t, CreateTemporaryToken(parent, child))
+	require.Equal(t, parent.Id, child.ParentId)
+	require.Equal(t, 1, child.UserId)
+	stored, err := GetTokenById(parent.Id)
+	require.NoError(t, err)
+	require.Equal(t, 700, stored.RemainQuota)
+	require.Equal(t, 300, stored.UsedQuota)
+
+	tooLarge := &Token{Name: "temporary", Key: "temporarychild1111111111111111111111111111111111", Status: common.TokenStatusEnabled, ExpiredTime: now + 600, RemainQuota: 800}
+	require.ErrorIs(t, CreateTemporaryToken(parent, tooLarge), ErrTemporaryTokenQuotaExceeded)
+
+	// 父令牌停用后临时令牌不可用
+	_, err = ValidateUserToken(child.Key)
+	require.NoError(t, err)
+	require.NoError(t, DB.Model(&Token{}).Where("id = ?", parent.Id).Update("status", common.TokenStatusDisabled).Error)
+	_, err = ValidateUserToken(child.Key)
+	require.Error(t, err)
+	require.NoError(t, DB.Model(&Token{}).Where("id = ?", parent.Id).Update("status", common.TokenStatusEnabled).Error)
+
+	// 未到回收时间不处理，到期后删除并退回未用完的额度
+	require.NoError(t, decreaseTokenQuota(child.Id, 100))
+	count, err := ReleaseExpiredTemporaryTokens(10)
+	require.NoError(t, err)
+	require.Equal(t, 0, count)
+	require.NoError(t, DB.Model(&Token{}).Where("id = ?", child.Id).Update("expired_time", now-temporaryTokenReleaseGrace-1).Error)
+	count, err = ReleaseExpiredTemporaryTokens(10)
+	require.NoError(t, err)
+	require.Equal(t, 1, count)
+
+	stored, err = GetTokenById(parent.Id)
+	require.NoError(t, err)
+	require.Equal(t, 900, stored.RemainQuota)
+	require.Equal(t, 100, stored.UsedQuota)
+	_, err = GetTokenById(child.Id)
+	require.ErrorIs(t, err, gorm.ErrRecordNotFound)
+}
+
+func TestDeleteTemporaryTokenRefundsQuota(t *testing.T) {
+	truncateTables(t)
+	parent := &Token{UserId: 1, Name: "parent", Key: "temporaryparent222222222222222222222222222222222", Status: common.TokenStatusExhausted, ExpiredTime: -1, RemainQuota: 200}
+	require.NoError(t, DB.Create(parent).Error)
+	child := &Token{Name: "temporary", Key: "temporarychild2222222222222222222222222222222222", Status: common.TokenStatusEnabled, ExpiredTime: common.GetTimestamp() + 600, RemainQuota: 200}
+	require.NoError(t, CreateTemporaryToken(parent, child))
+
+	require.NoError(t, DeleteTokenById(child.Id, 1))
+	stored, err := GetTokenById(parent.Id)
+	require.NoError(t, err)
+	require.Equal(t, 200, stored.RemainQuota)
+	require.Equal(t, 0, stored.UsedQuota)
+	require.Equal(t, common.TokenStatusEnabled, stored.Status)
+}
+
+func TestTemporaryTokenRespectsParentQuotaMode(t *testing.T) {
+	truncateTables(t)
+	now := common.GetTimestamp()
+	requestMode := &Token{UserId: 1, Name: "requests", Key: "temporaryparent333333333333333333333333333333333", Status: common.TokenStatusEnabled, ExpiredTime: -1, QuotaMode: TokenQuotaModeRequests, RequestQuota: 10}
+	require.NoError(t, DB.Create(requestMode).Error)
+	child := &Token{Name: "temporary", Key: "temporarychild3333333333333333333333333333333333", Status: common.TokenStatusEnabled, ExpiredTime: now + 600, RemainQuota: 100}
+	require.ErrorIs(t, CreateTemporaryToken(requestMode, child), ErrTemporaryTokenRequestModeParent)
+
+	// 周期重置会把预留额度并回父令牌的剩余额度，设置了额度重置周期的父令牌不能签发
+	periodic := &Token{UserId: 1, Name: "periodic", Key: "temporaryparent555555555555555555555555555555555", Status: common.TokenStatusEnabled, ExpiredTime: -1, RemainQuota: 1000}
+	periodic.SetQuotaResetPeriod(TokenQuotaResetMonth, 0)
+	require.NoError(t, DB.Create(periodic).Error)
+	require.ErrorIs(t, CreateTemporaryToken(periodic, child), ErrTemporaryTokenPeriodicParent)
+	stored, err := GetTokenById(periodic.Id)
+	require.NoError(t, err)
+	require.Equal(t, 1000, stored.RemainQuota)
+	hasTemporary, err := HasTemporaryTokens(periodic.Id)
+	require.NoError(t, err)
+	require.False(t, hasTemporary)
+
+	// 不限额度的父令牌不预留，回收时也不退回
+	unlimited := &Token{UserId: 1, Name: "unlimited", Key: "temporaryparent444444444444444444444444444444444", Status: common.TokenStatusEnabled, ExpiredTime: -1, UnlimitedQuota: true}
+	require.NoError(t, DB.Create(unlimited).Error)
+	child = &Token{Name: "temporary", Key: "temporarychild4444444444444444444444444444444444", Status: common.TokenStatusEnabled, ExpiredTime: now - temporaryTokenReleaseGrace - 1, RemainQuota: 100}
+	require.NoError(t, CreateTemporaryToken(unlimited, child))
+	count, err := ReleaseExpiredTemporaryTokens(10)
+	require.NoError(t, err)
+	require.Equal(t, 1, count)
+	stored, err = GetTokenById(unlimited.Id)
+	require.NoError(t, err)
+	require.Equal(t, 0, stored.RemainQuota)
+	require.Equal(t, 0, stored.UsedQuota)
+}
@@ -90,6 +90,9 @@ func Recharge(referenceId string, customerId string) (err error) {
 		if err != nil {
 			return err
 		}
+		if err = RecordQuotaPool(tx, topUp.UserId, QuotaPoolKindPrepaid, int(quota), "topup"); err != nil {
+			return err
+		}
 
 		return nil
 	})
@@ -292,6 +295,9 @@ func ManualCompleteTopUp(tradeNo string) error {
 		if err := tx.Model(&User{}).Where("id = ?", topUp.UserId).Update("quota", gorm.Expr("quota + ?", quotaToAdd)).Error; err != nil {
 			return err
 		}
+		if err := RecordQuotaPool(tx, topUp.UserId, QuotaPoolKindPrepaid, quotaToAdd, "topup"); err != nil {
+			return err
+		}
 
 		userId = topUp.UserId
 		payMoney = topUp.Money
@@ -363,6 +369,9 @@ func RechargeCreem(referenceId string, customerEmail string, customerName string
 		if err != nil {
 			return err
 		}
+		if err = RecordQuotaPool(tx, topUp.UserId, QuotaPoolKindPrepaid, int(quota), "topup"); err != nil {
+			return err
+		}
 
 		return nil
 	})
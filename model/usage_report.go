@@ -0,0 +1,228 @@
+package model
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/QuantumNous/new-api/common"
+
+	"gorm.io/gorm"
+)
+
+const (
+	ReportScopeUser   = "user"
+	ReportScopeTenant = "tenant"
+
+	ReportFrequencyWeekly  = "weekly"
+	ReportFrequencyMonthly = "monthly"
+
+	ReportChannelEmail   = "email"
+	ReportChannelWebhook = "webhook"
+)
+
+const (
+	ReportSubscriptionStatusEnabled  = 1
+	ReportSubscriptionStatusDisabled = 2
+)
+
+// ReportSubscription subscribes one recipient to the periodic usage report of a user
+// or a tenant. A tenant scope with TargetId 0 covers the whole platform (root only).
+type ReportSubscription struct {
+	Id          int    `json:"id"`
+	UserId      int    `json:"user_id" gorm:"index"`
+	Scope       string `json:"scope" gorm:"size:16;not null"`
+	TargetId    int    `json:"target_id" gorm:"default:0"`
+	Frequency   string `json:"frequency" gorm:"size:16;not null"`
+	Channel     string `json:"channel" gorm:"size:16;not null"`
+	Recipient   string `json:"recipient" gorm:"size:512;not null"`
+	Secret      string `json:"secret" gorm:"size:128;default:''"`
+	Status      int    `json:"status" gorm:"default:1"`
+	LastPeriod  string `json:"last_period" gorm:"size:16;default:''"`
+	LastError   string `json:"last_error" gorm:"type:text"`
+	CreatedTime int64  `json:"created_time" gorm:"bigint"`
+}
+
+func validateReportSubscription(sub *ReportSubscription) error {
+	sub.Recipient = strings.TrimSpace(sub.Recipient)
+	if sub.Scope != ReportScopeUser && sub.Scope != ReportScopeTenant {
+		return errors.New("invalid report scope")
+	}
+	if sub.Frequency != ReportFrequencyWeekly && sub.Frequency != ReportFrequencyMonthly {
+		return errors.New("invalid report frequency")
+	}
+	switch sub.Channel {
+	case ReportChannelEmail:
+		if err := common.Validate.Var(sub.Recipient, "required,email"); err != nil {
+			return errors.New("invalid report recipient email")
+		}
+	case ReportChannelWebhook:
+		if !strings.HasPrefix(sub.Recipient, "https://") && !strings.HasPrefix(sub.Recipient, "http://") {
+			return errors.New("invalid report webhook url")
+		}
+	default:
+		return errors.New("invalid report channel")
+	}
+	if sub.Status != ReportSubscriptionStatusDisabled {
+		sub.Status = ReportSubscriptionStatusEnabled
+	}
+	return nil
+}
+
+func GetReportSubscriptionsByUserId(userId int) ([]*ReportSubscription, error) {
+	var subs []*ReportSubscription
+	err := DB.Where("user_id = ?", userId).Order("id desc").Find(&subs).Error
+	return subs, err
+}
+
+func GetEnabledReportSubscriptions() ([]*ReportSubscription, error) {
+	var subs []*ReportSubscription
+	err := DB.Where("status = ?", ReportSubscriptionStatusEnabled).Order("id").Find(&subs).Error
+	return subs, err
+}
+
+func GetReportSubscriptionById(id int, userId int) (*ReportSubscription, error) {
+	var sub ReportSubscription
+	err := DB.Where("id = ? and user_id = ?", id, userId).First(&sub).Error
+	return &sub, err
+}
+
+func CreateReportSubscription(sub *ReportSubscription) error {
+	if err := validateReportSubscription(sub); err != nil {
+		return err
+	}
+	sub.LastPeriod = ""
+	sub.LastError = ""
+	sub.CreatedTime = common.GetTimestamp()
+	return DB.Create(sub).Error
+}
+
+func UpdateReportSubscription(sub *ReportSubscription) error {
+	if err := validateReportSubscription(sub); err != nil {
+		return err
+	}
+	return DB.Model(sub).Select("scope", "target_id", "frequency", "channel", "recipient", "secret", "status").Updates(sub).Error
+}
+
+func DeleteReportSubscription(id int, userId int) error {
+	result := DB.Where("id = ? and user_id = ?", id, userId).Delete(&ReportSubscription{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// MarkReportSubscriptionSent records the last delivered period and the delivery error, if any.
+func MarkReportSubscriptionSent(id int, period string, sendErr error) error {
+	lastError := ""
+	if sendErr != nil {
+		lastError = sendErr.Error()
+	}
+	return DB.Model(&ReportSubscription{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"last_period": period,
+		"last_error":  lastError,
+	}).Error
+}
+
+// ReportModelUsage is the consumption of one model in a report period.
+type ReportModelUsage struct {
+	ModelName string `json:"model_name"`
+	Quota     int64  `json:"quota"`
+	Requests  int64  `json:"requests"`
+	Tokens    int64  `json:"tokens"`
+}
+
+// ReportTokenUsage is the consumption of one API token in a report period.
+type ReportTokenUsage struct {
+	TokenName string `json:"token_name"`
+	Quota     int64  `json:"quota"`
+	Requests  int64  `json:"requests"`
+}
+
+// GetReportUserIds returns the users covered by a report scope; nil means every user.
+func GetReportUserIds(scope string, targetId int) ([]int, error) {
+	if scope == ReportScopeUser {
+		return []int{targetId}, nil
+	}
+	if targetId == 0 {
+		return nil, nil
+	}
+	var ids []int
+	err := DB.Model(&User{}).Where("tenant_id = ?", targetId).Pluck("id", &ids).Error
+	if err == nil && ids == nil {
+		ids = []int{}
+	}
+	return ids, err
+}
+
+func reportLogScope(userIds []int, startTimestamp int64, endTimestamp int64) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		db = db.Where("created_at >= ? and created_at < ?", startTimestamp, endTimestamp)
+		if userIds != nil {
+			db = db.Where("user_id in ?", userIds)
+		}
+		return db
+	}
+}
+
+// GetReportModelUsage sums consume logs per model, most expensive first.
+func GetReportModelUsage(userIds []int, startTimestamp int64, endTimestamp int64) ([]ReportModelUsage, error) {
+	var usage []ReportModelUsage
+	if userIds != nil && len(userIds) == 0 {
+		return usage, nil
+	}
+	err := LogReadDB().Table("logs").
+		Select("model_name, sum(quota) as quota, count(*) as requests, sum(prompt_tokens) + sum(completion_tokens) as tokens").
+		Scopes(reportLogScope(userIds, startTimestamp, endTimestamp)).
+		Where("type = ?", LogTypeConsume).
+		Group("model_name").
+		Order("quota desc").
+		Scan(&usage).Error
+	return usage, err
+}
+
+// GetReportTopTokens sums consume logs per token name, most expensive first.
+func GetReportTopTokens(userIds []int, startTimestamp int64, endTimestamp int64, limit int) ([]ReportTokenUsage, error) {
+	var usage []ReportTokenUsage
+	if userIds != nil && len(userIds) == 0 {
+		return usage, nil
+	}
+	err := LogReadDB().Table("logs").
+		Select("token_name, sum(quota) as quota, count(*) as requests").
+		Scopes(reportLogScope(userIds, startTimestamp, endTimestamp)).
+		Where("type = ?", LogTypeConsume).
+		Group("token_name").
+		Order("quota desc").
+		Limit(limit).
+		Scan(&usage).Error
+	return usage, err
+}
+
+// GetReportErrorCount counts error logs in the report period.
+func GetReportErrorCount(userIds []int, startTimestamp int64, endTimestamp int64) (int64, error) {
+	var count int64
+	if userIds != nil && len(userIds) == 0 {
+		return 0, nil
+	}
+	err := LogReadDB().Table("logs").
+		Scopes(reportLogScope(userIds, startTimestamp, endTimestamp)).
+		Where("type = ?", LogTypeError).
+		Count(&count).Error
+	return count, err
+}
+
+// GetReportRemainQuota sums the remaining quota of the users in the report scope.
+func GetReportRemainQuota(userIds []int) (int64, error) {
+	var remain int64
+	if userIds != nil && len(userIds) == 0 {
+		return 0, nil
+	}
+	query := DB.Model(&User{}).Select("coalesce(sum(quota), 0)")
+	if userIds != nil {
+		query = query.Where("id in ?", userIds)
+	}
+	err := query.Scan(&remain).Error
+	return remain, err
+}
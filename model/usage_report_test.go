@@ -0,0 +1,81 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReportUsageAggregation(t *testing.T) {
+	truncateTables(t)
+	t.Cleanup(func() {
+		DB.Exec("DELETE FROM report_subscriptions")
+	})
+
+	require.NoError(t, DB.Create(&User{Id: 1, Username: "alice", AffCode: "rpt1", Quota: 500, TenantId: 7}).Error)
+	require.NoError(t, DB.Create(&User{Id: 2, Username: "bob", AffCode: "rpt2", Quota: 300}).Error)
+	logs := []*Log{
+		{UserId: 1, Type: LogTypeConsume, ModelName: "gpt-4o", TokenName: "prod", Quota: 100, PromptTokens: 10, CompletionTokens: 5, CreatedAt: 1000},
+		{UserId: 1, Type: LogTypeConsume, ModelName: "gpt-4o", TokenName: "dev", Quota: 50, PromptTokens: 4, CompletionTokens: 1, CreatedAt: 1500},
+		{UserId: 1, Type: LogTypeConsume, ModelName: "claude", TokenName: "prod", Quota: 20, CreatedAt: 1600},
+		{UserId: 1, Type: LogTypeError, ModelName: "claude", CreatedAt: 1700},
+		{UserId: 1, Type: LogTypeConsume, ModelName: "gpt-4o", Quota: 999, CreatedAt: 5000},
+		{UserId: 2, Type: LogTypeConsume, ModelName: "gpt-4o", Quota: 70, CreatedAt: 1200},
+	}
+	require.NoError(t, LOG_DB.Create(&logs).Error)
+
+	userIds, err := GetReportUserIds(ReportScopeTenant, 7)
+	require.NoError(t, err)
+	assert.Equal(t, []int{1}, userIds)
+
+	models, err := GetReportModelUsage(userIds, 1000, 2000)
+	require.NoError(t, err)
+	require.Len(t, models, 2)
+	assert.Equal(t, ReportModelUsage{ModelName: "gpt-4o", Quota: 150, Requests: 2, Tokens: 20}, models[0])
+
+	tokens, err := GetReportTopTokens(userIds, 1000, 2000, 1)
+	require.NoError(t, err)
+	require.Len(t, tokens, 1)
+	assert.Equal(t, "prod", tokens[0].TokenName)
+	assert.EqualValues(t, 120, tokens[0].Quota)
+
+	errCount, err := GetReportErrorCount(userIds, 1000, 2000)
+	require.NoError(t, err)
+	assert.EqualValues(t, 1, errCount)
+
+	remain, err := GetReportRemainQuota(nil)
+	require.NoError(t, err)
+	assert.EqualValues(t, 800, remain)
+
+	empty, err := GetReportUserIds(ReportScopeTenant, 99)
+	require.NoError(t, err)
+	models, err = GetReportModelUsage(empty, 1000, 2000)
+	require.NoError(t, err)
+	assert.Empty(t, models)
+}
+
+func TestReportSubscriptionValidation(t *testing.T) {
+	t.Cleanup(func() {
+		DB.Exec("DELETE FROM report_subscriptions")
+	})
+
+	sub := &ReportSubscription{UserId: 1, Scope: ReportScopeUser, TargetId: 1, Frequency: ReportFrequencyWeekly, Channel: ReportChannelEmail, Recipient: " a@example.com "}
+	require.NoError(t, CreateReportSubscription(sub))
+	assert.Equal(t, "a@example.com", sub.Recipient)
+	assert.Equal(t, ReportSubscriptionStatusEnabled, sub.Status)
+
+	assert.Error(t, CreateReportSubscription(&ReportSubscription{Scope: ReportScopeUser, Frequency: "daily", Channel: ReportChannelEmail, Recipient: "a@example.com"}))
+	assert.Error(t, CreateReportSubscription(&ReportSubscription{Scope: ReportScopeUser, Frequency: ReportFrequencyMonthly, Channel: ReportChannelWebhook, Recipient: "ftp://x"}))
+
+	require.NoError(t, MarkReportSubscriptionSent(sub.Id, "2026-W40", nil))
+	subs, err := GetEnabledReportSubscriptions()
+	require.NoError(t, err)
+	require.Len(t, subs, 1)
+	assert.Equal(t, "2026-W40", subs[0].LastPeriod)
+
+	_, err = GetReportSubscriptionById(sub.Id, 2)
+	assert.Error(t, err)
+	require.NoError(t, DeleteReportSubscription(sub.Id, 1))
+	assert.Error(t, DeleteReportSubscription(sub.Id, 1))
+}
@@ -50,6 +50,8 @@ type User struct {
 	Setting          string         `json:"setting" gorm:"type:text;column:setting"`
 	Remark           string         `json:"remark,omitempty" gorm:"type:varchar(255)" validate:"max=255"`
 	StripeCustomer   string         `json:"stripe_customer" gorm:"type:varchar(64);column:stripe_customer;index"`
+	TenantId         int            `json:"tenant_id" gorm:"type:int;default:0;index"` // 0 = platform user
+	TrustScore       int            `json:"trust_score" gorm:"type:int;default:100"`   // 信任分，由人工审核结论调整
 }
 
 func (user *User) ToBaseUser() *UserBase {
@@ -61,6 +63,7 @@ func (user *User) ToBaseUser() *UserBase {
 		Username: user.Username,
 		Setting:  user.Setting,
 		Email:    user.Email,
+		TenantId: user.TenantId,
 	}
 	return cache
 }
@@ -188,7 +191,8 @@ func GetMaxUserId() int {
 	return user.Id
 }
 
-func GetAllUsers(pageInfo *common.PageInfo) (users []*User, total int64, err error) {
+// GetAllUsers lists users of the tenant; tenantId 0 lists every user.
+func GetAllUsers(pageInfo *common.PageInfo, tenantId int) (users []*User, total int64, err error) {
 	// Start transaction
 	tx := DB.Begin()
 	if tx.Error != nil {
@@ -201,14 +205,14 @@ func GetAllUsers(pageInfo *common.PageInfo) (users []*User, total int64, err err
 	}()
 
 	// Get total count within transaction
-	err = tx.Unscoped().Model(&User{}).Count(&total).Error
+	err = tx.Unscoped().Model(&User{}).Scopes(TenantScope(tenantId)).Count(&total).Error
 	if err != nil {
 		tx.Rollback()
 		return nil, 0, err
 	}
 
 	// Get paginated users within same transaction
-	err = tx.Unscoped().Order("id desc").Limit(pageInfo.GetPageSize()).Offset(pageInfo.GetStartIdx()).Omit("password").Find(&users).Error
+	err = tx.Unscoped().Scopes(TenantScope(tenantId)).Order("id desc").Limit(pageInfo.GetPageSize()).Offset(pageInfo.GetStartIdx()).Omit("password").Find(&users).Error
 	if err != nil {
 		tx.Rollback()
 		return nil, 0, err
@@ -222,7 +226,7 @@ func GetAllUsers(pageInfo *common.PageInfo) (users []*User, total int64, err err
 	return users, total, nil
 }
 
-func SearchUsers(keyword string, group string, startIdx int, num int) ([]*User, int64, error) {
+func SearchUsers(keyword string, group string, tenantId int, startIdx int, num int) ([]*User, int64, error) {
 	var users []*User
 	var total int64
 	var err error
@@ -239,7 +243,7 @@ func SearchUsers(keyword string, group string, startIdx int, num int) ([]*User,
 	}()
 
 	// 构建基础查询
-	query := tx.Unscoped().Model(&User{})
+	query := tx.Unscoped().Model(&User{}).Scopes(TenantScope(tenantId))
 
 	// 构建搜索条件
 	likeCondition := "username LIKE ? OR email LIKE ? OR display_name LIKE ?"
@@ -417,10 +421,12 @@ func (user *User) Insert(inviterId int) error {
 
 	if common.QuotaForNewUser > 0 {
 		RecordLog(user.Id, LogTypeSystem, fmt.Sprintf("新用户注册赠送 %s", logger.LogQuota(common.QuotaForNewUser)))
+		_ = RecordQuotaPool(nil, user.Id, QuotaPoolKindBonus, common.QuotaForNewUser, "register")
 	}
 	if inviterId != 0 {
 		if common.QuotaForInvitee > 0 {
 			_ = IncreaseUserQuota(user.Id, common.QuotaForInvitee, true)
+			_ = RecordQuotaPool(nil, user.Id, QuotaPoolKindBonus, common.QuotaForInvitee, "invitation")
 			RecordLog(user.Id, LogTypeSystem, fmt.Sprintf("使用邀请码赠送 %s", logger.LogQuota(common.QuotaForInvitee)))
 		}
 		if common.QuotaForInviter > 0 {
@@ -463,25 +469,16 @@ func (user *User) InsertWithTx(tx *gorm.DB, inviterId int) error {
 // FinalizeOAuthUserCreation performs post-transaction tasks for OAuth user creation.
 // This should be called after the transaction commits successfully.
 func (user *User) FinalizeOAuthUserCreation(inviterId int) {
-	// 用户创建成功后，根据角色初始化边栏配置
-	var createdUser User
-	if err := DB.Where("id = ?", user.Id).First(&createdUser).Error; err == nil {
-		defaultSidebarConfig := generateDefaultSidebarConfigForRole(createdUser.Role)
-		if defaultSidebarConfig != "" {
-			currentSetting := createdUser.GetSetting()
-			currentSetting.SidebarModules = defaultSidebarConfig
-			createdUser.SetSetting(currentSetting)
-			createdUser.Update(false)
-			common.SysLog(fmt.Sprintf("为新用户 %s (角色: %d) 初始化边栏配置", createdUser.Username, createdUser.Role))
-		}
-	}
+	user.initDefaultSidebarConfig()
 
 	if common.QuotaForNewUser > 0 {
 		RecordLog(user.Id, LogTypeSystem, fmt.Sprintf("新用户注册赠送 %s", logger.LogQuota(common.QuotaForNewUser)))
+		_ = RecordQuotaPool(nil, user.Id, QuotaPoolKindBonus, common.QuotaForNewUser, "register")
 	}
 	if inviterId != 0 {
 		if common.QuotaForInvitee > 0 {
 			_ = IncreaseUserQuota(user.Id, common.QuotaForInvitee, true)
+			_ = RecordQuotaPool(nil, user.Id, QuotaPoolKindBonus, common.QuotaForInvitee, "invitation")
 			RecordLog(user.Id, LogTypeSystem, fmt.Sprintf("使用邀请码赠送 %s", logger.LogQuota(common.QuotaForInvitee)))
 		}
 		if common.QuotaForInviter > 0 {
@@ -491,6 +488,21 @@ func (user *User) FinalizeOAuthUserCreation(inviterId int) {
 	}
 }
 
+// initDefaultSidebarConfig 用户创建成功后，根据角色初始化边栏配置
+func (user *User) initDefaultSidebarConfig() {
+	var createdUser User
+	if err := DB.Where("id = ?", user.Id).First(&createdUser).Error; err == nil {
+		defaultSidebarConfig := generateDefaultSidebarConfigForRole(createdUser.Role)
+		if defaultSidebarConfig != "" {
+			currentSetting := createdUser.GetSetting()
+			currentSetting.SidebarModules = defaultSidebarConfig
+			createdUser.SetSetting(currentSetting)
+			createdUser.Update(false)
+			common.SysLog(fmt.Sprintf("为新用户 %s (角色: %d) 初始化边栏配置", createdUser.Username, createdUser.Role))
+		}
+	}
+}
+
 func (user *User) Update(updatePassword bool) error {
 	var err error
 	if updatePassword {
@@ -897,8 +909,15 @@ func increaseUserQuota(id int, quota int) (err error) {
 }
 
 func DecreaseUserQuota(id int, quota int) (err error) {
+	_, err = DecreaseUserQuotaWithPools(id, quota)
+	return err
+}
+
+// DecreaseUserQuotaWithPools 扣除用户余额并按消费顺序同步扣除额度池，返回各额度池的扣除记录供退款时原路退回。
+// 额度池只记录余额构成，同步失败时不影响扣费
+func DecreaseUserQuotaWithPools(id int, quota int) ([]QuotaPoolDeduction, error) {
 	if quota < 0 {
-		return errors.New("quota 不能为负数！")
+		return nil, errors.New("quota 不能为负数！")
 	}
 	gopool.Go(func() {
 		err := cacheDecrUserQuota(id, int64(quota))
@@ -908,9 +927,14 @@ func DecreaseUserQuota(id int, quota int) (err error) {
 	})
 	if common.BatchUpdateEnabled {
 		addNewRecord(BatchUpdateTypeUserQuota, id, -quota)
-		return nil
+	} else if err := decreaseUserQuota(id, quota); err != nil {
+		return nil, err
+	}
+	deductions, err := ConsumeQuotaPools(id, quota)
+	if err != nil {
+		common.SysLog(fmt.Sprintf("failed to consume quota pools of user %d: %s", id, err.Error()))
 	}
-	return decreaseUserQuota(id, quota)
+	return deductions, nil
 }
 
 func decreaseUserQuota(id int, quota int) (err error) {
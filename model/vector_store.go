@@ -0,0 +1,307 @@
+package model
+
+import (
+	"errors"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/dto"
+
+	"gorm.io/gorm"
+)
+
+const (
+	VectorStoreStatusInProgress = "in_progress"
+	VectorStoreStatusCompleted  = "completed"
+	VectorStoreStatusExpired    = "expired"
+
+	VectorStoreFileStatusInProgress = "in_progress"
+	VectorStoreFileStatusCompleted  = "completed"
+	VectorStoreFileStatusFailed     = "failed"
+	VectorStoreFileStatusCancelled  = "cancelled"
+)
+
+// File 通过 /v1/files 上传到网关的文件，用于向量库的文件导入，也可以在请求中以 file_id 引用。
+// 元信息始终保存在主库；内容保存在 Content，或按 Storage 保存在共享存储目录、对象存储或上游渠道中的 StoragePath
+type File struct {
+	Id          string `json:"id" gorm:"type:varchar(64);primaryKey"`
+	UserId      int    `json:"user_id" gorm:"index"`
+	Filename    string `json:"filename" gorm:"type:varchar(255)"`
+	Purpose     string `json:"purpose" gorm:"type:varchar(32)"`
+	Bytes       int64  `json:"bytes"`
+	Content     []byte `json:"-"`
+	StoragePath string `json:"-" gorm:"type:varchar(255)"`
+	// 内容的存储方式，为空表示旧数据：有 StoragePath 时在共享存储目录，否则在 Content
+	Storage string `json:"-" gorm:"type:varchar(16)"`
+	// 内容上传到上游渠道时的渠道 ID，StoragePath 为上游的文件 ID
+	ChannelId int   `json:"-"`
+	CreatedAt int64 `json:"created_at" gorm:"bigint"`
+}
+
+// VectorStore 用户的向量库，片段与向量保存在向量存储后端中
+type VectorStore struct {
+	Id           string `json:"id" gorm:"type:varchar(64);primaryKey"`
+	UserId       int    `json:"user_id" gorm:"index"`
+	Name         string `json:"name" gorm:"type:varchar(255)"`
+	Metadata     string `json:"metadata" gorm:"type:text"`
+	UsageBytes   int64  `json:"usage_bytes"`
+	ExpiresDays  int    `json:"expires_days" gorm:"default:0"` // 最后活跃后多少天过期，0 表示不过期
+	LastActiveAt int64  `json:"last_active_at" gorm:"bigint"`
+	CreatedAt    int64  `json:"created_at" gorm:"bigint"`
+}
+
+// VectorStoreFile 向量库与文件的关联及导入状态
+type VectorStoreFile struct {
+	Id            int    `json:"id"`
+	VectorStoreId string `json:"vector_store_id" gorm:"type:varchar(64);index"`
+	FileId        string `json:"file_id" gorm:"type:varchar(64);index"`
+	UserId        int    `json:"user_id" gorm:"index"`
+	Status        string `json:"status" gorm:"type:varchar(16)"`
+	UsageBytes    int64  `json:"usage_bytes"`
+	ChunkCount    int    `json:"chunk_count"`
+	MaxChunkSize  int    `json:"max_chunk_size"`
+	ChunkOverlap  int    `json:"chunk_overlap"`
+	Attributes    string `json:"attributes" gorm:"type:text"`
+	LastError     string `json:"last_error" gorm:"type:text"`
+	CreatedAt     int64  `json:"created_at" gorm:"bigint"`
+}
+
+func NewFileId() string {
+	return "file-" + common.GetRandomString(24)
+}
+
+// CreateFile 保存文件记录；内容不在主库时需预先设置 Id、StoragePath 与 Bytes
+func CreateFile(file *File) error {
+	if file.Id == "" {
+		file.Id = NewFileId()
+	}
+	if file.StoragePath == "" {
+		file.Bytes = int64(len(file.Content))
+	}
+	file.CreatedAt = common.GetTimestamp()
+	return DB.Create(file).Error
+}
+
+// GetFileById 查询文件元信息，withContent 为 true 时同时读取文件内容
+func GetFileById(id string, userId int, withContent bool) (*File, error) {
+	var file File
+	query := DB.Where("id = ? and user_id = ?", id, userId)
+	if !withContent {
+		query = query.Omit("content")
+	}
+	err := query.First(&file).Error
+	return &file, err
+}
+
+// FindUserFile 查询用户文件的元信息，不存在时返回 nil
+func FindUserFile(id string, userId int) (*File, error) {
+	file, err := GetFileById(id, userId, false)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	return file, err
+}
+
+func GetUserFiles(userId int, purpose string, limit int) ([]*File, error) {
+	var files []*File
+	query := DB.Omit("content").Where("user_id = ?", userId)
+	if purpose != "" {
+		query = query.Where("purpose = ?", purpose)
+	}
+	err := query.Order("created_at desc").Limit(limit).Find(&files).Error
+	return files, err
+}
+
+func DeleteFile(id string, userId int) error {
+	result := DB.Where("id = ? and user_id = ?", id, userId).Delete(&File{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+func CreateVectorStore(store *VectorStore) error {
+	store.Id = "vs_" + common.GetRandomString(24)
+	store.CreatedAt = common.GetTimestamp()
+	store.LastActiveAt = store.CreatedAt
+	return DB.Create(store).Error
+}
+
+func GetVectorStoreById(id string, userId int) (*VectorStore, error) {
+	var store VectorStore
+	err := DB.Where("id = ? and user_id = ?", id, userId).First(&store).Error
+	return &store, err
+}
+
+// GetVectorStoresByIds 返回用户拥有的向量库，忽略不存在或不属于该用户的 id
+func GetVectorStoresByIds(ids []string, userId int) ([]*VectorStore, error) {
+	var stores []*VectorStore
+	if len(ids) == 0 {
+		return stores, nil
+	}
+	err := DB.Where("id IN ? and user_id = ?", ids, userId).Find(&stores).Error
+	return stores, err
+}
+
+func GetUserVectorStores(userId int, limit int, after string) ([]*VectorStore, error) {
+	var stores []*VectorStore
+	query := DB.Where("user_id = ?", userId)
+	if after != "" {
+		var cursor VectorStore
+		if err := DB.Select("created_at").Where("id = ? and user_id = ?", after, userId).First(&cursor).Error; err == nil {
+			query = query.Where("created_at < ? or (created_at = ? and id < ?)", cursor.CreatedAt, cursor.CreatedAt, after)
+		}
+	}
+	err := query.Order("created_at desc").Order("id desc").Limit(limit).Find(&stores).Error
+	return stores, err
+}
+
+func UpdateVectorStore(store *VectorStore) error {
+	return DB.Model(store).Select("name", "metadata", "expires_days").Updates(store).Error
+}
+
+func TouchVectorStores(ids []string) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	return DB.Model(&VectorStore{}).Where("id IN ?", ids).Update("last_active_at", common.GetTimestamp()).Error
+}
+
+// DeleteVectorStore 删除向量库及其文件关联
+func DeleteVectorStore(id string, userId int) error {
+	return DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Where("id = ? and user_id = ?", id, userId).Delete(&VectorStore{})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		return tx.Where("vector_store_id = ?", id).Delete(&VectorStoreFile{}).Error
+	})
+}
+
+func GetVectorStoreFileCounts(vectorStoreId string) (dto.VectorStoreFileCounts, error) {
+	var rows []struct {
+		Status string
+		Count  int
+	}
+	counts := dto.VectorStoreFileCounts{}
+	err := DB.Model(&VectorStoreFile{}).Select("status, count(*) as count").
+		Where("vector_store_id = ?", vectorStoreId).Group("status").Scan(&rows).Error
+	if err != nil {
+		return counts, err
+	}
+	for _, row := range rows {
+		switch row.Status {
+		case VectorStoreFileStatusInProgress:
+			counts.InProgress = row.Count
+		case VectorStoreFileStatusCompleted:
+			counts.Completed = row.Count
+		case VectorStoreFileStatusFailed:
+			counts.Failed = row.Count
+		case VectorStoreFileStatusCancelled:
+			counts.Cancelled = row.Count
+		}
+		counts.Total += row.Count
+	}
+	return counts, nil
+}
+
+func CreateVectorStoreFile(file *VectorStoreFile) error {
+	var count int64
+	if err := DB.Model(&VectorStoreFile{}).Where("vector_store_id = ? and file_id = ?", file.VectorStoreId, file.FileId).Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return errors.New("file already exists in vector store")
+	}
+	file.Status = VectorStoreFileStatusInProgress
+	file.CreatedAt = common.GetTimestamp()
+	return DB.Create(file).Error
+}
+
+func GetVectorStoreFile(vectorStoreId string, fileId string, userId int) (*VectorStoreFile, error) {
+	var file VectorStoreFile
+	err := DB.Where("vector_store_id = ? and file_id = ? and user_id = ?", vectorStoreId, fileId, userId).First(&file).Error
+	return &file, err
+}
+
+func GetVectorStoreFiles(vectorStoreId string, status string, limit int) ([]*VectorStoreFile, error) {
+	var files []*VectorStoreFile
+	query := DB.Where("vector_store_id = ?", vectorStoreId)
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+	err := query.Order("id desc").Limit(limit).Find(&files).Error
+	return files, err
+}
+
+// GetVectorStoreFilesByFileId 返回引用了某个文件的所有向量库关联
+func GetVectorStoreFilesByFileId(fileId string, userId int) ([]*VectorStoreFile, error) {
+	var files []*VectorStoreFile
+	err := DB.Where("file_id = ? and user_id = ?", fileId, userId).Find(&files).Error
+	return files, err
+}
+
+// GetUserVectorStoreFileUsage 返回用户所有向量库文件的总用量与文件数
+func GetUserVectorStoreFileUsage(userId int) (int64, int64, error) {
+	var usage struct {
+		UsageBytes int64
+		Files      int64
+	}
+	err := DB.Model(&VectorStoreFile{}).Select("COALESCE(SUM(usage_bytes), 0) AS usage_bytes, COUNT(*) AS files").
+		Where("user_id = ?", userId).Scan(&usage).Error
+	return usage.UsageBytes, usage.Files, err
+}
+
+// FinishVectorStoreFile 更新导入结果，并同步向量库的用量
+func FinishVectorStoreFile(file *VectorStoreFile, status string, chunkCount int, usageBytes int64, lastError string) error {
+	return DB.Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(file).Updates(map[string]interface{}{
+			"status":      status,
+			"chunk_count": chunkCount,
+			"usage_bytes": usageBytes,
+			"last_error":  lastError,
+		}).Error
+		if err != nil {
+			return err
+		}
+		if usageBytes == 0 {
+			return nil
+		}
+		return tx.Model(&VectorStore{}).Where("id = ?", file.VectorStoreId).
+			Update("usage_bytes", gorm.Expr("usage_bytes + ?", usageBytes)).Error
+	})
+}
+
+func DeleteVectorStoreFile(file *VectorStoreFile) error {
+	return DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(file).Error; err != nil {
+			return err
+		}
+		if file.UsageBytes == 0 {
+			return nil
+		}
+		return tx.Model(&VectorStore{}).Where("id = ?", file.VectorStoreId).
+			Update("usage_bytes", gorm.Expr("usage_bytes - ?", file.UsageBytes)).Error
+	})
+}
+
+// GetFilenames 返回文件 id 到文件名的映射，用于检索结果展示
+func GetFilenames(ids []string) (map[string]string, error) {
+	var files []*File
+	names := make(map[string]string, len(ids))
+	if len(ids) == 0 {
+		return names, nil
+	}
+	if err := DB.Select("id", "filename").Where("id IN ?", ids).Find(&files).Error; err != nil {
+		return names, err
+	}
+	for _, file := range files {
+		names[file.Id] = file.Filename
+	}
+	return names, nil
+}
@@ -21,7 +21,8 @@ type HybridCacheConfig[V any] struct {
 	Namespace Namespace
 
 	// Redis is used when RedisEnabled returns true (or RedisEnabled is nil) and Redis is not nil.
-	Redis        *redis.Client
+	// Standalone, Sentinel (failover) and Cluster clients are supported.
+	Redis        redis.UniversalClient
 	RedisCodec   ValueCodec[V]
 	RedisEnabled func() bool
 
@@ -33,7 +34,7 @@ type HybridCacheConfig[V any] struct {
 type HybridCache[V any] struct {
 	ns Namespace
 
-	redis        *redis.Client
+	redis        redis.UniversalClient
 	redisCodec   ValueCodec[V]
 	redisEnabled func() bool
 
@@ -140,10 +141,27 @@ func (c *HybridCache[V]) scanKeys(match string) ([]string, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), defaultRedisScanTimeout)
 	defer cancel()
 
+	// SCAN on a cluster client only visits a single node, so every master is scanned.
+	if cluster, ok := c.redis.(*redis.ClusterClient); ok {
+		var mu sync.Mutex
+		keys := make([]string, 0, 1024)
+		err := cluster.ForEachMaster(ctx, func(ctx context.Context, master *redis.Client) error {
+			nodeKeys, err := scanNodeKeys(ctx, master, match)
+			mu.Lock()
+			keys = append(keys, nodeKeys...)
+			mu.Unlock()
+			return err
+		})
+		return keys, err
+	}
+	return scanNodeKeys(ctx, c.redis, match)
+}
+
+func scanNodeKeys(ctx context.Context, client redis.Cmdable, match string) ([]string, error) {
 	var cursor uint64
 	keys := make([]string, 0, 1024)
 	for {
-		k, next, err := c.redis.Scan(ctx, cursor, match, 1000).Result()
+		k, next, err := client.Scan(ctx, cursor, match, 1000).Result()
 		if err != nil {
 			return keys, err
 		}
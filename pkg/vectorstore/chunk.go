@@ -0,0 +1,81 @@
+package vectorstore
+
+import (
+	"strings"
+	"unicode"
+)
+
+const (
+	// 与 OpenAI static 分块策略的默认值一致
+	DefaultMaxChunkSizeTokens = 800
+	DefaultChunkOverlapTokens = 400
+
+	// 估算 token 数时按每个 token 约 4 个字符计算，CJK 字符按 1 个 token 计算
+	charsPerToken = 4
+)
+
+// ChunkText 按近似 token 数将文本切分为带重叠的片段，尽量在空白处断开
+func ChunkText(text string, maxTokens int, overlapTokens int) []string {
+	if maxTokens <= 0 {
+		maxTokens = DefaultMaxChunkSizeTokens
+	}
+	if overlapTokens < 0 || overlapTokens > maxTokens/2 {
+		overlapTokens = maxTokens / 2
+	}
+	runes := []rune(strings.TrimSpace(text))
+	if len(runes) == 0 {
+		return nil
+	}
+
+	// weights[i] 为前 i 个字符的近似 token 数（乘以 charsPerToken 以避免浮点）
+	weights := make([]int, len(runes)+1)
+	for i, r := range runes {
+		w := 1
+		if r > unicode.MaxLatin1 {
+			w = charsPerToken
+		}
+		weights[i+1] = weights[i] + w
+	}
+	maxWeight := maxTokens * charsPerToken
+	overlapWeight := overlapTokens * charsPerToken
+
+	chunks := make([]string, 0)
+	start := 0
+	for start < len(runes) {
+		end := start
+		for end < len(runes) && weights[end+1]-weights[start] <= maxWeight {
+			end++
+		}
+		if end == start {
+			end++
+		}
+		if end < len(runes) {
+			// 回退到最近的空白处，避免截断单词
+			for i := end; i > start+(end-start)/2; i-- {
+				if unicode.IsSpace(runes[i-1]) {
+					end = i
+					break
+				}
+			}
+		}
+		if chunk := strings.TrimSpace(string(runes[start:end])); chunk != "" {
+			chunks = append(chunks, chunk)
+		}
+		if end >= len(runes) {
+			break
+		}
+		next := end
+		for next > start+1 && weights[end]-weights[next-1] <= overlapWeight {
+			next--
+		}
+		// 重叠部分同样从单词边界开始
+		for i := next; i < end; i++ {
+			if i > start && unicode.IsSpace(runes[i-1]) {
+				next = i
+				break
+			}
+		}
+		start = next
+	}
+	return chunks
+}
@@ -0,0 +1,45 @@
+package vectorstore
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestChunkTextShortInput(t *testing.T) {
+	assert.Equal(t, []string{"hello world"}, ChunkText("  hello world \n", 100, 10))
+	assert.Empty(t, ChunkText("   ", 100, 10))
+}
+
+func TestChunkTextSplitsWithOverlap(t *testing.T) {
+	words := make([]string, 0, 200)
+	for i := 0; i < 200; i++ {
+		words = append(words, "word")
+	}
+	text := strings.Join(words, " ")
+
+	// 每个片段最多约 10 token（40 个字符），重叠约 2 token
+	chunks := ChunkText(text, 10, 2)
+	require.Greater(t, len(chunks), 1)
+	for _, chunk := range chunks {
+		assert.LessOrEqual(t, len(chunk), 40)
+		assert.False(t, strings.HasPrefix(chunk, "ord"), "chunk should start at a word boundary: %q", chunk)
+	}
+	// 相邻片段应有重叠
+	assert.True(t, strings.HasSuffix(chunks[0], "word"))
+	assert.True(t, strings.HasPrefix(chunks[1], "word"))
+	assert.Greater(t, len(strings.Join(chunks, " ")), len(text))
+}
+
+func TestChunkTextCJKWeight(t *testing.T) {
+	text := strings.Repeat("中", 30)
+	chunks := ChunkText(text, 10, 0)
+	require.Len(t, chunks, 3)
+	assert.Equal(t, strings.Repeat("中", 10), chunks[0])
+}
+
+func TestVectorLiteral(t *testing.T) {
+	assert.Equal(t, "[0.5,-1,2.25]", vectorLiteral([]float32{0.5, -1, 2.25}))
+}
@@ -0,0 +1,144 @@
+package vectorstore
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+var tableNamePattern = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+type pgvectorBackend struct {
+	db    *gorm.DB
+	table string
+}
+
+func newPgvectorBackend(cfg Config) (Backend, error) {
+	if cfg.PostgresDSN == "" {
+		return nil, fmt.Errorf("pgvector backend requires a PostgreSQL DSN")
+	}
+	if !tableNamePattern.MatchString(cfg.Collection) {
+		return nil, fmt.Errorf("invalid pgvector table name: %s", cfg.Collection)
+	}
+	db, err := gorm.Open(postgres.Open(cfg.PostgresDSN), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		return nil, err
+	}
+	b := &pgvectorBackend{db: db, table: cfg.Collection}
+	statements := []string{
+		"CREATE EXTENSION IF NOT EXISTS vector",
+		fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+			vector_store_id VARCHAR(64) NOT NULL,
+			file_id VARCHAR(64) NOT NULL,
+			chunk_index INTEGER NOT NULL,
+			content TEXT NOT NULL,
+			embedding vector(%d) NOT NULL,
+			PRIMARY KEY (vector_store_id, file_id, chunk_index)
+		)`, b.table, cfg.Dimensions),
+		fmt.Sprintf("CREATE INDEX IF NOT EXISTS %s_embedding_idx ON %s USING hnsw (embedding vector_cosine_ops)", b.table, b.table),
+	}
+	for _, statement := range statements {
+		if err = db.Exec(statement).Error; err != nil {
+			_ = b.Close()
+			return nil, err
+		}
+	}
+	return b, nil
+}
+
+// vectorLiteral 将向量格式化为 pgvector 的文本表示，如 [0.1,0.2]
+func vectorLiteral(vector []float32) string {
+	var sb strings.Builder
+	sb.WriteByte('[')
+	for i, v := range vector {
+		if i > 0 {
+			sb.WriteByte(',')
+		}
+		sb.WriteString(strconv.FormatFloat(float64(v), 'g', -1, 32))
+	}
+	sb.WriteByte(']')
+	return sb.String()
+}
+
+func (b *pgvectorBackend) Upsert(ctx context.Context, chunks []Chunk) error {
+	if len(chunks) == 0 {
+		return nil
+	}
+	return b.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		query := fmt.Sprintf(`INSERT INTO %s (vector_store_id, file_id, chunk_index, content, embedding)
+			VALUES (?, ?, ?, ?, ?::vector)
+			ON CONFLICT (vector_store_id, file_id, chunk_index)
+			DO UPDATE SET content = EXCLUDED.content, embedding = EXCLUDED.embedding`, b.table)
+		for _, chunk := range chunks {
+			if err := tx.Exec(query, chunk.VectorStoreId, chunk.FileId, chunk.Index, chunk.Text, vectorLiteral(chunk.Vector)).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (b *pgvectorBackend) Search(ctx context.Context, query SearchQuery) ([]SearchResult, error) {
+	if len(query.VectorStoreIds) == 0 {
+		return nil, nil
+	}
+	vector := vectorLiteral(query.Vector)
+	where := "vector_store_id IN ?"
+	args := []any{vector, query.VectorStoreIds}
+	if len(query.FileIds) > 0 {
+		where += " AND file_id IN ?"
+		args = append(args, query.FileIds)
+	}
+	args = append(args, vector, query.Limit)
+	sql := fmt.Sprintf(`SELECT vector_store_id, file_id, chunk_index, content, 1 - (embedding <=> ?::vector) AS score
+		FROM %s WHERE %s ORDER BY embedding <=> ?::vector LIMIT ?`, b.table, where)
+
+	var rows []struct {
+		VectorStoreId string
+		FileId        string
+		ChunkIndex    int
+		Content       string
+		Score         float64
+	}
+	if err := b.db.WithContext(ctx).Raw(sql, args...).Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+	results := make([]SearchResult, 0, len(rows))
+	for _, row := range rows {
+		if row.Score < query.ScoreThreshold {
+			continue
+		}
+		results = append(results, SearchResult{
+			VectorStoreId: row.VectorStoreId,
+			FileId:        row.FileId,
+			Index:         row.ChunkIndex,
+			Text:          row.Content,
+			Score:         row.Score,
+		})
+	}
+	return results, nil
+}
+
+func (b *pgvectorBackend) DeleteFile(ctx context.Context, vectorStoreId string, fileId string) error {
+	return b.db.WithContext(ctx).Exec(fmt.Sprintf("DELETE FROM %s WHERE vector_store_id = ? AND file_id = ?", b.table), vectorStoreId, fileId).Error
+}
+
+func (b *pgvectorBackend) DeleteVectorStore(ctx context.Context, vectorStoreId string) error {
+	return b.db.WithContext(ctx).Exec(fmt.Sprintf("DELETE FROM %s WHERE vector_store_id = ?", b.table), vectorStoreId).Error
+}
+
+func (b *pgvectorBackend) Close() error {
+	sqlDB, err := b.db.DB()
+	if err != nil {
+		return err
+	}
+	return sqlDB.Close()
+}
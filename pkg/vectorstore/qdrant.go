@@ -0,0 +1,195 @@
+package vectorstore
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+
+	"github.com/google/uuid"
+)
+
+// qdrantBatchSize 单次写入的点数量上限
+const qdrantBatchSize = 128
+
+type qdrantBackend struct {
+	baseURL    string
+	apiKey     string
+	collection string
+	client     *http.Client
+}
+
+func newQdrantBackend(cfg Config) (Backend, error) {
+	if cfg.QdrantURL == "" {
+		return nil, fmt.Errorf("qdrant backend requires a url")
+	}
+	b := &qdrantBackend{
+		baseURL:    strings.TrimSuffix(cfg.QdrantURL, "/"),
+		apiKey:     cfg.QdrantApiKey,
+		collection: cfg.Collection,
+		client:     &http.Client{Timeout: 30 * time.Second},
+	}
+	ctx := context.Background()
+	status, _, err := b.do(ctx, http.MethodGet, "", nil)
+	if err != nil {
+		return nil, err
+	}
+	if status == http.StatusNotFound {
+		create := map[string]any{
+			"vectors": map[string]any{"size": cfg.Dimensions, "distance": "Cosine"},
+		}
+		if err = b.expectOK(b.do(ctx, http.MethodPut, "", create)); err != nil {
+			return nil, err
+		}
+		for _, field := range []string{"vector_store_id", "file_id"} {
+			index := map[string]any{"field_name": field, "field_schema": "keyword"}
+			if err = b.expectOK(b.do(ctx, http.MethodPut, "/index?wait=true", index)); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return b, nil
+}
+
+func (b *qdrantBackend) do(ctx context.Context, method string, path string, body any) (int, []byte, error) {
+	var reader io.Reader
+	if body != nil {
+		data, err := common.Marshal(body)
+		if err != nil {
+			return 0, nil, err
+		}
+		reader = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, b.baseURL+"/collections/"+url.PathEscape(b.collection)+path, reader)
+	if err != nil {
+		return 0, nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if b.apiKey != "" {
+		req.Header.Set("api-key", b.apiKey)
+	}
+	resp, err := b.client.Do(req)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	return resp.StatusCode, data, err
+}
+
+func (b *qdrantBackend) expectOK(status int, data []byte, err error) error {
+	if err != nil {
+		return err
+	}
+	if status < http.StatusOK || status >= http.StatusMultipleChoices {
+		return fmt.Errorf("qdrant returned status %d: %s", status, string(data))
+	}
+	return nil
+}
+
+// pointId 由向量库、文件与片段序号生成稳定的 UUID，重复写入时覆盖
+func pointId(vectorStoreId string, fileId string, index int) string {
+	return uuid.NewSHA1(uuid.NameSpaceURL, []byte(fmt.Sprintf("%s/%s/%d", vectorStoreId, fileId, index))).String()
+}
+
+func matchFilter(field string, values []string) map[string]any {
+	return map[string]any{"key": field, "match": map[string]any{"any": values}}
+}
+
+func (b *qdrantBackend) Upsert(ctx context.Context, chunks []Chunk) error {
+	for start := 0; start < len(chunks); start += qdrantBatchSize {
+		end := min(start+qdrantBatchSize, len(chunks))
+		points := make([]map[string]any, 0, end-start)
+		for _, chunk := range chunks[start:end] {
+			points = append(points, map[string]any{
+				"id":     pointId(chunk.VectorStoreId, chunk.FileId, chunk.Index),
+				"vector": chunk.Vector,
+				"payload": map[string]any{
+					"vector_store_id": chunk.VectorStoreId,
+					"file_id":         chunk.FileId,
+					"chunk_index":     chunk.Index,
+					"content":         chunk.Text,
+				},
+			})
+		}
+		if err := b.expectOK(b.do(ctx, http.MethodPut, "/points?wait=true", map[string]any{"points": points})); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (b *qdrantBackend) Search(ctx context.Context, query SearchQuery) ([]SearchResult, error) {
+	if len(query.VectorStoreIds) == 0 {
+		return nil, nil
+	}
+	must := []map[string]any{matchFilter("vector_store_id", query.VectorStoreIds)}
+	if len(query.FileIds) > 0 {
+		must = append(must, matchFilter("file_id", query.FileIds))
+	}
+	body := map[string]any{
+		"vector":       query.Vector,
+		"limit":        query.Limit,
+		"with_payload": true,
+		"filter":       map[string]any{"must": must},
+	}
+	if query.ScoreThreshold > 0 {
+		body["score_threshold"] = query.ScoreThreshold
+	}
+	status, data, err := b.do(ctx, http.MethodPost, "/points/search", body)
+	if err = b.expectOK(status, data, err); err != nil {
+		return nil, err
+	}
+	var response struct {
+		Result []struct {
+			Score   float64 `json:"score"`
+			Payload struct {
+				VectorStoreId string `json:"vector_store_id"`
+				FileId        string `json:"file_id"`
+				ChunkIndex    int    `json:"chunk_index"`
+				Content       string `json:"content"`
+			} `json:"payload"`
+		} `json:"result"`
+	}
+	if err = common.Unmarshal(data, &response); err != nil {
+		return nil, err
+	}
+	results := make([]SearchResult, 0, len(response.Result))
+	for _, point := range response.Result {
+		results = append(results, SearchResult{
+			VectorStoreId: point.Payload.VectorStoreId,
+			FileId:        point.Payload.FileId,
+			Index:         point.Payload.ChunkIndex,
+			Text:          point.Payload.Content,
+			Score:         point.Score,
+		})
+	}
+	return results, nil
+}
+
+func (b *qdrantBackend) deleteByFilter(ctx context.Context, must []map[string]any) error {
+	body := map[string]any{"filter": map[string]any{"must": must}}
+	return b.expectOK(b.do(ctx, http.MethodPost, "/points/delete?wait=true", body))
+}
+
+func (b *qdrantBackend) DeleteFile(ctx context.Context, vectorStoreId string, fileId string) error {
+	return b.deleteByFilter(ctx, []map[string]any{
+		matchFilter("vector_store_id", []string{vectorStoreId}),
+		matchFilter("file_id", []string{fileId}),
+	})
+}
+
+func (b *qdrantBackend) DeleteVectorStore(ctx context.Context, vectorStoreId string) error {
+	return b.deleteByFilter(ctx, []map[string]any{matchFilter("vector_store_id", []string{vectorStoreId})})
+}
+
+func (b *qdrantBackend) Close() error {
+	b.client.CloseIdleConnections()
+	return nil
+}
@@ -0,0 +1,75 @@
+package vectorstore
+
+import (
+	"context"
+	"fmt"
+)
+
+const (
+	BackendPgvector = "pgvector"
+	BackendQdrant   = "qdrant"
+)
+
+// Chunk 文件切分后的一个片段及其向量
+type Chunk struct {
+	VectorStoreId string
+	FileId        string
+	Index         int
+	Text          string
+	Vector        []float32
+}
+
+// SearchResult 检索命中的片段，Score 为余弦相似度
+type SearchResult struct {
+	VectorStoreId string
+	FileId        string
+	Index         int
+	Text          string
+	Score         float64
+}
+
+// SearchQuery 检索参数，FileIds 为空时不按文件过滤
+type SearchQuery struct {
+	VectorStoreIds []string
+	FileIds        []string
+	Vector         []float32
+	Limit          int
+	ScoreThreshold float64
+}
+
+// Backend 向量存储后端
+type Backend interface {
+	Upsert(ctx context.Context, chunks []Chunk) error
+	Search(ctx context.Context, query SearchQuery) ([]SearchResult, error)
+	DeleteFile(ctx context.Context, vectorStoreId string, fileId string) error
+	DeleteVectorStore(ctx context.Context, vectorStoreId string) error
+	Close() error
+}
+
+// Config 后端连接参数，Dimensions 为向量维度，需与嵌入模型输出一致
+type Config struct {
+	Backend      string
+	PostgresDSN  string
+	QdrantURL    string
+	QdrantApiKey string
+	Collection   string
+	Dimensions   int
+}
+
+// New 按配置创建向量存储后端
+func New(cfg Config) (Backend, error) {
+	if cfg.Dimensions <= 0 {
+		return nil, fmt.Errorf("invalid vector dimensions: %d", cfg.Dimensions)
+	}
+	if cfg.Collection == "" {
+		cfg.Collection = "vector_store_chunks"
+	}
+	switch cfg.Backend {
+	case BackendPgvector:
+		return newPgvectorBackend(cfg)
+	case BackendQdrant:
+		return newQdrantBackend(cfg)
+	default:
+		return nil, fmt.Errorf("unsupported vector store backend: %s", cfg.Backend)
+	}
+}
@@ -10,6 +10,7 @@ import (
 	relaycommon "github.com/QuantumNous/new-api/relay/common"
 	"github.com/QuantumNous/new-api/relay/helper"
 	"github.com/QuantumNous/new-api/service"
+	"github.com/QuantumNous/new-api/setting/model_setting"
 	"github.com/QuantumNous/new-api/types"
 
 	"github.com/gin-gonic/gin"
@@ -67,6 +68,24 @@ func AudioHelper(c *gin.Context, info *relaycommon.RelayInfo) (newAPIError *type
 		service.ResetStatusCode(newAPIError, statusCodeMappingStr)
 		return newAPIError
 	}
+	// 配置了每分钟价格的转写模型按音频时长计费，无法确定时长时回退到按 token 计费
+	if pricePerMinute, ok := service.AudioTranscriptionPricePerMinute(info); ok {
+		if seconds := service.AudioTranscriptionSeconds(c); seconds > 0 {
+			service.PostAudioMinuteConsumeQuota(c, info, usage.(*dto.Usage), pricePerMinute, seconds)
+			return nil
+		}
+	}
+	// 配置了字符或时长价格的语音合成模型按价格计费，按时长计费但无法确定时长时回退到按 token 计费
+	if billing, price, ok := service.AudioSpeechPricing(info); ok {
+		if billing == model_setting.SpeechBillingPerCharacter {
+			service.PostAudioSpeechConsumeQuota(c, info, usage.(*dto.Usage), billing, price, float64(service.AudioSpeechCharacters(info)))
+			return nil
+		}
+		if seconds := service.AudioSpeechSeconds(c); seconds > 0 {
+			service.PostAudioSpeechConsumeQuota(c, info, usage.(*dto.Usage), billing, price, seconds)
+			return nil
+		}
+	}
 	if usage.(*dto.Usage).CompletionTokenDetails.AudioTokens > 0 || usage.(*dto.Usage).PromptTokensDetails.AudioTokens > 0 {
 		service.PostAudioConsumeQuota(c, info, usage.(*dto.Usage), "")
 	} else {
@@ -16,7 +16,6 @@ import (
 	"github.com/QuantumNous/new-api/relay/common"
 	"github.com/QuantumNous/new-api/relay/constant"
 	"github.com/QuantumNous/new-api/relay/helper"
-	"github.com/QuantumNous/new-api/service"
 	"github.com/QuantumNous/new-api/setting/operation_setting"
 	"github.com/QuantumNous/new-api/types"
 
@@ -288,7 +287,7 @@ func applyHeaderOverrideToRequest(req *http.Request, headerOverride map[string]s
 }
 
 func DoApiRequest(a Adaptor, c *gin.Context, info *common.RelayInfo, requestBody io.Reader) (*http.Response, error) {
-	fullRequestURL, err := a.GetRequestURL(info)
+	fullRequestURL, err := getRequestURL(a, info)
 	if err != nil {
 		return nil, fmt.Errorf("get request url failed: %w", err)
 	}
@@ -319,7 +318,7 @@ func DoApiRequest(a Adaptor, c *gin.Context, info *common.RelayInfo, requestBody
 }
 
 func DoFormRequest(a Adaptor, c *gin.Context, info *common.RelayInfo, requestBody io.Reader) (*http.Response, error) {
-	fullRequestURL, err := a.GetRequestURL(info)
+	fullRequestURL, err := getRequestURL(a, info)
 	if err != nil {
 		return nil, fmt.Errorf("get request url failed: %w", err)
 	}
@@ -352,7 +351,7 @@ func DoFormRequest(a Adaptor, c *gin.Context, info *common.RelayInfo, requestBod
 }
 
 func DoWssRequest(a Adaptor, c *gin.Context, info *common.RelayInfo, requestBody io.Reader) (*websocket.Conn, error) {
-	fullRequestURL, err := a.GetRequestURL(info)
+	fullRequestURL, err := getRequestURL(a, info)
 	if err != nil {
 		return nil, fmt.Errorf("get request url failed: %w", err)
 	}
@@ -484,15 +483,14 @@ func DoRequest(c *gin.Context, req *http.Request, info *common.RelayInfo) (*http
 	return doRequest(c, req, info)
 }
 func doRequest(c *gin.Context, req *http.Request, info *common.RelayInfo) (*http.Response, error) {
-	var client *http.Client
-	var err error
-	if info.ChannelSetting.Proxy != "" {
-		client, err = service.NewProxyHttpClient(info.ChannelSetting.Proxy)
-		if err != nil {
-			return nil, fmt.Errorf("new proxy http client failed: %w", err)
-		}
-	} else {
-		client = service.GetHttpClient()
+	// 渠道配置了双向 TLS 客户端证书、上游证书校验或拨号设置时使用专用客户端（同样遵循渠道代理）
+	signing := info.ChannelOtherSettings.RequestSigning
+	client, err := GetChannelHttpClient(info.ChannelOtherSettings, info.ChannelBaseUrl, info.ChannelSetting.Proxy)
+	if err != nil {
+		return nil, err
+	}
+	if err = signUpstreamRequest(c.Request.Context(), req, signing); err != nil {
+		return nil, fmt.Errorf("sign upstream request failed: %w", err)
 	}
 
 	var stopPinger context.CancelFunc
@@ -515,6 +513,9 @@ func doRequest(c *gin.Context, req *http.Request, info *common.RelayInfo) (*http
 		}
 	}
 
+	if req.Body != nil && req.Body != http.NoBody {
+		req.Body = info.CaptureBody(common.CaptureStageUpstreamRequest, req.Body)
+	}
 	resp, err := client.Do(req)
 	if err != nil {
 		logger.LogError(c, "do request failed: "+err.Error())
@@ -523,6 +524,15 @@ func doRequest(c *gin.Context, req *http.Request, info *common.RelayInfo) (*http
 	if resp == nil {
 		return nil, errors.New("resp is nil")
 	}
+	resp.Body = info.CaptureBody(common.CaptureStageUpstreamResponse, resp.Body)
+	if info.IsStream && resp.StatusCode == http.StatusOK {
+		// 首个内容 token 之前失败时尚未向客户端写出数据，返回错误以便换渠道重试
+		if err := bufferStreamPrelude(c.Request.Context(), resp, info.ChannelOtherSettings.StreamPrelude); err != nil {
+			_ = resp.Body.Close()
+			logger.LogWarn(c, "stream prelude failed: "+err.Error())
+			return nil, types.NewError(err, types.ErrorCodeBadResponse)
+		}
+	}
 
 	_ = req.Body.Close()
 	_ = c.Request.Body.Close()
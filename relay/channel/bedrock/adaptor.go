@@ -0,0 +1,161 @@
+package bedrock
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/QuantumNous/new-api/dto"
+	"github.com/QuantumNous/new-api/relay/channel"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	"github.com/QuantumNous/new-api/types"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Adaptor 通过 Bedrock Converse / ConverseStream API 调用 Bedrock 上的模型，
+// 请求使用渠道密钥中的 AK/SK 做 SigV4 签名，或使用 Bedrock API Key
+type Adaptor struct {
+	key bedrockKey
+}
+
+func (a *Adaptor) ConvertGeminiRequest(*gin.Context, *relaycommon.RelayInfo, *dto.GeminiChatRequest) (any, error) {
+	//TODO implement me
+	return nil, errors.New("not implemented")
+}
+
+func (a *Adaptor) ConvertClaudeRequest(*gin.Context, *relaycommon.RelayInfo, *dto.ClaudeRequest) (any, error) {
+	//TODO implement me
+	return nil, errors.New("not implemented")
+}
+
+func (a *Adaptor) ConvertAudioRequest(c *gin.Context, info *relaycommon.RelayInfo, request dto.AudioRequest) (io.Reader, error) {
+	//TODO implement me
+	return nil, errors.New("not implemented")
+}
+
+func (a *Adaptor) ConvertImageRequest(c *gin.Context, info *relaycommon.RelayInfo, request dto.ImageRequest) (any, error) {
+	//TODO implement me
+	return nil, errors.New("not implemented")
+}
+
+func (a *Adaptor) Init(info *relaycommon.RelayInfo) {
+}
+
+func (a *Adaptor) GetRequestURL(info *relaycommon.RelayInfo) (string, error) {
+	key, err := parseBedrockKey(info.ApiKey, info.ChannelOtherSettings.AwsKeyType)
+	if err != nil {
+		return "", err
+	}
+	a.key = key
+	baseURL := strings.TrimSuffix(info.ChannelBaseUrl, "/")
+	if baseURL == "" {
+		baseURL = fmt.Sprintf("https://bedrock-runtime.%s.amazonaws.com", key.region)
+	}
+	action := "converse"
+	if info.IsStream {
+		action = "converse-stream"
+	}
+	modelID := getBedrockModelID(info.UpstreamModelName, key.region)
+	return fmt.Sprintf("%s/model/%s/%s", baseURL, url.PathEscape(modelID), action), nil
+}
+
+func (a *Adaptor) SetupRequestHeader(c *gin.Context, req *http.Header, info *relaycommon.RelayInfo) error {
+	channel.SetupApiRequestHeader(info, c, req)
+	req.Set("Content-Type", "application/json")
+	if info.IsStream {
+		req.Set("Accept", "application/vnd.amazon.eventstream")
+	} else {
+		req.Set("Accept", "application/json")
+	}
+	if a.key.apiKey != "" {
+		req.Set("Authorization", "Bearer "+a.key.apiKey)
+	}
+	return nil
+}
+
+func (a *Adaptor) ConvertOpenAIRequest(c *gin.Context, info *relaycommon.RelayInfo, request *dto.GeneralOpenAIRequest) (any, error) {
+	if request == nil {
+		return nil, errors.New("request is nil")
+	}
+	return requestOpenAI2Converse(c, request)
+}
+
+func (a *Adaptor) ConvertRerankRequest(c *gin.Context, relayMode int, request dto.RerankRequest) (any, error) {
+	return nil, nil
+}
+
+func (a *Adaptor) ConvertEmbeddingRequest(c *gin.Context, info *relaycommon.RelayInfo, request dto.EmbeddingRequest) (any, error) {
+	//TODO implement me
+	return nil, errors.New("not implemented")
+}
+
+func (a *Adaptor) ConvertOpenAIResponsesRequest(c *gin.Context, info *relaycommon.RelayInfo, request dto.OpenAIResponsesRequest) (any, error) {
+	// TODO implement me
+	return nil, errors.New("not implemented")
+}
+
+func (a *Adaptor) DoRequest(c *gin.Context, info *relaycommon.RelayInfo, requestBody io.Reader) (any, error) {
+	if a.key.accessKeyId != "" {
+		// 复用渠道的上游签名流程，保留渠道上已配置的 TLS 设置
+		signing := dto.RequestSigning{}
+		if info.ChannelOtherSettings.RequestSigning != nil {
+			signing = *info.ChannelOtherSettings.RequestSigning
+		}
+		signing.Type = dto.RequestSigningTypeAwsSigV4
+		signing.AwsService = "bedrock"
+		signing.AwsRegion = a.key.region
+		signing.AwsAccessKeyId = a.key.accessKeyId
+		signing.AwsSecretAccessKey = a.key.secretAccessKey
+		signing.AwsSessionToken = a.key.sessionToken
+		info.ChannelOtherSettings.RequestSigning = &signing
+	}
+	return channel.DoApiRequest(a, c, info, requestBody)
+}
+
+func (a *Adaptor) DoResponse(c *gin.Context, resp *http.Response, info *relaycommon.RelayInfo) (usage any, err *types.NewAPIError) {
+	if info.IsStream {
+		usage, err = converseStreamHandler(c, info, resp)
+	} else {
+		usage, err = converseHandler(c, info, resp)
+	}
+	return
+}
+
+func (a *Adaptor) GetModelList() []string {
+	return ModelList
+}
+
+func (a *Adaptor) GetChannelName() string {
+	return ChannelName
+}
+
+// bedrockKey 渠道密钥，AK/SK 模式为 ak|sk|region 或 ak|sk|region|session_token，API Key 模式为 api_key|region
+type bedrockKey struct {
+	accessKeyId     string
+	secretAccessKey string
+	sessionToken    string
+	apiKey          string
+	region          string
+}
+
+func parseBedrockKey(key string, keyType dto.AwsKeyType) (bedrockKey, error) {
+	parts := strings.Split(strings.TrimSpace(key), "|")
+	if keyType == dto.AwsKeyTypeApiKey {
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			return bedrockKey{}, errors.New("invalid bedrock api key, should be in format of <api-key>|<region>")
+		}
+		return bedrockKey{apiKey: parts[0], region: parts[1]}, nil
+	}
+	if len(parts) < 3 || len(parts) > 4 || parts[0] == "" || parts[1] == "" || parts[2] == "" {
+		return bedrockKey{}, errors.New("invalid bedrock key, should be in format of <ak>|<sk>|<region> or <ak>|<sk>|<region>|<session-token>")
+	}
+	parsed := bedrockKey{accessKeyId: parts[0], secretAccessKey: parts[1], region: parts[2]}
+	if len(parts) == 4 {
+		parsed.sessionToken = parts[3]
+	}
+	return parsed, nil
+}
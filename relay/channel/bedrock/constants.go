@@ -0,0 +1,65 @@
+package bedrock
+
+import "strings"
+
+// bedrockModelIDMap 常用模型名到 Bedrock 模型 ID 的映射，未命中时直接使用请求的模型名（可为模型 ID、推理配置文件 ID 或 ARN）
+var bedrockModelIDMap = map[string]string{
+	"claude-3-5-haiku-20241022":  "anthropic.claude-3-5-haiku-20241022-v1:0",
+	"claude-3-7-sonnet-20250219": "anthropic.claude-3-7-sonnet-20250219-v1:0",
+	"claude-sonnet-4-20250514":   "anthropic.claude-sonnet-4-20250514-v1:0",
+	"claude-opus-4-1-20250805":   "anthropic.claude-opus-4-1-20250805-v1:0",
+	"claude-sonnet-4-5-20250929": "anthropic.claude-sonnet-4-5-20250929-v1:0",
+	"claude-haiku-4-5-20251001":  "anthropic.claude-haiku-4-5-20251001-v1:0",
+	"nova-micro-v1:0":            "amazon.nova-micro-v1:0",
+	"nova-lite-v1:0":             "amazon.nova-lite-v1:0",
+	"nova-pro-v1:0":              "amazon.nova-pro-v1:0",
+	"nova-premier-v1:0":          "amazon.nova-premier-v1:0",
+	"llama3-3-70b-instruct":      "meta.llama3-3-70b-instruct-v1:0",
+	"llama4-maverick-17b":        "meta.llama4-maverick-17b-instruct-v1:0",
+	"mistral-large-2407":         "mistral.mistral-large-2407-v1:0",
+	"deepseek-r1":                "deepseek.r1-v1:0",
+	"command-r-plus":             "cohere.command-r-plus-v1:0",
+}
+
+// bedrockInferenceProfileModels 只能通过跨区域推理配置文件调用的模型，请求时按渠道区域加上 us / eu / apac 前缀
+var bedrockInferenceProfileModels = map[string]bool{
+	"anthropic.claude-sonnet-4-20250514-v1:0":   true,
+	"anthropic.claude-opus-4-1-20250805-v1:0":   true,
+	"anthropic.claude-sonnet-4-5-20250929-v1:0": true,
+	"anthropic.claude-haiku-4-5-20251001-v1:0":  true,
+	"amazon.nova-premier-v1:0":                  true,
+	"meta.llama3-3-70b-instruct-v1:0":           true,
+	"meta.llama4-maverick-17b-instruct-v1:0":    true,
+	"deepseek.r1-v1:0":                          true,
+}
+
+var bedrockRegionProfilePrefix = map[string]string{
+	"us": "us",
+	"eu": "eu",
+	"ap": "apac",
+}
+
+var ModelList = func() []string {
+	models := make([]string, 0, len(bedrockModelIDMap))
+	for name := range bedrockModelIDMap {
+		models = append(models, name)
+	}
+	return models
+}()
+
+var ChannelName = "bedrock"
+
+// getBedrockModelID 返回请求使用的 Bedrock 模型 ID，需要推理配置文件的模型按区域加前缀
+func getBedrockModelID(requestModel string, region string) string {
+	modelID, ok := bedrockModelIDMap[requestModel]
+	if !ok {
+		modelID = requestModel
+	}
+	if !bedrockInferenceProfileModels[modelID] {
+		return modelID
+	}
+	if prefix, ok := bedrockRegionProfilePrefix[strings.Split(region, "-")[0]]; ok {
+		return prefix + "." + modelID
+	}
+	return modelID
+}
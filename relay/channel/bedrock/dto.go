@@ -0,0 +1,143 @@
+package bedrock
+
+import "encoding/json"
+
+// ConverseRequest Bedrock Converse / ConverseStream 请求体，模型 ID 在路径中
+type ConverseRequest struct {
+	Messages        []ConverseMessage        `json:"messages"`
+	System          []ConverseSystemBlock    `json:"system,omitempty"`
+	InferenceConfig *ConverseInferenceConfig `json:"inferenceConfig,omitempty"`
+	ToolConfig      *ConverseToolConfig      `json:"toolConfig,omitempty"`
+}
+
+type ConverseMessage struct {
+	Role    string                 `json:"role"`
+	Content []ConverseContentBlock `json:"content"`
+}
+
+type ConverseSystemBlock struct {
+	Text string `json:"text"`
+}
+
+// ConverseContentBlock 内容块，每个块只设置其中一个字段
+type ConverseContentBlock struct {
+	Text             *string                   `json:"text,omitempty"`
+	Image            *ConverseImageBlock       `json:"image,omitempty"`
+	ToolUse          *ConverseToolUseBlock     `json:"toolUse,omitempty"`
+	ToolResult       *ConverseToolResultBlock  `json:"toolResult,omitempty"`
+	ReasoningContent *ConverseReasoningContent `json:"reasoningContent,omitempty"`
+}
+
+type ConverseImageBlock struct {
+	Format string              `json:"format"` // png / jpeg / gif / webp
+	Source ConverseImageSource `json:"source"`
+}
+
+type ConverseImageSource struct {
+	Bytes string `json:"bytes"` // base64
+}
+
+type ConverseToolUseBlock struct {
+	ToolUseId string          `json:"toolUseId"`
+	Name      string          `json:"name"`
+	Input     json.RawMessage `json:"input"`
+}
+
+type ConverseToolResultBlock struct {
+	ToolUseId string                      `json:"toolUseId"`
+	Content   []ConverseToolResultContent `json:"content"`
+}
+
+type ConverseToolResultContent struct {
+	Text string `json:"text"`
+}
+
+type ConverseReasoningContent struct {
+	ReasoningText *ConverseReasoningText `json:"reasoningText,omitempty"`
+}
+
+type ConverseReasoningText struct {
+	Text      string `json:"text"`
+	Signature string `json:"signature,omitempty"`
+}
+
+type ConverseInferenceConfig struct {
+	MaxTokens     *uint    `json:"maxTokens,omitempty"`
+	Temperature   *float64 `json:"temperature,omitempty"`
+	TopP          *float64 `json:"topP,omitempty"`
+	StopSequences []string `json:"stopSequences,omitempty"`
+}
+
+type ConverseToolConfig struct {
+	Tools      []ConverseTool      `json:"tools"`
+	ToolChoice *ConverseToolChoice `json:"toolChoice,omitempty"`
+}
+
+type ConverseTool struct {
+	ToolSpec ConverseToolSpec `json:"toolSpec"`
+}
+
+type ConverseToolSpec struct {
+	Name        string              `json:"name"`
+	Description string              `json:"description,omitempty"`
+	InputSchema ConverseInputSchema `json:"inputSchema"`
+}
+
+type ConverseInputSchema struct {
+	Json any `json:"json"`
+}
+
+// ConverseToolChoice auto / any / tool 三选一
+type ConverseToolChoice struct {
+	Auto *struct{}             `json:"auto,omitempty"`
+	Any  *struct{}             `json:"any,omitempty"`
+	Tool *ConverseSpecificTool `json:"tool,omitempty"`
+}
+
+type ConverseSpecificTool struct {
+	Name string `json:"name"`
+}
+
+type ConverseResponse struct {
+	Output struct {
+		Message ConverseMessage `json:"message"`
+	} `json:"output"`
+	StopReason string        `json:"stopReason"`
+	Usage      ConverseUsage `json:"usage"`
+}
+
+type ConverseUsage struct {
+	InputTokens           int `json:"inputTokens"`
+	OutputTokens          int `json:"outputTokens"`
+	TotalTokens           int `json:"totalTokens"`
+	CacheReadInputTokens  int `json:"cacheReadInputTokens"`
+	CacheWriteInputTokens int `json:"cacheWriteInputTokens"`
+}
+
+// ConverseStreamEvent ConverseStream 事件的载荷，事件类型在 eventstream 的 :event-type 头中
+type ConverseStreamEvent struct {
+	ContentBlockIndex int                       `json:"contentBlockIndex"`
+	Start             *ConverseStreamBlockStart `json:"start,omitempty"`
+	Delta             *ConverseStreamBlockDelta `json:"delta,omitempty"`
+	StopReason        string                    `json:"stopReason,omitempty"`
+	Usage             *ConverseUsage            `json:"usage,omitempty"`
+	Message           string                    `json:"message,omitempty"` // 异常事件的错误信息
+}
+
+type ConverseStreamBlockStart struct {
+	ToolUse *struct {
+		ToolUseId string `json:"toolUseId"`
+		Name      string `json:"name"`
+	} `json:"toolUse,omitempty"`
+}
+
+type ConverseStreamBlockDelta struct {
+	Text    *string `json:"text,omitempty"`
+	ToolUse *struct {
+		Input string `json:"input"`
+	} `json:"toolUse,omitempty"`
+	ReasoningContent *struct {
+		Text      string `json:"text,omitempty"`
+		Signature string `json:"signature,omitempty"`
+	} `json:"reasoningContent,omitempty"`
+}
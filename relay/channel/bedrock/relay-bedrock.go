@@ -0,0 +1,483 @@
+package bedrock
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/dto"
+	"github.com/QuantumNous/new-api/logger"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	"github.com/QuantumNous/new-api/relay/helper"
+	"github.com/QuantumNous/new-api/service"
+	"github.com/QuantumNous/new-api/types"
+
+	"github.com/aws/aws-sdk-go-v2/aws/protocol/eventstream"
+	"github.com/gin-gonic/gin"
+)
+
+// requestOpenAI2Converse 将 OpenAI Chat Completions 请求转换为 Converse 请求。
+// system / developer 消息放入 system，tool 消息转换为 user 角色的 toolResult，相邻的同角色消息合并为一条
+func requestOpenAI2Converse(c *gin.Context, request *dto.GeneralOpenAIRequest) (*ConverseRequest, error) {
+	converseRequest := &ConverseRequest{
+		Messages: make([]ConverseMessage, 0, len(request.Messages)),
+	}
+	for _, message := range request.Messages {
+		switch message.Role {
+		case "system", "developer":
+			if text := message.StringContent(); text != "" {
+				converseRequest.System = append(converseRequest.System, ConverseSystemBlock{Text: text})
+			}
+			continue
+		case "tool":
+			result := message.StringContent()
+			converseRequest.Messages = appendConverseMessage(converseRequest.Messages, "user", ConverseContentBlock{
+				ToolResult: &ConverseToolResultBlock{
+					ToolUseId: message.ToolCallId,
+					Content:   []ConverseToolResultContent{{Text: result}},
+				},
+			})
+			continue
+		}
+
+		role := "user"
+		if message.Role == "assistant" {
+			role = "assistant"
+		}
+		blocks, err := converseContentBlocks(c, &message)
+		if err != nil {
+			return nil, err
+		}
+		if role == "assistant" {
+			for _, toolCall := range message.ParseToolCalls() {
+				input := json.RawMessage(toolCall.Function.Arguments)
+				if !common.ValidJson(input) {
+					input = json.RawMessage("{}")
+				}
+				blocks = append(blocks, ConverseContentBlock{
+					ToolUse: &ConverseToolUseBlock{
+						ToolUseId: toolCall.ID,
+						Name:      toolCall.Function.Name,
+						Input:     input,
+					},
+				})
+			}
+		}
+		if len(blocks) == 0 {
+			continue
+		}
+		converseRequest.Messages = appendConverseMessage(converseRequest.Messages, role, blocks...)
+	}
+
+	inferenceConfig := &ConverseInferenceConfig{
+		Temperature:   request.Temperature,
+		TopP:          request.TopP,
+		StopSequences: parseStopSequences(request.Stop),
+	}
+	if request.MaxCompletionTokens != nil && *request.MaxCompletionTokens > 0 {
+		inferenceConfig.MaxTokens = request.MaxCompletionTokens
+	} else if request.MaxTokens != nil && *request.MaxTokens > 0 {
+		inferenceConfig.MaxTokens = request.MaxTokens
+	}
+	if inferenceConfig.MaxTokens != nil || inferenceConfig.Temperature != nil || inferenceConfig.TopP != nil || len(inferenceConfig.StopSequences) > 0 {
+		converseRequest.InferenceConfig = inferenceConfig
+	}
+
+	if len(request.Tools) > 0 {
+		toolConfig := &ConverseToolConfig{
+			Tools: make([]ConverseTool, 0, len(request.Tools)),
+		}
+		for _, tool := range request.Tools {
+			if tool.Type != "" && tool.Type != "function" {
+				continue
+			}
+			parameters := tool.Function.Parameters
+			if parameters == nil {
+				parameters = map[string]any{"type": "object", "properties": map[string]any{}}
+			}
+			toolConfig.Tools = append(toolConfig.Tools, ConverseTool{
+				ToolSpec: ConverseToolSpec{
+					Name:        tool.Function.Name,
+					Description: tool.Function.Description,
+					InputSchema: ConverseInputSchema{Json: parameters},
+				},
+			})
+		}
+		if len(toolConfig.Tools) > 0 {
+			toolConfig.ToolChoice = mapToolChoice(request.ToolChoice)
+			converseRequest.ToolConfig = toolConfig
+		}
+	}
+	return converseRequest, nil
+}
+
+// converseContentBlocks 转换消息的文本与图片内容
+func converseContentBlocks(c *gin.Context, message *dto.Message) ([]ConverseContentBlock, error) {
+	blocks := make([]ConverseContentBlock, 0)
+	if message.IsStringContent() {
+		if text := message.StringContent(); text != "" {
+			blocks = append(blocks, ConverseContentBlock{Text: &text})
+		}
+		return blocks, nil
+	}
+	for _, part := range message.ParseContent() {
+		switch part.Type {
+		case dto.ContentTypeText:
+			if part.Text == "" {
+				continue
+			}
+			text := part.Text
+			blocks = append(blocks, ConverseContentBlock{Text: &text})
+		case dto.ContentTypeImageURL:
+			imageMedia := part.GetImageMedia()
+			if imageMedia == nil {
+				continue
+			}
+			var source *types.FileSource
+			if strings.HasPrefix(imageMedia.Url, "http") {
+				source = types.NewURLFileSource(imageMedia.Url)
+			} else {
+				source = types.NewBase64FileSource(imageMedia.Url, "")
+			}
+			base64Data, mimeType, err := service.GetBase64Data(c, source, "formatting image for Bedrock")
+			if err != nil {
+				return nil, fmt.Errorf("get file data failed: %s", err.Error())
+			}
+			blocks = append(blocks, ConverseContentBlock{
+				Image: &ConverseImageBlock{
+					Format: converseImageFormat(mimeType),
+					Source: ConverseImageSource{Bytes: base64Data},
+				},
+			})
+		}
+	}
+	return blocks, nil
+}
+
+// appendConverseMessage Converse 要求 user 与 assistant 交替出现，相邻的同角色内容合并到上一条消息
+func appendConverseMessage(messages []ConverseMessage, role string, blocks ...ConverseContentBlock) []ConverseMessage {
+	if n := len(messages); n > 0 && messages[n-1].Role == role {
+		messages[n-1].Content = append(messages[n-1].Content, blocks...)
+		return messages
+	}
+	return append(messages, ConverseMessage{Role: role, Content: blocks})
+}
+
+func converseImageFormat(mimeType string) string {
+	format := strings.TrimPrefix(strings.ToLower(mimeType), "image/")
+	if format == "jpg" {
+		return "jpeg"
+	}
+	return format
+}
+
+func parseStopSequences(stop any) []string {
+	switch v := stop.(type) {
+	case string:
+		if v != "" {
+			return []string{v}
+		}
+	case []string:
+		return v
+	case []any:
+		stopSequences := make([]string, 0, len(v))
+		for _, item := range v {
+			if s, ok := item.(string); ok && s != "" {
+				stopSequences = append(stopSequences, s)
+			}
+		}
+		return stopSequences
+	}
+	return nil
+}
+
+// mapToolChoice auto 对应 auto，required 对应 any，指定函数对应 tool；Converse 没有 none，按 auto 处理
+func mapToolChoice(toolChoice any) *ConverseToolChoice {
+	switch v := toolChoice.(type) {
+	case string:
+		switch v {
+		case "auto":
+			return &ConverseToolChoice{Auto: &struct{}{}}
+		case "required":
+			return &ConverseToolChoice{Any: &struct{}{}}
+		}
+	case map[string]any:
+		if function, ok := v["function"].(map[string]any); ok {
+			if name, _ := function["name"].(string); name != "" {
+				return &ConverseToolChoice{Tool: &ConverseSpecificTool{Name: name}}
+			}
+		}
+		switch v["type"] {
+		case "auto":
+			return &ConverseToolChoice{Auto: &struct{}{}}
+		case "required", "any":
+			return &ConverseToolChoice{Any: &struct{}{}}
+		}
+	}
+	return nil
+}
+
+func stopReasonConverse2OpenAI(reason string) string {
+	switch reason {
+	case "end_turn", "stop_sequence":
+		return "stop"
+	case "max_tokens", "model_context_window_exceeded":
+		return "length"
+	case "tool_use":
+		return "tool_calls"
+	case "content_filtered", "guardrail_intervened":
+		return "content_filter"
+	default:
+		return reason
+	}
+}
+
+func usageConverse2OpenAI(usage ConverseUsage) dto.Usage {
+	openaiUsage := dto.Usage{
+		PromptTokens:     usage.InputTokens + usage.CacheReadInputTokens + usage.CacheWriteInputTokens,
+		CompletionTokens: usage.OutputTokens,
+	}
+	openaiUsage.PromptTokensDetails.CachedTokens = usage.CacheReadInputTokens
+	openaiUsage.PromptTokensDetails.CachedCreationTokens = usage.CacheWriteInputTokens
+	openaiUsage.TotalTokens = openaiUsage.PromptTokens + openaiUsage.CompletionTokens
+	return openaiUsage
+}
+
+// responseConverse2OpenAI 将 Converse 响应转换为 OpenAI Chat Completions 响应
+func responseConverse2OpenAI(converseResp *ConverseResponse, id string, model string) *dto.OpenAITextResponse {
+	message := dto.Message{Role: "assistant"}
+	var text strings.Builder
+	var reasoning strings.Builder
+	toolCalls := make([]dto.ToolCallResponse, 0)
+	for _, block := range converseResp.Output.Message.Content {
+		switch {
+		case block.Text != nil:
+			text.WriteString(*block.Text)
+		case block.ReasoningContent != nil && block.ReasoningContent.ReasoningText != nil:
+			reasoning.WriteString(block.ReasoningContent.ReasoningText.Text)
+		case block.ToolUse != nil:
+			arguments := string(block.ToolUse.Input)
+			if arguments == "" {
+				arguments = "{}"
+			}
+			toolCalls = append(toolCalls, dto.ToolCallResponse{
+				ID:   block.ToolUse.ToolUseId,
+				Type: "function",
+				Function: dto.FunctionResponse{
+					Name:      block.ToolUse.Name,
+					Arguments: arguments,
+				},
+			})
+		}
+	}
+	message.SetStringContent(text.String())
+	message.ReasoningContent = reasoning.String()
+	if len(toolCalls) > 0 {
+		message.SetToolCalls(toolCalls)
+	}
+	return &dto.OpenAITextResponse{
+		Id:      id,
+		Model:   model,
+		Object:  "chat.completion",
+		Created: common.GetTimestamp(),
+		Choices: []dto.OpenAITextResponseChoice{
+			{
+				Index:        0,
+				Message:      message,
+				FinishReason: stopReasonConverse2OpenAI(converseResp.StopReason),
+			},
+		},
+		Usage: usageConverse2OpenAI(converseResp.Usage),
+	}
+}
+
+func converseHandler(c *gin.Context, info *relaycommon.RelayInfo, resp *http.Response) (*dto.Usage, *types.NewAPIError) {
+	responseBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, types.NewOpenAIError(err, types.ErrorCodeReadResponseBodyFailed, http.StatusInternalServerError)
+	}
+	service.CloseResponseBodyGracefully(resp)
+	var converseResp ConverseResponse
+	if err = common.Unmarshal(responseBody, &converseResp); err != nil {
+		return nil, types.NewOpenAIError(err, types.ErrorCodeBadResponseBody, http.StatusInternalServerError)
+	}
+	openaiResp := responseConverse2OpenAI(&converseResp, helper.GetResponseID(c), info.UpstreamModelName)
+	jsonResponse, err := common.Marshal(openaiResp)
+	if err != nil {
+		return nil, types.NewOpenAIError(err, types.ErrorCodeBadResponseBody, http.StatusInternalServerError)
+	}
+	service.IOCopyBytesGracefully(c, resp, jsonResponse)
+	return &openaiResp.Usage, nil
+}
+
+// converseStreamConverter 将 ConverseStream 事件逐个转换为 OpenAI 流式块
+type converseStreamConverter struct {
+	id      string
+	created int64
+	model   string
+
+	// toolCallIndex 内容块序号到 tool_calls 序号的映射
+	toolCallIndex map[int]int
+	responseText  strings.Builder
+	usage         *dto.Usage
+}
+
+func newConverseStreamConverter(id string, model string) *converseStreamConverter {
+	return &converseStreamConverter{
+		id:            id,
+		created:       common.GetTimestamp(),
+		model:         model,
+		toolCallIndex: make(map[int]int),
+	}
+}
+
+func (s *converseStreamConverter) chunk(delta dto.ChatCompletionsStreamResponseChoiceDelta, finishReason *string) *dto.ChatCompletionsStreamResponse {
+	return &dto.ChatCompletionsStreamResponse{
+		Id:      s.id,
+		Object:  "chat.completion.chunk",
+		Created: s.created,
+		Model:   s.model,
+		Choices: []dto.ChatCompletionsStreamResponseChoice{
+			{Delta: delta, FinishReason: finishReason},
+		},
+	}
+}
+
+// handleEvent 处理一个事件，返回需要发送的流式块，没有可发送内容时返回 nil。metadata 事件只记录用量
+func (s *converseStreamConverter) handleEvent(eventType string, payload []byte) (*dto.ChatCompletionsStreamResponse, error) {
+	var event ConverseStreamEvent
+	if err := common.Unmarshal(payload, &event); err != nil {
+		return nil, err
+	}
+	switch eventType {
+	case "messageStart":
+		return s.chunk(dto.ChatCompletionsStreamResponseChoiceDelta{Role: "assistant"}, nil), nil
+	case "contentBlockStart":
+		if event.Start == nil || event.Start.ToolUse == nil {
+			return nil, nil
+		}
+		index := len(s.toolCallIndex)
+		s.toolCallIndex[event.ContentBlockIndex] = index
+		toolCall := dto.ToolCallResponse{
+			ID:   event.Start.ToolUse.ToolUseId,
+			Type: "function",
+			Function: dto.FunctionResponse{
+				Name: event.Start.ToolUse.Name,
+			},
+		}
+		toolCall.SetIndex(index)
+		return s.chunk(dto.ChatCompletionsStreamResponseChoiceDelta{ToolCalls: []dto.ToolCallResponse{toolCall}}, nil), nil
+	case "contentBlockDelta":
+		if event.Delta == nil {
+			return nil, nil
+		}
+		delta := dto.ChatCompletionsStreamResponseChoiceDelta{}
+		switch {
+		case event.Delta.Text != nil:
+			s.responseText.WriteString(*event.Delta.Text)
+			delta.SetContentString(*event.Delta.Text)
+		case event.Delta.ToolUse != nil:
+			s.responseText.WriteString(event.Delta.ToolUse.Input)
+			toolCall := dto.ToolCallResponse{
+				Function: dto.FunctionResponse{Arguments: event.Delta.ToolUse.Input},
+			}
+			toolCall.SetIndex(s.toolCallIndex[event.ContentBlockIndex])
+			delta.ToolCalls = []dto.ToolCallResponse{toolCall}
+		case event.Delta.ReasoningContent != nil && event.Delta.ReasoningContent.Text != "":
+			s.responseText.WriteString(event.Delta.ReasoningContent.Text)
+			delta.SetReasoningContent(event.Delta.ReasoningContent.Text)
+		default:
+			return nil, nil
+		}
+		return s.chunk(delta, nil), nil
+	case "messageStop":
+		finishReason := stopReasonConverse2OpenAI(event.StopReason)
+		return s.chunk(dto.ChatCompletionsStreamResponseChoiceDelta{}, &finishReason), nil
+	case "metadata":
+		if event.Usage != nil {
+			usage := usageConverse2OpenAI(*event.Usage)
+			s.usage = &usage
+		}
+		return nil, nil
+	default:
+		return nil, nil
+	}
+}
+
+func converseStreamHandler(c *gin.Context, info *relaycommon.RelayInfo, resp *http.Response) (*dto.Usage, *types.NewAPIError) {
+	defer service.CloseResponseBodyGracefully(resp)
+
+	converter := newConverseStreamConverter(helper.GetResponseID(c), info.UpstreamModelName)
+	decoder := eventstream.NewDecoder()
+	payloadBuf := make([]byte, 10*1024)
+	for {
+		message, err := decoder.Decode(resp.Body, payloadBuf)
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return nil, types.NewOpenAIError(err, types.ErrorCodeBadResponseBody, http.StatusInternalServerError)
+		}
+		switch headerString(message.Headers, ":message-type") {
+		case "exception":
+			return nil, converseStreamError(headerString(message.Headers, ":exception-type"), message.Payload)
+		case "error":
+			return nil, types.NewOpenAIError(fmt.Errorf("%s: %s", headerString(message.Headers, ":error-code"), headerString(message.Headers, ":error-message")),
+				types.ErrorCodeBadResponse, http.StatusInternalServerError)
+		}
+		response, err := converter.handleEvent(headerString(message.Headers, ":event-type"), message.Payload)
+		if err != nil {
+			logger.LogError(c, "error handling bedrock stream event: "+err.Error())
+			continue
+		}
+		if response == nil {
+			continue
+		}
+		info.SetFirstResponseTime()
+		if err = helper.ObjectData(c, response); err != nil {
+			logger.LogError(c, "error sending bedrock stream response: "+err.Error())
+		}
+	}
+
+	usage := converter.usage
+	if usage == nil {
+		usage = service.ResponseText2Usage(c, converter.responseText.String(), info.UpstreamModelName, info.GetEstimatePromptTokens())
+	}
+	if info.ShouldIncludeUsage {
+		_ = helper.ObjectData(c, helper.GenerateFinalUsageResponse(converter.id, converter.created, info.UpstreamModelName, *usage))
+	}
+	helper.Done(c)
+	return usage, nil
+}
+
+func headerString(headers eventstream.Headers, name string) string {
+	value := headers.Get(name)
+	if value == nil {
+		return ""
+	}
+	return value.String()
+}
+
+// converseStreamError 将流中的异常事件转换为错误，限流与过载按 429 / 503 返回以便重试
+func converseStreamError(exceptionType string, payload []byte) *types.NewAPIError {
+	var event ConverseStreamEvent
+	_ = common.Unmarshal(payload, &event)
+	message := event.Message
+	if message == "" {
+		message = string(payload)
+	}
+	statusCode := http.StatusInternalServerError
+	switch exceptionType {
+	case "throttlingException":
+		statusCode = http.StatusTooManyRequests
+	case "serviceUnavailableException", "modelStreamErrorException":
+		statusCode = http.StatusServiceUnavailable
+	case "validationException":
+		statusCode = http.StatusBadRequest
+	}
+	return types.NewOpenAIError(fmt.Errorf("%s: %s", exceptionType, message), types.ErrorCodeBadResponse, statusCode)
+}
@@ -0,0 +1,193 @@
+package bedrock
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/dto"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	"github.com/aws/aws-sdk-go-v2/aws/protocol/eventstream"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseBedrockKey(t *testing.T) {
+	key, err := parseBedrockKey("ak|sk|us-west-2", dto.AwsKeyTypeAKSK)
+	require.NoError(t, err)
+	require.Equal(t, bedrockKey{accessKeyId: "ak", secretAccessKey: "sk", region: "us-west-2"}, key)
+
+	key, err = parseBedrockKey("ak|sk|eu-central-1|token", "")
+	require.NoError(t, err)
+	require.Equal(t, "token", key.sessionToken)
+
+	key, err = parseBedrockKey("api-key|us-east-1", dto.AwsKeyTypeApiKey)
+	require.NoError(t, err)
+	require.Equal(t, bedrockKey{apiKey: "api-key", region: "us-east-1"}, key)
+
+	_, err = parseBedrockKey("ak|sk", dto.AwsKeyTypeAKSK)
+	require.Error(t, err)
+	_, err = parseBedrockKey("ak|sk|us-east-1", dto.AwsKeyTypeApiKey)
+	require.Error(t, err)
+}
+
+func TestGetBedrockModelID(t *testing.T) {
+	require.Equal(t, "anthropic.claude-3-5-haiku-20241022-v1:0", getBedrockModelID("claude-3-5-haiku-20241022", "us-east-1"))
+	require.Equal(t, "us.anthropic.claude-sonnet-4-20250514-v1:0", getBedrockModelID("claude-sonnet-4-20250514", "us-west-2"))
+	require.Equal(t, "eu.anthropic.claude-sonnet-4-20250514-v1:0", getBedrockModelID("claude-sonnet-4-20250514", "eu-west-1"))
+	require.Equal(t, "apac.amazon.nova-premier-v1:0", getBedrockModelID("nova-premier-v1:0", "ap-northeast-1"))
+	require.Equal(t, "global.anthropic.claude-sonnet-4-20250514-v1:0", getBedrockModelID("global.anthropic.claude-sonnet-4-20250514-v1:0", "us-east-1"))
+}
+
+func TestGetRequestURL(t *testing.T) {
+	adaptor := &Adaptor{}
+	info := &relaycommon.RelayInfo{
+		IsStream: true,
+		ChannelMeta: &relaycommon.ChannelMeta{
+			ApiKey:            "ak|sk|us-east-1",
+			UpstreamModelName: "claude-3-5-haiku-20241022",
+		},
+	}
+	requestURL, err := adaptor.GetRequestURL(info)
+	require.NoError(t, err)
+	require.Equal(t, "https://bedrock-runtime.us-east-1.amazonaws.com/model/anthropic.claude-3-5-haiku-20241022-v1:0/converse-stream", requestURL)
+}
+
+func TestRequestOpenAI2Converse(t *testing.T) {
+	var request dto.GeneralOpenAIRequest
+	require.NoError(t, common.Unmarshal([]byte(`{
+		"model": "claude-3-5-haiku-20241022",
+		"max_tokens": 256,
+		"temperature": 0.5,
+		"stop": ["END"],
+		"messages": [
+			{"role": "system", "content": "be brief"},
+			{"role": "user", "content": "weather in Paris?"},
+			{"role": "assistant", "content": null, "tool_calls": [{"id": "call_1", "type": "function", "function": {"name": "get_weather", "arguments": "{\"city\":\"Paris\"}"}}]},
+			{"role": "tool", "tool_call_id": "call_1", "content": "sunny"},
+			{"role": "user", "content": "thanks"}
+		],
+		"tools": [{"type": "function", "function": {"name": "get_weather", "parameters": {"type": "object"}}}],
+		"tool_choice": {"type": "function", "function": {"name": "get_weather"}}
+	}`), &request))
+
+	converseRequest, err := requestOpenAI2Converse(nil, &request)
+	require.NoError(t, err)
+	require.Equal(t, []ConverseSystemBlock{{Text: "be brief"}}, converseRequest.System)
+	require.Len(t, converseRequest.Messages, 3)
+
+	require.Equal(t, "assistant", converseRequest.Messages[1].Role)
+	toolUse := converseRequest.Messages[1].Content[0].ToolUse
+	require.NotNil(t, toolUse)
+	require.Equal(t, "call_1", toolUse.ToolUseId)
+	require.JSONEq(t, `{"city":"Paris"}`, string(toolUse.Input))
+
+	// 工具结果与随后的用户消息合并为一条 user 消息
+	require.Equal(t, "user", converseRequest.Messages[2].Role)
+	require.Len(t, converseRequest.Messages[2].Content, 2)
+	require.Equal(t, "call_1", converseRequest.Messages[2].Content[0].ToolResult.ToolUseId)
+	require.Equal(t, "thanks", *converseRequest.Messages[2].Content[1].Text)
+
+	require.Equal(t, uint(256), *converseRequest.InferenceConfig.MaxTokens)
+	require.Equal(t, []string{"END"}, converseRequest.InferenceConfig.StopSequences)
+	require.Equal(t, "get_weather", converseRequest.ToolConfig.ToolChoice.Tool.Name)
+}
+
+func TestResponseConverse2OpenAI(t *testing.T) {
+	var converseResp ConverseResponse
+	require.NoError(t, common.Unmarshal([]byte(`{
+		"output": {"message": {"role": "assistant", "content": [
+			{"reasoningContent": {"reasoningText": {"text": "thinking"}}},
+			{"text": "calling tool"},
+			{"toolUse": {"toolUseId": "tool_1", "name": "get_weather", "input": {"city": "Paris"}}}
+		]}},
+		"stopReason": "tool_use",
+		"usage": {"inputTokens": 10, "outputTokens": 5, "totalTokens": 15, "cacheReadInputTokens": 4}
+	}`), &converseResp))
+
+	openaiResp := responseConverse2OpenAI(&converseResp, "chatcmpl-1", "claude")
+	choice := openaiResp.Choices[0]
+	require.Equal(t, "tool_calls", choice.FinishReason)
+	require.Equal(t, "calling tool", choice.Message.StringContent())
+	require.Equal(t, "thinking", choice.Message.ReasoningContent)
+	toolCalls := choice.Message.ParseToolCalls()
+	require.Len(t, toolCalls, 1)
+	require.Equal(t, "get_weather", toolCalls[0].Function.Name)
+	require.JSONEq(t, `{"city":"Paris"}`, toolCalls[0].Function.Arguments)
+	require.Equal(t, 14, openaiResp.Usage.PromptTokens)
+	require.Equal(t, 4, openaiResp.Usage.PromptTokensDetails.CachedTokens)
+	require.Equal(t, 19, openaiResp.Usage.TotalTokens)
+}
+
+func encodeConverseEvents(t *testing.T, events [][2]string) io.ReadCloser {
+	var buf bytes.Buffer
+	encoder := eventstream.NewEncoder()
+	for _, event := range events {
+		message := eventstream.Message{Payload: []byte(event[1])}
+		message.Headers.Set(":message-type", eventstream.StringValue("event"))
+		message.Headers.Set(":event-type", eventstream.StringValue(event[0]))
+		require.NoError(t, encoder.Encode(&buf, message))
+	}
+	return io.NopCloser(&buf)
+}
+
+func TestConverseStreamHandler(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	recorder := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(recorder)
+	c.Request = httptest.NewRequest(http.MethodPost, "/v1/chat/completions", nil)
+
+	resp := &http.Response{
+		StatusCode: http.StatusOK,
+		Body: encodeConverseEvents(t, [][2]string{
+			{"messageStart", `{"role":"assistant"}`},
+			{"contentBlockDelta", `{"contentBlockIndex":0,"delta":{"text":"Hello"}}`},
+			{"contentBlockStart", `{"contentBlockIndex":1,"start":{"toolUse":{"toolUseId":"tool_1","name":"get_weather"}}}`},
+			{"contentBlockDelta", `{"contentBlockIndex":1,"delta":{"toolUse":{"input":"{\"city\":"}}}`},
+			{"messageStop", `{"stopReason":"tool_use"}`},
+			{"metadata", `{"usage":{"inputTokens":12,"outputTokens":7,"totalTokens":19}}`},
+		}),
+	}
+	info := &relaycommon.RelayInfo{
+		IsStream:           true,
+		ShouldIncludeUsage: true,
+		ChannelMeta:        &relaycommon.ChannelMeta{UpstreamModelName: "claude"},
+	}
+
+	usage, apiErr := converseStreamHandler(c, info, resp)
+	require.Nil(t, apiErr)
+	require.Equal(t, 12, usage.PromptTokens)
+	require.Equal(t, 7, usage.CompletionTokens)
+
+	body := recorder.Body.String()
+	require.Contains(t, body, `"content":"Hello"`)
+	require.Contains(t, body, `"id":"tool_1"`)
+	require.Contains(t, body, `"arguments":"{\"city\":"`)
+	require.Contains(t, body, `"finish_reason":"tool_calls"`)
+	require.Contains(t, body, `"total_tokens":19`)
+	require.True(t, strings.HasSuffix(strings.TrimSpace(body), "data: [DONE]"))
+}
+
+func TestConverseStreamHandlerException(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	recorder := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(recorder)
+	c.Request = httptest.NewRequest(http.MethodPost, "/v1/chat/completions", nil)
+
+	var buf bytes.Buffer
+	message := eventstream.Message{Payload: []byte(`{"message":"Too many requests"}`)}
+	message.Headers.Set(":message-type", eventstream.StringValue("exception"))
+	message.Headers.Set(":exception-type", eventstream.StringValue("throttlingException"))
+	require.NoError(t, eventstream.NewEncoder().Encode(&buf, message))
+
+	resp := &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(&buf)}
+	info := &relaycommon.RelayInfo{IsStream: true, ChannelMeta: &relaycommon.ChannelMeta{UpstreamModelName: "claude"}}
+	_, apiErr := converseStreamHandler(c, info, resp)
+	require.NotNil(t, apiErr)
+	require.Equal(t, http.StatusTooManyRequests, apiErr.StatusCode)
+	require.Contains(t, apiErr.Error(), "Too many requests")
+}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"strings"
 
 	"github.com/QuantumNous/new-api/dto"
 	"github.com/QuantumNous/new-api/relay/channel"
@@ -15,14 +16,19 @@ import (
 	"github.com/QuantumNous/new-api/types"
 
 	"github.com/gin-gonic/gin"
+	"github.com/samber/lo"
 )
 
 type Adaptor struct {
 }
 
-func (a *Adaptor) ConvertGeminiRequest(*gin.Context, *relaycommon.RelayInfo, *dto.GeminiChatRequest) (any, error) {
-	//TODO implement me
-	return nil, errors.New("not implemented")
+func (a *Adaptor) ConvertGeminiRequest(c *gin.Context, info *relaycommon.RelayInfo, request *dto.GeminiChatRequest) (any, error) {
+	// Gemini 请求先转为 OpenAI 格式，再转为 Claude Messages，响应在 DoResponse 中转回 Gemini 格式
+	openaiRequest, err := service.GeminiToOpenAIRequest(request, info)
+	if err != nil {
+		return nil, err
+	}
+	return a.ConvertOpenAIRequest(c, info, openaiRequest)
 }
 
 func (a *Adaptor) ConvertClaudeRequest(c *gin.Context, info *relaycommon.RelayInfo, request *dto.ClaudeRequest) (any, error) {
@@ -53,6 +59,13 @@ func (a *Adaptor) GetRequestURL(info *relaycommon.RelayInfo) (string, error) {
 func CommonClaudeHeadersOperation(c *gin.Context, req *http.Header, info *relaycommon.RelayInfo) {
 	// common headers operation
 	anthropicBeta := c.Request.Header.Get("anthropic-beta")
+	// 由 OpenAI 格式转换而来的计算机操作请求需要开启对应 beta
+	if c.GetBool(computerUseContextKey) && !strings.Contains(anthropicBeta, ComputerUseBeta) {
+		if anthropicBeta != "" {
+			anthropicBeta += ","
+		}
+		anthropicBeta += ComputerUseBeta
+	}
 	if anthropicBeta != "" {
 		req.Set("anthropic-beta", anthropicBeta)
 	}
@@ -91,14 +104,19 @@ func (a *Adaptor) ConvertOpenAIResponsesRequest(c *gin.Context, info *relaycommo
 	// Store original request in context for response conversion
 	c.Set("responses_original_request", &request)
 
+	// web_search and computer use are mapped to the native tools of the channel
+	if apiErr := service.EnforceCompatMode(c, info, service.ResponsesToChatUnsupportedFeatures(&request, "web_search", service.ComputerToolType)); apiErr != nil {
+		return nil, apiErr
+	}
+
 	// Convert Responses request to Chat Completions request
-	chatReq, err := service.ResponsesRequestToChatCompletionsRequest(&request)
+	chatReq, err := service.ResponsesRequestToChatCompletionsRequest(info, &request)
 	if err != nil {
 		return nil, err
 	}
 
 	// Set stream flag
-	info.IsStream = request.Stream
+	info.IsStream = lo.FromPtrOr(request.Stream, false)
 
 	// Convert Chat Completions request to Claude format
 	return a.ConvertOpenAIRequest(c, info, chatReq)
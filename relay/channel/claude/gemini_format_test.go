@@ -0,0 +1,80 @@
+package claude
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/QuantumNous/new-api/dto"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	"github.com/QuantumNous/new-api/types"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandleClaudeResponseData_GeminiFormat(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	recorder := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(recorder)
+	info := &relaycommon.RelayInfo{RelayFormat: types.RelayFormatGemini}
+	claudeInfo := &ClaudeResponseInfo{Usage: &dto.Usage{}}
+
+	data := []byte(`{"id":"msg_1","type":"message","role":"assistant","model":"claude-sonnet-4",
+		"content":[{"type":"text","text":"Hello"}],"stop_reason":"end_turn",
+		"usage":{"input_tokens":12,"output_tokens":3}}`)
+	resp := &http.Response{StatusCode: http.StatusOK, Header: http.Header{}}
+	require.Nil(t, HandleClaudeResponseData(c, info, claudeInfo, resp, data))
+
+	var geminiResp dto.GeminiChatResponse
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &geminiResp))
+	require.Len(t, geminiResp.Candidates, 1)
+	candidate := geminiResp.Candidates[0]
+	assert.Equal(t, "model", candidate.Content.Role)
+	require.Len(t, candidate.Content.Parts, 1)
+	assert.Equal(t, "Hello", candidate.Content.Parts[0].Text)
+	require.NotNil(t, candidate.FinishReason)
+	assert.Equal(t, "STOP", *candidate.FinishReason)
+	assert.Equal(t, 12, geminiResp.UsageMetadata.PromptTokenCount)
+	assert.Equal(t, 3, geminiResp.UsageMetadata.CandidatesTokenCount)
+}
+
+func TestHandleStreamResponseData_GeminiFormat(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	recorder := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(recorder)
+	info := &relaycommon.RelayInfo{RelayFormat: types.RelayFormatGemini}
+	claudeInfo := &ClaudeResponseInfo{Usage: &dto.Usage{}}
+
+	events := []string{
+		`{"type":"message_start","message":{"id":"msg_1","model":"claude-sonnet-4","usage":{"input_tokens":12,"output_tokens":1}}}`,
+		`{"type":"content_block_start","index":0,"content_block":{"type":"text","text":""}}`,
+		`{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"Hi"}}`,
+		`{"type":"content_block_stop","index":0}`,
+		`{"type":"message_delta","delta":{"stop_reason":"end_turn"},"usage":{"output_tokens":4}}`,
+		`{"type":"message_stop"}`,
+	}
+	for _, event := range events {
+		require.Nil(t, HandleStreamResponseData(c, info, claudeInfo, event))
+	}
+
+	var chunks []dto.GeminiChatResponse
+	for _, line := range strings.Split(recorder.Body.String(), "\n") {
+		if !strings.HasPrefix(line, "data: ") {
+			continue
+		}
+		var chunk dto.GeminiChatResponse
+		require.NoError(t, json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), &chunk))
+		chunks = append(chunks, chunk)
+	}
+	require.Len(t, chunks, 2)
+	assert.Equal(t, "Hi", chunks[0].Candidates[0].Content.Parts[0].Text)
+	last := chunks[1]
+	require.NotNil(t, last.Candidates[0].FinishReason)
+	assert.Equal(t, "STOP", *last.Candidates[0].FinishReason)
+	assert.Equal(t, 12, last.UsageMetadata.PromptTokenCount)
+	assert.Equal(t, 4, last.UsageMetadata.CandidatesTokenCount)
+	assert.True(t, claudeInfo.Done)
+}
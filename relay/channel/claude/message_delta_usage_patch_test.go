@@ -61,6 +61,11 @@ func TestShouldSkipClaudeMessageDeltaUsagePatch(t *testing.T) {
 	assert.False(t, shouldSkipClaudeMessageDeltaUsagePatch(&relaycommon.RelayInfo{
 		ChannelMeta: &relaycommon.ChannelMeta{ChannelSetting: dto.ChannelSettings{PassThroughBodyEnabled: false}},
 	}))
+	// 强制注入系统提示词时请求体已被改写，不再视为透传
+	assert.False(t, shouldSkipClaudeMessageDeltaUsagePatch(&relaycommon.RelayInfo{
+		SystemPromptEnforced: true,
+		ChannelMeta:          &relaycommon.ChannelMeta{ChannelSetting: dto.ChannelSettings{PassThroughBodyEnabled: true}},
+	}))
 }
 
 func TestBuildMessageDeltaPatchUsage(t *testing.T) {
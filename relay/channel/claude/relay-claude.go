@@ -21,6 +21,7 @@ import (
 	"github.com/QuantumNous/new-api/types"
 
 	"github.com/gin-gonic/gin"
+	"github.com/samber/lo"
 	"github.com/tidwall/gjson"
 	"github.com/tidwall/sjson"
 )
@@ -31,6 +32,14 @@ const (
 	WebSearchMaxUsesHigh   = 10
 )
 
+const (
+	// https://docs.anthropic.com/en/docs/agents-and-tools/tool-use/computer-use-tool
+	ComputerToolType       = "computer_20250124"
+	ComputerUseBeta        = "computer-use-2025-01-24"
+	computerUseContextKey  = "claude_computer_use"
+	defaultComputerDisplay = 1024
+)
+
 func stopReasonClaude2OpenAI(reason string) string {
 	return reasonmap.ClaudeStopReasonToOpenAIFinishReason(reason)
 }
@@ -46,8 +55,25 @@ func maybeMarkClaudeRefusal(c *gin.Context, stopReason string) {
 
 func RequestOpenAI2ClaudeMessage(c *gin.Context, textRequest dto.GeneralOpenAIRequest) (*dto.ClaudeRequest, error) {
 	claudeTools := make([]any, 0, len(textRequest.Tools))
+	computerUse := false
 
 	for _, tool := range textRequest.Tools {
+		// 计算机操作工具映射为 Claude 原生 computer 工具
+		if tool.Type == service.ComputerToolType {
+			computerTool := dto.ClaudeComputerTool{
+				Type:            ComputerToolType,
+				Name:            service.ComputerToolCallName,
+				DisplayWidthPx:  defaultComputerDisplay,
+				DisplayHeightPx: defaultComputerDisplay * 3 / 4,
+			}
+			if tool.ComputerUsePreview != nil && tool.ComputerUsePreview.DisplayWidth > 0 && tool.ComputerUsePreview.DisplayHeight > 0 {
+				computerTool.DisplayWidthPx = tool.ComputerUsePreview.DisplayWidth
+				computerTool.DisplayHeightPx = tool.ComputerUsePreview.DisplayHeight
+			}
+			claudeTools = append(claudeTools, &computerTool)
+			computerUse = true
+			continue
+		}
 		if params, ok := tool.Function.Parameters.(map[string]any); ok {
 			claudeTool := dto.Tool{
 				Name:        tool.Function.Name,
@@ -121,6 +147,10 @@ func RequestOpenAI2ClaudeMessage(c *gin.Context, textRequest dto.GeneralOpenAIRe
 		claudeTools = append(claudeTools, &webSearchTool)
 	}
 
+	if computerUse && c != nil {
+		c.Set(computerUseContextKey, true)
+	}
+
 	claudeRequest := dto.ClaudeRequest{
 		Model:         textRequest.Model,
 		StopSequences: nil,
@@ -140,8 +170,8 @@ func RequestOpenAI2ClaudeMessage(c *gin.Context, textRequest dto.GeneralOpenAIRe
 		claudeRequest.Stream = common.GetPointer(true)
 	}
 
-	// 处理 tool_choice 和 parallel_tool_calls
-	if textRequest.ToolChoice != nil || textRequest.ParallelTooCalls != nil {
+	// 处理 tool_choice 和 parallel_tool_calls，Claude 不允许在没有工具时指定 tool_choice
+	if len(claudeTools) > 0 && (textRequest.ToolChoice != nil || textRequest.ParallelTooCalls != nil) {
 		claudeToolChoice := mapToolChoice(textRequest.ToolChoice, textRequest.ParallelTooCalls)
 		if claudeToolChoice != nil {
 			claudeRequest.ToolChoice = claudeToolChoice
@@ -204,10 +234,10 @@ func RequestOpenAI2ClaudeMessage(c *gin.Context, textRequest dto.GeneralOpenAIRe
 		}
 		// Extended Thinking 必要配置
 		// https://docs.anthropic.com/en/docs/build-with-claude/extended-thinking#important-considerations-when-using-extended-thinking
-		claudeRequest.TopP = 0
+		claudeRequest.TopP = common.GetPointer[float64](0)
 		claudeRequest.Temperature = common.GetPointer[float64](1.0)
-		if claudeRequest.MaxTokens < 1280 {
-			claudeRequest.MaxTokens = 1280
+		if lo.FromPtrOr(claudeRequest.MaxTokens, uint(0)) < 1280 {
+			claudeRequest.MaxTokens = common.GetPointer[uint](1280)
 		}
 	}
 
@@ -225,10 +255,10 @@ func RequestOpenAI2ClaudeMessage(c *gin.Context, textRequest dto.GeneralOpenAIRe
 				BudgetTokens: &budgetTokens,
 			}
 			// Extended Thinking 必要配置
-			claudeRequest.TopP = 0
+			claudeRequest.TopP = common.GetPointer[float64](0)
 			claudeRequest.Temperature = common.GetPointer[float64](1.0)
-			if claudeRequest.MaxTokens < uint(budgetTokens)+256 {
-				claudeRequest.MaxTokens = uint(budgetTokens) + 256
+			if lo.FromPtrOr(claudeRequest.MaxTokens, uint(0)) < uint(budgetTokens)+256 {
+				claudeRequest.MaxTokens = common.GetPointer[uint](uint(budgetTokens) + 256)
 			}
 		}
 	}
@@ -253,6 +283,14 @@ func RequestOpenAI2ClaudeMessage(c *gin.Context, textRequest dto.GeneralOpenAIRe
 	for i, message := range textRequest.Messages {
 		if message.Role == "" {
 			textRequest.Messages[i].Role = "user"
+			message.Role = "user"
+		}
+		// developer 角色等同于 system，Claude 只接受 system 字段
+		if message.Role == "developer" {
+			message.Role = "system"
+		}
+		if message.Role == "system" && message.Content == nil {
+			continue
 		}
 		fmtMessage := dto.Message{
 			Role:    message.Role,
@@ -264,7 +302,8 @@ func RequestOpenAI2ClaudeMessage(c *gin.Context, textRequest dto.GeneralOpenAIRe
 		if message.Role == "assistant" && message.ToolCalls != nil {
 			fmtMessage.ToolCalls = message.ToolCalls
 		}
-		if lastMessage.Role == message.Role && lastMessage.Role != "tool" {
+		// 多条 system 消息各自保留为独立的 system 块，不做合并
+		if lastMessage.Role == message.Role && lastMessage.Role != "tool" && lastMessage.Role != "system" {
 			if lastMessage.IsStringContent() && message.IsStringContent() {
 				fmtMessage.SetStringContent(strings.Trim(fmt.Sprintf("%s %s", lastMessage.StringContent(), message.StringContent()), "\""))
 				// delete last message
@@ -296,8 +335,9 @@ func RequestOpenAI2ClaudeMessage(c *gin.Context, textRequest dto.GeneralOpenAIRe
 				for _, ctx := range message.ParseContent() {
 					if ctx.Type == "text" {
 						systemMessages = append(systemMessages, dto.ClaudeMediaMessage{
-							Type: "text",
-							Text: common.GetPointer[string](ctx.Text),
+							Type:         "text",
+							Text:         common.GetPointer[string](ctx.Text),
+							CacheControl: ctx.CacheControl,
 						})
 					}
 					// 未来可以在这里扩展对图片等其他类型的支持
@@ -334,20 +374,28 @@ func RequestOpenAI2ClaudeMessage(c *gin.Context, textRequest dto.GeneralOpenAIRe
 							},
 						}
 					}
+					toolResult, err := toolResultContent(c, message)
+					if err != nil {
+						return nil, err
+					}
 					lastMessage.Content = append(lastMessage.Content.([]dto.ClaudeMediaMessage), dto.ClaudeMediaMessage{
 						Type:      "tool_result",
 						ToolUseId: message.ToolCallId,
-						Content:   message.Content,
+						Content:   toolResult,
 					})
 					claudeMessages[len(claudeMessages)-1] = lastMessage
 					continue
 				} else {
+					toolResult, err := toolResultContent(c, message)
+					if err != nil {
+						return nil, err
+					}
 					claudeMessage.Role = "user"
 					claudeMessage.Content = []dto.ClaudeMediaMessage{
 						{
 							Type:      "tool_result",
 							ToolUseId: message.ToolCallId,
-							Content:   message.Content,
+							Content:   toolResult,
 						},
 					}
 				}
@@ -362,24 +410,11 @@ func RequestOpenAI2ClaudeMessage(c *gin.Context, textRequest dto.GeneralOpenAIRe
 					if mediaMessage.Type == "text" {
 						claudeMediaMessage.Text = common.GetPointer[string](mediaMessage.Text)
 					} else {
-						imageUrl := mediaMessage.GetImageMedia()
-						claudeMediaMessage.Type = "image"
-						claudeMediaMessage.Source = &dto.ClaudeMessageSource{
-							Type: "base64",
-						}
-						// 使用统一的文件服务获取图片数据
-						var source *types.FileSource
-						if strings.HasPrefix(imageUrl.Url, "http") {
-							source = types.NewURLFileSource(imageUrl.Url)
-						} else {
-							source = types.NewBase64FileSource(imageUrl.Url, "")
-						}
-						base64Data, mimeType, err := service.GetBase64Data(c, source, "formatting image for Claude")
+						imageMessage, err := claudeImageMessage(c, mediaMessage.GetImageMedia().Url)
 						if err != nil {
-							return nil, fmt.Errorf("get file data failed: %s", err.Error())
+							return nil, err
 						}
-						claudeMediaMessage.Source.MediaType = mimeType
-						claudeMediaMessage.Source.Data = base64Data
+						claudeMediaMessage = imageMessage
 					}
 					claudeMediaMessages = append(claudeMediaMessages, claudeMediaMessage)
 				}
@@ -390,6 +425,9 @@ func RequestOpenAI2ClaudeMessage(c *gin.Context, textRequest dto.GeneralOpenAIRe
 							common.SysLog("tool call function arguments is not a map[string]any: " + fmt.Sprintf("%v", toolCall.Function.Arguments))
 							continue
 						}
+						if computerUse && toolCall.Function.Name == service.ComputerToolCallName {
+							inputObj = service.ComputerActionToClaude(toolCall.Function.Arguments)
+						}
 						claudeMediaMessages = append(claudeMediaMessages, dto.ClaudeMediaMessage{
 							Type:  "tool_use",
 							Id:    toolCall.ID,
@@ -414,6 +452,60 @@ func RequestOpenAI2ClaudeMessage(c *gin.Context, textRequest dto.GeneralOpenAIRe
 	return &claudeRequest, nil
 }
 
+// claudeImageMessage 将图片地址（URL 或 base64）转换为 Claude image 块
+func claudeImageMessage(c *gin.Context, imageUrl string) (dto.ClaudeMediaMessage, error) {
+	// 使用统一的文件服务获取图片数据
+	var source *types.FileSource
+	if strings.HasPrefix(imageUrl, "http") {
+		source = types.NewURLFileSource(imageUrl)
+	} else {
+		source = types.NewBase64FileSource(imageUrl, "")
+	}
+	base64Data, mimeType, err := service.GetBase64Data(c, source, "formatting image for Claude")
+	if err != nil {
+		return dto.ClaudeMediaMessage{}, fmt.Errorf("get file data failed: %s", err.Error())
+	}
+	return dto.ClaudeMediaMessage{
+		Type: "image",
+		Source: &dto.ClaudeMessageSource{
+			Type:      "base64",
+			MediaType: mimeType,
+			Data:      base64Data,
+		},
+	}, nil
+}
+
+// toolResultContent 转换 tool 消息内容，其中的图片（如计算机操作截图）转为 Claude image 块
+func toolResultContent(c *gin.Context, message dto.Message) (any, error) {
+	if message.IsStringContent() {
+		return message.Content, nil
+	}
+	parts := message.ParseContent()
+	hasImage := lo.ContainsBy(parts, func(part dto.MediaContent) bool {
+		return part.Type == dto.ContentTypeImageURL
+	})
+	if !hasImage {
+		return message.Content, nil
+	}
+	contents := make([]dto.ClaudeMediaMessage, 0, len(parts))
+	for _, part := range parts {
+		switch part.Type {
+		case dto.ContentTypeText:
+			contents = append(contents, dto.ClaudeMediaMessage{
+				Type: "text",
+				Text: common.GetPointer[string](part.Text),
+			})
+		case dto.ContentTypeImageURL:
+			imageMessage, err := claudeImageMessage(c, part.GetImageMedia().Url)
+			if err != nil {
+				return nil, err
+			}
+			contents = append(contents, imageMessage)
+		}
+	}
+	return contents, nil
+}
+
 func StreamResponseClaude2OpenAI(claudeResponse *dto.ClaudeResponse) *dto.ChatCompletionsStreamResponse {
 	var response dto.ChatCompletionsStreamResponse
 	response.Object = "chat.completion.chunk"
@@ -597,13 +689,10 @@ func buildMessageDeltaPatchUsage(claudeResponse *dto.ClaudeResponse, claudeInfo
 }
 
 func shouldSkipClaudeMessageDeltaUsagePatch(info *relaycommon.RelayInfo) bool {
-	if model_setting.GetGlobalSettings().PassThroughRequestEnabled {
-		return true
-	}
 	if info == nil {
-		return false
+		return model_setting.GetGlobalSettings().PassThroughRequestEnabled
 	}
-	return info.ChannelSetting.PassThroughBodyEnabled
+	return info.PassThroughBody()
 }
 
 func patchClaudeMessageDeltaUsageData(data string, usage *dto.ClaudeUsage) string {
@@ -753,6 +842,24 @@ func HandleStreamResponseData(c *gin.Context, info *relaycommon.RelayInfo, claud
 		if err != nil {
 			logger.LogError(c, "send_stream_response_failed: "+err.Error())
 		}
+	} else if info.RelayFormat == types.RelayFormatGemini {
+		response := StreamResponseClaude2OpenAI(&claudeResponse)
+		if !FormatClaudeResponseInfo(&claudeResponse, response, claudeInfo) || response == nil {
+			return nil
+		}
+		if claudeResponse.Type == "message_delta" {
+			// 最后一个分片携带完整用量，作为 Gemini 的 usageMetadata 返回
+			usage := *claudeInfo.Usage
+			response.Usage = &usage
+		}
+		geminiResponse := service.StreamResponseOpenAI2Gemini(response, info)
+		if geminiResponse == nil {
+			return nil
+		}
+		err = helper.ObjectData(c, geminiResponse)
+		if err != nil {
+			logger.LogError(c, "send_stream_response_failed: "+err.Error())
+		}
 	}
 	return nil
 }
@@ -839,6 +946,13 @@ func HandleClaudeResponseData(c *gin.Context, info *relaycommon.RelayInfo, claud
 		}
 	case types.RelayFormatClaude:
 		responseData = data
+	case types.RelayFormatGemini:
+		openaiResponse := ResponseClaude2OpenAI(&claudeResponse)
+		openaiResponse.Usage = *claudeInfo.Usage
+		responseData, err = common.Marshal(service.ResponseOpenAI2Gemini(openaiResponse, info))
+		if err != nil {
+			return types.NewError(err, types.ErrorCodeBadResponseBody)
+		}
 	}
 
 	if claudeResponse.Usage != nil && claudeResponse.Usage.ServerToolUse != nil && claudeResponse.Usage.ServerToolUse.WebSearchRequests > 0 {
@@ -894,8 +1008,34 @@ func mapToolChoice(toolChoice any, parallelToolCalls *bool) *dto.ClaudeToolChoic
 		}
 	} else if toolChoiceMap, ok := toolChoice.(map[string]interface{}); ok {
 		// 处理 tool_choice 对象值
-		if function, ok := toolChoiceMap["function"].(map[string]interface{}); ok {
-			if toolName, ok := function["name"].(string); ok {
+		toolChoiceType, _ := toolChoiceMap["type"].(string)
+		switch toolChoiceType {
+		case "auto", "none":
+			claudeToolChoice = &dto.ClaudeToolChoice{
+				Type: toolChoiceType,
+			}
+		case "required", "any":
+			claudeToolChoice = &dto.ClaudeToolChoice{
+				Type: "any",
+			}
+		case "allowed_tools":
+			// {"type":"allowed_tools","mode":"auto|required","tools":[...]}
+			claudeToolChoice = &dto.ClaudeToolChoice{
+				Type: "auto",
+			}
+			if mode, _ := toolChoiceMap["mode"].(string); mode == "required" {
+				claudeToolChoice.Type = "any"
+			}
+		default:
+			if function, ok := toolChoiceMap["function"].(map[string]interface{}); ok {
+				if toolName, ok := function["name"].(string); ok {
+					claudeToolChoice = &dto.ClaudeToolChoice{
+						Type: "tool",
+						Name: toolName,
+					}
+				}
+			} else if toolName, ok := toolChoiceMap["name"].(string); ok && toolName != "" {
+				// Responses 风格 {"type":"function","name":"..."} 或 Claude 风格 {"type":"tool","name":"..."}
 				claudeToolChoice = &dto.ClaudeToolChoice{
 					Type: "tool",
 					Name: toolName,
@@ -977,7 +1117,7 @@ func ClaudeResponsesHandler(c *gin.Context, resp *http.Response, info *relaycomm
 	}
 
 	// Convert Chat response to Responses format
-	responsesResponse := service.ChatCompletionsResponseToResponsesResponse(openaiResponse, originalReq)
+	responsesResponse := service.ChatCompletionsResponseToResponsesResponse(openaiResponse, originalReq, info)
 
 	// Marshal and send response
 	responseData, err := json.Marshal(responsesResponse)
@@ -989,6 +1129,7 @@ func ClaudeResponsesHandler(c *gin.Context, resp *http.Response, info *relaycomm
 		c.Set("claude_web_search_requests", claudeResponse.Usage.ServerToolUse.WebSearchRequests)
 	}
 
+	service.SaveResponsesConversation(c, info, responsesResponse.ID, service.ChatResponseAssistantMessage(openaiResponse), responsesResponse)
 	service.IOCopyBytesGracefully(c, resp, responseData)
 	return claudeInfo.Usage, nil
 }
@@ -1002,7 +1143,8 @@ func ClaudeResponsesStreamHandler(c *gin.Context, resp *http.Response, info *rel
 	}
 
 	// Create stream adapter
-	streamAdapter := service.NewChatToResponsesStreamAdapter(originalReq)
+	streamAdapter := service.NewChatToResponsesStreamAdapter(c, originalReq, info)
+	defer streamAdapter.Close()
 
 	claudeInfo := &ClaudeResponseInfo{
 		ResponseId:   helper.GetResponseID(c),
@@ -0,0 +1,109 @@
+package claude
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/QuantumNous/new-api/dto"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func convertOpenAIRequestForTest(t *testing.T, body string) map[string]any {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+
+	var request dto.GeneralOpenAIRequest
+	require.NoError(t, json.Unmarshal([]byte(body), &request))
+	claudeRequest, err := RequestOpenAI2ClaudeMessage(c, request)
+	require.NoError(t, err)
+
+	data, err := json.Marshal(claudeRequest)
+	require.NoError(t, err)
+	var out map[string]any
+	require.NoError(t, json.Unmarshal(data, &out))
+	return out
+}
+
+const convertTestTools = `"tools":[{"type":"function","function":{"name":"get_weather","parameters":{"type":"object","properties":{"city":{"type":"string"}}}}}]`
+
+func TestRequestOpenAI2ClaudeMessage_DeveloperAndMultipleSystemBlocks(t *testing.T) {
+	out := convertOpenAIRequestForTest(t, `{
+		"model":"claude-sonnet-4",
+		"messages":[
+			{"role":"system","content":"You are helpful."},
+			{"role":"developer","content":[{"type":"text","text":"Answer in JSON.","cache_control":{"type":"ephemeral"}}]},
+			{"role":"user","content":"hi"}
+		]
+	}`)
+
+	system, ok := out["system"].([]any)
+	require.True(t, ok)
+	require.Len(t, system, 2)
+	assert.Equal(t, "You are helpful.", system[0].(map[string]any)["text"])
+	second := system[1].(map[string]any)
+	assert.Equal(t, "Answer in JSON.", second["text"])
+	assert.Equal(t, map[string]any{"type": "ephemeral"}, second["cache_control"])
+
+	messages := out["messages"].([]any)
+	require.Len(t, messages, 1)
+	assert.Equal(t, "user", messages[0].(map[string]any)["role"])
+}
+
+func TestRequestOpenAI2ClaudeMessage_ToolChoice(t *testing.T) {
+	tests := []struct {
+		name     string
+		extra    string
+		expected map[string]any
+	}{
+		{"none", `"tool_choice":"none","parallel_tool_calls":false`, map[string]any{"type": "none"}},
+		{"required", `"tool_choice":"required"`, map[string]any{"type": "any"}},
+		{"required object", `"tool_choice":{"type":"required"}`, map[string]any{"type": "any"}},
+		{"named function", `"tool_choice":{"type":"function","function":{"name":"get_weather"}}`, map[string]any{"type": "tool", "name": "get_weather"}},
+		{"responses style function", `"tool_choice":{"type":"function","name":"get_weather"}`, map[string]any{"type": "tool", "name": "get_weather"}},
+		{"parallel disabled", `"tool_choice":"auto","parallel_tool_calls":false`, map[string]any{"type": "auto", "disable_parallel_tool_use": true}},
+		{"parallel disabled without choice", `"parallel_tool_calls":false`, map[string]any{"type": "auto", "disable_parallel_tool_use": true}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out := convertOpenAIRequestForTest(t, `{"model":"claude-sonnet-4","messages":[{"role":"user","content":"hi"}],`+convertTestTools+`,`+tt.extra+`}`)
+			assert.Equal(t, tt.expected, out["tool_choice"])
+		})
+	}
+}
+
+func TestRequestOpenAI2ClaudeMessage_ToolChoiceWithoutTools(t *testing.T) {
+	out := convertOpenAIRequestForTest(t, `{"model":"claude-sonnet-4","messages":[{"role":"user","content":"hi"}],"tool_choice":"required","parallel_tool_calls":false}`)
+	_, ok := out["tool_choice"]
+	assert.False(t, ok)
+}
+
+func TestRequestOpenAI2ClaudeMessage_ComputerUse(t *testing.T) {
+	out := convertOpenAIRequestForTest(t, `{
+		"model":"claude-sonnet-4",
+		"tools":[{"type":"computer_use_preview","computer_use_preview":{"display_width":1280,"display_height":800,"environment":"browser"}}],
+		"messages":[
+			{"role":"user","content":"open the menu"},
+			{"role":"assistant","content":"","tool_calls":[{"id":"toolu_1","type":"function","function":{"name":"computer","arguments":"{\"type\":\"click\",\"button\":\"left\",\"x\":10,\"y\":20}"}}]},
+			{"role":"tool","tool_call_id":"toolu_1","content":"done"}
+		]
+	}`)
+
+	tools := out["tools"].([]any)
+	require.Len(t, tools, 1)
+	tool := tools[0].(map[string]any)
+	assert.Equal(t, ComputerToolType, tool["type"])
+	assert.Equal(t, "computer", tool["name"])
+	assert.EqualValues(t, 1280, tool["display_width_px"])
+	assert.EqualValues(t, 800, tool["display_height_px"])
+
+	messages := out["messages"].([]any)
+	require.Len(t, messages, 3)
+	assistant := messages[1].(map[string]any)["content"].([]any)
+	toolUse := assistant[len(assistant)-1].(map[string]any)
+	assert.Equal(t, "tool_use", toolUse["type"])
+	assert.Equal(t, map[string]any{"action": "left_click", "coordinate": []any{float64(10), float64(20)}}, toolUse["input"])
+}
@@ -46,7 +46,7 @@ func (a *Adaptor) GetRequestURL(info *relaycommon.RelayInfo) (string, error) {
 	if info.RelayMode == constant.RelayModeRerank {
 		return fmt.Sprintf("%s/v1/rerank", info.ChannelBaseUrl), nil
 	} else {
-		return fmt.Sprintf("%s/v1/chat", info.ChannelBaseUrl), nil
+		return fmt.Sprintf("%s/v2/chat", info.ChannelBaseUrl), nil
 	}
 }
 
@@ -57,7 +57,10 @@ func (a *Adaptor) SetupRequestHeader(c *gin.Context, req *http.Header, info *rel
 }
 
 func (a *Adaptor) ConvertOpenAIRequest(c *gin.Context, info *relaycommon.RelayInfo, request *dto.GeneralOpenAIRequest) (any, error) {
-	return requestOpenAI2Cohere(*request), nil
+	if request == nil {
+		return nil, errors.New("request is nil")
+	}
+	return requestOpenAI2Cohere(request)
 }
 
 func (a *Adaptor) ConvertOpenAIResponsesRequest(c *gin.Context, info *relaycommon.RelayInfo, request dto.OpenAIResponsesRequest) (any, error) {
@@ -83,7 +86,7 @@ func (a *Adaptor) DoResponse(c *gin.Context, resp *http.Response, info *relaycom
 		usage, err = cohereRerankHandler(c, resp, info)
 	} else {
 		if info.IsStream {
-			usage, err = cohereStreamHandler(c, info, resp) // TODO: fix this
+			usage, err = cohereStreamHandler(c, info, resp)
 		} else {
 			usage, err = cohereHandler(c, info, resp)
 		}
@@ -1,41 +1,145 @@
 package cohere
 
-import "github.com/QuantumNous/new-api/dto"
+import (
+	"encoding/json"
 
-type CohereRequest struct {
-	Model       string        `json:"model"`
-	ChatHistory []ChatHistory `json:"chat_history"`
-	Message     string        `json:"message"`
-	Stream      bool          `json:"stream"`
-	MaxTokens   uint          `json:"max_tokens"`
-	SafetyMode  string        `json:"safety_mode,omitempty"`
+	"github.com/QuantumNous/new-api/dto"
+)
+
+// CohereChatRequest Cohere v2 Chat API 请求
+type CohereChatRequest struct {
+	Model            string                `json:"model"`
+	Messages         []CohereMessage       `json:"messages"`
+	Tools            []CohereTool          `json:"tools,omitempty"`
+	ToolChoice       string                `json:"tool_choice,omitempty"` // REQUIRED 或 NONE，未设置时由模型决定
+	Stream           bool                  `json:"stream"`
+	MaxTokens        uint                  `json:"max_tokens,omitempty"`
+	StopSequences    []string              `json:"stop_sequences,omitempty"`
+	Temperature      *float64              `json:"temperature,omitempty"`
+	Seed             *int                  `json:"seed,omitempty"`
+	FrequencyPenalty *float64              `json:"frequency_penalty,omitempty"`
+	PresencePenalty  *float64              `json:"presence_penalty,omitempty"`
+	K                *int                  `json:"k,omitempty"`
+	P                *float64              `json:"p,omitempty"`
+	ResponseFormat   *CohereResponseFormat `json:"response_format,omitempty"`
+	SafetyMode       string                `json:"safety_mode,omitempty"`
+}
+
+// CohereMessage 消息的 content 为字符串或内容块数组；assistant 消息发起工具调用前的说明放在 tool_plan
+type CohereMessage struct {
+	Role       string           `json:"role"`
+	Content    any              `json:"content,omitempty"`
+	ToolPlan   string           `json:"tool_plan,omitempty"`
+	ToolCalls  []CohereToolCall `json:"tool_calls,omitempty"`
+	ToolCallId string           `json:"tool_call_id,omitempty"`
+}
+
+// CohereContent 内容块，type 为 text、image_url 或 thinking
+type CohereContent struct {
+	Type     string          `json:"type"`
+	Text     string          `json:"text,omitempty"`
+	Thinking string          `json:"thinking,omitempty"`
+	ImageUrl *CohereImageUrl `json:"image_url,omitempty"`
+}
+
+type CohereImageUrl struct {
+	Url    string `json:"url"`
+	Detail string `json:"detail,omitempty"`
+}
+
+type CohereTool struct {
+	Type     string             `json:"type"`
+	Function CohereToolFunction `json:"function"`
+}
+
+type CohereToolFunction struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	Parameters  any    `json:"parameters,omitempty"`
+}
+
+type CohereToolCall struct {
+	Id       string                 `json:"id,omitempty"`
+	Type     string                 `json:"type,omitempty"`
+	Function CohereToolCallFunction `json:"function"`
+}
+
+type CohereToolCallFunction struct {
+	Name      string `json:"name,omitempty"`
+	Arguments string `json:"arguments,omitempty"`
+}
+
+type CohereResponseFormat struct {
+	Type       string `json:"type"`
+	JsonSchema any    `json:"json_schema,omitempty"`
+}
+
+// CohereChatResponse Cohere v2 Chat API 响应
+type CohereChatResponse struct {
+	Id           string                `json:"id"`
+	FinishReason string                `json:"finish_reason"`
+	Message      CohereResponseMessage `json:"message"`
+	Usage        *CohereUsage          `json:"usage,omitempty"`
+}
+
+type CohereResponseMessage struct {
+	Role      string           `json:"role"`
+	Content   []CohereContent  `json:"content,omitempty"`
+	ToolPlan  string           `json:"tool_plan,omitempty"`
+	ToolCalls []CohereToolCall `json:"tool_calls,omitempty"`
+	Citations []CohereCitation `json:"citations,omitempty"`
+}
+
+// CohereCitation 回答中 [start, end) 区间的文本引用的来源
+type CohereCitation struct {
+	Start   int                    `json:"start"`
+	End     int                    `json:"end"`
+	Text    string                 `json:"text"`
+	Sources []CohereCitationSource `json:"sources,omitempty"`
+	Type    string                 `json:"type,omitempty"` // TEXT_CONTENT、THINKING_CONTENT 或 PLAN
+}
+
+// CohereCitationSource 引用来源，type 为 document 时详情在 document，为 tool 时在 tool_output
+type CohereCitationSource struct {
+	Type       string         `json:"type"`
+	Id         string         `json:"id,omitempty"`
+	Document   map[string]any `json:"document,omitempty"`
+	ToolOutput map[string]any `json:"tool_output,omitempty"`
+}
+
+type CohereUsage struct {
+	BilledUnits CohereBilledUnits `json:"billed_units"`
+	Tokens      CohereTokens      `json:"tokens"`
 }
 
-type ChatHistory struct {
-	Role    string `json:"role"`
-	Message string `json:"message"`
+// CohereStreamEvent 流式事件。不同事件中 delta.message 的 content、tool_calls、citations 为单个对象或数组，先保留原始 JSON
+type CohereStreamEvent struct {
+	Type  string             `json:"type"`
+	Id    string             `json:"id,omitempty"`
+	Index int                `json:"index"`
+	Delta *CohereStreamDelta `json:"delta,omitempty"`
 }
 
-type CohereResponse struct {
-	IsFinished   bool                  `json:"is_finished"`
-	EventType    string                `json:"event_type"`
-	Text         string                `json:"text,omitempty"`
-	FinishReason string                `json:"finish_reason,omitempty"`
-	Response     *CohereResponseResult `json:"response"`
+type CohereStreamDelta struct {
+	Message      *CohereStreamMessageDelta `json:"message,omitempty"`
+	FinishReason string                    `json:"finish_reason,omitempty"`
+	Usage        *CohereUsage              `json:"usage,omitempty"`
+	Error        string                    `json:"error,omitempty"`
 }
 
-type CohereResponseResult struct {
-	ResponseId   string     `json:"response_id"`
-	FinishReason string     `json:"finish_reason,omitempty"`
-	Text         string     `json:"text"`
-	Meta         CohereMeta `json:"meta"`
+type CohereStreamMessageDelta struct {
+	Role      string          `json:"role,omitempty"`
+	Content   json.RawMessage `json:"content,omitempty"`
+	ToolPlan  string          `json:"tool_plan,omitempty"`
+	ToolCalls json.RawMessage `json:"tool_calls,omitempty"`
+	Citations json.RawMessage `json:"citations,omitempty"`
 }
 
 type CohereRerankRequest struct {
 	Documents       []any  `json:"documents"`
 	Query           string `json:"query"`
 	Model           string `json:"model"`
-	TopN            int    `json:"top_n"`
+	TopN            *int   `json:"top_n,omitempty"` // 未指定时返回全部文档的排序
 	ReturnDocuments bool   `json:"return_documents"`
 }
 
@@ -52,9 +156,11 @@ type CohereMeta struct {
 type CohereBilledUnits struct {
 	InputTokens  int `json:"input_tokens"`
 	OutputTokens int `json:"output_tokens"`
+	SearchUnits  int `json:"search_units"`
 }
 
 type CohereTokens struct {
 	InputTokens  int `json:"input_tokens"`
 	OutputTokens int `json:"output_tokens"`
+	SearchUnits  int `json:"search_units"`
 }
@@ -1,15 +1,15 @@
 package cohere
 
 import (
-	"bufio"
 	"encoding/json"
 	"io"
 	"net/http"
 	"strings"
-	"time"
 
 	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/constant"
 	"github.com/QuantumNous/new-api/dto"
+	"github.com/QuantumNous/new-api/logger"
 	relaycommon "github.com/QuantumNous/new-api/relay/common"
 	"github.com/QuantumNous/new-api/relay/helper"
 	"github.com/QuantumNous/new-api/service"
@@ -19,199 +19,476 @@ import (
 	"github.com/samber/lo"
 )
 
-func requestOpenAI2Cohere(textRequest dto.GeneralOpenAIRequest) *CohereRequest {
-	cohereReq := CohereRequest{
-		Model:       textRequest.Model,
-		ChatHistory: []ChatHistory{},
-		Message:     "",
-		Stream:      lo.FromPtrOr(textRequest.Stream, false),
-		MaxTokens:   textRequest.GetMaxTokens(),
+// requestOpenAI2Cohere 将 OpenAI Chat Completions 请求转换为 Cohere v2 Chat 请求。
+// developer 消息按 system 处理；assistant 消息发起工具调用时，文本内容放入 tool_plan
+func requestOpenAI2Cohere(request *dto.GeneralOpenAIRequest) (*CohereChatRequest, error) {
+	cohereReq := &CohereChatRequest{
+		Model:            request.Model,
+		Messages:         make([]CohereMessage, 0, len(request.Messages)),
+		Stream:           lo.FromPtrOr(request.Stream, false),
+		MaxTokens:        request.GetMaxTokens(),
+		StopSequences:    parseStopSequences(request.Stop),
+		Temperature:      request.Temperature,
+		FrequencyPenalty: request.FrequencyPenalty,
+		PresencePenalty:  request.PresencePenalty,
+		K:                request.TopK,
+		P:                request.TopP,
+	}
+	if request.Seed != nil {
+		seed := int(*request.Seed)
+		cohereReq.Seed = &seed
 	}
 	if common.CohereSafetySetting != "NONE" {
 		cohereReq.SafetyMode = common.CohereSafetySetting
 	}
-	if cohereReq.MaxTokens == 0 {
-		cohereReq.MaxTokens = 4000
-	}
-	for _, msg := range textRequest.Messages {
-		if msg.Role == "user" {
-			cohereReq.Message = msg.StringContent()
-		} else {
-			var role string
-			if msg.Role == "assistant" {
-				role = "CHATBOT"
-			} else if msg.Role == "system" {
-				role = "SYSTEM"
-			} else {
-				role = "USER"
-			}
-			cohereReq.ChatHistory = append(cohereReq.ChatHistory, ChatHistory{
-				Role:    role,
-				Message: msg.StringContent(),
+	for _, message := range request.Messages {
+		switch message.Role {
+		case "system", "developer":
+			cohereReq.Messages = append(cohereReq.Messages, CohereMessage{Role: "system", Content: message.StringContent()})
+		case "tool":
+			cohereReq.Messages = append(cohereReq.Messages, CohereMessage{
+				Role:       "tool",
+				ToolCallId: message.ToolCallId,
+				Content:    message.StringContent(),
 			})
+		case "assistant":
+			cohereMessage := CohereMessage{Role: "assistant"}
+			text := message.StringContent()
+			toolCalls := message.ParseToolCalls()
+			for _, toolCall := range toolCalls {
+				arguments := toolCall.Function.Arguments
+				if arguments == "" {
+					arguments = "{}"
+				}
+				cohereMessage.ToolCalls = append(cohereMessage.ToolCalls, CohereToolCall{
+					Id:       toolCall.ID,
+					Type:     "function",
+					Function: CohereToolCallFunction{Name: toolCall.Function.Name, Arguments: arguments},
+				})
+			}
+			if len(toolCalls) > 0 {
+				cohereMessage.ToolPlan = text
+			} else if text != "" {
+				cohereMessage.Content = text
+			}
+			cohereReq.Messages = append(cohereReq.Messages, cohereMessage)
+		default:
+			cohereReq.Messages = append(cohereReq.Messages, CohereMessage{Role: "user", Content: cohereUserContent(&message)})
 		}
 	}
 
-	return &cohereReq
+	for _, tool := range request.Tools {
+		if tool.Type != "" && tool.Type != "function" {
+			continue
+		}
+		cohereReq.Tools = append(cohereReq.Tools, CohereTool{
+			Type: "function",
+			Function: CohereToolFunction{
+				Name:        tool.Function.Name,
+				Description: tool.Function.Description,
+				Parameters:  tool.Function.Parameters,
+			},
+		})
+	}
+	if len(cohereReq.Tools) > 0 {
+		applyToolChoice(cohereReq, request.ToolChoice)
+	}
+
+	if format := request.ResponseFormat; format != nil {
+		switch format.Type {
+		case "json_object":
+			cohereReq.ResponseFormat = &CohereResponseFormat{Type: "json_object"}
+		case "json_schema":
+			cohereReq.ResponseFormat = &CohereResponseFormat{Type: "json_object"}
+			var jsonSchema dto.FormatJsonSchema
+			if len(format.JsonSchema) > 0 {
+				if err := common.Unmarshal(format.JsonSchema, &jsonSchema); err != nil {
+					return nil, err
+				}
+			}
+			cohereReq.ResponseFormat.JsonSchema = jsonSchema.Schema
+		}
+	}
+	return cohereReq, nil
+}
+
+// cohereUserContent 纯文本消息保持字符串，多模态消息转换为 text / image_url 内容块
+func cohereUserContent(message *dto.Message) any {
+	if message.IsStringContent() {
+		return message.StringContent()
+	}
+	contents := make([]CohereContent, 0)
+	for _, part := range message.ParseContent() {
+		switch part.Type {
+		case dto.ContentTypeText:
+			contents = append(contents, CohereContent{Type: "text", Text: part.Text})
+		case dto.ContentTypeImageURL:
+			if image := part.GetImageMedia(); image != nil && image.Url != "" {
+				contents = append(contents, CohereContent{
+					Type:     "image_url",
+					ImageUrl: &CohereImageUrl{Url: image.Url, Detail: image.Detail},
+				})
+			}
+		}
+	}
+	return contents
+}
+
+func parseStopSequences(stop any) []string {
+	switch v := stop.(type) {
+	case string:
+		if v != "" {
+			return []string{v}
+		}
+	case []string:
+		return v
+	case []any:
+		stopSequences := make([]string, 0, len(v))
+		for _, item := range v {
+			if s, ok := item.(string); ok && s != "" {
+				stopSequences = append(stopSequences, s)
+			}
+		}
+		return stopSequences
+	}
+	return nil
+}
+
+// applyToolChoice required 对应 REQUIRED，none 对应 NONE。Cohere 不能指定调用哪个函数，
+// 指定函数时只保留该函数并要求调用
+func applyToolChoice(cohereReq *CohereChatRequest, toolChoice any) {
+	switch v := toolChoice.(type) {
+	case string:
+		switch v {
+		case "required":
+			cohereReq.ToolChoice = "REQUIRED"
+		case "none":
+			cohereReq.ToolChoice = "NONE"
+		}
+	case map[string]any:
+		if function, ok := v["function"].(map[string]any); ok {
+			if name, _ := function["name"].(string); name != "" {
+				tools := lo.Filter(cohereReq.Tools, func(tool CohereTool, _ int) bool {
+					return tool.Function.Name == name
+				})
+				if len(tools) > 0 {
+					cohereReq.Tools = tools
+					cohereReq.ToolChoice = "REQUIRED"
+				}
+				return
+			}
+		}
+		switch v["type"] {
+		case "required":
+			cohereReq.ToolChoice = "REQUIRED"
+		case "none":
+			cohereReq.ToolChoice = "NONE"
+		}
+	}
 }
 
 func requestConvertRerank2Cohere(rerankRequest dto.RerankRequest) *CohereRerankRequest {
-	topN := lo.FromPtrOr(rerankRequest.TopN, 1)
-	if topN <= 0 {
-		topN = 1
+	var topN *int
+	if rerankRequest.TopN != nil && *rerankRequest.TopN > 0 {
+		topN = rerankRequest.TopN
 	}
 	cohereReq := CohereRerankRequest{
 		Query:           rerankRequest.Query,
 		Documents:       rerankRequest.Documents,
 		Model:           rerankRequest.Model,
 		TopN:            topN,
-		ReturnDocuments: true,
+		ReturnDocuments: rerankRequest.GetReturnDocuments(),
 	}
 	return &cohereReq
 }
 
 func stopReasonCohere2OpenAI(reason string) string {
 	switch reason {
-	case "COMPLETE":
+	case "COMPLETE", "STOP_SEQUENCE":
 		return "stop"
 	case "MAX_TOKENS":
-		return "max_tokens"
+		return "length"
+	case "TOOL_CALL":
+		return "tool_calls"
 	default:
-		return reason
+		return strings.ToLower(reason)
 	}
 }
 
-func cohereStreamHandler(c *gin.Context, info *relaycommon.RelayInfo, resp *http.Response) (*dto.Usage, *types.NewAPIError) {
-	responseId := helper.GetResponseID(c)
-	createdTime := common.GetTimestamp()
-	usage := &dto.Usage{}
-	responseText := ""
-	scanner := bufio.NewScanner(resp.Body)
-	scanner.Split(func(data []byte, atEOF bool) (advance int, token []byte, err error) {
-		if atEOF && len(data) == 0 {
-			return 0, nil, nil
-		}
-		if i := strings.Index(string(data), "\n"); i >= 0 {
-			return i + 1, data[0:i], nil
-		}
-		if atEOF {
-			return len(data), data, nil
-		}
-		return 0, nil, nil
-	})
-	dataChan := make(chan string)
-	stopChan := make(chan bool)
-	go func() {
-		for scanner.Scan() {
-			data := scanner.Text()
-			dataChan <- data
-		}
-		stopChan <- true
-	}()
-	helper.SetEventStreamHeaders(c)
-	isFirst := true
-	c.Stream(func(w io.Writer) bool {
-		select {
-		case data := <-dataChan:
-			if isFirst {
-				isFirst = false
-				info.FirstResponseTime = time.Now()
-			}
-			data = strings.TrimSuffix(data, "\r")
-			var cohereResp CohereResponse
-			err := json.Unmarshal([]byte(data), &cohereResp)
-			if err != nil {
-				common.SysLog("error unmarshalling stream response: " + err.Error())
-				return true
-			}
-			var openaiResp dto.ChatCompletionsStreamResponse
-			openaiResp.Id = responseId
-			openaiResp.Created = createdTime
-			openaiResp.Object = "chat.completion.chunk"
-			openaiResp.Model = info.UpstreamModelName
-			if cohereResp.IsFinished {
-				finishReason := stopReasonCohere2OpenAI(cohereResp.FinishReason)
-				openaiResp.Choices = []dto.ChatCompletionsStreamResponseChoice{
-					{
-						Delta:        dto.ChatCompletionsStreamResponseChoiceDelta{},
-						Index:        0,
-						FinishReason: &finishReason,
-					},
-				}
-				if cohereResp.Response != nil {
-					usage.PromptTokens = cohereResp.Response.Meta.BilledUnits.InputTokens
-					usage.CompletionTokens = cohereResp.Response.Meta.BilledUnits.OutputTokens
-				}
-			} else {
-				openaiResp.Choices = []dto.ChatCompletionsStreamResponseChoice{
-					{
-						Delta: dto.ChatCompletionsStreamResponseChoiceDelta{
-							Role:    "assistant",
-							Content: &cohereResp.Text,
-						},
-						Index: 0,
-					},
-				}
-				responseText += cohereResp.Text
-			}
-			jsonStr, err := json.Marshal(openaiResp)
-			if err != nil {
-				common.SysLog("error marshalling stream response: " + err.Error())
-				return true
+// usageCohere2OpenAI 按计费用量（billed_units）统计，上游未返回计费用量时使用实际 token 数
+func usageCohere2OpenAI(usage *CohereUsage) *dto.Usage {
+	if usage == nil {
+		return nil
+	}
+	openaiUsage := &dto.Usage{
+		PromptTokens:     usage.BilledUnits.InputTokens,
+		CompletionTokens: usage.BilledUnits.OutputTokens,
+	}
+	if openaiUsage.PromptTokens == 0 && openaiUsage.CompletionTokens == 0 {
+		openaiUsage.PromptTokens = usage.Tokens.InputTokens
+		openaiUsage.CompletionTokens = usage.Tokens.OutputTokens
+	}
+	if openaiUsage.PromptTokens == 0 && openaiUsage.CompletionTokens == 0 {
+		return nil
+	}
+	openaiUsage.TotalTokens = openaiUsage.PromptTokens + openaiUsage.CompletionTokens
+	return openaiUsage
+}
+
+// citationToAnnotation 将回答文本的引用转换为 OpenAI 的 url_citation 标注。来源文档带 url 时使用该 url，
+// 否则使用来源 ID（文档 ID 或 工具调用 ID:序号）；标题依次取文档 title 与被引用的文本。
+// 引用工具计划或思考内容的引用没有对应的回答文本，返回 nil
+func citationToAnnotation(citation CohereCitation) map[string]any {
+	if citation.Type != "" && citation.Type != "TEXT_CONTENT" {
+		return nil
+	}
+	url, title := "", ""
+	for _, source := range citation.Sources {
+		if documentUrl, _ := source.Document["url"].(string); documentUrl != "" && url == "" {
+			url = documentUrl
+			title, _ = source.Document["title"].(string)
+		}
+	}
+	if url == "" && len(citation.Sources) > 0 {
+		url = citation.Sources[0].Id
+		title, _ = citation.Sources[0].Document["title"].(string)
+	}
+	if url == "" {
+		return nil
+	}
+	if title == "" {
+		title = citation.Text
+	}
+	return map[string]any{
+		"type": "url_citation",
+		"url_citation": map[string]any{
+			"start_index": citation.Start,
+			"end_index":   citation.End,
+			"url":         url,
+			"title":       title,
+		},
+	}
+}
+
+// responseCohere2OpenAI 将 Cohere v2 Chat 响应转换为 OpenAI Chat Completions 响应。
+// thinking 内容块转换为 reasoning_content，只有工具调用时 tool_plan 作为回答文本
+func responseCohere2OpenAI(cohereResp *CohereChatResponse, id string, model string) *dto.OpenAITextResponse {
+	message := dto.Message{Role: "assistant"}
+	var text strings.Builder
+	var reasoning strings.Builder
+	for _, content := range cohereResp.Message.Content {
+		switch content.Type {
+		case "text":
+			text.WriteString(content.Text)
+		case "thinking":
+			reasoning.WriteString(content.Thinking)
+		}
+	}
+	if text.Len() == 0 {
+		text.WriteString(cohereResp.Message.ToolPlan)
+	}
+	message.SetStringContent(text.String())
+	message.ReasoningContent = reasoning.String()
+	if len(cohereResp.Message.ToolCalls) > 0 {
+		toolCalls := make([]dto.ToolCallResponse, 0, len(cohereResp.Message.ToolCalls))
+		for _, toolCall := range cohereResp.Message.ToolCalls {
+			arguments := toolCall.Function.Arguments
+			if arguments == "" {
+				arguments = "{}"
 			}
-			c.Render(-1, common.CustomEvent{Data: "data: " + string(jsonStr)})
-			return true
-		case <-stopChan:
-			c.Render(-1, common.CustomEvent{Data: "data: [DONE]"})
-			return false
+			toolCalls = append(toolCalls, dto.ToolCallResponse{
+				ID:       toolCall.Id,
+				Type:     "function",
+				Function: dto.FunctionResponse{Name: toolCall.Function.Name, Arguments: arguments},
+			})
 		}
-	})
-	if usage.PromptTokens == 0 {
-		usage = service.ResponseText2Usage(c, responseText, info.UpstreamModelName, info.GetEstimatePromptTokens())
+		message.SetToolCalls(toolCalls)
 	}
-	return usage, nil
+	for _, citation := range cohereResp.Message.Citations {
+		if annotation := citationToAnnotation(citation); annotation != nil {
+			message.Annotations = append(message.Annotations, annotation)
+		}
+	}
+	if cohereResp.Id != "" {
+		id = cohereResp.Id
+	}
+	openaiResp := &dto.OpenAITextResponse{
+		Id:      id,
+		Model:   model,
+		Object:  "chat.completion",
+		Created: common.GetTimestamp(),
+		Choices: []dto.OpenAITextResponseChoice{
+			{
+				Index:        0,
+				Message:      message,
+				FinishReason: stopReasonCohere2OpenAI(cohereResp.FinishReason),
+			},
+		},
+	}
+	if usage := usageCohere2OpenAI(cohereResp.Usage); usage != nil {
+		openaiResp.Usage = *usage
+	}
+	return openaiResp
 }
 
 func cohereHandler(c *gin.Context, info *relaycommon.RelayInfo, resp *http.Response) (*dto.Usage, *types.NewAPIError) {
-	createdTime := common.GetTimestamp()
 	responseBody, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return nil, types.NewError(err, types.ErrorCodeBadResponseBody)
+		return nil, types.NewOpenAIError(err, types.ErrorCodeReadResponseBodyFailed, http.StatusInternalServerError)
 	}
 	service.CloseResponseBodyGracefully(resp)
-	var cohereResp CohereResponseResult
-	err = json.Unmarshal(responseBody, &cohereResp)
+	var cohereResp CohereChatResponse
+	if err = common.Unmarshal(responseBody, &cohereResp); err != nil {
+		return nil, types.NewOpenAIError(err, types.ErrorCodeBadResponseBody, http.StatusInternalServerError)
+	}
+	openaiResp := responseCohere2OpenAI(&cohereResp, helper.GetResponseID(c), info.UpstreamModelName)
+	if openaiResp.Usage.TotalTokens == 0 {
+		usage := service.ResponseText2Usage(c, openaiResp.Choices[0].Message.StringContent(), info.UpstreamModelName, info.GetEstimatePromptTokens())
+		openaiResp.Usage = *usage
+	}
+	jsonResponse, err := common.Marshal(openaiResp)
 	if err != nil {
-		return nil, types.NewError(err, types.ErrorCodeBadResponseBody)
+		return nil, types.NewOpenAIError(err, types.ErrorCodeBadResponseBody, http.StatusInternalServerError)
 	}
-	usage := dto.Usage{}
-	usage.PromptTokens = cohereResp.Meta.BilledUnits.InputTokens
-	usage.CompletionTokens = cohereResp.Meta.BilledUnits.OutputTokens
-	usage.TotalTokens = cohereResp.Meta.BilledUnits.InputTokens + cohereResp.Meta.BilledUnits.OutputTokens
-
-	var openaiResp dto.TextResponse
-	openaiResp.Id = cohereResp.ResponseId
-	openaiResp.Created = createdTime
-	openaiResp.Object = "chat.completion"
-	openaiResp.Model = info.UpstreamModelName
-	openaiResp.Usage = usage
-
-	openaiResp.Choices = []dto.OpenAITextResponseChoice{
-		{
-			Index:        0,
-			Message:      dto.Message{Content: cohereResp.Text, Role: "assistant"},
-			FinishReason: stopReasonCohere2OpenAI(cohereResp.FinishReason),
+	service.IOCopyBytesGracefully(c, resp, jsonResponse)
+	return &openaiResp.Usage, nil
+}
+
+// cohereStreamConverter 将 Cohere v2 流式事件逐个转换为 OpenAI 流式块
+type cohereStreamConverter struct {
+	id      string
+	created int64
+	model   string
+
+	responseText strings.Builder
+	usage        *dto.Usage
+}
+
+func newCohereStreamConverter(id string, model string) *cohereStreamConverter {
+	return &cohereStreamConverter{
+		id:      id,
+		created: common.GetTimestamp(),
+		model:   model,
+	}
+}
+
+func (s *cohereStreamConverter) chunk(delta dto.ChatCompletionsStreamResponseChoiceDelta, finishReason *string) *dto.ChatCompletionsStreamResponse {
+	return &dto.ChatCompletionsStreamResponse{
+		Id:      s.id,
+		Object:  "chat.completion.chunk",
+		Created: s.created,
+		Model:   s.model,
+		Choices: []dto.ChatCompletionsStreamResponseChoice{
+			{Delta: delta, FinishReason: finishReason},
 		},
 	}
+}
 
-	jsonResponse, err := json.Marshal(openaiResp)
-	if err != nil {
-		return nil, types.NewError(err, types.ErrorCodeBadResponseBody)
+// handleEvent 处理一个事件，返回需要发送的流式块，没有可发送内容时返回 nil。
+// 工具调用的序号沿用事件的 index，引用转换为带 annotations 的块
+func (s *cohereStreamConverter) handleEvent(data string) (*dto.ChatCompletionsStreamResponse, error) {
+	var event CohereStreamEvent
+	if err := common.UnmarshalJsonStr(data, &event); err != nil {
+		return nil, err
 	}
-	c.Writer.Header().Set("Content-Type", "application/json")
-	c.Writer.WriteHeader(resp.StatusCode)
-	_, _ = c.Writer.Write(jsonResponse)
-	return &usage, nil
+	if event.Type == "message-start" {
+		return s.chunk(dto.ChatCompletionsStreamResponseChoiceDelta{Role: "assistant"}, nil), nil
+	}
+	if event.Delta == nil {
+		return nil, nil
+	}
+	if event.Type == "message-end" {
+		if usage := usageCohere2OpenAI(event.Delta.Usage); usage != nil {
+			s.usage = usage
+		}
+		finishReason := stopReasonCohere2OpenAI(event.Delta.FinishReason)
+		return s.chunk(dto.ChatCompletionsStreamResponseChoiceDelta{}, &finishReason), nil
+	}
+	message := event.Delta.Message
+	if message == nil {
+		return nil, nil
+	}
+	delta := dto.ChatCompletionsStreamResponseChoiceDelta{}
+	switch event.Type {
+	case "content-delta":
+		var content CohereContent
+		if err := common.Unmarshal(message.Content, &content); err != nil {
+			return nil, err
+		}
+		switch {
+		case content.Text != "":
+			s.responseText.WriteString(content.Text)
+			delta.SetContentString(content.Text)
+		case content.Thinking != "":
+			s.responseText.WriteString(content.Thinking)
+			delta.SetReasoningContent(content.Thinking)
+		default:
+			return nil, nil
+		}
+	case "tool-plan-delta":
+		if message.ToolPlan == "" {
+			return nil, nil
+		}
+		s.responseText.WriteString(message.ToolPlan)
+		delta.SetContentString(message.ToolPlan)
+	case "tool-call-start", "tool-call-delta":
+		var toolCall CohereToolCall
+		if err := common.Unmarshal(message.ToolCalls, &toolCall); err != nil {
+			return nil, err
+		}
+		s.responseText.WriteString(toolCall.Function.Arguments)
+		openaiToolCall := dto.ToolCallResponse{
+			Function: dto.FunctionResponse{Arguments: toolCall.Function.Arguments},
+		}
+		if event.Type == "tool-call-start" {
+			openaiToolCall.ID = toolCall.Id
+			openaiToolCall.Type = "function"
+			openaiToolCall.Function.Name = toolCall.Function.Name
+		}
+		openaiToolCall.SetIndex(event.Index)
+		delta.ToolCalls = []dto.ToolCallResponse{openaiToolCall}
+	case "citation-start":
+		var citation CohereCitation
+		if err := common.Unmarshal(message.Citations, &citation); err != nil {
+			return nil, err
+		}
+		annotation := citationToAnnotation(citation)
+		if annotation == nil {
+			return nil, nil
+		}
+		delta.Annotations = []any{annotation}
+	default:
+		return nil, nil
+	}
+	return s.chunk(delta, nil), nil
+}
+
+func cohereStreamHandler(c *gin.Context, info *relaycommon.RelayInfo, resp *http.Response) (*dto.Usage, *types.NewAPIError) {
+	converter := newCohereStreamConverter(helper.GetResponseID(c), info.UpstreamModelName)
+	helper.StreamScannerHandler(c, resp, info, func(data string) bool {
+		response, err := converter.handleEvent(data)
+		if err != nil {
+			logger.LogError(c, "error handling cohere stream event: "+err.Error())
+			return true
+		}
+		if response == nil {
+			return true
+		}
+		if err = helper.ObjectData(c, response); err != nil {
+			logger.LogError(c, "error sending cohere stream response: "+err.Error())
+		}
+		return true
+	})
+
+	usage := converter.usage
+	if usage == nil {
+		usage = service.ResponseText2Usage(c, converter.responseText.String(), info.UpstreamModelName, info.GetEstimatePromptTokens())
+	}
+	if info.ShouldIncludeUsage {
+		_ = helper.ObjectData(c, helper.GenerateFinalUsageResponse(converter.id, converter.created, info.UpstreamModelName, *usage))
+	}
+	helper.Done(c)
+	return usage, nil
 }
 
 func cohereRerankHandler(c *gin.Context, resp *http.Response, info *relaycommon.RelayInfo) (*dto.Usage, *types.NewAPIError) {
@@ -235,6 +512,10 @@ func cohereRerankHandler(c *gin.Context, resp *http.Response, info *relaycommon.
 		usage.CompletionTokens = cohereResp.Meta.BilledUnits.OutputTokens
 		usage.TotalTokens = cohereResp.Meta.BilledUnits.InputTokens + cohereResp.Meta.BilledUnits.OutputTokens
 	}
+	// Cohere 按搜索单元计费，记录上游实际计费的搜索单元数供结算使用
+	if cohereResp.Meta.BilledUnits.SearchUnits > 0 {
+		common.SetContextKey(c, constant.ContextKeyRerankSearchUnits, cohereResp.Meta.BilledUnits.SearchUnits)
+	}
 
 	var rerankResp dto.RerankResponse
 	rerankResp.Results = cohereResp.Results
@@ -0,0 +1,162 @@
+package cohere
+
+import (
+	"testing"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/dto"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRequestOpenAI2Cohere(t *testing.T) {
+	var request dto.GeneralOpenAIRequest
+	require.NoError(t, common.UnmarshalJsonStr(`{
+		"model": "command-a-03-2025",
+		"stream": true,
+		"max_tokens": 256,
+		"top_p": 0.9,
+		"stop": "END",
+		"messages": [
+			{"role": "developer", "content": "be brief"},
+			{"role": "user", "content": [{"type": "text", "text": "weather?"}, {"type": "image_url", "image_url": {"url": "https://example.com/a.png"}}]},
+			{"role": "assistant", "content": "I will look it up.", "tool_calls": [{"id": "call_1", "type": "function", "function": {"name": "get_weather", "arguments": "{\"city\":\"Paris\"}"}}]},
+			{"role": "tool", "tool_call_id": "call_1", "content": "sunny"}
+		],
+		"tools": [
+			{"type": "function", "function": {"name": "get_weather", "parameters": {"type": "object"}}},
+			{"type": "function", "function": {"name": "get_time", "parameters": {"type": "object"}}}
+		],
+		"tool_choice": {"type": "function", "function": {"name": "get_weather"}},
+		"response_format": {"type": "json_schema", "json_schema": {"name": "answer", "schema": {"type": "object"}}}
+	}`, &request))
+
+	cohereReq, err := requestOpenAI2Cohere(&request)
+	require.NoError(t, err)
+	assert.True(t, cohereReq.Stream)
+	assert.EqualValues(t, 256, cohereReq.MaxTokens)
+	assert.Equal(t, 0.9, *cohereReq.P)
+	assert.Equal(t, []string{"END"}, cohereReq.StopSequences)
+
+	require.Len(t, cohereReq.Messages, 4)
+	assert.Equal(t, CohereMessage{Role: "system", Content: "be brief"}, cohereReq.Messages[0])
+	assert.Equal(t, []CohereContent{
+		{Type: "text", Text: "weather?"},
+		{Type: "image_url", ImageUrl: &CohereImageUrl{Url: "https://example.com/a.png"}},
+	}, cohereReq.Messages[1].Content)
+	assert.Equal(t, "I will look it up.", cohereReq.Messages[2].ToolPlan)
+	assert.Nil(t, cohereReq.Messages[2].Content)
+	assert.Equal(t, []CohereToolCall{{Id: "call_1", Type: "function", Function: CohereToolCallFunction{Name: "get_weather", Arguments: `{"city":"Paris"}`}}}, cohereReq.Messages[2].ToolCalls)
+	assert.Equal(t, CohereMessage{Role: "tool", ToolCallId: "call_1", Content: "sunny"}, cohereReq.Messages[3])
+
+	// a forced function keeps only that tool
+	require.Len(t, cohereReq.Tools, 1)
+	assert.Equal(t, "get_weather", cohereReq.Tools[0].Function.Name)
+	assert.Equal(t, "REQUIRED", cohereReq.ToolChoice)
+
+	require.NotNil(t, cohereReq.ResponseFormat)
+	assert.Equal(t, "json_object", cohereReq.ResponseFormat.Type)
+	assert.Equal(t, map[string]any{"type": "object"}, cohereReq.ResponseFormat.JsonSchema)
+}
+
+func TestResponseCohere2OpenAI(t *testing.T) {
+	var cohereResp CohereChatResponse
+	require.NoError(t, common.UnmarshalJsonStr(`{
+		"id": "abc",
+		"finish_reason": "COMPLETE",
+		"message": {
+			"role": "assistant",
+			"content": [{"type": "thinking", "thinking": "hmm"}, {"type": "text", "text": "It is sunny in Paris."}],
+			"citations": [
+				{"start": 9, "end": 14, "text": "sunny", "type": "TEXT_CONTENT", "sources": [{"type": "tool", "id": "call_1:0", "tool_output": {"text": "sunny"}}]},
+				{"start": 18, "end": 23, "text": "Paris", "type": "TEXT_CONTENT", "sources": [{"type": "document", "id": "doc_0", "document": {"title": "Paris", "url": "https://example.com/paris"}}]},
+				{"start": 0, "end": 3, "text": "hmm", "type": "THINKING_CONTENT", "sources": [{"type": "tool", "id": "call_1:0"}]}
+			]
+		},
+		"usage": {"billed_units": {"input_tokens": 10, "output_tokens": 5}, "tokens": {"input_tokens": 120, "output_tokens": 7}}
+	}`, &cohereResp))
+
+	openaiResp := responseCohere2OpenAI(&cohereResp, "chatcmpl-1", "command-a-03-2025")
+	assert.Equal(t, "abc", openaiResp.Id)
+	choice := openaiResp.Choices[0]
+	assert.Equal(t, "stop", choice.FinishReason)
+	assert.Equal(t, "It is sunny in Paris.", choice.Message.StringContent())
+	assert.Equal(t, "hmm", choice.Message.ReasoningContent)
+	assert.Equal(t, []any{
+		map[string]any{"type": "url_citation", "url_citation": map[string]any{"start_index": 9, "end_index": 14, "url": "call_1:0", "title": "sunny"}},
+		map[string]any{"type": "url_citation", "url_citation": map[string]any{"start_index": 18, "end_index": 23, "url": "https://example.com/paris", "title": "Paris"}},
+	}, choice.Message.Annotations)
+	assert.Equal(t, 10, openaiResp.Usage.PromptTokens)
+	assert.Equal(t, 15, openaiResp.Usage.TotalTokens)
+
+	toolResp := responseCohere2OpenAI(&CohereChatResponse{
+		FinishReason: "TOOL_CALL",
+		Message: CohereResponseMessage{
+			ToolPlan:  "I will check the weather.",
+			ToolCalls: []CohereToolCall{{Id: "call_1", Type: "function", Function: CohereToolCallFunction{Name: "get_weather", Arguments: `{"city":"Paris"}`}}},
+		},
+	}, "chatcmpl-2", "command-a-03-2025")
+	assert.Equal(t, "chatcmpl-2", toolResp.Id)
+	assert.Equal(t, "tool_calls", toolResp.Choices[0].FinishReason)
+	assert.Equal(t, "I will check the weather.", toolResp.Choices[0].Message.StringContent())
+	toolCalls := toolResp.Choices[0].Message.ParseToolCalls()
+	require.Len(t, toolCalls, 1)
+	assert.Equal(t, "get_weather", toolCalls[0].Function.Name)
+}
+
+func TestCohereStreamConverter(t *testing.T) {
+	converter := newCohereStreamConverter("chatcmpl-1", "command-a-03-2025")
+	events := []string{
+		`{"type":"message-start","id":"abc","delta":{"message":{"role":"assistant","content":[],"tool_plan":"","tool_calls":[],"citations":[]}}}`,
+		`{"type":"tool-plan-delta","delta":{"message":{"tool_plan":"Checking."}}}`,
+		`{"type":"tool-call-start","index":0,"delta":{"message":{"tool_calls":{"id":"call_1","type":"function","function":{"name":"get_weather","arguments":""}}}}}`,
+		`{"type":"tool-call-delta","index":0,"delta":{"message":{"tool_calls":{"function":{"arguments":"{\"city\":\"Paris\"}"}}}}}`,
+		`{"type":"tool-call-end","index":0}`,
+		`{"type":"content-start","index":0,"delta":{"message":{"content":{"type":"text","text":""}}}}`,
+		`{"type":"content-delta","index":0,"delta":{"message":{"content":{"text":"Sunny"}}}}`,
+		`{"type":"citation-start","index":0,"delta":{"message":{"citations":{"start":0,"end":5,"text":"Sunny","sources":[{"type":"tool","id":"call_1:0"}],"type":"TEXT_CONTENT"}}}}`,
+		`{"type":"citation-end","index":0}`,
+		`{"type":"message-end","delta":{"finish_reason":"TOOL_CALL","usage":{"billed_units":{"input_tokens":12,"output_tokens":8}}}}`,
+	}
+	chunks := make([]*dto.ChatCompletionsStreamResponse, 0)
+	for _, event := range events {
+		chunk, err := converter.handleEvent(event)
+		require.NoError(t, err)
+		if chunk != nil {
+			chunks = append(chunks, chunk)
+		}
+	}
+	require.Len(t, chunks, 7)
+	assert.Equal(t, "assistant", chunks[0].Choices[0].Delta.Role)
+	assert.Equal(t, "Checking.", chunks[1].Choices[0].Delta.GetContentString())
+
+	start := chunks[2].Choices[0].Delta.ToolCalls[0]
+	assert.Equal(t, "call_1", start.ID)
+	assert.Equal(t, "get_weather", start.Function.Name)
+	assert.Equal(t, 0, *start.Index)
+	argumentsDelta := chunks[3].Choices[0].Delta.ToolCalls[0]
+	assert.Empty(t, argumentsDelta.ID)
+	assert.Equal(t, `{"city":"Paris"}`, argumentsDelta.Function.Arguments)
+
+	assert.Equal(t, "Sunny", chunks[4].Choices[0].Delta.GetContentString())
+	require.Len(t, chunks[5].Choices[0].Delta.Annotations, 1)
+	assert.Equal(t, "tool_calls", *chunks[6].Choices[0].FinishReason)
+	require.NotNil(t, converter.usage)
+	assert.Equal(t, 20, converter.usage.TotalTokens)
+}
+
+func TestRequestConvertRerank2Cohere(t *testing.T) {
+	var request dto.RerankRequest
+	require.NoError(t, common.UnmarshalJsonStr(`{"model": "rerank-v3.5", "query": "q", "documents": ["a", "b"]}`, &request))
+	cohereReq := requestConvertRerank2Cohere(request)
+	assert.Nil(t, cohereReq.TopN)
+	assert.False(t, cohereReq.ReturnDocuments)
+
+	request.TopK = common.GetPointer(1)
+	request.NormalizeTopN()
+	request.ReturnDocuments = common.GetPointer(true)
+	cohereReq = requestConvertRerank2Cohere(request)
+	assert.Equal(t, 1, *cohereReq.TopN)
+	assert.True(t, cohereReq.ReturnDocuments)
+}
@@ -242,14 +242,19 @@ func (a *Adaptor) ConvertOpenAIResponsesRequest(c *gin.Context, info *relaycommo
 	// Store original request in context for response conversion
 	c.Set("responses_original_request", &request)
 
+	// web_search is mapped to the native search tool of the channel
+	if apiErr := service.EnforceCompatMode(c, info, service.ResponsesToChatUnsupportedFeatures(&request, "web_search")); apiErr != nil {
+		return nil, apiErr
+	}
+
 	// Convert Responses request to Chat Completions request
-	chatReq, err := service.ResponsesRequestToChatCompletionsRequest(&request)
+	chatReq, err := service.ResponsesRequestToChatCompletionsRequest(info, &request)
 	if err != nil {
 		return nil, err
 	}
 
 	// Set stream flag
-	info.IsStream = request.Stream
+	info.IsStream = lo.FromPtrOr(request.Stream, false)
 
 	// Convert Chat Completions request to Gemini format
 	return a.ConvertOpenAIRequest(c, info, chatReq)
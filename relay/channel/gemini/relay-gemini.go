@@ -373,9 +373,10 @@ func CovertOpenAI2Gemini(c *gin.Context, textRequest dto.GeneralOpenAIRequest, i
 	geminiRequest.SafetySettings = safetySettings
 
 	// openaiContent.FuncToToolCalls()
-	if textRequest.Tools != nil {
+	if textRequest.Tools != nil || textRequest.WebSearchOptions != nil {
 		functions := make([]dto.FunctionRequest, 0, len(textRequest.Tools))
-		googleSearch := false
+		// web_search_options (and the Responses web_search tool carried by it) maps to googleSearch
+		googleSearch := textRequest.WebSearchOptions != nil
 		codeExecution := false
 		urlContext := false
 		for _, tool := range textRequest.Tools {
@@ -1833,7 +1834,7 @@ func GeminiResponsesHandler(c *gin.Context, info *relaycommon.RelayInfo, resp *h
 	}
 
 	// Convert Chat response to Responses format
-	responsesResponse := service.ChatCompletionsResponseToResponsesResponse(fullTextResponse, originalReq)
+	responsesResponse := service.ChatCompletionsResponseToResponsesResponse(fullTextResponse, originalReq, info)
 
 	// Marshal and send response
 	jsonResponse, err := common.Marshal(responsesResponse)
@@ -1841,6 +1842,7 @@ func GeminiResponsesHandler(c *gin.Context, info *relaycommon.RelayInfo, resp *h
 		return nil, types.NewError(err, types.ErrorCodeBadResponseBody)
 	}
 
+	service.SaveResponsesConversation(c, info, responsesResponse.ID, service.ChatResponseAssistantMessage(fullTextResponse), responsesResponse)
 	service.IOCopyBytesGracefully(c, resp, jsonResponse)
 	return &usage, nil
 }
@@ -1854,7 +1856,8 @@ func GeminiResponsesStreamHandler(c *gin.Context, info *relaycommon.RelayInfo, r
 	}
 
 	// Create stream adapter
-	streamAdapter := service.NewChatToResponsesStreamAdapter(originalReq)
+	streamAdapter := service.NewChatToResponsesStreamAdapter(c, originalReq, info)
+	defer streamAdapter.Close()
 
 	id := helper.GetResponseID(c)
 	createAt := common.GetTimestamp()
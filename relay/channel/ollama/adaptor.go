@@ -33,7 +33,12 @@ func (a *Adaptor) ConvertClaudeRequest(c *gin.Context, info *relaycommon.RelayIn
 		IncludeUsage: true,
 	}
 	// map to ollama chat request (Claude -> OpenAI -> Ollama chat)
-	return openAIChatToOllamaChat(c, openaiRequest.(*dto.GeneralOpenAIRequest))
+	chatReq, err := openAIChatToOllamaChat(c, openaiRequest.(*dto.GeneralOpenAIRequest))
+	if err != nil {
+		return nil, err
+	}
+	applyChannelOptions(info, chatReq)
+	return chatReq, nil
 }
 
 func (a *Adaptor) ConvertAudioRequest(c *gin.Context, info *relaycommon.RelayInfo, request dto.AudioRequest) (io.Reader, error) {
@@ -69,9 +74,19 @@ func (a *Adaptor) ConvertOpenAIRequest(c *gin.Context, info *relaycommon.RelayIn
 	}
 	// decide generate or chat
 	if strings.Contains(info.RequestURLPath, "/v1/completions") || info.RelayMode == relayconstant.RelayModeCompletions {
-		return openAIToGenerate(c, request)
+		gen, err := openAIToGenerate(c, request)
+		if err != nil {
+			return nil, err
+		}
+		applyChannelOptions(info, gen)
+		return gen, nil
+	}
+	chatReq, err := openAIChatToOllamaChat(c, request)
+	if err != nil {
+		return nil, err
 	}
-	return openAIChatToOllamaChat(c, request)
+	applyChannelOptions(info, chatReq)
+	return chatReq, nil
 }
 
 func (a *Adaptor) ConvertRerankRequest(c *gin.Context, relayMode int, request dto.RerankRequest) (any, error) {
@@ -79,7 +94,9 @@ func (a *Adaptor) ConvertRerankRequest(c *gin.Context, relayMode int, request dt
 }
 
 func (a *Adaptor) ConvertEmbeddingRequest(c *gin.Context, info *relaycommon.RelayInfo, request dto.EmbeddingRequest) (any, error) {
-	return requestOpenAI2Embeddings(request), nil
+	embeddingReq := requestOpenAI2Embeddings(request)
+	applyChannelOptions(info, embeddingReq)
+	return embeddingReq, nil
 }
 
 func (a *Adaptor) ConvertOpenAIResponsesRequest(c *gin.Context, info *relaycommon.RelayInfo, request dto.OpenAIResponsesRequest) (any, error) {
@@ -0,0 +1,66 @@
+package ollama
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+)
+
+// ParseKeepAlive 解析渠道设置的 keep_alive：纯数字视为秒数（负数表示常驻内存），
+// 其余须为时长格式，如 5m、1h30m。空字符串返回 nil，表示使用 Ollama 的默认值
+func ParseKeepAlive(value string) (any, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return nil, nil
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		return seconds, nil
+	}
+	if _, err := time.ParseDuration(value); err != nil {
+		return nil, fmt.Errorf("invalid keep_alive %q: must be seconds or a duration such as 5m", value)
+	}
+	return value, nil
+}
+
+// applyChannelOptions 将渠道设置的 keep_alive 与 num_ctx 写入转换后的请求，请求已指定的值不覆盖
+func applyChannelOptions(info *relaycommon.RelayInfo, request any) {
+	if info == nil || info.ChannelMeta == nil {
+		return
+	}
+	settings := info.ChannelOtherSettings
+	// 保存渠道时已校验，解析失败时忽略该设置
+	keepAlive, _ := ParseKeepAlive(settings.OllamaKeepAlive)
+	switch r := request.(type) {
+	case *OllamaChatRequest:
+		if r.KeepAlive == nil {
+			r.KeepAlive = keepAlive
+		}
+		r.Options = withNumCtx(r.Options, settings.OllamaNumCtx)
+	case *OllamaGenerateRequest:
+		if r.KeepAlive == nil {
+			r.KeepAlive = keepAlive
+		}
+		r.Options = withNumCtx(r.Options, settings.OllamaNumCtx)
+	case *OllamaEmbeddingRequest:
+		if r.KeepAlive == nil {
+			r.KeepAlive = keepAlive
+		}
+		r.Options = withNumCtx(r.Options, settings.OllamaNumCtx)
+	}
+}
+
+func withNumCtx(options map[string]any, numCtx int) map[string]any {
+	if numCtx <= 0 {
+		return options
+	}
+	if options == nil {
+		options = map[string]any{}
+	}
+	if _, ok := options["num_ctx"]; !ok {
+		options["num_ctx"] = numCtx
+	}
+	return options
+}
@@ -0,0 +1,55 @@
+package ollama
+
+import (
+	"testing"
+
+	"github.com/QuantumNous/new-api/dto"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseKeepAlive(t *testing.T) {
+	keepAlive, err := ParseKeepAlive("")
+	require.NoError(t, err)
+	assert.Nil(t, keepAlive)
+
+	keepAlive, err = ParseKeepAlive("-1")
+	require.NoError(t, err)
+	assert.Equal(t, -1, keepAlive)
+
+	keepAlive, err = ParseKeepAlive(" 30m ")
+	require.NoError(t, err)
+	assert.Equal(t, "30m", keepAlive)
+
+	_, err = ParseKeepAlive("forever")
+	assert.Error(t, err)
+}
+
+func TestApplyChannelOptions(t *testing.T) {
+	info := &relaycommon.RelayInfo{ChannelMeta: &relaycommon.ChannelMeta{
+		ChannelOtherSettings: dto.ChannelOtherSettings{OllamaKeepAlive: "10m", OllamaNumCtx: 8192},
+	}}
+
+	chatReq := &OllamaChatRequest{Options: map[string]any{}}
+	applyChannelOptions(info, chatReq)
+	assert.Equal(t, "10m", chatReq.KeepAlive)
+	assert.Equal(t, 8192, chatReq.Options["num_ctx"])
+
+	// values already present on the request win
+	gen := &OllamaGenerateRequest{KeepAlive: 0, Options: map[string]any{"num_ctx": 2048}}
+	applyChannelOptions(info, gen)
+	assert.Equal(t, 0, gen.KeepAlive)
+	assert.Equal(t, 2048, gen.Options["num_ctx"])
+
+	embeddingReq := requestOpenAI2Embeddings(dto.EmbeddingRequest{Model: "nomic-embed-text", Input: "hello"})
+	applyChannelOptions(info, embeddingReq)
+	assert.Equal(t, "10m", embeddingReq.KeepAlive)
+	assert.Equal(t, 8192, embeddingReq.Options["num_ctx"])
+
+	unset := &OllamaChatRequest{}
+	applyChannelOptions(&relaycommon.RelayInfo{ChannelMeta: &relaycommon.ChannelMeta{}}, unset)
+	assert.Nil(t, unset.KeepAlive)
+	assert.Nil(t, unset.Options)
+}
@@ -26,6 +26,9 @@ import (
 	relaycommon "github.com/QuantumNous/new-api/relay/common"
 	"github.com/QuantumNous/new-api/relay/common_handler"
 	relayconstant "github.com/QuantumNous/new-api/relay/constant"
+	"github.com/QuantumNous/new-api/relay/rerank"
+	"github.com/QuantumNous/new-api/relay/speech"
+	"github.com/QuantumNous/new-api/relay/transcription"
 	"github.com/QuantumNous/new-api/service"
 	"github.com/QuantumNous/new-api/setting/model_setting"
 	"github.com/QuantumNous/new-api/types"
@@ -37,6 +40,9 @@ import (
 type Adaptor struct {
 	ChannelType    int
 	ResponseFormat string
+	AudioLanguage  string
+	SpeechVoice    string
+	RerankProtocol string
 }
 
 // parseReasoningEffortFromModelSuffix 从模型名称中解析推理级别
@@ -97,6 +103,7 @@ func (a *Adaptor) ConvertClaudeRequest(c *gin.Context, info *relaycommon.RelayIn
 
 func (a *Adaptor) Init(info *relaycommon.RelayInfo) {
 	a.ChannelType = info.ChannelType
+	a.RerankProtocol = info.ChannelOtherSettings.RerankProtocol
 
 	// initialize ThinkingContentInfo when thinking_to_content is enabled
 	if info.ChannelSetting.ThinkingToContent {
@@ -109,6 +116,12 @@ func (a *Adaptor) Init(info *relaycommon.RelayInfo) {
 }
 
 func (a *Adaptor) GetRequestURL(info *relaycommon.RelayInfo) (string, error) {
+	if deepgramTranscription(info) {
+		return transcription.DeepgramListenURL(info.ChannelBaseUrl, info.UpstreamModelName, a.AudioLanguage)
+	}
+	if elevenLabsSpeech(info) {
+		return speech.ElevenLabsStreamURL(info.ChannelBaseUrl, a.SpeechVoice, a.ResponseFormat)
+	}
 	if info.RelayMode == relayconstant.RelayModeRealtime {
 		if strings.HasPrefix(info.ChannelBaseUrl, "https://") {
 			baseUrl := strings.TrimPrefix(info.ChannelBaseUrl, "https://")
@@ -138,27 +151,15 @@ func (a *Adaptor) GetRequestURL(info *relaycommon.RelayInfo) (string, error) {
 
 		// 特殊处理 responses API
 		if info.RelayMode == relayconstant.RelayModeResponses {
-			responsesApiVersion := "preview"
-
-			subUrl := "/openai/v1/responses"
-			if strings.Contains(info.ChannelBaseUrl, "cognitiveservices.azure.com") {
-				subUrl = "/openai/responses"
-				responsesApiVersion = apiVersion
-			}
-
-			if info.ChannelOtherSettings.AzureResponsesVersion != "" {
-				responsesApiVersion = info.ChannelOtherSettings.AzureResponsesVersion
+			endpoint := info.AzureResponsesEndpoint
+			if endpoint == nil {
+				endpoint = &AzureResponsesEndpoints(info)[0]
 			}
-
-			requestURL = fmt.Sprintf("%s?api-version=%s", subUrl, responsesApiVersion)
+			requestURL = fmt.Sprintf("%s?api-version=%s", endpoint.Path, endpoint.ApiVersion)
 			return relaycommon.GetFullRequestURL(info.ChannelBaseUrl, requestURL, info.ChannelType), nil
 		}
 
-		model_ := info.UpstreamModelName
-		// 2025年5月10日后创建的渠道不移除.
-		if info.ChannelCreateTime < constant.AzureNoRemoveDotTime {
-			model_ = strings.Replace(model_, ".", "", -1)
-		}
+		model_ := azureDeploymentName(info, info.UpstreamModelName)
 		// https://github.com/songquanpeng/one-api/issues/67
 		requestURL = fmt.Sprintf("/openai/deployments/%s/%s", model_, task)
 		if info.RelayMode == relayconstant.RelayModeRealtime {
@@ -183,6 +184,14 @@ func (a *Adaptor) GetRequestURL(info *relaycommon.RelayInfo) (string, error) {
 
 func (a *Adaptor) SetupRequestHeader(c *gin.Context, header *http.Header, info *relaycommon.RelayInfo) error {
 	channel.SetupApiRequestHeader(info, c, header)
+	if deepgramTranscription(info) {
+		header.Set("Authorization", "Token "+info.ApiKey)
+		return nil
+	}
+	if elevenLabsSpeech(info) {
+		header.Set("xi-api-key", info.ApiKey)
+		return nil
+	}
 	if info.ChannelType == constant.ChannelTypeAzure {
 		header.Set("api-key", info.ApiKey)
 		return nil
@@ -204,17 +213,14 @@ func (a *Adaptor) SetupRequestHeader(c *gin.Context, header *http.Header, info *
 	if info.RelayMode == relayconstant.RelayModeRealtime {
 		swp := c.Request.Header.Get("Sec-WebSocket-Protocol")
 		if swp != "" {
-			items := []string{
-				"realtime",
-				"openai-insecure-api-key." + info.ApiKey,
-				"openai-beta.realtime-v1",
-			}
-			header.Set("Sec-WebSocket-Protocol", strings.Join(items, ","))
+			header.Set("Sec-WebSocket-Protocol", realtimeSubprotocols(swp, info.ApiKey))
 			//req.Header.Set("Sec-WebSocket-Key", c.Request.Header.Get("Sec-WebSocket-Key"))
 			//req.Header.Set("Sec-Websocket-Extensions", c.Request.Header.Get("Sec-Websocket-Extensions"))
 			//req.Header.Set("Sec-Websocket-Version", c.Request.Header.Get("Sec-Websocket-Version"))
 		} else {
-			header.Set("openai-beta", "realtime=v1")
+			if beta := realtimeBetaHeader(c.Request.Header.Get("OpenAI-Beta"), info.UpstreamModelName); beta != "" {
+				header.Set("OpenAI-Beta", beta)
+			}
 			if !hasAuthOverride {
 				header.Set("Authorization", "Bearer "+info.ApiKey)
 			}
@@ -231,6 +237,32 @@ func (a *Adaptor) SetupRequestHeader(c *gin.Context, header *http.Header, info *
 	return nil
 }
 
+// realtimeSubprotocols 改写客户端声明的 WebSocket 子协议：仅将其中的网关令牌替换为渠道密钥，
+// 其余协议原样透传。客户端声明了 openai-beta.realtime-v1 时走 beta 版本，否则走 GA 版本 Realtime API
+func realtimeSubprotocols(clientProtocols string, apiKey string) string {
+	items := []string{"realtime", "openai-insecure-api-key." + apiKey}
+	for _, part := range strings.Split(clientProtocols, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" || part == "realtime" || strings.HasPrefix(part, "openai-insecure-api-key.") {
+			continue
+		}
+		items = append(items, part)
+	}
+	return strings.Join(items, ", ")
+}
+
+// realtimeBetaHeader 决定通过请求头鉴权时上游的 OpenAI-Beta 头：优先透传客户端的值，
+// 客户端未声明时仅为 beta 时期的 preview 模型补上 realtime=v1，GA 模型不再需要
+func realtimeBetaHeader(clientBeta string, upstreamModel string) string {
+	if clientBeta != "" {
+		return clientBeta
+	}
+	if strings.Contains(upstreamModel, "realtime-preview") {
+		return "realtime=v1"
+	}
+	return ""
+}
+
 func (a *Adaptor) ConvertOpenAIRequest(c *gin.Context, info *relaycommon.RelayInfo, request *dto.GeneralOpenAIRequest) (any, error) {
 	if request == nil {
 		return nil, errors.New("request is nil")
@@ -355,6 +387,9 @@ func (a *Adaptor) ConvertOpenAIRequest(c *gin.Context, info *relaycommon.RelayIn
 }
 
 func (a *Adaptor) ConvertRerankRequest(c *gin.Context, relayMode int, request dto.RerankRequest) (any, error) {
+	if a.RerankProtocol == rerank.ProtocolVoyage {
+		return rerank.ConvertVoyageRequest(request)
+	}
 	return request, nil
 }
 
@@ -365,12 +400,30 @@ func (a *Adaptor) ConvertEmbeddingRequest(c *gin.Context, info *relaycommon.Rela
 func (a *Adaptor) ConvertAudioRequest(c *gin.Context, info *relaycommon.RelayInfo, request dto.AudioRequest) (io.Reader, error) {
 	a.ResponseFormat = request.ResponseFormat
 	if info.RelayMode == relayconstant.RelayModeAudioSpeech {
+		if elevenLabsSpeech(info) {
+			a.SpeechVoice = request.Voice
+			converted, err := speech.ConvertElevenLabsRequest(request, info.UpstreamModelName)
+			if err != nil {
+				return nil, err
+			}
+			jsonData, err := common.Marshal(converted)
+			if err != nil {
+				return nil, fmt.Errorf("error marshalling object: %w", err)
+			}
+			return bytes.NewReader(jsonData), nil
+		}
 		jsonData, err := json.Marshal(request)
 		if err != nil {
 			return nil, fmt.Errorf("error marshalling object: %w", err)
 		}
 		return bytes.NewReader(jsonData), nil
 	} else {
+		a.AudioLanguage = request.Language
+		if deepgramTranscription(info) {
+			return convertDeepgramAudioRequest(c, info)
+		}
+		// Groq 托管的 whisper 不支持流式与分块参数，流式输出由网关转换
+		groqWhisper := transcription.IsGroqWhisperModel(info.UpstreamModelName)
 		var requestBody bytes.Buffer
 		writer := multipart.NewWriter(&requestBody)
 
@@ -386,7 +439,7 @@ func (a *Adaptor) ConvertAudioRequest(c *gin.Context, info *relaycommon.RelayInf
 
 		// 遍历表单字段并打印输出
 		for key, values := range formData.Value {
-			if key == "model" {
+			if key == "model" || (groqWhisper && transcription.GroqUnsupportedField(key)) {
 				continue
 			}
 			for _, value := range values {
@@ -592,6 +645,10 @@ func (a *Adaptor) ConvertOpenAIResponsesRequest(c *gin.Context, info *relaycommo
 	if info != nil && request.Reasoning != nil && request.Reasoning.Effort != "" {
 		info.ReasoningEffort = request.Reasoning.Effort
 	}
+	// Azure 的 Responses API 以部署名作为 model
+	if info != nil && info.ChannelType == constant.ChannelTypeAzure {
+		request.Model = azureDeploymentName(info, request.Model)
+	}
 	return request, nil
 }
 
@@ -616,7 +673,11 @@ func (a *Adaptor) DoResponse(c *gin.Context, resp *http.Response, info *relaycom
 	case relayconstant.RelayModeAudioTranslation:
 		fallthrough
 	case relayconstant.RelayModeAudioTranscription:
-		err, usage = OpenaiSTTHandler(c, resp, info, a.ResponseFormat)
+		if deepgramTranscription(info) {
+			err, usage = DeepgramSTTHandler(c, resp, info, a.ResponseFormat)
+		} else {
+			err, usage = OpenaiSTTHandler(c, resp, info, a.ResponseFormat)
+		}
 	case relayconstant.RelayModeImagesGenerations, relayconstant.RelayModeImagesEdits:
 		usage, err = OpenaiHandlerWithUsage(c, info, resp)
 	case relayconstant.RelayModeRerank:
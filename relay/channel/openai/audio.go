@@ -2,18 +2,25 @@ package openai
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"io"
 	"math"
 	"net/http"
+	"os"
+	"strings"
 
 	"github.com/QuantumNous/new-api/common"
 	"github.com/QuantumNous/new-api/constant"
 	"github.com/QuantumNous/new-api/dto"
 	"github.com/QuantumNous/new-api/logger"
 	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	relayconstant "github.com/QuantumNous/new-api/relay/constant"
 	"github.com/QuantumNous/new-api/relay/helper"
+	"github.com/QuantumNous/new-api/relay/speech"
+	"github.com/QuantumNous/new-api/relay/transcription"
 	"github.com/QuantumNous/new-api/service"
+	"github.com/QuantumNous/new-api/setting/model_setting"
 	"github.com/QuantumNous/new-api/types"
 	"github.com/gin-gonic/gin"
 )
@@ -53,53 +60,30 @@ func OpenaiTTSHandler(c *gin.Context, resp *http.Response, info *relaycommon.Rel
 		})
 	} else {
 		common.SetContextKey(c, constant.ContextKeyLocalCountTokens, true)
-		// 读取响应体到缓冲区
-		bodyBytes, err := io.ReadAll(resp.Body)
-		if err != nil {
-			logger.LogError(c, fmt.Sprintf("failed to read TTS response body: %v", err))
-			c.Writer.WriteHeaderNow()
-			return usage
-		}
-
-		// 写入响应到客户端
-		c.Writer.WriteHeaderNow()
-		_, err = c.Writer.Write(bodyBytes)
-		if err != nil {
-			logger.LogError(c, fmt.Sprintf("failed to write TTS response: %v", err))
-		}
-
-		// 计算音频时长并更新 usage
 		audioFormat := "mp3" // 默认格式
 		if audioReq, ok := info.Request.(*dto.AudioRequest); ok && audioReq.ResponseFormat != "" {
 			audioFormat = audioReq.ResponseFormat
 		}
-
-		var duration float64
-		var durationErr error
-
-		if audioFormat == "pcm" {
-			// PCM 格式没有文件头，根据 OpenAI TTS 的 PCM 参数计算时长
-			// 采样率: 24000 Hz, 位深度: 16-bit (2 bytes), 声道数: 1
-			const sampleRate = 24000
-			const bytesPerSample = 2
-			const channels = 1
-			duration = float64(len(bodyBytes)) / float64(sampleRate*bytesPerSample*channels)
-		} else {
-			ext := "." + audioFormat
-			reader := bytes.NewReader(bodyBytes)
-			duration, durationErr = common.GetAudioDuration(c.Request.Context(), reader, ext)
+		// 按字符计费的模型不需要音频时长
+		needDuration := true
+		if billing, _, ok := service.AudioSpeechPricing(info); ok && billing == model_setting.SpeechBillingPerCharacter {
+			needDuration = false
 		}
+		size, duration, durationErr := relaySpeechAudio(c, resp.Body, audioFormat, needDuration)
 
 		usage.PromptTokensDetails.TextTokens = usage.PromptTokens
-
+		if !needDuration {
+			return usage
+		}
 		if durationErr != nil {
 			logger.LogWarn(c, fmt.Sprintf("failed to get audio duration: %v", durationErr))
 			// 如果无法获取时长，则设置保底的 CompletionTokens，根据body大小计算
-			sizeInKB := float64(len(bodyBytes)) / 1000.0
+			sizeInKB := float64(size) / 1000.0
 			estimatedTokens := int(math.Ceil(sizeInKB)) // 粗略估算每KB约等于1 token
 			usage.CompletionTokens = estimatedTokens
 			usage.CompletionTokenDetails.AudioTokens = estimatedTokens
 		} else if duration > 0 {
+			common.SetContextKey(c, constant.ContextKeyAudioDuration, duration)
 			// 计算 token: ceil(duration) / 60.0 * 1000，即每分钟 1000 tokens
 			completionTokens := int(math.Round(math.Ceil(duration) / 60.0 * 1000))
 			usage.CompletionTokens = completionTokens
@@ -111,35 +95,216 @@ func OpenaiTTSHandler(c *gin.Context, resp *http.Response, info *relaycommon.Rel
 	return usage
 }
 
+// flushWriter 每次写入后立即刷新，使音频分片到达后即转发给客户端
+type flushWriter struct {
+	c *gin.Context
+}
+
+func (w flushWriter) Write(p []byte) (int, error) {
+	n, err := w.c.Writer.Write(p)
+	if err == nil {
+		w.c.Writer.Flush()
+	}
+	return n, err
+}
+
+// relaySpeechAudio 边接收边转发合成的音频，不在内存中缓存整个文件。需要时长时 PCM 按字节数计算，
+// 其他格式同时写入临时文件，转发结束后解析时长
+func relaySpeechAudio(c *gin.Context, body io.Reader, audioFormat string, needDuration bool) (int64, float64, error) {
+	c.Writer.WriteHeaderNow()
+	var spool *os.File
+	var writer io.Writer = flushWriter{c: c}
+	if needDuration && audioFormat != "pcm" {
+		file, err := os.CreateTemp("", "speech-*."+audioFormat)
+		if err != nil {
+			logger.LogWarn(c, fmt.Sprintf("failed to create speech spool file: %v", err))
+		} else {
+			spool = file
+			defer func() {
+				_ = spool.Close()
+				_ = os.Remove(spool.Name())
+			}()
+			writer = io.MultiWriter(writer, spool)
+		}
+	}
+	size, err := io.Copy(writer, body)
+	if err != nil {
+		logger.LogError(c, fmt.Sprintf("failed to relay TTS response: %v", err))
+	}
+	if !needDuration {
+		return size, 0, nil
+	}
+	if audioFormat == "pcm" {
+		return size, speech.PCMDuration(size), nil
+	}
+	if spool == nil {
+		return size, 0, errors.New("speech spool file is not available")
+	}
+	if _, err := spool.Seek(0, io.SeekStart); err != nil {
+		return size, 0, err
+	}
+	duration, err := common.GetAudioDuration(c.Request.Context(), spool, "."+audioFormat)
+	return size, duration, err
+}
+
+// elevenLabsSpeech 渠道语音合成协议设置为 elevenlabs 时，语音合成改走 ElevenLabs 流式合成接口
+func elevenLabsSpeech(info *relaycommon.RelayInfo) bool {
+	return info.RelayMode == relayconstant.RelayModeAudioSpeech && info.ChannelOtherSettings.SpeechProtocol == speech.ProtocolElevenLabs
+}
+
 func OpenaiSTTHandler(c *gin.Context, resp *http.Response, info *relaycommon.RelayInfo, responseFormat string) (*types.NewAPIError, *dto.Usage) {
+	if strings.HasPrefix(resp.Header.Get("Content-Type"), "text/event-stream") {
+		return nil, openaiSTTStreamHandler(c, resp, info)
+	}
 	defer service.CloseResponseBodyGracefully(resp)
 
 	responseBody, err := io.ReadAll(resp.Body)
 	if err != nil {
 		return types.NewOpenAIError(err, types.ErrorCodeReadResponseBodyFailed, http.StatusInternalServerError), nil
 	}
-	// 写入新的 response body
-	service.IOCopyBytesGracefully(c, resp, responseBody)
+	result := transcription.ParseOpenAIFileResponse(responseBody, responseFormat)
+	if result.Duration > 0 {
+		common.SetContextKey(c, constant.ContextKeyAudioDuration, result.Duration)
+	}
+	if info.IsStream {
+		// 上游（如 whisper-1、Groq）不支持流式转写时，将完整结果转换为流式事件
+		writeTranscriptionStream(c, result)
+	} else {
+		// 写入新的 response body
+		service.IOCopyBytesGracefully(c, resp, responseBody)
+	}
 
 	var responseData struct {
 		Usage *dto.Usage `json:"usage"`
 	}
 	if err := common.Unmarshal(responseBody, &responseData); err == nil && responseData.Usage != nil {
-		if responseData.Usage.TotalTokens > 0 {
-			usage := responseData.Usage
-			if usage.PromptTokens == 0 {
-				usage.PromptTokens = usage.InputTokens
+		if usage := normalizeSTTUsage(responseData.Usage); usage != nil {
+			return nil, usage
+		}
+	}
+	return nil, estimateSTTUsage(info)
+}
+
+// openaiSTTStreamHandler 透传上游的流式转写事件，并从 transcript.text.done 事件中读取用量与音频时长
+func openaiSTTStreamHandler(c *gin.Context, resp *http.Response, info *relaycommon.RelayInfo) *dto.Usage {
+	var usage *dto.Usage
+	helper.StreamScannerHandler(c, resp, info, func(data string) bool {
+		if service.SundaySearch(data, "transcript.text.done") {
+			var event struct {
+				Usage *dto.Usage `json:"usage"`
 			}
-			if usage.CompletionTokens == 0 {
-				usage.CompletionTokens = usage.OutputTokens
+			if err := common.Unmarshal([]byte(data), &event); err != nil {
+				logger.LogError(c, err.Error())
+			} else if event.Usage != nil {
+				usage = normalizeSTTUsage(event.Usage)
 			}
-			return nil, usage
+			if result := transcription.ParseOpenAIFileResponse([]byte(data), "json"); result.Duration > 0 {
+				common.SetContextKey(c, constant.ContextKeyAudioDuration, result.Duration)
+			}
+		}
+		_ = helper.StringData(c, data)
+		return true
+	})
+	if usage == nil {
+		return estimateSTTUsage(info)
+	}
+	return usage
+}
+
+// DeepgramSTTHandler 将 Deepgram 预录音频转写结果转换为 OpenAI 转写响应格式
+func DeepgramSTTHandler(c *gin.Context, resp *http.Response, info *relaycommon.RelayInfo, responseFormat string) (*types.NewAPIError, *dto.Usage) {
+	defer service.CloseResponseBodyGracefully(resp)
+
+	responseBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return types.NewOpenAIError(err, types.ErrorCodeReadResponseBodyFailed, http.StatusInternalServerError), nil
+	}
+	result, err := transcription.ParseDeepgramFileResponse(responseBody)
+	if err != nil {
+		return types.NewOpenAIError(err, types.ErrorCodeBadResponseBody, http.StatusInternalServerError), nil
+	}
+	if result.Duration > 0 {
+		common.SetContextKey(c, constant.ContextKeyAudioDuration, result.Duration)
+	}
+	if info.IsStream {
+		writeTranscriptionStream(c, result)
+		return nil, estimateSTTUsage(info)
+	}
+	contentType, body, err := transcription.FormatFileResult(result, responseFormat)
+	if err != nil {
+		return types.NewOpenAIError(err, types.ErrorCodeBadResponseBody, http.StatusInternalServerError), nil
+	}
+	c.Data(http.StatusOK, contentType, body)
+	return nil, estimateSTTUsage(info)
+}
+
+// writeTranscriptionStream 以 OpenAI 流式转写事件输出完整的转写结果
+func writeTranscriptionStream(c *gin.Context, result *transcription.FileResult) {
+	helper.SetEventStreamHeaders(c)
+	for _, event := range transcription.StreamEvents(result) {
+		if err := helper.ObjectData(c, event); err != nil {
+			logger.LogError(c, "write transcription stream failed: "+err.Error())
+			return
 		}
 	}
+}
 
+// normalizeSTTUsage 将按 token 计量的转写用量转换为统一格式，按时长计量（无 token）时返回 nil
+func normalizeSTTUsage(usage *dto.Usage) *dto.Usage {
+	if usage.TotalTokens <= 0 {
+		return nil
+	}
+	if usage.PromptTokens == 0 {
+		usage.PromptTokens = usage.InputTokens
+	}
+	if usage.CompletionTokens == 0 {
+		usage.CompletionTokens = usage.OutputTokens
+	}
+	return usage
+}
+
+func estimateSTTUsage(info *relaycommon.RelayInfo) *dto.Usage {
 	usage := &dto.Usage{}
 	usage.PromptTokens = info.GetEstimatePromptTokens()
 	usage.CompletionTokens = 0
 	usage.TotalTokens = usage.PromptTokens + usage.CompletionTokens
-	return nil, usage
+	return usage
+}
+
+// convertDeepgramAudioRequest Deepgram 预录音频接口直接接收音频二进制，转写参数通过查询参数传递
+func convertDeepgramAudioRequest(c *gin.Context, info *relaycommon.RelayInfo) (io.Reader, error) {
+	if info.RelayMode == relayconstant.RelayModeAudioTranslation {
+		return nil, errors.New("deepgram does not support audio translation")
+	}
+	formData, err := common.ParseMultipartFormReusable(c)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing multipart form: %w", err)
+	}
+	fileHeaders := formData.File["file"]
+	if len(fileHeaders) == 0 {
+		return nil, errors.New("file is required")
+	}
+	file, err := fileHeaders[0].Open()
+	if err != nil {
+		return nil, fmt.Errorf("error opening audio file: %v", err)
+	}
+	defer file.Close()
+	audio, err := io.ReadAll(file)
+	if err != nil {
+		return nil, fmt.Errorf("error reading audio file: %v", err)
+	}
+	contentType := fileHeaders[0].Header.Get("Content-Type")
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	c.Request.Header.Set("Content-Type", contentType)
+	return bytes.NewReader(audio), nil
+}
+
+// deepgramTranscription 渠道转写协议设置为 deepgram 时，文件转写改走 Deepgram 预录音频接口
+func deepgramTranscription(info *relaycommon.RelayInfo) bool {
+	if info.RelayMode != relayconstant.RelayModeAudioTranscription && info.RelayMode != relayconstant.RelayModeAudioTranslation {
+		return false
+	}
+	return info.ChannelOtherSettings.RealtimeTranscriptionProtocol == transcription.ProtocolDeepgram
 }
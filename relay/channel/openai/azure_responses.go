@@ -0,0 +1,118 @@
+package openai
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/QuantumNous/new-api/constant"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+)
+
+// Azure OpenAI 的 Responses API 有两种接口：v1 接口 /openai/v1/responses（api-version=preview），
+// 以及按日期版本的 /openai/responses（api-version 与渠道一致）。渠道未指定 Responses 版本时依次尝试两种接口，
+// 记住渠道可用的接口；部署不支持 Responses API 时，该部署的请求在一段时间内改走 Chat Completions
+const (
+	azureResponsesV1Path         = "/openai/v1/responses"
+	azureResponsesDatedPath      = "/openai/responses"
+	azureResponsesV1APIVersion   = "preview"
+	azureResponsesUnsupportedTTL = time.Hour
+)
+
+var (
+	// azureResponsesPaths 渠道 ID 到协商成功的接口路径
+	azureResponsesPaths sync.Map
+	// azureResponsesUnsupported 不支持 Responses API 的部署（渠道 ID:部署名）到标记过期时间
+	azureResponsesUnsupported sync.Map
+)
+
+// AzureResponsesFailure Azure Responses 请求失败的原因
+type AzureResponsesFailure int
+
+const (
+	AzureResponsesFailureNone AzureResponsesFailure = iota
+	// AzureResponsesFailureEndpoint 接口路径或 api-version 不可用，可以尝试下一种接口
+	AzureResponsesFailureEndpoint
+	// AzureResponsesFailureDeployment 部署的模型不支持 Responses API，应改走 Chat Completions
+	AzureResponsesFailureDeployment
+)
+
+// azureDeploymentName 返回模型对应的 Azure 部署名，2025年5月10日前创建的渠道移除模型名中的点
+func azureDeploymentName(info *relaycommon.RelayInfo, modelName string) string {
+	if info.ChannelCreateTime < constant.AzureNoRemoveDotTime {
+		return strings.Replace(modelName, ".", "", -1)
+	}
+	return modelName
+}
+
+// AzureResponsesEndpoints 返回 Responses 请求依次尝试的接口。渠道设置了 Responses 版本时只使用该版本；
+// cognitiveservices.azure.com 的资源优先使用按日期版本的接口，其余优先使用 v1 接口，之前协商成功的接口排在最前
+func AzureResponsesEndpoints(info *relaycommon.RelayInfo) []relaycommon.AzureResponsesEndpoint {
+	apiVersion := info.ApiVersion
+	if apiVersion == "" {
+		apiVersion = constant.AzureDefaultAPIVersion
+	}
+	v1 := relaycommon.AzureResponsesEndpoint{Path: azureResponsesV1Path, ApiVersion: azureResponsesV1APIVersion}
+	dated := relaycommon.AzureResponsesEndpoint{Path: azureResponsesDatedPath, ApiVersion: apiVersion}
+	endpoints := []relaycommon.AzureResponsesEndpoint{v1, dated}
+	if strings.Contains(info.ChannelBaseUrl, "cognitiveservices.azure.com") {
+		endpoints = []relaycommon.AzureResponsesEndpoint{dated, v1}
+	}
+	if version := info.ChannelOtherSettings.AzureResponsesVersion; version != "" {
+		endpoint := endpoints[0]
+		endpoint.ApiVersion = version
+		return []relaycommon.AzureResponsesEndpoint{endpoint}
+	}
+	if path, ok := azureResponsesPaths.Load(info.ChannelId); ok && endpoints[1].Path == path {
+		endpoints[0], endpoints[1] = endpoints[1], endpoints[0]
+	}
+	return endpoints
+}
+
+// RememberAzureResponsesEndpoint 记录渠道协商成功的接口
+func RememberAzureResponsesEndpoint(channelId int, endpoint relaycommon.AzureResponsesEndpoint) {
+	azureResponsesPaths.Store(channelId, endpoint.Path)
+}
+
+func azureResponsesDeploymentKey(channelId int, deployment string) string {
+	return fmt.Sprintf("%d:%s", channelId, deployment)
+}
+
+// AzureResponsesUnsupported 判断部署是否已被标记为不支持 Responses API
+func AzureResponsesUnsupported(channelId int, deployment string) bool {
+	key := azureResponsesDeploymentKey(channelId, deployment)
+	expiresAt, ok := azureResponsesUnsupported.Load(key)
+	if !ok {
+		return false
+	}
+	if time.Now().After(expiresAt.(time.Time)) {
+		azureResponsesUnsupported.Delete(key)
+		return false
+	}
+	return true
+}
+
+// MarkAzureResponsesUnsupported 标记部署不支持 Responses API，标记过期后重新尝试原生接口
+func MarkAzureResponsesUnsupported(channelId int, deployment string) {
+	azureResponsesUnsupported.Store(azureResponsesDeploymentKey(channelId, deployment), time.Now().Add(azureResponsesUnsupportedTTL))
+}
+
+// ClassifyAzureResponsesFailure 根据上游的错误响应判断 Responses 请求失败的原因
+func ClassifyAzureResponsesFailure(status int, body []byte) AzureResponsesFailure {
+	lower := strings.ToLower(string(body))
+	switch {
+	case strings.Contains(lower, "operationnotsupported") ||
+		strings.Contains(lower, "does not work with the specified model") ||
+		strings.Contains(lower, "responses api is not enabled") ||
+		strings.Contains(lower, "not supported for this model"):
+		return AzureResponsesFailureDeployment
+	case status == http.StatusNotFound && !strings.Contains(lower, "deploymentnotfound"):
+		// 路径不存在或 api-version 不提供该接口时返回 Resource not found
+		return AzureResponsesFailureEndpoint
+	case status == http.StatusBadRequest && (strings.Contains(lower, "api version") || strings.Contains(lower, "api-version")):
+		return AzureResponsesFailureEndpoint
+	}
+	return AzureResponsesFailureNone
+}
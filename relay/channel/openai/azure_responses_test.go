@@ -0,0 +1,80 @@
+package openai
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/QuantumNous/new-api/constant"
+	"github.com/QuantumNous/new-api/dto"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	relayconstant "github.com/QuantumNous/new-api/relay/constant"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newAzureResponsesInfo(channelId int, baseURL string) *relaycommon.RelayInfo {
+	return &relaycommon.RelayInfo{
+		RelayMode: relayconstant.RelayModeResponses,
+		ChannelMeta: &relaycommon.ChannelMeta{
+			ChannelType:       constant.ChannelTypeAzure,
+			ChannelId:         channelId,
+			ChannelBaseUrl:    baseURL,
+			ApiVersion:        "2025-04-01-preview",
+			UpstreamModelName: "gpt-4.1",
+			ChannelCreateTime: constant.AzureNoRemoveDotTime + 1,
+		},
+	}
+}
+
+func TestAzureResponsesEndpoints(t *testing.T) {
+	info := newAzureResponsesInfo(9001, "https://example.openai.azure.com")
+	endpoints := AzureResponsesEndpoints(info)
+	require.Len(t, endpoints, 2)
+	assert.Equal(t, relaycommon.AzureResponsesEndpoint{Path: "/openai/v1/responses", ApiVersion: "preview"}, endpoints[0])
+	assert.Equal(t, relaycommon.AzureResponsesEndpoint{Path: "/openai/responses", ApiVersion: "2025-04-01-preview"}, endpoints[1])
+
+	url, err := (&Adaptor{}).GetRequestURL(info)
+	require.NoError(t, err)
+	assert.Equal(t, "https://example.openai.azure.com/openai/v1/responses?api-version=preview", url)
+
+	// the negotiated endpoint is tried first afterwards
+	RememberAzureResponsesEndpoint(info.ChannelId, endpoints[1])
+	t.Cleanup(func() { azureResponsesPaths.Delete(info.ChannelId) })
+	assert.Equal(t, "/openai/responses", AzureResponsesEndpoints(info)[0].Path)
+
+	cognitive := newAzureResponsesInfo(9002, "https://example.cognitiveservices.azure.com")
+	assert.Equal(t, "/openai/responses", AzureResponsesEndpoints(cognitive)[0].Path)
+
+	pinned := newAzureResponsesInfo(9003, "https://example.openai.azure.com")
+	pinned.ChannelOtherSettings = dto.ChannelOtherSettings{AzureResponsesVersion: "2025-03-01-preview"}
+	assert.Equal(t, []relaycommon.AzureResponsesEndpoint{{Path: "/openai/v1/responses", ApiVersion: "2025-03-01-preview"}}, AzureResponsesEndpoints(pinned))
+}
+
+func TestAzureResponsesUseDeploymentName(t *testing.T) {
+	info := newAzureResponsesInfo(9004, "https://example.openai.azure.com")
+	info.ChannelCreateTime = constant.AzureNoRemoveDotTime - 1
+	converted, err := (&Adaptor{}).ConvertOpenAIResponsesRequest(nil, info, dto.OpenAIResponsesRequest{Model: "gpt-4.1"})
+	require.NoError(t, err)
+	assert.Equal(t, "gpt-41", converted.(dto.OpenAIResponsesRequest).Model)
+}
+
+func TestClassifyAzureResponsesFailure(t *testing.T) {
+	assert.Equal(t, AzureResponsesFailureDeployment, ClassifyAzureResponsesFailure(http.StatusBadRequest,
+		[]byte(`{"error":{"code":"OperationNotSupported","message":"The responses operation does not work with the specified model, gpt-35-turbo."}}`)))
+	assert.Equal(t, AzureResponsesFailureEndpoint, ClassifyAzureResponsesFailure(http.StatusNotFound,
+		[]byte(`{"error":{"code":"404","message":"Resource not found"}}`)))
+	assert.Equal(t, AzureResponsesFailureEndpoint, ClassifyAzureResponsesFailure(http.StatusBadRequest,
+		[]byte(`{"error":{"code":"BadRequest","message":"API version not supported"}}`)))
+	assert.Equal(t, AzureResponsesFailureNone, ClassifyAzureResponsesFailure(http.StatusNotFound,
+		[]byte(`{"error":{"code":"DeploymentNotFound","message":"The API deployment for this resource does not exist."}}`)))
+	assert.Equal(t, AzureResponsesFailureNone, ClassifyAzureResponsesFailure(http.StatusTooManyRequests, []byte(`{"error":{"code":"429"}}`)))
+}
+
+func TestAzureResponsesUnsupported(t *testing.T) {
+	assert.False(t, AzureResponsesUnsupported(9005, "gpt-35-turbo"))
+	MarkAzureResponsesUnsupported(9005, "gpt-35-turbo")
+	t.Cleanup(func() { azureResponsesUnsupported.Delete(azureResponsesDeploymentKey(9005, "gpt-35-turbo")) })
+	assert.True(t, AzureResponsesUnsupported(9005, "gpt-35-turbo"))
+	assert.False(t, AzureResponsesUnsupported(9005, "gpt-4.1"))
+}
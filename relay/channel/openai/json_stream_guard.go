@@ -0,0 +1,74 @@
+package openai
+
+import (
+	"net/http"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/constant"
+	"github.com/QuantumNous/new-api/dto"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	relayconstant "github.com/QuantumNous/new-api/relay/constant"
+	"github.com/QuantumNous/new-api/relay/helper"
+	"github.com/QuantumNous/new-api/service"
+	"github.com/QuantumNous/new-api/setting/model_setting"
+	"github.com/QuantumNous/new-api/types"
+
+	"github.com/gin-gonic/gin"
+)
+
+// streamJSONGuard 按 choice 分别校验 JSON 模式下流式返回的 content
+type streamJSONGuard struct {
+	guards map[int]*helper.JSONStreamGuard
+}
+
+// newStreamJSONGuard 仅在开启校验（全局设置或灰度开关）且 OpenAI 格式的流式 Chat Completions 请求了 json_object / json_schema 时返回校验器
+func newStreamJSONGuard(info *relaycommon.RelayInfo) *streamJSONGuard {
+	if !model_setting.GetGlobalSettings().StreamJSONGuardEnabled && !service.FeatureEnabled(info, constant.FeatureFlagStreamJSONGuard) {
+		return nil
+	}
+	if info.RelayFormat != types.RelayFormatOpenAI || info.RelayMode != relayconstant.RelayModeChatCompletions {
+		return nil
+	}
+	request, ok := info.Request.(*dto.GeneralOpenAIRequest)
+	if !ok || request.ResponseFormat == nil {
+		return nil
+	}
+	switch request.ResponseFormat.Type {
+	case "json_object", "json_schema":
+		return &streamJSONGuard{guards: make(map[int]*helper.JSONStreamGuard)}
+	}
+	return nil
+}
+
+// check 校验一个流式数据块中的 content 增量，无法解析的数据块交由后续流程处理
+func (g *streamJSONGuard) check(data string) error {
+	if g == nil {
+		return nil
+	}
+	var chunk dto.ChatCompletionsStreamResponse
+	if err := common.UnmarshalJsonStr(data, &chunk); err != nil {
+		return nil
+	}
+	for i := range chunk.Choices {
+		content := chunk.Choices[i].Delta.GetContentString()
+		if content == "" {
+			continue
+		}
+		guard, ok := g.guards[chunk.Choices[i].Index]
+		if !ok {
+			guard = helper.NewJSONStreamGuard()
+			g.guards[chunk.Choices[i].Index] = guard
+		}
+		if err := guard.Write(content); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// abortJSONStream 以结构化错误事件结束流，客户端无需读完无效内容
+func abortJSONStream(c *gin.Context, err error) {
+	apiErr := types.NewOpenAIError(err, types.ErrorCodeInvalidJSONStream, http.StatusBadGateway)
+	_ = helper.ObjectData(c, gin.H{"error": apiErr.ToOpenAIError()})
+	helper.Done(c)
+}
@@ -0,0 +1,23 @@
+package openai
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRealtimeSubprotocols(t *testing.T) {
+	assert.Equal(t, "realtime, openai-insecure-api-key.sk-upstream, openai-beta.realtime-v1",
+		realtimeSubprotocols("realtime, openai-insecure-api-key.sk-gateway, openai-beta.realtime-v1", "sk-upstream"))
+	// GA 客户端不声明 beta 协议时不再强制追加
+	assert.Equal(t, "realtime, openai-insecure-api-key.sk-upstream",
+		realtimeSubprotocols("realtime,openai-insecure-api-key.sk-gateway", "sk-upstream"))
+	assert.Equal(t, "realtime, openai-insecure-api-key.sk-upstream, openai-organization.org-1",
+		realtimeSubprotocols("openai-organization.org-1", "sk-upstream"))
+}
+
+func TestRealtimeBetaHeader(t *testing.T) {
+	assert.Equal(t, "realtime=v1", realtimeBetaHeader("", "gpt-4o-realtime-preview-2024-12-17"))
+	assert.Equal(t, "", realtimeBetaHeader("", "gpt-realtime"))
+	assert.Equal(t, "realtime=v1", realtimeBetaHeader("realtime=v1", "gpt-realtime"))
+}
@@ -0,0 +1,61 @@
+package openai
+
+import (
+	"time"
+
+	"github.com/QuantumNous/new-api/constant"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	relayconstant "github.com/QuantumNous/new-api/relay/constant"
+	"github.com/QuantumNous/new-api/relay/helper"
+	"github.com/QuantumNous/new-api/service"
+	"github.com/QuantumNous/new-api/setting/model_setting"
+	"github.com/QuantumNous/new-api/types"
+
+	"github.com/gin-gonic/gin"
+)
+
+// newReasoningCoalescer 仅在开启思考内容合并（全局设置或灰度开关）且为 OpenAI 格式的流式 Chat Completions 时返回合并器
+func newReasoningCoalescer(info *relaycommon.RelayInfo) *helper.ReasoningCoalescer {
+	settings := model_setting.GetGlobalSettings()
+	if !settings.ReasoningCoalesceEnabled && !service.FeatureEnabled(info, constant.FeatureFlagReasoningCoalesce) {
+		return nil
+	}
+	if info.RelayFormat != types.RelayFormatOpenAI || info.RelayMode != relayconstant.RelayModeChatCompletions {
+		return nil
+	}
+	interval := settings.ReasoningCoalesceIntervalMs
+	if interval <= 0 {
+		interval = 200
+	}
+	maxBytes := settings.ReasoningCoalesceBytes
+	if maxBytes <= 0 {
+		maxBytes = 1024
+	}
+	return helper.NewReasoningCoalescer(time.Duration(interval)*time.Millisecond, maxBytes)
+}
+
+// sendCoalescedStreamData 经合并器发送数据块，未开启合并时直接发送
+func sendCoalescedStreamData(c *gin.Context, info *relaycommon.RelayInfo, coalescer *helper.ReasoningCoalescer, data string) error {
+	if coalescer == nil {
+		return HandleStreamFormat(c, info, data, info.ChannelSetting.ForceFormat, info.ChannelSetting.ThinkingToContent)
+	}
+	for _, chunk := range coalescer.Push(data) {
+		if err := HandleStreamFormat(c, info, chunk, info.ChannelSetting.ForceFormat, info.ChannelSetting.ThinkingToContent); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// flushCoalescedStreamData 发送合并器中剩余的思考内容
+func flushCoalescedStreamData(c *gin.Context, info *relaycommon.RelayInfo, coalescer *helper.ReasoningCoalescer) error {
+	if coalescer == nil {
+		return nil
+	}
+	for _, chunk := range coalescer.Flush() {
+		if err := HandleStreamFormat(c, info, chunk, info.ChannelSetting.ForceFormat, info.ChannelSetting.ThinkingToContent); err != nil {
+			return err
+		}
+	}
+	return nil
+}
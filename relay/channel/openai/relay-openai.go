@@ -126,9 +126,13 @@ func OaiStreamHandler(c *gin.Context, info *relaycommon.RelayInfo, resp *http.Re
 	// 检查是否为音频模型
 	isAudioModel := strings.Contains(strings.ToLower(model), "audio")
 
+	jsonGuard := newStreamJSONGuard(info)
+	var jsonGuardErr error
+	coalescer := newReasoningCoalescer(info)
+
 	helper.StreamScannerHandler(c, resp, info, func(data string) bool {
 		if lastStreamData != "" {
-			err := HandleStreamFormat(c, info, lastStreamData, info.ChannelSetting.ForceFormat, info.ChannelSetting.ThinkingToContent)
+			err := sendCoalescedStreamData(c, info, coalescer, lastStreamData)
 			if err != nil {
 				common.SysLog("error handling stream format: " + err.Error())
 			}
@@ -141,10 +145,24 @@ func OaiStreamHandler(c *gin.Context, info *relaycommon.RelayInfo, resp *http.Re
 
 			lastStreamData = data
 			streamItems = append(streamItems, data)
+			// 发现无法恢复的非法 JSON 时不再转发当前数据块，并停止读取上游
+			if jsonGuardErr = jsonGuard.check(data); jsonGuardErr != nil {
+				return false
+			}
 		}
 		return true
 	})
 
+	// 最后一个数据块单独处理，先发送尚未发送的思考内容
+	if err := flushCoalescedStreamData(c, info, coalescer); err != nil {
+		common.SysLog("error handling stream format: " + err.Error())
+	}
+
+	if jsonGuardErr != nil {
+		logger.LogWarn(c, "abort stream with broken JSON output: "+jsonGuardErr.Error())
+		abortJSONStream(c, jsonGuardErr)
+	}
+
 	// 对音频模型，从倒数第二个stream data中提取usage信息
 	if isAudioModel && secondLastStreamData != "" {
 		var streamResp struct {
@@ -171,7 +189,7 @@ func OaiStreamHandler(c *gin.Context, info *relaycommon.RelayInfo, resp *http.Re
 	}
 
 	if info.RelayFormat == types.RelayFormatOpenAI {
-		if shouldSendLastResp {
+		if shouldSendLastResp && jsonGuardErr == nil {
 			_ = sendStreamData(c, info, lastStreamData, info.ChannelSetting.ForceFormat, info.ChannelSetting.ThinkingToContent)
 		}
 	}
@@ -188,7 +206,9 @@ func OaiStreamHandler(c *gin.Context, info *relaycommon.RelayInfo, resp *http.Re
 
 	applyUsagePostProcessing(info, usage, common.StringToByteSlice(lastStreamData))
 
-	HandleFinalResponse(c, info, lastStreamData, responseId, createAt, model, systemFingerprint, usage, containStreamUsage)
+	if jsonGuardErr == nil {
+		HandleFinalResponse(c, info, lastStreamData, responseId, createAt, model, systemFingerprint, usage, containStreamUsage)
+	}
 
 	return usage, nil
 }
@@ -442,14 +462,7 @@ func OpenaiRealtimeHandler(c *gin.Context, info *relaycommon.RelayInfo) (*types.
 				if realtimeEvent.Type == dto.RealtimeEventTypeResponseDone {
 					realtimeUsage := realtimeEvent.Response.Usage
 					if realtimeUsage != nil {
-						usage.TotalTokens += realtimeUsage.TotalTokens
-						usage.InputTokens += realtimeUsage.InputTokens
-						usage.OutputTokens += realtimeUsage.OutputTokens
-						usage.InputTokenDetails.AudioTokens += realtimeUsage.InputTokenDetails.AudioTokens
-						usage.InputTokenDetails.CachedTokens += realtimeUsage.InputTokenDetails.CachedTokens
-						usage.InputTokenDetails.TextTokens += realtimeUsage.InputTokenDetails.TextTokens
-						usage.OutputTokenDetails.AudioTokens += realtimeUsage.OutputTokenDetails.AudioTokens
-						usage.OutputTokenDetails.TextTokens += realtimeUsage.OutputTokenDetails.TextTokens
+						usage.Add(realtimeUsage)
 						err := preConsumeUsage(c, info, usage, sumUsage)
 						if err != nil {
 							errChan <- fmt.Errorf("error consume usage: %v", err)
@@ -488,8 +501,9 @@ func OpenaiRealtimeHandler(c *gin.Context, info *relaycommon.RelayInfo) (*types.
 					realtimeSession := realtimeEvent.Session
 					if realtimeSession != nil {
 						// update audio format
-						info.InputAudioFormat = common.GetStringIfEmpty(realtimeSession.InputAudioFormat, info.InputAudioFormat)
-						info.OutputAudioFormat = common.GetStringIfEmpty(realtimeSession.OutputAudioFormat, info.OutputAudioFormat)
+						inputFormat, outputFormat := realtimeSession.AudioFormats()
+						info.InputAudioFormat = common.GetStringIfEmpty(inputFormat, info.InputAudioFormat)
+						info.OutputAudioFormat = common.GetStringIfEmpty(outputFormat, info.OutputAudioFormat)
 					}
 				} else {
 					textToken, audioToken, err := service.CountTokenRealtime(info, *realtimeEvent, info.UpstreamModelName)
@@ -545,14 +559,7 @@ func preConsumeUsage(ctx *gin.Context, info *relaycommon.RelayInfo, usage *dto.R
 		return fmt.Errorf("invalid usage pointer")
 	}
 
-	totalUsage.TotalTokens += usage.TotalTokens
-	totalUsage.InputTokens += usage.InputTokens
-	totalUsage.OutputTokens += usage.OutputTokens
-	totalUsage.InputTokenDetails.CachedTokens += usage.InputTokenDetails.CachedTokens
-	totalUsage.InputTokenDetails.TextTokens += usage.InputTokenDetails.TextTokens
-	totalUsage.InputTokenDetails.AudioTokens += usage.InputTokenDetails.AudioTokens
-	totalUsage.OutputTokenDetails.TextTokens += usage.OutputTokenDetails.TextTokens
-	totalUsage.OutputTokenDetails.AudioTokens += usage.OutputTokenDetails.AudioTokens
+	totalUsage.Add(usage)
 	// clear usage
 	err := service.PreWssConsumeQuota(ctx, info, usage)
 	return err
@@ -597,6 +604,9 @@ func applyUsagePostProcessing(info *relaycommon.RelayInfo, usage *dto.Usage, res
 	if info == nil || usage == nil {
 		return
 	}
+	if serviceTier := extractServiceTierFromBody(responseBody); serviceTier != "" {
+		info.ServiceTier = serviceTier
+	}
 
 	switch info.ChannelType {
 	case constant.ChannelTypeDeepSeek:
@@ -630,6 +640,20 @@ func applyUsagePostProcessing(info *relaycommon.RelayInfo, usage *dto.Usage, res
 	}
 }
 
+// extractServiceTierFromBody 读取响应中上游实际提供的 service_tier
+func extractServiceTierFromBody(body []byte) string {
+	if len(body) == 0 {
+		return ""
+	}
+	var payload struct {
+		ServiceTier string `json:"service_tier"`
+	}
+	if err := common.Unmarshal(body, &payload); err != nil {
+		return ""
+	}
+	return payload.ServiceTier
+}
+
 func extractCachedTokensFromBody(body []byte) (int, bool) {
 	if len(body) == 0 {
 		return 0, false
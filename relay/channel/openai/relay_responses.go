@@ -40,8 +40,13 @@ func OaiResponsesHandler(c *gin.Context, info *relaycommon.RelayInfo, resp *http
 		c.Set("image_generation_call_size", responsesResponse.GetSize())
 	}
 
-	// 写入新的 response body
-	service.IOCopyBytesGracefully(c, resp, responseBody)
+	if info != nil && responsesResponse.ServiceTier != "" {
+		info.ServiceTier = responsesResponse.ServiceTier
+	}
+
+	// 写入新的 response body，先记录响应所属的渠道，客户端收到响应后即可查询
+	service.SaveNativeResponse(info, responsesResponse.ID, responsesResponse.Store)
+	service.IOCopyBytesGracefully(c, resp, service.ApplyProvenanceToResponsesBody(responseBody))
 
 	// compute usage
 	usage := dto.Usage{}
@@ -84,6 +89,10 @@ func OaiResponsesStreamHandler(c *gin.Context, info *relaycommon.RelayInfo, resp
 		// 检查当前数据是否包含 completed 状态和 usage 信息
 		var streamResponse dto.ResponsesStreamResponse
 		if err := common.UnmarshalJsonStr(data, &streamResponse); err == nil {
+			if streamResponse.Type == "response.created" && streamResponse.Response != nil {
+				// 先记录响应所属的渠道再转发，客户端收到响应 ID 后即可查询或取消
+				service.SaveNativeResponse(info, streamResponse.Response.ID, streamResponse.Response.Store)
+			}
 			sendResponsesStreamData(c, streamResponse, data)
 			switch streamResponse.Type {
 			case "response.completed":
@@ -107,6 +116,9 @@ func OaiResponsesStreamHandler(c *gin.Context, info *relaycommon.RelayInfo, resp
 						c.Set("image_generation_call_quality", streamResponse.Response.GetQuality())
 						c.Set("image_generation_call_size", streamResponse.Response.GetSize())
 					}
+					if info != nil && streamResponse.Response.ServiceTier != "" {
+						info.ServiceTier = streamResponse.Response.ServiceTier
+					}
 				}
 			case "response.output_text.delta":
 				// 处理输出文本
@@ -0,0 +1,165 @@
+package openai
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/dto"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	"github.com/QuantumNous/new-api/relay/helper"
+	"github.com/QuantumNous/new-api/service"
+	"github.com/QuantumNous/new-api/types"
+
+	"github.com/gin-gonic/gin"
+)
+
+// OaiChatToResponsesHandler converts a Chat Completions response of an upstream without
+// native Responses API support back into a Responses API response. prefixItems are
+// output items produced by the gateway and placed before the upstream output.
+func OaiChatToResponsesHandler(c *gin.Context, info *relaycommon.RelayInfo, resp *http.Response, originalReq *dto.OpenAIResponsesRequest, prefixItems ...dto.ResponsesOutput) (*dto.Usage, *types.NewAPIError) {
+	if resp == nil || resp.Body == nil {
+		return nil, types.NewOpenAIError(fmt.Errorf("invalid response"), types.ErrorCodeBadResponse, http.StatusInternalServerError)
+	}
+
+	defer service.CloseResponseBodyGracefully(resp)
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, types.NewOpenAIError(err, types.ErrorCodeReadResponseBodyFailed, http.StatusInternalServerError)
+	}
+
+	var chatResp dto.OpenAITextResponse
+	if err := common.Unmarshal(body, &chatResp); err != nil {
+		return nil, types.NewOpenAIError(err, types.ErrorCodeBadResponseBody, http.StatusInternalServerError)
+	}
+	if oaiError := chatResp.GetOpenAIError(); oaiError != nil && oaiError.Type != "" {
+		return nil, types.WithOpenAIError(*oaiError, resp.StatusCode)
+	}
+
+	usage := chatResp.Usage
+	if usage.TotalTokens == 0 {
+		var text strings.Builder
+		for _, choice := range chatResp.Choices {
+			text.WriteString(choice.Message.StringContent())
+		}
+		usage = *service.ResponseText2Usage(c, text.String(), info.UpstreamModelName, info.GetEstimatePromptTokens())
+		chatResp.Usage = usage
+	}
+
+	if chatResp.ServiceTier != "" {
+		info.ServiceTier = chatResp.ServiceTier
+	}
+
+	responsesResp := service.ChatCompletionsResponseToResponsesResponse(&chatResp, originalReq, info)
+	if len(prefixItems) > 0 {
+		responsesResp.Output = append(prefixItems, responsesResp.Output...)
+	}
+	responseBody, err := common.Marshal(responsesResp)
+	if err != nil {
+		return nil, types.NewOpenAIError(err, types.ErrorCodeJsonMarshalFailed, http.StatusInternalServerError)
+	}
+
+	service.SaveResponsesConversation(c, info, responsesResp.ID, service.ChatResponseAssistantMessage(&chatResp), responsesResp)
+	service.IOCopyBytesGracefully(c, resp, responseBody)
+	return &usage, nil
+}
+
+// OaiChatToResponsesStreamHandler converts a Chat Completions stream into Responses API
+// stream events. OpenAI sends usage in a chunk after the finish reason, so the finish
+// chunks (one per choice when n > 1) are held back until the usage arrives or the stream ends.
+func OaiChatToResponsesStreamHandler(c *gin.Context, info *relaycommon.RelayInfo, resp *http.Response, originalReq *dto.OpenAIResponsesRequest, prefixItems ...dto.ResponsesOutput) (*dto.Usage, *types.NewAPIError) {
+	if resp == nil || resp.Body == nil {
+		return nil, types.NewOpenAIError(fmt.Errorf("invalid response"), types.ErrorCodeBadResponse, http.StatusInternalServerError)
+	}
+
+	defer service.CloseResponseBodyGracefully(resp)
+
+	streamAdapter := service.NewChatToResponsesStreamAdapter(c, originalReq, info)
+	defer streamAdapter.Close()
+	for _, item := range prefixItems {
+		streamAdapter.PrependOutputItem(item)
+	}
+
+	var (
+		usage        *dto.Usage
+		outputText   strings.Builder
+		finishChunks []*dto.ChatCompletionsStreamResponse
+		sentHeaders  bool
+		streamErr    *types.NewAPIError
+	)
+
+	sendChunk := func(chunk *dto.ChatCompletionsStreamResponse) {
+		for _, eventData := range streamAdapter.ConvertChunk(chunk) {
+			if !sentHeaders {
+				helper.SetEventStreamHeaders(c)
+				sentHeaders = true
+			}
+			_ = helper.StringData(c, string(eventData))
+		}
+	}
+
+	helper.StreamScannerHandler(c, resp, info, func(data string) bool {
+		var chunk dto.ChatCompletionsStreamResponse
+		if err := common.UnmarshalJsonStr(data, &chunk); err != nil {
+			common.SysLog("error unmarshalling stream response: " + err.Error())
+			streamErr = types.NewOpenAIError(err, types.ErrorCodeBadResponseBody, http.StatusInternalServerError)
+			return false
+		}
+		if chunk.Usage != nil && chunk.Usage.TotalTokens > 0 {
+			usage = chunk.Usage
+		}
+		for _, choice := range chunk.Choices {
+			if choice.Delta.Content != nil {
+				outputText.WriteString(*choice.Delta.Content)
+			}
+			outputText.WriteString(choice.Delta.GetReasoningContent())
+			for _, toolCall := range choice.Delta.ToolCalls {
+				outputText.WriteString(toolCall.Function.Name)
+				outputText.WriteString(toolCall.Function.Arguments)
+			}
+		}
+		if hasFinishedChoice(&chunk) {
+			finishChunks = append(finishChunks, &chunk)
+			return true
+		}
+		if len(chunk.Choices) > 0 {
+			sendChunk(&chunk)
+		}
+		return true
+	})
+
+	if streamErr != nil {
+		return nil, streamErr
+	}
+
+	if streamAdapter.ServiceTier != "" {
+		info.ServiceTier = streamAdapter.ServiceTier
+	}
+	if usage == nil {
+		usage = service.ResponseText2Usage(c, outputText.String(), info.UpstreamModelName, info.GetEstimatePromptTokens())
+	}
+	for i, finishChunk := range finishChunks {
+		// the response is completed by the last finish chunk, which carries the usage
+		if i == len(finishChunks)-1 {
+			finishChunk.Usage = usage
+		}
+		sendChunk(finishChunk)
+	}
+	if sentHeaders {
+		helper.Done(c)
+	}
+	return usage, nil
+}
+
+// hasFinishedChoice reports whether any choice of the chunk carries a finish reason
+func hasFinishedChoice(chunk *dto.ChatCompletionsStreamResponse) bool {
+	for _, choice := range chunk.Choices {
+		if choice.FinishReason != nil && *choice.FinishReason != "" {
+			return true
+		}
+	}
+	return false
+}
@@ -23,6 +23,7 @@ func requestOpenAI2Perplexity(request dto.GeneralOpenAIRequest) *dto.GeneralOpen
 		ReturnImages:           request.ReturnImages,
 		ReturnRelatedQuestions: request.ReturnRelatedQuestions,
 		SearchMode:             request.SearchMode,
+		WebSearchOptions:       request.WebSearchOptions,
 	}
 	if request.MaxTokens != nil || request.MaxCompletionTokens != nil {
 		maxTokens := request.GetMaxTokens()
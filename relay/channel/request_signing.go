@@ -0,0 +1,184 @@
+package channel
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	common2 "github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/dto"
+	"github.com/QuantumNous/new-api/service"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
+)
+
+const (
+	defaultHmacStringToSign    = "{method}\n{path}\n{query}\n{timestamp}\n{body_sha256}"
+	defaultHmacSignatureHeader = "X-Signature"
+	defaultHmacKeyIdHeader     = "X-Key-Id"
+	defaultHmacTimestampHeader = "X-Timestamp"
+	defaultAwsSigningService   = "execute-api"
+)
+
+var hmacPlaceholderRegex = regexp.MustCompile(`\{(method|path|query|timestamp|nonce|body_sha256|header:[A-Za-z0-9-]+)\}`)
+
+// ValidateRequestSigning 校验渠道的上游请求签名设置，nil 表示未启用
+func ValidateRequestSigning(signing *dto.RequestSigning) error {
+	if signing == nil {
+		return nil
+	}
+	switch signing.Type {
+	case dto.RequestSigningTypeNone:
+	case dto.RequestSigningTypeAwsSigV4:
+		if signing.AwsRegion == "" || signing.AwsAccessKeyId == "" || signing.AwsSecretAccessKey == "" {
+			return fmt.Errorf("aws_sigv4 签名需要 aws_region、aws_access_key_id 和 aws_secret_access_key")
+		}
+	case dto.RequestSigningTypeHmac:
+		if signing.HmacSecret == "" {
+			return fmt.Errorf("hmac 签名需要 hmac_secret")
+		}
+		switch strings.ToLower(signing.HmacAlgorithm) {
+		case "", "sha256", "sha512":
+		default:
+			return fmt.Errorf("不支持的 hmac_algorithm: %s", signing.HmacAlgorithm)
+		}
+		switch strings.ToLower(signing.HmacEncoding) {
+		case "", "hex", "base64":
+		default:
+			return fmt.Errorf("不支持的 hmac_encoding: %s", signing.HmacEncoding)
+		}
+		if strings.Contains(signing.HmacStringToSign, "{nonce}") && signing.HmacNonceHeader == "" {
+			return fmt.Errorf("hmac_string_to_sign 使用了 {nonce} 时必须设置 hmac_nonce_header")
+		}
+	default:
+		return fmt.Errorf("不支持的签名方式: %s", signing.Type)
+	}
+	if signing.TlsClientCert != "" || signing.TlsClientKey != "" {
+		err := service.ValidateUpstreamTLSOptions(service.UpstreamTLSOptions{
+			ClientCertPEM: signing.TlsClientCert,
+			ClientKeyPEM:  signing.TlsClientKey,
+			CaPEM:         signing.TlsCaCert,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// signUpstreamRequest 按渠道设置为上游请求签名，需要在所有请求头确定之后调用
+func signUpstreamRequest(ctx context.Context, req *http.Request, signing *dto.RequestSigning) error {
+	if signing == nil || signing.Type == dto.RequestSigningTypeNone {
+		return nil
+	}
+	body, err := bufferRequestBody(req)
+	if err != nil {
+		return fmt.Errorf("read request body for signing failed: %w", err)
+	}
+	bodyHash := sha256.Sum256(body)
+	bodySha256 := hex.EncodeToString(bodyHash[:])
+
+	switch signing.Type {
+	case dto.RequestSigningTypeAwsSigV4:
+		signingService := orDefault(signing.AwsService, defaultAwsSigningService)
+		credentials := aws.Credentials{
+			AccessKeyID:     signing.AwsAccessKeyId,
+			SecretAccessKey: signing.AwsSecretAccessKey,
+			SessionToken:    signing.AwsSessionToken,
+		}
+		// 上游网关不识别 Bearer 时会拒绝，SigV4 以 Authorization 头承载签名
+		req.Header.Del("Authorization")
+		req.Header.Set("X-Amz-Content-Sha256", bodySha256)
+		return v4.NewSigner().SignHTTP(ctx, credentials, req, bodySha256, signingService, signing.AwsRegion, time.Now())
+	case dto.RequestSigningTypeHmac:
+		signHmacRequest(req, signing, bodySha256, time.Now())
+		return nil
+	default:
+		return fmt.Errorf("unsupported request signing type: %s", signing.Type)
+	}
+}
+
+func signHmacRequest(req *http.Request, signing *dto.RequestSigning, bodySha256 string, now time.Time) {
+	timestamp := strconv.FormatInt(now.Unix(), 10)
+	timestampHeader := orDefault(signing.HmacTimestampHeader, defaultHmacTimestampHeader)
+	req.Header.Set(timestampHeader, timestamp)
+	nonce := ""
+	if signing.HmacNonceHeader != "" {
+		nonce = common2.GetUUID()
+		req.Header.Set(signing.HmacNonceHeader, nonce)
+	}
+	if signing.HmacKeyId != "" {
+		req.Header.Set(orDefault(signing.HmacKeyIdHeader, defaultHmacKeyIdHeader), signing.HmacKeyId)
+	}
+
+	stringToSign := hmacPlaceholderRegex.ReplaceAllStringFunc(orDefault(signing.HmacStringToSign, defaultHmacStringToSign), func(placeholder string) string {
+		name := placeholder[1 : len(placeholder)-1]
+		switch name {
+		case "method":
+			return req.Method
+		case "path":
+			return req.URL.EscapedPath()
+		case "query":
+			return req.URL.Query().Encode()
+		case "timestamp":
+			return timestamp
+		case "nonce":
+			return nonce
+		case "body_sha256":
+			return bodySha256
+		default:
+			return strings.TrimSpace(req.Header.Get(strings.TrimPrefix(name, "header:")))
+		}
+	})
+
+	var newHash func() hash.Hash = sha256.New
+	if strings.EqualFold(signing.HmacAlgorithm, "sha512") {
+		newHash = sha512.New
+	}
+	mac := hmac.New(newHash, []byte(signing.HmacSecret))
+	mac.Write([]byte(stringToSign))
+	sum := mac.Sum(nil)
+	signature := hex.EncodeToString(sum)
+	if strings.EqualFold(signing.HmacEncoding, "base64") {
+		signature = base64.StdEncoding.EncodeToString(sum)
+	}
+	req.Header.Set(orDefault(signing.HmacSignatureHeader, defaultHmacSignatureHeader), signature)
+}
+
+// bufferRequestBody 读取请求体用于计算签名，并重置为可重复读取的内存副本
+func bufferRequestBody(req *http.Request) ([]byte, error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return []byte{}, nil
+	}
+	body, err := io.ReadAll(req.Body)
+	if err != nil {
+		return nil, err
+	}
+	_ = req.Body.Close()
+	req.Body = io.NopCloser(bytes.NewReader(body))
+	req.ContentLength = int64(len(body))
+	req.GetBody = func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(body)), nil
+	}
+	return body, nil
+}
+
+func orDefault(value string, fallback string) string {
+	if value == "" {
+		return fallback
+	}
+	return value
+}
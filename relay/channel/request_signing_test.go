@@ -0,0 +1,73 @@
+package channel
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/QuantumNous/new-api/dto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSignHmacRequest(t *testing.T) {
+	body := `{"model":"gpt-4o"}`
+	req, err := http.NewRequest(http.MethodPost, "https://gateway.internal/v1/chat/completions?b=2&a=1", strings.NewReader(body))
+	require.NoError(t, err)
+	req.Header.Set("X-Tenant", "acme")
+
+	signing := &dto.RequestSigning{
+		Type:             dto.RequestSigningTypeHmac,
+		HmacSecret:       "s3cret",
+		HmacKeyId:        "key-1",
+		HmacStringToSign: "{method}\n{path}\n{query}\n{timestamp}\n{header:X-Tenant}\n{body_sha256}",
+	}
+	bodyHash := sha256.Sum256([]byte(body))
+	signHmacRequest(req, signing, hex.EncodeToString(bodyHash[:]), time.Unix(1700000000, 0))
+
+	expected := "POST\n/v1/chat/completions\na=1&b=2\n1700000000\nacme\n" + hex.EncodeToString(bodyHash[:])
+	mac := hmac.New(sha256.New, []byte("s3cret"))
+	mac.Write([]byte(expected))
+	assert.Equal(t, hex.EncodeToString(mac.Sum(nil)), req.Header.Get("X-Signature"))
+	assert.Equal(t, "1700000000", req.Header.Get("X-Timestamp"))
+	assert.Equal(t, "key-1", req.Header.Get("X-Key-Id"))
+}
+
+func TestSignUpstreamRequest_AwsSigV4KeepsBody(t *testing.T) {
+	body := `{"input":"hello"}`
+	req, err := http.NewRequest(http.MethodPost, "https://abc.execute-api.us-east-1.amazonaws.com/prod/v1/embeddings", strings.NewReader(body))
+	require.NoError(t, err)
+	req.Header.Set("Authorization", "Bearer sk-upstream")
+
+	err = signUpstreamRequest(context.Background(), req, &dto.RequestSigning{
+		Type:               dto.RequestSigningTypeAwsSigV4,
+		AwsRegion:          "us-east-1",
+		AwsAccessKeyId:     "AKIDEXAMPLE",
+		AwsSecretAccessKey: "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY",
+	})
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(req.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/"))
+	assert.Contains(t, req.Header.Get("Authorization"), "/us-east-1/execute-api/aws4_request")
+	assert.NotEmpty(t, req.Header.Get("X-Amz-Date"))
+
+	sent, err := io.ReadAll(req.Body)
+	require.NoError(t, err)
+	assert.Equal(t, body, string(sent))
+	assert.Equal(t, int64(len(body)), req.ContentLength)
+}
+
+func TestValidateRequestSigning(t *testing.T) {
+	assert.NoError(t, ValidateRequestSigning(nil))
+	assert.NoError(t, ValidateRequestSigning(&dto.RequestSigning{Type: dto.RequestSigningTypeHmac, HmacSecret: "x"}))
+	assert.Error(t, ValidateRequestSigning(&dto.RequestSigning{Type: dto.RequestSigningTypeHmac}))
+	assert.Error(t, ValidateRequestSigning(&dto.RequestSigning{Type: dto.RequestSigningTypeHmac, HmacSecret: "x", HmacAlgorithm: "md5"}))
+	assert.Error(t, ValidateRequestSigning(&dto.RequestSigning{Type: dto.RequestSigningTypeAwsSigV4, AwsRegion: "us-east-1"}))
+	assert.Error(t, ValidateRequestSigning(&dto.RequestSigning{Type: "kerberos"}))
+	assert.Error(t, ValidateRequestSigning(&dto.RequestSigning{TlsClientCert: "not a cert", TlsClientKey: "not a key"}))
+}
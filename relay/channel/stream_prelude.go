@@ -0,0 +1,172 @@
+package channel
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/QuantumNous/new-api/dto"
+
+	"github.com/bytedance/gopkg/util/gopool"
+)
+
+// 流式响应开头缓冲：上游返回 200 后先读取响应开头，直到出现首个内容 token、达到字节上限或等待超时，
+// 再交给各渠道的流式处理。窗口内上游报错、断开或返回空流时还没有任何数据写给客户端，
+// 返回错误即可由重试逻辑透明地换渠道重试
+const (
+	defaultStreamPreludeMaxBytes  = 4096
+	defaultStreamPreludeMaxWait   = 10 * time.Second
+	maxStreamPreludeMaxBytes      = 1 << 20
+	maxStreamPreludeMaxWaitMillis = 60000
+	streamPreludeReadSize         = 4096
+)
+
+// 内容 token：对话、思考、工具调用参数等字段出现非空字符串值
+var streamContentTokenRegex = regexp.MustCompile(`"(content|text|delta|reasoning_content|reasoning|thinking|arguments|partial_json)"\s*:\s*"[^"]`)
+
+// 上游在流中返回的错误事件
+var streamErrorEventRegex = regexp.MustCompile(`"error"\s*:\s*\{|"type"\s*:\s*"(error|response\.failed)"|(^|\n)event:\s*error`)
+
+// ValidateStreamPrelude 校验渠道的流式响应开头缓冲设置
+func ValidateStreamPrelude(prelude *dto.StreamPrelude) error {
+	if prelude == nil {
+		return nil
+	}
+	if prelude.MaxBytes < 0 || prelude.MaxBytes > maxStreamPreludeMaxBytes {
+		return fmt.Errorf("max_bytes must be between 0 and %d", maxStreamPreludeMaxBytes)
+	}
+	if prelude.MaxWaitMs < 0 || prelude.MaxWaitMs > maxStreamPreludeMaxWaitMillis {
+		return fmt.Errorf("max_wait_ms must be between 0 and %d", maxStreamPreludeMaxWaitMillis)
+	}
+	return nil
+}
+
+func streamPreludeLimits(prelude *dto.StreamPrelude) (int, time.Duration) {
+	if prelude == nil {
+		return defaultStreamPreludeMaxBytes, defaultStreamPreludeMaxWait
+	}
+	maxWait := defaultStreamPreludeMaxWait
+	if prelude.MaxWaitMs > 0 {
+		maxWait = time.Duration(prelude.MaxWaitMs) * time.Millisecond
+	}
+	return prelude.MaxBytes, maxWait
+}
+
+// isBufferableStream 只缓冲文本事件流，音频等二进制流直接转发
+func isBufferableStream(resp *http.Response) bool {
+	contentType := resp.Header.Get("Content-Type")
+	return strings.Contains(contentType, "text/event-stream") || strings.Contains(contentType, "ndjson")
+}
+
+type preludeRead struct {
+	data []byte
+	err  error
+}
+
+// bufferStreamPrelude 缓冲上游流式响应的开头，缓冲的数据在交给流式处理时原样放回响应体；
+// 首个内容 token 之前上游报错、断开或返回空流时返回错误
+func bufferStreamPrelude(ctx context.Context, resp *http.Response, prelude *dto.StreamPrelude) error {
+	maxBytes, maxWait := streamPreludeLimits(prelude)
+	if maxBytes <= 0 || resp.Body == nil || !isBufferableStream(resp) {
+		return nil
+	}
+	body := resp.Body
+	timer := time.NewTimer(maxWait)
+	defer timer.Stop()
+
+	var buffered bytes.Buffer
+	for {
+		reads := make(chan preludeRead, 1)
+		gopool.Go(func() {
+			data := make([]byte, streamPreludeReadSize)
+			n, err := body.Read(data)
+			reads <- preludeRead{data: data[:n], err: err}
+		})
+		select {
+		case read := <-reads:
+			buffered.Write(read.data)
+			if streamContentTokenRegex.Match(buffered.Bytes()) {
+				resp.Body = newPreludeBody(buffered.Bytes(), nil, read.err, body)
+				return nil
+			}
+			if streamErrorEventRegex.Match(buffered.Bytes()) {
+				return fmt.Errorf("upstream stream failed before first content: %s", truncatePrelude(buffered.String()))
+			}
+			if buffered.Len() >= maxBytes {
+				resp.Body = newPreludeBody(buffered.Bytes(), nil, read.err, body)
+				return nil
+			}
+			if errors.Is(read.err, io.EOF) {
+				if buffered.Len() == 0 {
+					return errors.New("upstream closed the stream without sending any data")
+				}
+				// 没有内容也没有错误的完整流（如只有 [DONE]）原样交给流式处理
+				resp.Body = newPreludeBody(buffered.Bytes(), nil, read.err, body)
+				return nil
+			}
+			if read.err != nil {
+				return fmt.Errorf("upstream stream interrupted before first content: %w", read.err)
+			}
+		case <-timer.C:
+			resp.Body = newPreludeBody(buffered.Bytes(), reads, nil, body)
+			return nil
+		case <-ctx.Done():
+			resp.Body = newPreludeBody(buffered.Bytes(), reads, nil, body)
+			return nil
+		}
+	}
+}
+
+func truncatePrelude(s string) string {
+	s = strings.TrimSpace(s)
+	if len(s) > 512 {
+		return s[:512] + "..."
+	}
+	return s
+}
+
+// preludeBody 依次返回已缓冲的数据、尚未完成的那次读取结果，然后继续读取上游响应体
+type preludeBody struct {
+	buffered *bytes.Reader
+	pending  <-chan preludeRead
+	err      error
+	body     io.ReadCloser
+}
+
+func newPreludeBody(buffered []byte, pending <-chan preludeRead, err error, body io.ReadCloser) *preludeBody {
+	return &preludeBody{
+		buffered: bytes.NewReader(bytes.Clone(buffered)),
+		pending:  pending,
+		err:      err,
+		body:     body,
+	}
+}
+
+func (b *preludeBody) Read(p []byte) (int, error) {
+	if b.buffered.Len() > 0 {
+		return b.buffered.Read(p)
+	}
+	if b.pending != nil {
+		read := <-b.pending
+		b.pending = nil
+		b.buffered = bytes.NewReader(read.data)
+		b.err = read.err
+		if b.buffered.Len() > 0 {
+			return b.buffered.Read(p)
+		}
+	}
+	if b.err != nil {
+		return 0, b.err
+	}
+	return b.body.Read(p)
+}
+
+func (b *preludeBody) Close() error {
+	return b.body.Close()
+}
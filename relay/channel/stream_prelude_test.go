@@ -0,0 +1,74 @@
+package channel
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"testing/iotest"
+	"time"
+
+	"github.com/QuantumNous/new-api/dto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newStreamResponse(body io.Reader) *http.Response {
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     http.Header{"Content-Type": []string{"text/event-stream"}},
+		Body:       io.NopCloser(body),
+	}
+}
+
+func TestBufferStreamPreludeReplaysContent(t *testing.T) {
+	stream := "data: {\"choices\":[{\"delta\":{\"role\":\"assistant\",\"content\":\"\"}}]}\n\n" +
+		"data: {\"choices\":[{\"delta\":{\"content\":\"Hello\"}}]}\n\n" +
+		"data: [DONE]\n\n"
+	resp := newStreamResponse(iotest.OneByteReader(strings.NewReader(stream)))
+	require.NoError(t, bufferStreamPrelude(context.Background(), resp, nil))
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	assert.Equal(t, stream, string(body))
+}
+
+func TestBufferStreamPreludeFailsBeforeContent(t *testing.T) {
+	resp := newStreamResponse(strings.NewReader("event: error\ndata: {\"type\":\"error\",\"error\":{\"type\":\"overloaded_error\"}}\n\n"))
+	err := bufferStreamPrelude(context.Background(), resp, nil)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "overloaded_error")
+
+	resp = newStreamResponse(strings.NewReader(""))
+	assert.Error(t, bufferStreamPrelude(context.Background(), resp, nil))
+
+	resp = newStreamResponse(iotest.ErrReader(io.ErrUnexpectedEOF))
+	assert.ErrorIs(t, bufferStreamPrelude(context.Background(), resp, nil), io.ErrUnexpectedEOF)
+}
+
+func TestBufferStreamPreludeLimits(t *testing.T) {
+	// 错误出现在已转发的内容之后时不再拦截
+	stream := "data: {\"choices\":[{\"delta\":{\"content\":\"Hi\"}}]}\n\ndata: {\"error\":{\"message\":\"boom\"}}\n\n"
+	resp := newStreamResponse(strings.NewReader(stream))
+	require.NoError(t, bufferStreamPrelude(context.Background(), resp, nil))
+
+	// 关闭缓冲时不读取响应体
+	resp = newStreamResponse(strings.NewReader(""))
+	require.NoError(t, bufferStreamPrelude(context.Background(), resp, &dto.StreamPrelude{MaxBytes: 0}))
+
+	// 等待超时后未完成的读取结果仍按顺序返回
+	reader, writer := io.Pipe()
+	resp = newStreamResponse(reader)
+	require.NoError(t, bufferStreamPrelude(context.Background(), resp, &dto.StreamPrelude{MaxBytes: 4096, MaxWaitMs: 20}))
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		_, _ = writer.Write([]byte("data: late\n\n"))
+		_ = writer.Close()
+	}()
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	assert.Equal(t, "data: late\n\n", string(body))
+
+	assert.Error(t, ValidateStreamPrelude(&dto.StreamPrelude{MaxBytes: -1}))
+	assert.NoError(t, ValidateStreamPrelude(&dto.StreamPrelude{MaxBytes: 8192, MaxWaitMs: 5000}))
+}
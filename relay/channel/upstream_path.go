@@ -0,0 +1,115 @@
+package channel
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/QuantumNous/new-api/relay/common"
+)
+
+// 上游路径模板：渠道可以按端点类型覆盖适配器生成的上游请求路径，用于路径不标准的 OpenAI 兼容上游，
+// 例如 {"chat_completions": "/openai/deployments/{model}/chat/completions?api-version={ver}"}。
+// 端点类型由适配器生成的默认路径识别；模板以 / 开头时拼接在渠道地址之后，也可以是完整的 URL。
+// 模板中的 {model} 替换为经路径转义的上游模型名称，{ver}（或 {api_version}）替换为渠道的 API 版本，{path} 替换为默认路径
+var upstreamPathEndpoints = []struct {
+	suffix   string
+	endpoint string
+}{
+	// 按后缀匹配，较长的后缀在前
+	{"/chat/completions", "chat_completions"},
+	{"/responses/compact", "responses_compact"},
+	{"/responses", "responses"},
+	{"/completions", "completions"},
+	{"/embeddings", "embeddings"},
+	{"/images/generations", "image_generations"},
+	{"/images/edits", "image_edits"},
+	{"/audio/speech", "audio_speech"},
+	{"/audio/transcriptions", "audio_transcriptions"},
+	{"/audio/translations", "audio_translations"},
+	{"/moderations", "moderations"},
+	{"/rerank", "rerank"},
+	{"/messages", "messages"},
+	{":generateContent", "generate_content"},
+	{":streamGenerateContent", "generate_content"},
+	{"/realtime", "realtime"},
+}
+
+// upstreamPathEndpoint 返回上游请求路径对应的端点类型，无法识别时返回空字符串
+func upstreamPathEndpoint(path string) string {
+	path = strings.TrimSuffix(path, "/")
+	for _, e := range upstreamPathEndpoints {
+		if strings.HasSuffix(path, e.suffix) {
+			return e.endpoint
+		}
+	}
+	return ""
+}
+
+// ValidateUpstreamPathTemplates 校验渠道的上游路径模板
+func ValidateUpstreamPathTemplates(templates map[string]string) error {
+	for endpoint, template := range templates {
+		known := false
+		for _, e := range upstreamPathEndpoints {
+			if e.endpoint == endpoint {
+				known = true
+				break
+			}
+		}
+		if !known {
+			return fmt.Errorf("unknown endpoint type %q", endpoint)
+		}
+		if !strings.HasPrefix(template, "/") && !isAbsoluteUpstreamURL(template) {
+			return fmt.Errorf("template of %s must start with / or be a full URL", endpoint)
+		}
+	}
+	return nil
+}
+
+func isAbsoluteUpstreamURL(template string) bool {
+	for _, scheme := range []string{"http://", "https://", "ws://", "wss://"} {
+		if strings.HasPrefix(template, scheme) {
+			return true
+		}
+	}
+	return false
+}
+
+// applyUpstreamPathTemplate 按渠道的上游路径模板改写适配器生成的请求地址，没有对应模板时原样返回
+func applyUpstreamPathTemplate(info *common.RelayInfo, fullRequestURL string) string {
+	templates := info.ChannelOtherSettings.UpstreamPathTemplates
+	if len(templates) == 0 {
+		return fullRequestURL
+	}
+	parsed, err := url.Parse(fullRequestURL)
+	if err != nil {
+		return fullRequestURL
+	}
+	template, ok := templates[upstreamPathEndpoint(parsed.Path)]
+	if !ok || template == "" {
+		return fullRequestURL
+	}
+	defaultPath := parsed.EscapedPath()
+	if parsed.RawQuery != "" {
+		defaultPath += "?" + parsed.RawQuery
+	}
+	path := strings.NewReplacer(
+		"{model}", url.PathEscape(info.UpstreamModelName),
+		"{ver}", info.ApiVersion,
+		"{api_version}", info.ApiVersion,
+		"{path}", defaultPath,
+	).Replace(template)
+	if isAbsoluteUpstreamURL(path) {
+		return path
+	}
+	return strings.TrimSuffix(info.ChannelBaseUrl, "/") + path
+}
+
+// getRequestURL 返回适配器生成并按上游路径模板改写后的请求地址
+func getRequestURL(a Adaptor, info *common.RelayInfo) (string, error) {
+	fullRequestURL, err := a.GetRequestURL(info)
+	if err != nil {
+		return "", err
+	}
+	return applyUpstreamPathTemplate(info, fullRequestURL), nil
+}
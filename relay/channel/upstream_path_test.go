@@ -0,0 +1,38 @@
+package channel
+
+import (
+	"testing"
+
+	"github.com/QuantumNous/new-api/dto"
+	"github.com/QuantumNous/new-api/relay/common"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestApplyUpstreamPathTemplate(t *testing.T) {
+	info := &common.RelayInfo{ChannelMeta: &common.ChannelMeta{
+		ChannelBaseUrl:    "https://vendor.example.com/",
+		ApiVersion:        "2024-10-21",
+		UpstreamModelName: "gpt-4o",
+		ChannelOtherSettings: dto.ChannelOtherSettings{UpstreamPathTemplates: map[string]string{
+			"chat_completions": "/openai/deployments/{model}/chat/completions?api-version={ver}",
+			"embeddings":       "https://embed.example.com/api/v3{path}",
+		}},
+	}}
+	assert.Equal(t, "https://vendor.example.com/openai/deployments/gpt-4o/chat/completions?api-version=2024-10-21",
+		applyUpstreamPathTemplate(info, "https://vendor.example.com/v1/chat/completions"))
+	assert.Equal(t, "https://embed.example.com/api/v3/v1/embeddings",
+		applyUpstreamPathTemplate(info, "https://vendor.example.com/v1/embeddings"))
+	// model names are escaped as a single path segment
+	info.UpstreamModelName = "org/model?v=1"
+	assert.Equal(t, "https://vendor.example.com/openai/deployments/org%2Fmodel%3Fv=1/chat/completions?api-version=2024-10-21",
+		applyUpstreamPathTemplate(info, "https://vendor.example.com/v1/chat/completions"))
+	// endpoints without a template keep the adaptor URL
+	assert.Equal(t, "https://vendor.example.com/v1/completions", applyUpstreamPathTemplate(info, "https://vendor.example.com/v1/completions"))
+}
+
+func TestValidateUpstreamPathTemplates(t *testing.T) {
+	assert.NoError(t, ValidateUpstreamPathTemplates(nil))
+	assert.NoError(t, ValidateUpstreamPathTemplates(map[string]string{"responses": "/api/v3/responses"}))
+	assert.Error(t, ValidateUpstreamPathTemplates(map[string]string{"chat": "/api/v3/chat"}))
+	assert.Error(t, ValidateUpstreamPathTemplates(map[string]string{"chat_completions": "api/v3/chat"}))
+}
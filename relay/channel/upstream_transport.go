@@ -0,0 +1,95 @@
+package channel
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/QuantumNous/new-api/dto"
+	"github.com/QuantumNous/new-api/service"
+)
+
+// ValidateUpstreamTLS 校验渠道的上游证书校验设置，nil 表示未启用
+func ValidateUpstreamTLS(upstreamTLS *dto.UpstreamTLS) error {
+	if upstreamTLS == nil {
+		return nil
+	}
+	return service.ValidateUpstreamTLSOptions(service.UpstreamTLSOptions{
+		CaPEM:              upstreamTLS.CaCert,
+		DisableSystemRoots: upstreamTLS.DisableSystemRoots,
+		PinnedSha256:       upstreamTLS.PinnedSha256,
+	})
+}
+
+// upstreamTLSOptions 合并请求签名中的双向 TLS 证书与渠道的上游证书校验设置
+func upstreamTLSOptions(settings dto.ChannelOtherSettings) service.UpstreamTLSOptions {
+	options := service.UpstreamTLSOptions{}
+	if signing := settings.RequestSigning; signing != nil && signing.TlsClientCert != "" {
+		options.ClientCertPEM = signing.TlsClientCert
+		options.ClientKeyPEM = signing.TlsClientKey
+		if signing.TlsCaCert != "" {
+			// 请求签名中的 CA 保持原有语义：仅信任该 CA
+			options.CaPEM = signing.TlsCaCert
+			options.DisableSystemRoots = true
+		}
+	}
+	if upstreamTLS := settings.UpstreamTLS; upstreamTLS != nil {
+		if upstreamTLS.CaCert != "" {
+			options.CaPEM = strings.TrimSpace(options.CaPEM + "\n" + upstreamTLS.CaCert)
+		}
+		options.DisableSystemRoots = options.DisableSystemRoots || upstreamTLS.DisableSystemRoots
+		options.PinnedSha256 = upstreamTLS.PinnedSha256
+	}
+	return options
+}
+
+// ValidateUpstreamDialer 校验渠道的拨号设置，nil 表示未启用
+func ValidateUpstreamDialer(upstreamDialer *dto.UpstreamDialer) error {
+	if upstreamDialer == nil {
+		return nil
+	}
+	return service.ValidateUpstreamDialerOptions(upstreamDialerOptions(upstreamDialer))
+}
+
+func upstreamDialerOptions(upstreamDialer *dto.UpstreamDialer) service.UpstreamDialerOptions {
+	if upstreamDialer == nil {
+		return service.UpstreamDialerOptions{}
+	}
+	return service.UpstreamDialerOptions{
+		IPPreference:    upstreamDialer.IPPreference,
+		FallbackDelayMs: upstreamDialer.FallbackDelayMs,
+		Hosts:           upstreamDialer.Hosts,
+	}
+}
+
+// getUpstreamHttpClient 渠道配置了客户端证书、自定义 CA、证书固定或拨号设置时返回专用客户端，否则返回 nil
+func getUpstreamHttpClient(settings dto.ChannelOtherSettings, proxyURL string) (*http.Client, error) {
+	tlsOptions := upstreamTLSOptions(settings)
+	dialerOptions := upstreamDialerOptions(settings.UpstreamDialer)
+	if tlsOptions.IsEmpty() && dialerOptions.IsEmpty() {
+		return nil, nil
+	}
+	return service.GetUpstreamHttpClient(proxyURL, tlsOptions, dialerOptions)
+}
+
+// GetChannelHttpClient 返回发往渠道上游的 HTTP 客户端：Unix 套接字客户端、专用客户端、代理客户端或默认客户端，与转发请求使用同一连接池
+func GetChannelHttpClient(settings dto.ChannelOtherSettings, baseURL string, proxyURL string) (*http.Client, error) {
+	if service.IsUnixSocketBaseURL(baseURL) {
+		return service.GetUnixSocketHttpClient(), nil
+	}
+	client, err := getUpstreamHttpClient(settings, proxyURL)
+	if err != nil {
+		return nil, fmt.Errorf("new upstream http client failed: %w", err)
+	}
+	if client != nil {
+		return client, nil
+	}
+	if proxyURL != "" {
+		client, err = service.NewProxyHttpClient(proxyURL)
+		if err != nil {
+			return nil, fmt.Errorf("new proxy http client failed: %w", err)
+		}
+		return client, nil
+	}
+	return service.GetHttpClient(), nil
+}
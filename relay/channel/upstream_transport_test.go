@@ -0,0 +1,37 @@
+package channel
+
+import (
+	"testing"
+
+	"github.com/QuantumNous/new-api/dto"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUpstreamTLSOptions(t *testing.T) {
+	assert.True(t, upstreamTLSOptions(dto.ChannelOtherSettings{}).IsEmpty())
+	// the signing CA is only used together with a client certificate
+	assert.True(t, upstreamTLSOptions(dto.ChannelOtherSettings{RequestSigning: &dto.RequestSigning{TlsCaCert: "signing-ca"}}).IsEmpty())
+
+	options := upstreamTLSOptions(dto.ChannelOtherSettings{
+		RequestSigning: &dto.RequestSigning{TlsClientCert: "cert", TlsClientKey: "key", TlsCaCert: "signing-ca"},
+		UpstreamTLS:    &dto.UpstreamTLS{CaCert: "private-ca", PinnedSha256: []string{"pin"}},
+	})
+	assert.Equal(t, "cert", options.ClientCertPEM)
+	assert.Equal(t, "signing-ca\nprivate-ca", options.CaPEM)
+	assert.True(t, options.DisableSystemRoots)
+	assert.Equal(t, []string{"pin"}, options.PinnedSha256)
+
+	options = upstreamTLSOptions(dto.ChannelOtherSettings{UpstreamTLS: &dto.UpstreamTLS{CaCert: "private-ca"}})
+	assert.False(t, options.DisableSystemRoots)
+	assert.Empty(t, options.ClientCertPEM)
+}
+
+func TestUpstreamDialerOptions(t *testing.T) {
+	assert.True(t, upstreamDialerOptions(nil).IsEmpty())
+	options := upstreamDialerOptions(&dto.UpstreamDialer{IPPreference: "prefer_ipv4", Hosts: map[string]string{"api.example.com": "203.0.113.7"}})
+	assert.Equal(t, "prefer_ipv4", options.IPPreference)
+	assert.Equal(t, "203.0.113.7", options.Hosts["api.example.com"])
+
+	assert.NoError(t, ValidateUpstreamDialer(nil))
+	assert.Error(t, ValidateUpstreamDialer(&dto.UpstreamDialer{IPPreference: "ipv4_only"}))
+}
@@ -6,9 +6,9 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"regexp"
 	"strings"
 
-	"github.com/QuantumNous/new-api/common"
 	"github.com/QuantumNous/new-api/dto"
 	"github.com/QuantumNous/new-api/relay/channel"
 	"github.com/QuantumNous/new-api/relay/channel/claude"
@@ -46,6 +46,25 @@ var claudeModelMap = map[string]string{
 	"claude-opus-4-6":            "claude-opus-4-6",
 }
 
+var claudeDatedModelRegex = regexp.MustCompile(`^(claude-.+)-(\d{8})$`)
+
+// getClaudeModelID maps an Anthropic model name to its Vertex AI model ID. Vertex
+// separates the snapshot date with "@" (claude-sonnet-4-5@20250929); names that
+// are not in claudeModelMap are converted by that rule, and IDs that already
+// contain "@" or carry no date are passed through.
+func getClaudeModelID(modelName string) string {
+	if v, ok := claudeModelMap[modelName]; ok {
+		return v
+	}
+	if strings.Contains(modelName, "@") {
+		return modelName
+	}
+	if m := claudeDatedModelRegex.FindStringSubmatch(modelName); m != nil {
+		return m[1] + "@" + m[2]
+	}
+	return modelName
+}
+
 const anthropicVersion = "vertex-2023-10-16"
 
 type Adaptor struct {
@@ -92,11 +111,7 @@ func removeFunctionResponseID(request *dto.GeminiChatRequest) {
 }
 
 func (a *Adaptor) ConvertClaudeRequest(c *gin.Context, info *relaycommon.RelayInfo, request *dto.ClaudeRequest) (any, error) {
-	if v, ok := claudeModelMap[info.UpstreamModelName]; ok {
-		c.Set("request_model", v)
-	} else {
-		c.Set("request_model", request.Model)
-	}
+	c.Set("request_model", getClaudeModelID(info.UpstreamModelName))
 	vertexClaudeReq := copyRequest(request, anthropicVersion)
 	return vertexClaudeReq, nil
 }
@@ -124,82 +139,60 @@ func (a *Adaptor) Init(info *relaycommon.RelayInfo) {
 }
 
 func (a *Adaptor) getRequestUrl(info *relaycommon.RelayInfo, modelName, suffix string) (string, error) {
-	region := GetModelRegion(info.ApiVersion, info.OriginModelName)
+	region := GetModelRegion(info.ApiVersion, info.OriginModelName, info.UpstreamModelName)
+	host := regionHost(region)
 	if info.ChannelOtherSettings.VertexKeyType != dto.VertexKeyTypeAPIKey {
-		adc := &Credentials{}
-		if err := common.Unmarshal([]byte(info.ApiKey), adc); err != nil {
-			return "", fmt.Errorf("failed to decode credentials file: %w", err)
+		adc, err := ParseCredentials(info.ApiKey)
+		if err != nil {
+			return "", err
 		}
 		a.AccountCredentials = *adc
 
-		if a.RequestMode == RequestModeGemini {
-			if region == "global" {
-				return fmt.Sprintf(
-					"https://aiplatform.googleapis.com/v1/projects/%s/locations/global/publishers/google/models/%s:%s",
-					adc.ProjectID,
-					modelName,
-					suffix,
-				), nil
-			} else {
-				return fmt.Sprintf(
-					"https://%s-aiplatform.googleapis.com/v1/projects/%s/locations/%s/publishers/google/models/%s:%s",
-					region,
-					adc.ProjectID,
-					region,
-					modelName,
-					suffix,
-				), nil
-			}
-		} else if a.RequestMode == RequestModeClaude {
-			if region == "global" {
-				return fmt.Sprintf(
-					"https://aiplatform.googleapis.com/v1/projects/%s/locations/global/publishers/anthropic/models/%s:%s",
-					adc.ProjectID,
-					modelName,
-					suffix,
-				), nil
-			} else {
-				return fmt.Sprintf(
-					"https://%s-aiplatform.googleapis.com/v1/projects/%s/locations/%s/publishers/anthropic/models/%s:%s",
-					region,
-					adc.ProjectID,
-					region,
-					modelName,
-					suffix,
-				), nil
-			}
-		} else if a.RequestMode == RequestModeOpenSource {
+		switch a.RequestMode {
+		case RequestModeGemini:
 			return fmt.Sprintf(
-				"https://aiplatform.googleapis.com/v1beta1/projects/%s/locations/%s/endpoints/openapi/chat/completions",
+				"https://%s/v1/projects/%s/locations/%s/publishers/google/models/%s:%s",
+				host,
 				adc.ProjectID,
 				region,
-			), nil
-		}
-	} else {
-		var keyPrefix string
-		if strings.HasSuffix(suffix, "?alt=sse") {
-			keyPrefix = "&"
-		} else {
-			keyPrefix = "?"
-		}
-		if region == "global" {
-			return fmt.Sprintf(
-				"https://aiplatform.googleapis.com/v1/publishers/google/models/%s:%s%skey=%s",
 				modelName,
 				suffix,
-				keyPrefix,
-				info.ApiKey,
 			), nil
-		} else {
+		case RequestModeClaude:
 			return fmt.Sprintf(
-				"https://%s-aiplatform.googleapis.com/v1/publishers/google/models/%s:%s%skey=%s",
+				"https://%s/v1/projects/%s/locations/%s/publishers/anthropic/models/%s:%s",
+				host,
+				adc.ProjectID,
 				region,
 				modelName,
 				suffix,
-				keyPrefix,
-				info.ApiKey,
 			), nil
+		case RequestModeOpenSource:
+			return fmt.Sprintf(
+				"https://%s/v1beta1/projects/%s/locations/%s/endpoints/openapi/chat/completions",
+				host,
+				adc.ProjectID,
+				region,
+			), nil
+		}
+	} else {
+		if a.RequestMode != RequestModeGemini {
+			return "", errors.New("vertex api key mode only supports gemini models")
+		}
+		var keyPrefix string
+		if strings.HasSuffix(suffix, "?alt=sse") {
+			keyPrefix = "&"
+		} else {
+			keyPrefix = "?"
 		}
+		return fmt.Sprintf(
+			"https://%s/v1/publishers/google/models/%s:%s%skey=%s",
+			host,
+			modelName,
+			suffix,
+			keyPrefix,
+			info.ApiKey,
+		), nil
 	}
 	return "", errors.New("unsupported request mode")
 }
@@ -238,11 +231,7 @@ func (a *Adaptor) GetRequestURL(info *relaycommon.RelayInfo) (string, error) {
 		} else {
 			suffix = "rawPredict"
 		}
-		model := info.UpstreamModelName
-		if v, ok := claudeModelMap[info.UpstreamModelName]; ok {
-			model = v
-		}
-		return a.getRequestUrl(info, model, suffix)
+		return a.getRequestUrl(info, getClaudeModelID(info.UpstreamModelName), suffix)
 	} else if a.RequestMode == RequestModeOpenSource {
 		return a.getRequestUrl(info, "", "")
 	}
@@ -1,22 +1,72 @@
 package vertex
 
-import "github.com/QuantumNous/new-api/common"
+import (
+	"strings"
 
-func GetModelRegion(other string, localModelName string) string {
-	// if other is json string
-	if common.IsJsonObject(other) {
-		m, err := common.StrToMap(other)
-		if err != nil {
-			return other // return original if parsing fails
+	"github.com/QuantumNous/new-api/common"
+)
+
+const defaultRegion = "global"
+
+// GetModelRegion resolves the deployment region for a model. other is either a
+// single region or a JSON object mapping model names to regions; keys ending in
+// "*" match by prefix (the longest prefix wins) and "default" is the fallback.
+// The model names are tried in order, e.g. the requested name before the mapped
+// upstream name.
+func GetModelRegion(other string, modelNames ...string) string {
+	if !common.IsJsonObject(other) {
+		if region := strings.TrimSpace(other); region != "" {
+			return region
+		}
+		return defaultRegion
+	}
+	m, err := common.StrToMap(other)
+	if err != nil {
+		return other // return original if parsing fails
+	}
+	regionOf := func(key string) string {
+		region, _ := m[key].(string)
+		return strings.TrimSpace(region)
+	}
+	for _, name := range modelNames {
+		if region := regionOf(name); name != "" && region != "" {
+			return region
+		}
+	}
+	for _, name := range modelNames {
+		if region := wildcardRegion(m, name); name != "" && region != "" {
+			return region
 		}
-		if m[localModelName] != nil {
-			return m[localModelName].(string)
-		} else {
-			if v, ok := m["default"]; ok {
-				return v.(string)
-			}
-			return "global"
+	}
+	if region := regionOf("default"); region != "" {
+		return region
+	}
+	return defaultRegion
+}
+
+// wildcardRegion returns the region of the longest "prefix*" key matching name
+func wildcardRegion(m map[string]any, name string) string {
+	bestPrefix := ""
+	bestRegion := ""
+	for key, value := range m {
+		prefix, ok := strings.CutSuffix(key, "*")
+		region, _ := value.(string)
+		region = strings.TrimSpace(region)
+		if !ok || region == "" || !strings.HasPrefix(name, prefix) {
+			continue
 		}
+		if bestRegion == "" || len(prefix) > len(bestPrefix) {
+			bestPrefix, bestRegion = prefix, region
+		}
+	}
+	return bestRegion
+}
+
+// regionHost returns the Vertex AI API host serving the region; the global
+// location has no regional prefix.
+func regionHost(region string) string {
+	if region == defaultRegion {
+		return "aiplatform.googleapis.com"
 	}
-	return other
+	return region + "-aiplatform.googleapis.com"
 }
@@ -2066,6 +2066,30 @@ func TestRemoveDisabledFieldsAllowInferenceGeo(t *testing.T) {
 	assertJSONEqual(t, `{"inference_geo":"eu","store":true}`, string(out))
 }
 
+func TestRemoveDisabledFieldsForceServiceTier(t *testing.T) {
+	input := `{
+		"service_tier":"priority",
+		"store":true
+	}`
+	settings := dto.ChannelOtherSettings{
+		ForceServiceTier: "flex",
+	}
+
+	out, err := RemoveDisabledFields([]byte(input), settings, false)
+	if err != nil {
+		t.Fatalf("RemoveDisabledFields returned error: %v", err)
+	}
+	assertJSONEqual(t, `{"service_tier":"flex","store":true}`, string(out))
+
+	// an invalid forced tier is ignored and the request tier is filtered as usual
+	settings.ForceServiceTier = "ultra"
+	out, err = RemoveDisabledFields([]byte(input), settings, false)
+	if err != nil {
+		t.Fatalf("RemoveDisabledFields returned error: %v", err)
+	}
+	assertJSONEqual(t, `{"store":true}`, string(out))
+}
+
 func assertJSONEqual(t *testing.T, want, got string) {
 	t.Helper()
 
@@ -12,6 +12,7 @@ import (
 	"github.com/QuantumNous/new-api/dto"
 	relayconstant "github.com/QuantumNous/new-api/relay/constant"
 	"github.com/QuantumNous/new-api/setting/model_setting"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
 	"github.com/QuantumNous/new-api/types"
 
 	"github.com/gin-gonic/gin"
@@ -57,6 +58,13 @@ type ResponsesUsageInfo struct {
 	BuiltInTools map[string]*BuildInToolInfo
 }
 
+// AzureResponsesEndpoint Azure OpenAI Responses API 的一种请求路径与 api-version 组合：
+// v1 接口为 /openai/v1/responses，按日期版本的接口为 /openai/responses
+type AzureResponsesEndpoint struct {
+	Path       string
+	ApiVersion string
+}
+
 type ChannelMeta struct {
 	ChannelType          int
 	ChannelId            int
@@ -83,16 +91,25 @@ type TokenCountMeta struct {
 }
 
 type RelayInfo struct {
-	TokenId           int
-	TokenKey          string
-	TokenGroup        string
-	UserId            int
-	UsingGroup        string // 使用的分组，当auto跨分组重试时，会变动
-	UserGroup         string // 用户所在分组
-	TokenUnlimited    bool
-	StartTime         time.Time
-	FirstResponseTime time.Time
-	isFirstResponse   bool
+	TokenId              int
+	TokenKey             string
+	TokenGroup           string
+	TokenCompatMode      string              // 令牌级兼容模式，优先于渠道设置
+	TokenContextOverflow string              // 令牌级上下文超限策略，优先于全局设置
+	ClientApiVersion     constant.ApiVersion // 下游兼容格式版本，请求头优先于令牌设置
+	ClientProfile        string              // 令牌选择的客户端兼容配置
+	UserId               int
+	UsingGroup           string // 使用的分组，当auto跨分组重试时，会变动
+	UserGroup            string // 用户所在分组
+	TokenUnlimited       bool
+	TokenRequestMode     bool   // 令牌按请求次数限制，每次请求在预扣费时计数
+	TokenRequestQuota    int    // 按次模式每个周期允许的请求数
+	TokenRequestPeriod   string // 按次模式的重置周期
+	SystemPromptEnforced bool   // 请求已按分组系统提示词策略改写，请求体不再透传
+	RequestRewritten     bool   // 网关已改写请求内容（如上下文截断），请求体不再透传
+	StartTime            time.Time
+	FirstResponseTime    time.Time
+	isFirstResponse      bool
 	//SendLastReasoningResponse bool
 	IsStream               bool
 	IsGeminiBatchEmbedding bool
@@ -112,6 +129,7 @@ type RelayInfo struct {
 	IsFirstRequest         bool
 	AudioUsage             bool
 	ReasoningEffort        string
+	ServiceTier            string // 上游实际提供的 OpenAI service_tier
 	UserSetting            dto.UserSetting
 	UserEmail              string
 	UserQuota              int
@@ -160,6 +178,23 @@ type RelayInfo struct {
 	// 最终请求到上游的格式。可由 adaptor 显式设置；
 	// 若为空，调用 GetFinalRequestRelayFormat 会回退到 RequestConversionChain 的最后一项或 RelayFormat。
 	FinalRequestRelayFormat types.RelayFormat
+	// Capture 请求捕获，开启时记录各转换阶段的载荷，nil 表示未开启
+	Capture *RequestCapture
+	// ModerationFlagWords 提示词命中的待审词，非空时请求结束后加入人工审核队列
+	ModerationFlagWords []string
+	// ResponsesConversation Responses 请求经兼容层转换后的对话（不含 instructions），
+	// 响应完成后与输出一起存储供 previous_response_id 续接，nil 表示不存储
+	ResponsesConversation []dto.Message
+	// ResponsesPreviousId 存储的 Responses 请求续接的上一个响应 ID
+	ResponsesPreviousId string
+	// ResponsesHistoryLen ResponsesConversation 中从上一个响应还原的历史消息数，这些消息已保存在对话链上，不再重复存储
+	ResponsesHistoryLen int
+	// ResponsesConversationId 续接的对话链 ID，新响应加入同一条链
+	ResponsesConversationId string
+	// ResponsesBackgroundId 后台执行的 Responses 请求由网关生成的响应 ID，兼容层转换的响应沿用该 ID
+	ResponsesBackgroundId string
+	// AzureResponsesEndpoint Azure 渠道本次 Responses 请求协商使用的路径与 api-version，nil 时按渠道设置生成
+	AzureResponsesEndpoint *AzureResponsesEndpoint
 
 	ThinkingContentInfo
 	TokenCountMeta
@@ -224,6 +259,47 @@ func (info *RelayInfo) InitChannelMeta(c *gin.Context) {
 	}
 }
 
+// GetCompatMode returns the effective protocol conversion compat mode.
+// The token setting takes precedence over the channel setting; lenient is the default.
+func (info *RelayInfo) GetCompatMode() constant.CompatMode {
+	if info.TokenCompatMode != "" {
+		return constant.CompatMode(info.TokenCompatMode)
+	}
+	if info.ChannelMeta != nil && info.ChannelOtherSettings.CompatMode != "" {
+		return constant.CompatMode(info.ChannelOtherSettings.CompatMode)
+	}
+	return constant.CompatModeLenient
+}
+
+// PassThroughBody reports whether the raw request body is forwarded unchanged. Requests
+// rewritten by a group system prompt policy or by the gateway itself are always
+// re-serialized, so pass-through channels neither bypass the policy nor drop the rewrite.
+func (info *RelayInfo) PassThroughBody() bool {
+	if info.SystemPromptEnforced || info.RequestRewritten {
+		return false
+	}
+	return model_setting.GetGlobalSettings().PassThroughRequestEnabled ||
+		(info.ChannelMeta != nil && info.ChannelSetting.PassThroughBodyEnabled)
+}
+
+// GetClientProfile returns the client profile selected by the token, nil when none is set.
+func (info *RelayInfo) GetClientProfile() *operation_setting.ClientProfile {
+	return operation_setting.GetClientProfile(info.ClientProfile)
+}
+
+// ResponsesReasoningItems reports whether reasoning converted from chat completions is
+// emitted as separate reasoning output items. The client profile takes precedence over
+// the channel setting, which takes precedence over the global one.
+func (info *RelayInfo) ResponsesReasoningItems() bool {
+	if profile := info.GetClientProfile(); profile != nil && profile.ResponsesReasoningItems != nil {
+		return *profile.ResponsesReasoningItems
+	}
+	if info.ChannelMeta != nil && info.ChannelOtherSettings.ResponsesReasoningItems != nil {
+		return *info.ChannelOtherSettings.ResponsesReasoningItems
+	}
+	return model_setting.GetGlobalSettings().ResponsesReasoningItems
+}
+
 func (info *RelayInfo) ToString() string {
 	if info == nil {
 		return "RelayInfo<nil>"
@@ -301,24 +377,26 @@ func (info *RelayInfo) ToString() string {
 
 // 定义支持流式选项的通道类型
 var streamSupportedChannels = map[int]bool{
-	constant.ChannelTypeOpenAI:      true,
-	constant.ChannelTypeAnthropic:   true,
-	constant.ChannelTypeAws:         true,
-	constant.ChannelTypeGemini:      true,
-	constant.ChannelCloudflare:      true,
-	constant.ChannelTypeAzure:       true,
-	constant.ChannelTypeVolcEngine:  true,
-	constant.ChannelTypeOllama:      true,
-	constant.ChannelTypeXai:         true,
-	constant.ChannelTypeDeepSeek:    true,
-	constant.ChannelTypeBaiduV2:     true,
-	constant.ChannelTypeZhipu_v4:    true,
-	constant.ChannelTypeAli:         true,
-	constant.ChannelTypeSubmodel:    true,
-	constant.ChannelTypeCodex:       true,
-	constant.ChannelTypeMoonshot:    true,
-	constant.ChannelTypeMiniMax:     true,
-	constant.ChannelTypeSiliconFlow: true,
+	constant.ChannelTypeOpenAI:          true,
+	constant.ChannelTypeAnthropic:       true,
+	constant.ChannelTypeAws:             true,
+	constant.ChannelTypeBedrockConverse: true,
+	constant.ChannelTypeGemini:          true,
+	constant.ChannelCloudflare:          true,
+	constant.ChannelTypeAzure:           true,
+	constant.ChannelTypeVolcEngine:      true,
+	constant.ChannelTypeOllama:          true,
+	constant.ChannelTypeSelfHosted:      true,
+	constant.ChannelTypeXai:             true,
+	constant.ChannelTypeDeepSeek:        true,
+	constant.ChannelTypeBaiduV2:         true,
+	constant.ChannelTypeZhipu_v4:        true,
+	constant.ChannelTypeAli:             true,
+	constant.ChannelTypeSubmodel:        true,
+	constant.ChannelTypeCodex:           true,
+	constant.ChannelTypeMoonshot:        true,
+	constant.ChannelTypeMiniMax:         true,
+	constant.ChannelTypeSiliconFlow:     true,
 }
 
 func GenRelayInfoWs(c *gin.Context, ws *websocket.Conn) *RelayInfo {
@@ -374,6 +452,7 @@ func GenRelayInfoResponses(c *gin.Context, request *dto.OpenAIResponsesRequest)
 	info := genBaseRelayInfo(c, request)
 	info.RelayMode = relayconstant.RelayModeResponses
 	info.RelayFormat = types.RelayFormatOpenAIResponses
+	info.ResponsesBackgroundId = common.GetContextKeyString(c, constant.ContextKeyResponsesBackgroundId)
 
 	info.ResponsesUsageInfo = &ResponsesUsageInfo{
 		BuiltInTools: make(map[string]*BuildInToolInfo),
@@ -459,10 +538,14 @@ func genBaseRelayInfo(c *gin.Context, request dto.Request) *RelayInfo {
 
 		OriginModelName: common.GetContextKeyString(c, constant.ContextKeyOriginalModel),
 
-		TokenId:        common.GetContextKeyInt(c, constant.ContextKeyTokenId),
-		TokenKey:       common.GetContextKeyString(c, constant.ContextKeyTokenKey),
-		TokenUnlimited: common.GetContextKeyBool(c, constant.ContextKeyTokenUnlimited),
-		TokenGroup:     tokenGroup,
+		TokenId:              common.GetContextKeyInt(c, constant.ContextKeyTokenId),
+		TokenKey:             common.GetContextKeyString(c, constant.ContextKeyTokenKey),
+		TokenUnlimited:       common.GetContextKeyBool(c, constant.ContextKeyTokenUnlimited),
+		TokenGroup:           tokenGroup,
+		TokenCompatMode:      common.GetContextKeyString(c, constant.ContextKeyTokenCompatMode),
+		TokenContextOverflow: common.GetContextKeyString(c, constant.ContextKeyTokenContextOverflow),
+		ClientApiVersion:     constant.ApiVersion(common.GetContextKeyString(c, constant.ContextKeyClientApiVersion)),
+		ClientProfile:        common.GetContextKeyString(c, constant.ContextKeyTokenClientProfile),
 
 		isFirstResponse: true,
 		RelayMode:       relayconstant.Path2RelayMode(c.Request.URL.Path),
@@ -486,6 +569,12 @@ func genBaseRelayInfo(c *gin.Context, request dto.Request) *RelayInfo {
 		info.RelayMode = c.GetInt("relay_mode")
 	}
 
+	if requestQuota, ok := common.GetContextKey(c, constant.ContextKeyTokenRequestQuota); ok {
+		info.TokenRequestMode = true
+		info.TokenRequestQuota, _ = requestQuota.(int)
+		info.TokenRequestPeriod = common.GetContextKeyString(c, constant.ContextKeyTokenRequestPeriod)
+	}
+
 	if strings.HasPrefix(c.Request.URL.Path, "/pg") {
 		info.IsPlayground = true
 		info.RequestURLPath = strings.TrimPrefix(info.RequestURLPath, "/pg")
@@ -760,8 +849,9 @@ func FailTaskInfo(reason string) *TaskInfo {
 // store: 数据存储授权字段，涉及用户隐私（仅 OpenAI、Responses API 支持，默认允许透传，禁用后可能导致 Codex 无法使用）
 // safety_identifier: 安全标识符，用于向 OpenAI 报告违规用户（仅 OpenAI 支持，涉及用户隐私）
 // stream_options.include_obfuscation: 响应流混淆控制字段（仅 OpenAI Responses API 支持）
-func RemoveDisabledFields(jsonData []byte, channelOtherSettings dto.ChannelOtherSettings, channelPassThroughEnabled bool) ([]byte, error) {
-	if model_setting.GetGlobalSettings().PassThroughRequestEnabled || channelPassThroughEnabled {
+// passThroughBody 为 true（即 RelayInfo.PassThroughBody()）时原样返回
+func RemoveDisabledFields(jsonData []byte, channelOtherSettings dto.ChannelOtherSettings, passThroughBody bool) ([]byte, error) {
+	if passThroughBody {
 		return jsonData, nil
 	}
 
@@ -771,8 +861,10 @@ func RemoveDisabledFields(jsonData []byte, channelOtherSettings dto.ChannelOther
 		return jsonData, nil
 	}
 
-	// 默认移除 service_tier，除非明确允许（避免额外计费风险）
-	if !channelOtherSettings.AllowServiceTier {
+	// 渠道强制的 service_tier 优先；否则默认移除，除非明确允许（避免额外计费风险）
+	if forced := channelOtherSettings.GetForceServiceTier(); forced != "" {
+		data["service_tier"] = forced
+	} else if !channelOtherSettings.AllowServiceTier {
 		if _, exists := data["service_tier"]; exists {
 			delete(data, "service_tier")
 		}
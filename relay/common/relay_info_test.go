@@ -1,8 +1,10 @@
 package common
 
 import (
+	"encoding/json"
 	"testing"
 
+	"github.com/QuantumNous/new-api/dto"
 	"github.com/QuantumNous/new-api/types"
 	"github.com/stretchr/testify/require"
 )
@@ -38,3 +40,52 @@ func TestRelayInfoGetFinalRequestRelayFormatNilReceiver(t *testing.T) {
 	var info *RelayInfo
 	require.Equal(t, types.RelayFormat(""), info.GetFinalRequestRelayFormat())
 }
+
+func TestRelayInfoGetBillingServiceTier(t *testing.T) {
+	info := &RelayInfo{
+		Request:     &dto.GeneralOpenAIRequest{ServiceTier: json.RawMessage(`"priority"`)},
+		ChannelMeta: &ChannelMeta{},
+	}
+	// 未允许透传时请求中的 service_tier 会被过滤
+	require.Equal(t, "", info.GetBillingServiceTier())
+
+	info.ChannelOtherSettings.AllowServiceTier = true
+	require.Equal(t, "priority", info.GetBillingServiceTier())
+
+	// 非法的强制层级按未设置处理
+	info.ChannelOtherSettings.ForceServiceTier = "ultra"
+	require.Equal(t, "priority", info.GetBillingServiceTier())
+
+	info.ChannelOtherSettings.ForceServiceTier = "flex"
+	require.Equal(t, "flex", info.GetBillingServiceTier())
+
+	// 上游实际提供的层级优先
+	info.ServiceTier = "default"
+	require.Equal(t, "default", info.GetBillingServiceTier())
+}
+
+func TestRelayInfoResponsesReasoningItemsPrefersClientProfile(t *testing.T) {
+	disabled := false
+	info := &RelayInfo{
+		ClientProfile: "codex",
+		ChannelMeta:   &ChannelMeta{ChannelOtherSettings: dto.ChannelOtherSettings{ResponsesReasoningItems: &disabled}},
+	}
+	require.True(t, info.ResponsesReasoningItems())
+
+	info.ClientProfile = "claude-code"
+	require.False(t, info.ResponsesReasoningItems())
+}
+
+func TestRelayInfoPassThroughBodyDisabledBySystemPromptPolicy(t *testing.T) {
+	info := &RelayInfo{ChannelMeta: &ChannelMeta{ChannelSetting: dto.ChannelSettings{PassThroughBodyEnabled: true}}}
+	require.True(t, info.PassThroughBody())
+
+	info.SystemPromptEnforced = true
+	require.False(t, info.PassThroughBody())
+}
+
+func TestRelayInfoPassThroughBodyDisabledByRequestRewrite(t *testing.T) {
+	info := &RelayInfo{ChannelMeta: &ChannelMeta{ChannelSetting: dto.ChannelSettings{PassThroughBodyEnabled: true}}}
+	info.RequestRewritten = true
+	require.False(t, info.PassThroughBody())
+}
@@ -0,0 +1,129 @@
+package common
+
+import (
+	"io"
+	"sync"
+
+	"github.com/QuantumNous/new-api/common"
+)
+
+const (
+	CaptureStageInbound          = "inbound"
+	CaptureStageConverted        = "converted"
+	CaptureStageUpstreamRequest  = "upstream_request"
+	CaptureStageUpstreamResponse = "upstream_response"
+)
+
+// CaptureStage 一个阶段的载荷快照
+type CaptureStage struct {
+	Name      string `json:"name"`
+	Attempt   int    `json:"attempt"`
+	ChannelId int    `json:"channel_id,omitempty"`
+	Body      string `json:"body"`
+	Size      int    `json:"size"`
+	Truncated bool   `json:"truncated,omitempty"`
+}
+
+// RequestCapture 记录一次请求从入站、各级格式转换、发往上游到上游原始响应的载荷，
+// 单个载荷超过 maxBytes 时截断
+type RequestCapture struct {
+	mu       sync.Mutex
+	maxBytes int
+	stages   []*CaptureStage
+}
+
+func NewRequestCapture(maxBytes int) *RequestCapture {
+	return &RequestCapture{maxBytes: maxBytes}
+}
+
+func (rc *RequestCapture) newStage(name string, attempt int, channelId int) *CaptureStage {
+	stage := &CaptureStage{Name: name, Attempt: attempt, ChannelId: channelId}
+	rc.mu.Lock()
+	rc.stages = append(rc.stages, stage)
+	rc.mu.Unlock()
+	return stage
+}
+
+func (rc *RequestCapture) write(stage *CaptureStage, data []byte) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	stage.Size += len(data)
+	if stage.Truncated {
+		return
+	}
+	if rc.maxBytes > 0 && len(stage.Body)+len(data) > rc.maxBytes {
+		data = data[:rc.maxBytes-len(stage.Body)]
+		stage.Truncated = true
+	}
+	stage.Body += string(data)
+}
+
+// Add 记录一个完整载荷
+func (rc *RequestCapture) Add(name string, attempt int, channelId int, body []byte) {
+	rc.write(rc.newStage(name, attempt, channelId), body)
+}
+
+// Tee 包装 body，在被读取的同时记录其内容
+func (rc *RequestCapture) Tee(name string, attempt int, channelId int, body io.ReadCloser) io.ReadCloser {
+	return &captureReadCloser{ReadCloser: body, capture: rc, stage: rc.newStage(name, attempt, channelId)}
+}
+
+// Stages 返回已记录阶段的副本
+func (rc *RequestCapture) Stages() []CaptureStage {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	stages := make([]CaptureStage, 0, len(rc.stages))
+	for _, stage := range rc.stages {
+		stages = append(stages, *stage)
+	}
+	return stages
+}
+
+type captureReadCloser struct {
+	io.ReadCloser
+	capture *RequestCapture
+	stage   *CaptureStage
+}
+
+func (r *captureReadCloser) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	if n > 0 {
+		r.capture.write(r.stage, p[:n])
+	}
+	return n, err
+}
+
+// CaptureStage 记录当前尝试的一个阶段载荷，未开启捕获时不做任何事
+func (info *RelayInfo) CaptureStage(name string, body []byte) {
+	if info == nil || info.Capture == nil {
+		return
+	}
+	info.Capture.Add(name, info.RetryIndex, info.captureChannelId(), body)
+}
+
+// CaptureStageObject 序列化后记录阶段载荷
+func (info *RelayInfo) CaptureStageObject(name string, v any) {
+	if info == nil || info.Capture == nil {
+		return
+	}
+	data, err := common.Marshal(v)
+	if err != nil {
+		return
+	}
+	info.CaptureStage(name, data)
+}
+
+// CaptureBody 包装上游请求或响应 body 以记录其内容
+func (info *RelayInfo) CaptureBody(name string, body io.ReadCloser) io.ReadCloser {
+	if info == nil || info.Capture == nil || body == nil {
+		return body
+	}
+	return info.Capture.Tee(name, info.RetryIndex, info.captureChannelId(), body)
+}
+
+func (info *RelayInfo) captureChannelId() int {
+	if info.ChannelMeta == nil {
+		return 0
+	}
+	return info.ChannelId
+}
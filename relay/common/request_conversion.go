@@ -33,6 +33,13 @@ func AppendRequestConversionFromRequest(info *RelayInfo, req any) {
 		return
 	}
 	format, ok := GuessRelayFormatFromRequest(req)
+	if info.Capture != nil {
+		stage := CaptureStageConverted
+		if ok {
+			stage += ":" + string(format)
+		}
+		info.CaptureStageObject(stage, req)
+	}
 	if !ok {
 		return
 	}
@@ -0,0 +1,41 @@
+package common
+
+import (
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/dto"
+)
+
+// GetRequestedServiceTier 返回发往上游的 service_tier，与 RemoveDisabledFields 的处理保持一致：
+// 透传请求时为请求中的值，否则渠道强制的层级优先，其次为允许透传时请求中的值
+func (info *RelayInfo) GetRequestedServiceTier() string {
+	requested := ""
+	switch request := info.Request.(type) {
+	case *dto.GeneralOpenAIRequest:
+		if len(request.ServiceTier) > 0 {
+			_ = common.Unmarshal(request.ServiceTier, &requested)
+		}
+	case *dto.OpenAIResponsesRequest:
+		requested = request.ServiceTier
+	}
+	if info.ChannelMeta == nil {
+		return requested
+	}
+	if info.PassThroughBody() {
+		return requested
+	}
+	if forced := info.ChannelOtherSettings.GetForceServiceTier(); forced != "" {
+		return forced
+	}
+	if !info.ChannelOtherSettings.AllowServiceTier {
+		return ""
+	}
+	return requested
+}
+
+// GetBillingServiceTier 返回用于计费的 service_tier：优先使用上游响应中实际提供的层级
+func (info *RelayInfo) GetBillingServiceTier() string {
+	if info.ServiceTier != "" {
+		return info.ServiceTier
+	}
+	return info.GetRequestedServiceTier()
+}
@@ -9,6 +9,7 @@ import (
 	"github.com/QuantumNous/new-api/dto"
 	"github.com/QuantumNous/new-api/relay/channel/xinference"
 	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	"github.com/QuantumNous/new-api/relay/rerank"
 	"github.com/QuantumNous/new-api/service"
 	"github.com/QuantumNous/new-api/types"
 
@@ -25,7 +26,18 @@ func RerankHandler(c *gin.Context, info *relaycommon.RelayInfo, resp *http.Respo
 		println("reranker response body: ", string(responseBody))
 	}
 	var jinaResp dto.RerankResponse
-	if info.ChannelType == constant.ChannelTypeXinference {
+	if info.ChannelOtherSettings.RerankProtocol == rerank.ProtocolVoyage {
+		var voyageResp rerank.VoyageResponse
+		err = common.Unmarshal(responseBody, &voyageResp)
+		if err != nil {
+			return nil, types.NewOpenAIError(err, types.ErrorCodeBadResponseBody, http.StatusInternalServerError)
+		}
+		jinaResp = *voyageResp.ToRerankResponse()
+		if jinaResp.Usage.TotalTokens == 0 {
+			jinaResp.Usage.PromptTokens = info.GetEstimatePromptTokens()
+			jinaResp.Usage.TotalTokens = info.GetEstimatePromptTokens()
+		}
+	} else if info.ChannelType == constant.ChannelTypeXinference {
 		var xinRerankResponse xinference.XinRerankResponse
 		err = common.Unmarshal(responseBody, &xinRerankResponse)
 		if err != nil {
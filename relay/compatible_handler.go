@@ -17,7 +17,6 @@ import (
 	relayconstant "github.com/QuantumNous/new-api/relay/constant"
 	"github.com/QuantumNous/new-api/relay/helper"
 	"github.com/QuantumNous/new-api/service"
-	"github.com/QuantumNous/new-api/setting/model_setting"
 	"github.com/QuantumNous/new-api/setting/operation_setting"
 	"github.com/QuantumNous/new-api/setting/ratio_setting"
 	"github.com/QuantumNous/new-api/types"
@@ -41,6 +40,15 @@ func TextHelper(c *gin.Context, info *relaycommon.RelayInfo) (newAPIError *types
 		return types.NewError(fmt.Errorf("failed to copy request to GeneralOpenAIRequest: %w", err), types.ErrorCodeInvalidRequest, types.ErrOptionWithSkipRetry())
 	}
 
+	// 引用网关文件的 file_id 替换为文件内容，上游无法识别网关的 file_id；替换后的内容只存在于解析后的请求中，不能再透传原始请求体
+	resolved, err := service.ResolveChatFileReferences(info.UserId, info.ChannelId, request)
+	if err != nil {
+		return types.NewError(err, types.ErrorCodeQueryDataError, types.ErrOptionWithSkipRetry())
+	}
+	if resolved {
+		info.RequestRewritten = true
+	}
+
 	if request.WebSearchOptions != nil {
 		c.Set("chat_completion_web_search_context_size", request.WebSearchOptions.SearchContextSize)
 	}
@@ -84,12 +92,12 @@ func TextHelper(c *gin.Context, info *relaycommon.RelayInfo) (newAPIError *types
 	}
 	adaptor.Init(info)
 
-	passThroughGlobal := model_setting.GetGlobalSettings().PassThroughRequestEnabled
-	shouldUseResponses := service.ShouldChatCompletionsUseResponsesGlobal(info.ChannelId, info.ChannelType, info.OriginModelName) ||
+	passThrough := info.PassThroughBody()
+	shouldUseResponses := (service.ShouldChatCompletionsUseResponsesGlobal(info.ChannelId, info.ChannelType, info.OriginModelName) &&
+		!service.ResponsesNativeUnsupported(info.ChannelOtherSettings)) ||
 		info.ChannelType == constant.ChannelTypeOpenAIResponses
 	if info.RelayMode == relayconstant.RelayModeChatCompletions &&
-		!passThroughGlobal &&
-		!info.ChannelSetting.PassThroughBodyEnabled &&
+		!passThrough &&
 		shouldUseResponses {
 		applySystemPromptIfNeeded(c, info, request)
 		usage, newApiErr := chatCompletionsViaResponses(c, info, adaptor, request)
@@ -110,7 +118,7 @@ func TextHelper(c *gin.Context, info *relaycommon.RelayInfo) (newAPIError *types
 
 	var requestBody io.Reader
 
-	if passThroughGlobal || info.ChannelSetting.PassThroughBodyEnabled {
+	if passThrough {
 		storage, err := common.GetBodyStorage(c)
 		if err != nil {
 			return types.NewErrorWithStatusCode(err, types.ErrorCodeReadRequestBodyFailed, http.StatusBadRequest, types.ErrOptionWithSkipRetry())
@@ -176,7 +184,7 @@ func TextHelper(c *gin.Context, info *relaycommon.RelayInfo) (newAPIError *types
 		}
 
 		// remove disabled fields for OpenAI API
-		jsonData, err = relaycommon.RemoveDisabledFields(jsonData, info.ChannelOtherSettings, info.ChannelSetting.PassThroughBodyEnabled)
+		jsonData, err = relaycommon.RemoveDisabledFields(jsonData, info.ChannelOtherSettings, info.PassThroughBody())
 		if err != nil {
 			return types.NewError(err, types.ErrorCodeConvertRequestFailed, types.ErrOptionWithSkipRetry())
 		}
@@ -407,6 +415,13 @@ func postConsumeQuota(ctx *gin.Context, relayInfo *relaycommon.RelayInfo, usage
 	// 添加 image generation call 计费
 	quotaCalculateDecimal = quotaCalculateDecimal.Add(dImageGenerationCallQuota)
 
+	// OpenAI flex / priority 服务层级按各自倍率计费
+	if serviceTier := relayInfo.GetBillingServiceTier(); serviceTier != "" {
+		if tierRatio, ok := ratio_setting.GetServiceTierRatio(modelName, serviceTier); ok && tierRatio != 1 {
+			relayInfo.PriceData.AddOtherRatio("service_tier_"+serviceTier, tierRatio)
+		}
+	}
+
 	if len(relayInfo.PriceData.OtherRatios) > 0 {
 		for key, otherRatio := range relayInfo.PriceData.OtherRatios {
 			dOtherRatio := decimal.NewFromFloat(otherRatio)
@@ -436,7 +451,7 @@ func postConsumeQuota(ctx *gin.Context, relayInfo *relaycommon.RelayInfo, usage
 		model.UpdateChannelUsedQuota(relayInfo.ChannelId, quota)
 	}
 
-	if err := service.SettleBilling(ctx, relayInfo, quota); err != nil {
+	if err := service.SettleUsageBilling(ctx, relayInfo, quota, usage); err != nil {
 		logger.LogError(ctx, "error settling billing: "+err.Error())
 	}
 
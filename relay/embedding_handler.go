@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"fmt"
 	"net/http"
+	"strconv"
 
 	"github.com/QuantumNous/new-api/common"
 	"github.com/QuantumNous/new-api/dto"
@@ -40,6 +41,15 @@ func EmbeddingHelper(c *gin.Context, info *relaycommon.RelayInfo) (newAPIError *
 	}
 	adaptor.Init(info)
 
+	// 客户端需要的维度与编码格式，上游不支持时在响应中补齐
+	targetDimensions := 0
+	if request.Dimensions != nil && info.ChannelOtherSettings.EmbeddingDimensionsEmulation {
+		targetDimensions = *request.Dimensions
+		request.Dimensions = nil
+		request.EncodingFormat = ""
+	}
+	toBase64 := embeddingReq.EncodingFormat == service.EmbeddingEncodingFormatBase64
+
 	convertedRequest, err := adaptor.ConvertEmbeddingRequest(c, info, *request)
 	if err != nil {
 		return types.NewError(err, types.ErrorCodeConvertRequestFailed, types.ErrOptionWithSkipRetry())
@@ -76,7 +86,20 @@ func EmbeddingHelper(c *gin.Context, info *relaycommon.RelayInfo) (newAPIError *
 		}
 	}
 
+	var writer *embeddingResponseWriter
+	if targetDimensions > 0 || toBase64 {
+		writer = &embeddingResponseWriter{ResponseWriter: c.Writer}
+		c.Writer = writer
+	}
 	usage, newAPIError := adaptor.DoResponse(c, httpResp, info)
+	if writer != nil {
+		c.Writer = writer.ResponseWriter
+		if newAPIError == nil {
+			writer.flush(c, targetDimensions, toBase64)
+		} else {
+			writer.flushRaw()
+		}
+	}
 	if newAPIError != nil {
 		// reset status code 重置状态码
 		service.ResetStatusCode(newAPIError, statusCodeMappingStr)
@@ -85,3 +108,66 @@ func EmbeddingHelper(c *gin.Context, info *relaycommon.RelayInfo) (newAPIError *
 	postConsumeQuota(c, info, usage.(*dto.Usage))
 	return nil
 }
+
+// embeddingResponseWriter 缓存适配器写出的 embedding 响应，以便按客户端请求的维度与编码格式调整后再写回
+type embeddingResponseWriter struct {
+	gin.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (w *embeddingResponseWriter) WriteHeader(statusCode int) {
+	if w.status == 0 {
+		w.status = statusCode
+	}
+}
+
+func (w *embeddingResponseWriter) WriteHeaderNow() {
+	w.WriteHeader(http.StatusOK)
+}
+
+func (w *embeddingResponseWriter) Write(data []byte) (int, error) {
+	w.WriteHeader(http.StatusOK)
+	return w.body.Write(data)
+}
+
+func (w *embeddingResponseWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+func (w *embeddingResponseWriter) Status() int {
+	if w.status == 0 {
+		return http.StatusOK
+	}
+	return w.status
+}
+
+func (w *embeddingResponseWriter) Size() int {
+	return w.body.Len()
+}
+
+func (w *embeddingResponseWriter) Written() bool {
+	return w.status != 0
+}
+
+func (w *embeddingResponseWriter) flush(c *gin.Context, dimensions int, toBase64 bool) {
+	if w.status == http.StatusOK {
+		normalized, err := service.NormalizeEmbeddingResponse(w.body.Bytes(), dimensions, toBase64)
+		if err != nil {
+			logger.LogError(c, "normalize embedding response failed: "+err.Error())
+		} else {
+			w.body.Reset()
+			w.body.Write(normalized)
+		}
+	}
+	w.flushRaw()
+}
+
+func (w *embeddingResponseWriter) flushRaw() {
+	if w.status == 0 {
+		return
+	}
+	w.ResponseWriter.Header().Set("Content-Length", strconv.Itoa(w.body.Len()))
+	w.ResponseWriter.WriteHeader(w.status)
+	_, _ = w.ResponseWriter.Write(w.body.Bytes())
+}
@@ -0,0 +1,97 @@
+package helper
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// 字符串之外允许出现的字面量字符（数字与 true/false/null）
+const jsonLiteralChars = "0123456789+-.eEtrufalsn"
+
+// JSONStreamGuard 逐段校验流式输出的 JSON 内容，跟踪括号与引号的配对。
+// 内容被截断（尚未闭合）不视为错误，只有出现无法通过后续增量修复的内容时才报错。
+type JSONStreamGuard struct {
+	stack    []byte
+	inString bool
+	escape   bool
+	started  bool
+	closed   bool
+	offset   int
+	err      error
+}
+
+func NewJSONStreamGuard() *JSONStreamGuard {
+	return &JSONStreamGuard{}
+}
+
+// Write 追加一段增量内容，返回首次发现的错误；出错后再次调用返回同一错误
+func (g *JSONStreamGuard) Write(delta string) error {
+	if g.err != nil {
+		return g.err
+	}
+	for i := 0; i < len(delta); i++ {
+		if err := g.feed(delta[i]); err != nil {
+			g.err = fmt.Errorf("broken JSON output at offset %d: %w", g.offset, err)
+			return g.err
+		}
+		g.offset++
+	}
+	return nil
+}
+
+func (g *JSONStreamGuard) feed(ch byte) error {
+	if g.inString {
+		switch {
+		case g.escape:
+			if !strings.ContainsRune(`"\/bfnrtu`, rune(ch)) {
+				return fmt.Errorf("invalid escape %q", "\\"+string(ch))
+			}
+			g.escape = false
+		case ch == '\\':
+			g.escape = true
+		case ch == '"':
+			g.inString = false
+		case ch < 0x20:
+			return errors.New("unescaped control character in string")
+		}
+		return nil
+	}
+
+	switch ch {
+	case ' ', '\t', '\n', '\r':
+		return nil
+	}
+	if g.closed {
+		return fmt.Errorf("unexpected %q after the top-level value", ch)
+	}
+	if !g.started {
+		if ch != '{' && ch != '[' {
+			return fmt.Errorf("unexpected %q, expected '{' or '['", ch)
+		}
+		g.started = true
+	}
+
+	switch ch {
+	case '{', '[':
+		g.stack = append(g.stack, ch)
+	case '}', ']':
+		open := byte('{')
+		if ch == ']' {
+			open = '['
+		}
+		if len(g.stack) == 0 || g.stack[len(g.stack)-1] != open {
+			return fmt.Errorf("unbalanced %q", ch)
+		}
+		g.stack = g.stack[:len(g.stack)-1]
+		g.closed = len(g.stack) == 0
+	case '"':
+		g.inString = true
+	case ',', ':':
+	default:
+		if !strings.ContainsRune(jsonLiteralChars, rune(ch)) {
+			return fmt.Errorf("unexpected %q outside of a string", ch)
+		}
+	}
+	return nil
+}
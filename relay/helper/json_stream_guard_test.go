@@ -0,0 +1,43 @@
+package helper
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestJSONStreamGuardAcceptsValidDeltas(t *testing.T) {
+	guard := NewJSONStreamGuard()
+	for _, delta := range []string{"\n{", `"name": "a \"b\" {[`, `", "tags": [1, -2.5e3`, `, true, null], "ok": fal`, "se}\n"} {
+		require.NoError(t, guard.Write(delta))
+	}
+}
+
+func TestJSONStreamGuardAllowsTruncatedOutput(t *testing.T) {
+	guard := NewJSONStreamGuard()
+	require.NoError(t, guard.Write(`{"items": [{"id": 1}, {"id": "unterminated`))
+}
+
+func TestJSONStreamGuardRejectsBrokenOutput(t *testing.T) {
+	cases := map[string][]string{
+		"markdown prefix":   {"```json\n{}"},
+		"mismatched close":  {`{"a": [1, 2}`},
+		"trailing content":  {`{"a": 1}`, ` {"b": 2}`},
+		"prose outside":     {`{"a": 1, note: 2}`},
+		"invalid escape":    {`{"a": "\x"}`},
+		"raw newline value": {"{\"a\": \"line\nbreak\"}"},
+	}
+	for name, deltas := range cases {
+		t.Run(name, func(t *testing.T) {
+			guard := NewJSONStreamGuard()
+			var err error
+			for _, delta := range deltas {
+				if err = guard.Write(delta); err != nil {
+					break
+				}
+			}
+			require.Error(t, err)
+			require.Equal(t, err, guard.Write("}"))
+		})
+	}
+}
@@ -0,0 +1,115 @@
+package helper
+
+import (
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/dto"
+)
+
+// ReasoningCoalescer 合并流式 Chat Completions 中只包含思考内容的数据块，减少推理模型大量细碎增量带来的 SSE 开销。
+// 连续的思考数据块累积到 maxBytes 字节或自第一块起经过 interval 后合并为一块发送；
+// 其他数据块（正文、工具调用、结束原因、usage 等）到达时先发送已累积的思考内容，再原样发送，正文不会被延迟。
+// 没有定时器，时间窗口在下一个数据块到达时检查
+type ReasoningCoalescer struct {
+	interval time.Duration
+	maxBytes int
+	now      func() time.Time
+
+	pending      *dto.ChatCompletionsStreamResponse
+	pendingRaw   string // 只累积了一块时的原始数据，原样发送以免重新序列化
+	pendingBytes int
+	pendingSince time.Time
+}
+
+func NewReasoningCoalescer(interval time.Duration, maxBytes int) *ReasoningCoalescer {
+	return &ReasoningCoalescer{interval: interval, maxBytes: maxBytes, now: time.Now}
+}
+
+// Push 接收一个数据块，返回现在应发送的数据块（可能为空）
+func (r *ReasoningCoalescer) Push(data string) []string {
+	var chunk dto.ChatCompletionsStreamResponse
+	if err := common.UnmarshalJsonStr(data, &chunk); err != nil || !isReasoningOnlyChunk(&chunk) {
+		return append(r.flush(), data)
+	}
+	if r.pending == nil {
+		r.pending = &chunk
+		r.pendingRaw = data
+		r.pendingSince = r.now()
+	} else {
+		r.pendingRaw = ""
+		mergeReasoningChunk(r.pending, &chunk)
+	}
+	for _, choice := range chunk.Choices {
+		r.pendingBytes += len(choice.Delta.GetReasoningContent())
+	}
+	if r.pendingBytes >= r.maxBytes || r.now().Sub(r.pendingSince) >= r.interval {
+		return r.flush()
+	}
+	return nil
+}
+
+// Flush 返回尚未发送的思考内容，流结束时调用
+func (r *ReasoningCoalescer) Flush() []string {
+	return r.flush()
+}
+
+func (r *ReasoningCoalescer) flush() []string {
+	if r.pending == nil {
+		return nil
+	}
+	data := r.pendingRaw
+	if data == "" {
+		jsonData, err := common.Marshal(r.pending)
+		if err != nil {
+			common.SysError("failed to marshal coalesced reasoning chunk: " + err.Error())
+		}
+		data = string(jsonData)
+	}
+	r.pending = nil
+	r.pendingRaw = ""
+	r.pendingBytes = 0
+	if data == "" {
+		return nil
+	}
+	return []string{data}
+}
+
+// isReasoningOnlyChunk 判断数据块是否只包含思考内容增量
+func isReasoningOnlyChunk(chunk *dto.ChatCompletionsStreamResponse) bool {
+	if len(chunk.Choices) == 0 || chunk.Usage != nil {
+		return false
+	}
+	for _, choice := range chunk.Choices {
+		delta := choice.Delta
+		if delta.GetReasoningContent() == "" || delta.GetContentString() != "" || len(delta.ToolCalls) > 0 ||
+			len(delta.Annotations) > 0 || choice.FinishReason != nil || choice.Logprobs != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// mergeReasoningChunk 将数据块的思考内容按 choice 追加到已累积的数据块中
+func mergeReasoningChunk(pending *dto.ChatCompletionsStreamResponse, chunk *dto.ChatCompletionsStreamResponse) {
+	for _, choice := range chunk.Choices {
+		merged := false
+		for i := range pending.Choices {
+			if pending.Choices[i].Index != choice.Index {
+				continue
+			}
+			delta := &pending.Choices[i].Delta
+			if delta.ReasoningContent != nil {
+				delta.SetReasoningContent(*delta.ReasoningContent + choice.Delta.GetReasoningContent())
+			} else {
+				reasoning := delta.GetReasoningContent() + choice.Delta.GetReasoningContent()
+				delta.Reasoning = &reasoning
+			}
+			merged = true
+			break
+		}
+		if !merged {
+			pending.Choices = append(pending.Choices, choice)
+		}
+	}
+}
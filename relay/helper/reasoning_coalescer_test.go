@@ -0,0 +1,54 @@
+package helper
+
+import (
+	"testing"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/dto"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReasoningCoalescerMergesReasoningDeltas(t *testing.T) {
+	coalescer := NewReasoningCoalescer(time.Hour, 16)
+	reasoning := func(text string) string {
+		return `{"id":"c1","object":"chat.completion.chunk","choices":[{"index":0,"delta":{"reasoning_content":"` + text + `"},"finish_reason":null}]}`
+	}
+	content := `{"id":"c1","object":"chat.completion.chunk","choices":[{"index":0,"delta":{"content":"Hi"},"finish_reason":null}]}`
+
+	// a single pending chunk is sent unchanged
+	require.Empty(t, coalescer.Push(reasoning("a")))
+	require.Equal(t, []string{reasoning("a"), content}, coalescer.Push(content))
+
+	require.Empty(t, coalescer.Push(reasoning("think ")))
+	require.Empty(t, coalescer.Push(reasoning("more ")))
+	flushed := coalescer.Push(reasoning("and more"))
+	require.Len(t, flushed, 1)
+	var chunk dto.ChatCompletionsStreamResponse
+	require.NoError(t, common.UnmarshalJsonStr(flushed[0], &chunk))
+	require.Equal(t, "think more and more", chunk.Choices[0].Delta.GetReasoningContent())
+
+	// text deltas are never held back
+	require.Equal(t, []string{content}, coalescer.Push(content))
+	require.Empty(t, coalescer.Push(reasoning("tail")))
+	require.Equal(t, []string{reasoning("tail")}, coalescer.Flush())
+	require.Empty(t, coalescer.Flush())
+}
+
+func TestReasoningCoalescerFlushesAfterInterval(t *testing.T) {
+	coalescer := NewReasoningCoalescer(100*time.Millisecond, 1<<20)
+	now := time.Unix(0, 0)
+	coalescer.now = func() time.Time { return now }
+	reasoning := `{"choices":[{"index":0,"delta":{"reasoning":"x"}}]}`
+
+	require.Empty(t, coalescer.Push(reasoning))
+	now = now.Add(50 * time.Millisecond)
+	require.Empty(t, coalescer.Push(reasoning))
+	now = now.Add(60 * time.Millisecond)
+	flushed := coalescer.Push(reasoning)
+	require.Len(t, flushed, 1)
+	var chunk dto.ChatCompletionsStreamResponse
+	require.NoError(t, common.UnmarshalJsonStr(flushed[0], &chunk))
+	require.Nil(t, chunk.Choices[0].Delta.ReasoningContent)
+	require.Equal(t, "xxx", *chunk.Choices[0].Delta.Reasoning)
+}
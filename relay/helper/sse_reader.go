@@ -0,0 +1,194 @@
+package helper
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+
+	"github.com/QuantumNous/new-api/common"
+)
+
+// ErrSSEEventTooLarge is returned when a single line or event exceeds the configured max size.
+var ErrSSEEventTooLarge = errors.New("sse event exceeds max size")
+
+var utf8BOM = []byte{0xEF, 0xBB, 0xBF}
+
+// SSEEvent is a single dispatched server-sent event.
+type SSEEvent struct {
+	Event string
+	Data  string
+	ID    string
+	Retry int
+}
+
+// SSEReader parses a text/event-stream body:
+//   - lines end with CRLF, LF or a lone CR
+//   - a leading UTF-8 BOM is skipped
+//   - lines starting with ':' are comments
+//   - data: lines are joined with '\n' and the event is dispatched on a blank line or EOF
+//   - event/id/retry fields are attached to the next dispatched event
+//   - a bare [DONE] line without the data: prefix, sent by some upstreams, is dispatched
+//     immediately as a [DONE] event
+//
+// A lenient reader (NewLenientSSEReader) instead dispatches every data: line on its own,
+// for relay upstreams that omit the blank line between events. Only a data: line that
+// opens a JSON value without closing it is joined with the following data: lines until
+// the value is complete or a blank line ends the event.
+type SSEReader struct {
+	scanner      *bufio.Scanner
+	maxEventSize int
+	lenient      bool
+	started      bool
+	pendingDone  bool // a bare [DONE] line ended the previous event and is dispatched next
+}
+
+var sseDone = []byte("[DONE]")
+
+// NewSSEReader creates a reader limited to maxEventSize bytes per event;
+// maxEventSize <= 0 falls back to the stream scanner buffer size.
+func NewSSEReader(r io.Reader, maxEventSize int) *SSEReader {
+	if maxEventSize <= 0 {
+		maxEventSize = getScannerBufferSize()
+	}
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, min(InitialScannerBufferSize, maxEventSize)), maxEventSize)
+	scanner.Split(newSSELineSplitter())
+	return &SSEReader{
+		scanner:      scanner,
+		maxEventSize: maxEventSize,
+	}
+}
+
+// NewLenientSSEReader creates a reader that does not require blank lines between events,
+// see SSEReader.
+func NewLenientSSEReader(r io.Reader, maxEventSize int) *SSEReader {
+	reader := NewSSEReader(r, maxEventSize)
+	reader.lenient = true
+	return reader
+}
+
+// newSSELineSplitter splits on CRLF, LF or CR. A CR is returned as a line end
+// immediately so a lone CR does not wait for more input; a following LF is skipped.
+func newSSELineSplitter() bufio.SplitFunc {
+	skipLF := false
+	return func(data []byte, atEOF bool) (advance int, token []byte, err error) {
+		skipped := 0
+		if skipLF && len(data) > 0 {
+			skipLF = false
+			if data[0] == '\n' {
+				data = data[1:]
+				skipped = 1
+			}
+		}
+		if atEOF && len(data) == 0 {
+			return skipped, nil, nil
+		}
+		if i := bytes.IndexAny(data, "\r\n"); i >= 0 {
+			if data[i] == '\r' {
+				skipLF = true
+			}
+			return skipped + i + 1, data[:i], nil
+		}
+		if atEOF {
+			return skipped + len(data), data, nil
+		}
+		return skipped, nil, nil
+	}
+}
+
+// Next returns the next event that carries data. It returns io.EOF when the
+// stream is exhausted and ErrSSEEventTooLarge when an event is oversized.
+func (r *SSEReader) Next() (*SSEEvent, error) {
+	if r.pendingDone {
+		r.pendingDone = false
+		return &SSEEvent{Data: string(sseDone)}, nil
+	}
+	event := &SSEEvent{}
+	var data bytes.Buffer
+	hasData := false
+
+	for r.scanner.Scan() {
+		line := r.scanner.Bytes()
+		if !r.started {
+			r.started = true
+			line = bytes.TrimPrefix(line, utf8BOM)
+		}
+
+		if len(line) == 0 {
+			if hasData {
+				event.Data = data.String()
+				return event, nil
+			}
+			// an event without data is discarded
+			event = &SSEEvent{}
+			continue
+		}
+		if line[0] == ':' {
+			continue
+		}
+		if bytes.HasPrefix(line, sseDone) {
+			if hasData {
+				r.pendingDone = true
+				event.Data = data.String()
+				return event, nil
+			}
+			return &SSEEvent{Data: string(sseDone)}, nil
+		}
+
+		field, value := line, []byte(nil)
+		if i := bytes.IndexByte(line, ':'); i >= 0 {
+			field, value = line[:i], line[i+1:]
+			value = bytes.TrimPrefix(value, []byte(" "))
+		}
+
+		switch string(field) {
+		case "data":
+			if data.Len()+len(value)+1 > r.maxEventSize {
+				return nil, fmt.Errorf("%w: more than %d bytes", ErrSSEEventTooLarge, r.maxEventSize)
+			}
+			if hasData {
+				data.WriteByte('\n')
+			}
+			data.Write(value)
+			hasData = true
+			if r.lenient && !isUnfinishedJson(data.Bytes()) {
+				event.Data = data.String()
+				return event, nil
+			}
+		case "event":
+			event.Event = string(value)
+		case "id":
+			if bytes.IndexByte(value, 0) < 0 {
+				event.ID = string(value)
+			}
+		case "retry":
+			if retry, err := strconv.Atoi(string(value)); err == nil {
+				event.Retry = retry
+			}
+		}
+	}
+
+	if err := r.scanner.Err(); err != nil {
+		if errors.Is(err, bufio.ErrTooLong) {
+			return nil, fmt.Errorf("%w: more than %d bytes", ErrSSEEventTooLarge, r.maxEventSize)
+		}
+		return nil, err
+	}
+	if hasData {
+		event.Data = data.String()
+		return event, nil
+	}
+	return nil, io.EOF
+}
+
+// isUnfinishedJson reports whether data opens a JSON object or array that is not closed yet.
+func isUnfinishedJson(data []byte) bool {
+	switch common.GetJsonType(data) {
+	case "object", "array":
+		return !common.ValidJson(data)
+	}
+	return false
+}
@@ -0,0 +1,131 @@
+package helper
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func readAllSSEEvents(t *testing.T, r *SSEReader) []*SSEEvent {
+	t.Helper()
+	var events []*SSEEvent
+	for {
+		event, err := r.Next()
+		if errors.Is(err, io.EOF) {
+			return events
+		}
+		require.NoError(t, err)
+		events = append(events, event)
+	}
+}
+
+func TestSSEReader_LineEndings(t *testing.T) {
+	t.Parallel()
+
+	for name, body := range map[string]string{
+		"lf":   "data: a\n\ndata: b\n\n",
+		"crlf": "data: a\r\n\r\ndata: b\r\n\r\n",
+		"cr":   "data: a\r\rdata: b\r\r",
+	} {
+		events := readAllSSEEvents(t, NewSSEReader(strings.NewReader(body), 0))
+		require.Len(t, events, 2, name)
+		assert.Equal(t, "a", events[0].Data, name)
+		assert.Equal(t, "b", events[1].Data, name)
+	}
+}
+
+func TestSSEReader_MultiLineJsonData(t *testing.T) {
+	t.Parallel()
+
+	body := "event: message\nid: 7\ndata: {\ndata:   \"a\": 1\ndata: }\n\ndata: hello\ndata: world\n\n"
+	events := readAllSSEEvents(t, NewSSEReader(strings.NewReader(body), 0))
+
+	require.Len(t, events, 2)
+	assert.Equal(t, "message", events[0].Event)
+	assert.Equal(t, "7", events[0].ID)
+	assert.Equal(t, "{\n  \"a\": 1\n}", events[0].Data)
+	assert.Equal(t, "hello\nworld", events[1].Data)
+}
+
+func TestSSEReader_MultiLineDataDispatchedOnBlankLine(t *testing.T) {
+	t.Parallel()
+
+	body := "data: a\ndata: b\n\ndata: {\"x\":1}\ndata: tail\n\ndata: c\ndata: d"
+	events := readAllSSEEvents(t, NewSSEReader(strings.NewReader(body), 0))
+
+	require.Len(t, events, 3)
+	assert.Equal(t, "a\nb", events[0].Data)
+	assert.Equal(t, "{\"x\":1}\ntail", events[1].Data)
+	assert.Equal(t, "c\nd", events[2].Data)
+}
+
+func TestSSEReader_BareDoneLine(t *testing.T) {
+	t.Parallel()
+
+	// a bare [DONE] line ends the stream at once, even if the upstream keeps sending
+	r := NewSSEReader(strings.NewReader("data: {\"i\":0}\n[DONE]\ndata: late\n\n"), 0)
+	event, err := r.Next()
+	require.NoError(t, err)
+	assert.Equal(t, "{\"i\":0}", event.Data)
+	event, err = r.Next()
+	require.NoError(t, err)
+	assert.Equal(t, "[DONE]", event.Data)
+
+	events := readAllSSEEvents(t, NewSSEReader(strings.NewReader("[DONE]\n"), 0))
+	require.Len(t, events, 1)
+	assert.Equal(t, "[DONE]", events[0].Data)
+}
+
+func TestLenientSSEReader_DataLinesWithoutBlankLines(t *testing.T) {
+	t.Parallel()
+
+	body := "data: {\"i\":0}\ndata: {\ndata: \"i\": 1}\ndata: text\ndata: [DONE]\n"
+	events := readAllSSEEvents(t, NewLenientSSEReader(strings.NewReader(body), 0))
+
+	require.Len(t, events, 4)
+	assert.Equal(t, "{\"i\":0}", events[0].Data)
+	assert.Equal(t, "{\n\"i\": 1}", events[1].Data)
+	assert.Equal(t, "text", events[2].Data)
+	assert.Equal(t, "[DONE]", events[3].Data)
+}
+
+func TestSSEReader_CommentsBomAndRetry(t *testing.T) {
+	t.Parallel()
+
+	body := "\xEF\xBB\xBF: keep-alive\nretry: 3000\nevent: ping\n\ndata:no-space\n"
+	events := readAllSSEEvents(t, NewSSEReader(strings.NewReader(body), 0))
+
+	require.Len(t, events, 1)
+	assert.Equal(t, "no-space", events[0].Data)
+	assert.Equal(t, "", events[0].Event)
+}
+
+func TestSSEReader_LargeEventBeyond64KB(t *testing.T) {
+	t.Parallel()
+
+	payload := strings.Repeat("x", 256<<10)
+	body := "data: " + payload + "\n\n"
+	events := readAllSSEEvents(t, NewSSEReader(strings.NewReader(body), 1<<20))
+
+	require.Len(t, events, 1)
+	assert.Equal(t, payload, events[0].Data)
+}
+
+func TestSSEReader_EventTooLarge(t *testing.T) {
+	t.Parallel()
+
+	line := "data: {\"x\":\"" + strings.Repeat("x", 512) + "\n"
+	longLine := "data: " + strings.Repeat("x", 2048) + "\n"
+	for _, body := range []string{longLine, strings.Repeat(line, 4)} {
+		r := NewSSEReader(strings.NewReader(body), 1024)
+		var err error
+		for err == nil {
+			_, err = r.Next()
+		}
+		assert.ErrorIs(t, err, ErrSSEEventTooLarge)
+	}
+}
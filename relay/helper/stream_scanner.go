@@ -1,7 +1,6 @@
 package helper
 
 import (
-	"bufio"
 	"context"
 	"fmt"
 	"io"
@@ -51,7 +50,6 @@ func StreamScannerHandler(c *gin.Context, resp *http.Response, info *relaycommon
 
 	var (
 		stopChan   = make(chan bool, 3) // 增加缓冲区避免阻塞
-		scanner    = bufio.NewScanner(resp.Body)
 		ticker     = time.NewTicker(streamingTimeout)
 		pingTicker *time.Ticker
 		writeMutex sync.Mutex     // Mutex to protect concurrent writes
@@ -104,8 +102,10 @@ func StreamScannerHandler(c *gin.Context, resp *http.Response, info *relaycommon
 		close(stopChan)
 	}()
 
-	scanner.Buffer(make([]byte, InitialScannerBufferSize), getScannerBufferSize())
-	scanner.Split(bufio.ScanLines)
+	// 任何上游字节（包括注释心跳）都会重置超时计时器；不少上游省略事件间的空行，按行分发 data
+	reader := NewLenientSSEReader(&activityReader{r: resp.Body, onRead: func() {
+		ticker.Reset(streamingTimeout)
+	}}, getScannerBufferSize())
 	SetEventStreamHeaders(c)
 
 	ctx, cancel := context.WithCancel(context.Background())
@@ -212,7 +212,15 @@ func StreamScannerHandler(c *gin.Context, resp *http.Response, info *relaycommon
 			}
 		}()
 
-		for scanner.Scan() {
+		for {
+			event, err := reader.Next()
+			if err != nil {
+				if err != io.EOF {
+					logger.LogError(c, "sse reader error: "+err.Error())
+				}
+				return
+			}
+
 			// 检查是否需要停止
 			select {
 			case <-stopChan:
@@ -224,20 +232,10 @@ func StreamScannerHandler(c *gin.Context, resp *http.Response, info *relaycommon
 			default:
 			}
 
-			ticker.Reset(streamingTimeout)
-			data := scanner.Text()
+			data := strings.TrimSpace(event.Data)
 			if common.DebugEnabled {
 				println(data)
 			}
-
-			if len(data) < 6 {
-				continue
-			}
-			if data[:5] != "data:" && data[:6] != "[DONE]" {
-				continue
-			}
-			data = data[5:]
-			data = strings.TrimSpace(data)
 			if data == "" {
 				continue
 			}
@@ -260,12 +258,6 @@ func StreamScannerHandler(c *gin.Context, resp *http.Response, info *relaycommon
 				return
 			}
 		}
-
-		if err := scanner.Err(); err != nil {
-			if err != io.EOF {
-				logger.LogError(c, "scanner error: "+err.Error())
-			}
-		}
 	})
 
 	// 主循环等待完成或超时
@@ -281,3 +273,17 @@ func StreamScannerHandler(c *gin.Context, resp *http.Response, info *relaycommon
 		logger.LogInfo(c, "client disconnected")
 	}
 }
+
+// activityReader reports every successful read so idle timers can be reset.
+type activityReader struct {
+	r      io.Reader
+	onRead func()
+}
+
+func (a *activityReader) Read(p []byte) (int, error) {
+	n, err := a.r.Read(p)
+	if n > 0 {
+		a.onRead()
+	}
+	return n, err
+}
@@ -21,6 +21,7 @@ import (
 	"github.com/QuantumNous/new-api/service"
 	"github.com/QuantumNous/new-api/setting"
 	"github.com/QuantumNous/new-api/setting/system_setting"
+	"github.com/QuantumNous/new-api/types"
 
 	"github.com/gin-gonic/gin"
 )
@@ -194,7 +195,7 @@ func RelaySwapFace(c *gin.Context, info *relaycommon.RelayInfo) *dto.MidjourneyR
 		}
 	}
 
-	userQuota, err := model.GetUserQuota(info.UserId, false)
+	_, availableQuota, err := model.GetUserAvailableQuota(info.UserId, priceData.Quota)
 	if err != nil {
 		return &dto.MidjourneyResponse{
 			Code:        4,
@@ -202,17 +203,26 @@ func RelaySwapFace(c *gin.Context, info *relaycommon.RelayInfo) *dto.MidjourneyR
 		}
 	}
 
-	if userQuota-priceData.Quota < 0 {
+	if availableQuota-priceData.Quota < 0 {
 		return &dto.MidjourneyResponse{
 			Code:        4,
 			Description: "quota_not_enough",
 		}
 	}
+	// 按次模式的令牌计入本次请求，未提交成功时退还
+	rollbackTokenRequest, apiErr := service.ConsumeTokenRequest(c, info)
+	if apiErr != nil {
+		return &dto.MidjourneyResponse{
+			Code:        4,
+			Description: apiErr.Error(),
+		}
+	}
 	requestURL := getMjRequestPath(c.Request.URL.String())
 	baseURL := c.GetString("base_url")
 	fullRequestURL := fmt.Sprintf("%s%s", baseURL, requestURL)
 	mjResp, _, err := service.DoMidjourneyHttpRequest(c, time.Second*60, fullRequestURL)
 	if err != nil {
+		rollbackTokenRequest()
 		return &mjResp.Response
 	}
 	defer func() {
@@ -237,6 +247,8 @@ func RelaySwapFace(c *gin.Context, info *relaycommon.RelayInfo) *dto.MidjourneyR
 			})
 			model.UpdateUserUsedQuotaAndRequestCount(info.UserId, priceData.Quota)
 			model.UpdateChannelUsedQuota(info.ChannelId, priceData.Quota)
+		} else {
+			rollbackTokenRequest()
 		}
 	}()
 	midjResponse := &mjResp.Response
@@ -501,7 +513,7 @@ func RelayMidjourneySubmit(c *gin.Context, relayInfo *relaycommon.RelayInfo) *dt
 		}
 	}
 
-	userQuota, err := model.GetUserQuota(relayInfo.UserId, false)
+	_, availableQuota, err := model.GetUserAvailableQuota(relayInfo.UserId, priceData.Quota)
 	if err != nil {
 		return &dto.MidjourneyResponse{
 			Code:        4,
@@ -509,15 +521,29 @@ func RelayMidjourneySubmit(c *gin.Context, relayInfo *relaycommon.RelayInfo) *dt
 		}
 	}
 
-	if consumeQuota && userQuota-priceData.Quota < 0 {
+	if consumeQuota && availableQuota-priceData.Quota < 0 {
 		return &dto.MidjourneyResponse{
 			Code:        4,
 			Description: "quota_not_enough",
 		}
 	}
 
+	// 按次模式的令牌计入本次请求，未提交成功时退还
+	rollbackTokenRequest := func() {}
+	if consumeQuota {
+		var apiErr *types.NewAPIError
+		rollbackTokenRequest, apiErr = service.ConsumeTokenRequest(c, relayInfo)
+		if apiErr != nil {
+			return &dto.MidjourneyResponse{
+				Code:        4,
+				Description: apiErr.Error(),
+			}
+		}
+	}
+
 	midjResponseWithStatus, responseBody, err := service.DoMidjourneyHttpRequest(c, time.Second*60, fullRequestURL)
 	if err != nil {
+		rollbackTokenRequest()
 		return &midjResponseWithStatus.Response
 	}
 	midjResponse := &midjResponseWithStatus.Response
@@ -543,6 +569,8 @@ func RelayMidjourneySubmit(c *gin.Context, relayInfo *relaycommon.RelayInfo) *dt
 			})
 			model.UpdateUserUsedQuotaAndRequestCount(relayInfo.UserId, priceData.Quota)
 			model.UpdateChannelUsedQuota(relayInfo.ChannelId, priceData.Quota)
+		} else {
+			rollbackTokenRequest()
 		}
 	}()
 
@@ -0,0 +1,298 @@
+package relay
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/logger"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	"github.com/QuantumNous/new-api/relay/helper"
+	"github.com/QuantumNous/new-api/relay/transcription"
+	"github.com/QuantumNous/new-api/service"
+	"github.com/QuantumNous/new-api/setting/model_setting"
+	"github.com/QuantumNous/new-api/types"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+)
+
+const (
+	defaultTranscriptionSampleRate = 16000
+	// 结束会话后等待上游输出剩余结果的时间
+	transcriptionDrainQuiet = 2 * time.Second
+	transcriptionDrainMax   = 10 * time.Second
+)
+
+const (
+	TranscriptionEndClientClose  = "client_close"
+	TranscriptionEndDisconnected = "client_disconnected"
+	TranscriptionEndIdleTimeout  = "idle_timeout"
+	TranscriptionEndMaxDuration  = "max_duration"
+	TranscriptionEndUpstream     = "upstream_closed"
+)
+
+// 客户端发送的控制消息，音频以二进制帧发送
+type transcriptionClientMessage struct {
+	Type string `json:"type"`
+}
+
+type transcriptionServerEvent struct {
+	Type         string  `json:"type"`
+	Text         string  `json:"text,omitempty"`
+	IsFinal      *bool   `json:"is_final,omitempty"`
+	Model        string  `json:"model,omitempty"`
+	SampleRate   int     `json:"sample_rate,omitempty"`
+	MaxSeconds   int     `json:"max_seconds,omitempty"`
+	AudioSeconds float64 `json:"audio_seconds,omitempty"`
+	Reason       string  `json:"reason,omitempty"`
+	Message      string  `json:"message,omitempty"`
+}
+
+type transcriptionClientFrame struct {
+	messageType int
+	data        []byte
+	err         error
+}
+
+type transcriptionUpstreamResult struct {
+	event *transcription.Event
+	err   error
+}
+
+// RealtimeTranscriptionHelper 将客户端 WebSocket 音频流桥接到上游实时转写服务，会话结束后按音频秒数计费
+func RealtimeTranscriptionHelper(c *gin.Context, info *relaycommon.RelayInfo) *types.NewAPIError {
+	info.InitChannelMeta(c)
+	if err := helper.ModelMappedHelper(c, info, nil); err != nil {
+		return types.NewError(err, types.ErrorCodeChannelModelMappedError, types.ErrOptionWithSkipRetry())
+	}
+
+	sampleRate := defaultTranscriptionSampleRate
+	if value := c.Query("sample_rate"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 8000 || parsed > 48000 {
+			return types.NewErrorWithStatusCode(fmt.Errorf("invalid sample_rate: %s", value), types.ErrorCodeInvalidRequest, http.StatusBadRequest, types.ErrOptionWithSkipRetry())
+		}
+		sampleRate = parsed
+	}
+
+	groupRatio := helper.HandleGroupRatio(c, info).GroupRatio
+	maxSeconds, err := service.TranscriptionAffordableSeconds(c, info, groupRatio)
+	if err != nil {
+		return types.NewError(err, types.ErrorCodeQueryDataError, types.ErrOptionWithSkipRetry())
+	}
+	if maxSeconds < 1 {
+		return types.NewErrorWithStatusCode(errors.New("insufficient quota for realtime transcription"), types.ErrorCodeInsufficientUserQuota, http.StatusForbidden, types.ErrOptionWithSkipRetry())
+	}
+
+	// 按会话预扣额度（按次模式的令牌同时计入一次请求），连接上游失败时退还
+	if apiErr := service.PreConsumeTranscriptionQuota(c, info, groupRatio, maxSeconds); apiErr != nil {
+		return apiErr
+	}
+
+	header := http.Header{}
+	for key, value := range info.HeadersOverride {
+		if str, ok := value.(string); ok {
+			header.Set(key, str)
+		}
+	}
+	upstream, err := transcription.Dial(transcription.Config{
+		Protocol:   info.ChannelOtherSettings.RealtimeTranscriptionProtocol,
+		BaseURL:    info.ChannelBaseUrl,
+		ApiKey:     info.ApiKey,
+		Model:      info.UpstreamModelName,
+		Language:   c.Query("language"),
+		SampleRate: sampleRate,
+		Header:     header,
+	})
+	if err != nil {
+		info.Billing.Refund(c)
+		return types.NewError(err, types.ErrorCodeDoRequestFailed)
+	}
+	defer upstream.Close()
+
+	session := &transcriptionSession{
+		c:          c,
+		client:     info.ClientWs,
+		upstream:   upstream,
+		sampleRate: sampleRate,
+		maxBytes:   int64(maxSeconds) * int64(2*sampleRate),
+		done:       make(chan struct{}),
+	}
+	defer close(session.done)
+
+	session.send(transcriptionServerEvent{
+		Type:       "session.created",
+		Model:      info.OriginModelName,
+		SampleRate: sampleRate,
+		MaxSeconds: maxSeconds,
+	})
+	reason := session.run()
+	audioSeconds := transcription.PCMDurationSeconds(session.audioBytes, sampleRate)
+	session.send(transcriptionServerEvent{
+		Type:         "session.closed",
+		AudioSeconds: audioSeconds,
+		Reason:       reason,
+	})
+	logger.LogInfo(c, fmt.Sprintf("realtime transcription session ended, reason: %s, audio seconds: %.2f", reason, audioSeconds))
+
+	service.PostTranscriptionConsumeQuota(c, info, groupRatio, audioSeconds, reason)
+	return nil
+}
+
+type transcriptionSession struct {
+	c          *gin.Context
+	client     *websocket.Conn
+	clientMu   sync.Mutex
+	upstream   transcription.Upstream
+	sampleRate int
+	maxBytes   int64
+	audioBytes int64
+	done       chan struct{}
+}
+
+func (s *transcriptionSession) send(event transcriptionServerEvent) {
+	s.clientMu.Lock()
+	defer s.clientMu.Unlock()
+	_ = helper.WssObject(s.c, s.client, event)
+}
+
+func (s *transcriptionSession) forward(result transcriptionUpstreamResult) {
+	if result.event != nil {
+		isFinal := result.event.IsFinal
+		s.send(transcriptionServerEvent{Type: "transcript", Text: result.event.Text, IsFinal: &isFinal})
+		return
+	}
+	var upstreamErr *transcription.UpstreamError
+	if errors.As(result.err, &upstreamErr) {
+		s.send(transcriptionServerEvent{Type: "error", Message: upstreamErr.Message})
+	}
+}
+
+func (s *transcriptionSession) readClient(frames chan<- transcriptionClientFrame) {
+	for {
+		messageType, data, err := s.client.ReadMessage()
+		select {
+		case frames <- transcriptionClientFrame{messageType: messageType, data: data, err: err}:
+		case <-s.done:
+			return
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+func (s *transcriptionSession) readUpstream(results chan<- transcriptionUpstreamResult) {
+	for {
+		event, err := s.upstream.ReadEvent()
+		if event == nil && err == nil {
+			continue
+		}
+		select {
+		case results <- transcriptionUpstreamResult{event: event, err: err}:
+		case <-s.done:
+			return
+		}
+		var upstreamErr *transcription.UpstreamError
+		if err != nil && !errors.As(err, &upstreamErr) {
+			return
+		}
+	}
+}
+
+// run 处理会话直到结束，返回结束原因
+func (s *transcriptionSession) run() string {
+	frames := make(chan transcriptionClientFrame, 8)
+	results := make(chan transcriptionUpstreamResult, 8)
+	go s.readClient(frames)
+	go s.readUpstream(results)
+
+	idleTimeout := time.Duration(model_setting.GetRealtimeTranscriptionSettings().IdleTimeoutSeconds) * time.Second
+	if idleTimeout <= 0 {
+		idleTimeout = 30 * time.Second
+	}
+	idle := time.NewTimer(idleTimeout)
+	defer idle.Stop()
+
+	for {
+		select {
+		case frame := <-frames:
+			if frame.err != nil {
+				return TranscriptionEndDisconnected
+			}
+			if frame.messageType == websocket.BinaryMessage {
+				idle.Reset(idleTimeout)
+				pcm := frame.data
+				if remaining := s.maxBytes - s.audioBytes; int64(len(pcm)) > remaining {
+					pcm = pcm[:remaining]
+				}
+				if len(pcm) > 0 {
+					if err := s.upstream.SendAudio(pcm); err != nil {
+						logger.LogError(s.c, "send audio to upstream failed: "+err.Error())
+						return TranscriptionEndUpstream
+					}
+					s.audioBytes += int64(len(pcm))
+				}
+				if s.audioBytes >= s.maxBytes {
+					return s.finish(results, TranscriptionEndMaxDuration)
+				}
+				continue
+			}
+			var message transcriptionClientMessage
+			if err := common.Unmarshal(frame.data, &message); err != nil {
+				s.send(transcriptionServerEvent{Type: "error", Message: "invalid control message"})
+				continue
+			}
+			switch message.Type {
+			case "finalize":
+				if err := s.upstream.Finalize(); err != nil {
+					return TranscriptionEndUpstream
+				}
+			case "close":
+				return s.finish(results, TranscriptionEndClientClose)
+			default:
+				s.send(transcriptionServerEvent{Type: "error", Message: "unknown message type: " + message.Type})
+			}
+		case result := <-results:
+			var upstreamErr *transcription.UpstreamError
+			if result.err != nil && !errors.As(result.err, &upstreamErr) {
+				logger.LogError(s.c, "read realtime transcription upstream failed: "+result.err.Error())
+				return TranscriptionEndUpstream
+			}
+			s.forward(result)
+		case <-idle.C:
+			return s.finish(results, TranscriptionEndIdleTimeout)
+		}
+	}
+}
+
+// finish 通知上游结束并转发剩余结果，上游一段时间内无输出或关闭连接后返回
+func (s *transcriptionSession) finish(results <-chan transcriptionUpstreamResult, reason string) string {
+	if err := s.upstream.Finish(); err != nil {
+		return reason
+	}
+	deadline := time.NewTimer(transcriptionDrainMax)
+	defer deadline.Stop()
+	quiet := time.NewTimer(transcriptionDrainQuiet)
+	defer quiet.Stop()
+	for {
+		select {
+		case result := <-results:
+			var upstreamErr *transcription.UpstreamError
+			if result.err != nil && !errors.As(result.err, &upstreamErr) {
+				return reason
+			}
+			s.forward(result)
+			quiet.Reset(transcriptionDrainQuiet)
+		case <-quiet.C:
+			return reason
+		case <-deadline.C:
+			return reason
+		}
+	}
+}
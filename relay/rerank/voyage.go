@@ -0,0 +1,95 @@
+package rerank
+
+import (
+	"fmt"
+
+	"github.com/QuantumNous/new-api/dto"
+)
+
+// 重排序（/v1/rerank）的上游协议转换：渠道重排序协议设置为 voyage 时，
+// 将统一格式（Jina / Cohere 风格）的请求转换为 Voyage 格式，响应转换回统一格式
+
+const (
+	ProtocolJina   = "jina"
+	ProtocolVoyage = "voyage"
+)
+
+type VoyageRequest struct {
+	Query           string   `json:"query"`
+	Documents       []string `json:"documents"`
+	Model           string   `json:"model"`
+	TopK            *int     `json:"top_k,omitempty"`
+	ReturnDocuments *bool    `json:"return_documents,omitempty"`
+	Truncation      *bool    `json:"truncation,omitempty"`
+}
+
+type VoyageResult struct {
+	Index          int     `json:"index"`
+	RelevanceScore float64 `json:"relevance_score"`
+	Document       *string `json:"document,omitempty"`
+}
+
+type VoyageResponse struct {
+	Data  []VoyageResult `json:"data"`
+	Model string         `json:"model"`
+	Usage struct {
+		TotalTokens int `json:"total_tokens"`
+	} `json:"usage"`
+}
+
+// documentText 返回文档的文本，支持字符串与 Cohere 风格的 {"text": "..."}
+func documentText(document any) (string, bool) {
+	switch doc := document.(type) {
+	case string:
+		return doc, true
+	case map[string]any:
+		text, ok := doc["text"].(string)
+		return text, ok
+	}
+	return "", false
+}
+
+// ConvertVoyageRequest 将统一格式的重排序请求转换为 Voyage 请求，Voyage 只接受纯文本文档
+func ConvertVoyageRequest(request dto.RerankRequest) (*VoyageRequest, error) {
+	documents := make([]string, len(request.Documents))
+	for i, document := range request.Documents {
+		text, ok := documentText(document)
+		if !ok {
+			return nil, fmt.Errorf("documents[%d] must be a string or an object with a text field for voyage", i)
+		}
+		documents[i] = text
+	}
+	topK := request.TopN
+	if topK == nil {
+		topK = request.TopK
+	}
+	return &VoyageRequest{
+		Query:           request.Query,
+		Documents:       documents,
+		Model:           request.Model,
+		TopK:            topK,
+		ReturnDocuments: request.ReturnDocuments,
+		Truncation:      request.Truncation,
+	}, nil
+}
+
+// ToRerankResponse 将 Voyage 响应转换为统一格式，返回的文档与 Jina 一致包装为 {"text": "..."}
+func (r *VoyageResponse) ToRerankResponse() *dto.RerankResponse {
+	results := make([]dto.RerankResponseResult, len(r.Data))
+	for i, item := range r.Data {
+		results[i] = dto.RerankResponseResult{
+			Index:          item.Index,
+			RelevanceScore: item.RelevanceScore,
+		}
+		if item.Document != nil {
+			results[i].Document = dto.RerankDocument{Text: *item.Document}
+		}
+	}
+	return &dto.RerankResponse{
+		Results: results,
+		Usage: dto.Usage{
+			PromptTokens: r.Usage.TotalTokens,
+			TotalTokens:  r.Usage.TotalTokens,
+		},
+	}
+}
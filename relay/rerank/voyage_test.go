@@ -0,0 +1,66 @@
+package rerank
+
+import (
+	"testing"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/dto"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConvertVoyageRequest(t *testing.T) {
+	var request dto.RerankRequest
+	require.NoError(t, common.UnmarshalJsonStr(`{
+		"model": "rerank-2",
+		"query": "capital of France",
+		"documents": ["Paris is the capital of France.", {"text": "Berlin is in Germany."}],
+		"top_n": 1,
+		"return_documents": true,
+		"truncation": false
+	}`, &request))
+
+	voyageReq, err := ConvertVoyageRequest(request)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"Paris is the capital of France.", "Berlin is in Germany."}, voyageReq.Documents)
+	assert.Equal(t, 1, *voyageReq.TopK)
+	assert.True(t, *voyageReq.ReturnDocuments)
+	assert.False(t, *voyageReq.Truncation)
+
+	data, err := common.Marshal(voyageReq)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), `"top_k":1`)
+	assert.NotContains(t, string(data), "top_n")
+}
+
+func TestConvertVoyageRequestRejectsStructuredDocument(t *testing.T) {
+	request := dto.RerankRequest{
+		Query:     "q",
+		Documents: []any{map[string]any{"title": "no text"}},
+	}
+	_, err := ConvertVoyageRequest(request)
+	assert.Error(t, err)
+}
+
+func TestVoyageResponseToRerankResponse(t *testing.T) {
+	var voyageResp VoyageResponse
+	require.NoError(t, common.UnmarshalJsonStr(`{
+		"object": "list",
+		"data": [
+			{"relevance_score": 0.9, "index": 1, "document": "Paris"},
+			{"relevance_score": 0.1, "index": 0}
+		],
+		"model": "rerank-2",
+		"usage": {"total_tokens": 42}
+	}`, &voyageResp))
+
+	resp := voyageResp.ToRerankResponse()
+	require.Len(t, resp.Results, 2)
+	assert.Equal(t, 1, resp.Results[0].Index)
+	assert.Equal(t, 0.9, resp.Results[0].RelevanceScore)
+	assert.Equal(t, dto.RerankDocument{Text: "Paris"}, resp.Results[0].Document)
+	assert.Nil(t, resp.Results[1].Document)
+	assert.Equal(t, 42, resp.Usage.PromptTokens)
+	assert.Equal(t, 42, resp.Usage.TotalTokens)
+}
@@ -2,6 +2,7 @@ package relay
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
@@ -10,11 +11,13 @@ import (
 	"github.com/QuantumNous/new-api/common"
 	appconstant "github.com/QuantumNous/new-api/constant"
 	"github.com/QuantumNous/new-api/dto"
+	"github.com/QuantumNous/new-api/logger"
+	"github.com/QuantumNous/new-api/relay/channel"
+	openaichannel "github.com/QuantumNous/new-api/relay/channel/openai"
 	relaycommon "github.com/QuantumNous/new-api/relay/common"
 	relayconstant "github.com/QuantumNous/new-api/relay/constant"
 	"github.com/QuantumNous/new-api/relay/helper"
 	"github.com/QuantumNous/new-api/service"
-	"github.com/QuantumNous/new-api/setting/model_setting"
 	"github.com/QuantumNous/new-api/types"
 
 	"github.com/gin-gonic/gin"
@@ -60,6 +63,15 @@ func ResponsesHelper(c *gin.Context, info *relaycommon.RelayInfo) (newAPIError *
 		return types.NewError(fmt.Errorf("failed to copy request to GeneralOpenAIRequest: %w", err), types.ErrorCodeInvalidRequest, types.ErrOptionWithSkipRetry())
 	}
 
+	// 引用网关文件的 file_id 替换为文件内容，上游无法识别网关的 file_id；替换后的内容只存在于解析后的请求中，不能再透传原始请求体
+	resolved, err := service.ResolveResponsesFileReferences(info.UserId, info.ChannelId, request)
+	if err != nil {
+		return types.NewError(err, types.ErrorCodeQueryDataError, types.ErrOptionWithSkipRetry())
+	}
+	if resolved {
+		info.RequestRewritten = true
+	}
+
 	err = helper.ModelMappedHelper(c, info, request)
 	if err != nil {
 		return types.NewError(err, types.ErrorCodeChannelModelMappedError, types.ErrOptionWithSkipRetry())
@@ -70,26 +82,65 @@ func ResponsesHelper(c *gin.Context, info *relaycommon.RelayInfo) (newAPIError *
 		return types.NewError(fmt.Errorf("invalid api type: %d", info.ApiType), types.ErrorCodeInvalidApiType, types.ErrOptionWithSkipRetry())
 	}
 	adaptor.Init(info)
+	passThrough := info.PassThroughBody()
+
+	// gateway vector stores are searched by the gateway, which can only serve file_search through Chat Completions of OpenAI-type upstreams
+	if info.RelayMode == relayconstant.RelayModeResponses {
+		gatewayStores, err := service.ResponsesUsesGatewayVectorStores(info.UserId, request)
+		if err != nil {
+			return types.NewError(err, types.ErrorCodeQueryDataError, types.ErrOptionWithSkipRetry())
+		}
+		if gatewayStores {
+			if passThrough || info.ApiType != appconstant.APITypeOpenAI {
+				return types.NewErrorWithStatusCode(
+					fmt.Errorf("file_search on gateway vector stores is not supported by channel type %d", info.ChannelType),
+					types.ErrorCodeInvalidRequest,
+					http.StatusBadRequest,
+					types.ErrOptionWithSkipRetry(),
+				)
+			}
+			return relayResponsesViaChatCompletions(c, info, adaptor, request)
+		}
+	}
+
+	// upstreams detected without native /v1/responses support are served through Chat Completions
+	azureResponses := info.ChannelType == appconstant.ChannelTypeAzure && info.RelayMode == relayconstant.RelayModeResponses && !passThrough
+	if info.ApiType == appconstant.APITypeOpenAI && info.RelayMode == relayconstant.RelayModeResponses && !passThrough &&
+		(service.ResponsesNativeUnsupported(info.ChannelOtherSettings) ||
+			(azureResponses && openaichannel.AzureResponsesUnsupported(info.ChannelId, info.UpstreamModelName))) {
+		return relayResponsesViaChatCompletions(c, info, adaptor, request)
+	}
+
 	var requestBody io.Reader
-	if model_setting.GetGlobalSettings().PassThroughRequestEnabled || info.ChannelSetting.PassThroughBodyEnabled {
+	var jsonData []byte
+	if passThrough {
 		storage, err := common.GetBodyStorage(c)
 		if err != nil {
 			return types.NewError(err, types.ErrorCodeReadRequestBodyFailed, types.ErrOptionWithSkipRetry())
 		}
 		requestBody = common.ReaderOnly(storage)
 	} else {
+		if info.ApiType == appconstant.APITypeOpenAI {
+			dropped := service.DropResponsesUnsupportedNativeFeatures(info.ChannelOtherSettings.ResponsesCapability, request)
+			if newAPIError := service.EnforceCompatMode(c, info, dropped); newAPIError != nil {
+				return newAPIError
+			}
+		}
 		convertedRequest, err := adaptor.ConvertOpenAIResponsesRequest(c, info, *request)
+		if errors.Is(err, service.ErrPreviousResponseNotFound) {
+			return types.NewErrorWithStatusCode(err, types.ErrorCodeInvalidRequest, http.StatusBadRequest, types.ErrOptionWithSkipRetry())
+		}
 		if err != nil {
 			return types.NewError(err, types.ErrorCodeConvertRequestFailed, types.ErrOptionWithSkipRetry())
 		}
 		relaycommon.AppendRequestConversionFromRequest(info, convertedRequest)
-		jsonData, err := common.Marshal(convertedRequest)
+		jsonData, err = common.Marshal(convertedRequest)
 		if err != nil {
 			return types.NewError(err, types.ErrorCodeConvertRequestFailed, types.ErrOptionWithSkipRetry())
 		}
 
 		// remove disabled fields for OpenAI Responses API
-		jsonData, err = relaycommon.RemoveDisabledFields(jsonData, info.ChannelOtherSettings, info.ChannelSetting.PassThroughBodyEnabled)
+		jsonData, err = relaycommon.RemoveDisabledFields(jsonData, info.ChannelOtherSettings, info.PassThroughBody())
 		if err != nil {
 			return types.NewError(err, types.ErrorCodeConvertRequestFailed, types.ErrOptionWithSkipRetry())
 		}
@@ -109,7 +160,17 @@ func ResponsesHelper(c *gin.Context, info *relaycommon.RelayInfo) (newAPIError *
 	}
 
 	var httpResp *http.Response
-	resp, err := adaptor.DoRequest(c, info, requestBody)
+	var resp any
+	if azureResponses {
+		var fallback bool
+		resp, fallback, err = doAzureResponsesRequest(c, info, adaptor, jsonData)
+		if err == nil && fallback {
+			logger.LogWarn(c, fmt.Sprintf("azure deployment %s does not support the Responses API, falling back to chat completions", info.UpstreamModelName))
+			return relayResponsesViaChatCompletions(c, info, adaptor, request)
+		}
+	} else {
+		resp, err = adaptor.DoRequest(c, info, requestBody)
+	}
 	if err != nil {
 		return types.NewOpenAIError(err, types.ErrorCodeDoRequestFailed, http.StatusInternalServerError)
 	}
@@ -159,3 +220,51 @@ func ResponsesHelper(c *gin.Context, info *relaycommon.RelayInfo) (newAPIError *
 	}
 	return nil
 }
+
+// relayResponsesViaChatCompletions 通过 Chat Completions 完成 Responses 请求并结算
+func relayResponsesViaChatCompletions(c *gin.Context, info *relaycommon.RelayInfo, adaptor channel.Adaptor, request *dto.OpenAIResponsesRequest) *types.NewAPIError {
+	usage, newAPIError := responsesViaChatCompletions(c, info, adaptor, request)
+	if newAPIError != nil {
+		return newAPIError
+	}
+	postConsumeQuota(c, info, usage)
+	return nil
+}
+
+// doAzureResponsesRequest 依次尝试 Azure 的 Responses 接口，接口不可用时换下一种，返回第一个成功或无法重试的上游响应；
+// 部署不支持 Responses API 时标记该部署并返回 fallback 为 true，由调用方改走 Chat Completions
+func doAzureResponsesRequest(c *gin.Context, info *relaycommon.RelayInfo, adaptor channel.Adaptor, jsonData []byte) (resp any, fallback bool, err error) {
+	endpoints := openaichannel.AzureResponsesEndpoints(info)
+	defer func() { info.AzureResponsesEndpoint = nil }()
+	for i, endpoint := range endpoints {
+		info.AzureResponsesEndpoint = &endpoint
+		resp, err = adaptor.DoRequest(c, info, bytes.NewReader(jsonData))
+		if err != nil {
+			return nil, false, err
+		}
+		httpResp, _ := resp.(*http.Response)
+		if httpResp == nil || httpResp.StatusCode == http.StatusOK {
+			if httpResp != nil && len(endpoints) > 1 {
+				openaichannel.RememberAzureResponsesEndpoint(info.ChannelId, endpoint)
+			}
+			return resp, false, nil
+		}
+		body, err := io.ReadAll(httpResp.Body)
+		service.CloseResponseBodyGracefully(httpResp)
+		if err != nil {
+			return nil, false, err
+		}
+		httpResp.Body = io.NopCloser(bytes.NewReader(body))
+		switch openaichannel.ClassifyAzureResponsesFailure(httpResp.StatusCode, body) {
+		case openaichannel.AzureResponsesFailureDeployment:
+			openaichannel.MarkAzureResponsesUnsupported(info.ChannelId, info.UpstreamModelName)
+			return nil, true, nil
+		case openaichannel.AzureResponsesFailureEndpoint:
+			if i < len(endpoints)-1 {
+				continue
+			}
+		}
+		return httpResp, false, nil
+	}
+	return resp, false, nil
+}
@@ -0,0 +1,129 @@
+package relay
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/dto"
+	"github.com/QuantumNous/new-api/relay/channel"
+	openaichannel "github.com/QuantumNous/new-api/relay/channel/openai"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	relayconstant "github.com/QuantumNous/new-api/relay/constant"
+	"github.com/QuantumNous/new-api/service"
+	"github.com/QuantumNous/new-api/types"
+
+	"github.com/gin-gonic/gin"
+	"github.com/samber/lo"
+)
+
+// responsesViaChatCompletions serves a Responses API request through the Chat Completions
+// endpoint of an upstream that does not support /v1/responses natively.
+func responsesViaChatCompletions(c *gin.Context, info *relaycommon.RelayInfo, adaptor channel.Adaptor, request *dto.OpenAIResponsesRequest) (*dto.Usage, *types.NewAPIError) {
+	// file_search is executed against the gateway vector stores when they are enabled;
+	// output text logprobs map to the chat logprobs of the upstream
+	supported := []string{service.IncludeOutputTextLogprobs}
+	if service.VectorStoreEnabled() {
+		supported = append(supported, dto.BuildInToolFileSearch, service.IncludeFileSearchCallResults)
+	}
+	if newApiErr := service.EnforceCompatMode(c, info, service.ResponsesToChatUnsupportedFeatures(request, supported...)); newApiErr != nil {
+		return nil, newApiErr
+	}
+
+	chatReq, err := service.ResponsesRequestToChatCompletionsRequest(info, request)
+	if err != nil {
+		return nil, types.NewErrorWithStatusCode(err, types.ErrorCodeInvalidRequest, http.StatusBadRequest, types.ErrOptionWithSkipRetry())
+	}
+	// newer API versions spell the output limit of the forwarded Chat request as max_completion_tokens
+	if info.ClientApiVersion.UseMaxCompletionTokens() && chatReq.MaxTokens != nil {
+		chatReq.MaxCompletionTokens = chatReq.MaxTokens
+		chatReq.MaxTokens = nil
+	}
+
+	var prefixItems []dto.ResponsesOutput
+	if service.VectorStoreEnabled() {
+		fileSearchOutput, err := service.ResponsesFileSearch(c.Request.Context(), info.UserId, request, chatReq)
+		if err != nil {
+			return nil, types.NewErrorWithStatusCode(fmt.Errorf("file_search failed: %w", err), types.ErrorCodeInvalidRequest, http.StatusBadRequest, types.ErrOptionWithSkipRetry())
+		}
+		if fileSearchOutput != nil {
+			prefixItems = append(prefixItems, *fileSearchOutput)
+			// retries on another channel search again, but the call is billed once
+			if info.ResponsesUsageInfo != nil {
+				if fileSearchTool, exists := info.ResponsesUsageInfo.BuiltInTools[dto.BuildInToolFileSearch]; exists {
+					fileSearchTool.CallCount = 1
+				}
+			}
+		}
+	}
+	if lo.FromPtrOr(chatReq.Stream, false) {
+		chatReq.StreamOptions = &dto.StreamOptions{IncludeUsage: true}
+	}
+	info.AppendRequestConversion(types.RelayFormatOpenAI)
+
+	savedRelayMode := info.RelayMode
+	savedRequestURLPath := info.RequestURLPath
+	defer func() {
+		info.RelayMode = savedRelayMode
+		info.RequestURLPath = savedRequestURLPath
+	}()
+
+	info.RelayMode = relayconstant.RelayModeChatCompletions
+	info.RequestURLPath = "/v1/chat/completions"
+
+	convertedRequest, err := adaptor.ConvertOpenAIRequest(c, info, chatReq)
+	if err != nil {
+		return nil, types.NewError(err, types.ErrorCodeConvertRequestFailed, types.ErrOptionWithSkipRetry())
+	}
+	relaycommon.AppendRequestConversionFromRequest(info, convertedRequest)
+
+	jsonData, err := common.Marshal(convertedRequest)
+	if err != nil {
+		return nil, types.NewError(err, types.ErrorCodeConvertRequestFailed, types.ErrOptionWithSkipRetry())
+	}
+
+	jsonData, err = relaycommon.RemoveDisabledFields(jsonData, info.ChannelOtherSettings, info.PassThroughBody())
+	if err != nil {
+		return nil, types.NewError(err, types.ErrorCodeConvertRequestFailed, types.ErrOptionWithSkipRetry())
+	}
+
+	if len(info.ParamOverride) > 0 {
+		jsonData, err = relaycommon.ApplyParamOverrideWithRelayInfo(jsonData, info)
+		if err != nil {
+			return nil, newAPIErrorFromParamOverride(err)
+		}
+	}
+
+	resp, err := adaptor.DoRequest(c, info, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, types.NewOpenAIError(err, types.ErrorCodeDoRequestFailed, http.StatusInternalServerError)
+	}
+	if resp == nil {
+		return nil, types.NewOpenAIError(nil, types.ErrorCodeBadResponse, http.StatusInternalServerError)
+	}
+
+	statusCodeMappingStr := c.GetString("status_code_mapping")
+
+	httpResp := resp.(*http.Response)
+	info.IsStream = info.IsStream || strings.HasPrefix(httpResp.Header.Get("Content-Type"), "text/event-stream")
+	if httpResp.StatusCode != http.StatusOK {
+		newApiErr := service.RelayErrorHandler(c.Request.Context(), httpResp, false)
+		service.ResetStatusCode(newApiErr, statusCodeMappingStr)
+		return nil, newApiErr
+	}
+
+	var usage *dto.Usage
+	var newApiErr *types.NewAPIError
+	if info.IsStream {
+		usage, newApiErr = openaichannel.OaiChatToResponsesStreamHandler(c, info, httpResp, request, prefixItems...)
+	} else {
+		usage, newApiErr = openaichannel.OaiChatToResponsesHandler(c, info, httpResp, request, prefixItems...)
+	}
+	if newApiErr != nil {
+		service.ResetStatusCode(newApiErr, statusCodeMappingStr)
+		return nil, newApiErr
+	}
+	return usage, nil
+}
@@ -0,0 +1,97 @@
+package speech
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/QuantumNous/new-api/dto"
+)
+
+// 语音合成（/v1/audio/speech）的上游协议转换：渠道语音合成协议设置为 elevenlabs 时，
+// 将 OpenAI 格式的请求转换为 ElevenLabs 流式合成接口，voice 作为 ElevenLabs 的 voice_id
+
+const (
+	ProtocolOpenAI     = "openai"
+	ProtocolElevenLabs = "elevenlabs"
+)
+
+// OpenAI PCM 输出与 ElevenLabs pcm_24000 相同：24kHz、16 位、单声道
+const (
+	PCMSampleRate     = 24000
+	PCMBytesPerSample = 2
+)
+
+// PCMDuration 返回 PCM 音频字节数对应的时长（秒）
+func PCMDuration(bytes int64) float64 {
+	return float64(bytes) / float64(PCMSampleRate*PCMBytesPerSample)
+}
+
+var elevenLabsOutputFormats = map[string]string{
+	"":     "mp3_44100_128",
+	"mp3":  "mp3_44100_128",
+	"pcm":  "pcm_24000",
+	"opus": "opus_48000_128",
+}
+
+// ElevenLabsOutputFormat 将 OpenAI 的 response_format 转换为 ElevenLabs 的 output_format
+func ElevenLabsOutputFormat(responseFormat string) (string, error) {
+	format, ok := elevenLabsOutputFormats[responseFormat]
+	if !ok {
+		return "", fmt.Errorf("response_format %q is not supported by elevenlabs, use mp3, opus or pcm", responseFormat)
+	}
+	return format, nil
+}
+
+// ElevenLabsStreamURL 构造 ElevenLabs 流式语音合成地址
+func ElevenLabsStreamURL(baseURL string, voiceID string, responseFormat string) (string, error) {
+	if voiceID == "" {
+		return "", errors.New("voice is required")
+	}
+	outputFormat, err := ElevenLabsOutputFormat(responseFormat)
+	if err != nil {
+		return "", err
+	}
+	if baseURL == "" {
+		baseURL = "https://api.elevenlabs.io"
+	}
+	u, err := url.Parse(strings.TrimSuffix(baseURL, "/"))
+	if err != nil {
+		return "", err
+	}
+	if u.Scheme != "https" && u.Scheme != "http" {
+		return "", fmt.Errorf("invalid base url: %s", baseURL)
+	}
+	u.Path = strings.TrimSuffix(u.Path, "/v1") + "/v1/text-to-speech/" + url.PathEscape(voiceID) + "/stream"
+	u.RawQuery = url.Values{"output_format": []string{outputFormat}}.Encode()
+	return u.String(), nil
+}
+
+type ElevenLabsVoiceSettings struct {
+	Speed *float64 `json:"speed,omitempty"`
+}
+
+type ElevenLabsRequest struct {
+	Text          string                   `json:"text"`
+	ModelID       string                   `json:"model_id"`
+	VoiceSettings *ElevenLabsVoiceSettings `json:"voice_settings,omitempty"`
+}
+
+// ConvertElevenLabsRequest 将 OpenAI 语音合成请求转换为 ElevenLabs 请求，ElevenLabs 不支持 SSE 流式输出
+func ConvertElevenLabsRequest(request dto.AudioRequest, upstreamModel string) (*ElevenLabsRequest, error) {
+	if request.StreamFormat == "sse" {
+		return nil, errors.New("stream_format sse is not supported by elevenlabs, use the default audio stream")
+	}
+	if _, err := ElevenLabsOutputFormat(request.ResponseFormat); err != nil {
+		return nil, err
+	}
+	converted := &ElevenLabsRequest{
+		Text:    request.Input,
+		ModelID: upstreamModel,
+	}
+	if request.Speed != nil {
+		converted.VoiceSettings = &ElevenLabsVoiceSettings{Speed: request.Speed}
+	}
+	return converted, nil
+}
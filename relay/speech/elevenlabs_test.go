@@ -0,0 +1,41 @@
+package speech
+
+import (
+	"testing"
+
+	"github.com/QuantumNous/new-api/dto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestElevenLabsStreamURL(t *testing.T) {
+	u, err := ElevenLabsStreamURL("", "21m00Tcm4TlvDq8ikWAM", "pcm")
+	require.NoError(t, err)
+	assert.Equal(t, "https://api.elevenlabs.io/v1/text-to-speech/21m00Tcm4TlvDq8ikWAM/stream?output_format=pcm_24000", u)
+
+	u, err = ElevenLabsStreamURL("https://proxy.example.com/v1/", "voice", "")
+	require.NoError(t, err)
+	assert.Equal(t, "https://proxy.example.com/v1/text-to-speech/voice/stream?output_format=mp3_44100_128", u)
+
+	_, err = ElevenLabsStreamURL("", "", "mp3")
+	assert.Error(t, err)
+	_, err = ElevenLabsStreamURL("", "voice", "flac")
+	assert.Error(t, err)
+}
+
+func TestConvertElevenLabsRequest(t *testing.T) {
+	speed := 1.1
+	converted, err := ConvertElevenLabsRequest(dto.AudioRequest{Input: "hello", Voice: "voice", Speed: &speed}, "eleven_multilingual_v2")
+	require.NoError(t, err)
+	assert.Equal(t, "hello", converted.Text)
+	assert.Equal(t, "eleven_multilingual_v2", converted.ModelID)
+	require.NotNil(t, converted.VoiceSettings)
+	assert.Equal(t, 1.1, *converted.VoiceSettings.Speed)
+
+	_, err = ConvertElevenLabsRequest(dto.AudioRequest{Input: "hello", StreamFormat: "sse"}, "eleven_multilingual_v2")
+	assert.Error(t, err)
+}
+
+func TestPCMDuration(t *testing.T) {
+	assert.Equal(t, 1.5, PCMDuration(72000))
+}
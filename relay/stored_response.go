@@ -0,0 +1,37 @@
+package relay
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	appconstant "github.com/QuantumNous/new-api/constant"
+	"github.com/QuantumNous/new-api/relay/channel"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	relayconstant "github.com/QuantumNous/new-api/relay/constant"
+
+	"github.com/gin-gonic/gin"
+)
+
+// StoredResponseRequest 将已存储响应的查询、删除、取消请求转发到保存该响应的原生渠道，
+// 与实时转发一样经过适配器的请求地址与请求头、渠道的请求头覆盖、请求签名与专用传输。
+// 调用前须已选定渠道并设置创建该响应时使用的密钥；path 为 /v1/responses 之后的部分，例如 /resp_xxx/cancel
+func StoredResponseRequest(c *gin.Context, path string) (*http.Response, error) {
+	info := relaycommon.GenRelayInfoOpenAI(c, nil)
+	info.InitChannelMeta(c)
+	if info.ChannelType == appconstant.ChannelTypeAzure {
+		return nil, errors.New("stored responses of azure channels are not supported")
+	}
+	info.RelayMode = relayconstant.RelayModeResponses
+	info.RequestURLPath = "/v1/responses" + path
+	if c.Request.URL.RawQuery != "" {
+		info.RequestURLPath += "?" + c.Request.URL.RawQuery
+	}
+
+	adaptor := GetAdaptor(info.ApiType)
+	if adaptor == nil {
+		return nil, fmt.Errorf("invalid api type: %d", info.ApiType)
+	}
+	adaptor.Init(info)
+	return channel.DoApiRequest(adaptor, c, info, http.NoBody)
+}
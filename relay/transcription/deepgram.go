@@ -0,0 +1,107 @@
+package transcription
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/QuantumNous/new-api/common"
+
+	"github.com/gorilla/websocket"
+)
+
+type deepgramUpstream struct {
+	conn    *websocket.Conn
+	writeMu sync.Mutex
+}
+
+type deepgramEvent struct {
+	Type    string `json:"type"`
+	IsFinal bool   `json:"is_final"`
+	Channel struct {
+		Alternatives []struct {
+			Transcript string `json:"transcript"`
+		} `json:"alternatives"`
+	} `json:"channel"`
+	Description string `json:"description"`
+}
+
+func dialDeepgram(cfg Config) (Upstream, error) {
+	baseURL := cfg.BaseURL
+	if baseURL == "" {
+		baseURL = "https://api.deepgram.com"
+	}
+	query := url.Values{
+		"model":           []string{cfg.Model},
+		"encoding":        []string{"linear16"},
+		"sample_rate":     []string{strconv.Itoa(cfg.SampleRate)},
+		"channels":        []string{"1"},
+		"interim_results": []string{"true"},
+	}
+	if cfg.Language != "" {
+		query.Set("language", cfg.Language)
+	}
+	target, err := toWebSocketURL(baseURL, "/v1/listen", query)
+	if err != nil {
+		return nil, err
+	}
+	header := http.Header{}
+	for key, values := range cfg.Header {
+		header[key] = values
+	}
+	header.Set("Authorization", "Token "+cfg.ApiKey)
+	conn, err := dialWebSocket(target, header)
+	if err != nil {
+		return nil, err
+	}
+	return &deepgramUpstream{conn: conn}, nil
+}
+
+func (u *deepgramUpstream) write(messageType int, data []byte) error {
+	u.writeMu.Lock()
+	defer u.writeMu.Unlock()
+	return u.conn.WriteMessage(messageType, data)
+}
+
+func (u *deepgramUpstream) SendAudio(pcm []byte) error {
+	return u.write(websocket.BinaryMessage, pcm)
+}
+
+func (u *deepgramUpstream) Finalize() error {
+	return u.write(websocket.TextMessage, []byte(`{"type":"Finalize"}`))
+}
+
+func (u *deepgramUpstream) Finish() error {
+	return u.write(websocket.TextMessage, []byte(`{"type":"CloseStream"}`))
+}
+
+func (u *deepgramUpstream) ReadEvent() (*Event, error) {
+	_, data, err := u.conn.ReadMessage()
+	if err != nil {
+		return nil, err
+	}
+	var event deepgramEvent
+	if err = common.Unmarshal(data, &event); err != nil {
+		return nil, nil
+	}
+	switch event.Type {
+	case "Results":
+		if len(event.Channel.Alternatives) == 0 {
+			return nil, nil
+		}
+		text := strings.TrimSpace(event.Channel.Alternatives[0].Transcript)
+		if text == "" {
+			return nil, nil
+		}
+		return &Event{Text: text, IsFinal: event.IsFinal}, nil
+	case "Error":
+		return nil, &UpstreamError{Message: event.Description}
+	}
+	return nil, nil
+}
+
+func (u *deepgramUpstream) Close() error {
+	return u.conn.Close()
+}
@@ -0,0 +1,168 @@
+package transcription
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/dto"
+)
+
+// 文件转写（/v1/audio/transcriptions）的协议转换：Groq whisper 参数裁剪、Deepgram 预录音频接口转换，
+// 以及在上游不支持流式输出时将完整结果转换为 OpenAI 流式转写事件
+
+// IsGroqWhisperModel 判断上游模型是否为 Groq 托管的 whisper 模型
+func IsGroqWhisperModel(model string) bool {
+	return strings.HasPrefix(model, "whisper-large-v3") || strings.HasPrefix(model, "distil-whisper")
+}
+
+// GroqUnsupportedField 返回 Groq whisper 不支持、转发前需要剔除的表单字段
+func GroqUnsupportedField(key string) bool {
+	switch key {
+	case "stream", "chunking_strategy", "include", "include[]":
+		return true
+	}
+	return false
+}
+
+// FileResult 统一后的文件转写结果
+type FileResult struct {
+	Text     string
+	Language string
+	Duration float64
+}
+
+type deepgramFileResponse struct {
+	Metadata struct {
+		Duration float64 `json:"duration"`
+	} `json:"metadata"`
+	Results struct {
+		Channels []struct {
+			DetectedLanguage string `json:"detected_language"`
+			Alternatives     []struct {
+				Transcript string `json:"transcript"`
+			} `json:"alternatives"`
+		} `json:"channels"`
+	} `json:"results"`
+}
+
+// DeepgramListenURL 构造 Deepgram 预录音频转写地址，未指定语言时开启语言检测
+func DeepgramListenURL(baseURL string, model string, language string) (string, error) {
+	if baseURL == "" {
+		baseURL = "https://api.deepgram.com"
+	}
+	u, err := url.Parse(strings.TrimSuffix(baseURL, "/"))
+	if err != nil {
+		return "", err
+	}
+	if u.Scheme != "https" && u.Scheme != "http" {
+		return "", fmt.Errorf("invalid base url: %s", baseURL)
+	}
+	query := url.Values{
+		"model":        []string{model},
+		"smart_format": []string{"true"},
+		"punctuate":    []string{"true"},
+	}
+	if language != "" {
+		query.Set("language", language)
+	} else {
+		query.Set("detect_language", "true")
+	}
+	u.Path = strings.TrimSuffix(u.Path, "/v1") + "/v1/listen"
+	u.RawQuery = query.Encode()
+	return u.String(), nil
+}
+
+// ParseDeepgramFileResponse 解析 Deepgram 预录音频转写结果
+func ParseDeepgramFileResponse(body []byte) (*FileResult, error) {
+	var resp deepgramFileResponse
+	if err := common.Unmarshal(body, &resp); err != nil {
+		return nil, err
+	}
+	if len(resp.Results.Channels) == 0 {
+		return nil, errors.New("deepgram response has no channels")
+	}
+	channel := resp.Results.Channels[0]
+	result := &FileResult{
+		Language: channel.DetectedLanguage,
+		Duration: resp.Metadata.Duration,
+	}
+	if len(channel.Alternatives) > 0 {
+		result.Text = strings.TrimSpace(channel.Alternatives[0].Transcript)
+	}
+	return result, nil
+}
+
+// FormatFileResult 按 OpenAI 的 response_format 输出转写结果，返回 Content-Type 与响应体
+func FormatFileResult(result *FileResult, responseFormat string) (string, []byte, error) {
+	switch responseFormat {
+	case "text":
+		return "text/plain; charset=utf-8", []byte(result.Text), nil
+	case "srt":
+		body := fmt.Sprintf("1\n%s --> %s\n%s\n", subtitleTime(0, ","), subtitleTime(result.Duration, ","), result.Text)
+		return "text/plain; charset=utf-8", []byte(body), nil
+	case "vtt":
+		body := fmt.Sprintf("WEBVTT\n\n%s --> %s\n%s\n", subtitleTime(0, "."), subtitleTime(result.Duration, "."), result.Text)
+		return "text/vtt; charset=utf-8", []byte(body), nil
+	case "verbose_json":
+		body, err := common.Marshal(dto.WhisperVerboseJSONResponse{
+			Task:     "transcribe",
+			Language: result.Language,
+			Duration: result.Duration,
+			Text:     result.Text,
+		})
+		return "application/json", body, err
+	default:
+		body, err := common.Marshal(dto.AudioResponse{Text: result.Text})
+		return "application/json", body, err
+	}
+}
+
+func subtitleTime(seconds float64, separator string) string {
+	millis := int64(seconds * 1000)
+	return fmt.Sprintf("%02d:%02d:%02d%s%03d", millis/3600000, millis/60000%60, millis/1000%60, separator, millis%1000)
+}
+
+type openAIFileResponse struct {
+	Text     string  `json:"text"`
+	Duration float64 `json:"duration"`
+	Usage    *struct {
+		Type    string  `json:"type"`
+		Seconds float64 `json:"seconds"`
+	} `json:"usage"`
+}
+
+// ParseOpenAIFileResponse 从 OpenAI 兼容接口的非流式转写响应中提取文本与音频时长，
+// 时长优先取 verbose_json 的 duration，其次取 usage 中按时长计量的 seconds
+func ParseOpenAIFileResponse(body []byte, responseFormat string) *FileResult {
+	switch responseFormat {
+	case "text", "srt", "vtt":
+		return &FileResult{Text: strings.TrimSpace(string(body))}
+	}
+	var resp openAIFileResponse
+	if err := common.Unmarshal(body, &resp); err != nil {
+		return &FileResult{}
+	}
+	result := &FileResult{Text: resp.Text, Duration: resp.Duration}
+	if result.Duration == 0 && resp.Usage != nil && resp.Usage.Type == "duration" {
+		result.Duration = resp.Usage.Seconds
+	}
+	return result
+}
+
+// StreamEvents 将完整转写结果转换为 OpenAI 流式转写事件：一条 transcript.text.delta 与一条 transcript.text.done
+func StreamEvents(result *FileResult) []map[string]any {
+	done := map[string]any{
+		"type": "transcript.text.done",
+		"text": result.Text,
+	}
+	if result.Duration > 0 {
+		done["usage"] = map[string]any{"type": "duration", "seconds": result.Duration}
+	}
+	return []map[string]any{
+		{"type": "transcript.text.delta", "delta": result.Text},
+		done,
+	}
+}
@@ -0,0 +1,71 @@
+package transcription
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDeepgramListenURL(t *testing.T) {
+	target, err := DeepgramListenURL("", "nova-3", "")
+	require.NoError(t, err)
+	assert.Equal(t, "https://api.deepgram.com/v1/listen?detect_language=true&model=nova-3&punctuate=true&smart_format=true", target)
+
+	target, err = DeepgramListenURL("https://proxy.example.com/v1/", "nova-2", "en")
+	require.NoError(t, err)
+	assert.Equal(t, "https://proxy.example.com/v1/listen?language=en&model=nova-2&punctuate=true&smart_format=true", target)
+}
+
+func TestDeepgramFileResponseFormats(t *testing.T) {
+	body := []byte(`{"metadata":{"duration":3.5},"results":{"channels":[{"detected_language":"en","alternatives":[{"transcript":" Hello world. "}]}]}}`)
+	result, err := ParseDeepgramFileResponse(body)
+	require.NoError(t, err)
+	assert.Equal(t, "Hello world.", result.Text)
+	assert.Equal(t, "en", result.Language)
+	assert.Equal(t, 3.5, result.Duration)
+
+	contentType, out, err := FormatFileResult(result, "json")
+	require.NoError(t, err)
+	assert.Equal(t, "application/json", contentType)
+	assert.JSONEq(t, `{"text":"Hello world."}`, string(out))
+
+	_, out, err = FormatFileResult(result, "verbose_json")
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"task":"transcribe","language":"en","duration":3.5,"text":"Hello world."}`, string(out))
+
+	_, out, err = FormatFileResult(result, "srt")
+	require.NoError(t, err)
+	assert.Equal(t, "1\n00:00:00,000 --> 00:00:03,500\nHello world.\n", string(out))
+
+	_, err = ParseDeepgramFileResponse([]byte(`{"results":{"channels":[]}}`))
+	assert.Error(t, err)
+}
+
+func TestParseOpenAIFileResponse(t *testing.T) {
+	result := ParseOpenAIFileResponse([]byte(`{"text":"hi","usage":{"type":"duration","seconds":7}}`), "json")
+	assert.Equal(t, "hi", result.Text)
+	assert.Equal(t, 7.0, result.Duration)
+
+	result = ParseOpenAIFileResponse([]byte(`{"text":"hi","duration":2.25,"usage":{"type":"tokens","input_tokens":3}}`), "verbose_json")
+	assert.Equal(t, 2.25, result.Duration)
+
+	result = ParseOpenAIFileResponse([]byte("hi there\n"), "text")
+	assert.Equal(t, "hi there", result.Text)
+	assert.Zero(t, result.Duration)
+}
+
+func TestStreamEventsAndGroqFields(t *testing.T) {
+	events := StreamEvents(&FileResult{Text: "hi", Duration: 2})
+	require.Len(t, events, 2)
+	assert.Equal(t, "transcript.text.delta", events[0]["type"])
+	assert.Equal(t, "hi", events[0]["delta"])
+	assert.Equal(t, "transcript.text.done", events[1]["type"])
+	assert.Equal(t, map[string]any{"type": "duration", "seconds": 2.0}, events[1]["usage"])
+
+	assert.True(t, IsGroqWhisperModel("whisper-large-v3-turbo"))
+	assert.True(t, IsGroqWhisperModel("distil-whisper-large-v3-en"))
+	assert.False(t, IsGroqWhisperModel("whisper-1"))
+	assert.True(t, GroqUnsupportedField("stream"))
+	assert.False(t, GroqUnsupportedField("language"))
+}
@@ -0,0 +1,148 @@
+package transcription
+
+import (
+	"encoding/base64"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/QuantumNous/new-api/common"
+
+	"github.com/gorilla/websocket"
+)
+
+// openAIRealtimeSampleRate OpenAI 实时转写只接受 24kHz pcm16
+const openAIRealtimeSampleRate = 24000
+
+type openAIUpstream struct {
+	conn      *websocket.Conn
+	writeMu   sync.Mutex
+	resampler *pcmResampler
+	// 按 item_id 累积中间结果，便于向客户端输出完整的当前句子
+	partial map[string]string
+}
+
+type openAIRealtimeEvent struct {
+	Type       string `json:"type"`
+	ItemId     string `json:"item_id"`
+	Delta      string `json:"delta"`
+	Transcript string `json:"transcript"`
+	Error      *struct {
+		Code    string `json:"code"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+func dialOpenAI(cfg Config) (Upstream, error) {
+	baseURL := cfg.BaseURL
+	if baseURL == "" {
+		baseURL = "https://api.openai.com"
+	}
+	target, err := toWebSocketURL(baseURL, "/v1/realtime", url.Values{"intent": []string{"transcription"}})
+	if err != nil {
+		return nil, err
+	}
+	header := http.Header{}
+	for key, values := range cfg.Header {
+		header[key] = values
+	}
+	header.Set("Authorization", "Bearer "+cfg.ApiKey)
+	header.Set("OpenAI-Beta", "realtime=v1")
+	conn, err := dialWebSocket(target, header)
+	if err != nil {
+		return nil, err
+	}
+
+	transcriptionConfig := map[string]any{"model": cfg.Model}
+	if cfg.Language != "" {
+		transcriptionConfig["language"] = cfg.Language
+	}
+	update := map[string]any{
+		"type": "transcription_session.update",
+		"session": map[string]any{
+			"input_audio_format":        "pcm16",
+			"input_audio_transcription": transcriptionConfig,
+			"turn_detection":            map[string]any{"type": "server_vad"},
+		},
+	}
+	u := &openAIUpstream{
+		conn:      conn,
+		resampler: newPCMResampler(cfg.SampleRate, openAIRealtimeSampleRate),
+		partial:   make(map[string]string),
+	}
+	if err = u.writeJSON(update); err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	return u, nil
+}
+
+func (u *openAIUpstream) writeJSON(v any) error {
+	data, err := common.Marshal(v)
+	if err != nil {
+		return err
+	}
+	u.writeMu.Lock()
+	defer u.writeMu.Unlock()
+	return u.conn.WriteMessage(websocket.TextMessage, data)
+}
+
+func (u *openAIUpstream) SendAudio(pcm []byte) error {
+	resampled := u.resampler.Process(pcm)
+	if len(resampled) == 0 {
+		return nil
+	}
+	return u.writeJSON(map[string]any{
+		"type":  "input_audio_buffer.append",
+		"audio": base64.StdEncoding.EncodeToString(resampled),
+	})
+}
+
+func (u *openAIUpstream) Finalize() error {
+	return u.writeJSON(map[string]any{"type": "input_audio_buffer.commit"})
+}
+
+func (u *openAIUpstream) Finish() error {
+	// OpenAI 没有结束流的消息，提交剩余音频后等待最终结果即可
+	return u.Finalize()
+}
+
+func (u *openAIUpstream) ReadEvent() (*Event, error) {
+	_, data, err := u.conn.ReadMessage()
+	if err != nil {
+		return nil, err
+	}
+	var event openAIRealtimeEvent
+	if err = common.Unmarshal(data, &event); err != nil {
+		return nil, nil
+	}
+	switch event.Type {
+	case "conversation.item.input_audio_transcription.delta":
+		u.partial[event.ItemId] += event.Delta
+		return &Event{Text: u.partial[event.ItemId]}, nil
+	case "conversation.item.input_audio_transcription.completed":
+		delete(u.partial, event.ItemId)
+		return &Event{Text: strings.TrimSpace(event.Transcript), IsFinal: true}, nil
+	case "error":
+		// 缓冲区为空时提交会报错，结束会话时属于正常情况
+		if event.Error == nil || event.Error.Code == "input_audio_buffer_commit_empty" {
+			return nil, nil
+		}
+		return nil, &UpstreamError{Message: event.Error.Message}
+	}
+	return nil, nil
+}
+
+func (u *openAIUpstream) Close() error {
+	return u.conn.Close()
+}
+
+// UpstreamError 上游在会话中返回的错误，会转发给客户端但不一定中断会话
+type UpstreamError struct {
+	Message string
+}
+
+func (e *UpstreamError) Error() string {
+	return e.Message
+}
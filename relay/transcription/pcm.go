@@ -0,0 +1,68 @@
+package transcription
+
+import "encoding/binary"
+
+// PCMDurationSeconds 计算单声道 16 位 PCM 数据的时长
+func PCMDurationSeconds(bytes int64, sampleRate int) float64 {
+	if sampleRate <= 0 {
+		return 0
+	}
+	return float64(bytes) / float64(2*sampleRate)
+}
+
+// pcmResampler 对分块到达的单声道 pcm16 做线性插值重采样，跨块保留相位与奇数字节
+type pcmResampler struct {
+	from    int
+	to      int
+	pos     float64 // 下一个输出样本在当前块输入坐标中的位置，-1 表示上一块的最后一个样本
+	last    int16
+	hasLast bool
+	pending []byte
+}
+
+func newPCMResampler(from int, to int) *pcmResampler {
+	return &pcmResampler{from: from, to: to}
+}
+
+func (r *pcmResampler) Process(data []byte) []byte {
+	if len(r.pending) > 0 {
+		data = append(r.pending, data...)
+		r.pending = nil
+	}
+	if len(data)%2 == 1 {
+		r.pending = []byte{data[len(data)-1]}
+		data = data[:len(data)-1]
+	}
+	if r.from == r.to || r.from <= 0 || r.to <= 0 {
+		return data
+	}
+	n := len(data) / 2
+	if n == 0 {
+		return nil
+	}
+	sample := func(i int) float64 {
+		if i < 0 {
+			return float64(r.last)
+		}
+		return float64(int16(binary.LittleEndian.Uint16(data[i*2:])))
+	}
+	step := float64(r.from) / float64(r.to)
+	out := make([]byte, 0, int(float64(n)/step+2)*2)
+	for r.pos <= float64(n-1) {
+		i := int(r.pos)
+		if r.pos < 0 {
+			i = -1
+		}
+		frac := r.pos - float64(i)
+		value := sample(i)
+		if frac > 0 {
+			value += (sample(i+1) - value) * frac
+		}
+		out = binary.LittleEndian.AppendUint16(out, uint16(int16(value)))
+		r.pos += step
+	}
+	r.last = int16(binary.LittleEndian.Uint16(data[(n-1)*2:]))
+	r.hasLast = true
+	r.pos -= float64(n)
+	return out
+}
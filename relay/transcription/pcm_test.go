@@ -0,0 +1,38 @@
+package transcription
+
+import (
+	"encoding/binary"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func pcmSamples(values ...int16) []byte {
+	out := make([]byte, 0, len(values)*2)
+	for _, v := range values {
+		out = binary.LittleEndian.AppendUint16(out, uint16(v))
+	}
+	return out
+}
+
+func TestPCMDurationSeconds(t *testing.T) {
+	assert.Equal(t, 1.0, PCMDurationSeconds(32000, 16000))
+	assert.Equal(t, 0.5, PCMDurationSeconds(24000, 24000))
+	assert.Equal(t, 0.0, PCMDurationSeconds(100, 0))
+}
+
+func TestPCMResamplerUpsampleAcrossChunks(t *testing.T) {
+	r := newPCMResampler(1, 2)
+	first := r.Process(pcmSamples(0, 100))
+	second := r.Process(pcmSamples(200))
+	require.Equal(t, pcmSamples(0, 50, 100), first)
+	require.Equal(t, pcmSamples(150, 200), second)
+}
+
+func TestPCMResamplerKeepsOddByte(t *testing.T) {
+	r := newPCMResampler(16000, 16000)
+	data := pcmSamples(1, 2)
+	assert.Equal(t, data[:2], r.Process(data[:3]))
+	assert.Equal(t, data[2:], r.Process(data[3:]))
+}
@@ -0,0 +1,87 @@
+package transcription
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	ProtocolOpenAI   = "openai"
+	ProtocolDeepgram = "deepgram"
+)
+
+// Event 上游返回的转写结果，IsFinal 为 false 时表示中间结果
+type Event struct {
+	Text    string
+	IsFinal bool
+}
+
+// Upstream 上游实时转写连接，音频统一为单声道 16 位小端 PCM
+type Upstream interface {
+	// SendAudio 发送客户端采样率下的 PCM 音频
+	SendAudio(pcm []byte) error
+	// Finalize 要求上游尽快输出当前缓冲音频的最终结果
+	Finalize() error
+	// Finish 通知上游不再发送音频，之后仍可读取剩余结果
+	Finish() error
+	// ReadEvent 读取下一条结果，返回 nil 事件表示该消息无需转发
+	ReadEvent() (*Event, error)
+	Close() error
+}
+
+// Config 建立上游连接所需的参数
+type Config struct {
+	Protocol   string
+	BaseURL    string
+	ApiKey     string
+	Model      string
+	Language   string
+	SampleRate int
+	Header     http.Header
+}
+
+// Dial 按协议连接上游实时转写服务
+func Dial(cfg Config) (Upstream, error) {
+	switch cfg.Protocol {
+	case "", ProtocolOpenAI:
+		return dialOpenAI(cfg)
+	case ProtocolDeepgram:
+		return dialDeepgram(cfg)
+	default:
+		return nil, fmt.Errorf("unsupported realtime transcription protocol: %s", cfg.Protocol)
+	}
+}
+
+// toWebSocketURL 将渠道的 http(s) 地址转换为 ws(s) 地址并拼接路径
+func toWebSocketURL(baseURL string, path string, query url.Values) (string, error) {
+	u, err := url.Parse(strings.TrimSuffix(baseURL, "/"))
+	if err != nil {
+		return "", err
+	}
+	switch u.Scheme {
+	case "https", "wss":
+		u.Scheme = "wss"
+	case "http", "ws":
+		u.Scheme = "ws"
+	default:
+		return "", fmt.Errorf("invalid base url: %s", baseURL)
+	}
+	u.Path = strings.TrimSuffix(u.Path, "/v1") + path
+	u.RawQuery = query.Encode()
+	return u.String(), nil
+}
+
+func dialWebSocket(target string, header http.Header) (*websocket.Conn, error) {
+	conn, resp, err := websocket.DefaultDialer.Dial(target, header)
+	if err != nil {
+		if resp != nil {
+			return nil, fmt.Errorf("dial %s failed with status %d: %w", target, resp.StatusCode, err)
+		}
+		return nil, fmt.Errorf("dial %s failed: %w", target, err)
+	}
+	return conn, nil
+}
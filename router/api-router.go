@@ -22,6 +22,8 @@ func SetApiRouter(router *gin.Engine) {
 		apiRouter.POST("/setup", controller.PostSetup)
 		apiRouter.GET("/status", controller.GetStatus)
 		apiRouter.GET("/uptime/status", controller.GetUptimeKumaStatus)
+		apiRouter.GET("/status/models", controller.GetModelStatusPage)
+		apiRouter.GET("/status/usage", controller.GetPublicStats)
 		apiRouter.GET("/models", middleware.UserAuth(), controller.DashboardListModels)
 		apiRouter.GET("/status/test", middleware.AdminAuth(), controller.TestStatus)
 		apiRouter.GET("/notice", controller.GetNotice)
@@ -30,6 +32,7 @@ func SetApiRouter(router *gin.Engine) {
 		apiRouter.GET("/about", controller.GetAbout)
 		//apiRouter.GET("/midjourney", controller.GetMidjourney)
 		apiRouter.GET("/home_page_content", controller.GetHomePageContent)
+		apiRouter.GET("/branding", controller.GetBranding)
 		apiRouter.GET("/pricing", middleware.TryUserAuth(), controller.GetPricing)
 		apiRouter.GET("/verification", middleware.EmailVerificationRateLimit(), middleware.TurnstileCheck(), controller.SendEmailVerification)
 		apiRouter.GET("/reset_password", middleware.CriticalRateLimit(), middleware.TurnstileCheck(), controller.SendPasswordResetEmail)
@@ -83,6 +86,7 @@ func SetApiRouter(router *gin.Engine) {
 				selfRoute.GET("/aff", controller.GetAffCode)
 				selfRoute.GET("/topup/info", controller.GetTopUpInfo)
 				selfRoute.GET("/topup/self", controller.GetUserTopUps)
+				selfRoute.GET("/self/quota_pools", controller.GetSelfQuotaPools)
 				selfRoute.POST("/topup", middleware.CriticalRateLimit(), controller.TopUp)
 				selfRoute.POST("/pay", middleware.CriticalRateLimit(), controller.RequestEpay)
 				selfRoute.POST("/amount", controller.RequestAmount)
@@ -108,22 +112,31 @@ func SetApiRouter(router *gin.Engine) {
 				selfRoute.DELETE("/oauth/bindings/:provider_id", controller.UnbindCustomOAuth)
 			}
 
+			// 用户管理接口同时开放给租户管理员，数据限定在其租户内
+			tenantAdminRoute := userRoute.Group("/")
+			tenantAdminRoute.Use(middleware.TenantAdminAuth())
+			{
+				tenantAdminRoute.GET("/", controller.GetAllUsers)
+				tenantAdminRoute.GET("/search", controller.SearchUsers)
+				tenantAdminRoute.GET("/:id", controller.GetUser)
+				tenantAdminRoute.POST("/", controller.CreateUser)
+				tenantAdminRoute.POST("/manage", controller.ManageUser)
+				tenantAdminRoute.PUT("/", controller.UpdateUser)
+				tenantAdminRoute.DELETE("/:id", controller.DeleteUser)
+			}
+
 			adminRoute := userRoute.Group("/")
 			adminRoute.Use(middleware.AdminAuth())
 			{
-				adminRoute.GET("/", controller.GetAllUsers)
 				adminRoute.GET("/topup", controller.GetAllTopUps)
+				adminRoute.POST("/provision", controller.ProvisionUser)
 				adminRoute.POST("/topup/complete", controller.AdminCompleteTopUp)
-				adminRoute.GET("/search", controller.SearchUsers)
 				adminRoute.GET("/:id/oauth/bindings", controller.GetUserOAuthBindingsByAdmin)
 				adminRoute.DELETE("/:id/oauth/bindings/:provider_id", controller.UnbindCustomOAuthByAdmin)
 				adminRoute.DELETE("/:id/bindings/:binding_type", controller.AdminClearUserBinding)
-				adminRoute.GET("/:id", controller.GetUser)
-				adminRoute.POST("/", controller.CreateUser)
-				adminRoute.POST("/manage", controller.ManageUser)
-				adminRoute.PUT("/", controller.UpdateUser)
-				adminRoute.DELETE("/:id", controller.DeleteUser)
 				adminRoute.DELETE("/:id/reset_passkey", controller.AdminResetPasskey)
+				adminRoute.GET("/:id/quota_pools", controller.GetUserQuotaPools)
+				adminRoute.POST("/:id/quota_pools", controller.GrantUserQuotaPool)
 
 				// Admin 2FA routes
 				adminRoute.GET("/2fa/stats", controller.Admin2FAStats)
@@ -185,6 +198,65 @@ func SetApiRouter(router *gin.Engine) {
 			customOAuthRoute.PUT("/:id", controller.UpdateCustomOAuthProvider)
 			customOAuthRoute.DELETE("/:id", controller.DeleteCustomOAuthProvider)
 		}
+		// Branding management (root only)
+		brandingRoute := apiRouter.Group("/branding")
+		brandingRoute.Use(middleware.RootAuth())
+		{
+			brandingRoute.GET("/settings", controller.GetBrandingSettings)
+			brandingRoute.PUT("/settings", controller.UpdateBrandingSettings)
+			brandingRoute.POST("/preview", controller.PreviewBranding)
+		}
+		// Tenant management (root only)
+		tenantRoute := apiRouter.Group("/tenant")
+		tenantRoute.Use(middleware.RootAuth())
+		{
+			tenantRoute.GET("/", controller.GetTenants)
+			tenantRoute.GET("/:id", controller.GetTenant)
+			tenantRoute.POST("/", controller.CreateTenant)
+			tenantRoute.PUT("/", controller.UpdateTenant)
+			tenantRoute.DELETE("/:id", controller.DeleteTenant)
+		}
+		// Feature flags for gradual rollout (root only)
+		featureFlagRoute := apiRouter.Group("/feature_flag")
+		featureFlagRoute.Use(middleware.RootAuth())
+		{
+			featureFlagRoute.GET("/", controller.GetFeatureFlags)
+			featureFlagRoute.POST("/", controller.CreateFeatureFlag)
+			featureFlagRoute.PUT("/", controller.UpdateFeatureFlag)
+			featureFlagRoute.DELETE("/:id", controller.DeleteFeatureFlag)
+		}
+		// Human review queue of requests flagged by the moderation review
+		moderationReviewRoute := apiRouter.Group("/moderation_review")
+		moderationReviewRoute.Use(middleware.AdminAuth())
+		{
+			moderationReviewRoute.GET("/", controller.GetModerationReviews)
+			moderationReviewRoute.GET("/:id", controller.GetModerationReview)
+			moderationReviewRoute.POST("/:id/review", controller.ReviewModerationReview)
+		}
+		promptTemplateRoute := apiRouter.Group("/prompt_template")
+		promptTemplateRoute.Use(middleware.AdminAuth())
+		{
+			promptTemplateRoute.GET("/", controller.GetPromptTemplates)
+			promptTemplateRoute.GET("/:prompt_id", controller.GetPromptTemplateVersions)
+			promptTemplateRoute.POST("/", controller.CreatePromptTemplate)
+			promptTemplateRoute.DELETE("/:prompt_id", controller.DeletePromptTemplate)
+		}
+		modelLifecycleRoute := apiRouter.Group("/model_lifecycle")
+		modelLifecycleRoute.Use(middleware.AdminAuth())
+		{
+			modelLifecycleRoute.GET("/usage", controller.GetModelDeprecationUsages)
+		}
+		// Usage reports and their scheduled delivery subscriptions
+		reportRoute := apiRouter.Group("/report")
+		reportRoute.Use(middleware.UserAuth())
+		{
+			reportRoute.GET("/usage", controller.GetUsageReport)
+			reportRoute.GET("/subscription", controller.GetReportSubscriptions)
+			reportRoute.POST("/subscription", controller.CreateReportSubscription)
+			reportRoute.PUT("/subscription", controller.UpdateReportSubscription)
+			reportRoute.DELETE("/subscription/:id", controller.DeleteReportSubscription)
+			reportRoute.POST("/subscription/:id/send", controller.SendReportSubscription)
+		}
 		performanceRoute := apiRouter.Group("/performance")
 		performanceRoute.Use(middleware.RootAuth())
 		{
@@ -206,6 +278,8 @@ func SetApiRouter(router *gin.Engine) {
 			channelRoute.GET("/search", controller.SearchChannels)
 			channelRoute.GET("/models", controller.ChannelListModels)
 			channelRoute.GET("/models_enabled", controller.EnabledListModels)
+			channelRoute.GET("/labels", controller.GetChannelLabels)
+			channelRoute.GET("/labels/usage", controller.GetChannelLabelUsage)
 			channelRoute.GET("/:id", controller.GetChannel)
 			channelRoute.POST("/:id/key", middleware.RootAuth(), middleware.CriticalRateLimit(), middleware.DisableCache(), middleware.SecureVerificationRequired(), controller.GetChannelKey)
 			channelRoute.GET("/test", controller.TestAllChannels)
@@ -241,6 +315,7 @@ func SetApiRouter(router *gin.Engine) {
 			channelRoute.POST("/upstream_updates/apply_all", controller.ApplyAllChannelUpstreamModelUpdates)
 			channelRoute.POST("/upstream_updates/detect", controller.DetectChannelUpstreamModelUpdates)
 			channelRoute.POST("/upstream_updates/detect_all", controller.DetectAllChannelUpstreamModelUpdates)
+			channelRoute.POST("/responses_capability/detect", controller.DetectChannelResponsesCapability)
 		}
 		tokenRoute := apiRouter.Group("/token")
 		tokenRoute.Use(middleware.UserAuth())
@@ -248,6 +323,7 @@ func SetApiRouter(router *gin.Engine) {
 			tokenRoute.GET("/", controller.GetAllTokens)
 			tokenRoute.GET("/search", middleware.SearchRateLimit(), controller.SearchTokens)
 			tokenRoute.GET("/:id", controller.GetToken)
+			tokenRoute.GET("/:id/quota_periods", controller.GetTokenQuotaPeriods)
 			tokenRoute.POST("/:id/key", middleware.CriticalRateLimit(), middleware.DisableCache(), controller.GetTokenKey)
 			tokenRoute.POST("/", controller.AddToken)
 			tokenRoute.PUT("/", controller.UpdateToken)
@@ -283,9 +359,27 @@ func SetApiRouter(router *gin.Engine) {
 		logRoute.GET("/self/stat", middleware.UserAuth(), controller.GetLogsSelfStat)
 		logRoute.GET("/channel_affinity_usage_cache", middleware.AdminAuth(), controller.GetChannelAffinityUsageCacheStats)
 		logRoute.GET("/search", middleware.AdminAuth(), controller.SearchAllLogs)
+		logRoute.GET("/capture/:request_id", middleware.AdminAuth(), controller.GetRequestCapture)
+		logRoute.GET("/responses/:id/export", middleware.AdminAuth(), controller.AdminExportResponse)
 		logRoute.GET("/self", middleware.UserAuth(), controller.GetUserLogs)
 		logRoute.GET("/self/search", middleware.UserAuth(), middleware.SearchRateLimit(), controller.SearchUserLogs)
 
+		feedbackRoute := apiRouter.Group("/feedback")
+		feedbackRoute.Use(middleware.AdminAuth())
+		{
+			feedbackRoute.GET("/", controller.GetFeedbacks)
+			feedbackRoute.GET("/stat", controller.GetFeedbackStats)
+		}
+
+		datasetExportRoute := apiRouter.Group("/dataset_export")
+		datasetExportRoute.Use(middleware.RootAuth())
+		{
+			datasetExportRoute.GET("/", controller.GetDatasetExports)
+			datasetExportRoute.POST("/", controller.CreateDatasetExport)
+			datasetExportRoute.GET("/:id", controller.GetDatasetExport)
+			datasetExportRoute.GET("/:id/download", controller.DownloadDatasetExport)
+		}
+
 		dataRoute := apiRouter.Group("/data")
 		dataRoute.GET("/", middleware.AdminAuth(), controller.GetAllQuotaDates)
 		dataRoute.GET("/self", middleware.UserAuth(), controller.GetUserQuotaDates)
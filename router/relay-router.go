@@ -18,7 +18,7 @@ func SetRelayRouter(router *gin.Engine) {
 	// https://platform.openai.com/docs/api-reference/introduction
 	modelsRouter := router.Group("/v1/models")
 	modelsRouter.Use(middleware.RouteTag("relay"))
-	modelsRouter.Use(middleware.TokenAuth())
+	modelsRouter.Use(middleware.RelayIPLimit(), middleware.TokenAuth())
 	{
 		modelsRouter.GET("", func(c *gin.Context) {
 			switch {
@@ -43,7 +43,7 @@ func SetRelayRouter(router *gin.Engine) {
 
 	geminiRouter := router.Group("/v1beta/models")
 	geminiRouter.Use(middleware.RouteTag("relay"))
-	geminiRouter.Use(middleware.TokenAuth())
+	geminiRouter.Use(middleware.RelayIPLimit(), middleware.TokenAuth())
 	{
 		geminiRouter.GET("", func(c *gin.Context) {
 			controller.ListModels(c, constant.ChannelTypeGemini)
@@ -52,7 +52,7 @@ func SetRelayRouter(router *gin.Engine) {
 
 	geminiCompatibleRouter := router.Group("/v1beta/openai/models")
 	geminiCompatibleRouter.Use(middleware.RouteTag("relay"))
-	geminiCompatibleRouter.Use(middleware.TokenAuth())
+	geminiCompatibleRouter.Use(middleware.RelayIPLimit(), middleware.TokenAuth())
 	{
 		geminiCompatibleRouter.GET("", func(c *gin.Context) {
 			controller.ListModels(c, constant.ChannelTypeOpenAI)
@@ -69,7 +69,7 @@ func SetRelayRouter(router *gin.Engine) {
 	relayV1Router := router.Group("/v1")
 	relayV1Router.Use(middleware.RouteTag("relay"))
 	relayV1Router.Use(middleware.SystemPerformanceCheck())
-	relayV1Router.Use(middleware.TokenAuth())
+	relayV1Router.Use(middleware.RelayIPLimit(), middleware.TokenAuth(), middleware.GroupEndpointAccess())
 	relayV1Router.Use(middleware.ModelRequestRateLimit())
 	{
 		// WebSocket 路由（统一到 Relay）
@@ -78,6 +78,52 @@ func SetRelayRouter(router *gin.Engine) {
 		wsRouter.GET("/realtime", func(c *gin.Context) {
 			controller.Relay(c, types.RelayFormatOpenAIRealtime)
 		})
+		wsRouter.GET("/realtime/transcriptions", controller.RelayRealtimeTranscription)
+	}
+	{
+		// 网关本地实现的文件与向量库接口，不经过渠道分发
+		localRouter := relayV1Router.Group("")
+		localRouter.GET("/files", controller.ListFiles)
+		localRouter.POST("/files", controller.UploadFile)
+		localRouter.GET("/files/:id", controller.GetFile)
+		localRouter.DELETE("/files/:id", controller.DeleteFile)
+		localRouter.GET("/files/:id/content", controller.GetFileContent)
+
+		localRouter.GET("/vector_stores", controller.ListVectorStores)
+		localRouter.POST("/vector_stores", controller.CreateVectorStore)
+		localRouter.GET("/vector_stores/:id", controller.GetVectorStore)
+		localRouter.POST("/vector_stores/:id", controller.UpdateVectorStore)
+		localRouter.DELETE("/vector_stores/:id", controller.DeleteVectorStore)
+		localRouter.POST("/vector_stores/:id/search", controller.SearchVectorStore)
+		localRouter.GET("/vector_stores/:id/files", controller.ListVectorStoreFiles)
+		localRouter.POST("/vector_stores/:id/files", controller.CreateVectorStoreFile)
+		localRouter.GET("/vector_stores/:id/files/:file_id", controller.GetVectorStoreFile)
+		localRouter.DELETE("/vector_stores/:id/files/:file_id", controller.DeleteVectorStoreFile)
+
+		localRouter.GET("/batch_requests/:id", controller.GetBatchRequest)
+		// Batch API：输入与结果文件均为网关文件，由网关提交到上游或逐行模拟执行
+		localRouter.POST("/batches", controller.CreateBatch)
+		localRouter.GET("/batches", controller.ListBatches)
+		localRouter.GET("/batches/:id", controller.GetBatch)
+		localRouter.POST("/batches/:id/cancel", controller.CancelBatch)
+
+		// 由当前令牌签发短期、限模型、限额度的临时令牌，供浏览器、移动端使用
+		localRouter.POST("/tokens/temporary", controller.CreateTemporaryToken)
+		localRouter.POST("/realtime/client_secrets", controller.CreateRealtimeClientSecret)
+
+		// 按目标模型估算请求的提示词 token 数
+		localRouter.POST("/token/count", controller.CountTokens)
+		// Anthropic SDK 的 messages.count_tokens，与 /v1/messages 使用相同的请求格式
+		localRouter.POST("/messages/count_tokens", controller.CountClaudeMessageTokens)
+
+		// 已存储的 Responses API 响应，兼容层保存的在本地处理，原生渠道的转发到创建它的渠道
+		localRouter.GET("/responses/:id", controller.GetResponse)
+		localRouter.DELETE("/responses/:id", controller.DeleteResponse)
+		localRouter.POST("/responses/:id/cancel", controller.CancelResponse)
+		localRouter.GET("/responses/:id/export", controller.ExportResponse)
+		localRouter.POST("/responses/:id/feedback", controller.UpdateResponseFeedback)
+		// 按请求 ID（X-Oneapi-Request-Id）对任意一次请求评价
+		localRouter.POST("/feedback", controller.CreateFeedback)
 	}
 	{
 		//http router
@@ -94,11 +140,19 @@ func SetRelayRouter(router *gin.Engine) {
 			controller.Relay(c, types.RelayFormatOpenAI)
 		})
 		httpRouter.POST("/chat/completions", func(c *gin.Context) {
+			if middleware.IsFanoutRequest(c) {
+				controller.RelayFanout(c)
+				return
+			}
 			controller.Relay(c, types.RelayFormatOpenAI)
 		})
 
 		// response related routes
 		httpRouter.POST("/responses", func(c *gin.Context) {
+			if controller.IsBackgroundResponsesRequest(c) {
+				controller.RelayResponsesBackground(c)
+				return
+			}
 			controller.Relay(c, types.RelayFormatOpenAIResponses)
 		})
 		httpRouter.POST("/responses/compact", func(c *gin.Context) {
@@ -152,11 +206,6 @@ func SetRelayRouter(router *gin.Engine) {
 
 		// not implemented
 		httpRouter.POST("/images/variations", controller.RelayNotImplemented)
-		httpRouter.GET("/files", controller.RelayNotImplemented)
-		httpRouter.POST("/files", controller.RelayNotImplemented)
-		httpRouter.DELETE("/files/:id", controller.RelayNotImplemented)
-		httpRouter.GET("/files/:id", controller.RelayNotImplemented)
-		httpRouter.GET("/files/:id/content", controller.RelayNotImplemented)
 		httpRouter.POST("/fine-tunes", controller.RelayNotImplemented)
 		httpRouter.GET("/fine-tunes", controller.RelayNotImplemented)
 		httpRouter.GET("/fine-tunes/:id", controller.RelayNotImplemented)
@@ -179,7 +228,7 @@ func SetRelayRouter(router *gin.Engine) {
 	relaySunoRouter := router.Group("/suno")
 	relaySunoRouter.Use(middleware.RouteTag("relay"))
 	relaySunoRouter.Use(middleware.SystemPerformanceCheck())
-	relaySunoRouter.Use(middleware.TokenAuth(), middleware.Distribute())
+	relaySunoRouter.Use(middleware.RelayIPLimit(), middleware.TokenAuth(), middleware.GroupEndpointAccess(), middleware.Distribute())
 	{
 		relaySunoRouter.POST("/submit/:action", controller.RelayTask)
 		relaySunoRouter.POST("/fetch", controller.RelayTaskFetch)
@@ -189,7 +238,7 @@ func SetRelayRouter(router *gin.Engine) {
 	relayGeminiRouter := router.Group("/v1beta")
 	relayGeminiRouter.Use(middleware.RouteTag("relay"))
 	relayGeminiRouter.Use(middleware.SystemPerformanceCheck())
-	relayGeminiRouter.Use(middleware.TokenAuth())
+	relayGeminiRouter.Use(middleware.RelayIPLimit(), middleware.TokenAuth(), middleware.GroupEndpointAccess())
 	relayGeminiRouter.Use(middleware.ModelRequestRateLimit())
 	relayGeminiRouter.Use(middleware.Distribute())
 	{
@@ -202,7 +251,7 @@ func SetRelayRouter(router *gin.Engine) {
 
 func registerMjRouterGroup(relayMjRouter *gin.RouterGroup) {
 	relayMjRouter.GET("/image/:id", relay.RelayMidjourneyImage)
-	relayMjRouter.Use(middleware.TokenAuth(), middleware.Distribute())
+	relayMjRouter.Use(middleware.RelayIPLimit(), middleware.TokenAuth(), middleware.GroupEndpointAccess(), middleware.Distribute())
 	{
 		relayMjRouter.POST("/submit/action", controller.RelayMidjourney)
 		relayMjRouter.POST("/submit/shorten", controller.RelayMidjourney)
@@ -16,10 +16,10 @@ func parseAudio(audioBase64 string, format string) (duration float64, err error)
 	var sampleRate int
 
 	switch format {
-	case "pcm16":
+	case "pcm16", "audio/pcm":
 		samplesCount = len(audioData) / 2 // 16位 = 2字节每样本
 		sampleRate = 24000                // 24kHz
-	case "g711_ulaw", "g711_alaw":
+	case "g711_ulaw", "g711_alaw", "audio/pcmu", "audio/pcma":
 		samplesCount = len(audioData) // 8位 = 1字节每样本
 		sampleRate = 8000             // 8kHz
 	default:
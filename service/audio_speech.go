@@ -0,0 +1,114 @@
+package service
+
+import (
+	"fmt"
+	"time"
+	"unicode/utf8"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/constant"
+	"github.com/QuantumNous/new-api/dto"
+	"github.com/QuantumNous/new-api/logger"
+	"github.com/QuantumNous/new-api/model"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	relayconstant "github.com/QuantumNous/new-api/relay/constant"
+	"github.com/QuantumNous/new-api/setting/model_setting"
+
+	"github.com/gin-gonic/gin"
+	"github.com/shopspring/decimal"
+)
+
+// AudioSpeechPricing 返回语音合成请求的计费方式与价格，固定按次计价或未配置价格的模型返回 false
+func AudioSpeechPricing(relayInfo *relaycommon.RelayInfo) (string, float64, bool) {
+	if relayInfo.RelayMode != relayconstant.RelayModeAudioSpeech || relayInfo.PriceData.UsePrice {
+		return "", 0, false
+	}
+	return model_setting.GetAudioSpeechSettings().GetPricing(relayInfo.OriginModelName)
+}
+
+// AudioSpeechCharacters 返回语音合成请求输入文本的字符数
+func AudioSpeechCharacters(relayInfo *relaycommon.RelayInfo) int {
+	if request, ok := relayInfo.Request.(*dto.AudioRequest); ok {
+		return utf8.RuneCountInString(request.Input)
+	}
+	return 0
+}
+
+// AudioSpeechSeconds 返回本次合成的音频时长，由转发音频时解析得到，无法确定时返回 0
+func AudioSpeechSeconds(c *gin.Context) float64 {
+	if value, ok := common.GetContextKey(c, constant.ContextKeyAudioDuration); ok {
+		if seconds, ok := value.(float64); ok {
+			return seconds
+		}
+	}
+	return 0
+}
+
+// calculatePerCharacterQuota 按每百万字符价格计算语音合成额度
+func calculatePerCharacterQuota(pricePerMillion float64, groupRatio float64, characters int) int {
+	if characters <= 0 {
+		return 0
+	}
+	quota := int(decimal.NewFromFloat(pricePerMillion).
+		Mul(decimal.NewFromFloat(common.QuotaPerUnit)).
+		Mul(decimal.NewFromFloat(groupRatio)).
+		Mul(decimal.NewFromInt(int64(characters))).
+		Div(decimal.NewFromInt(1000000)).
+		Round(0).IntPart())
+	if quota <= 0 && groupRatio > 0 {
+		quota = 1
+	}
+	return quota
+}
+
+// PostAudioSpeechConsumeQuota 语音合成按输入字符数或生成音频时长结算
+func PostAudioSpeechConsumeQuota(ctx *gin.Context, relayInfo *relaycommon.RelayInfo, usage *dto.Usage, billing string, price float64, amount float64) {
+	groupRatio := relayInfo.PriceData.GroupRatioInfo.GroupRatio
+	other := map[string]interface{}{
+		"audio_speech":     true,
+		"speech_billing":   billing,
+		"group_ratio":      groupRatio,
+		"user_group_ratio": relayInfo.PriceData.GroupRatioInfo.GroupSpecialRatio,
+	}
+	var quota int
+	var content string
+	if billing == model_setting.SpeechBillingPerSecond {
+		var billedSeconds int
+		billedSeconds, quota = calculatePerMinuteQuota(price*60, groupRatio, amount)
+		other["audio_seconds"] = billedSeconds
+		other["price_per_second"] = price
+		content = fmt.Sprintf("语音合成 %d 秒，每秒价格 %.6f，分组倍率 %.2f", billedSeconds, price, groupRatio)
+	} else {
+		characters := int(amount)
+		quota = calculatePerCharacterQuota(price, groupRatio, characters)
+		other["characters"] = characters
+		other["price_per_million_characters"] = price
+		content = fmt.Sprintf("语音合成 %d 字符，每百万字符价格 %.4f，分组倍率 %.2f", characters, price, groupRatio)
+	}
+
+	if quota > 0 {
+		model.UpdateUserUsedQuotaAndRequestCount(relayInfo.UserId, quota)
+		model.UpdateChannelUsedQuota(relayInfo.ChannelId, quota)
+	}
+	if err := SettleBilling(ctx, relayInfo, quota); err != nil {
+		logger.LogError(ctx, "error settling billing: "+err.Error())
+	}
+
+	if relayInfo.UpstreamModelName != "" && relayInfo.UpstreamModelName != relayInfo.OriginModelName {
+		other["upstream_model_name"] = relayInfo.UpstreamModelName
+	}
+	model.RecordConsumeLog(ctx, relayInfo.UserId, model.RecordConsumeLogParams{
+		ChannelId:        relayInfo.ChannelId,
+		PromptTokens:     usage.PromptTokens,
+		CompletionTokens: usage.CompletionTokens,
+		ModelName:        relayInfo.OriginModelName,
+		TokenName:        ctx.GetString("token_name"),
+		Quota:            quota,
+		Content:          content,
+		TokenId:          relayInfo.TokenId,
+		UseTimeSeconds:   int(time.Since(relayInfo.StartTime).Seconds()),
+		IsStream:         relayInfo.IsStream,
+		Group:            relayInfo.UsingGroup,
+		Other:            other,
+	})
+}
@@ -0,0 +1,17 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCalculatePerCharacterQuota(t *testing.T) {
+	// 15 美元每百万字符，1000 字符为 0.015 美元
+	assert.Equal(t, int(0.015*common.QuotaPerUnit), calculatePerCharacterQuota(15, 1, 1000))
+	assert.Equal(t, int(0.03*common.QuotaPerUnit), calculatePerCharacterQuota(15, 2, 1000))
+	assert.Equal(t, 0, calculatePerCharacterQuota(15, 1, 0))
+	// 不足 1 额度时至少计 1
+	assert.Equal(t, 1, calculatePerCharacterQuota(0.01, 1, 1))
+}
@@ -0,0 +1,109 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/constant"
+	"github.com/QuantumNous/new-api/dto"
+	"github.com/QuantumNous/new-api/logger"
+	"github.com/QuantumNous/new-api/model"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	relayconstant "github.com/QuantumNous/new-api/relay/constant"
+	"github.com/QuantumNous/new-api/setting/model_setting"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AudioTranscriptionPricePerMinute 返回文件转写请求的每分钟价格，固定按次计价或未配置价格的模型返回 false
+func AudioTranscriptionPricePerMinute(relayInfo *relaycommon.RelayInfo) (float64, bool) {
+	if relayInfo.RelayMode != relayconstant.RelayModeAudioTranscription && relayInfo.RelayMode != relayconstant.RelayModeAudioTranslation {
+		return 0, false
+	}
+	if relayInfo.PriceData.UsePrice {
+		return 0, false
+	}
+	return model_setting.GetAudioTranscriptionSettings().GetPricePerMinute(relayInfo.OriginModelName)
+}
+
+// AudioTranscriptionSeconds 返回本次转写的音频时长：优先解析上传的音频文件，无法解析时使用上游返回的时长
+func AudioTranscriptionSeconds(c *gin.Context) float64 {
+	seconds, err := uploadedAudioSeconds(c)
+	if err == nil && seconds > 0 {
+		return seconds
+	}
+	if err != nil {
+		logger.LogWarn(c, fmt.Sprintf("failed to get uploaded audio duration: %v", err))
+	}
+	if value, ok := common.GetContextKey(c, constant.ContextKeyAudioDuration); ok {
+		if upstreamSeconds, ok := value.(float64); ok {
+			return upstreamSeconds
+		}
+	}
+	return 0
+}
+
+func uploadedAudioSeconds(c *gin.Context) (float64, error) {
+	formData, err := common.ParseMultipartFormReusable(c)
+	if err != nil {
+		return 0, err
+	}
+	fileHeaders := formData.File["file"]
+	if len(fileHeaders) == 0 {
+		return 0, errors.New("file is required")
+	}
+	file, err := fileHeaders[0].Open()
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+	ext := strings.ToLower(filepath.Ext(fileHeaders[0].Filename))
+	if ext == ".mpeg" || ext == ".mpga" {
+		ext = ".mp3"
+	}
+	return common.GetAudioDuration(c.Request.Context(), file, ext)
+}
+
+// PostAudioMinuteConsumeQuota 文件转写按音频时长结算，不足最少计费秒数时按最少秒数计
+func PostAudioMinuteConsumeQuota(ctx *gin.Context, relayInfo *relaycommon.RelayInfo, usage *dto.Usage, pricePerMinute float64, audioSeconds float64) {
+	groupRatio := relayInfo.PriceData.GroupRatioInfo.GroupRatio
+	seconds := max(audioSeconds, float64(model_setting.GetAudioTranscriptionSettings().MinBilledSeconds))
+	billedSeconds, quota := calculatePerMinuteQuota(pricePerMinute, groupRatio, seconds)
+
+	if quota > 0 {
+		model.UpdateUserUsedQuotaAndRequestCount(relayInfo.UserId, quota)
+		model.UpdateChannelUsedQuota(relayInfo.ChannelId, quota)
+	}
+	if err := SettleBilling(ctx, relayInfo, quota); err != nil {
+		logger.LogError(ctx, "error settling billing: "+err.Error())
+	}
+
+	other := map[string]interface{}{
+		"audio_transcription": true,
+		"audio_seconds":       billedSeconds,
+		"price_per_minute":    pricePerMinute,
+		"group_ratio":         groupRatio,
+		"user_group_ratio":    relayInfo.PriceData.GroupRatioInfo.GroupSpecialRatio,
+	}
+	if relayInfo.UpstreamModelName != "" && relayInfo.UpstreamModelName != relayInfo.OriginModelName {
+		other["upstream_model_name"] = relayInfo.UpstreamModelName
+	}
+	model.RecordConsumeLog(ctx, relayInfo.UserId, model.RecordConsumeLogParams{
+		ChannelId:        relayInfo.ChannelId,
+		PromptTokens:     usage.PromptTokens,
+		CompletionTokens: usage.CompletionTokens,
+		ModelName:        relayInfo.OriginModelName,
+		TokenName:        ctx.GetString("token_name"),
+		Quota:            quota,
+		Content:          fmt.Sprintf("音频转写 %d 秒，每分钟价格 %.4f，分组倍率 %.2f", billedSeconds, pricePerMinute, groupRatio),
+		TokenId:          relayInfo.TokenId,
+		UseTimeSeconds:   int(time.Since(relayInfo.StartTime).Seconds()),
+		IsStream:         relayInfo.IsStream,
+		Group:            relayInfo.UsingGroup,
+		Other:            other,
+	})
+}
@@ -0,0 +1,567 @@
+package service
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/constant"
+	"github.com/QuantumNous/new-api/dto"
+	"github.com/QuantumNous/new-api/logger"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/relay/channel/task/taskcommon"
+	"github.com/QuantumNous/new-api/types"
+
+	"github.com/bytedance/gopkg/util/gopool"
+)
+
+// Batch API（/v1/batches）代理：输入文件为网关文件（purpose=batch），校验后按渠道模型映射改写每行的模型，
+// OpenAI 渠道上传到上游并创建上游 Batch，由任务轮询同步状态，完成后将输出文件保存为网关文件并按其中的 usage 结算；
+// 其他渠道由网关逐行按普通请求执行（模拟模式），每个请求单独选择渠道与计费
+
+const (
+	BatchInputFilePurpose  = "batch"
+	BatchOutputFilePurpose = "batch_output"
+
+	// 模拟模式的进度写回间隔
+	batchEmulationFlushInterval = 5 * time.Second
+	// 取消后仍未结束的模拟 Batch（执行节点已退出）超过该时长由轮询直接结束
+	batchEmulationCancelGrace = 60
+)
+
+// BatchApiEndpoints 支持的 Batch 端点及其对应的转发格式
+var BatchApiEndpoints = map[string]types.RelayFormat{
+	"/v1/chat/completions": types.RelayFormatOpenAI,
+	"/v1/completions":      types.RelayFormatOpenAI,
+	"/v1/embeddings":       types.RelayFormatEmbedding,
+	"/v1/responses":        types.RelayFormatOpenAIResponses,
+}
+
+// ParseBatchInput 校验 Batch 输入文件：每行须有唯一的 custom_id、method 为 POST、url 与 Batch 端点一致，
+// 所有请求使用同一模型且不能流式输出；返回解析后的请求行与模型名称
+func ParseBatchInput(content []byte, endpoint string, maxRequests int) ([]*dto.OpenAIBatchInputLine, string, error) {
+	lines := make([]*dto.OpenAIBatchInputLine, 0)
+	customIds := make(map[string]bool)
+	modelName := ""
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	scanner.Buffer(make([]byte, 0, 64*1024), 64*1024*1024)
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		text := bytes.TrimSpace(scanner.Bytes())
+		if len(text) == 0 {
+			continue
+		}
+		var line dto.OpenAIBatchInputLine
+		if err := common.Unmarshal(text, &line); err != nil {
+			return nil, "", fmt.Errorf("line %d: invalid json: %w", lineNumber, err)
+		}
+		if line.CustomId == "" {
+			return nil, "", fmt.Errorf("line %d: custom_id is required", lineNumber)
+		}
+		if customIds[line.CustomId] {
+			return nil, "", fmt.Errorf("line %d: duplicate custom_id %q", lineNumber, line.CustomId)
+		}
+		customIds[line.CustomId] = true
+		if line.Method != http.MethodPost {
+			return nil, "", fmt.Errorf("line %d: method must be POST", lineNumber)
+		}
+		if line.Url != endpoint {
+			return nil, "", fmt.Errorf("line %d: url %q does not match the batch endpoint %s", lineNumber, line.Url, endpoint)
+		}
+		var body struct {
+			Model  string `json:"model"`
+			Stream bool   `json:"stream"`
+		}
+		if err := common.Unmarshal(line.Body, &body); err != nil {
+			return nil, "", fmt.Errorf("line %d: body must be a json object", lineNumber)
+		}
+		if body.Model == "" {
+			return nil, "", fmt.Errorf("line %d: body.model is required", lineNumber)
+		}
+		if modelName == "" {
+			modelName = body.Model
+		} else if body.Model != modelName {
+			return nil, "", fmt.Errorf("line %d: all requests in a batch must use the same model, got %q and %q", lineNumber, modelName, body.Model)
+		}
+		if body.Stream {
+			return nil, "", fmt.Errorf("line %d: stream is not supported in batch requests", lineNumber)
+		}
+		lines = append(lines, &line)
+		if maxRequests > 0 && len(lines) > maxRequests {
+			return nil, "", fmt.Errorf("batch input contains more than %d requests", maxRequests)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, "", err
+	}
+	if len(lines) == 0 {
+		return nil, "", errors.New("batch input file contains no requests")
+	}
+	return lines, modelName, nil
+}
+
+// RewriteBatchInputModel 将每行请求体中的模型替换为上游模型，生成提交到上游的输入文件
+func RewriteBatchInputModel(lines []*dto.OpenAIBatchInputLine, upstreamModel string) ([]byte, error) {
+	modelValue, err := common.Marshal(upstreamModel)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	for _, line := range lines {
+		var body map[string]json.RawMessage
+		if err = common.Unmarshal(line.Body, &body); err != nil {
+			return nil, err
+		}
+		body["model"] = modelValue
+		rewritten, err := common.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		data, err := common.Marshal(dto.OpenAIBatchInputLine{
+			CustomId: line.CustomId,
+			Method:   line.Method,
+			Url:      line.Url,
+			Body:     rewritten,
+		})
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(data)
+		buf.WriteByte('\n')
+	}
+	return buf.Bytes(), nil
+}
+
+// BatchUsesUpstream 判断渠道是否直接使用上游 Batch API，其他渠道使用模拟模式
+func BatchUsesUpstream(channelType int) bool {
+	return channelType == constant.ChannelTypeOpenAI
+}
+
+// CreateUpstreamBatch 上传改写后的输入文件并在渠道上创建上游 Batch
+func CreateUpstreamBatch(ctx context.Context, channel *model.Channel, key string, input []byte, request dto.OpenAIBatchCreateRequest) (*dto.OpenAIBatch, error) {
+	upstream, err := newBatchUpstream(channel, key)
+	if err != nil {
+		return nil, err
+	}
+	return upstream.createBatch(ctx, input, request)
+}
+
+// batchLineUsage 解析输出行响应体中的 usage，Responses 格式的 input/output tokens 转换为 prompt/completion tokens
+func batchLineUsage(body []byte) *dto.Usage {
+	var response struct {
+		Usage *dto.Usage `json:"usage"`
+	}
+	if err := common.Unmarshal(body, &response); err != nil || response.Usage == nil {
+		return nil
+	}
+	usage := response.Usage
+	if usage.PromptTokens == 0 && usage.CompletionTokens == 0 && (usage.InputTokens > 0 || usage.OutputTokens > 0) {
+		usage.PromptTokens = usage.InputTokens
+		usage.CompletionTokens = usage.OutputTokens
+		if usage.InputTokensDetails != nil {
+			usage.PromptTokensDetails.CachedTokens = usage.InputTokensDetails.CachedTokens
+		}
+	}
+	return usage
+}
+
+// batchOutputQuota 按计费快照汇总输出文件中成功请求的额度，返回额度与成功请求数
+func batchOutputQuota(bc *model.TaskBillingContext, output []byte) (int, int) {
+	quota := 0
+	succeeded := 0
+	for _, line := range parseBatchOutput(output) {
+		if line.Response == nil || line.Response.StatusCode/100 != 2 {
+			continue
+		}
+		succeeded++
+		quota += batchRequestQuota(bc, batchLineUsage(line.Response.Body))
+	}
+	return quota, succeeded
+}
+
+// saveBatchOutputFile 将 Batch 的输出或错误文件保存为用户的网关文件，返回文件 ID
+func saveBatchOutputFile(task *model.Task, suffix string, content []byte) (string, error) {
+	if len(content) == 0 {
+		return "", nil
+	}
+	file := &model.File{
+		UserId:   task.UserId,
+		Filename: fmt.Sprintf("%s_%s.jsonl", task.TaskID, suffix),
+		Purpose:  BatchOutputFilePurpose,
+	}
+	if err := SaveGatewayFile(file, bytes.NewReader(content), int64(len(content))); err != nil {
+		return "", err
+	}
+	return file.Id, nil
+}
+
+// UpdateBatchApiTasks 由任务轮询循环调用：同步上游 Batch 的状态，结束后保存结果文件并结算；
+// 模拟模式的 Batch 由执行节点自行推进，这里只结束执行节点已退出而过期或已取消的 Batch
+func UpdateBatchApiTasks(ctx context.Context, tasks []*model.Task) {
+	for _, task := range tasks {
+		switch task.Action {
+		case constant.TaskActionBatchUpstream:
+			pollUpstreamBatchApiTask(ctx, task)
+		case constant.TaskActionBatchEmulated:
+			sweepEmulatedBatchTask(ctx, task)
+		}
+	}
+}
+
+func pollUpstreamBatchApiTask(ctx context.Context, task *model.Task) {
+	channel, err := model.CacheGetChannel(task.ChannelId)
+	if err != nil {
+		logger.LogError(ctx, fmt.Sprintf("获取 Batch %s 的渠道失败: %s", task.TaskID, err.Error()))
+		return
+	}
+	upstream, err := newBatchUpstream(channel, task.PrivateData.Key)
+	if err != nil {
+		logger.LogError(ctx, fmt.Sprintf("渠道 #%d 创建 Batch 客户端失败: %s", channel.Id, err.Error()))
+		return
+	}
+	upstreamBatch, err := upstream.getBatch(ctx, task.PrivateData.UpstreamTaskID)
+	if err != nil {
+		logger.LogError(ctx, fmt.Sprintf("查询上游 Batch %s 失败: %s", task.PrivateData.UpstreamTaskID, err.Error()))
+		return
+	}
+
+	batch := task.ToOpenAIBatch()
+	batch.Status = upstreamBatch.Status
+	batch.Errors = upstreamBatch.Errors
+	batch.RequestCounts = upstreamBatch.RequestCounts
+	batch.InProgressAt = upstreamBatch.InProgressAt
+	batch.CompletedAt = upstreamBatch.CompletedAt
+	batch.FailedAt = upstreamBatch.FailedAt
+	batch.ExpiredAt = upstreamBatch.ExpiredAt
+	batch.CancellingAt = upstreamBatch.CancellingAt
+	batch.CancelledAt = upstreamBatch.CancelledAt
+	oldStatus := task.Status
+
+	if !upstreamBatch.IsTerminal() {
+		task.Status = model.TaskStatusInProgress
+		task.Progress = batchProgress(upstreamBatch)
+		task.SetData(batch)
+		if _, err := task.UpdateWithStatus(oldStatus); err != nil {
+			logger.LogError(ctx, fmt.Sprintf("UpdateWithStatus failed for batch %s: %s", task.TaskID, err.Error()))
+		}
+		return
+	}
+
+	// 过期或取消的 Batch 仍可能包含部分结果，同样保存并结算
+	var output []byte
+	for _, file := range []struct {
+		upstreamId string
+		suffix     string
+		target     *string
+	}{
+		{upstreamBatch.OutputFileId, "output", &batch.OutputFileId},
+		{upstreamBatch.ErrorFileId, "error", &batch.ErrorFileId},
+	} {
+		if file.upstreamId == "" {
+			continue
+		}
+		content, err := upstream.fileContent(ctx, file.upstreamId)
+		if err != nil {
+			logger.LogError(ctx, fmt.Sprintf("下载上游 Batch %s 结果文件 %s 失败: %s", upstreamBatch.ID, file.upstreamId, err.Error()))
+			return
+		}
+		if *file.target, err = saveBatchOutputFile(task, file.suffix, content); err != nil {
+			logger.LogError(ctx, fmt.Sprintf("保存 Batch %s 结果文件失败: %s", task.TaskID, err.Error()))
+			return
+		}
+		if file.suffix == "output" {
+			output = content
+		}
+	}
+	quota, succeeded := batchOutputQuota(task.PrivateData.BillingContext, output)
+
+	task.Progress = taskcommon.ProgressComplete
+	task.FinishTime = time.Now().Unix()
+	if batch.Status == dto.OpenAIBatchStatusCompleted {
+		task.Status = model.TaskStatusSuccess
+	} else {
+		task.Status = model.TaskStatusFailure
+		task.FailReason = batchFailReason(upstreamBatch)
+	}
+	task.SetData(batch)
+	won, err := task.UpdateWithStatus(oldStatus)
+	if err != nil {
+		logger.LogError(ctx, fmt.Sprintf("UpdateWithStatus failed for batch %s: %s", task.TaskID, err.Error()))
+		return
+	}
+	if !won {
+		logger.LogWarn(ctx, fmt.Sprintf("Batch %s already transitioned by another process, skip billing", task.TaskID))
+		return
+	}
+	if quota > 0 {
+		RecalculateTaskQuota(ctx, task, quota, fmt.Sprintf("Batch 用量结算：%d 个成功请求", succeeded))
+	}
+}
+
+// CancelBatchTask 取消 Batch：上游 Batch 转发取消请求，由轮询同步最终状态；模拟模式的 Batch 停止派发剩余请求
+func CancelBatchTask(ctx context.Context, task *model.Task) (*dto.OpenAIBatch, error) {
+	batch := task.ToOpenAIBatch()
+	if batch.IsTerminal() || batch.Status == dto.OpenAIBatchStatusCancelling {
+		return nil, fmt.Errorf("cannot cancel a batch with status %s", batch.Status)
+	}
+	if task.Action == constant.TaskActionBatchUpstream {
+		channel, err := model.CacheGetChannel(task.ChannelId)
+		if err != nil {
+			return nil, err
+		}
+		upstream, err := newBatchUpstream(channel, task.PrivateData.Key)
+		if err != nil {
+			return nil, err
+		}
+		if _, err = upstream.cancelBatch(ctx, task.PrivateData.UpstreamTaskID); err != nil {
+			return nil, err
+		}
+	}
+	batch.Status = dto.OpenAIBatchStatusCancelling
+	batch.CancellingAt = time.Now().Unix()
+	task.SetData(batch)
+	won, err := task.UpdateWithStatus(task.Status)
+	if err != nil {
+		return nil, err
+	}
+	if !won {
+		return nil, errors.New("batch status changed, please retry")
+	}
+	if cancel, ok := emulatedBatchCancels.Load(task.TaskID); ok {
+		cancel.(context.CancelFunc)()
+	}
+	return batch, nil
+}
+
+// emulatedBatchCancels 批次 ID -> 本节点执行中模拟 Batch 的 context.CancelFunc
+var emulatedBatchCancels sync.Map
+
+// BatchLineExecutor 按普通请求执行 Batch 的一行，返回响应状态码与响应体
+type BatchLineExecutor func(ctx context.Context, index int, line *dto.OpenAIBatchInputLine) (int, []byte)
+
+type batchLineResult struct {
+	done       bool
+	statusCode int
+	body       []byte
+}
+
+// RunEmulatedBatch 在本节点逐行执行模拟模式的 Batch，同时执行的请求数为 concurrency；
+// 到达 expires_at 或被取消后不再派发新请求，已完成的请求写入输出与错误文件
+func RunEmulatedBatch(ctx context.Context, task *model.Task, lines []*dto.OpenAIBatchInputLine, concurrency int, execute BatchLineExecutor) {
+	batch := task.ToOpenAIBatch()
+	ctx, cancel := context.WithDeadline(ctx, time.Unix(batch.ExpiresAt, 0))
+	defer cancel()
+	emulatedBatchCancels.Store(task.TaskID, context.CancelFunc(cancel))
+	defer emulatedBatchCancels.Delete(task.TaskID)
+
+	now := time.Now().Unix()
+	batch.Status = dto.OpenAIBatchStatusInProgress
+	batch.InProgressAt = now
+	batch.RequestCounts = dto.OpenAIBatchRequestCounts{Total: len(lines)}
+	task.Status = model.TaskStatusInProgress
+	task.Progress = taskcommon.ProgressInProgress
+	task.StartTime = now
+	task.SetData(batch)
+	if won, err := task.UpdateWithStatus(model.TaskStatusQueued); err != nil || !won {
+		logger.LogError(ctx, fmt.Sprintf("start emulated batch %s failed: %v", task.TaskID, err))
+		return
+	}
+
+	var completed, failed atomic.Int64
+	results := make([]batchLineResult, len(lines))
+	var lock sync.Mutex
+	// 取消请求可能由其他节点处理，只写入数据库中的 Batch 状态
+	syncCancelling := func() {
+		if batch.Status == dto.OpenAIBatchStatusCancelling {
+			return
+		}
+		if current, exist, err := model.GetByOnlyTaskId(task.TaskID); err == nil && exist {
+			if currentBatch := current.ToOpenAIBatch(); currentBatch.Status == dto.OpenAIBatchStatusCancelling {
+				batch.Status = currentBatch.Status
+				batch.CancellingAt = currentBatch.CancellingAt
+				cancel()
+			}
+		}
+	}
+	flush := func() bool {
+		lock.Lock()
+		defer lock.Unlock()
+		syncCancelling()
+		batch.RequestCounts.Completed = int(completed.Load())
+		batch.RequestCounts.Failed = int(failed.Load())
+		task.Progress = batchProgress(batch)
+		task.SetData(batch)
+		won, err := task.UpdateWithStatus(model.TaskStatusInProgress)
+		if err != nil {
+			logger.LogError(ctx, fmt.Sprintf("UpdateWithStatus failed for batch %s: %s", task.TaskID, err.Error()))
+			return true
+		}
+		return won
+	}
+
+	stopFlush := make(chan struct{})
+	flushDone := make(chan struct{})
+	gopool.Go(func() {
+		defer close(flushDone)
+		ticker := time.NewTicker(batchEmulationFlushInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stopFlush:
+				return
+			case <-ticker.C:
+				// 任务已被超时清理等方式结束时停止执行
+				if !flush() {
+					cancel()
+				}
+			}
+		}
+	})
+
+	var wg sync.WaitGroup
+	slots := make(chan struct{}, max(1, concurrency))
+dispatch:
+	for i, line := range lines {
+		select {
+		case <-ctx.Done():
+			break dispatch
+		case slots <- struct{}{}:
+		}
+		wg.Add(1)
+		gopool.Go(func() {
+			defer wg.Done()
+			defer func() { <-slots }()
+			statusCode, body := execute(ctx, i, line)
+			results[i] = batchLineResult{done: true, statusCode: statusCode, body: body}
+			if statusCode/100 == 2 {
+				completed.Add(1)
+			} else {
+				failed.Add(1)
+			}
+		})
+	}
+	wg.Wait()
+	close(stopFlush)
+	<-flushDone
+	syncCancelling()
+
+	finishEmulatedBatch(ctx, task, batch, lines, results, errors.Is(ctx.Err(), context.DeadlineExceeded))
+}
+
+// finishEmulatedBatch 写出输出与错误文件并结束模拟 Batch，请求已按普通请求各自计费，这里不再结算
+func finishEmulatedBatch(ctx context.Context, task *model.Task, batch *dto.OpenAIBatch, lines []*dto.OpenAIBatchInputLine, results []batchLineResult, expired bool) {
+	var output, errorOutput bytes.Buffer
+	for i, result := range results {
+		if !result.done {
+			continue
+		}
+		data, err := common.Marshal(dto.OpenAIBatchOutputLine{
+			ID:       "batch_req_" + common.GetRandomString(24),
+			CustomId: lines[i].CustomId,
+			Response: &dto.OpenAIBatchOutputResponse{
+				StatusCode: result.statusCode,
+				RequestId:  fmt.Sprintf("%s-%d", task.TaskID, i),
+				Body:       batchResponseBody(result.body),
+			},
+		})
+		if err != nil {
+			continue
+		}
+		target := &output
+		if result.statusCode/100 != 2 {
+			target = &errorOutput
+		}
+		target.Write(data)
+		target.WriteByte('\n')
+	}
+
+	var err error
+	if batch.OutputFileId, err = saveBatchOutputFile(task, "output", output.Bytes()); err != nil {
+		logger.LogError(ctx, fmt.Sprintf("保存 Batch %s 结果文件失败: %s", task.TaskID, err.Error()))
+	}
+	if batch.ErrorFileId, err = saveBatchOutputFile(task, "error", errorOutput.Bytes()); err != nil {
+		logger.LogError(ctx, fmt.Sprintf("保存 Batch %s 错误文件失败: %s", task.TaskID, err.Error()))
+	}
+
+	now := time.Now().Unix()
+	task.Progress = taskcommon.ProgressComplete
+	task.FinishTime = now
+	switch {
+	case batch.Status == dto.OpenAIBatchStatusCancelling:
+		batch.Status = dto.OpenAIBatchStatusCancelled
+		batch.CancelledAt = now
+	case expired:
+		batch.Status = dto.OpenAIBatchStatusExpired
+		batch.ExpiredAt = now
+	default:
+		batch.Status = dto.OpenAIBatchStatusCompleted
+		batch.CompletedAt = now
+	}
+	if batch.Status == dto.OpenAIBatchStatusCompleted {
+		task.Status = model.TaskStatusSuccess
+	} else {
+		task.Status = model.TaskStatusFailure
+		task.FailReason = "batch " + batch.Status
+	}
+	batch.RequestCounts.Completed, batch.RequestCounts.Failed = 0, 0
+	for _, result := range results {
+		if !result.done {
+			continue
+		}
+		if result.statusCode/100 == 2 {
+			batch.RequestCounts.Completed++
+		} else {
+			batch.RequestCounts.Failed++
+		}
+	}
+	task.SetData(batch)
+	if _, err := task.UpdateWithStatus(model.TaskStatusInProgress); err != nil {
+		logger.LogError(ctx, fmt.Sprintf("UpdateWithStatus failed for batch %s: %s", task.TaskID, err.Error()))
+	}
+}
+
+// batchResponseBody 响应体不是 JSON 时（如网关返回的纯文本错误）包装为 JSON 字符串
+func batchResponseBody(body []byte) json.RawMessage {
+	if common.ValidJson(body) {
+		return body
+	}
+	wrapped, _ := common.Marshal(string(body))
+	return wrapped
+}
+
+// sweepEmulatedBatchTask 结束执行节点已退出的模拟 Batch：已过期，或已取消且超过宽限时间仍未结束
+func sweepEmulatedBatchTask(ctx context.Context, task *model.Task) {
+	if _, running := emulatedBatchCancels.Load(task.TaskID); running {
+		return
+	}
+	batch := task.ToOpenAIBatch()
+	now := time.Now().Unix()
+	switch {
+	case batch.Status == dto.OpenAIBatchStatusCancelling && now-batch.CancellingAt > batchEmulationCancelGrace:
+		batch.Status = dto.OpenAIBatchStatusCancelled
+		batch.CancelledAt = now
+	case batch.ExpiresAt > 0 && now > batch.ExpiresAt:
+		batch.Status = dto.OpenAIBatchStatusExpired
+		batch.ExpiredAt = now
+	default:
+		return
+	}
+	oldStatus := task.Status
+	task.Status = model.TaskStatusFailure
+	task.Progress = taskcommon.ProgressComplete
+	task.FinishTime = now
+	task.FailReason = "batch " + batch.Status
+	task.SetData(batch)
+	if _, err := task.UpdateWithStatus(oldStatus); err != nil {
+		logger.LogError(ctx, fmt.Sprintf("UpdateWithStatus failed for batch %s: %s", task.TaskID, err.Error()))
+	}
+}
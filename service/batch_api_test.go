@@ -0,0 +1,93 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseBatchInput(t *testing.T) {
+	input := `{"custom_id":"a","method":"POST","url":"/v1/chat/completions","body":{"model":"gpt-4o-mini","messages":[]}}
+
+{"custom_id":"b","method":"POST","url":"/v1/chat/completions","body":{"model":"gpt-4o-mini","messages":[]}}
+`
+	lines, modelName, err := ParseBatchInput([]byte(input), "/v1/chat/completions", 10)
+	require.NoError(t, err)
+	require.Len(t, lines, 2)
+	require.Equal(t, "gpt-4o-mini", modelName)
+	require.Equal(t, "b", lines[1].CustomId)
+
+	for name, tc := range map[string]struct {
+		input string
+		err   string
+	}{
+		"duplicate custom_id": {
+			input: `{"custom_id":"a","method":"POST","url":"/v1/chat/completions","body":{"model":"m"}}` + "\n" +
+				`{"custom_id":"a","method":"POST","url":"/v1/chat/completions","body":{"model":"m"}}`,
+			err: "duplicate custom_id",
+		},
+		"endpoint mismatch": {
+			input: `{"custom_id":"a","method":"POST","url":"/v1/embeddings","body":{"model":"m"}}`,
+			err:   "does not match",
+		},
+		"mixed models": {
+			input: `{"custom_id":"a","method":"POST","url":"/v1/chat/completions","body":{"model":"m1"}}` + "\n" +
+				`{"custom_id":"b","method":"POST","url":"/v1/chat/completions","body":{"model":"m2"}}`,
+			err: "same model",
+		},
+		"stream": {
+			input: `{"custom_id":"a","method":"POST","url":"/v1/chat/completions","body":{"model":"m","stream":true}}`,
+			err:   "stream is not supported",
+		},
+		"too many requests": {
+			input: `{"custom_id":"a","method":"POST","url":"/v1/chat/completions","body":{"model":"m"}}` + "\n" +
+				`{"custom_id":"b","method":"POST","url":"/v1/chat/completions","body":{"model":"m"}}`,
+			err: "more than 1 requests",
+		},
+		"empty": {input: "\n\n", err: "no requests"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, _, err := ParseBatchInput([]byte(tc.input), "/v1/chat/completions", 1)
+			require.ErrorContains(t, err, tc.err)
+		})
+	}
+}
+
+func TestRewriteBatchInputModel(t *testing.T) {
+	lines, _, err := ParseBatchInput([]byte(`{"custom_id":"a","method":"POST","url":"/v1/embeddings","body":{"model":"alias","input":"hi"}}`), "/v1/embeddings", 0)
+	require.NoError(t, err)
+	output, err := RewriteBatchInputModel(lines, "text-embedding-3-small")
+	require.NoError(t, err)
+
+	rewritten, _, err := ParseBatchInput(output, "/v1/embeddings", 0)
+	require.NoError(t, err)
+	require.Equal(t, "a", rewritten[0].CustomId)
+	var body map[string]any
+	require.NoError(t, common.Unmarshal(rewritten[0].Body, &body))
+	require.Equal(t, "text-embedding-3-small", body["model"])
+	require.Equal(t, "hi", body["input"])
+}
+
+func TestBatchOutputQuota(t *testing.T) {
+	bc := &model.TaskBillingContext{
+		ModelRatio:      2,
+		CompletionRatio: 4,
+		CacheRatio:      0.5,
+		GroupRatio:      1,
+		OtherRatios:     map[string]float64{"batch": 0.5},
+	}
+	output := `{"id":"r1","custom_id":"a","response":{"status_code":200,"body":{"usage":{"prompt_tokens":100,"completion_tokens":10}}}}
+{"id":"r2","custom_id":"b","response":{"status_code":200,"body":{"usage":{"input_tokens":100,"output_tokens":10,"input_tokens_details":{"cached_tokens":40}}}}}
+{"id":"r3","custom_id":"c","response":{"status_code":400,"body":{"error":{"message":"bad"}}}}
+`
+	quota, succeeded := batchOutputQuota(bc, []byte(output))
+	require.Equal(t, 2, succeeded)
+	// (100 + 10*4) * 2 * 0.5 + (60 + 40*0.5 + 10*4) * 2 * 0.5
+	require.Equal(t, 140+120, quota)
+
+	quota, succeeded = batchOutputQuota(bc, nil)
+	require.Equal(t, 0, quota)
+	require.Equal(t, 0, succeeded)
+}
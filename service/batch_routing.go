@@ -0,0 +1,564 @@
+package service
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"mime/multipart"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/constant"
+	"github.com/QuantumNous/new-api/dto"
+	"github.com/QuantumNous/new-api/logger"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/relay/channel/task/taskcommon"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	relayconstant "github.com/QuantumNous/new-api/relay/constant"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+	"github.com/QuantumNous/new-api/types"
+
+	"github.com/bytedance/gopkg/util/gopool"
+	"github.com/gin-gonic/gin"
+)
+
+const batchChatCompletionsEndpoint = "/v1/chat/completions"
+
+// ShouldRouteToBatch 判断请求是否转入上游 Batch API：令牌开启了 Batch 模式、
+// 请求为非流式 Chat Completions、已分发到 OpenAI 渠道且模型在配置范围内
+func ShouldRouteToBatch(c *gin.Context, info *relaycommon.RelayInfo) bool {
+	if info == nil || info.IsPlayground || info.IsStream {
+		return false
+	}
+	if info.RelayFormat != types.RelayFormatOpenAI || info.RelayMode != relayconstant.RelayModeChatCompletions {
+		return false
+	}
+	if !common.GetContextKeyBool(c, constant.ContextKeyTokenBatchMode) {
+		return false
+	}
+	if common.GetContextKeyInt(c, constant.ContextKeyChannelType) != constant.ChannelTypeOpenAI {
+		return false
+	}
+	return operation_setting.ShouldRouteModelToBatch(info.OriginModelName)
+}
+
+// SubmitBatchRequest 将已生成的上游请求体排入 Batch 队列，按 Batch 倍率结算预扣费，
+// 实际用量在上游 Batch 完成后差额结算
+func SubmitBatchRequest(c *gin.Context, info *relaycommon.RelayInfo, body []byte) (*model.Task, *types.NewAPIError) {
+	billingRatio := operation_setting.GetBatchRoutingSetting().BillingRatio
+	if billingRatio <= 0 {
+		billingRatio = 1
+	}
+	info.PriceData.AddOtherRatio("batch", billingRatio)
+	info.PriceData.Quota = int(float64(info.PriceData.QuotaToPreConsume) * billingRatio)
+	info.TaskRelayInfo = &relaycommon.TaskRelayInfo{Action: constant.TaskActionBatchChatCompletions}
+
+	task := model.InitTask(constant.TaskPlatformBatch, info)
+	task.Status = model.TaskStatusQueued
+	task.Action = constant.TaskActionBatchChatCompletions
+	task.Quota = info.PriceData.Quota
+	task.Data = body
+	task.PrivateData.BillingSource = info.BillingSource
+	task.PrivateData.SubscriptionId = info.SubscriptionId
+	task.PrivateData.TokenId = info.TokenId
+	task.PrivateData.BillingContext = &model.TaskBillingContext{
+		ModelPrice:      info.PriceData.ModelPrice,
+		GroupRatio:      info.PriceData.GroupRatioInfo.GroupRatio,
+		ModelRatio:      info.PriceData.ModelRatio,
+		CompletionRatio: info.PriceData.CompletionRatio,
+		CacheRatio:      info.PriceData.CacheRatio,
+		OtherRatios:     info.PriceData.OtherRatios,
+		OriginModelName: info.OriginModelName,
+		PerCallBilling:  info.PriceData.UsePrice,
+	}
+	if err := task.Insert(); err != nil {
+		return nil, types.NewError(err, types.ErrorCodeQueryDataError, types.ErrOptionWithSkipRetry())
+	}
+
+	if err := SettleBilling(c, info, task.Quota); err != nil {
+		common.SysError("settle batch request billing error: " + err.Error())
+	}
+	LogTaskConsumption(c, info)
+	return task, nil
+}
+
+// UpdateBatchTasks 由任务轮询循环调用：将等待超过聚合窗口（或已攒满）的请求按渠道提交为上游 Batch，
+// 并轮询已提交的 Batch，完成后回填结果、差额结算并推送令牌 webhook
+func UpdateBatchTasks(ctx context.Context, tasks []*model.Task) {
+	setting := operation_setting.GetBatchRoutingSetting()
+	maxBatchSize := max(1, setting.MaxBatchSize)
+	now := time.Now().Unix()
+
+	queued := make(map[int][]*model.Task)
+	submitted := make(map[string][]*model.Task)
+	for _, task := range tasks {
+		switch task.Status {
+		case model.TaskStatusQueued:
+			queued[task.ChannelId] = append(queued[task.ChannelId], task)
+		case model.TaskStatusSubmitted, model.TaskStatusInProgress:
+			if batchId := task.PrivateData.UpstreamTaskID; batchId != "" {
+				submitted[batchId] = append(submitted[batchId], task)
+			}
+		}
+	}
+
+	// 任务按 id 升序返回，每组第一个即最早入队的请求
+	for channelId, channelTasks := range queued {
+		for len(channelTasks) > 0 {
+			size := min(len(channelTasks), maxBatchSize)
+			if size < maxBatchSize && now-channelTasks[0].SubmitTime < int64(setting.WindowSeconds) {
+				break
+			}
+			submitBatchTasks(ctx, channelId, channelTasks[:size])
+			channelTasks = channelTasks[size:]
+		}
+	}
+	for batchId, batchTasks := range submitted {
+		pollBatchTasks(ctx, batchId, batchTasks)
+	}
+}
+
+func submitBatchTasks(ctx context.Context, channelId int, tasks []*model.Task) {
+	setting := operation_setting.GetBatchRoutingSetting()
+	channel, err := model.CacheGetChannel(channelId)
+	if err != nil {
+		failBatchTasks(ctx, tasks, fmt.Sprintf("渠道 #%d 不可用：%s", channelId, err.Error()))
+		return
+	}
+	key, _, apiErr := channel.GetNextEnabledKey()
+	if apiErr != nil {
+		failBatchTasks(ctx, tasks, fmt.Sprintf("渠道 #%d 无可用密钥：%s", channelId, apiErr.Error()))
+		return
+	}
+	upstream, err := newBatchUpstream(channel, key)
+	if err != nil {
+		logger.LogError(ctx, fmt.Sprintf("渠道 #%d 创建 Batch 客户端失败: %s", channelId, err.Error()))
+		return
+	}
+
+	input, err := buildBatchInputFile(tasks)
+	if err != nil {
+		failBatchTasks(ctx, tasks, "构建 Batch 输入文件失败："+err.Error())
+		return
+	}
+	batch, err := upstream.createBatch(ctx, input, dto.OpenAIBatchCreateRequest{
+		Endpoint:         batchChatCompletionsEndpoint,
+		CompletionWindow: setting.CompletionWindow,
+	})
+	if err != nil {
+		// 4xx 说明请求本身不被上游接受，重试无意义；其他错误留到下个周期重新提交
+		if upstreamErr, ok := err.(*batchUpstreamError); ok && upstreamErr.StatusCode/100 == 4 {
+			failBatchTasks(ctx, tasks, "提交上游 Batch 失败："+err.Error())
+			return
+		}
+		logger.LogError(ctx, fmt.Sprintf("渠道 #%d 提交上游 Batch 失败，下个周期重试: %s", channelId, err.Error()))
+		return
+	}
+
+	now := time.Now().Unix()
+	for _, task := range tasks {
+		task.Status = model.TaskStatusSubmitted
+		task.Progress = taskcommon.ProgressSubmitted
+		task.StartTime = now
+		task.PrivateData.UpstreamTaskID = batch.ID
+		task.PrivateData.Key = key
+		if _, err := task.UpdateWithStatus(model.TaskStatusQueued); err != nil {
+			logger.LogError(ctx, fmt.Sprintf("UpdateWithStatus failed for batch task %s: %s", task.TaskID, err.Error()))
+		}
+	}
+	logger.LogInfo(ctx, fmt.Sprintf("渠道 #%d 提交上游 Batch %s，包含 %d 个请求", channelId, batch.ID, len(tasks)))
+}
+
+func pollBatchTasks(ctx context.Context, batchId string, tasks []*model.Task) {
+	channel, err := model.CacheGetChannel(tasks[0].ChannelId)
+	if err != nil {
+		logger.LogError(ctx, fmt.Sprintf("获取 Batch %s 的渠道失败: %s", batchId, err.Error()))
+		return
+	}
+	key := tasks[0].PrivateData.Key
+	if key == "" {
+		var apiErr *types.NewAPIError
+		if key, _, apiErr = channel.GetNextEnabledKey(); apiErr != nil {
+			logger.LogError(ctx, fmt.Sprintf("获取 Batch %s 的渠道密钥失败: %s", batchId, apiErr.Error()))
+			return
+		}
+	}
+	upstream, err := newBatchUpstream(channel, key)
+	if err != nil {
+		logger.LogError(ctx, fmt.Sprintf("渠道 #%d 创建 Batch 客户端失败: %s", channel.Id, err.Error()))
+		return
+	}
+	batch, err := upstream.getBatch(ctx, batchId)
+	if err != nil {
+		logger.LogError(ctx, fmt.Sprintf("查询上游 Batch %s 失败: %s", batchId, err.Error()))
+		return
+	}
+
+	switch batch.Status {
+	case "completed", "expired", "cancelled", "failed":
+	default:
+		progress := batchProgress(batch)
+		for _, task := range tasks {
+			if task.Status == model.TaskStatusInProgress && task.Progress == progress {
+				continue
+			}
+			oldStatus := task.Status
+			task.Status = model.TaskStatusInProgress
+			task.Progress = progress
+			if _, err := task.UpdateWithStatus(oldStatus); err != nil {
+				logger.LogError(ctx, fmt.Sprintf("UpdateWithStatus failed for batch task %s: %s", task.TaskID, err.Error()))
+			}
+		}
+		return
+	}
+
+	results := make(map[string]*dto.OpenAIBatchOutputLine, len(tasks))
+	for _, fileId := range []string{batch.OutputFileId, batch.ErrorFileId} {
+		if fileId == "" {
+			continue
+		}
+		content, err := upstream.fileContent(ctx, fileId)
+		if err != nil {
+			logger.LogError(ctx, fmt.Sprintf("下载上游 Batch %s 结果文件 %s 失败: %s", batchId, fileId, err.Error()))
+			return
+		}
+		for customId, line := range parseBatchOutput(content) {
+			results[customId] = line
+		}
+	}
+	reason := batchFailReason(batch)
+	for _, task := range tasks {
+		finishBatchTask(ctx, task, results[task.TaskID], reason)
+	}
+}
+
+// finishBatchTask 回填单个请求的结果：成功时按实际 usage 差额结算，失败时退还预扣额度
+func finishBatchTask(ctx context.Context, task *model.Task, line *dto.OpenAIBatchOutputLine, reason string) {
+	oldStatus := task.Status
+	task.Progress = taskcommon.ProgressComplete
+	task.FinishTime = time.Now().Unix()
+
+	var usage *dto.Usage
+	if line != nil && line.Response != nil && line.Response.StatusCode/100 == 2 {
+		task.Status = model.TaskStatusSuccess
+		task.Data = line.Response.Body
+		var response dto.OpenAITextResponse
+		if err := common.Unmarshal(line.Response.Body, &response); err == nil {
+			usage = &response.Usage
+		}
+	} else {
+		task.Status = model.TaskStatusFailure
+		task.FailReason = batchLineFailReason(line, reason)
+	}
+
+	won, err := task.UpdateWithStatus(oldStatus)
+	if err != nil {
+		logger.LogError(ctx, fmt.Sprintf("UpdateWithStatus failed for batch task %s: %s", task.TaskID, err.Error()))
+		return
+	}
+	if !won {
+		logger.LogWarn(ctx, fmt.Sprintf("Batch task %s already transitioned by another process, skip billing", task.TaskID))
+		return
+	}
+
+	if task.Status == model.TaskStatusSuccess {
+		if actualQuota := batchRequestQuota(task.PrivateData.BillingContext, usage); actualQuota > 0 {
+			RecalculateTaskQuota(ctx, task, actualQuota, "Batch 用量结算")
+		}
+	} else if task.Quota != 0 {
+		RefundTaskQuota(ctx, task, task.FailReason)
+	}
+	notifyBatchRequestWebhook(task)
+}
+
+func failBatchTasks(ctx context.Context, tasks []*model.Task, reason string) {
+	logger.LogError(ctx, reason)
+	for _, task := range tasks {
+		finishBatchTask(ctx, task, nil, reason)
+	}
+}
+
+// batchRequestQuota 按提交时的计费快照和上游返回的 usage 计算实际额度
+func batchRequestQuota(bc *model.TaskBillingContext, usage *dto.Usage) int {
+	if bc == nil {
+		return 0
+	}
+	otherRatio := 1.0
+	for _, ratio := range bc.OtherRatios {
+		otherRatio *= ratio
+	}
+	var quota float64
+	if bc.PerCallBilling {
+		quota = bc.ModelPrice * common.QuotaPerUnit * bc.GroupRatio * otherRatio
+	} else {
+		if usage == nil {
+			return 0
+		}
+		cachedTokens := usage.PromptTokensDetails.CachedTokens
+		promptTokens := float64(usage.PromptTokens-cachedTokens) + float64(cachedTokens)*bc.CacheRatio
+		completionTokens := float64(usage.CompletionTokens) * bc.CompletionRatio
+		quota = (promptTokens + completionTokens) * bc.ModelRatio * bc.GroupRatio * otherRatio
+	}
+	if quota > 0 && quota < 1 {
+		return 1
+	}
+	return int(math.Round(quota))
+}
+
+// buildBatchInputFile 生成 Batch 输入文件（JSONL），以公开任务 ID 作为 custom_id
+func buildBatchInputFile(tasks []*model.Task) ([]byte, error) {
+	var buf bytes.Buffer
+	for _, task := range tasks {
+		line, err := common.Marshal(dto.OpenAIBatchInputLine{
+			CustomId: task.TaskID,
+			Method:   http.MethodPost,
+			Url:      batchChatCompletionsEndpoint,
+			Body:     task.Data,
+		})
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(line)
+		buf.WriteByte('\n')
+	}
+	return buf.Bytes(), nil
+}
+
+// parseBatchOutput 解析 Batch 输出文件或错误文件，按 custom_id 索引，忽略无法解析的行
+func parseBatchOutput(content []byte) map[string]*dto.OpenAIBatchOutputLine {
+	results := make(map[string]*dto.OpenAIBatchOutputLine)
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	scanner.Buffer(make([]byte, 0, 64*1024), 64*1024*1024)
+	for scanner.Scan() {
+		text := bytes.TrimSpace(scanner.Bytes())
+		if len(text) == 0 {
+			continue
+		}
+		var line dto.OpenAIBatchOutputLine
+		if err := common.Unmarshal(text, &line); err != nil || line.CustomId == "" {
+			continue
+		}
+		results[line.CustomId] = &line
+	}
+	return results
+}
+
+func batchProgress(batch *dto.OpenAIBatch) string {
+	counts := batch.RequestCounts
+	if counts.Total <= 0 {
+		return taskcommon.ProgressInProgress
+	}
+	percent := (counts.Completed + counts.Failed) * 100 / counts.Total
+	return fmt.Sprintf("%d%%", min(99, max(30, percent)))
+}
+
+func batchFailReason(batch *dto.OpenAIBatch) string {
+	if batch.Errors != nil && len(batch.Errors.Data) > 0 {
+		messages := make([]string, 0, len(batch.Errors.Data))
+		for _, e := range batch.Errors.Data {
+			messages = append(messages, e.Message)
+		}
+		return fmt.Sprintf("上游 Batch %s：%s", batch.Status, strings.Join(messages, "; "))
+	}
+	return fmt.Sprintf("上游 Batch %s，未返回该请求的结果", batch.Status)
+}
+
+func batchLineFailReason(line *dto.OpenAIBatchOutputLine, fallback string) string {
+	if line == nil {
+		return fallback
+	}
+	if line.Error != nil && line.Error.Message != "" {
+		return line.Error.Message
+	}
+	if line.Response != nil {
+		var body struct {
+			Error types.OpenAIError `json:"error"`
+		}
+		if err := common.Unmarshal(line.Response.Body, &body); err == nil && body.Error.Message != "" {
+			return body.Error.Message
+		}
+		return fmt.Sprintf("上游返回状态码 %d", line.Response.StatusCode)
+	}
+	return fallback
+}
+
+// BatchRequestWebhookPayload Batch 请求完成后推送到令牌 webhook 地址的负载
+type BatchRequestWebhookPayload struct {
+	Type      string            `json:"type"`
+	TokenId   int               `json:"token_id"`
+	Data      *dto.BatchRequest `json:"data"`
+	Timestamp int64             `json:"timestamp"`
+}
+
+// notifyBatchRequestWebhook 令牌配置了推送地址时异步推送 Batch 请求结果，签名方式与用量推送相同
+func notifyBatchRequestWebhook(task *model.Task) {
+	if task.PrivateData.TokenId <= 0 {
+		return
+	}
+	token, err := model.GetTokenById(task.PrivateData.TokenId)
+	if err != nil || token.UsageWebhookUrl == "" {
+		return
+	}
+	payloadBytes, err := common.Marshal(BatchRequestWebhookPayload{
+		Type:      "batch_request",
+		TokenId:   token.Id,
+		Data:      task.ToBatchRequest(),
+		Timestamp: time.Now().Unix(),
+	})
+	if err != nil {
+		common.SysError(fmt.Sprintf("failed to marshal batch request webhook payload: %v", err))
+		return
+	}
+	webhookURL, secret := token.UsageWebhookUrl, token.UsageWebhookSecret
+	gopool.Go(func() {
+		if err := postWebhook(webhookURL, secret, payloadBytes); err != nil {
+			common.SysLog(fmt.Sprintf("failed to send batch request webhook for task %s: %v", task.TaskID, err))
+		}
+	})
+}
+
+type batchUpstreamError struct {
+	StatusCode int
+	Body       string
+}
+
+func (e *batchUpstreamError) Error() string {
+	return fmt.Sprintf("status code %d: %s", e.StatusCode, e.Body)
+}
+
+// batchUpstream 通过渠道的 OpenAI 兼容接口操作上游文件与 Batch
+type batchUpstream struct {
+	client  *http.Client
+	baseURL string
+	key     string
+}
+
+func newBatchUpstream(channel *model.Channel, key string) (*batchUpstream, error) {
+	client, err := GetHttpClientWithProxy(channel.GetSetting().Proxy)
+	if err != nil {
+		return nil, err
+	}
+	return &batchUpstream{
+		client:  client,
+		baseURL: strings.TrimSuffix(channel.GetBaseURL(), "/"),
+		key:     key,
+	}, nil
+}
+
+func (u *batchUpstream) do(ctx context.Context, method string, path string, contentType string, body io.Reader) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, method, u.baseURL+path, body)
+	if err != nil {
+		return nil, err
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	req.Header.Set("Authorization", "Bearer "+u.key)
+	resp, err := u.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode/100 != 2 {
+		return nil, &batchUpstreamError{StatusCode: resp.StatusCode, Body: string(data)}
+	}
+	return data, nil
+}
+
+// createBatch 上传输入文件并按 request 创建 Batch，request 中的 input_file_id 由上传结果填充
+func (u *batchUpstream) createBatch(ctx context.Context, input []byte, request dto.OpenAIBatchCreateRequest) (*dto.OpenAIBatch, error) {
+	file, err := u.uploadFile(ctx, "batch", "batch.jsonl", bytes.NewReader(input))
+	if err != nil {
+		return nil, err
+	}
+	request.InputFileId = file.Id
+	body, err := common.Marshal(request)
+	if err != nil {
+		return nil, err
+	}
+	data, err := u.do(ctx, http.MethodPost, "/v1/batches", "application/json", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	var batch dto.OpenAIBatch
+	if err = common.Unmarshal(data, &batch); err != nil {
+		return nil, err
+	}
+	if batch.ID == "" {
+		return nil, fmt.Errorf("upstream returned batch without id: %s", string(data))
+	}
+	return &batch, nil
+}
+
+func (u *batchUpstream) getBatch(ctx context.Context, batchId string) (*dto.OpenAIBatch, error) {
+	data, err := u.do(ctx, http.MethodGet, "/v1/batches/"+batchId, "", nil)
+	if err != nil {
+		return nil, err
+	}
+	var batch dto.OpenAIBatch
+	if err = common.Unmarshal(data, &batch); err != nil {
+		return nil, err
+	}
+	return &batch, nil
+}
+
+func (u *batchUpstream) cancelBatch(ctx context.Context, batchId string) (*dto.OpenAIBatch, error) {
+	data, err := u.do(ctx, http.MethodPost, "/v1/batches/"+batchId+"/cancel", "", nil)
+	if err != nil {
+		return nil, err
+	}
+	var batch dto.OpenAIBatch
+	if err = common.Unmarshal(data, &batch); err != nil {
+		return nil, err
+	}
+	return &batch, nil
+}
+
+func (u *batchUpstream) fileContent(ctx context.Context, fileId string) ([]byte, error) {
+	return u.do(ctx, http.MethodGet, "/v1/files/"+fileId+"/content", "", nil)
+}
+
+// uploadFile 以 multipart 表单上传文件到上游的 /v1/files
+func (u *batchUpstream) uploadFile(ctx context.Context, purpose string, filename string, content io.Reader) (*dto.FileObject, error) {
+	var form bytes.Buffer
+	writer := multipart.NewWriter(&form)
+	if err := writer.WriteField("purpose", purpose); err != nil {
+		return nil, err
+	}
+	part, err := writer.CreateFormFile("file", filename)
+	if err != nil {
+		return nil, err
+	}
+	if _, err = io.Copy(part, content); err != nil {
+		return nil, err
+	}
+	if err = writer.Close(); err != nil {
+		return nil, err
+	}
+	data, err := u.do(ctx, http.MethodPost, "/v1/files", writer.FormDataContentType(), &form)
+	if err != nil {
+		return nil, err
+	}
+	var file dto.FileObject
+	if err = common.Unmarshal(data, &file); err != nil {
+		return nil, err
+	}
+	if file.Id == "" {
+		return nil, fmt.Errorf("upstream returned file without id: %s", string(data))
+	}
+	return &file, nil
+}
+
+func (u *batchUpstream) deleteFile(ctx context.Context, fileId string) error {
+	_, err := u.do(ctx, http.MethodDelete, "/v1/files/"+fileId, "", nil)
+	return err
+}
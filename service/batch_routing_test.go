@@ -0,0 +1,74 @@
+package service
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/dto"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBatchRequestQuota(t *testing.T) {
+	bc := &model.TaskBillingContext{
+		ModelRatio:      2,
+		CompletionRatio: 4,
+		CacheRatio:      0.5,
+		GroupRatio:      1,
+		OtherRatios:     map[string]float64{"batch": 0.5},
+	}
+	usage := &dto.Usage{PromptTokens: 1000, CompletionTokens: 100}
+	usage.PromptTokensDetails.CachedTokens = 200
+	// (800 + 200*0.5 + 100*4) * 2 * 1 * 0.5
+	require.Equal(t, 1300, batchRequestQuota(bc, usage))
+	require.Equal(t, 0, batchRequestQuota(bc, nil))
+	require.Equal(t, 0, batchRequestQuota(nil, usage))
+
+	perCall := &model.TaskBillingContext{ModelPrice: 0.01, GroupRatio: 1, PerCallBilling: true, OtherRatios: map[string]float64{"batch": 0.5}}
+	require.Equal(t, int(0.01*common.QuotaPerUnit*0.5), batchRequestQuota(perCall, nil))
+}
+
+func TestBatchInputAndOutputFiles(t *testing.T) {
+	tasks := []*model.Task{
+		{TaskID: "task_a", Data: json.RawMessage(`{"model":"gpt-4o-mini","messages":[]}`)},
+		{TaskID: "task_b", Data: json.RawMessage(`{"model":"gpt-4o-mini","messages":[]}`)},
+	}
+	input, err := buildBatchInputFile(tasks)
+	require.NoError(t, err)
+	lines := parseBatchInputLines(t, input)
+	require.Len(t, lines, 2)
+	require.Equal(t, "task_a", lines[0].CustomId)
+	require.Equal(t, "POST", lines[0].Method)
+	require.Equal(t, "/v1/chat/completions", lines[0].Url)
+	require.JSONEq(t, `{"model":"gpt-4o-mini","messages":[]}`, string(lines[1].Body))
+
+	output := []byte(`{"id":"r1","custom_id":"task_a","response":{"status_code":200,"body":{"id":"c1","usage":{"prompt_tokens":3,"completion_tokens":2,"total_tokens":5}}},"error":null}
+
+{"id":"r2","custom_id":"task_b","response":{"status_code":400,"body":{"error":{"message":"bad model"}}},"error":null}
+not json
+`)
+	results := parseBatchOutput(output)
+	require.Len(t, results, 2)
+	require.Equal(t, 200, results["task_a"].Response.StatusCode)
+	require.Equal(t, "bad model", batchLineFailReason(results["task_b"], "fallback"))
+	require.Equal(t, "fallback", batchLineFailReason(nil, "fallback"))
+}
+
+func TestBatchProgress(t *testing.T) {
+	require.Equal(t, "30%", batchProgress(&dto.OpenAIBatch{}))
+	require.Equal(t, "50%", batchProgress(&dto.OpenAIBatch{RequestCounts: dto.OpenAIBatchRequestCounts{Total: 4, Completed: 1, Failed: 1}}))
+	require.Equal(t, "99%", batchProgress(&dto.OpenAIBatch{RequestCounts: dto.OpenAIBatchRequestCounts{Total: 2, Completed: 2}}))
+}
+
+func parseBatchInputLines(t *testing.T, input []byte) []dto.OpenAIBatchInputLine {
+	t.Helper()
+	var lines []dto.OpenAIBatchInputLine
+	for _, raw := range bytes.Split(bytes.TrimSpace(input), []byte("\n")) {
+		var line dto.OpenAIBatchInputLine
+		require.NoError(t, common.Unmarshal(raw, &line))
+		lines = append(lines, line)
+	}
+	return lines
+}
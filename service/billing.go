@@ -3,6 +3,9 @@ package service
 import (
 	"fmt"
 
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/constant"
+	"github.com/QuantumNous/new-api/dto"
 	"github.com/QuantumNous/new-api/logger"
 	relaycommon "github.com/QuantumNous/new-api/relay/common"
 	"github.com/QuantumNous/new-api/types"
@@ -76,3 +79,12 @@ func SettleBilling(ctx *gin.Context, relayInfo *relaycommon.RelayInfo, actualQuo
 	}
 	return nil
 }
+
+// SettleUsageBilling 在按 usage 结算时使用：先记录本次结算的补全 tokens（供流完整性尾部上报），
+// 再执行 SettleBilling。
+func SettleUsageBilling(ctx *gin.Context, relayInfo *relaycommon.RelayInfo, actualQuota int, usage *dto.Usage) error {
+	if usage != nil {
+		common.SetContextKey(ctx, constant.ContextKeyCompletionTokens, usage.CompletionTokens)
+	}
+	return SettleBilling(ctx, relayInfo, actualQuota)
+}
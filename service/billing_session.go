@@ -1,12 +1,14 @@
 package service
 
 import (
+	"errors"
 	"fmt"
 	"net/http"
 	"strings"
 	"sync"
 
 	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/i18n"
 	"github.com/QuantumNous/new-api/logger"
 	"github.com/QuantumNous/new-api/model"
 	relaycommon "github.com/QuantumNous/new-api/relay/common"
@@ -27,6 +29,8 @@ type BillingSession struct {
 	funding          FundingSource
 	preConsumedQuota int  // 实际预扣额度（信任用户可能为 0）
 	tokenConsumed    int  // 令牌额度实际扣减量
+	requestConsumed  bool // 按次模式的令牌已计入本次请求
+	requestPeriod    int64
 	fundingSettled   bool // funding.Settle 已成功，资金来源已提交
 	settled          bool // Settle 全部完成（资金 + 令牌）
 	refunded         bool // Refund 已调用
@@ -97,6 +101,8 @@ func (s *BillingSession) Refund(c *gin.Context) {
 	tokenKey := s.relayInfo.TokenKey
 	isPlayground := s.relayInfo.IsPlayground
 	tokenConsumed := s.tokenConsumed
+	requestConsumed := s.requestConsumed
+	requestPeriod := s.requestPeriod
 	funding := s.funding
 
 	gopool.Go(func() {
@@ -110,6 +116,12 @@ func (s *BillingSession) Refund(c *gin.Context) {
 				common.SysLog("error refunding token quota: " + err.Error())
 			}
 		}
+		// 3) 退还按次模式计入的请求
+		if requestConsumed {
+			if err := model.RefundTokenRequest(tokenId, requestPeriod); err != nil {
+				common.SysLog("error refunding token request: " + err.Error())
+			}
+		}
 	})
 }
 
@@ -125,7 +137,7 @@ func (s *BillingSession) needsRefundLocked() bool {
 		// fundingSettled 时资金来源已提交结算，不能再退预扣费
 		return false
 	}
-	if s.tokenConsumed > 0 {
+	if s.tokenConsumed > 0 || s.requestConsumed {
 		return true
 	}
 	// 订阅可能在 tokenConsumed=0 时仍预扣了额度
@@ -144,11 +156,16 @@ func (s *BillingSession) GetPreConsumedQuota() int {
 // PreConsume — 统一预扣费入口（含信任额度旁路）
 // ---------------------------------------------------------------------------
 
-// preConsume 执行预扣费：信任检查 -> 令牌预扣 -> 资金来源预扣。
+// preConsume 执行预扣费：按次令牌计数 -> 信任检查 -> 令牌预扣 -> 资金来源预扣。
 // 任一步骤失败时原子回滚已完成的步骤。
 func (s *BillingSession) preConsume(c *gin.Context, quota int) *types.NewAPIError {
 	effectiveQuota := quota
 
+	// ---- 按次模式的令牌计入本次请求 ----
+	if apiErr := s.consumeTokenRequest(c); apiErr != nil {
+		return apiErr
+	}
+
 	// ---- 信任额度旁路 ----
 	if s.shouldTrust(c) {
 		effectiveQuota = 0
@@ -160,6 +177,11 @@ func (s *BillingSession) preConsume(c *gin.Context, quota int) *types.NewAPIErro
 	// ---- 1) 预扣令牌额度 ----
 	if effectiveQuota > 0 {
 		if err := PreConsumeTokenQuota(s.relayInfo, effectiveQuota); err != nil {
+			var quotaErr *TokenQuotaInsufficientError
+			if errors.As(err, &quotaErr) {
+				err = errors.New(i18n.T(c, i18n.MsgQuotaTokenInsufficient, map[string]any{"Remain": logger.FormatQuota(quotaErr.Remain), "Need": logger.FormatQuota(quotaErr.Need)}))
+			}
+			s.rollbackTokenRequest()
 			return types.NewErrorWithStatusCode(err, types.ErrorCodePreConsumeTokenQuotaFailed, http.StatusForbidden, types.ErrOptionWithSkipRetry(), types.ErrOptionWithNoRecordErrorLog())
 		}
 		s.tokenConsumed = effectiveQuota
@@ -175,10 +197,11 @@ func (s *BillingSession) preConsume(c *gin.Context, quota int) *types.NewAPIErro
 			}
 			s.tokenConsumed = 0
 		}
+		s.rollbackTokenRequest()
 		// TODO: model 层应定义哨兵错误（如 ErrNoActiveSubscription），用 errors.Is 替代字符串匹配
 		errMsg := err.Error()
 		if strings.Contains(errMsg, "no active subscription") || strings.Contains(errMsg, "subscription quota insufficient") {
-			return types.NewErrorWithStatusCode(fmt.Errorf("订阅额度不足或未配置订阅: %s", errMsg), types.ErrorCodeInsufficientUserQuota, http.StatusForbidden, types.ErrOptionWithSkipRetry(), types.ErrOptionWithNoRecordErrorLog())
+			return types.NewErrorWithStatusCode(errors.New(i18n.T(c, i18n.MsgQuotaSubscriptionInsufficient, map[string]any{"Error": errMsg})), types.ErrorCodeInsufficientUserQuota, http.StatusForbidden, types.ErrOptionWithSkipRetry(), types.ErrOptionWithNoRecordErrorLog())
 		}
 		return types.NewError(err, types.ErrorCodeUpdateDataError, types.ErrOptionWithSkipRetry())
 	}
@@ -191,6 +214,61 @@ func (s *BillingSession) preConsume(c *gin.Context, quota int) *types.NewAPIErro
 	return nil
 }
 
+// consumeTokenRequest 按次模式的令牌计入本次请求，当前周期的次数已用尽时返回错误
+func (s *BillingSession) consumeTokenRequest(c *gin.Context) *types.NewAPIError {
+	period, consumed, apiErr := consumeRelayTokenRequest(c, s.relayInfo)
+	if apiErr != nil {
+		return apiErr
+	}
+	s.requestConsumed = consumed
+	s.requestPeriod = period
+	return nil
+}
+
+func consumeRelayTokenRequest(c *gin.Context, info *relaycommon.RelayInfo) (int64, bool, *types.NewAPIError) {
+	if !info.TokenRequestMode || info.IsPlayground {
+		return 0, false, nil
+	}
+	period, err := model.ConsumeTokenRequest(info.TokenId, info.TokenRequestQuota, info.TokenRequestPeriod)
+	if errors.Is(err, model.ErrTokenRequestQuotaExhausted) {
+		return 0, false, types.NewErrorWithStatusCode(errors.New(i18n.T(c, i18n.MsgQuotaTokenRequestsExhausted, map[string]any{"Limit": info.TokenRequestQuota})),
+			types.ErrorCodePreConsumeTokenQuotaFailed, http.StatusForbidden, types.ErrOptionWithSkipRetry(), types.ErrOptionWithNoRecordErrorLog())
+	}
+	if err != nil {
+		return 0, false, types.NewError(err, types.ErrorCodeUpdateDataError, types.ErrOptionWithSkipRetry())
+	}
+	return period, true, nil
+}
+
+// ConsumeTokenRequest 供不经过 BillingSession 的计费路径（Midjourney）计入按次模式令牌的一次请求，
+// 当前周期的次数已用尽时返回错误；返回的 rollback 在请求最终未计费时退还该次请求
+func ConsumeTokenRequest(c *gin.Context, info *relaycommon.RelayInfo) (rollback func(), apiErr *types.NewAPIError) {
+	period, consumed, apiErr := consumeRelayTokenRequest(c, info)
+	if apiErr != nil {
+		return func() {}, apiErr
+	}
+	return func() {
+		if !consumed {
+			return
+		}
+		consumed = false
+		if err := model.RefundTokenRequest(info.TokenId, period); err != nil {
+			common.SysLog(fmt.Sprintf("error rolling back token request (tokenId=%d): %s", info.TokenId, err.Error()))
+		}
+	}, nil
+}
+
+// rollbackTokenRequest 预扣费失败时退还已计入的请求
+func (s *BillingSession) rollbackTokenRequest() {
+	if !s.requestConsumed {
+		return
+	}
+	s.requestConsumed = false
+	if err := model.RefundTokenRequest(s.relayInfo.TokenId, s.requestPeriod); err != nil {
+		common.SysLog(fmt.Sprintf("error rolling back token request (tokenId=%d): %s", s.relayInfo.TokenId, err.Error()))
+	}
+}
+
 // shouldTrust 统一信任额度检查，适用于钱包和订阅。
 func (s *BillingSession) shouldTrust(c *gin.Context) bool {
 	// 异步任务（ForcePreConsume=true）必须预扣全额，不允许信任旁路
@@ -261,19 +339,20 @@ func NewBillingSession(c *gin.Context, relayInfo *relaycommon.RelayInfo, preCons
 
 	// 钱包路径需要先检查用户额度
 	tryWallet := func() (*BillingSession, *types.NewAPIError) {
-		userQuota, err := model.GetUserQuota(relayInfo.UserId, false)
+		// 余额不足时可在后付费授信额度内透支
+		userQuota, available, err := model.GetUserAvailableQuota(relayInfo.UserId, preConsumedQuota)
 		if err != nil {
 			return nil, types.NewError(err, types.ErrorCodeQueryDataError, types.ErrOptionWithSkipRetry())
 		}
-		if userQuota <= 0 {
+		if available <= 0 {
 			return nil, types.NewErrorWithStatusCode(
-				fmt.Errorf("用户额度不足, 剩余额度: %s", logger.FormatQuota(userQuota)),
+				errors.New(i18n.T(c, i18n.MsgQuotaUserInsufficient, map[string]any{"Remain": logger.FormatQuota(userQuota)})),
 				types.ErrorCodeInsufficientUserQuota, http.StatusForbidden,
 				types.ErrOptionWithSkipRetry(), types.ErrOptionWithNoRecordErrorLog())
 		}
-		if userQuota-preConsumedQuota < 0 {
+		if available-preConsumedQuota < 0 {
 			return nil, types.NewErrorWithStatusCode(
-				fmt.Errorf("预扣费额度失败, 用户剩余额度: %s, 需要预扣费额度: %s", logger.FormatQuota(userQuota), logger.FormatQuota(preConsumedQuota)),
+				errors.New(i18n.T(c, i18n.MsgQuotaUserPreConsumeFailed, map[string]any{"Remain": logger.FormatQuota(userQuota), "Need": logger.FormatQuota(preConsumedQuota)})),
 				types.ErrorCodeInsufficientUserQuota, http.StatusForbidden,
 				types.ErrOptionWithSkipRetry(), types.ErrOptionWithNoRecordErrorLog())
 		}
@@ -0,0 +1,149 @@
+package service
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+	"github.com/QuantumNous/new-api/setting/system_setting"
+)
+
+// SiteBranding is the branding the console of one site (platform or tenant) renders.
+// The same shape carries tenant overrides and live preview values, where empty
+// fields keep the inherited value.
+type SiteBranding struct {
+	SystemName string `json:"system_name"`
+	Logo       string `json:"logo"`
+	Footer     string `json:"footer_html"`
+	system_setting.BrandingSettings
+}
+
+// ResolveBranding merges the global branding, the tenant overrides and an optional
+// extra override (used by live preview), later values winning when non-empty.
+func ResolveBranding(tenant *model.Tenant, extra *SiteBranding) (*SiteBranding, error) {
+	global := system_setting.GetBrandingSettings()
+	branding := &SiteBranding{
+		SystemName: common.SystemName,
+		Logo:       common.Logo,
+		Footer:     common.Footer,
+		BrandingSettings: system_setting.BrandingSettings{
+			PrimaryColor:    global.PrimaryColor,
+			AccentColor:     global.AccentColor,
+			BackgroundColor: global.BackgroundColor,
+			FaviconURL:      global.FaviconURL,
+			DocsURL:         global.DocsURL,
+			FooterLinks:     global.FooterLinks,
+			EmailTemplates:  make(map[string]system_setting.BrandingEmailTemplate, len(global.EmailTemplates)),
+		},
+	}
+	if branding.DocsURL == "" {
+		branding.DocsURL = operation_setting.GetGeneralSetting().DocsLink
+	}
+	for kind, tpl := range global.EmailTemplates {
+		branding.EmailTemplates[kind] = tpl
+	}
+
+	if tenant != nil {
+		tenantOverride := &SiteBranding{
+			SystemName: tenant.SystemName,
+			Logo:       tenant.Logo,
+			Footer:     tenant.Footer,
+		}
+		if len(tenant.Branding) > 0 && string(tenant.Branding) != "null" {
+			if err := common.Unmarshal(tenant.Branding, &tenantOverride.BrandingSettings); err != nil {
+				return nil, fmt.Errorf("invalid branding of tenant %d: %w", tenant.Id, err)
+			}
+		}
+		applyBrandingOverride(branding, tenantOverride)
+	}
+	if extra != nil {
+		applyBrandingOverride(branding, extra)
+	}
+	return branding, nil
+}
+
+func applyBrandingOverride(branding *SiteBranding, override *SiteBranding) {
+	setIfNotEmpty := func(target *string, value string) {
+		if strings.TrimSpace(value) != "" {
+			*target = value
+		}
+	}
+	setIfNotEmpty(&branding.SystemName, override.SystemName)
+	setIfNotEmpty(&branding.Logo, override.Logo)
+	setIfNotEmpty(&branding.Footer, override.Footer)
+	setIfNotEmpty(&branding.PrimaryColor, override.PrimaryColor)
+	setIfNotEmpty(&branding.AccentColor, override.AccentColor)
+	setIfNotEmpty(&branding.BackgroundColor, override.BackgroundColor)
+	setIfNotEmpty(&branding.FaviconURL, override.FaviconURL)
+	setIfNotEmpty(&branding.DocsURL, override.DocsURL)
+	if len(override.FooterLinks) > 0 {
+		branding.FooterLinks = override.FooterLinks
+	}
+	for kind, tpl := range override.EmailTemplates {
+		if tpl.Subject != "" || tpl.Body != "" {
+			branding.EmailTemplates[kind] = tpl
+		}
+	}
+}
+
+// default email templates, used when neither the site nor the tenant configures one
+var defaultBrandingEmailTemplates = map[string]system_setting.BrandingEmailTemplate{
+	system_setting.EmailTemplateVerification: {
+		Subject: "{{system_name}}邮箱验证邮件",
+		Body: "<p>您好，你正在进行{{system_name}}邮箱验证。</p>" +
+			"<p>您的验证码为: <strong>{{code}}</strong></p>" +
+			"<p>验证码 {{minutes}} 分钟内有效，如果不是本人操作，请忽略。</p>",
+	},
+	system_setting.EmailTemplatePasswordReset: {
+		Subject: "{{system_name}}密码重置",
+		Body: "<p>您好，你正在进行{{system_name}}密码重置。</p>" +
+			"<p>点击 <a href='{{link}}'>此处</a> 进行密码重置。</p>" +
+			"<p>如果链接无法点击，请尝试点击下面的链接或将其复制到浏览器中打开：<br> {{link}} </p>" +
+			"<p>重置链接 {{minutes}} 分钟内有效，如果不是本人操作，请忽略。</p>",
+	},
+	system_setting.EmailTemplateBudgetAlert: {
+		Subject: "{{title}}",
+		Body:    "{{content}}",
+	},
+	system_setting.EmailTemplateUsageSummary: {
+		Subject: "{{system_name}} {{month}} 用量汇总",
+		Body: "<p>{{username}}，您好：</p>" +
+			"<p>您在 {{month}} 共发起 {{requests}} 次请求，消耗额度 {{quota}}。</p>" +
+			"<p>当前剩余额度 {{remain_quota}}。</p>",
+	},
+	system_setting.EmailTemplateUsageReport: {
+		Subject: "{{system_name}} {{title}} {{period}} 用量报告",
+		Body:    "{{report}}",
+	},
+	system_setting.EmailTemplateNotification: {
+		Subject: "{{title}}",
+		Body:    "{{content}}",
+	},
+}
+
+// RenderBrandingEmail renders the subject and body of a branded email. Empty template
+// fields fall back to the built-in template. Each key of vars replaces its {{key}}
+// placeholder; {{system_name}} and {{minutes}} are always available.
+func RenderBrandingEmail(branding *SiteBranding, kind string, vars map[string]string) (string, string) {
+	tpl := defaultBrandingEmailTemplates[kind]
+	if custom, ok := branding.EmailTemplates[kind]; ok {
+		if custom.Subject != "" {
+			tpl.Subject = custom.Subject
+		}
+		if custom.Body != "" {
+			tpl.Body = custom.Body
+		}
+	}
+	pairs := []string{
+		"{{system_name}}", branding.SystemName,
+		"{{minutes}}", strconv.Itoa(common.VerificationValidMinutes),
+	}
+	for key, value := range vars {
+		pairs = append(pairs, "{{"+key+"}}", value)
+	}
+	replacer := strings.NewReplacer(pairs...)
+	return replacer.Replace(tpl.Subject), replacer.Replace(tpl.Body)
+}
@@ -0,0 +1,47 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/setting/system_setting"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResolveBrandingTenantOverride(t *testing.T) {
+	originName := common.SystemName
+	originSettings := *system_setting.GetBrandingSettings()
+	t.Cleanup(func() {
+		common.SystemName = originName
+		*system_setting.GetBrandingSettings() = originSettings
+	})
+	common.SystemName = "Gateway"
+	settings := system_setting.GetBrandingSettings()
+	settings.PrimaryColor = "#111111"
+	settings.AccentColor = "#222222"
+	settings.EmailTemplates = map[string]system_setting.BrandingEmailTemplate{
+		system_setting.EmailTemplateVerification: {Subject: "[{{system_name}}] code"},
+	}
+
+	tenant := &model.Tenant{
+		Id:         3,
+		SystemName: "Acme AI",
+		Branding:   model.JSONValue(`{"primary_color":"#ff0000","footer_links":[{"label":"Docs","url":"https://acme.example/docs"}]}`),
+	}
+	branding, err := ResolveBranding(tenant, nil)
+	require.NoError(t, err)
+	require.Equal(t, "Acme AI", branding.SystemName)
+	require.Equal(t, "#ff0000", branding.PrimaryColor)
+	require.Equal(t, "#222222", branding.AccentColor)
+	require.Len(t, branding.FooterLinks, 1)
+
+	subject, body := RenderBrandingEmail(branding, system_setting.EmailTemplateVerification, map[string]string{"code": "424242"})
+	require.Equal(t, "[Acme AI] code", subject)
+	require.Contains(t, body, "424242")
+
+	preview, err := ResolveBranding(tenant, &SiteBranding{SystemName: "Preview"})
+	require.NoError(t, err)
+	require.Equal(t, "Preview", preview.SystemName)
+	require.Equal(t, "#ff0000", preview.PrimaryColor)
+}
@@ -91,7 +91,7 @@ func getChannelAffinityCache() *cachex.HybridCache[int] {
 		}
 
 		channelAffinityCache = cachex.NewHybridCache[int](cachex.HybridCacheConfig[int]{
-			Namespace: cachex.Namespace(channelAffinityCacheNamespace),
+			Namespace: cachex.Namespace(common.RedisKey(channelAffinityCacheNamespace)),
 			Redis:     common.RDB,
 			RedisEnabled: func() bool {
 				return common.RedisEnabled && common.RDB != nil
@@ -149,7 +149,7 @@ func GetChannelAffinityCacheStats() ChannelAffinityCacheStats {
 	total := len(keys)
 	unknown := 0
 	for _, k := range keys {
-		prefix := channelAffinityCacheNamespace + ":"
+		prefix := common.RedisKey(channelAffinityCacheNamespace) + ":"
 		if !strings.HasPrefix(k, prefix) {
 			unknown++
 			continue
@@ -574,7 +574,7 @@ func GetPreferredChannelByAffinity(c *gin.Context, modelName string, usingGroup
 			ttlSeconds = setting.DefaultTTLSeconds
 		}
 		cacheKeySuffix := buildChannelAffinityCacheKeySuffix(rule, usingGroup, affinityValue)
-		cacheKeyFull := channelAffinityCacheNamespace + ":" + cacheKeySuffix
+		cacheKeyFull := common.RedisKey(channelAffinityCacheNamespace) + ":" + cacheKeySuffix
 		setChannelAffinityContext(c, channelAffinityMeta{
 			CacheKey:       cacheKeyFull,
 			TTLSeconds:     ttlSeconds,
@@ -925,7 +925,7 @@ func getChannelAffinityUsageCacheStatsCache() *cachex.HybridCache[ChannelAffinit
 		}
 
 		channelAffinityUsageCacheStatsCache = cachex.NewHybridCache[ChannelAffinityUsageCacheCounters](cachex.HybridCacheConfig[ChannelAffinityUsageCacheCounters]{
-			Namespace: cachex.Namespace(channelAffinityUsageCacheStatsNamespace),
+			Namespace: cachex.Namespace(common.RedisKey(channelAffinityUsageCacheStatsNamespace)),
 			Redis:     common.RDB,
 			RedisEnabled: func() bool {
 				return common.RedisEnabled && common.RDB != nil
@@ -2,11 +2,13 @@ package service
 
 import (
 	"errors"
+	"strings"
 
 	"github.com/QuantumNous/new-api/common"
 	"github.com/QuantumNous/new-api/constant"
 	"github.com/QuantumNous/new-api/logger"
 	"github.com/QuantumNous/new-api/model"
+	relayconstant "github.com/QuantumNous/new-api/relay/constant"
 	"github.com/QuantumNous/new-api/setting"
 	"github.com/gin-gonic/gin"
 )
@@ -85,6 +87,8 @@ func CacheGetRandomSatisfiedChannel(param *RetryParam) (*model.Channel, string,
 	var err error
 	selectGroup := param.TokenGroup
 	userGroup := common.GetContextKeyString(param.Ctx, constant.ContextKeyUserGroup)
+	labelSelector := GetTokenChannelLabelSelector(param.Ctx)
+	endpoint := GetRequestChannelEndpoint(param.Ctx)
 
 	if param.TokenGroup == "auto" {
 		if len(setting.GetAutoGroups()) == 0 {
@@ -115,7 +119,7 @@ func CacheGetRandomSatisfiedChannel(param *RetryParam) (*model.Channel, string,
 			}
 			logger.LogDebug(param.Ctx, "Auto selecting group: %s, priorityRetry: %d", autoGroup, priorityRetry)
 
-			channel, _ = model.GetRandomSatisfiedChannel(autoGroup, param.ModelName, priorityRetry)
+			channel, _ = model.GetRandomSatisfiedChannel(autoGroup, param.ModelName, priorityRetry, labelSelector, endpoint)
 			if channel == nil {
 				// Current group has no available channel for this model, try next group
 				// 当前分组没有该模型的可用渠道，尝试下一个分组
@@ -153,10 +157,55 @@ func CacheGetRandomSatisfiedChannel(param *RetryParam) (*model.Channel, string,
 			break
 		}
 	} else {
-		channel, err = model.GetRandomSatisfiedChannel(param.TokenGroup, param.ModelName, param.GetRetry())
+		channel, err = model.GetRandomSatisfiedChannel(param.TokenGroup, param.ModelName, param.GetRetry(), labelSelector, endpoint)
 		if err != nil {
 			return nil, param.TokenGroup, err
 		}
 	}
 	return channel, selectGroup, nil
 }
+
+// GetTokenChannelLabelSelector 返回令牌的渠道标签约束，约束在保存令牌时已校验，解析失败时视为不约束
+func GetTokenChannelLabelSelector(c *gin.Context) model.ChannelLabelSelector {
+	selector, err := model.ParseChannelLabelSelector(common.GetContextKeyString(c, constant.ContextKeyTokenChannelLabels))
+	if err != nil {
+		logger.LogWarn(c, "invalid token channel labels: "+err.Error())
+		return nil
+	}
+	return selector
+}
+
+// GetRequestChannelEndpoint 返回请求所属的渠道端点类型，用于跳过未声明服务该类型的渠道
+func GetRequestChannelEndpoint(c *gin.Context) string {
+	if c == nil || c.Request == nil || c.Request.URL == nil {
+		return ""
+	}
+	return channelEndpointByPath(c.Request.URL.Path)
+}
+
+// channelEndpointByPath 按请求路径识别端点类型，Midjourney、视频等任务接口及实时接口不属于任何类型
+func channelEndpointByPath(path string) string {
+	switch relayconstant.Path2RelayMode(path) {
+	case relayconstant.RelayModeChatCompletions, relayconstant.RelayModeCompletions:
+		return model.ChannelEndpointChat
+	case relayconstant.RelayModeResponses, relayconstant.RelayModeResponsesCompact:
+		return model.ChannelEndpointResponses
+	case relayconstant.RelayModeEmbeddings:
+		return model.ChannelEndpointEmbeddings
+	case relayconstant.RelayModeImagesGenerations, relayconstant.RelayModeImagesEdits:
+		return model.ChannelEndpointImages
+	case relayconstant.RelayModeAudioSpeech, relayconstant.RelayModeAudioTranscription, relayconstant.RelayModeAudioTranslation:
+		return model.ChannelEndpointAudio
+	case relayconstant.RelayModeRerank:
+		return model.ChannelEndpointRerank
+	case relayconstant.RelayModeGemini:
+		if strings.HasSuffix(path, ":embedContent") || strings.HasSuffix(path, ":batchEmbedContents") {
+			return model.ChannelEndpointEmbeddings
+		}
+		return model.ChannelEndpointChat
+	}
+	if strings.HasPrefix(path, "/v1/messages") {
+		return model.ChannelEndpointChat
+	}
+	return ""
+}
@@ -0,0 +1,29 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/QuantumNous/new-api/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestChannelEndpointByPath(t *testing.T) {
+	cases := map[string]string{
+		"/v1/chat/completions":  model.ChannelEndpointChat,
+		"/v1/messages":          model.ChannelEndpointChat,
+		"/v1/responses":         model.ChannelEndpointResponses,
+		"/v1/responses/compact": model.ChannelEndpointResponses,
+		"/v1/embeddings":        model.ChannelEndpointEmbeddings,
+		"/v1/engines/text-embedding-3-small/embeddings":        model.ChannelEndpointEmbeddings,
+		"/v1beta/models/text-embedding-004:batchEmbedContents": model.ChannelEndpointEmbeddings,
+		"/v1beta/models/gemini-2.5-pro:generateContent":        model.ChannelEndpointChat,
+		"/v1/images/generations":                               model.ChannelEndpointImages,
+		"/v1/audio/transcriptions":                             model.ChannelEndpointAudio,
+		"/v1/rerank":                                           model.ChannelEndpointRerank,
+		"/v1/realtime":                                         "",
+		"/mj/submit/imagine":                                   "",
+	}
+	for path, endpoint := range cases {
+		assert.Equal(t, endpoint, channelEndpointByPath(path), path)
+	}
+}
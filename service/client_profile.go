@@ -0,0 +1,26 @@
+package service
+
+import (
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/constant"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ApplyClientProfileOverride 将令牌客户端兼容配置需要透传的请求头合并到所选渠道的参数覆盖，
+// 与渠道亲和规则的模板一样，模板中的操作排在渠道自身的操作之前
+func ApplyClientProfileOverride(c *gin.Context, paramOverride map[string]interface{}) map[string]interface{} {
+	if c == nil {
+		return paramOverride
+	}
+	profile := operation_setting.GetClientProfile(common.GetContextKeyString(c, constant.ContextKeyTokenClientProfile))
+	if profile == nil {
+		return paramOverride
+	}
+	template := profile.ParamOverrideTemplate()
+	if len(template) == 0 {
+		return paramOverride
+	}
+	return mergeChannelOverride(paramOverride, template)
+}
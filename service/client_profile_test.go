@@ -0,0 +1,35 @@
+package service
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/constant"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+)
+
+func TestApplyClientProfileOverride(t *testing.T) {
+	ctx, _ := gin.CreateTestContext(httptest.NewRecorder())
+	base := map[string]interface{}{
+		"operations": []interface{}{map[string]interface{}{"mode": "set", "path": "temperature", "value": 0.2}},
+	}
+	require.Equal(t, base, ApplyClientProfileOverride(ctx, base))
+
+	common.SetContextKey(ctx, constant.ContextKeyTokenClientProfile, operation_setting.ClientProfileClaudeCode)
+	merged := ApplyClientProfileOverride(ctx, base)
+	ops, ok := merged["operations"].([]interface{})
+	require.True(t, ok)
+	require.Len(t, ops, 2)
+	passHeaders, ok := ops[0].(map[string]interface{})
+	require.True(t, ok)
+	require.Equal(t, "pass_headers", passHeaders["mode"])
+	require.Contains(t, passHeaders["value"], "Anthropic-Beta")
+	require.Len(t, base["operations"], 1)
+
+	// cursor has no headers to pass through
+	common.SetContextKey(ctx, constant.ContextKeyTokenClientProfile, operation_setting.ClientProfileCursor)
+	require.Equal(t, base, ApplyClientProfileOverride(ctx, base))
+}
@@ -0,0 +1,52 @@
+package service
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/QuantumNous/new-api/constant"
+	"github.com/QuantumNous/new-api/dto"
+	"github.com/QuantumNous/new-api/logger"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	"github.com/QuantumNous/new-api/service/openaicompat"
+	"github.com/QuantumNous/new-api/types"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CompatWarningHeader carries the features dropped by a lenient protocol conversion.
+const CompatWarningHeader = "X-New-Api-Compat-Warning"
+
+// EnforceCompatMode applies the effective compat mode to the features lost in a protocol conversion.
+// Strict mode rejects the request, lenient mode lets it through with a warning header.
+func EnforceCompatMode(c *gin.Context, info *relaycommon.RelayInfo, features []string) *types.NewAPIError {
+	if len(features) == 0 {
+		return nil
+	}
+	joined := strings.Join(features, ", ")
+	if info.GetCompatMode() == constant.CompatModeStrict {
+		return types.NewErrorWithStatusCode(
+			fmt.Errorf("the selected channel cannot honor the following features: %s", joined),
+			types.ErrorCodeUnsupportedFeature,
+			http.StatusBadRequest,
+			types.ErrOptionWithSkipRetry(),
+		)
+	}
+	logger.LogWarn(c, fmt.Sprintf("compat mode lenient, dropped unsupported features: %s", joined))
+	c.Header(CompatWarningHeader, "dropped unsupported features: "+joined)
+	return nil
+}
+
+func ChatToResponsesUnsupportedFeatures(req *dto.GeneralOpenAIRequest) []string {
+	return openaicompat.ChatToResponsesUnsupportedFeatures(req)
+}
+
+// ResponsesToChatUnsupportedFeatures also reports previous_response_id when the responses store is off.
+func ResponsesToChatUnsupportedFeatures(req *dto.OpenAIResponsesRequest, supported ...string) []string {
+	features := openaicompat.ResponsesToChatUnsupportedFeatures(req, supported...)
+	if req != nil && req.PreviousResponseID != "" && ResponsesStoreBackend() == ResponsesStoreOff {
+		features = append(features, "previous_response_id")
+	}
+	return features
+}
@@ -0,0 +1,197 @@
+package service
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/constant"
+	"github.com/QuantumNous/new-api/dto"
+	"github.com/QuantumNous/new-api/logger"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	"github.com/QuantumNous/new-api/service/openaicompat"
+	"github.com/QuantumNous/new-api/setting/model_setting"
+	"github.com/QuantumNous/new-api/types"
+
+	"github.com/gin-gonic/gin"
+)
+
+// EnforceContextWindow 转发前比较估算的提示词 token 与目标模型的上下文窗口，超出时按令牌或全局策略
+// 拒绝、丢弃最早的消息或将其摘要，返回处理后的提示词 token 数。截断与摘要仅支持 Chat Completions 请求
+func EnforceContextWindow(c *gin.Context, info *relaycommon.RelayInfo, request dto.Request, promptTokens int) (int, *types.NewAPIError) {
+	settings := model_setting.GetContextWindowSettings()
+	if !settings.Enabled || info.RelayFormat == types.RelayFormatOpenAIRealtime {
+		return promptTokens, nil
+	}
+	window, ok := model_setting.GetModelContextWindow(info.OriginModelName)
+	if !ok {
+		return promptTokens, nil
+	}
+
+	chatReq, isChat := request.(*dto.GeneralOpenAIRequest)
+	reserve := settings.ReserveOutputTokens
+	if isChat && chatReq.GetMaxTokens() > 0 {
+		reserve = int(chatReq.GetMaxTokens())
+	}
+	limit := window - reserve
+	if isChat && promptTokens == 0 {
+		// 未开启 token 统计时单独估算
+		promptTokens = estimateMessagesTokens(chatReq.Messages, info.OriginModelName)
+	}
+	if promptTokens <= limit {
+		return promptTokens, nil
+	}
+	// truncation=auto 的 Responses 请求由上游或兼容层丢弃最早的消息
+	if responsesReq, ok := request.(*dto.OpenAIResponsesRequest); ok && openaicompat.ResponsesTruncationAuto(responsesReq) {
+		return promptTokens, nil
+	}
+
+	strategy := contextOverflowStrategy(info)
+	if strategy == constant.ContextOverflowReject || !isChat {
+		return promptTokens, contextLengthExceededError(info.OriginModelName, window, reserve, promptTokens)
+	}
+
+	kept, dropped, tokens := planContextTruncation(chatReq.Messages, promptTokens, limit, settings.KeepRecentMessages, info.OriginModelName)
+	if tokens > limit {
+		return promptTokens, contextLengthExceededError(info.OriginModelName, window, reserve, tokens)
+	}
+	if strategy == constant.ContextOverflowSummarize {
+		summary, err := summarizeAndCharge(c, info, summaryEndpoint{
+			BaseURL: settings.SummaryBaseURL,
+			APIKey:  settings.SummaryAPIKey,
+			Model:   settings.SummaryModel,
+			Timeout: time.Duration(settings.SummaryTimeoutMs) * time.Millisecond,
+		}, dropped)
+		if err != nil {
+			logger.LogWarn(c, fmt.Sprintf("context window summary failed, fallback to truncation: %s", err.Error()))
+		} else {
+			summaryMessage := conversationSummaryMessage(summary)
+			if summaryTokens := estimateMessageTokens(&summaryMessage, info.OriginModelName); tokens+summaryTokens <= limit {
+				kept = insertAfterSystemMessages(kept, summaryMessage)
+				tokens += summaryTokens
+			} else {
+				logger.LogWarn(c, "context window summary does not fit, fallback to truncation")
+			}
+		}
+	}
+
+	chatReq.Messages = kept
+	// 截断后的消息只存在于解析后的请求中，不能再透传原始请求体
+	info.RequestRewritten = true
+	common.SetContextKey(c, constant.ContextKeyPromptTokens, tokens)
+	logger.LogInfo(c, fmt.Sprintf("context window of model %s exceeded, dropped %d messages (%s), prompt tokens %d -> %d",
+		info.OriginModelName, len(dropped), strategy, promptTokens, tokens))
+	return tokens, nil
+}
+
+// TruncateResponsesConversation 处理转换为 Chat Completions 的 Responses 请求的 truncation=auto：
+// 对话超出模型上下文窗口时从最早的消息开始丢弃，未配置该模型的上下文窗口时不做处理
+func TruncateResponsesConversation(info *relaycommon.RelayInfo, chatReq *dto.GeneralOpenAIRequest) {
+	window, ok := model_setting.GetModelContextWindow(info.OriginModelName)
+	if !ok {
+		return
+	}
+	settings := model_setting.GetContextWindowSettings()
+	reserve := settings.ReserveOutputTokens
+	if chatReq.GetMaxTokens() > 0 {
+		reserve = int(chatReq.GetMaxTokens())
+	}
+	limit := window - reserve
+	promptTokens := estimateMessagesTokens(chatReq.Messages, info.OriginModelName)
+	if promptTokens <= limit {
+		return
+	}
+	chatReq.Messages, _, _ = planContextTruncation(chatReq.Messages, promptTokens, limit, settings.KeepRecentMessages, info.OriginModelName)
+}
+
+func contextOverflowStrategy(info *relaycommon.RelayInfo) constant.ContextOverflowStrategy {
+	if info.TokenContextOverflow != "" {
+		return constant.ContextOverflowStrategy(info.TokenContextOverflow)
+	}
+	strategy := model_setting.GetContextWindowSettings().DefaultStrategy
+	if strategy == "" || !constant.IsValidContextOverflowStrategy(strategy) {
+		return constant.ContextOverflowReject
+	}
+	return constant.ContextOverflowStrategy(strategy)
+}
+
+func contextLengthExceededError(modelName string, window int, reserve int, promptTokens int) *types.NewAPIError {
+	err := fmt.Errorf("model %s has a context window of %d tokens (%d reserved for output), but the prompt is about %d tokens, over by %d tokens",
+		modelName, window, reserve, promptTokens, promptTokens-(window-reserve))
+	return types.NewErrorWithStatusCode(err, types.ErrorCodeContextLengthExceeded, http.StatusBadRequest, types.ErrOptionWithSkipRetry())
+}
+
+// planContextTruncation 从最早的对话消息开始丢弃，直到估算 token 不超过 limit。
+// system / developer 消息与最近 keepRecent 条消息始终保留，丢弃工具调用时一并丢弃其结果
+func planContextTruncation(messages []dto.Message, promptTokens int, limit int, keepRecent int, modelName string) ([]dto.Message, []dto.Message, int) {
+	conversation := make([]int, 0, len(messages))
+	for i := range messages {
+		if messages[i].Role != "system" && messages[i].Role != "developer" {
+			conversation = append(conversation, i)
+		}
+	}
+	protectFrom := len(conversation) - max(keepRecent, 1)
+	// 保留的工具结果需要连同其工具调用一起保留
+	for protectFrom > 0 && protectFrom < len(conversation) && messages[conversation[protectFrom]].Role == "tool" {
+		protectFrom--
+	}
+
+	drop := make(map[int]bool)
+	tokens := promptTokens
+	for n := 0; n < protectFrom; n++ {
+		i := conversation[n]
+		// 已丢弃消息之后的工具结果失去了对应的工具调用
+		orphanToolResult := len(drop) > 0 && messages[i].Role == "tool"
+		if tokens <= limit && !orphanToolResult {
+			break
+		}
+		drop[i] = true
+		tokens -= estimateMessageTokens(&messages[i], modelName)
+	}
+
+	kept := make([]dto.Message, 0, len(messages)-len(drop))
+	dropped := make([]dto.Message, 0, len(drop))
+	for i, message := range messages {
+		if drop[i] {
+			dropped = append(dropped, message)
+		} else {
+			kept = append(kept, message)
+		}
+	}
+	return kept, dropped, max(tokens, 0)
+}
+
+func estimateMessagesTokens(messages []dto.Message, modelName string) int {
+	tokens := 3
+	for i := range messages {
+		tokens += estimateMessageTokens(&messages[i], modelName)
+	}
+	return tokens
+}
+
+// estimateMessageTokens 估算单条消息的 token 数，媒体按 EstimateRequestToken 的固定值计算
+func estimateMessageTokens(message *dto.Message, modelName string) int {
+	text := message.StringContent() + message.ReasoningContent + message.Reasoning
+	if len(message.ToolCalls) > 0 {
+		text += string(message.ToolCalls)
+	}
+	tokens := CountTextToken(text, modelName) + 3
+	if message.IsStringContent() {
+		return tokens
+	}
+	for _, part := range message.ParseContent() {
+		switch part.Type {
+		case dto.ContentTypeText:
+		case dto.ContentTypeImageURL:
+			tokens += 520
+		case dto.ContentTypeInputAudio:
+			tokens += 256
+		case dto.ContentTypeVideoUrl:
+			tokens += 4096 * 2
+		default:
+			tokens += 4096
+		}
+	}
+	return tokens
+}
@@ -0,0 +1,51 @@
+package service
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/QuantumNous/new-api/dto"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPlanContextTruncation(t *testing.T) {
+	const modelName = "claude-3-haiku"
+	long := strings.Repeat("lorem ipsum dolor sit amet ", 50)
+	messages := []dto.Message{
+		{Role: "system", Content: "be brief"},
+		{Role: "user", Content: long},
+		{Role: "assistant", Content: "", ToolCalls: []byte(`[{"id":"call_1","type":"function","function":{"name":"f","arguments":"{}"}}]`)},
+		{Role: "tool", Content: "result", ToolCallId: "call_1"},
+		{Role: "assistant", Content: long},
+		{Role: "user", Content: "what now?"},
+	}
+	total := estimateMessagesTokens(messages, modelName)
+	userTokens := estimateMessageTokens(&messages[1], modelName)
+
+	// 丢弃第一条用户消息即可满足限制
+	kept, dropped, tokens := planContextTruncation(messages, total, total-userTokens, 1, modelName)
+	require.Len(t, dropped, 1)
+	require.Equal(t, "user", dropped[0].Role)
+	require.Equal(t, "system", kept[0].Role)
+	require.Equal(t, "assistant", kept[1].Role)
+	require.Equal(t, total-userTokens, tokens)
+
+	// 丢弃工具调用时其结果一并丢弃
+	kept, dropped, _ = planContextTruncation(messages, total, total-userTokens-1, 1, modelName)
+	require.Len(t, dropped, 3)
+	require.Equal(t, "tool", dropped[2].Role)
+	require.Equal(t, []string{"system", "assistant", "user"}, messageRoles(kept))
+
+	// 最近的消息始终保留
+	kept, _, tokens = planContextTruncation(messages, total, 1, 1, modelName)
+	require.Equal(t, []string{"system", "user"}, messageRoles(kept))
+	require.Greater(t, tokens, 1)
+}
+
+func messageRoles(messages []dto.Message) []string {
+	roles := make([]string, 0, len(messages))
+	for _, message := range messages {
+		roles = append(roles, message.Role)
+	}
+	return roles
+}
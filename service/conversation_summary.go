@@ -0,0 +1,298 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/constant"
+	"github.com/QuantumNous/new-api/dto"
+	"github.com/QuantumNous/new-api/logger"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/pkg/cachex"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	"github.com/QuantumNous/new-api/setting/model_setting"
+	"github.com/QuantumNous/new-api/setting/ratio_setting"
+
+	"github.com/gin-gonic/gin"
+	"github.com/samber/hot"
+	"github.com/shopspring/decimal"
+)
+
+// 摘要请求中对话记录的最大字符数
+const conversationSummaryTranscriptMaxRunes = 32000
+
+const (
+	conversationSummaryCacheNamespace = "new-api:conversation_summary:v1"
+	conversationSummaryCacheCapacity  = 10_000
+	// 同一段对话在后续轮次中会反复被摘要，缓存期内直接复用
+	conversationSummaryCacheTTL = time.Hour
+)
+
+var (
+	conversationSummaryCache     *cachex.HybridCache[string]
+	conversationSummaryCacheOnce sync.Once
+)
+
+// summaryEndpoint 生成摘要使用的 OpenAI 兼容接口
+type summaryEndpoint struct {
+	BaseURL string
+	APIKey  string
+	Model   string
+	Timeout time.Duration
+}
+
+// ApplyConversationSummary 对话历史超过阈值时，将最近消息之前的对话总结为一条摘要消息。
+// 摘要失败时保持请求不变，仅记录警告
+func ApplyConversationSummary(c *gin.Context, info *relaycommon.RelayInfo, request dto.Request) {
+	chatReq, ok := request.(*dto.GeneralOpenAIRequest)
+	if !ok || !model_setting.ShouldSummarizeConversation(info.OriginModelName) {
+		return
+	}
+	settings := model_setting.GetConversationSummarySettings()
+	historyTokens := estimateMessagesTokens(chatReq.Messages, info.OriginModelName)
+	if historyTokens <= settings.TriggerTokens {
+		return
+	}
+
+	kept, dropped, tokens := planContextTruncation(chatReq.Messages, historyTokens, 0, settings.KeepRecentMessages, info.OriginModelName)
+	// 只有一条较早的消息时摘要不会更短
+	if len(dropped) < 2 {
+		return
+	}
+	summary, err := summarizeAndCharge(c, info, summaryEndpoint{
+		BaseURL: settings.SummaryBaseURL,
+		APIKey:  settings.SummaryAPIKey,
+		Model:   settings.SummaryModel,
+		Timeout: time.Duration(settings.SummaryTimeoutMs) * time.Millisecond,
+	}, dropped)
+	if err != nil {
+		logger.LogWarn(c, fmt.Sprintf("conversation summary failed, relay full history: %s", err.Error()))
+		return
+	}
+
+	summaryMessage := conversationSummaryMessage(summary)
+	tokens += estimateMessageTokens(&summaryMessage, info.OriginModelName)
+	chatReq.Messages = insertAfterSystemMessages(kept, summaryMessage)
+	// 摘要后的消息只存在于解析后的请求中，不能再透传原始请求体
+	info.RequestRewritten = true
+	common.SetContextKey(c, constant.ContextKeyConversationSummarized, len(dropped))
+	logger.LogInfo(c, fmt.Sprintf("conversation summary: replaced %d messages with a summary, history tokens %d -> %d",
+		len(dropped), historyTokens, tokens))
+}
+
+func conversationSummaryMessage(summary string) dto.Message {
+	return dto.Message{Role: "system", Content: "Summary of the earlier conversation:\n" + summary}
+}
+
+func insertAfterSystemMessages(messages []dto.Message, message dto.Message) []dto.Message {
+	i := 0
+	for i < len(messages) && (messages[i].Role == "system" || messages[i].Role == "developer") {
+		i++
+	}
+	result := make([]dto.Message, 0, len(messages)+1)
+	result = append(result, messages[:i]...)
+	result = append(result, message)
+	return append(result, messages[i:]...)
+}
+
+// summarizeAndCharge 生成一段对话的摘要，并按摘要模型的倍率向用户计费。
+// 相同对话记录的摘要会被缓存，命中缓存时不再请求摘要模型，也不重复计费
+func summarizeAndCharge(c *gin.Context, info *relaycommon.RelayInfo, endpoint summaryEndpoint, messages []dto.Message) (string, error) {
+	if endpoint.BaseURL == "" || endpoint.Model == "" {
+		return "", errors.New("summary model is not configured")
+	}
+	transcript := conversationSummaryTranscript(messages)
+	if transcript == "" {
+		return "", errors.New("nothing to summarize")
+	}
+	cache := getConversationSummaryCache()
+	cacheKey := conversationSummaryCacheKey(endpoint.Model, transcript)
+	if summary, found, err := cache.Get(cacheKey); err == nil && found {
+		return summary, nil
+	}
+
+	summary, usage, err := summarizeConversation(c.Request.Context(), endpoint, transcript)
+	if err != nil {
+		return "", err
+	}
+	chargeConversationSummary(c, info, endpoint.Model, transcript, summary, usage)
+	if err := cache.SetWithTTL(cacheKey, summary, conversationSummaryCacheTTL); err != nil {
+		logger.LogWarn(c, fmt.Sprintf("cache conversation summary failed: %s", err.Error()))
+	}
+	return summary, nil
+}
+
+// chargeConversationSummary 按摘要模型的价格或倍率与用户分组倍率扣除额度并记录消费日志，
+// 上游未返回用量时按对话记录与摘要估算 token
+func chargeConversationSummary(c *gin.Context, info *relaycommon.RelayInfo, modelName string, transcript string, summary string, usage *dto.Usage) {
+	promptTokens, completionTokens := 0, 0
+	if usage != nil {
+		promptTokens, completionTokens = usage.PromptTokens, usage.CompletionTokens
+	}
+	if promptTokens == 0 && completionTokens == 0 {
+		promptTokens = CountTextToken(transcript, modelName)
+		completionTokens = CountTextToken(summary, modelName)
+	}
+	groupRatio := ratio_setting.GetGroupRatio(info.UsingGroup)
+	if userGroupRatio, ok := ratio_setting.GetGroupGroupRatio(info.UserGroup, info.UsingGroup); ok {
+		groupRatio = userGroupRatio
+	}
+	quota := conversationSummaryQuota(modelName, promptTokens, completionTokens, groupRatio)
+	if quota <= 0 {
+		return
+	}
+	if err := PostConsumeQuota(info, quota, 0, false); err != nil {
+		logger.LogError(c, fmt.Sprintf("failed to charge conversation summary: %s", err.Error()))
+		return
+	}
+	model.UpdateUserUsedQuotaAndRequestCount(info.UserId, quota)
+	model.RecordConsumeLog(c, info.UserId, model.RecordConsumeLogParams{
+		PromptTokens:     promptTokens,
+		CompletionTokens: completionTokens,
+		ModelName:        modelName,
+		TokenName:        c.GetString("token_name"),
+		Quota:            quota,
+		Content:          "Conversation summary",
+		TokenId:          info.TokenId,
+		Group:            info.UsingGroup,
+		Other: map[string]any{
+			"conversation_summary": true,
+			"relay_model":          info.OriginModelName,
+			"group_ratio":          groupRatio,
+		},
+	})
+}
+
+func conversationSummaryQuota(modelName string, promptTokens int, completionTokens int, groupRatio float64) int {
+	if price, usePrice := ratio_setting.GetModelPrice(modelName, false); usePrice {
+		return int(decimal.NewFromFloat(price).
+			Mul(decimal.NewFromFloat(common.QuotaPerUnit)).
+			Mul(decimal.NewFromFloat(groupRatio)).
+			Round(0).
+			IntPart())
+	}
+	modelRatio, _, _ := ratio_setting.GetModelRatio(modelName)
+	tokens := decimal.NewFromInt(int64(promptTokens)).
+		Add(decimal.NewFromInt(int64(completionTokens)).Mul(decimal.NewFromFloat(ratio_setting.GetCompletionRatio(modelName))))
+	return int(tokens.
+		Mul(decimal.NewFromFloat(modelRatio)).
+		Mul(decimal.NewFromFloat(groupRatio)).
+		Ceil().
+		IntPart())
+}
+
+func getConversationSummaryCache() *cachex.HybridCache[string] {
+	conversationSummaryCacheOnce.Do(func() {
+		conversationSummaryCache = cachex.NewHybridCache[string](cachex.HybridCacheConfig[string]{
+			Namespace: cachex.Namespace(common.RedisKey(conversationSummaryCacheNamespace)),
+			Redis:     common.RDB,
+			RedisEnabled: func() bool {
+				return common.RedisEnabled && common.RDB != nil
+			},
+			RedisCodec: cachex.StringCodec{},
+			Memory: func() *hot.HotCache[string, string] {
+				return hot.NewHotCache[string, string](hot.LRU, conversationSummaryCacheCapacity).
+					WithTTL(conversationSummaryCacheTTL).
+					WithJanitor().
+					Build()
+			},
+		})
+	})
+	return conversationSummaryCache
+}
+
+func conversationSummaryCacheKey(modelName string, transcript string) string {
+	sum := sha256.Sum256([]byte(modelName + "\x00" + transcript))
+	return hex.EncodeToString(sum[:])
+}
+
+// conversationSummaryTranscript 将待摘要的消息拼接为对话记录，超长时保留较新的部分
+func conversationSummaryTranscript(messages []dto.Message) string {
+	var transcript strings.Builder
+	for i := range messages {
+		text := messages[i].StringContent()
+		if text == "" && len(messages[i].ToolCalls) > 0 {
+			text = "[tool calls] " + string(messages[i].ToolCalls)
+		}
+		if text == "" {
+			continue
+		}
+		transcript.WriteString(messages[i].Role + ": " + text + "\n\n")
+	}
+	prompt := transcript.String()
+	if utf8.RuneCountInString(prompt) > conversationSummaryTranscriptMaxRunes {
+		runes := []rune(prompt)
+		prompt = string(runes[len(runes)-conversationSummaryTranscriptMaxRunes:])
+	}
+	return prompt
+}
+
+// summarizeConversation 调用低价模型总结一段对话记录，返回摘要与上游报告的用量
+func summarizeConversation(ctx context.Context, endpoint summaryEndpoint, prompt string) (string, *dto.Usage, error) {
+	request := map[string]any{
+		"model": endpoint.Model,
+		"messages": []map[string]string{
+			{"role": "system", "content": "Summarize the following earlier part of a conversation between a user and an assistant. " +
+				"Keep facts, decisions, names, numbers and open questions that later turns may rely on. Reply with the summary only."},
+			{"role": "user", "content": prompt},
+		},
+		"temperature": 0,
+		"stream":      false,
+	}
+	payload, err := common.Marshal(request)
+	if err != nil {
+		return "", nil, err
+	}
+
+	timeout := endpoint.Timeout
+	if timeout <= 0 {
+		timeout = 15 * time.Second
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	url := strings.TrimSuffix(endpoint.BaseURL, "/") + "/v1/chat/completions"
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(payload))
+	if err != nil {
+		return "", nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if endpoint.APIKey != "" {
+		req.Header.Set("Authorization", "Bearer "+endpoint.APIKey)
+	}
+	resp, err := GetHttpClient().Do(req)
+	if err != nil {
+		return "", nil, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return "", nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", nil, fmt.Errorf("summary model returned status %d", resp.StatusCode)
+	}
+	var textResponse dto.OpenAITextResponse
+	if err = common.Unmarshal(body, &textResponse); err != nil {
+		return "", nil, err
+	}
+	if len(textResponse.Choices) == 0 {
+		return "", nil, errors.New("summary model returned no choices")
+	}
+	summary := strings.TrimSpace(textResponse.Choices[0].Message.StringContent())
+	if summary == "" {
+		return "", nil, errors.New("summary model returned an empty summary")
+	}
+	return summary, &textResponse.Usage, nil
+}
@@ -0,0 +1,47 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/QuantumNous/new-api/dto"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInsertAfterSystemMessages(t *testing.T) {
+	messages := []dto.Message{{Role: "system"}, {Role: "developer"}, {Role: "user"}}
+	result := insertAfterSystemMessages(messages, dto.Message{Role: "system", Content: "summary"})
+	require.Equal(t, []string{"system", "developer", "system", "user"}, messageRoles(result))
+}
+
+func TestConversationSummaryKeepsToolCallPairs(t *testing.T) {
+	messages := []dto.Message{
+		{Role: "system", Content: "be brief"},
+		{Role: "user", Content: "first question"},
+		{Role: "assistant", Content: "first answer"},
+		{Role: "user", Content: "look it up"},
+		{Role: "assistant", Content: "", ToolCalls: []byte(`[{"id":"call_1","type":"function","function":{"name":"f","arguments":"{}"}}]`)},
+		{Role: "tool", Content: "result", ToolCallId: "call_1"},
+	}
+	// 保留最近一条消息时，工具结果与其工具调用一起保留
+	kept, dropped, _ := planContextTruncation(messages, estimateMessagesTokens(messages, "claude-3-haiku"), 0, 1, "claude-3-haiku")
+	require.Equal(t, []string{"system", "assistant", "tool"}, messageRoles(kept))
+	require.Len(t, dropped, 3)
+
+	result := insertAfterSystemMessages(kept, conversationSummaryMessage("user asked two questions"))
+	require.Equal(t, []string{"system", "system", "assistant", "tool"}, messageRoles(result))
+	require.Contains(t, result[1].StringContent(), "user asked two questions")
+}
+
+func TestConversationSummaryTranscriptAndCacheKey(t *testing.T) {
+	messages := []dto.Message{
+		{Role: "user", Content: "first question"},
+		{Role: "assistant", Content: "", ToolCalls: []byte(`[{"id":"call_1"}]`)},
+		{Role: "tool", Content: ""},
+	}
+	transcript := conversationSummaryTranscript(messages)
+	require.Equal(t, "user: first question\n\nassistant: [tool calls] [{\"id\":\"call_1\"}]\n\n", transcript)
+
+	// 同一模型与对话记录得到相同的缓存键，换模型后不复用
+	require.Equal(t, conversationSummaryCacheKey("m", transcript), conversationSummaryCacheKey("m", transcript))
+	require.NotEqual(t, conversationSummaryCacheKey("m", transcript), conversationSummaryCacheKey("n", transcript))
+}
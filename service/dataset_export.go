@@ -0,0 +1,393 @@
+package service
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/dto"
+	"github.com/QuantumNous/new-api/logger"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/setting/system_setting"
+
+	"github.com/bytedance/gopkg/util/gopool"
+)
+
+// 训练数据集导出：每个经兼容层保存的响应是一段以 assistant 回复结尾的完整对话。
+// sft 格式每段对话一行 {"messages", "tools"}；preference 格式把相同上文的好评与差评回复配成一对，
+// 每对一行 {"input", "preferred_output", "non_preferred_output"}，未评价或找不到配对的对话计入 skipped。
+// 任务在收到请求的节点后台执行，结果与统计写回 DatasetExport
+const datasetExportBatchSize = 200
+
+// datasetMessage 训练样本中的消息，只保留微调接口接受的字段
+type datasetMessage struct {
+	Role       string          `json:"role"`
+	Content    any             `json:"content"`
+	Name       *string         `json:"name,omitempty"`
+	ToolCalls  json.RawMessage `json:"tool_calls,omitempty"`
+	ToolCallId string          `json:"tool_call_id,omitempty"`
+}
+
+// datasetSample 一段对话转换后的训练样本
+type datasetSample struct {
+	Messages []datasetMessage
+	Tools    []any
+	Rating   int
+}
+
+type datasetSFTLine struct {
+	Messages []datasetMessage `json:"messages"`
+	Tools    []any            `json:"tools,omitempty"`
+}
+
+type datasetPreferenceInput struct {
+	Messages []datasetMessage `json:"messages"`
+	Tools    []any            `json:"tools,omitempty"`
+}
+
+type datasetPreferenceLine struct {
+	Input              datasetPreferenceInput `json:"input"`
+	PreferredOutput    []datasetMessage       `json:"preferred_output"`
+	NonPreferredOutput []datasetMessage       `json:"non_preferred_output"`
+}
+
+// StartDatasetExport 校验参数并创建导出任务，任务在后台执行
+func StartDatasetExport(createdBy int, format string, destination string, filter model.DatasetResponseFilter, redactPII bool) (*model.DatasetExport, error) {
+	if !model.IsValidDatasetExportFormat(format) {
+		return nil, fmt.Errorf("format must be %s or %s", model.DatasetExportFormatSFT, model.DatasetExportFormatPreference)
+	}
+	if !model.IsValidDatasetExportDestination(destination) {
+		return nil, fmt.Errorf("destination must be %s or %s", model.DatasetExportDestinationFile, model.DatasetExportDestinationS3)
+	}
+	if destination == model.DatasetExportDestinationS3 && !system_setting.GetDatasetExportSettings().S3Configured() {
+		return nil, errors.New("S3 bucket and credentials are not configured")
+	}
+	if ResponsesStoreBackend() != ResponsesStoreDB {
+		return nil, errors.New("dataset export requires conversations stored in the database (RESPONSES_STORE=db)")
+	}
+	filterData, err := common.Marshal(filter)
+	if err != nil {
+		return nil, err
+	}
+	export := &model.DatasetExport{
+		CreatedBy:   createdBy,
+		Format:      format,
+		Destination: destination,
+		Filter:      string(filterData),
+		RedactPII:   redactPII,
+		Status:      model.DatasetExportStatusPending,
+		CreatedAt:   common.GetTimestamp(),
+	}
+	if err := model.CreateDatasetExport(export); err != nil {
+		return nil, err
+	}
+	job := *export
+	gopool.Go(func() {
+		runDatasetExport(&job, filter)
+	})
+	return export, nil
+}
+
+func runDatasetExport(export *model.DatasetExport, filter model.DatasetResponseFilter) {
+	ctx := context.Background()
+	export.Status = model.DatasetExportStatusRunning
+	if err := model.UpdateDatasetExport(export); err != nil {
+		logger.LogError(ctx, fmt.Sprintf("update dataset export %d failed: %s", export.Id, err.Error()))
+	}
+	err := executeDatasetExport(ctx, export, filter)
+	export.CompletedAt = common.GetTimestamp()
+	if err != nil {
+		export.Status = model.DatasetExportStatusFailed
+		export.Error = err.Error()
+		logger.LogWarn(ctx, fmt.Sprintf("dataset export %d failed: %s", export.Id, err.Error()))
+	} else {
+		export.Status = model.DatasetExportStatusSucceeded
+	}
+	if err := model.UpdateDatasetExport(export); err != nil {
+		logger.LogError(ctx, fmt.Sprintf("update dataset export %d failed: %s", export.Id, err.Error()))
+	}
+}
+
+func executeDatasetExport(ctx context.Context, export *model.DatasetExport, filter model.DatasetResponseFilter) error {
+	tmp, err := os.CreateTemp("", fmt.Sprintf("dataset-%d-*.jsonl", export.Id))
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = tmp.Close()
+		_ = os.Remove(tmp.Name())
+	}()
+
+	limit := int64(system_setting.GetDatasetExportSettings().MaxFileSizeMB) << 20
+	writer := &datasetWriter{w: bufio.NewWriter(tmp), limit: limit}
+	var redactor *piiRedactor
+	if export.RedactPII {
+		redactor = newPIIRedactor()
+	}
+	builder := newDatasetBuilder(export.Format, writer, redactor)
+	now := common.GetTimestamp()
+	afterId := 0
+	for !writer.truncated {
+		responses, err := model.GetDatasetStoredResponses(&filter, afterId, datasetExportBatchSize, now)
+		if err != nil {
+			return err
+		}
+		for _, stored := range responses {
+			afterId = stored.Id
+			if filter.Match(stored) {
+				if err := builder.add(stored); err != nil {
+					return err
+				}
+			}
+		}
+		if len(responses) < datasetExportBatchSize {
+			break
+		}
+	}
+	if err := builder.finish(); err != nil {
+		return err
+	}
+	if err := writer.w.Flush(); err != nil {
+		return err
+	}
+
+	export.Scanned = builder.scanned
+	export.Skipped = builder.skipped
+	export.Records = writer.records
+	export.Bytes = writer.bytes
+	export.Truncated = writer.truncated
+	if redactor != nil {
+		if data, err := common.Marshal(redactor.counts); err == nil {
+			export.Redactions = string(data)
+		}
+	}
+	if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	filename := fmt.Sprintf("dataset-%d-%s.jsonl", export.Id, export.Format)
+	if export.Destination == model.DatasetExportDestinationS3 {
+		export.Location, err = uploadDatasetToS3(ctx, filename, tmp, writer.bytes)
+		return err
+	}
+	file := &model.File{
+		UserId:   export.CreatedBy,
+		Filename: filename,
+		Purpose:  "fine-tune",
+	}
+	// 写入时已按上限截断，这里只需留出余量
+	if err := SaveGatewayFile(file, tmp, writer.bytes+1); err != nil {
+		return err
+	}
+	export.FileId = file.Id
+	export.Location = "/v1/files/" + file.Id + "/content"
+	return nil
+}
+
+// datasetWriter 逐行写入 JSONL，写入某行会超出大小上限时停止写入并标记 truncated
+type datasetWriter struct {
+	w         *bufio.Writer
+	limit     int64
+	bytes     int64
+	records   int
+	truncated bool
+}
+
+func (w *datasetWriter) writeLine(v any) error {
+	if w.truncated {
+		return nil
+	}
+	data, err := common.Marshal(v)
+	if err != nil {
+		return err
+	}
+	if w.limit > 0 && w.bytes+int64(len(data))+1 > w.limit {
+		w.truncated = true
+		return nil
+	}
+	if _, err := w.w.Write(data); err != nil {
+		return err
+	}
+	if err := w.w.WriteByte('\n'); err != nil {
+		return err
+	}
+	w.bytes += int64(len(data)) + 1
+	w.records++
+	return nil
+}
+
+// preferenceGroup 上文相同的一组回复
+type preferenceGroup struct {
+	prompt   []datasetMessage
+	tools    []any
+	chosen   [][]datasetMessage
+	rejected [][]datasetMessage
+}
+
+// datasetBuilder 把存储响应转换为训练样本写入 writer；preference 格式在全部读取后配对
+type datasetBuilder struct {
+	format   string
+	writer   *datasetWriter
+	redactor *piiRedactor
+	scanned  int
+	skipped  int
+	groups   map[string]*preferenceGroup
+	order    []string
+}
+
+func newDatasetBuilder(format string, writer *datasetWriter, redactor *piiRedactor) *datasetBuilder {
+	return &datasetBuilder{
+		format:   format,
+		writer:   writer,
+		redactor: redactor,
+		groups:   make(map[string]*preferenceGroup),
+	}
+}
+
+func (b *datasetBuilder) add(stored *model.StoredResponse) error {
+	b.scanned++
+	sample, err := buildDatasetSample(stored, b.redactor)
+	if err != nil || len(sample.Messages) < 2 || sample.Messages[len(sample.Messages)-1].Role != "assistant" {
+		b.skipped++
+		return nil
+	}
+	if b.format == model.DatasetExportFormatSFT {
+		return b.writer.writeLine(datasetSFTLine{Messages: sample.Messages, Tools: sample.Tools})
+	}
+	if sample.Rating == 0 {
+		b.skipped++
+		return nil
+	}
+	last := len(sample.Messages) - 1
+	prompt := sample.Messages[:last]
+	keyData, err := common.Marshal(datasetPreferenceInput{Messages: prompt, Tools: sample.Tools})
+	if err != nil {
+		return err
+	}
+	key := string(keyData)
+	group, ok := b.groups[key]
+	if !ok {
+		group = &preferenceGroup{prompt: prompt, tools: sample.Tools}
+		b.groups[key] = group
+		b.order = append(b.order, key)
+	}
+	output := []datasetMessage{sample.Messages[last]}
+	if sample.Rating > 0 {
+		group.chosen = append(group.chosen, output)
+	} else {
+		group.rejected = append(group.rejected, output)
+	}
+	return nil
+}
+
+// finish 写出配对的偏好样本，多出的好评或差评回复计入 skipped
+func (b *datasetBuilder) finish() error {
+	if b.format != model.DatasetExportFormatPreference {
+		return nil
+	}
+	for _, key := range b.order {
+		group := b.groups[key]
+		pairs := min(len(group.chosen), len(group.rejected))
+		b.skipped += len(group.chosen) + len(group.rejected) - 2*pairs
+		for i := 0; i < pairs; i++ {
+			err := b.writer.writeLine(datasetPreferenceLine{
+				Input:              datasetPreferenceInput{Messages: group.prompt, Tools: group.tools},
+				PreferredOutput:    group.chosen[i],
+				NonPreferredOutput: group.rejected[i],
+			})
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// buildDatasetSample 把存储响应还原为训练样本：instructions 作为 system 消息，工具定义转换为 Chat Completions 格式
+func buildDatasetSample(stored *model.StoredResponse, redactor *piiRedactor) (*datasetSample, error) {
+	chain, err := storedConversationChain(stored)
+	if err != nil {
+		return nil, err
+	}
+	var messages []dto.Message
+	for _, turn := range chain {
+		messages = append(messages, turn.messages...)
+	}
+	sample := &datasetSample{Rating: stored.Rating, Tools: storedResponseTools(stored.Response)}
+	if instructions := storedResponseInstructions(stored.Response); instructions != "" {
+		sample.Messages = append(sample.Messages, datasetMessage{Role: "system", Content: redactor.redactText(instructions)})
+	}
+	for _, message := range messages {
+		converted := datasetMessage{
+			Role:       message.Role,
+			Content:    redactor.redactContent(message.Content),
+			Name:       message.Name,
+			ToolCalls:  message.ToolCalls,
+			ToolCallId: message.ToolCallId,
+		}
+		if len(message.ToolCalls) > 0 && redactor != nil {
+			converted.ToolCalls = json.RawMessage(redactor.redact(string(message.ToolCalls)))
+		}
+		sample.Messages = append(sample.Messages, converted)
+	}
+	return sample, nil
+}
+
+// storedResponseTools 返回存储的响应对象中的函数工具，转换为 Chat Completions 的工具格式
+func storedResponseTools(response string) []any {
+	if response == "" {
+		return nil
+	}
+	var parsed struct {
+		Tools []map[string]any `json:"tools"`
+	}
+	if err := common.UnmarshalJsonStr(response, &parsed); err != nil {
+		return nil
+	}
+	tools := make([]any, 0, len(parsed.Tools))
+	for _, tool := range parsed.Tools {
+		if tool["type"] != "function" {
+			continue
+		}
+		if _, ok := tool["function"]; ok {
+			tools = append(tools, tool)
+			continue
+		}
+		function := map[string]any{"name": tool["name"]}
+		for _, key := range []string{"description", "parameters", "strict"} {
+			if value, ok := tool[key]; ok {
+				function[key] = value
+			}
+		}
+		tools = append(tools, map[string]any{"type": "function", "function": function})
+	}
+	if len(tools) == 0 {
+		return nil
+	}
+	return tools
+}
+
+// uploadDatasetToS3 以 PutObject 上传数据集文件，返回 s3://bucket/key
+func uploadDatasetToS3(ctx context.Context, filename string, body io.ReadSeeker, size int64) (string, error) {
+	settings := system_setting.GetDatasetExportSettings()
+	bucket := s3Bucket{
+		Endpoint:     settings.S3Endpoint,
+		Region:       settings.S3Region,
+		Bucket:       settings.S3Bucket,
+		Prefix:       settings.S3Prefix,
+		AccessKeyId:  settings.S3AccessKeyId,
+		AccessSecret: settings.S3AccessSecret,
+	}
+	key := bucket.objectKey(filename)
+	resp, err := bucket.do(ctx, http.MethodPut, key, body, size, "application/jsonl")
+	if err != nil {
+		return "", err
+	}
+	resp.Body.Close()
+	return fmt.Sprintf("s3://%s/%s", settings.S3Bucket, key), nil
+}
@@ -0,0 +1,76 @@
+package service
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/dto"
+	"github.com/QuantumNous/new-api/model"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPIIRedactor(t *testing.T) {
+	r := newPIIRedactor()
+	text := r.redact("mail bob@example.com or call +1 415-555-0100, card 4111 1111 1111 1111, key sk-abcdefghijklmnopqrstuv from 10.0.0.12")
+	assert.Equal(t, "mail [EMAIL] or call [PHONE], card [CARD], key [SECRET] from [IP]", text)
+	assert.Equal(t, map[string]int{"email": 1, "phone": 1, "card": 1, "secret": 1, "ip": 1}, r.counts)
+
+	// 不满足 Luhn 校验的长数字与普通年份不被替换
+	assert.Equal(t, "order 1234567890123 in 2024 2025", r.redact("order 1234567890123 in 2024 2025"))
+}
+
+func TestExecuteDatasetExport(t *testing.T) {
+	t.Cleanup(func() {
+		model.DB.Exec("DELETE FROM stored_responses")
+		model.DB.Exec("DELETE FROM files")
+	})
+	t.Setenv("RESPONSES_STORE", ResponsesStoreDB)
+
+	question := dto.Message{Role: "user", Content: "my email is bob@example.com, what is 2+2?"}
+	store := func(id string, answer string, rating int, tags string) {
+		data, err := common.Marshal([]dto.Message{question, {Role: "assistant", Content: answer}})
+		require.NoError(t, err)
+		stored := &model.StoredResponse{
+			ResponseId: id,
+			UserId:     1,
+			ModelName:  "gpt-4o",
+			Messages:   string(data),
+			Response:   `{"instructions":"be brief","tools":[{"type":"function","name":"add","parameters":{"type":"object"}},{"type":"web_search"}]}`,
+			Rating:     rating,
+			Tags:       tags,
+		}
+		require.NoError(t, createStoredResponse(stored))
+	}
+	store("resp_good", "4", 1, "math")
+	store("resp_bad", "5", -1, "math")
+	store("resp_other", "four", 0, "chat")
+
+	export := &model.DatasetExport{Id: 1, CreatedBy: 1, Format: model.DatasetExportFormatSFT, RedactPII: true}
+	require.NoError(t, executeDatasetExport(context.Background(), export, model.DatasetResponseFilter{Tags: []string{"math"}}))
+	assert.Equal(t, 2, export.Scanned)
+	assert.Equal(t, 2, export.Records)
+	assert.JSONEq(t, `{"email":2}`, export.Redactions)
+	_, content, err := ReadGatewayFile(export.FileId, 1)
+	require.NoError(t, err)
+	assert.Equal(t, export.Bytes, int64(len(content)))
+	lines := strings.Split(strings.TrimSpace(string(content)), "\n")
+	require.Len(t, lines, 2)
+	assert.JSONEq(t, `{"messages":[{"role":"system","content":"be brief"},{"role":"user","content":"my email is [EMAIL], what is 2+2?"},{"role":"assistant","content":"4"}],"tools":[{"type":"function","function":{"name":"add","parameters":{"type":"object"}}}]}`, lines[0])
+
+	export = &model.DatasetExport{Id: 2, CreatedBy: 1, Format: model.DatasetExportFormatPreference}
+	require.NoError(t, executeDatasetExport(context.Background(), export, model.DatasetResponseFilter{}))
+	assert.Equal(t, 3, export.Scanned)
+	assert.Equal(t, 1, export.Records)
+	assert.Equal(t, 1, export.Skipped)
+	_, content, err = ReadGatewayFile(export.FileId, 1)
+	require.NoError(t, err)
+	var line datasetPreferenceLine
+	require.NoError(t, common.Unmarshal(content, &line))
+	assert.Len(t, line.Input.Messages, 2)
+	assert.Equal(t, "4", line.PreferredOutput[0].Content)
+	assert.Equal(t, "5", line.NonPreferredOutput[0].Content)
+}
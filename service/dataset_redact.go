@@ -0,0 +1,126 @@
+package service
+
+import (
+	"regexp"
+	"strings"
+)
+
+// 导出训练数据集时的敏感信息脱敏：按顺序将密钥、邮箱、银行卡号、电话号码与 IPv4 地址替换为占位符，
+// 统计各类替换次数。规则偏保守，只匹配格式明确的片段，避免误伤代码与普通数字
+type piiRule struct {
+	kind        string
+	placeholder string
+	pattern     *regexp.Regexp
+	valid       func(match string) bool
+}
+
+var piiRules = []piiRule{
+	{
+		kind:        "secret",
+		placeholder: "[SECRET]",
+		pattern:     regexp.MustCompile(`\b(?:sk|pk|rk)-[A-Za-z0-9_-]{16,}|\bAKIA[0-9A-Z]{16}\b|\bgh[pousr]_[A-Za-z0-9]{30,}\b|\bBearer\s+[A-Za-z0-9._~+/=-]{16,}`),
+	},
+	{
+		kind:        "email",
+		placeholder: "[EMAIL]",
+		pattern:     regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9-]+(?:\.[A-Za-z0-9-]+)*\.[A-Za-z]{2,}`),
+	},
+	{
+		kind:        "card",
+		placeholder: "[CARD]",
+		pattern:     regexp.MustCompile(`\b\d(?:[ -]?\d){12,18}\b`),
+		valid:       luhnValid,
+	},
+	{
+		kind:        "phone",
+		placeholder: "[PHONE]",
+		pattern:     regexp.MustCompile(`\+\d{1,3}[ .-]?\(?\d{1,4}\)?(?:[ .-]?\d{2,4}){2,4}\b|\(?\b\d{3}\)?[ .-]\d{3}[ .-]\d{4}\b|\b1[3-9]\d{9}\b`),
+	},
+	{
+		kind:        "ip",
+		placeholder: "[IP]",
+		pattern:     regexp.MustCompile(`\b(?:(?:25[0-5]|2[0-4]\d|1\d\d|[1-9]?\d)\.){3}(?:25[0-5]|2[0-4]\d|1\d\d|[1-9]?\d)\b`),
+	},
+}
+
+// piiRedactor 脱敏文本并累计各类替换次数
+type piiRedactor struct {
+	counts map[string]int
+}
+
+func newPIIRedactor() *piiRedactor {
+	return &piiRedactor{counts: make(map[string]int)}
+}
+
+func (r *piiRedactor) redact(text string) string {
+	if text == "" {
+		return text
+	}
+	for _, rule := range piiRules {
+		text = rule.pattern.ReplaceAllStringFunc(text, func(match string) string {
+			if rule.valid != nil && !rule.valid(match) {
+				return match
+			}
+			r.counts[rule.kind]++
+			return rule.placeholder
+		})
+	}
+	return text
+}
+
+// redactText 脱敏文本，未开启脱敏（redactor 为 nil）时原样返回
+func (r *piiRedactor) redactText(text string) string {
+	if r == nil {
+		return text
+	}
+	return r.redact(text)
+}
+
+// redactContent 脱敏消息内容：字符串内容与多模态内容中的 text 部分
+func (r *piiRedactor) redactContent(content any) any {
+	if r == nil {
+		return content
+	}
+	switch v := content.(type) {
+	case string:
+		return r.redact(v)
+	case []any:
+		parts := make([]any, 0, len(v))
+		for _, part := range v {
+			item, ok := part.(map[string]any)
+			if !ok {
+				parts = append(parts, part)
+				continue
+			}
+			copied := make(map[string]any, len(item))
+			for key, value := range item {
+				copied[key] = value
+			}
+			if text, ok := copied["text"].(string); ok {
+				copied["text"] = r.redact(text)
+			}
+			parts = append(parts, copied)
+		}
+		return parts
+	}
+	return content
+}
+
+// luhnValid 校验银行卡号的 Luhn 校验位
+func luhnValid(number string) bool {
+	digits := strings.NewReplacer(" ", "", "-", "").Replace(number)
+	sum := 0
+	double := false
+	for i := len(digits) - 1; i >= 0; i-- {
+		d := int(digits[i] - '0')
+		if double {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		double = !double
+	}
+	return sum%10 == 0
+}
@@ -0,0 +1,353 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/constant"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+	"github.com/QuantumNous/new-api/setting/system_setting"
+)
+
+// doctor 命令：启动前检查配置、数据库连接与结构版本、Redis、渠道连通性与系统设置的一致性，
+// 逐项输出结果与修复建议。存在 error 级别的问题时以非零状态码退出
+
+const (
+	DoctorLevelOK    = "ok"
+	DoctorLevelWarn  = "warn"
+	DoctorLevelError = "error"
+
+	doctorChannelTimeout     = 5 * time.Second
+	doctorChannelConcurrency = 8
+)
+
+type DoctorFinding struct {
+	Level   string
+	Check   string
+	Message string
+	Hint    string
+}
+
+type DoctorReport struct {
+	Findings []DoctorFinding
+}
+
+func (r *DoctorReport) add(level string, check string, message string, hint string) {
+	r.Findings = append(r.Findings, DoctorFinding{Level: level, Check: check, Message: message, Hint: hint})
+}
+
+// HasErrors 是否存在 error 级别的问题
+func (r *DoctorReport) HasErrors() bool {
+	for _, finding := range r.Findings {
+		if finding.Level == DoctorLevelError {
+			return true
+		}
+	}
+	return false
+}
+
+// Print 按检查顺序输出结果，最后输出汇总
+func (r *DoctorReport) Print(w io.Writer) {
+	counts := make(map[string]int)
+	for _, finding := range r.Findings {
+		counts[finding.Level]++
+		fmt.Fprintf(w, "[%-5s] %-8s %s\n", strings.ToUpper(finding.Level), finding.Check, finding.Message)
+		if finding.Hint != "" {
+			fmt.Fprintf(w, "        %-8s -> %s\n", "", finding.Hint)
+		}
+	}
+	fmt.Fprintf(w, "\n%d ok, %d warnings, %d errors\n", counts[DoctorLevelOK], counts[DoctorLevelWarn], counts[DoctorLevelError])
+}
+
+// RunDoctor 执行全部检查并输出报告，没有 error 级别的问题时返回 true
+func RunDoctor(w io.Writer) bool {
+	report := &DoctorReport{}
+	checkDoctorConfig(report)
+	if checkDoctorDatabase(report) {
+		model.InitOptionMap()
+		checkDoctorOptions(report)
+		checkDoctorChannels(report)
+	}
+	checkDoctorRedis(report)
+	report.Print(w)
+	return !report.HasErrors()
+}
+
+// checkDoctorConfig 检查环境变量之间的组合是否合理
+func checkDoctorConfig(r *DoctorReport) {
+	const check = "config"
+	usingSQLite := os.Getenv("SQL_DSN") == "" || strings.HasPrefix(os.Getenv("SQL_DSN"), "local")
+	redisConfigured := os.Getenv("REDIS_CONN_STRING") != "" || os.Getenv("REDIS_CLUSTER_ADDRS") != "" || os.Getenv("REDIS_SENTINEL_ADDRS") != ""
+	issues := len(r.Findings)
+
+	if os.Getenv("SESSION_SECRET") == "" {
+		level := DoctorLevelWarn
+		if !common.IsMasterNode {
+			level = DoctorLevelError
+		}
+		r.add(level, check, "SESSION_SECRET is not set, a random secret is generated on every start and all login sessions are lost on restart",
+			"set SESSION_SECRET to the same random string on every node")
+	}
+	if usingSQLite && !common.IsMasterNode {
+		r.add(DoctorLevelError, check, "NODE_TYPE=slave with SQLite: every node would use its own local database",
+			"point SQL_DSN of all nodes to the same MySQL or PostgreSQL database")
+	}
+	if !common.IsMasterNode && !redisConfigured {
+		r.add(DoctorLevelWarn, check, "NODE_TYPE=slave without Redis: caches, rate limits and settings are not shared between nodes",
+			"configure REDIS_CONN_STRING (or REDIS_CLUSTER_ADDRS / REDIS_SENTINEL_ADDRS) on every node")
+	}
+	if usingSQLite && os.Getenv("SQLITE_WAL_AUTOCHECKPOINT") == "0" && common.GetEnvOrDefault("SQLITE_CHECKPOINT_INTERVAL", 0) <= 0 {
+		r.add(DoctorLevelError, check, "SQLITE_WAL_AUTOCHECKPOINT=0 without SQLITE_CHECKPOINT_INTERVAL: the WAL file is never checkpointed and grows without bound",
+			"set SQLITE_CHECKPOINT_INTERVAL (seconds) or remove SQLITE_WAL_AUTOCHECKPOINT")
+	}
+	if usingSQLite && (os.Getenv("SQL_READ_DSN") != "" || os.Getenv("LOG_SQL_READ_DSN") != "") {
+		r.add(DoctorLevelWarn, check, "read replicas are ignored when the main database is SQLite",
+			"remove SQL_READ_DSN / LOG_SQL_READ_DSN or switch to MySQL or PostgreSQL")
+	}
+	if common.IsEmbeddedProfile() && !usingSQLite {
+		r.add(DoctorLevelWarn, check, "DEPLOYMENT_PROFILE=embedded is meant for a single node with SQLite, but SQL_DSN points to a database server",
+			"unset DEPLOYMENT_PROFILE for server databases")
+	}
+	if profile := os.Getenv("DEPLOYMENT_PROFILE"); strings.TrimSpace(profile) != "" && !strings.EqualFold(strings.TrimSpace(profile), common.DeploymentProfile) {
+		r.add(DoctorLevelWarn, check, fmt.Sprintf("unknown DEPLOYMENT_PROFILE %q, the default profile is used", profile),
+			"use DEPLOYMENT_PROFILE=embedded or leave it empty")
+	}
+	if dir := fileStorageDir(); dir != "" {
+		if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+			r.add(DoctorLevelError, check, fmt.Sprintf("FILE_STORAGE_DIR %s is not a directory, file uploads will fail", dir),
+				"create the directory, mounted from shared storage on every node")
+		}
+	}
+	if path := os.Getenv("GEOIP_DB_PATH"); path != "" {
+		if count, err := common.LoadGeoIPDatabase(path); err != nil || count == 0 {
+			message := fmt.Sprintf("GEOIP_DB_PATH %s contains no country ranges", path)
+			if err != nil {
+				message = fmt.Sprintf("cannot load GEOIP_DB_PATH %s: %s", path, err.Error())
+			}
+			r.add(DoctorLevelError, check, message,
+				"point GEOIP_DB_PATH to a DB-IP or IP2Location LITE country CSV (optionally .gz)")
+		}
+	}
+	switch store := strings.ToLower(strings.TrimSpace(os.Getenv("RESPONSES_STORE"))); store {
+	case "", ResponsesStoreDB, ResponsesStoreOff:
+	case ResponsesStoreRedis:
+		if !redisConfigured {
+			r.add(DoctorLevelWarn, check, "RESPONSES_STORE=redis without Redis, stored responses fall back to the database",
+				"configure Redis or set RESPONSES_STORE=db")
+		}
+	default:
+		r.add(DoctorLevelWarn, check, fmt.Sprintf("unknown RESPONSES_STORE %q, the database is used", store),
+			"use one of db, redis, off")
+	}
+
+	if len(r.Findings) == issues {
+		r.add(DoctorLevelOK, check, "environment variables are consistent", "")
+	}
+}
+
+// checkDoctorDatabase 连接主库与日志库并检查结构版本，主库可用时返回 true
+func checkDoctorDatabase(r *DoctorReport) bool {
+	const check = "database"
+	if err := model.InitDB(); err != nil {
+		r.add(DoctorLevelError, check, "cannot connect to the main database: "+common.MaskSensitiveInfo(err.Error()),
+			"check SQL_DSN and that the database server is reachable")
+		return false
+	}
+	if err := model.InitLogDB(); err != nil {
+		r.add(DoctorLevelError, check, "cannot connect to the log database: "+common.MaskSensitiveInfo(err.Error()),
+			"check LOG_SQL_DSN and that the database server is reachable")
+		return false
+	}
+	for _, target := range []string{model.MigrationTargetMain, model.MigrationTargetLog} {
+		if target == model.MigrationTargetLog && os.Getenv("LOG_SQL_DSN") == "" {
+			continue
+		}
+		dialect, version, err := model.DatabaseVersion(target)
+		if err != nil {
+			r.add(DoctorLevelError, check, fmt.Sprintf("%s database (%s) does not answer queries: %s", target, dialect, err.Error()), "")
+			return false
+		}
+		r.add(DoctorLevelOK, check, fmt.Sprintf("%s database connected: %s %s", target, dialect, version), "")
+	}
+
+	issues, err := model.SchemaIssues()
+	if err != nil {
+		r.add(DoctorLevelError, check, "cannot inspect the database schema: "+err.Error(), "")
+		return true
+	}
+	for _, issue := range issues {
+		r.add(DoctorLevelError, check, "schema is behind this version: "+issue,
+			"start a master node once to migrate automatically, or run `newapi --migrate up`")
+	}
+	if len(issues) == 0 {
+		r.add(DoctorLevelOK, check, "schema is up to date", "")
+	}
+	return true
+}
+
+// checkDoctorRedis 按当前配置连接 Redis
+func checkDoctorRedis(r *DoctorReport) {
+	const check = "redis"
+	mode, err := common.CheckRedisConnection()
+	switch {
+	case err != nil:
+		r.add(DoctorLevelError, check, fmt.Sprintf("cannot connect to Redis (%s): %s", mode, common.MaskSensitiveInfo(err.Error())),
+			"check REDIS_CONN_STRING / REDIS_CLUSTER_ADDRS / REDIS_SENTINEL_ADDRS and that Redis is reachable")
+	case mode == "":
+		r.add(DoctorLevelOK, check, "Redis is not configured, in-memory caches are used", "")
+	case common.IsEmbeddedProfile():
+		r.add(DoctorLevelOK, check, fmt.Sprintf("Redis connected (%s), used instead of the in-process cache of DEPLOYMENT_PROFILE=embedded", mode), "")
+	default:
+		r.add(DoctorLevelOK, check, fmt.Sprintf("Redis connected (%s)", mode), "")
+	}
+}
+
+// checkDoctorOptions 检查数据库中的系统设置是否前后一致，需在 InitOptionMap 之后调用
+func checkDoctorOptions(r *DoctorReport) {
+	const check = "options"
+	issues := len(r.Findings)
+
+	if system_setting.ServerAddress == "" || strings.Contains(system_setting.ServerAddress, "://localhost") {
+		r.add(DoctorLevelWarn, check, fmt.Sprintf("ServerAddress is %q, OAuth callbacks, payment notifications and passkeys use this address", system_setting.ServerAddress),
+			"set Server Address in System Settings to the public URL")
+	}
+	if common.EmailVerificationEnabled && common.SMTPServer == "" {
+		r.add(DoctorLevelError, check, "email verification is enabled but no SMTP server is configured, users cannot register",
+			"configure SMTP in System Settings or disable email verification")
+	}
+	if common.GitHubOAuthEnabled && (common.GitHubClientId == "" || common.GitHubClientSecret == "") {
+		r.add(DoctorLevelError, check, "GitHub login is enabled without a client ID or secret",
+			"fill in the GitHub OAuth app in System Settings or disable GitHub login")
+	}
+	if common.TelegramOAuthEnabled && common.TelegramBotToken == "" {
+		r.add(DoctorLevelError, check, "Telegram login is enabled without a bot token",
+			"fill in the Telegram bot in System Settings or disable Telegram login")
+	}
+	if common.WeChatAuthEnabled && common.WeChatServerAddress == "" {
+		r.add(DoctorLevelError, check, "WeChat login is enabled without a WeChat server address",
+			"fill in the WeChat server in System Settings or disable WeChat login")
+	}
+	if common.TurnstileCheckEnabled && (common.TurnstileSiteKey == "" || common.TurnstileSecretKey == "") {
+		r.add(DoctorLevelError, check, "Turnstile check is enabled without a site key or secret key, logins will fail",
+			"fill in the Turnstile keys in System Settings or disable the Turnstile check")
+	}
+	if operation_setting.PayAddress != "" && (operation_setting.EpayId == "" || operation_setting.EpayKey == "") {
+		r.add(DoctorLevelWarn, check, "an Epay address is set without a merchant ID or key, top-ups will fail",
+			"complete the payment settings or clear the Epay address")
+	}
+	if !common.GeoIPEnabled() {
+		if count, err := model.CountTokensWithCountryLimits(); err == nil && count > 0 {
+			r.add(DoctorLevelError, check, fmt.Sprintf("%d tokens have country restrictions but no GeoIP database is loaded, their requests are rejected", count),
+				"set GEOIP_DB_PATH to a DB-IP or IP2Location LITE country CSV, or clear the country restrictions")
+		}
+	}
+	if common.QuotaPerUnit <= 0 {
+		r.add(DoctorLevelError, check, fmt.Sprintf("QuotaPerUnit is %v, quota and prices cannot be converted", common.QuotaPerUnit),
+			"set QuotaPerUnit to a positive value (default 500000)")
+	}
+
+	if len(r.Findings) == issues {
+		r.add(DoctorLevelOK, check, "system settings are consistent", "")
+	}
+}
+
+// checkDoctorChannels 检查已启用渠道的上游地址是否可以连通；同一地址与代理只检查一次，
+// 收到任何 HTTP 响应即视为可达，不消耗额度
+func checkDoctorChannels(r *DoctorReport) {
+	const check = "channels"
+	channels, err := model.GetAllChannels(0, 0, true, true)
+	if err != nil {
+		r.add(DoctorLevelError, check, "cannot load channels: "+err.Error(), "")
+		return
+	}
+
+	type target struct {
+		url      string
+		proxy    string
+		channels []string
+		err      error
+	}
+	targets := make(map[string]*target)
+	enabled := 0
+	for _, channel := range channels {
+		if channel.Status != common.ChannelStatusEnabled {
+			continue
+		}
+		enabled++
+		baseURL := channel.GetBaseURL()
+		if baseURL == "" && channel.Type >= 0 && channel.Type < len(constant.ChannelBaseURLs) {
+			baseURL = constant.ChannelBaseURLs[channel.Type]
+		}
+		if baseURL == "" {
+			continue
+		}
+		proxy := channel.GetSetting().Proxy
+		key := baseURL + "|" + proxy
+		if targets[key] == nil {
+			targets[key] = &target{url: baseURL, proxy: proxy}
+		}
+		targets[key].channels = append(targets[key].channels, fmt.Sprintf("#%d %s", channel.Id, channel.Name))
+	}
+	if enabled == 0 {
+		r.add(DoctorLevelWarn, check, "no enabled channels, relay requests will fail", "add a channel in the console")
+		return
+	}
+
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, doctorChannelConcurrency)
+	for _, t := range targets {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(t *target) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			t.err = probeChannelURL(t.url, t.proxy)
+		}(t)
+	}
+	wg.Wait()
+
+	keys := make([]string, 0, len(targets))
+	for key := range targets {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	unreachable := 0
+	for _, key := range keys {
+		t := targets[key]
+		if t.err == nil {
+			continue
+		}
+		unreachable++
+		r.add(DoctorLevelWarn, check, fmt.Sprintf("%s is unreachable: %s (channels: %s)", t.url, common.MaskSensitiveInfo(t.err.Error()), strings.Join(t.channels, ", ")),
+			"check the base URL, the channel proxy and outbound network access")
+	}
+	r.add(DoctorLevelOK, check, fmt.Sprintf("%d enabled channels, %d of %d upstream addresses reachable", enabled, len(targets)-unreachable, len(targets)), "")
+}
+
+func probeChannelURL(url string, proxy string) error {
+	client, err := GetHttpClientWithProxy(proxy)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), doctorChannelTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	CloseResponseBodyGracefully(resp)
+	return nil
+}
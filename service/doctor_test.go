@@ -0,0 +1,69 @@
+package service
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/stretchr/testify/require"
+)
+
+func doctorFindings(r *DoctorReport, level string) []DoctorFinding {
+	findings := make([]DoctorFinding, 0)
+	for _, finding := range r.Findings {
+		if finding.Level == level {
+			findings = append(findings, finding)
+		}
+	}
+	return findings
+}
+
+func TestCheckDoctorConfig(t *testing.T) {
+	isMasterNode := common.IsMasterNode
+	t.Cleanup(func() { common.IsMasterNode = isMasterNode })
+	for _, key := range []string{"SQL_DSN", "REDIS_CONN_STRING", "REDIS_CLUSTER_ADDRS", "REDIS_SENTINEL_ADDRS",
+		"SQLITE_WAL_AUTOCHECKPOINT", "SQLITE_CHECKPOINT_INTERVAL", "SQL_READ_DSN", "LOG_SQL_READ_DSN", "DEPLOYMENT_PROFILE", "RESPONSES_STORE"} {
+		t.Setenv(key, "")
+	}
+
+	t.Setenv("SESSION_SECRET", "doctor-test-secret")
+	common.IsMasterNode = true
+	report := &DoctorReport{}
+	checkDoctorConfig(report)
+	require.Len(t, report.Findings, 1)
+	require.Equal(t, DoctorLevelOK, report.Findings[0].Level)
+
+	// 多节点使用 SQLite 且未配置会话密钥与 Redis
+	t.Setenv("SESSION_SECRET", "")
+	t.Setenv("SQLITE_WAL_AUTOCHECKPOINT", "0")
+	t.Setenv("RESPONSES_STORE", "redis")
+	common.IsMasterNode = false
+	report = &DoctorReport{}
+	checkDoctorConfig(report)
+	require.True(t, report.HasErrors())
+	require.Len(t, doctorFindings(report, DoctorLevelError), 3)
+	require.Len(t, doctorFindings(report, DoctorLevelWarn), 2)
+	require.Empty(t, doctorFindings(report, DoctorLevelOK))
+
+	t.Setenv("SQLITE_CHECKPOINT_INTERVAL", "300")
+	t.Setenv("SQL_DSN", "postgresql://user:pass@db:5432/oneapi")
+	t.Setenv("REDIS_CONN_STRING", "redis://localhost:6379")
+	t.Setenv("SESSION_SECRET", "doctor-test-secret")
+	report = &DoctorReport{}
+	checkDoctorConfig(report)
+	require.False(t, report.HasErrors())
+}
+
+func TestDoctorReportPrint(t *testing.T) {
+	report := &DoctorReport{}
+	report.add(DoctorLevelOK, "redis", "Redis connected (standalone)", "")
+	report.add(DoctorLevelWarn, "options", "ServerAddress is \"http://localhost:3000\"", "set Server Address in System Settings")
+
+	var buf bytes.Buffer
+	report.Print(&buf)
+	output := buf.String()
+	require.Contains(t, output, "[OK   ] redis")
+	require.Contains(t, output, "-> set Server Address in System Settings")
+	require.Contains(t, output, "1 ok, 1 warnings, 0 errors")
+	require.False(t, report.HasErrors())
+}
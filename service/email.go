@@ -0,0 +1,27 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/QuantumNous/new-api/service/mail"
+	"github.com/QuantumNous/new-api/setting/system_setting"
+)
+
+var ErrEmailTemplateDisabled = errors.New("email template is disabled")
+
+// SendTemplateEmail renders an email template with the site branding and sends it
+// through the configured provider. Receivers are separated by ";".
+func SendTemplateEmail(ctx context.Context, branding *SiteBranding, template string, receiver string, vars map[string]string) error {
+	if !system_setting.IsEmailTemplateEnabled(template) {
+		return ErrEmailTemplateDisabled
+	}
+	subject, body := RenderBrandingEmail(branding, template, vars)
+	return mail.Send(ctx, &mail.Message{
+		To:       strings.Split(receiver, ";"),
+		FromName: branding.SystemName,
+		Subject:  subject,
+		HTML:     body,
+	})
+}
@@ -0,0 +1,87 @@
+package service
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/setting/system_setting"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSendTemplateEmailDisabled(t *testing.T) {
+	settings := system_setting.GetEmailSettings()
+	origin := settings.TemplateEnabled
+	t.Cleanup(func() { settings.TemplateEnabled = origin })
+	settings.TemplateEnabled = map[string]bool{system_setting.EmailTemplateBudgetAlert: false}
+
+	require.True(t, system_setting.IsEmailTemplateEnabled(system_setting.EmailTemplateVerification))
+	require.False(t, system_setting.IsEmailTemplateEnabled(system_setting.EmailTemplateBudgetAlert))
+
+	err := SendTemplateEmail(context.Background(), &SiteBranding{SystemName: "Gateway"}, system_setting.EmailTemplateBudgetAlert, "user@example.com", nil)
+	require.ErrorIs(t, err, ErrEmailTemplateDisabled)
+}
+
+func TestRenderUsageSummaryEmail(t *testing.T) {
+	branding := &SiteBranding{SystemName: "Gateway"}
+	subject, body := RenderBrandingEmail(branding, system_setting.EmailTemplateUsageSummary, map[string]string{
+		"username": "alice",
+		"month":    "2026-09",
+		"requests": "12",
+		"quota":    "$1.00",
+	})
+	require.Equal(t, "Gateway 2026-09 用量汇总", subject)
+	require.Contains(t, body, "alice")
+	require.Contains(t, body, "12 次请求")
+}
+
+func TestPreviousMonthRange(t *testing.T) {
+	now := time.Date(2026, 1, 15, 10, 0, 0, 0, time.UTC)
+	month, start, end := previousMonthRange(now)
+	require.Equal(t, "2025-12", month)
+	require.Equal(t, time.Date(2025, 12, 1, 0, 0, 0, 0, time.UTC).Unix(), start)
+	require.Equal(t, time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC).Unix(), end)
+}
+
+func TestUsageReportPeriod(t *testing.T) {
+	// Thursday
+	now := time.Date(2026, 10, 15, 9, 0, 0, 0, time.UTC)
+	period, start, end := UsageReportPeriod("weekly", now)
+	require.Equal(t, "2026-W41", period)
+	require.Equal(t, time.Date(2026, 10, 5, 0, 0, 0, 0, time.UTC).Unix(), start)
+	require.Equal(t, time.Date(2026, 10, 12, 0, 0, 0, 0, time.UTC).Unix(), end)
+
+	period, start, end = UsageReportPeriod("monthly", now)
+	require.Equal(t, "2026-09", period)
+	require.Equal(t, time.Date(2026, 9, 1, 0, 0, 0, 0, time.UTC).Unix(), start)
+	require.Equal(t, time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC).Unix(), end)
+}
+
+func TestCheckUsageReportTarget(t *testing.T) {
+	truncate(t)
+	require.NoError(t, model.DB.Create(&model.User{Id: 1, Username: "report_admin", Role: common.RoleAdminUser, Status: common.UserStatusEnabled}).Error)
+	require.NoError(t, model.DB.Create(&model.User{Id: 2, Username: "report_user", Role: common.RoleCommonUser, Status: common.UserStatusEnabled}).Error)
+
+	sub := &model.ReportSubscription{UserId: 1, Scope: model.ReportScopeUser, TargetId: 2}
+	require.NoError(t, checkUsageReportTarget(sub))
+
+	// the reported user was disabled after subscribing
+	require.NoError(t, model.DB.Model(&model.User{}).Where("id = ?", 2).Update("status", common.UserStatusDisabled).Error)
+	require.Error(t, checkUsageReportTarget(sub))
+
+	// the reported user was deleted
+	require.NoError(t, model.DB.Unscoped().Delete(&model.User{}, 2).Error)
+	require.Error(t, checkUsageReportTarget(sub))
+
+	// the owner lost the admin role and may only read their own report
+	require.NoError(t, model.DB.Model(&model.User{}).Where("id = ?", 1).Update("role", common.RoleCommonUser).Error)
+	require.NoError(t, checkUsageReportTarget(&model.ReportSubscription{UserId: 1, Scope: model.ReportScopeUser, TargetId: 1}))
+	require.Error(t, checkUsageReportTarget(&model.ReportSubscription{UserId: 1, Scope: model.ReportScopeTenant, TargetId: 0}))
+
+	// the owner was disabled
+	require.NoError(t, model.DB.Model(&model.User{}).Where("id = ?", 1).Update("status", common.UserStatusDisabled).Error)
+	require.Error(t, checkUsageReportTarget(&model.ReportSubscription{UserId: 1, Scope: model.ReportScopeUser, TargetId: 1}))
+}
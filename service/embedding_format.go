@@ -0,0 +1,121 @@
+package service
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"math"
+
+	"github.com/QuantumNous/new-api/common"
+)
+
+const EmbeddingEncodingFormatBase64 = "base64"
+
+// NormalizeEmbeddingResponse 按客户端请求调整 OpenAI 格式的 embedding 响应：
+// dimensions > 0 时截断向量并重新做 L2 归一化；toBase64 为 true 时将浮点数组编码为 base64（小端 float32）。
+// 其余字段原样保留。
+func NormalizeEmbeddingResponse(body []byte, dimensions int, toBase64 bool) ([]byte, error) {
+	var response map[string]json.RawMessage
+	if err := common.Unmarshal(body, &response); err != nil {
+		return nil, err
+	}
+	rawData, ok := response["data"]
+	if !ok {
+		return body, nil
+	}
+	var items []map[string]json.RawMessage
+	if err := common.Unmarshal(rawData, &items); err != nil {
+		return nil, err
+	}
+	for _, item := range items {
+		rawEmbedding, ok := item["embedding"]
+		if !ok {
+			continue
+		}
+		vector, isBase64, err := decodeEmbedding(rawEmbedding)
+		if err != nil {
+			return nil, err
+		}
+		if dimensions > 0 && len(vector) > dimensions {
+			vector = TruncateEmbedding(vector, dimensions)
+		} else if isBase64 || !toBase64 {
+			// 无需改动
+			continue
+		}
+		var encoded []byte
+		if toBase64 {
+			encoded, err = common.Marshal(EncodeEmbeddingBase64(vector))
+		} else {
+			encoded, err = common.Marshal(vector)
+		}
+		if err != nil {
+			return nil, err
+		}
+		item["embedding"] = encoded
+	}
+	data, err := common.Marshal(items)
+	if err != nil {
+		return nil, err
+	}
+	response["data"] = data
+	return common.Marshal(response)
+}
+
+func decodeEmbedding(raw json.RawMessage) (vector []float64, isBase64 bool, err error) {
+	if common.GetJsonType(raw) == "string" {
+		var encoded string
+		if err = common.Unmarshal(raw, &encoded); err != nil {
+			return nil, false, err
+		}
+		vector, err = DecodeEmbeddingBase64(encoded)
+		return vector, true, err
+	}
+	err = common.Unmarshal(raw, &vector)
+	return vector, false, err
+}
+
+// TruncateEmbedding 保留前 dimensions 维并重新归一化，与 OpenAI text-embedding-3 的 dimensions 行为一致
+func TruncateEmbedding(vector []float64, dimensions int) []float64 {
+	if dimensions <= 0 || dimensions >= len(vector) {
+		return vector
+	}
+	truncated := make([]float64, dimensions)
+	copy(truncated, vector[:dimensions])
+	var norm float64
+	for _, v := range truncated {
+		norm += v * v
+	}
+	norm = math.Sqrt(norm)
+	if norm == 0 {
+		return truncated
+	}
+	for i := range truncated {
+		truncated[i] /= norm
+	}
+	return truncated
+}
+
+// EncodeEmbeddingBase64 将向量编码为 OpenAI encoding_format=base64 的格式
+func EncodeEmbeddingBase64(vector []float64) string {
+	buf := make([]byte, 0, len(vector)*4)
+	for _, v := range vector {
+		buf = binary.LittleEndian.AppendUint32(buf, math.Float32bits(float32(v)))
+	}
+	return base64.StdEncoding.EncodeToString(buf)
+}
+
+func DecodeEmbeddingBase64(encoded string) ([]float64, error) {
+	buf, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, err
+	}
+	if len(buf)%4 != 0 {
+		return nil, errors.New("invalid base64 embedding length")
+	}
+	vector := make([]float64, len(buf)/4)
+	for i := range vector {
+		vector[i] = float64(math.Float32frombits(binary.LittleEndian.Uint32(buf[i*4:])))
+	}
+	return vector, nil
+}
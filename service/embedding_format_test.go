@@ -0,0 +1,66 @@
+package service
+
+import (
+	"math"
+	"testing"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTruncateEmbeddingRenormalizes(t *testing.T) {
+	vector := TruncateEmbedding([]float64{3, 4, 12}, 2)
+	require.Len(t, vector, 2)
+	assert.InDelta(t, 0.6, vector[0], 1e-9)
+	assert.InDelta(t, 0.8, vector[1], 1e-9)
+
+	assert.Equal(t, []float64{1, 2}, TruncateEmbedding([]float64{1, 2}, 4))
+}
+
+func TestEmbeddingBase64RoundTrip(t *testing.T) {
+	encoded := EncodeEmbeddingBase64([]float64{0.5, -1.25, 3})
+	decoded, err := DecodeEmbeddingBase64(encoded)
+	require.NoError(t, err)
+	assert.Equal(t, []float64{0.5, -1.25, 3}, decoded)
+}
+
+func TestNormalizeEmbeddingResponse(t *testing.T) {
+	body := []byte(`{"object":"list","data":[{"object":"embedding","index":0,"embedding":[3,4,12]}],"model":"m","usage":{"prompt_tokens":1,"total_tokens":1}}`)
+
+	t.Run("truncate", func(t *testing.T) {
+		out, err := NormalizeEmbeddingResponse(body, 2, false)
+		require.NoError(t, err)
+		var resp struct {
+			Model string `json:"model"`
+			Data  []struct {
+				Embedding []float64 `json:"embedding"`
+			} `json:"data"`
+		}
+		require.NoError(t, common.Unmarshal(out, &resp))
+		assert.Equal(t, "m", resp.Model)
+		require.Len(t, resp.Data[0].Embedding, 2)
+		assert.InDelta(t, 1, math.Hypot(resp.Data[0].Embedding[0], resp.Data[0].Embedding[1]), 1e-9)
+	})
+
+	t.Run("base64", func(t *testing.T) {
+		out, err := NormalizeEmbeddingResponse(body, 0, true)
+		require.NoError(t, err)
+		var resp struct {
+			Data []struct {
+				Embedding string `json:"embedding"`
+			} `json:"data"`
+		}
+		require.NoError(t, common.Unmarshal(out, &resp))
+		decoded, err := DecodeEmbeddingBase64(resp.Data[0].Embedding)
+		require.NoError(t, err)
+		assert.Equal(t, []float64{3, 4, 12}, decoded)
+	})
+
+	t.Run("already base64", func(t *testing.T) {
+		encoded := `{"data":[{"embedding":"` + EncodeEmbeddingBase64([]float64{1, 2}) + `"}]}`
+		out, err := NormalizeEmbeddingResponse([]byte(encoded), 0, true)
+		require.NoError(t, err)
+		assert.JSONEq(t, encoded, string(out))
+	})
+}
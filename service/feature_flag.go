@@ -0,0 +1,15 @@
+package service
+
+import (
+	"github.com/QuantumNous/new-api/model"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+)
+
+// FeatureEnabled reports whether the named feature flag is rolled out to the relay request,
+// matched by its endpoint, group and token (or user when the request has no token).
+func FeatureEnabled(info *relaycommon.RelayInfo, name string) bool {
+	if info == nil {
+		return false
+	}
+	return model.IsFeatureFlagEnabled(name, info.RelayFormat, info.UsingGroup, info.TokenId, info.UserId)
+}
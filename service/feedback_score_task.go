@@ -0,0 +1,59 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/logger"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+
+	"github.com/bytedance/gopkg/util/gopool"
+)
+
+const feedbackScoreRefreshInterval = 5 * time.Minute
+
+var (
+	feedbackScoreRefreshOnce    sync.Once
+	feedbackScoreRefreshRunning atomic.Bool
+)
+
+// StartFeedbackScoreRefreshTask 定期汇总近期用户评价，刷新渠道选择使用的评价分数；
+// 分数缓存在各节点内存中，因此每个节点都运行该任务
+func StartFeedbackScoreRefreshTask() {
+	feedbackScoreRefreshOnce.Do(func() {
+		gopool.Go(func() {
+			logger.LogInfo(context.Background(), fmt.Sprintf("feedback score refresh task started: tick=%s", feedbackScoreRefreshInterval))
+			ticker := time.NewTicker(feedbackScoreRefreshInterval)
+			defer ticker.Stop()
+
+			runFeedbackScoreRefreshOnce()
+			for range ticker.C {
+				runFeedbackScoreRefreshOnce()
+			}
+		})
+	})
+}
+
+func runFeedbackScoreRefreshOnce() {
+	setting := operation_setting.GetFeedbackRoutingSetting()
+	if !setting.Enabled {
+		return
+	}
+	if !feedbackScoreRefreshRunning.CompareAndSwap(false, true) {
+		return
+	}
+	defer feedbackScoreRefreshRunning.Store(false)
+
+	since := int64(0)
+	if setting.WindowHours > 0 {
+		since = common.GetTimestamp() - int64(setting.WindowHours)*3600
+	}
+	if err := model.RefreshChannelFeedbackScores(since, setting.MinSamples); err != nil {
+		logger.LogWarn(context.Background(), fmt.Sprintf("feedback score refresh task failed: %v", err))
+	}
+}
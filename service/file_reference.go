@@ -0,0 +1,140 @@
+package service
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"strings"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/dto"
+	"github.com/QuantumNous/new-api/model"
+)
+
+// 请求中引用网关文件：/v1/files 上传的文件由网关管理，上游无法识别其 file_id。
+// 转发前将属于该用户的网关文件引用替换为内联内容（Chat 的 file 片段，Responses 的 input_file、input_image），
+// 文件从网关的文件存储读取，与处理请求的节点和渠道无关；内容保存在上游渠道且请求正好转发到该渠道时改为引用上游的文件 ID。
+// 其他 file_id（例如直接上传到上游的文件）保持不变
+
+// ResolveChatFileReferences 将 Chat 请求中引用网关文件的 file 片段替换为 file_data 或上游文件 ID，返回请求是否被改写
+func ResolveChatFileReferences(userId int, channelId int, request *dto.GeneralOpenAIRequest) (bool, error) {
+	resolved := false
+	for i := range request.Messages {
+		message := &request.Messages[i]
+		if message.Content == nil || message.IsStringContent() {
+			continue
+		}
+		parts := message.ParseContent()
+		changed := false
+		for j := range parts {
+			if parts[j].Type != dto.ContentTypeFile {
+				continue
+			}
+			messageFile := parts[j].GetFile()
+			if messageFile == nil || messageFile.FileId == "" {
+				continue
+			}
+			file, upstreamFileId, dataURL, err := resolveGatewayFile(messageFile.FileId, userId, channelId)
+			if err != nil {
+				return false, err
+			}
+			if file == nil {
+				continue
+			}
+			if upstreamFileId != "" {
+				parts[j].File = &dto.MessageFile{FileId: upstreamFileId}
+			} else {
+				parts[j].File = &dto.MessageFile{FileName: file.Filename, FileData: dataURL}
+			}
+			changed = true
+		}
+		if changed {
+			message.SetMediaContent(parts)
+			resolved = true
+		}
+	}
+	return resolved, nil
+}
+
+// ResolveResponsesFileReferences 将 Responses 请求 input 中引用网关文件的 input_file、input_image 替换为内联内容或上游文件 ID，
+// 返回请求是否被改写
+func ResolveResponsesFileReferences(userId int, channelId int, request *dto.OpenAIResponsesRequest) (bool, error) {
+	if len(request.Input) == 0 || common.GetJsonType(request.Input) != "array" {
+		return false, nil
+	}
+	var items []map[string]any
+	if err := common.Unmarshal(request.Input, &items); err != nil {
+		return false, nil
+	}
+	changed := false
+	for _, item := range items {
+		parts, ok := item["content"].([]any)
+		if !ok {
+			continue
+		}
+		for _, p := range parts {
+			part, ok := p.(map[string]any)
+			if !ok {
+				continue
+			}
+			partType := common.Interface2String(part["type"])
+			fileId := common.Interface2String(part["file_id"])
+			if fileId == "" || (partType != "input_file" && partType != "input_image") {
+				continue
+			}
+			file, upstreamFileId, dataURL, err := resolveGatewayFile(fileId, userId, channelId)
+			if err != nil {
+				return false, err
+			}
+			if file == nil {
+				continue
+			}
+			changed = true
+			if upstreamFileId != "" {
+				part["file_id"] = upstreamFileId
+				continue
+			}
+			delete(part, "file_id")
+			if partType == "input_image" {
+				part["image_url"] = dataURL
+			} else {
+				part["file_data"] = dataURL
+				part["filename"] = file.Filename
+			}
+		}
+	}
+	if !changed {
+		return false, nil
+	}
+	input, err := common.Marshal(items)
+	if err != nil {
+		return false, err
+	}
+	request.Input = input
+	return true, nil
+}
+
+// resolveGatewayFile 解析用户的网关文件：内容保存在 channelId 渠道时返回上游文件 ID，否则读取内容并编码为 data URL；
+// fileId 不是该用户的网关文件时返回 nil
+func resolveGatewayFile(fileId string, userId int, channelId int) (*model.File, string, string, error) {
+	if !strings.HasPrefix(fileId, "file-") {
+		return nil, "", "", nil
+	}
+	file, err := model.FindUserFile(fileId, userId)
+	if err != nil || file == nil {
+		return nil, "", "", err
+	}
+	if storageOf(file) == FileStorageUpstream && file.ChannelId == channelId {
+		return file, file.StoragePath, "", nil
+	}
+	_, content, err := ReadGatewayFile(fileId, userId)
+	if err != nil {
+		return nil, "", "", fmt.Errorf("failed to read file %s: %w", fileId, err)
+	}
+	mimeType := GetMimeTypeByExtension(strings.TrimPrefix(filepath.Ext(file.Filename), "."))
+	if mimeType == "application/octet-stream" {
+		mimeType = http.DetectContentType(content)
+	}
+	return file, "", fmt.Sprintf("data:%s;base64,%s", mimeType, base64.StdEncoding.EncodeToString(content)), nil
+}
@@ -0,0 +1,151 @@
+package service
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/constant"
+	"github.com/QuantumNous/new-api/dto"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/setting/system_setting"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGatewayFileSharedStorage(t *testing.T) {
+	t.Cleanup(func() { model.DB.Exec("DELETE FROM files") })
+	dir := t.TempDir()
+	t.Setenv("FILE_STORAGE_DIR", dir)
+
+	file := &model.File{UserId: 1, Filename: "notes.txt", Purpose: "user_data"}
+	require.NoError(t, SaveGatewayFile(file, strings.NewReader("hello"), 32))
+	require.Equal(t, int64(5), file.Bytes)
+	data, err := os.ReadFile(filepath.Join(dir, file.StoragePath))
+	require.NoError(t, err)
+	require.Equal(t, "hello", string(data))
+
+	_, content, err := ReadGatewayFile(file.Id, 1)
+	require.NoError(t, err)
+	require.Equal(t, "hello", string(content))
+
+	require.ErrorIs(t, SaveGatewayFile(&model.File{UserId: 1, Filename: "big.txt"}, strings.NewReader("too large"), 4), ErrFileTooLarge)
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+
+	require.NoError(t, DeleteGatewayFile(file.Id, 1))
+	_, err = os.Stat(filepath.Join(dir, file.StoragePath))
+	require.True(t, os.IsNotExist(err))
+}
+
+func TestResolveFileReferences(t *testing.T) {
+	t.Cleanup(func() { model.DB.Exec("DELETE FROM files") })
+	t.Setenv("FILE_STORAGE_DIR", "")
+	file := &model.File{UserId: 1, Filename: "notes.txt", Purpose: "user_data"}
+	require.NoError(t, SaveGatewayFile(file, strings.NewReader("hello"), 32))
+	dataURL := "data:text/plain;base64,aGVsbG8="
+
+	chatReq := &dto.GeneralOpenAIRequest{Messages: []dto.Message{{
+		Role: "user",
+		Content: []any{
+			map[string]any{"type": "text", "text": "summarize"},
+			map[string]any{"type": "file", "file": map[string]any{"file_id": file.Id}},
+			map[string]any{"type": "file", "file": map[string]any{"file_id": "file-upstream"}},
+		},
+	}}}
+	resolved, err := ResolveChatFileReferences(1, 0, chatReq)
+	require.NoError(t, err)
+	require.True(t, resolved)
+	parts := chatReq.Messages[0].ParseContent()
+	require.Len(t, parts, 3)
+	require.Equal(t, &dto.MessageFile{FileName: "notes.txt", FileData: dataURL}, parts[1].File)
+	require.Equal(t, "file-upstream", parts[2].GetFile().FileId)
+
+	// files of other users are not resolved
+	otherReq := &dto.GeneralOpenAIRequest{Messages: []dto.Message{{
+		Role:    "user",
+		Content: []any{map[string]any{"type": "file", "file": map[string]any{"file_id": file.Id}}},
+	}}}
+	resolved, err = ResolveChatFileReferences(2, 0, otherReq)
+	require.NoError(t, err)
+	require.False(t, resolved)
+	require.Equal(t, file.Id, otherReq.Messages[0].ParseContent()[0].GetFile().FileId)
+
+	responsesReq := &dto.OpenAIResponsesRequest{
+		Input: json.RawMessage(`[{"role":"user","content":[{"type":"input_text","text":"summarize"},{"type":"input_file","file_id":"` + file.Id + `"},{"type":"input_image","file_id":"file-upstream"}]}]`),
+	}
+	resolved, err = ResolveResponsesFileReferences(1, 0, responsesReq)
+	require.NoError(t, err)
+	require.True(t, resolved)
+	var items []struct {
+		Content []map[string]any `json:"content"`
+	}
+	require.NoError(t, common.Unmarshal(responsesReq.Input, &items))
+	require.Equal(t, map[string]any{"type": "input_file", "file_data": dataURL, "filename": "notes.txt"}, items[0].Content[1])
+	require.Equal(t, "file-upstream", items[0].Content[2]["file_id"])
+}
+
+func TestGatewayFileUpstreamStorage(t *testing.T) {
+	t.Cleanup(func() {
+		model.DB.Exec("DELETE FROM files")
+		model.DB.Exec("DELETE FROM channels")
+	})
+	var deleted []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodPost && r.URL.Path == "/v1/files":
+			require.Equal(t, "user_data", r.FormValue("purpose"))
+			_, _ = w.Write([]byte(`{"id":"file-up-1","object":"file"}`))
+		case r.Method == http.MethodGet && r.URL.Path == "/v1/files/file-up-1/content":
+			_, _ = w.Write([]byte("hello"))
+		case r.Method == http.MethodDelete:
+			deleted = append(deleted, strings.TrimPrefix(r.URL.Path, "/v1/files/"))
+			_, _ = w.Write([]byte(`{"deleted":true}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	baseURL := server.URL
+	channel := &model.Channel{Type: constant.ChannelTypeOpenAI, Key: "sk-test", BaseURL: &baseURL, Status: common.ChannelStatusEnabled}
+	require.NoError(t, model.DB.Create(channel).Error)
+	settings := system_setting.GetFileStorageSettings()
+	previous := *settings
+	settings.Backend = FileStorageUpstream
+	settings.UpstreamChannelId = channel.Id
+	t.Cleanup(func() { *settings = previous })
+
+	file := &model.File{UserId: 1, Filename: "notes.txt", Purpose: "user_data"}
+	require.NoError(t, SaveGatewayFile(file, strings.NewReader("hello"), 32))
+	require.Equal(t, FileStorageUpstream, file.Storage)
+	require.Equal(t, "file-up-1", file.StoragePath)
+	require.Equal(t, channel.Id, file.ChannelId)
+
+	_, content, err := ReadGatewayFile(file.Id, 1)
+	require.NoError(t, err)
+	require.Equal(t, "hello", string(content))
+
+	// 同一渠道直接使用上游文件 ID，其他渠道内联内容
+	sameChannel := &dto.OpenAIResponsesRequest{Input: json.RawMessage(`[{"role":"user","content":[{"type":"input_file","file_id":"` + file.Id + `"}]}]`)}
+	_, err = ResolveResponsesFileReferences(1, channel.Id, sameChannel)
+	require.NoError(t, err)
+	require.Contains(t, string(sameChannel.Input), `"file_id":"file-up-1"`)
+	otherChannel := &dto.OpenAIResponsesRequest{Input: json.RawMessage(`[{"role":"user","content":[{"type":"input_file","file_id":"` + file.Id + `"}]}]`)}
+	_, err = ResolveResponsesFileReferences(1, channel.Id+1, otherChannel)
+	require.NoError(t, err)
+	require.Contains(t, string(otherChannel.Input), `"file_data":"data:text/plain;base64,aGVsbG8="`)
+
+	// 上游不接受的用途回退到主库
+	output := &model.File{UserId: 1, Filename: "output.jsonl", Purpose: "batch_output"}
+	require.NoError(t, SaveGatewayFile(output, strings.NewReader("{}"), 32))
+	require.Equal(t, FileStorageDatabase, output.Storage)
+
+	require.NoError(t, DeleteGatewayFile(file.Id, 1))
+	require.Equal(t, []string{"file-up-1"}, deleted)
+}
@@ -0,0 +1,357 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/setting/system_setting"
+)
+
+// 网关文件的内容存储：元信息与归属始终保存在主库，任一节点都能解析 file_id；内容按文件存储设置保存在
+// 主库（默认）、FILE_STORAGE_DIR 目录、S3 或上游渠道，读取与删除按文件记录的存储方式进行，与当前设置无关。
+// 多节点部署时 FILE_STORAGE_DIR 须为所有节点共享的挂载目录（NFS、云盘等），不能是单个节点的本地目录
+
+const (
+	FileStorageDatabase = "database"
+	FileStorageLocal    = "local"
+	FileStorageS3       = "s3"
+	FileStorageUpstream = "upstream"
+)
+
+var ErrFileTooLarge = errors.New("file exceeds the maximum size")
+
+func fileStorageDir() string {
+	return strings.TrimSpace(os.Getenv("FILE_STORAGE_DIR"))
+}
+
+// fileStorage 文件内容的存储后端，save 需设置文件的 StoragePath 与 Bytes
+type fileStorage interface {
+	save(ctx context.Context, file *model.File, content io.Reader, limit int64) error
+	open(ctx context.Context, file *model.File) (io.ReadCloser, error)
+	remove(ctx context.Context, file *model.File) error
+}
+
+// upstreamFilePurposes 上游 /v1/files 接受的用途，网关生成的文件（如 Batch 结果）不上传到上游
+var upstreamFilePurposes = map[string]bool{
+	"assistants": true,
+	"batch":      true,
+	"fine-tune":  true,
+	"vision":     true,
+	"user_data":  true,
+	"evals":      true,
+}
+
+// currentFileStorage 返回新上传文件使用的存储方式
+func currentFileStorage(purpose string) string {
+	backend := system_setting.GetFileStorageSettings().Backend
+	if backend == FileStorageUpstream && !upstreamFilePurposes[purpose] {
+		backend = ""
+	}
+	if backend != "" {
+		return backend
+	}
+	if fileStorageDir() != "" {
+		return FileStorageLocal
+	}
+	return FileStorageDatabase
+}
+
+// storageOf 返回文件内容所在的存储方式，兼容未记录存储方式的旧数据
+func storageOf(file *model.File) string {
+	if file.Storage != "" {
+		return file.Storage
+	}
+	if file.StoragePath != "" {
+		return FileStorageLocal
+	}
+	return FileStorageDatabase
+}
+
+func newFileStorage(storage string, file *model.File) (fileStorage, error) {
+	switch storage {
+	case FileStorageDatabase:
+		return databaseFileStorage{}, nil
+	case FileStorageLocal:
+		dir := fileStorageDir()
+		if dir == "" {
+			return nil, errors.New("file storage is local, but FILE_STORAGE_DIR is not set")
+		}
+		return localFileStorage{dir: dir}, nil
+	case FileStorageS3:
+		settings := system_setting.GetFileStorageSettings()
+		if settings.S3Bucket == "" || settings.S3AccessKeyId == "" || settings.S3AccessSecret == "" {
+			return nil, errors.New("file storage is s3, but the s3 bucket or credentials are not configured")
+		}
+		return s3FileStorage{bucket: s3Bucket{
+			Endpoint:     settings.S3Endpoint,
+			Region:       settings.S3Region,
+			Bucket:       settings.S3Bucket,
+			Prefix:       settings.S3Prefix,
+			AccessKeyId:  settings.S3AccessKeyId,
+			AccessSecret: settings.S3AccessSecret,
+		}}, nil
+	case FileStorageUpstream:
+		channelId := file.ChannelId
+		if channelId == 0 {
+			channelId = system_setting.GetFileStorageSettings().UpstreamChannelId
+		}
+		return newUpstreamFileStorage(channelId)
+	}
+	return nil, fmt.Errorf("unknown file storage %q", storage)
+}
+
+// SaveGatewayFile 保存上传的文件内容与元信息，内容超过 limit 字节时返回 ErrFileTooLarge
+func SaveGatewayFile(file *model.File, content io.Reader, limit int64) error {
+	ctx := context.Background()
+	file.Storage = currentFileStorage(file.Purpose)
+	storage, err := newFileStorage(file.Storage, file)
+	if err != nil {
+		return err
+	}
+	file.Id = model.NewFileId()
+	if err = storage.save(ctx, file, content, limit); err != nil {
+		return err
+	}
+	if err = model.CreateFile(file); err != nil {
+		if file.StoragePath != "" {
+			_ = storage.remove(ctx, file)
+		}
+		return err
+	}
+	return nil
+}
+
+// OpenGatewayFile 返回用户文件的元信息与内容，调用方负责关闭内容
+func OpenGatewayFile(id string, userId int) (*model.File, io.ReadCloser, error) {
+	file, err := model.GetFileById(id, userId, true)
+	if err != nil {
+		return nil, nil, err
+	}
+	storage, err := newFileStorage(storageOf(file), file)
+	if err != nil {
+		return nil, nil, err
+	}
+	reader, err := storage.open(context.Background(), file)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read file storage: %w", err)
+	}
+	return file, reader, nil
+}
+
+// ReadGatewayFile 读取用户文件的全部内容
+func ReadGatewayFile(id string, userId int) (*model.File, []byte, error) {
+	file, reader, err := OpenGatewayFile(id, userId)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer reader.Close()
+	content, err := io.ReadAll(reader)
+	return file, content, err
+}
+
+// DeleteGatewayFile 删除文件记录及其在存储中的内容
+func DeleteGatewayFile(id string, userId int) error {
+	file, err := model.GetFileById(id, userId, false)
+	if err != nil {
+		return err
+	}
+	if err = model.DeleteFile(id, userId); err != nil {
+		return err
+	}
+	// 记录已删除，残留的内容不影响结果
+	if storageOf(file) == FileStorageDatabase {
+		return nil
+	}
+	storage, err := newFileStorage(storageOf(file), file)
+	if err == nil {
+		err = storage.remove(context.Background(), file)
+	}
+	if err != nil {
+		common.SysError("failed to remove stored file content: " + err.Error())
+	}
+	return nil
+}
+
+// databaseFileStorage 内容与元信息一起保存在主库
+type databaseFileStorage struct{}
+
+func (databaseFileStorage) save(_ context.Context, file *model.File, content io.Reader, limit int64) error {
+	data, err := io.ReadAll(io.LimitReader(content, limit+1))
+	if err != nil {
+		return err
+	}
+	if int64(len(data)) > limit {
+		return ErrFileTooLarge
+	}
+	file.Content = data
+	file.Bytes = int64(len(data))
+	return nil
+}
+
+func (databaseFileStorage) open(_ context.Context, file *model.File) (io.ReadCloser, error) {
+	return io.NopCloser(bytes.NewReader(file.Content)), nil
+}
+
+func (databaseFileStorage) remove(context.Context, *model.File) error {
+	return nil
+}
+
+// localFileStorage 内容保存在共享存储目录中以文件 ID 命名的文件
+type localFileStorage struct {
+	dir string
+}
+
+func (s localFileStorage) save(_ context.Context, file *model.File, content io.Reader, limit int64) error {
+	// 先写入临时文件再重命名，其他节点不会读到写了一半的内容
+	tmp, n, err := spoolFileContent(s.dir, file.Id, content, limit)
+	if err != nil {
+		return err
+	}
+	file.StoragePath = file.Id
+	if err = tmp.Close(); err == nil {
+		err = os.Rename(tmp.Name(), filepath.Join(s.dir, file.StoragePath))
+	}
+	if err != nil {
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+	file.Bytes = n
+	return nil
+}
+
+func (s localFileStorage) open(_ context.Context, file *model.File) (io.ReadCloser, error) {
+	return os.Open(filepath.Join(s.dir, file.StoragePath))
+}
+
+func (s localFileStorage) remove(_ context.Context, file *model.File) error {
+	if err := os.Remove(filepath.Join(s.dir, file.StoragePath)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// s3FileStorage 内容保存在 S3 存储桶中以文件 ID 命名的对象
+type s3FileStorage struct {
+	bucket s3Bucket
+}
+
+func (s s3FileStorage) save(ctx context.Context, file *model.File, content io.Reader, limit int64) error {
+	// PutObject 需要预先知道内容长度，先写入本地临时文件
+	tmp, n, err := spoolFileContent("", file.Id, content, limit)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+	if _, err = tmp.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	key := s.bucket.objectKey(file.Id)
+	resp, err := s.bucket.do(ctx, http.MethodPut, key, tmp, n, "application/octet-stream")
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	file.StoragePath = key
+	file.Bytes = n
+	return nil
+}
+
+func (s s3FileStorage) open(ctx context.Context, file *model.File) (io.ReadCloser, error) {
+	resp, err := s.bucket.do(ctx, http.MethodGet, file.StoragePath, nil, 0, "")
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+func (s s3FileStorage) remove(ctx context.Context, file *model.File) error {
+	resp, err := s.bucket.do(ctx, http.MethodDelete, file.StoragePath, nil, 0, "")
+	if err != nil {
+		return err
+	}
+	return resp.Body.Close()
+}
+
+// upstreamFileStorage 内容上传到 OpenAI 渠道的 /v1/files，StoragePath 为上游的文件 ID
+type upstreamFileStorage struct {
+	channelId int
+	upstream  *batchUpstream
+}
+
+func newUpstreamFileStorage(channelId int) (fileStorage, error) {
+	if channelId == 0 {
+		return nil, errors.New("file storage is upstream, but the upstream channel is not configured")
+	}
+	channel, err := model.CacheGetChannel(channelId)
+	if err != nil {
+		return nil, err
+	}
+	keys := channel.GetKeys()
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("channel #%d has no key", channelId)
+	}
+	upstream, err := newBatchUpstream(channel, keys[0])
+	if err != nil {
+		return nil, err
+	}
+	return upstreamFileStorage{channelId: channelId, upstream: upstream}, nil
+}
+
+func (s upstreamFileStorage) save(ctx context.Context, file *model.File, content io.Reader, limit int64) error {
+	data, err := io.ReadAll(io.LimitReader(content, limit+1))
+	if err != nil {
+		return err
+	}
+	if int64(len(data)) > limit {
+		return ErrFileTooLarge
+	}
+	uploaded, err := s.upstream.uploadFile(ctx, file.Purpose, file.Filename, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	file.ChannelId = s.channelId
+	file.StoragePath = uploaded.Id
+	file.Bytes = int64(len(data))
+	return nil
+}
+
+func (s upstreamFileStorage) open(ctx context.Context, file *model.File) (io.ReadCloser, error) {
+	data, err := s.upstream.fileContent(ctx, file.StoragePath)
+	if err != nil {
+		return nil, err
+	}
+	return io.NopCloser(bytes.NewReader(data)), nil
+}
+
+func (s upstreamFileStorage) remove(ctx context.Context, file *model.File) error {
+	return s.upstream.deleteFile(ctx, file.StoragePath)
+}
+
+// spoolFileContent 将内容写入 dir 中的临时文件（dir 为空时使用系统临时目录），超过 limit 字节时返回 ErrFileTooLarge；
+// 成功时调用方负责关闭并移走或删除临时文件
+func spoolFileContent(dir string, id string, content io.Reader, limit int64) (*os.File, int64, error) {
+	tmp, err := os.CreateTemp(dir, id+".*.tmp")
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to write file storage: %w", err)
+	}
+	n, err := io.Copy(tmp, io.LimitReader(content, limit+1))
+	if err == nil && n > limit {
+		err = ErrFileTooLarge
+	}
+	if err != nil {
+		tmp.Close()
+		_ = os.Remove(tmp.Name())
+		return nil, 0, err
+	}
+	return tmp, n, nil
+}
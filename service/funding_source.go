@@ -1,8 +1,10 @@
 package service
 
 import (
+	"fmt"
 	"time"
 
+	"github.com/QuantumNous/new-api/common"
 	"github.com/QuantumNous/new-api/model"
 )
 
@@ -27,8 +29,9 @@ type FundingSource interface {
 // ---------------------------------------------------------------------------
 
 type WalletFunding struct {
-	userId   int
-	consumed int // 实际预扣的用户额度
+	userId     int
+	consumed   int                        // 实际预扣的用户额度
+	deductions []model.QuotaPoolDeduction // 从各额度池扣除的额度，退款时逆序退回
 }
 
 func (w *WalletFunding) Source() string { return BillingSourceWallet }
@@ -37,10 +40,12 @@ func (w *WalletFunding) PreConsume(amount int) error {
 	if amount <= 0 {
 		return nil
 	}
-	if err := model.DecreaseUserQuota(w.userId, amount); err != nil {
+	deductions, err := model.DecreaseUserQuotaWithPools(w.userId, amount)
+	if err != nil {
 		return err
 	}
 	w.consumed = amount
+	w.deductions = append(w.deductions, deductions...)
 	return nil
 }
 
@@ -49,9 +54,18 @@ func (w *WalletFunding) Settle(delta int) error {
 		return nil
 	}
 	if delta > 0 {
-		return model.DecreaseUserQuota(w.userId, delta)
+		deductions, err := model.DecreaseUserQuotaWithPools(w.userId, delta)
+		if err != nil {
+			return err
+		}
+		w.deductions = append(w.deductions, deductions...)
+		return nil
 	}
-	return model.IncreaseUserQuota(w.userId, -delta, false)
+	if err := model.IncreaseUserQuota(w.userId, -delta, false); err != nil {
+		return err
+	}
+	w.restorePools(-delta)
+	return nil
 }
 
 func (w *WalletFunding) Refund() error {
@@ -60,7 +74,19 @@ func (w *WalletFunding) Refund() error {
 	}
 	// IncreaseUserQuota 是 quota += N 的非幂等操作，不能重试，否则会多退额度。
 	// 订阅的 RefundSubscriptionPreConsume 有 requestId 幂等保护所以可以重试。
-	return model.IncreaseUserQuota(w.userId, w.consumed, false)
+	if err := model.IncreaseUserQuota(w.userId, w.consumed, false); err != nil {
+		return err
+	}
+	w.restorePools(w.consumed)
+	return nil
+}
+
+func (w *WalletFunding) restorePools(amount int) {
+	deductions, err := model.RestoreQuotaPools(w.deductions, amount)
+	if err != nil {
+		common.SysLog(fmt.Sprintf("failed to restore quota pools of user %d: %s", w.userId, err.Error()))
+	}
+	w.deductions = deductions
 }
 
 // ---------------------------------------------------------------------------
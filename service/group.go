@@ -1,6 +1,7 @@
 package service
 
 import (
+	"fmt"
 	"strings"
 
 	"github.com/QuantumNous/new-api/setting"
@@ -41,6 +42,20 @@ func GroupInUserUsableGroups(userGroup, groupName string) bool {
 	return ok
 }
 
+// CheckTokenGroup 检查令牌分组对用户是否可用：分组需在用户可用分组中且未被弃用，空分组表示使用用户分组
+func CheckTokenGroup(userGroup, tokenGroup string) error {
+	if tokenGroup == "" {
+		return nil
+	}
+	if !GroupInUserUsableGroups(userGroup, tokenGroup) {
+		return fmt.Errorf("无权访问 %s 分组", tokenGroup)
+	}
+	if !ratio_setting.ContainsGroupRatio(tokenGroup) && tokenGroup != "auto" {
+		return fmt.Errorf("分组 %s 已被弃用", tokenGroup)
+	}
+	return nil
+}
+
 // GetUserAutoGroup 根据用户分组获取自动分组设置
 func GetUserAutoGroup(userGroup string) []string {
 	groups := GetUserUsableGroups(userGroup)
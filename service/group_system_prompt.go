@@ -0,0 +1,184 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/constant"
+	"github.com/QuantumNous/new-api/dto"
+	"github.com/QuantumNous/new-api/logger"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+	"github.com/QuantumNous/new-api/types"
+
+	"github.com/gin-gonic/gin"
+)
+
+var errClientSystemPromptForbidden = errors.New("system prompts are not allowed for this group")
+
+// ApplyGroupSystemPromptPolicy enforces the system prompt policy of the request group on
+// chat completions, Responses and Claude messages requests. Client system prompts are
+// stripped or rejected first, then the mandated prompt is prepended.
+// The rewritten request is never forwarded through pass-through channels, so the policy
+// cannot be bypassed; client profiles only keep client system prompts when the group policy allows it.
+func ApplyGroupSystemPromptPolicy(c *gin.Context, info *relaycommon.RelayInfo, request dto.Request) *types.NewAPIError {
+	policy := operation_setting.GetGroupSystemPromptPolicy(info.UsingGroup)
+	if policy == nil && info.UserGroup != info.UsingGroup {
+		policy = operation_setting.GetGroupSystemPromptPolicy(info.UserGroup)
+	}
+	if policy == nil {
+		return nil
+	}
+	systemPrompt := renderGroupSystemPrompt(c, info, policy.SystemPrompt)
+	mode := clientSystemPromptMode(policy, info.GetClientProfile())
+
+	var err error
+	switch r := request.(type) {
+	case *dto.GeneralOpenAIRequest:
+		err = applyChatSystemPromptPolicy(r, mode, systemPrompt)
+	case *dto.OpenAIResponsesRequest:
+		err = applyResponsesSystemPromptPolicy(&r.Instructions, &r.Input, mode, systemPrompt)
+	case *dto.OpenAIResponsesCompactionRequest:
+		err = applyResponsesSystemPromptPolicy(&r.Instructions, &r.Input, mode, systemPrompt)
+	case *dto.ClaudeRequest:
+		err = applyClaudeSystemPromptPolicy(r, mode, systemPrompt)
+	default:
+		return nil
+	}
+	if err != nil {
+		if errors.Is(err, errClientSystemPromptForbidden) {
+			logger.LogWarn(c, fmt.Sprintf("group %s rejected client system prompt", info.UsingGroup))
+			return types.NewErrorWithStatusCode(err, types.ErrorCodeInvalidRequest, http.StatusBadRequest, types.ErrOptionWithSkipRetry())
+		}
+		return types.NewError(err, types.ErrorCodeInvalidRequest, types.ErrOptionWithSkipRetry())
+	}
+	info.SystemPromptEnforced = true
+	return nil
+}
+
+// clientSystemPromptMode 返回客户端系统提示词的处理方式，令牌的客户端兼容配置只有在分组策略允许时才能保留客户端系统提示词
+func clientSystemPromptMode(policy *operation_setting.GroupSystemPromptPolicy, profile *operation_setting.ClientProfile) string {
+	if policy.AllowClientProfilePassThrough && profile != nil && profile.SystemPromptPassThrough {
+		return operation_setting.ClientSystemPromptAllow
+	}
+	return policy.ClientSystemPrompt
+}
+
+func renderGroupSystemPrompt(c *gin.Context, info *relaycommon.RelayInfo, prompt string) string {
+	if prompt == "" || !strings.Contains(prompt, "{{") {
+		return prompt
+	}
+	return strings.NewReplacer(
+		"{{user_id}}", strconv.Itoa(info.UserId),
+		"{{username}}", common.GetContextKeyString(c, constant.ContextKeyUserName),
+		"{{user_email}}", info.UserEmail,
+		"{{group}}", info.UsingGroup,
+		"{{token_name}}", c.GetString("token_name"),
+	).Replace(prompt)
+}
+
+func isSystemRole(role string) bool {
+	return role == "system" || role == "developer"
+}
+
+func applyChatSystemPromptPolicy(request *dto.GeneralOpenAIRequest, mode string, systemPrompt string) error {
+	if mode == operation_setting.ClientSystemPromptStrip || mode == operation_setting.ClientSystemPromptReject {
+		messages := make([]dto.Message, 0, len(request.Messages))
+		for _, message := range request.Messages {
+			if isSystemRole(message.Role) {
+				if mode == operation_setting.ClientSystemPromptReject {
+					return errClientSystemPromptForbidden
+				}
+				continue
+			}
+			messages = append(messages, message)
+		}
+		request.Messages = messages
+	}
+	if systemPrompt != "" {
+		request.Messages = append([]dto.Message{{
+			Role:    request.GetSystemRoleName(),
+			Content: systemPrompt,
+		}}, request.Messages...)
+	}
+	return nil
+}
+
+func applyResponsesSystemPromptPolicy(instructions *json.RawMessage, input *json.RawMessage, mode string, systemPrompt string) error {
+	if mode == operation_setting.ClientSystemPromptStrip || mode == operation_setting.ClientSystemPromptReject {
+		hasInstructions := len(*instructions) > 0 && string(*instructions) != "null" && string(*instructions) != `""`
+		if hasInstructions && mode == operation_setting.ClientSystemPromptReject {
+			return errClientSystemPromptForbidden
+		}
+		*instructions = nil
+
+		if common.GetJsonType(*input) == "array" {
+			var items []map[string]any
+			if err := common.Unmarshal(*input, &items); err != nil {
+				return err
+			}
+			kept := make([]map[string]any, 0, len(items))
+			for _, item := range items {
+				if role, _ := item["role"].(string); isSystemRole(role) {
+					if mode == operation_setting.ClientSystemPromptReject {
+						return errClientSystemPromptForbidden
+					}
+					continue
+				}
+				kept = append(kept, item)
+			}
+			if len(kept) != len(items) {
+				data, err := common.Marshal(kept)
+				if err != nil {
+					return err
+				}
+				*input = data
+			}
+		}
+	}
+	if systemPrompt != "" {
+		merged := systemPrompt
+		var existing string
+		if len(*instructions) > 0 && common.Unmarshal(*instructions, &existing) == nil && strings.TrimSpace(existing) != "" {
+			merged = systemPrompt + "\n" + existing
+		}
+		data, err := common.Marshal(merged)
+		if err != nil {
+			return err
+		}
+		*instructions = data
+	}
+	return nil
+}
+
+func applyClaudeSystemPromptPolicy(request *dto.ClaudeRequest, mode string, systemPrompt string) error {
+	if mode == operation_setting.ClientSystemPromptStrip || mode == operation_setting.ClientSystemPromptReject {
+		hasSystem := request.System != nil && !(request.IsStringSystem() && request.GetStringSystem() == "")
+		if hasSystem && mode == operation_setting.ClientSystemPromptReject {
+			return errClientSystemPromptForbidden
+		}
+		request.System = nil
+	}
+	if systemPrompt == "" {
+		return nil
+	}
+	if request.System == nil {
+		request.SetStringSystem(systemPrompt)
+	} else if request.IsStringSystem() {
+		if existing := strings.TrimSpace(request.GetStringSystem()); existing != "" {
+			request.SetStringSystem(systemPrompt + "\n" + existing)
+		} else {
+			request.SetStringSystem(systemPrompt)
+		}
+	} else {
+		newSystem := dto.ClaudeMediaMessage{Type: dto.ContentTypeText}
+		newSystem.SetText(systemPrompt)
+		request.System = append([]dto.ClaudeMediaMessage{newSystem}, request.ParseSystem()...)
+	}
+	return nil
+}
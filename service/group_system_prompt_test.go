@@ -0,0 +1,54 @@
+package service
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/QuantumNous/new-api/dto"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+	"github.com/stretchr/testify/require"
+)
+
+func TestApplyChatSystemPromptPolicy(t *testing.T) {
+	request := &dto.GeneralOpenAIRequest{Messages: []dto.Message{
+		{Role: "system", Content: "client"},
+		{Role: "user", Content: "hi"},
+	}}
+	require.ErrorIs(t, applyChatSystemPromptPolicy(request, operation_setting.ClientSystemPromptReject, "group"), errClientSystemPromptForbidden)
+
+	require.NoError(t, applyChatSystemPromptPolicy(request, operation_setting.ClientSystemPromptStrip, "group"))
+	require.Len(t, request.Messages, 2)
+	require.Equal(t, "system", request.Messages[0].Role)
+	require.Equal(t, "group", request.Messages[0].StringContent())
+	require.Equal(t, "user", request.Messages[1].Role)
+}
+
+func TestApplyResponsesSystemPromptPolicy(t *testing.T) {
+	instructions := json.RawMessage(`"client"`)
+	input := json.RawMessage(`[{"role":"developer","content":"dev"},{"role":"user","content":"hi"}]`)
+
+	require.NoError(t, applyResponsesSystemPromptPolicy(&instructions, &input, operation_setting.ClientSystemPromptStrip, "group"))
+	require.JSONEq(t, `"group"`, string(instructions))
+	require.JSONEq(t, `[{"role":"user","content":"hi"}]`, string(input))
+
+	instructions = json.RawMessage(`"client"`)
+	require.NoError(t, applyResponsesSystemPromptPolicy(&instructions, &input, operation_setting.ClientSystemPromptAllow, "group"))
+	require.JSONEq(t, `"group\nclient"`, string(instructions))
+}
+
+func TestApplyClaudeSystemPromptPolicy(t *testing.T) {
+	request := &dto.ClaudeRequest{System: "client"}
+	require.ErrorIs(t, applyClaudeSystemPromptPolicy(request, operation_setting.ClientSystemPromptReject, ""), errClientSystemPromptForbidden)
+	require.NoError(t, applyClaudeSystemPromptPolicy(request, operation_setting.ClientSystemPromptAllow, "group"))
+	require.Equal(t, "group\nclient", request.GetStringSystem())
+}
+
+func TestClientSystemPromptModeRequiresGroupOptIn(t *testing.T) {
+	profile := operation_setting.GetClientProfile(operation_setting.ClientProfileClaudeCode)
+	policy := &operation_setting.GroupSystemPromptPolicy{ClientSystemPrompt: operation_setting.ClientSystemPromptReject}
+	require.Equal(t, operation_setting.ClientSystemPromptReject, clientSystemPromptMode(policy, profile))
+
+	policy.AllowClientProfilePassThrough = true
+	require.Equal(t, operation_setting.ClientSystemPromptAllow, clientSystemPromptMode(policy, profile))
+	require.Equal(t, operation_setting.ClientSystemPromptReject, clientSystemPromptMode(policy, nil))
+}
@@ -2,10 +2,16 @@ package service
 
 import (
 	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
 	"fmt"
 	"net"
 	"net/http"
 	"net/url"
+	"strings"
 	"sync"
 	"time"
 
@@ -19,6 +25,7 @@ var (
 	httpClient      *http.Client
 	proxyClientLock sync.Mutex
 	proxyClients    = make(map[string]*http.Client)
+	tlsClients      = make(map[string]*http.Client)
 )
 
 func checkRedirect(req *http.Request, via []*http.Request) error {
@@ -80,6 +87,13 @@ func ResetProxyClientCache() {
 		}
 	}
 	proxyClients = make(map[string]*http.Client)
+	for _, client := range tlsClients {
+		if transport, ok := client.Transport.(*http.Transport); ok && transport != nil {
+			transport.CloseIdleConnections()
+		}
+	}
+	tlsClients = make(map[string]*http.Client)
+	unixSocketTransport.reset()
 }
 
 // NewProxyHttpClient 创建支持代理的 HTTP 客户端
@@ -167,3 +181,150 @@ func NewProxyHttpClient(proxyURL string) (*http.Client, error) {
 		return nil, fmt.Errorf("unsupported proxy scheme: %s, must be http, https, socks5 or socks5h", parsedURL.Scheme)
 	}
 }
+
+// UpstreamTLSOptions 渠道级上游 TLS 设置：双向 TLS 客户端证书、自定义 CA 与证书公钥固定
+type UpstreamTLSOptions struct {
+	ClientCertPEM      string
+	ClientKeyPEM       string
+	CaPEM              string   // 额外信任的 CA 证书（PEM，可包含多个）
+	DisableSystemRoots bool     // 不信任系统根证书，仅信任 CaPEM
+	PinnedSha256       []string // 证书公钥（SPKI）的 SHA-256 指纹，hex 或 base64，可带 sha256/ 前缀
+}
+
+// IsEmpty 未配置任何设置时使用默认客户端
+func (o UpstreamTLSOptions) IsEmpty() bool {
+	return o.ClientCertPEM == "" && o.CaPEM == "" && !o.DisableSystemRoots && len(o.PinnedSha256) == 0
+}
+
+// GetUpstreamHttpClient 返回按渠道 TLS 与拨号设置定制的 HTTP 客户端，按代理和设置内容缓存。
+func GetUpstreamHttpClient(proxyURL string, tlsOptions UpstreamTLSOptions, dialerOptions UpstreamDialerOptions) (*http.Client, error) {
+	optionsJson, err := common.Marshal(map[string]any{"tls": tlsOptions, "dialer": dialerOptions})
+	if err != nil {
+		return nil, err
+	}
+	hash := sha256.Sum256(optionsJson)
+	cacheKey := proxyURL + "|" + hex.EncodeToString(hash[:])
+	proxyClientLock.Lock()
+	if client, ok := tlsClients[cacheKey]; ok {
+		proxyClientLock.Unlock()
+		return client, nil
+	}
+	proxyClientLock.Unlock()
+
+	base, err := GetHttpClientWithProxy(proxyURL)
+	if err != nil {
+		return nil, err
+	}
+	if base == nil {
+		return nil, fmt.Errorf("http client is not initialized")
+	}
+	baseTransport, ok := base.Transport.(*http.Transport)
+	if !ok || baseTransport == nil {
+		return nil, fmt.Errorf("upstream transport settings require an http.Transport based client")
+	}
+	transport := baseTransport.Clone()
+	if !tlsOptions.IsEmpty() {
+		tlsConfig, err := buildUpstreamTLSConfig(baseTransport.TLSClientConfig, tlsOptions)
+		if err != nil {
+			return nil, err
+		}
+		transport.TLSClientConfig = tlsConfig
+	}
+	// SOCKS 代理自带拨号器，由代理负责解析与连接上游
+	if !dialerOptions.IsEmpty() && baseTransport.DialContext == nil {
+		dialer, err := newUpstreamDialer(dialerOptions)
+		if err != nil {
+			return nil, err
+		}
+		transport.DialContext = dialer.DialContext
+	}
+	client := &http.Client{
+		Transport:     transport,
+		Timeout:       base.Timeout,
+		CheckRedirect: checkRedirect,
+	}
+	proxyClientLock.Lock()
+	tlsClients[cacheKey] = client
+	proxyClientLock.Unlock()
+	return client, nil
+}
+
+// ValidateUpstreamTLSOptions 校验客户端证书、CA 证书与固定指纹能否正常加载
+func ValidateUpstreamTLSOptions(options UpstreamTLSOptions) error {
+	_, err := buildUpstreamTLSConfig(nil, options)
+	return err
+}
+
+func buildUpstreamTLSConfig(base *tls.Config, options UpstreamTLSOptions) (*tls.Config, error) {
+	tlsConfig := &tls.Config{}
+	if base != nil {
+		tlsConfig = base.Clone()
+	}
+	if options.ClientCertPEM != "" || options.ClientKeyPEM != "" {
+		cert, err := tls.X509KeyPair([]byte(options.ClientCertPEM), []byte(options.ClientKeyPEM))
+		if err != nil {
+			return nil, fmt.Errorf("invalid client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	if options.DisableSystemRoots && options.CaPEM == "" {
+		return nil, fmt.Errorf("disabling system roots requires a CA certificate")
+	}
+	if options.CaPEM != "" {
+		pool := x509.NewCertPool()
+		if !options.DisableSystemRoots {
+			if systemPool, err := x509.SystemCertPool(); err == nil && systemPool != nil {
+				pool = systemPool
+			}
+		}
+		if !pool.AppendCertsFromPEM([]byte(options.CaPEM)) {
+			return nil, fmt.Errorf("invalid CA certificate")
+		}
+		tlsConfig.RootCAs = pool
+		// 渠道显式配置了信任的 CA 时，不受全局 TLS_INSECURE_SKIP_VERIFY 影响
+		tlsConfig.InsecureSkipVerify = false
+	}
+	if len(options.PinnedSha256) > 0 {
+		pins, err := parseCertificatePins(options.PinnedSha256)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.InsecureSkipVerify = false
+		// 证书链校验通过后再比对固定的公钥，已验证链中任一证书匹配即可，便于固定中间 CA；
+		// 只比对已验证的链，服务端额外发送但不在链上的证书不能满足固定
+		tlsConfig.VerifyConnection = func(state tls.ConnectionState) error {
+			return verifyCertificatePins(state, pins)
+		}
+	}
+	return tlsConfig, nil
+}
+
+func verifyCertificatePins(state tls.ConnectionState, pins map[[sha256.Size]byte]struct{}) error {
+	for _, chain := range state.VerifiedChains {
+		for _, cert := range chain {
+			if _, ok := pins[sha256.Sum256(cert.RawSubjectPublicKeyInfo)]; ok {
+				return nil
+			}
+		}
+	}
+	return fmt.Errorf("upstream certificate of %s does not match any pinned public key", state.ServerName)
+}
+
+func parseCertificatePins(values []string) (map[[sha256.Size]byte]struct{}, error) {
+	pins := make(map[[sha256.Size]byte]struct{}, len(values))
+	for _, value := range values {
+		value = strings.TrimPrefix(strings.TrimSpace(value), "sha256/")
+		var raw []byte
+		var err error
+		if compact := strings.ReplaceAll(value, ":", ""); len(compact) == hex.EncodedLen(sha256.Size) {
+			raw, err = hex.DecodeString(compact)
+		} else {
+			raw, err = base64.StdEncoding.DecodeString(value)
+		}
+		if err != nil || len(raw) != sha256.Size {
+			return nil, fmt.Errorf("invalid certificate pin %q, expected a SHA-256 digest in hex or base64", value)
+		}
+		pins[[sha256.Size]byte(raw)] = struct{}{}
+	}
+	return pins, nil
+}
@@ -0,0 +1,67 @@
+package service
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestUpstreamTLSConfig(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+	caPEM := string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw}))
+	pin := sha256.Sum256(server.Certificate().RawSubjectPublicKeyInfo)
+
+	get := func(options UpstreamTLSOptions) error {
+		tlsConfig, err := buildUpstreamTLSConfig(nil, options)
+		require.NoError(t, err)
+		client := &http.Client{Transport: &http.Transport{TLSClientConfig: tlsConfig}}
+		resp, err := client.Get(server.URL)
+		if err == nil {
+			resp.Body.Close()
+		}
+		return err
+	}
+
+	require.Error(t, get(UpstreamTLSOptions{PinnedSha256: []string{hex.EncodeToString(pin[:])}}))
+	require.NoError(t, get(UpstreamTLSOptions{CaPEM: caPEM, DisableSystemRoots: true}))
+	require.NoError(t, get(UpstreamTLSOptions{CaPEM: caPEM, PinnedSha256: []string{"sha256/" + base64.StdEncoding.EncodeToString(pin[:])}}))
+	wrongPin := sha256.Sum256([]byte("other key"))
+	err := get(UpstreamTLSOptions{CaPEM: caPEM, PinnedSha256: []string{hex.EncodeToString(wrongPin[:])}})
+	require.ErrorContains(t, err, "pinned public key")
+}
+
+func TestVerifyCertificatePinsUsesVerifiedChains(t *testing.T) {
+	leaf := &x509.Certificate{RawSubjectPublicKeyInfo: []byte("leaf key")}
+	extra := &x509.Certificate{RawSubjectPublicKeyInfo: []byte("extra key")}
+	pins := map[[sha256.Size]byte]struct{}{sha256.Sum256(extra.RawSubjectPublicKeyInfo): {}}
+
+	// 服务端额外发送但不在已验证链上的证书不能满足固定
+	state := tls.ConnectionState{
+		ServerName:       "upstream.example.com",
+		PeerCertificates: []*x509.Certificate{leaf, extra},
+		VerifiedChains:   [][]*x509.Certificate{{leaf}},
+	}
+	require.ErrorContains(t, verifyCertificatePins(state, pins), "pinned public key")
+
+	state.VerifiedChains = append(state.VerifiedChains, []*x509.Certificate{leaf, extra})
+	require.NoError(t, verifyCertificatePins(state, pins))
+}
+
+func TestValidateUpstreamTLSOptions(t *testing.T) {
+	require.NoError(t, ValidateUpstreamTLSOptions(UpstreamTLSOptions{}))
+	require.Error(t, ValidateUpstreamTLSOptions(UpstreamTLSOptions{DisableSystemRoots: true}))
+	require.Error(t, ValidateUpstreamTLSOptions(UpstreamTLSOptions{CaPEM: "not a cert"}))
+	require.Error(t, ValidateUpstreamTLSOptions(UpstreamTLSOptions{PinnedSha256: []string{"abc"}}))
+	require.Error(t, ValidateUpstreamTLSOptions(UpstreamTLSOptions{ClientCertPEM: "not a cert", ClientKeyPEM: "not a key"}))
+}
@@ -0,0 +1,73 @@
+package mail
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/setting/system_setting"
+)
+
+// Message is a rendered email ready to be delivered.
+type Message struct {
+	To       []string
+	FromName string
+	Subject  string
+	HTML     string
+}
+
+// Provider delivers messages through one outbound email service.
+type Provider interface {
+	Name() string
+	Send(ctx context.Context, message *Message) error
+}
+
+var httpClient = &http.Client{Timeout: 30 * time.Second}
+
+// GetProvider returns the provider selected in the email settings.
+func GetProvider() (Provider, error) {
+	settings := system_setting.GetEmailSettings()
+	switch settings.Provider {
+	case "", system_setting.EmailProviderSMTP:
+		return &SMTPProvider{}, nil
+	case system_setting.EmailProviderSendGrid:
+		return &SendGridProvider{APIKey: settings.SendGridAPIKey, From: fromAddress()}, nil
+	case system_setting.EmailProviderSES:
+		return &SESProvider{
+			Region:          settings.SESRegion,
+			AccessKeyId:     settings.SESAccessKeyId,
+			SecretAccessKey: settings.SESAccessSecret,
+			From:            fromAddress(),
+		}, nil
+	default:
+		return nil, fmt.Errorf("unknown email provider: %s", settings.Provider)
+	}
+}
+
+// Send delivers the message through the configured provider.
+func Send(ctx context.Context, message *Message) error {
+	if len(message.To) == 0 {
+		return fmt.Errorf("email has no receiver")
+	}
+	if message.FromName == "" {
+		message.FromName = common.SystemName
+	}
+	provider, err := GetProvider()
+	if err != nil {
+		return err
+	}
+	return provider.Send(ctx, message)
+}
+
+func fromAddress() string {
+	if from := strings.TrimSpace(system_setting.GetEmailSettings().FromAddress); from != "" {
+		return from
+	}
+	if common.SMTPFrom != "" {
+		return common.SMTPFrom
+	}
+	return common.SMTPAccount
+}
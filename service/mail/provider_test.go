@@ -0,0 +1,79 @@
+package mail
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/QuantumNous/new-api/common"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSendGridProviderSend(t *testing.T) {
+	var auth string
+	var payload sendGridRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth = r.Header.Get("Authorization")
+		body, _ := io.ReadAll(r.Body)
+		_ = common.Unmarshal(body, &payload)
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer server.Close()
+	original := sendGridEndpoint
+	sendGridEndpoint = server.URL
+	defer func() { sendGridEndpoint = original }()
+
+	provider := &SendGridProvider{APIKey: "sg-key", From: "noreply@example.com"}
+	err := provider.Send(context.Background(), &Message{
+		To:       []string{"a@example.com", "b@example.com"},
+		FromName: "Acme",
+		Subject:  "hello",
+		HTML:     "<p>hi</p>",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "Bearer sg-key", auth)
+	require.Len(t, payload.Personalizations, 1)
+	assert.Len(t, payload.Personalizations[0].To, 2)
+	assert.Equal(t, "Acme", payload.From.Name)
+	assert.Equal(t, "hello", payload.Subject)
+	assert.Equal(t, "<p>hi</p>", payload.Content[0].Value)
+}
+
+func TestSendGridProviderError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+		_, _ = w.Write([]byte("bad key"))
+	}))
+	defer server.Close()
+	original := sendGridEndpoint
+	sendGridEndpoint = server.URL
+	defer func() { sendGridEndpoint = original }()
+
+	provider := &SendGridProvider{APIKey: "sg-key", From: "noreply@example.com"}
+	err := provider.Send(context.Background(), &Message{To: []string{"a@example.com"}})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "401")
+}
+
+func TestSESProviderSignsRequest(t *testing.T) {
+	var auth string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth = r.Header.Get("Authorization")
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+	original := sesEndpoint
+	sesEndpoint = server.URL + "/%s"
+	defer func() { sesEndpoint = original }()
+
+	provider := &SESProvider{Region: "us-east-1", AccessKeyId: "AKID", SecretAccessKey: "secret", From: "noreply@example.com"}
+	err := provider.Send(context.Background(), &Message{To: []string{"a@example.com"}, Subject: "s", HTML: "b"})
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(auth, "AWS4-HMAC-SHA256 Credential=AKID/"))
+	assert.Contains(t, auth, "/us-east-1/ses/aws4_request")
+}
@@ -0,0 +1,79 @@
+package mail
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/QuantumNous/new-api/common"
+)
+
+var sendGridEndpoint = "https://api.sendgrid.com/v3/mail/send"
+
+// SendGridProvider sends through the SendGrid v3 mail API.
+type SendGridProvider struct {
+	APIKey string
+	From   string
+}
+
+type sendGridAddress struct {
+	Email string `json:"email"`
+	Name  string `json:"name,omitempty"`
+}
+
+type sendGridContent struct {
+	Type  string `json:"type"`
+	Value string `json:"value"`
+}
+
+type sendGridPersonalization struct {
+	To []sendGridAddress `json:"to"`
+}
+
+type sendGridRequest struct {
+	Personalizations []sendGridPersonalization `json:"personalizations"`
+	From             sendGridAddress           `json:"from"`
+	Subject          string                    `json:"subject"`
+	Content          []sendGridContent         `json:"content"`
+}
+
+func (p *SendGridProvider) Name() string {
+	return "sendgrid"
+}
+
+func (p *SendGridProvider) Send(ctx context.Context, message *Message) error {
+	if p.APIKey == "" || p.From == "" {
+		return fmt.Errorf("SendGrid API key or sender address is not configured")
+	}
+	to := make([]sendGridAddress, 0, len(message.To))
+	for _, addr := range message.To {
+		to = append(to, sendGridAddress{Email: addr})
+	}
+	body, err := common.Marshal(sendGridRequest{
+		Personalizations: []sendGridPersonalization{{To: to}},
+		From:             sendGridAddress{Email: p.From, Name: message.FromName},
+		Subject:          message.Subject,
+		Content:          []sendGridContent{{Type: "text/html", Value: message.HTML}},
+	})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, sendGridEndpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+p.APIKey)
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= http.StatusMultipleChoices {
+		respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return fmt.Errorf("SendGrid returned status %d: %s", resp.StatusCode, string(respBody))
+	}
+	return nil
+}
@@ -0,0 +1,91 @@
+package mail
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
+)
+
+// sesEndpoint is formatted with the region
+var sesEndpoint = "https://email.%s.amazonaws.com/v2/email/outbound-emails"
+
+// SESProvider sends through the Amazon SES v2 API.
+type SESProvider struct {
+	Region          string
+	AccessKeyId     string
+	SecretAccessKey string
+	From            string
+}
+
+type sesData struct {
+	Data    string `json:"Data"`
+	Charset string `json:"Charset"`
+}
+
+type sesRequest struct {
+	FromEmailAddress string `json:"FromEmailAddress"`
+	Destination      struct {
+		ToAddresses []string `json:"ToAddresses"`
+	} `json:"Destination"`
+	Content struct {
+		Simple struct {
+			Subject sesData `json:"Subject"`
+			Body    struct {
+				Html sesData `json:"Html"`
+			} `json:"Body"`
+		} `json:"Simple"`
+	} `json:"Content"`
+}
+
+func (p *SESProvider) Name() string {
+	return "ses"
+}
+
+func (p *SESProvider) Send(ctx context.Context, message *Message) error {
+	if p.Region == "" || p.AccessKeyId == "" || p.SecretAccessKey == "" || p.From == "" {
+		return fmt.Errorf("SES region, credentials or sender address is not configured")
+	}
+	var payload sesRequest
+	payload.FromEmailAddress = p.From
+	if message.FromName != "" {
+		payload.FromEmailAddress = fmt.Sprintf("%s <%s>", mime.BEncoding.Encode("UTF-8", message.FromName), p.From)
+	}
+	payload.Destination.ToAddresses = message.To
+	payload.Content.Simple.Subject = sesData{Data: message.Subject, Charset: "UTF-8"}
+	payload.Content.Simple.Body.Html = sesData{Data: message.HTML, Charset: "UTF-8"}
+	body, err := common.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, fmt.Sprintf(sesEndpoint, p.Region), bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	hash := sha256.Sum256(body)
+	credentials := aws.Credentials{AccessKeyID: p.AccessKeyId, SecretAccessKey: p.SecretAccessKey}
+	if err = v4.NewSigner().SignHTTP(ctx, credentials, req, hex.EncodeToString(hash[:]), "ses", p.Region, time.Now()); err != nil {
+		return err
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= http.StatusMultipleChoices {
+		respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return fmt.Errorf("SES returned status %d: %s", resp.StatusCode, string(respBody))
+	}
+	return nil
+}
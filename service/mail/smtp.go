@@ -0,0 +1,19 @@
+package mail
+
+import (
+	"context"
+	"strings"
+
+	"github.com/QuantumNous/new-api/common"
+)
+
+// SMTPProvider sends through the SMTP server configured by the SMTP* options.
+type SMTPProvider struct{}
+
+func (p *SMTPProvider) Name() string {
+	return "smtp"
+}
+
+func (p *SMTPProvider) Send(ctx context.Context, message *Message) error {
+	return common.SendEmailFrom(message.FromName, message.Subject, strings.Join(message.To, ";"), message.HTML)
+}
@@ -0,0 +1,98 @@
+package service
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/constant"
+	"github.com/QuantumNous/new-api/i18n"
+	"github.com/QuantumNous/new-api/logger"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/setting/model_setting"
+
+	"github.com/bytedance/gopkg/util/gopool"
+	"github.com/gin-gonic/gin"
+)
+
+// ModelDeprecationHeader carries the deprecation warning of a request to a deprecated model.
+const ModelDeprecationHeader = "X-New-Api-Model-Deprecation"
+
+// ModelDeprecationNotice records how a request to a deprecated model was handled; it is
+// stored in the request context and written to the consume log.
+type ModelDeprecationNotice struct {
+	Model       string `json:"model"`
+	SunsetDate  string `json:"sunset_date,omitempty"`
+	Replacement string `json:"replacement,omitempty"`
+	Mapped      bool   `json:"mapped,omitempty"`
+}
+
+// ApplyModelLifecycle 处理对弃用模型的请求并返回实际请求的模型：记录令牌的使用并附加弃用警告响应头，
+// 下线后开启自动映射时改为替代模型，否则返回 410 及提示信息
+func ApplyModelLifecycle(c *gin.Context, modelName string) (string, int, string) {
+	deprecation, ok := model_setting.GetModelDeprecation(modelName)
+	if !ok {
+		return modelName, 0, ""
+	}
+	recordModelDeprecationUsage(c, modelName)
+
+	notice := &ModelDeprecationNotice{Model: modelName, SunsetDate: deprecation.SunsetDate, Replacement: deprecation.Replacement}
+	sunset, hasSunset := deprecation.SunsetTime()
+	if hasSunset {
+		c.Header("Sunset", sunset.UTC().Format(http.TimeFormat))
+	}
+	if hasSunset && !time.Now().Before(sunset) {
+		if !model_setting.GetModelLifecycleSettings().AutoMapAfterSunset || deprecation.Replacement == "" {
+			args := map[string]any{"Model": modelName, "Date": deprecation.SunsetDate, "Replacement": deprecation.Replacement}
+			if deprecation.Replacement == "" {
+				return modelName, http.StatusGone, i18n.T(c, i18n.MsgDistributorModelSunset, args)
+			}
+			return modelName, http.StatusGone, i18n.T(c, i18n.MsgDistributorModelSunsetReplace, args)
+		}
+		notice.Mapped = true
+		c.Header(ModelDeprecationHeader, fmt.Sprintf("model %s was retired on %s, served by %s", modelName, deprecation.SunsetDate, deprecation.Replacement))
+		logger.LogInfo(c, fmt.Sprintf("model %s was retired, mapped to %s", modelName, deprecation.Replacement))
+		common.SetContextKey(c, constant.ContextKeyModelDeprecation, notice)
+		return deprecation.Replacement, 0, ""
+	}
+
+	warning := fmt.Sprintf("model %s is deprecated", modelName)
+	if hasSunset {
+		warning += " and will be retired on " + deprecation.SunsetDate
+	}
+	if deprecation.Replacement != "" {
+		warning += ", use " + deprecation.Replacement + " instead"
+	}
+	c.Header(ModelDeprecationHeader, warning)
+	common.SetContextKey(c, constant.ContextKeyModelDeprecation, notice)
+	return modelName, 0, ""
+}
+
+// GetModelDeprecationNotice returns the deprecation notice of the current request, if any.
+func GetModelDeprecationNotice(c *gin.Context) *ModelDeprecationNotice {
+	if c == nil {
+		return nil
+	}
+	value, ok := common.GetContextKey(c, constant.ContextKeyModelDeprecation)
+	if !ok {
+		return nil
+	}
+	notice, _ := value.(*ModelDeprecationNotice)
+	return notice
+}
+
+func recordModelDeprecationUsage(c *gin.Context, modelName string) {
+	tokenId := common.GetContextKeyInt(c, constant.ContextKeyTokenId)
+	if tokenId == 0 {
+		return
+	}
+	tokenName := c.GetString("token_name")
+	userId := c.GetInt("id")
+	now := common.GetTimestamp()
+	gopool.Go(func() {
+		if err := model.RecordModelDeprecationUsage(tokenId, tokenName, userId, modelName, now); err != nil {
+			common.SysLog("record model deprecation usage failed: " + err.Error())
+		}
+	})
+}
@@ -0,0 +1,293 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/constant"
+	"github.com/QuantumNous/new-api/dto"
+	"github.com/QuantumNous/new-api/logger"
+	"github.com/QuantumNous/new-api/setting/model_setting"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ModelRouterDecision records how a virtual router model was resolved; it is
+// stored in the request context and written to the consume log.
+type ModelRouterDecision struct {
+	VirtualModel string `json:"virtual_model"`
+	TargetModel  string `json:"target_model"`
+	Classifier   string `json:"classifier"`
+	Reason       string `json:"reason"`
+}
+
+// modelRouterFeatures are the request properties the classifiers look at.
+type modelRouterFeatures struct {
+	Text         string
+	PromptLength int
+	HasTools     bool
+	Language     string
+}
+
+// RouteVirtualModel picks the target model for a request to the virtual router model.
+func RouteVirtualModel(c *gin.Context, virtualModel string) (*ModelRouterDecision, error) {
+	settings := model_setting.GetModelRouterSettings()
+	if len(settings.Rules) == 0 && settings.DefaultModel == "" {
+		return nil, errors.New("model router has no target models configured")
+	}
+	features, err := extractModelRouterFeatures(c)
+	if err != nil {
+		return nil, err
+	}
+
+	decision := &ModelRouterDecision{VirtualModel: virtualModel}
+	if settings.Classifier == model_setting.ModelRouterClassifierLLM && settings.ClassifierModel != "" {
+		target, llmErr := classifyModelByLLM(c, settings, features)
+		if llmErr == nil {
+			decision.TargetModel = target
+			decision.Classifier = model_setting.ModelRouterClassifierLLM
+			decision.Reason = "llm classifier"
+		} else {
+			logger.LogWarn(c, fmt.Sprintf("model router llm classifier failed, fallback to heuristic: %s", llmErr.Error()))
+		}
+	}
+	if decision.TargetModel == "" {
+		decision.TargetModel, decision.Reason = classifyModelByHeuristic(settings, features)
+		decision.Classifier = model_setting.ModelRouterClassifierHeuristic
+	}
+	if decision.TargetModel == "" {
+		return nil, fmt.Errorf("model router found no target model for language %s, prompt length %d", features.Language, features.PromptLength)
+	}
+
+	common.SetContextKey(c, constant.ContextKeyModelRouterDecision, decision)
+	logger.LogInfo(c, fmt.Sprintf("model router: %s -> %s (%s: %s)", virtualModel, decision.TargetModel, decision.Classifier, decision.Reason))
+	return decision, nil
+}
+
+// GetModelRouterDecision returns the routing decision of the current request, if any.
+func GetModelRouterDecision(c *gin.Context) *ModelRouterDecision {
+	if c == nil {
+		return nil
+	}
+	value, ok := common.GetContextKey(c, constant.ContextKeyModelRouterDecision)
+	if !ok {
+		return nil
+	}
+	decision, _ := value.(*ModelRouterDecision)
+	return decision
+}
+
+func extractModelRouterFeatures(c *gin.Context) (*modelRouterFeatures, error) {
+	storage, err := common.GetBodyStorage(c)
+	if err != nil {
+		return nil, err
+	}
+	body, err := storage.Bytes()
+	if err != nil {
+		return nil, err
+	}
+	var request struct {
+		Messages []struct {
+			Content any `json:"content"`
+		} `json:"messages"`
+		System any   `json:"system"`
+		Input  any   `json:"input"`
+		Prompt any   `json:"prompt"`
+		Tools  []any `json:"tools"`
+	}
+	if err = common.Unmarshal(body, &request); err != nil {
+		return nil, fmt.Errorf("model router failed to parse request: %w", err)
+	}
+
+	var sb strings.Builder
+	collectModelRouterText(&sb, request.System)
+	for _, message := range request.Messages {
+		collectModelRouterText(&sb, message.Content)
+	}
+	collectModelRouterText(&sb, request.Input)
+	collectModelRouterText(&sb, request.Prompt)
+
+	text := sb.String()
+	return &modelRouterFeatures{
+		Text:         text,
+		PromptLength: utf8.RuneCountInString(text),
+		HasTools:     len(request.Tools) > 0,
+		Language:     detectPromptLanguage(text),
+	}, nil
+}
+
+// collectModelRouterText gathers plain text from string content, content part arrays
+// and nested message objects of the chat, responses and claude request formats.
+func collectModelRouterText(sb *strings.Builder, value any) {
+	switch v := value.(type) {
+	case string:
+		if sb.Len() > 0 {
+			sb.WriteByte('\n')
+		}
+		sb.WriteString(v)
+	case []any:
+		for _, item := range v {
+			collectModelRouterText(sb, item)
+		}
+	case map[string]any:
+		if text, ok := v["text"].(string); ok {
+			collectModelRouterText(sb, text)
+		}
+		if content, ok := v["content"]; ok {
+			collectModelRouterText(sb, content)
+		}
+	}
+}
+
+// detectPromptLanguage is a coarse script-based guess: zh / ja / ko, otherwise en.
+func detectPromptLanguage(text string) string {
+	var han, kana, hangul, letters int
+	for _, r := range text {
+		switch {
+		case unicode.Is(unicode.Hiragana, r), unicode.Is(unicode.Katakana, r):
+			kana++
+		case unicode.Is(unicode.Hangul, r):
+			hangul++
+		case unicode.Is(unicode.Han, r):
+			han++
+		case unicode.IsLetter(r):
+			letters++
+		}
+	}
+	cjk := han + kana + hangul
+	if cjk == 0 || cjk*2 < letters {
+		return "en"
+	}
+	switch {
+	case kana > 0:
+		return "ja"
+	case hangul >= han:
+		return "ko"
+	default:
+		return "zh"
+	}
+}
+
+// classifyModelByHeuristic returns the first rule whose conditions all match,
+// falling back to the default model.
+func classifyModelByHeuristic(settings *model_setting.ModelRouterSettings, features *modelRouterFeatures) (string, string) {
+	for i, rule := range settings.Rules {
+		if rule.Model == "" {
+			continue
+		}
+		if rule.MinPromptLength > 0 && features.PromptLength < rule.MinPromptLength {
+			continue
+		}
+		if rule.MaxPromptLength > 0 && features.PromptLength > rule.MaxPromptLength {
+			continue
+		}
+		if rule.RequireTools && !features.HasTools {
+			continue
+		}
+		if len(rule.Languages) > 0 && !common.StringsContains(rule.Languages, features.Language) {
+			continue
+		}
+		return rule.Model, fmt.Sprintf("rule #%d (length %d, tools %t, language %s)", i+1, features.PromptLength, features.HasTools, features.Language)
+	}
+	return settings.DefaultModel, "default model"
+}
+
+const modelRouterClassifierPromptMaxRunes = 4000
+
+// classifyModelByLLM asks a small model which candidate fits the prompt best. The
+// answer must be exactly one of the configured rule models.
+func classifyModelByLLM(c *gin.Context, settings *model_setting.ModelRouterSettings, features *modelRouterFeatures) (string, error) {
+	candidates := make([]string, 0, len(settings.Rules)+1)
+	var sb strings.Builder
+	sb.WriteString("You route user requests to the most suitable model. Reply with the model name only.\nCandidates:\n")
+	for _, rule := range settings.Rules {
+		if rule.Model == "" || common.StringsContains(candidates, rule.Model) {
+			continue
+		}
+		candidates = append(candidates, rule.Model)
+		sb.WriteString("- " + rule.Model)
+		if rule.Description != "" {
+			sb.WriteString(": " + rule.Description)
+		}
+		sb.WriteByte('\n')
+	}
+	if settings.DefaultModel != "" && !common.StringsContains(candidates, settings.DefaultModel) {
+		candidates = append(candidates, settings.DefaultModel)
+		sb.WriteString("- " + settings.DefaultModel + "\n")
+	}
+
+	prompt := features.Text
+	if utf8.RuneCountInString(prompt) > modelRouterClassifierPromptMaxRunes {
+		prompt = string([]rune(prompt)[:modelRouterClassifierPromptMaxRunes])
+	}
+	if features.HasTools {
+		prompt += "\n\n[The request declares tools]"
+	}
+
+	request := map[string]any{
+		"model": settings.ClassifierModel,
+		"messages": []map[string]string{
+			{"role": "system", "content": sb.String()},
+			{"role": "user", "content": prompt},
+		},
+		"temperature": 0,
+		"max_tokens":  32,
+		"stream":      false,
+	}
+	payload, err := common.Marshal(request)
+	if err != nil {
+		return "", err
+	}
+
+	timeout := time.Duration(settings.ClassifierTimeoutMs) * time.Millisecond
+	if timeout <= 0 {
+		timeout = 3 * time.Second
+	}
+	ctx, cancel := context.WithTimeout(c.Request.Context(), timeout)
+	defer cancel()
+
+	url := strings.TrimSuffix(settings.ClassifierBaseURL, "/") + "/v1/chat/completions"
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(payload))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if settings.ClassifierAPIKey != "" {
+		req.Header.Set("Authorization", "Bearer "+settings.ClassifierAPIKey)
+	}
+	resp, err := GetHttpClient().Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return "", err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("classifier returned status %d", resp.StatusCode)
+	}
+	var textResponse dto.OpenAITextResponse
+	if err = common.Unmarshal(body, &textResponse); err != nil {
+		return "", err
+	}
+	if len(textResponse.Choices) == 0 {
+		return "", errors.New("classifier returned no choices")
+	}
+	answer := strings.Trim(strings.TrimSpace(textResponse.Choices[0].Message.StringContent()), "`\"'.")
+	for _, candidate := range candidates {
+		if strings.EqualFold(answer, candidate) {
+			return candidate, nil
+		}
+	}
+	return "", fmt.Errorf("classifier answered unknown model %q", answer)
+}
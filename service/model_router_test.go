@@ -0,0 +1,37 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/QuantumNous/new-api/setting/model_setting"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDetectPromptLanguage(t *testing.T) {
+	require.Equal(t, "en", detectPromptLanguage("hello world"))
+	require.Equal(t, "zh", detectPromptLanguage("你好，请帮我写一首诗"))
+	require.Equal(t, "ja", detectPromptLanguage("こんにちは、日本語です"))
+	require.Equal(t, "ko", detectPromptLanguage("안녕하세요"))
+	require.Equal(t, "en", detectPromptLanguage("please translate 你好 into english for me"))
+}
+
+func TestClassifyModelByHeuristic(t *testing.T) {
+	settings := &model_setting.ModelRouterSettings{
+		DefaultModel: "small",
+		Rules: []model_setting.ModelRouterRule{
+			{Model: "tool-model", RequireTools: true},
+			{Model: "zh-model", Languages: []string{"zh"}},
+			{Model: "long-model", MinPromptLength: 100},
+		},
+	}
+
+	target, _ := classifyModelByHeuristic(settings, &modelRouterFeatures{PromptLength: 10, HasTools: true, Language: "zh"})
+	require.Equal(t, "tool-model", target)
+	target, _ = classifyModelByHeuristic(settings, &modelRouterFeatures{PromptLength: 10, Language: "zh"})
+	require.Equal(t, "zh-model", target)
+	target, _ = classifyModelByHeuristic(settings, &modelRouterFeatures{PromptLength: 200, Language: "en"})
+	require.Equal(t, "long-model", target)
+	target, reason := classifyModelByHeuristic(settings, &modelRouterFeatures{PromptLength: 10, Language: "en"})
+	require.Equal(t, "small", target)
+	require.Equal(t, "default model", reason)
+}
@@ -0,0 +1,122 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/logger"
+	"github.com/QuantumNous/new-api/model"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+	"github.com/QuantumNous/new-api/types"
+
+	"github.com/bytedance/gopkg/util/gopool"
+	"github.com/gin-gonic/gin"
+	"github.com/samber/lo"
+)
+
+// 审核动作
+const (
+	ModerationActionApprove  = "approve"
+	ModerationActionFlagUser = "flag_user"
+)
+
+// ModerationReviewEnabled 是否开启了待审词检查
+func ModerationReviewEnabled() bool {
+	setting := operation_setting.GetModerationReviewSetting()
+	return setting.Enabled && len(setting.FlagWords) > 0
+}
+
+// CheckModerationReview 审核的第二阶段：提示词命中待审词时，信任分低于阈值的用户直接拦截，
+// 其他用户放行并记录命中的待审词，同时开启载荷捕获，请求结束后由 SaveModerationReview 加入审核队列
+func CheckModerationReview(c *gin.Context, info *relaycommon.RelayInfo, text string) *types.NewAPIError {
+	if !ModerationReviewEnabled() || info.RelayFormat == types.RelayFormatOpenAIRealtime {
+		return nil
+	}
+	setting := operation_setting.GetModerationReviewSetting()
+	contains, words := AcSearch(strings.ToLower(text), setting.FlagWords, false)
+	if !contains {
+		return nil
+	}
+	words = lo.Uniq(words)
+	if setting.BlockBelowTrust > 0 {
+		trustScore, err := model.GetUserTrustScore(info.UserId)
+		if err != nil {
+			logger.LogError(c, "get user trust score failed: "+err.Error())
+		} else if trustScore < setting.BlockBelowTrust {
+			logger.LogWarn(c, fmt.Sprintf("flagged words detected for low trust user (trust score %d): %s", trustScore, strings.Join(words, ", ")))
+			return types.NewError(errors.New("sensitive words detected"), types.ErrorCodeSensitiveWordsDetected, types.ErrOptionWithSkipRetry())
+		}
+	}
+	logger.LogInfo(c, fmt.Sprintf("flagged words detected, queued for review: %s", strings.Join(words, ", ")))
+	info.ModerationFlagWords = words
+	if info.Capture == nil {
+		info.Capture = relaycommon.NewRequestCapture(setting.MaxBodyKB << 10)
+		if storage, err := common.GetBodyStorage(c); err == nil {
+			if body, err := storage.Bytes(); err == nil {
+				info.CaptureStage(relaycommon.CaptureStageInbound, body)
+			}
+		}
+	}
+	return nil
+}
+
+// SaveModerationReview 异步将命中待审词的请求及其上游响应加入审核队列
+func SaveModerationReview(c *gin.Context, info *relaycommon.RelayInfo) {
+	if info == nil || len(info.ModerationFlagWords) == 0 || info.Capture == nil {
+		return
+	}
+	review := &model.ModerationReview{
+		RequestId: c.GetString(common.RequestIdKey),
+		UserId:    info.UserId,
+		TokenId:   info.TokenId,
+		ModelName: info.OriginModelName,
+		Words:     strings.Join(info.ModerationFlagWords, ","),
+		Status:    model.ModerationReviewPending,
+		CreatedAt: common.GetTimestamp(),
+	}
+	if info.ChannelMeta != nil {
+		review.ChannelId = info.ChannelId
+	}
+	for _, stage := range info.Capture.Stages() {
+		switch stage.Name {
+		case relaycommon.CaptureStageInbound:
+			if review.Request == "" {
+				review.Request = stage.Body
+			}
+		case relaycommon.CaptureStageUpstreamResponse:
+			// 重试时保留最后一次上游响应
+			review.Response = stage.Body
+		}
+	}
+	gopool.Go(func() {
+		if err := model.CreateModerationReview(review); err != nil {
+			common.SysLog("save moderation review failed: " + err.Error())
+		}
+	})
+}
+
+// ReviewModeration 处理审核队列中的记录：approve 通过，flag_user 标记用户，并按配置调整用户信任分
+func ReviewModeration(id int, action string, reviewerId int) (*model.ModerationReview, error) {
+	setting := operation_setting.GetModerationReviewSetting()
+	var status string
+	var trustDelta int
+	switch action {
+	case ModerationActionApprove:
+		status, trustDelta = model.ModerationReviewApproved, setting.ApproveTrustDelta
+	case ModerationActionFlagUser:
+		status, trustDelta = model.ModerationReviewFlagged, setting.FlagTrustDelta
+	default:
+		return nil, fmt.Errorf("invalid review action: %s", action)
+	}
+	review, err := model.GetModerationReviewById(id)
+	if err != nil {
+		return nil, err
+	}
+	if err = model.ReviewModerationReview(review, status, reviewerId, trustDelta); err != nil {
+		return nil, err
+	}
+	return review, nil
+}
@@ -1,10 +1,35 @@
 package service
 
 import (
+	"context"
+	"errors"
+
 	"github.com/QuantumNous/new-api/dto"
+	"github.com/QuantumNous/new-api/model"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
 	"github.com/QuantumNous/new-api/service/openaicompat"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	ComputerToolType     = openaicompat.ComputerToolType
+	ComputerToolCallName = openaicompat.ComputerToolCallName
+
+	IncludeOutputTextLogprobs    = openaicompat.IncludeOutputTextLogprobs
+	IncludeFileSearchCallResults = openaicompat.IncludeFileSearchCallResults
 )
 
+// NormalizeResponsesWebSearchTools renames the web search tool variants of a Responses request to web_search.
+func NormalizeResponsesWebSearchTools(req *dto.OpenAIResponsesRequest) error {
+	return openaicompat.NormalizeResponsesWebSearchTools(req)
+}
+
+// ComputerActionToClaude converts "computer" tool call arguments into Anthropic computer tool input.
+func ComputerActionToClaude(arguments string) map[string]any {
+	return openaicompat.ComputerActionToClaude(arguments)
+}
+
 func ChatCompletionsRequestToResponsesRequest(req *dto.GeneralOpenAIRequest) (*dto.OpenAIResponsesRequest, error) {
 	return openaicompat.ChatCompletionsRequestToResponsesRequest(req)
 }
@@ -17,20 +42,139 @@ func ExtractOutputTextFromResponses(resp *dto.OpenAIResponsesResponse) string {
 	return openaicompat.ExtractOutputTextFromResponses(resp)
 }
 
+// NewResponsesToChatStreamAdapter creates a new stream adapter for converting
+// Responses stream events to Chat Completions stream chunks.
+func NewResponsesToChatStreamAdapter(id string, created int64, model string) *openaicompat.ResponsesToChatStreamAdapter {
+	return openaicompat.NewResponsesToChatStreamAdapter(id, created, model)
+}
+
 // ResponsesRequestToChatCompletionsRequest converts an OpenAI Responses API request
 // to a Chat Completions API request for channels that don't support Responses API natively.
-func ResponsesRequestToChatCompletionsRequest(req *dto.OpenAIResponsesRequest) (*dto.GeneralOpenAIRequest, error) {
-	return openaicompat.ResponsesRequestToChatCompletionsRequest(req)
+// The conversation of previous_response_id is restored from the responses store, and the
+// converted conversation is kept on info so the response can be stored when store is not false.
+// With truncation "auto" the oldest messages sent upstream are dropped to fit the context window,
+// while the stored conversation stays complete.
+func ResponsesRequestToChatCompletionsRequest(info *relaycommon.RelayInfo, req *dto.OpenAIResponsesRequest) (*dto.GeneralOpenAIRequest, error) {
+	storeEnabled := ResponsesStoreBackend() != ResponsesStoreOff
+	var previous *model.StoredResponse
+	var history []dto.Message
+	if req != nil && req.PreviousResponseID != "" && storeEnabled {
+		var err error
+		previous, history, err = loadStoredConversation(info.UserId, req.PreviousResponseID)
+		if err != nil {
+			return nil, err
+		}
+	}
+	chatReq, err := openaicompat.ResponsesRequestToChatCompletionsRequest(req, history)
+	if err != nil {
+		return nil, err
+	}
+	info.ResponsesConversation = nil
+	info.ResponsesPreviousId = ""
+	info.ResponsesHistoryLen = 0
+	info.ResponsesConversationId = ""
+	if storeEnabled && openaicompat.ResponsesStoreRequested(req) {
+		info.ResponsesConversation = openaicompat.ResponsesConversationMessages(req, chatReq)
+		info.ResponsesPreviousId = req.PreviousResponseID
+		if previous != nil {
+			info.ResponsesHistoryLen = len(history)
+			info.ResponsesConversationId = storedConversationId(previous)
+		}
+	}
+	if openaicompat.ResponsesTruncationAuto(req) {
+		TruncateResponsesConversation(info, chatReq)
+	}
+	return chatReq, nil
+}
+
+// ChatResponseAssistantMessage returns the assistant message stored for a converted response.
+func ChatResponseAssistantMessage(chatResp *dto.OpenAITextResponse) *dto.Message {
+	return openaicompat.ChatResponseAssistantMessage(chatResp)
 }
 
 // ChatCompletionsResponseToResponsesResponse converts a Chat Completions response
-// to an OpenAI Responses API response format.
-func ChatCompletionsResponseToResponsesResponse(chatResp *dto.OpenAITextResponse, originalReq *dto.OpenAIResponsesRequest) *dto.OpenAIResponsesResponse {
-	return openaicompat.ChatCompletionsResponseToResponsesResponse(chatResp, originalReq)
+// to an OpenAI Responses API response format. Reasoning is reported as separate reasoning
+// items when enabled for the channel, otherwise in the shape pinned by the client API version.
+// Background requests keep the response ID the gateway returned when queuing them, and the
+// provenance fields are added to the metadata when enabled.
+func ChatCompletionsResponseToResponsesResponse(chatResp *dto.OpenAITextResponse, originalReq *dto.OpenAIResponsesRequest, info *relaycommon.RelayInfo) *dto.OpenAIResponsesResponse {
+	resp := openaicompat.ChatCompletionsResponseToResponsesResponse(chatResp, originalReq)
+	if info.ResponsesBackgroundId != "" {
+		resp.ID = info.ResponsesBackgroundId
+	}
+	if info.ResponsesReasoningItems() {
+		openaicompat.UseReasoningItems(resp)
+	} else if info.ClientApiVersion.ReasoningTextEvents() {
+		openaicompat.UseReasoningTextParts(resp)
+	}
+	resp.Metadata = ApplyProvenanceMetadata(resp.Metadata)
+	return resp
 }
 
 // NewChatToResponsesStreamAdapter creates a new stream adapter for converting
-// Chat Completions stream to Responses stream format.
-func NewChatToResponsesStreamAdapter(originalReq *dto.OpenAIResponsesRequest) *openaicompat.ChatToResponsesStreamAdapter {
-	return openaicompat.NewChatToResponsesStreamAdapter(originalReq)
+// Chat Completions stream to Responses stream format, emitting reasoning the same way
+// as ChatCompletionsResponseToResponsesResponse. Tool call arguments are retained for
+// the done events when the client profile requires them. The response is stored when it
+// completes, before the response.completed event is sent.
+func NewChatToResponsesStreamAdapter(c *gin.Context, originalReq *dto.OpenAIResponsesRequest, info *relaycommon.RelayInfo) *openaicompat.ChatToResponsesStreamAdapter {
+	adapter := openaicompat.NewChatToResponsesStreamAdapter(originalReq)
+	adapter.OnCompleted = func() {
+		SaveResponsesConversation(c, info, adapter.GetResponseID(), adapter.AssistantMessage(), adapter.CompletedResponse())
+	}
+	adapter.ReasoningItems = info.ResponsesReasoningItems()
+	adapter.ReasoningText = info.ClientApiVersion.ReasoningTextEvents()
+	if profile := info.GetClientProfile(); profile != nil {
+		adapter.RetainToolArguments = profile.RetainToolArguments
+	}
+	return adapter
+}
+
+// ResponsesFileSearch executes the file_search tool of a Responses request against the
+// gateway vector stores and injects the results into the converted chat request.
+// It returns nil when the request has no file_search tool.
+func ResponsesFileSearch(ctx context.Context, userId int, req *dto.OpenAIResponsesRequest, chatReq *dto.GeneralOpenAIRequest) (*dto.ResponsesOutput, error) {
+	tool := openaicompat.ExtractFileSearchTool(req)
+	if tool == nil {
+		return nil, nil
+	}
+	if len(tool.VectorStoreIds) == 0 {
+		return nil, errors.New("file_search tool requires vector_store_ids")
+	}
+	query := openaicompat.FileSearchQuery(chatReq.Messages)
+	var results []dto.VectorStoreSearchResult
+	if query != "" {
+		options := FileSearchOptions{
+			VectorStoreIds: tool.VectorStoreIds,
+			Query:          query,
+			MaxResults:     tool.MaxNumResults,
+		}
+		if tool.RankingOptions != nil {
+			options.ScoreThreshold = tool.RankingOptions.ScoreThreshold
+		}
+		var err error
+		results, err = FileSearch(ctx, userId, options)
+		if err != nil {
+			return nil, err
+		}
+	}
+	openaicompat.ApplyFileSearchResults(chatReq, results)
+	output := openaicompat.BuildFileSearchCallOutput(query, results, openaicompat.FileSearchIncludeResults(req))
+	return &output, nil
+}
+
+// ResponsesUsesGatewayVectorStores reports whether the file_search tool of a Responses
+// request references vector stores created on this gateway, which only the gateway can search.
+func ResponsesUsesGatewayVectorStores(userId int, req *dto.OpenAIResponsesRequest) (bool, error) {
+	if !VectorStoreEnabled() {
+		return false, nil
+	}
+	tool := openaicompat.ExtractFileSearchTool(req)
+	if tool == nil || len(tool.VectorStoreIds) == 0 {
+		return false, nil
+	}
+	stores, err := model.GetVectorStoresByIds(tool.VectorStoreIds, userId)
+	if err != nil {
+		return false, err
+	}
+	return len(stores) > 0, nil
 }
@@ -0,0 +1,81 @@
+package openaicompat
+
+import (
+	"regexp"
+	"strconv"
+	"unicode/utf8"
+
+	"github.com/QuantumNous/new-api/dto"
+)
+
+// citationMarkerPattern matches the numbered citation markers ([1], [2], ...) that refer
+// to the top-level citations list of a chat response, as returned by Perplexity.
+var citationMarkerPattern = regexp.MustCompile(`\[(\d+)\]`)
+
+// responsesAnnotations returns the Responses output_text annotations of a chat message.
+// Message annotations are used when present; otherwise the citation markers of the text
+// are resolved against the citations list. The result is never nil.
+func responsesAnnotations(annotations []any, text string, citations []string, searchResults []dto.ChatSearchResult) []any {
+	if converted := chatAnnotationsToResponses(annotations); len(converted) > 0 {
+		return converted
+	}
+	return citationAnnotations(text, citations, searchResults)
+}
+
+// chatAnnotationsToResponses converts Chat Completions annotations, which nest their details
+// under the annotation type ({"type":"url_citation","url_citation":{"url":...}}), to the flat
+// Responses form ({"type":"url_citation","url":...}). Annotations already flat are kept as is.
+func chatAnnotationsToResponses(annotations []any) []any {
+	converted := make([]any, 0, len(annotations))
+	for _, a := range annotations {
+		annotation, ok := a.(map[string]any)
+		if !ok {
+			continue
+		}
+		annotationType, _ := annotation["type"].(string)
+		details, ok := annotation[annotationType].(map[string]any)
+		if !ok {
+			converted = append(converted, annotation)
+			continue
+		}
+		flat := make(map[string]any, len(details)+1)
+		for key, value := range details {
+			flat[key] = value
+		}
+		flat["type"] = annotationType
+		converted = append(converted, flat)
+	}
+	return converted
+}
+
+// citationAnnotations builds a url_citation annotation for every citation marker of text that
+// refers to an entry of citations. Titles are taken from the search result at the same position
+// when it has the same URL. Indexes count characters, like the annotations of OpenAI.
+func citationAnnotations(text string, citations []string, searchResults []dto.ChatSearchResult) []any {
+	annotations := make([]any, 0)
+	if len(citations) == 0 {
+		return annotations
+	}
+	byteOffset, charOffset := 0, 0
+	for _, match := range citationMarkerPattern.FindAllStringSubmatchIndex(text, -1) {
+		n, err := strconv.Atoi(text[match[2]:match[3]])
+		if err != nil || n < 1 || n > len(citations) {
+			continue
+		}
+		charOffset += utf8.RuneCountInString(text[byteOffset:match[0]])
+		byteOffset = match[0]
+		url := citations[n-1]
+		title := url
+		if n <= len(searchResults) && searchResults[n-1].Url == url && searchResults[n-1].Title != "" {
+			title = searchResults[n-1].Title
+		}
+		annotations = append(annotations, map[string]any{
+			"type":        "url_citation",
+			"start_index": charOffset,
+			"end_index":   charOffset + utf8.RuneCountInString(text[match[0]:match[1]]),
+			"url":         url,
+			"title":       title,
+		})
+	}
+	return annotations
+}
@@ -0,0 +1,91 @@
+package openaicompat
+
+import (
+	"testing"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/dto"
+	"github.com/stretchr/testify/require"
+)
+
+func TestChatAnnotationsToResponsesOutput(t *testing.T) {
+	var annotations []any
+	require.NoError(t, common.Unmarshal([]byte(`[{"type":"url_citation","url_citation":{"start_index":0,"end_index":5,"url":"https://example.com","title":"Example"}}]`), &annotations))
+	msg := dto.Message{Role: "assistant", Content: "Hello world", Annotations: annotations}
+	resp := ChatCompletionsResponseToResponsesResponse(&dto.OpenAITextResponse{
+		Choices: []dto.OpenAITextResponseChoice{{Message: msg, FinishReason: "stop"}},
+	}, &dto.OpenAIResponsesRequest{})
+	data, err := common.Marshal(resp.Output[0].Content[0].Annotations)
+	require.NoError(t, err)
+	require.JSONEq(t, `[{"type":"url_citation","start_index":0,"end_index":5,"url":"https://example.com","title":"Example"}]`, string(data))
+
+	resp = ChatCompletionsResponseToResponsesResponse(&dto.OpenAITextResponse{
+		Choices: []dto.OpenAITextResponseChoice{{Message: dto.Message{Role: "assistant", Content: "plain"}, FinishReason: "stop"}},
+	}, &dto.OpenAIResponsesRequest{})
+	require.Equal(t, []any{}, resp.Output[0].Content[0].Annotations)
+}
+
+func TestCitationMarkersToAnnotations(t *testing.T) {
+	chatResp := &dto.OpenAITextResponse{
+		Citations:     []string{"https://a.example", "https://b.example"},
+		SearchResults: []dto.ChatSearchResult{{Title: "A", Url: "https://a.example"}},
+		Choices: []dto.OpenAITextResponseChoice{{
+			Message:      dto.Message{Role: "assistant", Content: "天空是蓝的[1]，草是绿的[2][3]。"},
+			FinishReason: "stop",
+		}},
+	}
+	resp := ChatCompletionsResponseToResponsesResponse(chatResp, &dto.OpenAIResponsesRequest{})
+	data, err := common.Marshal(resp.Output[0].Content[0].Annotations)
+	require.NoError(t, err)
+	require.JSONEq(t, `[
+		{"type":"url_citation","start_index":5,"end_index":8,"url":"https://a.example","title":"A"},
+		{"type":"url_citation","start_index":13,"end_index":16,"url":"https://b.example","title":"https://b.example"}
+	]`, string(data))
+}
+
+func TestStreamAnnotationAddedEvents(t *testing.T) {
+	adapter := NewChatToResponsesStreamAdapter(&dto.OpenAIResponsesRequest{})
+	textChunk := &dto.ChatCompletionsStreamResponse{
+		Citations: []string{"https://a.example"},
+		Choices:   []dto.ChatCompletionsStreamResponseChoice{{}},
+	}
+	textChunk.Choices[0].Delta.SetContentString("Sky is blue [1].")
+	finish := "stop"
+	finishChunk := &dto.ChatCompletionsStreamResponse{
+		Citations: []string{"https://a.example"},
+		Choices:   []dto.ChatCompletionsStreamResponseChoice{{FinishReason: &finish}},
+	}
+
+	type event struct {
+		Type            string         `json:"type"`
+		AnnotationIndex int            `json:"annotation_index"`
+		Annotation      map[string]any `json:"annotation"`
+	}
+	var added []event
+	var types []string
+	for _, c := range []*dto.ChatCompletionsStreamResponse{textChunk, finishChunk} {
+		for _, data := range adapter.ConvertChunk(c) {
+			var e event
+			require.NoError(t, common.Unmarshal(data, &e))
+			types = append(types, e.Type)
+			if e.Type == "response.output_text.annotation.added" {
+				added = append(added, e)
+			}
+		}
+	}
+	require.Len(t, added, 1)
+	require.Equal(t, 0, added[0].AnnotationIndex)
+	require.Equal(t, "https://a.example", added[0].Annotation["url"])
+	require.EqualValues(t, 12, added[0].Annotation["start_index"])
+	require.Contains(t, types, "response.output_text.done")
+
+	var completed struct {
+		Output []struct {
+			Content []struct {
+				Annotations []map[string]any `json:"annotations"`
+			} `json:"content"`
+		} `json:"output"`
+	}
+	require.NoError(t, common.Unmarshal(adapter.CompletedResponse(), &completed))
+	require.Len(t, completed.Output[0].Content[0].Annotations, 1)
+}
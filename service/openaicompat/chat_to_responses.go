@@ -391,6 +391,10 @@ func ChatCompletionsRequestToResponsesRequest(req *dto.GeneralOpenAIRequest) (*d
 		out.MaxOutputTokens = lo.ToPtr(maxOutputTokens)
 	}
 
+	if len(req.ServiceTier) > 0 {
+		_ = common.Unmarshal(req.ServiceTier, &out.ServiceTier)
+	}
+
 	if req.ReasoningEffort != "" {
 		out.Reasoning = &dto.Reasoning{
 			Effort:  req.ReasoningEffort,
@@ -398,5 +402,11 @@ func ChatCompletionsRequestToResponsesRequest(req *dto.GeneralOpenAIRequest) (*d
 		}
 	}
 
+	// Chat logprobs are requested as output text logprobs and mapped back by the response conversion
+	if req.LogProbs != nil && *req.LogProbs {
+		out.Include, _ = common.Marshal([]string{IncludeOutputTextLogprobs})
+		out.TopLogProbs = req.TopLogProbs
+	}
+
 	return out, nil
 }
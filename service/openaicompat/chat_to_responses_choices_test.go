@@ -0,0 +1,88 @@
+package openaicompat
+
+import (
+	"testing"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/dto"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMultipleChoicesToResponsesResponse(t *testing.T) {
+	first := dto.Message{Role: "assistant"}
+	first.SetStringContent("first")
+	second := dto.Message{Role: "assistant"}
+	second.SetToolCalls([]dto.ToolCallResponse{{ID: "call_1", Type: "function", Function: dto.FunctionResponse{Name: "lookup", Arguments: `{}`}}})
+
+	resp := ChatCompletionsResponseToResponsesResponse(&dto.OpenAITextResponse{
+		Choices: []dto.OpenAITextResponseChoice{
+			{Index: 1, Message: second, FinishReason: "tool_calls"},
+			{Index: 0, Message: first, FinishReason: "length"},
+		},
+	}, &dto.OpenAIResponsesRequest{})
+
+	require.Len(t, resp.Output, 2)
+	require.Equal(t, "message", resp.Output[0].Type)
+	require.Equal(t, "first", resp.Output[0].Content[0].Text)
+	require.Equal(t, "function_call", resp.Output[1].Type)
+	require.Equal(t, "call_1", resp.Output[1].CallId)
+	require.JSONEq(t, `"incomplete"`, string(resp.Status))
+}
+
+func TestStreamAdapterMultipleChoices(t *testing.T) {
+	adapter := NewChatToResponsesStreamAdapter(&dto.OpenAIResponsesRequest{})
+	content := func(index int, text string) *dto.ChatCompletionsStreamResponse {
+		choice := dto.ChatCompletionsStreamResponseChoice{Index: index}
+		choice.Delta.SetContentString(text)
+		return &dto.ChatCompletionsStreamResponse{Choices: []dto.ChatCompletionsStreamResponseChoice{choice}}
+	}
+	finish := func(index int) *dto.ChatCompletionsStreamResponse {
+		reason := "stop"
+		return &dto.ChatCompletionsStreamResponse{Choices: []dto.ChatCompletionsStreamResponseChoice{{Index: index, FinishReason: &reason}}}
+	}
+
+	type event struct {
+		Type        string `json:"type"`
+		OutputIndex int    `json:"output_index"`
+		Text        string `json:"text"`
+		Response    *struct {
+			Output []struct {
+				Content []struct {
+					Text string `json:"text"`
+				} `json:"content"`
+			} `json:"output"`
+		} `json:"response"`
+	}
+	parse := func(raw [][]byte) []event {
+		events := make([]event, 0, len(raw))
+		for _, data := range raw {
+			var e event
+			require.NoError(t, common.Unmarshal(data, &e))
+			events = append(events, e)
+		}
+		return events
+	}
+
+	adapter.ConvertChunk(content(0, "alpha"))
+	adapter.ConvertChunk(content(1, "beta"))
+	adapter.ConvertChunk(content(0, " one"))
+
+	events := parse(adapter.ConvertChunk(finish(0)))
+	require.Len(t, events, 3)
+	require.Equal(t, "response.output_text.done", events[0].Type)
+	require.Equal(t, "alpha one", events[0].Text)
+	require.Equal(t, 0, events[0].OutputIndex)
+	require.Equal(t, "response.output_item.done", events[2].Type)
+
+	events = parse(adapter.ConvertChunk(finish(1)))
+	require.Len(t, events, 4)
+	require.Equal(t, "beta", events[0].Text)
+	require.Equal(t, 1, events[0].OutputIndex)
+	require.Equal(t, "response.completed", events[3].Type)
+	output := events[3].Response.Output
+	require.Len(t, output, 2)
+	require.Equal(t, "alpha one", output[0].Content[0].Text)
+	require.Equal(t, "beta", output[1].Content[0].Text)
+
+	require.Empty(t, adapter.ConvertChunk(finish(1)))
+}
@@ -0,0 +1,186 @@
+package openaicompat
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/dto"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReasoningStreamEventNames(t *testing.T) {
+	collect := func(reasoningText bool) []string {
+		adapter := NewChatToResponsesStreamAdapter(&dto.OpenAIResponsesRequest{})
+		adapter.ReasoningText = reasoningText
+		delta := dto.ChatCompletionsStreamResponseChoiceDelta{}
+		delta.SetReasoningContent("thinking")
+		finish := "stop"
+		var events [][]byte
+		events = append(events, adapter.ConvertChunk(&dto.ChatCompletionsStreamResponse{Choices: []dto.ChatCompletionsStreamResponseChoice{{Delta: delta}}})...)
+		events = append(events, adapter.ConvertChunk(&dto.ChatCompletionsStreamResponse{Choices: []dto.ChatCompletionsStreamResponseChoice{{FinishReason: &finish}}})...)
+		var types []string
+		for _, data := range events {
+			var event struct {
+				Type string `json:"type"`
+				Part *struct {
+					Type string `json:"type"`
+				} `json:"part"`
+			}
+			require.NoError(t, common.Unmarshal(data, &event))
+			types = append(types, event.Type)
+			if event.Part != nil {
+				types = append(types, "part:"+event.Part.Type)
+			}
+		}
+		return types
+	}
+
+	legacy := collect(false)
+	require.Contains(t, legacy, "response.reasoning.delta")
+	require.Contains(t, legacy, "response.reasoning.done")
+	require.Contains(t, legacy, "part:reasoning")
+
+	latest := collect(true)
+	require.Contains(t, latest, "response.reasoning_text.delta")
+	require.Contains(t, latest, "response.reasoning_text.done")
+	require.Contains(t, latest, "part:reasoning_text")
+	require.NotContains(t, latest, "response.reasoning.delta")
+	require.NotContains(t, latest, "part:reasoning")
+}
+
+func TestUseReasoningTextParts(t *testing.T) {
+	msg := dto.Message{Role: "assistant", ReasoningContent: "thinking"}
+	msg.SetStringContent("answer")
+	resp := ChatCompletionsResponseToResponsesResponse(&dto.OpenAITextResponse{
+		Choices: []dto.OpenAITextResponseChoice{{Message: msg, FinishReason: "stop"}},
+	}, &dto.OpenAIResponsesRequest{})
+	require.Equal(t, ReasoningPartType, resp.Output[0].Content[0].Type)
+
+	UseReasoningTextParts(resp)
+	require.Equal(t, ReasoningTextPartType, resp.Output[0].Content[0].Type)
+	require.Equal(t, "output_text", resp.Output[0].Content[1].Type)
+	UseReasoningTextParts(nil)
+}
+
+func TestUseReasoningItems(t *testing.T) {
+	msg := dto.Message{Role: "assistant", ReasoningContent: "thinking"}
+	msg.SetStringContent("answer")
+	resp := ChatCompletionsResponseToResponsesResponse(&dto.OpenAITextResponse{
+		Choices: []dto.OpenAITextResponseChoice{{Message: msg, FinishReason: "stop"}},
+	}, &dto.OpenAIResponsesRequest{})
+
+	UseReasoningItems(resp)
+	require.Len(t, resp.Output, 2)
+	require.Equal(t, ResponsesOutputTypeReasoning, resp.Output[0].Type)
+	require.Equal(t, []dto.ResponsesReasoningSummaryPart{{Type: ReasoningSummaryTextType, Text: "thinking"}}, resp.Output[0].Summary)
+	require.Equal(t, ResponsesOutputTypeMessage, resp.Output[1].Type)
+	require.Len(t, resp.Output[1].Content, 1)
+	require.Equal(t, "output_text", resp.Output[1].Content[0].Type)
+
+	UseReasoningItems(nil)
+}
+
+func TestStreamReasoningItems(t *testing.T) {
+	adapter := NewChatToResponsesStreamAdapter(&dto.OpenAIResponsesRequest{})
+	adapter.ReasoningItems = true
+	chunk := func(reasoning string, content string, finish string) *dto.ChatCompletionsStreamResponse {
+		choice := dto.ChatCompletionsStreamResponseChoice{}
+		if reasoning != "" {
+			choice.Delta.SetReasoningContent(reasoning)
+		}
+		if content != "" {
+			choice.Delta.SetContentString(content)
+		}
+		if finish != "" {
+			choice.FinishReason = &finish
+		}
+		return &dto.ChatCompletionsStreamResponse{Choices: []dto.ChatCompletionsStreamResponseChoice{choice}}
+	}
+
+	type event struct {
+		Type        string `json:"type"`
+		OutputIndex int    `json:"output_index"`
+		Item        *struct {
+			Type string `json:"type"`
+		} `json:"item"`
+	}
+	var types []string
+	for _, c := range []*dto.ChatCompletionsStreamResponse{chunk("thin", "", ""), chunk("king", "", ""), chunk("", "answer", ""), chunk("", "", "stop")} {
+		for _, data := range adapter.ConvertChunk(c) {
+			var e event
+			require.NoError(t, common.Unmarshal(data, &e))
+			if e.Item != nil {
+				types = append(types, fmt.Sprintf("%s:%s:%d", e.Type, e.Item.Type, e.OutputIndex))
+				continue
+			}
+			types = append(types, e.Type)
+		}
+	}
+	require.Equal(t, []string{
+		"response.created",
+		"response.in_progress",
+		"response.output_item.added:reasoning:0",
+		"response.reasoning_summary_part.added",
+		"response.reasoning_summary_text.delta",
+		"response.reasoning_summary_text.delta",
+		"response.reasoning_summary_text.done",
+		"response.reasoning_summary_part.done",
+		"response.output_item.done:reasoning:0",
+		"response.output_item.added:message:1",
+		"response.content_part.added",
+		"response.output_text.delta",
+		"response.output_text.done",
+		"response.content_part.done",
+		"response.output_item.done:message:1",
+		"response.completed",
+	}, types)
+
+	var completed struct {
+		Output []struct {
+			Type    string                              `json:"type"`
+			Summary []dto.ResponsesReasoningSummaryPart `json:"summary"`
+			Content []struct {
+				Type string `json:"type"`
+			} `json:"content"`
+		} `json:"output"`
+	}
+	require.NoError(t, common.Unmarshal(adapter.CompletedResponse(), &completed))
+	require.Len(t, completed.Output, 2)
+	require.Equal(t, []dto.ResponsesReasoningSummaryPart{{Type: ReasoningSummaryTextType, Text: "thinking"}}, completed.Output[0].Summary)
+	require.Len(t, completed.Output[1].Content, 1)
+	require.Equal(t, "output_text", completed.Output[1].Content[0].Type)
+}
+
+func TestChatUsageToResponsesUsageDetails(t *testing.T) {
+	chatUsage := &dto.Usage{
+		PromptTokens:           100,
+		CompletionTokens:       40,
+		TotalTokens:            140,
+		PromptTokensDetails:    dto.InputTokenDetails{CachedTokens: 64},
+		CompletionTokenDetails: dto.OutputTokenDetails{ReasoningTokens: 30},
+	}
+	usage := convertChatUsageToResponsesUsage(chatUsage)
+	require.Equal(t, 64, usage.InputTokensDetails.CachedTokens)
+	require.Equal(t, 30, usage.OutputTokensDetails.ReasoningTokens)
+
+	// DeepSeek reports cache hits as prompt_cache_hit_tokens
+	usage = convertChatUsageToResponsesUsage(&dto.Usage{PromptTokens: 10, PromptCacheHitTokens: 8})
+	require.Equal(t, 8, usage.InputTokensDetails.CachedTokens)
+	require.Equal(t, 0, usage.OutputTokensDetails.ReasoningTokens)
+
+	adapter := NewChatToResponsesStreamAdapter(&dto.OpenAIResponsesRequest{})
+	finish := "stop"
+	choice := dto.ChatCompletionsStreamResponseChoice{FinishReason: &finish}
+	choice.Delta.SetContentString("ok")
+	adapter.ConvertChunk(&dto.ChatCompletionsStreamResponse{Choices: []dto.ChatCompletionsStreamResponseChoice{choice}, Usage: chatUsage})
+	var completed struct {
+		Usage struct {
+			InputTokensDetails  dto.InputTokenDetails  `json:"input_tokens_details"`
+			OutputTokensDetails dto.OutputTokenDetails `json:"output_tokens_details"`
+		} `json:"usage"`
+	}
+	require.NoError(t, common.Unmarshal(adapter.CompletedResponse(), &completed))
+	require.Equal(t, 64, completed.Usage.InputTokensDetails.CachedTokens)
+	require.Equal(t, 30, completed.Usage.OutputTokensDetails.ReasoningTokens)
+}
@@ -1,22 +1,41 @@
 package openaicompat
 
 import (
+	"encoding/json"
 	"fmt"
+	"sort"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/QuantumNous/new-api/common"
 	"github.com/QuantumNous/new-api/dto"
+	"github.com/samber/lo"
+)
+
+// Content part types used for reasoning in converted Responses output.
+// Older clients expect "reasoning", newer API versions use "reasoning_text".
+// Both are superseded by separate reasoning output items (see UseReasoningItems).
+const (
+	ReasoningPartType     = "reasoning"
+	ReasoningTextPartType = "reasoning_text"
 )
 
 // ChatCompletionsResponseToResponsesResponse converts a Chat Completions response
 // to an OpenAI Responses API response format.
 //
 // Conversion rules:
-// - choices[0].message.content → output[{type:"message", content:[{type:"output_text", text:...}]}]
-// - choices[0].message.tool_calls → output[{type:"function_call", call_id:..., name:..., arguments:...}]
+// - choices[i].message.content → output[{type:"message", content:[{type:"output_text", text:...}]}]
+// - choices[i].message.tool_calls → output[{type:"function_call", call_id:..., name:..., arguments:...}]
+// - "computer" tool calls (when the computer use tool was requested) → output[{type:"computer_call", call_id:..., action:...}]
+// - choices[i].logprobs.content → output_text logprobs (when include lists message.output_text.logprobs)
+// - choices[i].message.annotations, or citation markers resolved against citations → output_text annotations
 // - usage.prompt_tokens → usage.input_tokens
 // - usage.completion_tokens → usage.output_tokens
+//
+// With multiple choices (n > 1) every choice contributes its own message item followed by
+// its call items, in choice index order. The response status follows the lowest choice index.
+// Tool calls beyond max_tool_calls are removed from chatResp itself (see LimitChatToolCalls).
 func ChatCompletionsResponseToResponsesResponse(
 	chatResp *dto.OpenAITextResponse,
 	originalReq *dto.OpenAIResponsesRequest,
@@ -24,6 +43,7 @@ func ChatCompletionsResponseToResponsesResponse(
 	if chatResp == nil {
 		return nil
 	}
+	LimitChatToolCalls(chatResp, originalReq)
 
 	// Generate response ID
 	responseID := chatResp.Id
@@ -44,68 +64,29 @@ func ChatCompletionsResponseToResponsesResponse(
 		createdAt = int(time.Now().Unix())
 	}
 
+	choices := make([]dto.OpenAITextResponseChoice, len(chatResp.Choices))
+	copy(choices, chatResp.Choices)
+	sort.SliceStable(choices, func(i, j int) bool {
+		return choices[i].Index < choices[j].Index
+	})
+
 	// Build output array
 	output := make([]dto.ResponsesOutput, 0)
-
-	if len(chatResp.Choices) > 0 {
-		choice := chatResp.Choices[0]
-		msg := choice.Message
-
-		// Check for tool calls first
-		toolCalls := msg.ParseToolCalls()
-		if len(toolCalls) > 0 {
-			// Add function_call outputs for each tool call
-			for _, tc := range toolCalls {
-				output = append(output, dto.ResponsesOutput{
-					Type:      "function_call",
-					ID:        fmt.Sprintf("fc_%s", common.GetUUID()),
-					Status:    "completed",
-					CallId:    tc.ID,
-					Name:      tc.Function.Name,
-					Arguments: tc.Function.Arguments,
-				})
-			}
-		}
-
-		// Check for text content
-		textContent := msg.StringContent()
-		if textContent != "" || len(toolCalls) == 0 {
-			// Build content array
-			contentItems := make([]dto.ResponsesOutputContent, 0)
-
-			// Add reasoning content if present
-			if msg.ReasoningContent != "" {
-				contentItems = append(contentItems, dto.ResponsesOutputContent{
-					Type: "reasoning",
-					Text: msg.ReasoningContent,
-				})
-			}
-
-			// Add text content
-			if textContent != "" {
-				contentItems = append(contentItems, dto.ResponsesOutputContent{
-					Type:        "output_text",
-					Text:        textContent,
-					Annotations: []interface{}{},
-				})
-			}
-
-			if len(contentItems) > 0 || len(toolCalls) == 0 {
-				output = append([]dto.ResponsesOutput{{
-					Type:    "message",
-					ID:      fmt.Sprintf("msg_%s", common.GetUUID()),
-					Status:  "completed",
-					Role:    "assistant",
-					Content: contentItems,
-				}}, output...)
-			}
+	computerUse := HasComputerUseTool(originalReq)
+	includeLogprobs := ResponsesIncludes(originalReq, IncludeOutputTextLogprobs)
+	for _, choice := range choices {
+		var logprobs []any
+		if includeLogprobs {
+			logprobs = chatLogprobsContent(choice.Logprobs)
 		}
+		annotations := responsesAnnotations(choice.Message.Annotations, choice.Message.StringContent(), chatResp.Citations, chatResp.SearchResults)
+		output = append(output, chatChoiceToResponsesOutput(choice.Message, computerUse, logprobs, annotations)...)
 	}
 
 	// Determine status
 	status := "completed"
-	if len(chatResp.Choices) > 0 {
-		switch chatResp.Choices[0].FinishReason {
+	if len(choices) > 0 {
+		switch choices[0].FinishReason {
 		case "length":
 			status = "incomplete"
 		case "content_filter":
@@ -125,7 +106,7 @@ func ChatCompletionsResponseToResponsesResponse(
 	// Get max_output_tokens from original request
 	maxOutputTokens := 0
 	if originalReq != nil {
-		maxOutputTokens = int(originalReq.MaxOutputTokens)
+		maxOutputTokens = int(lo.FromPtrOr(originalReq.MaxOutputTokens, uint(0)))
 	}
 
 	// Get temperature
@@ -152,29 +133,117 @@ func ChatCompletionsResponseToResponsesResponse(
 		metadata = originalReq.Metadata
 	}
 
+	// Get text config (output format), echoed like the other request settings
+	var text json.RawMessage
+	if originalReq != nil && len(originalReq.Text) > 0 {
+		text = originalReq.Text
+	}
+
 	return &dto.OpenAIResponsesResponse{
 		ID:              responseID,
 		Object:          "response",
 		CreatedAt:       createdAt,
-		Status:          status,
+		Status:          json.RawMessage(strconv.Quote(status)),
 		Model:           chatResp.Model,
 		Output:          output,
+		ServiceTier:     chatResp.ServiceTier,
 		Usage:           usage,
 		Instructions:    instructions,
 		MaxOutputTokens: maxOutputTokens,
 		Temperature:     temperature,
 		TopP:            topP,
 		Reasoning:       reasoning,
+		Text:            text,
 		Metadata:        metadata,
 	}
 }
 
+// ChatResponseAssistantMessage returns the message of the lowest choice without reasoning,
+// the form in which the conversation is stored for previous_response_id.
+func ChatResponseAssistantMessage(chatResp *dto.OpenAITextResponse) *dto.Message {
+	if chatResp == nil || len(chatResp.Choices) == 0 {
+		return nil
+	}
+	primary := chatResp.Choices[0]
+	for _, choice := range chatResp.Choices[1:] {
+		if choice.Index < primary.Index {
+			primary = choice
+		}
+	}
+	msg := primary.Message
+	msg.Role = "assistant"
+	msg.ReasoningContent = ""
+	msg.Annotations = nil
+	msg.Reasoning = ""
+	return &msg
+}
+
+// chatChoiceToResponsesOutput converts the message of one choice to a message item
+// followed by its function_call / computer_call items; logprobs and annotations are set on the output_text part
+func chatChoiceToResponsesOutput(msg dto.Message, computerUse bool, logprobs []any, annotations []any) []dto.ResponsesOutput {
+	output := make([]dto.ResponsesOutput, 0)
+
+	// Check for tool calls first
+	toolCalls := msg.ParseToolCalls()
+	for _, tc := range toolCalls {
+		if computerUse && tc.Function.Name == ComputerToolCallName {
+			output = append(output, buildComputerCallOutput(fmt.Sprintf("cu_%s", common.GetUUID()), tc.ID, tc.Function.Arguments, "completed"))
+			continue
+		}
+		output = append(output, dto.ResponsesOutput{
+			Type:      "function_call",
+			ID:        fmt.Sprintf("fc_%s", common.GetUUID()),
+			Status:    "completed",
+			CallId:    tc.ID,
+			Name:      tc.Function.Name,
+			Arguments: tc.Function.Arguments,
+		})
+	}
+
+	// Markdown data URL images (generated images) become image_generation_call items
+	textContent, images := extractMarkdownImages(msg.StringContent())
+	output = append(output, images...)
+	if textContent == "" && (len(toolCalls) > 0 || len(images) > 0) {
+		return output
+	}
+
+	// Build content array
+	contentItems := make([]dto.ResponsesOutputContent, 0)
+
+	// Add reasoning content if present
+	if msg.ReasoningContent != "" {
+		contentItems = append(contentItems, dto.ResponsesOutputContent{
+			Type: ReasoningPartType,
+			Text: msg.ReasoningContent,
+		})
+	}
+
+	// Add text content
+	if textContent != "" {
+		contentItems = append(contentItems, dto.ResponsesOutputContent{
+			Type:        "output_text",
+			Text:        textContent,
+			Annotations: annotations,
+			Logprobs:    logprobs,
+		})
+	}
+
+	return append([]dto.ResponsesOutput{{
+		Type:    "message",
+		ID:      fmt.Sprintf("msg_%s", common.GetUUID()),
+		Status:  "completed",
+		Role:    "assistant",
+		Content: contentItems,
+	}}, output...)
+}
+
 // convertChatUsageToResponsesUsage converts Chat Completions usage to Responses API usage format
 func convertChatUsageToResponsesUsage(chatUsage *dto.Usage) *dto.Usage {
 	if chatUsage == nil {
 		return nil
 	}
 
+	inputDetails, outputDetails := responsesUsageDetails(chatUsage)
 	usage := &dto.Usage{
 		PromptTokens:           chatUsage.PromptTokens,
 		CompletionTokens:       chatUsage.CompletionTokens,
@@ -183,6 +252,8 @@ func convertChatUsageToResponsesUsage(chatUsage *dto.Usage) *dto.Usage {
 		OutputTokens:           chatUsage.CompletionTokens,
 		PromptTokensDetails:    chatUsage.PromptTokensDetails,
 		CompletionTokenDetails: chatUsage.CompletionTokenDetails,
+		InputTokensDetails:     inputDetails,
+		OutputTokensDetails:    outputDetails,
 	}
 
 	// Use InputTokens if already set
@@ -196,6 +267,23 @@ func convertChatUsageToResponsesUsage(chatUsage *dto.Usage) *dto.Usage {
 	return usage
 }
 
+// responsesUsageDetails builds the input_tokens_details (cached tokens) and
+// output_tokens_details (reasoning tokens) of a Responses usage from the chat usage details.
+func responsesUsageDetails(chatUsage *dto.Usage) (*dto.InputTokenDetails, *dto.OutputTokenDetails) {
+	input := chatUsage.PromptTokensDetails
+	if input.CachedTokens == 0 {
+		input.CachedTokens = chatUsage.PromptCacheHitTokens
+	}
+	if chatUsage.InputTokensDetails != nil && input.CachedTokens == 0 {
+		input.CachedTokens = chatUsage.InputTokensDetails.CachedTokens
+	}
+	output := chatUsage.CompletionTokenDetails
+	if chatUsage.OutputTokensDetails != nil && output.ReasoningTokens == 0 {
+		output.ReasoningTokens = chatUsage.OutputTokensDetails.ReasoningTokens
+	}
+	return &input, &output
+}
+
 // ResponsesOutputTypeMessage is the type for message outputs
 const ResponsesOutputTypeMessage = "message"
 
@@ -203,3 +291,59 @@ const ResponsesOutputTypeMessage = "message"
 const ResponsesOutputTypeFunctionCall = "function_call"
 
 const ResponsesOutputTypeImageGenerationCall = "image_generation_call"
+
+// UseReasoningTextParts renames the reasoning content parts of a converted response to reasoning_text.
+func UseReasoningTextParts(resp *dto.OpenAIResponsesResponse) {
+	if resp == nil {
+		return
+	}
+	for i := range resp.Output {
+		for j := range resp.Output[i].Content {
+			if resp.Output[i].Content[j].Type == ReasoningPartType {
+				resp.Output[i].Content[j].Type = ReasoningTextPartType
+			}
+		}
+	}
+}
+
+// ResponsesOutputTypeReasoning is the type for reasoning outputs
+const ResponsesOutputTypeReasoning = "reasoning"
+
+// ReasoningSummaryTextType is the type of the summary parts of a reasoning output item
+const ReasoningSummaryTextType = "summary_text"
+
+// UseReasoningItems moves the reasoning content parts of a converted response into separate
+// reasoning output items with a summary_text part, each placed before the message it came from.
+// A message left without content is replaced by its reasoning item.
+func UseReasoningItems(resp *dto.OpenAIResponsesResponse) {
+	if resp == nil {
+		return
+	}
+	output := make([]dto.ResponsesOutput, 0, len(resp.Output))
+	for _, item := range resp.Output {
+		content := make([]dto.ResponsesOutputContent, 0, len(item.Content))
+		var summary []dto.ResponsesReasoningSummaryPart
+		for _, part := range item.Content {
+			if part.Type == ReasoningPartType {
+				summary = append(summary, dto.ResponsesReasoningSummaryPart{Type: ReasoningSummaryTextType, Text: part.Text})
+				continue
+			}
+			content = append(content, part)
+		}
+		if summary == nil {
+			output = append(output, item)
+			continue
+		}
+		output = append(output, dto.ResponsesOutput{
+			Type:    ResponsesOutputTypeReasoning,
+			ID:      fmt.Sprintf("rs_%s", common.GetUUID()),
+			Status:  "completed",
+			Summary: summary,
+		})
+		if len(content) > 0 {
+			item.Content = content
+			output = append(output, item)
+		}
+	}
+	resp.Output = output
+}
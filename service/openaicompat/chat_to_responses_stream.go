@@ -1,7 +1,9 @@
 package openaicompat
 
 import (
+	"encoding/json"
 	"fmt"
+	"strings"
 
 	"github.com/QuantumNous/new-api/common"
 	"github.com/QuantumNous/new-api/dto"
@@ -9,39 +11,146 @@ import (
 
 // ChatToResponsesStreamAdapter handles the conversion of Chat Completions stream chunks
 // to OpenAI Responses API stream events.
+//
+// Every choice (n > 1) is streamed as its own group of output items: a message item
+// followed by its function/computer call items. Output indexes are assigned by an
+// outputItemRegistry in the order items start, so interleaved choices (and interleaved
+// reasoning, text and tool calls of one choice) get interleaved indexes. A choice's items
+// are completed in output index order when its finish_reason arrives, and response.completed is sent once every
+// choice that has been seen is finished; its status follows the lowest choice index.
+// Like the OpenAI stream, every event carries a sequence_number increasing from 0.
+//
+// Annotations of the text (delta.annotations, or citation markers resolved against the
+// citations list of the chunks) are sent as response.output_text.annotation.added events;
+// annotations arriving before the text are held back until the text part is added.
 type ChatToResponsesStreamAdapter struct {
 	ResponseID      string
 	CreatedAt       int
 	Model           string
+	ServiceTier     string // Service tier reported by the upstream chunks
 	OriginalRequest *dto.OpenAIResponsesRequest
 
 	// State tracking
-	initialized       bool
-	messageItemID     string
-	contentPartIndex  int
-	toolCallItemIDs   map[int]string // Index -> Item ID
-	toolCallArguments map[int]string // Index -> Accumulated arguments
-	outputIndex       int
-	hasTextContent    bool
-	textContentIndex  int
-
-	// Reasoning content tracking
-	hasReasoningContent   bool
+	initialized bool
+	completed   bool
+	items       outputItemRegistry        // Output items in output index order
+	choices     map[int]*chatChoiceStream // Choice index -> stream state
+
+	// ReasoningText emits reasoning as response.reasoning_text.* events with reasoning_text parts
+	ReasoningText bool
+
+	// ReasoningItems emits reasoning as a separate reasoning output item with a summary_text part
+	// (response.reasoning_summary_*.* events) preceding the message; reasoning resuming after the message
+	// or a tool call started gets a new reasoning item. It takes precedence over ReasoningText
+	ReasoningItems bool
+
+	// RetainToolArguments keeps the arguments of every tool call for the done events,
+	// regardless of the global tool arguments retention setting
+	RetainToolArguments bool
+
+	// Computer use tool calls are reported as computer_call items
+	computerUse bool
+
+	// Tool calls of a choice beyond max_tool_calls are dropped; -1 means no limit
+	maxToolCalls int
+
+	// Chat logprobs are reported on the output_text parts (include message.output_text.logprobs)
+	includeLogprobs bool
+
+	// Top-level citations of the chunks (Perplexity), resolved against the markers of the text when a choice finishes
+	citations     []string
+	searchResults []dto.ChatSearchResult
+
+	// Response object of the response.completed event
+	completedResponse []byte
+
+	// OnCompleted is called once the response completed, before the response.completed event
+	// is returned, so the response can be stored before the client sees it
+	OnCompleted func()
+
+	// sequence_number of the next event; every event carries one, increasing from 0
+	sequenceNumber int
+}
+
+// chatChoiceStream tracks the output items of one choice.
+type chatChoiceStream struct {
+	index        int
+	finishReason string
+	finished     bool
+
+	messageItemID      string
+	messageAdded       bool
+	messageOutputIndex int
+	contentPartIndex   int
+
+	hasTextContent   bool
+	textContentIndex int
+	text             strings.Builder // Accumulated output text, reported in the done events
+	logprobs         []any           // Accumulated output text logprobs, nil unless requested
+	annotations      []any           // Output text annotations in Responses form
+	annotationsSent  int             // Number of annotations sent as annotation.added events
+
+	hasReasoningContent   bool // Reasoning content part of the message, unless reasoning is emitted as items
 	reasoningContentIndex int
+	reasoning             strings.Builder // Accumulated reasoning text, reported in the done events
+
+	// Reasoning output items, used when reasoning is emitted as separate items
+	reasoningItems []*reasoningItem
+
+	toolCallOrder         []int                        // Tool call indexes in arrival order
+	toolCallItemIDs       map[int]string               // Index -> Item ID
+	toolCallArguments     map[int]*toolArgumentsBuffer // Index -> Accumulated arguments
+	toolCallOutputIndexes map[int]int                  // Index -> Output index
+	toolCallIDs           map[int]string               // Index -> Call ID
+	toolCallNames         map[int]string               // Index -> Function name
+	computerCallIDs       map[int]string               // Index -> Call ID of computer_call items
+	droppedToolCalls      map[int]bool                 // Indexes of tool calls beyond max_tool_calls
+}
+
+func newChatChoiceStream(index int) *chatChoiceStream {
+	return &chatChoiceStream{
+		index:                 index,
+		messageItemID:         fmt.Sprintf("msg_%s", common.GetUUID()),
+		toolCallItemIDs:       make(map[int]string),
+		toolCallArguments:     make(map[int]*toolArgumentsBuffer),
+		toolCallOutputIndexes: make(map[int]int),
+		toolCallIDs:           make(map[int]string),
+		toolCallNames:         make(map[int]string),
+		computerCallIDs:       make(map[int]string),
+		droppedToolCalls:      make(map[int]bool),
+	}
+}
+
+// openReasoningItem returns the reasoning item of the choice that is still streaming, if any
+func (s *chatChoiceStream) openReasoningItem() *reasoningItem {
+	if len(s.reasoningItems) == 0 {
+		return nil
+	}
+	if r := s.reasoningItems[len(s.reasoningItems)-1]; !r.done {
+		return r
+	}
+	return nil
 }
 
 // NewChatToResponsesStreamAdapter creates a new stream adapter
 func NewChatToResponsesStreamAdapter(originalReq *dto.OpenAIResponsesRequest) *ChatToResponsesStreamAdapter {
 	return &ChatToResponsesStreamAdapter{
-		ResponseID:        fmt.Sprintf("resp_%s", common.GetUUID()),
-		CreatedAt:         int(common.GetTimestamp()),
-		OriginalRequest:   originalReq,
-		messageItemID:     fmt.Sprintf("msg_%s", common.GetUUID()),
-		toolCallItemIDs:   make(map[int]string),
-		toolCallArguments: make(map[int]string),
+		ResponseID:      fmt.Sprintf("resp_%s", common.GetUUID()),
+		CreatedAt:       int(common.GetTimestamp()),
+		OriginalRequest: originalReq,
+		choices:         make(map[int]*chatChoiceStream),
+		computerUse:     HasComputerUseTool(originalReq),
+		maxToolCalls:    responsesMaxToolCalls(originalReq),
+		includeLogprobs: ResponsesIncludes(originalReq, IncludeOutputTextLogprobs),
 	}
 }
 
+// PrependOutputItem adds a completed output item that precedes the upstream output.
+// It must be called before the first chunk is converted.
+func (a *ChatToResponsesStreamAdapter) PrependOutputItem(item dto.ResponsesOutput) {
+	a.items.add(&outputItem{kind: outputItemPrefix, prefix: item, done: true})
+}
+
 // ConvertChunk converts a Chat Completions stream chunk to Responses stream events.
 // Returns a slice of JSON-encoded event strings (without "data: " prefix).
 func (a *ChatToResponsesStreamAdapter) ConvertChunk(chunk *dto.ChatCompletionsStreamResponse) [][]byte {
@@ -55,107 +164,355 @@ func (a *ChatToResponsesStreamAdapter) ConvertChunk(chunk *dto.ChatCompletionsSt
 	if chunk.Model != "" {
 		a.Model = chunk.Model
 	}
+	if chunk.ServiceTier != "" {
+		a.ServiceTier = chunk.ServiceTier
+	}
+	if len(chunk.Citations) > 0 {
+		a.citations = chunk.Citations
+		a.searchResults = chunk.SearchResults
+	}
 
 	// Handle initial response.created event
 	if !a.initialized {
 		a.initialized = true
 		events = append(events, a.createResponseCreatedEvent())
 		events = append(events, a.createResponseInProgressEvent())
+		for _, item := range a.items.items {
+			if item.kind == outputItemPrefix {
+				events = append(events, a.createPrefixItemEvents(item.index, item.prefix)...)
+			}
+		}
 	}
 
 	// Process choices
-	if len(chunk.Choices) > 0 {
-		choice := chunk.Choices[0]
-		delta := choice.Delta
+	finishedAny := false
+	for i := range chunk.Choices {
+		choice := &chunk.Choices[i]
+		s := a.choiceStream(choice.Index)
+		if s.finished {
+			continue
+		}
+		var logprobs []any
+		if a.includeLogprobs {
+			logprobs = chatLogprobsContent(choice.Logprobs)
+		}
+		events = append(events, a.convertChoiceDelta(s, &choice.Delta, logprobs)...)
 
-		// Handle role (indicates start of new message)
-		if delta.Role == "assistant" && !a.hasTextContent && !a.hasReasoningContent {
-			events = append(events, a.createOutputItemAddedEvent())
+		// Handle finish reason
+		if choice.FinishReason != nil && *choice.FinishReason != "" {
+			s.finished = true
+			s.finishReason = *choice.FinishReason
+			finishedAny = true
+			events = append(events, a.finishChoice(s)...)
 		}
+	}
 
-		// Handle reasoning content first (reasoning comes before text in output)
-		if reasoning := delta.GetReasoningContent(); reasoning != "" {
-			if !a.hasReasoningContent {
-				a.hasReasoningContent = true
-				a.reasoningContentIndex = a.contentPartIndex
-				a.contentPartIndex++
-				events = append(events, a.createReasoningContentPartAddedEvent())
-			}
-			events = append(events, a.createReasoningDeltaEvent(reasoning))
+	// Create completed response once every choice is finished
+	if finishedAny && !a.completed && a.allChoicesFinished() {
+		a.completed = true
+		events = append(events, a.createResponseCompletedEvent(chunk.Usage, a.primaryFinishReason()))
+		if a.OnCompleted != nil {
+			a.OnCompleted()
 		}
+	}
 
-		// Handle text content delta
-		if delta.Content != nil && *delta.Content != "" {
-			if !a.hasTextContent {
-				a.hasTextContent = true
-				a.textContentIndex = a.contentPartIndex
-				a.contentPartIndex++
-				events = append(events, a.createContentPartAddedEvent())
-			}
-			events = append(events, a.createTextDeltaEvent(*delta.Content))
+	return events
+}
+
+// choiceStream returns the state of a choice, creating it on first use
+func (a *ChatToResponsesStreamAdapter) choiceStream(index int) *chatChoiceStream {
+	s, ok := a.choices[index]
+	if !ok {
+		s = newChatChoiceStream(index)
+		if a.includeLogprobs {
+			s.logprobs = make([]any, 0)
 		}
+		a.choices[index] = s
+	}
+	return s
+}
 
-		// Handle tool calls
-		if len(delta.ToolCalls) > 0 {
-			for _, tc := range delta.ToolCalls {
-				idx := 0
-				if tc.Index != nil {
-					idx = *tc.Index
-				}
-
-				// Check if this is a new tool call
-				if _, exists := a.toolCallItemIDs[idx]; !exists {
-					// New tool call
-					itemID := fmt.Sprintf("fc_%s", common.GetUUID())
-					a.toolCallItemIDs[idx] = itemID
-					a.toolCallArguments[idx] = ""
-					a.outputIndex++
-
-					// Emit output_item.added for function call
-					events = append(events, a.createFunctionCallAddedEvent(idx, tc.ID, tc.Function.Name))
-				}
-
-				// Handle arguments delta
-				if tc.Function.Arguments != "" {
-					a.toolCallArguments[idx] += tc.Function.Arguments
-					events = append(events, a.createFunctionCallArgumentsDeltaEvent(idx, tc.Function.Arguments))
-				}
-			}
+// convertChoiceDelta converts the delta of one choice; logprobs belong to its text content
+func (a *ChatToResponsesStreamAdapter) convertChoiceDelta(s *chatChoiceStream, delta *dto.ChatCompletionsStreamResponseChoiceDelta, logprobs []any) [][]byte {
+	events := make([][]byte, 0)
+
+	// Handle reasoning content first (reasoning comes before text in output)
+	if reasoning := delta.GetReasoningContent(); reasoning != "" && a.ReasoningItems {
+		events = append(events, a.convertReasoningItemDelta(s, reasoning)...)
+	} else if reasoning != "" {
+		events = append(events, a.ensureMessageAdded(s)...)
+		if !s.hasReasoningContent {
+			s.hasReasoningContent = true
+			s.reasoningContentIndex = s.contentPartIndex
+			s.contentPartIndex++
+			events = append(events, a.createReasoningContentPartAddedEvent(s))
 		}
+		s.reasoning.WriteString(reasoning)
+		events = append(events, a.createReasoningDeltaEvent(s, reasoning))
+	}
 
-		// Handle finish reason
-		if choice.FinishReason != nil && *choice.FinishReason != "" {
-			// Complete reasoning content first (reasoning comes before text in output)
-			if a.hasReasoningContent {
-				events = append(events, a.createReasoningDoneEvent())
-				events = append(events, a.createReasoningContentPartDoneEvent())
-			}
+	// Handle text content delta
+	if delta.Content != nil && *delta.Content != "" {
+		events = append(events, a.finishReasoningItem(s)...)
+		events = append(events, a.ensureMessageAdded(s)...)
+		if !s.hasTextContent {
+			s.hasTextContent = true
+			s.textContentIndex = s.contentPartIndex
+			s.contentPartIndex++
+			events = append(events, a.createContentPartAddedEvent(s))
+		}
+		s.text.WriteString(*delta.Content)
+		if s.logprobs != nil {
+			s.logprobs = append(s.logprobs, logprobs...)
+		}
+		events = append(events, a.createTextDeltaEvent(s, *delta.Content, logprobs))
+	}
 
-			// Complete any pending text content
-			if a.hasTextContent {
-				events = append(events, a.createTextDoneEvent())
-				events = append(events, a.createContentPartDoneEvent())
-			}
+	// Handle annotations of the text
+	if len(delta.Annotations) > 0 {
+		s.annotations = append(s.annotations, chatAnnotationsToResponses(delta.Annotations)...)
+	}
+	events = append(events, a.flushAnnotations(s)...)
 
-			// Complete message output item if we have any content
-			if a.hasTextContent || a.hasReasoningContent {
-				events = append(events, a.createOutputItemDoneEvent())
-			}
+	// Handle tool calls
+	if len(delta.ToolCalls) > 0 {
+		events = append(events, a.finishReasoningItem(s)...)
+	}
+	for _, tc := range delta.ToolCalls {
+		idx := 0
+		if tc.Index != nil {
+			idx = *tc.Index
+		}
+
+		// Tool calls beyond max_tool_calls are dropped with all their deltas
+		if _, exists := s.toolCallItemIDs[idx]; !exists && a.maxToolCalls >= 0 && len(s.toolCallOrder) >= a.maxToolCalls {
+			s.droppedToolCalls[idx] = true
+		}
+		if s.droppedToolCalls[idx] {
+			continue
+		}
 
-			// Complete tool calls
-			for idx := range a.toolCallItemIDs {
-				events = append(events, a.createFunctionCallArgumentsDoneEvent(idx))
-				events = append(events, a.createFunctionCallDoneEvent(idx))
+		// Computer actions are only complete once all arguments arrived, so no deltas are sent
+		if _, exists := s.toolCallItemIDs[idx]; !exists && a.computerUse && tc.Function.Name == ComputerToolCallName {
+			// the action is parsed from the complete arguments, so they are always retained within the size cap
+			args := newToolArgumentsBuffer()
+			args.retain = true
+			a.addToolCall(s, idx, fmt.Sprintf("cu_%s", common.GetUUID()), tc.ID, tc.Function.Name, args)
+			s.computerCallIDs[idx] = tc.ID
+			events = append(events, a.createComputerCallAddedEvent(s, idx))
+		}
+		if _, isComputerCall := s.computerCallIDs[idx]; isComputerCall {
+			s.toolCallArguments[idx].Append(tc.Function.Arguments)
+			continue
+		}
+
+		// Check if this is a new tool call. A call ID is generated when the upstream sends none,
+		// so that clients can always correlate their function_call_output with the call.
+		if _, exists := s.toolCallItemIDs[idx]; !exists {
+			callID := tc.ID
+			if callID == "" {
+				callID = fmt.Sprintf("call_%s", common.GetUUID())
+			}
+			args := newToolArgumentsBuffer()
+			if a.RetainToolArguments {
+				args.retain = true
 			}
+			a.addToolCall(s, idx, fmt.Sprintf("fc_%s", common.GetUUID()), callID, tc.Function.Name, args)
+			// Emit output_item.added for function call
+			events = append(events, a.createFunctionCallAddedEvent(s, idx))
+		} else if s.toolCallNames[idx] == "" && tc.Function.Name != "" {
+			s.toolCallNames[idx] = tc.Function.Name
+		}
 
-			// Create completed response
-			events = append(events, a.createResponseCompletedEvent(chunk.Usage, *choice.FinishReason))
+		// Handle arguments delta
+		if tc.Function.Arguments != "" {
+			s.toolCallArguments[idx].Append(tc.Function.Arguments)
+			events = append(events, a.createFunctionCallArgumentsDeltaEvent(s, idx, tc.Function.Arguments))
 		}
 	}
 
 	return events
 }
 
+// convertReasoningItemDelta streams reasoning as the summary text of a separate reasoning item.
+// The item is completed once the message or a tool call starts; reasoning arriving after that
+// opens a new reasoning item at the next output index.
+func (a *ChatToResponsesStreamAdapter) convertReasoningItemDelta(s *chatChoiceStream, reasoning string) [][]byte {
+	events := make([][]byte, 0, 3)
+	r := s.openReasoningItem()
+	if r == nil {
+		r = &reasoningItem{id: fmt.Sprintf("rs_%s", common.GetUUID())}
+		r.outputItem = a.items.add(&outputItem{kind: outputItemReasoning, choice: s, reasoning: r})
+		s.reasoningItems = append(s.reasoningItems, r)
+		events = append(events, a.createReasoningItemAddedEvent(r))
+		events = append(events, a.createReasoningSummaryPartEvent(r, "added"))
+	}
+	r.summary.WriteString(reasoning)
+	events = append(events, a.createReasoningSummaryTextEvent(r, "delta", reasoning))
+	return events
+}
+
+// finishReasoningItem completes the reasoning item of a choice, if one is still open
+func (a *ChatToResponsesStreamAdapter) finishReasoningItem(s *chatChoiceStream) [][]byte {
+	r := s.openReasoningItem()
+	if r == nil {
+		return nil
+	}
+	r.done = true
+	return [][]byte{
+		a.createReasoningSummaryTextEvent(r, "done", r.summary.String()),
+		a.createReasoningSummaryPartEvent(r, "done"),
+		a.marshalEvent(map[string]any{
+			"type":         "response.output_item.done",
+			"output_index": r.index,
+			"item":         r.build("completed"),
+		}),
+	}
+}
+
+// flushAnnotations emits annotation.added events for the annotations not sent yet,
+// once the text part they belong to has been added
+func (a *ChatToResponsesStreamAdapter) flushAnnotations(s *chatChoiceStream) [][]byte {
+	if !s.hasTextContent || s.annotationsSent == len(s.annotations) {
+		return nil
+	}
+	events := make([][]byte, 0, len(s.annotations)-s.annotationsSent)
+	for ; s.annotationsSent < len(s.annotations); s.annotationsSent++ {
+		events = append(events, a.marshalEvent(map[string]any{
+			"type":             "response.output_text.annotation.added",
+			"item_id":          s.messageItemID,
+			"output_index":     s.messageOutputIndex,
+			"content_index":    s.textContentIndex,
+			"annotation_index": s.annotationsSent,
+			"annotation":       s.annotations[s.annotationsSent],
+		}))
+	}
+	return events
+}
+
+// ensureMessageAdded emits output_item.added for the message of a choice when its first content arrives
+func (a *ChatToResponsesStreamAdapter) ensureMessageAdded(s *chatChoiceStream) [][]byte {
+	if s.messageAdded {
+		return nil
+	}
+	s.messageAdded = true
+	s.messageOutputIndex = a.items.add(&outputItem{kind: outputItemMessage, choice: s}).index
+	return [][]byte{a.createOutputItemAddedEvent(s)}
+}
+
+// addToolCall registers a new tool call item of a choice
+func (a *ChatToResponsesStreamAdapter) addToolCall(s *chatChoiceStream, idx int, itemID string, callID string, name string, args *toolArgumentsBuffer) {
+	s.toolCallItemIDs[idx] = itemID
+	s.toolCallIDs[idx] = callID
+	s.toolCallNames[idx] = name
+	s.toolCallArguments[idx] = args
+	s.toolCallOutputIndexes[idx] = a.items.add(&outputItem{kind: outputItemToolCall, choice: s, toolCall: idx}).index
+	s.toolCallOrder = append(s.toolCallOrder, idx)
+}
+
+// finishChoice completes the content parts and then the output items of a choice,
+// the latter in output index order
+func (a *ChatToResponsesStreamAdapter) finishChoice(s *chatChoiceStream) [][]byte {
+	events := make([][]byte, 0)
+
+	// Complete reasoning content first (reasoning comes before text in output)
+	events = append(events, a.finishReasoningItem(s)...)
+	if s.hasReasoningContent {
+		events = append(events, a.createReasoningDoneEvent(s))
+		events = append(events, a.createReasoningContentPartDoneEvent(s))
+	}
+
+	// Complete any pending text content
+	if s.hasTextContent {
+		if len(s.annotations) == 0 {
+			s.annotations = citationAnnotations(s.text.String(), a.citations, a.searchResults)
+		}
+		events = append(events, a.flushAnnotations(s)...)
+		events = append(events, a.createTextDoneEvent(s))
+		events = append(events, a.createContentPartDoneEvent(s))
+	}
+
+	// Complete the message and tool call items
+	for _, item := range a.items.pending(s) {
+		item.done = true
+		switch item.kind {
+		case outputItemMessage:
+			events = append(events, a.createOutputItemDoneEvent(s))
+		case outputItemToolCall:
+			if _, isComputerCall := s.computerCallIDs[item.toolCall]; isComputerCall {
+				events = append(events, a.createComputerCallDoneEvent(s, item.toolCall))
+				continue
+			}
+			events = append(events, a.createFunctionCallArgumentsDoneEvent(s, item.toolCall))
+			events = append(events, a.createFunctionCallDoneEvent(s, item.toolCall))
+		}
+	}
+	return events
+}
+
+func (a *ChatToResponsesStreamAdapter) allChoicesFinished() bool {
+	for _, s := range a.choices {
+		if !s.finished {
+			return false
+		}
+	}
+	return true
+}
+
+// primaryFinishReason returns the finish reason of the lowest choice index
+func (a *ChatToResponsesStreamAdapter) primaryFinishReason() string {
+	if s := a.primaryChoice(); s != nil {
+		return s.finishReason
+	}
+	return ""
+}
+
+// primaryChoice returns the choice with the lowest index, nil before any choice arrived
+func (a *ChatToResponsesStreamAdapter) primaryChoice() *chatChoiceStream {
+	var primary *chatChoiceStream
+	for index, s := range a.choices {
+		if primary == nil || index < primary.index {
+			primary = s
+		}
+	}
+	return primary
+}
+
+// AssistantMessage returns the output of the lowest choice as a Chat Completions assistant
+// message, the form in which the conversation is stored for previous_response_id.
+// Reasoning is not part of the conversation. It returns nil until the response completed.
+func (a *ChatToResponsesStreamAdapter) AssistantMessage() *dto.Message {
+	s := a.primaryChoice()
+	if s == nil || !a.completed {
+		return nil
+	}
+	msg := &dto.Message{Role: "assistant", Content: s.text.String()}
+	toolCalls := make([]dto.ToolCallRequest, 0, len(s.toolCallOrder))
+	for _, idx := range s.toolCallOrder {
+		toolCalls = append(toolCalls, dto.ToolCallRequest{
+			ID:   s.toolCallIDs[idx],
+			Type: "function",
+			Function: dto.FunctionRequest{
+				Name:      s.toolCallNames[idx],
+				Arguments: s.toolCallArguments[idx].String(),
+			},
+		})
+	}
+	if len(toolCalls) > 0 {
+		msg.SetToolCalls(toolCalls)
+	}
+	return msg
+}
+
+// marshalEvent assigns the next sequence_number to an event and encodes it.
+// Events must be marshaled in the order they are sent.
+func (a *ChatToResponsesStreamAdapter) marshalEvent(event map[string]any) []byte {
+	event["sequence_number"] = a.sequenceNumber
+	a.sequenceNumber++
+	data, _ := common.Marshal(event)
+	return data
+}
+
 // createResponseCreatedEvent creates the response.created event
 func (a *ChatToResponsesStreamAdapter) createResponseCreatedEvent() []byte {
 	event := map[string]any{
@@ -169,8 +526,19 @@ func (a *ChatToResponsesStreamAdapter) createResponseCreatedEvent() []byte {
 			"output":     []any{},
 		},
 	}
-	data, _ := common.Marshal(event)
-	return data
+	a.echoRequestText(event)
+	return a.marshalEvent(event)
+}
+
+// echoRequestText adds the text config (output format) of the original request to a
+// response event, as the Responses API echoes request settings in the response object
+func (a *ChatToResponsesStreamAdapter) echoRequestText(event map[string]any) {
+	if a.OriginalRequest == nil || len(a.OriginalRequest.Text) == 0 {
+		return
+	}
+	if resp, ok := event["response"].(map[string]any); ok {
+		resp["text"] = a.OriginalRequest.Text
+	}
 }
 
 // createResponseInProgressEvent creates the response.in_progress event
@@ -185,235 +553,329 @@ func (a *ChatToResponsesStreamAdapter) createResponseInProgressEvent() []byte {
 			"model":      a.Model,
 		},
 	}
-	data, _ := common.Marshal(event)
-	return data
+	return a.marshalEvent(event)
 }
 
 // createOutputItemAddedEvent creates the response.output_item.added event for message
-func (a *ChatToResponsesStreamAdapter) createOutputItemAddedEvent() []byte {
+func (a *ChatToResponsesStreamAdapter) createOutputItemAddedEvent(s *chatChoiceStream) []byte {
 	event := map[string]any{
 		"type":         "response.output_item.added",
-		"output_index": a.outputIndex,
+		"output_index": s.messageOutputIndex,
 		"item": map[string]any{
 			"type":    "message",
-			"id":      a.messageItemID,
+			"id":      s.messageItemID,
 			"status":  "in_progress",
 			"role":    "assistant",
 			"content": []any{},
 		},
 	}
-	data, _ := common.Marshal(event)
-	return data
+	return a.marshalEvent(event)
+}
+
+// createPrefixItemEvents creates the events of a gateway-produced output item
+func (a *ChatToResponsesStreamAdapter) createPrefixItemEvents(outputIdx int, item dto.ResponsesOutput) [][]byte {
+	events := make([][]byte, 0, 5)
+	addedItem := item
+	addedItem.Status = "in_progress"
+	addedItem.Results = nil
+	added := a.marshalEvent(map[string]any{
+		"type":         "response.output_item.added",
+		"output_index": outputIdx,
+		"item":         addedItem,
+	})
+	events = append(events, added)
+	if item.Type == "file_search_call" {
+		for _, eventType := range []string{"in_progress", "searching", "completed"} {
+			data := a.marshalEvent(map[string]any{
+				"type":         "response.file_search_call." + eventType,
+				"output_index": outputIdx,
+				"item_id":      item.ID,
+			})
+			events = append(events, data)
+		}
+	}
+	done := a.marshalEvent(map[string]any{
+		"type":         "response.output_item.done",
+		"output_index": outputIdx,
+		"item":         item,
+	})
+	return append(events, done)
+}
+
+// createReasoningItemAddedEvent creates the response.output_item.added event for reasoning
+func (a *ChatToResponsesStreamAdapter) createReasoningItemAddedEvent(r *reasoningItem) []byte {
+	item := r.build("in_progress")
+	item["summary"] = []any{}
+	event := map[string]any{
+		"type":         "response.output_item.added",
+		"output_index": r.index,
+		"item":         item,
+	}
+	return a.marshalEvent(event)
+}
+
+// createReasoningSummaryPartEvent creates the response.reasoning_summary_part.added/done event
+func (a *ChatToResponsesStreamAdapter) createReasoningSummaryPartEvent(r *reasoningItem, stage string) []byte {
+	text := ""
+	if stage == "done" {
+		text = r.summary.String()
+	}
+	event := map[string]any{
+		"type":          "response.reasoning_summary_part." + stage,
+		"item_id":       r.id,
+		"output_index":  r.index,
+		"summary_index": 0,
+		"part": map[string]any{
+			"type": ReasoningSummaryTextType,
+			"text": text,
+		},
+	}
+	return a.marshalEvent(event)
+}
+
+// createReasoningSummaryTextEvent creates the response.reasoning_summary_text.delta/done event
+func (a *ChatToResponsesStreamAdapter) createReasoningSummaryTextEvent(r *reasoningItem, stage string, text string) []byte {
+	event := map[string]any{
+		"type":          "response.reasoning_summary_text." + stage,
+		"item_id":       r.id,
+		"output_index":  r.index,
+		"summary_index": 0,
+	}
+	if stage == "delta" {
+		event["delta"] = text
+	} else {
+		event["text"] = text
+	}
+	return a.marshalEvent(event)
 }
 
 // createContentPartAddedEvent creates the response.content_part.added event
-func (a *ChatToResponsesStreamAdapter) createContentPartAddedEvent() []byte {
+func (a *ChatToResponsesStreamAdapter) createContentPartAddedEvent(s *chatChoiceStream) []byte {
 	event := map[string]any{
 		"type":          "response.content_part.added",
-		"item_id":       a.messageItemID,
-		"output_index":  a.outputIndex,
-		"content_index": a.textContentIndex,
+		"item_id":       s.messageItemID,
+		"output_index":  s.messageOutputIndex,
+		"content_index": s.textContentIndex,
 		"part": map[string]any{
 			"type": "output_text",
 			"text": "",
 		},
 	}
-	data, _ := common.Marshal(event)
-	return data
+	return a.marshalEvent(event)
 }
 
 // createTextDeltaEvent creates the response.output_text.delta event
-func (a *ChatToResponsesStreamAdapter) createTextDeltaEvent(text string) []byte {
+func (a *ChatToResponsesStreamAdapter) createTextDeltaEvent(s *chatChoiceStream, text string, logprobs []any) []byte {
 	event := map[string]any{
 		"type":          "response.output_text.delta",
-		"item_id":       a.messageItemID,
-		"output_index":  a.outputIndex,
-		"content_index": a.textContentIndex,
+		"item_id":       s.messageItemID,
+		"output_index":  s.messageOutputIndex,
+		"content_index": s.textContentIndex,
 		"delta":         text,
 	}
-	data, _ := common.Marshal(event)
-	return data
+	if s.logprobs != nil {
+		event["logprobs"] = append(make([]any, 0, len(logprobs)), logprobs...)
+	}
+	return a.marshalEvent(event)
+}
+
+// reasoningPartType returns the content part type (and event name segment) used for reasoning
+func (a *ChatToResponsesStreamAdapter) reasoningPartType() string {
+	if a.ReasoningText {
+		return ReasoningTextPartType
+	}
+	return ReasoningPartType
 }
 
 // createReasoningContentPartAddedEvent creates the response.content_part.added event for reasoning
-func (a *ChatToResponsesStreamAdapter) createReasoningContentPartAddedEvent() []byte {
+func (a *ChatToResponsesStreamAdapter) createReasoningContentPartAddedEvent(s *chatChoiceStream) []byte {
 	event := map[string]any{
 		"type":          "response.content_part.added",
-		"item_id":       a.messageItemID,
-		"output_index":  a.outputIndex,
-		"content_index": a.reasoningContentIndex,
+		"item_id":       s.messageItemID,
+		"output_index":  s.messageOutputIndex,
+		"content_index": s.reasoningContentIndex,
 		"part": map[string]any{
-			"type": "reasoning",
+			"type": a.reasoningPartType(),
 			"text": "",
 		},
 	}
-	data, _ := common.Marshal(event)
-	return data
+	return a.marshalEvent(event)
 }
 
 // createReasoningDeltaEvent creates the response.reasoning.delta event
-func (a *ChatToResponsesStreamAdapter) createReasoningDeltaEvent(text string) []byte {
+func (a *ChatToResponsesStreamAdapter) createReasoningDeltaEvent(s *chatChoiceStream, text string) []byte {
 	event := map[string]any{
-		"type":          "response.reasoning.delta",
-		"item_id":       a.messageItemID,
-		"output_index":  a.outputIndex,
-		"content_index": a.reasoningContentIndex,
+		"type":          "response." + a.reasoningPartType() + ".delta",
+		"item_id":       s.messageItemID,
+		"output_index":  s.messageOutputIndex,
+		"content_index": s.reasoningContentIndex,
 		"delta":         text,
 	}
-	data, _ := common.Marshal(event)
-	return data
+	return a.marshalEvent(event)
 }
 
 // createReasoningDoneEvent creates the response.reasoning.done event
-func (a *ChatToResponsesStreamAdapter) createReasoningDoneEvent() []byte {
+func (a *ChatToResponsesStreamAdapter) createReasoningDoneEvent(s *chatChoiceStream) []byte {
 	event := map[string]any{
-		"type":          "response.reasoning.done",
-		"item_id":       a.messageItemID,
-		"output_index":  a.outputIndex,
-		"content_index": a.reasoningContentIndex,
-		"text":          "",
+		"type":          "response." + a.reasoningPartType() + ".done",
+		"item_id":       s.messageItemID,
+		"output_index":  s.messageOutputIndex,
+		"content_index": s.reasoningContentIndex,
+		"text":          s.reasoning.String(),
 	}
-	data, _ := common.Marshal(event)
-	return data
+	return a.marshalEvent(event)
 }
 
 // createReasoningContentPartDoneEvent creates the response.content_part.done event for reasoning
-func (a *ChatToResponsesStreamAdapter) createReasoningContentPartDoneEvent() []byte {
+func (a *ChatToResponsesStreamAdapter) createReasoningContentPartDoneEvent(s *chatChoiceStream) []byte {
 	event := map[string]any{
 		"type":          "response.content_part.done",
-		"item_id":       a.messageItemID,
-		"output_index":  a.outputIndex,
-		"content_index": a.reasoningContentIndex,
+		"item_id":       s.messageItemID,
+		"output_index":  s.messageOutputIndex,
+		"content_index": s.reasoningContentIndex,
 		"part": map[string]any{
-			"type": "reasoning",
-			"text": "",
+			"type": a.reasoningPartType(),
+			"text": s.reasoning.String(),
 		},
 	}
-	data, _ := common.Marshal(event)
-	return data
+	return a.marshalEvent(event)
 }
 
 // createTextDoneEvent creates the response.output_text.done event
-func (a *ChatToResponsesStreamAdapter) createTextDoneEvent() []byte {
+func (a *ChatToResponsesStreamAdapter) createTextDoneEvent(s *chatChoiceStream) []byte {
 	event := map[string]any{
 		"type":          "response.output_text.done",
-		"item_id":       a.messageItemID,
-		"output_index":  a.outputIndex,
-		"content_index": a.textContentIndex,
-		"text":          "", // Full text would be accumulated, but we don't track it
+		"item_id":       s.messageItemID,
+		"output_index":  s.messageOutputIndex,
+		"content_index": s.textContentIndex,
+		"text":          s.text.String(),
 	}
-	data, _ := common.Marshal(event)
-	return data
+	if s.logprobs != nil {
+		event["logprobs"] = s.logprobs
+	}
+	return a.marshalEvent(event)
 }
 
 // createContentPartDoneEvent creates the response.content_part.done event
-func (a *ChatToResponsesStreamAdapter) createContentPartDoneEvent() []byte {
+func (a *ChatToResponsesStreamAdapter) createContentPartDoneEvent(s *chatChoiceStream) []byte {
+	part := map[string]any{
+		"type":        "output_text",
+		"text":        s.text.String(),
+		"annotations": s.responseAnnotations(),
+	}
+	if s.logprobs != nil {
+		part["logprobs"] = s.logprobs
+	}
 	event := map[string]any{
 		"type":          "response.content_part.done",
-		"item_id":       a.messageItemID,
-		"output_index":  a.outputIndex,
-		"content_index": a.textContentIndex,
-		"part": map[string]any{
-			"type": "output_text",
-			"text": "",
-		},
+		"item_id":       s.messageItemID,
+		"output_index":  s.messageOutputIndex,
+		"content_index": s.textContentIndex,
+		"part":          part,
 	}
-	data, _ := common.Marshal(event)
-	return data
+	return a.marshalEvent(event)
 }
 
 // createOutputItemDoneEvent creates the response.output_item.done event for message
-func (a *ChatToResponsesStreamAdapter) createOutputItemDoneEvent() []byte {
-	content := a.buildMessageContent(false)
+func (a *ChatToResponsesStreamAdapter) createOutputItemDoneEvent(s *chatChoiceStream) []byte {
+	content := s.buildMessageContent(false, a.reasoningPartType())
 
 	event := map[string]any{
 		"type":         "response.output_item.done",
-		"output_index": a.outputIndex,
+		"output_index": s.messageOutputIndex,
 		"item": map[string]any{
 			"type":    "message",
-			"id":      a.messageItemID,
+			"id":      s.messageItemID,
 			"status":  "completed",
 			"role":    "assistant",
 			"content": content,
 		},
 	}
-	data, _ := common.Marshal(event)
-	return data
+	return a.marshalEvent(event)
 }
 
 // createFunctionCallAddedEvent creates the response.output_item.added event for function call
-func (a *ChatToResponsesStreamAdapter) createFunctionCallAddedEvent(idx int, callID, name string) []byte {
+func (a *ChatToResponsesStreamAdapter) createFunctionCallAddedEvent(s *chatChoiceStream, idx int) []byte {
 	event := map[string]any{
 		"type":         "response.output_item.added",
-		"output_index": a.outputIndex,
+		"output_index": s.toolCallOutputIndexes[idx],
 		"item": map[string]any{
 			"type":      "function_call",
-			"id":        a.toolCallItemIDs[idx],
+			"id":        s.toolCallItemIDs[idx],
 			"status":    "in_progress",
-			"call_id":   callID,
-			"name":      name,
+			"call_id":   s.toolCallIDs[idx],
+			"name":      s.toolCallNames[idx],
 			"arguments": "",
 		},
 	}
-	data, _ := common.Marshal(event)
-	return data
+	return a.marshalEvent(event)
 }
 
 // createFunctionCallArgumentsDeltaEvent creates the response.function_call_arguments.delta event
-func (a *ChatToResponsesStreamAdapter) createFunctionCallArgumentsDeltaEvent(idx int, argsDelta string) []byte {
-	outputIdx := a.outputIndex
-	if a.hasTextContent || a.hasReasoningContent {
-		outputIdx = idx + 1 // Adjust for message output
-	} else {
-		outputIdx = idx
-	}
+func (a *ChatToResponsesStreamAdapter) createFunctionCallArgumentsDeltaEvent(s *chatChoiceStream, idx int, argsDelta string) []byte {
+	outputIdx := s.toolCallOutputIndexes[idx]
 
 	event := map[string]any{
 		"type":         "response.function_call_arguments.delta",
-		"item_id":      a.toolCallItemIDs[idx],
+		"item_id":      s.toolCallItemIDs[idx],
 		"output_index": outputIdx,
 		"delta":        argsDelta,
 	}
-	data, _ := common.Marshal(event)
-	return data
+	return a.marshalEvent(event)
 }
 
 // createFunctionCallArgumentsDoneEvent creates the response.function_call_arguments.done event
-func (a *ChatToResponsesStreamAdapter) createFunctionCallArgumentsDoneEvent(idx int) []byte {
-	outputIdx := idx
-	if a.hasTextContent || a.hasReasoningContent {
-		outputIdx = idx + 1
-	}
+func (a *ChatToResponsesStreamAdapter) createFunctionCallArgumentsDoneEvent(s *chatChoiceStream, idx int) []byte {
+	outputIdx := s.toolCallOutputIndexes[idx]
 
 	event := map[string]any{
 		"type":         "response.function_call_arguments.done",
-		"item_id":      a.toolCallItemIDs[idx],
+		"item_id":      s.toolCallItemIDs[idx],
 		"output_index": outputIdx,
-		"arguments":    a.toolCallArguments[idx],
+		"arguments":    s.toolCallArguments[idx].String(),
 	}
-	data, _ := common.Marshal(event)
-	return data
+	if s.toolCallArguments[idx].Truncated {
+		event["arguments_truncated"] = true
+	}
+	return a.marshalEvent(event)
 }
 
 // createFunctionCallDoneEvent creates the response.output_item.done event for function call
-func (a *ChatToResponsesStreamAdapter) createFunctionCallDoneEvent(idx int) []byte {
-	outputIdx := idx
-	if a.hasTextContent || a.hasReasoningContent {
-		outputIdx = idx + 1
-	}
+func (a *ChatToResponsesStreamAdapter) createFunctionCallDoneEvent(s *chatChoiceStream, idx int) []byte {
+	outputIdx := s.toolCallOutputIndexes[idx]
 
 	event := map[string]any{
 		"type":         "response.output_item.done",
 		"output_index": outputIdx,
+		"item":         s.buildFunctionCallItem(idx, s.toolCallItemIDs[idx]),
+	}
+	return a.marshalEvent(event)
+}
+
+// createComputerCallAddedEvent creates the response.output_item.added event for computer call
+func (a *ChatToResponsesStreamAdapter) createComputerCallAddedEvent(s *chatChoiceStream, idx int) []byte {
+	event := map[string]any{
+		"type":         "response.output_item.added",
+		"output_index": s.toolCallOutputIndexes[idx],
 		"item": map[string]any{
-			"type":      "function_call",
-			"id":        a.toolCallItemIDs[idx],
-			"status":    "completed",
-			"arguments": a.toolCallArguments[idx],
+			"type":                  "computer_call",
+			"id":                    s.toolCallItemIDs[idx],
+			"status":                "in_progress",
+			"call_id":               s.computerCallIDs[idx],
+			"pending_safety_checks": []any{},
 		},
 	}
-	data, _ := common.Marshal(event)
-	return data
+	return a.marshalEvent(event)
+}
+
+// createComputerCallDoneEvent creates the response.output_item.done event for computer call
+func (a *ChatToResponsesStreamAdapter) createComputerCallDoneEvent(s *chatChoiceStream, idx int) []byte {
+	event := map[string]any{
+		"type":         "response.output_item.done",
+		"output_index": s.toolCallOutputIndexes[idx],
+		"item":         s.buildFunctionCallItem(idx, s.toolCallItemIDs[idx]),
+	}
+	return a.marshalEvent(event)
 }
 
 // createResponseCompletedEvent creates the response.completed event
@@ -426,28 +888,10 @@ func (a *ChatToResponsesStreamAdapter) createResponseCompletedEvent(usage *dto.U
 		status = "failed"
 	}
 
-	// Build output array
-	output := make([]map[string]any, 0)
-
-	if a.hasTextContent || a.hasReasoningContent {
-		content := a.buildMessageContent(true)
-
-		output = append(output, map[string]any{
-			"type":    "message",
-			"id":      a.messageItemID,
-			"status":  "completed",
-			"role":    "assistant",
-			"content": content,
-		})
-	}
-
-	for idx, itemID := range a.toolCallItemIDs {
-		output = append(output, map[string]any{
-			"type":      "function_call",
-			"id":        itemID,
-			"status":    "completed",
-			"arguments": a.toolCallArguments[idx],
-		})
+	// Build output array ordered by output index
+	output := make([]any, 0, len(a.items.items))
+	for _, item := range a.items.items {
+		output = append(output, a.buildOutputItem(item))
 	}
 
 	// Convert usage
@@ -464,22 +908,103 @@ func (a *ChatToResponsesStreamAdapter) createResponseCompletedEvent(usage *dto.U
 		if usage.OutputTokens > 0 {
 			usageMap["output_tokens"] = usage.OutputTokens
 		}
+		inputDetails, outputDetails := responsesUsageDetails(usage)
+		usageMap["input_tokens_details"] = map[string]any{"cached_tokens": inputDetails.CachedTokens}
+		usageMap["output_tokens_details"] = map[string]any{"reasoning_tokens": outputDetails.ReasoningTokens}
 	}
 
+	response := map[string]any{
+		"id":         a.ResponseID,
+		"object":     "response",
+		"created_at": a.CreatedAt,
+		"status":     status,
+		"model":      a.Model,
+		"output":     output,
+		"usage":      usageMap,
+	}
+	if a.ServiceTier != "" {
+		response["service_tier"] = a.ServiceTier
+	}
 	event := map[string]any{
-		"type": "response.completed",
-		"response": map[string]any{
-			"id":         a.ResponseID,
-			"object":     "response",
-			"created_at": a.CreatedAt,
-			"status":     status,
-			"model":      a.Model,
-			"output":     output,
-			"usage":      usageMap,
-		},
+		"type":     "response.completed",
+		"response": response,
+	}
+	a.echoRequestText(event)
+	a.completedResponse, _ = common.Marshal(event["response"])
+	return a.marshalEvent(event)
+}
+
+// buildOutputItem builds a completed output item for the response.completed event
+func (a *ChatToResponsesStreamAdapter) buildOutputItem(item *outputItem) any {
+	s := item.choice
+	switch item.kind {
+	case outputItemReasoning:
+		return item.reasoning.build("completed")
+	case outputItemMessage:
+		return map[string]any{
+			"type":    "message",
+			"id":      s.messageItemID,
+			"status":  "completed",
+			"role":    "assistant",
+			"content": s.buildMessageContent(true, a.reasoningPartType()),
+		}
+	case outputItemToolCall:
+		return s.buildFunctionCallItem(item.toolCall, s.toolCallItemIDs[item.toolCall])
+	}
+	return item.prefix
+}
+
+// build builds the reasoning output item with its summary so far
+func (r *reasoningItem) build(status string) map[string]any {
+	return map[string]any{
+		"type":   ResponsesOutputTypeReasoning,
+		"id":     r.id,
+		"status": status,
+		"summary": []map[string]any{{
+			"type": ReasoningSummaryTextType,
+			"text": r.summary.String(),
+		}},
+	}
+}
+
+// buildFunctionCallItem builds a completed function_call (or computer_call) output item,
+// carrying the same call_id and name as the output_item.added event
+func (s *chatChoiceStream) buildFunctionCallItem(idx int, itemID string) any {
+	if callID, isComputerCall := s.computerCallIDs[idx]; isComputerCall {
+		status := "completed"
+		if s.toolCallArguments[idx].Truncated {
+			// the action could not be parsed from truncated arguments
+			status = "incomplete"
+		}
+		return buildComputerCallOutput(itemID, callID, s.toolCallArguments[idx].String(), status)
+	}
+	item := map[string]any{
+		"type":      "function_call",
+		"id":        itemID,
+		"status":    "completed",
+		"call_id":   s.toolCallIDs[idx],
+		"name":      s.toolCallNames[idx],
+		"arguments": s.toolCallArguments[idx].String(),
+	}
+	if s.toolCallArguments[idx].Truncated {
+		item["arguments_truncated"] = true
+	}
+	return item
+}
+
+// Close releases resources held for accumulated tool call arguments
+func (a *ChatToResponsesStreamAdapter) Close() {
+	for _, s := range a.choices {
+		for _, buf := range s.toolCallArguments {
+			buf.Close()
+		}
 	}
-	data, _ := common.Marshal(event)
-	return data
+}
+
+// CompletedResponse returns the response object sent in the response.completed event,
+// nil before the stream completed.
+func (a *ChatToResponsesStreamAdapter) CompletedResponse() json.RawMessage {
+	return a.completedResponse
 }
 
 // GetResponseID returns the response ID
@@ -487,31 +1012,43 @@ func (a *ChatToResponsesStreamAdapter) GetResponseID() string {
 	return a.ResponseID
 }
 
-func (a *ChatToResponsesStreamAdapter) buildMessageContent(withAnnotations bool) []map[string]any {
+// responseAnnotations returns the annotations of the text, an empty list when there are none
+func (s *chatChoiceStream) responseAnnotations() []any {
+	if s.annotations == nil {
+		return []any{}
+	}
+	return s.annotations
+}
+
+func (s *chatChoiceStream) buildMessageContent(withAnnotations bool, reasoningPartType string) []map[string]any {
 	parts := make([]map[string]any, 0, 2)
-	if !a.hasReasoningContent && !a.hasTextContent {
+	hasReasoningPart := s.hasReasoningContent
+	if !hasReasoningPart && !s.hasTextContent {
 		return parts
 	}
 
 	addReasoning := func() {
 		parts = append(parts, map[string]any{
-			"type": "reasoning",
-			"text": "",
+			"type": reasoningPartType,
+			"text": s.reasoning.String(),
 		})
 	}
 	addText := func() {
 		part := map[string]any{
 			"type": "output_text",
-			"text": "",
+			"text": s.text.String(),
+		}
+		if withAnnotations || len(s.annotations) > 0 {
+			part["annotations"] = s.responseAnnotations()
 		}
-		if withAnnotations {
-			part["annotations"] = []any{}
+		if s.logprobs != nil {
+			part["logprobs"] = s.logprobs
 		}
 		parts = append(parts, part)
 	}
 
-	if a.hasReasoningContent && a.hasTextContent {
-		if a.reasoningContentIndex <= a.textContentIndex {
+	if hasReasoningPart && s.hasTextContent {
+		if s.reasoningContentIndex <= s.textContentIndex {
 			addReasoning()
 			addText()
 		} else {
@@ -521,7 +1058,7 @@ func (a *ChatToResponsesStreamAdapter) buildMessageContent(withAnnotations bool)
 		return parts
 	}
 
-	if a.hasReasoningContent {
+	if hasReasoningPart {
 		addReasoning()
 		return parts
 	}
@@ -0,0 +1,338 @@
+package openaicompat
+
+import (
+	"testing"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/constant"
+	"github.com/QuantumNous/new-api/dto"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStreamDoneEventsCarryFullText(t *testing.T) {
+	adapter := NewChatToResponsesStreamAdapter(&dto.OpenAIResponsesRequest{})
+	chunk := func(reasoning string, content string, finish string) *dto.ChatCompletionsStreamResponse {
+		choice := dto.ChatCompletionsStreamResponseChoice{}
+		if reasoning != "" {
+			choice.Delta.SetReasoningContent(reasoning)
+		}
+		if content != "" {
+			choice.Delta.SetContentString(content)
+		}
+		if finish != "" {
+			choice.FinishReason = &finish
+		}
+		return &dto.ChatCompletionsStreamResponse{Choices: []dto.ChatCompletionsStreamResponseChoice{choice}}
+	}
+	adapter.ConvertChunk(chunk("let me ", "", ""))
+	adapter.ConvertChunk(chunk("think", "Hello", ""))
+	adapter.ConvertChunk(chunk("", ", world", ""))
+
+	type part struct {
+		Type string `json:"type"`
+		Text string `json:"text"`
+	}
+	type content struct {
+		Content []part `json:"content"`
+	}
+	type event struct {
+		Type     string   `json:"type"`
+		Text     string   `json:"text"`
+		Part     *part    `json:"part"`
+		Item     *content `json:"item"`
+		Response *struct {
+			Output []content `json:"output"`
+		} `json:"response"`
+	}
+	var events []event
+	for _, data := range adapter.ConvertChunk(chunk("", "", "stop")) {
+		var e event
+		require.NoError(t, common.Unmarshal(data, &e))
+		events = append(events, e)
+	}
+
+	expected := []part{{Type: ReasoningPartType, Text: "let me think"}, {Type: "output_text", Text: "Hello, world"}}
+	require.Len(t, events, 6)
+	require.Equal(t, "let me think", events[0].Text)
+	require.Equal(t, expected[0], *events[1].Part)
+	require.Equal(t, "response.output_text.done", events[2].Type)
+	require.Equal(t, "Hello, world", events[2].Text)
+	require.Equal(t, expected[1], *events[3].Part)
+	require.Equal(t, expected, events[4].Item.Content)
+	require.Equal(t, expected, events[5].Response.Output[0].Content)
+}
+
+func TestStreamAssistantMessage(t *testing.T) {
+	retain := constant.CompatToolArgumentsRetain
+	constant.CompatToolArgumentsRetain = true
+	t.Cleanup(func() { constant.CompatToolArgumentsRetain = retain })
+
+	adapter := NewChatToResponsesStreamAdapter(&dto.OpenAIResponsesRequest{})
+	require.Nil(t, adapter.AssistantMessage())
+
+	tool := dto.ToolCallResponse{ID: "call_1", Type: "function", Function: dto.FunctionResponse{Name: "get_weather", Arguments: `{"city":`}}
+	tool.SetIndex(0)
+	adapter.ConvertChunk(&dto.ChatCompletionsStreamResponse{Choices: []dto.ChatCompletionsStreamResponseChoice{
+		{Delta: dto.ChatCompletionsStreamResponseChoiceDelta{ToolCalls: []dto.ToolCallResponse{tool}}},
+	}})
+	tool = dto.ToolCallResponse{Function: dto.FunctionResponse{Arguments: `"Paris"}`}}
+	tool.SetIndex(0)
+	finish := "tool_calls"
+	adapter.ConvertChunk(&dto.ChatCompletionsStreamResponse{Choices: []dto.ChatCompletionsStreamResponseChoice{
+		{Delta: dto.ChatCompletionsStreamResponseChoiceDelta{ToolCalls: []dto.ToolCallResponse{tool}}, FinishReason: &finish},
+	}})
+
+	msg := adapter.AssistantMessage()
+	require.NotNil(t, msg)
+	require.Equal(t, "assistant", msg.Role)
+	toolCalls := msg.ParseToolCalls()
+	require.Len(t, toolCalls, 1)
+	require.Equal(t, "call_1", toolCalls[0].ID)
+	require.Equal(t, "get_weather", toolCalls[0].Function.Name)
+	require.Equal(t, `{"city":"Paris"}`, toolCalls[0].Function.Arguments)
+
+	var completed struct {
+		ID     string `json:"id"`
+		Status string `json:"status"`
+	}
+	require.NoError(t, common.Unmarshal(adapter.CompletedResponse(), &completed))
+	require.Equal(t, adapter.GetResponseID(), completed.ID)
+	require.Equal(t, "completed", completed.Status)
+}
+
+func TestStreamEventsCarrySequenceNumbers(t *testing.T) {
+	adapter := NewChatToResponsesStreamAdapter(&dto.OpenAIResponsesRequest{})
+	adapter.PrependOutputItem(BuildFileSearchCallOutput("query", nil, false))
+	var events [][]byte
+	for _, content := range []string{"Hello", ", world"} {
+		choice := dto.ChatCompletionsStreamResponseChoice{}
+		choice.Delta.SetContentString(content)
+		events = append(events, adapter.ConvertChunk(&dto.ChatCompletionsStreamResponse{Choices: []dto.ChatCompletionsStreamResponseChoice{choice}})...)
+	}
+	finish := "stop"
+	events = append(events, adapter.ConvertChunk(&dto.ChatCompletionsStreamResponse{
+		Choices: []dto.ChatCompletionsStreamResponseChoice{{FinishReason: &finish}},
+	})...)
+
+	require.Greater(t, len(events), 10)
+	for i, data := range events {
+		var event struct {
+			Type           string `json:"type"`
+			SequenceNumber *int   `json:"sequence_number"`
+		}
+		require.NoError(t, common.Unmarshal(data, &event))
+		require.NotNil(t, event.SequenceNumber, event.Type)
+		require.Equal(t, i, *event.SequenceNumber, event.Type)
+	}
+}
+
+func TestStreamFunctionCallDoneCarriesCallId(t *testing.T) {
+	adapter := NewChatToResponsesStreamAdapter(&dto.OpenAIResponsesRequest{})
+	first := dto.ToolCallResponse{ID: "call_1", Type: "function", Function: dto.FunctionResponse{Name: "get_weather", Arguments: `{}`}}
+	first.SetIndex(0)
+	second := dto.ToolCallResponse{Type: "function", Function: dto.FunctionResponse{Name: "get_time", Arguments: `{}`}}
+	second.SetIndex(1)
+	finish := "tool_calls"
+	events := adapter.ConvertChunk(&dto.ChatCompletionsStreamResponse{Choices: []dto.ChatCompletionsStreamResponseChoice{
+		{Delta: dto.ChatCompletionsStreamResponseChoiceDelta{ToolCalls: []dto.ToolCallResponse{first, second}}, FinishReason: &finish},
+	}})
+
+	type item struct {
+		Type   string `json:"type"`
+		CallId string `json:"call_id"`
+		Name   string `json:"name"`
+	}
+	added := map[string]string{}
+	var done []item
+	var completed []item
+	for _, data := range events {
+		var event struct {
+			Type     string `json:"type"`
+			Item     *item  `json:"item"`
+			Response *struct {
+				Output []item `json:"output"`
+			} `json:"response"`
+		}
+		require.NoError(t, common.Unmarshal(data, &event))
+		switch event.Type {
+		case "response.output_item.added":
+			added[event.Item.Name] = event.Item.CallId
+		case "response.output_item.done":
+			done = append(done, *event.Item)
+		case "response.completed":
+			completed = event.Response.Output
+		}
+	}
+
+	require.Equal(t, "call_1", added["get_weather"])
+	require.NotEmpty(t, added["get_time"])
+	expected := []item{
+		{Type: "function_call", CallId: "call_1", Name: "get_weather"},
+		{Type: "function_call", CallId: added["get_time"], Name: "get_time"},
+	}
+	require.Equal(t, expected, done)
+	require.Equal(t, expected, completed)
+}
+
+func TestServiceTierRoundTrip(t *testing.T) {
+	chatReq, err := ResponsesRequestToChatCompletionsRequest(&dto.OpenAIResponsesRequest{
+		Model:       "gpt-5",
+		Input:       []byte(`"hi"`),
+		ServiceTier: "flex",
+	}, nil)
+	require.NoError(t, err)
+	require.JSONEq(t, `"flex"`, string(chatReq.ServiceTier))
+
+	responsesReq, err := ChatCompletionsRequestToResponsesRequest(chatReq)
+	require.NoError(t, err)
+	require.Equal(t, "flex", responsesReq.ServiceTier)
+
+	resp := ChatCompletionsResponseToResponsesResponse(&dto.OpenAITextResponse{Model: "gpt-5", ServiceTier: "flex"}, responsesReq)
+	require.Equal(t, "flex", resp.ServiceTier)
+
+	finish := "stop"
+	adapter := NewChatToResponsesStreamAdapter(responsesReq)
+	adapter.ConvertChunk(&dto.ChatCompletionsStreamResponse{ServiceTier: "flex", Choices: []dto.ChatCompletionsStreamResponseChoice{{}}})
+	adapter.ConvertChunk(&dto.ChatCompletionsStreamResponse{Choices: []dto.ChatCompletionsStreamResponseChoice{{FinishReason: &finish}}})
+	var completed struct {
+		ServiceTier string `json:"service_tier"`
+	}
+	require.NoError(t, common.Unmarshal(adapter.CompletedResponse(), &completed))
+	require.Equal(t, "flex", adapter.ServiceTier)
+	require.Equal(t, "flex", completed.ServiceTier)
+}
+
+// interleavedStreamChunk builds a chunk of one choice with any of reasoning, text and a tool call delta
+func interleavedStreamChunk(reasoning string, content string, toolIndex int, toolName string, toolArgs string, finish string) *dto.ChatCompletionsStreamResponse {
+	choice := dto.ChatCompletionsStreamResponseChoice{}
+	if reasoning != "" {
+		choice.Delta.SetReasoningContent(reasoning)
+	}
+	if content != "" {
+		choice.Delta.SetContentString(content)
+	}
+	if toolIndex >= 0 {
+		tc := dto.ToolCallResponse{Type: "function", Function: dto.FunctionResponse{Name: toolName, Arguments: toolArgs}}
+		tc.SetIndex(toolIndex)
+		choice.Delta.ToolCalls = []dto.ToolCallResponse{tc}
+	}
+	if finish != "" {
+		choice.FinishReason = &finish
+	}
+	return &dto.ChatCompletionsStreamResponse{Choices: []dto.ChatCompletionsStreamResponseChoice{choice}}
+}
+
+// requireConsistentOutputIndexes checks that every output item is added once and done once at a dense
+// output index, that no event refers to an item outside of its added/done window, and that the
+// completed output lists the items in output index order. It returns the completed item types.
+func requireConsistentOutputIndexes(t *testing.T, events [][]byte) []string {
+	t.Helper()
+	type item struct {
+		Type string `json:"type"`
+		Id   string `json:"id"`
+	}
+	addedIds := map[int]string{}
+	done := map[int]bool{}
+	var completed []item
+	for _, data := range events {
+		var event struct {
+			Type        string `json:"type"`
+			OutputIndex *int   `json:"output_index"`
+			ItemId      string `json:"item_id"`
+			Item        *item  `json:"item"`
+			Response    *struct {
+				Output []item `json:"output"`
+			} `json:"response"`
+		}
+		require.NoError(t, common.Unmarshal(data, &event))
+		if event.Type == "response.completed" {
+			completed = event.Response.Output
+			continue
+		}
+		if event.OutputIndex == nil {
+			continue
+		}
+		index := *event.OutputIndex
+		switch event.Type {
+		case "response.output_item.added":
+			require.NotContains(t, addedIds, index, "output index %d added twice", index)
+			require.Equal(t, len(addedIds), index, "output indexes must be assigned densely")
+			addedIds[index] = event.Item.Id
+			continue
+		case "response.output_item.done":
+			require.Equal(t, addedIds[index], event.Item.Id)
+			require.False(t, done[index], "output index %d done twice", index)
+			done[index] = true
+			continue
+		}
+		require.Contains(t, addedIds, index, "%s before its item was added", event.Type)
+		require.False(t, done[index], "%s after its item was done", event.Type)
+		if event.ItemId != "" {
+			require.Equal(t, addedIds[index], event.ItemId, event.Type)
+		}
+	}
+
+	require.Len(t, done, len(addedIds))
+	require.Len(t, completed, len(addedIds))
+	types := make([]string, 0, len(completed))
+	for index, output := range completed {
+		require.Equal(t, addedIds[index], output.Id)
+		types = append(types, output.Type)
+	}
+	return types
+}
+
+func TestStreamInterleavedReasoningItemsTextAndTools(t *testing.T) {
+	retain := constant.CompatToolArgumentsRetain
+	constant.CompatToolArgumentsRetain = true
+	t.Cleanup(func() { constant.CompatToolArgumentsRetain = retain })
+	adapter := NewChatToResponsesStreamAdapter(&dto.OpenAIResponsesRequest{})
+	adapter.ReasoningItems = true
+	var events [][]byte
+	for _, chunk := range []*dto.ChatCompletionsStreamResponse{
+		interleavedStreamChunk("plan", "", -1, "", "", ""),
+		interleavedStreamChunk("", "", 0, "search", `{"q":`, ""),
+		interleavedStreamChunk("rethink", "", -1, "", "", ""),
+		interleavedStreamChunk("", "Looking it up", -1, "", "", ""),
+		interleavedStreamChunk("", "", 1, "fetch", `{}`, ""),
+		interleavedStreamChunk("", "", 0, "", `"x"}`, ""),
+		interleavedStreamChunk("", " now", -1, "", "", "tool_calls"),
+	} {
+		events = append(events, adapter.ConvertChunk(chunk)...)
+	}
+
+	types := requireConsistentOutputIndexes(t, events)
+	require.Equal(t, []string{
+		ResponsesOutputTypeReasoning, "function_call", ResponsesOutputTypeReasoning, "message", "function_call",
+	}, types)
+
+	var completed struct {
+		Output []struct {
+			Summary   []dto.ResponsesReasoningSummaryPart `json:"summary"`
+			Arguments string                              `json:"arguments"`
+		} `json:"output"`
+	}
+	require.NoError(t, common.Unmarshal(adapter.CompletedResponse(), &completed))
+	require.Equal(t, "plan", completed.Output[0].Summary[0].Text)
+	require.Equal(t, `{"q":"x"}`, completed.Output[1].Arguments)
+	require.Equal(t, "rethink", completed.Output[2].Summary[0].Text)
+	require.Equal(t, `{}`, completed.Output[4].Arguments)
+}
+
+func TestStreamInterleavedTextAndToolsWithPrefixItem(t *testing.T) {
+	adapter := NewChatToResponsesStreamAdapter(&dto.OpenAIResponsesRequest{})
+	adapter.PrependOutputItem(BuildFileSearchCallOutput("query", nil, false))
+	var events [][]byte
+	for _, chunk := range []*dto.ChatCompletionsStreamResponse{
+		interleavedStreamChunk("", "", 0, "first", `{}`, ""),
+		interleavedStreamChunk("thinking", "", -1, "", "", ""),
+		interleavedStreamChunk("", "", 1, "second", `{}`, ""),
+		interleavedStreamChunk("", "done", -1, "", "", ""),
+		interleavedStreamChunk("", "", 2, "third", `{}`, "tool_calls"),
+	} {
+		events = append(events, adapter.ConvertChunk(chunk)...)
+	}
+
+	types := requireConsistentOutputIndexes(t, events)
+	require.Equal(t, []string{"file_search_call", "function_call", "message", "function_call", "function_call"}, types)
+}
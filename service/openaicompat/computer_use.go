@@ -0,0 +1,303 @@
+package openaicompat
+
+import (
+	"encoding/json"
+	"math"
+	"strings"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/dto"
+)
+
+// Computer use is carried through the Chat Completions layer as a function tool call
+// named "computer". Its arguments are either an OpenAI Responses action
+// ({"type":"click","x":1,"y":2}) or an Anthropic computer tool input
+// ({"action":"left_click","coordinate":[1,2]}); each side converts to its own shape.
+const (
+	ComputerToolType     = "computer_use_preview"
+	ComputerToolCallName = "computer"
+
+	// pixels per Claude scroll "click"
+	computerScrollPixelsPerClick = 100
+)
+
+// IsComputerUseToolType reports whether a Responses tool type is the computer use tool.
+func IsComputerUseToolType(toolType string) bool {
+	switch toolType {
+	case ComputerToolType, "computer_use", "computer-use-preview":
+		return true
+	}
+	return false
+}
+
+// HasComputerUseTool reports whether the Responses request declares the computer use tool.
+func HasComputerUseTool(req *dto.OpenAIResponsesRequest) bool {
+	if req == nil {
+		return false
+	}
+	for _, tool := range req.GetToolsMap() {
+		if IsComputerUseToolType(common.Interface2String(tool["type"])) {
+			return true
+		}
+	}
+	return false
+}
+
+// openAI key name -> xdotool key name used by the Anthropic computer tool
+var computerKeyToXdotool = map[string]string{
+	"ENTER":      "Return",
+	"RETURN":     "Return",
+	"ESC":        "Escape",
+	"ESCAPE":     "Escape",
+	"BACKSPACE":  "BackSpace",
+	"TAB":        "Tab",
+	"SPACE":      "space",
+	"DELETE":     "Delete",
+	"DEL":        "Delete",
+	"UP":         "Up",
+	"ARROWUP":    "Up",
+	"DOWN":       "Down",
+	"ARROWDOWN":  "Down",
+	"LEFT":       "Left",
+	"ARROWLEFT":  "Left",
+	"RIGHT":      "Right",
+	"ARROWRIGHT": "Right",
+	"HOME":       "Home",
+	"END":        "End",
+	"PAGEUP":     "Page_Up",
+	"PAGEDOWN":   "Page_Down",
+	"CTRL":       "ctrl",
+	"CONTROL":    "ctrl",
+	"ALT":        "alt",
+	"OPTION":     "alt",
+	"SHIFT":      "shift",
+	"CMD":        "super",
+	"META":       "super",
+	"SUPER":      "super",
+	"WIN":        "super",
+}
+
+var xdotoolKeyToComputer = map[string]string{
+	"Return":    "ENTER",
+	"Escape":    "ESC",
+	"BackSpace": "BACKSPACE",
+	"Tab":       "TAB",
+	"space":     "SPACE",
+	"Delete":    "DELETE",
+	"Up":        "UP",
+	"Down":      "DOWN",
+	"Left":      "LEFT",
+	"Right":     "RIGHT",
+	"Home":      "HOME",
+	"End":       "END",
+	"Page_Up":   "PAGEUP",
+	"Page_Down": "PAGEDOWN",
+	"ctrl":      "CTRL",
+	"alt":       "ALT",
+	"shift":     "SHIFT",
+	"super":     "META",
+}
+
+// ComputerActionToClaude converts computer tool call arguments into the input of the
+// Anthropic computer tool. Arguments already in the Anthropic shape are returned as is.
+func ComputerActionToClaude(arguments string) map[string]any {
+	var action map[string]any
+	if err := common.UnmarshalJsonStr(arguments, &action); err != nil || action == nil {
+		return map[string]any{}
+	}
+	if _, ok := action["action"]; ok {
+		return action
+	}
+
+	actionType := common.Interface2String(action["type"])
+	coordinate := func() []int {
+		return []int{computerInt(action["x"]), computerInt(action["y"])}
+	}
+	switch actionType {
+	case "click":
+		switch common.Interface2String(action["button"]) {
+		case "right":
+			return map[string]any{"action": "right_click", "coordinate": coordinate()}
+		case "wheel", "middle":
+			return map[string]any{"action": "middle_click", "coordinate": coordinate()}
+		case "back":
+			return map[string]any{"action": "key", "text": "alt+Left"}
+		case "forward":
+			return map[string]any{"action": "key", "text": "alt+Right"}
+		default:
+			return map[string]any{"action": "left_click", "coordinate": coordinate()}
+		}
+	case "double_click":
+		return map[string]any{"action": "double_click", "coordinate": coordinate()}
+	case "move":
+		return map[string]any{"action": "mouse_move", "coordinate": coordinate()}
+	case "drag":
+		path, _ := action["path"].([]any)
+		if len(path) == 0 {
+			return map[string]any{"action": "left_click_drag"}
+		}
+		start, _ := path[0].(map[string]any)
+		end, _ := path[len(path)-1].(map[string]any)
+		return map[string]any{
+			"action":           "left_click_drag",
+			"start_coordinate": []int{computerInt(start["x"]), computerInt(start["y"])},
+			"coordinate":       []int{computerInt(end["x"]), computerInt(end["y"])},
+		}
+	case "keypress":
+		keys, _ := action["keys"].([]any)
+		names := make([]string, 0, len(keys))
+		for _, key := range keys {
+			name := common.Interface2String(key)
+			if mapped, ok := computerKeyToXdotool[strings.ToUpper(name)]; ok {
+				name = mapped
+			} else if len(name) == 1 {
+				name = strings.ToLower(name)
+			}
+			names = append(names, name)
+		}
+		return map[string]any{"action": "key", "text": strings.Join(names, "+")}
+	case "type":
+		return map[string]any{"action": "type", "text": common.Interface2String(action["text"])}
+	case "scroll":
+		scrollX, scrollY := computerInt(action["scroll_x"]), computerInt(action["scroll_y"])
+		direction, amount := "down", scrollY
+		switch {
+		case scrollY < 0:
+			direction, amount = "up", -scrollY
+		case scrollY == 0 && scrollX > 0:
+			direction, amount = "right", scrollX
+		case scrollY == 0 && scrollX < 0:
+			direction, amount = "left", -scrollX
+		}
+		return map[string]any{
+			"action":           "scroll",
+			"coordinate":       coordinate(),
+			"scroll_direction": direction,
+			"scroll_amount":    max(1, int(math.Round(float64(amount)/computerScrollPixelsPerClick))),
+		}
+	case "wait":
+		return map[string]any{"action": "wait", "duration": 1}
+	case "screenshot":
+		return map[string]any{"action": "screenshot"}
+	}
+	// actions without an Anthropic counterpart are passed through by name
+	delete(action, "type")
+	action["action"] = actionType
+	return action
+}
+
+// NormalizeComputerAction converts computer tool call arguments into an OpenAI Responses
+// computer action. Arguments already in the OpenAI shape are returned as is.
+func NormalizeComputerAction(arguments string) json.RawMessage {
+	var input map[string]any
+	if err := common.UnmarshalJsonStr(arguments, &input); err != nil || input == nil {
+		return json.RawMessage(`{}`)
+	}
+	if _, ok := input["action"]; !ok {
+		return json.RawMessage(arguments)
+	}
+
+	action := computerActionFromClaude(input)
+	data, err := common.Marshal(action)
+	if err != nil {
+		return json.RawMessage(`{}`)
+	}
+	return data
+}
+
+func computerActionFromClaude(input map[string]any) map[string]any {
+	actionType := common.Interface2String(input["action"])
+	withCoordinate := func(action map[string]any, key string) map[string]any {
+		if point, ok := input[key].([]any); ok && len(point) == 2 {
+			action["x"] = computerInt(point[0])
+			action["y"] = computerInt(point[1])
+		}
+		return action
+	}
+	switch actionType {
+	case "left_click":
+		return withCoordinate(map[string]any{"type": "click", "button": "left"}, "coordinate")
+	case "right_click":
+		return withCoordinate(map[string]any{"type": "click", "button": "right"}, "coordinate")
+	case "middle_click":
+		return withCoordinate(map[string]any{"type": "click", "button": "wheel"}, "coordinate")
+	case "double_click":
+		return withCoordinate(map[string]any{"type": "double_click"}, "coordinate")
+	case "mouse_move":
+		return withCoordinate(map[string]any{"type": "move"}, "coordinate")
+	case "left_click_drag":
+		path := make([]map[string]any, 0, 2)
+		for _, key := range []string{"start_coordinate", "coordinate"} {
+			if point := withCoordinate(map[string]any{}, key); len(point) > 0 {
+				path = append(path, point)
+			}
+		}
+		return map[string]any{"type": "drag", "path": path}
+	case "key":
+		names := strings.Split(common.Interface2String(input["text"]), "+")
+		keys := make([]string, 0, len(names))
+		for _, name := range names {
+			if mapped, ok := xdotoolKeyToComputer[name]; ok {
+				name = mapped
+			} else if len(name) > 1 {
+				name = strings.ToUpper(name)
+			}
+			keys = append(keys, name)
+		}
+		return map[string]any{"type": "keypress", "keys": keys}
+	case "type":
+		return map[string]any{"type": "type", "text": common.Interface2String(input["text"])}
+	case "scroll":
+		amount := computerInt(input["scroll_amount"]) * computerScrollPixelsPerClick
+		action := withCoordinate(map[string]any{"type": "scroll", "scroll_x": 0, "scroll_y": 0}, "coordinate")
+		switch common.Interface2String(input["scroll_direction"]) {
+		case "up":
+			action["scroll_y"] = -amount
+		case "down":
+			action["scroll_y"] = amount
+		case "left":
+			action["scroll_x"] = -amount
+		case "right":
+			action["scroll_x"] = amount
+		}
+		return action
+	case "wait":
+		return map[string]any{"type": "wait"}
+	case "screenshot":
+		return map[string]any{"type": "screenshot"}
+	}
+	// actions without an OpenAI counterpart (triple_click, hold_key, ...) keep their name
+	action := make(map[string]any, len(input))
+	for key, value := range input {
+		if key != "action" {
+			action[key] = value
+		}
+	}
+	action["type"] = actionType
+	return action
+}
+
+func computerInt(value any) int {
+	switch v := value.(type) {
+	case float64:
+		return int(math.Round(v))
+	case int:
+		return v
+	case json.Number:
+		n, _ := v.Float64()
+		return int(math.Round(n))
+	}
+	return 0
+}
+
+// buildComputerCallOutput builds a computer_call output item from a "computer" tool call.
+func buildComputerCallOutput(id string, callID string, arguments string, status string) dto.ResponsesOutput {
+	return dto.ResponsesOutput{
+		Type:                "computer_call",
+		ID:                  id,
+		Status:              status,
+		CallId:              callID,
+		Action:              NormalizeComputerAction(arguments),
+		PendingSafetyChecks: []any{},
+	}
+}
@@ -0,0 +1,138 @@
+package openaicompat
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/constant"
+	"github.com/QuantumNous/new-api/dto"
+	"github.com/stretchr/testify/require"
+)
+
+func TestComputerActionToClaude(t *testing.T) {
+	cases := []struct {
+		arguments string
+		want      map[string]any
+	}{
+		{`{"type":"click","button":"right","x":10,"y":20}`, map[string]any{"action": "right_click", "coordinate": []int{10, 20}}},
+		{`{"type":"keypress","keys":["CTRL","A"]}`, map[string]any{"action": "key", "text": "ctrl+a"}},
+		{`{"type":"scroll","x":5,"y":6,"scroll_x":0,"scroll_y":-300}`, map[string]any{"action": "scroll", "coordinate": []int{5, 6}, "scroll_direction": "up", "scroll_amount": 3}},
+		{`{"type":"drag","path":[{"x":1,"y":2},{"x":3,"y":4}]}`, map[string]any{"action": "left_click_drag", "start_coordinate": []int{1, 2}, "coordinate": []int{3, 4}}},
+		{`{"action":"triple_click","coordinate":[1,2]}`, map[string]any{"action": "triple_click", "coordinate": []any{float64(1), float64(2)}}},
+	}
+	for _, tc := range cases {
+		require.Equal(t, tc.want, ComputerActionToClaude(tc.arguments), tc.arguments)
+	}
+}
+
+func TestNormalizeComputerAction(t *testing.T) {
+	cases := map[string]string{
+		`{"action":"left_click","coordinate":[10,20]}`:                                       `{"button":"left","type":"click","x":10,"y":20}`,
+		`{"action":"key","text":"ctrl+Return"}`:                                              `{"keys":["CTRL","ENTER"],"type":"keypress"}`,
+		`{"action":"scroll","coordinate":[1,2],"scroll_direction":"down","scroll_amount":2}`: `{"scroll_x":0,"scroll_y":200,"type":"scroll","x":1,"y":2}`,
+		`{"action":"triple_click","coordinate":[1,2]}`:                                       `{"coordinate":[1,2],"type":"triple_click"}`,
+		`{"type":"screenshot"}`:                                                              `{"type":"screenshot"}`,
+	}
+	for arguments, want := range cases {
+		require.JSONEq(t, want, string(NormalizeComputerAction(arguments)), arguments)
+	}
+}
+
+func TestResponsesComputerCallToChat(t *testing.T) {
+	req := &dto.OpenAIResponsesRequest{
+		Model: "m",
+		Tools: json.RawMessage(`[{"type":"computer_use_preview","display_width":1024,"display_height":768,"environment":"browser"}]`),
+		Input: json.RawMessage(`[
+			{"role":"user","content":"open settings"},
+			{"type":"computer_call","id":"cu_1","call_id":"call_1","action":{"type":"click","button":"left","x":1,"y":2},"pending_safety_checks":[]},
+			{"type":"computer_call_output","call_id":"call_1","output":{"type":"computer_screenshot","image_url":"data:image/png;base64,AAAA"}}
+		]`),
+	}
+	require.Empty(t, ResponsesToChatUnsupportedFeatures(req, ComputerToolType))
+
+	chatReq, err := ResponsesRequestToChatCompletionsRequest(req, nil)
+	require.NoError(t, err)
+	require.Len(t, chatReq.Tools, 1)
+	require.Equal(t, ComputerToolType, chatReq.Tools[0].Type)
+	require.Equal(t, 1024, chatReq.Tools[0].ComputerUsePreview.DisplayWidth)
+
+	require.Len(t, chatReq.Messages, 3)
+	toolCalls := chatReq.Messages[1].ParseToolCalls()
+	require.Len(t, toolCalls, 1)
+	require.Equal(t, ComputerToolCallName, toolCalls[0].Function.Name)
+	require.JSONEq(t, `{"type":"click","button":"left","x":1,"y":2}`, toolCalls[0].Function.Arguments)
+
+	toolMessage := chatReq.Messages[2]
+	require.Equal(t, "tool", toolMessage.Role)
+	parts := toolMessage.ParseContent()
+	require.Len(t, parts, 1)
+	require.Equal(t, "data:image/png;base64,AAAA", parts[0].GetImageMedia().Url)
+}
+
+func TestChatComputerToolCallToResponses(t *testing.T) {
+	req := &dto.OpenAIResponsesRequest{Tools: json.RawMessage(`[{"type":"computer_use_preview","display_width":1024,"display_height":768}]`)}
+	msg := dto.Message{Role: "assistant"}
+	msg.SetToolCalls([]dto.ToolCallResponse{{
+		ID:       "toolu_1",
+		Type:     "function",
+		Function: dto.FunctionResponse{Name: "computer", Arguments: `{"action":"screenshot"}`},
+	}})
+	resp := ChatCompletionsResponseToResponsesResponse(&dto.OpenAITextResponse{
+		Choices: []dto.OpenAITextResponseChoice{{Message: msg, FinishReason: "tool_calls"}},
+	}, req)
+	require.Len(t, resp.Output, 1)
+	require.Equal(t, "computer_call", resp.Output[0].Type)
+	require.Equal(t, "toolu_1", resp.Output[0].CallId)
+	require.JSONEq(t, `{"type":"screenshot"}`, string(resp.Output[0].Action))
+
+	adapter := NewChatToResponsesStreamAdapter(req)
+	idx := 0
+	finish := "tool_calls"
+	adapter.ConvertChunk(&dto.ChatCompletionsStreamResponse{Choices: []dto.ChatCompletionsStreamResponseChoice{{
+		Delta: dto.ChatCompletionsStreamResponseChoiceDelta{ToolCalls: []dto.ToolCallResponse{{Index: &idx, ID: "toolu_1", Function: dto.FunctionResponse{Name: "computer"}}}},
+	}}})
+	deltaEvents := adapter.ConvertChunk(&dto.ChatCompletionsStreamResponse{Choices: []dto.ChatCompletionsStreamResponseChoice{{
+		Delta: dto.ChatCompletionsStreamResponseChoiceDelta{ToolCalls: []dto.ToolCallResponse{{Index: &idx, Function: dto.FunctionResponse{Arguments: `{"action":"type","text":"hi"}`}}}},
+	}}})
+	require.Empty(t, deltaEvents)
+	events := adapter.ConvertChunk(&dto.ChatCompletionsStreamResponse{Choices: []dto.ChatCompletionsStreamResponseChoice{{FinishReason: &finish}}})
+	require.Len(t, events, 2)
+	var done struct {
+		Type string              `json:"type"`
+		Item dto.ResponsesOutput `json:"item"`
+	}
+	require.NoError(t, common.Unmarshal(events[0], &done))
+	require.Equal(t, "response.output_item.done", done.Type)
+	require.Equal(t, "computer_call", done.Item.Type)
+	require.JSONEq(t, `{"type":"type","text":"hi"}`, string(done.Item.Action))
+}
+
+func TestChatComputerToolCallArgumentsSizeCap(t *testing.T) {
+	originalMaxKB, originalOverflow := constant.CompatToolArgumentsMaxKB, constant.CompatToolArgumentsOverflow
+	constant.CompatToolArgumentsMaxKB, constant.CompatToolArgumentsOverflow = 1, ToolArgumentsOverflowTruncate
+	t.Cleanup(func() {
+		constant.CompatToolArgumentsMaxKB, constant.CompatToolArgumentsOverflow = originalMaxKB, originalOverflow
+	})
+
+	req := &dto.OpenAIResponsesRequest{Tools: json.RawMessage(`[{"type":"computer_use_preview","display_width":1024,"display_height":768}]`)}
+	adapter := NewChatToResponsesStreamAdapter(req)
+	defer adapter.Close()
+	idx := 0
+	finish := "tool_calls"
+	adapter.ConvertChunk(&dto.ChatCompletionsStreamResponse{Choices: []dto.ChatCompletionsStreamResponseChoice{{
+		Delta: dto.ChatCompletionsStreamResponseChoiceDelta{ToolCalls: []dto.ToolCallResponse{{Index: &idx, ID: "toolu_1", Function: dto.FunctionResponse{Name: "computer"}}}},
+	}}})
+	adapter.ConvertChunk(&dto.ChatCompletionsStreamResponse{Choices: []dto.ChatCompletionsStreamResponseChoice{{
+		Delta: dto.ChatCompletionsStreamResponseChoiceDelta{ToolCalls: []dto.ToolCallResponse{{Index: &idx, Function: dto.FunctionResponse{Arguments: `{"action":"type","text":"` + strings.Repeat("x", 2048) + `"}`}}}},
+	}}})
+	events := adapter.ConvertChunk(&dto.ChatCompletionsStreamResponse{Choices: []dto.ChatCompletionsStreamResponseChoice{{FinishReason: &finish}}})
+	require.NotEmpty(t, events)
+	var done struct {
+		Item dto.ResponsesOutput `json:"item"`
+	}
+	require.NoError(t, common.Unmarshal(events[0], &done))
+	require.Equal(t, "computer_call", done.Item.Type)
+	require.Equal(t, "incomplete", done.Item.Status)
+}
@@ -0,0 +1,147 @@
+package openaicompat
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/dto"
+)
+
+// FileSearchTool is the `file_search` tool of a Responses API request.
+type FileSearchTool struct {
+	VectorStoreIds []string                       `json:"vector_store_ids"`
+	MaxNumResults  int                            `json:"max_num_results,omitempty"`
+	RankingOptions *dto.VectorStoreRankingOptions `json:"ranking_options,omitempty"`
+}
+
+// ExtractFileSearchTool returns the first file_search tool of the request, or nil if none is present.
+func ExtractFileSearchTool(req *dto.OpenAIResponsesRequest) *FileSearchTool {
+	if req == nil {
+		return nil
+	}
+	for _, tool := range req.GetToolsMap() {
+		if common.Interface2String(tool["type"]) != dto.BuildInToolFileSearch {
+			continue
+		}
+		toolBytes, err := common.Marshal(tool)
+		if err != nil {
+			return nil
+		}
+		var fileSearch FileSearchTool
+		if err := common.Unmarshal(toolBytes, &fileSearch); err != nil {
+			return nil
+		}
+		return &fileSearch
+	}
+	return nil
+}
+
+// FileSearchIncludeResults reports whether the request asked for the search results
+// to be returned on the file_search_call output item.
+func FileSearchIncludeResults(req *dto.OpenAIResponsesRequest) bool {
+	return ResponsesIncludes(req, IncludeFileSearchCallResults)
+}
+
+// FileSearchQuery uses the text of the last user message as the search query.
+func FileSearchQuery(messages []dto.Message) string {
+	for i := len(messages) - 1; i >= 0; i-- {
+		if messages[i].Role != "user" {
+			continue
+		}
+		if query := strings.TrimSpace(messageText(messages[i].Content)); query != "" {
+			return query
+		}
+	}
+	return ""
+}
+
+func messageText(content any) string {
+	switch c := content.(type) {
+	case string:
+		return c
+	case []map[string]any:
+		texts := make([]string, 0, len(c))
+		for _, part := range c {
+			if part["type"] == dto.ContentTypeText {
+				if text, ok := part["text"].(string); ok {
+					texts = append(texts, text)
+				}
+			}
+		}
+		return strings.Join(texts, "\n")
+	case []any:
+		msg := dto.Message{Content: c}
+		return msg.StringContent()
+	}
+	return ""
+}
+
+// ApplyFileSearchResults removes the file_search tool from a converted Chat Completions
+// request and injects the retrieved chunks into the system prompt, so that upstreams
+// without native file search answer from the same context.
+func ApplyFileSearchResults(chatReq *dto.GeneralOpenAIRequest, results []dto.VectorStoreSearchResult) {
+	if chatReq == nil {
+		return
+	}
+	chatReq.Tools = slices.DeleteFunc(chatReq.Tools, func(tool dto.ToolCallRequest) bool {
+		return tool.Type == dto.BuildInToolFileSearch
+	})
+	if len(chatReq.Tools) == 0 {
+		chatReq.Tools = nil
+		chatReq.ToolChoice = nil
+		chatReq.ParallelTooCalls = nil
+	} else if toolChoice, ok := chatReq.ToolChoice.(map[string]any); ok && toolChoice["type"] == dto.BuildInToolFileSearch {
+		chatReq.ToolChoice = "auto"
+	}
+
+	var context strings.Builder
+	context.WriteString("Use the following excerpts retrieved from the user's files to answer. ")
+	context.WriteString("Cite the file name when you rely on an excerpt. If they are not relevant, ignore them.\n")
+	if len(results) == 0 {
+		context.WriteString("\nNo relevant excerpts were found.")
+	}
+	for i, result := range results {
+		fmt.Fprintf(&context, "\n<file_search_result index=\"%d\" file_id=\"%s\" filename=\"%s\">\n", i+1, result.FileId, result.Filename)
+		for _, content := range result.Content {
+			context.WriteString(content.Text)
+			context.WriteString("\n")
+		}
+		context.WriteString("</file_search_result>\n")
+	}
+
+	if len(chatReq.Messages) > 0 && chatReq.Messages[0].Role == "system" && chatReq.Messages[0].IsStringContent() {
+		chatReq.Messages[0].SetStringContent(chatReq.Messages[0].StringContent() + "\n\n" + context.String())
+		return
+	}
+	chatReq.Messages = append([]dto.Message{{Role: "system", Content: context.String()}}, chatReq.Messages...)
+}
+
+// BuildFileSearchCallOutput builds the file_search_call output item reported to the client.
+func BuildFileSearchCallOutput(query string, results []dto.VectorStoreSearchResult, includeResults bool) dto.ResponsesOutput {
+	output := dto.ResponsesOutput{
+		Type:    "file_search_call",
+		ID:      fmt.Sprintf("fs_%s", common.GetUUID()),
+		Status:  "completed",
+		Queries: []string{query},
+	}
+	if !includeResults {
+		return output
+	}
+	output.Results = make([]dto.ResponsesFileSearchResult, 0, len(results))
+	for _, result := range results {
+		texts := make([]string, 0, len(result.Content))
+		for _, content := range result.Content {
+			texts = append(texts, content.Text)
+		}
+		output.Results = append(output.Results, dto.ResponsesFileSearchResult{
+			FileId:     result.FileId,
+			Filename:   result.Filename,
+			Score:      result.Score,
+			Text:       strings.Join(texts, "\n"),
+			Attributes: result.Attributes,
+		})
+	}
+	return output
+}
@@ -0,0 +1,102 @@
+package openaicompat
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/dto"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExtractFileSearchTool(t *testing.T) {
+	req := &dto.OpenAIResponsesRequest{
+		Tools:   json.RawMessage(`[{"type":"function","name":"f"},{"type":"file_search","vector_store_ids":["vs_1"],"max_num_results":3,"ranking_options":{"score_threshold":0.5}}]`),
+		Include: json.RawMessage(`["file_search_call.results"]`),
+	}
+	tool := ExtractFileSearchTool(req)
+	require.NotNil(t, tool)
+	require.Equal(t, []string{"vs_1"}, tool.VectorStoreIds)
+	require.Equal(t, 3, tool.MaxNumResults)
+	require.Equal(t, 0.5, tool.RankingOptions.ScoreThreshold)
+	require.True(t, FileSearchIncludeResults(req))
+
+	require.Nil(t, ExtractFileSearchTool(&dto.OpenAIResponsesRequest{Tools: json.RawMessage(`[{"type":"function","name":"f"}]`)}))
+}
+
+func TestApplyFileSearchResults(t *testing.T) {
+	req := &dto.OpenAIResponsesRequest{
+		Model:        "m",
+		Instructions: json.RawMessage(`"be brief"`),
+		Input:        json.RawMessage(`[{"role":"user","content":[{"type":"input_text","text":"what is the refund policy?"}]}]`),
+		Tools:        json.RawMessage(`[{"type":"file_search","vector_store_ids":["vs_1"]}]`),
+		ToolChoice:   json.RawMessage(`{"type":"file_search"}`),
+	}
+	chatReq, err := ResponsesRequestToChatCompletionsRequest(req, nil)
+	require.NoError(t, err)
+	require.Equal(t, "what is the refund policy?", FileSearchQuery(chatReq.Messages))
+
+	results := []dto.VectorStoreSearchResult{{
+		FileId:   "file-1",
+		Filename: "policy.md",
+		Score:    0.9,
+		Content:  []dto.VectorStoreSearchContent{{Type: "text", Text: "Refunds within 30 days."}},
+	}}
+	ApplyFileSearchResults(chatReq, results)
+	require.Nil(t, chatReq.Tools)
+	require.Nil(t, chatReq.ToolChoice)
+	require.Len(t, chatReq.Messages, 2)
+	system := chatReq.Messages[0].StringContent()
+	require.True(t, strings.HasPrefix(system, "be brief"))
+	require.Contains(t, system, `filename="policy.md"`)
+	require.Contains(t, system, "Refunds within 30 days.")
+
+	output := BuildFileSearchCallOutput("what is the refund policy?", results, true)
+	require.Equal(t, "file_search_call", output.Type)
+	require.Equal(t, []string{"what is the refund policy?"}, output.Queries)
+	require.Len(t, output.Results, 1)
+	require.Equal(t, "Refunds within 30 days.", output.Results[0].Text)
+	require.Nil(t, BuildFileSearchCallOutput("q", results, false).Results)
+}
+
+func TestStreamAdapterPrependOutputItem(t *testing.T) {
+	adapter := NewChatToResponsesStreamAdapter(nil)
+	adapter.PrependOutputItem(BuildFileSearchCallOutput("q", nil, false))
+
+	content := "hi"
+	finish := "stop"
+	events := adapter.ConvertChunk(&dto.ChatCompletionsStreamResponse{
+		Choices: []dto.ChatCompletionsStreamResponseChoice{{
+			Delta:        dto.ChatCompletionsStreamResponseChoiceDelta{Role: "assistant", Content: &content},
+			FinishReason: &finish,
+		}},
+	})
+
+	types := make([]string, 0, len(events))
+	var completed map[string]any
+	for _, event := range events {
+		var m map[string]any
+		require.NoError(t, common.Unmarshal(event, &m))
+		types = append(types, m["type"].(string))
+		if m["type"] == "response.output_item.added" && m["item"].(map[string]any)["type"] == "message" {
+			require.EqualValues(t, 1, m["output_index"])
+		}
+		if m["type"] == "response.completed" {
+			completed = m
+		}
+	}
+	require.Equal(t, []string{
+		"response.created",
+		"response.in_progress",
+		"response.output_item.added",
+		"response.file_search_call.in_progress",
+		"response.file_search_call.searching",
+		"response.file_search_call.completed",
+		"response.output_item.done",
+	}, types[:7])
+	output := completed["response"].(map[string]any)["output"].([]any)
+	require.Len(t, output, 2)
+	require.Equal(t, "file_search_call", output[0].(map[string]any)["type"])
+	require.Equal(t, "message", output[1].(map[string]any)["type"])
+}
@@ -0,0 +1,76 @@
+package openaicompat
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/dto"
+)
+
+// Generated images travel through chat messages as markdown images with a base64 data URL,
+// the form the Gemini channel already uses for its image output:
+// ![image](data:image/png;base64,...)
+var markdownDataImagePattern = regexp.MustCompile(`!\[[^\]]*\]\(data:(image/[a-zA-Z0-9.+-]+);base64,([A-Za-z0-9+/=]+)\)`)
+
+// imageGenerationMimeType returns the MIME type of an image_generation_call result by its output_format.
+func imageGenerationMimeType(outputFormat string) string {
+	switch strings.ToLower(outputFormat) {
+	case "jpeg", "jpg":
+		return "image/jpeg"
+	case "webp":
+		return "image/webp"
+	default:
+		return "image/png"
+	}
+}
+
+// imageGenerationOutputFormat returns the output_format of an image_generation_call for a MIME type.
+func imageGenerationOutputFormat(mimeType string) string {
+	switch mimeType {
+	case "image/jpeg":
+		return "jpeg"
+	case "image/webp":
+		return "webp"
+	default:
+		return "png"
+	}
+}
+
+// imageGenerationMarkdown returns the markdown image of a base64 image_generation_call result.
+func imageGenerationMarkdown(result string, outputFormat string) string {
+	return fmt.Sprintf("![image](data:%s;base64,%s)", imageGenerationMimeType(outputFormat), result)
+}
+
+// responsesImageGenerationMarkdown returns the markdown images of the image_generation_call
+// items of a response that carry a result, separated by blank lines.
+func responsesImageGenerationMarkdown(resp *dto.OpenAIResponsesResponse) string {
+	images := make([]string, 0)
+	for _, out := range resp.Output {
+		if out.Type == dto.ResponsesOutputTypeImageGenerationCall && out.Result != "" {
+			images = append(images, imageGenerationMarkdown(out.Result, out.OutputFormat))
+		}
+	}
+	return strings.Join(images, "\n\n")
+}
+
+// extractMarkdownImages removes the markdown data URL images from text and returns them as
+// completed image_generation_call items.
+func extractMarkdownImages(text string) (string, []dto.ResponsesOutput) {
+	matches := markdownDataImagePattern.FindAllStringSubmatch(text, -1)
+	if len(matches) == 0 {
+		return text, nil
+	}
+	calls := make([]dto.ResponsesOutput, 0, len(matches))
+	for _, match := range matches {
+		calls = append(calls, dto.ResponsesOutput{
+			Type:         dto.ResponsesOutputTypeImageGenerationCall,
+			ID:           fmt.Sprintf("ig_%s", common.GetUUID()),
+			Status:       "completed",
+			Result:       match[2],
+			OutputFormat: imageGenerationOutputFormat(match[1]),
+		})
+	}
+	return strings.TrimSpace(markdownDataImagePattern.ReplaceAllString(text, "")), calls
+}
@@ -0,0 +1,47 @@
+package openaicompat
+
+import (
+	"testing"
+
+	"github.com/QuantumNous/new-api/dto"
+	"github.com/stretchr/testify/require"
+)
+
+func TestImageGenerationCallToChat(t *testing.T) {
+	resp := &dto.OpenAIResponsesResponse{Output: []dto.ResponsesOutput{
+		{Type: "message", Role: "assistant", Content: []dto.ResponsesOutputContent{{Type: "output_text", Text: "Here it is"}}},
+		{Type: dto.ResponsesOutputTypeImageGenerationCall, ID: "ig_1", Status: "completed", Result: "iVBORw0KGgo=", OutputFormat: "png"},
+	}}
+	chatResp, _, err := ResponsesResponseToChatCompletionsResponse(resp, "chatcmpl-1")
+	require.NoError(t, err)
+	require.Equal(t, "Here it is\n\n![image](data:image/png;base64,iVBORw0KGgo=)", chatResp.Choices[0].Message.StringContent())
+
+	adapter := NewResponsesToChatStreamAdapter("chatcmpl-1", 0, "gpt-image")
+	adapter.ConvertEvent(&dto.ResponsesStreamResponse{Type: "response.output_item.added", Item: &dto.ResponsesOutput{Type: dto.ResponsesOutputTypeImageGenerationCall, ID: "ig_1"}})
+	chunks := adapter.ConvertEvent(&dto.ResponsesStreamResponse{Type: "response.output_item.done", Item: &resp.Output[1]})
+	require.Len(t, chunks, 2)
+	require.Equal(t, "![image](data:image/png;base64,iVBORw0KGgo=)", *chunks[1].Choices[0].Delta.Content)
+	require.Empty(t, adapter.ConvertEvent(&dto.ResponsesStreamResponse{Type: "response.output_item.done", Item: &resp.Output[1]}))
+}
+
+func TestChatImagesToImageGenerationCall(t *testing.T) {
+	chatResp := &dto.OpenAITextResponse{Choices: []dto.OpenAITextResponseChoice{{
+		Message:      dto.Message{Role: "assistant", Content: "A cat:\n\n![image](data:image/jpeg;base64,/9j/4AAQ)"},
+		FinishReason: "stop",
+	}}}
+	resp := ChatCompletionsResponseToResponsesResponse(chatResp, &dto.OpenAIResponsesRequest{})
+	require.Len(t, resp.Output, 2)
+	require.Equal(t, "A cat:", resp.Output[0].Content[0].Text)
+	require.Equal(t, dto.ResponsesOutputTypeImageGenerationCall, resp.Output[1].Type)
+	require.Equal(t, "/9j/4AAQ", resp.Output[1].Result)
+	require.Equal(t, "jpeg", resp.Output[1].OutputFormat)
+
+	messages, err := parseResponsesInput([]byte(`[
+		{"type":"image_generation_call","id":"ig_1","status":"completed","result":"/9j/4AAQ","output_format":"jpeg"},
+		{"role":"user","content":"make it blue"}
+	]`))
+	require.NoError(t, err)
+	require.Len(t, messages, 2)
+	require.Equal(t, "assistant", messages[0].Role)
+	require.Equal(t, "![image](data:image/jpeg;base64,/9j/4AAQ)", messages[0].StringContent())
+}
@@ -0,0 +1,83 @@
+package openaicompat
+
+import (
+	"slices"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/dto"
+)
+
+// Values of the Responses API `include` parameter known to the Chat Completions fallback.
+//
+// Native Responses upstreams receive `include` unchanged. When the request is served
+// through Chat Completions:
+//   - message.output_text.logprobs is emulated with the chat logprobs / top_logprobs parameters
+//     (and is what chat logprobs are requested as when a chat request is served through Responses)
+//   - file_search_call.results is honored by the gateway file_search tool
+//   - reasoning.encrypted_content is dropped: chat upstreams return no encrypted reasoning,
+//     so reasoning items are simply returned without it and nothing is lost for the client
+//   - every other value is reported as an unsupported feature
+const (
+	IncludeOutputTextLogprobs        = "message.output_text.logprobs"
+	IncludeReasoningEncryptedContent = "reasoning.encrypted_content"
+	IncludeFileSearchCallResults     = "file_search_call.results"
+)
+
+// ResponsesInclude returns the values of the `include` parameter of the request.
+func ResponsesInclude(req *dto.OpenAIResponsesRequest) []string {
+	if req == nil || len(req.Include) == 0 {
+		return nil
+	}
+	var include []string
+	if err := common.Unmarshal(req.Include, &include); err != nil {
+		return nil
+	}
+	return include
+}
+
+// ResponsesIncludes reports whether the request lists value in its `include` parameter.
+func ResponsesIncludes(req *dto.OpenAIResponsesRequest, value string) bool {
+	return slices.Contains(ResponsesInclude(req), value)
+}
+
+// chatLogprobsContent returns the per-token entries (logprobs.content) of a chat choice,
+// which have the same shape as the logprobs of a Responses output_text part.
+func chatLogprobsContent(logprobs *any) []any {
+	if logprobs == nil || *logprobs == nil {
+		return nil
+	}
+	data, err := common.Marshal(*logprobs)
+	if err != nil {
+		return nil
+	}
+	var parsed struct {
+		Content []any `json:"content"`
+	}
+	if err := common.Unmarshal(data, &parsed); err != nil {
+		return nil
+	}
+	return parsed.Content
+}
+
+// responsesOutputLogprobs returns the logprobs of the assistant output_text parts of a
+// Responses response, in output order, or nil when the upstream returned none.
+func responsesOutputLogprobs(resp *dto.OpenAIResponsesResponse) []any {
+	var logprobs []any
+	for _, out := range resp.Output {
+		if out.Type != "message" || (out.Role != "" && out.Role != "assistant") {
+			continue
+		}
+		for _, c := range out.Content {
+			if c.Type == "output_text" {
+				logprobs = append(logprobs, c.Logprobs...)
+			}
+		}
+	}
+	return logprobs
+}
+
+// chatLogprobs wraps per-token entries in the logprobs object of a chat choice.
+func chatLogprobs(content []any) *any {
+	var logprobs any = map[string]any{"content": content, "refusal": nil}
+	return &logprobs
+}
@@ -0,0 +1,148 @@
+package openaicompat
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/dto"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResponsesIncludeUnsupportedFeatures(t *testing.T) {
+	topLogprobs := 2
+	req := &dto.OpenAIResponsesRequest{
+		Model:       "gpt-5",
+		Input:       json.RawMessage(`"hi"`),
+		Include:     json.RawMessage(`["reasoning.encrypted_content","message.output_text.logprobs","web_search_call.action.sources"]`),
+		TopLogProbs: &topLogprobs,
+	}
+	// encrypted reasoning is dropped silently, logprobs are emulated when the adaptor supports them
+	require.Equal(t, []string{"include.web_search_call.action.sources"}, ResponsesToChatUnsupportedFeatures(req, IncludeOutputTextLogprobs))
+	require.Equal(t, []string{"top_logprobs", "include.message.output_text.logprobs", "include.web_search_call.action.sources"}, ResponsesToChatUnsupportedFeatures(req))
+
+	chatReq, err := ResponsesRequestToChatCompletionsRequest(req, nil)
+	require.NoError(t, err)
+	require.NotNil(t, chatReq.LogProbs)
+	require.True(t, *chatReq.LogProbs)
+	require.Equal(t, &topLogprobs, chatReq.TopLogProbs)
+
+	req.Include = nil
+	chatReq, err = ResponsesRequestToChatCompletionsRequest(req, nil)
+	require.NoError(t, err)
+	require.Nil(t, chatReq.LogProbs)
+	require.Nil(t, chatReq.TopLogProbs)
+}
+
+func TestChatLogprobsToResponsesOutputText(t *testing.T) {
+	var logprobs any
+	require.NoError(t, common.Unmarshal([]byte(`{"content":[{"token":"Hi","logprob":-0.1,"bytes":[72,105],"top_logprobs":[]}],"refusal":null}`), &logprobs))
+	chatResp := &dto.OpenAITextResponse{
+		Id:    "chatcmpl-1",
+		Model: "gpt-5",
+		Choices: []dto.OpenAITextResponseChoice{{
+			Message:      dto.Message{Role: "assistant", Content: "Hi"},
+			Logprobs:     &logprobs,
+			FinishReason: "stop",
+		}},
+	}
+
+	resp := ChatCompletionsResponseToResponsesResponse(chatResp, &dto.OpenAIResponsesRequest{})
+	require.Nil(t, resp.Output[0].Content[0].Logprobs)
+
+	resp = ChatCompletionsResponseToResponsesResponse(chatResp, &dto.OpenAIResponsesRequest{
+		Include: json.RawMessage(`["message.output_text.logprobs"]`),
+	})
+	data, err := common.Marshal(resp.Output[0].Content[0].Logprobs)
+	require.NoError(t, err)
+	require.JSONEq(t, `[{"token":"Hi","logprob":-0.1,"bytes":[72,105],"top_logprobs":[]}]`, string(data))
+}
+
+func TestStreamOutputTextLogprobs(t *testing.T) {
+	adapter := NewChatToResponsesStreamAdapter(&dto.OpenAIResponsesRequest{
+		Include: json.RawMessage(`["message.output_text.logprobs"]`),
+	})
+	chunk := func(content string, token string, finish string) *dto.ChatCompletionsStreamResponse {
+		choice := dto.ChatCompletionsStreamResponseChoice{}
+		choice.Delta.SetContentString(content)
+		var logprobs any = map[string]any{"content": []any{map[string]any{"token": token, "logprob": -0.5}}}
+		choice.Logprobs = &logprobs
+		if finish != "" {
+			choice.FinishReason = &finish
+		}
+		return &dto.ChatCompletionsStreamResponse{Choices: []dto.ChatCompletionsStreamResponseChoice{choice}}
+	}
+
+	type event struct {
+		Type     string `json:"type"`
+		Logprobs []struct {
+			Token string `json:"token"`
+		} `json:"logprobs"`
+	}
+	var deltas, dones []event
+	for _, c := range []*dto.ChatCompletionsStreamResponse{chunk("Hel", "Hel", ""), chunk("lo", "lo", "stop")} {
+		for _, data := range adapter.ConvertChunk(c) {
+			var e event
+			require.NoError(t, common.Unmarshal(data, &e))
+			switch e.Type {
+			case "response.output_text.delta":
+				deltas = append(deltas, e)
+			case "response.output_text.done":
+				dones = append(dones, e)
+			}
+		}
+	}
+	require.Len(t, deltas, 2)
+	require.Len(t, deltas[1].Logprobs, 1)
+	require.Equal(t, "lo", deltas[1].Logprobs[0].Token)
+	require.Len(t, dones, 1)
+	require.Len(t, dones[0].Logprobs, 2)
+
+	var completed struct {
+		Output []struct {
+			Content []struct {
+				Logprobs []any `json:"logprobs"`
+			} `json:"content"`
+		} `json:"output"`
+	}
+	require.NoError(t, common.Unmarshal(adapter.CompletedResponse(), &completed))
+	require.Len(t, completed.Output[0].Content[0].Logprobs, 2)
+}
+
+func TestChatLogprobsViaResponses(t *testing.T) {
+	logprobs, topLogprobs := true, 3
+	chatReq := &dto.GeneralOpenAIRequest{
+		Model:       "gpt-5",
+		Messages:    []dto.Message{{Role: "user", Content: "hi"}},
+		LogProbs:    &logprobs,
+		TopLogProbs: &topLogprobs,
+	}
+	require.Empty(t, ChatToResponsesUnsupportedFeatures(chatReq))
+	responsesReq, err := ChatCompletionsRequestToResponsesRequest(chatReq)
+	require.NoError(t, err)
+	require.Equal(t, []string{IncludeOutputTextLogprobs}, ResponsesInclude(responsesReq))
+	require.Equal(t, &topLogprobs, responsesReq.TopLogProbs)
+
+	entry := map[string]any{"token": "Hi", "logprob": -0.1, "top_logprobs": []any{}}
+	chatResp, _, err := ResponsesResponseToChatCompletionsResponse(&dto.OpenAIResponsesResponse{
+		Output: []dto.ResponsesOutput{{
+			Type:    "message",
+			Role:    "assistant",
+			Content: []dto.ResponsesOutputContent{{Type: "output_text", Text: "Hi", Logprobs: []any{entry}}},
+		}},
+	}, "chatcmpl-1")
+	require.NoError(t, err)
+	data, err := common.Marshal(chatResp.Choices[0].Logprobs)
+	require.NoError(t, err)
+	require.JSONEq(t, `{"content":[{"token":"Hi","logprob":-0.1,"top_logprobs":[]}],"refusal":null}`, string(data))
+
+	adapter := NewResponsesToChatStreamAdapter("chatcmpl-1", 0, "gpt-5")
+	var event dto.ResponsesStreamResponse
+	require.NoError(t, common.Unmarshal([]byte(`{"type":"response.output_text.delta","delta":"Hi","logprobs":[{"token":"Hi","logprob":-0.1}]}`), &event))
+	chunks := adapter.ConvertEvent(&event)
+	require.Len(t, chunks, 2)
+	require.Nil(t, chunks[0].Choices[0].Logprobs)
+	data, err = common.Marshal(chunks[1].Choices[0].Logprobs)
+	require.NoError(t, err)
+	require.JSONEq(t, `{"content":[{"token":"Hi","logprob":-0.1}],"refusal":null}`, string(data))
+}
@@ -0,0 +1,61 @@
+package openaicompat
+
+import (
+	"strings"
+
+	"github.com/QuantumNous/new-api/dto"
+)
+
+// Kinds of the output items of a streamed response
+const (
+	outputItemPrefix = iota
+	outputItemReasoning
+	outputItemMessage
+	outputItemToolCall
+)
+
+// outputItem is one output item of a streamed response. Its output_index is fixed when
+// the item is registered and never derived from the position of other items.
+type outputItem struct {
+	index     int
+	kind      int
+	choice    *chatChoiceStream   // Owning choice, nil for prefix items
+	prefix    dto.ResponsesOutput // Completed item produced by the gateway, for prefix items
+	reasoning *reasoningItem      // Reasoning state, for reasoning items
+	toolCall  int                 // Tool call index within the choice, for tool call items
+	done      bool                // output_item.done has been sent
+}
+
+// reasoningItem is a reasoning output item of a choice. Reasoning that resumes after the
+// message or a tool call of the choice started opens a new reasoning item.
+type reasoningItem struct {
+	*outputItem
+	id      string
+	summary strings.Builder // Accumulated summary text, reported in the done events
+}
+
+// outputItemRegistry assigns output indexes in the order output_item.added events are sent.
+// An item's output_index is its position in the registry, so indexes are dense whatever way
+// the reasoning, text and tool calls of the choices interleave, and the output of
+// response.completed is the registry in order.
+type outputItemRegistry struct {
+	items []*outputItem
+}
+
+// add registers an item at the next output index
+func (r *outputItemRegistry) add(item *outputItem) *outputItem {
+	item.index = len(r.items)
+	r.items = append(r.items, item)
+	return item
+}
+
+// pending returns the items of a choice whose output_item.done has not been sent, in output index order
+func (r *outputItemRegistry) pending(s *chatChoiceStream) []*outputItem {
+	items := make([]*outputItem, 0)
+	for _, item := range r.items {
+		if item.choice == s && !item.done {
+			items = append(items, item)
+		}
+	}
+	return items
+}
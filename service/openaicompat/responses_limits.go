@@ -0,0 +1,46 @@
+package openaicompat
+
+import (
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/dto"
+)
+
+// ResponsesTruncationAuto reports whether the request asks for truncation "auto": the oldest
+// messages of the conversation are dropped when it does not fit the context window of the model.
+func ResponsesTruncationAuto(req *dto.OpenAIResponsesRequest) bool {
+	if req == nil || len(req.Truncation) == 0 {
+		return false
+	}
+	var truncation string
+	return common.Unmarshal(req.Truncation, &truncation) == nil && truncation == "auto"
+}
+
+// responsesMaxToolCalls returns max_tool_calls of the request, or -1 when there is no limit.
+func responsesMaxToolCalls(req *dto.OpenAIResponsesRequest) int {
+	if req == nil || req.MaxToolCalls == nil {
+		return -1
+	}
+	return int(*req.MaxToolCalls)
+}
+
+// LimitChatToolCalls enforces max_tool_calls on a chat response by dropping the tool calls of
+// every choice beyond the limit, in place, so that the converted output and the stored
+// conversation agree on the calls the client has to answer.
+func LimitChatToolCalls(chatResp *dto.OpenAITextResponse, req *dto.OpenAIResponsesRequest) {
+	limit := responsesMaxToolCalls(req)
+	if chatResp == nil || limit < 0 {
+		return
+	}
+	for i := range chatResp.Choices {
+		msg := &chatResp.Choices[i].Message
+		toolCalls := msg.ParseToolCalls()
+		if len(toolCalls) <= limit {
+			continue
+		}
+		if limit == 0 {
+			msg.ToolCalls = nil
+		} else {
+			msg.SetToolCalls(toolCalls[:limit])
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package openaicompat
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/dto"
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResponsesMaxToolCalls(t *testing.T) {
+	req := &dto.OpenAIResponsesRequest{
+		Model:        "gpt-4o",
+		Input:        json.RawMessage(`"weather?"`),
+		Tools:        json.RawMessage(`[{"type":"function","name":"get_weather","parameters":{}}]`),
+		MaxToolCalls: lo.ToPtr(uint(1)),
+		Truncation:   json.RawMessage(`"auto"`),
+	}
+	require.Empty(t, ResponsesToChatUnsupportedFeatures(req))
+	require.True(t, ResponsesTruncationAuto(req))
+
+	chatReq, err := ResponsesRequestToChatCompletionsRequest(req, nil)
+	require.NoError(t, err)
+	require.NotNil(t, chatReq.ParallelTooCalls)
+	require.False(t, *chatReq.ParallelTooCalls)
+
+	msg := dto.Message{Role: "assistant"}
+	msg.SetToolCalls([]dto.ToolCallRequest{
+		{ID: "call_1", Type: "function", Function: dto.FunctionRequest{Name: "get_weather", Arguments: `{"city":"Paris"}`}},
+		{ID: "call_2", Type: "function", Function: dto.FunctionRequest{Name: "get_weather", Arguments: `{"city":"Rome"}`}},
+	})
+	chatResp := &dto.OpenAITextResponse{Choices: []dto.OpenAITextResponseChoice{{Message: msg, FinishReason: "tool_calls"}}}
+	resp := ChatCompletionsResponseToResponsesResponse(chatResp, req)
+	require.Len(t, resp.Output, 1)
+	require.Equal(t, "call_1", resp.Output[0].CallId)
+	require.Len(t, ChatResponseAssistantMessage(chatResp).ParseToolCalls(), 1)
+
+	adapter := NewChatToResponsesStreamAdapter(req)
+	first := dto.ToolCallResponse{ID: "call_1", Type: "function", Function: dto.FunctionResponse{Name: "get_weather", Arguments: `{}`}}
+	first.SetIndex(0)
+	second := dto.ToolCallResponse{ID: "call_2", Type: "function", Function: dto.FunctionResponse{Name: "get_weather", Arguments: `{}`}}
+	second.SetIndex(1)
+	finish := "tool_calls"
+	adapter.ConvertChunk(&dto.ChatCompletionsStreamResponse{Choices: []dto.ChatCompletionsStreamResponseChoice{
+		{Delta: dto.ChatCompletionsStreamResponseChoiceDelta{ToolCalls: []dto.ToolCallResponse{first, second}}, FinishReason: &finish},
+	}})
+	var completed struct {
+		Output []struct {
+			CallId string `json:"call_id"`
+		} `json:"output"`
+	}
+	require.NoError(t, common.Unmarshal(adapter.CompletedResponse(), &completed))
+	require.Len(t, completed.Output, 1)
+	require.Equal(t, "call_1", completed.Output[0].CallId)
+
+	req.MaxToolCalls = lo.ToPtr(uint(0))
+	chatReq, err = ResponsesRequestToChatCompletionsRequest(req, nil)
+	require.NoError(t, err)
+	require.Equal(t, "none", chatReq.ToolChoice)
+}
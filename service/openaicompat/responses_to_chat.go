@@ -13,37 +13,32 @@ func ResponsesResponseToChatCompletionsResponse(resp *dto.OpenAIResponsesRespons
 	}
 
 	text := ExtractOutputTextFromResponses(resp)
-
-	usage := &dto.Usage{}
-	if resp.Usage != nil {
-		if resp.Usage.InputTokens != 0 {
-			usage.PromptTokens = resp.Usage.InputTokens
-			usage.InputTokens = resp.Usage.InputTokens
-		}
-		if resp.Usage.OutputTokens != 0 {
-			usage.CompletionTokens = resp.Usage.OutputTokens
-			usage.OutputTokens = resp.Usage.OutputTokens
-		}
-		if resp.Usage.TotalTokens != 0 {
-			usage.TotalTokens = resp.Usage.TotalTokens
-		} else {
-			usage.TotalTokens = usage.PromptTokens + usage.CompletionTokens
-		}
-		if resp.Usage.InputTokensDetails != nil {
-			usage.PromptTokensDetails.CachedTokens = resp.Usage.InputTokensDetails.CachedTokens
-			usage.PromptTokensDetails.ImageTokens = resp.Usage.InputTokensDetails.ImageTokens
-			usage.PromptTokensDetails.AudioTokens = resp.Usage.InputTokensDetails.AudioTokens
-		}
-		if resp.Usage.CompletionTokenDetails.ReasoningTokens != 0 {
-			usage.CompletionTokenDetails.ReasoningTokens = resp.Usage.CompletionTokenDetails.ReasoningTokens
+	// Generated images follow the text as markdown images
+	if images := responsesImageGenerationMarkdown(resp); images != "" {
+		if text != "" {
+			text += "\n\n"
 		}
+		text += images
 	}
 
+	usage := responsesUsageToChatUsage(resp.Usage)
+
 	created := resp.CreatedAt
 
 	var toolCalls []dto.ToolCallResponse
 	if text == "" && len(resp.Output) > 0 {
 		for _, out := range resp.Output {
+			if out.Type == "computer_call" {
+				toolCalls = append(toolCalls, dto.ToolCallResponse{
+					ID:   strings.TrimSpace(out.CallId),
+					Type: "function",
+					Function: dto.FunctionResponse{
+						Name:      ComputerToolCallName,
+						Arguments: string(out.Action),
+					},
+				})
+				continue
+			}
 			if out.Type != "function_call" {
 				continue
 			}
@@ -94,6 +89,9 @@ func ResponsesResponseToChatCompletionsResponse(resp *dto.OpenAIResponsesRespons
 		},
 		Usage: *usage,
 	}
+	if logprobs := responsesOutputLogprobs(resp); len(logprobs) > 0 {
+		out.Choices[0].Logprobs = chatLogprobs(logprobs)
+	}
 
 	return out, usage, nil
 }
@@ -131,3 +129,36 @@ func ExtractOutputTextFromResponses(resp *dto.OpenAIResponsesResponse) string {
 	}
 	return sb.String()
 }
+
+// responsesUsageToChatUsage maps the usage of a Responses API response to Chat Completions usage.
+func responsesUsageToChatUsage(u *dto.Usage) *dto.Usage {
+	usage := &dto.Usage{}
+	if u == nil {
+		return usage
+	}
+	if u.InputTokens != 0 {
+		usage.PromptTokens = u.InputTokens
+		usage.InputTokens = u.InputTokens
+	}
+	if u.OutputTokens != 0 {
+		usage.CompletionTokens = u.OutputTokens
+		usage.OutputTokens = u.OutputTokens
+	}
+	if u.TotalTokens != 0 {
+		usage.TotalTokens = u.TotalTokens
+	} else {
+		usage.TotalTokens = usage.PromptTokens + usage.CompletionTokens
+	}
+	if u.InputTokensDetails != nil {
+		usage.PromptTokensDetails.CachedTokens = u.InputTokensDetails.CachedTokens
+		usage.PromptTokensDetails.ImageTokens = u.InputTokensDetails.ImageTokens
+		usage.PromptTokensDetails.AudioTokens = u.InputTokensDetails.AudioTokens
+	}
+	if u.CompletionTokenDetails.ReasoningTokens != 0 {
+		usage.CompletionTokenDetails.ReasoningTokens = u.CompletionTokenDetails.ReasoningTokens
+	}
+	if u.OutputTokensDetails != nil && u.OutputTokensDetails.ReasoningTokens != 0 {
+		usage.CompletionTokenDetails.ReasoningTokens = u.OutputTokensDetails.ReasoningTokens
+	}
+	return usage
+}
@@ -1,11 +1,13 @@
 package openaicompat
 
 import (
+	"encoding/json"
 	"errors"
 	"strings"
 
 	"github.com/QuantumNous/new-api/common"
 	"github.com/QuantumNous/new-api/dto"
+	"github.com/samber/lo"
 )
 
 // ResponsesRequestToChatCompletionsRequest converts an OpenAI Responses API request
@@ -17,9 +19,15 @@ import (
 // - max_output_tokens → max_tokens
 // - tools (function type) → tools
 // - tool_choice → tool_choice
+// - max_tool_calls 0 → tool_choice "none", 1 → parallel_tool_calls false (extra calls are dropped from the output)
 // - reasoning.effort → reasoning_effort
-// - temperature, top_p → direct mapping
-func ResponsesRequestToChatCompletionsRequest(req *dto.OpenAIResponsesRequest) (*dto.GeneralOpenAIRequest, error) {
+// - text.format (json_object / json_schema) → response_format, text.verbosity → verbosity
+// - include message.output_text.logprobs → logprobs, top_logprobs → top_logprobs
+// - temperature, top_p, service_tier → direct mapping
+//
+// history is the stored conversation of previous_response_id; it is placed after the
+// instructions (which are not carried over between responses) and before the input.
+func ResponsesRequestToChatCompletionsRequest(req *dto.OpenAIResponsesRequest, history []dto.Message) (*dto.GeneralOpenAIRequest, error) {
 	if req == nil {
 		return nil, errors.New("request is nil")
 	}
@@ -30,16 +38,16 @@ func ResponsesRequestToChatCompletionsRequest(req *dto.OpenAIResponsesRequest) (
 	messages := make([]dto.Message, 0)
 
 	// Process instructions as system message
-	if len(req.Instructions) > 0 {
-		var instructions string
-		if err := common.Unmarshal(req.Instructions, &instructions); err == nil && strings.TrimSpace(instructions) != "" {
-			messages = append(messages, dto.Message{
-				Role:    "system",
-				Content: instructions,
-			})
-		}
+	if instructions := responsesInstructions(req); instructions != "" {
+		messages = append(messages, dto.Message{
+			Role:    "system",
+			Content: instructions,
+		})
 	}
 
+	// Conversation of previous_response_id
+	messages = append(messages, history...)
+
 	// Process input field
 	if len(req.Input) > 0 {
 		inputMessages, err := parseResponsesInput(req.Input)
@@ -55,30 +63,19 @@ func ResponsesRequestToChatCompletionsRequest(req *dto.OpenAIResponsesRequest) (
 	if len(req.Tools) > 0 {
 		tools = convertResponsesTools(req.Tools)
 
-		// Extract web_search tool from tools and convert to WebSearchOptions
-		// This is needed for Claude channel which expects WebSearchOptions field
+		// web_search tools travel as web_search_options, the channel adaptor maps them to its native search tool
 		var toolsMap []map[string]any
 		if err := common.Unmarshal(req.Tools, &toolsMap); err == nil {
-			for _, tool := range toolsMap {
-				if toolType, _ := tool["type"].(string); toolType == "web_search" {
-					webSearchOptions = &dto.WebSearchOptions{}
-					if searchContextSize, ok := tool["search_context_size"].(string); ok {
-						webSearchOptions.SearchContextSize = searchContextSize
-					}
-					if userLocation, ok := tool["user_location"]; ok {
-						if userLocationBytes, err := common.Marshal(userLocation); err == nil {
-							webSearchOptions.UserLocation = userLocationBytes
-						}
-					}
-					break
-				}
-			}
+			webSearchOptions = responsesWebSearchOptions(toolsMap)
 		}
 	}
 
 	// Convert tool_choice
 	var toolChoice any
-	if len(req.ToolChoice) > 0 {
+	if len(tools) == 0 {
+		// only built-in tools carried outside of tools (web_search), tool_choice would be rejected without tools
+		tools = nil
+	} else if len(req.ToolChoice) > 0 {
 		toolChoice = convertResponsesToolChoice(req.ToolChoice)
 	}
 
@@ -91,6 +88,16 @@ func ResponsesRequestToChatCompletionsRequest(req *dto.OpenAIResponsesRequest) (
 		}
 	}
 
+	// max_tool_calls is enforced on the output; the request only avoids calls that would be dropped
+	if len(tools) > 0 {
+		switch responsesMaxToolCalls(req) {
+		case 0:
+			toolChoice = "none"
+		case 1:
+			parallelToolCalls = lo.ToPtr(false)
+		}
+	}
+
 	// Build the Chat Completions request
 	chatReq := &dto.GeneralOpenAIRequest{
 		Model:            req.Model,
@@ -109,7 +116,7 @@ func ResponsesRequestToChatCompletionsRequest(req *dto.OpenAIResponsesRequest) (
 
 	// Set TopP only if provided
 	if req.TopP != nil {
-		chatReq.TopP = *req.TopP
+		chatReq.TopP = req.TopP
 	}
 
 	// Convert reasoning
@@ -117,9 +124,91 @@ func ResponsesRequestToChatCompletionsRequest(req *dto.OpenAIResponsesRequest) (
 		chatReq.ReasoningEffort = req.Reasoning.Effort
 	}
 
+	if req.ServiceTier != "" {
+		chatReq.ServiceTier, _ = common.Marshal(req.ServiceTier)
+	}
+
+	// Convert text.format / text.verbosity
+	chatReq.ResponseFormat, chatReq.Verbosity = convertResponsesTextToChat(req.Text)
+
+	// Output text logprobs are returned on the output_text parts of the converted response
+	if ResponsesIncludes(req, IncludeOutputTextLogprobs) {
+		logprobs := true
+		chatReq.LogProbs = &logprobs
+		chatReq.TopLogProbs = req.TopLogProbs
+	}
+
 	return chatReq, nil
 }
 
+func responsesInstructions(req *dto.OpenAIResponsesRequest) string {
+	if len(req.Instructions) == 0 {
+		return ""
+	}
+	var instructions string
+	if err := common.Unmarshal(req.Instructions, &instructions); err != nil || strings.TrimSpace(instructions) == "" {
+		return ""
+	}
+	return instructions
+}
+
+// ResponsesConversationMessages returns the messages of a converted request without the
+// instructions, i.e. the conversation a stored response continues from.
+func ResponsesConversationMessages(req *dto.OpenAIResponsesRequest, chatReq *dto.GeneralOpenAIRequest) []dto.Message {
+	messages := chatReq.Messages
+	if responsesInstructions(req) != "" && len(messages) > 0 {
+		messages = messages[1:]
+	}
+	return append(make([]dto.Message, 0, len(messages)+1), messages...)
+}
+
+// ResponsesStoreRequested reports whether the response should be stored; store defaults to true.
+func ResponsesStoreRequested(req *dto.OpenAIResponsesRequest) bool {
+	if req == nil || len(req.Store) == 0 {
+		return true
+	}
+	var store *bool
+	if err := common.Unmarshal(req.Store, &store); err != nil || store == nil {
+		return true
+	}
+	return *store
+}
+
+// convertResponsesTextToChat maps the Responses text config to the Chat Completions
+// response_format and verbosity. The json_schema format is flat in Responses
+// ({type, name, schema, strict, description}) and nested under json_schema in chat.
+func convertResponsesTextToChat(textRaw json.RawMessage) (*dto.ResponseFormat, json.RawMessage) {
+	if len(textRaw) == 0 || common.GetJsonType(textRaw) != "object" {
+		return nil, nil
+	}
+	var text struct {
+		Format    map[string]any  `json:"format"`
+		Verbosity json.RawMessage `json:"verbosity"`
+	}
+	if err := common.Unmarshal(textRaw, &text); err != nil {
+		return nil, nil
+	}
+
+	var responseFormat *dto.ResponseFormat
+	formatType, _ := text.Format["type"].(string)
+	switch formatType {
+	case "json_object":
+		responseFormat = &dto.ResponseFormat{Type: formatType}
+	case "json_schema":
+		schema := make(map[string]any, len(text.Format))
+		for key, value := range text.Format {
+			if key != "type" {
+				schema[key] = value
+			}
+		}
+		schemaRaw, err := common.Marshal(schema)
+		if err == nil {
+			responseFormat = &dto.ResponseFormat{Type: formatType, JsonSchema: schemaRaw}
+		}
+	}
+	return responseFormat, text.Verbosity
+}
+
 // parseResponsesInput parses the Responses API input field into Chat Completions messages
 func parseResponsesInput(inputRaw []byte) ([]dto.Message, error) {
 	if len(inputRaw) == 0 {
@@ -150,6 +239,8 @@ func parseResponsesInput(inputRaw []byte) ([]dto.Message, error) {
 		return nil, err
 	}
 
+	// reasoning items precede the assistant output they belong to
+	pendingReasoning := ""
 	for _, item := range inputItems {
 		itemType, _ := item["type"].(string)
 		role, _ := item["role"].(string)
@@ -164,11 +255,25 @@ func parseResponsesInput(inputRaw []byte) ([]dto.Message, error) {
 
 			// Parse content
 			if content, ok := item["content"]; ok {
+				if role == "assistant" {
+					content, msg.Refusal = splitResponsesRefusal(content)
+				}
 				msg.Content = convertResponsesContent(content)
 			}
+			if role == "assistant" {
+				msg.ReasoningContent, pendingReasoning = pendingReasoning, ""
+			}
 
 			messages = append(messages, msg)
 
+		case ResponsesOutputTypeReasoning:
+			if text := responsesReasoningText(item); text != "" {
+				if pendingReasoning != "" {
+					pendingReasoning += "\n\n"
+				}
+				pendingReasoning += text
+			}
+
 		case "function_call":
 			// Function call from assistant - convert to assistant message with tool_calls
 			callID, _ := item["call_id"].(string)
@@ -176,31 +281,59 @@ func parseResponsesInput(inputRaw []byte) ([]dto.Message, error) {
 			arguments, _ := item["arguments"].(string)
 
 			if callID != "" && name != "" {
-				toolCall := dto.ToolCallResponse{
-					ID:   callID,
-					Type: "function",
-					Function: dto.FunctionResponse{
-						Name:      name,
-						Arguments: arguments,
-					},
+				messages = appendAssistantToolCall(messages, callID, name, arguments)
+				attachReasoning(messages, &pendingReasoning)
+			}
+
+		case "computer_call":
+			// Computer action from assistant - convert to a "computer" tool call
+			callID, _ := item["call_id"].(string)
+			if callID != "" {
+				arguments := "{}"
+				if action, ok := item["action"]; ok {
+					if actionBytes, err := common.Marshal(action); err == nil {
+						arguments = string(actionBytes)
+					}
 				}
+				messages = appendAssistantToolCall(messages, callID, ComputerToolCallName, arguments)
+				attachReasoning(messages, &pendingReasoning)
+			}
+
+		case "computer_call_output":
+			// Screenshot taken after the action - convert to tool message with image content
+			callID, _ := item["call_id"].(string)
+			output, _ := item["output"].(map[string]any)
+			imageURL, _ := output["image_url"].(string)
 
-				// Check if we need to append to existing assistant message or create new one
-				lastIdx := len(messages) - 1
-				if lastIdx >= 0 && messages[lastIdx].Role == "assistant" {
-					// Parse existing tool calls and append new one
-					var existingCalls []dto.ToolCallResponse
-					if messages[lastIdx].ToolCalls != nil {
-						_ = common.Unmarshal(messages[lastIdx].ToolCalls, &existingCalls)
+			if callID != "" {
+				var content any = "screenshot unavailable"
+				if imageURL != "" {
+					content = []any{
+						map[string]any{
+							"type": "image_url",
+							"image_url": map[string]any{
+								"url": imageURL,
+							},
+						},
 					}
-					existingCalls = append(existingCalls, toolCall)
-					messages[lastIdx].SetToolCalls(existingCalls)
-				} else {
-					// Create new assistant message with tool call
-					msg := dto.Message{Role: "assistant"}
-					msg.SetToolCalls([]dto.ToolCallResponse{toolCall})
-					messages = append(messages, msg)
 				}
+				messages = append(messages, dto.Message{
+					Role:       "tool",
+					Content:    content,
+					ToolCallId: callID,
+				})
+			}
+
+		case dto.ResponsesOutputTypeImageGenerationCall:
+			// Image generated in an earlier turn - convert to an assistant message with a markdown image
+			result, _ := item["result"].(string)
+			outputFormat, _ := item["output_format"].(string)
+			if result != "" {
+				messages = append(messages, dto.Message{
+					Role:    "assistant",
+					Content: imageGenerationMarkdown(result, outputFormat),
+				})
+				attachReasoning(messages, &pendingReasoning)
 			}
 
 		case "function_call_output":
@@ -221,6 +354,92 @@ func parseResponsesInput(inputRaw []byte) ([]dto.Message, error) {
 	return messages, nil
 }
 
+// responsesReasoningText returns the text of a reasoning input item: its reasoning content
+// when present, otherwise its summary. Encrypted reasoning cannot be replayed and is ignored.
+func responsesReasoningText(item map[string]any) string {
+	collect := func(parts any) string {
+		list, _ := parts.([]any)
+		texts := make([]string, 0, len(list))
+		for _, p := range list {
+			if part, ok := p.(map[string]any); ok {
+				if text, _ := part["text"].(string); text != "" {
+					texts = append(texts, text)
+				}
+			}
+		}
+		return strings.Join(texts, "\n\n")
+	}
+	if text := collect(item["content"]); text != "" {
+		return text
+	}
+	return collect(item["summary"])
+}
+
+// attachReasoning moves the pending reasoning onto the last message, the assistant message
+// just appended or extended with a tool call
+func attachReasoning(messages []dto.Message, pending *string) {
+	if *pending == "" || len(messages) == 0 {
+		return
+	}
+	last := &messages[len(messages)-1]
+	if last.ReasoningContent != "" {
+		last.ReasoningContent += "\n\n" + *pending
+	} else {
+		last.ReasoningContent = *pending
+	}
+	*pending = ""
+}
+
+// splitResponsesRefusal removes the refusal parts from assistant message content and returns
+// their text, which Chat Completions carries in the refusal field of the message
+func splitResponsesRefusal(content any) (any, string) {
+	parts, ok := content.([]any)
+	if !ok {
+		return content, ""
+	}
+	kept := make([]any, 0, len(parts))
+	refusals := make([]string, 0)
+	for _, p := range parts {
+		if part, ok := p.(map[string]any); ok && part["type"] == "refusal" {
+			if refusal, _ := part["refusal"].(string); refusal != "" {
+				refusals = append(refusals, refusal)
+			}
+			continue
+		}
+		kept = append(kept, p)
+	}
+	return kept, strings.Join(refusals, "\n")
+}
+
+// appendAssistantToolCall appends a tool call to the last assistant message, or starts a new one
+func appendAssistantToolCall(messages []dto.Message, callID string, name string, arguments string) []dto.Message {
+	toolCall := dto.ToolCallResponse{
+		ID:   callID,
+		Type: "function",
+		Function: dto.FunctionResponse{
+			Name:      name,
+			Arguments: arguments,
+		},
+	}
+
+	// Check if we need to append to existing assistant message or create new one
+	lastIdx := len(messages) - 1
+	if lastIdx >= 0 && messages[lastIdx].Role == "assistant" {
+		// Parse existing tool calls and append new one
+		var existingCalls []dto.ToolCallResponse
+		if messages[lastIdx].ToolCalls != nil {
+			_ = common.Unmarshal(messages[lastIdx].ToolCalls, &existingCalls)
+		}
+		existingCalls = append(existingCalls, toolCall)
+		messages[lastIdx].SetToolCalls(existingCalls)
+		return messages
+	}
+	// Create new assistant message with tool call
+	msg := dto.Message{Role: "assistant"}
+	msg.SetToolCalls([]dto.ToolCallResponse{toolCall})
+	return append(messages, msg)
+}
+
 // convertResponsesContent converts Responses API content to Chat Completions content format
 func convertResponsesContent(content any) any {
 	switch c := content.(type) {
@@ -325,8 +544,22 @@ func convertResponsesTools(toolsRaw []byte) []dto.ToolCallRequest {
 					Parameters:  parameters,
 				},
 			})
+		case ComputerToolType, "computer_use", "computer-use-preview":
+			// Screen parameters travel with the tool, the channel adaptor maps it to its native computer tool
+			tools = append(tools, dto.ToolCallRequest{
+				Type: ComputerToolType,
+				ComputerUsePreview: &dto.ComputerUseTool{
+					DisplayWidth:  computerInt(tool["display_width"]),
+					DisplayHeight: computerInt(tool["display_height"]),
+					Environment:   common.Interface2String(tool["environment"]),
+				},
+			})
 		default:
-			// For other tool types (web_search, code_interpreter, etc.), keep as-is
+			// web_search is carried as web_search_options, an empty tool of that type is rejected upstream
+			if IsWebSearchToolType(toolType) {
+				continue
+			}
+			// For other tool types (code_interpreter, etc.), keep as-is
 			// These will be handled by the specific channel adaptor
 			if toolType != "" {
 				tools = append(tools, dto.ToolCallRequest{
@@ -356,6 +589,10 @@ func convertResponsesToolChoice(toolChoiceRaw []byte) any {
 	}
 
 	toolType, _ := toolChoice["type"].(string)
+	if IsWebSearchToolType(toolType) {
+		// search is requested through web_search_options, which has no forcing equivalent
+		return nil
+	}
 	if toolType == "function" {
 		// Responses format: {type: "function", name: "..."}
 		// Chat format: {type: "function", function: {name: "..."}}
@@ -0,0 +1,117 @@
+package openaicompat
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/QuantumNous/new-api/dto"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResponsesTextFormatToChatResponseFormat(t *testing.T) {
+	text := `{"format":{"type":"json_schema","name":"weather","strict":true,"schema":{"type":"object","properties":{"city":{"type":"string"}}}},"verbosity":"low"}`
+	req := &dto.OpenAIResponsesRequest{
+		Model: "gpt-5",
+		Input: json.RawMessage(`"hi"`),
+		Text:  json.RawMessage(text),
+	}
+	chatReq, err := ResponsesRequestToChatCompletionsRequest(req, nil)
+	require.NoError(t, err)
+	require.NotNil(t, chatReq.ResponseFormat)
+	require.Equal(t, "json_schema", chatReq.ResponseFormat.Type)
+	require.JSONEq(t, `{"name":"weather","strict":true,"schema":{"type":"object","properties":{"city":{"type":"string"}}}}`, string(chatReq.ResponseFormat.JsonSchema))
+	require.JSONEq(t, `"low"`, string(chatReq.Verbosity))
+	// the text format is converted to response_format, so strict mode does not reject it
+	require.Empty(t, ResponsesToChatUnsupportedFeatures(req))
+
+	// converting back yields the original format
+	back := convertChatResponseFormatToResponsesText(chatReq.ResponseFormat)
+	require.JSONEq(t, `{"format":{"type":"json_schema","name":"weather","strict":true,"schema":{"type":"object","properties":{"city":{"type":"string"}}}}}`, string(back))
+
+	for raw, expected := range map[string]*dto.ResponseFormat{
+		`{"format":{"type":"json_object"}}`: {Type: "json_object"},
+		`{"format":{"type":"text"}}`:        nil,
+		`null`:                              nil,
+	} {
+		req.Text = json.RawMessage(raw)
+		chatReq, err = ResponsesRequestToChatCompletionsRequest(req, nil)
+		require.NoError(t, err)
+		require.Equal(t, expected, chatReq.ResponseFormat, raw)
+	}
+}
+
+func TestChatToResponsesEchoesTextConfig(t *testing.T) {
+	text := json.RawMessage(`{"format":{"type":"json_object"}}`)
+	resp := ChatCompletionsResponseToResponsesResponse(&dto.OpenAITextResponse{Id: "chatcmpl-1"}, &dto.OpenAIResponsesRequest{Text: text})
+	require.JSONEq(t, string(text), string(resp.Text))
+}
+
+func TestResponsesRequestContinuesStoredConversation(t *testing.T) {
+	history := []dto.Message{
+		{Role: "user", Content: "what is 2+2?"},
+		{Role: "assistant", Content: "4"},
+	}
+	req := &dto.OpenAIResponsesRequest{
+		Model:              "gpt-5",
+		Instructions:       json.RawMessage(`"be brief"`),
+		Input:              json.RawMessage(`"and times 3?"`),
+		PreviousResponseID: "resp_1",
+	}
+	chatReq, err := ResponsesRequestToChatCompletionsRequest(req, history)
+	require.NoError(t, err)
+	require.Len(t, chatReq.Messages, 4)
+	require.Equal(t, "system", chatReq.Messages[0].Role)
+	require.Equal(t, "what is 2+2?", chatReq.Messages[1].StringContent())
+	require.Equal(t, "4", chatReq.Messages[2].StringContent())
+	require.Equal(t, "and times 3?", chatReq.Messages[3].StringContent())
+
+	conversation := ResponsesConversationMessages(req, chatReq)
+	require.Len(t, conversation, 3)
+	require.Equal(t, "user", conversation[0].Role)
+
+	require.True(t, ResponsesStoreRequested(req))
+	req.Store = json.RawMessage(`false`)
+	require.False(t, ResponsesStoreRequested(req))
+	req.Store = json.RawMessage(`true`)
+	require.True(t, ResponsesStoreRequested(req))
+}
+
+func TestChatResponseAssistantMessage(t *testing.T) {
+	resp := &dto.OpenAITextResponse{
+		Choices: []dto.OpenAITextResponseChoice{
+			{Index: 1, Message: dto.Message{Role: "assistant", Content: "second"}},
+			{Index: 0, Message: dto.Message{Role: "assistant", Content: "first", ReasoningContent: "hidden"}},
+		},
+	}
+	msg := ChatResponseAssistantMessage(resp)
+	require.NotNil(t, msg)
+	require.Equal(t, "first", msg.StringContent())
+	require.Empty(t, msg.ReasoningContent)
+}
+
+func TestParseResponsesInputKeepsRefusalsAndReasoning(t *testing.T) {
+	input := `[
+		{"role":"user","content":"How do I pick a lock?"},
+		{"type":"reasoning","id":"rs_1","summary":[{"type":"summary_text","text":"Unsafe request."}]},
+		{"type":"message","role":"assistant","content":[{"type":"refusal","refusal":"I can't help with that."}]},
+		{"role":"user","content":"What is the weather in Paris?"},
+		{"type":"reasoning","id":"rs_2","summary":[{"type":"summary_text","text":"summary"}],"content":[{"type":"reasoning_text","text":"Need the weather tool."}]},
+		{"type":"function_call","call_id":"call_1","name":"get_weather","arguments":"{\"city\":\"Paris\"}"},
+		{"type":"function_call_output","call_id":"call_1","output":"sunny"},
+		{"type":"message","role":"assistant","content":[{"type":"output_text","text":"It is sunny."}]}
+	]`
+	messages, err := parseResponsesInput([]byte(input))
+	require.NoError(t, err)
+	require.Len(t, messages, 6)
+
+	require.Equal(t, "assistant", messages[1].Role)
+	require.Equal(t, "I can't help with that.", messages[1].Refusal)
+	require.Equal(t, "", messages[1].Content)
+	require.Equal(t, "Unsafe request.", messages[1].ReasoningContent)
+
+	require.Equal(t, "Need the weather tool.", messages[3].ReasoningContent)
+	require.NotEmpty(t, messages[3].ToolCalls)
+	require.Equal(t, "tool", messages[4].Role)
+	require.Empty(t, messages[5].ReasoningContent)
+	require.Empty(t, messages[5].Refusal)
+}
@@ -0,0 +1,312 @@
+package openaicompat
+
+import (
+	"strings"
+
+	"github.com/QuantumNous/new-api/dto"
+)
+
+// ResponsesToChatStreamAdapter converts OpenAI Responses API stream events to Chat
+// Completions stream chunks, so channels that only speak Responses can serve chat streams.
+//
+// Everything is reported on choice 0: output text becomes content deltas carrying their
+// logprobs when requested, reasoning text and reasoning summaries become reasoning_content
+// deltas, function/computer calls become tool_calls deltas indexed in the order the
+// calls start, and generated images become markdown images in the content. As in the
+// non-stream conversion, tool calls are dropped once assistant text has been streamed.
+// Error events (error, response.failed) are not converted and are left to the caller.
+type ResponsesToChatStreamAdapter struct {
+	ID          string
+	Created     int64
+	Model       string
+	ServiceTier string
+	Usage       *dto.Usage // Set by response.completed when the upstream reports usage
+
+	started     bool
+	stopped     bool
+	sawToolCall bool
+	outputText  strings.Builder
+	usageText   strings.Builder // Everything streamed to the client, for token estimation
+
+	toolCallIndexes   map[string]int    // Call ID -> tool call index
+	toolCallNames     map[string]string // Call ID -> function name
+	toolCallNameSent  map[string]bool
+	toolCallArguments map[string]string // Call ID -> arguments received so far
+	toolCallIDs       map[string]string // Item ID -> call ID
+	imagesSent        map[string]bool   // Item IDs of the image_generation_call items sent
+
+	sentReasoningSummary     bool
+	needsReasoningSummaryGap bool // A summary part ended, the next one is separated by a blank line
+}
+
+// NewResponsesToChatStreamAdapter creates a new stream adapter; the created time and model
+// are replaced by the ones reported by the upstream response.
+func NewResponsesToChatStreamAdapter(id string, created int64, model string) *ResponsesToChatStreamAdapter {
+	return &ResponsesToChatStreamAdapter{
+		ID:                id,
+		Created:           created,
+		Model:             model,
+		toolCallIndexes:   make(map[string]int),
+		toolCallNames:     make(map[string]string),
+		toolCallNameSent:  make(map[string]bool),
+		toolCallArguments: make(map[string]string),
+		toolCallIDs:       make(map[string]string),
+		imagesSent:        make(map[string]bool),
+	}
+}
+
+// ConvertEvent converts a Responses stream event to Chat Completions stream chunks.
+// The first chunk carries the assistant role; response.completed produces the finish chunk.
+func (a *ResponsesToChatStreamAdapter) ConvertEvent(event *dto.ResponsesStreamResponse) []*dto.ChatCompletionsStreamResponse {
+	if event == nil || a.stopped {
+		return nil
+	}
+
+	switch event.Type {
+	case "response.created", "response.in_progress":
+		a.updateResponse(event.Response)
+
+	case "response.reasoning_text.delta":
+		return a.reasoningDelta(event.Delta)
+
+	case "response.reasoning_summary_text.delta":
+		delta := event.Delta
+		if delta != "" && a.needsReasoningSummaryGap {
+			a.needsReasoningSummaryGap = false
+			if !strings.HasPrefix(delta, "\n\n") {
+				if strings.HasPrefix(delta, "\n") {
+					delta = "\n" + delta
+				} else {
+					delta = "\n\n" + delta
+				}
+			}
+		}
+		chunks := a.reasoningDelta(delta)
+		if len(chunks) > 0 {
+			a.sentReasoningSummary = true
+		}
+		return chunks
+
+	case "response.reasoning_summary_text.done":
+		if a.sentReasoningSummary {
+			a.needsReasoningSummaryGap = true
+		}
+
+	case "response.output_text.delta":
+		chunks := a.start()
+		if event.Delta == "" {
+			return chunks
+		}
+		a.outputText.WriteString(event.Delta)
+		a.usageText.WriteString(event.Delta)
+		delta := event.Delta
+		chunk := a.chunk(dto.ChatCompletionsStreamResponseChoiceDelta{Content: &delta})
+		if len(event.Logprobs) > 0 {
+			chunk.Choices[0].Logprobs = chatLogprobs(event.Logprobs)
+		}
+		return append(chunks, chunk)
+
+	case "response.output_item.added", "response.output_item.done":
+		return a.convertOutputItem(event.Item)
+
+	case "response.function_call_arguments.delta":
+		itemID := strings.TrimSpace(event.ItemID)
+		callID := a.toolCallIDs[itemID]
+		if callID == "" {
+			callID = itemID
+		}
+		if callID == "" {
+			return nil
+		}
+		a.toolCallArguments[callID] += event.Delta
+		return a.toolCallDelta(callID, "", event.Delta)
+
+	case "response.completed":
+		a.updateResponse(event.Response)
+		if event.Response != nil && event.Response.Usage != nil {
+			a.Usage = responsesUsageToChatUsage(event.Response.Usage)
+		}
+		return a.Finish()
+	}
+	return nil
+}
+
+// Finish returns the chunks still needed to end the stream: the role chunk if nothing was
+// streamed and the finish chunk. It returns nothing once the stream has been finished.
+func (a *ResponsesToChatStreamAdapter) Finish() []*dto.ChatCompletionsStreamResponse {
+	if a.stopped {
+		return nil
+	}
+	a.stopped = true
+	finishReason := a.FinishReason()
+	return append(a.start(), &dto.ChatCompletionsStreamResponse{
+		Id:      a.ID,
+		Object:  "chat.completion.chunk",
+		Created: a.Created,
+		Model:   a.Model,
+		Choices: []dto.ChatCompletionsStreamResponseChoice{
+			{
+				FinishReason: &finishReason,
+			},
+		},
+	})
+}
+
+// FinishReason returns tool_calls when the stream carried tool calls and no text, stop otherwise.
+func (a *ResponsesToChatStreamAdapter) FinishReason() string {
+	if a.sawToolCall && a.outputText.Len() == 0 {
+		return "tool_calls"
+	}
+	return "stop"
+}
+
+// UsageText returns the streamed text, reasoning and tool calls for estimating usage
+// when the upstream does not report it.
+func (a *ResponsesToChatStreamAdapter) UsageText() string {
+	return a.usageText.String()
+}
+
+func (a *ResponsesToChatStreamAdapter) updateResponse(resp *dto.OpenAIResponsesResponse) {
+	if resp == nil {
+		return
+	}
+	if resp.Model != "" {
+		a.Model = resp.Model
+	}
+	if resp.CreatedAt != 0 {
+		a.Created = int64(resp.CreatedAt)
+	}
+	if resp.ServiceTier != "" {
+		a.ServiceTier = resp.ServiceTier
+	}
+}
+
+func (a *ResponsesToChatStreamAdapter) start() []*dto.ChatCompletionsStreamResponse {
+	if a.started {
+		return nil
+	}
+	a.started = true
+	content := ""
+	return []*dto.ChatCompletionsStreamResponse{
+		a.chunk(dto.ChatCompletionsStreamResponseChoiceDelta{Role: "assistant", Content: &content}),
+	}
+}
+
+func (a *ResponsesToChatStreamAdapter) chunk(delta dto.ChatCompletionsStreamResponseChoiceDelta) *dto.ChatCompletionsStreamResponse {
+	return &dto.ChatCompletionsStreamResponse{
+		Id:      a.ID,
+		Object:  "chat.completion.chunk",
+		Created: a.Created,
+		Model:   a.Model,
+		Choices: []dto.ChatCompletionsStreamResponseChoice{
+			{
+				Index: 0,
+				Delta: delta,
+			},
+		},
+	}
+}
+
+func (a *ResponsesToChatStreamAdapter) reasoningDelta(delta string) []*dto.ChatCompletionsStreamResponse {
+	if delta == "" {
+		return nil
+	}
+	a.usageText.WriteString(delta)
+	return append(a.start(), a.chunk(dto.ChatCompletionsStreamResponseChoiceDelta{ReasoningContent: &delta}))
+}
+
+// convertOutputItem streams the parts of a call item not yet sent; added and done events
+// may both carry the name and the full arguments.
+func (a *ResponsesToChatStreamAdapter) convertOutputItem(item *dto.ResponsesOutput) []*dto.ChatCompletionsStreamResponse {
+	if item == nil {
+		return nil
+	}
+	switch item.Type {
+	case "function_call":
+		itemID := strings.TrimSpace(item.ID)
+		callID := strings.TrimSpace(item.CallId)
+		if callID == "" {
+			callID = itemID
+		}
+		if callID == "" {
+			return nil
+		}
+		if itemID != "" {
+			a.toolCallIDs[itemID] = callID
+		}
+		return a.toolCallDelta(callID, strings.TrimSpace(item.Name), a.argumentsDelta(callID, item.Arguments))
+
+	case "computer_call":
+		// computer_call items carry the whole action once they are done
+		callID := strings.TrimSpace(item.CallId)
+		if callID == "" || len(item.Action) == 0 {
+			return nil
+		}
+		return a.toolCallDelta(callID, ComputerToolCallName, a.argumentsDelta(callID, string(item.Action)))
+
+	case dto.ResponsesOutputTypeImageGenerationCall:
+		// The image is only carried by the done event, as base64
+		if item.Result == "" || a.imagesSent[item.ID] {
+			return nil
+		}
+		a.imagesSent[item.ID] = true
+		content := imageGenerationMarkdown(item.Result, item.OutputFormat)
+		if a.outputText.Len() > 0 {
+			content = "\n\n" + content
+		}
+		a.outputText.WriteString(content)
+		return append(a.start(), a.chunk(dto.ChatCompletionsStreamResponseChoiceDelta{Content: &content}))
+	}
+	return nil
+}
+
+func (a *ResponsesToChatStreamAdapter) argumentsDelta(callID string, arguments string) string {
+	if arguments == "" {
+		return ""
+	}
+	prev := a.toolCallArguments[callID]
+	a.toolCallArguments[callID] = arguments
+	if strings.HasPrefix(arguments, prev) {
+		return arguments[len(prev):]
+	}
+	return arguments
+}
+
+func (a *ResponsesToChatStreamAdapter) toolCallDelta(callID string, name string, argsDelta string) []*dto.ChatCompletionsStreamResponse {
+	if a.outputText.Len() > 0 {
+		// Prefer streaming assistant text over tool calls to match non-stream behavior.
+		return nil
+	}
+	if name != "" {
+		a.toolCallNames[callID] = name
+	}
+	name = a.toolCallNames[callID]
+
+	idx, ok := a.toolCallIndexes[callID]
+	if ok && argsDelta == "" && (name == "" || a.toolCallNameSent[callID]) {
+		return nil
+	}
+	chunks := a.start()
+	if !ok {
+		idx = len(a.toolCallIndexes)
+		a.toolCallIndexes[callID] = idx
+	}
+
+	tool := dto.ToolCallResponse{
+		ID:   callID,
+		Type: "function",
+		Function: dto.FunctionResponse{
+			Arguments: argsDelta,
+		},
+	}
+	tool.SetIndex(idx)
+	if name != "" && !a.toolCallNameSent[callID] {
+		tool.Function.Name = name
+		a.toolCallNameSent[callID] = true
+		a.usageText.WriteString(name)
+	}
+	a.usageText.WriteString(argsDelta)
+	a.sawToolCall = true
+
+	return append(chunks, a.chunk(dto.ChatCompletionsStreamResponseChoiceDelta{ToolCalls: []dto.ToolCallResponse{tool}}))
+}
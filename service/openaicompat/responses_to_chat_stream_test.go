@@ -0,0 +1,75 @@
+package openaicompat
+
+import (
+	"testing"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/dto"
+	"github.com/stretchr/testify/require"
+)
+
+func convertResponsesEvents(t *testing.T, adapter *ResponsesToChatStreamAdapter, events ...string) []*dto.ChatCompletionsStreamResponse {
+	t.Helper()
+	var chunks []*dto.ChatCompletionsStreamResponse
+	for _, data := range events {
+		var event dto.ResponsesStreamResponse
+		require.NoError(t, common.UnmarshalJsonStr(data, &event))
+		chunks = append(chunks, adapter.ConvertEvent(&event)...)
+	}
+	return chunks
+}
+
+func TestResponsesToChatStreamTextAndReasoning(t *testing.T) {
+	adapter := NewResponsesToChatStreamAdapter("chatcmpl-1", 1, "upstream")
+	chunks := convertResponsesEvents(t, adapter,
+		`{"type":"response.created","response":{"model":"gpt-5","created_at":100}}`,
+		`{"type":"response.reasoning_summary_text.delta","delta":"plan"}`,
+		`{"type":"response.reasoning_summary_text.done"}`,
+		`{"type":"response.reasoning_summary_text.delta","delta":"check"}`,
+		`{"type":"response.output_text.delta","delta":"Hi"}`,
+		`{"type":"response.completed","response":{"usage":{"input_tokens":3,"output_tokens":5}}}`,
+	)
+
+	require.Len(t, chunks, 5)
+	require.Equal(t, "assistant", chunks[0].Choices[0].Delta.Role)
+	require.Equal(t, "plan", *chunks[1].Choices[0].Delta.ReasoningContent)
+	require.Equal(t, "\n\ncheck", *chunks[2].Choices[0].Delta.ReasoningContent)
+	require.Equal(t, "Hi", chunks[3].Choices[0].Delta.GetContentString())
+	require.Equal(t, "stop", *chunks[4].Choices[0].FinishReason)
+	for _, chunk := range chunks {
+		require.Equal(t, "chatcmpl-1", chunk.Id)
+		require.Equal(t, "gpt-5", chunk.Model)
+		require.EqualValues(t, 100, chunk.Created)
+	}
+	require.Equal(t, 8, adapter.Usage.TotalTokens)
+	require.Empty(t, adapter.Finish())
+}
+
+func TestResponsesToChatStreamToolCalls(t *testing.T) {
+	adapter := NewResponsesToChatStreamAdapter("chatcmpl-1", 1, "gpt-5")
+	chunks := convertResponsesEvents(t, adapter,
+		`{"type":"response.output_item.added","item":{"type":"function_call","id":"fc_1","call_id":"call_1","name":"get_weather","arguments":""}}`,
+		`{"type":"response.function_call_arguments.delta","item_id":"fc_1","delta":"{\"city\":"}`,
+		`{"type":"response.function_call_arguments.delta","item_id":"fc_1","delta":"\"Paris\"}"}`,
+		`{"type":"response.output_item.done","item":{"type":"function_call","id":"fc_1","call_id":"call_1","name":"get_weather","arguments":"{\"city\":\"Paris\"}"}}`,
+		`{"type":"response.output_item.added","item":{"type":"function_call","id":"fc_2","call_id":"call_2","name":"get_time","arguments":"{}"}}`,
+	)
+	chunks = append(chunks, adapter.Finish()...)
+
+	var arguments string
+	for _, chunk := range chunks[1 : len(chunks)-1] {
+		for _, call := range chunk.Choices[0].Delta.ToolCalls {
+			if call.ID == "call_1" {
+				require.Equal(t, 0, *call.Index)
+				arguments += call.Function.Arguments
+			} else {
+				require.Equal(t, 1, *call.Index)
+				require.Equal(t, "get_time", call.Function.Name)
+			}
+		}
+	}
+	require.Equal(t, "get_weather", chunks[1].Choices[0].Delta.ToolCalls[0].Function.Name)
+	require.Empty(t, chunks[2].Choices[0].Delta.ToolCalls[0].Function.Name)
+	require.Equal(t, `{"city":"Paris"}`, arguments)
+	require.Equal(t, "tool_calls", *chunks[len(chunks)-1].Choices[0].FinishReason)
+}
@@ -0,0 +1,101 @@
+package openaicompat
+
+import (
+	"os"
+	"strings"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/constant"
+)
+
+const (
+	ToolArgumentsOverflowTruncate = "truncate"
+	ToolArgumentsOverflowDisk     = "disk"
+)
+
+// toolArgumentsBuffer accumulates streamed function call arguments for the done events.
+// Once maxBytes is reached the remaining arguments are either spilled to a disk cache
+// file or dropped with Truncated set; with retain disabled nothing is kept at all.
+type toolArgumentsBuffer struct {
+	retain   bool
+	maxBytes int
+	overflow string
+
+	memory    strings.Builder
+	size      int
+	Truncated bool
+
+	spillPath string
+	spillFile *os.File
+}
+
+func newToolArgumentsBuffer() *toolArgumentsBuffer {
+	return &toolArgumentsBuffer{
+		retain:   constant.CompatToolArgumentsRetain,
+		maxBytes: constant.CompatToolArgumentsMaxKB << 10,
+		overflow: constant.CompatToolArgumentsOverflow,
+	}
+}
+
+// Append records a delta. The delta itself is always forwarded to the client by the caller.
+func (b *toolArgumentsBuffer) Append(delta string) {
+	b.size += len(delta)
+	if !b.retain || b.Truncated {
+		return
+	}
+	if b.spillFile != nil {
+		b.writeSpill(delta)
+		return
+	}
+	if b.maxBytes <= 0 || b.memory.Len()+len(delta) <= b.maxBytes {
+		b.memory.WriteString(delta)
+		return
+	}
+	if b.overflow == ToolArgumentsOverflowDisk {
+		path, file, err := common.CreateDiskCacheFile(common.DiskCacheTypeFile)
+		if err == nil {
+			b.spillPath, b.spillFile = path, file
+			b.writeSpill(b.memory.String())
+			b.memory.Reset()
+			b.writeSpill(delta)
+			return
+		}
+		common.SysError("failed to spill tool arguments to disk: " + err.Error())
+	}
+	b.Truncated = true
+}
+
+func (b *toolArgumentsBuffer) writeSpill(data string) {
+	if _, err := b.spillFile.WriteString(data); err != nil {
+		common.SysError("failed to write tool arguments spill file: " + err.Error())
+		b.Truncated = true
+	}
+}
+
+// String returns the retained arguments; it is empty when retention is disabled.
+func (b *toolArgumentsBuffer) String() string {
+	if b.spillFile == nil {
+		return b.memory.String()
+	}
+	data, err := common.ReadDiskCacheFileString(b.spillPath)
+	if err != nil {
+		common.SysError("failed to read tool arguments spill file: " + err.Error())
+		return ""
+	}
+	return data
+}
+
+// Size returns the total size of all appended deltas, retained or not.
+func (b *toolArgumentsBuffer) Size() int {
+	return b.size
+}
+
+// Close releases the spill file, if any.
+func (b *toolArgumentsBuffer) Close() {
+	if b.spillFile == nil {
+		return
+	}
+	_ = b.spillFile.Close()
+	_ = common.RemoveDiskCacheFile(b.spillPath)
+	b.spillFile = nil
+}
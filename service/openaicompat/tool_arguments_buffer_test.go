@@ -0,0 +1,24 @@
+package openaicompat
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestToolArgumentsBufferTruncate(t *testing.T) {
+	b := &toolArgumentsBuffer{retain: true, maxBytes: 8, overflow: ToolArgumentsOverflowTruncate}
+	b.Append(`{"a":`)
+	b.Append(`"bcdef"}`)
+	require.True(t, b.Truncated)
+	require.Equal(t, `{"a":`, b.String())
+	require.Equal(t, 13, b.Size())
+}
+
+func TestToolArgumentsBufferNoRetain(t *testing.T) {
+	b := &toolArgumentsBuffer{retain: false, maxBytes: 8}
+	b.Append(`{"a":1}`)
+	require.False(t, b.Truncated)
+	require.Empty(t, b.String())
+	require.Equal(t, 7, b.Size())
+}
@@ -0,0 +1,92 @@
+package openaicompat
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/dto"
+)
+
+// ChatToResponsesUnsupportedFeatures lists the Chat Completions request features
+// that are dropped when the request is converted to a Responses API request.
+func ChatToResponsesUnsupportedFeatures(req *dto.GeneralOpenAIRequest) []string {
+	if req == nil {
+		return nil
+	}
+	features := make([]string, 0)
+	if req.Stop != nil {
+		features = append(features, "stop")
+	}
+	if req.FrequencyPenalty != nil {
+		features = append(features, "frequency_penalty")
+	}
+	if req.PresencePenalty != nil {
+		features = append(features, "presence_penalty")
+	}
+	if req.Seed != nil {
+		features = append(features, "seed")
+	}
+	if len(req.LogitBias) > 0 {
+		features = append(features, "logit_bias")
+	}
+	if len(req.Audio) > 0 {
+		features = append(features, "audio")
+	}
+	if len(req.Prediction) > 0 {
+		features = append(features, "prediction")
+	}
+	return features
+}
+
+// ResponsesToChatUnsupportedFeatures lists the Responses API request features
+// that cannot be honored once the request is converted to Chat Completions.
+// supported names the built-in tool types and include values the target adaptor
+// can still serve (function tools are always supported).
+func ResponsesToChatUnsupportedFeatures(req *dto.OpenAIResponsesRequest, supported ...string) []string {
+	if req == nil {
+		return nil
+	}
+	features := make([]string, 0)
+	for _, tool := range req.GetToolsMap() {
+		toolType := strings.TrimSpace(common.Interface2String(tool["type"]))
+		if IsComputerUseToolType(toolType) {
+			toolType = ComputerToolType
+		} else if IsWebSearchToolType(toolType) {
+			toolType = WebSearchToolType
+		}
+		if toolType == "" || toolType == "function" || slices.Contains(supported, toolType) {
+			continue
+		}
+		feature := "tools." + toolType
+		if !slices.Contains(features, feature) {
+			features = append(features, feature)
+		}
+	}
+	if len(req.Conversation) > 0 {
+		features = append(features, "conversation")
+	}
+	if len(req.Prompt) > 0 {
+		features = append(features, "prompt")
+	}
+	if len(req.Truncation) > 0 {
+		var truncation string
+		if err := common.Unmarshal(req.Truncation, &truncation); err != nil || (truncation != "disabled" && truncation != "auto") {
+			features = append(features, "truncation")
+		}
+	}
+	logprobs := ResponsesIncludes(req, IncludeOutputTextLogprobs) && slices.Contains(supported, IncludeOutputTextLogprobs)
+	if req.TopLogProbs != nil && !logprobs {
+		features = append(features, "top_logprobs")
+	}
+	for _, include := range ResponsesInclude(req) {
+		if include == IncludeReasoningEncryptedContent || slices.Contains(supported, include) {
+			continue
+		}
+		feature := "include." + include
+		if !slices.Contains(features, feature) {
+			features = append(features, feature)
+		}
+	}
+	return features
+}
@@ -0,0 +1,66 @@
+package openaicompat
+
+import (
+	"strings"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/dto"
+)
+
+// The Responses built-in web search tool has no Chat Completions tool equivalent. It is
+// carried through the Chat Completions layer as web_search_options, which the channel
+// adaptors translate to their native search tool (Claude web_search, Gemini googleSearch,
+// Perplexity web_search_options).
+const WebSearchToolType = "web_search"
+
+// IsWebSearchToolType reports whether a Responses tool type is the built-in web search tool,
+// including its preview and dated variants (web_search_preview_2025_03_11, web_search_2025_08_26).
+func IsWebSearchToolType(toolType string) bool {
+	return toolType == WebSearchToolType || toolType == dto.BuildInToolWebSearchPreview ||
+		strings.HasPrefix(toolType, WebSearchToolType+"_20") || strings.HasPrefix(toolType, dto.BuildInToolWebSearchPreview+"_20")
+}
+
+// responsesWebSearchOptions returns the web_search_options of the first web search tool
+// of a Responses request, or nil when the request declares none.
+func responsesWebSearchOptions(tools []map[string]any) *dto.WebSearchOptions {
+	for _, tool := range tools {
+		if !IsWebSearchToolType(common.Interface2String(tool["type"])) {
+			continue
+		}
+		options := &dto.WebSearchOptions{}
+		if searchContextSize, ok := tool["search_context_size"].(string); ok {
+			options.SearchContextSize = searchContextSize
+		}
+		if userLocation, ok := tool["user_location"]; ok && userLocation != nil {
+			if userLocationBytes, err := common.Marshal(userLocation); err == nil {
+				options.UserLocation = userLocationBytes
+			}
+		}
+		return options
+	}
+	return nil
+}
+
+// NormalizeResponsesWebSearchTools renames the preview and dated web search tool types of a
+// Responses request to web_search, for upstreams whose native Responses API only knows the
+// plain tool type (Perplexity).
+func NormalizeResponsesWebSearchTools(req *dto.OpenAIResponsesRequest) error {
+	tools := req.GetToolsMap()
+	changed := false
+	for _, tool := range tools {
+		toolType := common.Interface2String(tool["type"])
+		if toolType != WebSearchToolType && IsWebSearchToolType(toolType) {
+			tool["type"] = WebSearchToolType
+			changed = true
+		}
+	}
+	if !changed {
+		return nil
+	}
+	data, err := common.Marshal(tools)
+	if err != nil {
+		return err
+	}
+	req.Tools = data
+	return nil
+}
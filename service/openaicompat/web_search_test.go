@@ -0,0 +1,43 @@
+package openaicompat
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/QuantumNous/new-api/dto"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResponsesWebSearchToolBecomesWebSearchOptions(t *testing.T) {
+	req := &dto.OpenAIResponsesRequest{
+		Model:      "gemini-2.5-pro",
+		Input:      json.RawMessage(`"news today"`),
+		Tools:      json.RawMessage(`[{"type":"web_search_preview","search_context_size":"high","user_location":{"type":"approximate","country":"US"}}]`),
+		ToolChoice: json.RawMessage(`{"type":"web_search_preview"}`),
+	}
+	require.Empty(t, ResponsesToChatUnsupportedFeatures(req, WebSearchToolType))
+
+	chatReq, err := ResponsesRequestToChatCompletionsRequest(req, nil)
+	require.NoError(t, err)
+	require.Nil(t, chatReq.Tools)
+	require.Nil(t, chatReq.ToolChoice)
+	require.NotNil(t, chatReq.WebSearchOptions)
+	require.Equal(t, "high", chatReq.WebSearchOptions.SearchContextSize)
+	require.JSONEq(t, `{"type":"approximate","country":"US"}`, string(chatReq.WebSearchOptions.UserLocation))
+
+	req.Tools = json.RawMessage(`[{"type":"web_search_2025_08_26"},{"type":"function","name":"lookup","parameters":{"type":"object"}}]`)
+	req.ToolChoice = json.RawMessage(`"auto"`)
+	chatReq, err = ResponsesRequestToChatCompletionsRequest(req, nil)
+	require.NoError(t, err)
+	require.Len(t, chatReq.Tools, 1)
+	require.Equal(t, "lookup", chatReq.Tools[0].Function.Name)
+	require.Equal(t, "auto", chatReq.ToolChoice)
+	require.NotNil(t, chatReq.WebSearchOptions)
+}
+
+func TestNormalizeResponsesWebSearchTools(t *testing.T) {
+	req := &dto.OpenAIResponsesRequest{Tools: json.RawMessage(`[{"type":"web_search_preview_2025_03_11"},{"type":"function","name":"f"}]`)}
+	require.NoError(t, NormalizeResponsesWebSearchTools(req))
+	require.JSONEq(t, `[{"type":"web_search"},{"type":"function","name":"f"}]`, string(req.Tools))
+	require.False(t, IsWebSearchToolType("web_search_call"))
+}
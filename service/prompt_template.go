@@ -0,0 +1,186 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/dto"
+	"github.com/QuantumNous/new-api/logger"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/types"
+
+	"github.com/gin-gonic/gin"
+)
+
+// promptVariablePattern 匹配模板中的 {{变量名}}，允许花括号内有空格
+var promptVariablePattern = regexp.MustCompile(`\{\{\s*([A-Za-z0-9_.\-]+)\s*\}\}`)
+
+// responsesPrompt Responses 请求的 prompt 字段
+type responsesPrompt struct {
+	Id        string         `json:"id"`
+	Version   any            `json:"version"`
+	Variables map[string]any `json:"variables"`
+}
+
+// ApplyResponsesPromptTemplate 将 Responses 请求的 prompt 字段按网关提示词模板展开：
+// 模板 instructions 追加在请求 instructions 之后，模板 input 追加在请求 input 之前，
+// 变量替换后移除 prompt 字段。模板不存在时请求保持不变，由支持 prompt 的上游处理
+func ApplyResponsesPromptTemplate(c *gin.Context, request dto.Request) *types.NewAPIError {
+	r, ok := request.(*dto.OpenAIResponsesRequest)
+	if !ok || len(r.Prompt) == 0 || common.GetJsonType(r.Prompt) != "object" {
+		return nil
+	}
+	var prompt responsesPrompt
+	if err := common.Unmarshal(r.Prompt, &prompt); err != nil {
+		return types.NewErrorWithStatusCode(fmt.Errorf("invalid prompt: %w", err), types.ErrorCodeInvalidRequest, http.StatusBadRequest, types.ErrOptionWithSkipRetry())
+	}
+	version := 0
+	if v := common.Interface2String(prompt.Version); v != "" {
+		var err error
+		if version, err = strconv.Atoi(v); err != nil || version <= 0 {
+			return types.NewErrorWithStatusCode(fmt.Errorf("invalid prompt version: %s", v), types.ErrorCodeInvalidRequest, http.StatusBadRequest, types.ErrOptionWithSkipRetry())
+		}
+	}
+	if prompt.Id == "" {
+		return nil
+	}
+	template, err := model.GetPromptTemplate(prompt.Id, version)
+	if errors.Is(err, model.ErrPromptTemplateNotFound) {
+		return nil
+	}
+	if err != nil {
+		return types.NewError(err, types.ErrorCodeQueryDataError, types.ErrOptionWithSkipRetry())
+	}
+	if err = expandPromptTemplate(r, template, prompt.Variables); err != nil {
+		return types.NewErrorWithStatusCode(err, types.ErrorCodeInvalidRequest, http.StatusBadRequest, types.ErrOptionWithSkipRetry())
+	}
+	logger.LogInfo(c, fmt.Sprintf("prompt template %s version %d applied", template.PromptId, template.Version))
+	return nil
+}
+
+// expandPromptTemplate 按变量展开模板并合并到请求中，缺少变量时返回错误
+func expandPromptTemplate(r *dto.OpenAIResponsesRequest, template *model.PromptTemplate, variables map[string]any) error {
+	if template.Instructions != "" {
+		instructions, err := renderPromptText(template.Instructions, variables)
+		if err != nil {
+			return err
+		}
+		var existing string
+		if len(r.Instructions) > 0 && common.Unmarshal(r.Instructions, &existing) == nil && strings.TrimSpace(existing) != "" {
+			instructions = existing + "\n" + instructions
+		}
+		if r.Instructions, err = common.Marshal(instructions); err != nil {
+			return err
+		}
+	}
+	if strings.TrimSpace(template.Input) != "" {
+		var items []any
+		if err := common.UnmarshalJsonStr(template.Input, &items); err != nil {
+			return fmt.Errorf("invalid input of prompt template %s: %w", template.PromptId, err)
+		}
+		for _, item := range items {
+			if err := renderPromptItem(item, variables); err != nil {
+				return err
+			}
+		}
+		requestItems, err := responsesInputItems(r.Input)
+		if err != nil {
+			return err
+		}
+		if r.Input, err = common.Marshal(append(items, requestItems...)); err != nil {
+			return err
+		}
+	}
+	r.Prompt = nil
+	return nil
+}
+
+// renderPromptItem 替换 input 项 content 中的变量；只包含一个变量的文本部分在变量为图片、文件等输入对象时替换为该对象
+func renderPromptItem(item any, variables map[string]any) error {
+	message, ok := item.(map[string]any)
+	if !ok {
+		return nil
+	}
+	switch content := message["content"].(type) {
+	case string:
+		text, err := renderPromptText(content, variables)
+		if err != nil {
+			return err
+		}
+		message["content"] = text
+	case []any:
+		for i, p := range content {
+			part, ok := p.(map[string]any)
+			if !ok {
+				continue
+			}
+			text, ok := part["text"].(string)
+			if !ok {
+				continue
+			}
+			if match := promptVariablePattern.FindStringSubmatch(text); match != nil && match[0] == strings.TrimSpace(text) {
+				if value, ok := variables[match[1]].(map[string]any); ok && !isPromptTextVariable(value) {
+					content[i] = value
+					continue
+				}
+			}
+			rendered, err := renderPromptText(text, variables)
+			if err != nil {
+				return err
+			}
+			part["text"] = rendered
+		}
+	}
+	return nil
+}
+
+// renderPromptText 替换文本中的变量
+func renderPromptText(text string, variables map[string]any) (string, error) {
+	var renderErr error
+	rendered := promptVariablePattern.ReplaceAllStringFunc(text, func(placeholder string) string {
+		name := promptVariablePattern.FindStringSubmatch(placeholder)[1]
+		value, ok := variables[name]
+		if !ok {
+			renderErr = fmt.Errorf("missing prompt variable: %s", name)
+			return placeholder
+		}
+		if object, ok := value.(map[string]any); ok {
+			if !isPromptTextVariable(object) {
+				renderErr = fmt.Errorf("prompt variable %s is not text", name)
+				return placeholder
+			}
+			return common.Interface2String(object["text"])
+		}
+		return common.Interface2String(value)
+	})
+	return rendered, renderErr
+}
+
+func isPromptTextVariable(value map[string]any) bool {
+	return common.Interface2String(value["type"]) == "input_text"
+}
+
+// responsesInputItems 返回请求 input 的输入项，字符串 input 视为一条用户消息
+func responsesInputItems(input []byte) ([]any, error) {
+	switch common.GetJsonType(input) {
+	case "string":
+		var text string
+		if err := common.Unmarshal(input, &text); err != nil {
+			return nil, err
+		}
+		return []any{map[string]any{"role": "user", "content": text}}, nil
+	case "array":
+		var items []any
+		if err := common.Unmarshal(input, &items); err != nil {
+			return nil, err
+		}
+		return items, nil
+	default:
+		return nil, nil
+	}
+}
@@ -0,0 +1,49 @@
+package service
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/dto"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExpandPromptTemplate(t *testing.T) {
+	template := &model.PromptTemplate{
+		PromptId:     "pmpt_support",
+		Version:      2,
+		Instructions: "You answer questions about {{ product }}.",
+		Input:        `[{"role":"user","content":[{"type":"input_text","text":"Customer: {{customer}}"},{"type":"input_text","text":"{{screenshot}}"}]}]`,
+	}
+	req := &dto.OpenAIResponsesRequest{
+		Instructions: json.RawMessage(`"Be brief."`),
+		Input:        json.RawMessage(`"Why does it crash?"`),
+		Prompt:       json.RawMessage(`{"id":"pmpt_support"}`),
+	}
+	variables := map[string]any{
+		"product":    "new-api",
+		"customer":   map[string]any{"type": "input_text", "text": "Ada"},
+		"screenshot": map[string]any{"type": "input_image", "image_url": "https://example.com/crash.png"},
+	}
+	require.NoError(t, expandPromptTemplate(req, template, variables))
+	require.Nil(t, req.Prompt)
+	require.JSONEq(t, `"Be brief.\nYou answer questions about new-api."`, string(req.Instructions))
+
+	var items []map[string]any
+	require.NoError(t, common.Unmarshal(req.Input, &items))
+	require.Len(t, items, 2)
+	require.Equal(t, []any{
+		map[string]any{"type": "input_text", "text": "Customer: Ada"},
+		map[string]any{"type": "input_image", "image_url": "https://example.com/crash.png"},
+	}, items[0]["content"])
+	require.Equal(t, map[string]any{"role": "user", "content": "Why does it crash?"}, items[1])
+}
+
+func TestExpandPromptTemplateMissingVariable(t *testing.T) {
+	template := &model.PromptTemplate{PromptId: "pmpt_x", Instructions: "Hello {{name}}"}
+	req := &dto.OpenAIResponsesRequest{Prompt: json.RawMessage(`{"id":"pmpt_x"}`)}
+	require.ErrorContains(t, expandPromptTemplate(req, template, nil), "missing prompt variable: name")
+	require.ErrorContains(t, expandPromptTemplate(req, template, map[string]any{"name": map[string]any{"type": "input_file", "file_id": "f"}}), "is not text")
+}
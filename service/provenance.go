@@ -0,0 +1,79 @@
+package service
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ProvenanceHeader 标识生成内容来源的响应头
+const ProvenanceHeader = "X-Generated-By"
+
+// ProvenanceMetadataPrefix 写入 Responses 响应对象 metadata 的来源字段前缀，与客户端自己的 metadata 区分
+const ProvenanceMetadataPrefix = "generated_by_"
+
+// SetProvenanceHeader 开启时附加 X-Generated-By 响应头，值为分号分隔的 key=value，
+// 例如 deployment=prod-eu-1; policy_version=2026-10; content_policy_flags=pii,minors
+func SetProvenanceHeader(c *gin.Context) {
+	setting := operation_setting.GetProvenanceSetting()
+	if !setting.HeaderEnabled {
+		return
+	}
+	fields := setting.Fields()
+	if len(fields) == 0 {
+		return
+	}
+	parts := make([]string, 0, len(fields))
+	for _, field := range fields {
+		parts = append(parts, field[0]+"="+field[1])
+	}
+	c.Header(ProvenanceHeader, strings.Join(parts, "; "))
+}
+
+// ApplyProvenanceMetadata 开启时将来源字段合并到 Responses 响应对象的 metadata 中（键加 generated_by_ 前缀），
+// 未开启或 metadata 无法解析时原样返回
+func ApplyProvenanceMetadata(metadata json.RawMessage) json.RawMessage {
+	setting := operation_setting.GetProvenanceSetting()
+	if !setting.MetadataEnabled {
+		return metadata
+	}
+	fields := setting.Fields()
+	if len(fields) == 0 {
+		return metadata
+	}
+	merged := make(map[string]any)
+	if len(metadata) > 0 && string(metadata) != "null" {
+		if err := common.Unmarshal(metadata, &merged); err != nil {
+			return metadata
+		}
+	}
+	for _, field := range fields {
+		merged[ProvenanceMetadataPrefix+field[0]] = field[1]
+	}
+	data, err := common.Marshal(merged)
+	if err != nil {
+		return metadata
+	}
+	return data
+}
+
+// ApplyProvenanceToResponsesBody 将来源字段写入上游返回的 Responses 响应对象，未开启时原样返回
+func ApplyProvenanceToResponsesBody(body []byte) []byte {
+	if !operation_setting.GetProvenanceSetting().MetadataEnabled {
+		return body
+	}
+	var response map[string]json.RawMessage
+	if err := common.Unmarshal(body, &response); err != nil {
+		return body
+	}
+	response["metadata"] = ApplyProvenanceMetadata(response["metadata"])
+	data, err := common.Marshal(response)
+	if err != nil {
+		return body
+	}
+	return data
+}
@@ -0,0 +1,36 @@
+package service
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProvenance(t *testing.T) {
+	setting := operation_setting.GetProvenanceSetting()
+	saved := *setting
+	t.Cleanup(func() { *setting = saved })
+	setting.Deployment = "prod-eu-1"
+	setting.PolicyVersion = "2026-10"
+	setting.ContentPolicyFlags = []string{"pii", "minors"}
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	SetProvenanceHeader(c)
+	require.Empty(t, w.Header().Get(ProvenanceHeader))
+	require.Equal(t, `{"a":1}`, string(ApplyProvenanceToResponsesBody([]byte(`{"a":1}`))))
+
+	setting.HeaderEnabled = true
+	setting.MetadataEnabled = true
+	SetProvenanceHeader(c)
+	require.Equal(t, "deployment=prod-eu-1; policy_version=2026-10; content_policy_flags=pii,minors", w.Header().Get(ProvenanceHeader))
+
+	metadata := ApplyProvenanceMetadata([]byte(`{"user":"u1"}`))
+	require.JSONEq(t, `{"user":"u1","generated_by_deployment":"prod-eu-1","generated_by_policy_version":"2026-10","generated_by_content_policy_flags":"pii,minors"}`, string(metadata))
+
+	body := ApplyProvenanceToResponsesBody([]byte(`{"id":"resp_1","metadata":null}`))
+	require.JSONEq(t, `{"id":"resp_1","metadata":{"generated_by_deployment":"prod-eu-1","generated_by_policy_version":"2026-10","generated_by_content_policy_flags":"pii,minors"}}`, string(body))
+}
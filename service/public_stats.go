@@ -0,0 +1,249 @@
+package service
+
+import (
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"sort"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+)
+
+// PublicLatencyBucket 延迟分布中的一个区间，Share 为加噪后该区间请求所占比例
+type PublicLatencyBucket struct {
+	Label string  `json:"label"`
+	Share float64 `json:"share"`
+}
+
+// PublicModelStats 单个模型的公开统计，请求数为限制单用户贡献并加噪后的估计值
+type PublicModelStats struct {
+	Model    string                `json:"model"`
+	Requests int64                 `json:"requests"`
+	Share    float64               `json:"share"`
+	Latency  []PublicLatencyBucket `json:"latency"`
+}
+
+// PublicStats 对外发布的用量统计，统计窗口为 [WindowStart, WindowEnd)。
+// Epsilon 为每个用户在本次发布中消耗的总隐私预算，平均分给该用户计入的至多 MaxModelsPerUser 个模型，
+// 每个模型的预算为 ModelEpsilon；窗口互不重叠，每条消费日志只参与一次发布
+type PublicStats struct {
+	GeneratedAt      int64              `json:"generated_at"`
+	WindowStart      int64              `json:"window_start"`
+	WindowEnd        int64              `json:"window_end"`
+	WindowDays       int                `json:"window_days"`
+	Epsilon          float64            `json:"epsilon"`
+	ModelEpsilon     float64            `json:"model_epsilon"`
+	MaxModelsPerUser int                `json:"max_models_per_user"`
+	MinUsers         int                `json:"min_users"`
+	Models           []PublicModelStats `json:"models"`
+}
+
+// PublicStatsWindow 返回 now 之前最近一个已结束的统计窗口 [start, end)，窗口按天数对齐且互不重叠
+func PublicStatsWindow(windowDays int, now time.Time) (int64, int64) {
+	windowSeconds := int64(windowDays) * 24 * 3600
+	end := now.Unix() / windowSeconds * windowSeconds
+	return end - windowSeconds, end
+}
+
+// GetPublicStats 返回最近一个已结束窗口的公开统计。每个窗口只生成一次并保存在数据库中，
+// 之后所有节点都读取这份结果，反复请求无法通过多次采样平均掉噪声
+func GetPublicStats(setting *operation_setting.PublicStatsSetting) (*PublicStats, error) {
+	windowDays := setting.WindowDays
+	if windowDays <= 0 {
+		windowDays = 30
+	}
+	start, end := PublicStatsWindow(windowDays, time.Now())
+	release, err := model.GetPublicStatsRelease(start)
+	if err != nil {
+		return nil, err
+	}
+	if release == nil {
+		counts, err := model.GetModelUserLatencyCounts(start, end)
+		if err != nil {
+			return nil, err
+		}
+		stats := aggregatePublicStats(counts, setting, rand.Float64)
+		stats.WindowStart, stats.WindowEnd, stats.WindowDays = start, end, windowDays
+		data, err := common.Marshal(stats)
+		if err != nil {
+			return nil, err
+		}
+		release, err = model.SavePublicStatsRelease(&model.PublicStatsRelease{
+			WindowStart: start,
+			WindowEnd:   end,
+			Data:        string(data),
+			CreatedAt:   stats.GeneratedAt,
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	var stats PublicStats
+	if err := common.UnmarshalJsonStr(release.Data, &stats); err != nil {
+		return nil, err
+	}
+	return &stats, nil
+}
+
+// aggregatePublicStats 对每个用户只计入请求最多的 MaxModelsPerUser 个模型；对每个模型：单个用户最多计入
+// ContributionCap 个请求（超出时各延迟区间按比例缩减），再分别对使用人数、请求数与各延迟区间加入拉普拉斯噪声；
+// 加噪后使用人数不足 MinUsers 的模型不发布
+func aggregatePublicStats(counts []model.ModelUserLatencyCount, setting *operation_setting.PublicStatsSetting, uniform func() float64) *PublicStats {
+	bucketCount := len(model.PublicStatsLatencyBounds) + 1
+	perModel := make(map[string]map[int][]int64)
+	for _, count := range counts {
+		if count.Bucket < 0 || count.Bucket >= bucketCount {
+			continue
+		}
+		users, ok := perModel[count.ModelName]
+		if !ok {
+			users = make(map[int][]int64)
+			perModel[count.ModelName] = users
+		}
+		if users[count.UserId] == nil {
+			users[count.UserId] = make([]int64, bucketCount)
+		}
+		users[count.UserId][count.Bucket] += count.Count
+	}
+	maxModelsPerUser := max(setting.MaxModelsPerUser, 1)
+	limitModelsPerUser(perModel, maxModelsPerUser)
+
+	epsilon := setting.Epsilon
+	if epsilon <= 0 {
+		epsilon = 1
+	}
+	contributionCap := float64(max(setting.ContributionCap, 1))
+	// 每个用户至多出现在 maxModelsPerUser 个模型中，按组合定理每个模型分得 epsilon / maxModelsPerUser，
+	// 再平均分给使用人数、请求数与延迟分布
+	modelEpsilon := epsilon / float64(maxModelsPerUser)
+	userScale := 3 / modelEpsilon
+	requestScale := 3 * contributionCap / modelEpsilon
+
+	stats := &PublicStats{
+		GeneratedAt:      time.Now().Unix(),
+		Epsilon:          epsilon,
+		ModelEpsilon:     modelEpsilon,
+		MaxModelsPerUser: maxModelsPerUser,
+		MinUsers:         setting.MinUsers,
+		Models:           make([]PublicModelStats, 0),
+	}
+	var totalRequests int64
+	for modelName, users := range perModel {
+		noisyUsers := float64(len(users)) + laplaceNoise(userScale, uniform)
+		if noisyUsers < float64(setting.MinUsers) {
+			continue
+		}
+		var requests float64
+		buckets := make([]float64, bucketCount)
+		for _, userBuckets := range users {
+			var total int64
+			for _, n := range userBuckets {
+				total += n
+			}
+			if total == 0 {
+				continue
+			}
+			weight := math.Min(float64(total), contributionCap) / float64(total)
+			requests += float64(total) * weight
+			for i, n := range userBuckets {
+				buckets[i] += float64(n) * weight
+			}
+		}
+		noisyRequests := int64(math.Max(math.Round(requests+laplaceNoise(requestScale, uniform)), 0))
+		var bucketTotal float64
+		for i := range buckets {
+			buckets[i] = math.Max(buckets[i]+laplaceNoise(requestScale, uniform), 0)
+			bucketTotal += buckets[i]
+		}
+		latency := make([]PublicLatencyBucket, bucketCount)
+		for i := range buckets {
+			latency[i].Label = publicLatencyLabel(i)
+			if bucketTotal > 0 {
+				latency[i].Share = roundShare(buckets[i] / bucketTotal)
+			}
+		}
+		stats.Models = append(stats.Models, PublicModelStats{
+			Model:    modelName,
+			Requests: noisyRequests,
+			Latency:  latency,
+		})
+		totalRequests += noisyRequests
+	}
+	for i := range stats.Models {
+		if totalRequests > 0 {
+			stats.Models[i].Share = roundShare(float64(stats.Models[i].Requests) / float64(totalRequests))
+		}
+	}
+	sort.Slice(stats.Models, func(i, j int) bool {
+		if stats.Models[i].Requests != stats.Models[j].Requests {
+			return stats.Models[i].Requests > stats.Models[j].Requests
+		}
+		return stats.Models[i].Model < stats.Models[j].Model
+	})
+	return stats
+}
+
+// limitModelsPerUser 每个用户只保留请求最多的 limit 个模型，请求数相同时按模型名称保留
+func limitModelsPerUser(perModel map[string]map[int][]int64, limit int) {
+	type userModel struct {
+		model string
+		total int64
+	}
+	perUser := make(map[int][]userModel)
+	for modelName, users := range perModel {
+		for userId, buckets := range users {
+			var total int64
+			for _, n := range buckets {
+				total += n
+			}
+			perUser[userId] = append(perUser[userId], userModel{model: modelName, total: total})
+		}
+	}
+	for userId, models := range perUser {
+		if len(models) <= limit {
+			continue
+		}
+		sort.Slice(models, func(i, j int) bool {
+			if models[i].total != models[j].total {
+				return models[i].total > models[j].total
+			}
+			return models[i].model < models[j].model
+		})
+		for _, dropped := range models[limit:] {
+			delete(perModel[dropped.model], userId)
+			if len(perModel[dropped.model]) == 0 {
+				delete(perModel, dropped.model)
+			}
+		}
+	}
+}
+
+// laplaceNoise 按逆变换采样生成尺度为 scale 的拉普拉斯噪声
+func laplaceNoise(scale float64, uniform func() float64) float64 {
+	u := uniform() - 0.5
+	for u == -0.5 {
+		u = uniform() - 0.5
+	}
+	if u < 0 {
+		return scale * math.Log(1+2*u)
+	}
+	return -scale * math.Log(1-2*u)
+}
+
+func publicLatencyLabel(bucket int) string {
+	bounds := model.PublicStatsLatencyBounds
+	if bucket == 0 {
+		return fmt.Sprintf("<%ds", bounds[0])
+	}
+	if bucket >= len(bounds) {
+		return fmt.Sprintf(">=%ds", bounds[len(bounds)-1])
+	}
+	return fmt.Sprintf("%d-%ds", bounds[bucket-1], bounds[bucket])
+}
+
+func roundShare(share float64) float64 {
+	return math.Round(share*10000) / 10000
+}
@@ -0,0 +1,80 @@
+package service
+
+import (
+	"math"
+	"math/rand/v2"
+	"testing"
+	"time"
+
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLaplaceNoise(t *testing.T) {
+	rng := rand.New(rand.NewPCG(1, 2))
+	var sum, absSum float64
+	const n = 20000
+	for i := 0; i < n; i++ {
+		noise := laplaceNoise(2, rng.Float64)
+		sum += noise
+		absSum += math.Abs(noise)
+	}
+	// 拉普拉斯分布均值为 0，绝对值的均值等于尺度
+	assert.InDelta(t, 0, sum/n, 0.1)
+	assert.InDelta(t, 2, absSum/n, 0.1)
+}
+
+func TestAggregatePublicStats(t *testing.T) {
+	setting := &operation_setting.PublicStatsSetting{MinUsers: 3, Epsilon: 2, ContributionCap: 10, MaxModelsPerUser: 2}
+	var counts []model.ModelUserLatencyCount
+	for userId := 1; userId <= 5; userId++ {
+		counts = append(counts, model.ModelUserLatencyCount{ModelName: "popular", UserId: userId, Bucket: 0, Count: 4})
+	}
+	// 单个用户的大量请求只计入 ContributionCap 个，各延迟区间按比例缩减
+	counts = append(counts,
+		model.ModelUserLatencyCount{ModelName: "popular", UserId: 6, Bucket: 2, Count: 300},
+		model.ModelUserLatencyCount{ModelName: "popular", UserId: 6, Bucket: 3, Count: 100},
+		model.ModelUserLatencyCount{ModelName: "private", UserId: 1, Bucket: 1, Count: 1000},
+	)
+
+	// 均匀分布固定取 0.5 时噪声为 0
+	stats := aggregatePublicStats(counts, setting, func() float64 { return 0.5 })
+	require.Len(t, stats.Models, 1)
+	popular := stats.Models[0]
+	assert.Equal(t, "popular", popular.Model)
+	assert.EqualValues(t, 30, popular.Requests)
+	assert.Equal(t, 1.0, popular.Share)
+	require.Len(t, popular.Latency, len(model.PublicStatsLatencyBounds)+1)
+	assert.Equal(t, "<1s", popular.Latency[0].Label)
+	assert.Equal(t, "2-5s", popular.Latency[2].Label)
+	assert.Equal(t, ">=60s", popular.Latency[6].Label)
+	assert.InDelta(t, 20.0/30, popular.Latency[0].Share, 0.0001)
+	assert.InDelta(t, 7.5/30, popular.Latency[2].Share, 0.0001)
+	assert.InDelta(t, 2.5/30, popular.Latency[3].Share, 0.0001)
+}
+
+func TestLimitModelsPerUser(t *testing.T) {
+	perModel := map[string]map[int][]int64{
+		"a": {1: {5}, 2: {1}},
+		"b": {1: {9}},
+		"c": {1: {1}, 2: {3}},
+	}
+	limitModelsPerUser(perModel, 1)
+	// 用户 1 只保留请求最多的 b，用户 2 只保留 c
+	assert.NotContains(t, perModel, "a")
+	assert.Equal(t, map[int][]int64{1: {9}}, perModel["b"])
+	assert.Equal(t, map[int][]int64{2: {3}}, perModel["c"])
+}
+
+func TestPublicStatsWindow(t *testing.T) {
+	now := time.Unix(10*24*3600+123, 0)
+	start, end := PublicStatsWindow(7, now)
+	assert.EqualValues(t, 7*24*3600, end)
+	assert.EqualValues(t, 0, start)
+	// 同一窗口内的不同时刻得到相同的窗口
+	start2, end2 := PublicStatsWindow(7, now.Add(time.Hour))
+	assert.Equal(t, start, start2)
+	assert.Equal(t, end, end2)
+}
@@ -32,6 +32,9 @@ type TokenDetails struct {
 type QuotaInfo struct {
 	InputDetails  TokenDetails
 	OutputDetails TokenDetails
+	// CachedDetails 为输入中命中缓存的部分（已包含在 InputDetails 中），按 CacheRatio 折算
+	CachedDetails TokenDetails
+	CacheRatio    float64
 	ModelName     string
 	UsePrice      bool
 	ModelPrice    float64
@@ -70,6 +73,12 @@ func calculateAudioQuota(info QuotaInfo) int {
 	inputAudioTokens := decimal.NewFromInt(int64(info.InputDetails.AudioTokens))
 	outputAudioTokens := decimal.NewFromInt(int64(info.OutputDetails.AudioTokens))
 
+	cacheRatio := decimal.NewFromFloat(info.CacheRatio)
+	cachedTextTokens := decimal.NewFromInt(int64(min(info.CachedDetails.TextTokens, info.InputDetails.TextTokens)))
+	cachedAudioTokens := decimal.NewFromInt(int64(min(info.CachedDetails.AudioTokens, info.InputDetails.AudioTokens)))
+	inputTextTokens = inputTextTokens.Sub(cachedTextTokens).Add(cachedTextTokens.Mul(cacheRatio))
+	inputAudioTokens = inputAudioTokens.Sub(cachedAudioTokens).Add(cachedAudioTokens.Mul(cacheRatio))
+
 	quota := decimal.Zero
 	quota = quota.Add(inputTextTokens)
 	quota = quota.Add(outputTextTokens.Mul(completionRatio))
@@ -86,15 +95,22 @@ func calculateAudioQuota(info QuotaInfo) int {
 	return int(quota.Round(0).IntPart())
 }
 
+// realtimeCachedDetails 拆分实时会话输入中命中缓存的文本与音频 token，上游未给出拆分时全部计入文本
+func realtimeCachedDetails(details dto.RealtimeInputTokenDetails) TokenDetails {
+	cached := TokenDetails{
+		TextTokens:  details.CachedTokensDetails.TextTokens,
+		AudioTokens: details.CachedTokensDetails.AudioTokens,
+	}
+	if cached.TextTokens == 0 && cached.AudioTokens == 0 {
+		cached.TextTokens = details.CachedTokens
+	}
+	return cached
+}
+
 func PreWssConsumeQuota(ctx *gin.Context, relayInfo *relaycommon.RelayInfo, usage *dto.RealtimeUsage) error {
 	if relayInfo.UsePrice {
 		return nil
 	}
-	userQuota, err := model.GetUserQuota(relayInfo.UserId, false)
-	if err != nil {
-		return err
-	}
-
 	token, err := model.GetTokenByKey(strings.TrimPrefix(relayInfo.TokenKey, "sk-"), false)
 	if err != nil {
 		return err
@@ -107,6 +123,7 @@ func PreWssConsumeQuota(ctx *gin.Context, relayInfo *relaycommon.RelayInfo, usag
 	audioOutTokens := usage.OutputTokenDetails.AudioTokens
 	groupRatio := ratio_setting.GetGroupRatio(relayInfo.UsingGroup)
 	modelRatio, _, _ := ratio_setting.GetModelRatio(modelName)
+	cacheRatio, _ := ratio_setting.GetCacheRatio(modelName)
 
 	autoGroup, exists := common.GetContextKey(ctx, constant.ContextKeyAutoGroup)
 	if exists {
@@ -130,15 +147,21 @@ func PreWssConsumeQuota(ctx *gin.Context, relayInfo *relaycommon.RelayInfo, usag
 			TextTokens:  textOutTokens,
 			AudioTokens: audioOutTokens,
 		},
-		ModelName:  modelName,
-		UsePrice:   relayInfo.UsePrice,
-		ModelRatio: modelRatio,
-		GroupRatio: actualGroupRatio,
+		CachedDetails: realtimeCachedDetails(usage.InputTokenDetails),
+		CacheRatio:    cacheRatio,
+		ModelName:     modelName,
+		UsePrice:      relayInfo.UsePrice,
+		ModelRatio:    modelRatio,
+		GroupRatio:    actualGroupRatio,
 	}
 
 	quota := calculateAudioQuota(quotaInfo)
 
-	if userQuota < quota {
+	userQuota, available, err := model.GetUserAvailableQuota(relayInfo.UserId, quota)
+	if err != nil {
+		return err
+	}
+	if available < quota {
 		return fmt.Errorf("user quota is not enough, user quota: %s, need quota: %s", logger.FormatQuota(userQuota), logger.FormatQuota(quota))
 	}
 
@@ -168,6 +191,8 @@ func PostWssConsumeQuota(ctx *gin.Context, relayInfo *relaycommon.RelayInfo, mod
 	completionRatio := decimal.NewFromFloat(ratio_setting.GetCompletionRatio(modelName))
 	audioRatio := decimal.NewFromFloat(ratio_setting.GetAudioRatio(relayInfo.OriginModelName))
 	audioCompletionRatio := decimal.NewFromFloat(ratio_setting.GetAudioCompletionRatio(modelName))
+	cacheRatio := relayInfo.PriceData.CacheRatio
+	cachedDetails := realtimeCachedDetails(usage.InputTokenDetails)
 
 	modelRatio := relayInfo.PriceData.ModelRatio
 	groupRatio := relayInfo.PriceData.GroupRatioInfo.GroupRatio
@@ -183,10 +208,12 @@ func PostWssConsumeQuota(ctx *gin.Context, relayInfo *relaycommon.RelayInfo, mod
 			TextTokens:  textOutTokens,
 			AudioTokens: audioOutTokens,
 		},
-		ModelName:  modelName,
-		UsePrice:   usePrice,
-		ModelRatio: modelRatio,
-		GroupRatio: groupRatio,
+		CachedDetails: cachedDetails,
+		CacheRatio:    cacheRatio,
+		ModelName:     modelName,
+		UsePrice:      usePrice,
+		ModelRatio:    modelRatio,
+		GroupRatio:    groupRatio,
 	}
 
 	quota := calculateAudioQuota(quotaInfo)
@@ -196,6 +223,9 @@ func PostWssConsumeQuota(ctx *gin.Context, relayInfo *relaycommon.RelayInfo, mod
 	if !usePrice {
 		logContent = fmt.Sprintf("模型倍率 %.2f，补全倍率 %.2f，音频倍率 %.2f，音频补全倍率 %.2f，分组倍率 %.2f",
 			modelRatio, completionRatio.InexactFloat64(), audioRatio.InexactFloat64(), audioCompletionRatio.InexactFloat64(), groupRatio)
+		if cachedDetails.TextTokens+cachedDetails.AudioTokens > 0 {
+			logContent += fmt.Sprintf("，缓存倍率 %.2f", cacheRatio)
+		}
 	} else {
 		logContent = fmt.Sprintf("模型价格 %.2f，分组倍率 %.2f", modelPrice, groupRatio)
 	}
@@ -218,7 +248,7 @@ func PostWssConsumeQuota(ctx *gin.Context, relayInfo *relaycommon.RelayInfo, mod
 		logContent += ", " + extraContent
 	}
 	other := GenerateWssOtherInfo(ctx, relayInfo, usage, modelRatio, groupRatio,
-		completionRatio.InexactFloat64(), audioRatio.InexactFloat64(), audioCompletionRatio.InexactFloat64(), modelPrice, relayInfo.PriceData.GroupRatioInfo.GroupSpecialRatio)
+		completionRatio.InexactFloat64(), audioRatio.InexactFloat64(), audioCompletionRatio.InexactFloat64(), cacheRatio, modelPrice, relayInfo.PriceData.GroupRatioInfo.GroupSpecialRatio)
 	model.RecordConsumeLog(ctx, relayInfo.UserId, model.RecordConsumeLogParams{
 		ChannelId:        relayInfo.ChannelId,
 		PromptTokens:     usage.InputTokens,
@@ -310,7 +340,7 @@ func PostClaudeConsumeQuota(ctx *gin.Context, relayInfo *relaycommon.RelayInfo,
 		model.UpdateChannelUsedQuota(relayInfo.ChannelId, quota)
 	}
 
-	if err := SettleBilling(ctx, relayInfo, quota); err != nil {
+	if err := SettleUsageBilling(ctx, relayInfo, quota, usage); err != nil {
 		logger.LogError(ctx, "error settling billing: "+err.Error())
 	}
 
@@ -416,7 +446,7 @@ func PostAudioConsumeQuota(ctx *gin.Context, relayInfo *relaycommon.RelayInfo, u
 		model.UpdateChannelUsedQuota(relayInfo.ChannelId, quota)
 	}
 
-	if err := SettleBilling(ctx, relayInfo, quota); err != nil {
+	if err := SettleUsageBilling(ctx, relayInfo, quota, usage); err != nil {
 		logger.LogError(ctx, "error settling billing: "+err.Error())
 	}
 
@@ -442,6 +472,17 @@ func PostAudioConsumeQuota(ctx *gin.Context, relayInfo *relaycommon.RelayInfo, u
 	})
 }
 
+// TokenQuotaInsufficientError is returned by PreConsumeTokenQuota when the token
+// does not have enough remaining quota.
+type TokenQuotaInsufficientError struct {
+	Remain int
+	Need   int
+}
+
+func (e *TokenQuotaInsufficientError) Error() string {
+	return fmt.Sprintf("token quota is not enough, token remain quota: %s, need quota: %s", logger.FormatQuota(e.Remain), logger.FormatQuota(e.Need))
+}
+
 func PreConsumeTokenQuota(relayInfo *relaycommon.RelayInfo, quota int) error {
 	if quota < 0 {
 		return errors.New("quota 不能为负数！")
@@ -457,7 +498,7 @@ func PreConsumeTokenQuota(relayInfo *relaycommon.RelayInfo, quota int) error {
 		return err
 	}
 	if !relayInfo.TokenUnlimited && token.RemainQuota < quota {
-		return fmt.Errorf("token quota is not enough, token remain quota: %s, need quota: %s", logger.FormatQuota(token.RemainQuota), logger.FormatQuota(quota))
+		return &TokenQuotaInsufficientError{Remain: token.RemainQuota, Need: quota}
 	}
 	err = model.DecreaseTokenQuota(relayInfo.TokenId, relayInfo.TokenKey, quota)
 	if err != nil {
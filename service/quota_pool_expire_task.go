@@ -0,0 +1,68 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/logger"
+	"github.com/QuantumNous/new-api/model"
+
+	"github.com/bytedance/gopkg/util/gopool"
+)
+
+const (
+	quotaPoolExpireTickInterval = 1 * time.Minute
+	quotaPoolExpireBatchSize    = 300
+)
+
+var (
+	quotaPoolExpireOnce    sync.Once
+	quotaPoolExpireRunning atomic.Bool
+)
+
+// StartQuotaPoolExpireTask 在主节点定期处理到期的额度池，从余额中扣除其剩余额度
+func StartQuotaPoolExpireTask() {
+	quotaPoolExpireOnce.Do(func() {
+		if !common.IsMasterNode {
+			return
+		}
+		gopool.Go(func() {
+			logger.LogInfo(context.Background(), fmt.Sprintf("quota pool expire task started: tick=%s", quotaPoolExpireTickInterval))
+			ticker := time.NewTicker(quotaPoolExpireTickInterval)
+			defer ticker.Stop()
+
+			runQuotaPoolExpireOnce()
+			for range ticker.C {
+				runQuotaPoolExpireOnce()
+			}
+		})
+	})
+}
+
+func runQuotaPoolExpireOnce() {
+	if !quotaPoolExpireRunning.CompareAndSwap(false, true) {
+		return
+	}
+	defer quotaPoolExpireRunning.Store(false)
+
+	ctx := context.Background()
+	totalExpired := 0
+	for {
+		n, err := model.ExpireQuotaPools(quotaPoolExpireBatchSize)
+		if err != nil {
+			logger.LogWarn(ctx, fmt.Sprintf("quota pool expire task failed: %v", err))
+			return
+		}
+		totalExpired += n
+		if n < quotaPoolExpireBatchSize {
+			break
+		}
+	}
+	if common.DebugEnabled && totalExpired > 0 {
+		logger.LogDebug(ctx, "quota pool expire: expired_count=%d", totalExpired)
+	}
+}
@@ -0,0 +1,145 @@
+package service
+
+import (
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/logger"
+	"github.com/QuantumNous/new-api/model"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	"github.com/QuantumNous/new-api/setting/model_setting"
+	"github.com/QuantumNous/new-api/types"
+
+	"github.com/gin-gonic/gin"
+	"github.com/shopspring/decimal"
+)
+
+var (
+	transcriptionSessionsLock sync.Mutex
+	transcriptionSessions     = make(map[int]int)
+)
+
+// AcquireTranscriptionSession 占用一个用户实时转写会话名额，超过上限时返回 false
+func AcquireTranscriptionSession(userId int) bool {
+	limit := model_setting.GetRealtimeTranscriptionSettings().MaxConcurrentSessionsPerUser
+	transcriptionSessionsLock.Lock()
+	defer transcriptionSessionsLock.Unlock()
+	if limit > 0 && transcriptionSessions[userId] >= limit {
+		return false
+	}
+	transcriptionSessions[userId]++
+	return true
+}
+
+// ReleaseTranscriptionSession 释放 AcquireTranscriptionSession 占用的名额
+func ReleaseTranscriptionSession(userId int) {
+	transcriptionSessionsLock.Lock()
+	defer transcriptionSessionsLock.Unlock()
+	if transcriptionSessions[userId] <= 1 {
+		delete(transcriptionSessions, userId)
+		return
+	}
+	transcriptionSessions[userId]--
+}
+
+// TranscriptionQuotaPerSecond 返回每秒音频对应的额度（已乘分组倍率）
+func TranscriptionQuotaPerSecond(modelName string, groupRatio float64) decimal.Decimal {
+	pricePerMinute := model_setting.GetRealtimeTranscriptionSettings().GetPricePerMinute(modelName)
+	return perMinuteQuotaPerSecond(pricePerMinute, groupRatio)
+}
+
+// CalculateTranscriptionQuota 按秒计费，不足一秒按一秒计
+func CalculateTranscriptionQuota(modelName string, groupRatio float64, seconds float64) (billedSeconds int, quota int) {
+	pricePerMinute := model_setting.GetRealtimeTranscriptionSettings().GetPricePerMinute(modelName)
+	return calculatePerMinuteQuota(pricePerMinute, groupRatio, seconds)
+}
+
+func perMinuteQuotaPerSecond(pricePerMinute float64, groupRatio float64) decimal.Decimal {
+	return decimal.NewFromFloat(pricePerMinute).
+		Mul(decimal.NewFromFloat(common.QuotaPerUnit)).
+		Mul(decimal.NewFromFloat(groupRatio)).
+		Div(decimal.NewFromInt(60))
+}
+
+// calculatePerMinuteQuota 按每分钟价格计算音频额度，时长按秒向上取整
+func calculatePerMinuteQuota(pricePerMinute float64, groupRatio float64, seconds float64) (billedSeconds int, quota int) {
+	if seconds <= 0 {
+		return 0, 0
+	}
+	billedSeconds = int(math.Ceil(seconds))
+	quota = int(perMinuteQuotaPerSecond(pricePerMinute, groupRatio).Mul(decimal.NewFromInt(int64(billedSeconds))).Round(0).IntPart())
+	if quota <= 0 && groupRatio > 0 {
+		quota = 1
+	}
+	return billedSeconds, quota
+}
+
+// TranscriptionAffordableSeconds 根据用户与令牌剩余额度计算本次会话最多可转写的秒数
+func TranscriptionAffordableSeconds(c *gin.Context, relayInfo *relaycommon.RelayInfo, groupRatio float64) (int, error) {
+	maxSeconds := model_setting.GetRealtimeTranscriptionSettings().MaxSessionSeconds
+	perSecond := TranscriptionQuotaPerSecond(relayInfo.OriginModelName, groupRatio)
+	if perSecond.LessThanOrEqual(decimal.Zero) {
+		return maxSeconds, nil
+	}
+	// 会话时长按全部可用额度计算，包括后付费授信
+	_, available, err := model.GetUserAvailableQuota(relayInfo.UserId, math.MaxInt)
+	if err != nil {
+		return 0, err
+	}
+	if !relayInfo.TokenUnlimited {
+		available = min(available, c.GetInt("token_quota"))
+	}
+	affordable := int(decimal.NewFromInt(int64(available)).Div(perSecond).IntPart())
+	if maxSeconds > 0 && affordable > maxSeconds {
+		affordable = maxSeconds
+	}
+	return affordable, nil
+}
+
+// PreConsumeTranscriptionQuota 每个会话按可转写的最长时长预扣额度，同一用户的并发会话不会超出余额；
+// 按次模式的令牌同时计入一次请求。预扣记录在 relayInfo.Billing 上，会话结束后按实际时长结算
+func PreConsumeTranscriptionQuota(c *gin.Context, relayInfo *relaycommon.RelayInfo, groupRatio float64, maxSeconds int) *types.NewAPIError {
+	_, quota := CalculateTranscriptionQuota(relayInfo.OriginModelName, groupRatio, float64(maxSeconds))
+	return PreConsumeBilling(c, quota, relayInfo)
+}
+
+// PostTranscriptionConsumeQuota 会话结束后按实际音频时长结算预扣额度并记录日志
+func PostTranscriptionConsumeQuota(c *gin.Context, relayInfo *relaycommon.RelayInfo, groupRatio float64, audioSeconds float64, endReason string) {
+	modelName := relayInfo.OriginModelName
+	billedSeconds, quota := CalculateTranscriptionQuota(modelName, groupRatio, audioSeconds)
+	pricePerMinute := model_setting.GetRealtimeTranscriptionSettings().GetPricePerMinute(modelName)
+
+	if err := SettleBilling(c, relayInfo, quota); err != nil {
+		logger.LogError(c, "error consuming realtime transcription quota: "+err.Error())
+	}
+	if quota > 0 {
+		model.UpdateUserUsedQuotaAndRequestCount(relayInfo.UserId, quota)
+		model.UpdateChannelUsedQuota(relayInfo.ChannelId, quota)
+	}
+
+	other := map[string]interface{}{
+		"realtime_transcription": true,
+		"audio_seconds":          billedSeconds,
+		"price_per_minute":       pricePerMinute,
+		"group_ratio":            groupRatio,
+		"end_reason":             endReason,
+	}
+	if relayInfo.UpstreamModelName != "" && relayInfo.UpstreamModelName != modelName {
+		other["upstream_model_name"] = relayInfo.UpstreamModelName
+	}
+	model.RecordConsumeLog(c, relayInfo.UserId, model.RecordConsumeLogParams{
+		ChannelId:      relayInfo.ChannelId,
+		ModelName:      modelName,
+		TokenName:      c.GetString("token_name"),
+		Quota:          quota,
+		Content:        fmt.Sprintf("实时转写 %d 秒，每分钟价格 %.4f，分组倍率 %.2f", billedSeconds, pricePerMinute, groupRatio),
+		TokenId:        relayInfo.TokenId,
+		UseTimeSeconds: int(time.Since(relayInfo.StartTime).Seconds()),
+		IsStream:       true,
+		Group:          relayInfo.UsingGroup,
+		Other:          other,
+	})
+}
@@ -0,0 +1,273 @@
+package service
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/logger"
+	"github.com/QuantumNous/new-api/model"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	"github.com/QuantumNous/new-api/setting/operation_setting"
+	"github.com/QuantumNous/new-api/types"
+
+	"github.com/bytedance/gopkg/util/gopool"
+	"github.com/gin-gonic/gin"
+)
+
+// 单个阶段对比最多返回的差异条数
+const requestCaptureMaxChanges = 200
+
+var requestCaptureLastCleanup atomic.Int64
+
+// StartRequestCapture 按配置为请求开启载荷捕获，并记录入站原始请求体
+func StartRequestCapture(c *gin.Context, info *relaycommon.RelayInfo) bool {
+	if info == nil || !operation_setting.ShouldCaptureRequest(info.UserId, info.OriginModelName) {
+		return false
+	}
+	info.Capture = relaycommon.NewRequestCapture(operation_setting.GetRequestCaptureSetting().MaxBodyKB << 10)
+	storage, err := common.GetBodyStorage(c)
+	if err != nil {
+		return true
+	}
+	if body, err := storage.Bytes(); err == nil {
+		info.CaptureStage(relaycommon.CaptureStageInbound, body)
+	}
+	return true
+}
+
+// SaveRequestCapture 异步保存请求捕获记录，并顺带清理过期记录
+func SaveRequestCapture(c *gin.Context, info *relaycommon.RelayInfo, apiErr *types.NewAPIError) {
+	if info == nil || info.Capture == nil {
+		return
+	}
+	stages, err := common.Marshal(info.Capture.Stages())
+	if err != nil {
+		logger.LogError(c, "marshal request capture failed: "+err.Error())
+		return
+	}
+	capture := &model.RequestCapture{
+		RequestId:  c.GetString(common.RequestIdKey),
+		UserId:     info.UserId,
+		ModelName:  info.OriginModelName,
+		Path:       c.Request.URL.Path,
+		StatusCode: c.Writer.Status(),
+		Stages:     string(stages),
+		CreatedAt:  common.GetTimestamp(),
+	}
+	if info.ChannelMeta != nil {
+		capture.ChannelId = info.ChannelId
+	}
+	if apiErr != nil {
+		capture.StatusCode = apiErr.StatusCode
+		capture.ErrorMessage = apiErr.Error()
+	} else if capture.StatusCode == 0 {
+		capture.StatusCode = http.StatusOK
+	}
+	gopool.Go(func() {
+		if err := model.CreateRequestCapture(capture); err != nil {
+			common.SysLog("save request capture failed: " + err.Error())
+		}
+		cleanupRequestCaptures()
+	})
+}
+
+// cleanupRequestCaptures 每小时最多执行一次过期记录清理
+func cleanupRequestCaptures() {
+	now := time.Now().Unix()
+	last := requestCaptureLastCleanup.Load()
+	if now-last < 3600 || !requestCaptureLastCleanup.CompareAndSwap(last, now) {
+		return
+	}
+	retention := operation_setting.GetRequestCaptureSetting().RetentionHours
+	if retention <= 0 {
+		return
+	}
+	if _, err := model.DeleteRequestCapturesBefore(now - int64(retention)*3600); err != nil {
+		common.SysLog("cleanup request captures failed: " + err.Error())
+	}
+}
+
+// PayloadChange 两个阶段载荷之间的一处差异，Path 为 JSON Pointer
+type PayloadChange struct {
+	Path string `json:"path"`
+	Op   string `json:"op"`
+	From any    `json:"from,omitempty"`
+	To   any    `json:"to,omitempty"`
+}
+
+// CaptureStageDiff 相邻两个请求阶段之间的差异
+type CaptureStageDiff struct {
+	Attempt   int             `json:"attempt"`
+	From      int             `json:"from"`
+	To        int             `json:"to"`
+	FromName  string          `json:"from_name"`
+	ToName    string          `json:"to_name"`
+	Changes   []PayloadChange `json:"changes"`
+	Truncated bool            `json:"truncated,omitempty"`
+	// 任一阶段载荷被截断或不是 JSON 时无法对比
+	Error string `json:"error,omitempty"`
+}
+
+// RequestCaptureDetail 管理员查看的捕获详情
+type RequestCaptureDetail struct {
+	*model.RequestCapture
+	Stages []relaycommon.CaptureStage `json:"stages"`
+	Diffs  []CaptureStageDiff         `json:"diffs"`
+}
+
+// GetRequestCaptureDetail 获取请求的捕获记录及相邻请求阶段的差异，不存在时返回 nil
+func GetRequestCaptureDetail(requestId string) (*RequestCaptureDetail, error) {
+	capture, err := model.GetRequestCaptureByRequestId(requestId)
+	if err != nil || capture == nil {
+		return nil, err
+	}
+	var stages []relaycommon.CaptureStage
+	if err := common.UnmarshalJsonStr(capture.Stages, &stages); err != nil {
+		return nil, fmt.Errorf("invalid request capture stages: %w", err)
+	}
+	return &RequestCaptureDetail{
+		RequestCapture: capture,
+		Stages:         stages,
+		Diffs:          DiffCaptureStages(stages),
+	}, nil
+}
+
+// DiffCaptureStages 对每次尝试，依次对比入站请求、各级转换后的请求与发往上游的请求
+func DiffCaptureStages(stages []relaycommon.CaptureStage) []CaptureStageDiff {
+	inbound := -1
+	attempts := make([]int, 0)
+	chains := make(map[int][]int)
+	for i, stage := range stages {
+		switch {
+		case stage.Name == relaycommon.CaptureStageInbound:
+			inbound = i
+		case stage.Name == relaycommon.CaptureStageUpstreamRequest,
+			strings.HasPrefix(stage.Name, relaycommon.CaptureStageConverted):
+			if _, ok := chains[stage.Attempt]; !ok {
+				attempts = append(attempts, stage.Attempt)
+			}
+			chains[stage.Attempt] = append(chains[stage.Attempt], i)
+		}
+	}
+
+	diffs := make([]CaptureStageDiff, 0)
+	for _, attempt := range attempts {
+		chain := chains[attempt]
+		if inbound >= 0 {
+			chain = append([]int{inbound}, chain...)
+		}
+		for i := 1; i < len(chain); i++ {
+			from, to := stages[chain[i-1]], stages[chain[i]]
+			diff := CaptureStageDiff{
+				Attempt:  attempt,
+				From:     chain[i-1],
+				To:       chain[i],
+				FromName: from.Name,
+				ToName:   to.Name,
+			}
+			if from.Truncated || to.Truncated {
+				diff.Error = "payload truncated"
+			} else if changes, truncated, err := DiffJSONPayloads(from.Body, to.Body, requestCaptureMaxChanges); err != nil {
+				diff.Error = err.Error()
+			} else {
+				diff.Changes = changes
+				diff.Truncated = truncated
+			}
+			diffs = append(diffs, diff)
+		}
+	}
+	return diffs
+}
+
+// DiffJSONPayloads 结构化对比两个 JSON 载荷，最多返回 limit 条差异
+func DiffJSONPayloads(from string, to string, limit int) ([]PayloadChange, bool, error) {
+	var fromValue, toValue any
+	if err := common.UnmarshalJsonStr(from, &fromValue); err != nil {
+		return nil, false, fmt.Errorf("source payload is not json: %w", err)
+	}
+	if err := common.UnmarshalJsonStr(to, &toValue); err != nil {
+		return nil, false, fmt.Errorf("target payload is not json: %w", err)
+	}
+	d := &jsonDiffer{limit: limit, changes: make([]PayloadChange, 0)}
+	d.diff("", fromValue, toValue)
+	return d.changes, d.truncated, nil
+}
+
+type jsonDiffer struct {
+	limit     int
+	changes   []PayloadChange
+	truncated bool
+}
+
+func (d *jsonDiffer) add(change PayloadChange) {
+	if d.limit > 0 && len(d.changes) >= d.limit {
+		d.truncated = true
+		return
+	}
+	d.changes = append(d.changes, change)
+}
+
+func (d *jsonDiffer) diff(path string, from any, to any) {
+	switch fromValue := from.(type) {
+	case map[string]any:
+		toValue, ok := to.(map[string]any)
+		if !ok {
+			break
+		}
+		keys := make([]string, 0, len(fromValue)+len(toValue))
+		for key := range fromValue {
+			keys = append(keys, key)
+		}
+		for key := range toValue {
+			if _, exists := fromValue[key]; !exists {
+				keys = append(keys, key)
+			}
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			childPath := path + "/" + jsonPointerEscape(key)
+			fromChild, inFrom := fromValue[key]
+			toChild, inTo := toValue[key]
+			switch {
+			case !inTo:
+				d.add(PayloadChange{Path: childPath, Op: "removed", From: fromChild})
+			case !inFrom:
+				d.add(PayloadChange{Path: childPath, Op: "added", To: toChild})
+			default:
+				d.diff(childPath, fromChild, toChild)
+			}
+		}
+		return
+	case []any:
+		toValue, ok := to.([]any)
+		if !ok {
+			break
+		}
+		for i := 0; i < max(len(fromValue), len(toValue)); i++ {
+			childPath := path + "/" + strconv.Itoa(i)
+			switch {
+			case i >= len(toValue):
+				d.add(PayloadChange{Path: childPath, Op: "removed", From: fromValue[i]})
+			case i >= len(fromValue):
+				d.add(PayloadChange{Path: childPath, Op: "added", To: toValue[i]})
+			default:
+				d.diff(childPath, fromValue[i], toValue[i])
+			}
+		}
+		return
+	}
+	if !reflect.DeepEqual(from, to) {
+		d.add(PayloadChange{Path: path, Op: "changed", From: from, To: to})
+	}
+}
+
+func jsonPointerEscape(key string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(key, "~", "~0"), "/", "~1")
+}
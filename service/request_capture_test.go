@@ -0,0 +1,52 @@
+package service
+
+import (
+	"testing"
+
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiffJSONPayloads(t *testing.T) {
+	changes, truncated, err := DiffJSONPayloads(
+		`{"model":"gpt-4o","input":"hi","tools":[{"type":"a"},{"type":"b"}],"a/b":1}`,
+		`{"model":"claude","messages":[{"role":"user","content":"hi"}],"tools":[{"type":"a"}],"a/b":1}`,
+		0,
+	)
+	require.NoError(t, err)
+	require.False(t, truncated)
+	require.Equal(t, []PayloadChange{
+		{Path: "/input", Op: "removed", From: "hi"},
+		{Path: "/messages", Op: "added", To: []any{map[string]any{"role": "user", "content": "hi"}}},
+		{Path: "/model", Op: "changed", From: "gpt-4o", To: "claude"},
+		{Path: "/tools/1", Op: "removed", From: map[string]any{"type": "b"}},
+	}, changes)
+
+	changes, truncated, err = DiffJSONPayloads(`{"a":1,"b":2,"c":3}`, `{}`, 2)
+	require.NoError(t, err)
+	require.True(t, truncated)
+	require.Len(t, changes, 2)
+
+	_, _, err = DiffJSONPayloads(`{}`, `data: {}`, 0)
+	require.Error(t, err)
+}
+
+func TestDiffCaptureStages(t *testing.T) {
+	stages := []relaycommon.CaptureStage{
+		{Name: relaycommon.CaptureStageInbound, Body: `{"model":"m","input":"hi"}`},
+		{Name: relaycommon.CaptureStageConverted + ":openai", Body: `{"model":"m","messages":[]}`},
+		{Name: relaycommon.CaptureStageUpstreamRequest, Body: `{"model":"m","messages":[]}`},
+		{Name: relaycommon.CaptureStageUpstreamResponse, Body: `{"id":"x"}`},
+		{Name: relaycommon.CaptureStageUpstreamRequest, Attempt: 1, Body: `{"model":"m2"}`, Truncated: true},
+	}
+	diffs := DiffCaptureStages(stages)
+	require.Len(t, diffs, 3)
+	require.Equal(t, 0, diffs[0].From)
+	require.Equal(t, 1, diffs[0].To)
+	require.Len(t, diffs[0].Changes, 2)
+	require.Empty(t, diffs[1].Changes)
+	require.Equal(t, 1, diffs[2].Attempt)
+	require.Equal(t, 0, diffs[2].From)
+	require.Equal(t, 4, diffs[2].To)
+	require.Equal(t, "payload truncated", diffs[2].Error)
+}
@@ -0,0 +1,170 @@
+package service
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/constant"
+	"github.com/QuantumNous/new-api/logger"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	"github.com/QuantumNous/new-api/types"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 可复用给重复请求的最大响应大小，超出时重复请求自行转发
+const requestDedupMaxBodyBytes = 8 << 20
+
+// requestDedupEntry 去重窗口内第一个请求的执行结果
+type requestDedupEntry struct {
+	done      chan struct{}
+	expiresAt time.Time
+
+	// 以下字段在 done 关闭后只读
+	status     int
+	header     http.Header
+	body       bytes.Buffer
+	replayable bool
+}
+
+var (
+	requestDedupLock    sync.Mutex
+	requestDedupEntries = make(map[string]*requestDedupEntry)
+)
+
+// BeginRequestDedup 对令牌开启了去重窗口的请求进行去重：窗口内同一令牌的相同请求只转发一次，
+// 之后到达的请求等待第一个请求完成并直接复用其响应。
+// 返回 served 为 true 表示响应已写出；finish 非空时需在请求结束、响应写完后调用。
+// 去重仅在当前实例内生效
+func BeginRequestDedup(c *gin.Context, info *relaycommon.RelayInfo) (finish func(), served bool) {
+	window := common.GetContextKeyInt(c, constant.ContextKeyTokenDedupWindow)
+	if window <= 0 || info.RelayFormat == types.RelayFormatOpenAIRealtime {
+		return nil, false
+	}
+	if info.IsStream && common.GetContextKeyBool(c, constant.ContextKeyTokenDedupExemptStream) {
+		return nil, false
+	}
+	key, ok := requestDedupKey(c, info)
+	if !ok {
+		return nil, false
+	}
+
+	now := time.Now()
+	requestDedupLock.Lock()
+	entry, exists := requestDedupEntries[key]
+	if exists && now.Before(entry.expiresAt) {
+		requestDedupLock.Unlock()
+		return nil, serveDuplicateRequest(c, entry)
+	}
+	if exists {
+		// 窗口已过但第一个请求仍在进行，正常转发
+		requestDedupLock.Unlock()
+		return nil, false
+	}
+	entry = &requestDedupEntry{
+		done:      make(chan struct{}),
+		expiresAt: now.Add(time.Duration(window) * time.Second),
+	}
+	requestDedupEntries[key] = entry
+	requestDedupLock.Unlock()
+
+	writer := &requestDedupWriter{ResponseWriter: c.Writer, entry: entry}
+	c.Writer = writer
+	return func() {
+		c.Writer = writer.ResponseWriter
+		entry.status = writer.Status()
+		entry.header = writer.Header().Clone()
+		entry.replayable = !writer.overflow && entry.status >= http.StatusOK && entry.status < http.StatusMultipleChoices
+		close(entry.done)
+
+		// 失败的结果不复用，允许客户端立即重试
+		remaining := time.Until(entry.expiresAt)
+		if !entry.replayable || remaining <= 0 {
+			deleteRequestDedupEntry(key, entry)
+			return
+		}
+		time.AfterFunc(remaining, func() {
+			deleteRequestDedupEntry(key, entry)
+		})
+	}, false
+}
+
+func deleteRequestDedupEntry(key string, entry *requestDedupEntry) {
+	requestDedupLock.Lock()
+	if requestDedupEntries[key] == entry {
+		delete(requestDedupEntries, key)
+	}
+	requestDedupLock.Unlock()
+}
+
+// requestDedupKey 由令牌、请求方法、路径与请求体摘要组成
+func requestDedupKey(c *gin.Context, info *relaycommon.RelayInfo) (string, bool) {
+	storage, err := common.GetBodyStorage(c)
+	if err != nil {
+		return "", false
+	}
+	body, err := storage.Bytes()
+	if err != nil || len(body) == 0 {
+		return "", false
+	}
+	sum := sha256.Sum256(body)
+	return fmt.Sprintf("%d:%s:%s:%s", info.TokenId, c.Request.Method, c.Request.URL.RequestURI(), hex.EncodeToString(sum[:])), true
+}
+
+// serveDuplicateRequest 等待第一个请求完成后写出其响应，第一个请求失败或响应过大时返回 false 以正常转发
+func serveDuplicateRequest(c *gin.Context, entry *requestDedupEntry) bool {
+	select {
+	case <-entry.done:
+	case <-c.Request.Context().Done():
+		return true
+	}
+	if !entry.replayable {
+		return false
+	}
+	for name, values := range entry.header {
+		if name == common.RequestIdKey {
+			continue
+		}
+		c.Writer.Header()[name] = values
+	}
+	c.Writer.Header().Del("Content-Length")
+	c.Status(entry.status)
+	_, _ = c.Writer.Write(entry.body.Bytes())
+	logger.LogInfo(c, "duplicate request served from the in-flight request result")
+	return true
+}
+
+// requestDedupWriter 在写出响应的同时记录响应体，供重复请求复用
+type requestDedupWriter struct {
+	gin.ResponseWriter
+	entry    *requestDedupEntry
+	overflow bool
+}
+
+func (w *requestDedupWriter) Write(data []byte) (int, error) {
+	w.record(data)
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *requestDedupWriter) WriteString(s string) (int, error) {
+	w.record([]byte(s))
+	return w.ResponseWriter.WriteString(s)
+}
+
+func (w *requestDedupWriter) record(data []byte) {
+	if w.overflow {
+		return
+	}
+	if w.entry.body.Len()+len(data) > requestDedupMaxBodyBytes {
+		w.overflow = true
+		w.entry.body.Reset()
+		return
+	}
+	w.entry.body.Write(data)
+}
@@ -0,0 +1,76 @@
+package service
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/constant"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	"github.com/QuantumNous/new-api/types"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+)
+
+func newDedupTestContext(t *testing.T, body string) (*gin.Context, *httptest.ResponseRecorder) {
+	t.Helper()
+	recorder := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(recorder)
+	c.Request = httptest.NewRequest(http.MethodPost, "/v1/chat/completions", nil)
+	storage, err := common.CreateBodyStorage([]byte(body))
+	require.NoError(t, err)
+	c.Set(common.KeyBodyStorage, storage)
+	common.SetContextKey(c, constant.ContextKeyTokenDedupWindow, 5)
+	return c, recorder
+}
+
+func TestRequestDedupServesDuplicateFromFirstRequest(t *testing.T) {
+	info := &relaycommon.RelayInfo{TokenId: 1001, RelayFormat: types.RelayFormatOpenAI}
+	leader, leaderRecorder := newDedupTestContext(t, `{"model":"m","messages":[]}`)
+	finish, served := BeginRequestDedup(leader, info)
+	require.False(t, served)
+	require.NotNil(t, finish)
+
+	follower, followerRecorder := newDedupTestContext(t, `{"model":"m","messages":[]}`)
+	result := make(chan bool)
+	go func() {
+		_, served := BeginRequestDedup(follower, info)
+		result <- served
+	}()
+
+	leader.Header("Content-Type", "application/json")
+	leader.String(http.StatusOK, `{"id":"chatcmpl-1"}`)
+	finish()
+
+	select {
+	case served = <-result:
+	case <-time.After(time.Second):
+		t.Fatal("duplicate request was not released")
+	}
+	require.True(t, served)
+	require.Equal(t, leaderRecorder.Body.String(), followerRecorder.Body.String())
+	require.Equal(t, "application/json", followerRecorder.Header().Get("Content-Type"))
+
+	// 其他请求体不参与去重
+	other, _ := newDedupTestContext(t, `{"model":"m","messages":[{"role":"user","content":"hi"}]}`)
+	otherFinish, served := BeginRequestDedup(other, info)
+	require.False(t, served)
+	require.NotNil(t, otherFinish)
+	otherFinish()
+}
+
+func TestRequestDedupDoesNotReuseFailures(t *testing.T) {
+	info := &relaycommon.RelayInfo{TokenId: 1002, RelayFormat: types.RelayFormatOpenAI}
+	leader, _ := newDedupTestContext(t, `{"model":"m"}`)
+	finish, _ := BeginRequestDedup(leader, info)
+	leader.String(http.StatusBadGateway, `{"error":"upstream"}`)
+	finish()
+
+	retry, _ := newDedupTestContext(t, `{"model":"m"}`)
+	retryFinish, served := BeginRequestDedup(retry, info)
+	require.False(t, served)
+	require.NotNil(t, retryFinish)
+	retryFinish()
+}
@@ -0,0 +1,106 @@
+package service
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/constant"
+	"github.com/QuantumNous/new-api/dto"
+	"github.com/QuantumNous/new-api/logger"
+	"github.com/QuantumNous/new-api/model"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	"github.com/QuantumNous/new-api/setting/model_setting"
+
+	"github.com/gin-gonic/gin"
+	"github.com/shopspring/decimal"
+)
+
+// 一个搜索单元包含的最大文档数
+const rerankDocumentsPerSearchUnit = 100
+
+// RerankPricing 返回重排序请求的每千搜索单元价格，固定按次计价或未配置价格的模型返回 false
+func RerankPricing(relayInfo *relaycommon.RelayInfo) (float64, bool) {
+	if relayInfo.PriceData.UsePrice {
+		return 0, false
+	}
+	return model_setting.GetRerankSettings().GetPricePerThousandSearchUnits(relayInfo.OriginModelName)
+}
+
+// EstimateRerankSearchUnits 按文档数估算搜索单元数，每 100 个文档计一个搜索单元，至少计一个
+func EstimateRerankSearchUnits(documents int) int {
+	if documents <= 0 {
+		return 1
+	}
+	return (documents + rerankDocumentsPerSearchUnit - 1) / rerankDocumentsPerSearchUnit
+}
+
+// RerankSearchUnits 返回本次重排序的搜索单元数，优先使用上游返回的计费单元，否则按文档数估算
+func RerankSearchUnits(c *gin.Context, relayInfo *relaycommon.RelayInfo) int {
+	if value, ok := common.GetContextKey(c, constant.ContextKeyRerankSearchUnits); ok {
+		if units, ok := value.(int); ok && units > 0 {
+			return units
+		}
+	}
+	documents := 0
+	if relayInfo.RerankerInfo != nil {
+		documents = len(relayInfo.Documents)
+	}
+	return EstimateRerankSearchUnits(documents)
+}
+
+// calculatePerSearchUnitQuota 按每千搜索单元价格计算重排序额度
+func calculatePerSearchUnitQuota(pricePerThousand float64, groupRatio float64, searchUnits int) int {
+	if searchUnits <= 0 {
+		return 0
+	}
+	quota := int(decimal.NewFromFloat(pricePerThousand).
+		Mul(decimal.NewFromFloat(common.QuotaPerUnit)).
+		Mul(decimal.NewFromFloat(groupRatio)).
+		Mul(decimal.NewFromInt(int64(searchUnits))).
+		Div(decimal.NewFromInt(1000)).
+		Round(0).IntPart())
+	if quota <= 0 && groupRatio > 0 {
+		quota = 1
+	}
+	return quota
+}
+
+// PostRerankConsumeQuota 重排序按搜索单元结算
+func PostRerankConsumeQuota(ctx *gin.Context, relayInfo *relaycommon.RelayInfo, usage *dto.Usage, price float64, searchUnits int) {
+	groupRatio := relayInfo.PriceData.GroupRatioInfo.GroupRatio
+	quota := calculatePerSearchUnitQuota(price, groupRatio, searchUnits)
+	other := map[string]interface{}{
+		"rerank":                          true,
+		"search_units":                    searchUnits,
+		"price_per_thousand_search_units": price,
+		"group_ratio":                     groupRatio,
+		"user_group_ratio":                relayInfo.PriceData.GroupRatioInfo.GroupSpecialRatio,
+	}
+
+	if quota > 0 {
+		model.UpdateUserUsedQuotaAndRequestCount(relayInfo.UserId, quota)
+		model.UpdateChannelUsedQuota(relayInfo.ChannelId, quota)
+	}
+	if err := SettleBilling(ctx, relayInfo, quota); err != nil {
+		logger.LogError(ctx, "error settling billing: "+err.Error())
+	}
+
+	if relayInfo.UpstreamModelName != "" && relayInfo.UpstreamModelName != relayInfo.OriginModelName {
+		other["upstream_model_name"] = relayInfo.UpstreamModelName
+	}
+	model.RecordConsumeLog(ctx, relayInfo.UserId, model.RecordConsumeLogParams{
+		ChannelId:        relayInfo.ChannelId,
+		PromptTokens:     usage.PromptTokens,
+		CompletionTokens: usage.CompletionTokens,
+		ModelName:        relayInfo.OriginModelName,
+		TokenName:        ctx.GetString("token_name"),
+		Quota:            quota,
+		Content:          fmt.Sprintf("重排序 %d 个搜索单元，每千搜索单元价格 %.4f，分组倍率 %.2f", searchUnits, price, groupRatio),
+		TokenId:          relayInfo.TokenId,
+		UseTimeSeconds:   int(time.Since(relayInfo.StartTime).Seconds()),
+		IsStream:         relayInfo.IsStream,
+		Group:            relayInfo.UsingGroup,
+		Other:            other,
+	})
+}
@@ -0,0 +1,25 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEstimateRerankSearchUnits(t *testing.T) {
+	assert.Equal(t, 1, EstimateRerankSearchUnits(0))
+	assert.Equal(t, 1, EstimateRerankSearchUnits(1))
+	assert.Equal(t, 1, EstimateRerankSearchUnits(100))
+	assert.Equal(t, 2, EstimateRerankSearchUnits(101))
+	assert.Equal(t, 3, EstimateRerankSearchUnits(250))
+}
+
+func TestCalculatePerSearchUnitQuota(t *testing.T) {
+	// 2 美元每千搜索单元，一个搜索单元为 0.002 美元
+	assert.Equal(t, int(0.002*common.QuotaPerUnit), calculatePerSearchUnitQuota(2, 1, 1))
+	assert.Equal(t, int(0.006*common.QuotaPerUnit), calculatePerSearchUnitQuota(2, 1.5, 2))
+	assert.Equal(t, 0, calculatePerSearchUnitQuota(2, 1, 0))
+	// 不足 1 额度时至少计 1
+	assert.Equal(t, 1, calculatePerSearchUnitQuota(0.0001, 1, 1))
+}
@@ -0,0 +1,317 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/constant"
+	"github.com/QuantumNous/new-api/model"
+
+	"github.com/bytedance/gopkg/util/gopool"
+	"github.com/go-redis/redis/v8"
+)
+
+// 后台模式的 Responses 请求（background=true）：网关立即返回 queued 状态的响应对象并保存，
+// 请求在接收它的节点的工作池中执行，状态与结果写回存储，任意节点均可通过 GET /v1/responses/:id 轮询。
+// RESPONSES_BACKGROUND_WORKERS 为每个节点的工作协程数，RESPONSES_BACKGROUND_QUEUE_SIZE 为排队上限，
+// 队列已满时拒绝新的后台请求；节点重启时尚未执行完的任务不会恢复，任务最长执行 RESPONSES_BACKGROUND_TIMEOUT_MINUTES 分钟，
+// 主节点定期将排队或执行中超过该时间的响应标记为失败（Redis 存储依赖过期时间清理）
+const (
+	ResponsesStatusQueued     = "queued"
+	ResponsesStatusInProgress = "in_progress"
+	ResponsesStatusFailed     = "failed"
+	ResponsesStatusCancelled  = "cancelled"
+)
+
+var ErrResponsesBackgroundQueueFull = errors.New("too many background responses are queued, please retry later")
+
+var errBackgroundResponseConflict = errors.New("background response was modified concurrently")
+
+const (
+	// 并发写回冲突时重新读取并应用修改的次数
+	backgroundResponseUpdateAttempts = 5
+
+	responsesBackgroundReapInterval  = 5 * time.Minute
+	responsesBackgroundReapBatchSize = 100
+)
+
+var (
+	responsesBackgroundOnce     sync.Once
+	responsesBackgroundJobs     chan func()
+	responsesBackgroundCancels  sync.Map // 响应 ID -> 本节点执行中任务的 context.CancelFunc
+	responsesBackgroundReapOnce sync.Once
+)
+
+// ResponsesBackgroundTimeout 返回后台响应的最长执行时间
+func ResponsesBackgroundTimeout() time.Duration {
+	return time.Duration(max(constant.ResponsesBackgroundTimeoutMinutes, 1)) * time.Minute
+}
+
+// SubmitResponsesBackgroundJob 将后台任务加入工作池队列，队列已满时返回 ErrResponsesBackgroundQueueFull
+func SubmitResponsesBackgroundJob(job func()) error {
+	responsesBackgroundOnce.Do(startResponsesBackgroundWorkers)
+	select {
+	case responsesBackgroundJobs <- job:
+		return nil
+	default:
+		return ErrResponsesBackgroundQueueFull
+	}
+}
+
+func startResponsesBackgroundWorkers() {
+	responsesBackgroundJobs = make(chan func(), max(constant.ResponsesBackgroundQueueSize, 1))
+	for i := 0; i < max(constant.ResponsesBackgroundWorkers, 1); i++ {
+		go func() {
+			for job := range responsesBackgroundJobs {
+				job()
+			}
+		}()
+	}
+}
+
+// RegisterResponsesBackgroundCancel 记录本节点执行中的后台任务，供取消、删除时中止，返回的函数在任务结束时调用
+func RegisterResponsesBackgroundCancel(responseId string, cancel context.CancelFunc) func() {
+	responsesBackgroundCancels.Store(responseId, cancel)
+	return func() {
+		responsesBackgroundCancels.Delete(responseId)
+	}
+}
+
+// StopResponsesBackgroundJob 中止本节点上执行中的后台任务，任务不在本节点时不做任何事
+func StopResponsesBackgroundJob(responseId string) {
+	if cancel, ok := responsesBackgroundCancels.Load(responseId); ok {
+		cancel.(context.CancelFunc)()
+	}
+}
+
+// CreateBackgroundResponse 同步保存后台响应的初始响应对象，返回后即可查询
+func CreateBackgroundResponse(userId int, modelName string, responseId string, response []byte) error {
+	if ResponsesStoreBackend() == ResponsesStoreOff {
+		return errors.New("background mode requires the responses store, which is disabled")
+	}
+	return createStoredResponse(&model.StoredResponse{
+		ResponseId: responseId,
+		UserId:     userId,
+		ModelName:  modelName,
+		Background: true,
+		Status:     ResponsesStatusQueued,
+		Response:   string(response),
+	})
+}
+
+// UpdateBackgroundResponse 以 update 修改后台响应记录并写回，update 返回 false 时不写回；
+// 记录不存在（已删除或过期）时不调用 update。写回以读取时的状态为条件，期间记录被其他请求修改
+// （例如任务结束时响应已被取消）时重新读取并再次调用 update，因此 update 可能被调用多次
+func UpdateBackgroundResponse(userId int, responseId string, update func(stored *model.StoredResponse) bool) error {
+	if ResponsesStoreBackend() == ResponsesStoreRedis {
+		return updateRedisBackgroundResponse(userId, responseId, update)
+	}
+	for attempt := 0; attempt < backgroundResponseUpdateAttempts; attempt++ {
+		stored, err := FindStoredResponse(userId, responseId)
+		if err != nil || stored == nil || !stored.Background {
+			return err
+		}
+		status := stored.Status
+		if !update(stored) {
+			return nil
+		}
+		updated, err := model.UpdateStoredResponseIfStatus(stored, status)
+		if err != nil || updated {
+			return err
+		}
+	}
+	return errBackgroundResponseConflict
+}
+
+// updateRedisBackgroundResponse 以 WATCH 事务写回 Redis 中的后台响应，记录在读取后被修改时重试
+func updateRedisBackgroundResponse(userId int, responseId string, update func(stored *model.StoredResponse) bool) error {
+	ctx := context.Background()
+	key := responsesStoreRedisKeyPrefix + responseId
+	for attempt := 0; attempt < backgroundResponseUpdateAttempts; attempt++ {
+		err := common.RDB.Watch(ctx, func(tx *redis.Tx) error {
+			value, err := tx.Get(ctx, key).Result()
+			if errors.Is(err, redis.Nil) {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			var stored model.StoredResponse
+			if err := common.UnmarshalJsonStr(value, &stored); err != nil {
+				return err
+			}
+			if stored.UserId != userId || !stored.Background || !update(&stored) {
+				return nil
+			}
+			ttl := time.Until(time.Unix(stored.ExpiresAt, 0))
+			if ttl <= 0 {
+				return nil
+			}
+			data, err := common.Marshal(&stored)
+			if err != nil {
+				return err
+			}
+			_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+				pipe.Set(ctx, key, string(data), ttl)
+				return nil
+			})
+			return err
+		}, key)
+		if !errors.Is(err, redis.TxFailedErr) {
+			return err
+		}
+	}
+	return errBackgroundResponseConflict
+}
+
+// StartBackgroundResponse 将排队中的后台响应标记为 in_progress，响应已取消或已删除时返回 false，任务不再执行
+func StartBackgroundResponse(userId int, responseId string) (bool, error) {
+	started := false
+	err := UpdateBackgroundResponse(userId, responseId, func(stored *model.StoredResponse) bool {
+		response := parseResponseObject(stored.Response)
+		if response["status"] != ResponsesStatusQueued {
+			return false
+		}
+		response["status"] = ResponsesStatusInProgress
+		started = setResponseObject(stored, response)
+		return started
+	})
+	return started && err == nil, err
+}
+
+// FinishBackgroundResponse 写入后台任务的执行结果：成功时为上游返回的响应对象（id 改为后台响应的 id），
+// 失败时为带有 error 的 failed 响应对象；响应已取消或已删除时不写入
+func FinishBackgroundResponse(userId int, responseId string, statusCode int, body []byte) error {
+	return UpdateBackgroundResponse(userId, responseId, func(stored *model.StoredResponse) bool {
+		response := parseResponseObject(stored.Response)
+		if response == nil {
+			response = make(map[string]any)
+		} else if response["status"] == ResponsesStatusCancelled {
+			return false
+		}
+		if result := parseResponseObject(string(body)); statusCode == http.StatusOK && result != nil {
+			response = result
+		} else {
+			response["status"] = ResponsesStatusFailed
+			response["error"] = backgroundResponseError(statusCode, body)
+		}
+		response["id"] = responseId
+		response["background"] = true
+		return setResponseObject(stored, response)
+	})
+}
+
+// CancelBackgroundResponse 取消排队或执行中的后台响应并返回取消后的响应对象，已结束的响应原样返回
+func CancelBackgroundResponse(stored *model.StoredResponse) (string, error) {
+	result := stored.Response
+	err := UpdateBackgroundResponse(stored.UserId, stored.ResponseId, func(current *model.StoredResponse) bool {
+		result = current.Response
+		response := parseResponseObject(current.Response)
+		if response["status"] != ResponsesStatusQueued && response["status"] != ResponsesStatusInProgress {
+			return false
+		}
+		response["status"] = ResponsesStatusCancelled
+		if !setResponseObject(current, response) {
+			return false
+		}
+		result = current.Response
+		return true
+	})
+	if err != nil {
+		return "", err
+	}
+	StopResponsesBackgroundJob(stored.ResponseId)
+	return result, nil
+}
+
+// backgroundResponseError 将中继返回的错误转换为 Responses 响应对象的 error 字段
+func backgroundResponseError(statusCode int, body []byte) map[string]any {
+	var errResp struct {
+		Error struct {
+			Message string `json:"message"`
+			Type    string `json:"type"`
+			Code    any    `json:"code"`
+		} `json:"error"`
+	}
+	_ = common.Unmarshal(body, &errResp)
+	code := common.Interface2String(errResp.Error.Code)
+	if code == "" {
+		code = errResp.Error.Type
+	}
+	if code == "" {
+		code = "server_error"
+	}
+	message := errResp.Error.Message
+	if message == "" {
+		message = http.StatusText(statusCode)
+	}
+	return map[string]any{"code": code, "message": message}
+}
+
+func parseResponseObject(data string) map[string]any {
+	var response map[string]any
+	if err := common.UnmarshalJsonStr(data, &response); err != nil {
+		return nil
+	}
+	return response
+}
+
+func setResponseObject(stored *model.StoredResponse, response map[string]any) bool {
+	data, err := common.Marshal(response)
+	if err != nil {
+		common.SysError("marshal background response failed: " + err.Error())
+		return false
+	}
+	stored.Response = string(data)
+	stored.Status = common.Interface2String(response["status"])
+	return true
+}
+
+// StartResponsesBackgroundReapTask 在主节点定期将排队或执行中超过最长执行时间的后台响应标记为失败，
+// 这些任务已超时中止，或所在节点已重启而不会再写回结果
+func StartResponsesBackgroundReapTask() {
+	responsesBackgroundReapOnce.Do(func() {
+		if !common.IsMasterNode {
+			return
+		}
+		gopool.Go(func() {
+			ticker := time.NewTicker(responsesBackgroundReapInterval)
+			defer ticker.Stop()
+			for range ticker.C {
+				if ResponsesStoreBackend() == ResponsesStoreDB {
+					reapStaleBackgroundResponses(time.Now())
+				}
+			}
+		})
+	})
+}
+
+func reapStaleBackgroundResponses(now time.Time) {
+	before := now.Add(-ResponsesBackgroundTimeout()).Unix()
+	statuses := []string{ResponsesStatusQueued, ResponsesStatusInProgress}
+	stale, err := model.GetStaleBackgroundResponses(statuses, before, responsesBackgroundReapBatchSize)
+	if err != nil {
+		common.SysError("query stale background responses failed: " + err.Error())
+		return
+	}
+	for _, stored := range stale {
+		err := UpdateBackgroundResponse(stored.UserId, stored.ResponseId, func(current *model.StoredResponse) bool {
+			response := parseResponseObject(current.Response)
+			if response == nil {
+				response = map[string]any{"id": current.ResponseId, "background": true}
+			} else if response["status"] != ResponsesStatusQueued && response["status"] != ResponsesStatusInProgress {
+				return false
+			}
+			response["status"] = ResponsesStatusFailed
+			response["error"] = map[string]any{"code": "timeout", "message": "the background response did not finish in time"}
+			return setResponseObject(current, response)
+		})
+		if err != nil {
+			common.SysError("fail stale background response failed: " + err.Error())
+		}
+	}
+}
@@ -0,0 +1,116 @@
+package service
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/QuantumNous/new-api/model"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBackgroundResponseLifecycle(t *testing.T) {
+	t.Cleanup(func() { model.DB.Exec("DELETE FROM stored_responses") })
+	t.Setenv("RESPONSES_STORE", ResponsesStoreDB)
+	queued := []byte(`{"id":"resp_bg1","object":"response","status":"queued","background":true,"model":"gpt-5"}`)
+
+	require.NoError(t, CreateBackgroundResponse(1, "gpt-5", "resp_bg1", queued))
+	started, err := StartBackgroundResponse(1, "resp_bg1")
+	require.NoError(t, err)
+	require.True(t, started)
+	started, err = StartBackgroundResponse(1, "resp_bg1")
+	require.NoError(t, err)
+	require.False(t, started)
+
+	require.NoError(t, FinishBackgroundResponse(1, "resp_bg1", http.StatusOK, []byte(`{"id":"resp_upstream","object":"response","status":"completed","output":[]}`)))
+	stored, err := FindStoredResponse(1, "resp_bg1")
+	require.NoError(t, err)
+	require.JSONEq(t, `{"id":"resp_bg1","object":"response","status":"completed","output":[],"background":true}`, stored.Response)
+
+	// cancelling a finished response returns it unchanged
+	response, err := CancelBackgroundResponse(stored)
+	require.NoError(t, err)
+	require.Equal(t, stored.Response, response)
+
+	require.NoError(t, CreateBackgroundResponse(1, "gpt-5", "resp_bg2", queued))
+	require.NoError(t, FinishBackgroundResponse(1, "resp_bg2", http.StatusTooManyRequests, []byte(`{"error":{"message":"quota exceeded","type":"new_api_error","code":"insufficient_user_quota"}}`)))
+	stored, err = FindStoredResponse(1, "resp_bg2")
+	require.NoError(t, err)
+	require.JSONEq(t, `{"id":"resp_bg2","object":"response","status":"failed","background":true,"model":"gpt-5","error":{"code":"insufficient_user_quota","message":"quota exceeded"}}`, stored.Response)
+}
+
+func TestCancelBackgroundResponse(t *testing.T) {
+	t.Cleanup(func() { model.DB.Exec("DELETE FROM stored_responses") })
+	t.Setenv("RESPONSES_STORE", ResponsesStoreDB)
+	require.NoError(t, CreateBackgroundResponse(1, "gpt-5", "resp_bg3", []byte(`{"id":"resp_bg3","status":"queued"}`)))
+	ctx, cancel := context.WithCancel(context.Background())
+	done := RegisterResponsesBackgroundCancel("resp_bg3", cancel)
+	defer done()
+
+	stored, err := FindStoredResponse(1, "resp_bg3")
+	require.NoError(t, err)
+	response, err := CancelBackgroundResponse(stored)
+	require.NoError(t, err)
+	require.JSONEq(t, `{"id":"resp_bg3","status":"cancelled"}`, response)
+	require.Error(t, ctx.Err())
+
+	// a cancelled response is neither started nor overwritten by the finished job
+	started, err := StartBackgroundResponse(1, "resp_bg3")
+	require.NoError(t, err)
+	require.False(t, started)
+	require.NoError(t, FinishBackgroundResponse(1, "resp_bg3", http.StatusOK, []byte(`{"status":"completed"}`)))
+	stored, err = FindStoredResponse(1, "resp_bg3")
+	require.NoError(t, err)
+	require.JSONEq(t, `{"id":"resp_bg3","status":"cancelled"}`, stored.Response)
+}
+
+func TestReapStaleBackgroundResponses(t *testing.T) {
+	t.Cleanup(func() { model.DB.Exec("DELETE FROM stored_responses") })
+	t.Setenv("RESPONSES_STORE", ResponsesStoreDB)
+	require.NoError(t, CreateBackgroundResponse(1, "gpt-5", "resp_bg4", []byte(`{"id":"resp_bg4","status":"queued"}`)))
+	require.NoError(t, CreateBackgroundResponse(1, "gpt-5", "resp_bg5", []byte(`{"id":"resp_bg5","status":"queued"}`)))
+	require.NoError(t, FinishBackgroundResponse(1, "resp_bg5", http.StatusOK, []byte(`{"status":"completed"}`)))
+
+	// jobs younger than the timeout are left alone
+	reapStaleBackgroundResponses(time.Now())
+	stored, err := FindStoredResponse(1, "resp_bg4")
+	require.NoError(t, err)
+	require.Equal(t, ResponsesStatusQueued, stored.Status)
+
+	reapStaleBackgroundResponses(time.Now().Add(ResponsesBackgroundTimeout() + time.Minute))
+	stored, err = FindStoredResponse(1, "resp_bg4")
+	require.NoError(t, err)
+	require.Equal(t, ResponsesStatusFailed, stored.Status)
+	require.JSONEq(t, `{"id":"resp_bg4","status":"failed","error":{"code":"timeout","message":"the background response did not finish in time"}}`, stored.Response)
+	stored, err = FindStoredResponse(1, "resp_bg5")
+	require.NoError(t, err)
+	require.Equal(t, "completed", stored.Status)
+}
+
+func TestUpdateBackgroundResponseRetriesOnConflict(t *testing.T) {
+	t.Cleanup(func() { model.DB.Exec("DELETE FROM stored_responses") })
+	t.Setenv("RESPONSES_STORE", ResponsesStoreDB)
+	require.NoError(t, CreateBackgroundResponse(1, "gpt-5", "resp_bg6", []byte(`{"id":"resp_bg6","status":"in_progress"}`)))
+
+	calls := 0
+	err := UpdateBackgroundResponse(1, "resp_bg6", func(stored *model.StoredResponse) bool {
+		calls++
+		if calls == 1 {
+			// the response is cancelled between the read and the write of the finished job
+			_, err := CancelBackgroundResponse(stored)
+			require.NoError(t, err)
+		}
+		response := parseResponseObject(stored.Response)
+		if response["status"] == ResponsesStatusCancelled {
+			return false
+		}
+		response["status"] = "completed"
+		return setResponseObject(stored, response)
+	})
+	require.NoError(t, err)
+	require.Equal(t, 2, calls)
+	stored, err := FindStoredResponse(1, "resp_bg6")
+	require.NoError(t, err)
+	require.JSONEq(t, `{"id":"resp_bg6","status":"cancelled"}`, stored.Response)
+}
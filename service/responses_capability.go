@@ -0,0 +1,183 @@
+package service
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/dto"
+)
+
+// responsesProbeMaxOutputTokens is the smallest max_output_tokens OpenAI accepts
+const responsesProbeMaxOutputTokens = 16
+
+var responsesWebSearchToolTypes = []string{"web_search", "web_search_preview"}
+
+// ProbeResponsesCapability sends minimal requests to {baseURL}/v1/responses to detect
+// whether an OpenAI compatible upstream serves the Responses API natively and which
+// optional features (background, store, web_search) it accepts.
+func ProbeResponsesCapability(client *http.Client, baseURL string, key string, model string) (*dto.ResponsesCapability, error) {
+	url := strings.TrimSuffix(baseURL, "/") + "/v1/responses"
+	capability := &dto.ResponsesCapability{CheckedAt: common.GetTimestamp()}
+
+	status, body, err := probeResponsesRequest(client, url, key, map[string]any{
+		"model":             model,
+		"input":             "ping",
+		"max_output_tokens": responsesProbeMaxOutputTokens,
+		"store":             false,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if isResponsesEndpointMissing(status, body) {
+		return capability, nil
+	}
+	if status != http.StatusOK {
+		return nil, fmt.Errorf("responses probe failed with status %d: %s", status, common.MaskSensitiveInfo(string(body)))
+	}
+	capability.Native = true
+
+	status, body, err = probeResponsesRequest(client, url, key, map[string]any{
+		"model":             model,
+		"input":             "ping",
+		"max_output_tokens": responsesProbeMaxOutputTokens,
+		"store":             true,
+	})
+	if err == nil && status == http.StatusOK {
+		var resp struct {
+			Store *bool `json:"store"`
+		}
+		capability.Store = common.Unmarshal(body, &resp) == nil && (resp.Store == nil || *resp.Store)
+	}
+
+	status, body, err = probeResponsesRequest(client, url, key, map[string]any{
+		"model":             model,
+		"input":             "ping",
+		"max_output_tokens": responsesProbeMaxOutputTokens,
+		"background":        true,
+		"store":             true,
+	})
+	if err == nil && status == http.StatusOK {
+		var resp struct {
+			Id         string `json:"id"`
+			Background bool   `json:"background"`
+			Status     string `json:"status"`
+		}
+		if common.Unmarshal(body, &resp) == nil {
+			capability.Background = resp.Background || resp.Status == "queued" || resp.Status == "in_progress"
+			if capability.Background && resp.Id != "" {
+				// the probe does not need the answer, cancel it to save tokens
+				_, _, _ = probeResponsesRequest(client, url+"/"+resp.Id+"/cancel", key, nil)
+			}
+		}
+	}
+
+	status, _, err = probeResponsesRequest(client, url, key, map[string]any{
+		"model":             model,
+		"input":             "ping",
+		"max_output_tokens": responsesProbeMaxOutputTokens,
+		"store":             false,
+		"tools":             []map[string]any{{"type": "web_search"}},
+	})
+	capability.WebSearch = err == nil && status == http.StatusOK
+
+	return capability, nil
+}
+
+func probeResponsesRequest(client *http.Client, url string, key string, payload map[string]any) (int, []byte, error) {
+	var reader io.Reader = http.NoBody
+	if payload != nil {
+		data, err := common.Marshal(payload)
+		if err != nil {
+			return 0, nil, err
+		}
+		reader = bytes.NewReader(data)
+	}
+	req, err := http.NewRequest(http.MethodPost, url, reader)
+	if err != nil {
+		return 0, nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+key)
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer CloseResponseBodyGracefully(resp)
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return resp.StatusCode, nil, err
+	}
+	return resp.StatusCode, body, nil
+}
+
+// isResponsesEndpointMissing tells a missing endpoint apart from other failures such
+// as a bad key or rate limiting, which must not be recorded as a capability.
+func isResponsesEndpointMissing(status int, body []byte) bool {
+	switch status {
+	case http.StatusNotFound, http.StatusMethodNotAllowed, http.StatusNotImplemented:
+		return true
+	case http.StatusBadRequest:
+		lower := strings.ToLower(string(body))
+		return strings.Contains(lower, "invalid url") || strings.Contains(lower, "unknown endpoint") ||
+			strings.Contains(lower, "not supported") || strings.Contains(lower, "unsupported endpoint")
+	}
+	return false
+}
+
+// ResponsesNativeUnsupported reports whether the channel was detected not to serve
+// /v1/responses: Responses requests then go through Chat Completions, and Chat
+// Completions requests are never converted to the Responses API.
+func ResponsesNativeUnsupported(settings dto.ChannelOtherSettings) bool {
+	return settings.ResponsesCapability != nil && !settings.ResponsesCapability.Native
+}
+
+// DropResponsesUnsupportedNativeFeatures removes the features a native upstream was
+// detected not to accept from the request and returns their names, so the caller can
+// apply the compat mode to them.
+func DropResponsesUnsupportedNativeFeatures(capability *dto.ResponsesCapability, req *dto.OpenAIResponsesRequest) []string {
+	if capability == nil || !capability.Native || req == nil {
+		return nil
+	}
+	features := make([]string, 0)
+	if !capability.Store && len(req.Store) > 0 {
+		features = append(features, "store")
+		req.Store = nil
+	}
+	if !capability.WebSearch && len(req.Tools) > 0 {
+		tools := req.GetToolsMap()
+		kept := make([]map[string]any, 0, len(tools))
+		for _, tool := range tools {
+			if isResponsesWebSearchTool(tool) {
+				continue
+			}
+			kept = append(kept, tool)
+		}
+		if len(kept) != len(tools) {
+			features = append(features, "tools.web_search")
+			if len(kept) == 0 {
+				req.Tools = nil
+			} else if data, err := common.Marshal(kept); err == nil {
+				req.Tools = data
+			}
+			var toolChoice map[string]any
+			if len(req.ToolChoice) > 0 && common.Unmarshal(req.ToolChoice, &toolChoice) == nil && isResponsesWebSearchTool(toolChoice) {
+				req.ToolChoice = nil
+			}
+		}
+	}
+	return features
+}
+
+func isResponsesWebSearchTool(tool map[string]any) bool {
+	toolType := common.Interface2String(tool["type"])
+	for _, webSearchType := range responsesWebSearchToolTypes {
+		if toolType == webSearchType || strings.HasPrefix(toolType, webSearchType+"_") {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,84 @@
+package service
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/dto"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestProbeResponsesCapability(t *testing.T) {
+	var cancelled bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "Bearer sk-test", r.Header.Get("Authorization"))
+		if strings.HasSuffix(r.URL.Path, "/cancel") {
+			cancelled = true
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		var req map[string]any
+		require.NoError(t, common.Unmarshal(body, &req))
+		if _, ok := req["tools"]; ok {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(`{"error":{"message":"tool web_search is not allowed"}}`))
+			return
+		}
+		if req["background"] == true {
+			_, _ = w.Write([]byte(`{"id":"resp_1","status":"queued","background":true}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"id":"resp_2","status":"completed","store":` + common.Interface2String(req["store"]) + `}`))
+	}))
+	defer server.Close()
+
+	capability, err := ProbeResponsesCapability(server.Client(), server.URL, "sk-test", "gpt-4o-mini")
+	require.NoError(t, err)
+	require.True(t, capability.Native)
+	require.True(t, capability.Store)
+	require.True(t, capability.Background)
+	require.False(t, capability.WebSearch)
+	require.True(t, cancelled)
+}
+
+func TestProbeResponsesCapabilityMissingEndpoint(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	defer server.Close()
+
+	capability, err := ProbeResponsesCapability(server.Client(), server.URL+"/", "sk-test", "gpt-4o-mini")
+	require.NoError(t, err)
+	require.False(t, capability.Native)
+	require.True(t, ResponsesNativeUnsupported(dto.ChannelOtherSettings{ResponsesCapability: capability}))
+	require.False(t, ResponsesNativeUnsupported(dto.ChannelOtherSettings{}))
+
+	unauthorized := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer unauthorized.Close()
+	_, err = ProbeResponsesCapability(unauthorized.Client(), unauthorized.URL, "sk-test", "gpt-4o-mini")
+	require.Error(t, err)
+}
+
+func TestDropResponsesUnsupportedNativeFeatures(t *testing.T) {
+	req := &dto.OpenAIResponsesRequest{
+		Store:      []byte(`true`),
+		Tools:      []byte(`[{"type":"web_search_preview"},{"type":"function","name":"lookup"}]`),
+		ToolChoice: []byte(`{"type":"web_search_preview"}`),
+	}
+	features := DropResponsesUnsupportedNativeFeatures(&dto.ResponsesCapability{Native: true}, req)
+	require.Equal(t, []string{"store", "tools.web_search"}, features)
+	require.Nil(t, req.Store)
+	require.Nil(t, req.ToolChoice)
+	tools := req.GetToolsMap()
+	require.Len(t, tools, 1)
+	require.Equal(t, "function", tools[0]["type"])
+
+	req = &dto.OpenAIResponsesRequest{Store: []byte(`true`)}
+	require.Empty(t, DropResponsesUnsupportedNativeFeatures(&dto.ResponsesCapability{Native: true, Store: true}, req))
+	require.Empty(t, DropResponsesUnsupportedNativeFeatures(nil, req))
+}
@@ -0,0 +1,163 @@
+package service
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/dto"
+)
+
+// 导出 Responses 对话链：从指定响应沿 previous_response_id 向前追溯，还原整段对话，供排查问题与整理数据集。
+// 每个存储的响应只保存本轮新增的消息，链上较早的响应被删除后无法导出；早期保存的响应含截至该响应的完整对话，
+// 追溯到这样的响应为止，之前的轮次合并到第一轮，第一轮的 previous_response_id 不为空即表示这种合并
+const (
+	ResponsesExportFormatMessages = "messages"
+	ResponsesExportFormatMarkdown = "markdown"
+)
+
+// ResponsesConversationTurn 对话链上的一轮：续接上一个响应的新增输入与本轮的输出
+type ResponsesConversationTurn struct {
+	ResponseId         string        `json:"response_id"`
+	PreviousResponseId string        `json:"previous_response_id,omitempty"`
+	Model              string        `json:"model"`
+	CreatedAt          int64         `json:"created_at"`
+	Messages           []dto.Message `json:"messages"`
+}
+
+// ResponsesConversationExport 导出的对话，Messages 为 Chat Completions 格式的完整对话，
+// 最后一个响应带有 instructions 时以 system 消息开头
+type ResponsesConversationExport struct {
+	Object       string                      `json:"object"`
+	ResponseId   string                      `json:"response_id"`
+	Model        string                      `json:"model"`
+	Instructions string                      `json:"instructions,omitempty"`
+	Messages     []dto.Message               `json:"messages"`
+	Turns        []ResponsesConversationTurn `json:"turns"`
+}
+
+// ExportResponsesConversation 导出用户经兼容层存储的响应所在的对话链，响应不存在、
+// 由原生渠道保存或尚未完成时返回 ErrPreviousResponseNotFound
+func ExportResponsesConversation(userId int, responseId string) (*ResponsesConversationExport, error) {
+	chain, err := loadStoredConversationChain(userId, responseId)
+	if err != nil {
+		return nil, err
+	}
+
+	latest := chain[len(chain)-1].stored
+	export := &ResponsesConversationExport{
+		Object:       "response.conversation",
+		ResponseId:   latest.ResponseId,
+		Model:        latest.ModelName,
+		Instructions: storedResponseInstructions(latest.Response),
+		Turns:        make([]ResponsesConversationTurn, 0, len(chain)),
+	}
+	if export.Instructions != "" {
+		export.Messages = append(export.Messages, dto.Message{Role: "system", Content: export.Instructions})
+	}
+	for _, turn := range chain {
+		export.Messages = append(export.Messages, turn.messages...)
+		export.Turns = append(export.Turns, ResponsesConversationTurn{
+			ResponseId:         turn.stored.ResponseId,
+			PreviousResponseId: turn.stored.PreviousResponseId,
+			Model:              turn.stored.ModelName,
+			CreatedAt:          turn.stored.CreatedAt,
+			Messages:           turn.messages,
+		})
+	}
+	return export, nil
+}
+
+// storedResponseInstructions 返回存储的响应对象中字符串形式的 instructions
+func storedResponseInstructions(response string) string {
+	if response == "" {
+		return ""
+	}
+	var parsed struct {
+		Instructions any `json:"instructions"`
+	}
+	if err := common.UnmarshalJsonStr(response, &parsed); err != nil {
+		return ""
+	}
+	instructions, _ := parsed.Instructions.(string)
+	return instructions
+}
+
+// RenderResponsesConversationMarkdown 将导出的对话渲染为 Markdown，每轮一节，
+// 图片、音频、文件等非文本内容以占位说明代替
+func RenderResponsesConversationMarkdown(export *ResponsesConversationExport) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "# Conversation %s\n\n", export.ResponseId)
+	fmt.Fprintf(&sb, "- Model: %s\n- Turns: %d\n", export.Model, len(export.Turns))
+	if export.Instructions != "" {
+		sb.WriteString("\n## Instructions\n\n")
+		sb.WriteString(export.Instructions)
+		sb.WriteString("\n")
+	}
+	for i, turn := range export.Turns {
+		fmt.Fprintf(&sb, "\n## Turn %d · %s\n\n", i+1, turn.ResponseId)
+		fmt.Fprintf(&sb, "- Model: %s\n", turn.Model)
+		if turn.CreatedAt > 0 {
+			fmt.Fprintf(&sb, "- Created: %s\n", time.Unix(turn.CreatedAt, 0).UTC().Format(time.RFC3339))
+		}
+		if turn.PreviousResponseId != "" && i == 0 {
+			fmt.Fprintf(&sb, "- Continues: %s (no longer stored, earlier turns are merged into this one)\n", turn.PreviousResponseId)
+		}
+		for _, message := range turn.Messages {
+			writeMarkdownMessage(&sb, message)
+		}
+	}
+	return sb.String()
+}
+
+func writeMarkdownMessage(sb *strings.Builder, message dto.Message) {
+	role := message.Role
+	if role != "" {
+		role = strings.ToUpper(role[:1]) + role[1:]
+	}
+	if message.ToolCallId != "" {
+		role += " (" + message.ToolCallId + ")"
+	}
+	fmt.Fprintf(sb, "\n### %s\n\n", role)
+	if reasoning := message.ReasoningContent; reasoning != "" {
+		fmt.Fprintf(sb, "<details><summary>Reasoning</summary>\n\n%s\n\n</details>\n\n", reasoning)
+	}
+	if content := markdownMessageContent(message); content != "" {
+		sb.WriteString(content)
+		sb.WriteString("\n")
+	}
+	for _, toolCall := range message.ParseToolCalls() {
+		fmt.Fprintf(sb, "\n**Tool call** `%s` (%s)\n\n```json\n%s\n```\n", toolCall.Function.Name, toolCall.ID, toolCall.Function.Arguments)
+	}
+}
+
+func markdownMessageContent(message dto.Message) string {
+	if message.IsStringContent() {
+		return message.StringContent()
+	}
+	parts := make([]string, 0)
+	for _, content := range message.ParseContent() {
+		switch content.Type {
+		case dto.ContentTypeText:
+			parts = append(parts, content.Text)
+		case dto.ContentTypeImageURL:
+			if image := content.GetImageMedia(); image != nil && strings.HasPrefix(image.Url, "http") {
+				parts = append(parts, fmt.Sprintf("![image](%s)", image.Url))
+			} else {
+				parts = append(parts, "[image]")
+			}
+		case dto.ContentTypeInputAudio:
+			parts = append(parts, "[audio]")
+		case dto.ContentTypeFile:
+			if file := content.GetFile(); file != nil && file.FileName != "" {
+				parts = append(parts, fmt.Sprintf("[file: %s]", file.FileName))
+			} else {
+				parts = append(parts, "[file]")
+			}
+		case dto.ContentTypeVideoUrl:
+			parts = append(parts, "[video]")
+		}
+	}
+	return strings.Join(parts, "\n\n")
+}
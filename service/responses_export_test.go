@@ -0,0 +1,77 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/dto"
+	"github.com/QuantumNous/new-api/model"
+
+	"github.com/stretchr/testify/require"
+)
+
+func storeTestConversation(t *testing.T, responseId string, previousResponseId string, offset int, response string, messages ...dto.Message) {
+	t.Helper()
+	data, err := common.Marshal(messages)
+	require.NoError(t, err)
+	require.NoError(t, createStoredResponse(&model.StoredResponse{
+		ResponseId:         responseId,
+		PreviousResponseId: previousResponseId,
+		ConversationId:     "resp_1",
+		MessagesOffset:     offset,
+		UserId:             1,
+		ModelName:          "gpt-4o",
+		Messages:           string(data),
+		Response:           response,
+	}))
+}
+
+func TestExportResponsesConversation(t *testing.T) {
+	t.Cleanup(func() { model.DB.Exec("DELETE FROM stored_responses") })
+	t.Setenv("RESPONSES_STORE", ResponsesStoreDB)
+
+	user1 := dto.Message{Role: "user", Content: "hello"}
+	assistant1 := dto.Message{Role: "assistant", Content: "hi there"}
+	user2 := dto.Message{Role: "user", Content: "what is 2+2?"}
+	assistant2 := dto.Message{Role: "assistant", Content: "4"}
+	// 每个响应只保存本轮新增的消息
+	storeTestConversation(t, "resp_1", "", 0, `{"id":"resp_1","instructions":"be brief"}`, user1, assistant1)
+	storeTestConversation(t, "resp_2", "resp_1", 2, `{"id":"resp_2","instructions":"be terse"}`, user2, assistant2)
+
+	export, err := ExportResponsesConversation(1, "resp_2")
+	require.NoError(t, err)
+	require.Equal(t, "resp_2", export.ResponseId)
+	require.Equal(t, "be terse", export.Instructions)
+	require.Len(t, export.Messages, 5)
+	require.Equal(t, "system", export.Messages[0].Role)
+	require.Len(t, export.Turns, 2)
+	require.Equal(t, "resp_1", export.Turns[0].ResponseId)
+	require.Len(t, export.Turns[0].Messages, 2)
+	require.Equal(t, "resp_2", export.Turns[1].ResponseId)
+	require.Equal(t, "4", export.Turns[1].Messages[1].StringContent())
+
+	markdown := RenderResponsesConversationMarkdown(export)
+	require.Contains(t, markdown, "# Conversation resp_2")
+	require.Contains(t, markdown, "## Turn 2 · resp_2")
+	require.Contains(t, markdown, "### Assistant\n\n4\n")
+
+	_, history, err := loadStoredConversation(1, "resp_2")
+	require.NoError(t, err)
+	require.Equal(t, []string{"user", "assistant", "user", "assistant"}, messageRoles(history))
+
+	// 早期保存的响应含完整对话，之前的轮次合并到第一轮
+	storeTestConversation(t, "resp_legacy", "resp_0", 0, `{"id":"resp_legacy"}`, user1, assistant1, user2, assistant2)
+	export, err = ExportResponsesConversation(1, "resp_legacy")
+	require.NoError(t, err)
+	require.Len(t, export.Turns, 1)
+	require.Equal(t, "resp_0", export.Turns[0].PreviousResponseId)
+	require.Len(t, export.Turns[0].Messages, 4)
+
+	// 链上较早的响应被删除后无法还原对话
+	require.NoError(t, model.DeleteStoredResponse("resp_1", 1))
+	_, err = ExportResponsesConversation(1, "resp_2")
+	require.ErrorIs(t, err, ErrPreviousResponseNotFound)
+
+	_, err = ExportResponsesConversation(2, "resp_2")
+	require.ErrorIs(t, err, ErrPreviousResponseNotFound)
+}
@@ -0,0 +1,356 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/dto"
+	"github.com/QuantumNous/new-api/logger"
+	"github.com/QuantumNous/new-api/model"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-redis/redis/v8"
+)
+
+// 兼容层的 Responses 存储：渠道不支持原生 Responses API 时，store=true 的响应由网关保存
+// 本轮新增的消息，后续请求的 previous_response_id 沿对话链拼接各响应的消息还原历史，存储量随对话长度线性增长；
+// 原生渠道保存在上游的响应只记录所属渠道，GET/DELETE /v1/responses/:id 据此转发。
+// RESPONSES_STORE 选择存储后端：db（默认，主库）、redis、off（关闭）；
+// RESPONSES_STORE_TTL_HOURS 为保存时长，默认 720 小时（30 天）。
+const (
+	ResponsesStoreDB    = "db"
+	ResponsesStoreRedis = "redis"
+	ResponsesStoreOff   = "off"
+
+	responsesStoreRedisKeyPrefix = "responses_store:"
+
+	// 还原或导出对话时沿 previous_response_id 追溯的最大响应数
+	maxStoredConversationChain = 256
+)
+
+var ErrPreviousResponseNotFound = errors.New("previous response not found")
+
+var responsesStoreLastCleanup atomic.Int64
+
+// ResponsesStoreBackend 返回生效的存储后端，未启用 Redis 时 redis 回退为 db
+func ResponsesStoreBackend() string {
+	backend := strings.ToLower(strings.TrimSpace(common.GetEnvOrDefaultString("RESPONSES_STORE", ResponsesStoreDB)))
+	switch backend {
+	case ResponsesStoreOff:
+		return ResponsesStoreOff
+	case ResponsesStoreRedis:
+		if common.RedisEnabled {
+			return ResponsesStoreRedis
+		}
+	}
+	return ResponsesStoreDB
+}
+
+func responsesStoreTTL() time.Duration {
+	hours := common.GetEnvOrDefault("RESPONSES_STORE_TTL_HOURS", 720)
+	if hours <= 0 {
+		hours = 720
+	}
+	return time.Duration(hours) * time.Hour
+}
+
+// FindStoredResponse 返回用户已存储的响应，不存在、已过期或属于其他用户时返回 nil
+func FindStoredResponse(userId int, responseId string) (*model.StoredResponse, error) {
+	if ResponsesStoreBackend() == ResponsesStoreRedis {
+		return getRedisStoredResponse(responseId, userId)
+	}
+	return model.GetStoredResponse(responseId, userId, common.GetTimestamp())
+}
+
+// DeleteStoredResponse 删除用户已存储的响应记录
+func DeleteStoredResponse(userId int, responseId string) error {
+	if ResponsesStoreBackend() == ResponsesStoreRedis {
+		return common.RedisDel(responsesStoreRedisKeyPrefix + responseId)
+	}
+	return model.DeleteStoredResponse(responseId, userId)
+}
+
+// storedConversationTurn 对话链上的一个存储响应及其保存的消息
+type storedConversationTurn struct {
+	stored   *model.StoredResponse
+	messages []dto.Message
+}
+
+// loadStoredConversation 返回经兼容层存储的响应及截至该响应的完整对话
+func loadStoredConversation(userId int, responseId string) (*model.StoredResponse, []dto.Message, error) {
+	chain, err := loadStoredConversationChain(userId, responseId)
+	if err != nil {
+		return nil, nil, err
+	}
+	var messages []dto.Message
+	for _, turn := range chain {
+		messages = append(messages, turn.messages...)
+	}
+	return chain[len(chain)-1].stored, messages, nil
+}
+
+// loadStoredConversationChain 从 responseId 沿 previous_response_id 向前读取存储响应，直到完整保存对话的响应
+// （MessagesOffset 为 0），按时间顺序返回。链上的响应被删除或不完整时返回 ErrPreviousResponseNotFound
+func loadStoredConversationChain(userId int, responseId string) ([]storedConversationTurn, error) {
+	stored, err := FindStoredResponse(userId, responseId)
+	if err != nil {
+		return nil, err
+	}
+	if stored == nil {
+		return nil, fmt.Errorf("%w: %s", ErrPreviousResponseNotFound, responseId)
+	}
+	if stored.Native {
+		return nil, fmt.Errorf("%w: %s is stored by the upstream channel %d", ErrPreviousResponseNotFound, responseId, stored.ChannelId)
+	}
+	return storedConversationChain(stored)
+}
+
+// storedConversationChain 从已读取的存储响应向前还原对话链；数据库存储时一次读取该响应及之前的至多
+// maxStoredConversationChain 个同链响应，未读到的（对话分叉较多时）逐个查询
+func storedConversationChain(stored *model.StoredResponse) ([]storedConversationTurn, error) {
+	var conversation map[string]*model.StoredResponse
+	if stored.MessagesOffset > 0 && stored.ConversationId != "" && ResponsesStoreBackend() == ResponsesStoreDB {
+		rows, err := model.GetStoredConversation(stored.ConversationId, stored.UserId, stored.Id, common.GetTimestamp(), maxStoredConversationChain)
+		if err != nil {
+			return nil, err
+		}
+		conversation = make(map[string]*model.StoredResponse, len(rows))
+		for _, row := range rows {
+			conversation[row.ResponseId] = row
+		}
+	}
+
+	var chain []storedConversationTurn
+	seen := make(map[string]bool)
+	for current := stored; ; {
+		if current.Messages == "" {
+			// 后台响应尚未完成，或由原生渠道完成
+			return nil, fmt.Errorf("%w: %s has no stored conversation", ErrPreviousResponseNotFound, current.ResponseId)
+		}
+		var messages []dto.Message
+		if err := common.UnmarshalJsonStr(current.Messages, &messages); err != nil {
+			return nil, fmt.Errorf("failed to decode stored response %s: %w", current.ResponseId, err)
+		}
+		seen[current.ResponseId] = true
+		chain = append(chain, storedConversationTurn{stored: current, messages: messages})
+		if current.MessagesOffset == 0 {
+			break
+		}
+		previousId := current.PreviousResponseId
+		if previousId == "" || seen[previousId] || len(chain) >= maxStoredConversationChain {
+			return nil, fmt.Errorf("%w: the conversation of %s is incomplete", ErrPreviousResponseNotFound, stored.ResponseId)
+		}
+		previous := conversation[previousId]
+		if previous == nil {
+			var err error
+			if previous, err = FindStoredResponse(stored.UserId, previousId); err != nil {
+				return nil, err
+			}
+		}
+		if previous == nil || previous.Native {
+			return nil, fmt.Errorf("%w: %s, an earlier response of %s, is no longer stored", ErrPreviousResponseNotFound, previousId, stored.ResponseId)
+		}
+		current = previous
+	}
+	slices.Reverse(chain)
+
+	// 每个响应之前的消息数应与链上较早响应保存的消息总数一致
+	total := 0
+	for _, turn := range chain {
+		if turn.stored.MessagesOffset != total {
+			return nil, fmt.Errorf("%w: the conversation of %s is inconsistent", ErrPreviousResponseNotFound, stored.ResponseId)
+		}
+		total += len(turn.messages)
+	}
+	return chain, nil
+}
+
+// storedConversationId 返回存储响应所在对话链的 ID，早期保存的响应没有该字段时以自身 ID 代替
+func storedConversationId(stored *model.StoredResponse) string {
+	if stored.ConversationId != "" {
+		return stored.ConversationId
+	}
+	return stored.ResponseId
+}
+
+// SaveResponsesConversation 在响应完成前保存兼容层返回的响应：本轮新增的输入消息加上本次输出的 assistant 消息，
+// 以及返回给客户端的响应对象，客户端收到响应后即可用 previous_response_id 续接。
+// 请求未要求存储（store=false）或存储已关闭时不做任何事
+func SaveResponsesConversation(c *gin.Context, info *relaycommon.RelayInfo, responseId string, output *dto.Message, response any) {
+	if info == nil || info.ResponsesConversation == nil || output == nil || responseId == "" {
+		return
+	}
+	// 续接的历史消息已保存在链上较早的响应中
+	offset := min(info.ResponsesHistoryLen, len(info.ResponsesConversation))
+	conversationId := info.ResponsesConversationId
+	if offset == 0 || conversationId == "" {
+		offset, conversationId = 0, responseId
+	}
+	messages := append(info.ResponsesConversation[offset:len(info.ResponsesConversation):len(info.ResponsesConversation)], *output)
+	data, err := common.Marshal(messages)
+	if err != nil {
+		logger.LogError(c, "marshal stored response failed: "+err.Error())
+		return
+	}
+	if responseId == info.ResponsesBackgroundId {
+		// 后台响应的记录已由网关创建，这里只补充对话，响应对象在任务结束时写入
+		var expiresAt int64
+		err = UpdateBackgroundResponse(info.UserId, responseId, func(stored *model.StoredResponse) bool {
+			stored.Messages = string(data)
+			stored.MessagesOffset = offset
+			stored.ConversationId = conversationId
+			stored.PreviousResponseId = info.ResponsesPreviousId
+			expiresAt = stored.ExpiresAt
+			return true
+		})
+		if err == nil && offset > 0 {
+			err = extendStoredConversation(info.UserId, conversationId, info.ResponsesPreviousId, expiresAt)
+		}
+		if err != nil {
+			logger.LogError(c, "save background response conversation failed: "+err.Error())
+		}
+		return
+	}
+	responseData, err := common.Marshal(response)
+	if err != nil {
+		logger.LogError(c, "marshal stored response failed: "+err.Error())
+		return
+	}
+	stored := &model.StoredResponse{
+		ResponseId:         responseId,
+		PreviousResponseId: info.ResponsesPreviousId,
+		UserId:             info.UserId,
+		ChannelId:          info.ChannelId,
+		ModelName:          info.OriginModelName,
+		ConversationId:     conversationId,
+		MessagesOffset:     offset,
+		Messages:           string(data),
+		Response:           string(responseData),
+	}
+	if err := createStoredResponse(stored); err != nil {
+		logger.LogError(c, "save stored response failed: "+err.Error())
+		return
+	}
+	if offset > 0 {
+		if err := extendStoredConversation(info.UserId, conversationId, info.ResponsesPreviousId, stored.ExpiresAt); err != nil {
+			logger.LogError(c, "extend stored conversation failed: "+err.Error())
+		}
+	}
+}
+
+// extendStoredConversation 将对话链上较早的响应续期到新响应的过期时间，避免较早的响应先过期导致对话无法还原
+func extendStoredConversation(userId int, conversationId string, previousId string, expiresAt int64) error {
+	if ResponsesStoreBackend() != ResponsesStoreRedis {
+		return model.ExtendStoredConversation(conversationId, userId, expiresAt)
+	}
+	ttl := time.Until(time.Unix(expiresAt, 0))
+	for id, n := previousId, 0; id != "" && n < maxStoredConversationChain; n++ {
+		stored, err := getRedisStoredResponse(id, userId)
+		if err != nil || stored == nil {
+			return err
+		}
+		if err := common.RDB.Expire(context.Background(), responsesStoreRedisKeyPrefix+id, ttl).Err(); err != nil {
+			return err
+		}
+		if stored.MessagesOffset == 0 {
+			break
+		}
+		id = stored.PreviousResponseId
+	}
+	return nil
+}
+
+// SaveNativeResponse 记录原生渠道以 store=true 保存在上游的响应所属的渠道与密钥，供查询、删除、取消时转发
+func SaveNativeResponse(info *relaycommon.RelayInfo, responseId string, stored bool) {
+	if info == nil || !stored || responseId == "" || ResponsesStoreBackend() == ResponsesStoreOff {
+		return
+	}
+	err := createStoredResponse(&model.StoredResponse{
+		ResponseId: responseId,
+		UserId:     info.UserId,
+		ChannelId:  info.ChannelId,
+		KeyIndex:   info.ChannelMultiKeyIndex,
+		ModelName:  info.OriginModelName,
+		Native:     true,
+	})
+	if err != nil {
+		common.SysError("save stored response failed: " + err.Error())
+	}
+}
+
+func createStoredResponse(stored *model.StoredResponse) error {
+	ttl := responsesStoreTTL()
+	stored.CreatedAt = common.GetTimestamp()
+	stored.ExpiresAt = stored.CreatedAt + int64(ttl/time.Second)
+	if ResponsesStoreBackend() == ResponsesStoreRedis {
+		value, err := common.Marshal(stored)
+		if err != nil {
+			return err
+		}
+		return common.RedisSet(responsesStoreRedisKeyPrefix+stored.ResponseId, string(value), ttl)
+	}
+	err := model.CreateStoredResponse(stored)
+	cleanupStoredResponses()
+	return err
+}
+
+// updateStoredResponse 写回已存储响应的对话与响应对象，Redis 中保留原有的过期时间
+func updateStoredResponse(stored *model.StoredResponse) error {
+	if ResponsesStoreBackend() == ResponsesStoreRedis {
+		ttl := time.Until(time.Unix(stored.ExpiresAt, 0))
+		if ttl <= 0 {
+			return nil
+		}
+		value, err := common.Marshal(stored)
+		if err != nil {
+			return err
+		}
+		return common.RedisSet(responsesStoreRedisKeyPrefix+stored.ResponseId, string(value), ttl)
+	}
+	return model.UpdateStoredResponse(stored)
+}
+
+// UpdateStoredResponseFeedback 写回已存储响应的评价与标签
+func UpdateStoredResponseFeedback(stored *model.StoredResponse) error {
+	if ResponsesStoreBackend() == ResponsesStoreRedis {
+		return updateStoredResponse(stored)
+	}
+	return model.UpdateStoredResponseFeedback(stored)
+}
+
+func getRedisStoredResponse(responseId string, userId int) (*model.StoredResponse, error) {
+	value, err := common.RedisGet(responsesStoreRedisKeyPrefix + responseId)
+	if errors.Is(err, redis.Nil) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var stored model.StoredResponse
+	if err := common.UnmarshalJsonStr(value, &stored); err != nil {
+		return nil, err
+	}
+	if stored.UserId != userId {
+		return nil, nil
+	}
+	return &stored, nil
+}
+
+// cleanupStoredResponses 每小时至多清理一次过期的存储响应
+func cleanupStoredResponses() {
+	now := common.GetTimestamp()
+	last := responsesStoreLastCleanup.Load()
+	if now-last < 3600 || !responsesStoreLastCleanup.CompareAndSwap(last, now) {
+		return
+	}
+	if _, err := model.DeleteExpiredStoredResponses(now); err != nil {
+		common.SysError("cleanup stored responses failed: " + err.Error())
+	}
+}
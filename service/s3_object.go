@@ -0,0 +1,77 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
+)
+
+// s3Bucket S3（或兼容 S3 的对象存储）上的存储桶，请求以 SigV4 签名，载荷不参与签名
+type s3Bucket struct {
+	Endpoint     string // 兼容 S3 的对象存储地址，留空时使用 AWS S3
+	Region       string
+	Bucket       string
+	Prefix       string
+	AccessKeyId  string
+	AccessSecret string
+}
+
+// objectKey 返回加上前缀后的对象键
+func (b s3Bucket) objectKey(name string) string {
+	if prefix := strings.Trim(b.Prefix, "/"); prefix != "" {
+		return prefix + "/" + name
+	}
+	return name
+}
+
+func (b s3Bucket) region() string {
+	if b.Region == "" {
+		return "us-east-1"
+	}
+	return b.Region
+}
+
+func (b s3Bucket) objectURL(key string) string {
+	escapedKey := (&url.URL{Path: key}).EscapedPath()
+	if custom := strings.TrimRight(b.Endpoint, "/"); custom != "" {
+		// 兼容 S3 的存储使用路径风格的地址
+		return fmt.Sprintf("%s/%s/%s", custom, b.Bucket, escapedKey)
+	}
+	return fmt.Sprintf("https://%s.s3.%s.amazonaws.com/%s", b.Bucket, b.region(), escapedKey)
+}
+
+// do 对对象键为 key 的对象发起请求，响应状态码不是 2xx 时返回错误；成功时调用方负责关闭响应体
+func (b s3Bucket) do(ctx context.Context, method string, key string, body io.Reader, size int64, contentType string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, b.objectURL(key), body)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.ContentLength = size
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	req.Header.Set("X-Amz-Content-Sha256", "UNSIGNED-PAYLOAD")
+	credentials := aws.Credentials{AccessKeyID: b.AccessKeyId, SecretAccessKey: b.AccessSecret}
+	if err = v4.NewSigner().SignHTTP(ctx, credentials, req, "UNSIGNED-PAYLOAD", "s3", b.region(), time.Now()); err != nil {
+		return nil, err
+	}
+	resp, err := GetHttpClient().Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= http.StatusMultipleChoices {
+		defer resp.Body.Close()
+		respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return nil, fmt.Errorf("S3 returned status %d: %s", resp.StatusCode, string(respBody))
+	}
+	return resp, nil
+}
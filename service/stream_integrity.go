@@ -0,0 +1,113 @@
+package service
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/constant"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	"github.com/QuantumNous/new-api/setting/model_setting"
+	"github.com/QuantumNous/new-api/types"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 流式响应完整性校验：开启后记录写给客户端的全部响应字节，流正常结束时追加一条 SSE 注释
+//
+//	: integrity sha256=<hex> bytes=<n> completion_tokens=<n> request_id=<id>
+//
+// sha256 与 bytes 覆盖该注释之前的全部响应体，客户端按收到的字节计算比对即可发现被中间代理截断或改写的流；
+// 同样的信息也以 HTTP trailer 发送。流异常结束时不追加，缺少该注释即表示流不完整，客户端可凭 request_id 重新请求
+
+// StreamIntegrityHeader 客户端通过该请求头单独开启完整性校验
+const StreamIntegrityHeader = "X-Stream-Integrity"
+
+const (
+	streamIntegrityTrailerSha256 = "X-Stream-Sha256"
+	streamIntegrityTrailerBytes  = "X-Stream-Bytes"
+	streamIntegrityTrailerTokens = "X-Stream-Completion-Tokens"
+)
+
+// streamIntegrityEnabled 全局设置、灰度开关或请求头任一开启即对流式请求生效
+func streamIntegrityEnabled(c *gin.Context, info *relaycommon.RelayInfo) bool {
+	if info == nil || !info.IsStream || info.RelayFormat == types.RelayFormatOpenAIRealtime {
+		return false
+	}
+	if enabled, err := strconv.ParseBool(c.GetHeader(StreamIntegrityHeader)); err == nil && enabled {
+		return true
+	}
+	return model_setting.GetGlobalSettings().StreamIntegrityTrailerEnabled || FeatureEnabled(info, constant.FeatureFlagStreamIntegrity)
+}
+
+// BeginStreamIntegrity 为开启完整性校验的流式请求记录响应摘要；返回的 finish 非空时需在转发结束后调用，
+// succeeded 为 false（转发失败）或响应不是 SSE 时不追加校验信息
+func BeginStreamIntegrity(c *gin.Context, info *relaycommon.RelayInfo) (finish func(succeeded bool)) {
+	if !streamIntegrityEnabled(c, info) {
+		return nil
+	}
+	writer := &streamIntegrityWriter{ResponseWriter: c.Writer, hash: sha256.New()}
+	c.Writer = writer
+	return func(succeeded bool) {
+		c.Writer = writer.ResponseWriter
+		if !succeeded || !writer.Written() || !strings.HasPrefix(writer.Header().Get("Content-Type"), "text/event-stream") {
+			return
+		}
+		if c.Request.Context().Err() != nil {
+			return
+		}
+		sum, size := writer.digest()
+		completionTokens := common.GetContextKeyInt(c, constant.ContextKeyCompletionTokens)
+		_, _ = writer.ResponseWriter.WriteString(streamIntegrityComment(sum, size, completionTokens, c.GetString(common.RequestIdKey)))
+		header := writer.ResponseWriter.Header()
+		header.Set(http.TrailerPrefix+streamIntegrityTrailerSha256, sum)
+		header.Set(http.TrailerPrefix+streamIntegrityTrailerBytes, strconv.FormatInt(size, 10))
+		header.Set(http.TrailerPrefix+streamIntegrityTrailerTokens, strconv.Itoa(completionTokens))
+		writer.ResponseWriter.Flush()
+	}
+}
+
+func streamIntegrityComment(sum string, size int64, completionTokens int, requestId string) string {
+	return fmt.Sprintf(": integrity sha256=%s bytes=%d completion_tokens=%d request_id=%s\n\n", sum, size, completionTokens, requestId)
+}
+
+// streamIntegrityWriter 在写出响应的同时计算响应体摘要，保活 ping 可能与数据块并发写出
+type streamIntegrityWriter struct {
+	gin.ResponseWriter
+	lock sync.Mutex
+	hash hash.Hash
+	size int64
+}
+
+func (w *streamIntegrityWriter) Write(data []byte) (int, error) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	n, err := w.ResponseWriter.Write(data)
+	w.record(data[:n])
+	return n, err
+}
+
+func (w *streamIntegrityWriter) WriteString(s string) (int, error) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	n, err := w.ResponseWriter.WriteString(s)
+	w.record([]byte(s[:n]))
+	return n, err
+}
+
+func (w *streamIntegrityWriter) record(data []byte) {
+	w.hash.Write(data)
+	w.size += int64(len(data))
+}
+
+func (w *streamIntegrityWriter) digest() (string, int64) {
+	w.lock.Lock()
+	defer w.lock.Unlock()
+	return hex.EncodeToString(w.hash.Sum(nil)), w.size
+}
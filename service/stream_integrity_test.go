@@ -0,0 +1,72 @@
+package service
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/constant"
+	"github.com/QuantumNous/new-api/dto"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	"github.com/QuantumNous/new-api/types"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+)
+
+func newStreamIntegrityTestContext() (*gin.Context, *httptest.ResponseRecorder) {
+	recorder := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(recorder)
+	c.Request = httptest.NewRequest(http.MethodPost, "/v1/chat/completions", nil)
+	c.Request.Header.Set(StreamIntegrityHeader, "true")
+	c.Set(common.RequestIdKey, "req-1")
+	return c, recorder
+}
+
+func TestStreamIntegrityAppendsChecksumComment(t *testing.T) {
+	info := &relaycommon.RelayInfo{IsStream: true, RelayFormat: types.RelayFormatOpenAI}
+	c, recorder := newStreamIntegrityTestContext()
+	finish := BeginStreamIntegrity(c, info)
+	require.NotNil(t, finish)
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Status(http.StatusOK)
+	content := "data: {\"choices\":[{\"delta\":{\"content\":\"hi\"}}]}\n\ndata: [DONE]\n\n"
+	_, _ = c.Writer.WriteString(content[:10])
+	_, _ = c.Writer.Write([]byte(content[10:]))
+	common.SetContextKey(c, constant.ContextKeyCompletionTokens, 1)
+	finish(true)
+
+	sum := sha256.Sum256([]byte(content))
+	body := recorder.Body.String()
+	require.True(t, strings.HasPrefix(body, content))
+	require.Equal(t, streamIntegrityComment(hex.EncodeToString(sum[:]), int64(len(content)), 1, "req-1"), body[len(content):])
+}
+
+func TestStreamIntegritySkipsFailedAndNonStreamResponses(t *testing.T) {
+	c, recorder := newStreamIntegrityTestContext()
+	require.Nil(t, BeginStreamIntegrity(c, &relaycommon.RelayInfo{RelayFormat: types.RelayFormatOpenAI}))
+
+	finish := BeginStreamIntegrity(c, &relaycommon.RelayInfo{IsStream: true, RelayFormat: types.RelayFormatOpenAI})
+	require.NotNil(t, finish)
+	c.Header("Content-Type", "text/event-stream")
+	_, _ = c.Writer.WriteString("data: {}\n\n")
+	finish(false)
+	require.Equal(t, "data: {}\n\n", recorder.Body.String())
+
+	// 上游错误以 JSON 返回时不追加
+	c, recorder = newStreamIntegrityTestContext()
+	finish = BeginStreamIntegrity(c, &relaycommon.RelayInfo{IsStream: true, RelayFormat: types.RelayFormatOpenAI})
+	c.JSON(http.StatusOK, gin.H{"id": "1"})
+	finish(true)
+	require.NotContains(t, recorder.Body.String(), ": integrity")
+}
+
+func TestSettleUsageBillingRecordsCompletionTokens(t *testing.T) {
+	c, _ := newStreamIntegrityTestContext()
+	require.NoError(t, SettleUsageBilling(c, &relaycommon.RelayInfo{}, 0, &dto.Usage{CompletionTokens: 7}))
+	require.Equal(t, 7, common.GetContextKeyInt(c, constant.ContextKeyCompletionTokens))
+}
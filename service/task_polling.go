@@ -103,6 +103,15 @@ func TaskPollingLoop() {
 			if len(tasks) == 0 {
 				continue
 			}
+			// Batch 请求共享上游 Batch ID，需按 Batch 聚合处理
+			if platform == constant.TaskPlatformBatch {
+				UpdateBatchTasks(ctx, tasks)
+				continue
+			}
+			if platform == constant.TaskPlatformBatchApi {
+				UpdateBatchApiTasks(ctx, tasks)
+				continue
+			}
 			taskChannelM := make(map[int][]string)
 			taskM := make(map[string]*model.Task)
 			nullTaskIds := make([]int64, 0)
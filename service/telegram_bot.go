@@ -0,0 +1,414 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/logger"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/setting/system_setting"
+
+	"github.com/bytedance/gopkg/util/gopool"
+)
+
+const (
+	telegramAPIBase         = "https://api.telegram.org/bot"
+	telegramPollTimeout     = 30 // seconds, long polling
+	telegramIdleInterval    = 30 * time.Second
+	telegramRetryInterval   = 5 * time.Second
+	telegramDisabledListMax = 10
+	telegramTopErrorsMax    = 5
+)
+
+type telegramUser struct {
+	Id int64 `json:"id"`
+}
+
+type telegramChat struct {
+	Id int64 `json:"id"`
+}
+
+type telegramMessage struct {
+	MessageId int64         `json:"message_id"`
+	From      *telegramUser `json:"from"`
+	Chat      telegramChat  `json:"chat"`
+	Text      string        `json:"text"`
+}
+
+type telegramCallbackQuery struct {
+	Id      string           `json:"id"`
+	From    telegramUser     `json:"from"`
+	Message *telegramMessage `json:"message"`
+	Data    string           `json:"data"`
+}
+
+type telegramUpdate struct {
+	UpdateId      int64                  `json:"update_id"`
+	Message       *telegramMessage       `json:"message"`
+	CallbackQuery *telegramCallbackQuery `json:"callback_query"`
+}
+
+type telegramResponse struct {
+	Ok          bool             `json:"ok"`
+	Description string           `json:"description"`
+	Result      []telegramUpdate `json:"result"`
+}
+
+// telegramPendingAction is an action an admin requested and still has to approve.
+type telegramPendingAction struct {
+	TelegramId int64
+	UserId     int
+	ChannelId  int
+	ExpiresAt  time.Time
+}
+
+var (
+	telegramBotOnce     sync.Once
+	telegramPendingLock sync.Mutex
+	telegramPending     = make(map[string]*telegramPendingAction)
+	telegramHTTPClient  = &http.Client{Timeout: (telegramPollTimeout + 10) * time.Second}
+)
+
+// StartTelegramBot starts the admin companion bot on the master node. The bot long-polls
+// Telegram with the TelegramBotToken option and stays idle while it is disabled.
+func StartTelegramBot() {
+	telegramBotOnce.Do(func() {
+		if !common.IsMasterNode {
+			return
+		}
+		gopool.Go(runTelegramBot)
+	})
+}
+
+func runTelegramBot() {
+	ctx := context.Background()
+	var offset int64
+	for {
+		token := common.TelegramBotToken
+		if !system_setting.GetTelegramBotSettings().Enabled || token == "" {
+			time.Sleep(telegramIdleInterval)
+			continue
+		}
+		updates, err := telegramGetUpdates(token, offset)
+		if err != nil {
+			logger.LogWarn(ctx, "telegram bot poll failed: "+err.Error())
+			time.Sleep(telegramRetryInterval)
+			continue
+		}
+		for _, update := range updates {
+			offset = update.UpdateId + 1
+			handleTelegramUpdate(ctx, token, update)
+		}
+	}
+}
+
+func telegramGetUpdates(token string, offset int64) ([]telegramUpdate, error) {
+	query := url.Values{}
+	query.Set("timeout", strconv.Itoa(telegramPollTimeout))
+	query.Set("offset", strconv.FormatInt(offset, 10))
+	query.Set("allowed_updates", `["message","callback_query"]`)
+	resp, err := telegramHTTPClient.Get(telegramAPIBase + token + "/getUpdates?" + query.Encode())
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	var result telegramResponse
+	if err = common.DecodeJson(resp.Body, &result); err != nil {
+		return nil, err
+	}
+	if !result.Ok {
+		return nil, fmt.Errorf("telegram error: %s", result.Description)
+	}
+	return result.Result, nil
+}
+
+func telegramCall(token string, method string, payload map[string]any) {
+	body, err := common.Marshal(payload)
+	if err != nil {
+		return
+	}
+	resp, err := telegramHTTPClient.Post(telegramAPIBase+token+"/"+method, "application/json", strings.NewReader(string(body)))
+	if err != nil {
+		common.SysError("telegram bot " + method + " failed: " + err.Error())
+		return
+	}
+	_ = resp.Body.Close()
+}
+
+func telegramSendMessage(token string, chatId int64, text string, keyboard [][]map[string]string) {
+	payload := map[string]any{
+		"chat_id": chatId,
+		"text":    text,
+	}
+	if len(keyboard) > 0 {
+		payload["reply_markup"] = map[string]any{"inline_keyboard": keyboard}
+	}
+	telegramCall(token, "sendMessage", payload)
+}
+
+// telegramAdmin returns the enabled platform admin bound to the Telegram account.
+func telegramAdmin(telegramId int64) *model.User {
+	user := &model.User{TelegramId: strconv.FormatInt(telegramId, 10)}
+	if err := user.FillUserByTelegramId(); err != nil || user.Id == 0 {
+		return nil
+	}
+	if user.Role < common.RoleAdminUser || user.Status != common.UserStatusEnabled || user.TenantId != 0 {
+		return nil
+	}
+	return user
+}
+
+func handleTelegramUpdate(ctx context.Context, token string, update telegramUpdate) {
+	if update.CallbackQuery != nil {
+		query := update.CallbackQuery
+		telegramCall(token, "answerCallbackQuery", map[string]any{"callback_query_id": query.Id})
+		if query.Message == nil {
+			return
+		}
+		admin := telegramAdmin(query.From.Id)
+		if admin == nil {
+			telegramSendMessage(token, query.Message.Chat.Id, "该 Telegram 账户未绑定管理员账号", nil)
+			return
+		}
+		telegramSendMessage(token, query.Message.Chat.Id, handleTelegramCallback(ctx, query.From.Id, admin, query.Data), nil)
+		return
+	}
+	message := update.Message
+	if message == nil || message.From == nil || !strings.HasPrefix(message.Text, "/") {
+		return
+	}
+	admin := telegramAdmin(message.From.Id)
+	if admin == nil {
+		telegramSendMessage(token, message.Chat.Id, "该 Telegram 账户未绑定管理员账号，请先在个人设置中绑定 Telegram", nil)
+		return
+	}
+	command, args := parseTelegramCommand(message.Text)
+	var reply string
+	var keyboard [][]map[string]string
+	switch command {
+	case "health":
+		reply = telegramChannelHealth()
+	case "errors":
+		reply = telegramErrorSpikes()
+	case "spend":
+		reply = telegramTodaySpend()
+	case "enable":
+		reply, keyboard = telegramRequestEnable(message.From.Id, admin, args)
+	default:
+		reply = "可用命令：\n/health 渠道健康状况\n/errors 近期错误突增\n/spend 今日消耗\n/enable <渠道ID> 申请重新启用渠道"
+	}
+	telegramSendMessage(token, message.Chat.Id, reply, keyboard)
+}
+
+// parseTelegramCommand splits "/cmd@bot arg1 arg2" into the command and its arguments.
+func parseTelegramCommand(text string) (string, []string) {
+	fields := strings.Fields(strings.TrimPrefix(strings.TrimSpace(text), "/"))
+	if len(fields) == 0 {
+		return "", nil
+	}
+	command, _, _ := strings.Cut(fields[0], "@")
+	return strings.ToLower(command), fields[1:]
+}
+
+func telegramChannelHealth() string {
+	counts, err := model.GetChannelStatusCounts()
+	if err != nil {
+		return "查询渠道状态失败：" + err.Error()
+	}
+	byStatus := make(map[int]int64)
+	for _, count := range counts {
+		byStatus[count.Status] += count.Count
+	}
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("渠道状态：启用 %d，手动禁用 %d，自动禁用 %d\n",
+		byStatus[common.ChannelStatusEnabled], byStatus[common.ChannelStatusManuallyDisabled], byStatus[common.ChannelStatusAutoDisabled]))
+	channels, err := model.GetDisabledChannels(telegramDisabledListMax)
+	if err != nil {
+		return sb.String()
+	}
+	for _, channel := range channels {
+		reason, _ := channel.GetOtherInfo()["status_reason"].(string)
+		if reason == "" {
+			reason = "-"
+		}
+		sb.WriteString(fmt.Sprintf("#%d %s：%s\n", channel.Id, channel.Name, reason))
+	}
+	return sb.String()
+}
+
+type telegramErrorStat struct {
+	Model    string
+	Current  int64
+	Previous int64
+}
+
+func telegramErrorSpikes() string {
+	window := system_setting.GetTelegramBotSettings().ErrorWindowMinutes
+	if window <= 0 {
+		window = 60
+	}
+	now := time.Now()
+	currentStart := now.Add(-time.Duration(window) * time.Minute).Unix()
+	previousStart := now.Add(-2 * time.Duration(window) * time.Minute).Unix()
+	current, err := model.GetModelLogCountsSince(currentStart)
+	if err != nil {
+		return "查询错误日志失败：" + err.Error()
+	}
+	both, err := model.GetModelLogCountsSince(previousStart)
+	if err != nil {
+		return "查询错误日志失败：" + err.Error()
+	}
+	return formatTelegramErrorSpikes(window, current, both)
+}
+
+// formatTelegramErrorSpikes compares error counts of the current window with the
+// window before it; both contains the counts of the two windows together.
+func formatTelegramErrorSpikes(window int, current []model.ModelLogCount, both []model.ModelLogCount) string {
+	stats := make(map[string]*telegramErrorStat)
+	for _, count := range both {
+		if count.Type != model.LogTypeError {
+			continue
+		}
+		stats[count.ModelName] = &telegramErrorStat{Model: count.ModelName, Previous: count.Count}
+	}
+	for _, count := range current {
+		if count.Type != model.LogTypeError {
+			continue
+		}
+		if stat, ok := stats[count.ModelName]; ok {
+			stat.Current = count.Count
+			stat.Previous -= count.Count
+		}
+	}
+	list := make([]*telegramErrorStat, 0, len(stats))
+	for _, stat := range stats {
+		if stat.Current > 0 {
+			list = append(list, stat)
+		}
+	}
+	if len(list) == 0 {
+		return fmt.Sprintf("最近 %d 分钟没有错误", window)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Current-list[i].Previous > list[j].Current-list[j].Previous
+	})
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("最近 %d 分钟错误（与前 %d 分钟对比）：\n", window, window))
+	for i, stat := range list {
+		if i >= telegramTopErrorsMax {
+			break
+		}
+		sb.WriteString(fmt.Sprintf("%s：%d（之前 %d）\n", stat.Model, stat.Current, stat.Previous))
+	}
+	return sb.String()
+}
+
+func telegramTodaySpend() string {
+	now := time.Now()
+	start := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location()).Unix()
+	stat, err := model.SumUsedQuota(model.LogTypeConsume, start, 0, "", "", "", 0, "")
+	if err != nil {
+		return "查询今日消耗失败：" + err.Error()
+	}
+	counts, err := model.GetModelLogCountsSince(start)
+	if err != nil {
+		return "查询今日消耗失败：" + err.Error()
+	}
+	var requests, failures int64
+	for _, count := range counts {
+		if count.Type == model.LogTypeError {
+			failures += count.Count
+		} else {
+			requests += count.Count
+		}
+	}
+	return fmt.Sprintf("今日消耗：%s\n成功请求：%d\n失败请求：%d", logger.FormatQuota(stat.Quota), requests, failures)
+}
+
+func telegramRequestEnable(telegramId int64, admin *model.User, args []string) (string, [][]map[string]string) {
+	if len(args) == 0 {
+		return "用法：/enable <渠道ID>", nil
+	}
+	channelId, err := strconv.Atoi(args[0])
+	if err != nil {
+		return "渠道ID 格式错误", nil
+	}
+	channel, err := model.GetChannelById(channelId, false)
+	if err != nil {
+		return fmt.Sprintf("渠道 #%d 不存在", channelId), nil
+	}
+	if channel.Status == common.ChannelStatusEnabled {
+		return fmt.Sprintf("渠道 #%d %s 已处于启用状态", channel.Id, channel.Name), nil
+	}
+	if channel.ChannelInfo.IsMultiKey {
+		return "多密钥渠道请在控制台中按密钥启用", nil
+	}
+	timeout := system_setting.GetTelegramBotSettings().ApprovalTimeoutSeconds
+	if timeout <= 0 {
+		timeout = 300
+	}
+	nonce := common.GetRandomString(12)
+	telegramPendingLock.Lock()
+	for key, action := range telegramPending {
+		if time.Now().After(action.ExpiresAt) {
+			delete(telegramPending, key)
+		}
+	}
+	telegramPending[nonce] = &telegramPendingAction{
+		TelegramId: telegramId,
+		UserId:     admin.Id,
+		ChannelId:  channel.Id,
+		ExpiresAt:  time.Now().Add(time.Duration(timeout) * time.Second),
+	}
+	telegramPendingLock.Unlock()
+
+	text := fmt.Sprintf("确认重新启用渠道 #%d %s？（%d 秒内有效）", channel.Id, channel.Name, timeout)
+	keyboard := [][]map[string]string{{
+		{"text": "确认启用", "callback_data": "approve:" + nonce},
+		{"text": "取消", "callback_data": "cancel:" + nonce},
+	}}
+	return text, keyboard
+}
+
+// takeTelegramPendingAction removes and returns the pending action if it belongs to
+// the Telegram account and has not expired.
+func takeTelegramPendingAction(nonce string, telegramId int64) *telegramPendingAction {
+	telegramPendingLock.Lock()
+	defer telegramPendingLock.Unlock()
+	action, ok := telegramPending[nonce]
+	if !ok || action.TelegramId != telegramId {
+		return nil
+	}
+	delete(telegramPending, nonce)
+	if time.Now().After(action.ExpiresAt) {
+		return nil
+	}
+	return action
+}
+
+func handleTelegramCallback(ctx context.Context, telegramId int64, admin *model.User, data string) string {
+	verb, nonce, _ := strings.Cut(data, ":")
+	action := takeTelegramPendingAction(nonce, telegramId)
+	if action == nil || action.UserId != admin.Id {
+		return "操作已过期或无效"
+	}
+	if verb != "approve" {
+		return "已取消"
+	}
+	channel, err := model.GetChannelById(action.ChannelId, false)
+	if err != nil {
+		return fmt.Sprintf("渠道 #%d 不存在", action.ChannelId)
+	}
+	EnableChannel(channel.Id, "", channel.Name)
+	model.RecordLog(admin.Id, model.LogTypeManage, fmt.Sprintf("通过 Telegram 重新启用渠道 #%d %s", channel.Id, channel.Name))
+	logger.LogInfo(ctx, fmt.Sprintf("admin %d enabled channel #%d via telegram", admin.Id, channel.Id))
+	return fmt.Sprintf("渠道 #%d %s 已启用", channel.Id, channel.Name)
+}
@@ -0,0 +1,56 @@
+package service
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/QuantumNous/new-api/model"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseTelegramCommand(t *testing.T) {
+	command, args := parseTelegramCommand("/Enable@admin_bot 12 now")
+	require.Equal(t, "enable", command)
+	require.Equal(t, []string{"12", "now"}, args)
+
+	command, args = parseTelegramCommand("/health")
+	require.Equal(t, "health", command)
+	require.Empty(t, args)
+}
+
+func TestFormatTelegramErrorSpikes(t *testing.T) {
+	current := []model.ModelLogCount{
+		{ModelName: "gpt-a", Type: model.LogTypeError, Count: 9},
+		{ModelName: "gpt-b", Type: model.LogTypeError, Count: 2},
+		{ModelName: "gpt-a", Type: model.LogTypeConsume, Count: 100},
+	}
+	both := []model.ModelLogCount{
+		{ModelName: "gpt-a", Type: model.LogTypeError, Count: 10},
+		{ModelName: "gpt-b", Type: model.LogTypeError, Count: 6},
+		{ModelName: "gpt-c", Type: model.LogTypeError, Count: 3},
+	}
+	text := formatTelegramErrorSpikes(60, current, both)
+	require.Contains(t, text, "gpt-a：9（之前 1）")
+	require.Contains(t, text, "gpt-b：2（之前 4）")
+	require.NotContains(t, text, "gpt-c")
+	require.Less(t, strings.Index(text, "gpt-a"), strings.Index(text, "gpt-b"))
+
+	require.Contains(t, formatTelegramErrorSpikes(30, nil, nil), "30")
+}
+
+func TestTelegramPendingAction(t *testing.T) {
+	telegramPending["n1"] = &telegramPendingAction{TelegramId: 1, UserId: 2, ChannelId: 3, ExpiresAt: time.Now().Add(time.Minute)}
+	telegramPending["n2"] = &telegramPendingAction{TelegramId: 1, UserId: 2, ChannelId: 3, ExpiresAt: time.Now().Add(-time.Minute)}
+	t.Cleanup(func() {
+		delete(telegramPending, "n1")
+		delete(telegramPending, "n2")
+	})
+
+	require.Nil(t, takeTelegramPendingAction("n1", 99))
+	action := takeTelegramPendingAction("n1", 1)
+	require.NotNil(t, action)
+	require.Equal(t, 3, action.ChannelId)
+	require.Nil(t, takeTelegramPendingAction("n1", 1))
+	require.Nil(t, takeTelegramPendingAction("n2", 1))
+}
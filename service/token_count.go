@@ -0,0 +1,94 @@
+package service
+
+import (
+	"encoding/json"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/dto"
+	"github.com/QuantumNous/new-api/types"
+
+	"github.com/gin-gonic/gin"
+)
+
+// POST /v1/token/count：按目标模型估算请求的提示词 token 数，估算方式与转发请求时预扣费一致（不受 CountToken 开关影响）。
+// 明细按消息（Responses 请求为 input 条目）分别估算，包含每条消息的格式化开销；
+// 工具定义、instructions 等不属于任何消息的部分只计入总数，因此明细之和可能小于总数
+
+// CountChatRequestTokens 估算 Chat Completions 请求的提示词 token 数
+func CountChatRequestTokens(c *gin.Context, request *dto.GeneralOpenAIRequest) (*dto.TokenCountResponse, error) {
+	total, err := estimateTokenCountMeta(c, request.GetTokenCountMeta(), request.Model, types.RelayFormatOpenAI, false)
+	if err != nil {
+		return nil, err
+	}
+	messages := make([]dto.TokenCountMessage, 0, len(request.Messages))
+	for i, message := range request.Messages {
+		meta := (&dto.GeneralOpenAIRequest{Messages: []dto.Message{message}}).GetTokenCountMeta()
+		tokens, err := estimateTokenCountMeta(c, meta, request.Model, "", false)
+		if err != nil {
+			return nil, err
+		}
+		messages = append(messages, dto.TokenCountMessage{
+			Index:  i,
+			Role:   message.Role,
+			Tokens: tokens + messageFormatTokens(meta),
+		})
+	}
+	return newTokenCountResponse(request.Model, total, messages), nil
+}
+
+// CountResponsesRequestTokens 估算 Responses 请求的提示词 token 数
+func CountResponsesRequestTokens(c *gin.Context, request *dto.OpenAIResponsesRequest) (*dto.TokenCountResponse, error) {
+	total, err := estimateTokenCountMeta(c, request.GetTokenCountMeta(), request.Model, types.RelayFormatOpenAIResponses, false)
+	if err != nil {
+		return nil, err
+	}
+	var items []json.RawMessage
+	switch common.GetJsonType(request.Input) {
+	case "string":
+		items = []json.RawMessage{request.Input}
+	case "array":
+		if err := common.Unmarshal(request.Input, &items); err != nil {
+			return nil, err
+		}
+	}
+	messages := make([]dto.TokenCountMessage, 0, len(items))
+	for i, item := range items {
+		message := dto.TokenCountMessage{Index: i}
+		input := item
+		if common.GetJsonType(item) == "string" {
+			message.Type = "message"
+			message.Role = "user"
+		} else {
+			var head dto.Input
+			_ = common.Unmarshal(item, &head)
+			message.Type = head.Type
+			message.Role = head.Role
+			if message.Type == "" {
+				message.Type = "message"
+			}
+			input = append(append(json.RawMessage{'['}, item...), ']')
+		}
+		meta := (&dto.OpenAIResponsesRequest{Input: input}).GetTokenCountMeta()
+		message.Tokens, err = estimateTokenCountMeta(c, meta, request.Model, "", false)
+		if err != nil {
+			return nil, err
+		}
+		messages = append(messages, message)
+	}
+	return newTokenCountResponse(request.Model, total, messages), nil
+}
+
+// CountClaudeRequestTokens 估算 Claude Messages 请求的输入 token 数，供 /v1/messages/count_tokens 使用
+func CountClaudeRequestTokens(c *gin.Context, request *dto.ClaudeRequest) (int, error) {
+	return estimateTokenCountMeta(c, request.GetTokenCountMeta(), request.Model, types.RelayFormatClaude, false)
+}
+
+func newTokenCountResponse(model string, total int, messages []dto.TokenCountMessage) *dto.TokenCountResponse {
+	return &dto.TokenCountResponse{
+		Object:      "token_count",
+		Model:       model,
+		Tokenizer:   TokenizerName(model),
+		InputTokens: total,
+		Messages:    messages,
+	}
+}
@@ -0,0 +1,71 @@
+package service
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/QuantumNous/new-api/dto"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCountRequestTokens(t *testing.T) {
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+
+	chatResp, err := CountChatRequestTokens(c, &dto.GeneralOpenAIRequest{
+		Model: "gpt-4o",
+		Messages: []dto.Message{
+			{Role: "system", Content: "You are a helpful assistant."},
+			{Role: "user", Content: "How many tokens is this sentence?"},
+		},
+	})
+	require.NoError(t, err)
+	require.Equal(t, "token_count", chatResp.Object)
+	require.Equal(t, "tiktoken", chatResp.Tokenizer)
+	require.Len(t, chatResp.Messages, 2)
+	sum := 0
+	for i, message := range chatResp.Messages {
+		require.Equal(t, i, message.Index)
+		require.Greater(t, message.Tokens, 3)
+		sum += message.Tokens
+	}
+	require.Equal(t, "user", chatResp.Messages[1].Role)
+	require.GreaterOrEqual(t, chatResp.InputTokens, sum)
+
+	responsesResp, err := CountResponsesRequestTokens(c, &dto.OpenAIResponsesRequest{
+		Model:        "claude-sonnet-4",
+		Instructions: json.RawMessage(`"Answer briefly."`),
+		Input:        json.RawMessage(`[{"role":"user","content":"hello there"},{"type":"message","role":"assistant","content":[{"type":"output_text","text":"hi"}]}]`),
+	})
+	require.NoError(t, err)
+	require.Equal(t, "estimate:claude", responsesResp.Tokenizer)
+	require.Len(t, responsesResp.Messages, 2)
+	require.Equal(t, dto.TokenCountMessage{Index: 0, Type: "message", Role: "user", Tokens: responsesResp.Messages[0].Tokens}, responsesResp.Messages[0])
+	require.Positive(t, responsesResp.Messages[1].Tokens)
+	require.Greater(t, responsesResp.InputTokens, responsesResp.Messages[0].Tokens+responsesResp.Messages[1].Tokens)
+
+	stringResp, err := CountResponsesRequestTokens(c, &dto.OpenAIResponsesRequest{Model: "gpt-4o", Input: json.RawMessage(`"hello there"`)})
+	require.NoError(t, err)
+	require.Len(t, stringResp.Messages, 1)
+	require.Equal(t, stringResp.InputTokens, stringResp.Messages[0].Tokens)
+}
+
+func TestCountClaudeRequestTokens(t *testing.T) {
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+
+	short, err := CountClaudeRequestTokens(c, &dto.ClaudeRequest{
+		Model:    "claude-sonnet-4",
+		Messages: []dto.ClaudeMessage{{Role: "user", Content: "hello"}},
+	})
+	require.NoError(t, err)
+	require.Positive(t, short)
+
+	long, err := CountClaudeRequestTokens(c, &dto.ClaudeRequest{
+		Model:    "claude-sonnet-4",
+		System:   "You are a helpful assistant.",
+		Messages: []dto.ClaudeMessage{{Role: "user", Content: "hello, how many tokens does this request use?"}},
+	})
+	require.NoError(t, err)
+	require.Greater(t, long, short)
+}
@@ -219,6 +219,17 @@ func EstimateRequestToken(c *gin.Context, meta *types.TokenCountMeta, info *rela
 	}
 
 	model := common.GetContextKeyString(c, constant.ContextKeyOriginalModel)
+	tkm, err := estimateTokenCountMeta(c, meta, model, info.RelayFormat, info.IsStream)
+	if err != nil {
+		return 0, err
+	}
+
+	common.SetContextKey(c, constant.ContextKeyPromptTokens, tkm)
+	return tkm, nil
+}
+
+// estimateTokenCountMeta 估算请求的文本、格式化开销与媒体文件的 token 数
+func estimateTokenCountMeta(c *gin.Context, meta *types.TokenCountMeta, model string, relayFormat types.RelayFormat, isStream bool) (int, error) {
 	tkm := 0
 
 	if meta.TokenType == types.TokenTypeTextNumber {
@@ -227,16 +238,15 @@ func EstimateRequestToken(c *gin.Context, meta *types.TokenCountMeta, info *rela
 		tkm += CountTextToken(meta.CombineText, model)
 	}
 
-	if info.RelayFormat == types.RelayFormatOpenAI {
+	if relayFormat == types.RelayFormatOpenAI {
 		tkm += meta.ToolsCount * 8
-		tkm += meta.MessagesCount * 3 // 每条消息的格式化token数量
-		tkm += meta.NameCount * 3
+		tkm += messageFormatTokens(meta)
 		tkm += 3
 	}
 
 	shouldFetchFiles := true
 
-	if info.RelayFormat == types.RelayFormatGemini {
+	if relayFormat == types.RelayFormatGemini {
 		shouldFetchFiles = false
 	}
 
@@ -246,7 +256,7 @@ func EstimateRequestToken(c *gin.Context, meta *types.TokenCountMeta, info *rela
 	}
 
 	// 是否在非流模式下本地计算媒体token数量
-	if !constant.GetMediaTokenNotStream && !info.IsStream {
+	if !constant.GetMediaTokenNotStream && !isStream {
 		shouldFetchFiles = false
 	}
 
@@ -277,7 +287,7 @@ func EstimateRequestToken(c *gin.Context, meta *types.TokenCountMeta, info *rela
 		switch file.FileType {
 		case types.FileTypeImage:
 			if common.IsOpenAITextModel(model) {
-				token, err := getImageToken(c, file, model, info.IsStream)
+				token, err := getImageToken(c, file, model, isStream)
 				if err != nil {
 					return 0, fmt.Errorf("error counting image token, media index[%d], identifier[%s], err: %v", i, file.GetIdentifier(), err)
 				}
@@ -295,11 +305,14 @@ func EstimateRequestToken(c *gin.Context, meta *types.TokenCountMeta, info *rela
 			tkm += 4096 // Default case for unknown file types
 		}
 	}
-
-	common.SetContextKey(c, constant.ContextKeyPromptTokens, tkm)
 	return tkm, nil
 }
 
+// messageFormatTokens 返回 OpenAI 格式中消息与 name 的格式化 token 数
+func messageFormatTokens(meta *types.TokenCountMeta) int {
+	return meta.MessagesCount*3 + meta.NameCount*3
+}
+
 func CountTokenRealtime(info *relaycommon.RelayInfo, request dto.RealtimeEvent, model string) (int, int, error) {
 	audioToken := 0
 	textToken := 0
@@ -309,14 +322,14 @@ func CountTokenRealtime(info *relaycommon.RelayInfo, request dto.RealtimeEvent,
 			msgTokens := CountTextToken(request.Session.Instructions, model)
 			textToken += msgTokens
 		}
-	case dto.RealtimeEventResponseAudioDelta:
+	case dto.RealtimeEventResponseAudioDelta, dto.RealtimeEventResponseOutputAudioDelta:
 		// count audio token
 		atk, err := CountAudioTokenOutput(request.Delta, info.OutputAudioFormat)
 		if err != nil {
 			return 0, 0, fmt.Errorf("error counting audio token: %v", err)
 		}
 		audioToken += atk
-	case dto.RealtimeEventResponseAudioTranscriptionDelta, dto.RealtimeEventResponseFunctionCallArgumentsDelta:
+	case dto.RealtimeEventResponseAudioTranscriptionDelta, dto.RealtimeEventResponseOutputAudioTranscriptDelta, dto.RealtimeEventResponseFunctionCallArgumentsDelta:
 		// count text token
 		tkm := CountTextToken(request.Delta, model)
 		textToken += tkm
@@ -396,6 +409,14 @@ func CountAudioTokenOutput(audioBase64 string, audioFormat string) (int, error)
 	return int(duration / 60 * 200 / 0.24), nil
 }
 
+// TokenizerName 返回 CountTextToken 统计该模型文本时使用的方式：tiktoken，或按厂商估算的 estimate:<厂商>
+func TokenizerName(model string) string {
+	if common.IsOpenAITextModel(model) {
+		return "tiktoken"
+	}
+	return "estimate:" + string(estimateProvider(model))
+}
+
 // CountTextToken 统计文本的token数量，仅OpenAI模型使用tokenizer，其余模型使用估算
 func CountTextToken(text string, model string) int {
 	if text == "" {
@@ -219,12 +219,16 @@ func EstimateTokenByModel(model, text string) int {
 		return 0
 	}
 
+	return EstimateToken(estimateProvider(model), text)
+}
+
+// estimateProvider 根据模型名称选择估算使用的厂商权重
+func estimateProvider(model string) Provider {
 	model = strings.ToLower(model)
 	if strings.Contains(model, "gemini") {
-		return EstimateToken(Gemini, text)
+		return Gemini
 	} else if strings.Contains(model, "claude") {
-		return EstimateToken(Claude, text)
-	} else {
-		return EstimateToken(OpenAI, text)
+		return Claude
 	}
+	return OpenAI
 }
@@ -0,0 +1,84 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/logger"
+	"github.com/QuantumNous/new-api/model"
+
+	"github.com/bytedance/gopkg/util/gopool"
+)
+
+const (
+	tokenQuotaResetTickInterval = 1 * time.Minute
+	tokenQuotaResetBatchSize    = 300
+)
+
+var (
+	tokenQuotaResetOnce    sync.Once
+	tokenQuotaResetRunning atomic.Bool
+)
+
+// StartTokenQuotaResetTask 在主节点定期重置到期令牌的已用额度（按令牌的 week / month 周期），并回收已过期的临时令牌
+func StartTokenQuotaResetTask() {
+	tokenQuotaResetOnce.Do(func() {
+		if !common.IsMasterNode {
+			return
+		}
+		gopool.Go(func() {
+			logger.LogInfo(context.Background(), fmt.Sprintf("token quota reset task started: tick=%s", tokenQuotaResetTickInterval))
+			ticker := time.NewTicker(tokenQuotaResetTickInterval)
+			defer ticker.Stop()
+
+			runTokenQuotaResetOnce()
+			for range ticker.C {
+				runTokenQuotaResetOnce()
+			}
+		})
+	})
+}
+
+func runTokenQuotaResetOnce() {
+	if !tokenQuotaResetRunning.CompareAndSwap(false, true) {
+		return
+	}
+	defer tokenQuotaResetRunning.Store(false)
+
+	ctx := context.Background()
+	totalReset := 0
+	for {
+		n, err := model.ResetDueTokenQuotas(tokenQuotaResetBatchSize)
+		if err != nil {
+			logger.LogWarn(ctx, fmt.Sprintf("token quota reset task failed: %v", err))
+			return
+		}
+		totalReset += n
+		if n < tokenQuotaResetBatchSize {
+			break
+		}
+	}
+	if common.DebugEnabled && totalReset > 0 {
+		logger.LogDebug(ctx, "token quota reset: reset_count=%d", totalReset)
+	}
+
+	totalReleased := 0
+	for {
+		n, err := model.ReleaseExpiredTemporaryTokens(tokenQuotaResetBatchSize)
+		if err != nil {
+			logger.LogWarn(ctx, fmt.Sprintf("temporary token release failed: %v", err))
+			return
+		}
+		totalReleased += n
+		if n < tokenQuotaResetBatchSize {
+			break
+		}
+	}
+	if common.DebugEnabled && totalReleased > 0 {
+		logger.LogDebug(ctx, "temporary token release: release_count=%d", totalReleased)
+	}
+}
@@ -0,0 +1,112 @@
+package service
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/constant"
+	"github.com/QuantumNous/new-api/model"
+
+	"github.com/bytedance/gopkg/util/gopool"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// TokenUsageTagsHeader 调用方通过该请求头为本次请求附加标签，逗号分隔
+	TokenUsageTagsHeader = "X-Usage-Tags"
+
+	tokenUsageMaxTags   = 16
+	tokenUsageMaxTagLen = 64
+)
+
+// TokenUsageWebhookPayload 令牌用量推送的负载数据
+type TokenUsageWebhookPayload struct {
+	Type             string   `json:"type"`
+	RequestId        string   `json:"request_id"`
+	UserId           int      `json:"user_id"`
+	TokenId          int      `json:"token_id"`
+	TokenName        string   `json:"token_name"`
+	Model            string   `json:"model"`
+	Group            string   `json:"group"`
+	PromptTokens     int      `json:"prompt_tokens"`
+	CompletionTokens int      `json:"completion_tokens"`
+	TotalTokens      int      `json:"total_tokens"`
+	Quota            int      `json:"quota"`
+	Cost             float64  `json:"cost"`
+	LatencyMs        int64    `json:"latency_ms"`
+	IsStream         bool     `json:"is_stream"`
+	Tags             []string `json:"tags"`
+	Timestamp        int64    `json:"timestamp"`
+}
+
+// parseUsageTags 解析请求头中的标签，去除空白和重复项并限制数量与长度
+func parseUsageTags(header string) []string {
+	tags := make([]string, 0)
+	seen := make(map[string]struct{})
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "" || len(tag) > tokenUsageMaxTagLen {
+			continue
+		}
+		if _, ok := seen[tag]; ok {
+			continue
+		}
+		seen[tag] = struct{}{}
+		tags = append(tags, tag)
+		if len(tags) >= tokenUsageMaxTags {
+			break
+		}
+	}
+	return tags
+}
+
+// BuildTokenUsageWebhookPayload 根据请求上下文和消费日志参数构建用量推送负载
+func BuildTokenUsageWebhookPayload(c *gin.Context, userId int, params model.RecordConsumeLogParams) TokenUsageWebhookPayload {
+	latencyMs := int64(params.UseTimeSeconds) * 1000
+	if startTime := common.GetContextKeyTime(c, constant.ContextKeyRequestStartTime); !startTime.IsZero() {
+		latencyMs = time.Since(startTime).Milliseconds()
+	}
+	return TokenUsageWebhookPayload{
+		Type:             "token_usage",
+		RequestId:        c.GetString(common.RequestIdKey),
+		UserId:           userId,
+		TokenId:          params.TokenId,
+		TokenName:        params.TokenName,
+		Model:            params.ModelName,
+		Group:            params.Group,
+		PromptTokens:     params.PromptTokens,
+		CompletionTokens: params.CompletionTokens,
+		TotalTokens:      params.PromptTokens + params.CompletionTokens,
+		Quota:            params.Quota,
+		Cost:             float64(params.Quota) / common.QuotaPerUnit,
+		LatencyMs:        latencyMs,
+		IsStream:         params.IsStream,
+		Tags:             parseUsageTags(c.GetHeader(TokenUsageTagsHeader)),
+		Timestamp:        time.Now().Unix(),
+	}
+}
+
+// NotifyTokenUsageWebhook 在令牌配置了用量推送地址时异步推送本次请求的用量，签名方式与通知 webhook 相同
+func NotifyTokenUsageWebhook(c *gin.Context, userId int, params model.RecordConsumeLogParams) {
+	if c == nil {
+		return
+	}
+	webhookURL := common.GetContextKeyString(c, constant.ContextKeyTokenUsageWebhook)
+	if webhookURL == "" {
+		return
+	}
+	secret := common.GetContextKeyString(c, constant.ContextKeyTokenUsageWebhookKey)
+	payload := BuildTokenUsageWebhookPayload(c, userId, params)
+	payloadBytes, err := common.Marshal(payload)
+	if err != nil {
+		common.SysError(fmt.Sprintf("failed to marshal token usage webhook payload: %v", err))
+		return
+	}
+	gopool.Go(func() {
+		if err := postWebhook(webhookURL, secret, payloadBytes); err != nil {
+			common.SysLog(fmt.Sprintf("failed to send token usage webhook for token %d, request %s: %v", payload.TokenId, payload.RequestId, err))
+		}
+	})
+}
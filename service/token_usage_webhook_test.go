@@ -0,0 +1,77 @@
+package service
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/constant"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/setting/system_setting"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseUsageTags(t *testing.T) {
+	assert.Equal(t, []string{"team-a", "batch"}, parseUsageTags(" team-a, ,batch,team-a"))
+	assert.Empty(t, parseUsageTags(""))
+}
+
+func TestNotifyTokenUsageWebhook(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	fetchSetting := system_setting.GetFetchSetting()
+	originSSRF := fetchSetting.EnableSSRFProtection
+	fetchSetting.EnableSSRFProtection = false
+	t.Cleanup(func() { fetchSetting.EnableSSRFProtection = originSSRF })
+	InitHttpClient()
+
+	type received struct {
+		signature string
+		body      []byte
+	}
+	ch := make(chan received, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		ch <- received{signature: r.Header.Get("X-Webhook-Signature"), body: body}
+	}))
+	defer server.Close()
+
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodPost, "/v1/chat/completions", nil)
+	c.Request.Header.Set(TokenUsageTagsHeader, "team-a,nightly")
+	c.Set(common.RequestIdKey, "req-1")
+	common.SetContextKey(c, constant.ContextKeyTokenUsageWebhook, server.URL)
+	common.SetContextKey(c, constant.ContextKeyTokenUsageWebhookKey, "secret")
+	common.SetContextKey(c, constant.ContextKeyRequestStartTime, time.Now().Add(-1500*time.Millisecond))
+
+	NotifyTokenUsageWebhook(c, 7, model.RecordConsumeLogParams{
+		ModelName:        "gpt-4o",
+		TokenName:        "prod",
+		TokenId:          3,
+		PromptTokens:     10,
+		CompletionTokens: 5,
+		Quota:            int(common.QuotaPerUnit),
+	})
+
+	select {
+	case got := <-ch:
+		assert.Equal(t, generateSignature("secret", got.body), got.signature)
+		var payload TokenUsageWebhookPayload
+		require.NoError(t, common.Unmarshal(got.body, &payload))
+		assert.Equal(t, "token_usage", payload.Type)
+		assert.Equal(t, "req-1", payload.RequestId)
+		assert.Equal(t, 7, payload.UserId)
+		assert.Equal(t, 3, payload.TokenId)
+		assert.Equal(t, 15, payload.TotalTokens)
+		assert.InDelta(t, 1.0, payload.Cost, 1e-9)
+		assert.GreaterOrEqual(t, payload.LatencyMs, int64(1500))
+		assert.Equal(t, []string{"team-a", "nightly"}, payload.Tags)
+	case <-time.After(5 * time.Second):
+		t.Fatal("webhook was not delivered")
+	}
+}
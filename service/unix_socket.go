@@ -0,0 +1,147 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+)
+
+// 渠道地址支持 unix://<套接字绝对路径>[:<路径前缀>]，例如 unix:///run/vllm.sock 或
+// unix:///run/vllm.sock:/openai，用于以 sidecar 方式部署在同一 Pod / 主机上的本地推理服务。
+// 请求路径直接拼接在渠道地址之后，因此渠道测试、模型拉取等探测走同一条传输通道。
+const UnixSocketScheme = "unix"
+
+type unixSocketRoundTripper struct {
+	lock       sync.Mutex
+	transports map[string]*http.Transport
+}
+
+var (
+	unixSocketTransport  = &unixSocketRoundTripper{transports: make(map[string]*http.Transport)}
+	unixSocketClient     *http.Client
+	unixSocketClientOnce sync.Once
+)
+
+// GetUnixSocketHttpClient 返回 Unix 套接字渠道专用的客户端，只处理 unix:// 地址；
+// 默认客户端与代理客户端不注册该协议，其他渠道不会被引导到本机套接字
+func GetUnixSocketHttpClient() *http.Client {
+	unixSocketClientOnce.Do(func() {
+		unixSocketClient = &http.Client{
+			Transport:     unixSocketTransport,
+			Timeout:       time.Duration(common.RelayTimeout) * time.Second,
+			CheckRedirect: checkRedirect,
+		}
+	})
+	return unixSocketClient
+}
+
+// GetHttpClientForBaseURL 返回访问渠道地址的客户端：Unix 套接字地址使用专用客户端（本机连接不走代理），其余按代理设置返回
+func GetHttpClientForBaseURL(baseURL string, proxyURL string) (*http.Client, error) {
+	if IsUnixSocketBaseURL(baseURL) {
+		return GetUnixSocketHttpClient(), nil
+	}
+	return GetHttpClientWithProxy(proxyURL)
+}
+
+// IsUnixSocketBaseURL 判断渠道地址是否指向 Unix 套接字
+func IsUnixSocketBaseURL(baseURL string) bool {
+	return strings.HasPrefix(baseURL, UnixSocketScheme+"://")
+}
+
+// ValidateUnixSocketBaseURL 校验 Unix 套接字渠道地址的格式，套接字文件可以在保存后才创建
+func ValidateUnixSocketBaseURL(baseURL string) error {
+	u, err := url.Parse(baseURL)
+	if err != nil {
+		return err
+	}
+	if u.Host != "" || !strings.HasPrefix(u.Path, "/") {
+		return fmt.Errorf("unix socket base URL must look like unix:///absolute/path.sock[:/prefix]")
+	}
+	if u.RawQuery != "" || u.Fragment != "" {
+		return errors.New("unix socket base URL must not contain a query or fragment")
+	}
+	return nil
+}
+
+// splitUnixSocketURL 拆分出套接字路径与 HTTP 请求路径。未使用 ':' 分隔时，
+// 取路径中第一个实际存在的套接字文件作为套接字路径。
+func splitUnixSocketURL(u *url.URL) (string, string, error) {
+	if u.Host != "" || !strings.HasPrefix(u.Path, "/") {
+		return "", "", fmt.Errorf("invalid unix socket URL %q", u.String())
+	}
+	path := u.Path
+	if idx := strings.Index(path, ":"); idx >= 0 {
+		return path[:idx], ensureLeadingSlash(path[idx+1:]), nil
+	}
+	for i := 1; i <= len(path); i++ {
+		if i < len(path) && path[i] != '/' {
+			continue
+		}
+		if info, err := os.Stat(path[:i]); err == nil && info.Mode()&os.ModeSocket != 0 {
+			return path[:i], ensureLeadingSlash(path[i:]), nil
+		}
+	}
+	return "", "", fmt.Errorf("no unix socket found in %q", path)
+}
+
+func ensureLeadingSlash(path string) string {
+	if !strings.HasPrefix(path, "/") {
+		return "/" + path
+	}
+	return path
+}
+
+func (rt *unixSocketRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	socketPath, path, err := splitUnixSocketURL(req.URL)
+	if err != nil {
+		if req.Body != nil {
+			_ = req.Body.Close()
+		}
+		return nil, err
+	}
+	target := *req.URL
+	target.Scheme = "http"
+	target.Host = "localhost"
+	target.Path = path
+	target.RawPath = ""
+	outReq := req.Clone(req.Context())
+	outReq.URL = &target
+	outReq.Host = "localhost"
+	return rt.transport(socketPath).RoundTrip(outReq)
+}
+
+func (rt *unixSocketRoundTripper) transport(socketPath string) *http.Transport {
+	rt.lock.Lock()
+	defer rt.lock.Unlock()
+	if transport, ok := rt.transports[socketPath]; ok {
+		return transport
+	}
+	dialer := &net.Dialer{}
+	transport := &http.Transport{
+		MaxIdleConns:        common.RelayMaxIdleConns,
+		MaxIdleConnsPerHost: common.RelayMaxIdleConnsPerHost,
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return dialer.DialContext(ctx, "unix", socketPath)
+		},
+	}
+	rt.transports[socketPath] = transport
+	return transport
+}
+
+func (rt *unixSocketRoundTripper) reset() {
+	rt.lock.Lock()
+	defer rt.lock.Unlock()
+	for _, transport := range rt.transports {
+		transport.CloseIdleConnections()
+	}
+	rt.transports = make(map[string]*http.Transport)
+}
@@ -0,0 +1,58 @@
+package service
+
+import (
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestUnixSocketBaseURL(t *testing.T) {
+	dir, err := os.MkdirTemp("", "uds")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	socketPath := filepath.Join(dir, "llm.sock")
+	listener, err := net.Listen("unix", socketPath)
+	require.NoError(t, err)
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, r.Host+" "+r.URL.RequestURI())
+	}))
+	server.Listener = listener
+	server.Start()
+	defer server.Close()
+
+	client := GetUnixSocketHttpClient()
+	get := func(rawURL string) string {
+		resp, err := client.Get(rawURL)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		return string(body)
+	}
+
+	require.Equal(t, "localhost /v1/models?a=1", get("unix://"+socketPath+"/v1/models?a=1"))
+	require.Equal(t, "localhost /openai/v1/models", get("unix://"+socketPath+":/openai/v1/models"))
+	require.Equal(t, "localhost /", get("unix://"+socketPath))
+
+	_, err = client.Get("unix://" + filepath.Join(dir, "missing.sock") + "/v1/models")
+	require.Error(t, err)
+
+	// 共享的默认客户端不处理 unix:// 地址
+	InitHttpClient()
+	_, err = GetHttpClient().Get("unix://" + socketPath + "/v1/models")
+	require.Error(t, err)
+	viaBaseURL, err := GetHttpClientForBaseURL("unix://"+socketPath, "")
+	require.NoError(t, err)
+	require.Same(t, client, viaBaseURL)
+
+	require.True(t, IsUnixSocketBaseURL("unix:///run/llm.sock"))
+	require.NoError(t, ValidateUnixSocketBaseURL("unix:///run/llm.sock:/openai"))
+	require.Error(t, ValidateUnixSocketBaseURL("unix://run/llm.sock"))
+	require.Error(t, ValidateUnixSocketBaseURL("unix:///run/llm.sock?x=1"))
+}
@@ -0,0 +1,162 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+)
+
+const (
+	UpstreamIPPreferenceIPv4Only   = "ipv4"        // 只连接 IPv4 地址
+	UpstreamIPPreferenceIPv6Only   = "ipv6"        // 只连接 IPv6 地址
+	UpstreamIPPreferencePreferIPv4 = "prefer_ipv4" // 先连接 IPv4，超过回退延迟后并行尝试 IPv6
+	UpstreamIPPreferencePreferIPv6 = "prefer_ipv6" // 先连接 IPv6，超过回退延迟后并行尝试 IPv4
+
+	defaultUpstreamFallbackDelay = 300 * time.Millisecond
+)
+
+// UpstreamDialerOptions 渠道级拨号设置，用于绕开上游损坏的 IPv6 线路
+type UpstreamDialerOptions struct {
+	IPPreference    string            `json:"ip_preference,omitempty"`     // 为空时使用系统默认的双栈 happy eyeballs
+	FallbackDelayMs int               `json:"fallback_delay_ms,omitempty"` // happy eyeballs 回退延迟，0 使用默认 300ms，负数关闭并行回退
+	Hosts           map[string]string `json:"hosts,omitempty"`             // 静态解析，主机名 -> IP
+}
+
+// IsEmpty 未配置任何设置时使用默认拨号器
+func (o UpstreamDialerOptions) IsEmpty() bool {
+	return o.IPPreference == "" && o.FallbackDelayMs == 0 && len(o.Hosts) == 0
+}
+
+// ValidateUpstreamDialerOptions 校验 IP 偏好与静态解析设置
+func ValidateUpstreamDialerOptions(options UpstreamDialerOptions) error {
+	_, err := newUpstreamDialer(options)
+	return err
+}
+
+type upstreamDialer struct {
+	dialer        net.Dialer
+	preference    string
+	fallbackDelay time.Duration
+	hosts         map[string]string
+}
+
+func newUpstreamDialer(options UpstreamDialerOptions) (*upstreamDialer, error) {
+	switch options.IPPreference {
+	case "", UpstreamIPPreferenceIPv4Only, UpstreamIPPreferenceIPv6Only, UpstreamIPPreferencePreferIPv4, UpstreamIPPreferencePreferIPv6:
+	default:
+		return nil, fmt.Errorf("unsupported ip_preference %q, must be ipv4, ipv6, prefer_ipv4 or prefer_ipv6", options.IPPreference)
+	}
+	hosts := make(map[string]string, len(options.Hosts))
+	for host, ip := range options.Hosts {
+		host = strings.ToLower(strings.TrimSpace(host))
+		if host == "" {
+			return nil, fmt.Errorf("hosts mapping contains an empty host name")
+		}
+		if net.ParseIP(strings.TrimSpace(ip)) == nil {
+			return nil, fmt.Errorf("hosts mapping of %s must be an IP address, got %q", host, ip)
+		}
+		hosts[host] = strings.TrimSpace(ip)
+	}
+	fallbackDelay := time.Duration(options.FallbackDelayMs) * time.Millisecond
+	if options.FallbackDelayMs == 0 {
+		fallbackDelay = defaultUpstreamFallbackDelay
+	}
+	return &upstreamDialer{
+		// net.Dialer 的 FallbackDelay 为负数时关闭双栈并行回退
+		dialer:        net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second, FallbackDelay: fallbackDelay},
+		preference:    options.IPPreference,
+		fallbackDelay: fallbackDelay,
+		hosts:         hosts,
+	}, nil
+}
+
+func (d *upstreamDialer) DialContext(ctx context.Context, network string, addr string) (net.Conn, error) {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+	if ip, ok := d.hosts[strings.ToLower(host)]; ok {
+		addr = net.JoinHostPort(ip, port)
+	}
+	switch d.preference {
+	case UpstreamIPPreferenceIPv4Only:
+		return d.dialer.DialContext(ctx, "tcp4", addr)
+	case UpstreamIPPreferenceIPv6Only:
+		return d.dialer.DialContext(ctx, "tcp6", addr)
+	case UpstreamIPPreferencePreferIPv4:
+		return d.dialPreferred(ctx, "tcp4", "tcp6", addr)
+	case UpstreamIPPreferencePreferIPv6:
+		return d.dialPreferred(ctx, "tcp6", "tcp4", addr)
+	default:
+		return d.dialer.DialContext(ctx, network, addr)
+	}
+}
+
+type dialResult struct {
+	conn    net.Conn
+	err     error
+	primary bool
+}
+
+// dialPreferred 先连接首选地址族，失败或超过回退延迟仍未连上时并行连接另一地址族，返回先成功的连接
+func (d *upstreamDialer) dialPreferred(ctx context.Context, primary string, fallback string, addr string) (net.Conn, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	results := make(chan dialResult, 2)
+	dial := func(network string, isPrimary bool) {
+		conn, err := d.dialer.DialContext(ctx, network, addr)
+		results <- dialResult{conn: conn, err: err, primary: isPrimary}
+	}
+	go dial(primary, true)
+
+	var fallbackTimer <-chan time.Time
+	if d.fallbackDelay > 0 {
+		timer := time.NewTimer(d.fallbackDelay)
+		defer timer.Stop()
+		fallbackTimer = timer.C
+	}
+	fallbackStarted := false
+	pending := 1
+	var primaryErr error
+	for {
+		select {
+		case <-fallbackTimer:
+			if !fallbackStarted {
+				fallbackStarted = true
+				pending++
+				go dial(fallback, false)
+			}
+		case result := <-results:
+			pending--
+			if result.err == nil {
+				if pending > 0 {
+					// 关闭较晚建立的另一条连接
+					go func() {
+						if late := <-results; late.conn != nil {
+							_ = late.conn.Close()
+						}
+					}()
+				}
+				return result.conn, nil
+			}
+			if result.primary {
+				primaryErr = result.err
+			}
+			if !fallbackStarted {
+				fallbackStarted = true
+				pending++
+				go dial(fallback, false)
+				continue
+			}
+			if pending == 0 {
+				if primaryErr != nil {
+					return nil, primaryErr
+				}
+				return nil, result.err
+			}
+		}
+	}
+}
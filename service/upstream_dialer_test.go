@@ -0,0 +1,47 @@
+package service
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestUpstreamDialer(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+	serverURL, err := url.Parse(server.URL)
+	require.NoError(t, err)
+	port := serverURL.Port()
+
+	get := func(options UpstreamDialerOptions, rawURL string) error {
+		dialer, err := newUpstreamDialer(options)
+		require.NoError(t, err)
+		client := &http.Client{Transport: &http.Transport{DialContext: dialer.DialContext}}
+		resp, err := client.Get(rawURL)
+		if err == nil {
+			resp.Body.Close()
+		}
+		return err
+	}
+
+	hosts := map[string]string{"Upstream.Internal": "127.0.0.1"}
+	require.NoError(t, get(UpstreamDialerOptions{Hosts: hosts}, "http://upstream.internal:"+port))
+	require.NoError(t, get(UpstreamDialerOptions{IPPreference: UpstreamIPPreferenceIPv4Only, Hosts: hosts}, "http://upstream.internal:"+port))
+	require.Error(t, get(UpstreamDialerOptions{IPPreference: UpstreamIPPreferenceIPv6Only}, server.URL))
+	// the IPv6 attempt fails and the dialer falls back to IPv4
+	require.NoError(t, get(UpstreamDialerOptions{IPPreference: UpstreamIPPreferencePreferIPv6, FallbackDelayMs: -1}, server.URL))
+	require.NoError(t, get(UpstreamDialerOptions{IPPreference: UpstreamIPPreferencePreferIPv6}, server.URL))
+}
+
+func TestValidateUpstreamDialerOptions(t *testing.T) {
+	require.True(t, UpstreamDialerOptions{}.IsEmpty())
+	require.NoError(t, ValidateUpstreamDialerOptions(UpstreamDialerOptions{IPPreference: UpstreamIPPreferencePreferIPv4, Hosts: map[string]string{"api.example.com": "2001:db8::1"}}))
+	require.Error(t, ValidateUpstreamDialerOptions(UpstreamDialerOptions{IPPreference: "ipv5"}))
+	require.Error(t, ValidateUpstreamDialerOptions(UpstreamDialerOptions{Hosts: map[string]string{"api.example.com": "not-an-ip"}}))
+	require.Error(t, ValidateUpstreamDialerOptions(UpstreamDialerOptions{Hosts: map[string]string{" ": "127.0.0.1"}}))
+}
@@ -0,0 +1,320 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/logger"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/setting/system_setting"
+
+	"github.com/bytedance/gopkg/util/gopool"
+)
+
+const (
+	usageReportTickInterval = 1 * time.Hour
+	usageReportTopTokens    = 10
+)
+
+var (
+	usageReportOnce    sync.Once
+	usageReportRunning atomic.Bool
+)
+
+// UsageReport is the usage of a user or tenant in one report period. It is served by
+// the analytics API and delivered as-is to webhook subscribers.
+type UsageReport struct {
+	Scope          string                   `json:"scope"`
+	TargetId       int                      `json:"target_id"`
+	Title          string                   `json:"title"`
+	Period         string                   `json:"period"`
+	StartTimestamp int64                    `json:"start_timestamp"`
+	EndTimestamp   int64                    `json:"end_timestamp"`
+	TotalQuota     int64                    `json:"total_quota"`
+	TotalRequests  int64                    `json:"total_requests"`
+	ErrorCount     int64                    `json:"error_count"`
+	ErrorRate      float64                  `json:"error_rate"`
+	RemainQuota    int64                    `json:"remain_quota"`
+	Models         []model.ReportModelUsage `json:"models"`
+	TopTokens      []model.ReportTokenUsage `json:"top_tokens"`
+	QuotaPools     *model.QuotaPoolSummary  `json:"quota_pools,omitempty"` // Balance breakdown, user reports only
+}
+
+// UsageReportPeriod returns the key and [start, end) timestamps of the last complete
+// week (starting Monday) or month before now.
+func UsageReportPeriod(frequency string, now time.Time) (string, int64, int64) {
+	if frequency == model.ReportFrequencyWeekly {
+		today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+		daysSinceMonday := (int(today.Weekday()) + 6) % 7
+		end := today.AddDate(0, 0, -daysSinceMonday)
+		start := end.AddDate(0, 0, -7)
+		year, week := start.ISOWeek()
+		return fmt.Sprintf("%d-W%02d", year, week), start.Unix(), end.Unix()
+	}
+	end := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
+	start := end.AddDate(0, -1, 0)
+	return start.Format("2006-01"), start.Unix(), end.Unix()
+}
+
+// BuildUsageReport aggregates the usage of a user or tenant (0 for the whole platform)
+// between the timestamps.
+func BuildUsageReport(scope string, targetId int, period string, startTimestamp int64, endTimestamp int64) (*UsageReport, error) {
+	userIds, err := model.GetReportUserIds(scope, targetId)
+	if err != nil {
+		return nil, err
+	}
+	report := &UsageReport{
+		Scope:          scope,
+		TargetId:       targetId,
+		Title:          usageReportTitle(scope, targetId),
+		Period:         period,
+		StartTimestamp: startTimestamp,
+		EndTimestamp:   endTimestamp,
+	}
+	if report.Models, err = model.GetReportModelUsage(userIds, startTimestamp, endTimestamp); err != nil {
+		return nil, err
+	}
+	if report.TopTokens, err = model.GetReportTopTokens(userIds, startTimestamp, endTimestamp, usageReportTopTokens); err != nil {
+		return nil, err
+	}
+	if report.ErrorCount, err = model.GetReportErrorCount(userIds, startTimestamp, endTimestamp); err != nil {
+		return nil, err
+	}
+	if report.RemainQuota, err = model.GetReportRemainQuota(userIds); err != nil {
+		return nil, err
+	}
+	if scope == model.ReportScopeUser {
+		if report.QuotaPools, err = model.GetUserQuotaPoolSummary(targetId); err != nil {
+			return nil, err
+		}
+	}
+	for _, usage := range report.Models {
+		report.TotalQuota += usage.Quota
+		report.TotalRequests += usage.Requests
+	}
+	if total := report.TotalRequests + report.ErrorCount; total > 0 {
+		report.ErrorRate = float64(report.ErrorCount) / float64(total)
+	}
+	return report, nil
+}
+
+func usageReportTitle(scope string, targetId int) string {
+	if scope == model.ReportScopeUser {
+		if username, err := model.GetUsernameById(targetId, false); err == nil {
+			return username
+		}
+		return fmt.Sprintf("user #%d", targetId)
+	}
+	if targetId == 0 {
+		return common.SystemName
+	}
+	if tenant := model.GetCachedTenant(targetId); tenant != nil {
+		return tenant.Name
+	}
+	return fmt.Sprintf("tenant #%d", targetId)
+}
+
+// RenderUsageReportHTML renders the report as the HTML body of the usage report email.
+func RenderUsageReportHTML(report *UsageReport) string {
+	var b strings.Builder
+	b.WriteString(fmt.Sprintf("<p>%s · %s</p>", html.EscapeString(report.Title), html.EscapeString(report.Period)))
+	b.WriteString("<ul>")
+	b.WriteString(fmt.Sprintf("<li>消耗额度：%s</li>", logger.FormatQuota(int(report.TotalQuota))))
+	b.WriteString(fmt.Sprintf("<li>请求次数：%d</li>", report.TotalRequests))
+	b.WriteString(fmt.Sprintf("<li>错误率：%.2f%%（%d 次错误）</li>", report.ErrorRate*100, report.ErrorCount))
+	b.WriteString(fmt.Sprintf("<li>剩余额度：%s</li>", logger.FormatQuota(int(report.RemainQuota))))
+	if pools := report.QuotaPools; pools != nil {
+		b.WriteString(fmt.Sprintf("<li>赠送额度：%s</li>", logger.FormatQuota(pools.Bonus)))
+		b.WriteString(fmt.Sprintf("<li>预付额度：%s</li>", logger.FormatQuota(pools.Prepaid)))
+		if pools.PostpaidLimit > 0 {
+			b.WriteString(fmt.Sprintf("<li>后付费授信：已用 %s / %s</li>", logger.FormatQuota(pools.PostpaidUsed), logger.FormatQuota(pools.PostpaidLimit)))
+		}
+	}
+	b.WriteString("</ul>")
+	if pools := report.QuotaPools; pools != nil && len(pools.Pools) > 0 {
+		b.WriteString("<p>额度池</p><table border='1' cellpadding='4' cellspacing='0'><tr><th>类型</th><th>来源</th><th>剩余 / 总额</th><th>到期时间</th></tr>")
+		for _, pool := range pools.Pools {
+			expiresAt := "永不过期"
+			if pool.ExpiresAt > 0 {
+				expiresAt = time.Unix(pool.ExpiresAt, 0).Format("2006-01-02 15:04")
+			}
+			b.WriteString(fmt.Sprintf("<tr><td>%s</td><td>%s</td><td>%s / %s</td><td>%s</td></tr>",
+				pool.Kind, html.EscapeString(pool.Source), logger.FormatQuota(pool.Remaining), logger.FormatQuota(pool.Total), expiresAt))
+		}
+		b.WriteString("</table>")
+	}
+	if len(report.Models) > 0 {
+		b.WriteString("<p>按模型消耗</p><table border='1' cellpadding='4' cellspacing='0'><tr><th>模型</th><th>请求次数</th><th>Tokens</th><th>消耗额度</th></tr>")
+		for _, usage := range report.Models {
+			b.WriteString(fmt.Sprintf("<tr><td>%s</td><td>%d</td><td>%d</td><td>%s</td></tr>",
+				html.EscapeString(usage.ModelName), usage.Requests, usage.Tokens, logger.FormatQuota(int(usage.Quota))))
+		}
+		b.WriteString("</table>")
+	}
+	if len(report.TopTokens) > 0 {
+		b.WriteString("<p>消耗最多的令牌</p><table border='1' cellpadding='4' cellspacing='0'><tr><th>令牌</th><th>请求次数</th><th>消耗额度</th></tr>")
+		for _, usage := range report.TopTokens {
+			b.WriteString(fmt.Sprintf("<tr><td>%s</td><td>%d</td><td>%s</td></tr>",
+				html.EscapeString(usage.TokenName), usage.Requests, logger.FormatQuota(int(usage.Quota))))
+		}
+		b.WriteString("</table>")
+	}
+	return b.String()
+}
+
+// UsageReportWebhookPayload is the body posted to webhook subscribers.
+type UsageReportWebhookPayload struct {
+	Type      string       `json:"type"`
+	Title     string       `json:"title"`
+	Report    *UsageReport `json:"report"`
+	Timestamp int64        `json:"timestamp"`
+}
+
+// checkUsageReportTarget re-checks at delivery time that the subscription owner and the
+// reported user or tenant still exist and are enabled, and that the owner may still read
+// the report, since either may have changed after the subscription was created.
+func checkUsageReportTarget(sub *model.ReportSubscription) error {
+	owner, err := model.GetUserById(sub.UserId, false)
+	if err != nil {
+		return fmt.Errorf("subscription owner #%d no longer exists", sub.UserId)
+	}
+	if owner.Status != common.UserStatusEnabled {
+		return fmt.Errorf("subscription owner #%d is disabled", sub.UserId)
+	}
+	switch sub.Scope {
+	case model.ReportScopeUser:
+		if sub.TargetId == 0 || sub.TargetId == owner.Id {
+			return nil
+		}
+		target, err := model.GetUserById(sub.TargetId, false)
+		if err != nil {
+			return fmt.Errorf("report user #%d no longer exists", sub.TargetId)
+		}
+		if target.Status != common.UserStatusEnabled {
+			return fmt.Errorf("report user #%d is disabled", sub.TargetId)
+		}
+		if owner.Role < common.RoleAdminUser || (owner.TenantId != 0 && target.TenantId != owner.TenantId) {
+			return fmt.Errorf("subscription owner #%d may no longer read the report of user #%d", owner.Id, sub.TargetId)
+		}
+	case model.ReportScopeTenant:
+		if owner.Role < common.RoleAdminUser || (owner.TenantId != 0 && sub.TargetId != owner.TenantId) ||
+			(sub.TargetId == 0 && owner.Role != common.RoleRootUser) {
+			return fmt.Errorf("subscription owner #%d may no longer read the report of tenant #%d", owner.Id, sub.TargetId)
+		}
+		if sub.TargetId == 0 {
+			return nil
+		}
+		tenant, err := model.GetTenantById(sub.TargetId)
+		if err != nil {
+			return fmt.Errorf("report tenant #%d no longer exists", sub.TargetId)
+		}
+		if tenant.Status != model.TenantStatusEnabled {
+			return fmt.Errorf("report tenant #%d is disabled", sub.TargetId)
+		}
+	}
+	return nil
+}
+
+// DeliverUsageReport sends the report to the subscription's recipient.
+func DeliverUsageReport(ctx context.Context, sub *model.ReportSubscription, report *UsageReport) error {
+	if err := checkUsageReportTarget(sub); err != nil {
+		return err
+	}
+	switch sub.Channel {
+	case model.ReportChannelEmail:
+		var tenant *model.Tenant
+		if sub.Scope == model.ReportScopeTenant && sub.TargetId != 0 {
+			tenant = model.GetCachedTenant(sub.TargetId)
+		} else if sub.Scope == model.ReportScopeUser {
+			tenant = userTenant(sub.TargetId)
+		}
+		branding, err := ResolveBranding(tenant, nil)
+		if err != nil {
+			return err
+		}
+		return SendTemplateEmail(ctx, branding, system_setting.EmailTemplateUsageReport, sub.Recipient, map[string]string{
+			"title":  html.EscapeString(report.Title),
+			"period": report.Period,
+			"report": RenderUsageReportHTML(report),
+		})
+	case model.ReportChannelWebhook:
+		payloadBytes, err := common.Marshal(UsageReportWebhookPayload{
+			Type:      "usage_report",
+			Title:     fmt.Sprintf("%s %s", report.Title, report.Period),
+			Report:    report,
+			Timestamp: time.Now().Unix(),
+		})
+		if err != nil {
+			return err
+		}
+		return postWebhook(sub.Recipient, sub.Secret, payloadBytes)
+	default:
+		return fmt.Errorf("unknown report channel: %s", sub.Channel)
+	}
+}
+
+// StartUsageReportTask delivers the weekly and monthly usage reports of every enabled
+// subscription once per period.
+func StartUsageReportTask() {
+	usageReportOnce.Do(func() {
+		if !common.IsMasterNode {
+			return
+		}
+		gopool.Go(func() {
+			logger.LogInfo(context.Background(), fmt.Sprintf("usage report task started: tick=%s", usageReportTickInterval))
+			ticker := time.NewTicker(usageReportTickInterval)
+			defer ticker.Stop()
+
+			runUsageReportOnce(time.Now())
+			for range ticker.C {
+				runUsageReportOnce(time.Now())
+			}
+		})
+	})
+}
+
+func runUsageReportOnce(now time.Time) {
+	if !usageReportRunning.CompareAndSwap(false, true) {
+		return
+	}
+	defer usageReportRunning.Store(false)
+
+	ctx := context.Background()
+	subs, err := model.GetEnabledReportSubscriptions()
+	if err != nil {
+		logger.LogWarn(ctx, fmt.Sprintf("usage report task failed: %v", err))
+		return
+	}
+	// several recipients of the same report share one aggregation
+	reports := make(map[string]*UsageReport)
+	for _, sub := range subs {
+		period, start, end := UsageReportPeriod(sub.Frequency, now)
+		if sub.LastPeriod == period {
+			continue
+		}
+		key := fmt.Sprintf("%s-%d-%s", sub.Scope, sub.TargetId, period)
+		report, ok := reports[key]
+		if !ok {
+			report, err = BuildUsageReport(sub.Scope, sub.TargetId, period, start, end)
+			if err != nil {
+				logger.LogWarn(ctx, fmt.Sprintf("failed to build usage report %s: %v", key, err))
+				continue
+			}
+			reports[key] = report
+		}
+		// the period is marked even on failure so a broken recipient is not retried every tick
+		sendErr := DeliverUsageReport(ctx, sub, report)
+		if sendErr != nil {
+			logger.LogWarn(ctx, fmt.Sprintf("failed to deliver usage report subscription %d: %v", sub.Id, sendErr))
+		}
+		if err = model.MarkReportSubscriptionSent(sub.Id, period, sendErr); err != nil {
+			logger.LogWarn(ctx, fmt.Sprintf("failed to mark usage report subscription %d: %v", sub.Id, err))
+		}
+	}
+}
@@ -0,0 +1,122 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/logger"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/setting/system_setting"
+
+	"github.com/bytedance/gopkg/util/gopool"
+)
+
+const usageSummaryTickInterval = 1 * time.Hour
+
+var (
+	usageSummaryOnce    sync.Once
+	usageSummaryRunning atomic.Bool
+)
+
+// StartUsageSummaryEmailTask sends every user with consumption a summary of the
+// previous month, once per month, when enabled in the email settings.
+func StartUsageSummaryEmailTask() {
+	usageSummaryOnce.Do(func() {
+		if !common.IsMasterNode {
+			return
+		}
+		gopool.Go(func() {
+			logger.LogInfo(context.Background(), fmt.Sprintf("usage summary email task started: tick=%s", usageSummaryTickInterval))
+			ticker := time.NewTicker(usageSummaryTickInterval)
+			defer ticker.Stop()
+
+			runUsageSummaryEmailOnce(time.Now())
+			for range ticker.C {
+				runUsageSummaryEmailOnce(time.Now())
+			}
+		})
+	})
+}
+
+// previousMonthRange returns the label and [start, end) timestamps of the month before now.
+func previousMonthRange(now time.Time) (string, int64, int64) {
+	end := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
+	start := end.AddDate(0, -1, 0)
+	return start.Format("2006-01"), start.Unix(), end.Unix()
+}
+
+func runUsageSummaryEmailOnce(now time.Time) {
+	settings := system_setting.GetEmailSettings()
+	if !settings.UsageSummaryEnabled || !system_setting.IsEmailTemplateEnabled(system_setting.EmailTemplateUsageSummary) {
+		return
+	}
+	month, start, end := previousMonthRange(now)
+	if settings.UsageSummaryLastMonth == month {
+		return
+	}
+	if !usageSummaryRunning.CompareAndSwap(false, true) {
+		return
+	}
+	defer usageSummaryRunning.Store(false)
+
+	ctx := context.Background()
+	summaries, err := model.GetUserUsageSummaries(start, end)
+	if err != nil {
+		logger.LogWarn(ctx, fmt.Sprintf("usage summary email task failed: %v", err))
+		return
+	}
+	// mark the month first, a failure of single emails must not resend to everyone
+	if err = model.UpdateOption("email_setting.usage_summary_last_month", month); err != nil {
+		logger.LogWarn(ctx, fmt.Sprintf("usage summary email task failed to save progress: %v", err))
+		return
+	}
+	sent := 0
+	for _, summary := range summaries {
+		if summary.Requests == 0 {
+			continue
+		}
+		if err = sendUsageSummaryEmail(ctx, month, summary); err != nil {
+			logger.LogWarn(ctx, fmt.Sprintf("failed to send usage summary to user %d: %v", summary.UserId, err))
+			continue
+		}
+		sent++
+	}
+	logger.LogInfo(ctx, fmt.Sprintf("usage summary of %s sent to %d users", month, sent))
+}
+
+func sendUsageSummaryEmail(ctx context.Context, month string, summary model.UserUsageSummary) error {
+	user, err := model.GetUserById(summary.UserId, true)
+	if err != nil {
+		return err
+	}
+	if user.Status != common.UserStatusEnabled {
+		return nil
+	}
+	receiver := user.GetSetting().NotificationEmail
+	if receiver == "" {
+		receiver = user.Email
+	}
+	if receiver == "" {
+		return nil
+	}
+	var tenant *model.Tenant
+	if user.TenantId != 0 {
+		tenant = model.GetCachedTenant(user.TenantId)
+	}
+	branding, err := ResolveBranding(tenant, nil)
+	if err != nil {
+		return err
+	}
+	return SendTemplateEmail(ctx, branding, system_setting.EmailTemplateUsageSummary, receiver, map[string]string{
+		"username":     user.Username,
+		"month":        month,
+		"requests":     strconv.FormatInt(summary.Requests, 10),
+		"quota":        logger.FormatQuota(int(summary.Quota)),
+		"remain_quota": logger.FormatQuota(user.Quota),
+	})
+}
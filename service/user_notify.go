@@ -2,7 +2,9 @@ package service
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"net/url"
@@ -75,7 +77,7 @@ func NotifyUser(userId int, userEmail string, userSetting dto.UserSetting, data
 			common.SysLog(fmt.Sprintf("user %d has no email, skip sending email", userId))
 			return nil
 		}
-		return sendEmailNotify(emailToUse, data)
+		return sendEmailNotify(userId, emailToUse, data)
 	case dto.NotifyTypeWebhook:
 		webhookURLStr := userSetting.WebhookUrl
 		if webhookURLStr == "" {
@@ -105,14 +107,38 @@ func NotifyUser(userId int, userEmail string, userSetting dto.UserSetting, data
 	return nil
 }
 
-func sendEmailNotify(userEmail string, data dto.Notify) error {
+func sendEmailNotify(userId int, userEmail string, data dto.Notify) error {
 	// make email content
 	content := data.Content
 	// 处理占位符
 	for _, value := range data.Values {
 		content = strings.Replace(content, dto.ContentValueParam, fmt.Sprintf("%v", value), 1)
 	}
-	return common.SendEmail(data.Title, userEmail, content)
+	template := system_setting.EmailTemplateNotification
+	if data.Type == dto.NotifyTypeQuotaExceed {
+		template = system_setting.EmailTemplateBudgetAlert
+	}
+	branding, err := ResolveBranding(userTenant(userId), nil)
+	if err != nil {
+		return err
+	}
+	err = SendTemplateEmail(context.Background(), branding, template, userEmail, map[string]string{
+		"title":   data.Title,
+		"content": content,
+	})
+	if errors.Is(err, ErrEmailTemplateDisabled) {
+		return nil
+	}
+	return err
+}
+
+// userTenant returns the tenant the user belongs to, nil for platform users.
+func userTenant(userId int) *model.Tenant {
+	user, err := model.GetUserCache(userId)
+	if err != nil || user.TenantId == 0 {
+		return nil
+	}
+	return model.GetCachedTenant(user.TenantId)
 }
 
 func sendBarkNotify(barkURL string, data dto.Notify) error {
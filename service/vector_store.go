@@ -0,0 +1,415 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/QuantumNous/new-api/common"
+	"github.com/QuantumNous/new-api/dto"
+	"github.com/QuantumNous/new-api/logger"
+	"github.com/QuantumNous/new-api/model"
+	"github.com/QuantumNous/new-api/pkg/vectorstore"
+	relaycommon "github.com/QuantumNous/new-api/relay/common"
+	"github.com/QuantumNous/new-api/setting/ratio_setting"
+	"github.com/QuantumNous/new-api/types"
+
+	"github.com/bytedance/gopkg/util/gopool"
+	"github.com/gin-gonic/gin"
+	"github.com/shopspring/decimal"
+)
+
+const (
+	defaultVectorStoreEmbeddingModel      = "text-embedding-3-small"
+	defaultVectorStoreEmbeddingDimensions = 1536
+	// 单次嵌入请求的文本数量
+	vectorStoreEmbeddingBatchSize = 64
+	vectorStoreIngestTimeout      = 10 * time.Minute
+	DefaultFileSearchMaxResults   = 10
+	MaxFileSearchMaxResults       = 50
+	// 每个用户向量库文件的总用量（片段文本与向量）与文件数上限的默认值
+	defaultVectorStoreMaxUserBytes = 1 << 30
+	defaultVectorStoreMaxUserFiles = 10000
+)
+
+var ErrVectorStoreDisabled = errors.New("vector store is not enabled on this server")
+
+var ErrVectorStoreLimitExceeded = errors.New("vector store limit exceeded")
+
+var (
+	vectorStoreBackend             vectorstore.Backend
+	vectorStoreEmbeddingChannelId  int
+	vectorStoreEmbeddingModel      string
+	vectorStoreEmbeddingDimensions int
+	vectorStoreMaxUserBytes        int64
+	vectorStoreMaxUserFiles        int64
+)
+
+// InitVectorStore 按环境变量初始化向量存储后端，未配置 VECTOR_STORE_BACKEND 时向量库接口不可用
+func InitVectorStore() {
+	backend := common.GetEnvOrDefaultString("VECTOR_STORE_BACKEND", "")
+	if backend == "" {
+		return
+	}
+	vectorStoreEmbeddingChannelId = common.GetEnvOrDefault("VECTOR_STORE_EMBEDDING_CHANNEL_ID", 0)
+	vectorStoreEmbeddingModel = common.GetEnvOrDefaultString("VECTOR_STORE_EMBEDDING_MODEL", defaultVectorStoreEmbeddingModel)
+	vectorStoreEmbeddingDimensions = common.GetEnvOrDefault("VECTOR_STORE_EMBEDDING_DIMENSIONS", defaultVectorStoreEmbeddingDimensions)
+	vectorStoreMaxUserBytes = int64(common.GetEnvOrDefault("VECTOR_STORE_MAX_USER_MB", defaultVectorStoreMaxUserBytes>>20)) << 20
+	vectorStoreMaxUserFiles = int64(common.GetEnvOrDefault("VECTOR_STORE_MAX_USER_FILES", defaultVectorStoreMaxUserFiles))
+	if vectorStoreEmbeddingChannelId <= 0 {
+		common.SysError("vector store disabled: VECTOR_STORE_EMBEDDING_CHANNEL_ID is not set")
+		return
+	}
+
+	postgresDSN := common.GetEnvOrDefaultString("VECTOR_STORE_PG_DSN", "")
+	if postgresDSN == "" && common.UsingPostgreSQL {
+		postgresDSN = common.GetEnvOrDefaultString("SQL_DSN", "")
+	}
+	b, err := vectorstore.New(vectorstore.Config{
+		Backend:      backend,
+		PostgresDSN:  postgresDSN,
+		QdrantURL:    common.GetEnvOrDefaultString("VECTOR_STORE_QDRANT_URL", ""),
+		QdrantApiKey: common.GetEnvOrDefaultString("VECTOR_STORE_QDRANT_API_KEY", ""),
+		Collection:   common.GetEnvOrDefaultString("VECTOR_STORE_COLLECTION", ""),
+		Dimensions:   vectorStoreEmbeddingDimensions,
+	})
+	if err != nil {
+		common.SysError("failed to init vector store backend: " + err.Error())
+		return
+	}
+	vectorStoreBackend = b
+	common.SysLog(fmt.Sprintf("vector store enabled, backend: %s, embedding model: %s", backend, vectorStoreEmbeddingModel))
+}
+
+func VectorStoreEnabled() bool {
+	return vectorStoreBackend != nil
+}
+
+// embedTexts 通过配置的嵌入渠道（OpenAI 兼容接口）计算文本向量，同时返回消耗的 token 数
+func embedTexts(ctx context.Context, texts []string) ([][]float32, int, error) {
+	channel, err := model.CacheGetChannel(vectorStoreEmbeddingChannelId)
+	if err != nil {
+		return nil, 0, fmt.Errorf("get embedding channel failed: %w", err)
+	}
+	key, _, apiErr := channel.GetNextEnabledKey()
+	if apiErr != nil {
+		return nil, 0, apiErr
+	}
+	client := GetHttpClient()
+	if proxy := channel.GetSetting().Proxy; proxy != "" {
+		if client, err = GetHttpClientWithProxy(proxy); err != nil {
+			return nil, 0, err
+		}
+	}
+	endpoint := strings.TrimSuffix(channel.GetBaseURL(), "/") + "/v1/embeddings"
+
+	vectors := make([][]float32, 0, len(texts))
+	tokens := 0
+	for start := 0; start < len(texts); start += vectorStoreEmbeddingBatchSize {
+		end := min(start+vectorStoreEmbeddingBatchSize, len(texts))
+		request := dto.EmbeddingRequest{Model: vectorStoreEmbeddingModel, Input: texts[start:end]}
+		if vectorStoreEmbeddingModel != defaultVectorStoreEmbeddingModel || vectorStoreEmbeddingDimensions != defaultVectorStoreEmbeddingDimensions {
+			request.Dimensions = &vectorStoreEmbeddingDimensions
+		}
+		body, err := common.Marshal(request)
+		if err != nil {
+			return nil, 0, err
+		}
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+		if err != nil {
+			return nil, 0, err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer "+key)
+		resp, err := client.Do(req)
+		if err != nil {
+			return nil, 0, err
+		}
+		data, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return nil, 0, err
+		}
+		if resp.StatusCode != http.StatusOK {
+			return nil, 0, fmt.Errorf("embedding request failed with status %d: %s", resp.StatusCode, string(data))
+		}
+		var response dto.OpenAIEmbeddingResponse
+		if err = common.Unmarshal(data, &response); err != nil {
+			return nil, 0, err
+		}
+		if len(response.Data) != end-start {
+			return nil, 0, fmt.Errorf("embedding response has %d vectors, expected %d", len(response.Data), end-start)
+		}
+		// 上游未返回用量时按文本估算
+		if response.PromptTokens > 0 {
+			tokens += response.PromptTokens
+		} else {
+			for _, text := range texts[start:end] {
+				tokens += CountTextToken(text, vectorStoreEmbeddingModel)
+			}
+		}
+		batch := make([][]float32, end-start)
+		for _, item := range response.Data {
+			if item.Index < 0 || item.Index >= len(batch) {
+				return nil, 0, fmt.Errorf("invalid embedding index %d", item.Index)
+			}
+			if len(item.Embedding) != vectorStoreEmbeddingDimensions {
+				return nil, 0, fmt.Errorf("embedding has %d dimensions, expected %d", len(item.Embedding), vectorStoreEmbeddingDimensions)
+			}
+			vector := make([]float32, len(item.Embedding))
+			for i, v := range item.Embedding {
+				vector[i] = float32(v)
+			}
+			batch[item.Index] = vector
+		}
+		vectors = append(vectors, batch...)
+	}
+	return vectors, tokens, nil
+}
+
+// extractFileText 提取可导入的文本内容，目前仅支持 UTF-8 文本类文件
+func extractFileText(file *model.File, content []byte) (string, error) {
+	if !utf8.Valid(content) || bytes.IndexByte(content, 0) >= 0 {
+		return "", fmt.Errorf("unsupported file type: %s, only UTF-8 text files are supported", file.Filename)
+	}
+	return string(content), nil
+}
+
+// CheckVectorStoreUserLimits 检查用户加入 fileBytes 字节的文件后是否超出向量库文件数与用量上限
+func CheckVectorStoreUserLimits(userId int, fileBytes int64) error {
+	usageBytes, files, err := model.GetUserVectorStoreFileUsage(userId)
+	if err != nil {
+		return err
+	}
+	if vectorStoreMaxUserFiles > 0 && files >= vectorStoreMaxUserFiles {
+		return fmt.Errorf("%w: at most %d files can be added to vector stores", ErrVectorStoreLimitExceeded, vectorStoreMaxUserFiles)
+	}
+	if vectorStoreMaxUserBytes > 0 && usageBytes+fileBytes > vectorStoreMaxUserBytes {
+		return fmt.Errorf("%w: vector stores can use at most %d MB", ErrVectorStoreLimitExceeded, vectorStoreMaxUserBytes>>20)
+	}
+	return nil
+}
+
+// PreConsumeVectorStoreIngestion 按文件大小估算导入时计算向量消耗的 token 并通过 BillingSession 预扣，
+// 导入完成后按嵌入接口返回的用量结算，导入失败时退还
+func PreConsumeVectorStoreIngestion(c *gin.Context, fileBytes int64) (*relaycommon.RelayInfo, *types.NewAPIError) {
+	info := relaycommon.GenRelayInfoEmbedding(c, nil)
+	info.OriginModelName = vectorStoreEmbeddingModel
+	estimatedTokens := int(fileBytes/4) + 1
+	if apiErr := PreConsumeBilling(c, vectorStoreEmbeddingQuota(info, estimatedTokens), info); apiErr != nil {
+		return nil, apiErr
+	}
+	return info, nil
+}
+
+// vectorStoreEmbeddingQuota 返回计算 tokens 个 token 的向量所需的额度，按次计费的模型每次导入计费一次
+func vectorStoreEmbeddingQuota(info *relaycommon.RelayInfo, tokens int) int {
+	groupRatio := ratio_setting.GetGroupRatio(info.UsingGroup)
+	if userGroupRatio, ok := ratio_setting.GetGroupGroupRatio(info.UserGroup, info.UsingGroup); ok {
+		groupRatio = userGroupRatio
+	}
+	if price, usePrice := ratio_setting.GetModelPrice(vectorStoreEmbeddingModel, false); usePrice {
+		return int(decimal.NewFromFloat(price).
+			Mul(decimal.NewFromFloat(common.QuotaPerUnit)).
+			Mul(decimal.NewFromFloat(groupRatio)).
+			Round(0).
+			IntPart())
+	}
+	modelRatio, _, _ := ratio_setting.GetModelRatio(vectorStoreEmbeddingModel)
+	return int(decimal.NewFromInt(int64(tokens)).
+		Mul(decimal.NewFromFloat(modelRatio)).
+		Mul(decimal.NewFromFloat(groupRatio)).
+		Ceil().
+		IntPart())
+}
+
+// StartVectorStoreFileIngestion 异步切分文件、计算向量并写入后端，完成后更新文件状态，
+// 并结算 PreConsumeVectorStoreIngestion 预扣的额度
+func StartVectorStoreFileIngestion(c *gin.Context, info *relaycommon.RelayInfo, vsFile *model.VectorStoreFile) {
+	// 请求结束后 gin.Context 会被复用，异步任务使用副本
+	c = c.Copy()
+	gopool.Go(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), vectorStoreIngestTimeout)
+		defer cancel()
+		chunkCount, usageBytes, tokens, err := ingestVectorStoreFile(ctx, vsFile)
+		status := model.VectorStoreFileStatusCompleted
+		lastError := ""
+		if err != nil {
+			status = model.VectorStoreFileStatusFailed
+			lastError = err.Error()
+			common.SysError(fmt.Sprintf("vector store %s ingest file %s failed: %s", vsFile.VectorStoreId, vsFile.FileId, lastError))
+		}
+		settleVectorStoreIngestion(c, info, vsFile, tokens, err == nil)
+		if err = model.FinishVectorStoreFile(vsFile, status, chunkCount, usageBytes, lastError); err != nil {
+			common.SysError("failed to update vector store file status: " + err.Error())
+		}
+	})
+}
+
+// settleVectorStoreIngestion 导入成功时按实际 token 结算并记录消费日志，失败时退还预扣的额度
+func settleVectorStoreIngestion(c *gin.Context, info *relaycommon.RelayInfo, vsFile *model.VectorStoreFile, tokens int, succeeded bool) {
+	if !succeeded || tokens == 0 {
+		info.Billing.Refund(c)
+		return
+	}
+	quota := vectorStoreEmbeddingQuota(info, tokens)
+	if quota > 0 {
+		model.UpdateUserUsedQuotaAndRequestCount(info.UserId, quota)
+		model.UpdateChannelUsedQuota(vectorStoreEmbeddingChannelId, quota)
+	}
+	if err := SettleBilling(c, info, quota); err != nil {
+		logger.LogError(c, "error settling billing: "+err.Error())
+	}
+	model.RecordConsumeLog(c, info.UserId, model.RecordConsumeLogParams{
+		ChannelId:    vectorStoreEmbeddingChannelId,
+		PromptTokens: tokens,
+		ModelName:    vectorStoreEmbeddingModel,
+		TokenName:    c.GetString("token_name"),
+		Quota:        quota,
+		Content:      "Vector store file ingestion",
+		TokenId:      info.TokenId,
+		Group:        info.UsingGroup,
+		Other: map[string]any{
+			"vector_store_id": vsFile.VectorStoreId,
+			"file_id":         vsFile.FileId,
+		},
+	})
+}
+
+func ingestVectorStoreFile(ctx context.Context, vsFile *model.VectorStoreFile) (int, int64, int, error) {
+	if vectorStoreBackend == nil {
+		return 0, 0, 0, ErrVectorStoreDisabled
+	}
+	file, content, err := ReadGatewayFile(vsFile.FileId, vsFile.UserId)
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	text, err := extractFileText(file, content)
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	texts := vectorstore.ChunkText(text, vsFile.MaxChunkSize, vsFile.ChunkOverlap)
+	if len(texts) == 0 {
+		return 0, 0, 0, nil
+	}
+	vectors, tokens, err := embedTexts(ctx, texts)
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	chunks := make([]vectorstore.Chunk, len(texts))
+	var usageBytes int64
+	for i, chunkText := range texts {
+		chunks[i] = vectorstore.Chunk{
+			VectorStoreId: vsFile.VectorStoreId,
+			FileId:        vsFile.FileId,
+			Index:         i,
+			Text:          chunkText,
+			Vector:        vectors[i],
+		}
+		usageBytes += int64(len(chunkText)) + int64(len(vectors[i])*4)
+	}
+	// 重新导入时先清理旧片段
+	if err = vectorStoreBackend.DeleteFile(ctx, vsFile.VectorStoreId, vsFile.FileId); err != nil {
+		return 0, 0, 0, err
+	}
+	if err = vectorStoreBackend.Upsert(ctx, chunks); err != nil {
+		return 0, 0, 0, err
+	}
+	return len(chunks), usageBytes, tokens, nil
+}
+
+func DeleteVectorStoreFileChunks(ctx context.Context, vectorStoreId string, fileId string) error {
+	if vectorStoreBackend == nil {
+		return nil
+	}
+	return vectorStoreBackend.DeleteFile(ctx, vectorStoreId, fileId)
+}
+
+func DeleteVectorStoreChunks(ctx context.Context, vectorStoreId string) error {
+	if vectorStoreBackend == nil {
+		return nil
+	}
+	return vectorStoreBackend.DeleteVectorStore(ctx, vectorStoreId)
+}
+
+// FileSearchOptions file_search 检索参数
+type FileSearchOptions struct {
+	VectorStoreIds []string
+	Query          string
+	MaxResults     int
+	ScoreThreshold float64
+}
+
+// FileSearch 在用户的向量库中检索与查询最相关的片段，供 /v1/vector_stores/{id}/search 与 file_search 工具使用
+func FileSearch(ctx context.Context, userId int, options FileSearchOptions) ([]dto.VectorStoreSearchResult, error) {
+	if vectorStoreBackend == nil {
+		return nil, ErrVectorStoreDisabled
+	}
+	stores, err := model.GetVectorStoresByIds(options.VectorStoreIds, userId)
+	if err != nil {
+		return nil, err
+	}
+	if len(stores) != len(options.VectorStoreIds) {
+		return nil, errors.New("vector store not found")
+	}
+	if strings.TrimSpace(options.Query) == "" {
+		return nil, errors.New("query is required")
+	}
+	maxResults := options.MaxResults
+	if maxResults <= 0 {
+		maxResults = DefaultFileSearchMaxResults
+	}
+	maxResults = min(maxResults, MaxFileSearchMaxResults)
+
+	vectors, _, err := embedTexts(ctx, []string{options.Query})
+	if err != nil {
+		return nil, err
+	}
+	hits, err := vectorStoreBackend.Search(ctx, vectorstore.SearchQuery{
+		VectorStoreIds: options.VectorStoreIds,
+		Vector:         vectors[0],
+		Limit:          maxResults,
+		ScoreThreshold: options.ScoreThreshold,
+	})
+	if err != nil {
+		return nil, err
+	}
+	_ = model.TouchVectorStores(options.VectorStoreIds)
+
+	fileIds := make([]string, 0, len(hits))
+	for _, hit := range hits {
+		fileIds = append(fileIds, hit.FileId)
+	}
+	filenames, err := model.GetFilenames(fileIds)
+	if err != nil {
+		return nil, err
+	}
+	attributes := make(map[string]map[string]any)
+	results := make([]dto.VectorStoreSearchResult, 0, len(hits))
+	for _, hit := range hits {
+		attributesKey := hit.VectorStoreId + "/" + hit.FileId
+		attrs, ok := attributes[attributesKey]
+		if !ok {
+			attrs = make(map[string]any)
+			if vsFile, err := model.GetVectorStoreFile(hit.VectorStoreId, hit.FileId, userId); err == nil && vsFile.Attributes != "" {
+				_ = common.UnmarshalJsonStr(vsFile.Attributes, &attrs)
+			}
+			attributes[attributesKey] = attrs
+		}
+		results = append(results, dto.VectorStoreSearchResult{
+			FileId:        hit.FileId,
+			Filename:      filenames[hit.FileId],
+			Score:         hit.Score,
+			Attributes:    attrs,
+			Content:       []dto.VectorStoreSearchContent{{Type: "text", Text: hit.Text}},
+			VectorStoreId: hit.VectorStoreId,
+		})
+	}
+	return results, nil
+}
@@ -0,0 +1,28 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/QuantumNous/new-api/model"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckVectorStoreUserLimits(t *testing.T) {
+	t.Cleanup(func() { model.DB.Exec("DELETE FROM vector_store_files") })
+	maxBytes, maxFiles := vectorStoreMaxUserBytes, vectorStoreMaxUserFiles
+	t.Cleanup(func() { vectorStoreMaxUserBytes, vectorStoreMaxUserFiles = maxBytes, maxFiles })
+	vectorStoreMaxUserBytes, vectorStoreMaxUserFiles = 1000, 2
+
+	require.NoError(t, model.DB.Create(&model.VectorStoreFile{VectorStoreId: "vs_1", FileId: "file-1", UserId: 1, UsageBytes: 600}).Error)
+	require.NoError(t, model.DB.Create(&model.VectorStoreFile{VectorStoreId: "vs_1", FileId: "file-2", UserId: 2, UsageBytes: 900}).Error)
+
+	require.NoError(t, CheckVectorStoreUserLimits(1, 400))
+	require.ErrorIs(t, CheckVectorStoreUserLimits(1, 401), ErrVectorStoreLimitExceeded)
+
+	require.NoError(t, model.DB.Create(&model.VectorStoreFile{VectorStoreId: "vs_2", FileId: "file-1", UserId: 1}).Error)
+	require.ErrorIs(t, CheckVectorStoreUserLimits(1, 0), ErrVectorStoreLimitExceeded)
+
+	// limits of 0 disable the check
+	vectorStoreMaxUserBytes, vectorStoreMaxUserFiles = 0, 0
+	require.NoError(t, CheckVectorStoreUserLimits(1, 1<<40))
+}
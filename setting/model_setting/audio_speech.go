@@ -0,0 +1,45 @@
+package model_setting
+
+import "github.com/QuantumNous/new-api/setting/config"
+
+const (
+	SpeechBillingPerCharacter = "per_character"
+	SpeechBillingPerSecond    = "per_second"
+)
+
+// AudioSpeechSettings 语音合成（/v1/audio/speech）按输入字符数或生成音频时长计费
+type AudioSpeechSettings struct {
+	// 每百万字符价格（美元），按输入文本的字符数计费
+	PricePerMillionCharacters map[string]float64 `json:"price_per_million_characters"`
+	// 每秒音频价格（美元），按生成音频的时长计费，时长按秒向上取整
+	PricePerSecond map[string]float64 `json:"price_per_second"`
+}
+
+var defaultAudioSpeechSettings = AudioSpeechSettings{
+	PricePerMillionCharacters: map[string]float64{
+		"tts-1":    15,
+		"tts-1-hd": 30,
+	},
+	PricePerSecond: map[string]float64{},
+}
+
+var audioSpeechSettings = defaultAudioSpeechSettings
+
+func init() {
+	config.GlobalConfig.Register("audio_speech", &audioSpeechSettings)
+}
+
+func GetAudioSpeechSettings() *AudioSpeechSettings {
+	return &audioSpeechSettings
+}
+
+// GetPricing 返回模型的计费方式与价格，两者都配置时按字符计费，都未配置时返回 false，仍按倍率计费
+func (s *AudioSpeechSettings) GetPricing(modelName string) (billing string, price float64, ok bool) {
+	if price, ok := s.PricePerMillionCharacters[modelName]; ok {
+		return SpeechBillingPerCharacter, price, true
+	}
+	if price, ok := s.PricePerSecond[modelName]; ok {
+		return SpeechBillingPerSecond, price, true
+	}
+	return "", 0, false
+}
@@ -0,0 +1,39 @@
+package model_setting
+
+import "github.com/QuantumNous/new-api/setting/config"
+
+// AudioTranscriptionSettings 文件语音转写（/v1/audio/transcriptions、/v1/audio/translations）按时长计费
+type AudioTranscriptionSettings struct {
+	// 每分钟音频价格（美元），仅对配置了价格的模型按时长计费，其余模型仍按倍率计费
+	PricePerMinute map[string]float64 `json:"price_per_minute"`
+	// 单次请求最少计费秒数
+	MinBilledSeconds int `json:"min_billed_seconds"`
+}
+
+var defaultAudioTranscriptionSettings = AudioTranscriptionSettings{
+	PricePerMinute: map[string]float64{
+		"whisper-1":                  0.006,
+		"whisper-large-v3":           0.00185,
+		"whisper-large-v3-turbo":     0.000667,
+		"distil-whisper-large-v3-en": 0.000333,
+		"nova-2":                     0.0043,
+		"nova-3":                     0.0043,
+	},
+	MinBilledSeconds: 1,
+}
+
+var audioTranscriptionSettings = defaultAudioTranscriptionSettings
+
+func init() {
+	config.GlobalConfig.Register("audio_transcription", &audioTranscriptionSettings)
+}
+
+func GetAudioTranscriptionSettings() *AudioTranscriptionSettings {
+	return &audioTranscriptionSettings
+}
+
+// GetPricePerMinute 返回模型每分钟音频价格（美元），未配置时返回 false
+func (s *AudioTranscriptionSettings) GetPricePerMinute(modelName string) (float64, bool) {
+	price, ok := s.PricePerMinute[modelName]
+	return price, ok
+}
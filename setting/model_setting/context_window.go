@@ -0,0 +1,65 @@
+package model_setting
+
+import (
+	"strings"
+
+	"github.com/QuantumNous/new-api/setting/config"
+)
+
+// ContextWindowSettings 模型上下文窗口限制：转发前比较估算的提示词 token 与目标模型的上下文窗口，
+// 超出时按令牌或全局策略拒绝、截断或摘要
+type ContextWindowSettings struct {
+	Enabled bool `json:"enabled"`
+	// 模型上下文窗口大小（token），键以 * 结尾时按前缀匹配
+	ModelContextWindows map[string]int `json:"model_context_windows"`
+	// 令牌未设置策略时使用：reject / truncate / summarize
+	DefaultStrategy string `json:"default_strategy"`
+	// 请求未指定 max_tokens 时为输出预留的 token 数
+	ReserveOutputTokens int `json:"reserve_output_tokens"`
+	// 截断或摘要时至少保留的最近消息条数
+	KeepRecentMessages int `json:"keep_recent_messages"`
+
+	// 摘要策略使用的 OpenAI 兼容接口，失败时回退为截断
+	SummaryBaseURL   string `json:"summary_base_url"`
+	SummaryAPIKey    string `json:"summary_api_key"`
+	SummaryModel     string `json:"summary_model"`
+	SummaryTimeoutMs int    `json:"summary_timeout_ms"`
+}
+
+// 默认配置
+var defaultContextWindowSettings = ContextWindowSettings{
+	Enabled:             false,
+	ModelContextWindows: map[string]int{},
+	DefaultStrategy:     "reject",
+	ReserveOutputTokens: 1024,
+	KeepRecentMessages:  1,
+	SummaryTimeoutMs:    15000,
+}
+
+// 全局实例
+var contextWindowSettings = defaultContextWindowSettings
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("context_window", &contextWindowSettings)
+}
+
+func GetContextWindowSettings() *ContextWindowSettings {
+	return &contextWindowSettings
+}
+
+// GetModelContextWindow 返回模型的上下文窗口大小，精确匹配优先，其次为最长的前缀匹配
+func GetModelContextWindow(modelName string) (int, bool) {
+	if window, ok := contextWindowSettings.ModelContextWindows[modelName]; ok && window > 0 {
+		return window, true
+	}
+	bestPrefix, bestWindow := -1, 0
+	for pattern, window := range contextWindowSettings.ModelContextWindows {
+		prefix, ok := strings.CutSuffix(pattern, "*")
+		if !ok || window <= 0 || !strings.HasPrefix(modelName, prefix) || len(prefix) <= bestPrefix {
+			continue
+		}
+		bestPrefix, bestWindow = len(prefix), window
+	}
+	return bestWindow, bestPrefix >= 0
+}
@@ -0,0 +1,54 @@
+package model_setting
+
+import (
+	"slices"
+
+	"github.com/QuantumNous/new-api/setting/config"
+)
+
+// ConversationSummarySettings 对话摘要配置：Chat Completions 请求的对话历史超过阈值时，
+// 用低价模型总结较早的消息并以一条摘要消息替换，降低反复发送完整历史的成本
+type ConversationSummarySettings struct {
+	Enabled bool `json:"enabled"`
+	// 仅对这些模型生效，为空表示所有模型
+	Models []string `json:"models"`
+	// 对话历史估算 token 数超过该值时触发摘要
+	TriggerTokens int `json:"trigger_tokens"`
+	// 保留原文的最近消息条数
+	KeepRecentMessages int `json:"keep_recent_messages"`
+
+	// 生成摘要使用的 OpenAI 兼容接口
+	SummaryBaseURL   string `json:"summary_base_url"`
+	SummaryAPIKey    string `json:"summary_api_key"`
+	SummaryModel     string `json:"summary_model"`
+	SummaryTimeoutMs int    `json:"summary_timeout_ms"`
+}
+
+// 默认配置
+var defaultConversationSummarySettings = ConversationSummarySettings{
+	Enabled:            false,
+	Models:             []string{},
+	TriggerTokens:      16000,
+	KeepRecentMessages: 6,
+	SummaryTimeoutMs:   15000,
+}
+
+// 全局实例
+var conversationSummarySettings = defaultConversationSummarySettings
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("conversation_summary", &conversationSummarySettings)
+}
+
+func GetConversationSummarySettings() *ConversationSummarySettings {
+	return &conversationSummarySettings
+}
+
+// ShouldSummarizeConversation 判断该模型的请求是否启用对话摘要
+func ShouldSummarizeConversation(modelName string) bool {
+	if !conversationSummarySettings.Enabled || conversationSummarySettings.TriggerTokens <= 0 {
+		return false
+	}
+	return len(conversationSummarySettings.Models) == 0 || slices.Contains(conversationSummarySettings.Models, modelName)
+}
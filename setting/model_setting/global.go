@@ -36,6 +36,21 @@ type GlobalSettings struct {
 	PassThroughRequestEnabled        bool                             `json:"pass_through_request_enabled"`
 	ThinkingModelBlacklist           []string                         `json:"thinking_model_blacklist"`
 	ChatCompletionsToResponsesPolicy ChatCompletionsToResponsesPolicy `json:"chat_completions_to_responses_policy"`
+	// 自动探测 OpenAI 兼容渠道是否原生支持 /v1/responses，并据此选择原生透传或协议转换；探测会向上游发送真实请求，默认关闭
+	ResponsesCapabilityAutoDetect bool `json:"responses_capability_auto_detect"`
+	// 探测结果的有效期（小时），过期后重新探测
+	ResponsesCapabilityRecheckHours int `json:"responses_capability_recheck_hours"`
+	// 流式请求要求结构化输出（JSON 模式）时在服务端校验增量内容，发现无法恢复的非法 JSON 时提前终止流
+	StreamJSONGuardEnabled bool `json:"stream_json_guard_enabled"`
+	// Chat 转换为 Responses 时将思考内容输出为独立的 reasoning 输出项（summary），而不是 message 中的内容片段；渠道设置优先
+	ResponsesReasoningItems bool `json:"responses_reasoning_items"`
+	// 流式 Chat Completions 中只含思考内容的数据块合并发送，累积到指定字节数或经过指定毫秒后发送，正文不受影响
+	ReasoningCoalesceEnabled    bool `json:"reasoning_coalesce_enabled"`
+	ReasoningCoalesceIntervalMs int  `json:"reasoning_coalesce_interval_ms"`
+	ReasoningCoalesceBytes      int  `json:"reasoning_coalesce_bytes"`
+	// 流式响应结束时追加 SSE 注释（及 HTTP trailer），包含已发送内容的 SHA-256、字节数与补全 token 数，供客户端检测被截断或损坏的流；
+	// 客户端也可通过请求头 X-Stream-Integrity: true 单独开启
+	StreamIntegrityTrailerEnabled bool `json:"stream_integrity_trailer_enabled"`
 }
 
 // 默认配置
@@ -49,6 +64,10 @@ var defaultOpenaiSettings = GlobalSettings{
 		Enabled:     false,
 		AllChannels: true,
 	},
+	ResponsesCapabilityAutoDetect:   false,
+	ResponsesCapabilityRecheckHours: 168,
+	ReasoningCoalesceIntervalMs:     200,
+	ReasoningCoalesceBytes:          1024,
 }
 
 // 全局实例
@@ -0,0 +1,62 @@
+package model_setting
+
+import (
+	"time"
+
+	"github.com/QuantumNous/new-api/setting/config"
+)
+
+// ModelDeprecation 一个弃用模型的下线计划
+type ModelDeprecation struct {
+	// 下线日期（2006-01-02，服务器时区），当天 0 点起视为下线，为空表示仅弃用
+	SunsetDate string `json:"sunset_date"`
+	// 替代模型
+	Replacement string `json:"replacement"`
+}
+
+// SunsetTime 返回下线时间，未设置或格式错误时返回 false
+func (d ModelDeprecation) SunsetTime() (time.Time, bool) {
+	if d.SunsetDate == "" {
+		return time.Time{}, false
+	}
+	t, err := time.ParseInLocation(time.DateOnly, d.SunsetDate, time.Local)
+	return t, err == nil
+}
+
+// ModelLifecycleSettings 模型生命周期配置：请求弃用模型时附加弃用警告响应头，
+// 下线后拒绝请求，或在开启自动映射时改为请求替代模型
+type ModelLifecycleSettings struct {
+	Enabled bool `json:"enabled"`
+	// 下线后自动改为请求替代模型，关闭时拒绝请求
+	AutoMapAfterSunset bool `json:"auto_map_after_sunset"`
+	// 弃用模型，键为模型名称
+	DeprecatedModels map[string]ModelDeprecation `json:"deprecated_models"`
+}
+
+// 默认配置
+var defaultModelLifecycleSettings = ModelLifecycleSettings{
+	Enabled:            false,
+	AutoMapAfterSunset: false,
+	DeprecatedModels:   map[string]ModelDeprecation{},
+}
+
+// 全局实例
+var modelLifecycleSettings = defaultModelLifecycleSettings
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("model_lifecycle", &modelLifecycleSettings)
+}
+
+func GetModelLifecycleSettings() *ModelLifecycleSettings {
+	return &modelLifecycleSettings
+}
+
+// GetModelDeprecation 返回模型的弃用计划，未开启或模型未弃用时返回 false
+func GetModelDeprecation(modelName string) (ModelDeprecation, bool) {
+	if !modelLifecycleSettings.Enabled {
+		return ModelDeprecation{}, false
+	}
+	deprecation, ok := modelLifecycleSettings.DeprecatedModels[modelName]
+	return deprecation, ok
+}
@@ -0,0 +1,67 @@
+package model_setting
+
+import (
+	"strings"
+
+	"github.com/QuantumNous/new-api/setting/config"
+)
+
+const (
+	ModelRouterClassifierHeuristic = "heuristic"
+	ModelRouterClassifierLLM       = "llm"
+)
+
+// ModelRouterRule 描述一个候选目标模型及其启发式匹配条件，条件为空表示不限制
+type ModelRouterRule struct {
+	Model           string   `json:"model"`
+	Description     string   `json:"description,omitempty"` // 提供给 LLM 分类器的模型说明
+	MinPromptLength int      `json:"min_prompt_length,omitempty"`
+	MaxPromptLength int      `json:"max_prompt_length,omitempty"`
+	RequireTools    bool     `json:"require_tools,omitempty"`
+	Languages       []string `json:"languages,omitempty"` // zh / ja / ko / en
+}
+
+// ModelRouterSettings 虚拟模型路由配置：请求虚拟模型时按分类结果选择实际目标模型
+type ModelRouterSettings struct {
+	Enabled      bool              `json:"enabled"`
+	ModelName    string            `json:"model_name"`
+	Classifier   string            `json:"classifier"`
+	DefaultModel string            `json:"default_model"`
+	Rules        []ModelRouterRule `json:"rules"`
+
+	// LLM 分类器使用的 OpenAI 兼容接口，失败时回退到启发式规则
+	ClassifierBaseURL   string `json:"classifier_base_url"`
+	ClassifierAPIKey    string `json:"classifier_api_key"`
+	ClassifierModel     string `json:"classifier_model"`
+	ClassifierTimeoutMs int    `json:"classifier_timeout_ms"`
+}
+
+// 默认配置
+var defaultModelRouterSettings = ModelRouterSettings{
+	Enabled:             false,
+	ModelName:           "auto",
+	Classifier:          ModelRouterClassifierHeuristic,
+	Rules:               []ModelRouterRule{},
+	ClassifierTimeoutMs: 3000,
+}
+
+// 全局实例
+var modelRouterSettings = defaultModelRouterSettings
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("model_router", &modelRouterSettings)
+}
+
+func GetModelRouterSettings() *ModelRouterSettings {
+	return &modelRouterSettings
+}
+
+// IsModelRouterModel 判断请求的模型是否为启用中的虚拟路由模型
+func IsModelRouterModel(modelName string) bool {
+	if !modelRouterSettings.Enabled {
+		return false
+	}
+	name := strings.TrimSpace(modelRouterSettings.ModelName)
+	return name != "" && name == modelName
+}
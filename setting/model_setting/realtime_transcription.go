@@ -0,0 +1,48 @@
+package model_setting
+
+import "github.com/QuantumNous/new-api/setting/config"
+
+// RealtimeTranscriptionSettings 实时语音转写（WebSocket）计费与会话限制
+type RealtimeTranscriptionSettings struct {
+	// 每分钟音频价格（美元），按模型配置，未配置的模型使用默认价格
+	PricePerMinute        map[string]float64 `json:"price_per_minute"`
+	DefaultPricePerMinute float64            `json:"default_price_per_minute"`
+	// 单个会话最长音频时长（秒）
+	MaxSessionSeconds int `json:"max_session_seconds"`
+	// 客户端无音频输入超过该时长（秒）后关闭会话
+	IdleTimeoutSeconds int `json:"idle_timeout_seconds"`
+	// 每个用户同时进行的会话数上限，0 表示不限制
+	MaxConcurrentSessionsPerUser int `json:"max_concurrent_sessions_per_user"`
+}
+
+var defaultRealtimeTranscriptionSettings = RealtimeTranscriptionSettings{
+	PricePerMinute: map[string]float64{
+		"gpt-4o-transcribe":      0.006,
+		"gpt-4o-mini-transcribe": 0.003,
+		"whisper-1":              0.006,
+		"nova-2":                 0.0058,
+		"nova-3":                 0.0077,
+	},
+	DefaultPricePerMinute:        0.006,
+	MaxSessionSeconds:            3600,
+	IdleTimeoutSeconds:           30,
+	MaxConcurrentSessionsPerUser: 2,
+}
+
+var realtimeTranscriptionSettings = defaultRealtimeTranscriptionSettings
+
+func init() {
+	config.GlobalConfig.Register("realtime_transcription", &realtimeTranscriptionSettings)
+}
+
+func GetRealtimeTranscriptionSettings() *RealtimeTranscriptionSettings {
+	return &realtimeTranscriptionSettings
+}
+
+// GetPricePerMinute 返回模型每分钟音频价格（美元）
+func (s *RealtimeTranscriptionSettings) GetPricePerMinute(modelName string) float64 {
+	if price, ok := s.PricePerMinute[modelName]; ok {
+		return price
+	}
+	return s.DefaultPricePerMinute
+}
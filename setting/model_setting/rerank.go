@@ -0,0 +1,33 @@
+package model_setting
+
+import "github.com/QuantumNous/new-api/setting/config"
+
+// RerankSettings 重排序（/v1/rerank）按搜索单元计费，一个搜索单元为一次查询对最多 100 个文档的排序
+type RerankSettings struct {
+	// 每千搜索单元价格（美元），未配置的模型仍按 token 倍率计费
+	PricePerThousandSearchUnits map[string]float64 `json:"price_per_thousand_search_units"`
+}
+
+var defaultRerankSettings = RerankSettings{
+	PricePerThousandSearchUnits: map[string]float64{
+		"rerank-v3.5":              2,
+		"rerank-english-v3.0":      2,
+		"rerank-multilingual-v3.0": 2,
+	},
+}
+
+var rerankSettings = defaultRerankSettings
+
+func init() {
+	config.GlobalConfig.Register("rerank", &rerankSettings)
+}
+
+func GetRerankSettings() *RerankSettings {
+	return &rerankSettings
+}
+
+// GetPricePerThousandSearchUnits 返回模型的每千搜索单元价格，未配置时返回 false
+func (s *RerankSettings) GetPricePerThousandSearchUnits(modelName string) (float64, bool) {
+	price, ok := s.PricePerThousandSearchUnits[modelName]
+	return price, ok
+}
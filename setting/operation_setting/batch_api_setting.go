@@ -0,0 +1,30 @@
+package operation_setting
+
+import "github.com/QuantumNous/new-api/setting/config"
+
+// BatchApiSetting Batch API（/v1/batches）配置：输入文件中的请求按模型映射改写后提交到渠道的上游 Batch API，
+// 完成后按输出文件中的 usage 结算；渠道不支持 Batch 时可由网关逐行按普通请求执行（模拟模式）
+type BatchApiSetting struct {
+	Enabled bool `json:"enabled"`
+	// 渠道不支持上游 Batch 时是否由网关模拟执行，关闭时直接拒绝
+	EmulationEnabled bool `json:"emulation_enabled"`
+	// 模拟模式下单个 Batch 同时执行的请求数
+	EmulationConcurrency int `json:"emulation_concurrency"`
+	// 单个 Batch 最多包含的请求数
+	MaxRequests int `json:"max_requests"`
+}
+
+var batchApiSetting = BatchApiSetting{
+	Enabled:              true,
+	EmulationEnabled:     true,
+	EmulationConcurrency: 4,
+	MaxRequests:          50000,
+}
+
+func init() {
+	config.GlobalConfig.Register("batch_api_setting", &batchApiSetting)
+}
+
+func GetBatchApiSetting() *BatchApiSetting {
+	return &batchApiSetting
+}
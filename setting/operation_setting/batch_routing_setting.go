@@ -0,0 +1,48 @@
+package operation_setting
+
+import (
+	"slices"
+
+	"github.com/QuantumNous/new-api/setting/config"
+)
+
+// BatchRoutingSetting Batch 路由配置，开启后令牌标记为延迟不敏感的非流式 Chat Completions 请求
+// 不再实时转发，而是按渠道在窗口内聚合后提交到上游 Batch API，结果通过轮询或令牌 webhook 返回
+type BatchRoutingSetting struct {
+	Enabled bool `json:"enabled"`
+	// 仅这些模型的请求转入 Batch，为空表示不限模型
+	Models []string `json:"models"`
+	// 聚合窗口（秒），队列中最早的请求等待超过该时长后提交
+	WindowSeconds int `json:"window_seconds"`
+	// 单个上游 Batch 最多包含的请求数，达到后立即提交
+	MaxBatchSize int `json:"max_batch_size"`
+	// 上游 Batch 的完成时限
+	CompletionWindow string `json:"completion_window"`
+	// Batch 请求的计费倍率，对应上游的 Batch 折扣
+	BillingRatio float64 `json:"billing_ratio"`
+}
+
+var batchRoutingSetting = BatchRoutingSetting{
+	Enabled:          false,
+	Models:           []string{},
+	WindowSeconds:    60,
+	MaxBatchSize:     1000,
+	CompletionWindow: "24h",
+	BillingRatio:     0.5,
+}
+
+func init() {
+	config.GlobalConfig.Register("batch_routing_setting", &batchRoutingSetting)
+}
+
+func GetBatchRoutingSetting() *BatchRoutingSetting {
+	return &batchRoutingSetting
+}
+
+// ShouldRouteModelToBatch 判断该模型的请求是否允许转入 Batch
+func ShouldRouteModelToBatch(model string) bool {
+	if !batchRoutingSetting.Enabled {
+		return false
+	}
+	return len(batchRoutingSetting.Models) == 0 || slices.Contains(batchRoutingSetting.Models, model)
+}
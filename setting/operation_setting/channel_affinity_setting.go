@@ -34,30 +34,6 @@ type ChannelAffinitySetting struct {
 	Rules             []ChannelAffinityRule `json:"rules"`
 }
 
-var codexCliPassThroughHeaders = []string{
-	"Originator",
-	"Session_id",
-	"User-Agent",
-	"X-Codex-Beta-Features",
-	"X-Codex-Turn-Metadata",
-}
-
-var claudeCliPassThroughHeaders = []string{
-	"X-Stainless-Arch",
-	"X-Stainless-Lang",
-	"X-Stainless-Os",
-	"X-Stainless-Package-Version",
-	"X-Stainless-Retry-Count",
-	"X-Stainless-Runtime",
-	"X-Stainless-Runtime-Version",
-	"X-Stainless-Timeout",
-	"User-Agent",
-	"X-App",
-	"Anthropic-Beta",
-	"Anthropic-Dangerous-Direct-Browser-Access",
-	"Anthropic-Version",
-}
-
 func buildPassHeaderTemplate(headers []string) map[string]interface{} {
 	clonedHeaders := make([]string, 0, len(headers))
 	clonedHeaders = append(clonedHeaders, headers...)
@@ -0,0 +1,28 @@
+package operation_setting
+
+import "github.com/QuantumNous/new-api/setting/config"
+
+// ChannelLoadBalanceSetting 渠道负载感知选择配置：开启后同一优先级的渠道按权重随机抽取两个，
+// 选择负载更低的一个（power of two choices），负载由本节点上的进行中请求数与近期错误率计算，
+// 响应慢或频繁出错的渠道自然分到更少的请求，无需手动调整权重
+type ChannelLoadBalanceSetting struct {
+	Enabled bool `json:"enabled"`
+	// 错误率惩罚系数，错误率为 100% 的渠道负载按 1+ErrorPenalty 倍计算
+	ErrorPenalty float64 `json:"error_penalty"`
+	// 错误率半衰期（秒），渠道没有新请求时错误率按此衰减
+	ErrorHalfLifeSeconds int `json:"error_half_life_seconds"`
+}
+
+var channelLoadBalanceSetting = ChannelLoadBalanceSetting{
+	Enabled:              false,
+	ErrorPenalty:         4,
+	ErrorHalfLifeSeconds: 60,
+}
+
+func init() {
+	config.GlobalConfig.Register("channel_load_balance_setting", &channelLoadBalanceSetting)
+}
+
+func GetChannelLoadBalanceSetting() *ChannelLoadBalanceSetting {
+	return &channelLoadBalanceSetting
+}
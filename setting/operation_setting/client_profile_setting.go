@@ -0,0 +1,99 @@
+package operation_setting
+
+import "github.com/QuantumNous/new-api/common"
+
+// 客户端兼容配置：按令牌选择，集中维护 Claude Code、Codex CLI、Cursor 等客户端依赖的特殊处理，
+// 包括需要透传的请求头、Responses 兼容流的事件格式、系统提示词的处理与工具调用参数的保留
+const (
+	ClientProfileClaudeCode = "claude-code"
+	ClientProfileCodex      = "codex"
+	ClientProfileCursor     = "cursor"
+)
+
+// ClientProfile 客户端兼容配置
+type ClientProfile struct {
+	Name string `json:"name"`
+	// PassHeaders 透传到上游的客户端请求头
+	PassHeaders []string `json:"pass_headers,omitempty"`
+	// ApiVersion 令牌与请求头均未指定兼容格式版本时使用的版本
+	ApiVersion string `json:"api_version,omitempty"`
+	// ResponsesReasoningItems Chat 转换为 Responses 时思考内容输出为独立的 reasoning 输出项，优先于渠道与全局设置
+	ResponsesReasoningItems *bool `json:"responses_reasoning_items,omitempty"`
+	// SystemPromptPassThrough 保留客户端的系统提示词，仅在分组策略开启 allow_client_profile_pass_through 时生效，强制的系统提示词仍会前置
+	SystemPromptPassThrough bool `json:"system_prompt_pass_through,omitempty"`
+	// RetainToolArguments 兼容流始终保留工具调用参数，output_item.done 与 response.completed 带有完整参数
+	RetainToolArguments bool `json:"retain_tool_arguments,omitempty"`
+}
+
+var codexCliPassThroughHeaders = []string{
+	"Originator",
+	"Session_id",
+	"User-Agent",
+	"X-Codex-Beta-Features",
+	"X-Codex-Turn-Metadata",
+}
+
+var claudeCliPassThroughHeaders = []string{
+	"X-Stainless-Arch",
+	"X-Stainless-Lang",
+	"X-Stainless-Os",
+	"X-Stainless-Package-Version",
+	"X-Stainless-Retry-Count",
+	"X-Stainless-Runtime",
+	"X-Stainless-Runtime-Version",
+	"X-Stainless-Timeout",
+	"User-Agent",
+	"X-App",
+	"Anthropic-Beta",
+	"Anthropic-Dangerous-Direct-Browser-Access",
+	"Anthropic-Version",
+}
+
+var clientProfiles = map[string]ClientProfile{
+	ClientProfileClaudeCode: {
+		Name:                    ClientProfileClaudeCode,
+		PassHeaders:             claudeCliPassThroughHeaders,
+		SystemPromptPassThrough: true,
+	},
+	ClientProfileCodex: {
+		Name:                    ClientProfileCodex,
+		PassHeaders:             codexCliPassThroughHeaders,
+		ApiVersion:              "latest",
+		ResponsesReasoningItems: common.GetPointer(true),
+		SystemPromptPassThrough: true,
+		RetainToolArguments:     true,
+	},
+	ClientProfileCursor: {
+		Name:                    ClientProfileCursor,
+		ApiVersion:              "latest",
+		SystemPromptPassThrough: true,
+		RetainToolArguments:     true,
+	},
+}
+
+// GetClientProfile 获取客户端兼容配置，名称为空或不存在时返回 nil
+func GetClientProfile(name string) *ClientProfile {
+	profile, ok := clientProfiles[name]
+	if !ok {
+		return nil
+	}
+	return &profile
+}
+
+// IsValidClientProfile 判断令牌设置的客户端兼容配置是否存在，空值表示不使用
+func IsValidClientProfile(name string) bool {
+	return name == "" || GetClientProfile(name) != nil
+}
+
+// GetClientProfileNames 返回所有客户端兼容配置的名称
+func GetClientProfileNames() []string {
+	return []string{ClientProfileClaudeCode, ClientProfileCodex, ClientProfileCursor}
+}
+
+// ParamOverrideTemplate 返回透传请求头的参数覆盖模板，没有需要透传的请求头时返回 nil
+func (p *ClientProfile) ParamOverrideTemplate() map[string]interface{} {
+	if len(p.PassHeaders) == 0 {
+		return nil
+	}
+	return buildPassHeaderTemplate(p.PassHeaders)
+}
@@ -0,0 +1,30 @@
+package operation_setting
+
+import "github.com/QuantumNous/new-api/setting/config"
+
+// FeedbackRoutingSetting 按用户评价调整渠道选择：开启后定期汇总近期各渠道在各模型上的评价，
+// 同一优先级内渠道的权重乘以 1 + Strength * 评价分数（每个用户的 (好评 - 差评) / 评价数 在各用户间的平均值）
+type FeedbackRoutingSetting struct {
+	Enabled bool `json:"enabled"`
+	// 评价分数对权重的影响系数，全部为差评的渠道权重按 1-Strength 倍计算
+	Strength float64 `json:"strength"`
+	// 评价过的用户数少于该值的渠道不做调整
+	MinSamples int `json:"min_samples"`
+	// 统计最近多少小时内的评价
+	WindowHours int `json:"window_hours"`
+}
+
+var feedbackRoutingSetting = FeedbackRoutingSetting{
+	Enabled:     false,
+	Strength:    0.5,
+	MinSamples:  10,
+	WindowHours: 168,
+}
+
+func init() {
+	config.GlobalConfig.Register("feedback_routing_setting", &feedbackRoutingSetting)
+}
+
+func GetFeedbackRoutingSetting() *FeedbackRoutingSetting {
+	return &feedbackRoutingSetting
+}
@@ -0,0 +1,65 @@
+package operation_setting
+
+import (
+	"slices"
+
+	"github.com/QuantumNous/new-api/setting/config"
+)
+
+// 可按分组开放或关闭的接口类别
+const (
+	EndpointClassChat        = "chat"        // /v1/chat/completions、/v1/completions
+	EndpointClassResponses   = "responses"   // /v1/responses
+	EndpointClassMessages    = "messages"    // Claude /v1/messages
+	EndpointClassGemini      = "gemini"      // Gemini /v1beta/models
+	EndpointClassEmbeddings  = "embeddings"  // /v1/embeddings
+	EndpointClassImages      = "images"      // 图像生成与编辑
+	EndpointClassAudio       = "audio"       // 语音合成、转写与翻译
+	EndpointClassRealtime    = "realtime"    // /v1/realtime
+	EndpointClassRerank      = "rerank"      // /v1/rerank
+	EndpointClassModerations = "moderations" // /v1/moderations
+	EndpointClassVideo       = "video"       // 视频生成，包括可灵、即梦
+	EndpointClassMidjourney  = "midjourney"  // /mj
+	EndpointClassSuno        = "suno"        // /suno
+	EndpointClassFiles       = "files"       // 网关文件与向量库
+)
+
+// GroupEndpointSetting 按分组开放或关闭整类接口，在选择渠道之前拒绝请求，与模型白名单相互独立。
+// 分组取令牌分组，令牌未指定分组或为 auto 时取用户分组
+type GroupEndpointSetting struct {
+	Enabled bool `json:"enabled"`
+	// DisabledEndpoints 分组 -> 该分组禁用的接口类别
+	DisabledEndpoints map[string][]string `json:"disabled_endpoints"`
+	// RestrictedEndpoints 接口类别 -> 仅允许使用该类接口的分组，未列出的接口类别不限制
+	RestrictedEndpoints map[string][]string `json:"restricted_endpoints"`
+}
+
+// 默认配置
+var groupEndpointSetting = GroupEndpointSetting{
+	Enabled:             false,
+	DisabledEndpoints:   map[string][]string{},
+	RestrictedEndpoints: map[string][]string{},
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("group_endpoint_setting", &groupEndpointSetting)
+}
+
+func GetGroupEndpointSetting() *GroupEndpointSetting {
+	return &groupEndpointSetting
+}
+
+// IsEndpointAllowedForGroup 判断分组能否使用该类接口，未启用或接口类别为空时允许
+func IsEndpointAllowedForGroup(group string, endpointClass string) bool {
+	if !groupEndpointSetting.Enabled || endpointClass == "" {
+		return true
+	}
+	if slices.Contains(groupEndpointSetting.DisabledEndpoints[group], endpointClass) {
+		return false
+	}
+	if groups, ok := groupEndpointSetting.RestrictedEndpoints[endpointClass]; ok {
+		return slices.Contains(groups, group)
+	}
+	return true
+}
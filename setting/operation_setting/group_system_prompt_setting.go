@@ -0,0 +1,48 @@
+package operation_setting
+
+import "github.com/QuantumNous/new-api/setting/config"
+
+const (
+	ClientSystemPromptAllow  = "allow"  // 保留客户端系统提示词
+	ClientSystemPromptStrip  = "strip"  // 移除客户端系统提示词
+	ClientSystemPromptReject = "reject" // 拒绝携带系统提示词的请求
+)
+
+// GroupSystemPromptPolicy 分组系统提示词策略
+type GroupSystemPromptPolicy struct {
+	// SystemPrompt 强制前置的系统提示词，支持变量 {{user_id}} {{username}} {{user_email}} {{group}} {{token_name}}
+	SystemPrompt string `json:"system_prompt"`
+	// ClientSystemPrompt 客户端自带系统提示词的处理方式：allow / strip / reject，默认 allow
+	ClientSystemPrompt string `json:"client_system_prompt"`
+	// AllowClientProfilePassThrough 允许令牌客户端兼容配置保留客户端系统提示词，默认 false，由管理员按分组开启
+	AllowClientProfilePassThrough bool `json:"allow_client_profile_pass_through"`
+}
+
+// GroupSystemPromptSetting 分组系统提示词配置，作用于 chat、Responses 与 Claude 协议入口
+type GroupSystemPromptSetting struct {
+	Enabled  bool                               `json:"enabled"`
+	Policies map[string]GroupSystemPromptPolicy `json:"policies"`
+}
+
+// 默认配置
+var groupSystemPromptSetting = GroupSystemPromptSetting{
+	Enabled:  false,
+	Policies: map[string]GroupSystemPromptPolicy{},
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("group_system_prompt_setting", &groupSystemPromptSetting)
+}
+
+// GetGroupSystemPromptPolicy 获取分组的系统提示词策略，未启用或未配置时返回 nil
+func GetGroupSystemPromptPolicy(group string) *GroupSystemPromptPolicy {
+	if !groupSystemPromptSetting.Enabled || group == "" {
+		return nil
+	}
+	policy, ok := groupSystemPromptSetting.Policies[group]
+	if !ok {
+		return nil
+	}
+	return &policy
+}
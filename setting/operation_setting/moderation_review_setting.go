@@ -0,0 +1,38 @@
+package operation_setting
+
+import (
+	"github.com/QuantumNous/new-api/setting/config"
+)
+
+// ModerationReviewSetting 两阶段内容审核配置：提示词命中敏感词时直接拦截，命中待审词时放行，
+// 并将请求与上游响应加入人工审核队列。审核结论计入用户信任分，信任分低于阈值的用户命中待审词时同样被拦截
+type ModerationReviewSetting struct {
+	Enabled bool `json:"enabled"`
+	// 待审词，命中时放行并加入审核队列
+	FlagWords []string `json:"flag_words"`
+	// 审核记录中单个载荷最多保留的大小（KB），超出部分截断
+	MaxBodyKB int `json:"max_body_kb"`
+	// 审核通过时信任分的变化
+	ApproveTrustDelta int `json:"approve_trust_delta"`
+	// 标记用户时信任分的变化
+	FlagTrustDelta int `json:"flag_trust_delta"`
+	// 信任分低于该值的用户命中待审词时直接拦截，0 表示不拦截
+	BlockBelowTrust int `json:"block_below_trust"`
+}
+
+var moderationReviewSetting = ModerationReviewSetting{
+	Enabled:           false,
+	FlagWords:         []string{},
+	MaxBodyKB:         64,
+	ApproveTrustDelta: 5,
+	FlagTrustDelta:    -20,
+	BlockBelowTrust:   40,
+}
+
+func init() {
+	config.GlobalConfig.Register("moderation_review_setting", &moderationReviewSetting)
+}
+
+func GetModerationReviewSetting() *ModerationReviewSetting {
+	return &moderationReviewSetting
+}
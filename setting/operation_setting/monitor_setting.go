@@ -10,12 +10,20 @@ import (
 type MonitorSetting struct {
 	AutoTestChannelEnabled bool    `json:"auto_test_channel_enabled"`
 	AutoTestChannelMinutes float64 `json:"auto_test_channel_minutes"`
+	// 公开模型状态页：按模型汇总可用性与近期错误率，不暴露渠道信息
+	PublicStatusEnabled       bool    `json:"public_status_enabled"`
+	PublicStatusWindowMinutes int     `json:"public_status_window_minutes"`
+	PublicStatusDegradedRate  float64 `json:"public_status_degraded_rate"`
 }
 
 // 默认配置
 var monitorSetting = MonitorSetting{
 	AutoTestChannelEnabled: false,
 	AutoTestChannelMinutes: 10,
+
+	PublicStatusEnabled:       false,
+	PublicStatusWindowMinutes: 60,
+	PublicStatusDegradedRate:  0.2,
 }
 
 func init() {
@@ -0,0 +1,54 @@
+package operation_setting
+
+import (
+	"strings"
+
+	"github.com/QuantumNous/new-api/setting/config"
+)
+
+// ProvenanceSetting 生成内容的来源标识配置，供下游追溯内容出处：
+// 开启后在中继响应中附加 X-Generated-By 响应头，和/或在 Responses 响应对象的 metadata 中写入来源字段，
+// 标识提供服务的部署、内容策略版本与启用的内容策略标记
+type ProvenanceSetting struct {
+	// 在中继响应中附加 X-Generated-By 响应头
+	HeaderEnabled bool `json:"header_enabled"`
+	// 在非流式 Responses 响应对象的 metadata 中写入来源字段
+	MetadataEnabled bool `json:"metadata_enabled"`
+	// 部署标识，例如 prod-eu-1
+	Deployment string `json:"deployment"`
+	// 内容策略版本
+	PolicyVersion string `json:"policy_version"`
+	// 启用的内容策略标记
+	ContentPolicyFlags []string `json:"content_policy_flags"`
+}
+
+var provenanceSetting = ProvenanceSetting{
+	HeaderEnabled:      false,
+	MetadataEnabled:    false,
+	Deployment:         "",
+	PolicyVersion:      "",
+	ContentPolicyFlags: []string{},
+}
+
+func init() {
+	config.GlobalConfig.Register("provenance_setting", &provenanceSetting)
+}
+
+func GetProvenanceSetting() *ProvenanceSetting {
+	return &provenanceSetting
+}
+
+// Fields 返回非空的来源字段，键依次为 deployment、policy_version、content_policy_flags（逗号分隔）
+func (s *ProvenanceSetting) Fields() [][2]string {
+	fields := make([][2]string, 0, 3)
+	if s.Deployment != "" {
+		fields = append(fields, [2]string{"deployment", s.Deployment})
+	}
+	if s.PolicyVersion != "" {
+		fields = append(fields, [2]string{"policy_version", s.PolicyVersion})
+	}
+	if len(s.ContentPolicyFlags) > 0 {
+		fields = append(fields, [2]string{"content_policy_flags", strings.Join(s.ContentPolicyFlags, ",")})
+	}
+	return fields
+}
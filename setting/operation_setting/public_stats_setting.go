@@ -0,0 +1,37 @@
+package operation_setting
+
+import "github.com/QuantumNous/new-api/setting/config"
+
+// PublicStatsSetting 公开用量统计：对外发布各模型的请求量与延迟分布，发布前按差分隐私加入拉普拉斯噪声，
+// 并隐藏使用人数过少的模型，避免从统计中推断出单个客户的使用情况
+type PublicStatsSetting struct {
+	Enabled bool `json:"enabled"`
+	// 统计窗口的天数。窗口按天数对齐且互不重叠，每个窗口结束后只加噪发布一次并保存，各节点共用同一份结果
+	WindowDays int `json:"window_days"`
+	// k 匿名阈值：加噪后的使用人数少于该值的模型不发布
+	MinUsers int `json:"min_users"`
+	// 每个用户在一次发布中消耗的总隐私预算，越小噪声越大。预算平均分给用户计入的各个模型，
+	// 每个模型再平均分给使用人数、请求量与延迟分布三项
+	Epsilon float64 `json:"epsilon"`
+	// 单个用户在单个模型上最多计入的请求数，决定噪声的敏感度
+	ContributionCap int `json:"contribution_cap"`
+	// 单个用户最多计入的模型数，超出时只计入请求最多的模型；每个模型的隐私预算为 Epsilon / MaxModelsPerUser
+	MaxModelsPerUser int `json:"max_models_per_user"`
+}
+
+var publicStatsSetting = PublicStatsSetting{
+	Enabled:          false,
+	WindowDays:       30,
+	MinUsers:         10,
+	Epsilon:          1.0,
+	ContributionCap:  100,
+	MaxModelsPerUser: 5,
+}
+
+func init() {
+	config.GlobalConfig.Register("public_stats_setting", &publicStatsSetting)
+}
+
+func GetPublicStatsSetting() *PublicStatsSetting {
+	return &publicStatsSetting
+}
@@ -0,0 +1,25 @@
+package operation_setting
+
+import "github.com/QuantumNous/new-api/setting/config"
+
+// QuotaPoolSetting 额度池配置：赠送额度（签到、邀请奖励）与预付额度（充值、兑换码）的有效期
+type QuotaPoolSetting struct {
+	BonusExpireDays   int `json:"bonus_expire_days"`   // 赠送额度有效天数，0 表示永不过期
+	PrepaidExpireDays int `json:"prepaid_expire_days"` // 预付额度有效天数，0 表示永不过期
+}
+
+// 默认配置
+var quotaPoolSetting = QuotaPoolSetting{
+	BonusExpireDays:   0,
+	PrepaidExpireDays: 0,
+}
+
+func init() {
+	// 注册到全局配置管理器
+	config.GlobalConfig.Register("quota_pool_setting", &quotaPoolSetting)
+}
+
+// GetQuotaPoolSetting 获取额度池配置
+func GetQuotaPoolSetting() *QuotaPoolSetting {
+	return &quotaPoolSetting
+}
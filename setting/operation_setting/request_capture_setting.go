@@ -0,0 +1,51 @@
+package operation_setting
+
+import (
+	"slices"
+
+	"github.com/QuantumNous/new-api/setting/config"
+)
+
+// RequestCaptureSetting 请求捕获调试配置，开启后记录命中请求的入站载荷、各级转换载荷、
+// 上游请求与上游原始响应，供管理员按请求 ID 对比排查
+type RequestCaptureSetting struct {
+	Enabled bool `json:"enabled"`
+	// 仅捕获这些用户的请求，为空表示不限用户
+	UserIds []int `json:"user_ids"`
+	// 仅捕获这些模型的请求，为空表示不限模型
+	Models []string `json:"models"`
+	// 单个载荷最多保留的大小（KB），超出部分截断
+	MaxBodyKB int `json:"max_body_kb"`
+	// 捕获记录保留时长（小时）
+	RetentionHours int `json:"retention_hours"`
+}
+
+var requestCaptureSetting = RequestCaptureSetting{
+	Enabled:        false,
+	UserIds:        []int{},
+	Models:         []string{},
+	MaxBodyKB:      256,
+	RetentionHours: 24,
+}
+
+func init() {
+	config.GlobalConfig.Register("request_capture_setting", &requestCaptureSetting)
+}
+
+func GetRequestCaptureSetting() *RequestCaptureSetting {
+	return &requestCaptureSetting
+}
+
+// ShouldCaptureRequest 判断该用户、模型的请求是否需要捕获
+func ShouldCaptureRequest(userId int, model string) bool {
+	if !requestCaptureSetting.Enabled {
+		return false
+	}
+	if len(requestCaptureSetting.UserIds) > 0 && !slices.Contains(requestCaptureSetting.UserIds, userId) {
+		return false
+	}
+	if len(requestCaptureSetting.Models) > 0 && !slices.Contains(requestCaptureSetting.Models, model) {
+		return false
+	}
+	return true
+}
@@ -5,11 +5,19 @@ import "github.com/QuantumNous/new-api/setting/config"
 // TokenSetting 令牌相关配置
 type TokenSetting struct {
 	MaxUserTokens int `json:"max_user_tokens"` // 每用户最大令牌数量
+
+	TemporaryTokenDefaultTTL   int `json:"temporary_token_default_ttl"`   // 临时令牌未指定有效期时的默认有效期（秒）
+	TemporaryTokenMaxTTL       int `json:"temporary_token_max_ttl"`       // 临时令牌的最长有效期（秒）
+	TemporaryTokenDefaultQuota int `json:"temporary_token_default_quota"` // 临时令牌未指定额度时的默认额度
 }
 
 // 默认配置
 var tokenSetting = TokenSetting{
 	MaxUserTokens: 1000, // 默认每用户最多 1000 个令牌
+
+	TemporaryTokenDefaultTTL:   3600,
+	TemporaryTokenMaxTTL:       86400,
+	TemporaryTokenDefaultQuota: 500000, // 默认 $1
 }
 
 func init() {
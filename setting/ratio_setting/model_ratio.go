@@ -243,6 +243,10 @@ var defaultModelRatio = map[string]float64{
 	"deepseek-chat":          0.27 / 2,
 	"deepseek-coder":         0.27 / 2,
 	"deepseek-reasoner":      0.55 / 2, // 0.55 / 1k tokens
+	// cohere
+	"command-a-03-2025":          1.25,
+	"command-r7b-12-2024":        0.01875,
+	"command-r7b-arabic-02-2025": 0.01875,
 	// Perplexity online 模型对搜索额外收费，有需要应自行调整，此处不计入搜索费用
 	"llama-3-sonar-small-32k-chat":   0.2 / 1000 * USD,
 	"llama-3-sonar-small-32k-online": 0.2 / 1000 * USD,
@@ -589,6 +593,8 @@ func getHardcodedCompletionModelRatio(name string) (float64, bool) {
 			return 4, true
 		case "command-r-plus-08-2024":
 			return 4, true
+		case "command-a-03-2025", "command-r7b-12-2024", "command-r7b-arabic-02-2025":
+			return 4, true
 		default:
 			return 4, false
 		}
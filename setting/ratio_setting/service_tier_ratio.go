@@ -0,0 +1,41 @@
+package ratio_setting
+
+import (
+	"github.com/QuantumNous/new-api/setting/config"
+)
+
+// ServiceTierRatioSetting OpenAI service_tier 计费倍率，按上游实际提供的层级乘到最终费用上。
+// 键为服务层级（flex / priority），或 "模型名:服务层级" 以覆盖单个模型
+type ServiceTierRatioSetting struct {
+	ServiceTierRatio map[string]float64 `json:"service_tier_ratio"`
+}
+
+var serviceTierRatioSetting = ServiceTierRatioSetting{
+	ServiceTierRatio: map[string]float64{
+		"flex":     0.5,
+		"priority": 2,
+	},
+}
+
+func init() {
+	config.GlobalConfig.Register("service_tier_ratio_setting", &serviceTierRatioSetting)
+}
+
+func GetServiceTierRatioSetting() *ServiceTierRatioSetting {
+	return &serviceTierRatioSetting
+}
+
+// GetServiceTierRatio 返回模型在该服务层级下的计费倍率，未配置时返回 false
+func GetServiceTierRatio(modelName string, tier string) (float64, bool) {
+	if tier == "" {
+		return 1, false
+	}
+	if ratio, ok := serviceTierRatioSetting.ServiceTierRatio[modelName+":"+tier]; ok {
+		return ratio, true
+	}
+	ratio, ok := serviceTierRatioSetting.ServiceTierRatio[tier]
+	if !ok {
+		return 1, false
+	}
+	return ratio, true
+}
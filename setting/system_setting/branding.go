@@ -0,0 +1,39 @@
+package system_setting
+
+import "github.com/QuantumNous/new-api/setting/config"
+
+type BrandingFooterLink struct {
+	Label string `json:"label"`
+	URL   string `json:"url"`
+}
+
+// BrandingEmailTemplate 邮件模板，键为 EmailTemplate* 模板类型，支持 {{system_name}} {{minutes}} 及各模板自身的变量
+type BrandingEmailTemplate struct {
+	Subject string `json:"subject"`
+	Body    string `json:"body"`
+}
+
+// BrandingSettings 控制台白标配置，站点名称、Logo、页脚沿用原有的 SystemName / Logo / Footer 选项。
+// 租户可在租户配置中覆盖其中任意非空字段
+type BrandingSettings struct {
+	PrimaryColor    string                           `json:"primary_color"`
+	AccentColor     string                           `json:"accent_color"`
+	BackgroundColor string                           `json:"background_color"`
+	FaviconURL      string                           `json:"favicon_url"`
+	DocsURL         string                           `json:"docs_url"`
+	FooterLinks     []BrandingFooterLink             `json:"footer_links"`
+	EmailTemplates  map[string]BrandingEmailTemplate `json:"email_templates"`
+}
+
+var defaultBrandingSettings = BrandingSettings{
+	FooterLinks:    []BrandingFooterLink{},
+	EmailTemplates: map[string]BrandingEmailTemplate{},
+}
+
+func init() {
+	config.GlobalConfig.Register("branding", &defaultBrandingSettings)
+}
+
+func GetBrandingSettings() *BrandingSettings {
+	return &defaultBrandingSettings
+}
@@ -0,0 +1,34 @@
+package system_setting
+
+import "github.com/QuantumNous/new-api/setting/config"
+
+// DatasetExportSettings 训练数据集导出配置：导出到 S3（或兼容 S3 的对象存储）时使用的存储桶与凭证，
+// 以及单个数据集文件的大小上限
+type DatasetExportSettings struct {
+	// 兼容 S3 的对象存储地址（如 https://minio.example.com），留空时使用 AWS S3
+	S3Endpoint     string `json:"s3_endpoint"`
+	S3Region       string `json:"s3_region"`
+	S3Bucket       string `json:"s3_bucket"`
+	S3Prefix       string `json:"s3_prefix"`
+	S3AccessKeyId  string `json:"s3_access_key_id"`
+	S3AccessSecret string `json:"s3_access_secret"`
+	MaxFileSizeMB  int    `json:"max_file_size_mb"` // 单个数据集文件的大小上限，0 表示不限制
+}
+
+var defaultDatasetExportSettings = DatasetExportSettings{
+	S3Region:      "us-east-1",
+	MaxFileSizeMB: 512,
+}
+
+func init() {
+	config.GlobalConfig.Register("dataset_export", &defaultDatasetExportSettings)
+}
+
+func GetDatasetExportSettings() *DatasetExportSettings {
+	return &defaultDatasetExportSettings
+}
+
+// S3Configured 判断是否已配置导出到 S3 所需的存储桶与凭证
+func (s *DatasetExportSettings) S3Configured() bool {
+	return s.S3Bucket != "" && s.S3AccessKeyId != "" && s.S3AccessSecret != ""
+}
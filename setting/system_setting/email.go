@@ -0,0 +1,59 @@
+package system_setting
+
+import "github.com/QuantumNous/new-api/setting/config"
+
+const (
+	EmailProviderSMTP     = "smtp"
+	EmailProviderSendGrid = "sendgrid"
+	EmailProviderSES      = "ses"
+)
+
+// 邮件模板类型
+const (
+	EmailTemplateVerification  = "verification"
+	EmailTemplatePasswordReset = "password_reset"
+	EmailTemplateBudgetAlert   = "budget_alert"
+	EmailTemplateUsageSummary  = "usage_summary"
+	EmailTemplateUsageReport   = "usage_report"
+	EmailTemplateNotification  = "notification"
+)
+
+// EmailSettings 邮件发送配置，SMTP 沿用原有 SMTP* 选项
+type EmailSettings struct {
+	Provider string `json:"provider"`
+	// SendGrid / SES 使用的发件地址，留空时使用 SMTPFrom
+	FromAddress string `json:"from_address"`
+
+	SendGridAPIKey string `json:"sendgrid_api_key"`
+
+	SESRegion       string `json:"ses_region"`
+	SESAccessKeyId  string `json:"ses_access_key_id"`
+	SESAccessSecret string `json:"ses_access_secret"`
+
+	// 按模板启用/禁用，未配置的模板默认启用
+	TemplateEnabled map[string]bool `json:"template_enabled"`
+
+	// 每月 1 日向有消耗的用户发送上月用量汇总
+	UsageSummaryEnabled bool `json:"usage_summary_enabled"`
+	// 最近一次已发送汇总的月份（如 2026-09），由系统维护
+	UsageSummaryLastMonth string `json:"usage_summary_last_month"`
+}
+
+var defaultEmailSettings = EmailSettings{
+	Provider:        EmailProviderSMTP,
+	TemplateEnabled: map[string]bool{},
+}
+
+func init() {
+	config.GlobalConfig.Register("email_setting", &defaultEmailSettings)
+}
+
+func GetEmailSettings() *EmailSettings {
+	return &defaultEmailSettings
+}
+
+// IsEmailTemplateEnabled 判断模板是否启用
+func IsEmailTemplateEnabled(template string) bool {
+	enabled, ok := defaultEmailSettings.TemplateEnabled[template]
+	return !ok || enabled
+}
@@ -0,0 +1,35 @@
+package system_setting
+
+import "github.com/QuantumNous/new-api/setting/config"
+
+// FileStorageSettings /v1/files 上传文件的内容存储方式，文件元信息与归属始终保存在主库：
+// database 保存在主库；local 保存在 FILE_STORAGE_DIR 目录（多节点须为共享挂载）；
+// s3 保存在 S3（或兼容 S3 的对象存储）；upstream 直接上传到指定的 OpenAI 渠道，读取与删除转发到该渠道。
+// 修改后只影响之后上传的文件，已有文件仍从原存储读取
+type FileStorageSettings struct {
+	// 留空时设置了 FILE_STORAGE_DIR 则为 local，否则为 database
+	Backend string `json:"backend"`
+
+	// 兼容 S3 的对象存储地址（如 https://minio.example.com），留空时使用 AWS S3
+	S3Endpoint     string `json:"s3_endpoint"`
+	S3Region       string `json:"s3_region"`
+	S3Bucket       string `json:"s3_bucket"`
+	S3Prefix       string `json:"s3_prefix"`
+	S3AccessKeyId  string `json:"s3_access_key_id"`
+	S3AccessSecret string `json:"s3_access_secret"`
+
+	// upstream 方式上传到的渠道，使用渠道的第一个密钥
+	UpstreamChannelId int `json:"upstream_channel_id"`
+}
+
+var defaultFileStorageSettings = FileStorageSettings{
+	S3Region: "us-east-1",
+}
+
+func init() {
+	config.GlobalConfig.Register("file_storage", &defaultFileStorageSettings)
+}
+
+func GetFileStorageSettings() *FileStorageSettings {
+	return &defaultFileStorageSettings
+}
@@ -0,0 +1,27 @@
+package system_setting
+
+import "github.com/QuantumNous/new-api/setting/config"
+
+// TelegramBotSettings 管理员 Telegram 机器人配置，复用 TelegramBotToken，
+// 仅已绑定 Telegram 的平台管理员可以使用
+type TelegramBotSettings struct {
+	Enabled bool `json:"enabled"`
+	// 错误突增检测窗口（分钟），与上一个等长窗口对比
+	ErrorWindowMinutes int `json:"error_window_minutes"`
+	// 待确认操作的有效期（秒）
+	ApprovalTimeoutSeconds int `json:"approval_timeout_seconds"`
+}
+
+var defaultTelegramBotSettings = TelegramBotSettings{
+	Enabled:                false,
+	ErrorWindowMinutes:     60,
+	ApprovalTimeoutSeconds: 300,
+}
+
+func init() {
+	config.GlobalConfig.Register("telegram_bot", &defaultTelegramBotSettings)
+}
+
+func GetTelegramBotSettings() *TelegramBotSettings {
+	return &defaultTelegramBotSettings
+}
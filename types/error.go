@@ -64,9 +64,13 @@ const (
 	ErrorCodeReadRequestBodyFailed ErrorCode = "read_request_body_failed"
 	ErrorCodeConvertRequestFailed  ErrorCode = "convert_request_failed"
 	ErrorCodeAccessDenied          ErrorCode = "access_denied"
+	ErrorCodeUnsupportedFeature    ErrorCode = "unsupported_feature"
+	ErrorCodeRateLimitExceeded     ErrorCode = "rate_limit_exceeded"
+	ErrorCodeRateLimitCheckFailed  ErrorCode = "rate_limit_check_failed"
 
 	// request error
-	ErrorCodeBadRequestBody ErrorCode = "bad_request_body"
+	ErrorCodeBadRequestBody        ErrorCode = "bad_request_body"
+	ErrorCodeContextLengthExceeded ErrorCode = "context_length_exceeded"
 
 	// response error
 	ErrorCodeReadResponseBodyFailed ErrorCode = "read_response_body_failed"
@@ -74,6 +78,7 @@ const (
 	ErrorCodeBadResponse            ErrorCode = "bad_response"
 	ErrorCodeBadResponseBody        ErrorCode = "bad_response_body"
 	ErrorCodeEmptyResponse          ErrorCode = "empty_response"
+	ErrorCodeInvalidJSONStream      ErrorCode = "invalid_json_stream"
 	ErrorCodeAwsInvokeError         ErrorCode = "aws_invoke_error"
 	ErrorCodeModelNotFound          ErrorCode = "model_not_found"
 	ErrorCodePromptBlocked          ErrorCode = "prompt_blocked"
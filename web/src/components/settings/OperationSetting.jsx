@@ -27,6 +27,7 @@ import SettingsLog from '../../pages/Setting/Operation/SettingsLog';
 import SettingsMonitoring from '../../pages/Setting/Operation/SettingsMonitoring';
 import SettingsCreditLimit from '../../pages/Setting/Operation/SettingsCreditLimit';
 import SettingsCheckin from '../../pages/Setting/Operation/SettingsCheckin';
+import SettingsQuotaPool from '../../pages/Setting/Operation/SettingsQuotaPool';
 import { API, showError, toBoolean } from '../../helpers';
 
 const OperationSetting = () => {
@@ -79,6 +80,10 @@ const OperationSetting = () => {
     'checkin_setting.min_quota': 1000,
     'checkin_setting.max_quota': 10000,
 
+    /* 额度池设置 */
+    'quota_pool_setting.bonus_expire_days': 0,
+    'quota_pool_setting.prepaid_expire_days': 0,
+
     /* 令牌设置 */
     'token_setting.max_user_tokens': 1000,
   });
@@ -154,6 +159,10 @@ const OperationSetting = () => {
         <Card style={{ marginTop: '10px' }}>
           <SettingsCheckin options={inputs} refresh={onRefresh} />
         </Card>
+        {/* 额度池设置 */}
+        <Card style={{ marginTop: '10px' }}>
+          <SettingsQuotaPool options={inputs} refresh={onRefresh} />
+        </Card>
       </Spin>
     </>
   );
@@ -99,6 +99,7 @@ const REGION_EXAMPLE = {
   'gemini-1.5-pro-002': 'europe-west2',
   'gemini-1.5-flash-002': 'europe-west2',
   'claude-3-5-sonnet-20240620': 'europe-west1',
+  'claude-*': 'us-east5',
 };
 const UPSTREAM_DETECTED_MODEL_PREVIEW_LIMIT = 8;
 
@@ -206,6 +207,9 @@ const EditChannelModal = (props) => {
     claude_beta_query: false,
     upstream_model_update_check_enabled: false,
     upstream_model_update_auto_sync_enabled: false,
+    model_auto_discovery: false,
+    ollama_keep_alive: '',
+    ollama_num_ctx: 0,
     upstream_model_update_last_check_time: 0,
     upstream_model_update_last_detected_models: [],
     upstream_model_update_ignored_models: '',
@@ -889,6 +893,10 @@ const EditChannelModal = (props) => {
             parsedSettings.upstream_model_update_check_enabled === true;
           data.upstream_model_update_auto_sync_enabled =
             parsedSettings.upstream_model_update_auto_sync_enabled === true;
+          data.model_auto_discovery =
+            parsedSettings.model_auto_discovery === true;
+          data.ollama_keep_alive = parsedSettings.ollama_keep_alive || '';
+          data.ollama_num_ctx = Number(parsedSettings.ollama_num_ctx) || 0;
           data.upstream_model_update_last_check_time =
             Number(parsedSettings.upstream_model_update_last_check_time) || 0;
           data.upstream_model_update_last_detected_models = Array.isArray(
@@ -916,6 +924,9 @@ const EditChannelModal = (props) => {
           data.claude_beta_query = false;
           data.upstream_model_update_check_enabled = false;
           data.upstream_model_update_auto_sync_enabled = false;
+          data.model_auto_discovery = false;
+          data.ollama_keep_alive = '';
+          data.ollama_num_ctx = 0;
           data.upstream_model_update_last_check_time = 0;
           data.upstream_model_update_last_detected_models = [];
           data.upstream_model_update_ignored_models = '';
@@ -933,6 +944,9 @@ const EditChannelModal = (props) => {
         data.claude_beta_query = false;
         data.upstream_model_update_check_enabled = false;
         data.upstream_model_update_auto_sync_enabled = false;
+        data.model_auto_discovery = false;
+        data.ollama_keep_alive = '';
+        data.ollama_num_ctx = 0;
         data.upstream_model_update_last_check_time = 0;
         data.upstream_model_update_last_detected_models = [];
         data.upstream_model_update_ignored_models = '';
@@ -1709,8 +1723,8 @@ const EditChannelModal = (props) => {
         localInputs.is_enterprise_account === true;
     }
 
-    // type === 33 (AWS): 保存 aws_key_type 到 settings
-    if (localInputs.type === 33) {
+    // type === 33 (AWS) / 58 (AWS Bedrock Converse): 保存 aws_key_type 到 settings
+    if (localInputs.type === 33 || localInputs.type === 58) {
       settings.aws_key_type = localInputs.aws_key_type || 'ak_sk';
     }
 
@@ -1743,6 +1757,19 @@ const EditChannelModal = (props) => {
     settings.upstream_model_update_auto_sync_enabled =
       settings.upstream_model_update_check_enabled &&
       localInputs.upstream_model_update_auto_sync_enabled === true;
+    settings.model_auto_discovery =
+      MODEL_FETCHABLE_CHANNEL_TYPES.has(localInputs.type) &&
+      localInputs.model_auto_discovery === true;
+    // type === 4 (Ollama): 设置模型保留时长与上下文窗口
+    if (localInputs.type === 4) {
+      settings.ollama_keep_alive = String(
+        localInputs.ollama_keep_alive || '',
+      ).trim();
+      settings.ollama_num_ctx = Number(localInputs.ollama_num_ctx) || 0;
+    } else {
+      delete settings.ollama_keep_alive;
+      delete settings.ollama_num_ctx;
+    }
     settings.upstream_model_update_ignored_models = Array.from(
       new Set(
         String(localInputs.upstream_model_update_ignored_models || '')
@@ -1784,6 +1811,9 @@ const EditChannelModal = (props) => {
     delete localInputs.claude_beta_query;
     delete localInputs.upstream_model_update_check_enabled;
     delete localInputs.upstream_model_update_auto_sync_enabled;
+    delete localInputs.model_auto_discovery;
+    delete localInputs.ollama_keep_alive;
+    delete localInputs.ollama_num_ctx;
     delete localInputs.upstream_model_update_last_check_time;
     delete localInputs.upstream_model_update_last_detected_models;
     delete localInputs.upstream_model_update_ignored_models;
@@ -2268,7 +2298,7 @@ const EditChannelModal = (props) => {
                       autoComplete='new-password'
                     />
 
-                    {inputs.type === 33 && (
+                    {(inputs.type === 33 || inputs.type === 58) && (
                       <>
                         <Form.Select
                           field='aws_key_type'
@@ -2365,7 +2395,7 @@ const EditChannelModal = (props) => {
                           field='key'
                           label={t('密钥')}
                           placeholder={
-                            inputs.type === 33
+                            inputs.type === 33 || inputs.type === 58
                               ? inputs.aws_key_type === 'api_key'
                                 ? t(
                                     '请输入 API Key，一行一个，格式：APIKey|Region',
@@ -2663,7 +2693,7 @@ const EditChannelModal = (props) => {
                                 : t('密钥')
                             }
                             placeholder={
-                              inputs.type === 33
+                              inputs.type === 33 || inputs.type === 58
                                 ? inputs.aws_key_type === 'api_key'
                                   ? t('请输入 API Key，格式：APIKey|Region')
                                   : t(
@@ -2971,6 +3001,40 @@ const EditChannelModal = (props) => {
                         </>
                       )}
 
+                      {inputs.type === 4 && (
+                        <>
+                          <div>
+                            <Form.Input
+                              field='ollama_keep_alive'
+                              label={t('模型保留时长（keep_alive）')}
+                              placeholder={t('例如：5m、1h，-1 表示常驻内存')}
+                              onChange={(value) =>
+                                handleChannelOtherSettingsChange(
+                                  'ollama_keep_alive',
+                                  value,
+                                )
+                              }
+                              showClear
+                            />
+                          </div>
+                          <div>
+                            <Form.InputNumber
+                              field='ollama_num_ctx'
+                              label={t('上下文窗口大小（num_ctx）')}
+                              placeholder={t('为空则使用模型默认值')}
+                              min={0}
+                              onChange={(value) =>
+                                handleChannelOtherSettingsChange(
+                                  'ollama_num_ctx',
+                                  value || 0,
+                                )
+                              }
+                              style={{ width: '100%' }}
+                            />
+                          </div>
+                        </>
+                      )}
+
                       {inputs.type === 37 && (
                         <Banner
                           type='warning'
@@ -3285,6 +3349,21 @@ const EditChannelModal = (props) => {
                             '开启后由后端定时任务检测该渠道上游模型变化',
                           )}
                         />
+                        <Form.Switch
+                          field='model_auto_discovery'
+                          label={t('自动发现模型')}
+                          checkedText={t('开')}
+                          uncheckedText={t('关')}
+                          onChange={(value) =>
+                            handleChannelOtherSettingsChange(
+                              'model_auto_discovery',
+                              value,
+                            )
+                          }
+                          extraText={t(
+                            '开启后定时拉取上游模型列表，自动加入新模型并移除已下线的模型，适用于 Ollama 等自托管服务',
+                          )}
+                        />
                         <div className='text-xs text-gray-500 mb-2'>
                           {t('上次检测时间')}:&nbsp;
                           {formatUnixTime(
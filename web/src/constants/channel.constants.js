@@ -190,11 +190,21 @@ export const CHANNEL_OPTIONS = [
     color: 'blue',
     label: 'Codex (OpenAI OAuth)',
   },
+  {
+    value: 58,
+    color: 'orange',
+    label: 'AWS Bedrock Converse',
+  },
+  {
+    value: 59,
+    color: 'green',
+    label: 'Self-Hosted (OpenAI Compatible)',
+  },
 ];
 
 // Channel types that support upstream model list fetching in UI.
 export const MODEL_FETCHABLE_CHANNEL_TYPES = new Set([
-  1, 4, 14, 34, 17, 26, 27, 24, 47, 25, 20, 23, 31, 40, 42, 48, 43,
+  1, 4, 14, 34, 17, 26, 27, 24, 47, 25, 20, 23, 31, 40, 42, 48, 43, 59,
 ]);
 
 export const MODEL_TABLE_PAGE_SIZE = 10;